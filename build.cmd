@@ -2,4 +2,4 @@ set GOOS=windows
 set GOARCH=amd64
 
 go build -ldflags "-s -w" -o bin/campusserver.exe ./cmd/server
-go build -ldflags "-s -w" --buildmode=c-shared -o bin/libcampusserver.dll ./cmd/server
\ No newline at end of file
+go build -ldflags "-s -w" -tags cshared --buildmode=c-shared -o bin/libcampusserver.dll ./cmd/server
\ No newline at end of file
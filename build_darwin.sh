@@ -1,4 +1,5 @@
 export GOOS=darwin
 export GOARCH=arm64
 
-go build -ldflags "-s -w" -o bin/campusserver ./cmd/server
\ No newline at end of file
+go build -ldflags "-s -w" -o bin/campusserver ./cmd/server
+CGO_ENABLED=1 go build -ldflags "-s -w" -tags cshared -buildmode=c-shared -o bin/libcampusserver.dylib ./cmd/server
@@ -15,8 +15,10 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
@@ -30,6 +32,7 @@ import (
 	"github.com/livekit/protocol/utils"
 
 	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/recording"
 	"github.com/livekit/livekit-server/pkg/routing"
 	"github.com/livekit/livekit-server/pkg/service"
 )
@@ -159,6 +162,52 @@ func createToken(c *cli.Context) error {
 	return nil
 }
 
+func decryptRecording(c *cli.Context) error {
+	conf, err := getConfig(c)
+	if err != nil {
+		return err
+	}
+
+	dir := c.String("dir")
+	out := c.String("out")
+	if out == "" {
+		out = filepath.Join(dir, "decrypted")
+	}
+	files, err := recording.DecryptRecording(c.Context, conf.Recording.Encryption, dir, out)
+	for _, f := range files {
+		fmt.Println("decrypted", f)
+	}
+	return err
+}
+
+func migrateConfig(c *cli.Context) error {
+	confString, err := getConfigString(c.String("config"), "")
+	if err != nil {
+		return err
+	}
+	if confString == "" {
+		return errors.New("a config is required, pass it with --config")
+	}
+
+	migrated, changes, err := config.MigrateConfig(confString)
+	// changes go to stderr, so that the migrated config can be redirected
+	for _, change := range changes {
+		fmt.Fprintln(os.Stderr, change.String())
+	}
+	if err != nil {
+		return err
+	}
+	if len(changes) == 0 {
+		fmt.Fprintln(os.Stderr, "config is up to date")
+	}
+
+	if out := c.String("out"); out != "" {
+		return os.WriteFile(out, []byte(migrated), 0600)
+	}
+	fmt.Print(migrated)
+	return nil
+}
+
 func listNodes(c *cli.Context) error {
 	conf, err := getConfig(c)
 	if err != nil {
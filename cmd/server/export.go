@@ -0,0 +1,384 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build cshared
+
+// C API of the server, for embedding it into native applications. Build with
+//
+//	go build -tags cshared -buildmode=c-shared -o bin/libcampusserver.dll ./cmd/server
+//
+// which also generates the matching header. Strings returned by the API are owned by the caller and
+// released with LiveKitFree.
+package main
+
+/*
+#include <stdlib.h>
+
+// event is the webhook event name, e.g. room_started or participant_joined, payload the event as JSON.
+// both are only valid during the call
+typedef void (*LiveKitEventCallback)(const char *event, const char *payload, void *user_data);
+
+static inline void livekitInvokeEventCallback(LiveKitEventCallback cb, const char *event, const char *payload, void *user_data) {
+	cb(event, payload, user_data);
+}
+*/
+import "C"
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+	"unsafe"
+
+	"google.golang.org/protobuf/encoding/protojson"
+
+	"github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/logger"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/logging"
+	"github.com/livekit/livekit-server/pkg/routing"
+	"github.com/livekit/livekit-server/pkg/service"
+	"github.com/livekit/livekit-server/pkg/telemetry"
+	"github.com/livekit/livekit-server/pkg/telemetry/prometheus"
+)
+
+// return codes of the C API
+const (
+	resultOK             = 0
+	resultError          = -1
+	resultAlreadyRunning = -2
+	resultNotRunning     = -3
+)
+
+// server states returned by LiveKitStatus
+const (
+	statusStopped = iota
+	statusStarting
+	statusRunning
+	statusStopping
+)
+
+const embeddedStartTimeout = 30 * time.Second
+
+var (
+	errAlreadyRunning = errors.New("server is already running")
+	errNotRunning     = errors.New("server is not running")
+	errStartTimeout   = errors.New("server did not start in time")
+
+	embedded = &embeddedServer{}
+)
+
+type embeddedServer struct {
+	lock       sync.Mutex
+	server     *service.LivekitServer
+	done       chan struct{}
+	stopping   bool
+	configFile string
+	configBody string
+	lastErr    error
+
+	prometheusOnce sync.Once
+
+	callbackLock sync.RWMutex
+	callback     C.LiveKitEventCallback
+	userData     unsafe.Pointer
+}
+
+func (e *embeddedServer) start(configFile, configBody string) error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if e.statusLocked() != statusStopped {
+		return errAlreadyRunning
+	}
+	e.configFile, e.configBody = configFile, configBody
+
+	confString, err := getConfigString(configFile, configBody)
+	if err != nil {
+		return err
+	}
+	conf, err := config.NewConfig(confString, true, nil, nil)
+	if err != nil {
+		return err
+	}
+	if err = logging.InitFromConfig(&conf.Logging); err != nil {
+		return err
+	}
+	if err = conf.ValidateKeys(); err != nil {
+		return err
+	}
+
+	currentNode, err := routing.NewLocalNode(conf)
+	if err != nil {
+		return err
+	}
+	// metrics can only be registered once per process, restarts keep reporting under the first node ID
+	e.prometheusOnce.Do(func() {
+		prometheus.Init(currentNode.Id, currentNode.Type, conf.Environment)
+	})
+
+	server, err := service.InitializeServer(conf, currentNode)
+	if err != nil {
+		return err
+	}
+	telemetry.SetEventListener(e.dispatchEvent, &conf.Redaction)
+
+	errChan := make(chan error, 1)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := server.Start(); err != nil {
+			logger.Errorw("server stopped with error", err)
+			errChan <- err
+		}
+	}()
+
+	// Start blocks while the server runs, report back once it accepts connections
+	select {
+	case <-server.Started():
+	case err = <-errChan:
+		return err
+	case <-time.After(embeddedStartTimeout):
+		go server.Stop(true)
+		return errStartTimeout
+	}
+
+	e.server = server
+	e.done = done
+	return nil
+}
+
+func (e *embeddedServer) stop(force bool) error {
+	e.lock.Lock()
+	if e.statusLocked() == statusStopped || e.stopping {
+		e.lock.Unlock()
+		return errNotRunning
+	}
+	e.stopping = true
+	server, done := e.server, e.done
+	e.lock.Unlock()
+
+	// waiting for participants to leave can take a while, keep status queries answering meanwhile
+	server.Stop(force)
+	<-done
+
+	e.lock.Lock()
+	e.stopping = false
+	e.server = nil
+	e.lock.Unlock()
+	logging.Close()
+	return nil
+}
+
+func (e *embeddedServer) restart() error {
+	if err := e.stop(false); err != nil && err != errNotRunning {
+		return err
+	}
+
+	e.lock.Lock()
+	configFile, configBody := e.configFile, e.configBody
+	e.lock.Unlock()
+	return e.start(configFile, configBody)
+}
+
+func (e *embeddedServer) status() int {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	return e.statusLocked()
+}
+
+func (e *embeddedServer) statusLocked() int {
+	if e.server == nil {
+		return statusStopped
+	}
+	select {
+	case <-e.done:
+		// stopped on its own
+		return statusStopped
+	default:
+	}
+	if e.stopping {
+		return statusStopping
+	}
+	if !e.server.IsRunning() {
+		return statusStarting
+	}
+	return statusRunning
+}
+
+type embeddedStatus struct {
+	Status       string `json:"status"`
+	NodeID       string `json:"node_id,omitempty"`
+	Rooms        int32  `json:"rooms"`
+	Participants int32  `json:"participants"`
+	TracksIn     int32  `json:"tracks_in"`
+	TracksOut    int32  `json:"tracks_out"`
+	LastError    string `json:"last_error,omitempty"`
+}
+
+func (e *embeddedServer) statusInfo() *embeddedStatus {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	info := &embeddedStatus{}
+	switch e.statusLocked() {
+	case statusStopped:
+		info.Status = "stopped"
+	case statusStarting:
+		info.Status = "starting"
+	case statusRunning:
+		info.Status = "running"
+	case statusStopping:
+		info.Status = "stopping"
+	}
+	if e.server != nil {
+		node := e.server.Node()
+		info.NodeID = node.Id
+		if stats := node.Stats; stats != nil {
+			info.Rooms = stats.NumRooms
+			info.Participants = stats.NumClients
+			info.TracksIn = stats.NumTracksIn
+			info.TracksOut = stats.NumTracksOut
+		}
+	}
+	if e.lastErr != nil {
+		info.LastError = e.lastErr.Error()
+	}
+	return info
+}
+
+func (e *embeddedServer) setLastError(err error) {
+	e.lock.Lock()
+	e.lastErr = err
+	e.lock.Unlock()
+}
+
+func (e *embeddedServer) lastError() error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	return e.lastErr
+}
+
+func (e *embeddedServer) setCallback(callback C.LiveKitEventCallback, userData unsafe.Pointer) {
+	e.callbackLock.Lock()
+	e.callback = callback
+	e.userData = userData
+	e.callbackLock.Unlock()
+}
+
+func (e *embeddedServer) dispatchEvent(event *livekit.WebhookEvent) {
+	e.callbackLock.RLock()
+	defer e.callbackLock.RUnlock()
+	if e.callback == nil {
+		return
+	}
+
+	payload, err := protojson.Marshal(event)
+	if err != nil {
+		logger.Warnw("could not marshal event", err, "event", event.Event)
+		return
+	}
+
+	cEvent := C.CString(event.Event)
+	cPayload := C.CString(string(payload))
+	defer C.free(unsafe.Pointer(cEvent))
+	defer C.free(unsafe.Pointer(cPayload))
+	C.livekitInvokeEventCallback(e.callback, cEvent, cPayload, e.userData)
+}
+
+func resultCode(err error) C.int {
+	embedded.setLastError(err)
+	switch err {
+	case nil:
+		return resultOK
+	case errAlreadyRunning:
+		return resultAlreadyRunning
+	case errNotRunning:
+		return resultNotRunning
+	default:
+		logger.Errorw("embedded server call failed", err)
+		return resultError
+	}
+}
+
+// LiveKitStart starts the server with the YAML config in configBody, or read from configFile when configBody
+// is empty. It returns once the server accepts connections, 0 on success.
+//
+//export LiveKitStart
+func LiveKitStart(configFile *C.char, configBody *C.char) C.int {
+	return resultCode(embedded.start(C.GoString(configFile), C.GoString(configBody)))
+}
+
+// LiveKitStop stops the server. Unless force is set, it waits for participants to leave first.
+//
+//export LiveKitStop
+func LiveKitStop(force C.int) C.int {
+	return resultCode(embedded.stop(force != 0))
+}
+
+// LiveKitRestart stops the server and starts it again with the config of the last start, re-reading the file.
+//
+//export LiveKitRestart
+func LiveKitRestart() C.int {
+	return resultCode(embedded.restart())
+}
+
+// LiveKitStatus returns 0 when stopped, 1 while starting, 2 when running and 3 while stopping
+//
+//export LiveKitStatus
+func LiveKitStatus() C.int {
+	return C.int(embedded.status())
+}
+
+// LiveKitStatusJSON returns the state of the server with room and participant counts as JSON
+//
+//export LiveKitStatusJSON
+func LiveKitStatusJSON() *C.char {
+	data, err := json.Marshal(embedded.statusInfo())
+	if err != nil {
+		return nil
+	}
+	return C.CString(string(data))
+}
+
+// LiveKitLastError returns the error of the last start, stop or restart, or NULL when it succeeded
+//
+//export LiveKitLastError
+func LiveKitLastError() *C.char {
+	err := embedded.lastError()
+	if err == nil {
+		return nil
+	}
+	return C.CString(err.Error())
+}
+
+// LiveKitSetEventCallback registers the receiver of room, participant and track events, NULL removes it.
+// Events are redacted like webhooks and delivered in order from a server thread, user_data is passed through
+// as is. Events are dropped while the callback falls behind.
+//
+//export LiveKitSetEventCallback
+func LiveKitSetEventCallback(callback C.LiveKitEventCallback, userData unsafe.Pointer) {
+	embedded.setCallback(callback, userData)
+}
+
+// LiveKitFree releases a string returned by the API
+//
+//export LiveKitFree
+func LiveKitFree(str *C.char) {
+	C.free(unsafe.Pointer(str))
+}
@@ -15,6 +15,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"math/rand"
 	"net"
@@ -29,10 +30,12 @@ import (
 
 	"github.com/livekit/livekit-server/pkg/rtc"
 	"github.com/livekit/livekit-server/pkg/telemetry/prometheus"
+	"github.com/livekit/livekit-server/pkg/telemetry/tracing"
 	"github.com/livekit/mediatransportutil/pkg/rtcconfig"
 	"github.com/livekit/protocol/logger"
 
 	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/logging"
 	"github.com/livekit/livekit-server/pkg/routing"
 	"github.com/livekit/livekit-server/pkg/service"
 	"github.com/livekit/livekit-server/version"
@@ -89,6 +92,21 @@ var baseFlags = []cli.Flag{
 		Usage:   "password to redis",
 		EnvVars: []string{"REDIS_PASSWORD"},
 	},
+	&cli.StringSliceFlag{
+		Name:    "redis-sentinel-addresses",
+		Usage:   "addresses of redis sentinels, use flag multiple times to specify multiple addresses",
+		EnvVars: []string{"REDIS_SENTINEL_ADDRESSES"},
+	},
+	&cli.StringFlag{
+		Name:    "redis-sentinel-master-name",
+		Usage:   "name of the redis master monitored by sentinels",
+		EnvVars: []string{"REDIS_SENTINEL_MASTER_NAME"},
+	},
+	&cli.StringSliceFlag{
+		Name:    "redis-cluster-addresses",
+		Usage:   "addresses of redis cluster nodes, use flag multiple times to specify multiple addresses",
+		EnvVars: []string{"REDIS_CLUSTER_ADDRESSES"},
+	},
 	&cli.StringFlag{
 		Name:    "turn-cert",
 		Usage:   "tls cert file for TURN server",
@@ -177,6 +195,33 @@ func main() {
 				Usage:  "list all nodes",
 				Action: listNodes,
 			},
+			{
+				Name:   "decrypt-recording",
+				Usage:  "decrypts the files of an encrypted recording with the configured keys",
+				Action: decryptRecording,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "dir",
+						Usage:    "directory of the recording, holding recording.json and the encrypted files",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:  "out",
+						Usage: "directory decrypted files are written to, defaults to <dir>/decrypted",
+					},
+				},
+			},
+			{
+				Name:   "migrate-config",
+				Usage:  "converts a config of an earlier version to the current layout, reporting renamed and removed keys",
+				Action: migrateConfig,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "out",
+						Usage: "file the migrated config is written to, printed when not set",
+					},
+				},
+			},
 			{
 				Name:   "help-verbose",
 				Usage:  "prints app help, including all generated configuration flags",
@@ -215,7 +260,9 @@ func getConfig(c *cli.Context) (*config.Config, error) {
 	if err != nil {
 		return nil, err
 	}
-	config.InitLoggerFromConfig(&conf.Logging)
+	if err = logging.InitFromConfig(&conf.Logging); err != nil {
+		return nil, err
+	}
 
 	if c.String("config") == "" && c.String("config-body") == "" && conf.Development {
 		// use single port UDP when no config is provided
@@ -268,6 +315,7 @@ func startServer(c *cli.Context) error {
 	if err != nil {
 		return err
 	}
+	defer logging.Close()
 
 	// validate API key length
 	err = conf.ValidateKeys()
@@ -295,11 +343,37 @@ func startServer(c *cli.Context) error {
 
 	prometheus.Init(currentNode.Id, currentNode.Type, conf.Environment)
 
+	shutdownTracing, err := tracing.Init(conf.Tracing, currentNode.Id)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			logger.Warnw("could not flush traces", err)
+		}
+	}()
+
 	server, err := service.InitializeServer(conf, currentNode)
 	if err != nil {
 		return err
 	}
 
+	if configFile := c.String("config"); configFile != "" && c.String("config-body") == "" {
+		// pick up changes to the config file on write or SIGHUP
+		watcher := config.NewWatcher(conf, configFile, func() (*config.Config, error) {
+			confString, err := getConfigString(configFile, "")
+			if err != nil {
+				return nil, err
+			}
+			return config.NewConfig(confString, !c.Bool("disable-strict-config"), c, baseFlags)
+		})
+		watcher.OnReload(server.ReloadConfig)
+		watcher.Start()
+		defer watcher.Stop()
+	}
+
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
 
@@ -309,6 +383,16 @@ func startServer(c *cli.Context) error {
 		server.Stop(false)
 	}()
 
+	drainChan := make(chan os.Signal, 1)
+	notifyDrain(drainChan)
+
+	go func() {
+		for sig := range drainChan {
+			logger.Infow("drain requested", "signal", sig)
+			server.Drain()
+		}
+	}()
+
 	return server.Start()
 }
 
@@ -324,87 +408,3 @@ func getConfigString(configFile string, inConfigBody string) (string, error) {
 
 	return string(outConfigBody), nil
 }
-
-////////////////////////////////////////////////////////////////////////////////////
-////////////////////////////////////////////////////////////////////////////////////
-////////////////////////////////////////////////////////////////////////////////////
-/// export functions to C
-
-// var internalServer *service.LivekitServer = nil
-
-// // Start server
-// //
-// //export Start
-// func Start(config *C.char, redis *C.char) int {
-// 	c := strings.Fields(C.GoString(config))
-// 	configFilePath := strings.Join(c, "")
-
-// 	r := strings.Fields(C.GoString(redis))
-// 	redisAddress := strings.Join(r, "")
-
-// 	logger.Infow("start server with config file = %s, redis: %s", configFilePath, redisAddress)
-// 	err := runServer(configFilePath, redisAddress)
-// 	if err != nil {
-// 		logger.Errorw("start server failed", err)
-// 		return -1
-// 	}
-
-// 	return 0
-// }
-
-// // Stop server
-// //
-// //export Stop
-// func Stop() int {
-// 	if internalServer == nil {
-// 		logger.Infow("internalServer is nil")
-// 		return -1
-// 	}
-
-// 	logger.Infow("exit requested, shutting down")
-// 	internalServer.Stop(false)
-
-// 	return 0
-// }
-
-// func runServer(configFile string, redis string) error {
-// 	rand.Seed(time.Now().UnixNano())
-
-// 	confString, err := getConfigString(configFile, "")
-// 	if err != nil {
-// 		return err
-// 	}
-
-// 	strictMode := true
-// 	conf, err := config.NewConfig(confString, strictMode, nil, baseFlags)
-// 	if err != nil {
-// 		return err
-// 	}
-// 	config.InitLoggerFromConfig(&conf.Logging)
-
-// 	if len(redis) > 0 {
-// 		conf.Redis.Address = redis
-// 	}
-
-// 	// validate API key length
-// 	err = conf.ValidateKeys()
-// 	if err != nil {
-// 		return err
-// 	}
-
-// 	currentNode, err := routing.NewLocalNode(conf)
-// 	if err != nil {
-// 		return err
-// 	}
-
-// 	prometheus.Init(currentNode.Id, currentNode.Type, conf.Environment)
-
-// 	server, err := service.InitializeServer(conf, currentNode)
-// 	if err != nil {
-// 		return err
-// 	}
-
-// 	internalServer = server
-
-// 	return server.Start()
-// }
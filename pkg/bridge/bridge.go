@@ -0,0 +1,551 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bridge
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pion/rtcp"
+	"github.com/pion/webrtc/v3"
+	"go.uber.org/atomic"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/logger"
+)
+
+const (
+	DirectionPull = "pull"
+	DirectionPush = "push"
+	DirectionBoth = "both"
+
+	// IdentityPrefix is used by participants created by bridges, their tracks are never bridged
+	// back, which keeps bridges running on both ends from looping
+	IdentityPrefix = "bridge-"
+)
+
+type State string
+
+const (
+	StateConnecting   State = "connecting"
+	StateActive       State = "active"
+	StateReconnecting State = "reconnecting"
+	StateStopped      State = "stopped"
+)
+
+var (
+	ErrBridgeNotFound  = errors.New("bridge not found")
+	ErrInvalidBridge   = errors.New("bridge requires room, remote_url, remote_token and a valid direction")
+	errBridgeEnded     = errors.New("bridge connection ended")
+	errBridgeStopped   = errors.New("bridge stopped")
+	errNotBridgeSource = errors.New("track is not bridged")
+)
+
+// Info describes a running bridge, the remote token is not exposed
+type Info struct {
+	ID         string           `json:"id"`
+	Room       livekit.RoomName `json:"room"`
+	RemoteURL  string           `json:"remote_url"`
+	Direction  string           `json:"direction"`
+	Identities []string         `json:"identities,omitempty"`
+	Sources    []string         `json:"sources,omitempty"`
+	State      State            `json:"state"`
+	Error      string           `json:"error,omitempty"`
+	// unix milliseconds
+	StartedAt int64 `json:"started_at"`
+	// number of tracks currently bridged
+	Tracks int32 `json:"tracks"`
+}
+
+// worker runs sessions of a bridge until stopped, reconnecting after failures
+type worker struct {
+	id             string
+	logger         logger.Logger
+	reconnectDelay time.Duration
+
+	lock      sync.Mutex
+	state     State
+	lastErr   string
+	startedAt time.Time
+	tracks    atomic.Int32
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func newWorker(id string, reconnectDelay time.Duration, logger logger.Logger) *worker {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &worker{
+		id:             id,
+		logger:         logger,
+		reconnectDelay: reconnectDelay,
+		state:          StateConnecting,
+		startedAt:      time.Now(),
+		ctx:            ctx,
+		cancel:         cancel,
+		done:           make(chan struct{}),
+	}
+}
+
+func (w *worker) status() (State, string) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.state, w.lastErr
+}
+
+func (w *worker) stop() {
+	w.cancel()
+	<-w.done
+}
+
+func (w *worker) setState(state State, err error) {
+	w.lock.Lock()
+	w.state = state
+	if err != nil {
+		w.lastErr = err.Error()
+	} else if state == StateActive {
+		w.lastErr = ""
+	}
+	w.lock.Unlock()
+}
+
+func (w *worker) run(runSession func() error) {
+	defer close(w.done)
+
+	for {
+		err := runSession()
+		if w.ctx.Err() != nil {
+			w.setState(StateStopped, nil)
+			return
+		}
+
+		w.logger.Warnw("bridge disconnected, reconnecting", err, "delay", w.reconnectDelay)
+		w.setState(StateReconnecting, err)
+		select {
+		case <-w.ctx.Done():
+			w.setState(StateStopped, nil)
+			return
+		case <-time.After(w.reconnectDelay):
+		}
+	}
+}
+
+// Bridge keeps a local room linked to a remote room
+type Bridge struct {
+	*worker
+	spec    config.BridgeSpec
+	manager *Manager
+}
+
+func newBridge(id string, spec config.BridgeSpec, m *Manager) *Bridge {
+	if spec.Direction == "" {
+		spec.Direction = DirectionPull
+	}
+	return &Bridge{
+		worker:  newWorker(id, m.conf.ReconnectDelay, logger.GetLogger().WithValues("bridgeID", id, "room", spec.Room, "remoteURL", spec.RemoteURL)),
+		spec:    spec,
+		manager: m,
+	}
+}
+
+func (b *Bridge) Info() *Info {
+	state, lastErr := b.status()
+	return &Info{
+		ID:         b.id,
+		Room:       livekit.RoomName(b.spec.Room),
+		RemoteURL:  b.spec.RemoteURL,
+		Direction:  b.spec.Direction,
+		Identities: b.spec.Identities,
+		Sources:    b.spec.Sources,
+		State:      state,
+		Error:      lastErr,
+		StartedAt:  b.startedAt.UnixMilli(),
+		Tracks:     b.tracks.Load(),
+	}
+}
+
+// runSession connects both ends and bridges tracks until one of the connections ends
+func (b *Bridge) runSession() error {
+	s := &session{
+		bridge:     b,
+		publishers: make(map[livekit.ParticipantIdentity]*publisher),
+	}
+	defer s.close()
+
+	var remoteLink, localLink *link
+	if b.spec.Direction != DirectionPush {
+		remoteLink = newLink(b, s.localPublisherFor, s.releaseLocalPublisher, func(_ *livekit.ParticipantInfo, t *livekit.TrackInfo) string {
+			return t.Name
+		})
+	}
+
+	remote, err := dialClient(b.ctx, clientParams{
+		URL:         b.spec.RemoteURL,
+		Token:       b.spec.RemoteToken,
+		STUNServers: b.manager.stunServers,
+		Logger:      b.logger.WithValues("side", "remote"),
+		OnParticipantsChanged: func(participants []*livekit.ParticipantInfo) {
+			if remoteLink != nil {
+				remoteLink.onParticipantsChanged(participants)
+			}
+		},
+		OnTrack: func(p *livekit.ParticipantInfo, info *livekit.TrackInfo, track *webrtc.TrackRemote) {
+			if remoteLink != nil {
+				remoteLink.onTrack(p, info, track)
+			}
+		},
+	})
+	if err != nil {
+		return err
+	}
+	s.setRemote(remote)
+	if remoteLink != nil {
+		remoteLink.setSource(remote)
+	}
+
+	var collectorDone <-chan struct{}
+	if b.spec.Direction != DirectionPull {
+		localLink = newLink(b, s.remotePublisher, func(_ *livekit.ParticipantInfo) {}, func(p *livekit.ParticipantInfo, t *livekit.TrackInfo) string {
+			return p.Identity + "/" + t.Name
+		})
+		// the collector subscribes to local tracks without showing up in the room
+		token, err := b.manager.localToken(b.spec.Room, IdentityPrefix+b.id, "", true)
+		if err != nil {
+			return err
+		}
+		collector, err := dialClient(b.ctx, clientParams{
+			URL:                   b.manager.localURL,
+			Token:                 token,
+			InsecureSkipVerify:    b.manager.localInsecure,
+			Logger:                b.logger.WithValues("side", "local"),
+			OnParticipantsChanged: localLink.onParticipantsChanged,
+			OnTrack:               localLink.onTrack,
+		})
+		if err != nil {
+			return err
+		}
+		s.setCollector(collector)
+		localLink.setSource(collector)
+		collectorDone = collector.Done()
+	}
+
+	b.logger.Infow("bridge connected", "direction", b.spec.Direction)
+	b.setState(StateActive, nil)
+
+	select {
+	case <-b.ctx.Done():
+		return errBridgeStopped
+	case <-remote.Done():
+		return errBridgeEnded
+	case <-collectorDone:
+		return errBridgeEnded
+	}
+}
+
+func (b *Bridge) acceptsParticipant(p *livekit.ParticipantInfo) bool {
+	if strings.HasPrefix(p.Identity, IdentityPrefix) {
+		return false
+	}
+	if len(b.spec.Identities) == 0 {
+		return true
+	}
+	for _, identity := range b.spec.Identities {
+		if identity == p.Identity {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *Bridge) acceptsTrack(t *livekit.TrackInfo) bool {
+	if t.Type != livekit.TrackType_AUDIO && t.Type != livekit.TrackType_VIDEO {
+		return false
+	}
+	if len(b.spec.Sources) == 0 {
+		return true
+	}
+	for _, source := range b.spec.Sources {
+		if strings.EqualFold(source, t.Source.String()) {
+			return true
+		}
+	}
+	return false
+}
+
+type publisher struct {
+	client *client
+	refs   int
+}
+
+// session holds the connections of one connection attempt of a bridge
+type session struct {
+	bridge *Bridge
+
+	lock       sync.Mutex
+	remote     *client
+	collector  *client
+	publishers map[livekit.ParticipantIdentity]*publisher
+	// serializes the creation of local publishers
+	publishersLock sync.Mutex
+	closed         bool
+}
+
+func (s *session) setRemote(c *client) {
+	s.lock.Lock()
+	s.remote = c
+	s.lock.Unlock()
+}
+
+func (s *session) setCollector(c *client) {
+	s.lock.Lock()
+	s.collector = c
+	s.lock.Unlock()
+}
+
+func (s *session) remotePublisher(_ *livekit.ParticipantInfo) (*client, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.remote == nil || s.closed {
+		return nil, ErrClientClosed
+	}
+	return s.remote, nil
+}
+
+// localPublisherFor returns the local participant republishing the tracks of a remote participant
+func (s *session) localPublisherFor(p *livekit.ParticipantInfo) (*client, error) {
+	s.publishersLock.Lock()
+	defer s.publishersLock.Unlock()
+
+	identity := livekit.ParticipantIdentity(p.Identity)
+	s.lock.Lock()
+	if s.closed {
+		s.lock.Unlock()
+		return nil, ErrClientClosed
+	}
+	if pub := s.publishers[identity]; pub != nil {
+		pub.refs++
+		s.lock.Unlock()
+		return pub.client, nil
+	}
+	s.lock.Unlock()
+
+	b := s.bridge
+	token, err := b.manager.localToken(b.spec.Room, IdentityPrefix+p.Identity, p.Name, false)
+	if err != nil {
+		return nil, err
+	}
+	c, err := dialClient(b.ctx, clientParams{
+		URL:                b.manager.localURL,
+		Token:              token,
+		InsecureSkipVerify: b.manager.localInsecure,
+		Logger:             b.logger.WithValues("side", "local", "remoteParticipant", p.Identity),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.closed {
+		c.Close()
+		return nil, ErrClientClosed
+	}
+	s.publishers[identity] = &publisher{client: c, refs: 1}
+	return c, nil
+}
+
+func (s *session) releaseLocalPublisher(p *livekit.ParticipantInfo) {
+	identity := livekit.ParticipantIdentity(p.Identity)
+	s.lock.Lock()
+	pub := s.publishers[identity]
+	if pub == nil {
+		s.lock.Unlock()
+		return
+	}
+	pub.refs--
+	if pub.refs > 0 {
+		s.lock.Unlock()
+		return
+	}
+	delete(s.publishers, identity)
+	s.lock.Unlock()
+
+	pub.client.Close()
+}
+
+func (s *session) close() {
+	s.lock.Lock()
+	s.closed = true
+	clients := []*client{s.remote, s.collector}
+	for _, pub := range s.publishers {
+		clients = append(clients, pub.client)
+	}
+	s.publishers = make(map[livekit.ParticipantIdentity]*publisher)
+	s.lock.Unlock()
+
+	for _, c := range clients {
+		if c != nil {
+			c.Close()
+		}
+	}
+}
+
+// link subscribes to tracks on one side and republishes them on the other
+type link struct {
+	bridge           *Bridge
+	publisherFor     func(p *livekit.ParticipantInfo) (*client, error)
+	releasePublisher func(p *livekit.ParticipantInfo)
+	trackName        func(p *livekit.ParticipantInfo, t *livekit.TrackInfo) string
+
+	lock       sync.Mutex
+	source     *client
+	pending    []*livekit.ParticipantInfo
+	subscribed map[livekit.TrackID]bool
+}
+
+func newLink(
+	b *Bridge,
+	publisherFor func(p *livekit.ParticipantInfo) (*client, error),
+	releasePublisher func(p *livekit.ParticipantInfo),
+	trackName func(p *livekit.ParticipantInfo, t *livekit.TrackInfo) string,
+) *link {
+	return &link{
+		bridge:           b,
+		publisherFor:     publisherFor,
+		releasePublisher: releasePublisher,
+		trackName:        trackName,
+		subscribed:       make(map[livekit.TrackID]bool),
+	}
+}
+
+// setSource is called once connected, participants received while joining are handled then
+func (l *link) setSource(c *client) {
+	l.lock.Lock()
+	l.source = c
+	pending := l.pending
+	l.pending = nil
+	l.lock.Unlock()
+
+	if pending != nil {
+		l.onParticipantsChanged(pending)
+	}
+}
+
+func (l *link) onParticipantsChanged(participants []*livekit.ParticipantInfo) {
+	l.lock.Lock()
+	source := l.source
+	if source == nil {
+		l.pending = participants
+		l.lock.Unlock()
+		return
+	}
+
+	var trackIDs []livekit.TrackID
+	for _, p := range participants {
+		if !l.bridge.acceptsParticipant(p) {
+			continue
+		}
+		for _, t := range p.Tracks {
+			trackID := livekit.TrackID(t.Sid)
+			if l.subscribed[trackID] || !l.bridge.acceptsTrack(t) {
+				continue
+			}
+			l.subscribed[trackID] = true
+			trackIDs = append(trackIDs, trackID)
+		}
+	}
+	l.lock.Unlock()
+
+	if len(trackIDs) == 0 {
+		return
+	}
+	if err := source.UpdateSubscription(trackIDs, true); err != nil {
+		l.bridge.logger.Warnw("could not subscribe to bridged tracks", err)
+	}
+}
+
+// onTrack republishes a subscribed track until it ends
+func (l *link) onTrack(p *livekit.ParticipantInfo, info *livekit.TrackInfo, remote *webrtc.TrackRemote) {
+	l.lock.Lock()
+	source := l.source
+	subscribed := l.subscribed[livekit.TrackID(info.Sid)]
+	l.lock.Unlock()
+	if source == nil || !subscribed {
+		l.bridge.logger.Debugw("ignoring track", "error", errNotBridgeSource, "trackID", info.Sid)
+		return
+	}
+
+	log := l.bridge.logger.WithValues("participant", p.Identity, "trackID", info.Sid)
+	pub, err := l.publisherFor(p)
+	if err != nil {
+		log.Warnw("could not get publisher for bridged track", err)
+		return
+	}
+	defer l.releasePublisher(p)
+
+	local, err := webrtc.NewTrackLocalStaticRTP(remote.Codec().RTPCodecCapability, info.Sid, info.Sid)
+	if err != nil {
+		log.Warnw("could not create bridged track", err)
+		return
+	}
+	sender, published, err := pub.PublishTrack(local, l.trackName(p, info), info)
+	if err != nil {
+		log.Warnw("could not publish bridged track", err)
+		return
+	}
+	defer pub.UnpublishTrack(sender)
+
+	l.bridge.tracks.Inc()
+	defer l.bridge.tracks.Dec()
+	log.Infow("bridging track", "publishedTrackID", published.Sid, "mime", remote.Codec().MimeType)
+
+	// key frame requests of subscribers on the publishing side are relayed to the source
+	go func() {
+		for {
+			pkts, _, err := sender.ReadRTCP()
+			if err != nil {
+				return
+			}
+			for _, pkt := range pkts {
+				switch pkt.(type) {
+				case *rtcp.PictureLossIndication, *rtcp.FullIntraRequest:
+					source.RequestKeyFrame(uint32(remote.SSRC()))
+				}
+			}
+		}
+	}()
+
+	buf := make([]byte, 1500)
+	for {
+		n, _, err := remote.Read(buf)
+		if err != nil {
+			break
+		}
+		if _, err = local.Write(buf[:n]); err != nil && !errors.Is(err, io.ErrClosedPipe) {
+			break
+		}
+	}
+
+	l.lock.Lock()
+	delete(l.subscribed, livekit.TrackID(info.Sid))
+	l.lock.Unlock()
+	log.Infow("bridged track ended")
+}
@@ -0,0 +1,50 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bridge
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/protocol/livekit"
+)
+
+func TestBridgeFilters(t *testing.T) {
+	t.Run("bridged participants are never bridged back", func(t *testing.T) {
+		b := &Bridge{spec: config.BridgeSpec{}}
+		require.True(t, b.acceptsParticipant(&livekit.ParticipantInfo{Identity: "alice"}))
+		require.False(t, b.acceptsParticipant(&livekit.ParticipantInfo{Identity: IdentityPrefix + "alice"}))
+	})
+
+	t.Run("identities", func(t *testing.T) {
+		b := &Bridge{spec: config.BridgeSpec{Identities: []string{"lecturer"}}}
+		require.True(t, b.acceptsParticipant(&livekit.ParticipantInfo{Identity: "lecturer"}))
+		require.False(t, b.acceptsParticipant(&livekit.ParticipantInfo{Identity: "student"}))
+	})
+
+	t.Run("sources", func(t *testing.T) {
+		b := &Bridge{spec: config.BridgeSpec{Sources: []string{"camera", "SCREEN_SHARE"}}}
+		require.True(t, b.acceptsTrack(&livekit.TrackInfo{Type: livekit.TrackType_VIDEO, Source: livekit.TrackSource_CAMERA}))
+		require.True(t, b.acceptsTrack(&livekit.TrackInfo{Type: livekit.TrackType_VIDEO, Source: livekit.TrackSource_SCREEN_SHARE}))
+		require.False(t, b.acceptsTrack(&livekit.TrackInfo{Type: livekit.TrackType_AUDIO, Source: livekit.TrackSource_MICROPHONE}))
+	})
+
+	t.Run("data tracks are not bridged", func(t *testing.T) {
+		b := &Bridge{spec: config.BridgeSpec{}}
+		require.False(t, b.acceptsTrack(&livekit.TrackInfo{Type: livekit.TrackType_DATA}))
+	})
+}
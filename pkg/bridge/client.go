@@ -0,0 +1,497 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bridge
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/pion/rtcp"
+	"github.com/pion/webrtc/v3"
+	"go.uber.org/atomic"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/livekit/mediatransportutil/pkg/rtcconfig"
+	"github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/logger"
+
+	"github.com/livekit/livekit-server/pkg/rtc"
+	"github.com/livekit/livekit-server/pkg/rtc/types"
+)
+
+const (
+	publishTimeout = 10 * time.Second
+	connectTimeout = 20 * time.Second
+)
+
+var (
+	ErrPublishTimeout = errors.New("track was not published in time")
+	ErrClientClosed   = errors.New("bridge client closed")
+)
+
+var clientCodecs = []*livekit.Codec{
+	{Mime: webrtc.MimeTypeOpus},
+	{Mime: webrtc.MimeTypeVP8},
+	{Mime: webrtc.MimeTypeH264},
+	{Mime: webrtc.MimeTypeVP9},
+	{Mime: webrtc.MimeTypeAV1},
+}
+
+var clientDirectionConfig = rtc.DirectionConfig{
+	RTCPFeedback: rtc.RTCPFeedbackConfig{
+		Audio: []webrtc.RTCPFeedback{
+			{Type: webrtc.TypeRTCPFBNACK},
+		},
+		Video: []webrtc.RTCPFeedback{
+			{Type: webrtc.TypeRTCPFBCCM, Parameter: "fir"},
+			{Type: webrtc.TypeRTCPFBNACK},
+			{Type: webrtc.TypeRTCPFBNACK, Parameter: "pli"},
+		},
+	},
+}
+
+type clientParams struct {
+	URL   string
+	Token string
+	// local connections skip certificate verification, they go through the loopback interface
+	InsecureSkipVerify bool
+	STUNServers        []string
+	Logger             logger.Logger
+
+	OnParticipantsChanged func(participants []*livekit.ParticipantInfo)
+	OnTrack               func(participant *livekit.ParticipantInfo, info *livekit.TrackInfo, track *webrtc.TrackRemote)
+	OnClose               func()
+}
+
+// client is a minimal participant, joining a room through the signaling protocol to subscribe to
+// and publish tracks
+type client struct {
+	params     clientParams
+	conn       *websocket.Conn
+	publisher  *rtc.PCTransport
+	subscriber *rtc.PCTransport
+
+	wsLock       sync.Mutex
+	lock         sync.Mutex
+	participant  *livekit.ParticipantInfo
+	participants map[livekit.ParticipantID]*livekit.ParticipantInfo
+	pending      map[string]chan *livekit.TrackInfo
+	pingInterval time.Duration
+
+	connected chan struct{}
+	closed    atomic.Bool
+	done      chan struct{}
+}
+
+// dialClient joins a room and returns once the connection is established
+func dialClient(ctx context.Context, params clientParams) (*client, error) {
+	if params.Logger == nil {
+		params.Logger = logger.GetLogger()
+	}
+
+	u := strings.TrimSuffix(params.URL, "/")
+	u = strings.Replace(u, "http://", "ws://", 1)
+	u = strings.Replace(u, "https://", "wss://", 1)
+	u += "/rtc?protocol=7&auto_subscribe=false"
+
+	dialer := *websocket.DefaultDialer
+	if params.InsecureSkipVerify {
+		dialer.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	header := make(http.Header)
+	header.Set("Authorization", "Bearer "+params.Token)
+	conn, res, err := dialer.DialContext(ctx, u, header)
+	if err != nil {
+		if res != nil {
+			return nil, fmt.Errorf("could not connect to %s, status: %d", params.URL, res.StatusCode)
+		}
+		return nil, err
+	}
+
+	c := &client{
+		params:       params,
+		conn:         conn,
+		participants: make(map[livekit.ParticipantID]*livekit.ParticipantInfo),
+		pending:      make(map[string]chan *livekit.TrackInfo),
+		connected:    make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+	if err = c.createTransports(); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	go c.readWorker()
+
+	timeout := time.NewTimer(connectTimeout)
+	defer timeout.Stop()
+	select {
+	case <-c.connected:
+		go c.pingWorker()
+		return c, nil
+	case <-c.done:
+		return nil, ErrClientClosed
+	case <-ctx.Done():
+		c.Close()
+		return nil, ctx.Err()
+	case <-timeout.C:
+		c.Close()
+		return nil, fmt.Errorf("could not connect to %s in time", params.URL)
+	}
+}
+
+func (c *client) createTransports() error {
+	var iceServers []webrtc.ICEServer
+	if len(c.params.STUNServers) > 0 {
+		iceServers = append(iceServers, webrtc.ICEServer{URLs: c.params.STUNServers})
+	}
+	conf := rtc.WebRTCConfig{
+		WebRTCConfig: rtcconfig.WebRTCConfig{
+			Configuration: webrtc.Configuration{ICEServers: iceServers},
+		},
+	}
+	conf.SettingEngine.SetAnsweringDTLSRole(webrtc.DTLSRoleClient)
+
+	// signal targets are named from the server's point of view, the publisher of the client is
+	// the server's subscriber
+	var err error
+	c.publisher, err = rtc.NewPCTransport(rtc.TransportParams{
+		Config:          &conf,
+		DirectionConfig: clientDirectionConfig,
+		EnabledCodecs:   clientCodecs,
+		IsOfferer:       true,
+		IsSendSide:      true,
+		Logger:          c.params.Logger.WithValues("transport", "publisher"),
+	})
+	if err != nil {
+		return err
+	}
+	c.subscriber, err = rtc.NewPCTransport(rtc.TransportParams{
+		Config:          &conf,
+		DirectionConfig: clientDirectionConfig,
+		EnabledCodecs:   clientCodecs,
+		Logger:          c.params.Logger.WithValues("transport", "subscriber"),
+	})
+	if err != nil {
+		c.publisher.Close()
+		return err
+	}
+
+	c.publisher.OnICECandidate(func(ic *webrtc.ICECandidate) error {
+		return c.sendICECandidate(ic, livekit.SignalTarget_PUBLISHER)
+	})
+	c.publisher.OnOffer(func(offer webrtc.SessionDescription) error {
+		return c.sendRequest(&livekit.SignalRequest{
+			Message: &livekit.SignalRequest_Offer{Offer: rtc.ToProtoSessionDescription(offer)},
+		})
+	})
+	c.publisher.OnFailed(func(_ bool) { go c.Close() })
+
+	c.subscriber.OnICECandidate(func(ic *webrtc.ICECandidate) error {
+		return c.sendICECandidate(ic, livekit.SignalTarget_SUBSCRIBER)
+	})
+	c.subscriber.OnAnswer(func(answer webrtc.SessionDescription) error {
+		return c.sendRequest(&livekit.SignalRequest{
+			Message: &livekit.SignalRequest_Answer{Answer: rtc.ToProtoSessionDescription(answer)},
+		})
+	})
+	c.subscriber.OnInitialConnected(func() {
+		close(c.connected)
+	})
+	c.subscriber.OnFailed(func(_ bool) { go c.Close() })
+	c.subscriber.OnTrack(c.onTrack)
+
+	// the server expects the reliable data channel to exist
+	ordered := true
+	return c.publisher.CreateDataChannel(rtc.ReliableDataChannel, &webrtc.DataChannelInit{Ordered: &ordered})
+}
+
+func (c *client) Identity() livekit.ParticipantIdentity {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.participant == nil {
+		return ""
+	}
+	return livekit.ParticipantIdentity(c.participant.Identity)
+}
+
+func (c *client) Done() <-chan struct{} {
+	return c.done
+}
+
+// PublishTrack announces a track and adds it to the publisher connection
+func (c *client) PublishTrack(track webrtc.TrackLocal, name string, info *livekit.TrackInfo) (*webrtc.RTPSender, *livekit.TrackInfo, error) {
+	ch := make(chan *livekit.TrackInfo, 1)
+	c.lock.Lock()
+	c.pending[track.ID()] = ch
+	c.lock.Unlock()
+	defer func() {
+		c.lock.Lock()
+		delete(c.pending, track.ID())
+		c.lock.Unlock()
+	}()
+
+	err := c.sendRequest(&livekit.SignalRequest{
+		Message: &livekit.SignalRequest_AddTrack{
+			AddTrack: &livekit.AddTrackRequest{
+				Cid:        track.ID(),
+				Name:       name,
+				Type:       info.Type,
+				Source:     info.Source,
+				Width:      info.Width,
+				Height:     info.Height,
+				Muted:      info.Muted,
+				DisableDtx: info.DisableDtx,
+				Stereo:     info.Stereo,
+			},
+		},
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var published *livekit.TrackInfo
+	select {
+	case published = <-ch:
+	case <-c.done:
+		return nil, nil, ErrClientClosed
+	case <-time.After(publishTimeout):
+		return nil, nil, ErrPublishTimeout
+	}
+
+	sender, _, err := c.publisher.AddTrack(track, types.AddTrackParams{})
+	if err != nil {
+		return nil, nil, err
+	}
+	c.publisher.Negotiate(false)
+	return sender, published, nil
+}
+
+func (c *client) UnpublishTrack(sender *webrtc.RTPSender) {
+	if err := c.publisher.RemoveTrack(sender); err != nil {
+		c.params.Logger.Debugw("could not remove track", "error", err)
+		return
+	}
+	c.publisher.Negotiate(false)
+}
+
+func (c *client) UpdateSubscription(trackIDs []livekit.TrackID, subscribe bool) error {
+	sids := make([]string, 0, len(trackIDs))
+	for _, id := range trackIDs {
+		sids = append(sids, string(id))
+	}
+	return c.sendRequest(&livekit.SignalRequest{
+		Message: &livekit.SignalRequest_Subscription{
+			Subscription: &livekit.UpdateSubscription{
+				TrackSids: sids,
+				Subscribe: subscribe,
+			},
+		},
+	})
+}
+
+// RequestKeyFrame asks the publisher of a subscribed track for a key frame
+func (c *client) RequestKeyFrame(ssrc uint32) {
+	_ = c.subscriber.WriteRTCP([]rtcp.Packet{&rtcp.PictureLossIndication{MediaSSRC: ssrc}})
+}
+
+func (c *client) Close() {
+	if c.closed.Swap(true) {
+		return
+	}
+
+	_ = c.sendRequest(&livekit.SignalRequest{
+		Message: &livekit.SignalRequest_Leave{Leave: &livekit.LeaveRequest{}},
+	})
+	_ = c.conn.Close()
+	c.publisher.Close()
+	c.subscriber.Close()
+	close(c.done)
+
+	if c.params.OnClose != nil {
+		c.params.OnClose()
+	}
+}
+
+func (c *client) sendICECandidate(ic *webrtc.ICECandidate, target livekit.SignalTarget) error {
+	if ic == nil {
+		return nil
+	}
+	trickle := rtc.ToProtoTrickle(ic.ToJSON())
+	trickle.Target = target
+	return c.sendRequest(&livekit.SignalRequest{
+		Message: &livekit.SignalRequest_Trickle{Trickle: trickle},
+	})
+}
+
+func (c *client) sendRequest(msg *livekit.SignalRequest) error {
+	payload, err := proto.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	c.wsLock.Lock()
+	defer c.wsLock.Unlock()
+	return c.conn.WriteMessage(websocket.BinaryMessage, payload)
+}
+
+func (c *client) pingWorker() {
+	c.lock.Lock()
+	interval := c.pingInterval
+	c.lock.Unlock()
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			_ = c.sendRequest(&livekit.SignalRequest{
+				Message: &livekit.SignalRequest_Ping{Ping: time.Now().UnixMilli()},
+			})
+		}
+	}
+}
+
+func (c *client) readWorker() {
+	defer c.Close()
+
+	for {
+		messageType, payload, err := c.conn.ReadMessage()
+		if err != nil {
+			if !c.closed.Load() {
+				c.params.Logger.Infow("bridge signal connection closed", "error", err)
+			}
+			return
+		}
+		if messageType != websocket.BinaryMessage {
+			continue
+		}
+
+		res := &livekit.SignalResponse{}
+		if err = proto.Unmarshal(payload, res); err != nil {
+			c.params.Logger.Warnw("could not decode signal response", err)
+			continue
+		}
+		if !c.handleResponse(res) {
+			return
+		}
+	}
+}
+
+// handleResponse returns false when the server ended the session
+func (c *client) handleResponse(res *livekit.SignalResponse) bool {
+	switch msg := res.Message.(type) {
+	case *livekit.SignalResponse_Join:
+		c.lock.Lock()
+		c.participant = msg.Join.Participant
+		c.pingInterval = time.Duration(msg.Join.PingInterval) * time.Second
+		c.lock.Unlock()
+		c.updateParticipants(msg.Join.OtherParticipants)
+		if !msg.Join.SubscriberPrimary {
+			c.publisher.Negotiate(false)
+		}
+
+	case *livekit.SignalResponse_Offer:
+		c.subscriber.HandleRemoteDescription(rtc.FromProtoSessionDescription(msg.Offer))
+
+	case *livekit.SignalResponse_Answer:
+		c.publisher.HandleRemoteDescription(rtc.FromProtoSessionDescription(msg.Answer))
+
+	case *livekit.SignalResponse_Trickle:
+		candidate, err := rtc.FromProtoTrickle(msg.Trickle)
+		if err != nil {
+			return true
+		}
+		if msg.Trickle.Target == livekit.SignalTarget_PUBLISHER {
+			c.publisher.AddICECandidate(candidate)
+		} else {
+			c.subscriber.AddICECandidate(candidate)
+		}
+
+	case *livekit.SignalResponse_Update:
+		c.updateParticipants(msg.Update.Participants)
+
+	case *livekit.SignalResponse_TrackPublished:
+		c.lock.Lock()
+		ch := c.pending[msg.TrackPublished.Cid]
+		c.lock.Unlock()
+		if ch != nil {
+			ch <- msg.TrackPublished.Track
+		}
+
+	case *livekit.SignalResponse_Leave:
+		c.params.Logger.Infow("bridge client removed from room", "reason", msg.Leave.Reason)
+		return false
+	}
+	return true
+}
+
+func (c *client) updateParticipants(updates []*livekit.ParticipantInfo) {
+	c.lock.Lock()
+	self := c.participant
+	for _, p := range updates {
+		if self != nil && p.Sid == self.Sid {
+			continue
+		}
+		if p.State == livekit.ParticipantInfo_DISCONNECTED {
+			delete(c.participants, livekit.ParticipantID(p.Sid))
+		} else {
+			c.participants[livekit.ParticipantID(p.Sid)] = p
+		}
+	}
+	participants := make([]*livekit.ParticipantInfo, 0, len(c.participants))
+	for _, p := range c.participants {
+		participants = append(participants, p)
+	}
+	c.lock.Unlock()
+
+	if c.params.OnParticipantsChanged != nil {
+		c.params.OnParticipantsChanged(participants)
+	}
+}
+
+func (c *client) onTrack(track *webrtc.TrackRemote, _ *webrtc.RTPReceiver) {
+	participantID, trackID := rtc.UnpackStreamID(track.StreamID())
+	if trackID == "" {
+		trackID = livekit.TrackID(track.ID())
+	}
+
+	c.lock.Lock()
+	p := c.participants[participantID]
+	c.lock.Unlock()
+	if p == nil {
+		c.params.Logger.Debugw("track of unknown participant", "pID", participantID, "trackID", trackID)
+		return
+	}
+	for _, info := range p.Tracks {
+		if livekit.TrackID(info.Sid) == trackID {
+			if c.params.OnTrack != nil {
+				go c.params.OnTrack(p, info, track)
+			}
+			return
+		}
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bridge
+
+// G.711 companding as used by SIP and PSTN gateways, converting between 16-bit linear PCM and
+// 8-bit µ-law (PCMU) or A-law (PCMA) samples
+
+const (
+	ulawBias = 0x84
+	ulawClip = 32635
+)
+
+var alawSegmentEnds = [8]int32{0x1f, 0x3f, 0x7f, 0xff, 0x1ff, 0x3ff, 0x7ff, 0xfff}
+
+// g711Codec is a G.711 variant negotiated with a SIP peer
+type g711Codec struct {
+	name        string
+	payloadType uint8
+	encode      func(sample int16) byte
+	decode      func(value byte) int16
+}
+
+var (
+	codecPCMU = g711Codec{name: "PCMU", payloadType: 0, encode: linearToULaw, decode: ulawToLinear}
+	codecPCMA = g711Codec{name: "PCMA", payloadType: 8, encode: linearToALaw, decode: alawToLinear}
+)
+
+func (c g711Codec) encodeFrame(pcm []int16, out []byte) []byte {
+	for _, s := range pcm {
+		out = append(out, c.encode(s))
+	}
+	return out
+}
+
+func (c g711Codec) decodeFrame(payload []byte, out []int16) []int16 {
+	for _, v := range payload {
+		out = append(out, c.decode(v))
+	}
+	return out
+}
+
+func linearToULaw(sample int16) byte {
+	s := int32(sample)
+	sign := byte(0)
+	if s < 0 {
+		s = -s
+		sign = 0x80
+	}
+	if s > ulawClip {
+		s = ulawClip
+	}
+	s += ulawBias
+
+	exponent := byte(7)
+	for mask := int32(0x4000); s&mask == 0 && exponent > 0; mask >>= 1 {
+		exponent--
+	}
+	mantissa := byte(s>>(exponent+3)) & 0x0f
+	return ^(sign | exponent<<4 | mantissa)
+}
+
+func ulawToLinear(value byte) int16 {
+	value = ^value
+	exponent := (value >> 4) & 0x07
+	mantissa := value & 0x0f
+	s := ((int32(mantissa) << 3) + ulawBias) << exponent
+	s -= ulawBias
+	if value&0x80 != 0 {
+		return int16(-s)
+	}
+	return int16(s)
+}
+
+func linearToALaw(sample int16) byte {
+	// A-law works on 13-bit samples
+	s := int32(sample) >> 3
+	mask := byte(0xd5)
+	if s < 0 {
+		mask = 0x55
+		s = -s - 1
+	}
+
+	segment := len(alawSegmentEnds)
+	for i, end := range alawSegmentEnds {
+		if s <= end {
+			segment = i
+			break
+		}
+	}
+	if segment == len(alawSegmentEnds) {
+		return 0x7f ^ mask
+	}
+
+	value := byte(segment << 4)
+	if segment < 2 {
+		value |= byte(s>>1) & 0x0f
+	} else {
+		value |= byte(s>>segment) & 0x0f
+	}
+	return value ^ mask
+}
+
+func alawToLinear(value byte) int16 {
+	value ^= 0x55
+	s := int32(value&0x0f) << 4
+	switch segment := (value & 0x70) >> 4; segment {
+	case 0:
+		s += 8
+	case 1:
+		s += 0x108
+	default:
+		s += 0x108
+		s <<= segment - 1
+	}
+	if value&0x80 != 0 {
+		return int16(s)
+	}
+	return int16(-s)
+}
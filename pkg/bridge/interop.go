@@ -0,0 +1,148 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bridge
+
+import (
+	"errors"
+	"net"
+	"strings"
+
+	"github.com/pion/rtcp"
+	"github.com/pion/webrtc/v3"
+
+	"github.com/livekit/livekit-server/pkg/config"
+)
+
+const (
+	PresetJanus     = "janus"
+	PresetMediasoup = "mediasoup"
+
+	FeedbackREMB = "remb"
+	FeedbackNone = "none"
+
+	defaultREMBBitrate = 2_000_000
+)
+
+var (
+	ErrUnknownPreset     = errors.New("unknown rtp preset")
+	ErrUnsupportedCodec  = errors.New("codec is not supported for rtp interop")
+	errNoPayloadType     = errors.New("preset has no payload type for codec")
+	errUnexpectedPayload = errors.New("unexpected payload type")
+)
+
+// builtinPresets match the defaults of Janus streaming mountpoints / rtp_forward and of mediasoup
+// plain transports as set up in their examples. Payload types need to match the peer's configuration,
+// presets with the same name in the config replace these.
+var builtinPresets = map[string]config.RTPPresetConfig{
+	PresetJanus: {
+		PayloadTypes: map[string]uint8{
+			"audio/opus": 111,
+			"video/vp8":  100,
+			"video/vp9":  101,
+			"video/h264": 126,
+		},
+		// Janus uses separate RTCP ports, e.g. videortcpport
+		RTCPMux:     false,
+		Feedback:    FeedbackREMB,
+		REMBBitrate: defaultREMBBitrate,
+	},
+	PresetMediasoup: {
+		PayloadTypes: map[string]uint8{
+			"audio/opus": 100,
+			"video/vp8":  101,
+			"video/h264": 102,
+			"video/vp9":  103,
+		},
+		RTCPMux:     true,
+		Feedback:    FeedbackREMB,
+		REMBBitrate: defaultREMBBitrate,
+	},
+}
+
+// rtpPreset resolves a preset by name, configured presets take precedence over built-in ones
+func rtpPreset(presets map[string]config.RTPPresetConfig, name string) (config.RTPPresetConfig, error) {
+	if name == "" {
+		name = PresetJanus
+	}
+	preset, ok := presets[name]
+	if !ok {
+		if preset, ok = builtinPresets[name]; !ok {
+			return preset, ErrUnknownPreset
+		}
+	}
+
+	payloadTypes := make(map[string]uint8, len(preset.PayloadTypes))
+	for mime, pt := range preset.PayloadTypes {
+		payloadTypes[strings.ToLower(mime)] = pt
+	}
+	preset.PayloadTypes = payloadTypes
+	if preset.Feedback == "" {
+		preset.Feedback = FeedbackREMB
+	}
+	if preset.REMBBitrate == 0 {
+		preset.REMBBitrate = defaultREMBBitrate
+	}
+	return preset, nil
+}
+
+// codecCapability returns the capability of an ingested codec, given as a mime type or codec name
+func codecCapability(kind string, codec string) (webrtc.RTPCodecCapability, error) {
+	mime := strings.ToLower(codec)
+	if !strings.Contains(mime, "/") {
+		mime = kind + "/" + mime
+	}
+	switch mime {
+	case strings.ToLower(webrtc.MimeTypeOpus):
+		return webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus, ClockRate: 48000, Channels: 2}, nil
+	case strings.ToLower(webrtc.MimeTypeVP8):
+		return webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeVP8, ClockRate: 90000}, nil
+	case strings.ToLower(webrtc.MimeTypeVP9):
+		return webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeVP9, ClockRate: 90000}, nil
+	case strings.ToLower(webrtc.MimeTypeH264):
+		return webrtc.RTPCodecCapability{
+			MimeType:    webrtc.MimeTypeH264,
+			ClockRate:   90000,
+			SDPFmtpLine: "level-asymmetry-allowed=1;packetization-mode=1;profile-level-id=42e01f",
+		}, nil
+	default:
+		return webrtc.RTPCodecCapability{}, ErrUnsupportedCodec
+	}
+}
+
+// rtcpPort returns the port RTCP is exchanged on for an RTP port
+func rtcpPort(preset config.RTPPresetConfig, port int) int {
+	if preset.RTCPMux {
+		return port
+	}
+	return port + 1
+}
+
+// isRTCP demultiplexes RTCP from RTP sharing a port (RFC 5761)
+func isRTCP(buf []byte) bool {
+	return len(buf) >= 2 && buf[1] >= 192 && buf[1] <= 223
+}
+
+// writeRTCP sends RTCP to a plain RTP peer, errors are ignored as the peer may not be listening yet
+func writeRTCP(conn *net.UDPConn, addr *net.UDPAddr, pkts ...rtcp.Packet) {
+	buf, err := rtcp.Marshal(pkts)
+	if err != nil {
+		return
+	}
+	if addr == nil {
+		_, _ = conn.Write(buf)
+	} else {
+		_, _ = conn.WriteToUDP(buf, addr)
+	}
+}
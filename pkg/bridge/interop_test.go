@@ -0,0 +1,71 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bridge
+
+import (
+	"testing"
+
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/livekit-server/pkg/config"
+)
+
+func TestRTPPreset(t *testing.T) {
+	t.Run("defaults to janus", func(t *testing.T) {
+		preset, err := rtpPreset(nil, "")
+		require.NoError(t, err)
+		require.False(t, preset.RTCPMux)
+		require.Equal(t, uint8(111), preset.PayloadTypes["audio/opus"])
+		require.Equal(t, 5005, rtcpPort(preset, 5004))
+	})
+
+	t.Run("configured presets replace built-in presets", func(t *testing.T) {
+		preset, err := rtpPreset(map[string]config.RTPPresetConfig{
+			PresetMediasoup: {PayloadTypes: map[string]uint8{"Video/VP8": 96}, RTCPMux: true},
+		}, PresetMediasoup)
+		require.NoError(t, err)
+		require.Equal(t, uint8(96), preset.PayloadTypes["video/vp8"])
+		require.NotContains(t, preset.PayloadTypes, "audio/opus")
+		require.Equal(t, FeedbackREMB, preset.Feedback)
+		require.Equal(t, 5004, rtcpPort(preset, 5004))
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		_, err := rtpPreset(nil, "freeswitch")
+		require.ErrorIs(t, err, ErrUnknownPreset)
+	})
+}
+
+func TestCodecCapability(t *testing.T) {
+	c, err := codecCapability("audio", "opus")
+	require.NoError(t, err)
+	require.Equal(t, webrtc.MimeTypeOpus, c.MimeType)
+	require.Equal(t, uint32(48000), c.ClockRate)
+
+	c, err = codecCapability("video", "video/H264")
+	require.NoError(t, err)
+	require.Equal(t, webrtc.MimeTypeH264, c.MimeType)
+
+	_, err = codecCapability("video", "theora")
+	require.ErrorIs(t, err, ErrUnsupportedCodec)
+}
+
+func TestIsRTCP(t *testing.T) {
+	require.True(t, isRTCP([]byte{0x80, 200}))
+	require.True(t, isRTCP([]byte{0x81, 206}))
+	require.False(t, isRTCP([]byte{0x80, 111}))
+	require.False(t, isRTCP([]byte{0x80, 0xe0}))
+}
@@ -0,0 +1,351 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bridge
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/livekit/protocol/auth"
+	"github.com/livekit/protocol/logger"
+	"github.com/livekit/protocol/utils"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/opus"
+)
+
+const (
+	BridgePrefix     = "BR_"
+	RTPForwardPrefix = "RF_"
+	SIPCallPrefix    = "SC_"
+)
+
+// Manager runs the bridges of this node
+type Manager struct {
+	conf          config.BridgeConfig
+	apiSecret     string
+	localURL      string
+	localInsecure bool
+	stunServers   []string
+	nodeIP        string
+
+	lock     sync.RWMutex
+	bridges  map[string]*Bridge
+	forwards map[string]*RTPForward
+	sip      *sipServer
+	closed   bool
+}
+
+// NewManager returns nil when bridging is disabled
+func NewManager(conf *config.Config, keyProvider auth.KeyProvider) *Manager {
+	if conf.Bridge.APIKey == "" {
+		return nil
+	}
+	secret := keyProvider.GetSecret(conf.Bridge.APIKey)
+	if secret == "" {
+		logger.Errorw("bridge api key not found, bridges disabled", nil, "apiKey", conf.Bridge.APIKey)
+		return nil
+	}
+
+	m := &Manager{
+		conf:        conf.Bridge,
+		apiSecret:   secret,
+		localURL:    conf.Bridge.LocalURL,
+		stunServers: conf.RTC.STUNServers,
+		nodeIP:      conf.RTC.NodeIP,
+		bridges:     make(map[string]*Bridge),
+		forwards:    make(map[string]*RTPForward),
+	}
+	if m.localURL == "" {
+		if conf.TLS.IsEnabled() {
+			m.localURL = fmt.Sprintf("wss://127.0.0.1:%d", conf.Port)
+			m.localInsecure = true
+		} else {
+			m.localURL = fmt.Sprintf("ws://127.0.0.1:%d", conf.Port)
+		}
+	}
+	return m
+}
+
+// Start starts the bridges configured on the server, it is called once the server is listening
+func (m *Manager) Start() {
+	if m == nil {
+		return
+	}
+	for _, spec := range m.conf.Bridges {
+		if _, err := m.Create(spec); err != nil {
+			logger.Warnw("could not start bridge", err, "room", spec.Room, "remoteURL", spec.RemoteURL)
+		}
+	}
+	for _, spec := range m.conf.RTPForwards {
+		if _, err := m.CreateRTPForward(spec); err != nil {
+			logger.Warnw("could not start rtp forward", err, "room", spec.Room, "direction", spec.Direction)
+		}
+	}
+	if m.conf.SIP.Port != 0 {
+		m.startSIP()
+	}
+}
+
+func (m *Manager) startSIP() {
+	if !opus.Supported {
+		logger.Errorw("could not accept sip calls", opus.ErrUnavailable)
+		return
+	}
+	sip, err := newSIPServer(m, m.nodeIP)
+	if err != nil {
+		logger.Errorw("could not accept sip calls", err, "port", m.conf.SIP.Port)
+		return
+	}
+
+	m.lock.Lock()
+	if m.closed {
+		m.lock.Unlock()
+		sip.close()
+		return
+	}
+	m.sip = sip
+	m.lock.Unlock()
+}
+
+func (m *Manager) Create(spec config.BridgeSpec) (*Info, error) {
+	if spec.Room == "" || spec.RemoteURL == "" || spec.RemoteToken == "" {
+		return nil, ErrInvalidBridge
+	}
+	switch spec.Direction {
+	case "", DirectionPull, DirectionPush, DirectionBoth:
+	default:
+		return nil, ErrInvalidBridge
+	}
+
+	m.lock.Lock()
+	if m.closed {
+		m.lock.Unlock()
+		return nil, errBridgeStopped
+	}
+	b := newBridge(utils.NewGuid(BridgePrefix), spec, m)
+	m.bridges[b.id] = b
+	m.lock.Unlock()
+
+	b.logger.Infow("starting bridge", "direction", b.spec.Direction)
+	go b.run(b.runSession)
+	return b.Info(), nil
+}
+
+func (m *Manager) Get(id string) (*Info, error) {
+	m.lock.RLock()
+	b := m.bridges[id]
+	m.lock.RUnlock()
+	if b == nil {
+		return nil, ErrBridgeNotFound
+	}
+	return b.Info(), nil
+}
+
+func (m *Manager) Stop(id string) (*Info, error) {
+	m.lock.Lock()
+	b := m.bridges[id]
+	delete(m.bridges, id)
+	m.lock.Unlock()
+	if b == nil {
+		return nil, ErrBridgeNotFound
+	}
+
+	b.stop()
+	b.logger.Infow("bridge stopped")
+	return b.Info(), nil
+}
+
+// List returns the running bridges, oldest first
+func (m *Manager) List() []*Info {
+	m.lock.RLock()
+	infos := make([]*Info, 0, len(m.bridges))
+	for _, b := range m.bridges {
+		infos = append(infos, b.Info())
+	}
+	m.lock.RUnlock()
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].StartedAt < infos[j].StartedAt
+	})
+	return infos
+}
+
+func (m *Manager) CreateRTPForward(spec config.RTPForwardSpec) (*RTPForwardInfo, error) {
+	if spec.Room == "" || spec.Identity == "" || (spec.AudioPort == 0 && spec.VideoPort == 0) {
+		return nil, ErrInvalidRTPForward
+	}
+	switch spec.Direction {
+	case RTPDirectionForward:
+		if spec.Host == "" {
+			return nil, ErrInvalidRTPForward
+		}
+	case RTPDirectionIngest:
+	default:
+		return nil, ErrInvalidRTPForward
+	}
+	preset, err := rtpPreset(m.conf.RTPPresets, spec.Preset)
+	if err != nil {
+		return nil, err
+	}
+
+	m.lock.Lock()
+	if m.closed {
+		m.lock.Unlock()
+		return nil, errBridgeStopped
+	}
+	f := newRTPForward(utils.NewGuid(RTPForwardPrefix), spec, preset, m)
+	m.forwards[f.id] = f
+	m.lock.Unlock()
+
+	f.logger.Infow("starting rtp forward", "identity", spec.Identity, "host", spec.Host, "audioPort", spec.AudioPort, "videoPort", spec.VideoPort)
+	go f.run(f.runSession)
+	return f.Info(), nil
+}
+
+func (m *Manager) GetRTPForward(id string) (*RTPForwardInfo, error) {
+	m.lock.RLock()
+	f := m.forwards[id]
+	m.lock.RUnlock()
+	if f == nil {
+		return nil, ErrBridgeNotFound
+	}
+	return f.Info(), nil
+}
+
+func (m *Manager) StopRTPForward(id string) (*RTPForwardInfo, error) {
+	m.lock.Lock()
+	f := m.forwards[id]
+	delete(m.forwards, id)
+	m.lock.Unlock()
+	if f == nil {
+		return nil, ErrBridgeNotFound
+	}
+
+	f.stop()
+	f.logger.Infow("rtp forward stopped")
+	return f.Info(), nil
+}
+
+// ListRTPForwards returns the running rtp forwards and ingests, oldest first
+func (m *Manager) ListRTPForwards() []*RTPForwardInfo {
+	m.lock.RLock()
+	infos := make([]*RTPForwardInfo, 0, len(m.forwards))
+	for _, f := range m.forwards {
+		infos = append(infos, f.Info())
+	}
+	m.lock.RUnlock()
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].StartedAt < infos[j].StartedAt
+	})
+	return infos
+}
+
+// ListSIPCalls returns the calls in progress on this node, oldest first
+func (m *Manager) ListSIPCalls() []*SIPCallInfo {
+	m.lock.RLock()
+	sip := m.sip
+	m.lock.RUnlock()
+	if sip == nil {
+		return nil
+	}
+	return sip.listCalls()
+}
+
+func (m *Manager) GetSIPCall(id string) (*SIPCallInfo, error) {
+	c, err := m.findSIPCall(id)
+	if err != nil {
+		return nil, err
+	}
+	return c.Info(), nil
+}
+
+// HangupSIPCall removes the caller from the room and ends the call
+func (m *Manager) HangupSIPCall(id string) (*SIPCallInfo, error) {
+	c, err := m.findSIPCall(id)
+	if err != nil {
+		return nil, err
+	}
+
+	c.hangup()
+	<-c.done
+	c.logger.Infow("sip call hung up")
+	return c.Info(), nil
+}
+
+func (m *Manager) findSIPCall(id string) (*sipCall, error) {
+	m.lock.RLock()
+	sip := m.sip
+	m.lock.RUnlock()
+	if sip == nil {
+		return nil, ErrSIPCallNotFound
+	}
+	c := sip.findCall(id)
+	if c == nil {
+		return nil, ErrSIPCallNotFound
+	}
+	return c, nil
+}
+
+func (m *Manager) Close() {
+	if m == nil {
+		return
+	}
+
+	m.lock.Lock()
+	m.closed = true
+	bridges := m.bridges
+	m.bridges = make(map[string]*Bridge)
+	forwards := m.forwards
+	m.forwards = make(map[string]*RTPForward)
+	sip := m.sip
+	m.sip = nil
+	m.lock.Unlock()
+
+	if sip != nil {
+		sip.close()
+	}
+	for _, b := range bridges {
+		b.stop()
+	}
+	for _, f := range forwards {
+		f.stop()
+	}
+}
+
+// localToken grants bridge participants access to a local room. The collector stays hidden and
+// does not publish. Subscribing is allowed for all, clients connect through the subscriber transport
+// and never subscribe automatically.
+func (m *Manager) localToken(room, identity, name string, collector bool) (string, error) {
+	grant := &auth.VideoGrant{
+		RoomJoin: true,
+		Room:     room,
+		Hidden:   collector,
+	}
+	grant.SetCanPublish(!collector)
+	grant.SetCanPublishData(false)
+	grant.SetCanSubscribe(true)
+	if name == "" {
+		name = strings.TrimPrefix(identity, IdentityPrefix)
+	}
+	return auth.NewAccessToken(m.conf.APIKey, m.apiSecret).
+		AddGrant(grant).
+		SetIdentity(identity).
+		SetName(name).
+		ToJWT()
+}
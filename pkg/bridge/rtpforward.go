@@ -0,0 +1,613 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bridge
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pion/rtcp"
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v3"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/mediatransportutil"
+	"github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/logger"
+)
+
+const (
+	RTPDirectionForward = "forward"
+	RTPDirectionIngest  = "ingest"
+
+	rtcpInterval = time.Second
+)
+
+var ErrInvalidRTPForward = errors.New("rtp forward requires room, identity, a port, a valid direction and a host when forwarding")
+
+// RTPForwardInfo describes a running exchange of media with a plain RTP peer
+type RTPForwardInfo struct {
+	ID        string           `json:"id"`
+	Room      livekit.RoomName `json:"room"`
+	Direction string           `json:"direction"`
+	Preset    string           `json:"preset"`
+	Identity  string           `json:"identity"`
+	Host      string           `json:"host,omitempty"`
+	AudioPort int              `json:"audio_port,omitempty"`
+	VideoPort int              `json:"video_port,omitempty"`
+	State     State            `json:"state"`
+	Error     string           `json:"error,omitempty"`
+	// unix milliseconds
+	StartedAt int64 `json:"started_at"`
+	Tracks    int32 `json:"tracks"`
+}
+
+// RTPForward exchanges the media of a participant with a plain RTP peer such as a Janus or
+// mediasoup deployment. Forwards send the participant's first audio and video tracks to the
+// peer, ingests publish the media sent by the peer as the participant.
+type RTPForward struct {
+	*worker
+	spec    config.RTPForwardSpec
+	preset  config.RTPPresetConfig
+	manager *Manager
+}
+
+func newRTPForward(id string, spec config.RTPForwardSpec, preset config.RTPPresetConfig, m *Manager) *RTPForward {
+	if spec.Preset == "" {
+		spec.Preset = PresetJanus
+	}
+	if spec.AudioCodec == "" {
+		spec.AudioCodec = webrtc.MimeTypeOpus
+	}
+	if spec.VideoCodec == "" {
+		spec.VideoCodec = webrtc.MimeTypeVP8
+	}
+	return &RTPForward{
+		worker:  newWorker(id, m.conf.ReconnectDelay, logger.GetLogger().WithValues("rtpForwardID", id, "room", spec.Room, "direction", spec.Direction, "preset", spec.Preset)),
+		spec:    spec,
+		preset:  preset,
+		manager: m,
+	}
+}
+
+func (f *RTPForward) Info() *RTPForwardInfo {
+	state, lastErr := f.status()
+	return &RTPForwardInfo{
+		ID:        f.id,
+		Room:      livekit.RoomName(f.spec.Room),
+		Direction: f.spec.Direction,
+		Preset:    f.spec.Preset,
+		Identity:  f.spec.Identity,
+		Host:      f.spec.Host,
+		AudioPort: f.spec.AudioPort,
+		VideoPort: f.spec.VideoPort,
+		State:     state,
+		Error:     lastErr,
+		StartedAt: f.startedAt.UnixMilli(),
+		Tracks:    f.tracks.Load(),
+	}
+}
+
+func (f *RTPForward) runSession() error {
+	if f.spec.Direction == RTPDirectionIngest {
+		return f.runIngest()
+	}
+	return f.runForward()
+}
+
+// ----------------------------------------------------
+
+type forwardSession struct {
+	forward *RTPForward
+
+	lock         sync.Mutex
+	source       *client
+	participants []*livekit.ParticipantInfo
+	subscribed   map[livekit.TrackType]livekit.TrackID
+}
+
+func (f *RTPForward) runForward() error {
+	s := &forwardSession{
+		forward:    f,
+		subscribed: make(map[livekit.TrackType]livekit.TrackID),
+	}
+
+	// the collector subscribes to the participant's tracks without showing up in the room
+	token, err := f.manager.localToken(f.spec.Room, IdentityPrefix+f.id, "", true)
+	if err != nil {
+		return err
+	}
+	source, err := dialClient(f.ctx, clientParams{
+		URL:                   f.manager.localURL,
+		Token:                 token,
+		InsecureSkipVerify:    f.manager.localInsecure,
+		Logger:                f.logger,
+		OnParticipantsChanged: s.onParticipantsChanged,
+		OnTrack:               s.onTrack,
+	})
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	s.lock.Lock()
+	s.source = source
+	s.lock.Unlock()
+	s.updateSubscriptions()
+
+	f.logger.Infow("rtp forward connected", "host", f.spec.Host)
+	f.setState(StateActive, nil)
+
+	select {
+	case <-f.ctx.Done():
+		return errBridgeStopped
+	case <-source.Done():
+		return errBridgeEnded
+	}
+}
+
+func (s *forwardSession) onParticipantsChanged(participants []*livekit.ParticipantInfo) {
+	s.lock.Lock()
+	s.participants = participants
+	s.lock.Unlock()
+
+	s.updateSubscriptions()
+}
+
+// updateSubscriptions subscribes to an audio and a video track of the participant, preferring the
+// microphone and camera
+func (s *forwardSession) updateSubscriptions() {
+	f := s.forward
+
+	s.lock.Lock()
+	source := s.source
+	if source == nil {
+		s.lock.Unlock()
+		return
+	}
+	var trackIDs []livekit.TrackID
+	for _, p := range s.participants {
+		if p.Identity != f.spec.Identity {
+			continue
+		}
+		for _, kind := range []livekit.TrackType{livekit.TrackType_AUDIO, livekit.TrackType_VIDEO} {
+			if s.subscribed[kind] != "" || f.port(kind) == 0 {
+				continue
+			}
+			var selected *livekit.TrackInfo
+			for _, t := range p.Tracks {
+				if t.Type != kind {
+					continue
+				}
+				if selected == nil || t.Source == livekit.TrackSource_MICROPHONE || t.Source == livekit.TrackSource_CAMERA {
+					selected = t
+				}
+			}
+			if selected != nil {
+				s.subscribed[kind] = livekit.TrackID(selected.Sid)
+				trackIDs = append(trackIDs, livekit.TrackID(selected.Sid))
+			}
+		}
+	}
+	s.lock.Unlock()
+
+	if len(trackIDs) == 0 {
+		return
+	}
+	if err := source.UpdateSubscription(trackIDs, true); err != nil {
+		f.logger.Warnw("could not subscribe to forwarded tracks", err)
+	}
+}
+
+func (s *forwardSession) onTrack(_ *livekit.ParticipantInfo, info *livekit.TrackInfo, track *webrtc.TrackRemote) {
+	s.lock.Lock()
+	source := s.source
+	subscribed := s.subscribed[info.Type] == livekit.TrackID(info.Sid)
+	s.lock.Unlock()
+	if source == nil || !subscribed {
+		return
+	}
+
+	if err := s.forward.forwardTrack(source, info, track); err != nil {
+		s.forward.logger.Warnw("could not forward track", err, "trackID", info.Sid)
+	}
+
+	// another track of the participant may be forwarded instead
+	s.lock.Lock()
+	if s.subscribed[info.Type] == livekit.TrackID(info.Sid) {
+		delete(s.subscribed, info.Type)
+	}
+	s.lock.Unlock()
+	s.updateSubscriptions()
+}
+
+func (f *RTPForward) port(kind livekit.TrackType) int {
+	if kind == livekit.TrackType_VIDEO {
+		return f.spec.VideoPort
+	}
+	return f.spec.AudioPort
+}
+
+// forwardTrack sends RTP of a subscribed track to the peer, with the payload type of the preset
+func (f *RTPForward) forwardTrack(source *client, info *livekit.TrackInfo, track *webrtc.TrackRemote) error {
+	codec := track.Codec()
+	pt, ok := f.preset.PayloadTypes[strings.ToLower(codec.MimeType)]
+	if !ok {
+		return errNoPayloadType
+	}
+
+	port := f.port(info.Type)
+	conn, err := dialUDP(f.spec.Host, port)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	rtcpConn := conn
+	if !f.preset.RTCPMux {
+		if rtcpConn, err = dialUDP(f.spec.Host, rtcpPort(f.preset, port)); err != nil {
+			return err
+		}
+		defer rtcpConn.Close()
+	}
+
+	f.tracks.Inc()
+	defer f.tracks.Dec()
+	log := f.logger.WithValues("trackID", info.Sid, "mime", codec.MimeType, "payloadType", pt, "port", port)
+	log.Infow("forwarding track")
+
+	ssrc := uint32(track.SSRC())
+	sr := &senderReport{ssrc: ssrc, clockRate: codec.ClockRate}
+	done := make(chan struct{})
+	defer close(done)
+	go f.senderReportWorker(rtcpConn, sr, done)
+	go f.feedbackWorker(rtcpConn, func() {
+		source.RequestKeyFrame(ssrc)
+	})
+
+	for {
+		pkt, _, err := track.ReadRTP()
+		if err != nil {
+			log.Infow("forwarded track ended")
+			return nil
+		}
+		pkt.PayloadType = pt
+		// extension ids are negotiated with WebRTC peers only
+		pkt.Header.Extension = false
+		pkt.Header.Extensions = nil
+		buf, err := pkt.Marshal()
+		if err != nil {
+			continue
+		}
+		// the peer may not be listening yet
+		_, _ = conn.Write(buf)
+		sr.update(pkt)
+	}
+}
+
+// feedbackWorker relays key frame requests of the peer until the connection is closed
+func (f *RTPForward) feedbackWorker(conn *net.UDPConn, requestKeyFrame func()) {
+	buf := make([]byte, 1500)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			// connection refused until the peer listens
+			continue
+		}
+		if !isRTCP(buf[:n]) {
+			continue
+		}
+		pkts, err := rtcp.Unmarshal(buf[:n])
+		if err != nil {
+			continue
+		}
+		for _, pkt := range pkts {
+			switch pkt.(type) {
+			case *rtcp.PictureLossIndication, *rtcp.FullIntraRequest:
+				requestKeyFrame()
+			}
+		}
+	}
+}
+
+// senderReport tracks what is needed to send RTCP sender reports for a forwarded track, so
+// the peer can synchronize audio and video
+type senderReport struct {
+	ssrc      uint32
+	clockRate uint32
+
+	lock          sync.Mutex
+	lastTimestamp uint32
+	lastAt        time.Time
+	packets       uint32
+	octets        uint32
+}
+
+func (s *senderReport) update(pkt *rtp.Packet) {
+	s.lock.Lock()
+	s.lastTimestamp = pkt.Timestamp
+	s.lastAt = time.Now()
+	s.packets++
+	s.octets += uint32(len(pkt.Payload))
+	s.lock.Unlock()
+}
+
+func (s *senderReport) build(now time.Time) *rtcp.SenderReport {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.lastAt.IsZero() {
+		return nil
+	}
+	elapsed := now.Sub(s.lastAt)
+	return &rtcp.SenderReport{
+		SSRC:        s.ssrc,
+		NTPTime:     uint64(mediatransportutil.ToNtpTime(now)),
+		RTPTime:     s.lastTimestamp + uint32(elapsed.Seconds()*float64(s.clockRate)),
+		PacketCount: s.packets,
+		OctetCount:  s.octets,
+	}
+}
+
+func (f *RTPForward) senderReportWorker(conn *net.UDPConn, sr *senderReport, done <-chan struct{}) {
+	ticker := time.NewTicker(rtcpInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			writeRTCP(conn, nil, &rtcp.Goodbye{Sources: []uint32{sr.ssrc}})
+			return
+		case now := <-ticker.C:
+			if pkt := sr.build(now); pkt != nil {
+				writeRTCP(conn, nil, pkt)
+			}
+		}
+	}
+}
+
+// ----------------------------------------------------
+
+func (f *RTPForward) runIngest() error {
+	token, err := f.manager.localToken(f.spec.Room, f.spec.Identity, "", false)
+	if err != nil {
+		return err
+	}
+	c, err := dialClient(f.ctx, clientParams{
+		URL:                f.manager.localURL,
+		Token:              token,
+		InsecureSkipVerify: f.manager.localInsecure,
+		Logger:             f.logger,
+	})
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	ctx, cancel := context.WithCancel(f.ctx)
+	defer cancel()
+	errChan := make(chan error, 2)
+	kinds := 0
+	if f.spec.AudioPort != 0 {
+		kinds++
+		go func() {
+			errChan <- f.ingestTrack(ctx, c, livekit.TrackType_AUDIO, f.spec.AudioPort, f.spec.AudioCodec)
+		}()
+	}
+	if f.spec.VideoPort != 0 {
+		kinds++
+		go func() {
+			errChan <- f.ingestTrack(ctx, c, livekit.TrackType_VIDEO, f.spec.VideoPort, f.spec.VideoCodec)
+		}()
+	}
+
+	f.logger.Infow("rtp ingest connected", "audioPort", f.spec.AudioPort, "videoPort", f.spec.VideoPort)
+	f.setState(StateActive, nil)
+
+	for ; kinds > 0; kinds-- {
+		select {
+		case <-f.ctx.Done():
+			return errBridgeStopped
+		case <-c.Done():
+			return errBridgeEnded
+		case err = <-errChan:
+			if err != nil {
+				return err
+			}
+		}
+	}
+	<-f.ctx.Done()
+	return errBridgeStopped
+}
+
+// ingestPeer is the address media is received from, feedback is sent to its RTCP address
+type ingestPeer struct {
+	lock     sync.Mutex
+	ssrc     uint32
+	rtcpAddr *net.UDPAddr
+}
+
+func (p *ingestPeer) get() (uint32, *net.UDPAddr) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.ssrc, p.rtcpAddr
+}
+
+// ingestTrack publishes RTP received on a port until the session ends
+func (f *RTPForward) ingestTrack(ctx context.Context, c *client, kind livekit.TrackType, port int, codec string) error {
+	kindName := strings.ToLower(kind.String())
+	capability, err := codecCapability(kindName, codec)
+	if err != nil {
+		return err
+	}
+	pt, ok := f.preset.PayloadTypes[strings.ToLower(capability.MimeType)]
+	if !ok {
+		return errNoPayloadType
+	}
+
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{Port: port})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	rtcpConn := conn
+	if !f.preset.RTCPMux {
+		if rtcpConn, err = net.ListenUDP("udp", &net.UDPAddr{Port: rtcpPort(f.preset, port)}); err != nil {
+			return err
+		}
+		defer rtcpConn.Close()
+	}
+	go func() {
+		<-ctx.Done()
+		_ = conn.Close()
+		_ = rtcpConn.Close()
+	}()
+
+	track, err := webrtc.NewTrackLocalStaticRTP(capability, f.id+"-"+kindName, f.id)
+	if err != nil {
+		return err
+	}
+	source := livekit.TrackSource_MICROPHONE
+	if kind == livekit.TrackType_VIDEO {
+		source = livekit.TrackSource_CAMERA
+	}
+	sender, _, err := c.PublishTrack(track, kindName, &livekit.TrackInfo{Type: kind, Source: source})
+	if err != nil {
+		return err
+	}
+	defer c.UnpublishTrack(sender)
+
+	f.tracks.Inc()
+	defer f.tracks.Dec()
+	log := f.logger.WithValues("mime", capability.MimeType, "payloadType", pt, "port", port)
+	log.Infow("ingesting track")
+
+	peer := &ingestPeer{}
+	go f.ingestFeedbackWorker(ctx, rtcpConn, sender, peer)
+	if rtcpConn != conn {
+		go f.ingestRTCPWorker(rtcpConn, peer)
+	}
+
+	buf := make([]byte, 1500)
+	warned := false
+	for {
+		n, addr, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		if isRTCP(buf[:n]) {
+			peer.lock.Lock()
+			peer.rtcpAddr = addr
+			peer.lock.Unlock()
+			continue
+		}
+
+		pkt := &rtp.Packet{}
+		if err = pkt.Unmarshal(buf[:n]); err != nil {
+			continue
+		}
+		if pkt.PayloadType != pt {
+			if !warned {
+				log.Warnw("dropping rtp packets", errUnexpectedPayload, "received", pkt.PayloadType)
+				warned = true
+			}
+			continue
+		}
+
+		peer.lock.Lock()
+		peer.ssrc = pkt.SSRC
+		if peer.rtcpAddr == nil {
+			peer.rtcpAddr = &net.UDPAddr{IP: addr.IP, Port: rtcpPort(f.preset, addr.Port), Zone: addr.Zone}
+		}
+		peer.lock.Unlock()
+
+		if err = track.WriteRTP(pkt); err != nil && ctx.Err() != nil {
+			return nil
+		}
+	}
+}
+
+// ingestRTCPWorker learns the RTCP address of peers sending RTCP on a separate port
+func (f *RTPForward) ingestRTCPWorker(conn *net.UDPConn, peer *ingestPeer) {
+	buf := make([]byte, 1500)
+	for {
+		n, addr, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		if isRTCP(buf[:n]) {
+			peer.lock.Lock()
+			peer.rtcpAddr = addr
+			peer.lock.Unlock()
+		}
+	}
+}
+
+// ingestFeedbackWorker relays key frame requests of subscribers to the peer and, with REMB
+// feedback, advertises the preset bitrate. NACK and transport-cc are never sent.
+func (f *RTPForward) ingestFeedbackWorker(ctx context.Context, conn *net.UDPConn, sender *webrtc.RTPSender, peer *ingestPeer) {
+	go func() {
+		for {
+			pkts, _, err := sender.ReadRTCP()
+			if err != nil {
+				return
+			}
+			for _, pkt := range pkts {
+				switch pkt.(type) {
+				case *rtcp.PictureLossIndication, *rtcp.FullIntraRequest:
+					if ssrc, addr := peer.get(); addr != nil {
+						writeRTCP(conn, addr, &rtcp.PictureLossIndication{MediaSSRC: ssrc})
+					}
+				}
+			}
+		}
+	}()
+
+	if f.preset.Feedback != FeedbackREMB {
+		return
+	}
+	ticker := time.NewTicker(rtcpInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if ssrc, addr := peer.get(); addr != nil {
+				writeRTCP(conn, addr, &rtcp.ReceiverEstimatedMaximumBitrate{
+					Bitrate: float32(f.preset.REMBBitrate),
+					SSRCs:   []uint32{ssrc},
+				})
+			}
+		}
+	}
+}
+
+func dialUDP(host string, port int) (*net.UDPConn, error) {
+	addr, err := net.ResolveUDPAddr("udp", net.JoinHostPort(host, strconv.Itoa(port)))
+	if err != nil {
+		return nil, err
+	}
+	return net.DialUDP("udp", nil, addr)
+}
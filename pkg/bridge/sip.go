@@ -0,0 +1,372 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bridge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/livekit/protocol/logger"
+
+	"github.com/livekit/livekit-server/pkg/config"
+)
+
+const (
+	// SIPIdentityPrefix is prepended to the calling number to form the identity of callers
+	SIPIdentityPrefix = "sip_"
+
+	// SIP timers (RFC 3261 section 17.1.1.1)
+	sipT1      = 500 * time.Millisecond
+	sipT2      = 4 * time.Second
+	sipTimeout = 64 * sipT1
+
+	sipMaxMessageSize = 65535
+	sipAllow          = "INVITE, ACK, CANCEL, BYE, OPTIONS, INFO"
+	sipUserAgent      = "LiveKit"
+
+	defaultSIPInputTimeout = 30 * time.Second
+)
+
+var (
+	ErrSIPCallNotFound = errors.New("sip call not found")
+	errNoRTPPort       = errors.New("no rtp port available")
+)
+
+// sipServer accepts calls on a UDP port and runs them until either side hangs up
+type sipServer struct {
+	conf     config.SIPConfig
+	manager  *Manager
+	host     string
+	conn     *net.UDPConn
+	networks []*net.IPNet
+	logger   logger.Logger
+
+	lock     sync.Mutex
+	calls    map[string]*sipCall
+	nextPort int
+	closed   bool
+}
+
+func newSIPServer(m *Manager, nodeIP string) (*sipServer, error) {
+	conf := m.conf.SIP
+	if conf.InputTimeout <= 0 {
+		conf.InputTimeout = defaultSIPInputTimeout
+	}
+	s := &sipServer{
+		conf:     conf,
+		manager:  m,
+		host:     conf.ExternalIP,
+		logger:   logger.GetLogger().WithValues("sipPort", conf.Port),
+		calls:    make(map[string]*sipCall),
+		nextPort: conf.RTPPortStart,
+	}
+	if s.host == "" {
+		s.host = nodeIP
+	}
+	for _, network := range conf.AllowedNetworks {
+		_, ipNet, err := net.ParseCIDR(network)
+		if err != nil {
+			return nil, err
+		}
+		s.networks = append(s.networks, ipNet)
+	}
+
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{Port: conf.Port})
+	if err != nil {
+		return nil, err
+	}
+	s.conn = conn
+
+	go s.readWorker()
+	s.logger.Infow("accepting sip calls", "host", s.host, "rtpPortStart", conf.RTPPortStart, "rtpPortEnd", conf.RTPPortEnd)
+	return s, nil
+}
+
+func (s *sipServer) readWorker() {
+	buf := make([]byte, sipMaxMessageSize)
+	for {
+		n, addr, err := s.conn.ReadFromUDP(buf)
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				s.logger.Warnw("sip connection failed", err)
+			}
+			return
+		}
+		// keep-alives are empty lines
+		if strings.TrimSpace(string(buf[:n])) == "" {
+			continue
+		}
+
+		msg, err := parseSIPMessage(buf[:n])
+		if err != nil {
+			s.logger.Debugw("dropping sip message", "error", err, "addr", addr)
+			continue
+		}
+		if msg.isRequest() {
+			s.handleRequest(msg, addr)
+		} else {
+			s.handleResponse(msg)
+		}
+	}
+}
+
+func (s *sipServer) handleRequest(req *sipMessage, addr *net.UDPAddr) {
+	if req.get("Call-ID") == "" || req.get("CSeq") == "" || len(req.getAll("Via")) == 0 {
+		s.respond(req, addr, 400, "Bad Request")
+		return
+	}
+
+	switch req.method {
+	case "INVITE":
+		s.onInvite(req, addr)
+	case "OPTIONS":
+		res := newSIPResponse(req, 200, "OK", "")
+		res.add("Allow", sipAllow)
+		res.add("Accept", "application/sdp")
+		s.send(res, addr)
+	case "ACK":
+		if c := s.getCall(req.get("Call-ID")); c != nil {
+			c.onAck()
+		}
+	case "BYE", "CANCEL", "INFO":
+		c := s.getCall(req.get("Call-ID"))
+		if c == nil {
+			s.respond(req, addr, 481, "Call/Transaction Does Not Exist")
+			return
+		}
+		s.respond(req, addr, 200, "OK")
+		switch req.method {
+		case "BYE":
+			c.onBye()
+		case "CANCEL":
+			// the call is answered right away, a CANCEL crossing the answer ends it like a BYE
+			c.onBye()
+		case "INFO":
+			if strings.EqualFold(req.get("Content-Type"), "application/dtmf-relay") {
+				if digit, ok := dtmfRelayDigit(req.body); ok {
+					c.onDigit(digit)
+				}
+			}
+		}
+	default:
+		res := newSIPResponse(req, 501, "Not Implemented", "")
+		res.add("Allow", sipAllow)
+		s.send(res, addr)
+	}
+}
+
+func (s *sipServer) onInvite(req *sipMessage, addr *net.UDPAddr) {
+	if c := s.getCall(req.get("Call-ID")); c != nil {
+		// retransmission of the INVITE or a re-INVITE within the call
+		c.onInvite(req, addr)
+		return
+	}
+	if sipHeaderParam(req.get("To"), "tag") != "" {
+		s.respond(req, addr, 481, "Call/Transaction Does Not Exist")
+		return
+	}
+	if !s.isAllowed(addr.IP) {
+		s.logger.Infow("rejecting sip call from disallowed address", "addr", addr)
+		s.respond(req, addr, 403, "Forbidden")
+		return
+	}
+
+	offer, err := parseSDPOffer(req.body)
+	if err != nil {
+		s.logger.Infow("rejecting sip call", "error", err, "from", req.get("From"))
+		s.respond(req, addr, 488, "Not Acceptable Here")
+		return
+	}
+
+	s.lock.Lock()
+	if s.closed {
+		s.lock.Unlock()
+		s.respond(req, addr, 503, "Service Unavailable")
+		return
+	}
+	if s.conf.MaxCalls > 0 && len(s.calls) >= s.conf.MaxCalls {
+		s.lock.Unlock()
+		s.respond(req, addr, 486, "Busy Here")
+		return
+	}
+	rtpConn, err := s.listenRTPLocked()
+	if err != nil {
+		s.lock.Unlock()
+		s.logger.Warnw("could not accept sip call", err)
+		s.respond(req, addr, 503, "Service Unavailable")
+		return
+	}
+	c := newSIPCall(s, req, addr, offer, rtpConn)
+	s.calls[req.get("Call-ID")] = c
+	s.lock.Unlock()
+
+	s.respond(req, addr, 100, "Trying")
+	go c.run()
+}
+
+func (s *sipServer) handleResponse(res *sipMessage) {
+	c := s.getCall(res.get("Call-ID"))
+	if c == nil {
+		return
+	}
+	if _, method := res.cseq(); method == "BYE" && res.status >= 200 {
+		c.onByeAnswered()
+	}
+}
+
+func (s *sipServer) respond(req *sipMessage, addr *net.UDPAddr, status int, reason string) {
+	s.send(newSIPResponse(req, status, reason, ""), addr)
+}
+
+func (s *sipServer) send(msg *sipMessage, addr *net.UDPAddr) {
+	if msg.get("User-Agent") == "" {
+		msg.add("User-Agent", sipUserAgent)
+	}
+	if _, err := s.conn.WriteToUDP(msg.marshal(), addr); err != nil {
+		s.logger.Debugw("could not send sip message", "error", err, "addr", addr)
+	}
+}
+
+func (s *sipServer) isAllowed(ip net.IP) bool {
+	if len(s.networks) == 0 {
+		return true
+	}
+	for _, network := range s.networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// listenRTPLocked binds the next free media port of the range
+func (s *sipServer) listenRTPLocked() (*net.UDPConn, error) {
+	size := s.conf.RTPPortEnd - s.conf.RTPPortStart + 1
+	for i := 0; i < size; i++ {
+		port := s.nextPort
+		s.nextPort++
+		if s.nextPort > s.conf.RTPPortEnd {
+			s.nextPort = s.conf.RTPPortStart
+		}
+		if conn, err := net.ListenUDP("udp", &net.UDPAddr{Port: port}); err == nil {
+			return conn, nil
+		}
+	}
+	return nil, errNoRTPPort
+}
+
+// contact is the address peers send requests within a call to
+func (s *sipServer) contact() string {
+	return fmt.Sprintf("<sip:livekit@%s>", net.JoinHostPort(s.host, strconv.Itoa(s.conf.Port)))
+}
+
+func (s *sipServer) via(branch string) string {
+	return fmt.Sprintf("SIP/2.0/UDP %s;branch=%s;rport", net.JoinHostPort(s.host, strconv.Itoa(s.conf.Port)), branch)
+}
+
+func (s *sipServer) routeForNumber(number string) *config.SIPRoute {
+	if number == "" {
+		return nil
+	}
+	for i := range s.conf.Routes {
+		if s.conf.Routes[i].Number == number {
+			return &s.conf.Routes[i]
+		}
+	}
+	return nil
+}
+
+func (s *sipServer) routeForCode(code string) *config.SIPRoute {
+	if code == "" {
+		return nil
+	}
+	for i := range s.conf.Routes {
+		if s.conf.Routes[i].Code == code {
+			return &s.conf.Routes[i]
+		}
+	}
+	return nil
+}
+
+func (s *sipServer) getCall(callID string) *sipCall {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.calls[callID]
+}
+
+func (s *sipServer) findCall(id string) *sipCall {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, c := range s.calls {
+		if c.id == id {
+			return c
+		}
+	}
+	return nil
+}
+
+func (s *sipServer) removeCall(c *sipCall) {
+	s.lock.Lock()
+	if s.calls[c.callID] == c {
+		delete(s.calls, c.callID)
+	}
+	s.lock.Unlock()
+}
+
+// listCalls returns the calls in progress, oldest first
+func (s *sipServer) listCalls() []*SIPCallInfo {
+	s.lock.Lock()
+	infos := make([]*SIPCallInfo, 0, len(s.calls))
+	for _, c := range s.calls {
+		infos = append(infos, c.Info())
+	}
+	s.lock.Unlock()
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].StartedAt < infos[j].StartedAt
+	})
+	return infos
+}
+
+// close hangs up all calls
+func (s *sipServer) close() {
+	s.lock.Lock()
+	s.closed = true
+	calls := make([]*sipCall, 0, len(s.calls))
+	for _, c := range s.calls {
+		calls = append(calls, c)
+	}
+	s.lock.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), sipT2)
+	defer cancel()
+	for _, c := range calls {
+		c.hangup()
+	}
+	for _, c := range calls {
+		select {
+		case <-c.done:
+		case <-ctx.Done():
+		}
+	}
+	_ = s.conn.Close()
+}
@@ -0,0 +1,164 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bridge
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testInvite = "INVITE sip:1001@10.0.0.1:5060 SIP/2.0\r\n" +
+	"v: SIP/2.0/UDP 10.0.0.2:5060;branch=z9hG4bK776asdhds\r\n" +
+	"Via: SIP/2.0/UDP 10.0.0.3:5060;branch=z9hG4bK1234\r\n" +
+	"Max-Forwards: 70\r\n" +
+	"To: <sip:1001@10.0.0.1>\r\n" +
+	"f: \"Alice Smith\" <sip:+15550001@10.0.0.2>;tag=1928301774\r\n" +
+	"i: a84b4c76e66710@10.0.0.2\r\n" +
+	"CSeq: 314159 INVITE\r\n" +
+	"Contact: <sip:+15550001@10.0.0.2:5060>\r\n" +
+	"c: application/sdp\r\n" +
+	"l: %d\r\n" +
+	"\r\n%s"
+
+const testOffer = "v=0\r\n" +
+	"o=- 1 1 IN IP4 10.0.0.2\r\n" +
+	"s=-\r\n" +
+	"c=IN IP4 10.0.0.2\r\n" +
+	"t=0 0\r\n" +
+	"m=audio 40000 RTP/AVP 9 8 0 101\r\n" +
+	"a=rtpmap:9 G722/8000\r\n" +
+	"a=rtpmap:8 PCMA/8000\r\n" +
+	"a=rtpmap:0 PCMU/8000\r\n" +
+	"a=rtpmap:101 telephone-event/8000\r\n" +
+	"a=fmtp:101 0-16\r\n"
+
+func testInviteMessage() []byte {
+	return []byte(fmt.Sprintf(testInvite, len(testOffer), testOffer))
+}
+
+func TestSIPMessage(t *testing.T) {
+	req, err := parseSIPMessage(testInviteMessage())
+	require.NoError(t, err)
+	require.True(t, req.isRequest())
+	require.Equal(t, "INVITE", req.method)
+	require.Equal(t, "sip:1001@10.0.0.1:5060", req.uri)
+	require.Len(t, req.getAll("Via"), 2)
+	require.Equal(t, "a84b4c76e66710@10.0.0.2", req.get("call-id"))
+	require.Equal(t, testOffer, string(req.body))
+
+	cseq, method := req.cseq()
+	require.Equal(t, uint32(314159), cseq)
+	require.Equal(t, "INVITE", method)
+
+	from := req.get("From")
+	require.Equal(t, "+15550001", sipUser(from))
+	require.Equal(t, "Alice Smith", sipDisplayName(from))
+	require.Equal(t, "1928301774", sipHeaderParam(from, "tag"))
+	require.Equal(t, "1001", sipUser(req.uri))
+	require.Equal(t, "sip:+15550001@10.0.0.2:5060", sipAddress(req.get("Contact")))
+
+	t.Run("responses keep vias and tag the callee", func(t *testing.T) {
+		res := newSIPResponse(req, 200, "OK", "abc")
+		parsed, err := parseSIPMessage(res.marshal())
+		require.NoError(t, err)
+		require.False(t, parsed.isRequest())
+		require.Equal(t, 200, parsed.status)
+		require.Equal(t, req.getAll("Via"), parsed.getAll("Via"))
+		require.Equal(t, "abc", sipHeaderParam(parsed.get("To"), "tag"))
+		require.Equal(t, "0", parsed.get("Content-Length"))
+	})
+
+	t.Run("invalid messages", func(t *testing.T) {
+		_, err := parseSIPMessage([]byte("INVITE sip:1001@10.0.0.1 SIP/2.0\r\nTo: <sip:1001@10.0.0.1>\r\n"))
+		require.ErrorIs(t, err, errInvalidSIPMessage)
+		_, err = parseSIPMessage([]byte("hello\r\n\r\n"))
+		require.ErrorIs(t, err, errInvalidSIPMessage)
+	})
+}
+
+func TestSDPOffer(t *testing.T) {
+	offer, err := parseSDPOffer([]byte(testOffer))
+	require.NoError(t, err)
+	// G.722 is skipped, the first G.711 variant offered is used
+	require.Equal(t, codecPCMA.name, offer.codec.name)
+	require.Equal(t, uint8(101), offer.dtmfPT)
+	require.Equal(t, "10.0.0.2:40000", offer.addr.String())
+
+	answer, err := parseSDPOffer(sdpAnswer(1, "10.0.0.1", 20000, offer))
+	require.NoError(t, err)
+	require.Equal(t, codecPCMA.name, answer.codec.name)
+	require.Equal(t, uint8(101), answer.dtmfPT)
+	require.Equal(t, "10.0.0.1:20000", answer.addr.String())
+
+	_, err = parseSDPOffer([]byte(strings.Replace(testOffer, "RTP/AVP 9 8 0 101", "RTP/AVP 9 101", 1)))
+	require.ErrorIs(t, err, errNoCommonCodec)
+}
+
+func TestDTMF(t *testing.T) {
+	digit, end, ok := dtmfEvent([]byte{11, 0x80, 0x03, 0x20})
+	require.True(t, ok)
+	require.True(t, end)
+	require.Equal(t, byte('#'), digit)
+
+	digit, end, ok = dtmfEvent([]byte{5, 0x0a, 0x00, 0xa0})
+	require.True(t, ok)
+	require.False(t, end)
+	require.Equal(t, byte('5'), digit)
+
+	_, _, ok = dtmfEvent([]byte{16, 0x80, 0, 0})
+	require.False(t, ok)
+
+	digit, ok = dtmfRelayDigit([]byte("Signal=*\r\nDuration=160\r\n"))
+	require.True(t, ok)
+	require.Equal(t, byte('*'), digit)
+}
+
+func TestG711(t *testing.T) {
+	for _, codec := range []g711Codec{codecPCMU, codecPCMA} {
+		for _, sample := range []int16{0, 1, -1, 100, -100, 1000, -1000, 12345, -12345, 32767, -32768} {
+			decoded := codec.decode(codec.encode(sample))
+			// companding keeps a relative precision of a few percent
+			tolerance := int(sample)/16 + 16
+			if tolerance < 0 {
+				tolerance = -tolerance
+			}
+			require.InDelta(t, int(sample), int(decoded), float64(tolerance)+32, "%s %d", codec.name, sample)
+		}
+	}
+	require.Equal(t, byte(0xff), linearToULaw(0))
+	require.Equal(t, byte(0xd5), linearToALaw(0))
+}
+
+func TestAudioMixer(t *testing.T) {
+	m := newAudioMixer()
+	out := make([]int16, 4)
+	require.False(t, m.mix(out))
+
+	m.push("a", []int16{1, 2, 3, 4, 5})
+	m.push("b", []int16{10, 20})
+	m.push("c", []int16{32000, 32000, -32000, -32000})
+	require.True(t, m.mix(out))
+	require.Equal(t, []int16{32011, 32022, -31997, -31996}, out)
+
+	m.push("c", []int16{32767})
+	require.True(t, m.mix(out))
+	require.Equal(t, []int16{32767, 0, 0, 0}, out)
+
+	m.remove("a")
+	require.False(t, m.mix(out))
+}
@@ -0,0 +1,725 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bridge
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"math/rand"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v3"
+	"github.com/pion/webrtc/v3/pkg/media"
+	"go.uber.org/atomic"
+
+	"github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/logger"
+	"github.com/livekit/protocol/utils"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/opus"
+)
+
+const (
+	sipMaxDigits     = 32
+	sipInputAttempts = 3
+	// callers without media for this long are hung up on
+	sipMediaTimeout = 30 * time.Second
+
+	// largest opus frame, 120ms
+	opusMaxFrameSamples = sipSampleRate * 120 / 1000
+	opusMaxPacketSize   = 1500
+)
+
+var (
+	errNoACK           = errors.New("call was not acknowledged")
+	errInputTimeout    = errors.New("caller did not enter digits in time")
+	errTooManyAttempts = errors.New("too many invalid entries")
+	errCallerHungUp    = errors.New("caller hung up")
+	errMediaTimeout    = errors.New("no media received from caller")
+	errParticipantLeft = errors.New("participant left the room")
+)
+
+type SIPCallState string
+
+const (
+	SIPCallAnswering    SIPCallState = "answering"
+	SIPCallEnteringRoom SIPCallState = "entering_room"
+	SIPCallEnteringPIN  SIPCallState = "entering_pin"
+	SIPCallJoining      SIPCallState = "joining"
+	SIPCallActive       SIPCallState = "active"
+	SIPCallEnded        SIPCallState = "ended"
+)
+
+// SIPCallInfo describes a call in progress
+type SIPCallInfo struct {
+	ID       string           `json:"id"`
+	From     string           `json:"from"`
+	To       string           `json:"to"`
+	Room     livekit.RoomName `json:"room,omitempty"`
+	Identity string           `json:"identity,omitempty"`
+	Codec    string           `json:"codec"`
+	State    SIPCallState     `json:"state"`
+	// unix milliseconds
+	StartedAt int64 `json:"started_at"`
+}
+
+// sipCall answers a caller, lets them enter a room code and PIN when needed and then joins the room as
+// a participant publishing the caller's audio and playing the mix of the other participants to them
+type sipCall struct {
+	id        string
+	callID    string
+	server    *sipServer
+	invite    *sipMessage
+	addr      *net.UDPAddr
+	from      string
+	to        string
+	localTag  string
+	sessionID uint64
+	rtpConn   *net.UDPConn
+	mixer     *audioMixer
+	startedAt time.Time
+	logger    logger.Logger
+
+	lock         sync.Mutex
+	offer        *sdpOffer
+	mediaAddr    *net.UDPAddr
+	answer       *sipMessage
+	state        SIPCallState
+	room         livekit.RoomName
+	identity     string
+	prompt       []int16
+	client       *client
+	participants []*livekit.ParticipantInfo
+	subscribed   map[livekit.TrackID]bool
+
+	// the caller's audio is published through uplink while in the room
+	uplinkLock sync.Mutex
+	uplink     func(pcm []int16)
+
+	acked        chan struct{}
+	ackOnce      sync.Once
+	byeAnswered  chan struct{}
+	byeOnce      sync.Once
+	remoteHungUp atomic.Bool
+	digits       chan byte
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func newSIPCall(s *sipServer, invite *sipMessage, addr *net.UDPAddr, offer *sdpOffer, rtpConn *net.UDPConn) *sipCall {
+	id := utils.NewGuid(SIPCallPrefix)
+	from := sipUser(invite.get("From"))
+	to := sipUser(invite.uri)
+	ctx, cancel := context.WithCancel(context.Background())
+	return &sipCall{
+		id:          id,
+		callID:      invite.get("Call-ID"),
+		server:      s,
+		invite:      invite,
+		addr:        addr,
+		from:        from,
+		to:          to,
+		localTag:    utils.NewGuid(""),
+		sessionID:   uint64(time.Now().UnixNano()),
+		rtpConn:     rtpConn,
+		mixer:       newAudioMixer(),
+		startedAt:   time.Now(),
+		logger:      s.logger.WithValues("sipCallID", id, "from", from, "to", to),
+		offer:       offer,
+		mediaAddr:   offer.addr,
+		state:       SIPCallAnswering,
+		subscribed:  make(map[livekit.TrackID]bool),
+		acked:       make(chan struct{}),
+		byeAnswered: make(chan struct{}),
+		digits:      make(chan byte, sipMaxDigits),
+		ctx:         ctx,
+		cancel:      cancel,
+		done:        make(chan struct{}),
+	}
+}
+
+func (c *sipCall) Info() *SIPCallInfo {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return &SIPCallInfo{
+		ID:        c.id,
+		From:      c.from,
+		To:        c.to,
+		Room:      c.room,
+		Identity:  c.identity,
+		Codec:     c.offer.codec.name,
+		State:     c.state,
+		StartedAt: c.startedAt.UnixMilli(),
+	}
+}
+
+func (c *sipCall) setState(state SIPCallState) {
+	c.lock.Lock()
+	c.state = state
+	c.lock.Unlock()
+}
+
+func (c *sipCall) run() {
+	defer c.close()
+
+	if err := c.answerCall(); err != nil {
+		c.logger.Infow("sip call not established", "error", err)
+		return
+	}
+	c.logger.Infow("sip call answered", "codec", c.offer.codec.name, "dtmf", c.offer.dtmfPT != 0)
+	go c.rtpReadWorker()
+	go c.rtpWriteWorker()
+
+	err := c.runCall()
+	switch {
+	case c.remoteHungUp.Load():
+		c.logger.Infow("sip call ended by caller")
+	case err != nil && !errors.Is(err, context.Canceled):
+		c.logger.Infow("ending sip call", "reason", err)
+		c.waitPrompt()
+	default:
+		c.logger.Infow("ending sip call")
+	}
+}
+
+func (c *sipCall) runCall() error {
+	route, err := c.enterRoom()
+	if err != nil {
+		return err
+	}
+	if err = c.enterPIN(route); err != nil {
+		return err
+	}
+	return c.joinRoom(route)
+}
+
+// answerCall accepts the call and retransmits the answer until it is acknowledged
+func (c *sipCall) answerCall() error {
+	c.lock.Lock()
+	answer := newSIPResponse(c.invite, 200, "OK", c.localTag)
+	answer.add("Contact", c.server.contact())
+	answer.add("Allow", sipAllow)
+	answer.add("Content-Type", "application/sdp")
+	answer.body = sdpAnswer(c.sessionID, c.server.host, c.rtpConn.LocalAddr().(*net.UDPAddr).Port, c.offer)
+	c.answer = answer
+	c.lock.Unlock()
+
+	c.server.send(answer, c.addr)
+	interval := sipT1
+	timeout := time.NewTimer(sipTimeout)
+	defer timeout.Stop()
+	for {
+		select {
+		case <-c.acked:
+			return nil
+		case <-c.ctx.Done():
+			return c.ctx.Err()
+		case <-timeout.C:
+			return errNoACK
+		case <-time.After(interval):
+			c.server.send(answer, c.addr)
+			if interval *= 2; interval > sipT2 {
+				interval = sipT2
+			}
+		}
+	}
+}
+
+// onInvite answers retransmitted INVITEs with the answer sent before and re-INVITEs with the same session
+func (c *sipCall) onInvite(req *sipMessage, addr *net.UDPAddr) {
+	cseq, _ := req.cseq()
+	inviteCSeq, _ := c.invite.cseq()
+
+	c.lock.Lock()
+	answer := c.answer
+	c.lock.Unlock()
+	if cseq == inviteCSeq {
+		if answer != nil {
+			c.server.send(answer, addr)
+		}
+		return
+	}
+
+	offer, err := parseSDPOffer(req.body)
+	if err != nil {
+		c.server.respond(req, addr, 488, "Not Acceptable Here")
+		return
+	}
+	c.lock.Lock()
+	if offer.codec.payloadType == c.offer.codec.payloadType {
+		c.mediaAddr = offer.addr
+	}
+	res := newSIPResponse(req, 200, "OK", c.localTag)
+	res.add("Contact", c.server.contact())
+	res.add("Content-Type", "application/sdp")
+	res.body = sdpAnswer(c.sessionID, c.server.host, c.rtpConn.LocalAddr().(*net.UDPAddr).Port, c.offer)
+	c.lock.Unlock()
+	c.server.send(res, addr)
+}
+
+func (c *sipCall) onAck() {
+	c.ackOnce.Do(func() { close(c.acked) })
+}
+
+func (c *sipCall) onBye() {
+	c.remoteHungUp.Store(true)
+	c.cancel()
+}
+
+func (c *sipCall) onByeAnswered() {
+	c.byeOnce.Do(func() { close(c.byeAnswered) })
+}
+
+func (c *sipCall) onDigit(digit byte) {
+	select {
+	case c.digits <- digit:
+	default:
+	}
+}
+
+// hangup ends the call, the caller is sent a BYE
+func (c *sipCall) hangup() {
+	c.cancel()
+}
+
+func (c *sipCall) close() {
+	c.cancel()
+	c.setState(SIPCallEnded)
+
+	select {
+	case <-c.acked:
+		if !c.remoteHungUp.Load() {
+			c.sendBye()
+		}
+	default:
+	}
+
+	_ = c.rtpConn.Close()
+	c.server.removeCall(c)
+	close(c.done)
+}
+
+// sendBye ends an established call, it is retransmitted until answered for a few seconds
+func (c *sipCall) sendBye() {
+	from := c.invite.get("To")
+	if sipHeaderParam(from, "tag") == "" {
+		from += ";tag=" + c.localTag
+	}
+	target := sipAddress(c.invite.get("Contact"))
+	if target == "" {
+		target = sipAddress(c.invite.get("From"))
+	}
+
+	bye := &sipMessage{method: "BYE", uri: target}
+	bye.add("Via", c.server.via(utils.NewGuid("z9hG4bK")))
+	for _, route := range c.invite.getAll("Record-Route") {
+		bye.add("Route", route)
+	}
+	bye.add("Max-Forwards", "70")
+	bye.add("From", from)
+	bye.add("To", c.invite.get("From"))
+	bye.add("Call-ID", c.callID)
+	bye.add("CSeq", "1 BYE")
+
+	interval := sipT1
+	for elapsed := time.Duration(0); elapsed < sipT2; elapsed += interval {
+		c.server.send(bye, c.addr)
+		select {
+		case <-c.byeAnswered:
+			return
+		case <-time.After(interval):
+		}
+		interval *= 2
+	}
+}
+
+// enterRoom resolves the room from the dialed number, otherwise the caller enters a room code
+func (c *sipCall) enterRoom() (*config.SIPRoute, error) {
+	if route := c.server.routeForNumber(c.to); route != nil {
+		return route, nil
+	}
+
+	c.setState(SIPCallEnteringRoom)
+	for attempt := 0; attempt < sipInputAttempts; attempt++ {
+		code, err := c.collectDigits(promptEnterRoom)
+		if err != nil {
+			return nil, err
+		}
+		if route := c.server.routeForCode(code); route != nil {
+			return route, nil
+		}
+		c.logger.Infow("caller entered an unknown room code")
+		c.play(promptInvalid)
+	}
+	return nil, errTooManyAttempts
+}
+
+func (c *sipCall) enterPIN(route *config.SIPRoute) error {
+	if route.PIN == "" {
+		return nil
+	}
+
+	c.setState(SIPCallEnteringPIN)
+	for attempt := 0; attempt < sipInputAttempts; attempt++ {
+		pin, err := c.collectDigits(promptEnterPIN)
+		if err != nil {
+			return err
+		}
+		if subtle.ConstantTimeCompare([]byte(pin), []byte(route.PIN)) == 1 {
+			return nil
+		}
+		c.logger.Infow("caller entered an invalid pin", "room", route.Room)
+		c.play(promptInvalid)
+	}
+	return errTooManyAttempts
+}
+
+// collectDigits plays a prompt and returns the digits entered up to #, * starts over
+func (c *sipCall) collectDigits(prompt promptTone) (string, error) {
+	c.play(prompt)
+
+	timeout := time.NewTimer(c.server.conf.InputTimeout)
+	defer timeout.Stop()
+	var digits strings.Builder
+	for {
+		select {
+		case <-c.ctx.Done():
+			if c.remoteHungUp.Load() {
+				return "", errCallerHungUp
+			}
+			return "", c.ctx.Err()
+		case <-timeout.C:
+			return "", errInputTimeout
+		case digit := <-c.digits:
+			switch {
+			case digit == '#':
+				return digits.String(), nil
+			case digit == '*':
+				digits.Reset()
+			case digits.Len() < sipMaxDigits:
+				digits.WriteByte(digit)
+			}
+		}
+	}
+}
+
+func (c *sipCall) play(prompt promptTone) {
+	c.lock.Lock()
+	c.prompt = append(c.prompt, prompt.samples()...)
+	c.lock.Unlock()
+}
+
+// waitPrompt lets the caller hear the last prompt before hanging up
+func (c *sipCall) waitPrompt() {
+	for i := 0; i < 50; i++ {
+		c.lock.Lock()
+		remaining := len(c.prompt)
+		c.lock.Unlock()
+		if remaining == 0 {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// joinRoom publishes the caller's audio to the room and subscribes to the audio of all other participants
+func (c *sipCall) joinRoom(route *config.SIPRoute) error {
+	c.setState(SIPCallJoining)
+
+	identity := SIPIdentityPrefix + c.from
+	if c.from == "" {
+		identity = SIPIdentityPrefix + c.id
+	}
+	name := sipDisplayName(c.invite.get("From"))
+	if name == "" {
+		name = c.from
+	}
+	m := c.server.manager
+	token, err := m.localToken(route.Room, identity, name, false)
+	if err != nil {
+		return err
+	}
+
+	encoder, err := opus.NewEncoder(sipSampleRate)
+	if err != nil {
+		return err
+	}
+	defer encoder.Close()
+
+	cl, err := dialClient(c.ctx, clientParams{
+		URL:                   m.localURL,
+		Token:                 token,
+		InsecureSkipVerify:    m.localInsecure,
+		Logger:                c.logger,
+		OnParticipantsChanged: c.onParticipantsChanged,
+		OnTrack:               c.onTrack,
+	})
+	if err != nil {
+		return err
+	}
+	defer cl.Close()
+
+	track, err := webrtc.NewTrackLocalStaticSample(webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus, ClockRate: 48000, Channels: 2}, "audio", c.id)
+	if err != nil {
+		return err
+	}
+	sender, _, err := cl.PublishTrack(track, "phone", &livekit.TrackInfo{Type: livekit.TrackType_AUDIO, Source: livekit.TrackSource_MICROPHONE})
+	if err != nil {
+		return err
+	}
+	defer cl.UnpublishTrack(sender)
+	go func() {
+		for {
+			if _, _, err := sender.ReadRTCP(); err != nil {
+				return
+			}
+		}
+	}()
+
+	packet := make([]byte, opusMaxPacketSize)
+	c.setUplink(func(pcm []int16) {
+		n, err := encoder.Encode(pcm, packet)
+		if err != nil {
+			return
+		}
+		_ = track.WriteSample(media.Sample{Data: packet[:n], Duration: 20 * time.Millisecond})
+	})
+	// the encoder is closed once no more audio is sent through it
+	defer c.setUplink(nil)
+
+	c.lock.Lock()
+	c.client = cl
+	c.room = livekit.RoomName(route.Room)
+	c.identity = identity
+	c.lock.Unlock()
+	defer func() {
+		c.lock.Lock()
+		c.client = nil
+		c.lock.Unlock()
+	}()
+	c.updateSubscriptions()
+
+	c.setState(SIPCallActive)
+	c.play(promptJoined)
+	c.logger.Infow("sip caller joined room", "room", route.Room, "identity", identity)
+
+	select {
+	case <-c.ctx.Done():
+		if c.remoteHungUp.Load() {
+			return errCallerHungUp
+		}
+		return c.ctx.Err()
+	case <-cl.Done():
+		return errParticipantLeft
+	}
+}
+
+func (c *sipCall) setUplink(uplink func(pcm []int16)) {
+	c.uplinkLock.Lock()
+	c.uplink = uplink
+	c.uplinkLock.Unlock()
+}
+
+// sendUplink publishes a frame of the caller's audio, it returns false when not in a room
+func (c *sipCall) sendUplink(pcm []int16) bool {
+	c.uplinkLock.Lock()
+	defer c.uplinkLock.Unlock()
+	if c.uplink == nil {
+		return false
+	}
+	c.uplink(pcm)
+	return true
+}
+
+func (c *sipCall) onParticipantsChanged(participants []*livekit.ParticipantInfo) {
+	c.lock.Lock()
+	c.participants = participants
+	c.lock.Unlock()
+
+	c.updateSubscriptions()
+}
+
+// updateSubscriptions subscribes to audio tracks that are not yet part of the caller's mix
+func (c *sipCall) updateSubscriptions() {
+	c.lock.Lock()
+	cl := c.client
+	if cl == nil {
+		c.lock.Unlock()
+		return
+	}
+	var trackIDs []livekit.TrackID
+	for _, p := range c.participants {
+		for _, t := range p.Tracks {
+			trackID := livekit.TrackID(t.Sid)
+			if t.Type != livekit.TrackType_AUDIO || c.subscribed[trackID] {
+				continue
+			}
+			c.subscribed[trackID] = true
+			trackIDs = append(trackIDs, trackID)
+		}
+	}
+	c.lock.Unlock()
+
+	if len(trackIDs) == 0 {
+		return
+	}
+	if err := cl.UpdateSubscription(trackIDs, true); err != nil {
+		c.logger.Warnw("could not subscribe to room audio", err)
+	}
+}
+
+// onTrack decodes a subscribed audio track into the caller's mix until it ends
+func (c *sipCall) onTrack(_ *livekit.ParticipantInfo, info *livekit.TrackInfo, track *webrtc.TrackRemote) {
+	trackID := livekit.TrackID(info.Sid)
+	defer func() {
+		c.mixer.remove(info.Sid)
+		c.lock.Lock()
+		delete(c.subscribed, trackID)
+		c.lock.Unlock()
+	}()
+	if info.Type != livekit.TrackType_AUDIO {
+		return
+	}
+
+	decoder, err := opus.NewDecoder(sipSampleRate)
+	if err != nil {
+		c.logger.Warnw("could not decode room audio", err, "trackID", info.Sid)
+		return
+	}
+	defer decoder.Close()
+
+	pcm := make([]int16, opusMaxFrameSamples)
+	for {
+		pkt, _, err := track.ReadRTP()
+		if err != nil {
+			return
+		}
+		n, err := decoder.Decode(pkt.Payload, pcm)
+		if err != nil {
+			continue
+		}
+		c.mixer.push(info.Sid, pcm[:n])
+	}
+}
+
+// rtpReadWorker decodes the caller's audio and collects DTMF digits, the caller's media address is
+// latched from received packets to get through NATs
+func (c *sipCall) rtpReadWorker() {
+	buf := make([]byte, 1500)
+	var pcm []int16
+	var lastDTMF uint32
+	hasDTMF := false
+	for {
+		_ = c.rtpConn.SetReadDeadline(time.Now().Add(sipMediaTimeout))
+		n, addr, err := c.rtpConn.ReadFromUDP(buf)
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				c.logger.Infow("ending sip call", "reason", errMediaTimeout)
+				c.hangup()
+			}
+			return
+		}
+		if isRTCP(buf[:n]) {
+			continue
+		}
+		pkt := &rtp.Packet{}
+		if err = pkt.Unmarshal(buf[:n]); err != nil {
+			continue
+		}
+
+		c.lock.Lock()
+		c.mediaAddr = addr
+		codec := c.offer.codec
+		dtmfPT := c.offer.dtmfPT
+		c.lock.Unlock()
+
+		switch {
+		case dtmfPT != 0 && pkt.PayloadType == dtmfPT:
+			digit, end, ok := dtmfEvent(pkt.Payload)
+			if ok && end && (!hasDTMF || pkt.Timestamp != lastDTMF) {
+				lastDTMF, hasDTMF = pkt.Timestamp, true
+				c.onDigit(digit)
+			}
+
+		case pkt.PayloadType == codec.payloadType:
+			pcm = codec.decodeFrame(pkt.Payload, pcm)
+			for len(pcm) >= sipFrameSamples {
+				if !c.sendUplink(pcm[:sipFrameSamples]) {
+					// audio before joining is not published
+					pcm = pcm[:0]
+					break
+				}
+				pcm = append(pcm[:0], pcm[sipFrameSamples:]...)
+			}
+		}
+	}
+}
+
+// rtpWriteWorker sends a frame every 20ms, prompts take precedence over the room's audio
+func (c *sipCall) rtpWriteWorker() {
+	ticker := time.NewTicker(20 * time.Millisecond)
+	defer ticker.Stop()
+
+	frame := make([]int16, sipFrameSamples)
+	payload := make([]byte, 0, sipFrameSamples)
+	header := rtp.Header{
+		Version:        2,
+		Marker:         true,
+		SequenceNumber: uint16(rand.Uint32()),
+		Timestamp:      rand.Uint32(),
+		SSRC:           rand.Uint32(),
+	}
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		c.lock.Lock()
+		codec := c.offer.codec
+		addr := c.mediaAddr
+		n := copy(frame, c.prompt)
+		c.prompt = c.prompt[n:]
+		c.lock.Unlock()
+		if n > 0 {
+			for i := n; i < len(frame); i++ {
+				frame[i] = 0
+			}
+		} else if !c.mixer.mix(frame) {
+			for i := range frame {
+				frame[i] = 0
+			}
+		}
+
+		header.PayloadType = codec.payloadType
+		pkt := &rtp.Packet{Header: header, Payload: codec.encodeFrame(frame, payload[:0])}
+		if buf, err := pkt.Marshal(); err == nil {
+			_, _ = c.rtpConn.WriteToUDP(buf, addr)
+		}
+		header.Marker = false
+		header.SequenceNumber++
+		header.Timestamp += sipFrameSamples
+	}
+}
@@ -0,0 +1,264 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bridge
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pion/sdp/v3"
+)
+
+const (
+	// G.711 runs at 8kHz, media is exchanged in frames of 20ms
+	sipSampleRate   = 8000
+	sipFrameSamples = sipSampleRate / 50
+
+	// audio buffered for each speaker in the mix, older samples are dropped
+	mixerMaxBuffered = sipSampleRate / 5
+
+	promptAmplitude = 8000
+)
+
+var (
+	errNoAudioOffered = errors.New("offer has no audio media")
+	errNoCommonCodec  = errors.New("offer has no G.711 codec")
+)
+
+// sdpOffer is what is used of a SIP peer's session description
+type sdpOffer struct {
+	addr   *net.UDPAddr
+	codec  g711Codec
+	dtmfPT uint8
+}
+
+// parseSDPOffer picks the first G.711 codec offered for audio and, when offered, telephone events (RFC 4733)
+func parseSDPOffer(body []byte) (*sdpOffer, error) {
+	sd := &sdp.SessionDescription{}
+	if err := sd.Unmarshal(body); err != nil {
+		return nil, err
+	}
+
+	for _, md := range sd.MediaDescriptions {
+		if md.MediaName.Media != "audio" || md.MediaName.Port.Value == 0 {
+			continue
+		}
+		conn := md.ConnectionInformation
+		if conn == nil {
+			conn = sd.ConnectionInformation
+		}
+		if conn == nil || conn.Address == nil {
+			return nil, errNoAudioOffered
+		}
+		ip := net.ParseIP(conn.Address.Address)
+		if ip == nil {
+			addr, err := net.ResolveIPAddr("ip", conn.Address.Address)
+			if err != nil {
+				return nil, err
+			}
+			ip = addr.IP
+		}
+
+		offer := &sdpOffer{addr: &net.UDPAddr{IP: ip, Port: md.MediaName.Port.Value}}
+		found := false
+		for _, format := range md.MediaName.Formats {
+			switch format {
+			case strconv.Itoa(int(codecPCMU.payloadType)):
+				offer.codec, found = codecPCMU, true
+			case strconv.Itoa(int(codecPCMA.payloadType)):
+				offer.codec, found = codecPCMA, true
+			}
+			if found {
+				break
+			}
+		}
+		if !found {
+			return nil, errNoCommonCodec
+		}
+		for _, attr := range md.Attributes {
+			if attr.Key != "rtpmap" {
+				continue
+			}
+			pt, encoding, _ := strings.Cut(attr.Value, " ")
+			if strings.HasPrefix(strings.ToLower(encoding), "telephone-event/8000") {
+				if v, err := strconv.ParseUint(pt, 10, 7); err == nil {
+					offer.dtmfPT = uint8(v)
+				}
+			}
+		}
+		return offer, nil
+	}
+	return nil, errNoAudioOffered
+}
+
+// sdpAnswer accepts an offer with the selected codec, receiving media on port
+func sdpAnswer(sessionID uint64, host string, port int, offer *sdpOffer) []byte {
+	formats := strconv.Itoa(int(offer.codec.payloadType))
+	if offer.dtmfPT != 0 {
+		formats += " " + strconv.Itoa(int(offer.dtmfPT))
+	}
+	lines := []string{
+		"v=0",
+		fmt.Sprintf("o=livekit %d %d IN IP4 %s", sessionID, sessionID, host),
+		"s=LiveKit",
+		"c=IN IP4 " + host,
+		"t=0 0",
+		fmt.Sprintf("m=audio %d RTP/AVP %s", port, formats),
+		fmt.Sprintf("a=rtpmap:%d %s/%d", offer.codec.payloadType, offer.codec.name, sipSampleRate),
+	}
+	if offer.dtmfPT != 0 {
+		lines = append(lines,
+			fmt.Sprintf("a=rtpmap:%d telephone-event/%d", offer.dtmfPT, sipSampleRate),
+			fmt.Sprintf("a=fmtp:%d 0-16", offer.dtmfPT),
+		)
+	}
+	lines = append(lines, "a=ptime:20", "a=sendrecv")
+	return []byte(strings.Join(lines, "\r\n") + "\r\n")
+}
+
+// ----------------------------------------------------
+
+const dtmfDigits = "0123456789*#ABCD"
+
+// dtmfEvent decodes a telephone event payload (RFC 4733). A digit is complete once the end bit is set,
+// end packets are repeated and need to be deduplicated by their RTP timestamp.
+func dtmfEvent(payload []byte) (digit byte, end bool, ok bool) {
+	if len(payload) < 4 || int(payload[0]) >= len(dtmfDigits) {
+		return 0, false, false
+	}
+	return dtmfDigits[payload[0]], payload[1]&0x80 != 0, true
+}
+
+// dtmfRelayDigit decodes the body of a SIP INFO request carrying application/dtmf-relay
+func dtmfRelayDigit(body []byte) (byte, bool) {
+	for _, line := range strings.Split(string(body), "\n") {
+		k, v, ok := strings.Cut(line, "=")
+		if !ok || !strings.EqualFold(strings.TrimSpace(k), "signal") {
+			continue
+		}
+		v = strings.ToUpper(strings.TrimSpace(v))
+		if len(v) == 1 && strings.Contains(dtmfDigits, v) {
+			return v[0], true
+		}
+	}
+	return 0, false
+}
+
+// ----------------------------------------------------
+
+// audioMixer sums the audio of the participants a caller hears. Sources push decoded samples as they
+// arrive, a frame is taken from each of them at the pace media is sent to the caller.
+type audioMixer struct {
+	lock    sync.Mutex
+	sources map[string][]int16
+}
+
+func newAudioMixer() *audioMixer {
+	return &audioMixer{sources: make(map[string][]int16)}
+}
+
+func (m *audioMixer) push(source string, samples []int16) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	buf := append(m.sources[source], samples...)
+	if len(buf) > mixerMaxBuffered {
+		buf = buf[len(buf)-mixerMaxBuffered:]
+	}
+	m.sources[source] = buf
+}
+
+func (m *audioMixer) remove(source string) {
+	m.lock.Lock()
+	delete(m.sources, source)
+	m.lock.Unlock()
+}
+
+// mix fills out with the next frame, it returns false when no source has audio
+func (m *audioMixer) mix(out []int16) bool {
+	sum := make([]int32, len(out))
+	mixed := false
+
+	m.lock.Lock()
+	for source, buf := range m.sources {
+		if len(buf) == 0 {
+			continue
+		}
+		n := len(buf)
+		if n > len(out) {
+			n = len(out)
+		}
+		for i := 0; i < n; i++ {
+			sum[i] += int32(buf[i])
+		}
+		m.sources[source] = buf[n:]
+		mixed = true
+	}
+	m.lock.Unlock()
+
+	for i, s := range sum {
+		switch {
+		case s > math.MaxInt16:
+			out[i] = math.MaxInt16
+		case s < math.MinInt16:
+			out[i] = math.MinInt16
+		default:
+			out[i] = int16(s)
+		}
+	}
+	return mixed
+}
+
+// ----------------------------------------------------
+
+// prompt tones guide callers through entering a room code and PIN
+type promptTone int
+
+const (
+	promptEnterRoom promptTone = iota
+	promptEnterPIN
+	promptInvalid
+	promptJoined
+)
+
+func (p promptTone) samples() []int16 {
+	switch p {
+	case promptEnterRoom:
+		return tone(1000, 200)
+	case promptEnterPIN:
+		return append(append(tone(1000, 150), tone(0, 100)...), tone(1000, 150)...)
+	case promptInvalid:
+		return tone(400, 600)
+	default:
+		return append(tone(800, 100), tone(1200, 150)...)
+	}
+}
+
+// tone generates a sine tone, or silence for a frequency of 0
+func tone(frequency float64, durationMs int) []int16 {
+	samples := make([]int16, sipSampleRate*durationMs/1000)
+	if frequency == 0 {
+		return samples
+	}
+	for i := range samples {
+		samples[i] = int16(promptAmplitude * math.Sin(2*math.Pi*frequency*float64(i)/sipSampleRate))
+	}
+	return samples
+}
@@ -0,0 +1,241 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bridge
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const sipVersion = "SIP/2.0"
+
+var errInvalidSIPMessage = errors.New("invalid sip message")
+
+// compact header forms (RFC 3261 section 7.3.3) and the canonical names they expand to
+var sipHeaderNames = map[string]string{
+	"v":              "Via",
+	"via":            "Via",
+	"f":              "From",
+	"from":           "From",
+	"t":              "To",
+	"to":             "To",
+	"i":              "Call-ID",
+	"call-id":        "Call-ID",
+	"m":              "Contact",
+	"contact":        "Contact",
+	"l":              "Content-Length",
+	"content-length": "Content-Length",
+	"c":              "Content-Type",
+	"content-type":   "Content-Type",
+	"cseq":           "CSeq",
+	"max-forwards":   "Max-Forwards",
+}
+
+type sipHeader struct {
+	name  string
+	value string
+}
+
+// sipMessage is a SIP request or response. Headers keep their order so that Via headers are
+// returned as received.
+type sipMessage struct {
+	method string
+	uri    string
+
+	status int
+	reason string
+
+	headers []sipHeader
+	body    []byte
+}
+
+func (m *sipMessage) isRequest() bool {
+	return m.method != ""
+}
+
+func (m *sipMessage) get(name string) string {
+	for _, h := range m.headers {
+		if strings.EqualFold(h.name, name) {
+			return h.value
+		}
+	}
+	return ""
+}
+
+func (m *sipMessage) getAll(name string) []string {
+	var values []string
+	for _, h := range m.headers {
+		if strings.EqualFold(h.name, name) {
+			values = append(values, h.value)
+		}
+	}
+	return values
+}
+
+func (m *sipMessage) add(name, value string) {
+	m.headers = append(m.headers, sipHeader{name: name, value: value})
+}
+
+// cseq returns the sequence number and method of the CSeq header
+func (m *sipMessage) cseq() (uint32, string) {
+	fields := strings.Fields(m.get("CSeq"))
+	if len(fields) != 2 {
+		return 0, ""
+	}
+	n, _ := strconv.ParseUint(fields[0], 10, 32)
+	return uint32(n), strings.ToUpper(fields[1])
+}
+
+func parseSIPMessage(buf []byte) (*sipMessage, error) {
+	head, body, found := bytes.Cut(buf, []byte("\r\n\r\n"))
+	if !found {
+		return nil, errInvalidSIPMessage
+	}
+	lines := strings.Split(string(head), "\r\n")
+
+	m := &sipMessage{}
+	start := strings.SplitN(lines[0], " ", 3)
+	if len(start) != 3 {
+		return nil, errInvalidSIPMessage
+	}
+	if start[0] == sipVersion {
+		status, err := strconv.Atoi(start[1])
+		if err != nil {
+			return nil, errInvalidSIPMessage
+		}
+		m.status = status
+		m.reason = start[2]
+	} else {
+		if start[2] != sipVersion {
+			return nil, errInvalidSIPMessage
+		}
+		m.method = strings.ToUpper(start[0])
+		m.uri = start[1]
+	}
+
+	for _, line := range lines[1:] {
+		// folded header lines continue the previous header
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(m.headers) > 0 {
+			m.headers[len(m.headers)-1].value += " " + strings.TrimSpace(line)
+			continue
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, errInvalidSIPMessage
+		}
+		name = strings.TrimSpace(name)
+		if canonical, ok := sipHeaderNames[strings.ToLower(name)]; ok {
+			name = canonical
+		}
+		m.add(name, strings.TrimSpace(value))
+	}
+
+	if cl := m.get("Content-Length"); cl != "" {
+		length, err := strconv.Atoi(cl)
+		if err != nil || length < 0 || length > len(body) {
+			return nil, errInvalidSIPMessage
+		}
+		body = body[:length]
+	}
+	m.body = body
+	return m, nil
+}
+
+// marshal serializes the message, Content-Length is set from the body
+func (m *sipMessage) marshal() []byte {
+	var b bytes.Buffer
+	if m.isRequest() {
+		fmt.Fprintf(&b, "%s %s %s\r\n", m.method, m.uri, sipVersion)
+	} else {
+		fmt.Fprintf(&b, "%s %d %s\r\n", sipVersion, m.status, m.reason)
+	}
+	for _, h := range m.headers {
+		if h.name == "Content-Length" {
+			continue
+		}
+		fmt.Fprintf(&b, "%s: %s\r\n", h.name, h.value)
+	}
+	fmt.Fprintf(&b, "Content-Length: %d\r\n\r\n", len(m.body))
+	b.Write(m.body)
+	return b.Bytes()
+}
+
+// newSIPResponse answers a request, toTag is added to the To header when it has none
+func newSIPResponse(req *sipMessage, status int, reason string, toTag string) *sipMessage {
+	res := &sipMessage{status: status, reason: reason}
+	for _, via := range req.getAll("Via") {
+		res.add("Via", via)
+	}
+	res.add("From", req.get("From"))
+	to := req.get("To")
+	if toTag != "" && sipHeaderParam(to, "tag") == "" {
+		to += ";tag=" + toTag
+	}
+	res.add("To", to)
+	res.add("Call-ID", req.get("Call-ID"))
+	res.add("CSeq", req.get("CSeq"))
+	return res
+}
+
+// sipHeaderParam returns a parameter of a name-addr header value such as From or To, e.g. tag
+func sipHeaderParam(value, name string) string {
+	if i := strings.LastIndex(value, ">"); i >= 0 {
+		value = value[i+1:]
+	}
+	for _, param := range strings.Split(value, ";") {
+		k, v, _ := strings.Cut(strings.TrimSpace(param), "=")
+		if strings.EqualFold(k, name) {
+			return v
+		}
+	}
+	return ""
+}
+
+// sipAddress returns the URI of a name-addr or addr-spec header value
+func sipAddress(value string) string {
+	if start := strings.Index(value, "<"); start >= 0 {
+		if end := strings.Index(value[start:], ">"); end >= 0 {
+			return value[start+1 : start+end]
+		}
+	}
+	uri, _, _ := strings.Cut(strings.TrimSpace(value), ";")
+	return uri
+}
+
+// sipUser returns the user part of a SIP URI or header value, e.g. the dialed or calling number
+func sipUser(value string) string {
+	uri := sipAddress(value)
+	if i := strings.Index(uri, ":"); i >= 0 {
+		uri = uri[i+1:]
+	}
+	user, _, found := strings.Cut(uri, "@")
+	if !found {
+		return ""
+	}
+	user, _, _ = strings.Cut(user, ";")
+	return user
+}
+
+// sipDisplayName returns the display name of a name-addr header value
+func sipDisplayName(value string) string {
+	start := strings.Index(value, "<")
+	if start <= 0 {
+		return ""
+	}
+	return strings.Trim(strings.TrimSpace(value[:start]), `"`)
+}
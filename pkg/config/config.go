@@ -15,9 +15,13 @@
 package config
 
 import (
+	"encoding/base64"
 	"fmt"
+	"net"
+	"net/url"
 	"os"
 	"reflect"
+	"strconv"
 	"strings"
 	"time"
 
@@ -34,6 +38,9 @@ import (
 
 type CongestionControlProbeMode string
 type StreamTrackerType string
+type VideoAllocationPreset string
+type PausedVideoPlaceholder string
+type E2EEKeyMode string
 
 const (
 	generatedCLIFlagUsage = "generated"
@@ -44,6 +51,20 @@ const (
 	StreamTrackerTypePacket StreamTrackerType = "packet"
 	StreamTrackerTypeFrame  StreamTrackerType = "frame"
 
+	VideoAllocationPresetDefault VideoAllocationPreset = "default"
+	VideoAllocationPresetSpeaker VideoAllocationPreset = "speaker"
+
+	PausedVideoPlaceholderOff      PausedVideoPlaceholder = "off"
+	PausedVideoPlaceholderKeyFrame PausedVideoPlaceholder = "keyframe"
+	PausedVideoPlaceholderPattern  PausedVideoPlaceholder = "pattern"
+
+	E2EEKeyModeShared         E2EEKeyMode = "shared"
+	E2EEKeyModePerParticipant E2EEKeyMode = "per_participant"
+
+	RedactFieldIdentity = "identity"
+	RedactFieldName     = "name"
+	RedactFieldMetadata = "metadata"
+
 	StatsUpdateInterval          = time.Second * 10
 	TelemetryStatsUpdateInterval = time.Second * 30
 )
@@ -51,26 +72,62 @@ const (
 var (
 	ErrKeyFileIncorrectPermission = errors.New("key file others permissions must be set to 0")
 	ErrKeysNotSet                 = errors.New("one of key-file or keys must be provided")
+	ErrICELiteNotRoutable         = errors.New("ICE-lite requires a publicly routable node IP, set use_external_ip or node_ip")
 )
 
 type Config struct {
-	Port           uint32                   `yaml:"port"`
-	BindAddresses  []string                 `yaml:"bind_addresses,omitempty"`
-	PrometheusPort uint32                   `yaml:"prometheus_port,omitempty"`
-	Environment    string                   `yaml:"environment,omitempty"`
-	RTC            RTCConfig                `yaml:"rtc,omitempty"`
-	Redis          redisLiveKit.RedisConfig `yaml:"redis,omitempty"`
-	Audio          AudioConfig              `yaml:"audio,omitempty"`
-	Video          VideoConfig              `yaml:"video,omitempty"`
-	Room           RoomConfig               `yaml:"room,omitempty"`
-	TURN           TURNConfig               `yaml:"turn,omitempty"`
-	Ingress        IngressConfig            `yaml:"ingress,omitempty"`
-	WebHook        WebHookConfig            `yaml:"webhook,omitempty"`
-	NodeSelector   NodeSelectorConfig       `yaml:"node_selector,omitempty"`
-	KeyFile        string                   `yaml:"key_file,omitempty"`
-	Keys           map[string]string        `yaml:"keys,omitempty"`
-	Region         string                   `yaml:"region,omitempty"`
-	SignalRelay    SignalRelayConfig        `yaml:"signal_relay,omitempty"`
+	Port           uint32   `yaml:"port"`
+	BindAddresses  []string `yaml:"bind_addresses,omitempty"`
+	PrometheusPort uint32   `yaml:"prometheus_port,omitempty"`
+	// serve metrics in the OpenMetrics format when scrapers ask for it, which carries trace exemplars
+	PrometheusExemplars bool                     `yaml:"prometheus_exemplars,omitempty"`
+	Tracing             TracingConfig            `yaml:"tracing,omitempty"`
+	TLS                 TLSConfig                `yaml:"tls,omitempty"`
+	Listeners           []ListenerConfig         `yaml:"listeners,omitempty"`
+	ControlSocket       ControlSocketConfig      `yaml:"control_socket,omitempty"`
+	Environment         string                   `yaml:"environment,omitempty"`
+	RTC                 RTCConfig                `yaml:"rtc,omitempty"`
+	Redis               redisLiveKit.RedisConfig `yaml:"redis,omitempty"`
+	NATS                NATSConfig               `yaml:"nats,omitempty"`
+	Persistence         PersistenceConfig        `yaml:"persistence,omitempty"`
+	Audio               AudioConfig              `yaml:"audio,omitempty"`
+	Video               VideoConfig              `yaml:"video,omitempty"`
+	Room                RoomConfig               `yaml:"room,omitempty"`
+	TURN                TURNConfig               `yaml:"turn,omitempty"`
+	Ingress             IngressConfig            `yaml:"ingress,omitempty"`
+	WebHook             WebHookConfig            `yaml:"webhook,omitempty"`
+	Redaction           RedactionConfig          `yaml:"redaction,omitempty"`
+	NodeSelector        NodeSelectorConfig       `yaml:"node_selector,omitempty"`
+	KeyFile             string                   `yaml:"key_file,omitempty"`
+	Keys                map[string]string        `yaml:"keys,omitempty"`
+	TokenKeys           TokenKeysConfig          `yaml:"token_keys,omitempty"`
+	AdminOIDC           AdminOIDCConfig          `yaml:"admin_oidc,omitempty"`
+	AuthLockout         AuthLockoutConfig        `yaml:"auth_lockout,omitempty"`
+	TrustedProxies      []string                 `yaml:"trusted_proxies,omitempty"`
+	ResumeToken         ResumeTokenConfig        `yaml:"resume_token,omitempty"`
+	Reconnect           ReconnectPolicyConfig    `yaml:"reconnect_policy,omitempty"`
+	Drain               DrainConfig              `yaml:"drain,omitempty"`
+	IOWorkers           IOWorkersConfig          `yaml:"io_workers,omitempty"`
+	Recording           RecordingConfig          `yaml:"recording,omitempty"`
+	HLS                 HLSConfig                `yaml:"hls,omitempty"`
+	Transcription       TranscriptionConfig      `yaml:"transcription,omitempty"`
+	Restream            RestreamConfig           `yaml:"restream,omitempty"`
+	Storage             StorageConfig            `yaml:"storage,omitempty"`
+	Bridge              BridgeConfig             `yaml:"bridge,omitempty"`
+	Region              string                   `yaml:"region,omitempty"`
+	SignalRelay         SignalRelayConfig        `yaml:"signal_relay,omitempty"`
+	Edge                EdgeConfig               `yaml:"edge,omitempty"`
+	Heartbeat           HeartbeatConfig          `yaml:"heartbeat,omitempty"`
+	RoomDirectory       RoomDirectoryConfig      `yaml:"room_directory,omitempty"`
+	RouterMessages      RouterMessagesConfig     `yaml:"router_messages,omitempty"`
+	CrashReport         CrashReportConfig        `yaml:"crash_report,omitempty"`
+	PublishHook         PublishHookConfig        `yaml:"publish_hook,omitempty"`
+	PostRoom            PostRoomConfig           `yaml:"post_room,omitempty"`
+	Campus              CampusConfig             `yaml:"campus,omitempty"`
+	SessionLimits       SessionLimitConfig       `yaml:"session_limits,omitempty"`
+	Quotas              QuotaConfig              `yaml:"quotas,omitempty"`
+	ResourceBudget      ResourceBudgetConfig     `yaml:"resource_budget,omitempty"`
+	QoSReport           QoSReportConfig          `yaml:"qos_report,omitempty"`
 	// LogLevel is deprecated
 	LogLevel string        `yaml:"log_level,omitempty"`
 	Logging  LoggingConfig `yaml:"logging,omitempty"`
@@ -79,6 +136,99 @@ type Config struct {
 	Development bool `yaml:"development,omitempty"`
 }
 
+// TracingConfig exports OpenTelemetry spans of the join flow, from the signal connection through routing to the
+// RTC session, to an OTLP collector. Trace context is passed between nodes with signal relay requests
+type TracingConfig struct {
+	// host:port of grpc collectors, URL of http collectors. Spans are not exported when empty
+	OTLPEndpoint string `yaml:"otlp_endpoint,omitempty"`
+	// grpc or http
+	Protocol string `yaml:"protocol,omitempty"`
+	// connects to grpc collectors without TLS
+	Insecure bool              `yaml:"insecure,omitempty"`
+	Headers  map[string]string `yaml:"headers,omitempty"`
+	// fraction of joins traced unless clients send a traceparent header, which decides instead
+	SampleRatio float64 `yaml:"sample_ratio,omitempty"`
+	ServiceName string  `yaml:"service_name,omitempty"`
+}
+
+// TLSConfig enables HTTPS/WSS on the main API and signaling port, either from certificate files
+// or with certificates obtained from an ACME CA such as Let's Encrypt
+type TLSConfig struct {
+	CertFile string     `yaml:"cert_file,omitempty"`
+	KeyFile  string     `yaml:"key_file,omitempty"`
+	ACME     ACMEConfig `yaml:"acme,omitempty"`
+	// staple OCSP responses for certificates loaded from files. ACME certificates are not stapled
+	OCSPStapling bool `yaml:"ocsp_stapling,omitempty"`
+}
+
+type ACMEConfig struct {
+	Enabled bool     `yaml:"enabled,omitempty"`
+	Domains []string `yaml:"domains,omitempty"`
+	Email   string   `yaml:"email,omitempty"`
+	// directory where issued certificates and the account key are persisted
+	CacheDir string `yaml:"cache_dir,omitempty"`
+	// ACME directory, defaults to Let's Encrypt production
+	DirectoryURL string `yaml:"directory_url,omitempty"`
+	// port to answer HTTP-01 challenges on, usually 80. TLS-ALPN-01 is always served on the main port
+	HTTPChallengePort uint32 `yaml:"http_challenge_port,omitempty"`
+}
+
+type ListenerRole string
+
+const (
+	// RTC signaling, /rtc and /rtc/validate
+	ListenerRoleSignal ListenerRole = "signal"
+	// Twirp and other server APIs
+	ListenerRoleAPI ListenerRole = "api"
+	// status and health checks
+	ListenerRoleHealth ListenerRole = "health"
+	// prometheus metrics on /metrics
+	ListenerRoleMetrics ListenerRole = "metrics"
+)
+
+func (r ListenerRole) Valid() bool {
+	switch r {
+	case ListenerRoleSignal, ListenerRoleAPI, ListenerRoleHealth, ListenerRoleMetrics:
+		return true
+	default:
+		return false
+	}
+}
+
+// ListenerConfig serves a subset of the HTTP endpoints on an address. When listeners are set, they replace the
+// listeners opened on bind_addresses and port for the main HTTP service
+type ListenerConfig struct {
+	// host:port
+	Address string         `yaml:"address"`
+	Roles   []ListenerRole `yaml:"roles"`
+	// serve HTTPS/WSS with the certificates from tls
+	TLS bool `yaml:"tls,omitempty"`
+}
+
+// ControlSocketConfig serves the server APIs on a Unix domain socket for co-located tooling.
+// Requests on the socket are not authenticated, access is controlled by the socket file permissions
+type ControlSocketConfig struct {
+	// empty disables the socket
+	Path string `yaml:"path,omitempty"`
+	// octal file mode of the socket, defaults to 0660
+	Mode string `yaml:"mode,omitempty"`
+}
+
+func (c *ControlSocketConfig) FileMode() (os.FileMode, error) {
+	if c.Mode == "" {
+		return 0660, nil
+	}
+	mode, err := strconv.ParseUint(c.Mode, 8, 32)
+	if err != nil {
+		return 0, err
+	}
+	return os.FileMode(mode), nil
+}
+
+func (c *TLSConfig) IsEnabled() bool {
+	return c.ACME.Enabled || (c.CertFile != "" && c.KeyFile != "")
+}
+
 type RTCConfig struct {
 	rtcconfig.RTCConfig `yaml:",inline"`
 
@@ -89,6 +239,16 @@ type RTCConfig struct {
 	// Number of packets to buffer for NACK
 	PacketBufferSize int `yaml:"packet_buffer_size,omitempty"`
 
+	// Number of forwarder rewrite decisions (SSRC switches, sequence number/timestamp offsets)
+	// kept per down track and reported on /debug/rooms, 0 disables
+	ForwarderAuditSize int `yaml:"forwarder_audit_size,omitempty"`
+
+	// Detection and recovery of subscribed tracks that stopped forwarding
+	Watchdog WatchdogConfig `yaml:"watchdog,omitempty"`
+
+	// Reconciliation of signaled mute state with the media received from publishers
+	MuteReconciliation MuteReconciliationConfig `yaml:"mute_reconciliation,omitempty"`
+
 	// Throttle periods for pli/fir rtcp packets
 	PLIThrottle PLIThrottleConfig `yaml:"pli_throttle,omitempty"`
 
@@ -105,6 +265,39 @@ type RTCConfig struct {
 
 	// force a reconnect on a data channel error
 	ReconnectOnDataChannelError *bool `yaml:"reconnect_on_data_channel_error,omitempty"`
+
+	// maximum bitrates of published tracks
+	PublishBitrateLimits PublishBitrateLimitsConfig `yaml:"publish_bitrate_limits,omitempty"`
+
+	// RTCP feedback sent to publishers
+	RTCPFeedback RTCPFeedbackConfig `yaml:"rtcp_feedback,omitempty"`
+
+	// DTLS certificate shared by all connections of the node
+	DTLS DTLSConfig `yaml:"dtls,omitempty"`
+
+	// SRTP protection profiles offered to clients, in order of preference.
+	// AEAD_AES_128_GCM, AEAD_AES_256_GCM and AES_CM_128_HMAC_SHA1_80 are supported
+	SRTPProtectionProfiles []string `yaml:"srtp_protection_profiles,omitempty"`
+}
+
+// WatchdogConfig controls detection of stuck down tracks. A down track is stalled when its publisher
+// keeps sending but nothing has been sent to the subscriber for StallTimeout. Stalled down tracks are
+// resynced and a key frame is requested; after MaxResets consecutive resets that did not help,
+// the subscriber is asked to fully reconnect.
+type WatchdogConfig struct {
+	// 0 disables the watchdog
+	StallTimeout time.Duration `yaml:"stall_timeout,omitempty"`
+	// 0 never forces a reconnect
+	MaxResets int `yaml:"max_resets,omitempty"`
+}
+
+// MuteReconciliationConfig controls correction of published tracks whose signaled mute state does not
+// match the media received. A track signaled as unmuted that has not received media for MediaTimeout is
+// muted by the server until media resumes, unless media is not expected on it (no subscribers, paused by
+// dynacast or a screen share); a publisher that keeps sending on a track it muted is asked to mute again.
+type MuteReconciliationConfig struct {
+	// 0 disables reconciliation
+	MediaTimeout time.Duration `yaml:"media_timeout,omitempty"`
 }
 
 type TURNServer struct {
@@ -115,6 +308,59 @@ type TURNServer struct {
 	Credential string `yaml:"credential,omitempty"`
 }
 
+// PublishBitrateLimitsConfig caps published tracks by source, in bps. Publishers are capped through
+// REMB feedback, which covers all their tracks, so it is only sent when every published video track
+// has a limit. Tracks exceeding their limit regardless are suspended after DropAfter.
+type PublishBitrateLimitsConfig struct {
+	Camera           uint64 `yaml:"camera,omitempty"`
+	Microphone       uint64 `yaml:"microphone,omitempty"`
+	ScreenShare      uint64 `yaml:"screen_share,omitempty"`
+	ScreenShareAudio uint64 `yaml:"screen_share_audio,omitempty"`
+	// tracks exceeding their limit for this long are no longer forwarded until back under it.
+	// 0 disables dropping
+	DropAfter time.Duration `yaml:"drop_after,omitempty"`
+}
+
+func (c *PublishBitrateLimitsConfig) Enabled() bool {
+	return c.Camera != 0 || c.Microphone != 0 || c.ScreenShare != 0 || c.ScreenShareAudio != 0
+}
+
+// CheckICELite verifies that clients can reach the node directly when ICE-lite is used.
+// A lite agent never sends connectivity checks, so it cannot open a path through NAT.
+func (c *RTCConfig) CheckICELite() error {
+	if !c.UseICELite {
+		return nil
+	}
+
+	ip := net.ParseIP(c.NodeIP)
+	if ip == nil || ip.IsUnspecified() || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || sharedAddressSpace.Contains(ip) {
+		return ErrICELiteNotRoutable
+	}
+	return nil
+}
+
+// carrier-grade NAT, RFC 6598
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// DTLSConfig sets a single DTLS certificate for all connections of the node, so that clients can pin
+// its fingerprint. The certificate is loaded from PEM files when set, otherwise generated at startup
+// with CertLifetime. When neither is configured, every connection uses its own certificate.
+type DTLSConfig struct {
+	CertFile     string        `yaml:"cert_file,omitempty"`
+	KeyFile      string        `yaml:"key_file,omitempty"`
+	CertLifetime time.Duration `yaml:"cert_lifetime,omitempty"`
+}
+
+type RTCPFeedbackConfig struct {
+	// interval between transport-cc feedback packets, halved at the end of frames
+	TransportCCInterval time.Duration `yaml:"transport_cc_interval,omitempty"`
+	// send feedback as is (RFC 5506), instead of in compound packets led by a receiver report
+	ReducedSize bool `yaml:"reduced_size"`
+	// hold receiver reports and REMB until the next transport-cc, NACK or PLI write,
+	// so that feedback for all streams of the publisher goes out together
+	Batch bool `yaml:"batch,omitempty"`
+}
+
 type PLIThrottleConfig struct {
 	LowQuality  time.Duration `yaml:"low_quality,omitempty"`
 	MidQuality  time.Duration `yaml:"mid_quality,omitempty"`
@@ -163,6 +409,9 @@ type CongestionControlConfig struct {
 	ChannelObserverProbeConfig       CongestionControlChannelObserverConfig `yaml:"channel_observer_probe_config,omitempty"`
 	ChannelObserverNonProbeConfig    CongestionControlChannelObserverConfig `yaml:"channel_observer_non_probe_config,omitempty"`
 	DisableEstimationUnmanagedTracks bool                                   `yaml:"disable_etimation_unmanaged_tracks,omitempty"`
+
+	// maximum share of sent bytes spent on retransmissions and probing, 0 disables
+	MaxRepairShare float64 `yaml:"max_repair_share,omitempty"`
 }
 
 type AudioConfig struct {
@@ -221,6 +470,223 @@ type RoomConfig struct {
 	EnableRemoteUnmute bool               `yaml:"enable_remote_unmute,omitempty"`
 	MaxMetadataSize    uint32             `yaml:"max_metadata_size,omitempty"`
 	PlayoutDelay       PlayoutDelayConfig `yaml:"playout_delay,omitempty"`
+
+	// restrict automatic creation, rooms not matching need to be created through the API first.
+	// when set, only rooms with these name prefixes are automatically created
+	AutoCreatePrefixes []string `yaml:"auto_create_prefixes,omitempty"`
+	// when set, only tokens signed with these API keys automatically create rooms
+	AutoCreateAPIKeys []string `yaml:"auto_create_api_keys,omitempty"`
+	// only tokens with the roomCreate grant automatically create rooms
+	AutoCreateRequireGrant bool `yaml:"auto_create_require_grant,omitempty"`
+
+	// aggregate bitrate (bps) forwarded to all subscribers of a room, shared fairly between them, 0 for no limit
+	MaxEgressBitrate uint64 `yaml:"max_egress_bitrate,omitempty"`
+	// overrides the estimated bandwidth of every subscriber, e.g. for deterministic QoS tests
+	SubscriberBandwidth BandwidthOverrideConfig `yaml:"subscriber_bandwidth,omitempty"`
+	// how subscriber bandwidth is split between video tracks, default or speaker
+	VideoAllocation VideoAllocationPreset `yaml:"video_allocation,omitempty"`
+	// what subscribers see while a video track is paused by the stream allocator or muted by its publisher.
+	// off freezes at the last frame, keyframe repeats the last key frame slowly, pattern sends a static blank frame
+	PausedVideoPlaceholder PausedVideoPlaceholder `yaml:"paused_video_placeholder,omitempty"`
+	// slows down non-critical participant updates (active speakers, connection quality) as rooms grow.
+	// joins and leaves are not affected
+	UpdateThrottle []UpdateThrottleStep `yaml:"update_throttle,omitempty"`
+	// seconds a room lingers after the last participant left before it's closed and room_finished fires,
+	// gives participants time to reconnect. defaults to 20
+	DepartureTimeout uint32 `yaml:"departure_timeout,omitempty"`
+	// named sets of room settings, selected with the Livekit-Room-Template header of CreateRoom
+	Templates map[string]RoomTemplate `yaml:"templates,omitempty"`
+	// server side key management of end-to-end encrypted rooms
+	E2EE E2EEConfig `yaml:"e2ee,omitempty"`
+	// publishers are asked for opus in-band FEC unless disabled. rooms of templates flagged lossy always ask for it
+	DisableOpusFEC bool `yaml:"disable_opus_fec,omitempty"`
+	// custom token claims, e.g. a role, shown to the other participants of the room
+	PublicClaims PublicClaimsConfig `yaml:"public_claims,omitempty"`
+	// negotiate H.265 (video/h265) with clients that can encode it, e.g. Safari and native SDKs. tracks are
+	// passed through, so subscribers need to decode H.265 as well. rooms of every template get the codec
+	EnableH265 bool `yaml:"enable_h265,omitempty"`
+}
+
+// PublicClaimsConfig adds custom claims of participant tokens to the participant info sent to the room, under a key
+// of the participant metadata. Metadata that isn't a JSON object is sent as is. Claims take precedence over a
+// metadata key of the same name, so participants cannot impersonate them by updating their metadata
+type PublicClaimsConfig struct {
+	// names of the claims that are shared, no claims are shared when empty
+	Claims []string `yaml:"claims,omitempty"`
+	// metadata key holding the claims, defaults to claims
+	MetadataKey string `yaml:"metadata_key,omitempty"`
+	// only share claims of tokens with the shareClaims entitlement. without it, tokens may still opt out with
+	// shareClaims: false
+	RequireGrant bool `yaml:"require_grant,omitempty"`
+}
+
+// BandwidthOverrideConfig replaces congestion control decisions for subscribers. Simulcast layers are selected
+// to fit the resulting bandwidth as they are for the estimate. Bitrates are in bps
+type BandwidthOverrideConfig struct {
+	// caps the estimated bandwidth, 0 for no cap
+	MaxBitrate uint64 `yaml:"max_bitrate,omitempty" json:"max_bitrate"`
+	// used instead of the estimated bandwidth, probing stops. 0 follows the estimate
+	ForcedBitrate uint64 `yaml:"forced_bitrate,omitempty" json:"forced_bitrate"`
+}
+
+// E2EEConfig makes the server the key provider of end-to-end encrypted (insertable streams) rooms. Keys are
+// sent to participants as reliable data packets, encrypted media is forwarded as is
+type E2EEConfig struct {
+	Enabled bool `yaml:"enabled,omitempty"`
+	// shared: one key for the room, per_participant: every participant encrypts with its own key. defaults to shared
+	KeyMode E2EEKeyMode `yaml:"key_mode,omitempty"`
+	// participants with one of these grants (room_admin, can_publish, can_publish_data) may ask for keys to be
+	// rotated. empty lets no participant rotate keys, the server API always can
+	RotateGrants []string `yaml:"rotate_grants,omitempty"`
+	// rotate keys when a participant leaves, so that it cannot decrypt media published afterwards
+	RotateOnLeave bool `yaml:"rotate_on_leave,omitempty"`
+}
+
+// RoomTemplate holds the settings applied to rooms created with it. Settings left empty are taken
+// from the inherited template, settings of the CreateRoom request take precedence
+type RoomTemplate struct {
+	// name of the template this one extends
+	Inherits        string      `yaml:"inherits,omitempty"`
+	Description     string      `yaml:"description,omitempty"`
+	EnabledCodecs   []CodecSpec `yaml:"enabled_codecs,omitempty"`
+	MaxParticipants uint32      `yaml:"max_participants,omitempty"`
+	EmptyTimeout    uint32      `yaml:"empty_timeout,omitempty"`
+	MinPlayoutDelay uint32      `yaml:"min_playout_delay,omitempty"`
+	Metadata        string      `yaml:"metadata,omitempty"`
+	// webhook events of the rooms are sent to these URLs, in addition to webhook.urls
+	WebhookURLs []string `yaml:"webhook_urls,omitempty"`
+	// egress started with each room, replaces the inherited recording as a whole
+	Recording *RoomTemplateRecording `yaml:"recording,omitempty"`
+	// makes rooms one-to-many broadcasts, replaces the inherited broadcast settings as a whole
+	Broadcast *RoomTemplateBroadcast `yaml:"broadcast,omitempty"`
+	// flags rooms of participants on lossy links, replaces the inherited lossy settings as a whole
+	Lossy *RoomTemplateLossy `yaml:"lossy,omitempty"`
+	// transcodes video for subscribers that cannot decode its codec, replaces the inherited transcoding as a whole
+	Transcoding *RoomTemplateTranscoding `yaml:"transcoding,omitempty"`
+}
+
+// RoomTemplateTranscoding has video transcoded to H.264 for subscribers that cannot decode the codecs it is
+// published with, instead of failing their subscription. It costs CPU and needs a server built with the
+// transcode tag
+type RoomTemplateTranscoding struct {
+	// bitrate (bps) of the transcoded rendition. defaults to 600000
+	Bitrate uint64 `yaml:"bitrate,omitempty"`
+}
+
+// RoomTemplateLossy forces opus in-band FEC, publishers add redundancy that lets subscribers recover lost audio
+// packets. Subscribers keep bitrate free of video for it
+type RoomTemplateLossy struct {
+	// bitrate (bps) kept free of video for every subscriber. defaults to 24000
+	FECHeadroom uint64 `yaml:"fec_headroom,omitempty"`
+	// audio is also sent to subscribers as RED (redundant audio), every packet repeating the previous ones, even
+	// when publishers do not send RED. Subscribers whose client does not support RED receive opus
+	REDEncoding bool `yaml:"red_encoding,omitempty"`
+}
+
+// RoomTemplateBroadcast configures broadcast rooms, where a few publishers are watched by a large audience.
+// Participants without the canPublish grant are viewers, they are not announced to other participants.
+// Fan-out stays on the node hosting the room, there is no relaying of media through other nodes
+type RoomTemplateBroadcast struct {
+	// participants that can publish allowed in the room, 0 is unlimited
+	MaxPublishers int `yaml:"max_publishers,omitempty"`
+	// subscribers of a track above which its packets are written by parallel workers. defaults to 4
+	FanOutThreshold int `yaml:"fan_out_threshold,omitempty"`
+}
+
+type RoomTemplateRecording struct {
+	// record a room composite
+	RoomComposite bool   `yaml:"room_composite,omitempty"`
+	Layout        string `yaml:"layout,omitempty"`
+	AudioOnly     bool   `yaml:"audio_only,omitempty"`
+	Filepath      string `yaml:"filepath,omitempty"`
+	// record each published track to its own file
+	Tracks        bool   `yaml:"tracks,omitempty"`
+	TrackFilepath string `yaml:"track_filepath,omitempty"`
+}
+
+// ResolveTemplate returns the named template with the settings it inherits filled in
+func (c *RoomConfig) ResolveTemplate(name string) (*RoomTemplate, error) {
+	t, ok := c.Templates[name]
+	if !ok {
+		return nil, fmt.Errorf("room template %s not found", name)
+	}
+	resolved := t
+	seen := map[string]bool{name: true}
+	for parent := t.Inherits; parent != ""; {
+		if seen[parent] {
+			return nil, fmt.Errorf("room template %s has an inheritance cycle through %s", name, parent)
+		}
+		seen[parent] = true
+
+		p, ok := c.Templates[parent]
+		if !ok {
+			return nil, fmt.Errorf("room template %s inherits unknown template %s", name, parent)
+		}
+		resolved.inherit(&p)
+		parent = p.Inherits
+	}
+	return &resolved, nil
+}
+
+// inherit fills the settings left empty from parent
+func (t *RoomTemplate) inherit(parent *RoomTemplate) {
+	if t.Description == "" {
+		t.Description = parent.Description
+	}
+	if len(t.EnabledCodecs) == 0 {
+		t.EnabledCodecs = parent.EnabledCodecs
+	}
+	if t.MaxParticipants == 0 {
+		t.MaxParticipants = parent.MaxParticipants
+	}
+	if t.EmptyTimeout == 0 {
+		t.EmptyTimeout = parent.EmptyTimeout
+	}
+	if t.MinPlayoutDelay == 0 {
+		t.MinPlayoutDelay = parent.MinPlayoutDelay
+	}
+	if t.Metadata == "" {
+		t.Metadata = parent.Metadata
+	}
+	if len(t.WebhookURLs) == 0 {
+		t.WebhookURLs = parent.WebhookURLs
+	}
+	if t.Recording == nil {
+		t.Recording = parent.Recording
+	}
+	if t.Broadcast == nil {
+		t.Broadcast = parent.Broadcast
+	}
+	if t.Lossy == nil {
+		t.Lossy = parent.Lossy
+	}
+	if t.Transcoding == nil {
+		t.Transcoding = parent.Transcoding
+	}
+}
+
+// UpdateThrottleStep multiplies the interval of non-critical participant updates once a room has MinParticipants
+type UpdateThrottleStep struct {
+	MinParticipants    int     `yaml:"min_participants"`
+	IntervalMultiplier float64 `yaml:"interval_multiplier"`
+}
+
+func (p VideoAllocationPreset) Valid() bool {
+	switch p {
+	case VideoAllocationPresetDefault, VideoAllocationPresetSpeaker:
+		return true
+	default:
+		return false
+	}
+}
+
+func (p PausedVideoPlaceholder) Valid() bool {
+	switch p {
+	case PausedVideoPlaceholderOff, PausedVideoPlaceholderKeyFrame, PausedVideoPlaceholderPattern:
+		return true
+	default:
+		return false
+	}
 }
 
 type CodecSpec struct {
@@ -230,7 +696,44 @@ type CodecSpec struct {
 
 type LoggingConfig struct {
 	logger.Config `yaml:",inline"`
-	PionLevel     string `yaml:"pion_level,omitempty"`
+	PionLevel     string         `yaml:"pion_level,omitempty"`
+	Sinks         LogSinksConfig `yaml:"sinks,omitempty"`
+}
+
+// LogSinksConfig configures additional log outputs, written to alongside stderr.
+// Any combination of sinks can be enabled at the same time.
+type LogSinksConfig struct {
+	File   LogFileSinkConfig   `yaml:"file,omitempty"`
+	Syslog LogSyslogSinkConfig `yaml:"syslog,omitempty"`
+	Loki   LogLokiSinkConfig   `yaml:"loki,omitempty"`
+}
+
+type LogFileSinkConfig struct {
+	// path of the active log file, rotated files are suffixed with .1, .2, ...
+	Path string `yaml:"path,omitempty"`
+	// size in megabytes at which the file is rotated
+	MaxSizeMB int `yaml:"max_size_mb,omitempty"`
+	// number of rotated files to keep
+	MaxBackups int `yaml:"max_backups,omitempty"`
+}
+
+type LogSyslogSinkConfig struct {
+	Enabled bool `yaml:"enabled,omitempty"`
+	// network and address of the syslog daemon, local syslog is used when empty
+	Network string `yaml:"network,omitempty"`
+	Address string `yaml:"address,omitempty"`
+	Tag     string `yaml:"tag,omitempty"`
+}
+
+type LogLokiSinkConfig struct {
+	// base URL of the Loki server, e.g. http://loki:3100
+	URL      string            `yaml:"url,omitempty"`
+	TenantID string            `yaml:"tenant_id,omitempty"`
+	Labels   map[string]string `yaml:"labels,omitempty"`
+	// number of log lines buffered in memory while waiting to be pushed, lines are dropped once full
+	BufferSize    int           `yaml:"buffer_size,omitempty"`
+	BatchSize     int           `yaml:"batch_size,omitempty"`
+	FlushInterval time.Duration `yaml:"flush_interval,omitempty"`
 }
 
 type TURNConfig struct {
@@ -243,12 +746,76 @@ type TURNConfig struct {
 	RelayPortRangeStart uint16 `yaml:"relay_range_start,omitempty"`
 	RelayPortRangeEnd   uint16 `yaml:"relay_range_end,omitempty"`
 	ExternalTLS         bool   `yaml:"external_tls,omitempty"`
+	// allocations a TURN user, i.e. a participant, may hold at once. 0 is unlimited
+	MaxAllocationsPerUser int `yaml:"max_allocations_per_user,omitempty"`
+	// bits per second relayed by an allocation in each direction, packets above it are dropped. 0 is unlimited
+	MaxAllocationBitrate uint64 `yaml:"max_allocation_bitrate,omitempty"`
+	// obtain and renew the TURN/TLS certificate automatically instead of using cert_file/key_file
+	ACME TURNACMEConfig `yaml:"acme,omitempty"`
+}
+
+// TURNACMEConfig issues the certificate for turn.domain with the ACME DNS-01 challenge,
+// which does not require the TURN host to serve HTTP
+type TURNACMEConfig struct {
+	Enabled bool   `yaml:"enabled,omitempty"`
+	Email   string `yaml:"email,omitempty"`
+	// ACME directory, defaults to Let's Encrypt production
+	DirectoryURL string `yaml:"directory_url,omitempty"`
+	// directory where the issued certificate and the account key are persisted
+	CacheDir string `yaml:"cache_dir,omitempty"`
+	// renew once the certificate expires within this window
+	RenewBefore time.Duration `yaml:"renew_before,omitempty"`
+	// name of a registered DNS provider used to publish the challenge TXT records
+	DNSProvider       string            `yaml:"dns_provider,omitempty"`
+	DNSProviderConfig map[string]string `yaml:"dns_provider_config,omitempty"`
+	// time to wait for challenge records to propagate before the CA validates them
+	PropagationDelay time.Duration `yaml:"propagation_delay,omitempty"`
 }
 
 type WebHookConfig struct {
 	URLs []string `yaml:"urls"`
 	// key to use for webhook
 	APIKey string `yaml:"api_key"`
+
+	// delivery attempts of an event, including the first one
+	MaxAttempts int `yaml:"max_attempts,omitempty"`
+	// wait before the first retry, doubled with every further retry up to MaxRetryInterval
+	RetryInterval    time.Duration `yaml:"retry_interval,omitempty"`
+	MaxRetryInterval time.Duration `yaml:"max_retry_interval,omitempty"`
+	RequestTimeout   time.Duration `yaml:"request_timeout,omitempty"`
+	// events waiting to be delivered per URL, further events are dead lettered or dropped
+	QueueSize  int                     `yaml:"queue_size,omitempty"`
+	DeadLetter WebHookDeadLetterConfig `yaml:"dead_letter,omitempty"`
+	Archive    WebHookArchiveConfig    `yaml:"archive,omitempty"`
+}
+
+// WebHookDeadLetterConfig keeps events that could not be delivered in redis, so they can be replayed
+type WebHookDeadLetterConfig struct {
+	Enabled bool `yaml:"enabled,omitempty"`
+	// dead letters kept, the oldest are discarded beyond
+	MaxSize int `yaml:"max_size,omitempty"`
+}
+
+// WebHookArchiveConfig archives the payload of every delivered or failed event to object storage, as a record of
+// the events sent
+type WebHookArchiveConfig struct {
+	// storage profile payloads are archived to, archiving is disabled when empty
+	StorageProfile string `yaml:"storage_profile,omitempty"`
+	// payloads are deleted within a day once older than retention, 0 keeps them
+	Retention time.Duration `yaml:"retention,omitempty"`
+	// payloads waiting to be archived, further payloads are not archived
+	QueueSize int `yaml:"queue_size,omitempty"`
+}
+
+// RedactionConfig removes personal data from webhook and analytics event payloads.
+// Room and participant sids are always kept, so events can still be correlated.
+type RedactionConfig struct {
+	// fields to redact, any of identity, name, metadata
+	Fields []string `yaml:"fields,omitempty"`
+	// when set, values are replaced by a salted SHA-256 digest instead of being cleared,
+	// so the same participant can be matched across events without revealing who it is
+	Hash bool   `yaml:"hash,omitempty"`
+	Salt string `yaml:"salt,omitempty"`
 }
 
 type NodeSelectorConfig struct {
@@ -257,6 +824,13 @@ type NodeSelectorConfig struct {
 	CPULoadLimit float32        `yaml:"cpu_load_limit,omitempty"`
 	SysloadLimit float32        `yaml:"sysload_limit,omitempty"`
 	Regions      []RegionConfig `yaml:"regions,omitempty"`
+	// used in cpuheadroom, nodes with fewer idle CPUs don't take new rooms
+	MinCPUHeadroom float32 `yaml:"min_cpu_headroom,omitempty"`
+
+	// measure RTT to the other nodes at this interval, 0 disables probing unless kind is latency
+	LatencyProbeInterval time.Duration `yaml:"latency_probe_interval,omitempty"`
+	// used in latency, nodes within this RTT of the closest node are considered equally close
+	LatencyTolerance time.Duration `yaml:"latency_tolerance,omitempty"`
 }
 
 type SignalRelayConfig struct {
@@ -267,12 +841,160 @@ type SignalRelayConfig struct {
 	StreamBufferSize int           `yaml:"stream_buffer_size,omitempty"`
 }
 
+// EdgeConfig relays signaling between clients and core media nodes over gRPC. Edge nodes terminate WebSocket
+// signaling, and optionally TURN, close to clients and host no rooms. Core nodes accept their relays
+type EdgeConfig struct {
+	// host:port of the relay listener of core nodes, usually a load balancer in front of them. Setting it runs this
+	// node as an edge
+	CoreAddress string `yaml:"core_address,omitempty"`
+	// on core nodes, port accepting relays from edges. 0 disables
+	RelayPort uint32 `yaml:"relay_port,omitempty"`
+	// shared secret edges authenticate to core nodes with, it also signs the TURN credentials of edges
+	Secret string `yaml:"secret,omitempty"`
+	// core nodes serve relays with TLS when cert_file and key_file are set. Edges connect with TLS when tls is set,
+	// verifying core nodes with ca_file or the system roots
+	CertFile string `yaml:"cert_file,omitempty"`
+	KeyFile  string `yaml:"key_file,omitempty"`
+	TLS      bool   `yaml:"tls,omitempty"`
+	CAFile   string `yaml:"ca_file,omitempty"`
+	// edges hand out their embedded TURN server (turn.enabled) to clients, ahead of the ICE servers of the core node
+	TURN bool `yaml:"turn,omitempty"`
+}
+
+func (c *EdgeConfig) IsEdge() bool {
+	return c.CoreAddress != ""
+}
+
+// HeartbeatConfig controls how quickly dead signal connections and participants are detected
+type HeartbeatConfig struct {
+	// interval of WebSocket pings sent to clients
+	PingInterval time.Duration `yaml:"ping_interval,omitempty"`
+	// signal connections that sent nothing for this long, not even a pong, are closed. 0 disables the timeout
+	PongTimeout time.Duration `yaml:"pong_timeout,omitempty"`
+	// interval and timeout of the pings clients send over signal, advertised in the join response
+	ClientPingInterval time.Duration `yaml:"client_ping_interval,omitempty"`
+	ClientPingTimeout  time.Duration `yaml:"client_ping_timeout,omitempty"`
+	// participants that sent no signal message for this long while none of their peer connections is connected
+	// are removed, 0 disables dead peer detection
+	StaleTimeout time.Duration `yaml:"stale_timeout,omitempty"`
+}
+
+// RoomDirectoryConfig controls the node local cache of room => node mappings kept in Redis.
+// Mapping changes are broadcast to all nodes, the TTL bounds staleness should a notification be missed
+type RoomDirectoryConfig struct {
+	// 0 disables the cache
+	CacheTTL time.Duration `yaml:"cache_ttl,omitempty"`
+}
+
+// NATSConfig connects to NATS, used in place of Redis for inter-node messages and for node, room and participant
+// state. State is kept in JetStream key-value buckets, so JetStream must be enabled on the servers
+type NATSConfig struct {
+	// servers to connect to, e.g. nats://nats-0:4222
+	URLs     []string `yaml:"urls,omitempty"`
+	Username string   `yaml:"username,omitempty"`
+	Password string   `yaml:"password,omitempty"`
+	Token    string   `yaml:"token,omitempty"`
+	// NATS credentials file, holding the user JWT and nkey seed
+	CredentialsFile string `yaml:"credentials_file,omitempty"`
+	// replicas of the key-value buckets created by the server, defaults to 1
+	Replicas int `yaml:"replicas,omitempty"`
+}
+
+func (c *NATSConfig) IsConfigured() bool {
+	return len(c.URLs) > 0
+}
+
+// PersistenceConfig mirrors rooms and their settings to a SQL database, so that they survive restarts of the
+// whole cluster. Rooms are synced periodically and restored into the room store on start
+type PersistenceConfig struct {
+	// database/sql driver name, the driver has to be linked into the server. defaults to pgx
+	Driver string `yaml:"driver,omitempty"`
+	// data source name, e.g. postgres://livekit:secret@db:5432/livekit. persistence is disabled when empty
+	DSN string `yaml:"dsn,omitempty"`
+	// interval between syncs to the database
+	SyncInterval time.Duration `yaml:"sync_interval,omitempty"`
+}
+
+func (c *PersistenceConfig) IsConfigured() bool {
+	return c.DSN != ""
+}
+
+// RouterMessagesConfig controls how inter-node router messages are published through Redis pub/sub.
+// Compressed and batched messages are only understood by nodes that support them, enable on all nodes at once
+type RouterMessagesConfig struct {
+	// messages at least this large are compressed, 0 disables compression
+	CompressionThreshold int `yaml:"compression_threshold,omitempty"`
+	// messages to the same node within this interval are published together, 0 disables batching
+	BatchInterval time.Duration `yaml:"batch_interval,omitempty"`
+	// publish messages to a node with SPUBLISH, so that in a Redis Cluster only the shard owning the node's
+	// channels receives them instead of every cluster node. requires Redis 7
+	ShardedPubSub bool `yaml:"sharded_pubsub,omitempty"`
+}
+
+// CrashReportConfig sets where panics recovered in participant goroutines are reported, with their stack.
+// Panics are always logged and counted in livekit_participant_panic_total
+type CrashReportConfig struct {
+	// append reports as JSON lines to this file
+	File string `yaml:"file,omitempty"`
+	// POST reports as JSON to this URL
+	URL string `yaml:"url,omitempty"`
+}
+
+// PublishHookConfig asks an external service before tracks are published. The service can deny a
+// publication, or constrain its name, codecs and dimensions.
+type PublishHookConfig struct {
+	URL string `yaml:"url,omitempty"`
+	// key used to sign requests, defaults to the webhook api_key
+	APIKey  string        `yaml:"api_key,omitempty"`
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+	// allow publishing when the service cannot be reached or fails
+	FailOpen bool `yaml:"fail_open,omitempty"`
+}
+
+// PostRoomConfig runs named pipelines once rooms hosted on this node have finished. Pipelines of a room run
+// concurrently, the steps of a pipeline in order.
+type PostRoomConfig struct {
+	Pipelines []PostRoomPipelineConfig `yaml:"pipelines,omitempty"`
+	// runs are persisted here and resumed after a restart. They are only kept in memory when empty
+	StateDir string `yaml:"state_dir,omitempty"`
+	// finished runs are reported for this long
+	Retention time.Duration `yaml:"retention,omitempty"`
+}
+
+type PostRoomPipelineConfig struct {
+	Name string `yaml:"name"`
+	// rooms with names starting with this prefix run the pipeline, all rooms when empty
+	RoomPrefix string               `yaml:"room_prefix,omitempty"`
+	Steps      []PostRoomStepConfig `yaml:"steps"`
+	// attempts of each step before the run fails, defaults to 5
+	MaxAttempts int `yaml:"max_attempts,omitempty"`
+	// delay before the first retry of a step, doubled after every failed attempt. Defaults to 30s
+	RetryInterval time.Duration `yaml:"retry_interval,omitempty"`
+}
+
+type PostRoomStepConfig struct {
+	// upload_recordings, webhook or purge_store_keys
+	Type string `yaml:"type"`
+	// upload_recordings: profile receiving recordings that were kept locally, defaults to the profile of the room
+	StorageProfile string `yaml:"storage_profile,omitempty"`
+	// webhook: receives the summary of the room, signed like webhooks
+	URL string `yaml:"url,omitempty"`
+	// webhook: key used to sign requests, defaults to the webhook api_key
+	APIKey  string        `yaml:"api_key,omitempty"`
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+	// purge_store_keys: redis keys to delete. {room} is replaced with the room name, * matches any characters.
+	// Keys start with a literal prefix and {room} may not be next to a *, see ValidatePurgeStoreKey
+	Keys []string `yaml:"keys,omitempty"`
+}
+
 // RegionConfig lists available regions and their latitude/longitude, so the selector would prefer
 // regions that are closer
 type RegionConfig struct {
 	Name string  `yaml:"name"`
 	Lat  float64 `yaml:"lat"`
 	Lon  float64 `yaml:"lon"`
+	// signal URL of the region, joins of rooms pinned to it arriving through other regions are redirected there
+	URL string `yaml:"url,omitempty"`
 }
 
 type LimitConfig struct {
@@ -282,11 +1004,511 @@ type LimitConfig struct {
 	SubscriptionLimitAudio int32   `yaml:"subscription_limit_audio,omitempty"`
 }
 
+// SessionLimits caps concurrent sessions across the cluster, so that shared tokens cannot multiply load.
+// Sessions are counted per room and identity, a participant replacing its own session in a room is not
+// counted twice. 0 is unlimited
+type SessionLimits struct {
+	// sessions of one identity, across rooms
+	MaxPerIdentity int `yaml:"max_per_identity,omitempty"`
+	// sessions of all identities joining with tokens of one API key
+	MaxPerAPIKey int `yaml:"max_per_api_key,omitempty"`
+}
+
+type SessionLimitConfig struct {
+	SessionLimits `yaml:",inline"`
+	// limits of tokens signed with these API keys, replacing the defaults above
+	Keys map[string]SessionLimits `yaml:"keys,omitempty"`
+}
+
+// ForKey returns the limits of participants joining with a token of apiKey
+func (c *SessionLimitConfig) ForKey(apiKey string) SessionLimits {
+	if limits, ok := c.Keys[apiKey]; ok {
+		return limits
+	}
+	return c.SessionLimits
+}
+
+func (c *SessionLimitConfig) Enabled() bool {
+	if c.MaxPerIdentity > 0 || c.MaxPerAPIKey > 0 {
+		return true
+	}
+	for _, limits := range c.Keys {
+		if limits.MaxPerIdentity > 0 || limits.MaxPerAPIKey > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Quotas cap the resources used through each API key, a key exceeding a quota cannot create rooms or have
+// participants join until usage is back under it. 0 is unlimited
+type Quotas struct {
+	// open rooms created with the key, across the cluster
+	MaxRooms int `yaml:"max_rooms,omitempty"`
+	// participants connected with tokens of the key, across the cluster
+	MaxParticipants int `yaml:"max_participants,omitempty"`
+	// bitrate (bps) forwarded to subscribers of the rooms created with the key, on each node.
+	// It is shared between the rooms by demand, on top of room.max_egress_bitrate
+	MaxEgressBitrate uint64 `yaml:"max_egress_bitrate,omitempty"`
+	// participant minutes of the key in a calendar month (UTC), across the cluster
+	MonthlyParticipantMinutes int64 `yaml:"monthly_participant_minutes,omitempty"`
+}
+
+func (q *Quotas) enabled() bool {
+	return q.MaxRooms > 0 || q.MaxParticipants > 0 || q.MaxEgressBitrate > 0 || q.MonthlyParticipantMinutes > 0
+}
+
+type QuotaConfig struct {
+	Quotas `yaml:",inline"`
+	// quotas of these API keys, replacing the defaults above
+	Keys map[string]Quotas `yaml:"keys,omitempty"`
+}
+
+// ForKey returns the quotas of apiKey
+func (c *QuotaConfig) ForKey(apiKey string) Quotas {
+	if quotas, ok := c.Keys[apiKey]; ok {
+		return quotas
+	}
+	return c.Quotas
+}
+
+func (c *QuotaConfig) Enabled() bool {
+	if c.Quotas.enabled() {
+		return true
+	}
+	for _, quotas := range c.Keys {
+		if quotas.enabled() {
+			return true
+		}
+	}
+	return false
+}
+
+// ResourceBudgetConfig sets soft limits on what the node holds, to catch leaks on nodes running for months before
+// they exhaust it. Usage is exported as metrics regardless, exceeding a limit logs a warning. 0 disables a limit
+type ResourceBudgetConfig struct {
+	// how often usage is sampled
+	CheckInterval time.Duration `yaml:"check_interval,omitempty"`
+	MaxGoroutines int           `yaml:"max_goroutines,omitempty"`
+	// open file descriptors as a fraction of the process limit, e.g. 0.8. linux only
+	MaxFDUsage float64              `yaml:"max_fd_usage,omitempty"`
+	MaxObjects ResourceObjectLimits `yaml:"max_objects,omitempty"`
+	// new participants are refused while a limit is exceeded, participants already in rooms can reconnect
+	RefuseJoins bool `yaml:"refuse_joins,omitempty"`
+}
+
+// ResourceObjectLimits caps live objects of the node by subsystem
+type ResourceObjectLimits struct {
+	Rooms            int `yaml:"rooms,omitempty"`
+	Participants     int `yaml:"participants,omitempty"`
+	PublishedTracks  int `yaml:"published_tracks,omitempty"`
+	SubscribedTracks int `yaml:"subscribed_tracks,omitempty"`
+	PeerConnections  int `yaml:"peer_connections,omitempty"`
+}
+
+// QoSReportConfig stores a report of the network quality of each room when it closes, with the loss, RTT and
+// bitrate of every participant and how long their connection quality was at each level
+type QoSReportConfig struct {
+	Enabled bool `yaml:"enabled,omitempty"`
+	// profile reports are stored with, defaults to the storage profile of the room
+	StorageProfile string `yaml:"storage_profile,omitempty"`
+}
+
 type IngressConfig struct {
 	RTMPBaseURL string `yaml:"rtmp_base_url"`
 	WHIPBaseURL string `yaml:"whip_base_url"`
 }
 
+// TokenKeysConfig accepts access tokens signed with RS256 or ES256 by an external identity provider. These tokens
+// are verified with public keys instead of the API secrets, their issuer stands in for the API key
+type TokenKeysConfig struct {
+	// JSON Web Key Set of the identity provider, the key is selected by the kid header of the token
+	JWKSURL string `yaml:"jwks_url,omitempty"`
+	// interval the key set is fetched again at. an unknown kid triggers an early fetch, at most once per
+	// jwks_min_refresh_interval
+	JWKSRefreshInterval    time.Duration `yaml:"jwks_refresh_interval,omitempty"`
+	JWKSMinRefreshInterval time.Duration `yaml:"jwks_min_refresh_interval,omitempty"`
+	// issuers accepted for tokens verified with the key set, any issuer when empty
+	Issuers []string `yaml:"issuers,omitempty"`
+	// PEM encoded RSA or EC public keys, by the issuer of the tokens they verify
+	PEMFiles map[string]string `yaml:"pem_files,omitempty"`
+}
+
+func (c *TokenKeysConfig) Enabled() bool {
+	return c.JWKSURL != "" || len(c.PEMFiles) > 0
+}
+
+// AdminOIDCConfig requires a token of the organization's OpenID Connect provider on admin endpoints (RoomService,
+// campus and debug), on top of the API key token authorizing the call
+type AdminOIDCConfig struct {
+	// issuer of the tokens, its keys are discovered from <issuer>/.well-known/openid-configuration. Unset disables
+	// OIDC authentication
+	Issuer string `yaml:"issuer,omitempty"`
+	// key set of the provider, skipping discovery
+	JWKSURL string `yaml:"jwks_url,omitempty"`
+	// value the aud claim must contain, typically the client id
+	Audience string `yaml:"audience,omitempty"`
+	// claims the token must have, with one of the listed values. Array claims such as groups must contain one of
+	// them, an empty list only requires the claim
+	RequiredClaims map[string][]string `yaml:"required_claims,omitempty"`
+	// header carrying the token, as Authorization carries the API key token
+	Header             string        `yaml:"header,omitempty"`
+	KeyRefreshInterval time.Duration `yaml:"key_refresh_interval,omitempty"`
+}
+
+// AuthLockoutConfig temporarily rejects requests from client IPs that keep presenting invalid tokens
+type AuthLockoutConfig struct {
+	Enabled bool `yaml:"enabled,omitempty"`
+	// number of failed attempts within window that triggers a lockout
+	MaxFailures int           `yaml:"max_failures,omitempty"`
+	Window      time.Duration `yaml:"window,omitempty"`
+	// duration of the first lockout, doubled for each consecutive lockout up to max_duration
+	BaseDuration time.Duration `yaml:"base_duration,omitempty"`
+	MaxDuration  time.Duration `yaml:"max_duration,omitempty"`
+	// number of client IPs tracked at once, the stalest ones are forgotten beyond it
+	MaxClients int `yaml:"max_clients,omitempty"`
+}
+
+// CampusConfig secures token requests of the campus service
+type CampusConfig struct {
+	// requests authenticate with this shared secret, either in the X-Campus-Secret header, or as the key
+	// of the hex HMAC-SHA256 signature of the body in X-Campus-Signature. Token requests are refused while unset
+	Secret string `yaml:"secret,omitempty"`
+	// token requests allowed per client IP within rate_window, 0 disables rate limiting
+	RateLimit  int           `yaml:"rate_limit,omitempty"`
+	RateWindow time.Duration `yaml:"rate_window,omitempty"`
+	// grants of issued tokens, for the requested room
+	Grants CampusGrantTemplate `yaml:"grants,omitempty"`
+	// grants of tokens requested with a role, by role name. defaults to an unprivileged participant role
+	Roles    map[string]CampusGrantTemplate `yaml:"roles,omitempty"`
+	TokenTTL time.Duration                  `yaml:"token_ttl,omitempty"`
+}
+
+type CampusGrantTemplate struct {
+	RoomJoin  bool `yaml:"room_join,omitempty"`
+	RoomList  bool `yaml:"room_list,omitempty"`
+	RoomAdmin bool `yaml:"room_admin,omitempty"`
+	// unset permissions are granted
+	CanPublish     *bool `yaml:"can_publish,omitempty"`
+	CanSubscribe   *bool `yaml:"can_subscribe,omitempty"`
+	CanPublishData *bool `yaml:"can_publish_data,omitempty"`
+	Hidden         bool  `yaml:"hidden,omitempty"`
+	Recorder       bool  `yaml:"recorder,omitempty"`
+}
+
+// DefaultCampusRoles returns the roles campus tokens can be requested with when none are configured.
+// Roles with room admin or other privileged grants have to be configured explicitly
+func DefaultCampusRoles() map[string]CampusGrantTemplate {
+	granted := true
+	return map[string]CampusGrantTemplate{
+		"participant": {
+			RoomJoin:       true,
+			CanPublish:     &granted,
+			CanSubscribe:   &granted,
+			CanPublishData: &granted,
+		},
+	}
+}
+
+// ResumeTokenConfig lets participants resume their session on signal reconnect with the access token they
+// joined with, even when it expired in the meantime
+type ResumeTokenConfig struct {
+	Enabled bool `yaml:"enabled,omitempty"`
+	// how long after losing its connection a participant can still resume, renewed while the session is alive
+	TTL time.Duration `yaml:"ttl,omitempty"`
+}
+
+// ReconnectPolicyConfig spreads out reconnects of clients, so they don't stampede a recovering node in lockstep
+type ReconnectPolicyConfig struct {
+	// backoff advertised to clients once they are connected, not advertised when initial_delay is unset.
+	// the n-th attempt waits min(initial_delay * multiplier^(n-1), max_delay), varied by a jitter fraction of it
+	InitialDelay time.Duration `yaml:"initial_delay,omitempty"`
+	MaxDelay     time.Duration `yaml:"max_delay,omitempty"`
+	Multiplier   float64       `yaml:"multiplier,omitempty"`
+	MaxAttempts  int           `yaml:"max_attempts,omitempty"`
+	Jitter       float64       `yaml:"jitter,omitempty"`
+	// signal connections allowed per client IP within rate_window, 0 disables rate limiting
+	RateLimit  int           `yaml:"rate_limit,omitempty"`
+	RateWindow time.Duration `yaml:"rate_window,omitempty"`
+}
+
+// DrainConfig controls draining nodes through the API or SIGUSR1, e.g. for rolling upgrades
+type DrainConfig struct {
+	// how long to wait for rooms to empty before asking remaining participants to reconnect to other nodes,
+	// 0 waits until they leave
+	MaxDuration time.Duration `yaml:"max_duration,omitempty"`
+	// also fail the health check at / while draining, for load balancers that can't probe /ready
+	FailHealthCheck bool `yaml:"fail_health_check,omitempty"`
+	// how long to keep accepting signal connections after /ready starts failing, giving load balancers
+	// time to notice before the node stops taking new rooms
+	LBGracePeriod time.Duration `yaml:"lb_grace_period,omitempty"`
+	// deregisters the node from service discovery when it starts draining or shutting down
+	Consul     DrainConsulConfig       `yaml:"consul,omitempty"`
+	Deregister []DrainDeregisterConfig `yaml:"deregister,omitempty"`
+	// timeout of each deregistration call
+	DeregisterTimeout time.Duration `yaml:"deregister_timeout,omitempty"`
+}
+
+// DrainConsulConfig deregisters a service from the local consul agent
+type DrainConsulConfig struct {
+	// agent address, defaults to http://127.0.0.1:8500
+	Address   string `yaml:"address,omitempty"`
+	ServiceID string `yaml:"service_id,omitempty"`
+	Token     string `yaml:"token,omitempty"`
+}
+
+// DrainDeregisterConfig is an HTTP call removing the node from a load balancer, e.g. patching the pod's
+// labels through the kubernetes API so its service stops selecting it
+type DrainDeregisterConfig struct {
+	URL string `yaml:"url,omitempty"`
+	// defaults to POST
+	Method  string            `yaml:"method,omitempty"`
+	Headers map[string]string `yaml:"headers,omitempty"`
+	// sent verbatim, defaults to a JSON description of the node
+	Body string `yaml:"body,omitempty"`
+	// bearer token read at call time, e.g. /var/run/secrets/kubernetes.io/serviceaccount/token
+	BearerTokenFile string `yaml:"bearer_token_file,omitempty"`
+	// CA bundle to verify the endpoint with, e.g. /var/run/secrets/kubernetes.io/serviceaccount/ca.crt
+	CAFile string `yaml:"ca_file,omitempty"`
+}
+
+// IOWorkersConfig controls the liveness registry of egress/ingress workers reporting heartbeats
+type IOWorkersConfig struct {
+	// workers without a heartbeat for this long are considered dead
+	HeartbeatTimeout time.Duration `yaml:"heartbeat_timeout,omitempty"`
+	// dead workers are removed from the registry after this long
+	PurgeAfter time.Duration `yaml:"purge_after,omitempty"`
+	// minimum interval between webhook alerts when no worker is available
+	AlertInterval time.Duration `yaml:"alert_interval,omitempty"`
+}
+
+// RecordingConfig enables the built-in recorder, which writes the tracks of rooms hosted on this node to disk
+type RecordingConfig struct {
+	Enabled bool `yaml:"enabled,omitempty"`
+	// one sub directory is created per recording
+	OutputDir string `yaml:"output_dir,omitempty"`
+	// interval of recording_progress webhook events
+	ProgressInterval time.Duration `yaml:"progress_interval,omitempty"`
+	// base URL of download links returned when listing recordings, defaults to /recordings/download on this server
+	DownloadBaseURL string                    `yaml:"download_base_url,omitempty"`
+	Encryption      RecordingEncryptionConfig `yaml:"encryption,omitempty"`
+	// native writes ogg, ivf and h264 files. mkv muxes opus and vp8 tracks into matroska, other codecs stay native
+	Container string `yaml:"container,omitempty"`
+	// rotates the file of each track at this duration, video files are cut on a key frame. Disabled when zero
+	SegmentDuration time.Duration `yaml:"segment_duration,omitempty"`
+}
+
+// HLSConfig packages the tracks of a room participant into low latency HLS, served at /hls/<room>/master.m3u8,
+// so large audiences can watch without WebRTC connections. Tracks are passed through: H.264 video, each
+// simulcast layer becoming a variant, and Opus audio
+type HLSConfig struct {
+	Enabled bool `yaml:"enabled,omitempty"`
+	// one sub directory is created per room, segments are removed once they leave the playlist
+	OutputDir       string        `yaml:"output_dir,omitempty"`
+	SegmentDuration time.Duration `yaml:"segment_duration,omitempty"`
+	// duration of low latency parts
+	PartDuration time.Duration `yaml:"part_duration,omitempty"`
+	// segments listed in media playlists
+	PlaylistSize int `yaml:"playlist_size,omitempty"`
+	// video layers offered as variants, high, medium and low. All published layers when empty
+	Renditions []string `yaml:"renditions,omitempty"`
+	// also uploads segments and playlists to this storage profile
+	StorageProfile string `yaml:"storage_profile,omitempty"`
+	// serve playlists without a token, otherwise a token allowed to subscribe in the room is required
+	PublicPlayback bool `yaml:"public_playback,omitempty"`
+}
+
+// RestreamConfig controls restreaming rooms to RTMP endpoints through egress
+type RestreamConfig struct {
+	// key of the room metadata JSON object active restreams are reported under, not reported when empty
+	MetadataKey string `yaml:"metadata_key,omitempty"`
+}
+
+// TranscriptionConfig forks the microphone audio of participants to a speech-to-text backend and sends the
+// resulting captions to the room
+type TranscriptionConfig struct {
+	Enabled bool `yaml:"enabled,omitempty"`
+	// websocket or grpc
+	Backend string `yaml:"backend,omitempty"`
+	// ws:// or wss:// URL of websocket backends, host:port of grpc backends
+	URL string `yaml:"url,omitempty"`
+	// sent to the backend as a bearer token
+	Token string `yaml:"token,omitempty"`
+	// connects to grpc backends without TLS
+	Insecure bool `yaml:"insecure,omitempty"`
+	// opus passes the published payloads through, pcm decodes them to 16-bit mono at sample_rate, which needs the
+	// server built with the opus tag
+	Format     string `yaml:"format,omitempty"`
+	SampleRate int    `yaml:"sample_rate,omitempty"`
+	// passed to the backend, detected by the backend when empty
+	Language string `yaml:"language,omitempty"`
+	// also sends captions the backend may still revise
+	InterimResults bool `yaml:"interim_results,omitempty"`
+	// topic of the data packets carrying captions
+	Topic string `yaml:"topic,omitempty"`
+}
+
+// RecordingEncryptionConfig encrypts recorded files with AES-GCM. Each recording gets its own data key,
+// wrapped with the key of the room, either from keys or by a KMS
+type RecordingEncryptionConfig struct {
+	Enabled bool `yaml:"enabled,omitempty"`
+	// base64 encoded 32 byte keys by key ID, not needed with a KMS
+	Keys map[string]string `yaml:"keys,omitempty"`
+	// key used by rooms not matching any entry in rooms
+	DefaultKeyID string                       `yaml:"default_key_id,omitempty"`
+	Rooms        []RecordingKeyRoomConfig     `yaml:"rooms,omitempty"`
+	KMS          RecordingEncryptionKMSConfig `yaml:"kms,omitempty"`
+}
+
+type RecordingKeyRoomConfig struct {
+	// rooms with names starting with this prefix use the key, the first match wins
+	RoomPrefix string `yaml:"room_prefix"`
+	KeyID      string `yaml:"key_id"`
+}
+
+// RecordingEncryptionKMSConfig wraps data keys through a KMS exposing <url>/wrap and <url>/unwrap
+type RecordingEncryptionKMSConfig struct {
+	URL string `yaml:"url,omitempty"`
+	// sent as a bearer token
+	Token string `yaml:"token,omitempty"`
+}
+
+// StorageConfig defines where generated media such as recordings is uploaded
+type StorageConfig struct {
+	// profile used by rooms not matching any entry in rooms, files are kept locally when empty
+	DefaultProfile string                          `yaml:"default_profile,omitempty"`
+	Profiles       map[string]StorageProfileConfig `yaml:"profiles,omitempty"`
+	Rooms          []StorageRoomConfig             `yaml:"rooms,omitempty"`
+}
+
+type StorageRoomConfig struct {
+	// rooms with names starting with this prefix use the profile, the first match wins
+	RoomPrefix string `yaml:"room_prefix"`
+	Profile    string `yaml:"profile"`
+}
+
+type StorageProfileConfig struct {
+	// s3, gcs or azure. s3 covers S3 compatible services such as MinIO
+	Provider string `yaml:"provider"`
+	// bucket, or container for azure
+	Bucket string `yaml:"bucket"`
+	// prepended to object keys
+	Prefix string `yaml:"prefix,omitempty"`
+
+	// s3 and gcs, gcs requires HMAC keys
+	Endpoint       string `yaml:"endpoint,omitempty"`
+	Region         string `yaml:"region,omitempty"`
+	AccessKey      string `yaml:"access_key,omitempty"`
+	Secret         string `yaml:"secret,omitempty"`
+	ForcePathStyle bool   `yaml:"force_path_style,omitempty"`
+
+	// azure
+	AccountName string `yaml:"account_name,omitempty"`
+	SASToken    string `yaml:"sas_token,omitempty"`
+
+	// size of multipart upload parts in bytes, defaults to 16MiB
+	PartSize int64 `yaml:"part_size,omitempty"`
+	// attempts per part before the upload fails, defaults to 5
+	MaxRetries int `yaml:"max_retries,omitempty"`
+	// delay before the first retry, doubled for each following attempt, defaults to 1s
+	RetryDelay time.Duration `yaml:"retry_delay,omitempty"`
+	// upload bandwidth limit in bytes per second, unlimited when 0
+	MaxBandwidth int64 `yaml:"max_bandwidth,omitempty"`
+	// remove local files once uploaded
+	DeleteLocal bool `yaml:"delete_local,omitempty"`
+}
+
+// BridgeConfig links local rooms with rooms of other LiveKit deployments
+type BridgeConfig struct {
+	// API key used by bridges to join local rooms, bridges are disabled when empty
+	APIKey string `yaml:"api_key,omitempty"`
+	// URL bridges use to join local rooms, defaults to the loopback address and port of this server
+	LocalURL string `yaml:"local_url,omitempty"`
+	// delay before reconnecting a bridge after a failure
+	ReconnectDelay time.Duration `yaml:"reconnect_delay,omitempty"`
+	// bridges started with the server
+	Bridges []BridgeSpec `yaml:"bridges,omitempty"`
+	// plain RTP interop presets, adding to or overriding the built-in janus and mediasoup presets
+	RTPPresets map[string]RTPPresetConfig `yaml:"rtp_presets,omitempty"`
+	// plain RTP forwards and ingests started with the server
+	RTPForwards []RTPForwardSpec `yaml:"rtp_forwards,omitempty"`
+	// SIP gateway letting phone callers join rooms
+	SIP SIPConfig `yaml:"sip,omitempty"`
+}
+
+type BridgeSpec struct {
+	Room      string `yaml:"room"`
+	RemoteURL string `yaml:"remote_url"`
+	// joins the remote room, it needs to allow subscribing to pull and publishing to push
+	RemoteToken string `yaml:"remote_token"`
+	// pull republishes remote tracks locally, push publishes local tracks remotely, both does both, defaults to pull
+	Direction string `yaml:"direction,omitempty"`
+	// only tracks of these participants are bridged, all when empty
+	Identities []string `yaml:"identities,omitempty"`
+	// only tracks from these sources are bridged (camera, microphone, screen_share, screen_share_audio), all when empty
+	Sources []string `yaml:"sources,omitempty"`
+}
+
+// RTPPresetConfig describes how a plain RTP peer expects media to be exchanged
+type RTPPresetConfig struct {
+	// payload types by mime type, e.g. audio/opus: 111
+	PayloadTypes map[string]uint8 `yaml:"payload_types,omitempty"`
+	// RTCP shares the RTP port, otherwise it uses the next port
+	RTCPMux bool `yaml:"rtcp_mux,omitempty"`
+	// feedback sent to RTP sources, remb or none. NACK and transport-cc are never sent
+	Feedback string `yaml:"feedback,omitempty"`
+	// bitrate advertised to RTP sources through REMB, in bps
+	REMBBitrate uint64 `yaml:"remb_bitrate,omitempty"`
+}
+
+type RTPForwardSpec struct {
+	Room string `yaml:"room"`
+	// forward sends tracks of a participant to host, ingest publishes RTP received on the ports
+	Direction string `yaml:"direction"`
+	// interop preset, defaults to janus
+	Preset string `yaml:"preset,omitempty"`
+	// participant whose tracks are forwarded, or identity the ingested tracks are published with
+	Identity string `yaml:"identity"`
+	// destination of forwarded media
+	Host string `yaml:"host,omitempty"`
+	// destination ports when forwarding, listening ports when ingesting. media of a kind is skipped when 0
+	AudioPort int `yaml:"audio_port,omitempty"`
+	VideoPort int `yaml:"video_port,omitempty"`
+	// codecs of ingested media, default to audio/opus and video/vp8
+	AudioCodec string `yaml:"audio_codec,omitempty"`
+	VideoCodec string `yaml:"video_codec,omitempty"`
+}
+
+// SIPConfig lets SIP and PSTN callers dial into rooms as audio-only participants. Callers are routed
+// by the dialed number or, when it matches no route, enter a room code followed by # on their keypad.
+// Audio is exchanged as G.711, the server needs to be built with cgo and the opus tag.
+type SIPConfig struct {
+	// UDP port SIP requests are received on, the gateway is disabled when 0
+	Port int `yaml:"port,omitempty"`
+	// address advertised in SDP and Contact headers, defaults to the node IP
+	ExternalIP string `yaml:"external_ip,omitempty"`
+	// UDP ports used for call media
+	RTPPortStart int `yaml:"rtp_port_start,omitempty"`
+	RTPPortEnd   int `yaml:"rtp_port_end,omitempty"`
+	// concurrent calls accepted by this node, unlimited when 0
+	MaxCalls int `yaml:"max_calls,omitempty"`
+	// only calls from these networks (CIDR) are accepted, all when empty
+	AllowedNetworks []string `yaml:"allowed_networks,omitempty"`
+	// time callers have to enter a room code or PIN
+	InputTimeout time.Duration `yaml:"input_timeout,omitempty"`
+	Routes       []SIPRoute    `yaml:"routes,omitempty"`
+}
+
+type SIPRoute struct {
+	Room string `yaml:"room"`
+	// dialed number (user part of the request URI) leading straight to the room
+	Number string `yaml:"number,omitempty"`
+	// code callers enter on their keypad to reach the room
+	Code string `yaml:"code,omitempty"`
+	// PIN callers enter before joining, none when empty
+	PIN string `yaml:"pin,omitempty"`
+}
+
 // not exposed to YAML
 type APIConfig struct {
 	// amount of time to wait for API to execute, default 2s
@@ -366,6 +1588,15 @@ var DefaultConfig = Config{
 				NackRatioThreshold:             0.08,
 			},
 		},
+		Watchdog: WatchdogConfig{
+			StallTimeout: 10 * time.Second,
+			MaxResets:    2,
+		},
+		RTCPFeedback: RTCPFeedbackConfig{
+			TransportCCInterval: 100 * time.Millisecond,
+			ReducedSize:         true,
+		},
+		SRTPProtectionProfiles: []string{"AEAD_AES_128_GCM", "AEAD_AES_256_GCM", "AES_CM_128_HMAC_SHA1_80"},
 	},
 	Audio: AudioConfig{
 		ActiveLevel:     35, // -35dBov
@@ -451,6 +1682,13 @@ var DefaultConfig = Config{
 		},
 	},
 	Redis: redisLiveKit.RedisConfig{},
+	Persistence: PersistenceConfig{
+		Driver:       "pgx",
+		SyncInterval: 10 * time.Second,
+	},
+	NATS: NATSConfig{
+		Replicas: 1,
+	},
 	Room: RoomConfig{
 		AutoCreate: true,
 		EnabledCodecs: []CodecSpec{
@@ -465,15 +1703,54 @@ var DefaultConfig = Config{
 	},
 	Logging: LoggingConfig{
 		PionLevel: "error",
+		Sinks: LogSinksConfig{
+			File: LogFileSinkConfig{
+				MaxSizeMB:  100,
+				MaxBackups: 5,
+			},
+			Syslog: LogSyslogSinkConfig{
+				Tag: "livekit",
+			},
+			Loki: LogLokiSinkConfig{
+				BufferSize:    10000,
+				BatchSize:     500,
+				FlushInterval: time.Second,
+			},
+		},
+	},
+	TLS: TLSConfig{
+		ACME: ACMEConfig{
+			CacheDir: "./certs",
+		},
+	},
+	WebHook: WebHookConfig{
+		MaxAttempts:      5,
+		RetryInterval:    time.Second,
+		MaxRetryInterval: 30 * time.Second,
+		RequestTimeout:   10 * time.Second,
+		QueueSize:        100,
+		DeadLetter: WebHookDeadLetterConfig{
+			MaxSize: 1000,
+		},
+		Archive: WebHookArchiveConfig{
+			QueueSize: 1000,
+		},
 	},
 	TURN: TURNConfig{
 		Enabled: false,
+		ACME: TURNACMEConfig{
+			CacheDir:         "./certs/turn",
+			RenewBefore:      30 * 24 * time.Hour,
+			PropagationDelay: 30 * time.Second,
+		},
 	},
 	NodeSelector: NodeSelectorConfig{
 		Kind:         "any",
 		SortBy:       "random",
 		SysloadLimit: 0.9,
 		CPULoadLimit: 0.9,
+
+		LatencyTolerance: 10 * time.Millisecond,
 	},
 	SignalRelay: SignalRelayConfig{
 		Enabled:          true,
@@ -482,7 +1759,101 @@ var DefaultConfig = Config{
 		MaxRetryInterval: 4 * time.Second,
 		StreamBufferSize: 1000,
 	},
+	RoomDirectory: RoomDirectoryConfig{
+		CacheTTL: 5 * time.Second,
+	},
 	Keys: map[string]string{},
+	TokenKeys: TokenKeysConfig{
+		JWKSRefreshInterval:    10 * time.Minute,
+		JWKSMinRefreshInterval: 30 * time.Second,
+	},
+	AdminOIDC: AdminOIDCConfig{
+		Header:             "X-OIDC-Token",
+		KeyRefreshInterval: 10 * time.Minute,
+	},
+	AuthLockout: AuthLockoutConfig{
+		MaxFailures:  10,
+		Window:       time.Minute,
+		BaseDuration: 30 * time.Second,
+		MaxDuration:  time.Hour,
+		MaxClients:   100000,
+	},
+	ResumeToken: ResumeTokenConfig{
+		TTL: 2 * time.Minute,
+	},
+	Reconnect: ReconnectPolicyConfig{
+		MaxDelay:    30 * time.Second,
+		Multiplier:  2,
+		MaxAttempts: 10,
+		Jitter:      0.5,
+		RateWindow:  10 * time.Second,
+	},
+	ResourceBudget: ResourceBudgetConfig{
+		CheckInterval: 10 * time.Second,
+	},
+	Drain: DrainConfig{
+		MaxDuration:       30 * time.Minute,
+		DeregisterTimeout: 5 * time.Second,
+	},
+	IOWorkers: IOWorkersConfig{
+		HeartbeatTimeout: 30 * time.Second,
+		PurgeAfter:       24 * time.Hour,
+		AlertInterval:    5 * time.Minute,
+	},
+	Recording: RecordingConfig{
+		OutputDir:        "./recordings",
+		ProgressInterval: 10 * time.Second,
+		Container:        "native",
+	},
+	Restream: RestreamConfig{
+		MetadataKey: "restream",
+	},
+	HLS: HLSConfig{
+		OutputDir:       "./hls",
+		SegmentDuration: 4 * time.Second,
+		PartDuration:    500 * time.Millisecond,
+		PlaylistSize:    6,
+	},
+	Transcription: TranscriptionConfig{
+		Backend:    "websocket",
+		Format:     "opus",
+		SampleRate: 16000,
+		Topic:      "lk.transcription",
+	},
+	Bridge: BridgeConfig{
+		ReconnectDelay: 5 * time.Second,
+		SIP: SIPConfig{
+			RTPPortStart: 20000,
+			RTPPortEnd:   20999,
+			InputTimeout: 30 * time.Second,
+		},
+	},
+	PublishHook: PublishHookConfig{
+		Timeout: 2 * time.Second,
+	},
+	Heartbeat: HeartbeatConfig{
+		PingInterval:       10 * time.Second,
+		PongTimeout:        20 * time.Second,
+		ClientPingInterval: 10 * time.Second,
+		ClientPingTimeout:  20 * time.Second,
+		StaleTimeout:       20 * time.Second,
+	},
+	PostRoom: PostRoomConfig{
+		Retention: 7 * 24 * time.Hour,
+	},
+	Tracing: TracingConfig{
+		Protocol:    "grpc",
+		SampleRatio: 1,
+		ServiceName: "livekit-server",
+	},
+	Campus: CampusConfig{
+		RateLimit:  30,
+		RateWindow: time.Minute,
+		Grants: CampusGrantTemplate{
+			RoomJoin: true,
+		},
+		TokenTTL: 24 * time.Hour,
+	},
 }
 
 func NewConfig(confString string, strictMode bool, c *cli.Context, baseFlags []cli.Flag) (*Config, error) {
@@ -506,6 +1877,26 @@ func NewConfig(confString string, strictMode bool, c *cli.Context, baseFlags []c
 		}
 	}
 
+	if len(conf.Redis.SentinelAddresses) > 0 {
+		if len(conf.Redis.ClusterAddresses) > 0 {
+			return nil, errors.New("redis.sentinel_addresses and redis.cluster_addresses cannot be used together")
+		}
+		if conf.Redis.MasterName == "" {
+			return nil, errors.New("redis.sentinel_master_name is required with redis.sentinel_addresses")
+		}
+	}
+	if len(conf.Redis.ClusterAddresses) > 0 && conf.Redis.DB != 0 {
+		return nil, errors.New("redis.db is not supported by Redis Cluster")
+	}
+	if conf.NATS.IsConfigured() {
+		if conf.Redis.IsConfigured() {
+			return nil, errors.New("redis and nats cannot be used together")
+		}
+		if conf.NATS.Replicas < 1 || conf.NATS.Replicas > 5 {
+			return nil, fmt.Errorf("invalid nats.replicas %d, must be between 1 and 5", conf.NATS.Replicas)
+		}
+	}
+
 	// expand env vars in filenames
 	file, err := homedir.Expand(os.ExpandEnv(conf.KeyFile))
 	if err != nil {
@@ -525,6 +1916,453 @@ func NewConfig(confString string, strictMode bool, c *cli.Context, baseFlags []c
 		}
 	}
 
+	if conf.TLS.ACME.Enabled {
+		if len(conf.TLS.ACME.Domains) == 0 {
+			return nil, errors.New("tls.acme.domains is required when ACME is enabled")
+		}
+		if conf.TLS.CertFile != "" || conf.TLS.KeyFile != "" {
+			return nil, errors.New("tls.acme cannot be used together with tls.cert_file/key_file")
+		}
+	} else if (conf.TLS.CertFile == "") != (conf.TLS.KeyFile == "") {
+		return nil, errors.New("both tls.cert_file and tls.key_file are required")
+	}
+
+	for _, lc := range conf.Listeners {
+		if _, _, err := net.SplitHostPort(lc.Address); err != nil {
+			return nil, fmt.Errorf("invalid listeners.address %s: %v", lc.Address, err)
+		}
+		if len(lc.Roles) == 0 {
+			return nil, fmt.Errorf("listener %s has no roles", lc.Address)
+		}
+		for _, role := range lc.Roles {
+			if !role.Valid() {
+				return nil, fmt.Errorf("listener %s has invalid role: %s", lc.Address, role)
+			}
+		}
+		if lc.TLS && !conf.TLS.IsEnabled() {
+			return nil, fmt.Errorf("listener %s uses TLS, but tls is not configured", lc.Address)
+		}
+	}
+
+	if conf.ControlSocket.Path != "" {
+		if _, err := conf.ControlSocket.FileMode(); err != nil {
+			return nil, fmt.Errorf("invalid control_socket.mode %s: %v", conf.ControlSocket.Mode, err)
+		}
+	}
+
+	if wh := conf.WebHook; wh.MaxAttempts < 1 || wh.RetryInterval <= 0 || wh.MaxRetryInterval < wh.RetryInterval || wh.QueueSize < 1 {
+		return nil, errors.New("webhook.max_attempts and queue_size must be positive, retry_interval positive and not above max_retry_interval")
+	}
+	if dl := conf.WebHook.DeadLetter; dl.Enabled && !conf.Redis.IsConfigured() {
+		return nil, errors.New("webhook.dead_letter requires redis")
+	} else if dl.Enabled && dl.MaxSize < 1 {
+		return nil, errors.New("webhook.dead_letter.max_size must be positive")
+	}
+
+	if conf.TURN.RelayPortRangeStart > conf.TURN.RelayPortRangeEnd {
+		return nil, fmt.Errorf("turn.relay_range_start %d is above relay_range_end %d", conf.TURN.RelayPortRangeStart, conf.TURN.RelayPortRangeEnd)
+	}
+	if conf.TURN.MaxAllocationsPerUser < 0 {
+		return nil, errors.New("turn.max_allocations_per_user cannot be negative")
+	}
+
+	if conf.TURN.ACME.Enabled {
+		if conf.TURN.ACME.DNSProvider == "" {
+			return nil, errors.New("turn.acme.dns_provider is required when ACME is enabled")
+		}
+		if conf.TURN.ExternalTLS || conf.TURN.CertFile != "" || conf.TURN.KeyFile != "" {
+			return nil, errors.New("turn.acme cannot be used together with external_tls or cert_file/key_file")
+		}
+	}
+
+	if dtls := conf.RTC.DTLS; (dtls.CertFile == "") != (dtls.KeyFile == "") {
+		return nil, errors.New("rtc.dtls.cert_file and key_file must be set together")
+	} else if dtls.CertFile != "" && dtls.CertLifetime != 0 {
+		return nil, errors.New("rtc.dtls.cert_lifetime only applies to generated certificates, not to cert_file")
+	}
+
+	if share := conf.RTC.CongestionControl.MaxRepairShare; share < 0 || share >= 1 {
+		return nil, fmt.Errorf("rtc.congestion_control.max_repair_share must be in [0, 1): %v", share)
+	}
+
+	for apiKey, limits := range conf.SessionLimits.Keys {
+		if limits.MaxPerIdentity < 0 || limits.MaxPerAPIKey < 0 {
+			return nil, fmt.Errorf("session_limits of key %s cannot be negative", apiKey)
+		}
+	}
+	if conf.SessionLimits.MaxPerIdentity < 0 || conf.SessionLimits.MaxPerAPIKey < 0 {
+		return nil, errors.New("session_limits cannot be negative")
+	}
+	if conf.TokenKeys.JWKSURL != "" {
+		if u, err := url.Parse(conf.TokenKeys.JWKSURL); err != nil || (u.Scheme != "https" && u.Scheme != "http") {
+			return nil, fmt.Errorf("token_keys.jwks_url must be an http(s) URL: %s", conf.TokenKeys.JWKSURL)
+		}
+		if conf.TokenKeys.JWKSRefreshInterval <= 0 {
+			return nil, errors.New("token_keys.jwks_refresh_interval must be positive")
+		}
+	}
+	if conf.AdminOIDC.Issuer != "" {
+		for _, u := range []string{conf.AdminOIDC.Issuer, conf.AdminOIDC.JWKSURL} {
+			if u == "" {
+				continue
+			}
+			if parsed, err := url.Parse(u); err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") {
+				return nil, fmt.Errorf("admin_oidc issuer and jwks_url must be http(s) URLs: %s", u)
+			}
+		}
+		if conf.AdminOIDC.Header == "" || strings.EqualFold(conf.AdminOIDC.Header, "Authorization") {
+			return nil, errors.New("admin_oidc.header must be set and differ from Authorization")
+		}
+		if conf.AdminOIDC.KeyRefreshInterval <= 0 {
+			return nil, errors.New("admin_oidc.key_refresh_interval must be positive")
+		}
+	}
+	for apiKey, quotas := range conf.Quotas.Keys {
+		if quotas.MaxRooms < 0 || quotas.MaxParticipants < 0 || quotas.MonthlyParticipantMinutes < 0 {
+			return nil, fmt.Errorf("quotas of key %s cannot be negative", apiKey)
+		}
+	}
+	if conf.Quotas.MaxRooms < 0 || conf.Quotas.MaxParticipants < 0 || conf.Quotas.MonthlyParticipantMinutes < 0 {
+		return nil, errors.New("quotas cannot be negative")
+	}
+
+	if conf.Persistence.IsConfigured() && conf.Persistence.SyncInterval < time.Second {
+		return nil, errors.New("persistence.sync_interval must be at least 1s")
+	}
+
+	if wd := conf.RTC.Watchdog; wd.StallTimeout < 0 || wd.MaxResets < 0 {
+		return nil, errors.New("rtc.watchdog.stall_timeout and max_resets cannot be negative")
+	} else if wd.StallTimeout != 0 && wd.StallTimeout < time.Second {
+		return nil, errors.New("rtc.watchdog.stall_timeout must be at least 1s")
+	}
+
+	if mt := conf.RTC.MuteReconciliation.MediaTimeout; mt < 0 {
+		return nil, errors.New("rtc.mute_reconciliation.media_timeout cannot be negative")
+	} else if mt != 0 && mt < time.Second {
+		return nil, errors.New("rtc.mute_reconciliation.media_timeout must be at least 1s")
+	}
+
+	for _, step := range conf.Room.UpdateThrottle {
+		if step.MinParticipants <= 0 || step.IntervalMultiplier < 1 {
+			return nil, fmt.Errorf("invalid room.update_throttle step, min_participants must be positive and interval_multiplier at least 1: %+v", step)
+		}
+	}
+
+	for name, tmpl := range conf.Room.Templates {
+		if _, err := conf.Room.ResolveTemplate(name); err != nil {
+			return nil, err
+		}
+		if b := tmpl.Broadcast; b != nil && (b.MaxPublishers < 0 || b.FanOutThreshold < 0) {
+			return nil, fmt.Errorf("invalid broadcast settings of room template %s, max_publishers and fan_out_threshold cannot be negative", name)
+		}
+	}
+	if preset := conf.Room.VideoAllocation; preset != "" && !preset.Valid() {
+		return nil, fmt.Errorf("invalid room.video_allocation: %s", preset)
+	}
+	if placeholder := conf.Room.PausedVideoPlaceholder; placeholder != "" && !placeholder.Valid() {
+		return nil, fmt.Errorf("invalid room.paused_video_placeholder: %s", placeholder)
+	}
+	if e2ee := conf.Room.E2EE; e2ee.Enabled {
+		if e2ee.KeyMode != "" && e2ee.KeyMode != E2EEKeyModeShared && e2ee.KeyMode != E2EEKeyModePerParticipant {
+			return nil, fmt.Errorf("invalid room.e2ee.key_mode: %s", e2ee.KeyMode)
+		}
+		for _, grant := range e2ee.RotateGrants {
+			if grant != "room_admin" && grant != "can_publish" && grant != "can_publish_data" {
+				return nil, fmt.Errorf("invalid room.e2ee.rotate_grants: %s", grant)
+			}
+		}
+	}
+
+	if c := conf.Recording.Container; c != "native" && c != "mkv" {
+		return nil, fmt.Errorf("invalid recording.container: %s", c)
+	}
+	if d := conf.Recording.SegmentDuration; d != 0 && d < 10*time.Second {
+		return nil, errors.New("recording.segment_duration must be at least 10s")
+	}
+
+	if h := conf.HLS; h.Enabled {
+		if h.SegmentDuration <= 0 || h.PartDuration <= 0 || h.PartDuration > h.SegmentDuration {
+			return nil, errors.New("hls.part_duration must be positive and not longer than hls.segment_duration")
+		}
+		if h.PlaylistSize < 3 {
+			return nil, errors.New("hls.playlist_size must be at least 3")
+		}
+		for _, r := range h.Renditions {
+			if r != "high" && r != "medium" && r != "low" {
+				return nil, fmt.Errorf("invalid hls rendition: %s", r)
+			}
+		}
+		if h.StorageProfile != "" {
+			if _, ok := conf.Storage.Profiles[h.StorageProfile]; !ok {
+				return nil, fmt.Errorf("hls.storage_profile: unknown profile %s", h.StorageProfile)
+			}
+		}
+	}
+
+	if p := conf.QoSReport.StorageProfile; p != "" {
+		if _, ok := conf.Storage.Profiles[p]; !ok {
+			return nil, fmt.Errorf("qos_report.storage_profile: unknown profile %s", p)
+		}
+	}
+
+	if a := conf.WebHook.Archive; a.StorageProfile != "" {
+		if _, ok := conf.Storage.Profiles[a.StorageProfile]; !ok {
+			return nil, fmt.Errorf("webhook.archive.storage_profile: unknown profile %s", a.StorageProfile)
+		}
+		if a.Retention < 0 {
+			return nil, errors.New("webhook.archive.retention cannot be negative")
+		}
+	}
+
+	if t := conf.Transcription; t.Enabled {
+		if t.Backend != "websocket" && t.Backend != "grpc" {
+			return nil, fmt.Errorf("invalid transcription.backend: %s", t.Backend)
+		}
+		if t.URL == "" {
+			return nil, errors.New("transcription.url is required")
+		}
+		if t.Format != "opus" && t.Format != "pcm" {
+			return nil, fmt.Errorf("invalid transcription.format: %s", t.Format)
+		}
+		switch t.SampleRate {
+		case 8000, 12000, 16000, 24000, 48000:
+		default:
+			return nil, fmt.Errorf("invalid transcription.sample_rate: %d", t.SampleRate)
+		}
+		if t.Topic == "" {
+			return nil, errors.New("transcription.topic is required")
+		}
+	}
+
+	if enc := conf.Recording.Encryption; enc.Enabled {
+		keyIDs := []string{enc.DefaultKeyID}
+		for _, room := range enc.Rooms {
+			keyIDs = append(keyIDs, room.KeyID)
+		}
+		for _, keyID := range keyIDs {
+			if keyID == "" {
+				return nil, errors.New("recording.encryption.default_key_id and the key_id of rooms are required")
+			}
+			if enc.KMS.URL != "" {
+				continue
+			}
+			key, err := base64.StdEncoding.DecodeString(enc.Keys[keyID])
+			if err != nil || len(key) != 32 {
+				return nil, fmt.Errorf("recording.encryption: key %s must be a base64 encoded 32 byte key", keyID)
+			}
+		}
+	}
+
+	if conf.ResumeToken.Enabled && conf.ResumeToken.TTL <= 0 {
+		return nil, errors.New("resume_token.ttl must be positive")
+	}
+
+	if conf.Reconnect.InitialDelay > 0 {
+		if conf.Reconnect.Multiplier < 1 {
+			return nil, errors.New("reconnect_policy.multiplier must be at least 1")
+		}
+		if conf.Reconnect.MaxDelay < conf.Reconnect.InitialDelay {
+			return nil, errors.New("reconnect_policy.max_delay must not be less than initial_delay")
+		}
+		if conf.Reconnect.Jitter < 0 || conf.Reconnect.Jitter > 1 {
+			return nil, errors.New("reconnect_policy.jitter must be between 0 and 1")
+		}
+	}
+	if conf.Reconnect.RateLimit > 0 && conf.Reconnect.RateWindow <= 0 {
+		return nil, errors.New("reconnect_policy.rate_window must be positive")
+	}
+
+	if conf.Edge.IsEdge() || conf.Edge.RelayPort != 0 {
+		if conf.Edge.IsEdge() && conf.Edge.RelayPort != 0 {
+			return nil, errors.New("edge.core_address and edge.relay_port cannot both be set, a node is either an edge or a core node")
+		}
+		if conf.Edge.Secret == "" {
+			return nil, errors.New("edge.secret is required to relay signaling between edge and core nodes")
+		}
+		if (conf.Edge.CertFile == "") != (conf.Edge.KeyFile == "") {
+			return nil, errors.New("edge.cert_file and edge.key_file must be set together")
+		}
+		if conf.Edge.TURN && !conf.TURN.Enabled {
+			return nil, errors.New("edge.turn requires the embedded TURN server to be enabled")
+		}
+	}
+	if conf.ResourceBudget.CheckInterval <= 0 {
+		return nil, errors.New("resource_budget.check_interval must be positive")
+	}
+	if conf.Campus.RateLimit > 0 && conf.Campus.RateWindow <= 0 {
+		return nil, errors.New("campus.rate_window must be positive")
+	}
+	if conf.Campus.Roles == nil {
+		conf.Campus.Roles = DefaultCampusRoles()
+	}
+
+	if conf.PublishHook.URL != "" && conf.PublishHook.Timeout <= 0 {
+		return nil, errors.New("publish_hook.timeout must be positive")
+	}
+
+	if hb := conf.Heartbeat; hb.PingInterval <= 0 {
+		return nil, errors.New("heartbeat.ping_interval must be positive")
+	} else if hb.PongTimeout != 0 && hb.PongTimeout <= hb.PingInterval {
+		return nil, errors.New("heartbeat.pong_timeout must be longer than heartbeat.ping_interval")
+	} else if hb.ClientPingInterval < time.Second || hb.ClientPingTimeout <= hb.ClientPingInterval {
+		return nil, errors.New("heartbeat.client_ping_timeout must be longer than heartbeat.client_ping_interval, at least 1s")
+	}
+
+	if t := conf.Tracing; t.OTLPEndpoint != "" {
+		if t.Protocol != "grpc" && t.Protocol != "http" {
+			return nil, fmt.Errorf("invalid tracing.protocol: %s", t.Protocol)
+		}
+		if t.SampleRatio < 0 || t.SampleRatio > 1 {
+			return nil, errors.New("tracing.sample_ratio must be between 0 and 1")
+		}
+	}
+
+	if conf.Drain.Consul.ServiceID != "" && conf.Drain.Consul.Address == "" {
+		conf.Drain.Consul.Address = "http://127.0.0.1:8500"
+	}
+	for i := range conf.Drain.Deregister {
+		d := &conf.Drain.Deregister[i]
+		if u, err := url.Parse(d.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return nil, fmt.Errorf("invalid drain.deregister url: %q", d.URL)
+		}
+		if d.Method == "" {
+			d.Method = "POST"
+		}
+	}
+
+	pipelineNames := make(map[string]bool)
+	for i := range conf.PostRoom.Pipelines {
+		pl := &conf.PostRoom.Pipelines[i]
+		if pl.Name == "" || pipelineNames[pl.Name] {
+			return nil, errors.New("post_room pipelines require unique names")
+		}
+		pipelineNames[pl.Name] = true
+		if len(pl.Steps) == 0 {
+			return nil, fmt.Errorf("post_room pipeline %s: no steps", pl.Name)
+		}
+		if pl.MaxAttempts <= 0 {
+			pl.MaxAttempts = 5
+		}
+		if pl.RetryInterval <= 0 {
+			pl.RetryInterval = 30 * time.Second
+		}
+		for j := range pl.Steps {
+			step := &pl.Steps[j]
+			switch step.Type {
+			case "upload_recordings":
+				if !conf.Recording.Enabled {
+					return nil, fmt.Errorf("post_room pipeline %s: upload_recordings requires recording to be enabled", pl.Name)
+				}
+				if step.StorageProfile != "" {
+					if _, ok := conf.Storage.Profiles[step.StorageProfile]; !ok {
+						return nil, fmt.Errorf("post_room pipeline %s: unknown storage profile %s", pl.Name, step.StorageProfile)
+					}
+				}
+			case "webhook":
+				if step.URL == "" {
+					return nil, fmt.Errorf("post_room pipeline %s: webhook steps require a url", pl.Name)
+				}
+				if step.Timeout <= 0 {
+					step.Timeout = 10 * time.Second
+				}
+			case "purge_store_keys":
+				if len(step.Keys) == 0 {
+					return nil, fmt.Errorf("post_room pipeline %s: purge_store_keys steps require keys", pl.Name)
+				}
+				for _, key := range step.Keys {
+					if err := ValidatePurgeStoreKey(key); err != nil {
+						return nil, fmt.Errorf("post_room pipeline %s: %w", pl.Name, err)
+					}
+				}
+			default:
+				return nil, fmt.Errorf("post_room pipeline %s: invalid step type %q", pl.Name, step.Type)
+			}
+		}
+	}
+
+	if (len(conf.Bridge.Bridges) > 0 || len(conf.Bridge.RTPForwards) > 0) && conf.Bridge.APIKey == "" {
+		return nil, errors.New("bridge.api_key is required to run bridges")
+	}
+	for _, b := range conf.Bridge.Bridges {
+		if b.Room == "" || b.RemoteURL == "" || b.RemoteToken == "" {
+			return nil, errors.New("bridges require room, remote_url and remote_token")
+		}
+		switch b.Direction {
+		case "", "pull", "push", "both":
+		default:
+			return nil, fmt.Errorf("invalid bridge direction: %s", b.Direction)
+		}
+	}
+	for name, preset := range conf.Bridge.RTPPresets {
+		switch preset.Feedback {
+		case "", "remb", "none":
+		default:
+			return nil, fmt.Errorf("invalid feedback for rtp preset %s: %s", name, preset.Feedback)
+		}
+	}
+	for _, f := range conf.Bridge.RTPForwards {
+		if f.Room == "" || f.Identity == "" || (f.AudioPort == 0 && f.VideoPort == 0) {
+			return nil, errors.New("rtp forwards require room, identity and a port")
+		}
+		switch f.Direction {
+		case "forward":
+			if f.Host == "" {
+				return nil, errors.New("rtp forwards require host")
+			}
+		case "ingest":
+		default:
+			return nil, fmt.Errorf("invalid rtp forward direction: %s", f.Direction)
+		}
+	}
+	if sip := conf.Bridge.SIP; sip.Port != 0 {
+		if conf.Bridge.APIKey == "" {
+			return nil, errors.New("bridge.api_key is required to accept sip calls")
+		}
+		if sip.RTPPortStart <= 0 || sip.RTPPortEnd < sip.RTPPortStart {
+			return nil, errors.New("bridge.sip requires a valid rtp port range")
+		}
+		for _, network := range sip.AllowedNetworks {
+			if _, _, err := net.ParseCIDR(network); err != nil {
+				return nil, fmt.Errorf("invalid sip allowed network %s: %w", network, err)
+			}
+		}
+		for _, r := range sip.Routes {
+			if r.Room == "" || (r.Number == "" && r.Code == "") {
+				return nil, errors.New("sip routes require room and a number or code")
+			}
+		}
+	}
+
+	for name, profile := range conf.Storage.Profiles {
+		switch profile.Provider {
+		case "s3", "gcs", "azure":
+		default:
+			return nil, fmt.Errorf("storage profile %s: unknown provider %q", name, profile.Provider)
+		}
+		if profile.Bucket == "" {
+			return nil, fmt.Errorf("storage profile %s: bucket is required", name)
+		}
+		if profile.Provider == "azure" && profile.AccountName == "" {
+			return nil, fmt.Errorf("storage profile %s: account_name is required", name)
+		}
+	}
+	if _, ok := conf.Storage.Profiles[conf.Storage.DefaultProfile]; conf.Storage.DefaultProfile != "" && !ok {
+		return nil, fmt.Errorf("unknown storage profile: %s", conf.Storage.DefaultProfile)
+	}
+	for _, room := range conf.Storage.Rooms {
+		if _, ok := conf.Storage.Profiles[room.Profile]; !ok {
+			return nil, fmt.Errorf("unknown storage profile: %s", room.Profile)
+		}
+	}
+
+	for _, field := range conf.Redaction.Fields {
+		switch field {
+		case RedactFieldIdentity, RedactFieldName, RedactFieldMetadata:
+		default:
+			return nil, fmt.Errorf("unknown redaction field: %s", field)
+		}
+	}
+
 	if conf.LogLevel != "" {
 		conf.Logging.Level = conf.LogLevel
 	}
@@ -635,7 +2473,7 @@ func (conf *Config) ValidateKeys() error {
 		}
 	}
 
-	if len(conf.Keys) == 0 {
+	if len(conf.Keys) == 0 && !conf.TokenKeys.Enabled() {
 		return ErrKeysNotSet
 	}
 
@@ -649,6 +2487,21 @@ func (conf *Config) ValidateKeys() error {
 	return nil
 }
 
+// ValidatePurgeStoreKey checks that a purge_store_keys pattern only matches keys of a single room. The pattern has
+// to contain {room}, start with a literal prefix and may not have a * next to {room}, which would let room "a"
+// purge the keys of room "ab"
+func ValidatePurgeStoreKey(key string) error {
+	switch {
+	case !strings.Contains(key, "{room}"):
+		return fmt.Errorf("purge_store_keys key %q does not contain {room}", key)
+	case strings.HasPrefix(key, "{room}") || strings.HasPrefix(key, "*"):
+		return fmt.Errorf("purge_store_keys key %q has to start with a literal prefix", key)
+	case strings.Contains(key, "*{room}") || strings.Contains(key, "{room}*"):
+		return fmt.Errorf("purge_store_keys key %q has a wildcard next to {room}", key)
+	}
+	return nil
+}
+
 func GenerateCLIFlags(existingFlags []cli.Flag, hidden bool) ([]cli.Flag, error) {
 	blankConfig := &Config{}
 	flags := make([]cli.Flag, 0)
@@ -696,6 +2549,13 @@ func GenerateCLIFlags(existingFlags []cli.Flag, hidden bool) ([]cli.Flag, error)
 				Usage:   generatedCLIFlagUsage,
 				Hidden:  hidden,
 			}
+		case reflect.Uint64:
+			flag = &cli.Uint64Flag{
+				Name:    name,
+				EnvVars: []string{envVar},
+				Usage:   generatedCLIFlagUsage,
+				Hidden:  hidden,
+			}
 		case reflect.Float32:
 			flag = &cli.Float64Flag{
 				Name:    name,
@@ -731,8 +2591,7 @@ func (conf *Config) updateFromCLI(c *cli.Context, baseFlags []cli.Flag) error {
 	for _, flag := range c.App.Flags {
 		flagName := flag.Names()[0]
 
-		// the `c.App.Name != "test"` check is needed because `c.IsSet(...)` is always false in unit tests
-		if !c.IsSet(flagName) && c.App.Name != "test" {
+		if !c.IsSet(flagName) {
 			continue
 		}
 
@@ -757,7 +2616,7 @@ func (conf *Config) updateFromCLI(c *cli.Context, baseFlags []cli.Flag) error {
 			configValue.SetString(c.String(flagName))
 		case reflect.Int, reflect.Int32, reflect.Int64:
 			configValue.SetInt(c.Int64(flagName))
-		case reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
 			configValue.SetUint(c.Uint64(flagName))
 		case reflect.Float32:
 			configValue.SetFloat(c.Float64(flagName))
@@ -792,6 +2651,15 @@ func (conf *Config) updateFromCLI(c *cli.Context, baseFlags []cli.Flag) error {
 	if c.IsSet("redis-password") {
 		conf.Redis.Password = c.String("redis-password")
 	}
+	if c.IsSet("redis-sentinel-addresses") {
+		conf.Redis.SentinelAddresses = c.StringSlice("redis-sentinel-addresses")
+	}
+	if c.IsSet("redis-sentinel-master-name") {
+		conf.Redis.MasterName = c.String("redis-sentinel-master-name")
+	}
+	if c.IsSet("redis-cluster-addresses") {
+		conf.Redis.ClusterAddresses = c.StringSlice("redis-cluster-addresses")
+	}
 	if c.IsSet("turn-cert") {
 		conf.TURN.CertFile = c.String("turn-cert")
 	}
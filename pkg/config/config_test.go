@@ -17,6 +17,7 @@ package config
 import (
 	"flag"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 	"github.com/urfave/cli/v2"
@@ -47,6 +48,231 @@ room:
 	require.Error(t, err)
 }
 
+func TestConfig_RedisTopology(t *testing.T) {
+	t.Run("sentinel requires master name", func(t *testing.T) {
+		const content = `redis:
+  sentinel_addresses:
+    - sentinel-0:26379`
+		_, err := NewConfig(content, true, nil, nil)
+		require.Error(t, err)
+	})
+
+	t.Run("sentinel and cluster are exclusive", func(t *testing.T) {
+		const content = `redis:
+  sentinel_master_name: livekit
+  sentinel_addresses:
+    - sentinel-0:26379
+  cluster_addresses:
+    - redis-0:6379`
+		_, err := NewConfig(content, true, nil, nil)
+		require.Error(t, err)
+	})
+
+	t.Run("cluster has no db", func(t *testing.T) {
+		const content = `redis:
+  db: 1
+  cluster_addresses:
+    - redis-0:6379`
+		_, err := NewConfig(content, true, nil, nil)
+		require.Error(t, err)
+	})
+
+	t.Run("nats replaces redis", func(t *testing.T) {
+		const content = `redis:
+  address: redis-0:6379
+nats:
+  urls:
+    - nats://nats-0:4222`
+		_, err := NewConfig(content, true, nil, nil)
+		require.Error(t, err)
+	})
+
+	t.Run("cluster", func(t *testing.T) {
+		const content = `redis:
+  cluster_addresses:
+    - redis-0:6379
+    - redis-1:6379
+router_messages:
+  sharded_pubsub: true`
+		conf, err := NewConfig(content, true, nil, nil)
+		require.NoError(t, err)
+		require.True(t, conf.Redis.IsConfigured())
+		require.True(t, conf.RouterMessages.ShardedPubSub)
+	})
+}
+
+func TestValidatePurgeStoreKey(t *testing.T) {
+	testCases := []struct {
+		key   string
+		valid bool
+	}{
+		{key: "egress:room:{room}", valid: true},
+		{key: "myapp:{room}:*", valid: true},
+		{key: "myapp:*:{room}:state", valid: true},
+		{key: "myapp:{room}*", valid: false},
+		{key: "myapp:*{room}", valid: false},
+		{key: "{room}:state", valid: false},
+		{key: "*:{room}", valid: false},
+		{key: "myapp:*", valid: false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.key, func(t *testing.T) {
+			err := ValidatePurgeStoreKey(tc.key)
+			if tc.valid {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestConfig_Listeners(t *testing.T) {
+	testCases := []struct {
+		name    string
+		content string
+		valid   bool
+	}{
+		{
+			name: "valid",
+			content: `listeners:
+  - address: 127.0.0.1:7880
+    roles: [signal, health]
+  - address: 10.0.0.1:9000
+    roles: [api, metrics]`,
+			valid: true,
+		},
+		{
+			name: "no port",
+			content: `listeners:
+  - address: 127.0.0.1
+    roles: [signal]`,
+		},
+		{
+			name: "no roles",
+			content: `listeners:
+  - address: 127.0.0.1:7880`,
+		},
+		{
+			name: "invalid role",
+			content: `listeners:
+  - address: 127.0.0.1:7880
+    roles: [turn]`,
+		},
+		{
+			name: "tls without certificates",
+			content: `listeners:
+  - address: 127.0.0.1:7880
+    roles: [signal]
+    tls: true`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			conf, err := NewConfig(tc.content, true, nil, nil)
+			if !tc.valid {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, conf.Listeners, 2)
+			require.Equal(t, []ListenerRole{ListenerRoleAPI, ListenerRoleMetrics}, conf.Listeners[1].Roles)
+		})
+	}
+}
+
+func TestConfig_DrainDeregister(t *testing.T) {
+	testCases := []struct {
+		name    string
+		content string
+		valid   bool
+	}{
+		{
+			name: "defaults",
+			content: `drain:
+  consul:
+    service_id: livekit-1
+  deregister:
+    - url: https://kubernetes.default.svc/api/v1/namespaces/livekit/pods/livekit-0`,
+			valid: true,
+		},
+		{
+			name: "no url",
+			content: `drain:
+  deregister:
+    - method: PATCH`,
+		},
+		{
+			name: "invalid scheme",
+			content: `drain:
+  deregister:
+    - url: ftp://lb.local/deregister`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			conf, err := NewConfig(tc.content, true, nil, nil)
+			if !tc.valid {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, "http://127.0.0.1:8500", conf.Drain.Consul.Address)
+			require.Equal(t, "POST", conf.Drain.Deregister[0].Method)
+			require.Equal(t, 5*time.Second, conf.Drain.DeregisterTimeout)
+		})
+	}
+}
+
+func TestConfig_Heartbeat(t *testing.T) {
+	testCases := []struct {
+		name    string
+		content string
+		valid   bool
+	}{
+		{name: "defaults", valid: true},
+		{
+			name: "no pong timeout",
+			content: `heartbeat:
+  ping_interval: 5s
+  pong_timeout: 0s`,
+			valid: true,
+		},
+		{
+			name: "no ping interval",
+			content: `heartbeat:
+  ping_interval: 0s`,
+		},
+		{
+			name: "pong timeout shorter than ping interval",
+			content: `heartbeat:
+  ping_interval: 10s
+  pong_timeout: 5s`,
+		},
+		{
+			name: "client ping interval below a second",
+			content: `heartbeat:
+  client_ping_interval: 500ms`,
+		},
+		{
+			name: "client ping timeout shorter than interval",
+			content: `heartbeat:
+  client_ping_interval: 10s
+  client_ping_timeout: 10s`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := NewConfig(tc.content, true, nil, nil)
+			if tc.valid {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+			}
+		})
+	}
+}
+
 func TestGeneratedFlags(t *testing.T) {
 	generatedFlags, err := GenerateCLIFlags(nil, false)
 	require.NoError(t, err)
@@ -62,6 +288,16 @@ func TestGeneratedFlags(t *testing.T) {
 	set.Bool("rtc.allow_tcp_fallback", true, "")               // pointer
 	set.Bool("rtc.reconnect_on_publication_error", true, "")   // pointer
 	set.Bool("rtc.reconnect_on_subscription_error", false, "") // pointer
+	set.Uint64("rtc.publish_bitrate_limits.camera", 0, "")     // uint64
+	require.NoError(t, set.Parse([]string{
+		"--rtc.use_ice_lite",
+		"--redis.address=localhost:6379",
+		"--prometheus_port=9999",
+		"--rtc.allow_tcp_fallback",
+		"--rtc.reconnect_on_publication_error",
+		"--rtc.reconnect_on_subscription_error=false",
+		"--rtc.publish_bitrate_limits.camera=2000000",
+	}))
 
 	c := cli.NewContext(app, set, nil)
 	conf, err := NewConfig("", true, c, nil)
@@ -70,6 +306,7 @@ func TestGeneratedFlags(t *testing.T) {
 	require.True(t, conf.RTC.UseICELite)
 	require.Equal(t, "localhost:6379", conf.Redis.Address)
 	require.Equal(t, uint32(9999), conf.PrometheusPort)
+	require.Equal(t, uint64(2_000_000), conf.RTC.PublishBitrateLimits.Camera)
 
 	require.NotNil(t, conf.RTC.AllowTCPFallback)
 	require.True(t, *conf.RTC.AllowTCPFallback)
@@ -80,3 +317,91 @@ func TestGeneratedFlags(t *testing.T) {
 	require.NotNil(t, conf.RTC.ReconnectOnSubscriptionError)
 	require.False(t, *conf.RTC.ReconnectOnSubscriptionError)
 }
+
+func TestRTCConfig_CheckICELite(t *testing.T) {
+	conf := RTCConfig{}
+	conf.NodeIP = "10.0.0.1"
+	require.NoError(t, conf.CheckICELite())
+
+	conf.UseICELite = true
+	for _, ip := range []string{"10.0.0.1", "192.168.1.2", "127.0.0.1", "100.64.3.4", "fe80::1", ""} {
+		conf.NodeIP = ip
+		require.ErrorIs(t, conf.CheckICELite(), ErrICELiteNotRoutable, ip)
+	}
+	for _, ip := range []string{"203.0.113.7", "2001:db8::1"} {
+		conf.NodeIP = ip
+		require.NoError(t, conf.CheckICELite(), ip)
+	}
+}
+
+func TestRoomConfig_ResolveTemplate(t *testing.T) {
+	conf := RoomConfig{
+		Templates: map[string]RoomTemplate{
+			"base": {
+				MaxParticipants: 50,
+				EmptyTimeout:    300,
+				WebhookURLs:     []string{"https://hooks.example.com/base"},
+			},
+			"webinar": {
+				Inherits:        "base",
+				MaxParticipants: 500,
+				Recording:       &RoomTemplateRecording{RoomComposite: true, Layout: "speaker"},
+			},
+			"loop-a": {Inherits: "loop-b"},
+			"loop-b": {Inherits: "loop-a"},
+			"orphan": {Inherits: "missing"},
+		},
+	}
+
+	tmpl, err := conf.ResolveTemplate("webinar")
+	require.NoError(t, err)
+	require.Equal(t, "base", tmpl.Inherits)
+	require.Equal(t, uint32(500), tmpl.MaxParticipants)
+	require.Equal(t, uint32(300), tmpl.EmptyTimeout)
+	require.Equal(t, []string{"https://hooks.example.com/base"}, tmpl.WebhookURLs)
+	require.Equal(t, "speaker", tmpl.Recording.Layout)
+
+	// the template itself is left untouched
+	require.Zero(t, conf.Templates["webinar"].EmptyTimeout)
+
+	_, err = conf.ResolveTemplate("loop-a")
+	require.Error(t, err)
+	_, err = conf.ResolveTemplate("orphan")
+	require.Error(t, err)
+	_, err = conf.ResolveTemplate("unknown")
+	require.Error(t, err)
+}
+
+func TestMergeReloadable(t *testing.T) {
+	current, err := NewConfig("", true, nil, nil)
+	require.NoError(t, err)
+	next, err := NewConfig("", true, nil, nil)
+	require.NoError(t, err)
+
+	next.Room.EmptyTimeout = current.Room.EmptyTimeout + 60
+	next.Keys = map[string]string{"key": "secret"}
+	next.Port = current.Port + 1
+
+	merged, reloaded, ignored := mergeReloadable(current, next)
+	require.ElementsMatch(t, []string{"room", "keys"}, reloaded)
+	require.Equal(t, []string{"port"}, ignored)
+	require.Equal(t, next.Room.EmptyTimeout, merged.Room.EmptyTimeout)
+	require.Equal(t, next.Keys, merged.Keys)
+	require.Equal(t, current.Port, merged.Port)
+	// current is left untouched
+	require.NotEqual(t, next.Room.EmptyTimeout, current.Room.EmptyTimeout)
+
+	t.Run("room settings read at startup", func(t *testing.T) {
+		next, err := NewConfig("", true, nil, nil)
+		require.NoError(t, err)
+		next.Room.EmptyTimeout = current.Room.EmptyTimeout + 60
+		next.Room.Templates = map[string]RoomTemplate{"webinar": {}}
+		next.Room.PublicClaims.Claims = []string{"role"}
+
+		merged, reloaded, ignored := mergeReloadable(current, next)
+		require.Equal(t, []string{"room"}, reloaded)
+		require.ElementsMatch(t, []string{"room.templates", "room.public_claims"}, ignored)
+		// still applied to what reads the live config
+		require.Equal(t, next.Room.Templates, merged.Room.Templates)
+	})
+}
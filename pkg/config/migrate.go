@@ -0,0 +1,247 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigChange is a key of a legacy config that MigrateConfig renamed or removed
+type ConfigChange struct {
+	// dotted path of the key in the legacy config
+	Path string
+	// dotted path the value was moved to, empty when it was removed
+	NewPath string
+	Reason  string
+}
+
+func (c ConfigChange) String() string {
+	if c.NewPath != "" {
+		return fmt.Sprintf("renamed %s to %s: %s", c.Path, c.NewPath, c.Reason)
+	}
+	return fmt.Sprintf("removed %s: %s", c.Path, c.Reason)
+}
+
+type configRename struct {
+	from   string
+	to     string
+	reason string
+}
+
+// keys of earlier layouts that moved, applied in order. keys that were dropped don't need an entry, everything
+// the current schema doesn't know is removed
+var configRenames = []configRename{
+	{from: "log_level", to: "logging.level", reason: "log_level is deprecated"},
+}
+
+var (
+	yamlUnmarshalerType         = reflect.TypeOf((*yaml.Unmarshaler)(nil)).Elem()
+	yamlObsoleteUnmarshalerType = reflect.TypeOf((*interface {
+		UnmarshalYAML(unmarshal func(interface{}) error) error
+	})(nil)).Elem()
+)
+
+// MigrateConfig converts a config of an earlier layout to the current schema, keeping comments where possible.
+// It returns the migrated config and the keys that were renamed or removed on the way
+func MigrateConfig(confString string) (string, []ConfigChange, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(confString), &doc); err != nil {
+		return "", nil, fmt.Errorf("could not parse config: %v", err)
+	}
+	if len(doc.Content) == 0 {
+		return confString, nil, nil
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return "", nil, errors.New("config must be a mapping")
+	}
+
+	var changes []ConfigChange
+	for _, rename := range configRenames {
+		value := removeConfigKey(root, strings.Split(rename.from, "."))
+		if value == nil {
+			continue
+		}
+		if !setConfigKey(root, strings.Split(rename.to, "."), value) {
+			changes = append(changes, ConfigChange{
+				Path:   rename.from,
+				Reason: fmt.Sprintf("%s is already set", rename.to),
+			})
+			continue
+		}
+		changes = append(changes, ConfigChange{Path: rename.from, NewPath: rename.to, Reason: rename.reason})
+	}
+	changes = append(changes, pruneUnknownConfigKeys(root, reflect.TypeOf(Config{}), "")...)
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&doc); err != nil {
+		return "", nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return "", nil, err
+	}
+
+	// values of known keys may still be invalid, e.g. of a different type
+	conf := Config{}
+	decoder := yaml.NewDecoder(bytes.NewReader(buf.Bytes()))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&conf); err != nil {
+		return "", changes, fmt.Errorf("migrated config is invalid: %v", err)
+	}
+	return buf.String(), changes, nil
+}
+
+// removeConfigKey removes the key at path and returns its value, nil when it is not set
+func removeConfigKey(node *yaml.Node, path []string) *yaml.Node {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value != path[0] {
+			continue
+		}
+		value := node.Content[i+1]
+		if len(path) > 1 {
+			if value.Kind != yaml.MappingNode {
+				return nil
+			}
+			return removeConfigKey(value, path[1:])
+		}
+		node.Content = append(node.Content[:i], node.Content[i+2:]...)
+		return value
+	}
+	return nil
+}
+
+// setConfigKey sets the key at path, creating its parents. It returns false when the key is already set
+func setConfigKey(node *yaml.Node, path []string, value *yaml.Node) bool {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value != path[0] {
+			continue
+		}
+		if len(path) == 1 {
+			return false
+		}
+		child := node.Content[i+1]
+		if child.Kind != yaml.MappingNode {
+			return false
+		}
+		return setConfigKey(child, path[1:], value)
+	}
+
+	for _, key := range path[:len(path)-1] {
+		child := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, child)
+		node = child
+	}
+	node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: path[len(path)-1]}, value)
+	return true
+}
+
+// pruneUnknownConfigKeys removes the keys of node that typ does not decode
+func pruneUnknownConfigKeys(node *yaml.Node, typ reflect.Type, prefix string) []ConfigChange {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if reflect.PtrTo(typ).Implements(yamlUnmarshalerType) || reflect.PtrTo(typ).Implements(yamlObsoleteUnmarshalerType) {
+		return nil
+	}
+
+	var changes []ConfigChange
+	switch {
+	case typ.Kind() == reflect.Struct && node.Kind == yaml.MappingNode:
+		fields := yamlFields(typ)
+		content := node.Content[:0]
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			path := key.Value
+			if prefix != "" {
+				path = prefix + "." + key.Value
+			}
+			fieldType, ok := fields[key.Value]
+			if !ok && key.Value != "<<" {
+				changes = append(changes, ConfigChange{Path: path, Reason: "not part of the current schema"})
+				continue
+			}
+			if ok {
+				changes = append(changes, pruneUnknownConfigKeys(value, fieldType, path)...)
+			}
+			content = append(content, key, value)
+		}
+		node.Content = content
+
+	case typ.Kind() == reflect.Map && typ.Key().Kind() == reflect.String && node.Kind == yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			path := node.Content[i].Value
+			if prefix != "" {
+				path = prefix + "." + path
+			}
+			changes = append(changes, pruneUnknownConfigKeys(node.Content[i+1], typ.Elem(), path)...)
+		}
+
+	case typ.Kind() == reflect.Slice && node.Kind == yaml.SequenceNode:
+		for i, item := range node.Content {
+			changes = append(changes, pruneUnknownConfigKeys(item, typ.Elem(), fmt.Sprintf("%s[%d]", prefix, i))...)
+		}
+	}
+	return changes
+}
+
+// yamlFields returns the types of the fields of a struct by yaml key, including inlined structs
+func yamlFields(typ reflect.Type) map[string]reflect.Type {
+	fields := map[string]reflect.Type{}
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+		tag := strings.Split(field.Tag.Get("yaml"), ",")
+		if tag[0] == "-" {
+			continue
+		}
+		inline := false
+		for _, flag := range tag[1:] {
+			if flag == "inline" {
+				inline = true
+			}
+		}
+		if inline {
+			fieldType := field.Type
+			for fieldType.Kind() == reflect.Ptr {
+				fieldType = fieldType.Elem()
+			}
+			if fieldType.Kind() == reflect.Struct {
+				for name, t := range yamlFields(fieldType) {
+					fields[name] = t
+				}
+			}
+			continue
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+		name := tag[0]
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		fields[name] = field.Type
+	}
+	return fields
+}
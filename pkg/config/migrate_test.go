@@ -0,0 +1,85 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrateConfig(t *testing.T) {
+	t.Run("renames and removes legacy keys", func(t *testing.T) {
+		const content = `log_level: debug
+port: 7880
+room:
+  empty_timeout: 10
+  legacy_setting: true
+campus:
+  # shared with the portal
+  secret: s3cret
+  portal_url: https://portal.example.com
+  roles:
+    viewer:
+      room_join: true
+      can_chat: true
+`
+		migrated, changes, err := MigrateConfig(content)
+		require.NoError(t, err)
+		require.Equal(t, []ConfigChange{
+			{Path: "log_level", NewPath: "logging.level", Reason: "log_level is deprecated"},
+			{Path: "room.legacy_setting", Reason: "not part of the current schema"},
+			{Path: "campus.portal_url", Reason: "not part of the current schema"},
+			{Path: "campus.roles.viewer.can_chat", Reason: "not part of the current schema"},
+		}, changes)
+		require.Contains(t, migrated, "# shared with the portal")
+
+		conf, err := NewConfig(migrated, true, nil, nil)
+		require.NoError(t, err)
+		require.Equal(t, "debug", conf.Logging.Level)
+		require.Equal(t, uint32(10), conf.Room.EmptyTimeout)
+		require.Equal(t, "s3cret", conf.Campus.Secret)
+		require.True(t, conf.Campus.Roles["viewer"].RoomJoin)
+	})
+
+	t.Run("keeps the current key", func(t *testing.T) {
+		const content = `log_level: debug
+logging:
+  level: warn
+`
+		migrated, changes, err := MigrateConfig(content)
+		require.NoError(t, err)
+		require.Equal(t, []ConfigChange{{Path: "log_level", Reason: "logging.level is already set"}}, changes)
+
+		conf, err := NewConfig(migrated, true, nil, nil)
+		require.NoError(t, err)
+		require.Equal(t, "warn", conf.Logging.Level)
+	})
+
+	t.Run("current config is unchanged", func(t *testing.T) {
+		const content = `port: 7880
+rtc:
+  udp_port: 7882
+`
+		_, changes, err := MigrateConfig(content)
+		require.NoError(t, err)
+		require.Empty(t, changes)
+	})
+
+	t.Run("invalid values", func(t *testing.T) {
+		_, _, err := MigrateConfig("port: [7880]\n")
+		require.Error(t, err)
+	})
+}
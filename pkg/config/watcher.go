@@ -0,0 +1,215 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"os"
+	"os/signal"
+	"reflect"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/livekit/protocol/logger"
+)
+
+const configWatchInterval = 5 * time.Second
+
+// settings applied at runtime when the config file changes, changes to all other settings need a restart
+var reloadableSettings = map[string]bool{
+	"logging":   true,
+	"log_level": true,
+	"keys":      true,
+	"key_file":  true,
+	"room":      true,
+	"webhook":   true,
+	"limit":     true,
+}
+
+// room settings read once at startup, the rest of the room section is applied to rooms created after a reload
+var restartRoomSettings = map[string]bool{
+	"templates":     true,
+	"public_claims": true,
+}
+
+// Watcher reloads the config file when it's modified or the process receives SIGHUP, and passes the
+// reloadable settings on to listeners. Changed settings that can't be reloaded are logged and ignored.
+type Watcher struct {
+	path string
+	load func() (*Config, error)
+
+	lock      sync.Mutex
+	current   *Config
+	modTime   time.Time
+	listeners []func(conf *Config)
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewWatcher watches the config file at path, conf being the config currently in use. load parses the
+// file the same way conf was created
+func NewWatcher(conf *Config, path string, load func() (*Config, error)) *Watcher {
+	w := &Watcher{
+		path:    path,
+		load:    load,
+		current: conf,
+		done:    make(chan struct{}),
+	}
+	if st, err := os.Stat(path); err == nil {
+		w.modTime = st.ModTime()
+	}
+	return w
+}
+
+// OnReload registers f to be called with the reloaded config. Only reloadable settings differ from the
+// config in use, others keep their current values
+func (w *Watcher) OnReload(f func(conf *Config)) {
+	w.lock.Lock()
+	w.listeners = append(w.listeners, f)
+	w.lock.Unlock()
+}
+
+func (w *Watcher) Start() {
+	go w.watch()
+}
+
+func (w *Watcher) Stop() {
+	w.closeOnce.Do(func() {
+		close(w.done)
+	})
+}
+
+func (w *Watcher) watch() {
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGHUP)
+	defer signal.Stop(sigChan)
+
+	ticker := time.NewTicker(configWatchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-sigChan:
+			logger.Infow("reloading config on SIGHUP", "path", w.path)
+			w.reload()
+		case <-ticker.C:
+			if w.isModified() {
+				logger.Infow("config file changed, reloading", "path", w.path)
+				w.reload()
+			}
+		}
+	}
+}
+
+func (w *Watcher) isModified() bool {
+	st, err := os.Stat(w.path)
+	if err != nil {
+		return false
+	}
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if st.ModTime().Equal(w.modTime) {
+		return false
+	}
+	w.modTime = st.ModTime()
+	return true
+}
+
+func (w *Watcher) reload() {
+	if err := w.Reload(); err != nil {
+		logger.Errorw("could not reload config, keeping the current one", err, "path", w.path)
+	}
+}
+
+// Reload reads the config file and applies the reloadable settings that changed
+func (w *Watcher) Reload() error {
+	next, err := w.load()
+	if err != nil {
+		return err
+	}
+
+	w.lock.Lock()
+	merged, reloaded, ignored := mergeReloadable(w.current, next)
+	if len(reloaded) != 0 {
+		w.current = merged
+	}
+	listeners := w.listeners
+	w.lock.Unlock()
+
+	for _, setting := range ignored {
+		logger.Warnw("config setting changed but can't be reloaded, restart to apply it", nil, "setting", setting)
+	}
+	if len(reloaded) == 0 {
+		return nil
+	}
+
+	logger.Infow("applying reloaded config", "settings", reloaded)
+	for _, f := range listeners {
+		f(merged)
+	}
+	return nil
+}
+
+// mergeReloadable returns a copy of current with the reloadable settings taken from next, along with the
+// names of changed settings that were reloaded and that were ignored
+func mergeReloadable(current, next *Config) (*Config, []string, []string) {
+	merged := &Config{}
+	var reloaded, ignored []string
+
+	// copied field by field, Config holds locks that must not be copied as a whole
+	mv := reflect.ValueOf(merged).Elem()
+	cv := reflect.ValueOf(current).Elem()
+	nv := reflect.ValueOf(next).Elem()
+	for i := 0; i < mv.NumField(); i++ {
+		mv.Field(i).Set(cv.Field(i))
+		if reflect.DeepEqual(cv.Field(i).Interface(), nv.Field(i).Interface()) {
+			continue
+		}
+
+		name := strings.Split(mv.Type().Field(i).Tag.Get("yaml"), ",")[0]
+		if !reloadableSettings[name] {
+			ignored = append(ignored, name)
+			continue
+		}
+		mv.Field(i).Set(nv.Field(i))
+		reloaded = append(reloaded, name)
+
+		if name == "room" {
+			for _, field := range changedFields(cv.Field(i), nv.Field(i)) {
+				if restartRoomSettings[field] {
+					ignored = append(ignored, name+"."+field)
+				}
+			}
+		}
+	}
+	return merged, reloaded, ignored
+}
+
+// changedFields returns the yaml names of the fields that differ between two structs of the same type
+func changedFields(a, b reflect.Value) []string {
+	var changed []string
+	for i := 0; i < a.NumField(); i++ {
+		if reflect.DeepEqual(a.Field(i).Interface(), b.Field(i).Interface()) {
+			continue
+		}
+		changed = append(changed, strings.Split(a.Type().Field(i).Tag.Get("yaml"), ",")[0])
+	}
+	return changed
+}
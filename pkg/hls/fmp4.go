@@ -0,0 +1,190 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hls
+
+import (
+	"encoding/binary"
+)
+
+// sample is a complete access unit or audio frame
+type sample struct {
+	data     []byte
+	duration uint32
+	keyFrame bool
+}
+
+// trackConfig describes the single track of a rendition's init segment
+type trackConfig struct {
+	video     bool
+	timescale uint32
+	width     uint16
+	height    uint16
+	sps       []byte
+	pps       []byte
+	channels  uint8
+}
+
+func mp4Box(typ string, payload ...[]byte) []byte {
+	size := 8
+	for _, p := range payload {
+		size += len(p)
+	}
+	out := make([]byte, 8, size)
+	binary.BigEndian.PutUint32(out, uint32(size))
+	copy(out[4:], typ)
+	for _, p := range payload {
+		out = append(out, p...)
+	}
+	return out
+}
+
+func mp4FullBox(typ string, version uint8, flags uint32, payload ...[]byte) []byte {
+	header := []byte{version, byte(flags >> 16), byte(flags >> 8), byte(flags)}
+	return mp4Box(typ, append([][]byte{header}, payload...)...)
+}
+
+func u16(v uint16) []byte {
+	return binary.BigEndian.AppendUint16(nil, v)
+}
+
+func u32(v uint32) []byte {
+	return binary.BigEndian.AppendUint32(nil, v)
+}
+
+func u64(v uint64) []byte {
+	return binary.BigEndian.AppendUint64(nil, v)
+}
+
+var unityMatrix = []byte{
+	0x00, 0x01, 0x00, 0x00, 0, 0, 0, 0, 0, 0, 0, 0,
+	0, 0, 0, 0, 0x00, 0x01, 0x00, 0x00, 0, 0, 0, 0,
+	0, 0, 0, 0, 0, 0, 0, 0, 0x40, 0x00, 0x00, 0x00,
+}
+
+// initSegment returns the fMP4 initialization section, ftyp and moov, of a single track file
+func initSegment(c trackConfig) []byte {
+	ftyp := mp4Box("ftyp", []byte("iso6"), u32(0), []byte("iso6"), []byte("iso5"), []byte("mp41"))
+
+	mvhd := mp4FullBox("mvhd", 0, 0,
+		u32(0), u32(0), // creation and modification time
+		u32(1000), u32(0), // timescale and duration
+		u32(0x00010000), u16(0x0100), make([]byte, 10), // rate, volume, reserved
+		unityMatrix,
+		make([]byte, 24), // pre_defined
+		u32(2),           // next track ID
+	)
+
+	var volume uint16
+	if !c.video {
+		volume = 0x0100
+	}
+	tkhd := mp4FullBox("tkhd", 0, 3,
+		u32(0), u32(0), // creation and modification time
+		u32(1), u32(0), u32(0), // track ID, reserved, duration
+		make([]byte, 8), u16(0), u16(0), u16(volume), u16(0), // reserved, layer, group, volume, reserved
+		unityMatrix,
+		u32(uint32(c.width)<<16), u32(uint32(c.height)<<16),
+	)
+
+	mdhd := mp4FullBox("mdhd", 0, 0,
+		u32(0), u32(0), u32(c.timescale), u32(0),
+		u16(0x55c4), u16(0), // und
+	)
+	handler, name, mediaHeader := "soun", "SoundHandler", mp4FullBox("smhd", 0, 0, u16(0), u16(0))
+	if c.video {
+		handler, name, mediaHeader = "vide", "VideoHandler", mp4FullBox("vmhd", 0, 1, u16(0), make([]byte, 6))
+	}
+	hdlr := mp4FullBox("hdlr", 0, 0, u32(0), []byte(handler), make([]byte, 12), []byte(name), []byte{0})
+	dinf := mp4Box("dinf", mp4FullBox("dref", 0, 0, u32(1), mp4FullBox("url ", 0, 1)))
+	stbl := mp4Box("stbl",
+		mp4FullBox("stsd", 0, 0, u32(1), sampleEntry(c)),
+		mp4FullBox("stts", 0, 0, u32(0)),
+		mp4FullBox("stsc", 0, 0, u32(0)),
+		mp4FullBox("stsz", 0, 0, u32(0), u32(0)),
+		mp4FullBox("stco", 0, 0, u32(0)),
+	)
+	trak := mp4Box("trak", tkhd, mp4Box("mdia", mdhd, hdlr, mp4Box("minf", mediaHeader, dinf, stbl)))
+	mvex := mp4Box("mvex", mp4FullBox("trex", 0, 0, u32(1), u32(1), u32(0), u32(0), u32(0)))
+
+	return append(ftyp, mp4Box("moov", mvhd, trak, mvex)...)
+}
+
+func sampleEntry(c trackConfig) []byte {
+	if c.video {
+		avcC := mp4Box("avcC",
+			[]byte{1, c.sps[1], c.sps[2], c.sps[3], 0xff, 0xe1}, // 4 byte NAL lengths, one SPS
+			u16(uint16(len(c.sps))), c.sps,
+			[]byte{1}, u16(uint16(len(c.pps))), c.pps,
+		)
+		return mp4Box("avc1",
+			make([]byte, 6), u16(1), // reserved, data reference index
+			make([]byte, 16), // pre_defined and reserved
+			u16(c.width), u16(c.height),
+			u32(0x00480000), u32(0x00480000), u32(0), u16(1), // 72 dpi, reserved, frame count
+			make([]byte, 32), u16(0x0018), u16(0xffff), // compressor name, depth, pre_defined
+			avcC,
+		)
+	}
+
+	dOps := mp4Box("dOps",
+		[]byte{0, c.channels}, u16(3840), u32(48000), // version, channels, pre-skip, input sample rate
+		u16(0), []byte{0}, // output gain, channel mapping family
+	)
+	return mp4Box("Opus",
+		make([]byte, 6), u16(1), // reserved, data reference index
+		make([]byte, 8), u16(uint16(c.channels)), u16(16), u16(0), u16(0),
+		u32(48000<<16),
+		dOps,
+	)
+}
+
+// fragment returns a moof and mdat pair holding the samples
+func fragment(sequence uint32, baseDecodeTime uint64, samples []sample) []byte {
+	entries := make([]byte, 0, 12*len(samples))
+	size := 0
+	for _, s := range samples {
+		// non key frames depend on others and are not sync samples
+		flags := uint32(0x01010000)
+		if s.keyFrame {
+			flags = 0x02000000
+		}
+		entries = append(entries, u32(s.duration)...)
+		entries = append(entries, u32(uint32(len(s.data)))...)
+		entries = append(entries, u32(flags)...)
+		size += len(s.data)
+	}
+
+	// data offset, duration, size and flags present
+	trun := mp4FullBox("trun", 0, 0x000701, u32(uint32(len(samples))), u32(0), entries)
+	moof := mp4Box("moof",
+		mp4FullBox("mfhd", 0, 0, u32(sequence)),
+		mp4Box("traf",
+			// offsets are relative to the moof
+			mp4FullBox("tfhd", 0, 0x020000, u32(1)),
+			mp4FullBox("tfdt", 1, 0, u64(baseDecodeTime)),
+			trun,
+		),
+	)
+	// trun ends the moof, its data offset follows the box header, flags and sample count and points
+	// past the mdat header
+	dataOffsetAt := len(moof) - len(trun) + 16
+	binary.BigEndian.PutUint32(moof[dataOffsetAt:], uint32(len(moof)+8))
+
+	mdat := make([]byte, 0, size)
+	for _, s := range samples {
+		mdat = append(mdat, s.data...)
+	}
+	return append(moof, mp4Box("mdat", mdat)...)
+}
@@ -0,0 +1,79 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hls
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+var errInvalidAccessUnit = errors.New("invalid H.264 access unit")
+
+const (
+	naluTypeIDR = 5
+	naluTypeSPS = 7
+	naluTypePPS = 8
+	naluTypeAUD = 9
+)
+
+// avcAccessUnit is a depacketized access unit, parameter sets are moved out of band into avcC
+type avcAccessUnit struct {
+	data     []byte
+	sps      []byte
+	pps      []byte
+	keyFrame bool
+}
+
+// parseAVC splits an access unit of 4 byte length prefixed NAL units
+func parseAVC(data []byte) (*avcAccessUnit, error) {
+	au := &avcAccessUnit{data: make([]byte, 0, len(data))}
+	for len(data) > 0 {
+		if len(data) < 4 {
+			return nil, errInvalidAccessUnit
+		}
+		size := int(binary.BigEndian.Uint32(data))
+		if size == 0 || size > len(data)-4 {
+			return nil, errInvalidAccessUnit
+		}
+		nalu := data[4 : 4+size]
+		data = data[4+size:]
+
+		switch nalu[0] & 0x1f {
+		case naluTypeSPS:
+			au.sps = nalu
+			continue
+		case naluTypePPS:
+			au.pps = nalu
+			continue
+		case naluTypeAUD:
+			continue
+		case naluTypeIDR:
+			au.keyFrame = true
+		}
+		au.data = binary.BigEndian.AppendUint32(au.data, uint32(size))
+		au.data = append(au.data, nalu...)
+	}
+	return au, nil
+}
+
+// avcCodecString returns the RFC 6381 codec of a stream, e.g. avc1.42e01f
+func avcCodecString(sps []byte) string {
+	if len(sps) < 4 {
+		// constrained baseline 3.1
+		return "avc1.42e01f"
+	}
+	return fmt.Sprintf("avc1.%02x%02x%02x", sps[1], sps[2], sps[3])
+}
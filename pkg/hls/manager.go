@@ -0,0 +1,177 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hls
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"sort"
+	"sync"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/storage"
+	"github.com/livekit/protocol/livekit"
+)
+
+// PathPrefix is where playlists are served, /hls/<room>/master.m3u8
+const PathPrefix = "/hls"
+
+var (
+	ErrNotPackaging        = errors.New("room is not packaged to HLS")
+	ErrAlreadyPackaging    = errors.New("room is already packaged to HLS")
+	ErrRenditionNotFound   = errors.New("rendition not found")
+	ErrPlaylistTooFarAhead = errors.New("requested segment is too far ahead of the live edge")
+)
+
+var validFilename = regexp.MustCompile(`^[a-zA-Z0-9_-][a-zA-Z0-9._-]*$`)
+
+// Manager packages rooms hosted on this node into HLS
+type Manager struct {
+	conf    config.HLSConfig
+	storage *storage.Storage
+
+	lock     sync.RWMutex
+	sessions map[livekit.RoomName]*session
+}
+
+// NewManager returns nil when HLS is disabled
+func NewManager(conf *config.Config, store *storage.Storage) *Manager {
+	if !conf.HLS.Enabled {
+		return nil
+	}
+	return &Manager{
+		conf:     conf.HLS,
+		storage:  store,
+		sessions: make(map[livekit.RoomName]*session),
+	}
+}
+
+// Start packages the tracks of a participant, or of the first participant publishing when
+// identity is empty
+func (m *Manager) Start(room Room, identity livekit.ParticipantIdentity) (*Info, error) {
+	m.lock.Lock()
+	if _, ok := m.sessions[room.Name()]; ok {
+		m.lock.Unlock()
+		return nil, ErrAlreadyPackaging
+	}
+	s, err := newSession(m.conf, room, identity, m.storage)
+	if err != nil {
+		m.lock.Unlock()
+		return nil, err
+	}
+	s.onStopped = m.onSessionStopped
+	m.sessions[room.Name()] = s
+	m.lock.Unlock()
+
+	s.start()
+	return s.info(), nil
+}
+
+func (m *Manager) Stop(roomName livekit.RoomName) (*Info, error) {
+	s := m.getSession(roomName)
+	if s == nil {
+		return nil, ErrNotPackaging
+	}
+	s.stop()
+	return s.info(), nil
+}
+
+func (m *Manager) List() []*Info {
+	m.lock.RLock()
+	infos := make([]*Info, 0, len(m.sessions))
+	for _, s := range m.sessions {
+		infos = append(infos, s.info())
+	}
+	m.lock.RUnlock()
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].StartedAt > infos[j].StartedAt
+	})
+	return infos
+}
+
+func (m *Manager) MasterPlaylist(roomName livekit.RoomName, query string) (string, error) {
+	s := m.getSession(roomName)
+	if s == nil {
+		return "", ErrNotPackaging
+	}
+	return s.MasterPlaylist(query), nil
+}
+
+// MediaPlaylist returns the playlist of a rendition, see rendition.Playlist for blocking requests
+func (m *Manager) MediaPlaylist(
+	ctx context.Context,
+	roomName livekit.RoomName,
+	name string,
+	msn int64,
+	part int64,
+	lowLatency bool,
+	query string,
+) (string, error) {
+	s := m.getSession(roomName)
+	if s == nil {
+		return "", ErrNotPackaging
+	}
+	r := s.Rendition(name)
+	if r == nil {
+		return "", ErrRenditionNotFound
+	}
+	return r.Playlist(ctx, msn, part, lowLatency, query)
+}
+
+// FilePath returns the local path of a segment, part or init section of a rendition
+func (m *Manager) FilePath(roomName livekit.RoomName, rendition string, filename string) (string, error) {
+	s := m.getSession(roomName)
+	if s == nil {
+		return "", ErrNotPackaging
+	}
+	if s.Rendition(rendition) == nil || !validFilename.MatchString(filename) {
+		return "", ErrRenditionNotFound
+	}
+	return s.FilePath(rendition, filename), nil
+}
+
+// Close stops packaging all rooms
+func (m *Manager) Close() {
+	if m == nil {
+		return
+	}
+
+	m.lock.RLock()
+	sessions := make([]*session, 0, len(m.sessions))
+	for _, s := range m.sessions {
+		sessions = append(sessions, s)
+	}
+	m.lock.RUnlock()
+
+	for _, s := range sessions {
+		s.stop()
+	}
+}
+
+func (m *Manager) getSession(roomName livekit.RoomName) *session {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	return m.sessions[roomName]
+}
+
+func (m *Manager) onSessionStopped(s *session) {
+	m.lock.Lock()
+	if m.sessions[s.room.Name()] == s {
+		delete(m.sessions, s.room.Name())
+	}
+	m.lock.Unlock()
+}
@@ -0,0 +1,558 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hls
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pion/rtp"
+	"github.com/pion/rtp/codecs"
+	"github.com/pion/webrtc/v3/pkg/media/samplebuilder"
+
+	"github.com/livekit/protocol/logger"
+)
+
+const (
+	// packets a sample may arrive late before it is given up on
+	maxLatePackets = 200
+	// parts are listed for this many of the latest segments
+	partSegments = 3
+
+	PlaylistFilename = "index.m3u8"
+)
+
+type renditionParams struct {
+	Name            string
+	Dir             string
+	Video           bool
+	ClockRate       uint32
+	Width           uint16
+	Height          uint16
+	Channels        uint8
+	Bandwidth       int
+	SegmentDuration time.Duration
+	PartDuration    time.Duration
+	PlaylistSize    int
+	// decode times of all renditions of a session start at this time
+	StartedAt time.Time
+	// called with the file names of a completed segment, its init section and the playlist
+	OnSegment func(r *rendition, filenames []string)
+	Logger    logger.Logger
+}
+
+type part struct {
+	uri         string
+	duration    float64
+	independent bool
+}
+
+type segment struct {
+	msn      uint64
+	uri      string
+	duration float64
+	size     int
+	parts    []part
+	// set on the first segment after a codec change or a new track
+	initURI       string
+	discontinuity bool
+	programTime   time.Time
+}
+
+// rendition packages one layer of a track into fMP4 segments and parts of a low latency media playlist
+type rendition struct {
+	params  renditionParams
+	builder *samplebuilder.SampleBuilder
+
+	lock sync.Mutex
+	// current init section, empty until the first key frame
+	initURI       string
+	initCount     int
+	sps           []byte
+	mapPending    bool
+	discontinuity bool
+	// discontinuities that left the playlist
+	discontinuitySequence int
+
+	// pending sample, written once the next sample gives its duration
+	pending           *sample
+	pendingDecodeTime uint64
+	lastTimestamp     uint32
+	decodeTime        uint64
+	timed             bool
+	lastArrival       time.Time
+	// publishers only send key frames on request, requests are repeated when lost
+	keyFrameRequestedAt time.Time
+
+	sequence    uint32
+	msn         uint64
+	segments    []*segment
+	segmentOpen bool
+	segmentHead *segment
+	segmentData []byte
+	segmentFrom uint64
+	partSamples []sample
+	partFrom    uint64
+	parts       []part
+	ended       bool
+	updated     chan struct{}
+}
+
+func newRendition(params renditionParams) (*rendition, error) {
+	if err := os.MkdirAll(filepath.Join(params.Dir, params.Name), 0755); err != nil {
+		return nil, err
+	}
+	r := &rendition{
+		params:  params,
+		updated: make(chan struct{}),
+	}
+	r.resetBuilder()
+	return r, nil
+}
+
+func (r *rendition) resetBuilder() {
+	if r.params.Video {
+		r.builder = samplebuilder.New(maxLatePackets, &codecs.H264Packet{IsAVC: true}, r.params.ClockRate)
+	} else {
+		r.builder = samplebuilder.New(maxLatePackets, &codecs.OpusPacket{}, r.params.ClockRate)
+	}
+}
+
+func (r *rendition) Name() string {
+	return r.params.Name
+}
+
+// Restart continues the rendition with a new track, the next segment starts a discontinuity
+func (r *rendition) Restart() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.flushLocked()
+	r.resetBuilder()
+	r.timed = false
+	r.initURI = ""
+	r.sps = nil
+	r.keyFrameRequestedAt = time.Time{}
+}
+
+// Push adds a packet, returns true when a key frame should be requested from the publisher
+func (r *rendition) Push(packet *rtp.Packet, arrival time.Time) bool {
+	// packets are shared with subscribers and buffered by the sample builder
+	pkt := *packet
+	pkt.Payload = append([]byte(nil), packet.Payload...)
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.ended {
+		return false
+	}
+	r.builder.Push(&pkt)
+
+	requestKeyFrame := false
+	for {
+		s := r.builder.Pop()
+		if s == nil {
+			break
+		}
+		if r.addSampleLocked(s.Data, s.PacketTimestamp, arrival) {
+			requestKeyFrame = true
+		}
+	}
+	return requestKeyFrame
+}
+
+func (r *rendition) addSampleLocked(data []byte, timestamp uint32, arrival time.Time) bool {
+	r.lastArrival = arrival
+	s := sample{data: data, keyFrame: !r.params.Video}
+	if r.params.Video {
+		au, err := parseAVC(data)
+		if err != nil {
+			r.params.Logger.Debugw("dropping invalid access unit", err, "rendition", r.params.Name)
+			return false
+		}
+		s.data, s.keyFrame = au.data, au.keyFrame
+		if s.keyFrame && au.sps != nil && au.pps != nil && string(au.sps) != string(r.sps) {
+			// resolution changes need a new init section, a segment cannot mix init sections
+			r.flushLocked()
+			if err = r.writeInitLocked(au.sps, au.pps); err != nil {
+				r.params.Logger.Warnw("could not write init section", err, "rendition", r.params.Name)
+				return false
+			}
+		}
+		if r.initURI == "" {
+			return r.requestKeyFrameLocked()
+		}
+	} else if r.initURI == "" {
+		if err := r.writeInitLocked(nil, nil); err != nil {
+			r.params.Logger.Warnw("could not write init section", err, "rendition", r.params.Name)
+			return false
+		}
+	}
+	if len(s.data) == 0 {
+		return false
+	}
+
+	if !r.timed {
+		// align renditions on arrival time, later samples follow the RTP clock
+		offset := arrival.Sub(r.params.StartedAt)
+		if offset < 0 {
+			offset = 0
+		}
+		r.decodeTime = uint64(offset.Seconds() * float64(r.params.ClockRate))
+		r.lastTimestamp = timestamp
+		r.timed = true
+	} else if diff := int32(timestamp - r.lastTimestamp); diff > 0 {
+		r.decodeTime += uint64(diff)
+		r.lastTimestamp = timestamp
+	}
+
+	requestKeyFrame := false
+	if r.pending != nil {
+		r.pending.duration = uint32(r.decodeTime - r.pendingDecodeTime)
+		requestKeyFrame = r.writeSampleLocked(*r.pending, r.pendingDecodeTime)
+	}
+	r.pending = &s
+	r.pendingDecodeTime = r.decodeTime
+	return requestKeyFrame
+}
+
+func (r *rendition) requestKeyFrameLocked() bool {
+	if !r.keyFrameRequestedAt.IsZero() && r.lastArrival.Sub(r.keyFrameRequestedAt) < time.Second {
+		return false
+	}
+	r.keyFrameRequestedAt = r.lastArrival
+	return true
+}
+
+func (r *rendition) writeInitLocked(sps []byte, pps []byte) error {
+	conf := trackConfig{
+		video:     r.params.Video,
+		timescale: r.params.ClockRate,
+		width:     r.params.Width,
+		height:    r.params.Height,
+		sps:       sps,
+		pps:       pps,
+		channels:  r.params.Channels,
+	}
+	uri := "init.mp4"
+	if r.initCount > 0 {
+		uri = fmt.Sprintf("init%d.mp4", r.initCount)
+	}
+	if err := os.WriteFile(r.path(uri), initSegment(conf), 0644); err != nil {
+		return err
+	}
+	r.initCount++
+	r.initURI = uri
+	r.sps = sps
+	r.mapPending = true
+	if r.msn > 0 {
+		r.discontinuity = true
+	}
+	return nil
+}
+
+func (r *rendition) writeSampleLocked(s sample, decodeTime uint64) bool {
+	requestKeyFrame := false
+	segmentTicks := uint64(r.params.SegmentDuration.Seconds() * float64(r.params.ClockRate))
+	if r.segmentOpen && decodeTime-r.segmentFrom >= segmentTicks {
+		if s.keyFrame {
+			r.closeSegmentLocked(decodeTime)
+		} else {
+			requestKeyFrame = r.requestKeyFrameLocked()
+		}
+	}
+
+	partTicks := uint64(r.params.PartDuration.Seconds() * float64(r.params.ClockRate))
+	if len(r.partSamples) > 0 && decodeTime+uint64(s.duration)-r.partFrom > partTicks {
+		r.closePartLocked()
+	}
+
+	if !r.segmentOpen {
+		r.segmentOpen = true
+		r.segmentFrom = decodeTime
+		r.segmentHead = &segment{
+			msn:           r.msn,
+			uri:           fmt.Sprintf("%d.m4s", r.msn),
+			discontinuity: r.discontinuity,
+			programTime:   r.params.StartedAt.Add(time.Duration(float64(decodeTime) / float64(r.params.ClockRate) * float64(time.Second))),
+		}
+		if r.mapPending {
+			r.segmentHead.initURI = r.initURI
+			r.mapPending = false
+		}
+		r.discontinuity = false
+		r.keyFrameRequestedAt = time.Time{}
+	}
+	if len(r.partSamples) == 0 {
+		r.partFrom = decodeTime
+	}
+	r.partSamples = append(r.partSamples, s)
+	return requestKeyFrame
+}
+
+func (r *rendition) closePartLocked() {
+	if len(r.partSamples) == 0 {
+		return
+	}
+
+	data := fragment(r.sequence, r.partFrom, r.partSamples)
+	r.sequence++
+	var ticks uint64
+	for _, s := range r.partSamples {
+		ticks += uint64(s.duration)
+	}
+	p := part{
+		uri:         fmt.Sprintf("%d.%d.m4s", r.msn, len(r.parts)),
+		duration:    float64(ticks) / float64(r.params.ClockRate),
+		independent: r.partSamples[0].keyFrame,
+	}
+	r.partSamples = nil
+	if err := os.WriteFile(r.path(p.uri), data, 0644); err != nil {
+		r.params.Logger.Warnw("could not write part", err, "rendition", r.params.Name, "part", p.uri)
+	}
+	r.segmentData = append(r.segmentData, data...)
+	r.parts = append(r.parts, p)
+	r.notifyLocked()
+}
+
+func (r *rendition) closeSegmentLocked(endDecodeTime uint64) {
+	r.closePartLocked()
+	if !r.segmentOpen {
+		return
+	}
+
+	seg := r.segmentHead
+	seg.duration = float64(endDecodeTime-r.segmentFrom) / float64(r.params.ClockRate)
+	seg.size = len(r.segmentData)
+	seg.parts = r.parts
+	if err := os.WriteFile(r.path(seg.uri), r.segmentData, 0644); err != nil {
+		r.params.Logger.Warnw("could not write segment", err, "rendition", r.params.Name, "segment", seg.uri)
+	}
+	r.segmentOpen = false
+	r.segmentHead = nil
+	r.segmentData = nil
+	r.parts = nil
+	r.msn++
+
+	r.segments = append(r.segments, seg)
+	if len(r.segments) > partSegments {
+		// parts of older segments are no longer listed
+		old := r.segments[len(r.segments)-partSegments-1]
+		for _, p := range old.parts {
+			_ = os.Remove(r.path(p.uri))
+		}
+		old.parts = nil
+	}
+	if len(r.segments) > r.params.PlaylistSize {
+		removed := r.segments[0]
+		r.segments = r.segments[1:]
+		if removed.discontinuity {
+			r.discontinuitySequence++
+		}
+		if removed.initURI != "" && r.segments[0].initURI == "" {
+			// the init section is still needed by the following segments
+			r.segments[0].initURI = removed.initURI
+		}
+		_ = os.Remove(r.path(removed.uri))
+	}
+
+	if err := os.WriteFile(r.path(PlaylistFilename), []byte(r.playlistLocked(false, "")), 0644); err != nil {
+		r.params.Logger.Warnw("could not write playlist", err, "rendition", r.params.Name)
+	}
+	r.notifyLocked()
+	if r.params.OnSegment != nil {
+		filenames := []string{seg.uri, PlaylistFilename}
+		if seg.initURI != "" {
+			filenames = append([]string{seg.initURI}, filenames...)
+		}
+		r.params.OnSegment(r, filenames)
+	}
+}
+
+// flushLocked writes out the pending sample and closes the open segment
+func (r *rendition) flushLocked() {
+	if r.pending != nil {
+		// the last frame lasts as long as the one before
+		duration := uint32(r.params.ClockRate / 50)
+		if r.params.Video {
+			duration = r.params.ClockRate / 30
+		}
+		r.pending.duration = duration
+		r.writeSampleLocked(*r.pending, r.pendingDecodeTime)
+		r.closeSegmentLocked(r.pendingDecodeTime + uint64(duration))
+		r.pending = nil
+	}
+}
+
+// End completes the playlist
+func (r *rendition) End() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.ended {
+		return
+	}
+	r.flushLocked()
+	r.ended = true
+	if err := os.WriteFile(r.path(PlaylistFilename), []byte(r.playlistLocked(false, "")), 0644); err != nil {
+		r.params.Logger.Warnw("could not write playlist", err, "rendition", r.params.Name)
+	}
+	r.notifyLocked()
+}
+
+func (r *rendition) notifyLocked() {
+	close(r.updated)
+	r.updated = make(chan struct{})
+}
+
+func (r *rendition) path(filename string) string {
+	return filepath.Join(r.params.Dir, r.params.Name, filename)
+}
+
+// Bandwidth returns the peak bitrate of the listed segments, or the expected bitrate until
+// segments are available
+func (r *rendition) Bandwidth() int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	peak := 0
+	for _, seg := range r.segments {
+		if seg.duration > 0 {
+			if bw := int(float64(seg.size*8) / seg.duration); bw > peak {
+				peak = bw
+			}
+		}
+	}
+	if peak == 0 {
+		return r.params.Bandwidth
+	}
+	return peak
+}
+
+// Codec returns the RFC 6381 codec of the rendition
+func (r *rendition) Codec() string {
+	if !r.params.Video {
+		return "opus"
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return avcCodecString(r.sps)
+}
+
+// Playlist returns the media playlist. It blocks until the requested segment or part is available
+// when msn is not negative, as long as the context allows. Parts are omitted for regular clients.
+func (r *rendition) Playlist(ctx context.Context, msn int64, partIndex int64, lowLatency bool, query string) (string, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if msn >= 0 {
+		// blocking requests may only reach two segments ahead
+		if uint64(msn) > r.msn+2 {
+			return "", ErrPlaylistTooFarAhead
+		}
+		for !r.hasLocked(uint64(msn), partIndex) {
+			updated := r.updated
+			r.lock.Unlock()
+			select {
+			case <-updated:
+			case <-ctx.Done():
+				r.lock.Lock()
+				return "", ctx.Err()
+			}
+			r.lock.Lock()
+		}
+	}
+	return r.playlistLocked(lowLatency, query), nil
+}
+
+func (r *rendition) hasLocked(msn uint64, partIndex int64) bool {
+	if r.ended || msn < r.msn {
+		return true
+	}
+	return partIndex >= 0 && msn == r.msn && partIndex < int64(len(r.parts))
+}
+
+func (r *rendition) playlistLocked(lowLatency bool, query string) string {
+	if query != "" {
+		query = "?" + query
+	}
+
+	target := r.params.SegmentDuration.Seconds()
+	for _, seg := range r.segments {
+		target = math.Max(target, seg.duration)
+	}
+	partTarget := r.params.PartDuration.Seconds()
+
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n")
+	fmt.Fprintf(&b, "#EXT-X-VERSION:%d\n", 9)
+	fmt.Fprintf(&b, "#EXT-X-TARGETDURATION:%d\n", int(math.Ceil(target)))
+	var first uint64
+	if len(r.segments) > 0 {
+		first = r.segments[0].msn
+	} else {
+		first = r.msn
+	}
+	fmt.Fprintf(&b, "#EXT-X-MEDIA-SEQUENCE:%d\n", first)
+	if r.discontinuitySequence > 0 {
+		fmt.Fprintf(&b, "#EXT-X-DISCONTINUITY-SEQUENCE:%d\n", r.discontinuitySequence)
+	}
+	if lowLatency {
+		fmt.Fprintf(&b, "#EXT-X-SERVER-CONTROL:CAN-BLOCK-RELOAD=YES,PART-HOLD-BACK=%.3f\n", 3*partTarget)
+		fmt.Fprintf(&b, "#EXT-X-PART-INF:PART-TARGET=%.3f\n", partTarget)
+	}
+
+	writeParts := func(parts []part) {
+		for _, p := range parts {
+			fmt.Fprintf(&b, "#EXT-X-PART:DURATION=%.5f,URI=\"%s%s\"", p.duration, p.uri, query)
+			if p.independent {
+				b.WriteString(",INDEPENDENT=YES")
+			}
+			b.WriteString("\n")
+		}
+	}
+	writeHead := func(seg *segment) {
+		if seg.discontinuity {
+			b.WriteString("#EXT-X-DISCONTINUITY\n")
+		}
+		if seg.initURI != "" {
+			fmt.Fprintf(&b, "#EXT-X-MAP:URI=\"%s%s\"\n", seg.initURI, query)
+		}
+		fmt.Fprintf(&b, "#EXT-X-PROGRAM-DATE-TIME:%s\n", seg.programTime.UTC().Format("2006-01-02T15:04:05.000Z"))
+	}
+
+	for _, seg := range r.segments {
+		writeHead(seg)
+		if lowLatency {
+			writeParts(seg.parts)
+		}
+		fmt.Fprintf(&b, "#EXTINF:%.5f,\n%s%s\n", seg.duration, seg.uri, query)
+	}
+	if lowLatency && r.segmentOpen {
+		writeHead(r.segmentHead)
+		writeParts(r.parts)
+	}
+	if r.ended {
+		b.WriteString("#EXT-X-ENDLIST\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,134 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hls
+
+import (
+	"context"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/protocol/logger"
+)
+
+func TestFragment(t *testing.T) {
+	samples := []sample{
+		{data: []byte{1, 2, 3}, duration: 3000, keyFrame: true},
+		{data: []byte{4, 5}, duration: 3000},
+	}
+	data := fragment(7, 90000, samples)
+
+	moofSize := binary.BigEndian.Uint32(data)
+	require.Equal(t, "moof", string(data[4:8]))
+	require.Equal(t, "mdat", string(data[moofSize+4:moofSize+8]))
+
+	// trun ends the moof, the data offset points at the first sample
+	trun := strings.LastIndex(string(data[:moofSize]), "trun") - 4
+	dataOffset := binary.BigEndian.Uint32(data[trun+16:])
+	require.Equal(t, []byte{1, 2, 3, 4, 5}, data[dataOffset:])
+}
+
+func TestParseAVC(t *testing.T) {
+	var data []byte
+	for _, nalu := range [][]byte{{0x09, 0xf0}, {0x67, 0x42, 0xe0, 0x1f}, {0x68, 0xce}, {0x65, 0x88, 0x84}} {
+		data = binary.BigEndian.AppendUint32(data, uint32(len(nalu)))
+		data = append(data, nalu...)
+	}
+
+	au, err := parseAVC(data)
+	require.NoError(t, err)
+	require.True(t, au.keyFrame)
+	require.Equal(t, []byte{0x67, 0x42, 0xe0, 0x1f}, au.sps)
+	require.Equal(t, []byte{0x68, 0xce}, au.pps)
+	// parameter sets and delimiters are moved out of the samples
+	require.Equal(t, []byte{0, 0, 0, 3, 0x65, 0x88, 0x84}, au.data)
+	require.Equal(t, "avc1.42e01f", avcCodecString(au.sps))
+
+	_, err = parseAVC(data[:9])
+	require.ErrorIs(t, err, errInvalidAccessUnit)
+}
+
+func TestRendition(t *testing.T) {
+	dir := t.TempDir()
+	startedAt := time.Now()
+	var uploaded []string
+	r, err := newRendition(renditionParams{
+		Name:            audioRendition,
+		Dir:             dir,
+		ClockRate:       48000,
+		Channels:        2,
+		SegmentDuration: time.Second,
+		PartDuration:    200 * time.Millisecond,
+		PlaylistSize:    3,
+		StartedAt:       startedAt,
+		OnSegment: func(_ *rendition, filenames []string) {
+			uploaded = append(uploaded, filenames...)
+		},
+		Logger: logger.GetLogger(),
+	})
+	require.NoError(t, err)
+
+	// 6s of 20ms frames
+	for i := 0; i < 300; i++ {
+		r.Push(&rtp.Packet{
+			Header: rtp.Header{
+				SequenceNumber: uint16(i),
+				Timestamp:      uint32(i * 960),
+			},
+			Payload: []byte{0xfc, byte(i)},
+		}, startedAt.Add(time.Duration(i)*20*time.Millisecond))
+	}
+
+	playlist, err := r.Playlist(context.Background(), -1, -1, true, "access_token=abc")
+	require.NoError(t, err)
+	require.Contains(t, playlist, "#EXT-X-MEDIA-SEQUENCE:2\n")
+	require.Contains(t, playlist, "#EXT-X-MAP:URI=\"init.mp4?access_token=abc\"")
+	require.Contains(t, playlist, "#EXTINF:1.00000,\n4.m4s?access_token=abc\n")
+	require.Contains(t, playlist, "#EXT-X-PART:DURATION=0.20000,URI=\"5.0.m4s?access_token=abc\",INDEPENDENT=YES\n")
+	require.NotContains(t, playlist, "#EXT-X-ENDLIST")
+	require.Equal(t, []string{"init.mp4", "0.m4s", PlaylistFilename}, uploaded[:3])
+
+	// segments leaving the playlist are removed
+	_, err = os.Stat(filepath.Join(dir, audioRendition, "1.m4s"))
+	require.True(t, os.IsNotExist(err))
+	_, err = os.Stat(filepath.Join(dir, audioRendition, "4.m4s"))
+	require.NoError(t, err)
+
+	// blocking requests are limited to two segments ahead of the live edge
+	_, err = r.Playlist(context.Background(), 8, -1, true, "")
+	require.ErrorIs(t, err, ErrPlaylistTooFarAhead)
+
+	// waits for the next part
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = r.Playlist(ctx, 5, 5, true, "")
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	r.End()
+	playlist, err = r.Playlist(context.Background(), 6, 0, true, "")
+	require.NoError(t, err)
+	require.True(t, strings.HasSuffix(playlist, "#EXT-X-ENDLIST\n"))
+	// regular playlists written next to the segments do not list parts
+	data, err := os.ReadFile(filepath.Join(dir, audioRendition, PlaylistFilename))
+	require.NoError(t, err)
+	require.NotContains(t, string(data), "#EXT-X-PART")
+	require.Contains(t, string(data), "#EXT-X-ENDLIST")
+}
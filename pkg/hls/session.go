@@ -0,0 +1,571 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hls
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+	"go.uber.org/atomic"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/rtc/types"
+	"github.com/livekit/livekit-server/pkg/sfu"
+	"github.com/livekit/livekit-server/pkg/sfu/buffer"
+	"github.com/livekit/livekit-server/pkg/storage"
+	"github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/logger"
+)
+
+const (
+	syncInterval     = time.Second
+	MasterFilename   = "master.m3u8"
+	audioRendition   = "audio"
+	defaultBandwidth = 64000
+)
+
+// expected bitrates of video layers that do not advertise one
+var defaultLayerBandwidth = map[livekit.VideoQuality]int{
+	livekit.VideoQuality_LOW:    150000,
+	livekit.VideoQuality_MEDIUM: 500000,
+	livekit.VideoQuality_HIGH:   1500000,
+}
+
+// Room is the part of a room needed by the packager
+type Room interface {
+	Name() livekit.RoomName
+	ID() livekit.RoomID
+	IsClosed() bool
+	GetParticipants() []types.LocalParticipant
+}
+
+// dynacast needs to keep forwarding layers nobody else subscribes to
+type maxQualityNotifier interface {
+	NotifySubscriberNodeMaxQuality(nodeID livekit.NodeID, qualities []types.SubscribedCodecQuality)
+}
+
+// Info describes the HLS output of a room
+type Info struct {
+	RoomName livekit.RoomName `json:"room_name"`
+	RoomID   livekit.RoomID   `json:"room_id"`
+	// participant whose tracks are packaged
+	ParticipantIdentity livekit.ParticipantIdentity `json:"participant_identity,omitempty"`
+	// unix milliseconds
+	StartedAt   int64    `json:"started_at"`
+	PlaylistURL string   `json:"playlist_url"`
+	Renditions  []string `json:"renditions"`
+}
+
+// trackSender is attached to a track receiver in place of a subscriber's DownTrack and feeds
+// the renditions of the track's layers
+type trackSender struct {
+	subscriberID livekit.ParticipantID
+	track        types.MediaTrack
+	receiver     sfu.TrackReceiver
+	isVideo      bool
+	// by spatial layer, nil for layers that are not packaged. Audio uses the first entry
+	renditions []*rendition
+	closed     atomic.Bool
+}
+
+var _ sfu.TrackSender = (*trackSender)(nil)
+
+func (t *trackSender) UpTrackLayersChange()                           {}
+func (t *trackSender) UpTrackBitrateAvailabilityChange()              {}
+func (t *trackSender) UpTrackMaxPublishedLayerChange(_ int32)         {}
+func (t *trackSender) UpTrackMaxTemporalLayerSeenChange(_ int32)      {}
+func (t *trackSender) UpTrackBitrateReport(_ []int32, _ sfu.Bitrates) {}
+func (t *trackSender) TrackInfoAvailable()                            {}
+func (t *trackSender) Resync()                                        {}
+func (t *trackSender) ID() string                                     { return string(t.track.ID()) }
+func (t *trackSender) SubscriberID() livekit.ParticipantID            { return t.subscriberID }
+func (t *trackSender) IsClosed() bool                                 { return t.closed.Load() }
+func (t *trackSender) Close()                                         { t.closed.Store(true) }
+
+func (t *trackSender) HandleRTCPSenderReportData(_ webrtc.PayloadType, _ int32, _ *buffer.RTCPSenderReportData) error {
+	return nil
+}
+
+func (t *trackSender) WriteRTP(p *buffer.ExtPacket, layer int32) error {
+	if t.closed.Load() {
+		return nil
+	}
+	if !t.isVideo {
+		layer = 0
+	}
+	if layer < 0 || int(layer) >= len(t.renditions) || t.renditions[layer] == nil {
+		return nil
+	}
+	if t.renditions[layer].Push(p.Packet, p.Arrival) {
+		t.receiver.SendPLI(layer, true)
+	}
+	return nil
+}
+
+// session packages the tracks of one participant of a room until stopped or the room closes
+type session struct {
+	conf      config.HLSConfig
+	logger    logger.Logger
+	room      Room
+	identity  livekit.ParticipantIdentity
+	dir       string
+	startedAt time.Time
+	storage   *storage.Storage
+
+	lock       sync.Mutex
+	current    livekit.ParticipantIdentity
+	video      *trackSender
+	audio      *trackSender
+	renditions map[string]*rendition
+	stopped    bool
+
+	uploadLock   sync.Mutex
+	uploads      chan string
+	uploadsEnded bool
+
+	onStopped func(s *session)
+	doneChan  chan struct{}
+	doneOnce  sync.Once
+}
+
+func newSession(conf config.HLSConfig, room Room, identity livekit.ParticipantIdentity, store *storage.Storage) (*session, error) {
+	dir := filepath.Join(conf.OutputDir, string(room.ID()))
+	// segments of an earlier session of the room are not reused
+	if err := os.RemoveAll(dir); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	s := &session{
+		conf:       conf,
+		logger:     logger.GetLogger().WithValues("room", room.Name(), "roomID", room.ID()),
+		room:       room,
+		identity:   identity,
+		dir:        dir,
+		startedAt:  time.Now(),
+		storage:    store,
+		renditions: make(map[string]*rendition),
+		doneChan:   make(chan struct{}),
+	}
+	if conf.StorageProfile != "" && store != nil {
+		s.uploads = make(chan string, 256)
+		go s.uploadWorker()
+	}
+	return s, nil
+}
+
+func (s *session) nodeID() livekit.NodeID {
+	return livekit.NodeID("hls_" + string(s.room.ID()))
+}
+
+func (s *session) start() {
+	s.sync()
+	go s.worker()
+}
+
+func (s *session) worker() {
+	ticker := time.NewTicker(syncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.doneChan:
+			return
+		case <-ticker.C:
+			if s.room.IsClosed() {
+				s.stop()
+				return
+			}
+			s.sync()
+		}
+	}
+}
+
+// sync attaches to the tracks of the packaged participant, following it when it republishes
+func (s *session) sync() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.stopped {
+		return
+	}
+	if s.video != nil && s.video.IsClosed() {
+		s.detachLocked(s.video)
+		s.video = nil
+	}
+	if s.audio != nil && s.audio.IsClosed() {
+		s.detachLocked(s.audio)
+		s.audio = nil
+	}
+
+	p := s.participantLocked()
+	if p == nil {
+		return
+	}
+	if p.Identity() != s.current {
+		// the previous participant left
+		s.current = p.Identity()
+		s.logger.Infow("packaging participant", "participant", s.current)
+	}
+	if s.video == nil {
+		if track := findTrack(p, livekit.TrackType_VIDEO, webrtc.MimeTypeH264, livekit.TrackSource_SCREEN_SHARE, livekit.TrackSource_CAMERA); track != nil {
+			s.video = s.attachLocked(track)
+		}
+	}
+	if s.audio == nil {
+		if track := findTrack(p, livekit.TrackType_AUDIO, webrtc.MimeTypeOpus, livekit.TrackSource_MICROPHONE, livekit.TrackSource_SCREEN_SHARE_AUDIO); track != nil {
+			s.audio = s.attachLocked(track)
+		}
+	}
+}
+
+// participantLocked returns the requested participant, or keeps to the first one publishing
+func (s *session) participantLocked() types.LocalParticipant {
+	participants := s.room.GetParticipants()
+	sort.Slice(participants, func(i, j int) bool {
+		return participants[i].Identity() < participants[j].Identity()
+	})
+
+	var first types.LocalParticipant
+	for _, p := range participants {
+		if s.identity != "" && p.Identity() != s.identity {
+			continue
+		}
+		if p.Identity() == s.current {
+			return p
+		}
+		if first == nil && len(p.GetPublishedTracks()) > 0 {
+			first = p
+		}
+	}
+	return first
+}
+
+// findTrack returns a track of the kind and codec, preferring sources in order
+func findTrack(p types.LocalParticipant, kind livekit.TrackType, mimeType string, sources ...livekit.TrackSource) types.MediaTrack {
+	var found types.MediaTrack
+	rank := len(sources)
+	for _, track := range p.GetPublishedTracks() {
+		if track.Kind() != kind || !track.IsOpen() || track.IsEncrypted() {
+			continue
+		}
+		receivers := track.Receivers()
+		if len(receivers) == 0 || !strings.EqualFold(receivers[0].Codec().MimeType, mimeType) {
+			continue
+		}
+		for i, source := range sources {
+			if track.Source() == source && i < rank {
+				found, rank = track, i
+			}
+		}
+	}
+	return found
+}
+
+func (s *session) attachLocked(track types.MediaTrack) *trackSender {
+	receiver := track.Receivers()[0]
+	sender := &trackSender{
+		subscriberID: livekit.ParticipantID(s.nodeID()),
+		track:        track,
+		receiver:     receiver,
+		isVideo:      track.Kind() == livekit.TrackType_VIDEO,
+	}
+
+	if sender.isVideo {
+		info := track.ToProto()
+		for layer := int32(0); layer <= buffer.DefaultMaxLayerSpatial; layer++ {
+			quality := buffer.SpatialLayerToVideoQuality(layer, info, s.logger)
+			name := strings.ToLower(quality.String())
+			if quality == livekit.VideoQuality_OFF || !s.renditionEnabled(name) {
+				sender.renditions = append(sender.renditions, nil)
+				continue
+			}
+			params := s.renditionParams(name, receiver.Codec().ClockRate)
+			params.Video = true
+			params.Bandwidth = defaultLayerBandwidth[quality]
+			for _, l := range info.Layers {
+				if l.Quality == quality {
+					params.Width, params.Height = uint16(l.Width), uint16(l.Height)
+					if l.Bitrate > 0 {
+						params.Bandwidth = int(l.Bitrate)
+					}
+				}
+			}
+			sender.renditions = append(sender.renditions, s.renditionLocked(params))
+		}
+	} else {
+		params := s.renditionParams(audioRendition, receiver.Codec().ClockRate)
+		params.Channels = 2
+		params.Bandwidth = defaultBandwidth
+		sender.renditions = []*rendition{s.renditionLocked(params)}
+	}
+
+	if err := receiver.AddDownTrack(sender); err != nil {
+		s.logger.Warnw("could not attach packager to track", err, "trackID", track.ID())
+		return nil
+	}
+	if n, ok := track.(maxQualityNotifier); ok && sender.isVideo {
+		n.NotifySubscriberNodeMaxQuality(s.nodeID(), []types.SubscribedCodecQuality{
+			{CodecMime: receiver.Codec().MimeType, Quality: livekit.VideoQuality_HIGH},
+		})
+	}
+	for layer, r := range sender.renditions {
+		if r != nil && sender.isVideo {
+			receiver.SendPLI(int32(layer), true)
+		}
+	}
+	s.logger.Infow("packaging track", "trackID", track.ID(), "kind", track.Kind())
+	return sender
+}
+
+func (s *session) detachLocked(sender *trackSender) {
+	sender.Close()
+	sender.receiver.DeleteDownTrack(sender.subscriberID)
+	if n, ok := sender.track.(maxQualityNotifier); ok && sender.isVideo {
+		n.NotifySubscriberNodeMaxQuality(s.nodeID(), []types.SubscribedCodecQuality{
+			{CodecMime: sender.receiver.Codec().MimeType, Quality: livekit.VideoQuality_OFF},
+		})
+	}
+}
+
+func (s *session) renditionEnabled(name string) bool {
+	if len(s.conf.Renditions) == 0 {
+		return true
+	}
+	for _, r := range s.conf.Renditions {
+		if strings.EqualFold(r, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *session) renditionParams(name string, clockRate uint32) renditionParams {
+	return renditionParams{
+		Name:            name,
+		Dir:             s.dir,
+		ClockRate:       clockRate,
+		SegmentDuration: s.conf.SegmentDuration,
+		PartDuration:    s.conf.PartDuration,
+		PlaylistSize:    s.conf.PlaylistSize,
+		StartedAt:       s.startedAt,
+		OnSegment:       s.onSegment,
+		Logger:          s.logger,
+	}
+}
+
+// renditionLocked returns the rendition of the name, a rendition that was fed by a previous
+// track continues after a discontinuity
+func (s *session) renditionLocked(params renditionParams) *rendition {
+	if r, ok := s.renditions[params.Name]; ok {
+		r.Restart()
+		return r
+	}
+	r, err := newRendition(params)
+	if err != nil {
+		s.logger.Warnw("could not create rendition", err, "rendition", params.Name)
+		return nil
+	}
+	s.renditions[params.Name] = r
+	return r
+}
+
+// Rendition returns a rendition being packaged
+func (s *session) Rendition(name string) *rendition {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.renditions[name]
+}
+
+// FilePath returns the local path of a segment, part or init section
+func (s *session) FilePath(rendition string, filename string) string {
+	return filepath.Join(s.dir, rendition, filename)
+}
+
+// MasterPlaylist lists a variant per packaged video layer, sharing the audio rendition
+func (s *session) MasterPlaylist(query string) string {
+	s.lock.Lock()
+	video, audio := s.video, s.audio
+	s.lock.Unlock()
+
+	if query != "" {
+		query = "?" + query
+	}
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n")
+	b.WriteString("#EXT-X-VERSION:9\n")
+	b.WriteString("#EXT-X-INDEPENDENT-SEGMENTS\n")
+
+	var audioRend *rendition
+	if audio != nil {
+		audioRend = audio.renditions[0]
+	}
+	var variants []*rendition
+	if video != nil {
+		for i := len(video.renditions) - 1; i >= 0; i-- {
+			if video.renditions[i] != nil {
+				variants = append(variants, video.renditions[i])
+			}
+		}
+	}
+
+	switch {
+	case len(variants) > 0:
+		audioBandwidth := 0
+		audioCodec := ""
+		if audioRend != nil {
+			audioBandwidth = audioRend.Bandwidth()
+			audioCodec = "," + audioRend.Codec()
+			fmt.Fprintf(&b, "#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID=\"audio\",NAME=\"audio\",DEFAULT=YES,AUTOSELECT=YES,URI=\"%s/%s%s\"\n",
+				audioRendition, PlaylistFilename, query)
+		}
+		for _, r := range variants {
+			fmt.Fprintf(&b, "#EXT-X-STREAM-INF:BANDWIDTH=%d,CODECS=\"%s%s\"", r.Bandwidth()+audioBandwidth, r.Codec(), audioCodec)
+			if r.params.Width > 0 && r.params.Height > 0 {
+				fmt.Fprintf(&b, ",RESOLUTION=%dx%d", r.params.Width, r.params.Height)
+			}
+			if audioRend != nil {
+				b.WriteString(",AUDIO=\"audio\"")
+			}
+			fmt.Fprintf(&b, "\n%s/%s%s\n", r.Name(), PlaylistFilename, query)
+		}
+	case audioRend != nil:
+		fmt.Fprintf(&b, "#EXT-X-STREAM-INF:BANDWIDTH=%d,CODECS=\"%s\"\n%s/%s%s\n",
+			audioRend.Bandwidth(), audioRend.Codec(), audioRendition, PlaylistFilename, query)
+	}
+	return b.String()
+}
+
+func (s *session) info() *Info {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	info := &Info{
+		RoomName:            s.room.Name(),
+		RoomID:              s.room.ID(),
+		ParticipantIdentity: s.current,
+		StartedAt:           s.startedAt.UnixMilli(),
+		PlaylistURL:         PathPrefix + "/" + string(s.room.Name()) + "/" + MasterFilename,
+		Renditions:          []string{},
+	}
+	for name := range s.renditions {
+		info.Renditions = append(info.Renditions, name)
+	}
+	sort.Strings(info.Renditions)
+	return info
+}
+
+// onSegment uploads completed segments to the storage profile. The master playlist is written
+// with the first segment of every init section, once codecs are known
+func (s *session) onSegment(r *rendition, filenames []string) {
+	if s.uploads == nil {
+		return
+	}
+	for _, f := range filenames {
+		if strings.HasPrefix(f, "init") {
+			if err := os.WriteFile(filepath.Join(s.dir, MasterFilename), []byte(s.MasterPlaylist("")), 0644); err != nil {
+				s.logger.Warnw("could not write master playlist", err)
+			}
+			s.queueUpload(MasterFilename)
+			break
+		}
+	}
+	for _, f := range filenames {
+		s.queueUpload(r.Name() + "/" + f)
+	}
+}
+
+func (s *session) queueUpload(relPath string) {
+	s.uploadLock.Lock()
+	defer s.uploadLock.Unlock()
+
+	if s.uploadsEnded {
+		return
+	}
+	select {
+	case s.uploads <- relPath:
+	default:
+		s.logger.Warnw("dropping HLS upload, storage is not keeping up", nil, "file", relPath)
+	}
+}
+
+func (s *session) uploadWorker() {
+	for relPath := range s.uploads {
+		key := s.storage.ObjectKey(s.conf.StorageProfile, "hls", string(s.room.Name()), relPath)
+		contentType := "video/iso.segment"
+		switch filepath.Ext(relPath) {
+		case ".m3u8":
+			contentType = "application/vnd.apple.mpegurl"
+		case ".mp4":
+			contentType = "video/mp4"
+		}
+		if _, err := s.storage.Upload(context.Background(), s.conf.StorageProfile, filepath.Join(s.dir, relPath), key, contentType); err != nil {
+			s.logger.Warnw("could not upload HLS file", err, "file", relPath)
+		}
+	}
+}
+
+// stop detaches from the tracks and ends the playlists, returns false if already stopped
+func (s *session) stop() bool {
+	stopped := false
+	s.doneOnce.Do(func() {
+		close(s.doneChan)
+		stopped = true
+	})
+	if !stopped {
+		return false
+	}
+
+	s.lock.Lock()
+	s.stopped = true
+	for _, sender := range []*trackSender{s.video, s.audio} {
+		if sender != nil {
+			s.detachLocked(sender)
+		}
+	}
+	renditions := make([]*rendition, 0, len(s.renditions))
+	for _, r := range s.renditions {
+		renditions = append(renditions, r)
+	}
+	s.lock.Unlock()
+
+	for _, r := range renditions {
+		r.End()
+	}
+	if s.uploads != nil {
+		for _, r := range renditions {
+			s.queueUpload(r.Name() + "/" + PlaylistFilename)
+		}
+		s.uploadLock.Lock()
+		s.uploadsEnded = true
+		close(s.uploads)
+		s.uploadLock.Unlock()
+	}
+
+	s.logger.Infow("HLS packaging stopped")
+	if s.onStopped != nil {
+		s.onStopped(s)
+	}
+	return true
+}
@@ -0,0 +1,54 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logging
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/livekit-server/pkg/config"
+)
+
+func TestFileSink_Rotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "livekit.log")
+	f, err := NewFileSink(&config.LogFileSinkConfig{
+		Path:       path,
+		MaxBackups: 2,
+	})
+	require.NoError(t, err)
+	defer f.Close()
+
+	// rotate every 10 bytes
+	f.maxSize = 10
+	line := []byte("0123456789")
+	for i := 0; i < 4; i++ {
+		_, err = f.Write(line)
+		require.NoError(t, err)
+	}
+
+	for _, name := range []string{path, backupName(path, 1), backupName(path, 2)} {
+		content, err := os.ReadFile(name)
+		require.NoError(t, err)
+		require.True(t, bytes.Equal(line, content))
+	}
+
+	// only max_backups files are kept
+	_, err = os.Stat(backupName(path, 3))
+	require.True(t, os.IsNotExist(err))
+}
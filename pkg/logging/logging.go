@@ -0,0 +1,277 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logging
+
+import (
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/protocol/logger"
+)
+
+// Sink is an additional log output. Sinks are written to in addition to stderr.
+type Sink interface {
+	Core(enc zapcore.Encoder) zapcore.Core
+	Close() error
+}
+
+var (
+	sinksLock   sync.Mutex
+	activeSinks []Sink
+)
+
+// InitFromConfig initializes the server logger, teeing output into every sink enabled in config.
+func InitFromConfig(conf *config.LoggingConfig) error {
+	sinks, err := NewSinks(&conf.Sinks)
+	if err != nil {
+		return err
+	}
+
+	if len(sinks) == 0 {
+		config.InitLoggerFromConfig(conf)
+		replaceSinks(nil)
+		return nil
+	}
+
+	zl, err := logger.NewZapLogger(&conf.Config)
+	if err != nil {
+		closeSinks(sinks)
+		return err
+	}
+
+	encoderConfig := zap.NewDevelopmentEncoderConfig()
+	if conf.JSON {
+		encoderConfig = zap.NewProductionEncoderConfig()
+	}
+	cores := make([]zapcore.Core, 0, len(sinks))
+	for _, s := range sinks {
+		var enc zapcore.Encoder
+		if conf.JSON {
+			enc = zapcore.NewJSONEncoder(encoderConfig)
+		} else {
+			enc = zapcore.NewConsoleEncoder(encoderConfig)
+		}
+		cores = append(cores, s.Core(enc))
+	}
+	sl := zap.New(zapcore.NewTee(cores...), zap.AddCaller(), zap.AddCallerSkip(1))
+
+	// account for the extra frame added by teeLogger
+	config.SetLogger(&teeLogger{
+		primary: zl.WithCallDepth(1),
+		sinks:   newSinkLogger(&conf.Config, sl.Sugar()).WithCallDepth(1),
+	})
+
+	replaceSinks(sinks)
+	return nil
+}
+
+// replaceSinks makes sinks the active ones, closing those active before
+func replaceSinks(sinks []Sink) {
+	sinksLock.Lock()
+	old := activeSinks
+	activeSinks = sinks
+	sinksLock.Unlock()
+	closeSinks(old)
+}
+
+// NewSinks creates all sinks that are enabled in config
+func NewSinks(conf *config.LogSinksConfig) ([]Sink, error) {
+	var sinks []Sink
+	if conf.File.Path != "" {
+		s, err := NewFileSink(&conf.File)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, s)
+	}
+	if conf.Syslog.Enabled {
+		s, err := NewSyslogSink(&conf.Syslog)
+		if err != nil {
+			closeSinks(sinks)
+			return nil, err
+		}
+		sinks = append(sinks, s)
+	}
+	if conf.Loki.URL != "" {
+		sinks = append(sinks, NewLokiSink(&conf.Loki))
+	}
+	return sinks, nil
+}
+
+// Close flushes and closes all active sinks, should be called before the process exits
+func Close() {
+	replaceSinks(nil)
+}
+
+func closeSinks(sinks []Sink) {
+	for _, s := range sinks {
+		_ = s.Close()
+	}
+}
+
+// ------------------------------------------------
+
+// teeLogger writes every entry to the primary (stderr) logger as well as to the sinks
+type teeLogger struct {
+	primary logger.Logger
+	sinks   logger.Logger
+}
+
+func (t *teeLogger) Debugw(msg string, keysAndValues ...interface{}) {
+	t.primary.Debugw(msg, keysAndValues...)
+	t.sinks.Debugw(msg, keysAndValues...)
+}
+
+func (t *teeLogger) Infow(msg string, keysAndValues ...interface{}) {
+	t.primary.Infow(msg, keysAndValues...)
+	t.sinks.Infow(msg, keysAndValues...)
+}
+
+func (t *teeLogger) Warnw(msg string, err error, keysAndValues ...interface{}) {
+	t.primary.Warnw(msg, err, keysAndValues...)
+	t.sinks.Warnw(msg, err, keysAndValues...)
+}
+
+func (t *teeLogger) Errorw(msg string, err error, keysAndValues ...interface{}) {
+	t.primary.Errorw(msg, err, keysAndValues...)
+	t.sinks.Errorw(msg, err, keysAndValues...)
+}
+
+func (t *teeLogger) WithValues(keysAndValues ...interface{}) logger.Logger {
+	return &teeLogger{primary: t.primary.WithValues(keysAndValues...), sinks: t.sinks.WithValues(keysAndValues...)}
+}
+
+func (t *teeLogger) WithName(name string) logger.Logger {
+	return &teeLogger{primary: t.primary.WithName(name), sinks: t.sinks.WithName(name)}
+}
+
+func (t *teeLogger) WithComponent(component string) logger.Logger {
+	return &teeLogger{primary: t.primary.WithComponent(component), sinks: t.sinks.WithComponent(component)}
+}
+
+func (t *teeLogger) WithCallDepth(depth int) logger.Logger {
+	return &teeLogger{primary: t.primary.WithCallDepth(depth), sinks: t.sinks.WithCallDepth(depth)}
+}
+
+func (t *teeLogger) WithItemSampler() logger.Logger {
+	return &teeLogger{primary: t.primary.WithItemSampler(), sinks: t.sinks}
+}
+
+func (t *teeLogger) WithoutSampler() logger.Logger {
+	return &teeLogger{primary: t.primary.WithoutSampler(), sinks: t.sinks}
+}
+
+// ------------------------------------------------
+
+// sinkLogger is a minimal zap backed logger honoring the configured level and component levels.
+// Sinks are not sampled, they are expected to keep the full log stream.
+type sinkLogger struct {
+	zap       *zap.SugaredLogger
+	conf      *logger.Config
+	component string
+	level     zapcore.Level
+}
+
+func newSinkLogger(conf *logger.Config, zl *zap.SugaredLogger) *sinkLogger {
+	return &sinkLogger{
+		zap:   zl,
+		conf:  conf,
+		level: logger.ParseZapLevel(conf.Level),
+	}
+}
+
+func (l *sinkLogger) Debugw(msg string, keysAndValues ...interface{}) {
+	if l.level > zapcore.DebugLevel {
+		return
+	}
+	l.zap.Debugw(msg, keysAndValues...)
+}
+
+func (l *sinkLogger) Infow(msg string, keysAndValues ...interface{}) {
+	if l.level > zapcore.InfoLevel {
+		return
+	}
+	l.zap.Infow(msg, keysAndValues...)
+}
+
+func (l *sinkLogger) Warnw(msg string, err error, keysAndValues ...interface{}) {
+	if l.level > zapcore.WarnLevel {
+		return
+	}
+	if err != nil {
+		keysAndValues = append(keysAndValues, "error", err)
+	}
+	l.zap.Warnw(msg, keysAndValues...)
+}
+
+func (l *sinkLogger) Errorw(msg string, err error, keysAndValues ...interface{}) {
+	if err != nil {
+		keysAndValues = append(keysAndValues, "error", err)
+	}
+	l.zap.Errorw(msg, keysAndValues...)
+}
+
+func (l *sinkLogger) WithValues(keysAndValues ...interface{}) logger.Logger {
+	dup := *l
+	dup.zap = l.zap.With(keysAndValues...)
+	return &dup
+}
+
+func (l *sinkLogger) WithName(name string) logger.Logger {
+	dup := *l
+	dup.zap = l.zap.Named(name)
+	return &dup
+}
+
+func (l *sinkLogger) WithComponent(component string) logger.Logger {
+	dup := l.WithName(component).(*sinkLogger)
+	if dup.component == "" {
+		dup.component = component
+	} else {
+		dup.component = dup.component + "." + component
+	}
+	dup.level = componentLevel(l.conf, dup.component)
+	return dup
+}
+
+func (l *sinkLogger) WithCallDepth(depth int) logger.Logger {
+	dup := *l
+	dup.zap = l.zap.WithOptions(zap.AddCallerSkip(depth))
+	return &dup
+}
+
+func (l *sinkLogger) WithItemSampler() logger.Logger {
+	return l
+}
+
+func (l *sinkLogger) WithoutSampler() logger.Logger {
+	return l
+}
+
+// search up the component hierarchy to find the first level that is set
+func componentLevel(conf *logger.Config, component string) zapcore.Level {
+	parts := strings.Split(component, ".")
+	for len(parts) > 0 {
+		if level, ok := conf.ComponentLevels[strings.Join(parts, ".")]; ok {
+			return logger.ParseZapLevel(level)
+		}
+		parts = parts[:len(parts)-1]
+	}
+	return logger.ParseZapLevel(conf.Level)
+}
@@ -0,0 +1,66 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logging
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/livekit-server/pkg/config"
+)
+
+func TestInitFromConfig_ClosesReplacedSinks(t *testing.T) {
+	defer Close()
+
+	conf := &config.LoggingConfig{}
+	conf.Sinks.File.Path = filepath.Join(t.TempDir(), "livekit.log")
+	require.NoError(t, InitFromConfig(conf))
+
+	sinksLock.Lock()
+	require.Len(t, activeSinks, 1)
+	first := activeSinks[0].(*FileSink)
+	sinksLock.Unlock()
+
+	testCases := []struct {
+		name string
+		path string
+	}{
+		{"replaced by another sink", filepath.Join(t.TempDir(), "other.log")},
+		{"reloaded without sinks", ""},
+	}
+	prev := first
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			conf := &config.LoggingConfig{}
+			conf.Sinks.File.Path = tc.path
+			require.NoError(t, InitFromConfig(conf))
+
+			prev.lock.Lock()
+			require.Nil(t, prev.file, "previous sink is closed")
+			prev.lock.Unlock()
+
+			sinksLock.Lock()
+			defer sinksLock.Unlock()
+			if tc.path == "" {
+				require.Empty(t, activeSinks)
+			} else {
+				require.Len(t, activeSinks, 1)
+				prev = activeSinks[0].(*FileSink)
+			}
+		})
+	}
+}
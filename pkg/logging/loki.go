@@ -0,0 +1,218 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/atomic"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/livekit/livekit-server/pkg/config"
+)
+
+const (
+	lokiPushPath    = "/loki/api/v1/push"
+	lokiPushTimeout = 5 * time.Second
+)
+
+// LokiSink buffers log lines in memory and pushes them to Loki in batches.
+// Writes never block on the network, when the buffer is full the oldest lines are dropped.
+type LokiSink struct {
+	conf   config.LogLokiSinkConfig
+	url    string
+	client *http.Client
+	labels map[string]string
+
+	lock    sync.Mutex
+	pending [][2]string
+	dropped atomic.Uint64
+
+	flushChan chan struct{}
+	doneChan  chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+func NewLokiSink(conf *config.LogLokiSinkConfig) *LokiSink {
+	labels := map[string]string{"app": "livekit"}
+	for k, v := range conf.Labels {
+		labels[k] = v
+	}
+	c := *conf
+	defaults := config.DefaultConfig.Logging.Sinks.Loki
+	if c.BufferSize <= 0 {
+		c.BufferSize = defaults.BufferSize
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = defaults.BatchSize
+	}
+	if c.FlushInterval <= 0 {
+		c.FlushInterval = defaults.FlushInterval
+	}
+
+	l := &LokiSink{
+		conf:      c,
+		url:       strings.TrimSuffix(conf.URL, "/") + lokiPushPath,
+		client:    &http.Client{Timeout: lokiPushTimeout},
+		labels:    labels,
+		flushChan: make(chan struct{}, 1),
+		doneChan:  make(chan struct{}),
+	}
+	l.wg.Add(1)
+	go l.worker()
+	return l
+}
+
+func (l *LokiSink) Core(enc zapcore.Encoder) zapcore.Core {
+	return zapcore.NewCore(enc, zapcore.AddSync(l), zapcore.DebugLevel)
+}
+
+func (l *LokiSink) Write(p []byte) (int, error) {
+	line := [2]string{
+		strconv.FormatInt(time.Now().UnixNano(), 10),
+		strings.TrimSuffix(string(p), "\n"),
+	}
+
+	l.lock.Lock()
+	if len(l.pending) >= l.conf.BufferSize {
+		l.pending = l.pending[1:]
+		l.dropped.Inc()
+	}
+	l.pending = append(l.pending, line)
+	full := len(l.pending) >= l.conf.BatchSize
+	l.lock.Unlock()
+
+	if full {
+		select {
+		case l.flushChan <- struct{}{}:
+		default:
+		}
+	}
+	return len(p), nil
+}
+
+// Dropped returns the number of lines discarded because the buffer was full
+func (l *LokiSink) Dropped() uint64 {
+	return l.dropped.Load()
+}
+
+func (l *LokiSink) Close() error {
+	l.closeOnce.Do(func() {
+		close(l.doneChan)
+	})
+	l.wg.Wait()
+	return nil
+}
+
+func (l *LokiSink) worker() {
+	defer l.wg.Done()
+
+	ticker := time.NewTicker(l.conf.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.doneChan:
+			// best effort to deliver whatever is left
+			for l.flush() {
+			}
+			return
+		case <-ticker.C:
+			for l.flush() {
+			}
+		case <-l.flushChan:
+			l.flush()
+		}
+	}
+}
+
+// flush pushes a single batch, returns true when a batch was delivered and more lines are pending
+func (l *LokiSink) flush() bool {
+	l.lock.Lock()
+	n := len(l.pending)
+	if n == 0 {
+		l.lock.Unlock()
+		return false
+	}
+	if n > l.conf.BatchSize {
+		n = l.conf.BatchSize
+	}
+	batch := make([][2]string, n)
+	copy(batch, l.pending[:n])
+	droppedBefore := l.dropped.Load()
+	l.lock.Unlock()
+
+	if err := l.push(batch); err != nil {
+		// keep the batch buffered, it will be retried on the next tick
+		fmt.Fprintf(os.Stderr, "could not push logs to loki: %v\n", err)
+		return false
+	}
+
+	l.lock.Lock()
+	// lines of this batch may have been dropped from the front while pushing
+	n -= int(l.dropped.Load() - droppedBefore)
+	if n > 0 {
+		l.pending = l.pending[n:]
+	}
+	more := len(l.pending) > 0
+	l.lock.Unlock()
+	return more
+}
+
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+type lokiPushRequest struct {
+	Streams []lokiStream `json:"streams"`
+}
+
+func (l *LokiSink) push(batch [][2]string) error {
+	body, err := json.Marshal(&lokiPushRequest{
+		Streams: []lokiStream{{Stream: l.labels, Values: batch}},
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, l.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if l.conf.TenantID != "" {
+		req.Header.Set("X-Scope-OrgID", l.conf.TenantID)
+	}
+
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
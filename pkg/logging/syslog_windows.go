@@ -0,0 +1,41 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build windows
+
+package logging
+
+import (
+	"errors"
+
+	"go.uber.org/zap/zapcore"
+
+	"github.com/livekit/livekit-server/pkg/config"
+)
+
+var ErrSyslogUnsupported = errors.New("syslog sink is not supported on windows")
+
+type SyslogSink struct{}
+
+func NewSyslogSink(_ *config.LogSyslogSinkConfig) (*SyslogSink, error) {
+	return nil, ErrSyslogUnsupported
+}
+
+func (s *SyslogSink) Core(_ zapcore.Encoder) zapcore.Core {
+	return zapcore.NewNopCore()
+}
+
+func (s *SyslogSink) Close() error {
+	return nil
+}
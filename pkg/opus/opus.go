@@ -0,0 +1,31 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package opus
+
+import "errors"
+
+var ErrUnavailable = errors.New("opus is not available, the server needs to be built with cgo and the opus tag")
+
+// Encoder encodes mono 16-bit PCM frames, Decoder decodes packets to mono 16-bit PCM.
+// Both work at the sample rate they are created with, libopus resamples internally.
+type Encoder interface {
+	Encode(pcm []int16, out []byte) (int, error)
+	Close()
+}
+
+type Decoder interface {
+	Decode(packet []byte, pcm []int16) (int, error)
+	Close()
+}
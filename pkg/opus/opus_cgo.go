@@ -0,0 +1,119 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build opus && cgo
+// +build opus,cgo
+
+package opus
+
+/*
+#cgo pkg-config: opus
+#include <opus.h>
+
+static int lk_opus_set_bitrate(OpusEncoder *st, opus_int32 bitrate) {
+	return opus_encoder_ctl(st, OPUS_SET_BITRATE(bitrate));
+}
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"unsafe"
+)
+
+const (
+	Supported = true
+
+	// encoders carry speech, narrowband voice does not need more
+	opusBitrate = 24000
+)
+
+var errOpusBuffer = errors.New("opus buffer is empty")
+
+type libopusEncoder struct {
+	st *C.OpusEncoder
+}
+
+func NewEncoder(sampleRate int) (Encoder, error) {
+	var errno C.int
+	st := C.opus_encoder_create(C.opus_int32(sampleRate), 1, C.OPUS_APPLICATION_VOIP, &errno)
+	if errno != C.OPUS_OK {
+		return nil, fmt.Errorf("could not create opus encoder: %d", int(errno))
+	}
+	C.lk_opus_set_bitrate(st, opusBitrate)
+	return &libopusEncoder{st: st}, nil
+}
+
+func (e *libopusEncoder) Encode(pcm []int16, out []byte) (int, error) {
+	if len(pcm) == 0 || len(out) == 0 {
+		return 0, errOpusBuffer
+	}
+	n := C.opus_encode(
+		e.st,
+		(*C.opus_int16)(unsafe.Pointer(&pcm[0])),
+		C.int(len(pcm)),
+		(*C.uchar)(unsafe.Pointer(&out[0])),
+		C.opus_int32(len(out)),
+	)
+	if n < 0 {
+		return 0, fmt.Errorf("opus encoding failed: %d", int(n))
+	}
+	return int(n), nil
+}
+
+func (e *libopusEncoder) Close() {
+	if e.st != nil {
+		C.opus_encoder_destroy(e.st)
+		e.st = nil
+	}
+}
+
+type libopusDecoder struct {
+	st *C.OpusDecoder
+}
+
+func NewDecoder(sampleRate int) (Decoder, error) {
+	var errno C.int
+	st := C.opus_decoder_create(C.opus_int32(sampleRate), 1, &errno)
+	if errno != C.OPUS_OK {
+		return nil, fmt.Errorf("could not create opus decoder: %d", int(errno))
+	}
+	return &libopusDecoder{st: st}, nil
+}
+
+func (d *libopusDecoder) Decode(packet []byte, pcm []int16) (int, error) {
+	if len(packet) == 0 || len(pcm) == 0 {
+		return 0, errOpusBuffer
+	}
+	n := C.opus_decode(
+		d.st,
+		(*C.uchar)(unsafe.Pointer(&packet[0])),
+		C.opus_int32(len(packet)),
+		(*C.opus_int16)(unsafe.Pointer(&pcm[0])),
+		C.int(len(pcm)),
+		0,
+	)
+	if n < 0 {
+		return 0, fmt.Errorf("opus decoding failed: %d", int(n))
+	}
+	return int(n), nil
+}
+
+func (d *libopusDecoder) Close() {
+	if d.st != nil {
+		C.opus_decoder_destroy(d.st)
+		d.st = nil
+	}
+}
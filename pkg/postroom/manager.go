@@ -0,0 +1,362 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postroom
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/livekit/protocol/auth"
+	"github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/logger"
+	"github.com/livekit/protocol/utils"
+
+	"github.com/livekit/livekit-server/pkg/config"
+)
+
+const (
+	RunPrefix = "PR_"
+
+	// retry intervals stop doubling at this duration
+	maxRetryInterval = 10 * time.Minute
+)
+
+var (
+	ErrRunNotFound       = errors.New("post room run not found")
+	ErrRunNotFailed      = errors.New("only failed post room runs can be retried")
+	ErrRecordingDisabled = errors.New("upload_recordings requires recording to be enabled")
+	ErrNoAPIKey          = errors.New("webhook step api_key has no secret")
+	ErrNoKeyPurger       = errors.New("purge_store_keys requires a redis store")
+)
+
+type pipeline struct {
+	conf  config.PostRoomPipelineConfig
+	steps []step
+}
+
+// Manager runs the configured pipelines for rooms that finished on this node
+type Manager struct {
+	conf      config.PostRoomConfig
+	pipelines []*pipeline
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	lock sync.RWMutex
+	runs map[string]*Run
+}
+
+// NewManager returns nil when no pipeline is configured. recordings and purger may be nil when recording or
+// redis are not used, pipelines with steps requiring them are then rejected.
+func NewManager(conf *config.Config, provider auth.KeyProvider, recordings Recordings, purger KeyPurger) (*Manager, error) {
+	if len(conf.PostRoom.Pipelines) == 0 {
+		return nil, nil
+	}
+
+	m := &Manager{
+		conf: conf.PostRoom,
+		runs: make(map[string]*Run),
+	}
+	for _, pc := range conf.PostRoom.Pipelines {
+		p := &pipeline{conf: pc}
+		for _, sc := range pc.Steps {
+			s, err := newStep(conf, sc, provider, recordings, purger)
+			if err != nil {
+				return nil, fmt.Errorf("post_room pipeline %s: %w", pc.Name, err)
+			}
+			p.steps = append(p.steps, s)
+		}
+		m.pipelines = append(m.pipelines, p)
+	}
+	m.ctx, m.cancel = context.WithCancel(context.Background())
+
+	if m.conf.StateDir != "" {
+		if err := os.MkdirAll(m.conf.StateDir, 0755); err != nil {
+			return nil, err
+		}
+		m.resumeRuns()
+	}
+	return m, nil
+}
+
+// RoomFinished starts the pipelines matching the room
+func (m *Manager) RoomFinished(summary *Summary) {
+	if m == nil {
+		return
+	}
+	m.prune()
+
+	for _, p := range m.pipelines {
+		if !strings.HasPrefix(string(summary.RoomName), p.conf.RoomPrefix) {
+			continue
+		}
+		r := &Run{
+			ID:        utils.NewGuid(RunPrefix),
+			Pipeline:  p.conf.Name,
+			Room:      summary,
+			State:     StatePending,
+			CreatedAt: time.Now().UnixMilli(),
+		}
+		for _, sc := range p.conf.Steps {
+			r.Steps = append(r.Steps, &StepRun{Type: sc.Type, State: StatePending})
+		}
+
+		m.lock.Lock()
+		m.runs[r.ID] = r
+		m.persistLocked(r)
+		m.lock.Unlock()
+
+		go m.execute(p, r)
+	}
+}
+
+// List returns the runs of a room, or of all rooms when roomName is empty, newest first
+func (m *Manager) List(roomName livekit.RoomName) []*Run {
+	m.lock.RLock()
+	runs := make([]*Run, 0, len(m.runs))
+	for _, r := range m.runs {
+		if roomName == "" || r.Room.RoomName == roomName {
+			runs = append(runs, r.clone())
+		}
+	}
+	m.lock.RUnlock()
+
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].CreatedAt > runs[j].CreatedAt
+	})
+	return runs
+}
+
+// Retry restarts a failed run from its failed step
+func (m *Manager) Retry(id string) (*Run, error) {
+	m.lock.Lock()
+	r := m.runs[id]
+	if r == nil {
+		m.lock.Unlock()
+		return nil, ErrRunNotFound
+	}
+	if r.State != StateFailed {
+		m.lock.Unlock()
+		return nil, ErrRunNotFailed
+	}
+	p := m.getPipeline(r.Pipeline)
+	if p == nil {
+		m.lock.Unlock()
+		return nil, ErrRunNotFound
+	}
+	r.State = StatePending
+	r.EndedAt = 0
+	for _, s := range r.Steps {
+		if s.State == StateFailed || s.State == StateSkipped {
+			s.State = StatePending
+			s.Attempts = 0
+			s.NextAttemptAt = 0
+		}
+	}
+	m.persistLocked(r)
+	snapshot := r.clone()
+	m.lock.Unlock()
+
+	go m.execute(p, r)
+	return snapshot, nil
+}
+
+// Close interrupts running pipelines, persisted runs are resumed on the next start
+func (m *Manager) Close() {
+	if m == nil {
+		return
+	}
+	m.cancel()
+}
+
+func (m *Manager) execute(p *pipeline, r *Run) {
+	l := logger.GetLogger().WithValues("runID", r.ID, "pipeline", r.Pipeline, "room", r.Room.RoomName)
+
+	m.lock.Lock()
+	r.State = StateRunning
+	m.lock.Unlock()
+
+	for i, s := range p.steps {
+		sr := r.Steps[i]
+		if sr.State == StateSucceeded {
+			continue
+		}
+
+		for {
+			if wait := time.Until(time.UnixMilli(sr.NextAttemptAt)); sr.NextAttemptAt != 0 && wait > 0 {
+				select {
+				case <-m.ctx.Done():
+					return
+				case <-time.After(wait):
+				}
+			}
+
+			m.lock.Lock()
+			sr.State = StateRunning
+			sr.Attempts++
+			m.persistLocked(r)
+			m.lock.Unlock()
+
+			err := s.run(m.ctx, r)
+			if m.ctx.Err() != nil {
+				// interrupted by a shutdown, the attempt is repeated on the next start
+				return
+			}
+
+			m.lock.Lock()
+			if err == nil {
+				sr.State = StateSucceeded
+				sr.Error = ""
+				sr.NextAttemptAt = 0
+				m.persistLocked(r)
+				m.lock.Unlock()
+				break
+			}
+
+			sr.Error = err.Error()
+			if sr.Attempts >= p.conf.MaxAttempts {
+				sr.State = StateFailed
+				sr.NextAttemptAt = 0
+				for _, next := range r.Steps[i+1:] {
+					next.State = StateSkipped
+				}
+				r.State = StateFailed
+				r.EndedAt = time.Now().UnixMilli()
+				m.persistLocked(r)
+				m.lock.Unlock()
+				l.Errorw("post room pipeline failed", err, "step", sr.Type, "attempts", sr.Attempts)
+				return
+			}
+
+			sr.State = StatePending
+			sr.NextAttemptAt = time.Now().Add(retryInterval(p.conf.RetryInterval, sr.Attempts)).UnixMilli()
+			m.persistLocked(r)
+			m.lock.Unlock()
+			l.Warnw("post room step failed, retrying", err, "step", sr.Type, "attempts", sr.Attempts)
+		}
+	}
+
+	m.lock.Lock()
+	r.State = StateSucceeded
+	r.EndedAt = time.Now().UnixMilli()
+	m.persistLocked(r)
+	m.lock.Unlock()
+	l.Infow("post room pipeline succeeded")
+}
+
+// retryInterval doubles interval after each failed attempt
+func retryInterval(interval time.Duration, attempts int) time.Duration {
+	for i := 1; i < attempts && interval < maxRetryInterval; i++ {
+		interval *= 2
+	}
+	if interval > maxRetryInterval {
+		interval = maxRetryInterval
+	}
+	return interval
+}
+
+func (m *Manager) getPipeline(name string) *pipeline {
+	for _, p := range m.pipelines {
+		if p.conf.Name == name {
+			return p
+		}
+	}
+	return nil
+}
+
+// prune forgets finished runs past retention
+func (m *Manager) prune() {
+	expiry := time.Now().Add(-m.conf.Retention).UnixMilli()
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for id, r := range m.runs {
+		if r.isFinished() && r.EndedAt < expiry {
+			delete(m.runs, id)
+			if m.conf.StateDir != "" {
+				_ = os.Remove(m.runPath(id))
+			}
+		}
+	}
+}
+
+// resumeRuns loads persisted runs and continues the ones interrupted by a restart
+func (m *Manager) resumeRuns() {
+	entries, err := os.ReadDir(m.conf.StateDir)
+	if err != nil {
+		logger.Warnw("could not read post room runs", err, "dir", m.conf.StateDir)
+		return
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(m.conf.StateDir, entry.Name()))
+		if err != nil {
+			continue
+		}
+		r := &Run{}
+		if err = json.Unmarshal(data, r); err != nil || r.Room == nil {
+			logger.Warnw("invalid post room run", err, "file", entry.Name())
+			continue
+		}
+		m.runs[r.ID] = r
+	}
+	m.prune()
+
+	for _, r := range m.runs {
+		if r.isFinished() {
+			continue
+		}
+		p := m.getPipeline(r.Pipeline)
+		if p == nil || len(p.steps) != len(r.Steps) {
+			logger.Warnw("post room pipeline changed, run abandoned", nil, "runID", r.ID, "pipeline", r.Pipeline)
+			r.State = StateFailed
+			r.EndedAt = time.Now().UnixMilli()
+			m.persistLocked(r)
+			continue
+		}
+		go m.execute(p, r)
+	}
+}
+
+func (m *Manager) runPath(id string) string {
+	return filepath.Join(m.conf.StateDir, id+".json")
+}
+
+func (m *Manager) persistLocked(r *Run) {
+	if m.conf.StateDir == "" {
+		return
+	}
+	data, err := json.Marshal(r)
+	if err != nil {
+		return
+	}
+	tmp := m.runPath(r.ID) + ".tmp"
+	if err = os.WriteFile(tmp, data, 0644); err == nil {
+		err = os.Rename(tmp, m.runPath(r.ID))
+	}
+	if err != nil {
+		logger.Warnw("could not persist post room run", err, "runID", r.ID)
+	}
+}
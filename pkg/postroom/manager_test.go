@@ -0,0 +1,184 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postroom
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+
+	"github.com/livekit/protocol/auth/authfakes"
+	"github.com/livekit/protocol/livekit"
+
+	"github.com/livekit/livekit-server/pkg/config"
+)
+
+type fakePurger struct {
+	lock     sync.Mutex
+	err      error
+	patterns []string
+}
+
+func (f *fakePurger) PurgeRoomKeys(_ context.Context, roomName livekit.RoomName, patterns []string) (int, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.err != nil {
+		return 0, f.err
+	}
+	f.patterns = append(f.patterns, patterns...)
+	return len(patterns), nil
+}
+
+func (f *fakePurger) setErr(err error) {
+	f.lock.Lock()
+	f.err = err
+	f.lock.Unlock()
+}
+
+func newTestConfig(stateDir string, steps ...config.PostRoomStepConfig) *config.Config {
+	return &config.Config{
+		WebHook: config.WebHookConfig{APIKey: "APIabcdefg"},
+		PostRoom: config.PostRoomConfig{
+			StateDir:  stateDir,
+			Retention: time.Hour,
+			Pipelines: []config.PostRoomPipelineConfig{{
+				Name:          "archive",
+				RoomPrefix:    "class-",
+				Steps:         steps,
+				MaxAttempts:   2,
+				RetryInterval: 10 * time.Millisecond,
+			}},
+		},
+	}
+}
+
+func waitForRun(t *testing.T, m *Manager, roomName livekit.RoomName, state State) *Run {
+	var run *Run
+	require.Eventually(t, func() bool {
+		runs := m.List(roomName)
+		if len(runs) != 1 || runs[0].State != state {
+			return false
+		}
+		run = runs[0]
+		return true
+	}, 5*time.Second, 10*time.Millisecond)
+	return run
+}
+
+func TestPipelineRetriesSteps(t *testing.T) {
+	provider := &authfakes.FakeKeyProvider{}
+	provider.GetSecretReturns("somesecretencodedinbase62")
+
+	requests := atomic.NewInt32(0)
+	var received webhookRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NotEmpty(t, r.Header.Get("Authorization"))
+		if requests.Inc() == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+	}))
+	defer srv.Close()
+
+	purger := &fakePurger{}
+	conf := newTestConfig("",
+		config.PostRoomStepConfig{Type: StepWebhook, URL: srv.URL, Timeout: time.Second},
+		config.PostRoomStepConfig{Type: StepPurgeStoreKeys, Keys: []string{"app:{room}"}},
+	)
+	m, err := NewManager(conf, provider, nil, purger)
+	require.NoError(t, err)
+	defer m.Close()
+
+	// not matching the prefix of the pipeline
+	m.RoomFinished(&Summary{RoomName: "lobby"})
+	require.Empty(t, m.List(""))
+
+	m.RoomFinished(&Summary{RoomName: "class-1", RoomID: "RM_1", Duration: 60})
+	run := waitForRun(t, m, "class-1", StateSucceeded)
+	require.Equal(t, "archive", run.Pipeline)
+	require.Equal(t, 2, run.Steps[0].Attempts)
+	require.Equal(t, 1, run.Steps[1].Attempts)
+	require.Equal(t, int32(2), requests.Load())
+
+	require.Equal(t, run.ID, received.RunID)
+	require.Equal(t, livekit.RoomName("class-1"), received.Room.RoomName)
+	require.Equal(t, int64(60), received.Room.Duration)
+	require.Equal(t, []string{"app:{room}"}, purger.patterns)
+}
+
+func TestPipelineFailureAndRetry(t *testing.T) {
+	stateDir := t.TempDir()
+	purger := &fakePurger{err: errors.New("redis unavailable")}
+	conf := newTestConfig(stateDir,
+		config.PostRoomStepConfig{Type: StepPurgeStoreKeys, Keys: []string{"app:{room}"}},
+		config.PostRoomStepConfig{Type: StepPurgeStoreKeys, Keys: []string{"other:{room}"}},
+	)
+	m, err := NewManager(conf, nil, nil, purger)
+	require.NoError(t, err)
+
+	m.RoomFinished(&Summary{RoomName: "class-1"})
+	run := waitForRun(t, m, "class-1", StateFailed)
+	require.Equal(t, StateFailed, run.Steps[0].State)
+	require.Equal(t, 2, run.Steps[0].Attempts)
+	require.Equal(t, "redis unavailable", run.Steps[0].Error)
+	require.Equal(t, StateSkipped, run.Steps[1].State)
+	m.Close()
+
+	// runs are reloaded from the state dir
+	m, err = NewManager(conf, nil, nil, purger)
+	require.NoError(t, err)
+	defer m.Close()
+	reloaded := waitForRun(t, m, "class-1", StateFailed)
+	require.Equal(t, run.ID, reloaded.ID)
+
+	_, err = m.Retry("PR_unknown")
+	require.ErrorIs(t, err, ErrRunNotFound)
+
+	purger.setErr(nil)
+	_, err = m.Retry(run.ID)
+	require.NoError(t, err)
+	run = waitForRun(t, m, "class-1", StateSucceeded)
+	require.Equal(t, 1, run.Steps[0].Attempts)
+	require.Equal(t, []string{"app:{room}", "other:{room}"}, purger.patterns)
+
+	_, err = m.Retry(run.ID)
+	require.ErrorIs(t, err, ErrRunNotFailed)
+}
+
+func TestNewManagerRequiresDependencies(t *testing.T) {
+	_, err := NewManager(newTestConfig("", config.PostRoomStepConfig{Type: StepPurgeStoreKeys, Keys: []string{"a"}}), nil, nil, nil)
+	require.ErrorIs(t, err, ErrNoKeyPurger)
+
+	_, err = NewManager(newTestConfig("", config.PostRoomStepConfig{Type: StepUploadRecordings}), nil, nil, nil)
+	require.ErrorIs(t, err, ErrRecordingDisabled)
+
+	_, err = NewManager(newTestConfig("", config.PostRoomStepConfig{Type: StepWebhook, URL: "http://localhost"}), nil, nil, nil)
+	require.ErrorIs(t, err, ErrNoAPIKey)
+}
+
+func TestRetryInterval(t *testing.T) {
+	require.Equal(t, 30*time.Second, retryInterval(30*time.Second, 1))
+	require.Equal(t, 2*time.Minute, retryInterval(30*time.Second, 3))
+	require.Equal(t, maxRetryInterval, retryInterval(30*time.Second, 100))
+}
@@ -0,0 +1,82 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postroom
+
+import (
+	"github.com/livekit/protocol/livekit"
+
+	"github.com/livekit/livekit-server/pkg/rtc"
+)
+
+type State string
+
+const (
+	// waiting to start or for the next attempt of a step
+	StatePending   State = "pending"
+	StateRunning   State = "running"
+	StateSucceeded State = "succeeded"
+	StateFailed    State = "failed"
+	// not run because an earlier step failed
+	StateSkipped State = "skipped"
+)
+
+// Summary describes a finished room
+type Summary struct {
+	RoomName livekit.RoomName `json:"room"`
+	RoomID   livekit.RoomID   `json:"room_id"`
+	Metadata string           `json:"metadata,omitempty"`
+	// unix seconds
+	CreatedAt int64 `json:"created_at"`
+	EndedAt   int64 `json:"ended_at"`
+	// seconds the room was open
+	Duration int64             `json:"duration"`
+	Speakers []rtc.SpeakerStat `json:"speakers,omitempty"`
+}
+
+// Run is the execution of a pipeline for a room, it is persisted while it runs and reported until it expires
+type Run struct {
+	ID       string     `json:"id"`
+	Pipeline string     `json:"pipeline"`
+	Room     *Summary   `json:"room"`
+	State    State      `json:"state"`
+	Steps    []*StepRun `json:"steps"`
+	// unix milliseconds
+	CreatedAt int64 `json:"created_at"`
+	EndedAt   int64 `json:"ended_at,omitempty"`
+}
+
+type StepRun struct {
+	Type     string `json:"type"`
+	State    State  `json:"state"`
+	Attempts int    `json:"attempts"`
+	// error of the last attempt
+	Error string `json:"error,omitempty"`
+	// unix milliseconds of the next attempt of a pending step that failed before
+	NextAttemptAt int64 `json:"next_attempt_at,omitempty"`
+}
+
+func (r *Run) clone() *Run {
+	c := *r
+	c.Steps = make([]*StepRun, 0, len(r.Steps))
+	for _, s := range r.Steps {
+		sc := *s
+		c.Steps = append(c.Steps, &sc)
+	}
+	return &c
+}
+
+func (r *Run) isFinished() bool {
+	return r.State == StateSucceeded || r.State == StateFailed
+}
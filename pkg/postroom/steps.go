@@ -0,0 +1,186 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postroom
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/livekit/protocol/auth"
+	"github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/logger"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/recording"
+)
+
+const (
+	StepUploadRecordings = "upload_recordings"
+	StepWebhook          = "webhook"
+	StepPurgeStoreKeys   = "purge_store_keys"
+)
+
+// Recordings uploads and lists the recordings of rooms
+type Recordings interface {
+	List(roomName livekit.RoomName) ([]*recording.Info, error)
+	UploadRoom(ctx context.Context, roomName livekit.RoomName, profile string) error
+}
+
+// KeyPurger deletes store keys left behind by rooms
+type KeyPurger interface {
+	PurgeRoomKeys(ctx context.Context, roomName livekit.RoomName, patterns []string) (int, error)
+}
+
+type step interface {
+	run(ctx context.Context, r *Run) error
+}
+
+type webhookRequest struct {
+	// identifies the run, requests are repeated when they are not acknowledged
+	RunID      string            `json:"run_id"`
+	Pipeline   string            `json:"pipeline"`
+	Room       *Summary          `json:"room"`
+	Recordings []*recording.Info `json:"recordings,omitempty"`
+}
+
+func newStep(conf *config.Config, sc config.PostRoomStepConfig, provider auth.KeyProvider, recordings Recordings, purger KeyPurger) (step, error) {
+	switch sc.Type {
+	case StepUploadRecordings:
+		if recordings == nil {
+			return nil, ErrRecordingDisabled
+		}
+		return &uploadRecordingsStep{profile: sc.StorageProfile, recordings: recordings}, nil
+
+	case StepWebhook:
+		apiKey := sc.APIKey
+		if apiKey == "" {
+			apiKey = conf.WebHook.APIKey
+		}
+		var secret string
+		if provider != nil {
+			secret = provider.GetSecret(apiKey)
+		}
+		if secret == "" {
+			return nil, ErrNoAPIKey
+		}
+		return &webhookStep{
+			url:        sc.URL,
+			apiKey:     apiKey,
+			secret:     secret,
+			client:     &http.Client{Timeout: sc.Timeout},
+			recordings: recordings,
+		}, nil
+
+	case StepPurgeStoreKeys:
+		if purger == nil {
+			return nil, ErrNoKeyPurger
+		}
+		return &purgeStoreKeysStep{keys: sc.Keys, purger: purger}, nil
+
+	default:
+		return nil, fmt.Errorf("invalid step type %q", sc.Type)
+	}
+}
+
+type uploadRecordingsStep struct {
+	profile    string
+	recordings Recordings
+}
+
+func (s *uploadRecordingsStep) run(ctx context.Context, r *Run) error {
+	err := s.recordings.UploadRoom(ctx, r.Room.RoomName, s.profile)
+	if errors.Is(err, recording.ErrNoStorageProfile) {
+		// nothing to upload to, recordings stay local
+		logger.Infow("no storage profile, recordings kept locally", "room", r.Room.RoomName, "pipeline", r.Pipeline)
+		return nil
+	}
+	return err
+}
+
+// webhookStep posts the summary of the room, signed like webhooks with the sha256 of the body in the token of
+// the Authorization header
+type webhookStep struct {
+	url        string
+	apiKey     string
+	secret     string
+	client     *http.Client
+	recordings Recordings
+}
+
+func (s *webhookStep) run(ctx context.Context, r *Run) error {
+	wr := &webhookRequest{
+		RunID:    r.ID,
+		Pipeline: r.Pipeline,
+		Room:     r.Room,
+	}
+	if s.recordings != nil {
+		infos, err := s.recordings.List(r.Room.RoomName)
+		if err != nil {
+			return err
+		}
+		wr.Recordings = infos
+	}
+
+	body, err := json.Marshal(wr)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(body)
+	token, err := auth.NewAccessToken(s.apiKey, s.secret).
+		SetValidFor(5 * time.Minute).
+		SetSha256(base64.StdEncoding.EncodeToString(sum[:])).
+		ToJWT()
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+type purgeStoreKeysStep struct {
+	keys   []string
+	purger KeyPurger
+}
+
+func (s *purgeStoreKeysStep) run(ctx context.Context, r *Run) error {
+	deleted, err := s.purger.PurgeRoomKeys(ctx, r.Room.RoomName, s.keys)
+	if err != nil {
+		return err
+	}
+	logger.Debugw("purged room keys", "room", r.Room.RoomName, "pipeline", r.Pipeline, "deleted", deleted)
+	return nil
+}
@@ -0,0 +1,420 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recording
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/protocol/livekit"
+)
+
+const (
+	EncryptionAlgorithm = "AES-256-GCM-STREAM"
+	// appended to the name of encrypted files
+	EncryptedExtension = ".enc"
+
+	encryptionChunkSize = 64 << 10
+	noncePrefixSize     = 7
+	kmsTimeout          = 10 * time.Second
+)
+
+var encryptionMagic = []byte("LKRE\x01")
+
+var (
+	ErrInvalidEncryptedFile = errors.New("invalid encrypted recording file")
+	ErrUnknownKey           = errors.New("unknown encryption key")
+)
+
+// EncryptionInfo is stored in the index and manifest of encrypted recordings
+type EncryptionInfo struct {
+	Algorithm string `json:"algorithm"`
+	KeyID     string `json:"key_id"`
+	// data key of the recording, wrapped with the key identified by KeyID
+	WrappedKey []byte `json:"wrapped_key"`
+}
+
+// KeyProvider wraps the data keys of recordings with the key of their room
+type KeyProvider interface {
+	Wrap(ctx context.Context, keyID string, roomName livekit.RoomName, dataKey []byte) ([]byte, error)
+	Unwrap(ctx context.Context, keyID string, wrapped []byte) ([]byte, error)
+}
+
+func NewKeyProvider(conf config.RecordingEncryptionConfig) (KeyProvider, error) {
+	if conf.KMS.URL != "" {
+		return &kmsKeyProvider{
+			conf:   conf.KMS,
+			client: &http.Client{Timeout: kmsTimeout},
+		}, nil
+	}
+
+	p := &localKeyProvider{keys: make(map[string]cipher.AEAD)}
+	for keyID, encoded := range conf.Keys {
+		aead, err := newAEAD(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", keyID, err)
+		}
+		p.keys[keyID] = aead
+	}
+	return p, nil
+}
+
+// keyIDFor returns the key protecting recordings of a room
+func keyIDFor(conf config.RecordingEncryptionConfig, roomName livekit.RoomName) string {
+	for _, r := range conf.Rooms {
+		if strings.HasPrefix(string(roomName), r.RoomPrefix) {
+			return r.KeyID
+		}
+	}
+	return conf.DefaultKeyID
+}
+
+func newAEAD(encoded string) (cipher.AEAD, error) {
+	key, err := decodeKey(encoded)
+	if err != nil {
+		return nil, err
+	}
+	return newAEADFromKey(key)
+}
+
+func newAEADFromKey(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func decodeKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(key) != 32 {
+		return nil, errors.New("key must be a base64 encoded 32 byte key")
+	}
+	return key, nil
+}
+
+type localKeyProvider struct {
+	keys map[string]cipher.AEAD
+}
+
+func (p *localKeyProvider) Wrap(_ context.Context, keyID string, _ livekit.RoomName, dataKey []byte) ([]byte, error) {
+	aead, ok := p.keys[keyID]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, dataKey, []byte(keyID)), nil
+}
+
+func (p *localKeyProvider) Unwrap(_ context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	aead, ok := p.keys[keyID]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	if len(wrapped) < aead.NonceSize() {
+		return nil, ErrInvalidEncryptedFile
+	}
+	return aead.Open(nil, wrapped[:aead.NonceSize()], wrapped[aead.NonceSize():], []byte(keyID))
+}
+
+// kmsKeyProvider delegates wrapping to a key management service, keys never leave the service
+type kmsKeyProvider struct {
+	conf   config.RecordingEncryptionKMSConfig
+	client *http.Client
+}
+
+type kmsRequest struct {
+	KeyID      string           `json:"key_id"`
+	Room       livekit.RoomName `json:"room,omitempty"`
+	Plaintext  []byte           `json:"plaintext,omitempty"`
+	Ciphertext []byte           `json:"ciphertext,omitempty"`
+}
+
+type kmsResponse struct {
+	Plaintext  []byte `json:"plaintext,omitempty"`
+	Ciphertext []byte `json:"ciphertext,omitempty"`
+}
+
+func (p *kmsKeyProvider) Wrap(ctx context.Context, keyID string, roomName livekit.RoomName, dataKey []byte) ([]byte, error) {
+	res, err := p.call(ctx, "wrap", &kmsRequest{KeyID: keyID, Room: roomName, Plaintext: dataKey})
+	if err != nil {
+		return nil, err
+	}
+	return res.Ciphertext, nil
+}
+
+func (p *kmsKeyProvider) Unwrap(ctx context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	res, err := p.call(ctx, "unwrap", &kmsRequest{KeyID: keyID, Ciphertext: wrapped})
+	if err != nil {
+		return nil, err
+	}
+	return res.Plaintext, nil
+}
+
+func (p *kmsKeyProvider) call(ctx context.Context, op string, body *kmsRequest) (*kmsResponse, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(p.conf.URL, "/")+"/"+op, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.conf.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+p.conf.Token)
+	}
+
+	res, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("kms %s failed with status %d", op, res.StatusCode)
+	}
+	out := &kmsResponse{}
+	if err = json.NewDecoder(res.Body).Decode(out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// encryptingWriter splits the stream in chunks sealed with AES-GCM. Nonces are made of a random
+// prefix, the chunk counter and a flag marking the last chunk, so chunks cannot be reordered and
+// truncation is detected. The header is authenticated with every chunk.
+type encryptingWriter struct {
+	out         io.WriteCloser
+	aead        cipher.AEAD
+	header      []byte
+	noncePrefix []byte
+	counter     uint32
+	buf         []byte
+	sealed      []byte
+}
+
+func newEncryptingWriter(out io.WriteCloser, key []byte) (*encryptingWriter, error) {
+	aead, err := newAEADFromKey(key)
+	if err != nil {
+		return nil, err
+	}
+	noncePrefix := make([]byte, noncePrefixSize)
+	if _, err = rand.Read(noncePrefix); err != nil {
+		return nil, err
+	}
+	header := append(append([]byte{}, encryptionMagic...), noncePrefix...)
+	if _, err = out.Write(header); err != nil {
+		return nil, err
+	}
+
+	return &encryptingWriter{
+		out:         out,
+		aead:        aead,
+		header:      header,
+		noncePrefix: noncePrefix,
+		buf:         make([]byte, 0, encryptionChunkSize),
+	}, nil
+}
+
+func chunkNonce(prefix []byte, counter uint32, last bool) []byte {
+	nonce := make([]byte, noncePrefixSize+5)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[noncePrefixSize:], counter)
+	if last {
+		nonce[noncePrefixSize+4] = 1
+	}
+	return nonce
+}
+
+func (w *encryptingWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		// a full chunk is only sealed once more data arrives, the last chunk is sealed on close
+		if len(w.buf) == encryptionChunkSize {
+			if err := w.flush(false); err != nil {
+				return written, err
+			}
+		}
+		n := copy(w.buf[len(w.buf):cap(w.buf)], p)
+		w.buf = w.buf[:len(w.buf)+n]
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+func (w *encryptingWriter) flush(last bool) error {
+	w.sealed = w.aead.Seal(w.sealed[:0], chunkNonce(w.noncePrefix, w.counter, last), w.buf, w.header)
+	w.counter++
+	w.buf = w.buf[:0]
+	_, err := w.out.Write(w.sealed)
+	return err
+}
+
+func (w *encryptingWriter) Close() error {
+	err := w.flush(true)
+	if cerr := w.out.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+type decryptingReader struct {
+	in          *bufio.Reader
+	aead        cipher.AEAD
+	header      []byte
+	noncePrefix []byte
+	counter     uint32
+	chunk       []byte
+	plaintext   []byte
+	done        bool
+}
+
+// NewDecryptingReader returns the content of an encrypted recording file
+func NewDecryptingReader(in io.Reader, key []byte) (io.Reader, error) {
+	aead, err := newAEADFromKey(key)
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, len(encryptionMagic)+noncePrefixSize)
+	if _, err = io.ReadFull(in, header); err != nil || !bytes.Equal(header[:len(encryptionMagic)], encryptionMagic) {
+		return nil, ErrInvalidEncryptedFile
+	}
+
+	return &decryptingReader{
+		in:          bufio.NewReader(in),
+		aead:        aead,
+		header:      header,
+		noncePrefix: header[len(encryptionMagic):],
+		chunk:       make([]byte, encryptionChunkSize+aead.Overhead()),
+	}, nil
+}
+
+func (r *decryptingReader) Read(p []byte) (int, error) {
+	for len(r.plaintext) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		if err := r.readChunk(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, r.plaintext)
+	r.plaintext = r.plaintext[n:]
+	return n, nil
+}
+
+func (r *decryptingReader) readChunk() error {
+	n, err := io.ReadFull(r.in, r.chunk)
+	last := false
+	switch {
+	case err == io.ErrUnexpectedEOF || err == io.EOF:
+		last = true
+	case err != nil:
+		return err
+	default:
+		if _, perr := r.in.Peek(1); perr == io.EOF {
+			last = true
+		}
+	}
+	if n < r.aead.Overhead() {
+		return ErrInvalidEncryptedFile
+	}
+
+	plaintext, err := r.aead.Open(r.chunk[:0], chunkNonce(r.noncePrefix, r.counter, last), r.chunk[:n], r.header)
+	if err != nil {
+		return ErrInvalidEncryptedFile
+	}
+	r.counter++
+	r.plaintext = plaintext
+	r.done = last
+	return nil
+}
+
+// DecryptRecording writes the decrypted files of the recording stored in dir to outDir, it returns
+// the paths of written files. Uploaded files need to be downloaded back to dir first.
+func DecryptRecording(ctx context.Context, conf config.RecordingEncryptionConfig, dir string, outDir string) ([]string, error) {
+	info, err := readInfo(dir)
+	if err != nil {
+		return nil, err
+	}
+	if info.Encryption == nil {
+		return nil, errors.New("recording is not encrypted")
+	}
+
+	keys, err := NewKeyProvider(conf)
+	if err != nil {
+		return nil, err
+	}
+	key, err := keys.Unwrap(ctx, info.Encryption.KeyID, info.Encryption.WrappedKey)
+	if err != nil {
+		return nil, fmt.Errorf("could not unwrap key %s: %w", info.Encryption.KeyID, err)
+	}
+
+	if err = os.MkdirAll(outDir, 0755); err != nil {
+		return nil, err
+	}
+	var written []string
+	for _, f := range info.Files {
+		out := filepath.Join(outDir, strings.TrimSuffix(f.Filename, EncryptedExtension))
+		if err = decryptFile(filepath.Join(dir, f.Filename), out, key); err != nil {
+			return written, fmt.Errorf("%s: %w", f.Filename, err)
+		}
+		written = append(written, out)
+	}
+	return written, nil
+}
+
+func decryptFile(in string, out string, key []byte) error {
+	src, err := os.Open(in)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	r, err := NewDecryptingReader(src, key)
+	if err != nil {
+		return err
+	}
+	dst, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(dst, r); err != nil {
+		_ = dst.Close()
+		_ = os.Remove(out)
+		return err
+	}
+	return dst.Close()
+}
@@ -0,0 +1,135 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recording
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/livekit-server/pkg/config"
+)
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+func encryptBytes(t *testing.T, key []byte, plaintext []byte, writeSize int) []byte {
+	var out bytes.Buffer
+	w, err := newEncryptingWriter(nopWriteCloser{&out}, key)
+	require.NoError(t, err)
+	for len(plaintext) > 0 {
+		n := writeSize
+		if n > len(plaintext) {
+			n = len(plaintext)
+		}
+		_, err = w.Write(plaintext[:n])
+		require.NoError(t, err)
+		plaintext = plaintext[n:]
+	}
+	require.NoError(t, w.Close())
+	return out.Bytes()
+}
+
+func TestEncryptionRoundTrip(t *testing.T) {
+	key := make([]byte, 32)
+	_, _ = rand.Read(key)
+
+	for _, size := range []int{0, 100, encryptionChunkSize, 2*encryptionChunkSize + 1000} {
+		plaintext := make([]byte, size)
+		_, _ = rand.Read(plaintext)
+
+		encrypted := encryptBytes(t, key, plaintext, 1500)
+		if size > 0 {
+			require.NotContains(t, string(encrypted), string(plaintext[:size/2]))
+		}
+
+		r, err := NewDecryptingReader(bytes.NewReader(encrypted), key)
+		require.NoError(t, err)
+		decrypted, err := io.ReadAll(r)
+		require.NoError(t, err)
+		require.Equal(t, plaintext, decrypted, "size %d", size)
+	}
+}
+
+func TestEncryptionTampering(t *testing.T) {
+	key := make([]byte, 32)
+	_, _ = rand.Read(key)
+	plaintext := make([]byte, 2*encryptionChunkSize+1000)
+	encrypted := encryptBytes(t, key, plaintext, len(plaintext))
+
+	decrypt := func(data []byte, key []byte) error {
+		r, err := NewDecryptingReader(bytes.NewReader(data), key)
+		if err != nil {
+			return err
+		}
+		_, err = io.ReadAll(r)
+		return err
+	}
+
+	t.Run("truncated at chunk boundary", func(t *testing.T) {
+		headerSize := len(encryptionMagic) + noncePrefixSize
+		chunk := encryptionChunkSize + 16
+		require.ErrorIs(t, decrypt(encrypted[:headerSize+2*chunk], key), ErrInvalidEncryptedFile)
+	})
+
+	t.Run("modified", func(t *testing.T) {
+		modified := append([]byte{}, encrypted...)
+		modified[len(modified)/2] ^= 1
+		require.ErrorIs(t, decrypt(modified, key), ErrInvalidEncryptedFile)
+	})
+
+	t.Run("wrong key", func(t *testing.T) {
+		other := make([]byte, 32)
+		require.ErrorIs(t, decrypt(encrypted, other), ErrInvalidEncryptedFile)
+	})
+}
+
+func TestLocalKeyProvider(t *testing.T) {
+	master := make([]byte, 32)
+	_, _ = rand.Read(master)
+	conf := config.RecordingEncryptionConfig{
+		Enabled:      true,
+		Keys:         map[string]string{"exams": base64.StdEncoding.EncodeToString(master)},
+		DefaultKeyID: "default",
+		Rooms: []config.RecordingKeyRoomConfig{
+			{RoomPrefix: "exam-", KeyID: "exams"},
+		},
+	}
+	require.Equal(t, "exams", keyIDFor(conf, "exam-math"))
+	require.Equal(t, "default", keyIDFor(conf, "lecture"))
+
+	keys, err := NewKeyProvider(conf)
+	require.NoError(t, err)
+
+	dataKey := []byte("0123456789abcdef0123456789abcdef")
+	wrapped, err := keys.Wrap(context.Background(), "exams", "exam-math", dataKey)
+	require.NoError(t, err)
+	require.NotContains(t, string(wrapped), string(dataKey))
+
+	unwrapped, err := keys.Unwrap(context.Background(), "exams", wrapped)
+	require.NoError(t, err)
+	require.Equal(t, dataKey, unwrapped)
+
+	_, err = keys.Wrap(context.Background(), "default", "lecture", dataKey)
+	require.ErrorIs(t, err, ErrUnknownKey)
+}
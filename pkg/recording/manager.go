@@ -0,0 +1,504 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recording
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/storage"
+	"github.com/livekit/livekit-server/pkg/telemetry"
+	"github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/logger"
+	"github.com/livekit/protocol/utils"
+)
+
+const (
+	RecordingPrefix = "RC_"
+
+	EventRecordingStarted  = "recording_started"
+	EventRecordingProgress = "recording_progress"
+	EventRecordingPaused   = "recording_paused"
+	EventRecordingResumed  = "recording_resumed"
+	EventRecordingFinished = "recording_finished"
+	// sent for every finalized file, with only that file in the file results
+	EventRecordingFileFinished = "recording_file_finished"
+)
+
+var (
+	ErrRecordingNotFound = errors.New("recording not found")
+	ErrAlreadyRecording  = errors.New("room is already being recorded")
+	ErrInvalidState      = errors.New("recording is not in a state allowing this operation")
+	ErrRecordingActive   = errors.New("room has active recordings")
+	ErrUploadInProgress  = errors.New("recording is being uploaded")
+	ErrNoStorageProfile  = errors.New("no storage profile for the room")
+	ErrUploadFailed      = errors.New("recording upload failed")
+)
+
+// Manager runs the recordings of rooms hosted on this node
+type Manager struct {
+	conf      config.RecordingConfig
+	telemetry telemetry.TelemetryService
+	storage   *storage.Storage
+	keys      KeyProvider
+
+	lock     sync.RWMutex
+	sessions map[string]*session
+	// recordings with uploads in progress
+	uploading map[string]bool
+}
+
+// NewManager returns nil when recording is disabled
+func NewManager(conf *config.Config, ts telemetry.TelemetryService, store *storage.Storage) *Manager {
+	if !conf.Recording.Enabled {
+		return nil
+	}
+	m := &Manager{
+		conf:      conf.Recording,
+		telemetry: ts,
+		storage:   store,
+		sessions:  make(map[string]*session),
+		uploading: make(map[string]bool),
+	}
+	if conf.Recording.Encryption.Enabled {
+		keys, err := NewKeyProvider(conf.Recording.Encryption)
+		if err != nil {
+			// never fall back to writing unencrypted recordings
+			logger.Errorw("could not load recording encryption keys, recording disabled", err)
+			return nil
+		}
+		m.keys = keys
+	}
+	if store != nil {
+		go m.resumeUploads()
+	}
+	return m
+}
+
+func (m *Manager) Start(ctx context.Context, room Room) (*Info, error) {
+	var enc *EncryptionInfo
+	var key []byte
+	if m.keys != nil {
+		var err error
+		if enc, key, err = m.newDataKey(ctx, room.Name()); err != nil {
+			return nil, err
+		}
+	}
+
+	m.lock.Lock()
+	for _, s := range m.sessions {
+		if s.room.Name() == room.Name() {
+			m.lock.Unlock()
+			return nil, ErrAlreadyRecording
+		}
+	}
+
+	id := utils.NewGuid(RecordingPrefix)
+	s, err := newSession(id, room, filepath.Join(m.conf.OutputDir, id))
+	if err != nil {
+		m.lock.Unlock()
+		return nil, err
+	}
+	s.onFinished = m.onSessionFinished
+	s.onFileFinished = m.onFileFinished
+	s.container = m.conf.Container
+	s.segmentDuration = m.conf.SegmentDuration
+	s.info.StorageProfile = m.storage.ProfileFor(room.Name())
+	s.info.Encryption = enc
+	s.key = key
+	m.sessions[id] = s
+	m.lock.Unlock()
+
+	s.start()
+	go m.progressWorker(s)
+
+	info := s.snapshot()
+	m.notify(ctx, EventRecordingStarted, info)
+	return info, nil
+}
+
+// newDataKey generates the key encrypting the files of a recording
+func (m *Manager) newDataKey(ctx context.Context, roomName livekit.RoomName) (*EncryptionInfo, []byte, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, nil, err
+	}
+	keyID := keyIDFor(m.conf.Encryption, roomName)
+	wrapped, err := m.keys.Wrap(ctx, keyID, roomName, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &EncryptionInfo{
+		Algorithm:  EncryptionAlgorithm,
+		KeyID:      keyID,
+		WrappedKey: wrapped,
+	}, key, nil
+}
+
+func (m *Manager) Pause(ctx context.Context, id string) (*Info, error) {
+	return m.setPaused(ctx, id, true)
+}
+
+func (m *Manager) Resume(ctx context.Context, id string) (*Info, error) {
+	return m.setPaused(ctx, id, false)
+}
+
+func (m *Manager) setPaused(ctx context.Context, id string, paused bool) (*Info, error) {
+	s := m.getSession(id)
+	if s == nil {
+		return nil, ErrRecordingNotFound
+	}
+	if !s.setPaused(paused) {
+		return nil, ErrInvalidState
+	}
+
+	info := s.snapshot()
+	if paused {
+		m.notify(ctx, EventRecordingPaused, info)
+	} else {
+		m.notify(ctx, EventRecordingResumed, info)
+	}
+	return info, nil
+}
+
+func (m *Manager) Stop(_ context.Context, id string) (*Info, error) {
+	s := m.getSession(id)
+	if s == nil {
+		return nil, ErrRecordingNotFound
+	}
+	s.stop()
+	return s.snapshot(), nil
+}
+
+// List returns active recordings and recordings completed on this node, newest first.
+// All rooms are included when roomName is empty.
+func (m *Manager) List(roomName livekit.RoomName) ([]*Info, error) {
+	var infos []*Info
+	active := make(map[string]bool)
+
+	m.lock.RLock()
+	for id, s := range m.sessions {
+		if roomName == "" || s.room.Name() == roomName {
+			infos = append(infos, s.snapshot())
+		}
+		active[id] = true
+	}
+	m.lock.RUnlock()
+
+	entries, err := os.ReadDir(m.conf.OutputDir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() || active[entry.Name()] {
+			continue
+		}
+		info, err := readInfo(filepath.Join(m.conf.OutputDir, entry.Name()))
+		if err != nil {
+			continue
+		}
+		if roomName == "" || info.RoomName == roomName {
+			infos = append(infos, info)
+		}
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].StartedAt > infos[j].StartedAt
+	})
+	return infos, nil
+}
+
+// FilePath returns the local path of a recorded file
+func (m *Manager) FilePath(id string, filename string) (string, error) {
+	if id != sanitizeFilename(id) || filename != sanitizeFilename(filename) || filename == indexFilename {
+		return "", ErrRecordingNotFound
+	}
+	path := filepath.Join(m.conf.OutputDir, id, filename)
+	if _, err := os.Stat(path); err != nil {
+		return "", ErrRecordingNotFound
+	}
+	return path, nil
+}
+
+// Close stops all recordings, finalizing their files
+func (m *Manager) Close() {
+	if m == nil {
+		return
+	}
+
+	m.lock.RLock()
+	sessions := make([]*session, 0, len(m.sessions))
+	for _, s := range m.sessions {
+		sessions = append(sessions, s)
+	}
+	m.lock.RUnlock()
+
+	for _, s := range sessions {
+		s.stop()
+	}
+}
+
+func (m *Manager) getSession(id string) *session {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	return m.sessions[id]
+}
+
+func (m *Manager) progressWorker(s *session) {
+	ticker := time.NewTicker(m.conf.ProgressInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.doneChan:
+			return
+		case <-ticker.C:
+			if err := s.persist(); err != nil {
+				s.logger.Warnw("could not write recording index", err)
+			}
+			m.notify(context.Background(), EventRecordingProgress, s.snapshot())
+		}
+	}
+}
+
+func (m *Manager) onSessionFinished(s *session) {
+	m.lock.Lock()
+	delete(m.sessions, s.info.ID)
+	m.lock.Unlock()
+
+	info := s.snapshot()
+	s.logger.Infow("recording finished", "duration", time.Duration(info.Duration)*time.Millisecond, "files", len(info.Files))
+	if info.StorageProfile == "" {
+		m.notify(context.Background(), EventRecordingFinished, info)
+		return
+	}
+	// the finished event carries the uploaded locations
+	go m.upload(s.dir, info)
+}
+
+func (m *Manager) onFileFinished(s *session, file *FileInfo) {
+	s.logger.Debugw("recording file finished", "file", file.Filename, "size", file.Size)
+	info := s.snapshot()
+	info.Files = []*FileInfo{file}
+	m.notify(context.Background(), EventRecordingFileFinished, info)
+}
+
+// UploadRoom uploads the completed recordings of a room that were kept locally or failed to upload, to profile or
+// the profile of the room when empty. Recordings that already have a profile are retried with it. It fails while
+// recordings of the room are active or being uploaded, so callers can retry later
+func (m *Manager) UploadRoom(ctx context.Context, roomName livekit.RoomName, profile string) error {
+	if profile == "" {
+		profile = m.storage.ProfileFor(roomName)
+	}
+
+	infos, err := m.List(roomName)
+	if err != nil {
+		return err
+	}
+	for _, info := range infos {
+		if info.State != StateComplete {
+			return ErrRecordingActive
+		}
+	}
+
+	for _, info := range infos {
+		if isUploaded(info) {
+			continue
+		}
+		if info.StorageProfile == "" {
+			if profile == "" {
+				return ErrNoStorageProfile
+			}
+			info.StorageProfile = profile
+		}
+		if !m.beginUpload(info.ID) {
+			return ErrUploadInProgress
+		}
+		err := m.uploadFiles(ctx, filepath.Join(m.conf.OutputDir, info.ID), info)
+		m.endUpload(info.ID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// upload sends the files of a completed recording to its storage profile. Files are kept locally
+// when an upload fails, it is resumed on the next start
+func (m *Manager) upload(dir string, info *Info) {
+	if !m.beginUpload(info.ID) {
+		return
+	}
+	defer m.endUpload(info.ID)
+
+	ctx := context.Background()
+	_ = m.uploadFiles(ctx, dir, info)
+	m.notify(ctx, EventRecordingFinished, info)
+}
+
+// uploadFiles uploads the files of info that have no location yet and persists the resulting locations
+func (m *Manager) uploadFiles(ctx context.Context, dir string, info *Info) error {
+	l := logger.GetLogger().WithValues("recordingID", info.ID, "profile", info.StorageProfile)
+
+	failed := false
+	for _, f := range info.Files {
+		if f.Location != "" {
+			continue
+		}
+		key := m.storage.ObjectKey(info.StorageProfile, string(info.RoomName), info.ID, f.Filename)
+		contentType := f.MimeType
+		if info.Encryption != nil {
+			contentType = "application/octet-stream"
+		}
+		location, err := m.storage.Upload(ctx, info.StorageProfile, filepath.Join(dir, f.Filename), key, contentType)
+		if err != nil {
+			l.Errorw("could not upload recording file", err, "file", f.Filename)
+			failed = true
+			continue
+		}
+		f.Location = location
+	}
+	if !failed {
+		key := m.storage.ObjectKey(info.StorageProfile, string(info.RoomName), info.ID, ManifestFilename)
+		if _, err := m.storage.Upload(ctx, info.StorageProfile, filepath.Join(dir, ManifestFilename), key, "application/json"); err != nil {
+			l.Warnw("could not upload recording manifest", err)
+		}
+		l.Infow("recording uploaded")
+	}
+
+	if err := writeJSON(dir, indexFilename, info); err != nil {
+		l.Errorw("could not write recording index", err)
+	}
+	if failed {
+		return ErrUploadFailed
+	}
+	return nil
+}
+
+func (m *Manager) beginUpload(id string) bool {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.uploading[id] {
+		return false
+	}
+	m.uploading[id] = true
+	return true
+}
+
+func (m *Manager) endUpload(id string) {
+	m.lock.Lock()
+	delete(m.uploading, id)
+	m.lock.Unlock()
+}
+
+func isUploaded(info *Info) bool {
+	if info.StorageProfile == "" {
+		return false
+	}
+	for _, f := range info.Files {
+		if f.Location == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// resumeUploads picks up uploads interrupted by a restart
+func (m *Manager) resumeUploads() {
+	entries, err := os.ReadDir(m.conf.OutputDir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		dir := filepath.Join(m.conf.OutputDir, entry.Name())
+		info, err := readInfo(dir)
+		if err != nil || info.State != StateComplete || info.StorageProfile == "" {
+			continue
+		}
+		for _, f := range info.Files {
+			if f.Location == "" {
+				m.upload(dir, info)
+				break
+			}
+		}
+	}
+}
+
+func (m *Manager) notify(ctx context.Context, event string, info *Info) {
+	if m.telemetry == nil {
+		return
+	}
+	m.telemetry.NotifyEvent(ctx, &livekit.WebhookEvent{
+		Event:      event,
+		EgressInfo: info.ToEgressInfo(),
+	})
+}
+
+func readInfo(dir string) (*Info, error) {
+	data, err := os.ReadFile(filepath.Join(dir, indexFilename))
+	if err != nil {
+		return nil, err
+	}
+	info := &Info{}
+	if err = json.Unmarshal(data, info); err != nil {
+		logger.Warnw("invalid recording index", err, "dir", dir)
+		return nil, err
+	}
+	return info, nil
+}
+
+// ToEgressInfo maps a recording onto EgressInfo, so webhook consumers can reuse their egress handling
+func (i *Info) ToEgressInfo() *livekit.EgressInfo {
+	status := livekit.EgressStatus_EGRESS_ACTIVE
+	if i.State == StateComplete {
+		status = livekit.EgressStatus_EGRESS_COMPLETE
+	}
+
+	ei := &livekit.EgressInfo{
+		EgressId:  i.ID,
+		RoomId:    string(i.RoomID),
+		RoomName:  string(i.RoomName),
+		Status:    status,
+		StartedAt: i.StartedAt * int64(time.Millisecond),
+		EndedAt:   i.EndedAt * int64(time.Millisecond),
+		UpdatedAt: time.Now().UnixNano(),
+	}
+	for _, f := range i.Files {
+		location := f.Location
+		if location == "" {
+			location = f.DownloadURL
+		}
+		ei.FileResults = append(ei.FileResults, &livekit.FileInfo{
+			Filename:  f.Filename,
+			StartedAt: f.StartedAt * int64(time.Millisecond),
+			EndedAt:   f.EndedAt * int64(time.Millisecond),
+			Duration:  (f.EndedAt - f.StartedAt) * int64(time.Millisecond),
+			Size:      f.Size,
+			Location:  location,
+		})
+	}
+	return ei
+}
@@ -0,0 +1,136 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recording
+
+import (
+	"sort"
+	"time"
+
+	"github.com/livekit/protocol/livekit"
+)
+
+// ManifestFilename is written next to the recorded files
+const ManifestFilename = "manifest.json"
+
+const (
+	SyncSourceSenderReport = "rtcp_sr"
+	SyncSourceArrival      = "arrival"
+)
+
+// Manifest lets post-production tools align the files of a recording. Every track gets an offset
+// from the earliest track start, tracks of the same participant are aligned on the sender clock
+// advertised in RTCP sender reports, participants are aligned with each other on arrival time.
+type Manifest struct {
+	RecordingID string           `json:"recording_id"`
+	RoomName    livekit.RoomName `json:"room_name"`
+	// unix microseconds on the server clock all offsets are relative to
+	ReferenceTime int64            `json:"reference_time_us"`
+	Tracks        []*ManifestTrack `json:"tracks"`
+	// set when files are encrypted, all files share the data key
+	Encryption *EncryptionInfo `json:"encryption,omitempty"`
+}
+
+type ManifestTrack struct {
+	Filename            string                      `json:"filename"`
+	TrackID             livekit.TrackID             `json:"track_id"`
+	ParticipantIdentity livekit.ParticipantIdentity `json:"participant_identity"`
+	Kind                string                      `json:"kind"`
+	Source              string                      `json:"source"`
+	MimeType            string                      `json:"mime_type"`
+	ClockRate           uint32                      `json:"clock_rate"`
+	// RTP timestamp of the first sample in the file
+	FirstRTPTimestamp uint32 `json:"first_rtp_timestamp"`
+	// unix microseconds of the first sample on the sender clock, only set when synced on sender reports
+	SenderStartTime int64 `json:"sender_start_time_us,omitempty"`
+	// unix microseconds the first sample arrived at the server
+	ArrivalTime int64 `json:"arrival_time_us"`
+	// microseconds between the reference time and the first sample of the file
+	StartOffset int64  `json:"start_offset_us"`
+	SyncSource  string `json:"sync_source"`
+}
+
+// trackSync holds the timing of the first sample written to a file
+type trackSync struct {
+	file              *FileInfo
+	clockRate         uint32
+	firstRTPTimestamp uint32
+	arrivalAt         time.Time
+	senderStartAt     time.Time
+}
+
+func buildManifest(id string, roomName livekit.RoomName, syncs []*trackSync) *Manifest {
+	m := &Manifest{
+		RecordingID: id,
+		RoomName:    roomName,
+		Tracks:      []*ManifestTrack{},
+	}
+
+	// smallest arrival - sender time of each participant, the least delayed packet gives the best
+	// estimate of the offset between the participant clock and ours
+	clockOffsets := make(map[livekit.ParticipantIdentity]time.Duration)
+	for _, ts := range syncs {
+		if ts.arrivalAt.IsZero() || ts.senderStartAt.IsZero() {
+			continue
+		}
+		offset := ts.arrivalAt.Sub(ts.senderStartAt)
+		if current, ok := clockOffsets[ts.file.ParticipantIdentity]; !ok || offset < current {
+			clockOffsets[ts.file.ParticipantIdentity] = offset
+		}
+	}
+
+	starts := make([]time.Time, 0, len(syncs))
+	var reference time.Time
+	for _, ts := range syncs {
+		if ts.arrivalAt.IsZero() {
+			// nothing written yet
+			continue
+		}
+
+		t := &ManifestTrack{
+			Filename:            ts.file.Filename,
+			TrackID:             ts.file.TrackID,
+			ParticipantIdentity: ts.file.ParticipantIdentity,
+			Kind:                ts.file.Kind,
+			Source:              ts.file.Source,
+			MimeType:            ts.file.MimeType,
+			ClockRate:           ts.clockRate,
+			FirstRTPTimestamp:   ts.firstRTPTimestamp,
+			ArrivalTime:         ts.arrivalAt.UnixMicro(),
+			SyncSource:          SyncSourceArrival,
+		}
+		start := ts.arrivalAt
+		if !ts.senderStartAt.IsZero() {
+			t.SenderStartTime = ts.senderStartAt.UnixMicro()
+			t.SyncSource = SyncSourceSenderReport
+			start = ts.senderStartAt.Add(clockOffsets[ts.file.ParticipantIdentity])
+		}
+		if reference.IsZero() || start.Before(reference) {
+			reference = start
+		}
+		m.Tracks = append(m.Tracks, t)
+		starts = append(starts, start)
+	}
+
+	if !reference.IsZero() {
+		m.ReferenceTime = reference.UnixMicro()
+	}
+	for i, t := range m.Tracks {
+		t.StartOffset = starts[i].Sub(reference).Microseconds()
+	}
+	sort.SliceStable(m.Tracks, func(i, j int) bool {
+		return m.Tracks[i].StartOffset < m.Tracks[j].StartOffset
+	})
+	return m
+}
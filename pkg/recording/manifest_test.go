@@ -0,0 +1,83 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recording
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/protocol/livekit"
+)
+
+func TestBuildManifest(t *testing.T) {
+	t0 := time.Now()
+	// sender clock running one hour behind ours
+	senderT0 := t0.Add(-time.Hour)
+
+	syncs := []*trackSync{
+		{
+			file:              &FileInfo{Filename: "a_video.ivf", TrackID: "TR_video", ParticipantIdentity: "a", Kind: "VIDEO"},
+			clockRate:         90000,
+			firstRTPTimestamp: 9000,
+			arrivalAt:         t0.Add(180 * time.Millisecond),
+			senderStartAt:     senderT0.Add(100 * time.Millisecond),
+		},
+		{
+			file:              &FileInfo{Filename: "a_audio.ogg", TrackID: "TR_audio", ParticipantIdentity: "a", Kind: "AUDIO"},
+			clockRate:         48000,
+			firstRTPTimestamp: 4800,
+			arrivalAt:         t0.Add(50 * time.Millisecond),
+			senderStartAt:     senderT0,
+		},
+		{
+			file:      &FileInfo{Filename: "b_audio.ogg", TrackID: "TR_b", ParticipantIdentity: "b", Kind: "AUDIO"},
+			clockRate: 48000,
+			arrivalAt: t0.Add(20 * time.Millisecond),
+		},
+		{
+			// nothing written yet
+			file: &FileInfo{Filename: "c_audio.ogg", TrackID: "TR_c", ParticipantIdentity: "c", Kind: "AUDIO"},
+		},
+	}
+
+	m := buildManifest("RC_test", "room", syncs)
+	require.Equal(t, "RC_test", m.RecordingID)
+	require.Equal(t, livekit.RoomName("room"), m.RoomName)
+	require.Equal(t, t0.Add(20*time.Millisecond).UnixMicro(), m.ReferenceTime)
+	require.Len(t, m.Tracks, 3)
+
+	require.Equal(t, livekit.TrackID("TR_b"), m.Tracks[0].TrackID)
+	require.Equal(t, int64(0), m.Tracks[0].StartOffset)
+	require.Equal(t, SyncSourceArrival, m.Tracks[0].SyncSource)
+	require.Zero(t, m.Tracks[0].SenderStartTime)
+
+	// audio and video of a are aligned on the sender clock, not on their arrival
+	require.Equal(t, livekit.TrackID("TR_audio"), m.Tracks[1].TrackID)
+	require.Equal(t, int64(30_000), m.Tracks[1].StartOffset)
+	require.Equal(t, SyncSourceSenderReport, m.Tracks[1].SyncSource)
+	require.Equal(t, uint32(4800), m.Tracks[1].FirstRTPTimestamp)
+
+	require.Equal(t, livekit.TrackID("TR_video"), m.Tracks[2].TrackID)
+	require.Equal(t, int64(130_000), m.Tracks[2].StartOffset)
+	require.Equal(t, SyncSourceSenderReport, m.Tracks[2].SyncSource)
+}
+
+func TestBuildManifest_Empty(t *testing.T) {
+	m := buildManifest("RC_test", "room", nil)
+	require.Zero(t, m.ReferenceTime)
+	require.Empty(t, m.Tracks)
+}
@@ -0,0 +1,320 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recording
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/pion/rtp"
+	"github.com/pion/rtp/codecs"
+	"github.com/pion/webrtc/v3"
+	"github.com/pion/webrtc/v3/pkg/media/samplebuilder"
+)
+
+const (
+	ContainerNative = "native"
+	ContainerMKV    = "mkv"
+
+	// packets a sample may arrive late before it is given up on
+	mkvMaxLatePackets = 200
+	// clusters are cut at this duration, or on video key frames once at least mkvMinClusterMs long
+	mkvMaxClusterMs = 5000
+	mkvMinClusterMs = 1000
+	mkvTrackNumber  = 1
+)
+
+// matroska element IDs
+const (
+	mkvEBML               = 0x1A45DFA3
+	mkvEBMLVersion        = 0x4286
+	mkvEBMLReadVersion    = 0x42F7
+	mkvEBMLMaxIDLength    = 0x42F2
+	mkvEBMLMaxSizeLength  = 0x42F3
+	mkvDocType            = 0x4282
+	mkvDocTypeVersion     = 0x4287
+	mkvDocTypeReadVersion = 0x4285
+	mkvSegment            = 0x18538067
+	mkvInfo               = 0x1549A966
+	mkvTimecodeScale      = 0x2AD7B1
+	mkvMuxingApp          = 0x4D80
+	mkvWritingApp         = 0x5741
+	mkvTracks             = 0x1654AE6B
+	mkvTrackEntry         = 0xAE
+	mkvTrackNumberID      = 0xD7
+	mkvTrackUID           = 0x73C5
+	mkvTrackType          = 0x83
+	mkvCodecID            = 0x86
+	mkvCodecPrivate       = 0x63A2
+	mkvVideo              = 0xE0
+	mkvPixelWidth         = 0xB0
+	mkvPixelHeight        = 0xBA
+	mkvAudio              = 0xE1
+	mkvSamplingFrequency  = 0xB5
+	mkvChannels           = 0x9F
+	mkvCluster            = 0x1F43B675
+	mkvTimecode           = 0xE7
+	mkvSimpleBlock        = 0xA3
+)
+
+// supportsMKV returns true for codecs the matroska writer can mux
+func supportsMKV(mimeType string) bool {
+	return strings.EqualFold(mimeType, webrtc.MimeTypeOpus) || strings.EqualFold(mimeType, webrtc.MimeTypeVP8)
+}
+
+// mkvWriter muxes a single Opus or VP8 track into matroska. The segment is written with an unknown
+// size and clusters are only written once complete, so the file is never seeked and stays playable
+// when the server stops without closing it.
+type mkvWriter struct {
+	out       io.WriteCloser
+	isVideo   bool
+	clockRate uint32
+	builder   *samplebuilder.SampleBuilder
+
+	headerWritten bool
+	// RTP timestamps are unwrapped relative to the first sample
+	lastTimestamp uint32
+	elapsed       int64
+
+	cluster      bytes.Buffer
+	clusterStart int64
+	clusterOpen  bool
+}
+
+func newMKVWriter(out io.WriteCloser, mimeType string) (*mkvWriter, error) {
+	if !supportsMKV(mimeType) {
+		return nil, ErrUnsupportedCodec
+	}
+	w := &mkvWriter{
+		out:     out,
+		isVideo: strings.EqualFold(mimeType, webrtc.MimeTypeVP8),
+	}
+	if w.isVideo {
+		w.clockRate = 90000
+		w.builder = samplebuilder.New(mkvMaxLatePackets, &codecs.VP8Packet{}, w.clockRate)
+	} else {
+		w.clockRate = 48000
+		w.builder = samplebuilder.New(mkvMaxLatePackets, &codecs.OpusPacket{}, w.clockRate)
+	}
+	return w, nil
+}
+
+func (w *mkvWriter) WriteRTP(packet *rtp.Packet) error {
+	// packets are shared with subscribers and buffered by the sample builder
+	pkt := *packet
+	pkt.Payload = append([]byte(nil), packet.Payload...)
+	w.builder.Push(&pkt)
+
+	for {
+		sample := w.builder.Pop()
+		if sample == nil {
+			return nil
+		}
+		if err := w.writeSample(sample.Data, sample.PacketTimestamp); err != nil {
+			return err
+		}
+	}
+}
+
+func (w *mkvWriter) writeSample(data []byte, timestamp uint32) error {
+	if len(data) == 0 {
+		return nil
+	}
+	keyFrame := !w.isVideo || data[0]&0x01 == 0
+	if !w.headerWritten {
+		// video tracks need the dimensions of the first key frame
+		if !keyFrame {
+			return nil
+		}
+		if err := w.writeHeader(data); err != nil {
+			return err
+		}
+		w.headerWritten = true
+		w.lastTimestamp = timestamp
+	}
+
+	w.elapsed += int64(int32(timestamp - w.lastTimestamp))
+	w.lastTimestamp = timestamp
+	ms := w.elapsed * 1000 / int64(w.clockRate)
+
+	if w.clusterOpen {
+		length := ms - w.clusterStart
+		if length >= mkvMaxClusterMs || length < 0 || (w.isVideo && keyFrame && length >= mkvMinClusterMs) {
+			if err := w.flushCluster(); err != nil {
+				return err
+			}
+		}
+	}
+	if !w.clusterOpen {
+		w.cluster.Reset()
+		writeUintElement(&w.cluster, mkvTimecode, uint64(ms))
+		w.clusterStart = ms
+		w.clusterOpen = true
+	}
+
+	block := make([]byte, 0, len(data)+4)
+	block = append(block, 0x80|mkvTrackNumber)
+	block = binary.BigEndian.AppendUint16(block, uint16(int16(ms-w.clusterStart)))
+	var flags byte
+	if keyFrame {
+		flags |= 0x80
+	}
+	block = append(block, flags)
+	block = append(block, data...)
+	writeElement(&w.cluster, mkvSimpleBlock, block)
+	return nil
+}
+
+func (w *mkvWriter) flushCluster() error {
+	if !w.clusterOpen {
+		return nil
+	}
+	w.clusterOpen = false
+	var buf bytes.Buffer
+	writeElement(&buf, mkvCluster, w.cluster.Bytes())
+	_, err := w.out.Write(buf.Bytes())
+	return err
+}
+
+func (w *mkvWriter) writeHeader(keyFrame []byte) error {
+	var buf, ebml, info, tracks, entry bytes.Buffer
+
+	writeUintElement(&ebml, mkvEBMLVersion, 1)
+	writeUintElement(&ebml, mkvEBMLReadVersion, 1)
+	writeUintElement(&ebml, mkvEBMLMaxIDLength, 4)
+	writeUintElement(&ebml, mkvEBMLMaxSizeLength, 8)
+	writeElement(&ebml, mkvDocType, []byte("matroska"))
+	writeUintElement(&ebml, mkvDocTypeVersion, 4)
+	writeUintElement(&ebml, mkvDocTypeReadVersion, 2)
+	writeElement(&buf, mkvEBML, ebml.Bytes())
+
+	// unknown size, the segment ends with the file
+	writeID(&buf, mkvSegment)
+	buf.Write([]byte{0x01, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF})
+
+	// timecodes are in milliseconds
+	writeUintElement(&info, mkvTimecodeScale, 1000000)
+	writeElement(&info, mkvMuxingApp, []byte("livekit-server"))
+	writeElement(&info, mkvWritingApp, []byte("livekit-server"))
+	writeElement(&buf, mkvInfo, info.Bytes())
+
+	writeUintElement(&entry, mkvTrackNumberID, mkvTrackNumber)
+	writeUintElement(&entry, mkvTrackUID, mkvTrackNumber)
+	if w.isVideo {
+		writeUintElement(&entry, mkvTrackType, 1)
+		writeElement(&entry, mkvCodecID, []byte("V_VP8"))
+		var video bytes.Buffer
+		width, height := vp8Dimensions(keyFrame)
+		writeUintElement(&video, mkvPixelWidth, uint64(width))
+		writeUintElement(&video, mkvPixelHeight, uint64(height))
+		writeElement(&entry, mkvVideo, video.Bytes())
+	} else {
+		writeUintElement(&entry, mkvTrackType, 2)
+		writeElement(&entry, mkvCodecID, []byte("A_OPUS"))
+		writeElement(&entry, mkvCodecPrivate, opusHead(2, 48000))
+		var audio bytes.Buffer
+		writeFloatElement(&audio, mkvSamplingFrequency, 48000)
+		writeUintElement(&audio, mkvChannels, 2)
+		writeElement(&entry, mkvAudio, audio.Bytes())
+	}
+	writeElement(&tracks, mkvTrackEntry, entry.Bytes())
+	writeElement(&buf, mkvTracks, tracks.Bytes())
+
+	_, err := w.out.Write(buf.Bytes())
+	return err
+}
+
+func (w *mkvWriter) Close() error {
+	err := w.flushCluster()
+	if closeErr := w.out.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// vp8Dimensions reads the frame size from the header of a VP8 key frame
+func vp8Dimensions(frame []byte) (uint16, uint16) {
+	if len(frame) < 10 {
+		return 0, 0
+	}
+	width := binary.LittleEndian.Uint16(frame[6:8]) & 0x3fff
+	height := binary.LittleEndian.Uint16(frame[8:10]) & 0x3fff
+	return width, height
+}
+
+// opusHead is the codec private data of Opus tracks, RFC 7845 identification header
+func opusHead(channels uint8, sampleRate uint32) []byte {
+	head := []byte("OpusHead")
+	head = append(head, 1, channels)
+	// pre-skip
+	head = binary.LittleEndian.AppendUint16(head, 3840)
+	head = binary.LittleEndian.AppendUint32(head, sampleRate)
+	// output gain and channel mapping family
+	head = append(head, 0, 0, 0)
+	return head
+}
+
+func writeID(buf *bytes.Buffer, id uint32) {
+	switch {
+	case id >= 1<<24:
+		buf.Write([]byte{byte(id >> 24), byte(id >> 16), byte(id >> 8), byte(id)})
+	case id >= 1<<16:
+		buf.Write([]byte{byte(id >> 16), byte(id >> 8), byte(id)})
+	case id >= 1<<8:
+		buf.Write([]byte{byte(id >> 8), byte(id)})
+	default:
+		buf.WriteByte(byte(id))
+	}
+}
+
+// writeSize writes an EBML variable size integer using the smallest length, all ones is reserved
+// for unknown sizes
+func writeSize(buf *bytes.Buffer, size uint64) {
+	length := 1
+	for length < 8 && size >= 1<<(7*length)-1 {
+		length++
+	}
+	encoded := size | 1<<(7*length)
+	for i := length - 1; i >= 0; i-- {
+		buf.WriteByte(byte(encoded >> (8 * i)))
+	}
+}
+
+func writeElement(buf *bytes.Buffer, id uint32, data []byte) {
+	writeID(buf, id)
+	writeSize(buf, uint64(len(data)))
+	buf.Write(data)
+}
+
+func writeUintElement(buf *bytes.Buffer, id uint32, v uint64) {
+	length := 1
+	for length < 8 && v >= 1<<(8*length) {
+		length++
+	}
+	data := make([]byte, length)
+	for i := 0; i < length; i++ {
+		data[length-1-i] = byte(v >> (8 * i))
+	}
+	writeElement(buf, id, data)
+}
+
+func writeFloatElement(buf *bytes.Buffer, id uint32, v float64) {
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint64(data, math.Float64bits(v))
+	writeElement(buf, id, data)
+}
@@ -0,0 +1,106 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recording
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteSize(t *testing.T) {
+	for _, tc := range []struct {
+		size     uint64
+		expected []byte
+	}{
+		{0, []byte{0x80}},
+		{126, []byte{0xFE}},
+		// all ones is reserved for unknown sizes
+		{127, []byte{0x40, 0x7F}},
+		{16382, []byte{0x7F, 0xFE}},
+		{16383, []byte{0x20, 0x3F, 0xFF}},
+	} {
+		var buf bytes.Buffer
+		writeSize(&buf, tc.size)
+		require.Equal(t, tc.expected, buf.Bytes(), "size %d", tc.size)
+	}
+}
+
+func TestMKVWriter(t *testing.T) {
+	t.Run("unsupported codec", func(t *testing.T) {
+		_, err := newMKVWriter(nopWriteCloser{&bytes.Buffer{}}, webrtc.MimeTypeH264)
+		require.ErrorIs(t, err, ErrUnsupportedCodec)
+	})
+
+	t.Run("opus clusters", func(t *testing.T) {
+		var out bytes.Buffer
+		w, err := newMKVWriter(nopWriteCloser{&out}, webrtc.MimeTypeOpus)
+		require.NoError(t, err)
+
+		// 6s of 20ms frames
+		for i := 0; i < 300; i++ {
+			require.NoError(t, w.WriteRTP(&rtp.Packet{
+				Header: rtp.Header{
+					SequenceNumber: uint16(65500 + i),
+					Timestamp:      uint32(4294960000 + i*960),
+				},
+				Payload: []byte{0xfc, byte(i)},
+			}))
+		}
+		require.NoError(t, w.Close())
+
+		data := out.Bytes()
+		require.True(t, bytes.HasPrefix(data, []byte{0x1A, 0x45, 0xDF, 0xA3}))
+		require.True(t, bytes.Contains(data, []byte("A_OPUS")))
+		require.True(t, bytes.Contains(data, opusHead(2, 48000)))
+		// timestamps wrap, clusters are cut every 5s
+		require.Equal(t, 2, bytes.Count(data, []byte{0x1F, 0x43, 0xB6, 0x75}))
+	})
+
+	t.Run("vp8 starts on key frame", func(t *testing.T) {
+		var out bytes.Buffer
+		w, err := newMKVWriter(nopWriteCloser{&out}, webrtc.MimeTypeVP8)
+		require.NoError(t, err)
+
+		// 640x360 key frame after an inter frame
+		keyFrame := []byte{0x10, 0x00, 0x00, 0x00, 0x9d, 0x01, 0x2a, 0x80, 0x02, 0x68, 0x01}
+		interFrame := []byte{0x10, 0x01, 0x00, 0x00}
+		frames := [][]byte{interFrame, keyFrame, interFrame, interFrame}
+		for i, frame := range frames {
+			require.NoError(t, w.WriteRTP(&rtp.Packet{
+				Header: rtp.Header{
+					SequenceNumber: uint16(i),
+					Timestamp:      uint32(i * 3000),
+					Marker:         true,
+				},
+				Payload: frame,
+			}))
+		}
+		require.NoError(t, w.Close())
+
+		data := out.Bytes()
+		require.True(t, bytes.Contains(data, []byte("V_VP8")))
+		// pixel width and height
+		require.True(t, bytes.Contains(data, []byte{0xB0, 0x82, 0x02, 0x80, 0xBA, 0x82, 0x01, 0x68}))
+		// the first block is the key frame, the descriptor is stripped and the key frame flag set
+		frame := bytes.Index(data, keyFrame[1:])
+		require.Greater(t, frame, 0)
+		require.Equal(t, byte(0x80), data[frame-1])
+		require.Less(t, bytes.Index(data, []byte{0xA3}), frame)
+	})
+}
@@ -0,0 +1,501 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recording
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/livekit/livekit-server/pkg/rtc/types"
+	"github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/logger"
+)
+
+const (
+	syncInterval  = time.Second
+	indexFilename = "recording.json"
+)
+
+type State string
+
+const (
+	StateActive   State = "active"
+	StatePaused   State = "paused"
+	StateComplete State = "complete"
+)
+
+// Info describes a recording, it is persisted next to the recorded files
+type Info struct {
+	ID       string           `json:"id"`
+	RoomName livekit.RoomName `json:"room_name"`
+	RoomID   livekit.RoomID   `json:"room_id"`
+	State    State            `json:"state"`
+	// unix milliseconds
+	StartedAt int64 `json:"started_at"`
+	EndedAt   int64 `json:"ended_at,omitempty"`
+	// recorded time in milliseconds, excluding pauses
+	Duration int64       `json:"duration"`
+	Files    []*FileInfo `json:"files"`
+	// storage profile files are uploaded to once the recording completes
+	StorageProfile string          `json:"storage_profile,omitempty"`
+	Encryption     *EncryptionInfo `json:"encryption,omitempty"`
+	// only set in listings, the manifest holds the offsets needed to align the files
+	ManifestURL string `json:"manifest_url,omitempty"`
+}
+
+type FileInfo struct {
+	Filename            string                      `json:"filename"`
+	TrackID             livekit.TrackID             `json:"track_id"`
+	ParticipantIdentity livekit.ParticipantIdentity `json:"participant_identity"`
+	Kind                string                      `json:"kind"`
+	Source              string                      `json:"source"`
+	MimeType            string                      `json:"mime_type"`
+	// unix milliseconds of the first and last written packets
+	StartedAt int64 `json:"started_at,omitempty"`
+	EndedAt   int64 `json:"ended_at,omitempty"`
+	Size      int64 `json:"size"`
+	// location in object storage once uploaded
+	Location string `json:"location,omitempty"`
+	// only set in listings
+	DownloadURL string `json:"download_url,omitempty"`
+}
+
+// Room is the part of a room needed by the recorder
+type Room interface {
+	Name() livekit.RoomName
+	ID() livekit.RoomID
+	IsClosed() bool
+	GetParticipants() []types.LocalParticipant
+}
+
+// dynacast needs to keep forwarding layers nobody else subscribes to
+type maxQualityNotifier interface {
+	NotifySubscriberNodeMaxQuality(nodeID livekit.NodeID, qualities []types.SubscribedCodecQuality)
+}
+
+type recordedTrack struct {
+	file   *FileInfo
+	sync   *trackSync
+	writer *trackWriter
+	track  types.MediaTrack
+}
+
+// session records every published track of a room until stopped or the room closes
+type session struct {
+	logger logger.Logger
+	room   Room
+	dir    string
+	// data key of encrypted recordings
+	key             []byte
+	container       string
+	segmentDuration time.Duration
+
+	lock        sync.Mutex
+	info        *Info
+	tracks      map[livekit.TrackID]*recordedTrack
+	syncs       []*trackSync
+	activeSince time.Time
+	recorded    time.Duration
+
+	onFinished     func(s *session)
+	onFileFinished func(s *session, file *FileInfo)
+	doneChan       chan struct{}
+	doneOnce       sync.Once
+}
+
+func newSession(id string, room Room, dir string) (*session, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	s := &session{
+		logger: logger.GetLogger().WithValues("room", room.Name(), "roomID", room.ID(), "recordingID", id),
+		room:   room,
+		dir:    dir,
+		info: &Info{
+			ID:        id,
+			RoomName:  room.Name(),
+			RoomID:    room.ID(),
+			State:     StateActive,
+			StartedAt: now.UnixMilli(),
+			Files:     []*FileInfo{},
+		},
+		tracks:      make(map[livekit.TrackID]*recordedTrack),
+		activeSince: now,
+		doneChan:    make(chan struct{}),
+	}
+	return s, nil
+}
+
+func (s *session) nodeID() livekit.NodeID {
+	return livekit.NodeID("recorder_" + s.info.ID)
+}
+
+func (s *session) start() {
+	s.sync()
+	go s.worker()
+}
+
+func (s *session) worker() {
+	ticker := time.NewTicker(syncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.doneChan:
+			return
+		case <-ticker.C:
+			if s.room.IsClosed() {
+				s.stop()
+				return
+			}
+			s.sync()
+		}
+	}
+}
+
+// sync attaches writers to newly published tracks and picks up rotated segments
+func (s *session) sync() {
+	s.lock.Lock()
+	if s.info.State == StateComplete {
+		s.lock.Unlock()
+		return
+	}
+
+	var finished []*FileInfo
+	for _, t := range s.tracks {
+		if t.writer != nil {
+			finished = append(finished, s.collectSegmentsLocked(t)...)
+		}
+	}
+
+	for _, p := range s.room.GetParticipants() {
+		if !p.GetEntitlements().GetAllowRecording() {
+			continue
+		}
+		for _, track := range p.GetPublishedTracks() {
+			if _, ok := s.tracks[track.ID()]; ok || !track.IsOpen() || track.IsEncrypted() {
+				continue
+			}
+			s.attachLocked(p.Identity(), track)
+		}
+	}
+	s.lock.Unlock()
+
+	s.notifyFilesFinished(finished)
+}
+
+func (s *session) notifyFilesFinished(files []*FileInfo) {
+	if s.onFileFinished == nil {
+		return
+	}
+	for _, f := range files {
+		s.onFileFinished(s, f)
+	}
+}
+
+func (s *session) attachLocked(identity livekit.ParticipantIdentity, track types.MediaTrack) {
+	receivers := track.Receivers()
+	if len(receivers) == 0 {
+		return
+	}
+	// the primary codec is recorded when a track is published with several codecs
+	receiver := receivers[0]
+	mimeType := receiver.Codec().MimeType
+	ext := fileExtension(mimeType, s.container)
+	if ext == "" {
+		s.logger.Infow("skipping track with unsupported codec", "trackID", track.ID(), "mime", mimeType)
+		// do not retry on every sync
+		s.tracks[track.ID()] = &recordedTrack{track: track}
+		return
+	}
+
+	base := sanitizeFilename(string(identity) + "_" + string(track.ID()))
+	filename := func(segment int) string {
+		name := base
+		if s.segmentDuration > 0 {
+			name = fmt.Sprintf("%s_%04d", base, segment)
+		}
+		name += ext
+		if s.key != nil {
+			name += EncryptedExtension
+		}
+		return name
+	}
+	file := &FileInfo{
+		Filename:            filename(0),
+		TrackID:             track.ID(),
+		ParticipantIdentity: identity,
+		Kind:                track.Kind().String(),
+		Source:              track.Source().String(),
+		MimeType:            mimeType,
+	}
+	isVideo := track.Kind() == livekit.TrackType_VIDEO
+	writer, err := newTrackWriter(trackWriterParams{
+		Dir:             s.dir,
+		Filename:        filename,
+		Key:             s.key,
+		Container:       s.container,
+		SegmentDuration: s.segmentDuration,
+		SubscriberID:    livekit.ParticipantID(s.nodeID()),
+		Receiver:        receiver,
+		IsVideo:         isVideo,
+		Logger:          s.logger.WithValues("trackID", track.ID()),
+	})
+	if err != nil {
+		s.logger.Warnw("could not record track", err, "trackID", track.ID())
+		s.tracks[track.ID()] = &recordedTrack{track: track}
+		return
+	}
+	rt := &recordedTrack{
+		file: file,
+		sync: &trackSync{
+			file:      file,
+			clockRate: receiver.Codec().ClockRate,
+		},
+		writer: writer,
+		track:  track,
+	}
+	writer.SetPaused(s.info.State == StatePaused)
+	writer.OnClose(func() {
+		s.lock.Lock()
+		finished := s.collectSegmentsLocked(rt)
+		s.updateTrackLocked(rt.sync, writer)
+		last := *rt.file
+		s.lock.Unlock()
+
+		s.notifyFilesFinished(append(finished, &last))
+	})
+
+	if err = receiver.AddDownTrack(writer); err != nil {
+		s.logger.Warnw("could not attach recorder to track", err, "trackID", track.ID())
+		// not attached, the session lock is held
+		writer.OnClose(nil)
+		writer.Close()
+		return
+	}
+	if isVideo {
+		if n, ok := track.(maxQualityNotifier); ok {
+			n.NotifySubscriberNodeMaxQuality(s.nodeID(), []types.SubscribedCodecQuality{
+				{CodecMime: mimeType, Quality: livekit.VideoQuality_HIGH},
+			})
+		}
+	}
+
+	s.tracks[track.ID()] = rt
+	s.info.Files = append(s.info.Files, file)
+	s.syncs = append(s.syncs, rt.sync)
+	s.logger.Infow("recording track", "trackID", track.ID(), "file", file.Filename)
+}
+
+// collectSegmentsLocked finalizes the files of rotated segments and starts a file for the
+// segment being written, returns copies of the finished files
+func (s *session) collectSegmentsLocked(t *recordedTrack) []*FileInfo {
+	segments := t.writer.TakeFinishedSegments()
+	if len(segments) == 0 {
+		return nil
+	}
+
+	current := t.writer.Filename()
+	finished := make([]*FileInfo, 0, len(segments))
+	for i, seg := range segments {
+		s.updateSegmentLocked(t.sync, seg)
+		file := *t.file
+		finished = append(finished, &file)
+
+		next := current
+		if i+1 < len(segments) {
+			next = segments[i+1].filename
+		}
+		nextFile := &FileInfo{
+			Filename:            next,
+			TrackID:             t.file.TrackID,
+			ParticipantIdentity: t.file.ParticipantIdentity,
+			Kind:                t.file.Kind,
+			Source:              t.file.Source,
+			MimeType:            t.file.MimeType,
+		}
+		t.file = nextFile
+		t.sync = &trackSync{
+			file:      nextFile,
+			clockRate: t.sync.clockRate,
+		}
+		s.info.Files = append(s.info.Files, nextFile)
+		s.syncs = append(s.syncs, t.sync)
+	}
+	return finished
+}
+
+func (s *session) updateTrackLocked(ts *trackSync, writer *trackWriter) {
+	if writer.Filename() != ts.file.Filename {
+		// rotated, picked up on the next sync
+		return
+	}
+	seg := segmentTiming{filename: ts.file.Filename}
+	seg.firstPacketAt, seg.lastPacketAt = writer.PacketTimes()
+	seg.firstRTPTimestamp, seg.senderStartAt = writer.SyncInfo()
+	s.updateSegmentLocked(ts, seg)
+}
+
+func (s *session) updateSegmentLocked(ts *trackSync, seg segmentTiming) {
+	file := ts.file
+	if !seg.firstPacketAt.IsZero() {
+		file.StartedAt = seg.firstPacketAt.UnixMilli()
+		file.EndedAt = seg.lastPacketAt.UnixMilli()
+		ts.arrivalAt = seg.firstPacketAt
+		ts.firstRTPTimestamp, ts.senderStartAt = seg.firstRTPTimestamp, seg.senderStartAt
+	}
+	if st, err := os.Stat(filepath.Join(s.dir, file.Filename)); err == nil {
+		file.Size = st.Size()
+	}
+}
+
+func (s *session) setPaused(paused bool) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	switch {
+	case paused && s.info.State == StateActive:
+		s.info.State = StatePaused
+		s.recorded += time.Since(s.activeSince)
+	case !paused && s.info.State == StatePaused:
+		s.info.State = StateActive
+		s.activeSince = time.Now()
+	default:
+		return false
+	}
+
+	for _, t := range s.tracks {
+		if t.writer != nil {
+			t.writer.SetPaused(paused)
+		}
+	}
+	return true
+}
+
+// stop finalizes all files, returns false if the session was already stopped
+func (s *session) stop() bool {
+	stopped := false
+	s.doneOnce.Do(func() {
+		close(s.doneChan)
+		stopped = true
+	})
+	if !stopped {
+		return false
+	}
+
+	s.lock.Lock()
+	if s.info.State == StateActive {
+		s.recorded += time.Since(s.activeSince)
+	}
+	s.info.State = StateComplete
+	tracks := s.tracks
+	s.tracks = make(map[livekit.TrackID]*recordedTrack)
+	s.lock.Unlock()
+
+	for _, t := range tracks {
+		if t.writer == nil {
+			continue
+		}
+		for _, r := range t.track.Receivers() {
+			r.DeleteDownTrack(t.writer.SubscriberID())
+		}
+		if n, ok := t.track.(maxQualityNotifier); ok && t.track.Kind() == livekit.TrackType_VIDEO {
+			n.NotifySubscriberNodeMaxQuality(s.nodeID(), []types.SubscribedCodecQuality{
+				{CodecMime: t.writer.mimeType, Quality: livekit.VideoQuality_OFF},
+			})
+		}
+		t.writer.Close()
+	}
+
+	s.lock.Lock()
+	s.info.EndedAt = time.Now().UnixMilli()
+	s.lock.Unlock()
+
+	if err := s.persist(); err != nil {
+		s.logger.Errorw("could not write recording index", err)
+	}
+	if s.onFinished != nil {
+		s.onFinished(s)
+	}
+	return true
+}
+
+// snapshot returns a copy of the recording info with up to date sizes and duration
+func (s *session) snapshot() *Info {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, t := range s.tracks {
+		if t.writer != nil && !t.writer.IsClosed() {
+			s.updateTrackLocked(t.sync, t.writer)
+		}
+	}
+
+	recorded := s.recorded
+	if s.info.State == StateActive {
+		recorded += time.Since(s.activeSince)
+	}
+	s.info.Duration = recorded.Milliseconds()
+
+	info := *s.info
+	info.Files = make([]*FileInfo, 0, len(s.info.Files))
+	for _, f := range s.info.Files {
+		file := *f
+		info.Files = append(info.Files, &file)
+	}
+	return &info
+}
+
+// manifest must be called after snapshot to use up to date track timing
+func (s *session) manifest() *Manifest {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	m := buildManifest(s.info.ID, s.info.RoomName, s.syncs)
+	m.Encryption = s.info.Encryption
+	return m
+}
+
+func (s *session) persist() error {
+	if err := writeJSON(s.dir, indexFilename, s.snapshot()); err != nil {
+		return err
+	}
+	return writeJSON(s.dir, ManifestFilename, s.manifest())
+}
+
+func writeJSON(dir string, filename string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(dir, filename+".tmp")
+	if err = os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(dir, filename))
+}
+
+var unsafeFilenameChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+func sanitizeFilename(name string) string {
+	return unsafeFilenameChars.ReplaceAllString(name, "_")
+}
@@ -0,0 +1,437 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recording
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v3"
+	"github.com/pion/webrtc/v3/pkg/media/h264writer"
+	"github.com/pion/webrtc/v3/pkg/media/ivfwriter"
+	"github.com/pion/webrtc/v3/pkg/media/oggwriter"
+	"go.uber.org/atomic"
+
+	"github.com/livekit/livekit-server/pkg/sfu"
+	"github.com/livekit/livekit-server/pkg/sfu/buffer"
+	"github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/logger"
+)
+
+var ErrUnsupportedCodec = errors.New("codec cannot be recorded")
+
+type mediaWriter interface {
+	WriteRTP(packet *rtp.Packet) error
+	Close() error
+}
+
+// fileExtension returns the container used for a codec, empty when the codec is not supported.
+// Codecs the matroska writer cannot mux keep their native container when mkv is configured
+func fileExtension(mimeType string, container string) string {
+	switch {
+	case container == ContainerMKV && supportsMKV(mimeType):
+		return ".mkv"
+	case strings.EqualFold(mimeType, webrtc.MimeTypeOpus):
+		return ".ogg"
+	case strings.EqualFold(mimeType, webrtc.MimeTypeVP8), strings.EqualFold(mimeType, webrtc.MimeTypeAV1):
+		return ".ivf"
+	case strings.EqualFold(mimeType, webrtc.MimeTypeH264):
+		return ".h264"
+	default:
+		return ""
+	}
+}
+
+// newMediaWriter encrypts the file when key is set. Encrypted files cannot be seeked, the frame
+// count of ivf headers is left empty, which players do not rely on
+func newMediaWriter(path string, mimeType string, container string, key []byte) (mediaWriter, error) {
+	if fileExtension(mimeType, container) == "" {
+		return nil, ErrUnsupportedCodec
+	}
+	if container == ContainerMKV && supportsMKV(mimeType) {
+		return newFileMKVWriter(path, mimeType, key)
+	}
+	if key != nil {
+		return newEncryptedMediaWriter(path, mimeType, key)
+	}
+
+	switch {
+	case strings.EqualFold(mimeType, webrtc.MimeTypeOpus):
+		return oggwriter.New(path, 48000, 2)
+	case strings.EqualFold(mimeType, webrtc.MimeTypeVP8):
+		return ivfwriter.New(path, ivfwriter.WithCodec(webrtc.MimeTypeVP8))
+	case strings.EqualFold(mimeType, webrtc.MimeTypeAV1):
+		return ivfwriter.New(path, ivfwriter.WithCodec(webrtc.MimeTypeAV1))
+	case strings.EqualFold(mimeType, webrtc.MimeTypeH264):
+		return h264writer.New(path)
+	default:
+		return nil, ErrUnsupportedCodec
+	}
+}
+
+func newFileMKVWriter(path string, mimeType string, key []byte) (mediaWriter, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	var out io.WriteCloser = f
+	if key != nil {
+		if out, err = newEncryptingWriter(f, key); err != nil {
+			_ = f.Close()
+			return nil, err
+		}
+	}
+	return newMKVWriter(out, mimeType)
+}
+
+func newEncryptedMediaWriter(path string, mimeType string, key []byte) (mediaWriter, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	out, err := newEncryptingWriter(f, key)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+
+	var writer mediaWriter
+	switch {
+	case strings.EqualFold(mimeType, webrtc.MimeTypeOpus):
+		writer, err = oggwriter.NewWith(out, 48000, 2)
+	case strings.EqualFold(mimeType, webrtc.MimeTypeVP8):
+		writer, err = ivfwriter.NewWith(out, ivfwriter.WithCodec(webrtc.MimeTypeVP8))
+	case strings.EqualFold(mimeType, webrtc.MimeTypeAV1):
+		writer, err = ivfwriter.NewWith(out, ivfwriter.WithCodec(webrtc.MimeTypeAV1))
+	default:
+		writer = h264writer.NewWith(out)
+	}
+	if err != nil {
+		_ = out.Close()
+		return nil, err
+	}
+	return writer, nil
+}
+
+// segmentTiming holds the timing of the packets written to a finished segment file
+type segmentTiming struct {
+	filename          string
+	firstPacketAt     time.Time
+	lastPacketAt      time.Time
+	firstRTPTimestamp uint32
+	senderStartAt     time.Time
+}
+
+type trackWriterParams struct {
+	Dir string
+	// file name of a segment, segments are numbered from zero
+	Filename  func(segment int) string
+	Key       []byte
+	Container string
+	// files are rotated at this duration, on the next key frame for video. Disabled when zero
+	SegmentDuration time.Duration
+	SubscriberID    livekit.ParticipantID
+	Receiver        sfu.TrackReceiver
+	IsVideo         bool
+	Logger          logger.Logger
+}
+
+// trackWriter is attached to a track receiver in place of a subscriber's DownTrack and writes
+// the highest published layer to a file
+type trackWriter struct {
+	params       trackWriterParams
+	logger       logger.Logger
+	subscriberID livekit.ParticipantID
+	trackID      livekit.TrackID
+	receiver     sfu.TrackReceiver
+	isVideo      bool
+	mimeType     string
+	onClose      func()
+
+	lock          sync.Mutex
+	writer        mediaWriter
+	segment       int
+	filename      string
+	finished      []segmentTiming
+	targetLayer   int32
+	keyFrameSeen  bool
+	rotating      bool
+	paused        bool
+	firstPacketAt time.Time
+	lastPacketAt  time.Time
+	closed        atomic.Bool
+
+	// RTP to sender wall clock mapping of the first packet written to the current segment
+	clockRate         uint32
+	firstLayer        int32
+	firstRTPTimestamp uint32
+	senderReports     map[int32]buffer.RTCPSenderReportData
+	senderStartAt     time.Time
+}
+
+var _ sfu.TrackSender = (*trackWriter)(nil)
+
+func newTrackWriter(params trackWriterParams) (*trackWriter, error) {
+	mimeType := params.Receiver.Codec().MimeType
+	filename := params.Filename(0)
+	writer, err := newMediaWriter(filepath.Join(params.Dir, filename), mimeType, params.Container, params.Key)
+	if err != nil {
+		return nil, err
+	}
+	return &trackWriter{
+		params:       params,
+		logger:       params.Logger,
+		subscriberID: params.SubscriberID,
+		trackID:      params.Receiver.TrackID(),
+		receiver:     params.Receiver,
+		isVideo:      params.IsVideo,
+		mimeType:     mimeType,
+		writer:       writer,
+		filename:     filename,
+		targetLayer:  buffer.InvalidLayerSpatial,
+		clockRate:    params.Receiver.Codec().ClockRate,
+	}, nil
+}
+
+func (w *trackWriter) OnClose(f func()) {
+	w.onClose = f
+}
+
+func (w *trackWriter) SetPaused(paused bool) {
+	w.lock.Lock()
+	w.paused = paused
+	// resume on a key frame so the file stays decodable
+	w.keyFrameSeen = false
+	target := w.targetLayer
+	w.lock.Unlock()
+
+	if !paused && w.isVideo && target != buffer.InvalidLayerSpatial {
+		w.receiver.SendPLI(target, true)
+	}
+}
+
+// Filename returns the name of the file currently written
+func (w *trackWriter) Filename() string {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.filename
+}
+
+// TakeFinishedSegments returns the segments finalized since the last call
+func (w *trackWriter) TakeFinishedSegments() []segmentTiming {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	finished := w.finished
+	w.finished = nil
+	return finished
+}
+
+// PacketTimes returns arrival times of the first and last packets written to the current segment
+func (w *trackWriter) PacketTimes() (time.Time, time.Time) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.firstPacketAt, w.lastPacketAt
+}
+
+func (w *trackWriter) UpTrackLayersChange()                           {}
+func (w *trackWriter) UpTrackBitrateAvailabilityChange()              {}
+func (w *trackWriter) UpTrackMaxTemporalLayerSeenChange(_ int32)      {}
+func (w *trackWriter) UpTrackBitrateReport(_ []int32, _ sfu.Bitrates) {}
+func (w *trackWriter) TrackInfoAvailable()                            {}
+func (w *trackWriter) Resync()                                        {}
+func (w *trackWriter) ID() string                                     { return string(w.trackID) }
+func (w *trackWriter) SubscriberID() livekit.ParticipantID            { return w.subscriberID }
+func (w *trackWriter) IsClosed() bool                                 { return w.closed.Load() }
+
+// SyncInfo returns the RTP timestamp of the first written packet and the sender wall clock time
+// it maps to, the time is zero until a sender report for the recorded layer has been received
+func (w *trackWriter) SyncInfo() (uint32, time.Time) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.firstRTPTimestamp, w.senderStartAt
+}
+
+func (w *trackWriter) HandleRTCPSenderReportData(_ webrtc.PayloadType, layer int32, srData *buffer.RTCPSenderReportData) error {
+	if srData == nil {
+		return nil
+	}
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if !w.senderStartAt.IsZero() {
+		return nil
+	}
+	if w.senderReports == nil {
+		w.senderReports = make(map[int32]buffer.RTCPSenderReportData)
+	}
+	w.senderReports[layer] = *srData
+	w.syncLocked()
+	return nil
+}
+
+// syncLocked maps the first written packet onto the sender clock, it is done once, close to the
+// start, to keep the RTP timestamp difference small
+func (w *trackWriter) syncLocked() {
+	if w.firstPacketAt.IsZero() || !w.senderStartAt.IsZero() || w.clockRate == 0 {
+		return
+	}
+	sr, ok := w.senderReports[w.firstLayer]
+	if !ok {
+		return
+	}
+
+	diff := int64(int32(w.firstRTPTimestamp - sr.RTPTimestamp))
+	w.senderStartAt = sr.NTPTimestamp.Time().Add(time.Duration(diff * int64(time.Second) / int64(w.clockRate)))
+	w.senderReports = nil
+}
+
+func (w *trackWriter) UpTrackMaxPublishedLayerChange(maxPublishedLayer int32) {
+	if !w.isVideo {
+		return
+	}
+
+	w.lock.Lock()
+	changed := maxPublishedLayer != w.targetLayer
+	if changed {
+		w.targetLayer = maxPublishedLayer
+		w.keyFrameSeen = false
+	}
+	w.lock.Unlock()
+
+	if changed && maxPublishedLayer != buffer.InvalidLayerSpatial {
+		w.receiver.SendPLI(maxPublishedLayer, true)
+	}
+}
+
+func (w *trackWriter) WriteRTP(p *buffer.ExtPacket, layer int32) error {
+	if w.closed.Load() {
+		return nil
+	}
+
+	w.lock.Lock()
+	requestKeyFrame, err := w.writeLocked(p, layer)
+	target := w.targetLayer
+	w.lock.Unlock()
+
+	if requestKeyFrame {
+		w.receiver.SendPLI(target, true)
+	}
+	return err
+}
+
+// writeLocked returns true when a key frame is needed to rotate the segment
+func (w *trackWriter) writeLocked(p *buffer.ExtPacket, layer int32) (bool, error) {
+	if w.paused || w.writer == nil {
+		return false, nil
+	}
+	if w.isVideo {
+		if layer != w.targetLayer {
+			return false, nil
+		}
+		if !w.keyFrameSeen {
+			if !p.KeyFrame {
+				return false, nil
+			}
+			w.keyFrameSeen = true
+		}
+	}
+
+	requestKeyFrame := false
+	if w.params.SegmentDuration > 0 && !w.firstPacketAt.IsZero() && p.Arrival.Sub(w.firstPacketAt) >= w.params.SegmentDuration {
+		// video segments start on a key frame to be decodable on their own
+		if !w.isVideo || p.KeyFrame {
+			if err := w.rotateLocked(); err != nil {
+				return false, err
+			}
+		} else if !w.rotating {
+			w.rotating = true
+			requestKeyFrame = true
+		}
+	}
+
+	if err := w.writer.WriteRTP(p.Packet); err != nil {
+		return requestKeyFrame, err
+	}
+	if w.firstPacketAt.IsZero() {
+		w.firstPacketAt = p.Arrival
+		w.firstLayer = layer
+		if p.Spatial >= 0 {
+			// svc layers share a single stream and its sender reports
+			w.firstLayer = 0
+		}
+		w.firstRTPTimestamp = p.Packet.Timestamp
+		w.syncLocked()
+	}
+	w.lastPacketAt = p.Arrival
+	return requestKeyFrame, nil
+}
+
+// rotateLocked finalizes the current segment and opens the next one
+func (w *trackWriter) rotateLocked() error {
+	if err := w.writer.Close(); err != nil {
+		w.logger.Warnw("could not finalize recording file", err, "file", w.filename)
+	}
+	w.finished = append(w.finished, segmentTiming{
+		filename:          w.filename,
+		firstPacketAt:     w.firstPacketAt,
+		lastPacketAt:      w.lastPacketAt,
+		firstRTPTimestamp: w.firstRTPTimestamp,
+		senderStartAt:     w.senderStartAt,
+	})
+
+	w.segment++
+	w.filename = w.params.Filename(w.segment)
+	w.firstPacketAt = time.Time{}
+	w.lastPacketAt = time.Time{}
+	w.senderStartAt = time.Time{}
+	w.senderReports = nil
+	w.rotating = false
+
+	writer, err := newMediaWriter(filepath.Join(w.params.Dir, w.filename), w.mimeType, w.params.Container, w.params.Key)
+	if err != nil {
+		// nothing more is written, the finished segments are kept
+		w.writer = nil
+		return err
+	}
+	w.writer = writer
+	return nil
+}
+
+func (w *trackWriter) Close() {
+	if w.closed.Swap(true) {
+		return
+	}
+
+	w.lock.Lock()
+	var err error
+	if w.writer != nil {
+		err = w.writer.Close()
+	}
+	w.lock.Unlock()
+	if err != nil {
+		w.logger.Warnw("could not finalize recording file", err)
+	}
+
+	if w.onClose != nil {
+		w.onClose()
+	}
+}
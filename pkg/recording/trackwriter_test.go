@@ -0,0 +1,203 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recording
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/logger"
+
+	"github.com/livekit/livekit-server/pkg/sfu"
+	"github.com/livekit/livekit-server/pkg/sfu/buffer"
+)
+
+type testReceiver struct {
+	sfu.TrackReceiver
+	codec webrtc.RTPCodecParameters
+
+	lock sync.Mutex
+	plis []int32
+}
+
+func (r *testReceiver) Codec() webrtc.RTPCodecParameters { return r.codec }
+func (r *testReceiver) TrackID() livekit.TrackID         { return "TR_recorded" }
+
+func (r *testReceiver) SendPLI(layer int32, _ bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.plis = append(r.plis, layer)
+}
+
+func (r *testReceiver) numPLIs() int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return len(r.plis)
+}
+
+func newTestTrackWriter(t *testing.T, mimeType string, clockRate uint32, isVideo bool) (*trackWriter, *testReceiver, string) {
+	dir := t.TempDir()
+	receiver := &testReceiver{codec: webrtc.RTPCodecParameters{
+		RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: mimeType, ClockRate: clockRate},
+	}}
+	ext := fileExtension(mimeType, "")
+	w, err := newTrackWriter(trackWriterParams{
+		Dir: dir,
+		Filename: func(segment int) string {
+			return fmt.Sprintf("track_%d%s", segment, ext)
+		},
+		SegmentDuration: 2 * time.Second,
+		SubscriberID:    "PA_recorder",
+		Receiver:        receiver,
+		IsVideo:         isVideo,
+		Logger:          logger.GetLogger(),
+	})
+	require.NoError(t, err)
+	return w, receiver, dir
+}
+
+func testPacket(seq uint16, arrival time.Time, keyFrame bool) *buffer.ExtPacket {
+	return &buffer.ExtPacket{
+		VideoLayer: buffer.VideoLayer{Spatial: buffer.InvalidLayerSpatial},
+		Arrival:    arrival,
+		KeyFrame:   keyFrame,
+		Packet: &rtp.Packet{
+			Header: rtp.Header{
+				Version:        2,
+				Marker:         true,
+				SequenceNumber: seq,
+				Timestamp:      uint32(seq) * 960,
+			},
+			// a vp8 descriptor starting a partition followed by a key frame header, also a valid opus frame
+			Payload: []byte{0x10, 0x00, 0x00, 0x00},
+		},
+	}
+}
+
+func TestTrackWriterSegments(t *testing.T) {
+	t.Run("audio rotates at segment duration", func(t *testing.T) {
+		w, _, dir := newTestTrackWriter(t, webrtc.MimeTypeOpus, 48000, false)
+		start := time.Now()
+
+		// 3s of 20ms packets
+		for i := 0; i < 150; i++ {
+			require.NoError(t, w.WriteRTP(testPacket(uint16(i), start.Add(time.Duration(i)*20*time.Millisecond), false), 0))
+		}
+
+		finished := w.TakeFinishedSegments()
+		require.Len(t, finished, 1)
+		require.Equal(t, "track_0.ogg", finished[0].filename)
+		require.Equal(t, start, finished[0].firstPacketAt)
+		require.Equal(t, start.Add(1980*time.Millisecond), finished[0].lastPacketAt)
+		require.Empty(t, w.TakeFinishedSegments(), "segments are taken once")
+
+		require.Equal(t, "track_1.ogg", w.Filename())
+		first, last := w.PacketTimes()
+		require.Equal(t, start.Add(2*time.Second), first)
+		require.Equal(t, start.Add(2980*time.Millisecond), last)
+		require.FileExists(t, filepath.Join(dir, "track_0.ogg"))
+		require.FileExists(t, filepath.Join(dir, "track_1.ogg"))
+	})
+
+	t.Run("video rotates on the next key frame", func(t *testing.T) {
+		w, receiver, _ := newTestTrackWriter(t, webrtc.MimeTypeVP8, 90000, true)
+		w.UpTrackMaxPublishedLayerChange(0)
+		require.Equal(t, 1, receiver.numPLIs())
+
+		start := time.Now()
+		// waits for a key frame to start
+		require.NoError(t, w.WriteRTP(testPacket(0, start, false), 0))
+		first, _ := w.PacketTimes()
+		require.True(t, first.IsZero())
+		require.NoError(t, w.WriteRTP(testPacket(1, start, true), 0))
+
+		// other layers are not recorded
+		require.NoError(t, w.WriteRTP(testPacket(2, start, true), 1))
+		_, last := w.PacketTimes()
+		require.Equal(t, start, last)
+
+		// past the segment duration a key frame is requested once, the segment continues until it arrives
+		require.NoError(t, w.WriteRTP(testPacket(3, start.Add(2*time.Second), false), 0))
+		require.NoError(t, w.WriteRTP(testPacket(4, start.Add(2100*time.Millisecond), false), 0))
+		require.Equal(t, 2, receiver.numPLIs())
+		require.Empty(t, w.TakeFinishedSegments())
+
+		require.NoError(t, w.WriteRTP(testPacket(5, start.Add(2200*time.Millisecond), true), 0))
+		finished := w.TakeFinishedSegments()
+		require.Len(t, finished, 1)
+		require.Equal(t, "track_0.ivf", finished[0].filename)
+		require.Equal(t, start.Add(2100*time.Millisecond), finished[0].lastPacketAt)
+		require.Equal(t, "track_1.ivf", w.Filename())
+		first, _ = w.PacketTimes()
+		require.Equal(t, start.Add(2200*time.Millisecond), first)
+	})
+
+	t.Run("resumes on a key frame after pausing", func(t *testing.T) {
+		w, receiver, _ := newTestTrackWriter(t, webrtc.MimeTypeVP8, 90000, true)
+		w.UpTrackMaxPublishedLayerChange(0)
+		start := time.Now()
+		require.NoError(t, w.WriteRTP(testPacket(0, start, true), 0))
+
+		w.SetPaused(true)
+		require.NoError(t, w.WriteRTP(testPacket(1, start.Add(time.Second), true), 0))
+		_, last := w.PacketTimes()
+		require.Equal(t, start, last)
+
+		plis := receiver.numPLIs()
+		w.SetPaused(false)
+		require.Equal(t, plis+1, receiver.numPLIs())
+		require.NoError(t, w.WriteRTP(testPacket(2, start.Add(1100*time.Millisecond), false), 0))
+		_, last = w.PacketTimes()
+		require.Equal(t, start, last)
+		require.NoError(t, w.WriteRTP(testPacket(3, start.Add(1200*time.Millisecond), true), 0))
+		_, last = w.PacketTimes()
+		require.Equal(t, start.Add(1200*time.Millisecond), last)
+	})
+
+	t.Run("close finalizes the current segment", func(t *testing.T) {
+		w, _, dir := newTestTrackWriter(t, webrtc.MimeTypeOpus, 48000, false)
+		closed := 0
+		w.OnClose(func() { closed++ })
+
+		start := time.Now()
+		for i := 0; i < 150; i++ {
+			require.NoError(t, w.WriteRTP(testPacket(uint16(i), start.Add(time.Duration(i)*20*time.Millisecond), false), 0))
+		}
+		w.Close()
+		w.Close()
+		require.True(t, w.IsClosed())
+		require.Equal(t, 1, closed)
+
+		// written after close is dropped
+		require.NoError(t, w.WriteRTP(testPacket(150, start.Add(3*time.Second), false), 0))
+		_, last := w.PacketTimes()
+		require.Equal(t, start.Add(2980*time.Millisecond), last)
+
+		for _, name := range []string{"track_0.ogg", "track_1.ogg"} {
+			data, err := os.ReadFile(filepath.Join(dir, name))
+			require.NoError(t, err)
+			require.Equal(t, "OggS", string(data[:4]), name)
+		}
+	})
+}
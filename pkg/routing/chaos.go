@@ -0,0 +1,62 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package routing
+
+import (
+	"time"
+
+	"github.com/livekit/protocol/logger"
+)
+
+// ChaosRouter injects routing failures, used for resilience testing in development mode
+type ChaosRouter interface {
+	// StallStats stops updating node stats, other nodes consider the node unavailable once its stats are stale
+	StallStats(duration time.Duration)
+	// DropRedisConnection closes the Redis pub/sub connection, messages for the node are lost until it resubscribes
+	DropRedisConnection(duration time.Duration) error
+}
+
+var _ ChaosRouter = (*LocalRouter)(nil)
+var _ ChaosRouter = (*RedisRouter)(nil)
+
+func (r *LocalRouter) StallStats(duration time.Duration) {
+	logger.Infow("chaos: stalling node stats", "duration", duration)
+	r.statsStalledUntil.Store(time.Now().Add(duration).Unix())
+}
+
+func (r *LocalRouter) isStatsStalled() bool {
+	return time.Now().Unix() < r.statsStalledUntil.Load()
+}
+
+func (r *LocalRouter) DropRedisConnection(_ time.Duration) error {
+	return ErrRedisNotConfigured
+}
+
+func (r *RedisRouter) DropRedisConnection(duration time.Duration) error {
+	if !r.isStarted.Load() {
+		return ErrChannelClosed
+	}
+
+	logger.Infow("chaos: dropping redis pub/sub connection", "duration", duration)
+	_ = r.pubsub.Close()
+	time.AfterFunc(duration, func() {
+		if !r.isStarted.Load() {
+			return
+		}
+		logger.Infow("chaos: restoring redis pub/sub connection")
+		go r.redisWorker(make(chan struct{}))
+	})
+	return nil
+}
@@ -0,0 +1,46 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package routing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalRouterChaos(t *testing.T) {
+	testCases := []struct {
+		name    string
+		stall   time.Duration
+		stalled bool
+	}{
+		{name: "not stalled"},
+		{name: "stalled", stall: time.Minute, stalled: true},
+		{name: "elapsed", stall: -time.Minute},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := &LocalRouter{}
+			if tc.stall != 0 {
+				r.StallStats(tc.stall)
+			}
+			require.Equal(t, tc.stalled, r.isStatsStalled())
+		})
+	}
+
+	// there is no redis connection to drop
+	require.ErrorIs(t, (&LocalRouter{}).DropRedisConnection(time.Second), ErrRedisNotConfigured)
+}
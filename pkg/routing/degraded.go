@@ -0,0 +1,222 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package routing
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/logger"
+	"github.com/livekit/protocol/utils"
+
+	"github.com/livekit/livekit-server/pkg/telemetry/prometheus"
+)
+
+const (
+	redisHealthCheckInterval = time.Second
+	redisHealthCheckTimeout  = time.Second
+	// consecutive failed pings before the router switches to local routing
+	redisDegradeAfterFailures = 3
+)
+
+// IsDegraded is true while redis is unreachable. Rooms hosted on this node keep working with local routing,
+// other rooms cannot be located and new rooms cannot be placed
+func (r *RedisRouter) IsDegraded() bool {
+	return r.degraded.Load()
+}
+
+func (r *RedisRouter) IsLocalRoom(roomName livekit.RoomName) bool {
+	r.localRoomsLock.RLock()
+	defer r.localRoomsLock.RUnlock()
+
+	return r.localRooms[roomName]
+}
+
+func (r *RedisRouter) OnRecovered(callback func()) {
+	r.localRoomsLock.Lock()
+	defer r.localRoomsLock.Unlock()
+
+	r.onRecovered = append(r.onRecovered, callback)
+}
+
+func (r *RedisRouter) SetLocalRoom(roomName livekit.RoomName, local bool) {
+	r.localRoomsLock.Lock()
+	defer r.localRoomsLock.Unlock()
+
+	if local {
+		r.localRooms[roomName] = true
+		delete(r.unclearedRooms, roomName)
+	} else {
+		delete(r.localRooms, roomName)
+	}
+}
+
+func (r *RedisRouter) localNodeForRoom(roomName livekit.RoomName) (*livekit.Node, error) {
+	if !r.IsLocalRoom(roomName) {
+		return nil, ErrRedisUnavailable
+	}
+
+	r.nodeMu.RLock()
+	defer r.nodeMu.RUnlock()
+	return proto.Clone((*livekit.Node)(r.currentNode)).(*livekit.Node), nil
+}
+
+// startLocalParticipantSignal hands the participant to the room on this node in process, as redis cannot carry
+// the session
+func (r *RedisRouter) startLocalParticipantSignal(roomName livekit.RoomName, pi ParticipantInit) (livekit.ConnectionID, MessageSink, MessageSource, error) {
+	if r.onNewParticipant == nil {
+		return "", nil, nil, ErrHandlerNotDefined
+	}
+
+	connectionID := livekit.ConnectionID(utils.NewGuid("CO_"))
+	reqChan := NewMessageChannel(connectionID, DefaultMessageChannelSize)
+	resChan := NewMessageChannel(connectionID, DefaultMessageChannelSize)
+	go func() {
+		if err := r.onNewParticipant(r.ctx, roomName, pi, reqChan, resChan); err != nil {
+			logger.Errorw("could not handle new participant", err,
+				"room", roomName,
+				"participant", pi.Identity,
+				"connID", connectionID,
+			)
+			reqChan.Close()
+			resChan.Close()
+		}
+	}()
+	return connectionID, reqChan, resChan, nil
+}
+
+// healthWorker pings redis, switching to local routing when it stops answering and resynchronizing the state
+// of this node once it answers again
+func (r *RedisRouter) healthWorker() {
+	ticker := time.NewTicker(redisHealthCheckInterval)
+	defer ticker.Stop()
+
+	failures := 0
+	for {
+		select {
+		case <-r.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		ctx, cancel := context.WithTimeout(r.ctx, redisHealthCheckTimeout)
+		err := r.rc.Ping(ctx).Err()
+		cancel()
+		if err != nil {
+			if r.ctx.Err() != nil {
+				return
+			}
+			failures++
+			if failures == redisDegradeAfterFailures {
+				r.degrade(err)
+			}
+			continue
+		}
+
+		failures = 0
+		if r.degraded.Load() {
+			if err = r.resync(); err != nil {
+				logger.Warnw("could not resynchronize node state, staying degraded", err, "nodeID", r.currentNode.Id)
+			}
+		}
+	}
+}
+
+func (r *RedisRouter) degrade(err error) {
+	if r.degraded.Swap(true) {
+		return
+	}
+	prometheus.RecordRouterDegraded(true)
+
+	r.localRoomsLock.RLock()
+	numRooms := len(r.localRooms)
+	r.localRoomsLock.RUnlock()
+	logger.Errorw("redis is unreachable, routing only rooms hosted on this node", err,
+		"nodeID", r.currentNode.Id,
+		"rooms", numRooms,
+	)
+}
+
+// resync registers this node and the rooms it kept hosting while degraded, clears the rooms closed meanwhile
+// and runs the recovery callbacks before accepting placements again
+func (r *RedisRouter) resync() error {
+	if err := r.RegisterNode(); err != nil {
+		return err
+	}
+
+	r.localRoomsLock.RLock()
+	localRooms := make([]livekit.RoomName, 0, len(r.localRooms))
+	for roomName := range r.localRooms {
+		localRooms = append(localRooms, roomName)
+	}
+	unclearedRooms := make([]livekit.RoomName, 0, len(r.unclearedRooms))
+	for roomName := range r.unclearedRooms {
+		unclearedRooms = append(unclearedRooms, roomName)
+	}
+	callbacks := r.onRecovered
+	r.localRoomsLock.RUnlock()
+
+	for _, roomName := range localRooms {
+		nodeID, err := r.rc.HGet(r.ctx, NodeRoomKey, string(roomName)).Result()
+		if err == redis.Nil {
+			err = r.rc.HSet(r.ctx, NodeRoomKey, string(roomName), r.currentNode.Id).Err()
+		}
+		if err != nil {
+			return err
+		}
+		if nodeID != "" && nodeID != r.currentNode.Id {
+			// placed again by a node that could reach redis, the copy on this node is not routed anymore and
+			// ends when its participants leave
+			logger.Warnw("room was placed on another node while redis was unreachable", nil,
+				"room", roomName,
+				"nodeID", nodeID,
+			)
+			r.SetLocalRoom(roomName, false)
+		}
+	}
+	for _, roomName := range unclearedRooms {
+		nodeID, err := r.rc.HGet(r.ctx, NodeRoomKey, string(roomName)).Result()
+		if err == nil && nodeID == r.currentNode.Id {
+			err = r.rc.HDel(r.ctx, NodeRoomKey, string(roomName)).Err()
+		}
+		if err != nil && err != redis.Nil {
+			return err
+		}
+		r.localRoomsLock.Lock()
+		delete(r.unclearedRooms, roomName)
+		r.localRoomsLock.Unlock()
+	}
+
+	// invalidations were missed
+	if r.roomNodeCache != nil {
+		r.roomNodeCache.Clear()
+	}
+
+	for _, callback := range callbacks {
+		callback()
+	}
+
+	r.degraded.Store(false)
+	prometheus.RecordRouterDegraded(false)
+	logger.Infow("redis is reachable again, resumed cluster routing",
+		"nodeID", r.currentNode.Id,
+		"rooms", len(localRooms),
+	)
+	return nil
+}
@@ -0,0 +1,176 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package routing
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-jose/go-jose/v3/jwt"
+
+	"github.com/livekit/protocol/livekit"
+)
+
+// EntitlementsClaim is the custom JWT claim holding the entitlements of a participant
+const EntitlementsClaim = "entitlements"
+
+// Entitlements are features granted to a participant by its token, unset fields leave the feature unrestricted
+type Entitlements struct {
+	// highest video layer forwarded to the participant: low, medium or high
+	MaxVideoQuality string `json:"maxVideoQuality,omitempty"`
+	// whether the participant may publish screen share tracks
+	AllowScreenshare *bool `json:"allowScreenshare,omitempty"`
+	// whether the participant may start recordings and its tracks are included in server side recordings
+	AllowRecording *bool `json:"allowRecording,omitempty"`
+	// whether the configured public claims of the token are shown to other participants
+	ShareClaims *bool `json:"shareClaims,omitempty"`
+	// whether opus audio is sent to the participant as RED (redundant audio), e.g. for a participant on a lossy
+	// downlink in a room without RED. Left to the room when unset
+	REDAudio *bool `json:"redAudio,omitempty"`
+}
+
+// ParseEntitlements reads the entitlements claim of a token, the token signature must have been verified
+// beforehand. Returns nil when the token has no entitlements
+func ParseEntitlements(token string) (*Entitlements, error) {
+	tok, err := jwt.ParseSigned(token)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := struct {
+		Entitlements *Entitlements `json:"entitlements,omitempty"`
+	}{}
+	if err = tok.UnsafeClaimsWithoutVerification(&claims); err != nil {
+		return nil, err
+	}
+	if claims.Entitlements == nil {
+		return nil, nil
+	}
+	if err = claims.Entitlements.Validate(); err != nil {
+		return nil, err
+	}
+	return claims.Entitlements, nil
+}
+
+func (e *Entitlements) Validate() error {
+	if e.MaxVideoQuality == "" {
+		return nil
+	}
+	switch strings.ToLower(e.MaxVideoQuality) {
+	case "low", "medium", "high":
+		return nil
+	default:
+		return fmt.Errorf("invalid %s.maxVideoQuality: %s", EntitlementsClaim, e.MaxVideoQuality)
+	}
+}
+
+// GetMaxVideoQuality returns the highest video quality the participant may receive
+func (e *Entitlements) GetMaxVideoQuality() livekit.VideoQuality {
+	if e == nil {
+		return livekit.VideoQuality_HIGH
+	}
+	switch strings.ToLower(e.MaxVideoQuality) {
+	case "low":
+		return livekit.VideoQuality_LOW
+	case "medium":
+		return livekit.VideoQuality_MEDIUM
+	default:
+		return livekit.VideoQuality_HIGH
+	}
+}
+
+func (e *Entitlements) GetAllowScreenshare() bool {
+	return e == nil || e.AllowScreenshare == nil || *e.AllowScreenshare
+}
+
+func (e *Entitlements) GetAllowRecording() bool {
+	return e == nil || e.AllowRecording == nil || *e.AllowRecording
+}
+
+// GetShareClaims returns whether public claims of the token are shared, required is true when the entitlement has
+// to be granted explicitly
+func (e *Entitlements) GetShareClaims(required bool) bool {
+	if e == nil || e.ShareClaims == nil {
+		return !required
+	}
+	return *e.ShareClaims
+}
+
+// GetREDAudio returns whether audio is sent to the participant as RED, roomDefault when the entitlement is unset
+func (e *Entitlements) GetREDAudio(roomDefault bool) bool {
+	if e == nil || e.REDAudio == nil {
+		return roomDefault
+	}
+	return *e.REDAudio
+}
+
+// CanPublishSource applies the screen share entitlement on top of the publish grants
+func (e *Entitlements) CanPublishSource(source livekit.TrackSource) bool {
+	switch source {
+	case livekit.TrackSource_SCREEN_SHARE, livekit.TrackSource_SCREEN_SHARE_AUDIO:
+		return e.GetAllowScreenshare()
+	default:
+		return true
+	}
+}
+
+// ApplyToPermission removes the sources the participant is not entitled to from the permission sent to clients
+func (e *Entitlements) ApplyToPermission(permission *livekit.ParticipantPermission) *livekit.ParticipantPermission {
+	if e.GetAllowScreenshare() {
+		return permission
+	}
+
+	sources := permission.CanPublishSources
+	if len(sources) == 0 {
+		// no sources means all of them
+		sources = []livekit.TrackSource{
+			livekit.TrackSource_CAMERA,
+			livekit.TrackSource_MICROPHONE,
+			livekit.TrackSource_SCREEN_SHARE,
+			livekit.TrackSource_SCREEN_SHARE_AUDIO,
+		}
+	}
+	permission.CanPublishSources = nil
+	for _, source := range sources {
+		if e.CanPublishSource(source) {
+			permission.CanPublishSources = append(permission.CanPublishSources, source)
+		}
+	}
+	return permission
+}
+
+// ParsePublicClaims returns the custom claims of a token that are named in names, the token signature must have been
+// verified beforehand. Returns nil when the token has none of them
+func ParsePublicClaims(token string, names []string) (map[string]interface{}, error) {
+	tok, err := jwt.ParseSigned(token)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := map[string]interface{}{}
+	if err = tok.UnsafeClaimsWithoutVerification(&claims); err != nil {
+		return nil, err
+	}
+	var public map[string]interface{}
+	for _, name := range names {
+		if value, ok := claims[name]; ok {
+			if public == nil {
+				public = make(map[string]interface{}, len(names))
+			}
+			public[name] = value
+		}
+	}
+	return public, nil
+}
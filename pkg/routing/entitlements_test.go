@@ -0,0 +1,127 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package routing
+
+import (
+	"testing"
+
+	"github.com/go-jose/go-jose/v3"
+	"github.com/go-jose/go-jose/v3/jwt"
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/protocol/auth"
+	"github.com/livekit/protocol/livekit"
+)
+
+func signedToken(t *testing.T, claims map[string]interface{}) string {
+	sig, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.HS256, Key: []byte("secret")}, nil)
+	require.NoError(t, err)
+	token, err := jwt.Signed(sig).Claims(claims).CompactSerialize()
+	require.NoError(t, err)
+	return token
+}
+
+func TestParseEntitlements(t *testing.T) {
+	entitlements, err := ParseEntitlements(signedToken(t, map[string]interface{}{"sub": "identity"}))
+	require.NoError(t, err)
+	require.Nil(t, entitlements)
+	require.Equal(t, livekit.VideoQuality_HIGH, entitlements.GetMaxVideoQuality())
+	require.True(t, entitlements.GetAllowScreenshare())
+	require.True(t, entitlements.GetAllowRecording())
+	require.True(t, entitlements.GetREDAudio(true))
+	require.False(t, entitlements.GetREDAudio(false))
+
+	entitlements, err = ParseEntitlements(signedToken(t, map[string]interface{}{
+		EntitlementsClaim: map[string]interface{}{
+			"maxVideoQuality":  "medium",
+			"allowScreenshare": false,
+			"redAudio":         true,
+		},
+	}))
+	require.NoError(t, err)
+	require.Equal(t, livekit.VideoQuality_MEDIUM, entitlements.GetMaxVideoQuality())
+	require.False(t, entitlements.GetAllowScreenshare())
+	require.True(t, entitlements.GetAllowRecording())
+	require.True(t, entitlements.GetREDAudio(false))
+	require.True(t, entitlements.CanPublishSource(livekit.TrackSource_CAMERA))
+	require.False(t, entitlements.CanPublishSource(livekit.TrackSource_SCREEN_SHARE_AUDIO))
+
+	permission := entitlements.ApplyToPermission(&livekit.ParticipantPermission{CanPublish: true})
+	require.ElementsMatch(t, []livekit.TrackSource{livekit.TrackSource_CAMERA, livekit.TrackSource_MICROPHONE}, permission.CanPublishSources)
+
+	_, err = ParseEntitlements(signedToken(t, map[string]interface{}{
+		EntitlementsClaim: map[string]interface{}{"maxVideoQuality": "ultra"},
+	}))
+	require.Error(t, err)
+}
+
+func TestStartSessionEntitlements(t *testing.T) {
+	allowRecording := false
+	pi := ParticipantInit{
+		Identity: "identity",
+		Grants:   &auth.ClaimGrants{Name: "name", Video: &auth.VideoGrant{RoomJoin: true, Room: "room"}},
+		Entitlements: &Entitlements{
+			MaxVideoQuality: "low",
+			AllowRecording:  &allowRecording,
+		},
+	}
+	ss, err := pi.ToStartSession("room", "connection")
+	require.NoError(t, err)
+
+	decoded, err := ParticipantInitFromStartSession(ss, "")
+	require.NoError(t, err)
+	require.Equal(t, pi.Grants.Video, decoded.Grants.Video)
+	require.Equal(t, pi.Entitlements, decoded.Entitlements)
+
+	// sessions started by nodes without entitlements support
+	ss.GrantsJson = `{"name":"name","video":{"roomJoin":true,"room":"room"}}`
+	decoded, err = ParticipantInitFromStartSession(ss, "")
+	require.NoError(t, err)
+	require.Equal(t, "name", decoded.Grants.Name)
+	require.Nil(t, decoded.Entitlements)
+}
+
+func TestParsePublicClaims(t *testing.T) {
+	token := signedToken(t, map[string]interface{}{
+		"sub":  "identity",
+		"role": "teacher",
+		"team": "blue",
+	})
+	claims, err := ParsePublicClaims(token, []string{"role", "grade"})
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"role": "teacher"}, claims)
+
+	claims, err = ParsePublicClaims(token, []string{"grade"})
+	require.NoError(t, err)
+	require.Nil(t, claims)
+
+	var entitlements *Entitlements
+	require.True(t, entitlements.GetShareClaims(false))
+	require.False(t, entitlements.GetShareClaims(true))
+	shareClaims := false
+	entitlements = &Entitlements{ShareClaims: &shareClaims}
+	require.False(t, entitlements.GetShareClaims(false))
+
+	pi := ParticipantInit{
+		Identity:     "identity",
+		Grants:       &auth.ClaimGrants{Video: &auth.VideoGrant{RoomJoin: true, Room: "room"}},
+		PublicClaims: map[string]interface{}{"role": "teacher"},
+	}
+	ss, err := pi.ToStartSession("room", "connection")
+	require.NoError(t, err)
+	decoded, err := ParticipantInitFromStartSession(ss, "")
+	require.NoError(t, err)
+	require.Equal(t, pi.PublicClaims, decoded.PublicClaims)
+}
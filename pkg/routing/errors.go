@@ -26,4 +26,6 @@ var (
 	ErrInvalidRouterMessage = errors.New("invalid router message")
 	ErrChannelClosed        = errors.New("channel closed")
 	ErrChannelFull          = errors.New("channel is full")
+	ErrRedisNotConfigured   = errors.New("router is not connected to redis")
+	ErrRedisUnavailable     = errors.New("redis is unreachable, only rooms hosted on this node are routed")
 )
@@ -18,6 +18,7 @@ import (
 	"context"
 	"encoding/json"
 
+	"github.com/nats-io/nats.go"
 	"github.com/redis/go-redis/v9"
 	"google.golang.org/protobuf/proto"
 
@@ -61,6 +62,16 @@ type ParticipantInit struct {
 	AdaptiveStream       bool
 	ID                   livekit.ParticipantID
 	SubscriberAllowPause *bool
+	Entitlements         *Entitlements
+	// custom token claims shown to other participants
+	PublicClaims map[string]interface{}
+}
+
+// startSessionGrants carries the entitlements and public claims alongside the grants in StartSession.GrantsJson
+type startSessionGrants struct {
+	*auth.ClaimGrants
+	Entitlements *Entitlements          `json:"entitlements,omitempty"`
+	PublicClaims map[string]interface{} `json:"publicClaims,omitempty"`
 }
 
 type NewParticipantCallback func(
@@ -107,6 +118,19 @@ type Router interface {
 	OnRTCMessage(callback RTCMessageCallback)
 }
 
+// DegradedModeRouter is implemented by routers that keep serving the rooms of this node while the shared
+// cluster state is unreachable
+type DegradedModeRouter interface {
+	// IsDegraded is true while only rooms hosted on this node can be routed
+	IsDegraded() bool
+	// IsLocalRoom is true when the room is hosted on this node
+	IsLocalRoom(roomName livekit.RoomName) bool
+	// SetLocalRoom tracks the rooms hosted on this node, which are routed while degraded
+	SetLocalRoom(roomName livekit.RoomName, local bool)
+	// OnRecovered is called once the cluster state is reachable again, before new placements are accepted
+	OnRecovered(callback func())
+}
+
 type MessageRouter interface {
 	// StartParticipantSignal participant signal connection is ready to start
 	StartParticipantSignal(ctx context.Context, roomName livekit.RoomName, pi ParticipantInit) (connectionID livekit.ConnectionID, reqSink MessageSink, resSource MessageSource, err error)
@@ -116,20 +140,27 @@ type MessageRouter interface {
 	WriteRoomRTC(ctx context.Context, roomName livekit.RoomName, msg *livekit.RTCNodeMessage) error
 }
 
-func CreateRouter(config *config.Config, rc redis.UniversalClient, node LocalNode, signalClient SignalClient) Router {
+func CreateRouter(config *config.Config, rc redis.UniversalClient, nc *nats.Conn, node LocalNode, signalClient SignalClient) (Router, error) {
 	lr := NewLocalRouter(node, signalClient)
 
 	if rc != nil {
-		return NewRedisRouter(config, lr, rc)
+		return NewRedisRouter(config, lr, rc), nil
+	}
+	if nc != nil {
+		return NewNATSRouter(config, lr, nc)
 	}
 
 	// local routing and store
 	logger.Infow("using single-node routing")
-	return lr
+	return lr, nil
 }
 
 func (pi *ParticipantInit) ToStartSession(roomName livekit.RoomName, connectionID livekit.ConnectionID) (*livekit.StartSession, error) {
-	claims, err := json.Marshal(pi.Grants)
+	claims, err := json.Marshal(startSessionGrants{
+		ClaimGrants:  pi.Grants,
+		Entitlements: pi.Entitlements,
+		PublicClaims: pi.PublicClaims,
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -157,8 +188,8 @@ func (pi *ParticipantInit) ToStartSession(roomName livekit.RoomName, connectionI
 }
 
 func ParticipantInitFromStartSession(ss *livekit.StartSession, region string) (*ParticipantInit, error) {
-	claims := &auth.ClaimGrants{}
-	if err := json.Unmarshal([]byte(ss.GrantsJson), claims); err != nil {
+	claims := startSessionGrants{ClaimGrants: &auth.ClaimGrants{}}
+	if err := json.Unmarshal([]byte(ss.GrantsJson), &claims); err != nil {
 		return nil, err
 	}
 
@@ -169,10 +200,12 @@ func ParticipantInitFromStartSession(ss *livekit.StartSession, region string) (*
 		ReconnectReason: ss.ReconnectReason,
 		Client:          ss.Client,
 		AutoSubscribe:   ss.AutoSubscribe,
-		Grants:          claims,
+		Grants:          claims.ClaimGrants,
 		Region:          region,
 		AdaptiveStream:  ss.AdaptiveStream,
 		ID:              livekit.ParticipantID(ss.ParticipantId),
+		Entitlements:    claims.Entitlements,
+		PublicClaims:    claims.PublicClaims,
 	}
 	if ss.SubscriberAllowPause != nil {
 		subscriberAllowPause := *ss.SubscriberAllowPause
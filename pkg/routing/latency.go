@@ -0,0 +1,234 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package routing
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/logger"
+
+	"github.com/livekit/livekit-server/pkg/routing/selector"
+)
+
+const (
+	// hash of node_id => JSON of the RTTs measured from that node
+	NodeLatencyKey = "node_latency"
+
+	defaultLatencyProbeInterval = 30 * time.Second
+	latencyProbeTimeout         = 2 * time.Second
+	latencyProbeSamples         = 3
+)
+
+// LatencyReporter is implemented by routers measuring the RTT between nodes
+type LatencyReporter interface {
+	// NodeLatency returns the last RTT measured between the current node and nodeID
+	NodeLatency(nodeID livekit.NodeID) (time.Duration, bool)
+	// GetLatencyMap returns the RTTs measured by all nodes of the cluster
+	GetLatencyMap() (LatencyMap, error)
+}
+
+// LatencyMap holds the RTTs measured between nodes, keyed by the measuring node and then by the probed node
+type LatencyMap map[livekit.NodeID]map[livekit.NodeID]time.Duration
+
+// Latency returns the RTT between two nodes, measured by either of them
+func (m LatencyMap) Latency(from, to livekit.NodeID) (time.Duration, bool) {
+	if from == to {
+		return 0, true
+	}
+	if rtt, ok := m[from][to]; ok {
+		return rtt, true
+	}
+	rtt, ok := m[to][from]
+	return rtt, ok
+}
+
+// RelayPath returns the chain of nodes, from and to included, with the lowest total RTT between the two nodes.
+// Cascading media over an intermediate node can beat the direct path when the nodes are poorly connected.
+// Returns nil when there is no measured path
+func (m LatencyMap) RelayPath(from, to livekit.NodeID) ([]livekit.NodeID, time.Duration) {
+	nodes := make(map[livekit.NodeID]bool)
+	for a, rtts := range m {
+		nodes[a] = true
+		for b := range rtts {
+			nodes[b] = true
+		}
+	}
+	if !nodes[from] || !nodes[to] {
+		return nil, 0
+	}
+
+	// Dijkstra, maps are small enough to go without a heap
+	dist := map[livekit.NodeID]time.Duration{from: 0}
+	prev := make(map[livekit.NodeID]livekit.NodeID)
+	visited := make(map[livekit.NodeID]bool)
+	for {
+		current := livekit.NodeID("")
+		best := time.Duration(math.MaxInt64)
+		for n, d := range dist {
+			if !visited[n] && d < best {
+				current, best = n, d
+			}
+		}
+		if current == "" {
+			return nil, 0
+		}
+		if current == to {
+			break
+		}
+		visited[current] = true
+
+		for n := range nodes {
+			if visited[n] {
+				continue
+			}
+			rtt, ok := m.Latency(current, n)
+			if !ok {
+				continue
+			}
+			if d, ok := dist[n]; !ok || best+rtt < d {
+				dist[n] = best + rtt
+				prev[n] = current
+			}
+		}
+	}
+
+	path := []livekit.NodeID{to}
+	for n := to; n != from; {
+		n = prev[n]
+		path = append([]livekit.NodeID{n}, path...)
+	}
+	return path, dist[to]
+}
+
+func latencyProbeInterval(kind string, interval time.Duration) time.Duration {
+	if interval == 0 && kind == "latency" {
+		return defaultLatencyProbeInterval
+	}
+	return interval
+}
+
+// probes the other nodes and publishes the measured RTTs, refreshing the cached map of the whole cluster
+func (r *RedisRouter) latencyWorker() {
+	ticker := time.NewTicker(r.latencyProbeInterval)
+	defer ticker.Stop()
+
+	for {
+		r.probeLatencies()
+
+		select {
+		case <-ticker.C:
+		case <-r.ctx.Done():
+			return
+		}
+	}
+}
+
+func (r *RedisRouter) probeLatencies() {
+	nodes, err := r.ListNodes()
+	if err != nil {
+		logger.Warnw("could not list nodes to probe", err)
+		return
+	}
+
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	rtts := make(map[livekit.NodeID]time.Duration)
+	for _, node := range nodes {
+		if node.Id == r.currentNode.Id || node.Ip == "" || !selector.IsAvailable(node) {
+			continue
+		}
+		wg.Add(1)
+		go func(node *livekit.Node) {
+			defer wg.Done()
+			rtt, err := probeNode(r.ctx, net.JoinHostPort(node.Ip, strconv.Itoa(int(r.latencyProbePort))))
+			if err != nil {
+				logger.Debugw("could not probe node", "error", err, "nodeID", node.Id, "ip", node.Ip)
+				return
+			}
+			lock.Lock()
+			rtts[livekit.NodeID(node.Id)] = rtt
+			lock.Unlock()
+		}(node)
+	}
+	wg.Wait()
+
+	data, err := json.Marshal(rtts)
+	if err != nil {
+		return
+	}
+	if err := r.rc.HSet(r.ctx, NodeLatencyKey, r.currentNode.Id, data).Err(); err != nil {
+		logger.Warnw("could not store node latencies", err)
+		return
+	}
+
+	latencies, err := r.GetLatencyMap()
+	if err != nil {
+		logger.Warnw("could not load latency map", err)
+		return
+	}
+	r.latencies.Store(&latencies)
+}
+
+// probeNode measures the RTT to address as the fastest of a few TCP handshakes
+func probeNode(ctx context.Context, address string) (time.Duration, error) {
+	dialer := net.Dialer{Timeout: latencyProbeTimeout}
+	var best time.Duration
+	for i := 0; i < latencyProbeSamples; i++ {
+		start := time.Now()
+		conn, err := dialer.DialContext(ctx, "tcp", address)
+		if err != nil {
+			return 0, err
+		}
+		rtt := time.Since(start)
+		_ = conn.Close()
+
+		if best == 0 || rtt < best {
+			best = rtt
+		}
+	}
+	return best, nil
+}
+
+func (r *RedisRouter) NodeLatency(nodeID livekit.NodeID) (time.Duration, bool) {
+	latencies := r.latencies.Load()
+	if latencies == nil {
+		return 0, false
+	}
+	return latencies.Latency(livekit.NodeID(r.currentNode.Id), nodeID)
+}
+
+func (r *RedisRouter) GetLatencyMap() (LatencyMap, error) {
+	items, err := r.rc.HGetAll(r.ctx, NodeLatencyKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	latencies := make(LatencyMap, len(items))
+	for id, data := range items {
+		rtts := make(map[livekit.NodeID]time.Duration)
+		if err := json.Unmarshal([]byte(data), &rtts); err != nil {
+			return nil, err
+		}
+		latencies[livekit.NodeID(id)] = rtts
+	}
+	return latencies, nil
+}
@@ -0,0 +1,60 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package routing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/protocol/livekit"
+)
+
+func TestLatencyMap(t *testing.T) {
+	latencies := LatencyMap{
+		"nd_a": {"nd_b": 10 * time.Millisecond, "nd_c": 200 * time.Millisecond},
+		"nd_b": {"nd_c": 30 * time.Millisecond},
+		"nd_d": {"nd_a": 5 * time.Millisecond},
+	}
+
+	t.Run("latency measured in either direction", func(t *testing.T) {
+		rtt, ok := latencies.Latency("nd_a", "nd_b")
+		require.True(t, ok)
+		require.Equal(t, 10*time.Millisecond, rtt)
+
+		rtt, ok = latencies.Latency("nd_a", "nd_d")
+		require.True(t, ok)
+		require.Equal(t, 5*time.Millisecond, rtt)
+
+		_, ok = latencies.Latency("nd_c", "nd_d")
+		require.False(t, ok)
+	})
+
+	t.Run("relay path prefers lower total RTT", func(t *testing.T) {
+		path, rtt := latencies.RelayPath("nd_a", "nd_c")
+		require.Equal(t, []livekit.NodeID{"nd_a", "nd_b", "nd_c"}, path)
+		require.Equal(t, 40*time.Millisecond, rtt)
+
+		path, rtt = latencies.RelayPath("nd_d", "nd_c")
+		require.Equal(t, []livekit.NodeID{"nd_d", "nd_a", "nd_b", "nd_c"}, path)
+		require.Equal(t, 45*time.Millisecond, rtt)
+	})
+
+	t.Run("no relay path to unknown nodes", func(t *testing.T) {
+		path, _ := latencies.RelayPath("nd_a", "nd_e")
+		require.Nil(t, path)
+	})
+}
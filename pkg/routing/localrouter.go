@@ -19,11 +19,14 @@ import (
 	"sync"
 	"time"
 
+	"go.opentelemetry.io/otel/attribute"
 	"go.uber.org/atomic"
 	"google.golang.org/protobuf/proto"
 
 	"github.com/livekit/protocol/livekit"
 	"github.com/livekit/protocol/logger"
+
+	"github.com/livekit/livekit-server/pkg/telemetry/tracing"
 )
 
 // aggregated channel for all participants
@@ -44,6 +47,9 @@ type LocalRouter struct {
 
 	onNewParticipant NewParticipantCallback
 	onRTCMessage     RTCMessageCallback
+
+	// unix time until which node stats are not updated, for failure injection
+	statsStalledUntil atomic.Int64
 }
 
 func NewLocalRouter(currentNode LocalNode, signalClient SignalClient) *LocalRouter {
@@ -98,6 +104,11 @@ func (r *LocalRouter) ListNodes() ([]*livekit.Node, error) {
 }
 
 func (r *LocalRouter) StartParticipantSignal(ctx context.Context, roomName livekit.RoomName, pi ParticipantInit) (connectionID livekit.ConnectionID, reqSink MessageSink, resSource MessageSource, err error) {
+	ctx, span := tracing.Start(ctx, "routing.StartParticipantSignal", attribute.String("room", string(roomName)))
+	defer func() {
+		tracing.End(span, err)
+	}()
+
 	return r.StartParticipantSignalWithNodeID(ctx, roomName, pi, livekit.NodeID(r.currentNode.Id))
 }
 
@@ -185,6 +196,9 @@ func (r *LocalRouter) statsWorker() {
 		}
 		// update every 10 seconds
 		<-time.After(statsUpdateInterval)
+		if r.isStatsStalled() {
+			continue
+		}
 		r.lock.Lock()
 		r.currentNode.Stats.UpdatedAt = time.Now().Unix()
 		r.lock.Unlock()
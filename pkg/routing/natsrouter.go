@@ -0,0 +1,645 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package routing
+
+import (
+	"context"
+	"encoding/base64"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/atomic"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/logger"
+	"github.com/livekit/protocol/utils"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/routing/selector"
+	"github.com/livekit/livekit-server/pkg/telemetry/prometheus"
+	"github.com/livekit/livekit-server/pkg/telemetry/tracing"
+)
+
+const (
+	// key-value bucket of node_id => Node proto
+	NATSNodesBucket = "livekit_nodes"
+
+	// key-value bucket of room_name => node_id
+	NATSRoomNodesBucket = "livekit_room_nodes"
+
+	// key-value bucket of participant and connection => node_id, entries expire after participantMappingTTL
+	NATSParticipantNodesBucket = "livekit_participant_nodes"
+)
+
+// NATSKeyValue returns the key-value bucket, creating it when it doesn't exist yet
+func NATSKeyValue(nc *nats.Conn, bucket string, ttl time.Duration, replicas int) (nats.KeyValue, error) {
+	js, err := nc.JetStream()
+	if err != nil {
+		return nil, err
+	}
+	kv, err := js.KeyValue(bucket)
+	if err == nats.ErrBucketNotFound {
+		kv, err = js.CreateKeyValue(&nats.KeyValueConfig{
+			Bucket:   bucket,
+			TTL:      ttl,
+			Replicas: replicas,
+		})
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not open key-value bucket %s", bucket)
+	}
+	return kv, nil
+}
+
+// NATSKey encodes s into a valid key of a key-value bucket, room names and identities may hold any character
+func NATSKey(s string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(s))
+}
+
+// NATSPublisher publishes router messages to NATS subjects. Messages are not batched, the NATS client
+// already coalesces writes to the server
+type NATSPublisher struct {
+	nc     *nats.Conn
+	config config.RouterMessagesConfig
+}
+
+func NewNATSPublisher(nc *nats.Conn, conf config.RouterMessagesConfig) *NATSPublisher {
+	return &NATSPublisher{
+		nc:     nc,
+		config: conf,
+	}
+}
+
+func (p *NATSPublisher) IsSharded() bool {
+	return false
+}
+
+func (p *NATSPublisher) Publish(channel string, data []byte) error {
+	payload, err := encodeFrame([][]byte{data}, p.config.CompressionThreshold)
+	if err != nil {
+		return err
+	}
+	prometheus.RecordRouterPublish(1, len(data), len(payload))
+
+	return p.nc.Publish(channel, payload)
+}
+
+// NATSRouter routes signaling messages across nodes through NATS subjects and keeps node, room and participant
+// assignments in JetStream key-value buckets. Like RedisRouter, the RTC node drives the participant connection
+type NATSRouter struct {
+	*LocalRouter
+
+	nc             *nats.Conn
+	nodes          nats.KeyValue
+	roomNodes      nats.KeyValue
+	participants   nats.KeyValue
+	publisher      *NATSPublisher
+	usePSRPCSignal bool
+	ctx            context.Context
+	isStarted      atomic.Bool
+	nodeMu         sync.RWMutex
+	// previous stats for computing averages
+	prevStats *livekit.NodeStats
+
+	subs   []*nats.Subscription
+	cancel func()
+}
+
+func NewNATSRouter(config *config.Config, lr *LocalRouter, nc *nats.Conn) (*NATSRouter, error) {
+	nodes, err := NATSKeyValue(nc, NATSNodesBucket, 0, config.NATS.Replicas)
+	if err != nil {
+		return nil, err
+	}
+	roomNodes, err := NATSKeyValue(nc, NATSRoomNodesBucket, 0, config.NATS.Replicas)
+	if err != nil {
+		return nil, err
+	}
+	participants, err := NATSKeyValue(nc, NATSParticipantNodesBucket, participantMappingTTL, config.NATS.Replicas)
+	if err != nil {
+		return nil, err
+	}
+
+	nr := &NATSRouter{
+		LocalRouter:    lr,
+		nc:             nc,
+		nodes:          nodes,
+		roomNodes:      roomNodes,
+		participants:   participants,
+		publisher:      NewNATSPublisher(nc, config.RouterMessages),
+		usePSRPCSignal: config.SignalRelay.Enabled,
+	}
+	nr.ctx, nr.cancel = context.WithCancel(context.Background())
+	return nr, nil
+}
+
+func (r *NATSRouter) RegisterNode() error {
+	r.nodeMu.RLock()
+	data, err := proto.Marshal((*livekit.Node)(r.currentNode))
+	r.nodeMu.RUnlock()
+	if err != nil {
+		return err
+	}
+	if _, err := r.nodes.Put(NATSKey(r.currentNode.Id), data); err != nil {
+		return errors.Wrap(err, "could not register node")
+	}
+	return nil
+}
+
+func (r *NATSRouter) UnregisterNode() error {
+	return ignoreKeyNotFound(r.nodes.Delete(NATSKey(r.currentNode.Id)))
+}
+
+func (r *NATSRouter) RemoveDeadNodes() error {
+	nodes, err := r.ListNodes()
+	if err != nil {
+		return err
+	}
+	for _, n := range nodes {
+		if !selector.IsAvailable(n) {
+			if err := ignoreKeyNotFound(r.nodes.Delete(NATSKey(n.Id))); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (r *NATSRouter) GetNodeForRoom(_ context.Context, roomName livekit.RoomName) (*livekit.Node, error) {
+	entry, err := r.roomNodes.Get(NATSKey(string(roomName)))
+	if err == nats.ErrKeyNotFound {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, errors.Wrap(err, "could not get node for room")
+	}
+	return r.GetNode(livekit.NodeID(entry.Value()))
+}
+
+func (r *NATSRouter) SetNodeForRoom(_ context.Context, roomName livekit.RoomName, nodeID livekit.NodeID) error {
+	_, err := r.roomNodes.Put(NATSKey(string(roomName)), []byte(nodeID))
+	return err
+}
+
+func (r *NATSRouter) ClearRoomState(_ context.Context, roomName livekit.RoomName) error {
+	if err := ignoreKeyNotFound(r.roomNodes.Delete(NATSKey(string(roomName)))); err != nil {
+		return errors.Wrap(err, "could not clear room state")
+	}
+	return nil
+}
+
+func (r *NATSRouter) GetNode(nodeID livekit.NodeID) (*livekit.Node, error) {
+	entry, err := r.nodes.Get(NATSKey(string(nodeID)))
+	if err == nats.ErrKeyNotFound {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	n := livekit.Node{}
+	if err = proto.Unmarshal(entry.Value(), &n); err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+func (r *NATSRouter) ListNodes() ([]*livekit.Node, error) {
+	keys, err := r.nodes.Keys()
+	if err == nats.ErrNoKeysFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "could not list nodes")
+	}
+	nodes := make([]*livekit.Node, 0, len(keys))
+	for _, key := range keys {
+		entry, err := r.nodes.Get(key)
+		if err == nats.ErrKeyNotFound {
+			// unregistered in the meantime
+			continue
+		} else if err != nil {
+			return nil, errors.Wrap(err, "could not list nodes")
+		}
+		n := livekit.Node{}
+		if err := proto.Unmarshal(entry.Value(), &n); err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, &n)
+	}
+	return nodes, nil
+}
+
+// StartParticipantSignal signal connection sets up paths to the RTC node, and starts to route messages to that message queue
+func (r *NATSRouter) StartParticipantSignal(ctx context.Context, roomName livekit.RoomName, pi ParticipantInit) (connectionID livekit.ConnectionID, reqSink MessageSink, resSource MessageSource, err error) {
+	ctx, span := tracing.Start(ctx, "routing.StartParticipantSignal", attribute.String("room", string(roomName)))
+	defer func() {
+		tracing.End(span, err)
+	}()
+
+	// find the node where the room is hosted at
+	rtcNode, err := r.GetNodeForRoom(ctx, roomName)
+	if err != nil {
+		return
+	}
+	span.SetAttributes(attribute.String("rtcNodeID", rtcNode.Id))
+
+	if r.usePSRPCSignal {
+		connectionID, reqSink, resSource, err = r.StartParticipantSignalWithNodeID(ctx, roomName, pi, livekit.NodeID(rtcNode.Id))
+		if err != nil {
+			return
+		}
+
+		// map signal & rtc nodes
+		err = r.setParticipantSignalNode(connectionID, r.currentNode.Id)
+		return
+	}
+
+	connectionID = livekit.ConnectionID(utils.NewGuid("CO_"))
+	pKey := ParticipantKeyLegacy(roomName, pi.Identity)
+	pKeyB62 := ParticipantKey(roomName, pi.Identity)
+
+	// map signal & rtc nodes
+	if err = r.setParticipantSignalNode(connectionID, r.currentNode.Id); err != nil {
+		return
+	}
+
+	// set up response channel before sending StartSession and be ready to receive responses.
+	resChan := r.getOrCreateMessageChannel(r.responseChannels, string(connectionID))
+
+	sink := NewRTCNodeSink(r.publisher, livekit.NodeID(rtcNode.Id), connectionID, pKey, pKeyB62)
+
+	ss, err := pi.ToStartSession(roomName, connectionID)
+	if err != nil {
+		return
+	}
+
+	// sends a message to start session
+	err = sink.WriteMessage(ss)
+	if err != nil {
+		return
+	}
+
+	return connectionID, sink, resChan, nil
+}
+
+func (r *NATSRouter) WriteParticipantRTC(_ context.Context, roomName livekit.RoomName, identity livekit.ParticipantIdentity, msg *livekit.RTCNodeMessage) error {
+	pkey := ParticipantKeyLegacy(roomName, identity)
+	pkeyB62 := ParticipantKey(roomName, identity)
+	rtcNode, err := r.getParticipantRTCNode(pkey, pkeyB62)
+	if err != nil {
+		return err
+	}
+
+	rtcSink := NewRTCNodeSink(r.publisher, livekit.NodeID(rtcNode), "ephemeral", pkey, pkeyB62)
+	msg.ParticipantKey = string(pkey)
+	msg.ParticipantKeyB62 = string(pkeyB62)
+	return r.writeRTCMessage(rtcSink, msg)
+}
+
+func (r *NATSRouter) WriteRoomRTC(ctx context.Context, roomName livekit.RoomName, msg *livekit.RTCNodeMessage) error {
+	node, err := r.GetNodeForRoom(ctx, roomName)
+	if err != nil {
+		return err
+	}
+	msg.ParticipantKey = string(ParticipantKeyLegacy(roomName, ""))
+	msg.ParticipantKeyB62 = string(ParticipantKey(roomName, ""))
+	return r.WriteNodeRTC(ctx, node.Id, msg)
+}
+
+func (r *NATSRouter) WriteNodeRTC(_ context.Context, rtcNodeID string, msg *livekit.RTCNodeMessage) error {
+	rtcSink := NewRTCNodeSink(r.publisher, livekit.NodeID(rtcNodeID), "ephemeral", livekit.ParticipantKey(msg.ParticipantKey), livekit.ParticipantKey(msg.ParticipantKeyB62))
+	return r.writeRTCMessage(rtcSink, msg)
+}
+
+func (r *NATSRouter) startParticipantRTC(ss *livekit.StartSession, participantKey livekit.ParticipantKey, participantKeyB62 livekit.ParticipantKey) error {
+	prometheus.IncrementParticipantRtcInit(1)
+	// find the node where the room is hosted at
+	rtcNode, err := r.GetNodeForRoom(r.ctx, livekit.RoomName(ss.RoomName))
+	if err != nil {
+		return err
+	}
+
+	if rtcNode.Id != r.currentNode.Id {
+		err = ErrIncorrectRTCNode
+		logger.Errorw("called participant on incorrect node", err,
+			"rtcNode", rtcNode,
+		)
+		return err
+	}
+
+	if err := r.SetParticipantRTCNode(participantKey, participantKeyB62, rtcNode.Id); err != nil {
+		return err
+	}
+
+	// find signal node to send responses back
+	signalNode, err := r.getParticipantSignalNode(livekit.ConnectionID(ss.ConnectionId))
+	if err != nil {
+		return err
+	}
+
+	if r.onNewParticipant == nil {
+		return ErrHandlerNotDefined
+	}
+
+	// sever the previous connection of the participant, its rtc worker is still consuming off of the channel
+	pkey := participantKey
+	if participantKeyB62 != "" {
+		pkey = participantKeyB62
+	}
+	r.lock.RLock()
+	requestChan, ok := r.requestChannels[string(pkey)]
+	r.lock.RUnlock()
+	if ok {
+		requestChan.Close()
+	}
+
+	pi, err := ParticipantInitFromStartSession(ss, r.currentNode.Region)
+	if err != nil {
+		return err
+	}
+
+	reqChan := r.getOrCreateMessageChannel(r.requestChannels, string(pkey))
+	resSink := NewSignalNodeSink(r.publisher, livekit.NodeID(signalNode), livekit.ConnectionID(ss.ConnectionId))
+	go func() {
+		err := r.onNewParticipant(
+			r.ctx,
+			livekit.RoomName(ss.RoomName),
+			*pi,
+			reqChan,
+			resSink,
+		)
+		if err != nil {
+			logger.Errorw("could not handle new participant", err,
+				"room", ss.RoomName,
+				"participant", ss.Identity,
+			)
+			reqChan.Close()
+			resSink.Close()
+		}
+	}()
+	return nil
+}
+
+func (r *NATSRouter) Start() error {
+	if r.isStarted.Swap(true) {
+		return nil
+	}
+
+	nodeID := livekit.NodeID(r.currentNode.Id)
+	sigSub, err := r.nc.Subscribe(signalNodeChannel(nodeID, false), func(msg *nats.Msg) {
+		payloads, err := decodeFrame(msg.Data)
+		if err != nil {
+			logger.Errorw("could not decode signal messages on sigchan", err)
+			prometheus.MessageCounter.WithLabelValues("signal", "failure").Add(1)
+			return
+		}
+		for _, payload := range payloads {
+			r.processSignalPayload(payload)
+		}
+	})
+	if err != nil {
+		return errors.Wrap(err, "unable to start nats router")
+	}
+	rtcSub, err := r.nc.Subscribe(rtcNodeChannel(nodeID, false), func(msg *nats.Msg) {
+		payloads, err := decodeFrame(msg.Data)
+		if err != nil {
+			logger.Errorw("could not decode RTC messages on rtcchan", err)
+			prometheus.MessageCounter.WithLabelValues("rtc", "failure").Add(1)
+			return
+		}
+		for _, payload := range payloads {
+			r.processRTCPayload(payload)
+		}
+	})
+	if err != nil {
+		_ = sigSub.Unsubscribe()
+		return errors.Wrap(err, "unable to start nats router")
+	}
+	r.subs = []*nats.Subscription{sigSub, rtcSub}
+
+	go r.statsWorker()
+	return nil
+}
+
+func (r *NATSRouter) Drain() {
+	r.nodeMu.Lock()
+	r.currentNode.State = livekit.NodeState_SHUTTING_DOWN
+	r.nodeMu.Unlock()
+	if err := r.RegisterNode(); err != nil {
+		logger.Errorw("failed to mark as draining", err, "nodeID", r.currentNode.Id)
+	}
+}
+
+func (r *NATSRouter) Stop() {
+	if !r.isStarted.Swap(false) {
+		return
+	}
+	logger.Debugw("stopping NATSRouter")
+	for _, sub := range r.subs {
+		_ = sub.Unsubscribe()
+	}
+	_ = r.UnregisterNode()
+	r.cancel()
+}
+
+func (r *NATSRouter) SetParticipantRTCNode(participantKey livekit.ParticipantKey, participantKeyB62 livekit.ParticipantKey, nodeID string) error {
+	var err error
+	if participantKey != "" {
+		if _, err1 := r.participants.Put(NATSKey(participantRTCKey(participantKey)), []byte(nodeID)); err1 != nil {
+			err = errors.Wrap(err1, "could not set rtc node")
+		}
+	}
+	if participantKeyB62 != "" {
+		if _, err2 := r.participants.Put(NATSKey(participantRTCKey(participantKeyB62)), []byte(nodeID)); err2 != nil {
+			err = errors.Wrap(err2, "could not set rtc node")
+		}
+	}
+	return err
+}
+
+func (r *NATSRouter) setParticipantSignalNode(connectionID livekit.ConnectionID, nodeID string) error {
+	if _, err := r.participants.Put(NATSKey(participantSignalKey(connectionID)), []byte(nodeID)); err != nil {
+		return errors.Wrap(err, "could not set signal node")
+	}
+	return nil
+}
+
+func (r *NATSRouter) getParticipantRTCNode(participantKey livekit.ParticipantKey, participantKeyB62 livekit.ParticipantKey) (string, error) {
+	if participantKeyB62 != "" {
+		entry, err := r.participants.Get(NATSKey(participantRTCKey(participantKeyB62)))
+		if err == nil {
+			return string(entry.Value()), nil
+		} else if err != nats.ErrKeyNotFound {
+			return "", err
+		}
+	}
+	entry, err := r.participants.Get(NATSKey(participantRTCKey(participantKey)))
+	if err == nats.ErrKeyNotFound {
+		return "", ErrNodeNotFound
+	} else if err != nil {
+		return "", err
+	}
+	return string(entry.Value()), nil
+}
+
+func (r *NATSRouter) getParticipantSignalNode(connectionID livekit.ConnectionID) (string, error) {
+	entry, err := r.participants.Get(NATSKey(participantSignalKey(connectionID)))
+	if err == nats.ErrKeyNotFound {
+		return "", ErrNodeNotFound
+	} else if err != nil {
+		return "", err
+	}
+	return string(entry.Value()), nil
+}
+
+// update node stats and cleanup
+func (r *NATSRouter) statsWorker() {
+	for r.ctx.Err() == nil {
+		select {
+		case <-time.After(statsUpdateInterval):
+			if r.isStatsStalled() {
+				continue
+			}
+			_ = r.WriteNodeRTC(context.Background(), r.currentNode.Id, &livekit.RTCNodeMessage{
+				Message: &livekit.RTCNodeMessage_KeepAlive{},
+			})
+		case <-r.ctx.Done():
+			return
+		}
+	}
+}
+
+func (r *NATSRouter) processSignalPayload(payload []byte) {
+	sm := livekit.SignalNodeMessage{}
+	if err := proto.Unmarshal(payload, &sm); err != nil {
+		logger.Errorw("could not unmarshal signal message on sigchan", err)
+		prometheus.MessageCounter.WithLabelValues("signal", "failure").Add(1)
+		return
+	}
+
+	r.lock.RLock()
+	resSink := r.responseChannels[sm.ConnectionId]
+	r.lock.RUnlock()
+
+	// the client may have closed the channel already
+	if resSink != nil {
+		switch rmb := sm.Message.(type) {
+		case *livekit.SignalNodeMessage_Response:
+			if err := resSink.WriteMessage(rmb.Response); err != nil {
+				logger.Errorw("error processing signal message", err)
+				prometheus.MessageCounter.WithLabelValues("signal", "failure").Add(1)
+				return
+			}
+		case *livekit.SignalNodeMessage_EndSession:
+			resSink.Close()
+		}
+	}
+	prometheus.MessageCounter.WithLabelValues("signal", "success").Add(1)
+}
+
+func (r *NATSRouter) processRTCPayload(payload []byte) {
+	rm := livekit.RTCNodeMessage{}
+	if err := proto.Unmarshal(payload, &rm); err != nil {
+		logger.Errorw("could not unmarshal RTC message on rtcchan", err)
+		prometheus.MessageCounter.WithLabelValues("rtc", "failure").Add(1)
+		return
+	}
+	if err := r.handleRTCMessage(&rm); err != nil {
+		logger.Errorw("error processing RTC message", err)
+		prometheus.MessageCounter.WithLabelValues("rtc", "failure").Add(1)
+		return
+	}
+	prometheus.MessageCounter.WithLabelValues("rtc", "success").Add(1)
+}
+
+func (r *NATSRouter) handleRTCMessage(rm *livekit.RTCNodeMessage) error {
+	pKey := livekit.ParticipantKey(rm.ParticipantKey)
+	pKeyB62 := livekit.ParticipantKey(rm.ParticipantKeyB62)
+
+	switch rmb := rm.Message.(type) {
+	case *livekit.RTCNodeMessage_StartSession:
+		// RTC session should start on this node
+		if err := r.startParticipantRTC(rmb.StartSession, pKey, pKeyB62); err != nil {
+			return errors.Wrap(err, "could not start participant")
+		}
+
+	case *livekit.RTCNodeMessage_Request:
+		key := pKey
+		if pKeyB62 != "" {
+			key = pKeyB62
+		}
+		r.lock.RLock()
+		requestChan := r.requestChannels[string(key)]
+		r.lock.RUnlock()
+		if requestChan == nil {
+			return ErrChannelClosed
+		}
+		if err := requestChan.WriteMessage(rmb.Request); err != nil {
+			return err
+		}
+
+	case *livekit.RTCNodeMessage_KeepAlive:
+		if time.Since(time.Unix(rm.SenderTime, 0)) > statsUpdateInterval {
+			logger.Infow("keep alive too old, skipping", "senderTime", rm.SenderTime)
+			break
+		}
+
+		r.nodeMu.Lock()
+		if r.prevStats == nil {
+			r.prevStats = r.currentNode.Stats
+		}
+		updated, computedAvg, err := prometheus.GetUpdatedNodeStats(r.currentNode.Stats, r.prevStats)
+		if err != nil {
+			logger.Errorw("could not update node stats", err)
+			r.nodeMu.Unlock()
+			return err
+		}
+		r.currentNode.Stats = updated
+		if computedAvg {
+			r.prevStats = updated
+		}
+		r.nodeMu.Unlock()
+
+		if err := r.RegisterNode(); err != nil {
+			logger.Errorw("could not update node", err)
+		}
+
+	default:
+		// route it to handler
+		if r.onRTCMessage != nil {
+			var roomName livekit.RoomName
+			var identity livekit.ParticipantIdentity
+			var err error
+			if pKeyB62 != "" {
+				roomName, identity, err = parseParticipantKey(pKeyB62)
+			}
+			if err != nil || pKeyB62 == "" {
+				roomName, identity, err = parseParticipantKeyLegacy(pKey)
+			}
+			if err != nil {
+				return err
+			}
+			r.onRTCMessage(r.ctx, roomName, identity, rm)
+		}
+	}
+	return nil
+}
+
+func ignoreKeyNotFound(err error) error {
+	if err == nats.ErrKeyNotFound {
+		return nil
+	}
+	return err
+}
@@ -17,7 +17,6 @@ package routing
 import (
 	"context"
 
-	"github.com/redis/go-redis/v9"
 	"go.uber.org/atomic"
 	"google.golang.org/protobuf/proto"
 
@@ -30,6 +29,9 @@ const (
 
 	// hash of room_name => node_id
 	NodeRoomKey = "room_node_map"
+
+	// channel announcing room names whose node assignment changed
+	RoomNodeInvalidationChannel = "room_node_invalidation"
 )
 
 var redisCtx = context.Background()
@@ -44,15 +46,23 @@ func participantSignalKey(connectionID livekit.ConnectionID) string {
 	return "participant_signal:" + string(connectionID)
 }
 
-func rtcNodeChannel(nodeID livekit.NodeID) string {
+// channels of a node are hash tagged by node id when sharded, so they map to the same cluster slot and
+// are subscribed with a single SSUBSCRIBE
+func rtcNodeChannel(nodeID livekit.NodeID, sharded bool) string {
+	if sharded {
+		return "rtc_channel:{" + string(nodeID) + "}"
+	}
 	return "rtc_channel:" + string(nodeID)
 }
 
-func signalNodeChannel(nodeID livekit.NodeID) string {
+func signalNodeChannel(nodeID livekit.NodeID, sharded bool) string {
+	if sharded {
+		return "signal_channel:{" + string(nodeID) + "}"
+	}
 	return "signal_channel:" + string(nodeID)
 }
 
-func publishRTCMessage(rc redis.UniversalClient, nodeID livekit.NodeID, participantKey livekit.ParticipantKey, participantKeyB62 livekit.ParticipantKey, msg proto.Message) error {
+func publishRTCMessage(publisher NodePublisher, nodeID livekit.NodeID, participantKey livekit.ParticipantKey, participantKeyB62 livekit.ParticipantKey, msg proto.Message) error {
 	rm := &livekit.RTCNodeMessage{
 		ParticipantKey:    string(participantKey),
 		ParticipantKeyB62: string(participantKeyB62),
@@ -80,10 +90,10 @@ func publishRTCMessage(rc redis.UniversalClient, nodeID livekit.NodeID, particip
 
 	// logger.Debugw("publishing to rtc", "rtcChannel", rtcNodeChannel(nodeID),
 	//	"message", rm.Message)
-	return rc.Publish(redisCtx, rtcNodeChannel(nodeID), data).Err()
+	return publisher.Publish(rtcNodeChannel(nodeID, publisher.IsSharded()), data)
 }
 
-func publishSignalMessage(rc redis.UniversalClient, nodeID livekit.NodeID, connectionID livekit.ConnectionID, msg proto.Message) error {
+func publishSignalMessage(publisher NodePublisher, nodeID livekit.NodeID, connectionID livekit.ConnectionID, msg proto.Message) error {
 	rm := &livekit.SignalNodeMessage{
 		ConnectionId: string(connectionID),
 	}
@@ -106,11 +116,11 @@ func publishSignalMessage(rc redis.UniversalClient, nodeID livekit.NodeID, conne
 
 	// logger.Debugw("publishing to signal", "signalChannel", signalNodeChannel(nodeID),
 	//	"message", rm.Message)
-	return rc.Publish(redisCtx, signalNodeChannel(nodeID), data).Err()
+	return publisher.Publish(signalNodeChannel(nodeID, publisher.IsSharded()), data)
 }
 
 type RTCNodeSink struct {
-	rc                redis.UniversalClient
+	publisher         NodePublisher
 	nodeID            livekit.NodeID
 	connectionID      livekit.ConnectionID
 	participantKey    livekit.ParticipantKey
@@ -120,14 +130,14 @@ type RTCNodeSink struct {
 }
 
 func NewRTCNodeSink(
-	rc redis.UniversalClient,
+	publisher NodePublisher,
 	nodeID livekit.NodeID,
 	connectionID livekit.ConnectionID,
 	participantKey livekit.ParticipantKey,
 	participantKeyB62 livekit.ParticipantKey,
 ) *RTCNodeSink {
 	return &RTCNodeSink{
-		rc:                rc,
+		publisher:         publisher,
 		nodeID:            nodeID,
 		connectionID:      connectionID,
 		participantKey:    participantKey,
@@ -139,7 +149,7 @@ func (s *RTCNodeSink) WriteMessage(msg proto.Message) error {
 	if s.isClosed.Load() {
 		return ErrChannelClosed
 	}
-	return publishRTCMessage(s.rc, s.nodeID, s.participantKey, s.participantKeyB62, msg)
+	return publishRTCMessage(s.publisher, s.nodeID, s.participantKey, s.participantKeyB62, msg)
 }
 
 func (s *RTCNodeSink) Close() {
@@ -166,16 +176,16 @@ func (s *RTCNodeSink) ConnectionID() livekit.ConnectionID {
 // ----------------------------------------------------------------------
 
 type SignalNodeSink struct {
-	rc           redis.UniversalClient
+	publisher    NodePublisher
 	nodeID       livekit.NodeID
 	connectionID livekit.ConnectionID
 	isClosed     atomic.Bool
 	onClose      func()
 }
 
-func NewSignalNodeSink(rc redis.UniversalClient, nodeID livekit.NodeID, connectionID livekit.ConnectionID) *SignalNodeSink {
+func NewSignalNodeSink(publisher NodePublisher, nodeID livekit.NodeID, connectionID livekit.ConnectionID) *SignalNodeSink {
 	return &SignalNodeSink{
-		rc:           rc,
+		publisher:    publisher,
 		nodeID:       nodeID,
 		connectionID: connectionID,
 	}
@@ -185,14 +195,14 @@ func (s *SignalNodeSink) WriteMessage(msg proto.Message) error {
 	if s.isClosed.Load() {
 		return ErrChannelClosed
 	}
-	return publishSignalMessage(s.rc, s.nodeID, s.connectionID, msg)
+	return publishSignalMessage(s.publisher, s.nodeID, s.connectionID, msg)
 }
 
 func (s *SignalNodeSink) Close() {
 	if s.isClosed.Swap(true) {
 		return
 	}
-	_ = publishSignalMessage(s.rc, s.nodeID, s.connectionID, &livekit.EndSession{})
+	_ = publishSignalMessage(s.publisher, s.nodeID, s.connectionID, &livekit.EndSession{})
 	if s.onClose != nil {
 		s.onClose()
 	}
@@ -0,0 +1,251 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package routing
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/livekit/protocol/logger"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/telemetry/prometheus"
+)
+
+// Messages published to node channels are either a plain protobuf message or a frame starting with
+// frameMarker, which cannot start a valid protobuf message (field number 0 is reserved).
+// The frame flags tell if the payload is compressed and/or a batch of length prefixed messages.
+const (
+	frameMarker         byte = 0x00
+	frameFlagCompress   byte = 0x01
+	frameFlagBatch      byte = 0x02
+	frameHeaderSize          = 2
+	maxBatchBytes            = 64 * 1024
+	maxDecompressedSize      = 16 * 1024 * 1024
+)
+
+var (
+	ErrInvalidFrame = errors.New("invalid router message frame")
+)
+
+// NodePublisher publishes router messages to the channels of nodes
+type NodePublisher interface {
+	Publish(channel string, data []byte) error
+	// IsSharded returns true if node channels are hash tagged for sharded pub/sub
+	IsSharded() bool
+}
+
+// RedisPublisher publishes router messages to Redis channels, optionally compressing large messages and
+// batching messages to the same channel published within a short interval.
+type RedisPublisher struct {
+	rc     redis.UniversalClient
+	config config.RouterMessagesConfig
+
+	lock     sync.Mutex
+	channels map[string]*channelPublisher
+}
+
+// channelPublisher keeps the messages of a channel in order, batches are published one at a time
+type channelPublisher struct {
+	// held while a batch is taken and published, so that batches are published in the order they were filled
+	publishLock sync.Mutex
+	// protected by RedisPublisher.lock
+	batch *pendingBatch
+	// error of the last batch published in the background, returned by the next publish
+	err error
+}
+
+type pendingBatch struct {
+	messages [][]byte
+	size     int
+}
+
+func NewRedisPublisher(rc redis.UniversalClient, conf config.RouterMessagesConfig) *RedisPublisher {
+	return &RedisPublisher{
+		rc:       rc,
+		config:   conf,
+		channels: make(map[string]*channelPublisher),
+	}
+}
+
+// IsSharded returns true if messages are published with SPUBLISH, reaching only the cluster shard owning
+// the channel instead of every node of the cluster
+func (p *RedisPublisher) IsSharded() bool {
+	return p.config.ShardedPubSub
+}
+
+// Publish publishes a message to the channel. When batching, the message is published with the next batch
+// and errors of a batch published in the background are returned by the next call for the same channel.
+func (p *RedisPublisher) Publish(channel string, data []byte) error {
+	if p.config.BatchInterval <= 0 {
+		return p.publish(channel, [][]byte{data})
+	}
+
+	p.lock.Lock()
+	cp := p.channels[channel]
+	if cp == nil {
+		// node channels are few and long lived, they are kept for the lifetime of the publisher
+		cp = &channelPublisher{}
+		p.channels[channel] = cp
+	}
+	err := cp.err
+	cp.err = nil
+	if cp.batch == nil {
+		cp.batch = &pendingBatch{}
+		time.AfterFunc(p.config.BatchInterval, func() {
+			if err := p.flush(channel, cp); err != nil {
+				logger.Errorw("could not publish router messages", err, "channel", channel)
+				p.lock.Lock()
+				cp.err = err
+				p.lock.Unlock()
+			}
+		})
+	}
+	batch := cp.batch
+	batch.messages = append(batch.messages, data)
+	batch.size += len(data)
+	isFull := batch.size >= maxBatchBytes
+	p.lock.Unlock()
+
+	if isFull {
+		if flushErr := p.flush(channel, cp); flushErr != nil {
+			err = flushErr
+		}
+	}
+	return err
+}
+
+// flush publishes the pending batch of the channel, if any
+func (p *RedisPublisher) flush(channel string, cp *channelPublisher) error {
+	cp.publishLock.Lock()
+	defer cp.publishLock.Unlock()
+
+	p.lock.Lock()
+	batch := cp.batch
+	cp.batch = nil
+	p.lock.Unlock()
+	if batch == nil {
+		// already flushed
+		return nil
+	}
+
+	return p.publish(channel, batch.messages)
+}
+
+func (p *RedisPublisher) publish(channel string, messages [][]byte) error {
+	payload, err := encodeFrame(messages, p.config.CompressionThreshold)
+	if err != nil {
+		return err
+	}
+
+	size := 0
+	for _, msg := range messages {
+		size += len(msg)
+	}
+	prometheus.RecordRouterPublish(len(messages), size, len(payload))
+
+	if p.config.ShardedPubSub {
+		return p.rc.SPublish(redisCtx, channel, payload).Err()
+	}
+	return p.rc.Publish(redisCtx, channel, payload).Err()
+}
+
+func encodeFrame(messages [][]byte, compressionThreshold int) ([]byte, error) {
+	var flags byte
+	var payload []byte
+	if len(messages) == 1 {
+		payload = messages[0]
+	} else {
+		flags |= frameFlagBatch
+		for _, msg := range messages {
+			payload = binary.AppendUvarint(payload, uint64(len(msg)))
+			payload = append(payload, msg...)
+		}
+	}
+
+	if compressionThreshold > 0 && len(payload) >= compressionThreshold {
+		var buf bytes.Buffer
+		w, err := flate.NewWriter(&buf, flate.BestSpeed)
+		if err != nil {
+			return nil, err
+		}
+		if _, err = w.Write(payload); err != nil {
+			return nil, err
+		}
+		if err = w.Close(); err != nil {
+			return nil, err
+		}
+		// keep uncompressed if it does not help
+		if buf.Len() < len(payload) {
+			flags |= frameFlagCompress
+			payload = buf.Bytes()
+		}
+	}
+
+	if flags == 0 {
+		return payload, nil
+	}
+
+	frame := make([]byte, 0, frameHeaderSize+len(payload))
+	frame = append(frame, frameMarker, flags)
+	return append(frame, payload...), nil
+}
+
+// decodeFrame returns the messages carried by a published payload
+func decodeFrame(data []byte) ([][]byte, error) {
+	if len(data) == 0 || data[0] != frameMarker {
+		return [][]byte{data}, nil
+	}
+	if len(data) < frameHeaderSize {
+		return nil, ErrInvalidFrame
+	}
+
+	flags := data[1]
+	payload := data[frameHeaderSize:]
+	if flags&frameFlagCompress != 0 {
+		r := flate.NewReader(bytes.NewReader(payload))
+		decompressed, err := io.ReadAll(io.LimitReader(r, maxDecompressedSize+1))
+		_ = r.Close()
+		if err != nil {
+			return nil, err
+		}
+		if len(decompressed) > maxDecompressedSize {
+			return nil, ErrInvalidFrame
+		}
+		payload = decompressed
+	}
+
+	if flags&frameFlagBatch == 0 {
+		return [][]byte{payload}, nil
+	}
+
+	var messages [][]byte
+	for len(payload) > 0 {
+		size, n := binary.Uvarint(payload)
+		if n <= 0 || uint64(len(payload)-n) < size {
+			return nil, ErrInvalidFrame
+		}
+		messages = append(messages, payload[n:n+int(size)])
+		payload = payload[n+int(size):]
+	}
+	return messages, nil
+}
@@ -0,0 +1,91 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package routing
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/livekit-server/pkg/config"
+)
+
+func TestRouterMessageFrame(t *testing.T) {
+	small := []byte{0x0a, 0x02, 'h', 'i'}
+	large := append([]byte{0x0a}, bytes.Repeat([]byte("participant"), 100)...)
+
+	t.Run("plain message passes through", func(t *testing.T) {
+		frame, err := encodeFrame([][]byte{small}, 0)
+		require.NoError(t, err)
+		require.Equal(t, small, frame)
+
+		messages, err := decodeFrame(frame)
+		require.NoError(t, err)
+		require.Equal(t, [][]byte{small}, messages)
+	})
+
+	t.Run("compressed", func(t *testing.T) {
+		frame, err := encodeFrame([][]byte{large}, 100)
+		require.NoError(t, err)
+		require.Less(t, len(frame), len(large))
+
+		messages, err := decodeFrame(frame)
+		require.NoError(t, err)
+		require.Equal(t, [][]byte{large}, messages)
+	})
+
+	t.Run("batched", func(t *testing.T) {
+		for _, threshold := range []int{0, 100} {
+			frame, err := encodeFrame([][]byte{small, large, small}, threshold)
+			require.NoError(t, err)
+
+			messages, err := decodeFrame(frame)
+			require.NoError(t, err)
+			require.Equal(t, [][]byte{small, large, small}, messages)
+		}
+	})
+
+	t.Run("truncated batch", func(t *testing.T) {
+		frame, err := encodeFrame([][]byte{small, large}, 0)
+		require.NoError(t, err)
+
+		_, err = decodeFrame(frame[:len(frame)-1])
+		require.ErrorIs(t, err, ErrInvalidFrame)
+	})
+}
+
+func TestRedisPublisherErrors(t *testing.T) {
+	// nothing listens on the port, every publish fails
+	rc := redis.NewUniversalClient(&redis.UniversalOptions{
+		Addrs:      []string{"127.0.0.1:1"},
+		MaxRetries: -1,
+	})
+	t.Cleanup(func() { _ = rc.Close() })
+	p := NewRedisPublisher(rc, config.RouterMessagesConfig{BatchInterval: 10 * time.Millisecond})
+
+	t.Run("background flush error is returned by the next publish", func(t *testing.T) {
+		require.NoError(t, p.Publish("node1", []byte{0x0a}))
+		require.Eventually(t, func() bool {
+			return p.Publish("node1", []byte{0x0a}) != nil
+		}, time.Second, 20*time.Millisecond)
+	})
+
+	t.Run("full batch error is returned", func(t *testing.T) {
+		require.Error(t, p.Publish("node2", make([]byte, maxBatchBytes)))
+	})
+}
@@ -23,6 +23,7 @@ import (
 
 	"github.com/pkg/errors"
 	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
 	"go.uber.org/atomic"
 	"google.golang.org/protobuf/proto"
 
@@ -33,6 +34,7 @@ import (
 	"github.com/livekit/livekit-server/pkg/config"
 	"github.com/livekit/livekit-server/pkg/routing/selector"
 	"github.com/livekit/livekit-server/pkg/telemetry/prometheus"
+	"github.com/livekit/livekit-server/pkg/telemetry/tracing"
 )
 
 const (
@@ -49,6 +51,7 @@ type RedisRouter struct {
 	*LocalRouter
 
 	rc             redis.UniversalClient
+	publisher      *RedisPublisher
 	usePSRPCSignal bool
 	ctx            context.Context
 	isStarted      atomic.Bool
@@ -56,15 +59,43 @@ type RedisRouter struct {
 	// previous stats for computing averages
 	prevStats *livekit.NodeStats
 
+	// nil if disabled
+	roomNodeCache *roomNodeCache
+
 	pubsub *redis.PubSub
-	cancel func()
+	// subscription to room node invalidations, when node channels are sharded
+	invalidationPubsub *redis.PubSub
+	cancel             func()
+
+	// zero if probing is disabled
+	latencyProbeInterval time.Duration
+	latencyProbePort     uint32
+	latencies            atomic.Pointer[LatencyMap]
+
+	// set while redis is unreachable, only rooms hosted on this node are routed then
+	degraded       atomic.Bool
+	localRoomsLock sync.RWMutex
+	localRooms     map[livekit.RoomName]bool
+	// rooms closed while degraded, their mapping is cleared once redis is back
+	unclearedRooms map[livekit.RoomName]bool
+	onRecovered    []func()
 }
 
 func NewRedisRouter(config *config.Config, lr *LocalRouter, rc redis.UniversalClient) *RedisRouter {
 	rr := &RedisRouter{
 		LocalRouter:    lr,
 		rc:             rc,
+		publisher:      NewRedisPublisher(rc, config.RouterMessages),
 		usePSRPCSignal: config.SignalRelay.Enabled,
+
+		latencyProbeInterval: latencyProbeInterval(config.NodeSelector.Kind, config.NodeSelector.LatencyProbeInterval),
+		latencyProbePort:     config.Port,
+
+		localRooms:     make(map[livekit.RoomName]bool),
+		unclearedRooms: make(map[livekit.RoomName]bool),
+	}
+	if config.RoomDirectory.CacheTTL > 0 {
+		rr.roomNodeCache = newRoomNodeCache(config.RoomDirectory.CacheTTL)
 	}
 	rr.ctx, rr.cancel = context.WithCancel(context.Background())
 	return rr
@@ -85,6 +116,7 @@ func (r *RedisRouter) RegisterNode() error {
 
 func (r *RedisRouter) UnregisterNode() error {
 	// could be called after Stop(), so we'd want to use an unrelated context
+	_ = r.rc.HDel(context.Background(), NodeLatencyKey, r.currentNode.Id).Err()
 	return r.rc.HDel(context.Background(), NodesKey, r.currentNode.Id).Err()
 }
 
@@ -98,12 +130,23 @@ func (r *RedisRouter) RemoveDeadNodes() error {
 			if err := r.rc.HDel(context.Background(), NodesKey, n.Id).Err(); err != nil {
 				return err
 			}
+			_ = r.rc.HDel(context.Background(), NodeLatencyKey, n.Id).Err()
 		}
 	}
 	return nil
 }
 
 func (r *RedisRouter) GetNodeForRoom(_ context.Context, roomName livekit.RoomName) (*livekit.Node, error) {
+	if r.degraded.Load() {
+		return r.localNodeForRoom(roomName)
+	}
+
+	if r.roomNodeCache != nil {
+		if nodeID, ok := r.roomNodeCache.Get(roomName); ok {
+			return r.GetNode(nodeID)
+		}
+	}
+
 	nodeID, err := r.rc.HGet(r.ctx, NodeRoomKey, string(roomName)).Result()
 	if err == redis.Nil {
 		return nil, ErrNotFound
@@ -111,20 +154,47 @@ func (r *RedisRouter) GetNodeForRoom(_ context.Context, roomName livekit.RoomNam
 		return nil, errors.Wrap(err, "could not get node for room")
 	}
 
+	if r.roomNodeCache != nil {
+		r.roomNodeCache.Set(roomName, livekit.NodeID(nodeID))
+	}
 	return r.GetNode(livekit.NodeID(nodeID))
 }
 
 func (r *RedisRouter) SetNodeForRoom(_ context.Context, roomName livekit.RoomName, nodeID livekit.NodeID) error {
-	return r.rc.HSet(r.ctx, NodeRoomKey, string(roomName), string(nodeID)).Err()
+	if err := r.rc.HSet(r.ctx, NodeRoomKey, string(roomName), string(nodeID)).Err(); err != nil {
+		return err
+	}
+
+	r.invalidateRoomNode(roomName)
+	return nil
 }
 
 func (r *RedisRouter) ClearRoomState(_ context.Context, roomName livekit.RoomName) error {
 	if err := r.rc.HDel(context.Background(), NodeRoomKey, string(roomName)).Err(); err != nil {
+		if r.degraded.Load() {
+			r.localRoomsLock.Lock()
+			r.unclearedRooms[roomName] = true
+			r.localRoomsLock.Unlock()
+		}
 		return errors.Wrap(err, "could not clear room state")
 	}
+
+	r.invalidateRoomNode(roomName)
 	return nil
 }
 
+// drops the cached mapping on all nodes
+func (r *RedisRouter) invalidateRoomNode(roomName livekit.RoomName) {
+	if r.roomNodeCache == nil {
+		return
+	}
+
+	r.roomNodeCache.Invalidate(roomName)
+	if err := r.rc.Publish(context.Background(), RoomNodeInvalidationChannel, string(roomName)).Err(); err != nil {
+		logger.Warnw("could not publish room node invalidation", err, "room", roomName)
+	}
+}
+
 func (r *RedisRouter) GetNode(nodeID livekit.NodeID) (*livekit.Node, error) {
 	data, err := r.rc.HGet(r.ctx, NodesKey, string(nodeID)).Result()
 	if err == redis.Nil {
@@ -157,11 +227,22 @@ func (r *RedisRouter) ListNodes() ([]*livekit.Node, error) {
 
 // StartParticipantSignal signal connection sets up paths to the RTC node, and starts to route messages to that message queue
 func (r *RedisRouter) StartParticipantSignal(ctx context.Context, roomName livekit.RoomName, pi ParticipantInit) (connectionID livekit.ConnectionID, reqSink MessageSink, resSource MessageSource, err error) {
+	ctx, span := tracing.Start(ctx, "routing.StartParticipantSignal", attribute.String("room", string(roomName)))
+	defer func() {
+		tracing.End(span, err)
+	}()
+
 	// find the node where the room is hosted at
 	rtcNode, err := r.GetNodeForRoom(ctx, roomName)
 	if err != nil {
 		return
 	}
+	span.SetAttributes(attribute.String("rtcNodeID", rtcNode.Id))
+
+	if r.degraded.Load() {
+		// the room is hosted on this node, which routes it without redis
+		return r.startLocalParticipantSignal(roomName, pi)
+	}
 
 	if r.usePSRPCSignal {
 		connectionID, reqSink, resSource, err = r.StartParticipantSignalWithNodeID(ctx, roomName, pi, livekit.NodeID(rtcNode.Id))
@@ -187,7 +268,7 @@ func (r *RedisRouter) StartParticipantSignal(ctx context.Context, roomName livek
 	// set up response channel before sending StartSession and be ready to receive responses.
 	resChan := r.getOrCreateMessageChannel(r.responseChannels, string(connectionID))
 
-	sink := NewRTCNodeSink(r.rc, livekit.NodeID(rtcNode.Id), connectionID, pKey, pKeyB62)
+	sink := NewRTCNodeSink(r.publisher, livekit.NodeID(rtcNode.Id), connectionID, pKey, pKeyB62)
 
 	// serialize claims
 	ss, err := pi.ToStartSession(roomName, connectionID)
@@ -212,7 +293,7 @@ func (r *RedisRouter) WriteParticipantRTC(_ context.Context, roomName livekit.Ro
 		return err
 	}
 
-	rtcSink := NewRTCNodeSink(r.rc, livekit.NodeID(rtcNode), "ephemeral", pkey, pkeyB62)
+	rtcSink := NewRTCNodeSink(r.publisher, livekit.NodeID(rtcNode), "ephemeral", pkey, pkeyB62)
 	msg.ParticipantKey = string(ParticipantKeyLegacy(roomName, identity))
 	msg.ParticipantKeyB62 = string(ParticipantKey(roomName, identity))
 	return r.writeRTCMessage(rtcSink, msg)
@@ -229,7 +310,7 @@ func (r *RedisRouter) WriteRoomRTC(ctx context.Context, roomName livekit.RoomNam
 }
 
 func (r *RedisRouter) WriteNodeRTC(_ context.Context, rtcNodeID string, msg *livekit.RTCNodeMessage) error {
-	rtcSink := NewRTCNodeSink(r.rc, livekit.NodeID(rtcNodeID), "ephemeral", livekit.ParticipantKey(msg.ParticipantKey), livekit.ParticipantKey(msg.ParticipantKeyB62))
+	rtcSink := NewRTCNodeSink(r.publisher, livekit.NodeID(rtcNodeID), "ephemeral", livekit.ParticipantKey(msg.ParticipantKey), livekit.ParticipantKey(msg.ParticipantKeyB62))
 	return r.writeRTCMessage(rtcSink, msg)
 }
 
@@ -289,7 +370,7 @@ func (r *RedisRouter) startParticipantRTC(ss *livekit.StartSession, participantK
 	}
 
 	reqChan := r.getOrCreateMessageChannel(r.requestChannels, string(pkey))
-	resSink := NewSignalNodeSink(r.rc, livekit.NodeID(signalNode), livekit.ConnectionID(ss.ConnectionId))
+	resSink := NewSignalNodeSink(r.publisher, livekit.NodeID(signalNode), livekit.ConnectionID(ss.ConnectionId))
 	go func() {
 		err := r.onNewParticipant(
 			r.ctx,
@@ -318,7 +399,11 @@ func (r *RedisRouter) Start() error {
 
 	workerStarted := make(chan struct{})
 	go r.statsWorker()
+	go r.healthWorker()
 	go r.redisWorker(workerStarted)
+	if r.latencyProbeInterval > 0 {
+		go r.latencyWorker()
+	}
 
 	// wait until worker is running
 	select {
@@ -344,6 +429,9 @@ func (r *RedisRouter) Stop() {
 	}
 	logger.Debugw("stopping RedisRouter")
 	_ = r.pubsub.Close()
+	if r.invalidationPubsub != nil {
+		_ = r.invalidationPubsub.Close()
+	}
 	_ = r.UnregisterNode()
 	r.cancel()
 }
@@ -403,13 +491,27 @@ func (r *RedisRouter) getParticipantSignalNode(connectionID livekit.ConnectionID
 // update node stats and cleanup
 func (r *RedisRouter) statsWorker() {
 	goroutineDumped := false
+	// stats are not updated while degraded, they catch up with the first keep alive after
+	recovering := false
 	for r.ctx.Err() == nil {
 		// update periodically
 		select {
 		case <-time.After(statsUpdateInterval):
+			if r.isStatsStalled() {
+				continue
+			}
+			// keep alives go through redis
+			if r.degraded.Load() {
+				recovering = true
+				continue
+			}
 			_ = r.WriteNodeRTC(context.Background(), r.currentNode.Id, &livekit.RTCNodeMessage{
 				Message: &livekit.RTCNodeMessage_KeepAlive{},
 			})
+			if recovering {
+				recovering = false
+				continue
+			}
 			r.nodeMu.RLock()
 			stats := r.currentNode.Stats
 			r.nodeMu.RUnlock()
@@ -433,6 +535,16 @@ func (r *RedisRouter) statsWorker() {
 	}
 }
 
+// worker that consumes room node invalidations, when they are subscribed apart from node channels
+func (r *RedisRouter) invalidationWorker(pubsub *redis.PubSub) {
+	for msg := range pubsub.Channel() {
+		if msg == nil {
+			return
+		}
+		r.roomNodeCache.Invalidate(livekit.RoomName(msg.Payload))
+	}
+}
+
 // worker that consumes redis messages intended for this node
 func (r *RedisRouter) redisWorker(startedChan chan struct{}) {
 	defer func() {
@@ -440,9 +552,23 @@ func (r *RedisRouter) redisWorker(startedChan chan struct{}) {
 	}()
 	logger.Debugw("starting redisWorker", "nodeID", r.currentNode.Id)
 
-	sigChannel := signalNodeChannel(livekit.NodeID(r.currentNode.Id))
-	rtcChannel := rtcNodeChannel(livekit.NodeID(r.currentNode.Id))
-	r.pubsub = r.rc.Subscribe(r.ctx, sigChannel, rtcChannel)
+	sharded := r.publisher.IsSharded()
+	sigChannel := signalNodeChannel(livekit.NodeID(r.currentNode.Id), sharded)
+	rtcChannel := rtcNodeChannel(livekit.NodeID(r.currentNode.Id), sharded)
+	if sharded {
+		// invalidations are meant for all nodes and stay on a regular channel
+		r.pubsub = r.rc.SSubscribe(r.ctx, sigChannel, rtcChannel)
+		if r.roomNodeCache != nil {
+			r.invalidationPubsub = r.rc.Subscribe(r.ctx, RoomNodeInvalidationChannel)
+			go r.invalidationWorker(r.invalidationPubsub)
+		}
+	} else {
+		channels := []string{sigChannel, rtcChannel}
+		if r.roomNodeCache != nil {
+			channels = append(channels, RoomNodeInvalidationChannel)
+		}
+		r.pubsub = r.rc.Subscribe(r.ctx, channels...)
+	}
 
 	close(startedChan)
 	for msg := range r.pubsub.Channel() {
@@ -450,36 +576,62 @@ func (r *RedisRouter) redisWorker(startedChan chan struct{}) {
 			return
 		}
 
-		if msg.Channel == sigChannel {
-			sm := livekit.SignalNodeMessage{}
-			if err := proto.Unmarshal([]byte(msg.Payload), &sm); err != nil {
-				logger.Errorw("could not unmarshal signal message on sigchan", err)
+		if msg.Channel == RoomNodeInvalidationChannel {
+			r.roomNodeCache.Invalidate(livekit.RoomName(msg.Payload))
+		} else if msg.Channel == sigChannel {
+			payloads, err := decodeFrame([]byte(msg.Payload))
+			if err != nil {
+				logger.Errorw("could not decode signal messages on sigchan", err)
 				prometheus.MessageCounter.WithLabelValues("signal", "failure").Add(1)
 				continue
 			}
-			if err := r.handleSignalMessage(&sm); err != nil {
-				logger.Errorw("error processing signal message", err)
-				prometheus.MessageCounter.WithLabelValues("signal", "failure").Add(1)
-				continue
+			for _, payload := range payloads {
+				r.processSignalPayload(payload)
 			}
-			prometheus.MessageCounter.WithLabelValues("signal", "success").Add(1)
 		} else if msg.Channel == rtcChannel {
-			rm := livekit.RTCNodeMessage{}
-			if err := proto.Unmarshal([]byte(msg.Payload), &rm); err != nil {
-				logger.Errorw("could not unmarshal RTC message on rtcchan", err)
+			payloads, err := decodeFrame([]byte(msg.Payload))
+			if err != nil {
+				logger.Errorw("could not decode RTC messages on rtcchan", err)
 				prometheus.MessageCounter.WithLabelValues("rtc", "failure").Add(1)
 				continue
 			}
-			if err := r.handleRTCMessage(&rm); err != nil {
-				logger.Errorw("error processing RTC message", err)
-				prometheus.MessageCounter.WithLabelValues("rtc", "failure").Add(1)
-				continue
+			for _, payload := range payloads {
+				r.processRTCPayload(payload)
 			}
-			prometheus.MessageCounter.WithLabelValues("rtc", "success").Add(1)
 		}
 	}
 }
 
+func (r *RedisRouter) processSignalPayload(payload []byte) {
+	sm := livekit.SignalNodeMessage{}
+	if err := proto.Unmarshal(payload, &sm); err != nil {
+		logger.Errorw("could not unmarshal signal message on sigchan", err)
+		prometheus.MessageCounter.WithLabelValues("signal", "failure").Add(1)
+		return
+	}
+	if err := r.handleSignalMessage(&sm); err != nil {
+		logger.Errorw("error processing signal message", err)
+		prometheus.MessageCounter.WithLabelValues("signal", "failure").Add(1)
+		return
+	}
+	prometheus.MessageCounter.WithLabelValues("signal", "success").Add(1)
+}
+
+func (r *RedisRouter) processRTCPayload(payload []byte) {
+	rm := livekit.RTCNodeMessage{}
+	if err := proto.Unmarshal(payload, &rm); err != nil {
+		logger.Errorw("could not unmarshal RTC message on rtcchan", err)
+		prometheus.MessageCounter.WithLabelValues("rtc", "failure").Add(1)
+		return
+	}
+	if err := r.handleRTCMessage(&rm); err != nil {
+		logger.Errorw("error processing RTC message", err)
+		prometheus.MessageCounter.WithLabelValues("rtc", "failure").Add(1)
+		return
+	}
+	prometheus.MessageCounter.WithLabelValues("rtc", "success").Add(1)
+}
+
 func (r *RedisRouter) handleSignalMessage(sm *livekit.SignalNodeMessage) error {
 	connectionID := sm.ConnectionId
 
@@ -0,0 +1,97 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package routing
+
+import (
+	"sync"
+	"time"
+
+	"github.com/livekit/protocol/livekit"
+)
+
+// roomNodeCache is a node local, short lived cache of room => node id mappings, saving a Redis round trip
+// on lookups during join storms. Entries are invalidated through Redis pub/sub when a mapping changes
+// and expire after ttl in case an invalidation is missed.
+type roomNodeCache struct {
+	ttl time.Duration
+
+	lock      sync.Mutex
+	entries   map[livekit.RoomName]roomNodeCacheEntry
+	lastPrune time.Time
+}
+
+type roomNodeCacheEntry struct {
+	nodeID    livekit.NodeID
+	expiresAt time.Time
+}
+
+func newRoomNodeCache(ttl time.Duration) *roomNodeCache {
+	return &roomNodeCache{
+		ttl:       ttl,
+		entries:   make(map[livekit.RoomName]roomNodeCacheEntry),
+		lastPrune: time.Now(),
+	}
+}
+
+func (c *roomNodeCache) Get(roomName livekit.RoomName) (livekit.NodeID, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	entry, ok := c.entries[roomName]
+	if !ok {
+		return "", false
+	}
+	if time.Now().After(entry.expiresAt) {
+		delete(c.entries, roomName)
+		return "", false
+	}
+	return entry.nodeID, true
+}
+
+func (c *roomNodeCache) Set(roomName livekit.RoomName, nodeID livekit.NodeID) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	now := time.Now()
+	c.entries[roomName] = roomNodeCacheEntry{
+		nodeID:    nodeID,
+		expiresAt: now.Add(c.ttl),
+	}
+
+	// sweep rooms which are not looked up anymore
+	if now.Sub(c.lastPrune) > c.ttl {
+		c.lastPrune = now
+		for name, entry := range c.entries {
+			if now.After(entry.expiresAt) {
+				delete(c.entries, name)
+			}
+		}
+	}
+}
+
+func (c *roomNodeCache) Invalidate(roomName livekit.RoomName) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	delete(c.entries, roomName)
+}
+
+// Clear drops all mappings, used when invalidations may have been missed
+func (c *roomNodeCache) Clear() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.entries = make(map[livekit.RoomName]roomNodeCacheEntry)
+}
@@ -0,0 +1,63 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package routing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoomNodeCache(t *testing.T) {
+	c := newRoomNodeCache(50 * time.Millisecond)
+
+	_, ok := c.Get("room")
+	require.False(t, ok)
+
+	c.Set("room", "node1")
+	nodeID, ok := c.Get("room")
+	require.True(t, ok)
+	require.Equal(t, "node1", string(nodeID))
+
+	// invalidation drops the mapping
+	c.Invalidate("room")
+	_, ok = c.Get("room")
+	require.False(t, ok)
+
+	// expires after ttl
+	c.Set("room", "node2")
+	time.Sleep(60 * time.Millisecond)
+	_, ok = c.Get("room")
+	require.False(t, ok)
+
+	// expired entries of other rooms are swept on set
+	c.Set("other", "node1")
+	time.Sleep(60 * time.Millisecond)
+	c.Set("room", "node2")
+	require.Len(t, c.entries, 1)
+}
+
+func TestRoomNodeCacheClear(t *testing.T) {
+	c := newRoomNodeCache(time.Minute)
+	c.Set("room", "node1")
+	c.Set("other", "node2")
+
+	c.Clear()
+	_, ok := c.Get("room")
+	require.False(t, ok)
+	_, ok = c.Get("other")
+	require.False(t, ok)
+}
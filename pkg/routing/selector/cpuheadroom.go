@@ -0,0 +1,65 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package selector
+
+import (
+	"github.com/livekit/protocol/livekit"
+)
+
+// CPUHeadroomSelector picks the node with the most idle CPUs, so larger nodes take proportionally more rooms
+type CPUHeadroomSelector struct {
+	// nodes with fewer idle CPUs are not picked, 0 disables the limit
+	MinHeadroom float32
+}
+
+func (s *CPUHeadroomSelector) SelectNode(nodes []*livekit.Node) (*livekit.Node, error) {
+	nodes = GetAvailableNodes(nodes)
+	if len(nodes) == 0 {
+		return nil, ErrNoAvailableNodes
+	}
+
+	var selected *livekit.Node
+	var maxHeadroom float32
+	for _, node := range nodes {
+		headroom := GetNodeCPUHeadroom(node)
+		if headroom < s.MinHeadroom {
+			continue
+		}
+		if selected == nil || headroom > maxHeadroom {
+			selected, maxHeadroom = node, headroom
+		}
+	}
+	if selected == nil {
+		return nil, ErrNoAvailableNodes
+	}
+	return selected, nil
+}
+
+// GetNodeCPUHeadroom returns the number of idle CPUs of a node
+func GetNodeCPUHeadroom(node *livekit.Node) float32 {
+	stats := node.Stats
+	if stats == nil {
+		return 0
+	}
+	numCpus := stats.NumCpus
+	if numCpus == 0 {
+		numCpus = 1
+	}
+	idle := 1 - stats.CpuLoad
+	if idle < 0 {
+		idle = 0
+	}
+	return idle * float32(numCpus)
+}
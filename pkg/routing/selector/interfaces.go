@@ -16,6 +16,8 @@ package selector
 
 import (
 	"errors"
+	"fmt"
+	"sync"
 
 	"github.com/livekit/protocol/livekit"
 	"github.com/livekit/protocol/logger"
@@ -30,6 +32,43 @@ type NodeSelector interface {
 	SelectNode(nodes []*livekit.Node) (*livekit.Node, error)
 }
 
+// LatencyAwareNodeSelector is a NodeSelector using the RTTs measured between nodes when probing is enabled
+type LatencyAwareNodeSelector interface {
+	NodeSelector
+	SetLatencyProvider(latencies LatencyProvider)
+}
+
+// NodeSelectorFactory creates a NodeSelector from the server config
+type NodeSelectorFactory func(conf *config.Config) (NodeSelector, error)
+
+var builtinSelectors = map[string]bool{
+	"any":         true,
+	"cpuload":     true,
+	"sysload":     true,
+	"regionaware": true,
+	"latency":     true,
+	"leastrooms":  true,
+	"cpuheadroom": true,
+	"random":      true,
+}
+
+var (
+	customSelectorsLock sync.RWMutex
+	customSelectors     = make(map[string]NodeSelectorFactory)
+)
+
+// RegisterNodeSelector makes a custom node selector available as node_selector.kind, for applications embedding
+// the server. It must be called before the server is created, and panics when kind is already taken
+func RegisterNodeSelector(kind string, factory NodeSelectorFactory) {
+	customSelectorsLock.Lock()
+	defer customSelectorsLock.Unlock()
+
+	if _, ok := customSelectors[kind]; ok || builtinSelectors[kind] || kind == "" {
+		panic(fmt.Sprintf("node selector %q already registered", kind))
+	}
+	customSelectors[kind] = factory
+}
+
 func CreateNodeSelector(conf *config.Config) (NodeSelector, error) {
 	kind := conf.NodeSelector.Kind
 	if kind == "" {
@@ -41,12 +80,12 @@ func CreateNodeSelector(conf *config.Config) (NodeSelector, error) {
 	case "cpuload":
 		return &CPULoadSelector{
 			CPULoadLimit: conf.NodeSelector.CPULoadLimit,
-			SortBy: conf.NodeSelector.SortBy,
+			SortBy:       conf.NodeSelector.SortBy,
 		}, nil
 	case "sysload":
 		return &SystemLoadSelector{
 			SysloadLimit: conf.NodeSelector.SysloadLimit,
-			SortBy: conf.NodeSelector.SortBy,
+			SortBy:       conf.NodeSelector.SortBy,
 		}, nil
 	case "regionaware":
 		s, err := NewRegionAwareSelector(conf.Region, conf.NodeSelector.Regions, conf.NodeSelector.SortBy)
@@ -55,10 +94,32 @@ func CreateNodeSelector(conf *config.Config) (NodeSelector, error) {
 		}
 		s.SysloadLimit = conf.NodeSelector.SysloadLimit
 		return s, nil
+	case "latency":
+		return &LatencyAwareSelector{
+			SystemLoadSelector: SystemLoadSelector{
+				SysloadLimit: conf.NodeSelector.SysloadLimit,
+				SortBy:       conf.NodeSelector.SortBy,
+			},
+			Tolerance: conf.NodeSelector.LatencyTolerance,
+		}, nil
+	case "leastrooms":
+		return &LeastRoomsSelector{
+			Limit: conf.Limit,
+		}, nil
+	case "cpuheadroom":
+		return &CPUHeadroomSelector{
+			MinHeadroom: conf.NodeSelector.MinCPUHeadroom,
+		}, nil
 	case "random":
 		logger.Warnw("random node selector is deprecated, please switch to \"any\" or another selector", nil)
 		return &AnySelector{conf.NodeSelector.SortBy}, nil
 	default:
-		return nil, ErrUnsupportedSelector
+		customSelectorsLock.RLock()
+		factory, ok := customSelectors[kind]
+		customSelectorsLock.RUnlock()
+		if !ok {
+			return nil, ErrUnsupportedSelector
+		}
+		return factory(conf)
 	}
 }
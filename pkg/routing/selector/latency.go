@@ -0,0 +1,71 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package selector
+
+import (
+	"time"
+
+	"github.com/livekit/protocol/livekit"
+)
+
+// LatencyProvider reports the RTT measured from the current node to other nodes
+type LatencyProvider interface {
+	NodeLatency(nodeID livekit.NodeID) (time.Duration, bool)
+}
+
+// LatencyAwareSelector prefers available nodes with the lowest measured RTT to the current node.
+// Nodes within Tolerance of the closest one are selected from using SortBy
+type LatencyAwareSelector struct {
+	SystemLoadSelector
+	Tolerance time.Duration
+	// without latencies, or when no node was measured, it behaves as SystemLoadSelector
+	Latencies LatencyProvider
+}
+
+func (s *LatencyAwareSelector) SetLatencyProvider(latencies LatencyProvider) {
+	s.Latencies = latencies
+}
+
+func (s *LatencyAwareSelector) SelectNode(nodes []*livekit.Node) (*livekit.Node, error) {
+	nodes, err := s.SystemLoadSelector.filterNodes(nodes)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.Latencies != nil {
+		rtts := make(map[*livekit.Node]time.Duration, len(nodes))
+		minRTT := time.Duration(-1)
+		for _, node := range nodes {
+			if rtt, ok := s.Latencies.NodeLatency(livekit.NodeID(node.Id)); ok {
+				rtts[node] = rtt
+				if minRTT < 0 || rtt < minRTT {
+					minRTT = rtt
+				}
+			}
+		}
+
+		if minRTT >= 0 {
+			var closestNodes []*livekit.Node
+			for _, node := range nodes {
+				if rtt, ok := rtts[node]; ok && rtt <= minRTT+s.Tolerance {
+					closestNodes = append(closestNodes, node)
+				}
+			}
+			nodes = closestNodes
+		}
+	}
+
+	return SelectSortedNode(nodes, s.SortBy)
+}
@@ -0,0 +1,104 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package selector_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/protocol/livekit"
+
+	"github.com/livekit/livekit-server/pkg/routing/selector"
+)
+
+type testLatencies map[livekit.NodeID]time.Duration
+
+func (l testLatencies) NodeLatency(nodeID livekit.NodeID) (time.Duration, bool) {
+	rtt, ok := l[nodeID]
+	return rtt, ok
+}
+
+func TestLatencyAwareSelector_SelectNode(t *testing.T) {
+	newSelector := func(latencies selector.LatencyProvider) *selector.LatencyAwareSelector {
+		return &selector.LatencyAwareSelector{
+			SystemLoadSelector: selector.SystemLoadSelector{
+				SysloadLimit: loadLimit,
+				SortBy:       "sysload",
+			},
+			Tolerance: 10 * time.Millisecond,
+			Latencies: latencies,
+		}
+	}
+
+	t.Run("picks the closest node", func(t *testing.T) {
+		far := newTestNodeInRegion(regionEast, true)
+		near := newTestNodeInRegion(regionWest, true)
+		near.Stats.LoadAvgLast1Min = 0.45
+
+		s := newSelector(testLatencies{
+			livekit.NodeID(far.Id):  80 * time.Millisecond,
+			livekit.NodeID(near.Id): 5 * time.Millisecond,
+		})
+		node, err := s.SelectNode([]*livekit.Node{far, near})
+		require.NoError(t, err)
+		require.Equal(t, near, node)
+	})
+
+	t.Run("sorts nodes within tolerance", func(t *testing.T) {
+		near := newTestNodeInRegion(regionWest, true)
+		near.Stats.LoadAvgLast1Min = 0.45
+		nearLessLoaded := newTestNodeInRegion(regionWest, true)
+		nearLessLoaded.Stats.LoadAvgLast1Min = 0.1
+		unmeasured := newTestNodeInRegion(regionEast, true)
+		unmeasured.Stats.LoadAvgLast1Min = 0
+
+		s := newSelector(testLatencies{
+			livekit.NodeID(near.Id):           2 * time.Millisecond,
+			livekit.NodeID(nearLessLoaded.Id): 9 * time.Millisecond,
+		})
+		node, err := s.SelectNode([]*livekit.Node{near, nearLessLoaded, unmeasured})
+		require.NoError(t, err)
+		require.Equal(t, nearLessLoaded, node)
+	})
+
+	t.Run("skips overloaded nodes even when closest", func(t *testing.T) {
+		overloaded := newTestNodeInRegion(regionWest, false)
+		far := newTestNodeInRegion(regionEast, true)
+
+		s := newSelector(testLatencies{
+			livekit.NodeID(overloaded.Id): time.Millisecond,
+			livekit.NodeID(far.Id):        50 * time.Millisecond,
+		})
+		node, err := s.SelectNode([]*livekit.Node{overloaded, far})
+		require.NoError(t, err)
+		require.Equal(t, far, node)
+	})
+
+	t.Run("behaves as sysload without measurements", func(t *testing.T) {
+		loaded := newTestNodeInRegion(regionWest, true)
+		idle := newTestNodeInRegion(regionEast, true)
+		idle.Stats.LoadAvgLast1Min = 0.1
+
+		node, err := newSelector(nil).SelectNode([]*livekit.Node{loaded, idle})
+		require.NoError(t, err)
+		require.Equal(t, idle, node)
+
+		node, err = newSelector(testLatencies{}).SelectNode([]*livekit.Node{loaded, idle})
+		require.NoError(t, err)
+		require.Equal(t, idle, node)
+	})
+}
@@ -0,0 +1,51 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package selector
+
+import (
+	"sort"
+
+	"github.com/livekit/protocol/livekit"
+
+	"github.com/livekit/livekit-server/pkg/config"
+)
+
+// LeastRoomsSelector picks the node hosting the fewest rooms, breaking ties by the number of clients.
+// Nodes that reached their limits are skipped
+type LeastRoomsSelector struct {
+	Limit config.LimitConfig
+}
+
+func (s *LeastRoomsSelector) SelectNode(nodes []*livekit.Node) (*livekit.Node, error) {
+	nodes = GetAvailableNodes(nodes)
+	candidates := make([]*livekit.Node, 0, len(nodes))
+	for _, node := range nodes {
+		if !LimitsReached(s.Limit, node.Stats) {
+			candidates = append(candidates, node)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, ErrNoAvailableNodes
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		si, sj := candidates[i].Stats, candidates[j].Stats
+		if si.GetNumRooms() != sj.GetNumRooms() {
+			return si.GetNumRooms() < sj.GetNumRooms()
+		}
+		return si.GetNumClients() < sj.GetNumClients()
+	})
+	return candidates[0], nil
+}
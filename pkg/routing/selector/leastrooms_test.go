@@ -0,0 +1,117 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package selector_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/protocol/livekit"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/routing/selector"
+)
+
+func TestLeastRoomsSelector(t *testing.T) {
+	sel := selector.LeastRoomsSelector{}
+
+	_, err := sel.SelectNode(nil)
+	require.ErrorIs(t, err, selector.ErrNoAvailableNodes)
+
+	node, err := sel.SelectNode([]*livekit.Node{nodeLoadHigh, nodeLoadMedium, nodeLoadLow})
+	require.NoError(t, err)
+	require.Equal(t, nodeLoadLow, node)
+
+	t.Run("skips nodes at limits", func(t *testing.T) {
+		// fewest rooms, but a few large ones
+		busy := &livekit.Node{
+			State: livekit.NodeState_SERVING,
+			Stats: &livekit.NodeStats{
+				UpdatedAt:    time.Now().Unix(),
+				NumRooms:     1,
+				NumClients:   20,
+				NumTracksIn:  40,
+				NumTracksOut: 800,
+			},
+		}
+		sel := selector.LeastRoomsSelector{Limit: config.LimitConfig{NumTracks: 500}}
+		node, err := sel.SelectNode([]*livekit.Node{busy, nodeLoadMedium})
+		require.NoError(t, err)
+		require.Equal(t, nodeLoadMedium, node)
+
+		_, err = sel.SelectNode([]*livekit.Node{busy})
+		require.ErrorIs(t, err, selector.ErrNoAvailableNodes)
+	})
+}
+
+func TestCPUHeadroomSelector(t *testing.T) {
+	sel := selector.CPUHeadroomSelector{}
+
+	_, err := sel.SelectNode(nil)
+	require.ErrorIs(t, err, selector.ErrNoAvailableNodes)
+
+	node, err := sel.SelectNode([]*livekit.Node{nodeLoadHigh, nodeLoadLow})
+	require.NoError(t, err)
+	require.Equal(t, nodeLoadLow, node)
+
+	t.Run("larger nodes have more headroom", func(t *testing.T) {
+		large := &livekit.Node{
+			State: livekit.NodeState_SERVING,
+			Stats: &livekit.NodeStats{
+				UpdatedAt: nodeLoadLow.Stats.UpdatedAt,
+				NumCpus:   16,
+				CpuLoad:   0.5,
+			},
+		}
+		node, err := sel.SelectNode([]*livekit.Node{nodeLoadLow, large})
+		require.NoError(t, err)
+		require.Equal(t, large, node)
+	})
+
+	t.Run("min headroom", func(t *testing.T) {
+		sel := selector.CPUHeadroomSelector{MinHeadroom: 0.5}
+		_, err := sel.SelectNode([]*livekit.Node{nodeLoadHigh})
+		require.ErrorIs(t, err, selector.ErrNoAvailableNodes)
+	})
+}
+
+type firstNodeSelector struct{}
+
+func (firstNodeSelector) SelectNode(nodes []*livekit.Node) (*livekit.Node, error) {
+	return nodes[0], nil
+}
+
+func TestRegisterNodeSelector(t *testing.T) {
+	selector.RegisterNodeSelector("first", func(conf *config.Config) (selector.NodeSelector, error) {
+		return firstNodeSelector{}, nil
+	})
+	require.Panics(t, func() {
+		selector.RegisterNodeSelector("first", nil)
+	})
+	require.Panics(t, func() {
+		selector.RegisterNodeSelector("sysload", nil)
+	})
+
+	conf := &config.Config{NodeSelector: config.NodeSelectorConfig{Kind: "first"}}
+	sel, err := selector.CreateNodeSelector(conf)
+	require.NoError(t, err)
+	require.IsType(t, firstNodeSelector{}, sel)
+
+	conf.NodeSelector.Kind = "unknown"
+	_, err = selector.CreateNodeSelector(conf)
+	require.ErrorIs(t, err, selector.ErrUnsupportedSelector)
+}
@@ -20,11 +20,14 @@ import (
 	"sync"
 	"time"
 
+	"go.opentelemetry.io/otel/attribute"
 	"go.uber.org/atomic"
 	"google.golang.org/protobuf/proto"
 
 	"github.com/livekit/livekit-server/pkg/config"
 	"github.com/livekit/livekit-server/pkg/telemetry/prometheus"
+	"github.com/livekit/livekit-server/pkg/telemetry/tracing"
+	sutils "github.com/livekit/livekit-server/pkg/utils"
 	"github.com/livekit/protocol/livekit"
 	"github.com/livekit/protocol/logger"
 	"github.com/livekit/protocol/rpc"
@@ -90,16 +93,24 @@ func (r *signalClient) StartParticipantSignal(
 		return
 	}
 
-	l := logger.GetLogger().WithValues(
-		"room", roomName,
-		"reqNodeID", nodeID,
-		"participant", pi.Identity,
-		"connID", connectionID,
-	)
+	l, ok := sutils.LoggerFromContext(ctx)
+	if !ok {
+		l = logger.GetLogger().WithValues("room", roomName, "participant", pi.Identity)
+	}
+	l = l.WithValues("reqNodeID", nodeID, "connID", connectionID)
 
 	l.Debugw("starting signal connection")
 
-	stream, err := r.client.RelaySignal(ctx, nodeID)
+	ctx, span := tracing.Start(ctx, "routing.RelaySignal",
+		attribute.String("rtcNodeID", string(nodeID)),
+		attribute.String("connID", string(connectionID)),
+	)
+	defer func() {
+		tracing.End(span, err)
+	}()
+
+	// the RTC node continues the trace of the join
+	stream, err := r.client.RelaySignal(tracing.InjectMetadata(ctx), nodeID)
 	if err != nil {
 		prometheus.MessageCounter.WithLabelValues("signal", "failure").Add(1)
 		return
@@ -38,7 +38,8 @@ type WebRTCConfig struct {
 }
 
 type ReceiverConfig struct {
-	PacketBufferSize int
+	PacketBufferSize   int
+	ForwarderAuditSize int
 }
 
 type RTPHeaderExtensionConfig struct {
@@ -68,6 +69,22 @@ func NewWebRTCConfig(conf *config.Config) (*WebRTCConfig, error) {
 	// we don't want to use active TCP on a server, clients should be dialing
 	webRTCConfig.SettingEngine.DisableActiveTCP(true)
 
+	cert, err := newDTLSCertificate(rtcConf.DTLS)
+	if err != nil {
+		return nil, err
+	}
+	if cert != nil {
+		webRTCConfig.Configuration.Certificates = []webrtc.Certificate{*cert}
+	}
+
+	if len(rtcConf.SRTPProtectionProfiles) != 0 {
+		profiles, err := parseSRTPProtectionProfiles(rtcConf.SRTPProtectionProfiles)
+		if err != nil {
+			return nil, err
+		}
+		webRTCConfig.SettingEngine.SetSRTPProtectionProfiles(profiles...)
+	}
+
 	if rtcConf.PacketBufferSize == 0 {
 		rtcConf.PacketBufferSize = 500
 	}
@@ -102,6 +119,11 @@ func NewWebRTCConfig(conf *config.Config) (*WebRTCConfig, error) {
 		},
 	}
 
+	// publishers are capped through REMB when publish bitrate limits are set
+	if rtcConf.PublishBitrateLimits.Enabled() {
+		publisherConfig.RTCPFeedback.Video = append(publisherConfig.RTCPFeedback.Video, webrtc.RTCPFeedback{Type: webrtc.TypeRTCPFBGoogREMB})
+	}
+
 	// subscriber configuration
 	subscriberConfig := DirectionConfig{
 		StrictACKs: conf.RTC.StrictACKs,
@@ -127,7 +149,8 @@ func NewWebRTCConfig(conf *config.Config) (*WebRTCConfig, error) {
 	return &WebRTCConfig{
 		WebRTCConfig: *webRTCConfig,
 		Receiver: ReceiverConfig{
-			PacketBufferSize: rtcConf.PacketBufferSize,
+			PacketBufferSize:   rtcConf.PacketBufferSize,
+			ForwarderAuditSize: rtcConf.ForwarderAuditSize,
 		},
 		Publisher:  publisherConfig,
 		Subscriber: subscriberConfig,
@@ -0,0 +1,105 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtc
+
+import (
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/logger"
+
+	"github.com/livekit/livekit-server/pkg/rtc/types"
+	"github.com/livekit/livekit-server/pkg/telemetry/prometheus"
+)
+
+// CrashReport describes a panic recovered in a participant goroutine
+type CrashReport struct {
+	Time          time.Time                   `json:"time"`
+	NodeID        livekit.NodeID              `json:"node_id,omitempty"`
+	Component     string                      `json:"component"`
+	Room          livekit.RoomName            `json:"room,omitempty"`
+	Participant   livekit.ParticipantIdentity `json:"participant,omitempty"`
+	ParticipantID livekit.ParticipantID       `json:"participant_id,omitempty"`
+	Panic         string                      `json:"panic"`
+	Stack         string                      `json:"stack"`
+}
+
+type CrashReporter interface {
+	Report(report *CrashReport)
+}
+
+var (
+	crashReporterMu sync.RWMutex
+	crashReporter   CrashReporter
+)
+
+// SetCrashReporter sets the sink receiving reports of recovered panics, nil only logs them
+func SetCrashReporter(reporter CrashReporter) {
+	crashReporterMu.Lock()
+	crashReporter = reporter
+	crashReporterMu.Unlock()
+}
+
+// ReportPanic logs, counts and reports a recovered panic. It has to be called from the deferred function
+// that recovered, for the stack to include the panicking frames.
+func ReportPanic(l logger.Logger, report CrashReport, r any) {
+	if l == nil {
+		l = logger.GetLogger()
+	}
+	report.Time = time.Now()
+	report.Panic = fmt.Sprint(r)
+	report.Stack = string(debug.Stack())
+
+	err, ok := r.(error)
+	if !ok {
+		err = errors.New(report.Panic)
+	}
+	l.Errorw("recovered panic", err, "component", report.Component, "stack", report.Stack)
+	prometheus.RecordPanic(report.Component)
+
+	crashReporterMu.RLock()
+	reporter := crashReporter
+	crashReporterMu.RUnlock()
+	if reporter != nil {
+		reporter.Report(&report)
+	}
+}
+
+// ReportParticipantPanic reports a panic recovered in one of the participant's goroutines and closes the participant,
+// leaving the rest of the process running
+func ReportParticipantPanic(p types.LocalParticipant, room livekit.RoomName, component string, r any) {
+	ReportPanic(p.GetLogger(), CrashReport{
+		Component:     component,
+		Room:          room,
+		Participant:   p.Identity(),
+		ParticipantID: p.ID(),
+	}, r)
+
+	// closing could wait on the goroutine that panicked
+	go func() {
+		_ = p.Close(true, types.ParticipantCloseReasonPanic, false)
+	}()
+}
+
+// recoverPanic is deferred directly by participant goroutines, recover only works in the deferred function itself
+func (p *ParticipantImpl) recoverPanic(component string) {
+	if r := recover(); r != nil {
+		ReportParticipantPanic(p, "", component, r)
+	}
+}
@@ -0,0 +1,55 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testCrashReporter struct {
+	reports []*CrashReport
+}
+
+func (r *testCrashReporter) Report(report *CrashReport) {
+	r.reports = append(r.reports, report)
+}
+
+func panickingWorker() {
+	defer func() {
+		if r := recover(); r != nil {
+			ReportPanic(nil, CrashReport{Component: "test", Room: "room"}, r)
+		}
+	}()
+	var m map[string]int
+	m["boom"] = 1
+}
+
+func TestReportPanic(t *testing.T) {
+	reporter := &testCrashReporter{}
+	SetCrashReporter(reporter)
+	defer SetCrashReporter(nil)
+
+	require.NotPanics(t, panickingWorker)
+	require.Len(t, reporter.reports, 1)
+
+	report := reporter.reports[0]
+	require.Equal(t, "test", report.Component)
+	require.Equal(t, "room", string(report.Room))
+	require.Contains(t, report.Panic, "nil map")
+	require.Contains(t, report.Stack, "panickingWorker")
+	require.False(t, report.Time.IsZero())
+}
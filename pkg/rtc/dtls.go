@@ -0,0 +1,92 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtc
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+
+	"github.com/livekit/livekit-server/pkg/config"
+)
+
+var ErrDTLSCertificateExpired = errors.New("DTLS certificate has expired")
+
+// newDTLSCertificate returns the certificate shared by all connections of the node, nil when
+// connections use their own.
+func newDTLSCertificate(conf config.DTLSConfig) (*webrtc.Certificate, error) {
+	switch {
+	case conf.CertFile != "":
+		pair, err := tls.LoadX509KeyPair(conf.CertFile, conf.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not load DTLS certificate: %w", err)
+		}
+		cert, err := x509.ParseCertificate(pair.Certificate[0])
+		if err != nil {
+			return nil, fmt.Errorf("could not parse DTLS certificate: %w", err)
+		}
+		if time.Now().After(cert.NotAfter) {
+			return nil, ErrDTLSCertificateExpired
+		}
+		c := webrtc.CertificateFromX509(pair.PrivateKey, cert)
+		return &c, nil
+
+	case conf.CertLifetime > 0:
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+		if err != nil {
+			return nil, err
+		}
+		now := time.Now()
+		return webrtc.NewCertificate(key, x509.Certificate{
+			Issuer:       pkix.Name{CommonName: "livekit"},
+			Subject:      pkix.Name{CommonName: "livekit"},
+			NotBefore:    now.Add(-24 * time.Hour),
+			NotAfter:     now.Add(conf.CertLifetime),
+			SerialNumber: serialNumber,
+			Version:      2,
+		})
+
+	default:
+		return nil, nil
+	}
+}
+
+// DTLSFingerprints returns the fingerprints of the certificate shared by all connections,
+// empty when every connection uses its own
+func (c *WebRTCConfig) DTLSFingerprints() ([]webrtc.DTLSFingerprint, time.Time, error) {
+	if len(c.Configuration.Certificates) == 0 {
+		return nil, time.Time{}, nil
+	}
+
+	cert := c.Configuration.Certificates[0]
+	fingerprints, err := cert.GetFingerprints()
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	return fingerprints, cert.Expires(), nil
+}
@@ -0,0 +1,78 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtc
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/livekit-server/pkg/config"
+)
+
+func TestDTLSCertificate(t *testing.T) {
+	t.Run("per connection by default", func(t *testing.T) {
+		cert, err := newDTLSCertificate(config.DTLSConfig{})
+		require.NoError(t, err)
+		require.Nil(t, cert)
+	})
+
+	t.Run("generated with lifetime", func(t *testing.T) {
+		cert, err := newDTLSCertificate(config.DTLSConfig{CertLifetime: 48 * time.Hour})
+		require.NoError(t, err)
+		require.NotNil(t, cert)
+		require.WithinDuration(t, time.Now().Add(48*time.Hour), cert.Expires(), time.Minute)
+	})
+
+	t.Run("loaded from files", func(t *testing.T) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+		tpl := &x509.Certificate{
+			SerialNumber: big.NewInt(1),
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+		}
+		der, err := x509.CreateCertificate(rand.Reader, tpl, tpl, &key.PublicKey, key)
+		require.NoError(t, err)
+		keyDer, err := x509.MarshalPKCS8PrivateKey(key)
+		require.NoError(t, err)
+
+		dir := t.TempDir()
+		certFile := filepath.Join(dir, "dtls.crt")
+		keyFile := filepath.Join(dir, "dtls.key")
+		require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+		require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDer}), 0600))
+
+		cert, err := newDTLSCertificate(config.DTLSConfig{CertFile: certFile, KeyFile: keyFile})
+		require.NoError(t, err)
+		fingerprints, err := cert.GetFingerprints()
+		require.NoError(t, err)
+		sum := sha256.Sum256(der)
+		require.Equal(t, "sha-256", fingerprints[0].Algorithm)
+		require.Equal(t, strings.ToUpper(hex.EncodeToString(sum[:])), strings.ReplaceAll(strings.ToUpper(fingerprints[0].Value), ":", ""))
+	})
+}
@@ -106,6 +106,22 @@ func (d *DynacastManager) Close() {
 // where subscribed quality needs to sent to the provider immediately.
 // This bypasses any debouncing and forces a subscribed quality update
 // with immediate effect.
+// IsPaused returns true when the quality last sent to the publisher is off for every codec
+func (d *DynacastManager) IsPaused() bool {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+
+	if len(d.committedMaxSubscribedQuality) == 0 {
+		return false
+	}
+	for _, quality := range d.committedMaxSubscribedQuality {
+		if quality != livekit.VideoQuality_OFF {
+			return false
+		}
+	}
+	return true
+}
+
 func (d *DynacastManager) ForceUpdate() {
 	d.update(true)
 }
@@ -284,3 +284,34 @@ func TestSubscribedMaxQuality(t *testing.T) {
 		}, 10*time.Second, 100*time.Millisecond)
 	})
 }
+
+func TestDynacastPaused(t *testing.T) {
+	dm := NewDynacastManager(DynacastManagerParams{})
+	defer dm.Close()
+
+	// nothing sent to the publisher yet
+	require.False(t, dm.IsPaused())
+
+	dm.NotifySubscriberMaxQuality("s1", webrtc.MimeTypeVP8, livekit.VideoQuality_HIGH)
+	dm.NotifySubscriberMaxQuality("s2", webrtc.MimeTypeAV1, livekit.VideoQuality_LOW)
+	require.False(t, dm.IsPaused())
+
+	// one codec still subscribed
+	dm.NotifySubscriberMaxQuality("s1", webrtc.MimeTypeVP8, livekit.VideoQuality_OFF)
+	dm.ForceUpdate()
+	require.Eventually(t, func() bool {
+		dm.lock.RLock()
+		defer dm.lock.RUnlock()
+		return dm.committedMaxSubscribedQuality[webrtc.MimeTypeVP8] == livekit.VideoQuality_OFF
+	}, time.Second, 10*time.Millisecond)
+	require.False(t, dm.IsPaused())
+
+	// all layers of all codecs off
+	dm.NotifySubscriberMaxQuality("s2", webrtc.MimeTypeAV1, livekit.VideoQuality_OFF)
+	dm.ForceUpdate()
+	require.Eventually(t, dm.IsPaused, time.Second, 10*time.Millisecond)
+
+	// resubscribing upgrades right away
+	dm.NotifySubscriberMaxQuality("s2", webrtc.MimeTypeAV1, livekit.VideoQuality_MEDIUM)
+	require.False(t, dm.IsPaused())
+}
@@ -17,16 +17,20 @@ package rtc
 import "errors"
 
 var (
-	ErrRoomClosed              = errors.New("room has already closed")
-	ErrPermissionDenied        = errors.New("no permissions to access the room")
-	ErrMaxParticipantsExceeded = errors.New("room has exceeded its max participants")
-	ErrLimitExceeded           = errors.New("node has exceeded its configured limit")
-	ErrAlreadyJoined           = errors.New("a participant with the same identity is already in the room")
-	ErrDataChannelUnavailable  = errors.New("data channel is not available")
-	ErrTransportFailure        = errors.New("transport failure")
-	ErrEmptyIdentity           = errors.New("participant identity cannot be empty")
-	ErrEmptyParticipantID      = errors.New("participant ID cannot be empty")
-	ErrMissingGrants           = errors.New("VideoGrant is missing")
+	ErrRoomClosed                    = errors.New("room has already closed")
+	ErrPermissionDenied              = errors.New("no permissions to access the room")
+	ErrMaxParticipantsExceeded       = errors.New("room has exceeded its max participants")
+	ErrMaxPublishersExceeded         = errors.New("broadcast room has reached its max publishers")
+	ErrLimitExceeded                 = errors.New("node has exceeded its configured limit")
+	ErrAlreadyJoined                 = errors.New("a participant with the same identity is already in the room")
+	ErrDataChannelUnavailable        = errors.New("data channel is not available")
+	ErrTransportFailure              = errors.New("transport failure")
+	ErrEmptyIdentity                 = errors.New("participant identity cannot be empty")
+	ErrEmptyParticipantID            = errors.New("participant ID cannot be empty")
+	ErrMissingGrants                 = errors.New("VideoGrant is missing")
+	ErrInvalidVideoAllocation        = errors.New("invalid video allocation preset")
+	ErrInvalidPausedVideoPlaceholder = errors.New("invalid paused video placeholder")
+	ErrE2EENotEnabled                = errors.New("server side e2ee key management is not enabled for the room")
 
 	// Track subscription related
 	ErrNoTrackPermission         = errors.New("participant is not allowed to subscribe to this track")
@@ -0,0 +1,62 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtc
+
+import (
+	"time"
+
+	"github.com/pion/interceptor"
+	"github.com/pion/rtp"
+
+	"github.com/livekit/livekit-server/pkg/sfu/impairment"
+)
+
+// ImpairmentInterceptorFactory drops and delays outgoing RTP right before it hits the wire,
+// so that congestion control and retransmissions react as they would to a lossy downlink.
+type ImpairmentInterceptorFactory struct {
+	impairment *impairment.Impairment
+}
+
+func NewImpairmentInterceptorFactory(imp *impairment.Impairment) *ImpairmentInterceptorFactory {
+	return &ImpairmentInterceptorFactory{impairment: imp}
+}
+
+func (f *ImpairmentInterceptorFactory) NewInterceptor(_ string) (interceptor.Interceptor, error) {
+	return &ImpairmentInterceptor{impairment: f.impairment}, nil
+}
+
+type ImpairmentInterceptor struct {
+	interceptor.NoOp
+	impairment *impairment.Impairment
+}
+
+func (i *ImpairmentInterceptor) BindLocalStream(_ *interceptor.StreamInfo, writer interceptor.RTPWriter) interceptor.RTPWriter {
+	return interceptor.RTPWriterFunc(func(header *rtp.Header, payload []byte, attributes interceptor.Attributes) (int, error) {
+		drop, delay := i.impairment.Apply()
+		if drop {
+			return header.MarshalSize() + len(payload), nil
+		}
+		if delay > 0 {
+			hdr := header.Clone()
+			pl := make([]byte, len(payload))
+			copy(pl, payload)
+			time.AfterFunc(delay, func() {
+				_, _ = writer.Write(&hdr, pl, attributes)
+			})
+			return hdr.MarshalSize() + len(pl), nil
+		}
+		return writer.Write(header, payload, attributes)
+	})
+}
@@ -24,7 +24,6 @@ import (
 	"go.uber.org/atomic"
 	"google.golang.org/protobuf/proto"
 
-	"github.com/livekit/mediatransportutil/pkg/twcc"
 	"github.com/livekit/protocol/livekit"
 	"github.com/livekit/protocol/logger"
 
@@ -33,6 +32,8 @@ import (
 	"github.com/livekit/livekit-server/pkg/sfu"
 	"github.com/livekit/livekit-server/pkg/sfu/buffer"
 	"github.com/livekit/livekit-server/pkg/sfu/connectionquality"
+	"github.com/livekit/livekit-server/pkg/sfu/impairment"
+	"github.com/livekit/livekit-server/pkg/sfu/twcc"
 	"github.com/livekit/livekit-server/pkg/telemetry"
 )
 
@@ -48,7 +49,11 @@ type MediaTrack struct {
 
 	dynacastManager *DynacastManager
 
-	lock sync.RWMutex
+	// muted by the mute reconciler rather than signaled by the publisher
+	mutedByServer atomic.Bool
+
+	lock  sync.RWMutex
+	ssrcs []uint32
 }
 
 type MediaTrackParams struct {
@@ -69,6 +74,23 @@ type MediaTrackParams struct {
 	Telemetry         telemetry.TelemetryService
 	Logger            logger.Logger
 	SimTracks         map[uint32]SimulcastTrackInfo
+
+	// limits of published tracks, a limit of 0 disables enforcement
+	PublishBitrateLimits config.PublishBitrateLimitsConfig
+	// drops and delays packets received from the publisher, used for testing
+	UplinkImpairment *impairment.Impairment
+	// called with panics recovered in receiver goroutines
+	OnReceiverPanic func(r any)
+	// caps the video layers requested from the publisher, 0 leaves a dimension unconstrained
+	MaxPublishWidth  uint32
+	MaxPublishHeight uint32
+	// down tracks above which packet writes are parallelized, 0 uses the default
+	LoadBalanceThreshold int
+	// bitrate (bps) of the rendition transcoded for subscribers that cannot decode the published codecs,
+	// 0 disables transcoding
+	TranscodeBitrate int64
+	// opus is sent to subscribers as RED even when the publisher does not send it
+	REDEncoding bool
 }
 
 func NewMediaTrack(params MediaTrackParams) *MediaTrack {
@@ -88,6 +110,8 @@ func NewMediaTrack(params MediaTrackParams) *MediaTrack {
 		AudioConfig:         params.AudioConfig,
 		Telemetry:           params.Telemetry,
 		Logger:              params.Logger,
+		Transcode:           params.TranscodeBitrate > 0,
+		REDEncoding:         params.REDEncoding,
 	})
 	t.MediaTrackReceiver.OnVideoLayerUpdate(func(layers []*livekit.VideoLayer) {
 		t.params.Telemetry.TrackPublishedUpdate(context.Background(), t.PublisherID(),
@@ -123,10 +147,23 @@ func NewMediaTrack(params MediaTrackParams) *MediaTrack {
 		})
 		t.MediaTrackReceiver.OnSubscriberMaxQualityChange(
 			func(subscriberID livekit.ParticipantID, codec webrtc.RTPCodecCapability, layer int32) {
+				mime := codec.MimeType
+				quality := buffer.SpatialLayerToVideoQuality(layer, t.params.TrackInfo, t.params.Logger)
+				if maxQuality, ok := maxQualityWithin(t.params.TrackInfo, t.params.MaxPublishWidth, t.params.MaxPublishHeight); ok &&
+					quality != livekit.VideoQuality_OFF && quality > maxQuality {
+					quality = maxQuality
+				}
+				if quality != livekit.VideoQuality_OFF && t.isTranscoded(mime) {
+					// transcoded renditions are decoded from the lowest layer of the primary codec
+					if pr := t.PrimaryReceiver(); pr != nil {
+						mime = pr.Codec().MimeType
+						quality = livekit.VideoQuality_LOW
+					}
+				}
 				t.dynacastManager.NotifySubscriberMaxQuality(
 					subscriberID,
-					codec.MimeType,
-					buffer.SpatialLayerToVideoQuality(layer, t.params.TrackInfo),
+					mime,
+					quality,
 				)
 			},
 		)
@@ -215,6 +252,9 @@ func (t *MediaTrack) AddReceiver(receiver *webrtc.RTPReceiver, track *webrtc.Tra
 		t.params.Logger.Errorw("could not retrieve buffer pair", nil)
 		return newCodec
 	}
+	if t.params.UplinkImpairment != nil {
+		buff.SetImpairment(t.params.UplinkImpairment)
+	}
 
 	rtcpReader.OnPacket(func(bytes []byte) {
 		pkts, err := rtcp.Unmarshal(bytes)
@@ -234,8 +274,9 @@ func (t *MediaTrack) AddReceiver(receiver *webrtc.RTPReceiver, track *webrtc.Tra
 	})
 
 	t.lock.Lock()
+	t.ssrcs = append(t.ssrcs, uint32(track.SSRC()))
 	mime := strings.ToLower(track.Codec().MimeType)
-	layer := buffer.RidToSpatialLayer(track.RID(), t.trackInfo)
+	layer := buffer.RidToSpatialLayer(track.RID(), t.trackInfo, t.params.Logger)
 	t.params.Logger.Debugw("AddReceiver", "mime", track.Codec().MimeType)
 	wr := t.MediaTrackReceiver.Receiver(mime)
 	if wr == nil {
@@ -246,17 +287,43 @@ func (t *MediaTrack) AddReceiver(receiver *webrtc.RTPReceiver, track *webrtc.Tra
 				break
 			}
 		}
-		newWR := sfu.NewWebRTCReceiver(
+		receiverLogger := LoggerWithCodecMime(t.params.Logger, mime)
+		loadBalanceThreshold := t.params.LoadBalanceThreshold
+		if loadBalanceThreshold <= 0 {
+			loadBalanceThreshold = defaultLoadBalanceThreshold
+		}
+		opts := []sfu.ReceiverOpts{
+			sfu.WithPliThrottleConfig(t.params.PLIThrottleConfig),
+			sfu.WithAudioConfig(t.params.AudioConfig),
+			sfu.WithLoadBalanceThreshold(loadBalanceThreshold),
+			sfu.WithStreamTrackers(),
+		}
+		if t.params.OnReceiverPanic != nil {
+			opts = append(opts, sfu.WithPanicHandler(t.params.OnReceiverPanic))
+		}
+		if t.params.TranscodeBitrate > 0 {
+			opts = append(opts, sfu.WithTranscoding(int(t.params.TranscodeBitrate)))
+		}
+		var newWR *sfu.WebRTCReceiver
+		if maxBitrate := t.MaxPublishBitrate(); maxBitrate != 0 {
+			opts = append(opts, sfu.WithBitrateLimiter(sfu.NewBitrateLimiter(sfu.BitrateLimiterParams{
+				MaxBitrate:       maxBitrate,
+				DropAfter:        t.params.PublishBitrateLimits.DropAfter,
+				Logger:           receiverLogger,
+				KeyFrameOnResume: t.Kind() == livekit.TrackType_VIDEO,
+				OnResume: func(layer int32) {
+					newWR.SendPLI(layer, true)
+				},
+			})))
+		}
+		newWR = sfu.NewWebRTCReceiver(
 			receiver,
 			track,
 			t.params.TrackInfo,
-			LoggerWithCodecMime(t.params.Logger, mime),
+			receiverLogger,
 			twcc,
 			t.params.VideoConfig.StreamTracker,
-			sfu.WithPliThrottleConfig(t.params.PLIThrottleConfig),
-			sfu.WithAudioConfig(t.params.AudioConfig),
-			sfu.WithLoadBalanceThreshold(20),
-			sfu.WithStreamTrackers(),
+			opts...,
 		)
 		newWR.SetRTCPCh(t.params.RTCPChan)
 		newWR.OnCloseHandler(func() {
@@ -349,6 +416,29 @@ func (t *MediaTrack) GetConnectionScoreAndQuality() (float32, livekit.Connection
 	return connectionquality.MaxMOS, livekit.ConnectionQuality_EXCELLENT
 }
 
+// MaxPublishBitrate returns the bitrate limit of the track, 0 when it is not limited.
+// Tracks without a source are limited as camera or microphone.
+func (t *MediaTrack) MaxPublishBitrate() uint64 {
+	limits := &t.params.PublishBitrateLimits
+	switch t.Source() {
+	case livekit.TrackSource_SCREEN_SHARE:
+		return limits.ScreenShare
+	case livekit.TrackSource_SCREEN_SHARE_AUDIO:
+		return limits.ScreenShareAudio
+	}
+	if t.Kind() == livekit.TrackType_AUDIO {
+		return limits.Microphone
+	}
+	return limits.Camera
+}
+
+// SSRCs returns the SSRCs of the streams received from the publisher
+func (t *MediaTrack) SSRCs() []uint32 {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	return append([]uint32{}, t.ssrcs...)
+}
+
 func (t *MediaTrack) SetRTT(rtt uint32) {
 	t.MediaTrackReceiver.SetRTT(rtt)
 }
@@ -357,6 +447,20 @@ func (t *MediaTrack) HasPendingCodec() bool {
 	return t.MediaTrackReceiver.PrimaryReceiver() == nil
 }
 
+// isTranscoded returns true for codecs subscribers receive a transcoded rendition in, neither published nor to be
+// published by the publisher
+func (t *MediaTrack) isTranscoded(mime string) bool {
+	if t.params.TranscodeBitrate <= 0 || t.MediaTrackReceiver.Receiver(mime) != nil {
+		return false
+	}
+	for _, c := range t.params.TrackInfo.Codecs {
+		if c.MimeType != "" && strings.HasSuffix(strings.ToLower(mime), strings.ToLower(c.MimeType)) {
+			return false
+		}
+	}
+	return true
+}
+
 func (t *MediaTrack) onMaxLayerChange(maxLayer int32) {
 	ti := &livekit.TrackInfo{
 		Sid:  t.trackInfo.Sid,
@@ -396,5 +500,15 @@ func (t *MediaTrack) SetMuted(muted bool) {
 		t.dynacastManager.ForceUpdate()
 	}
 
+	t.mutedByServer.Store(false)
 	t.MediaTrackReceiver.SetMuted(muted)
 }
+
+func (t *MediaTrack) IsMutedByServer() bool {
+	return t.mutedByServer.Load()
+}
+
+// IsPausedByDynacast returns true when the publisher was asked to stop sending all layers
+func (t *MediaTrack) IsPausedByDynacast() bool {
+	return t.dynacastManager != nil && t.dynacastManager.IsPaused()
+}
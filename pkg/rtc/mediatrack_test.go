@@ -20,6 +20,10 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/livekit/protocol/livekit"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/routing"
+	"github.com/livekit/livekit-server/pkg/rtc/types"
 )
 
 func TestTrackInfo(t *testing.T) {
@@ -174,3 +178,27 @@ func TestGetQualityForDimension(t *testing.T) {
 	})
 
 }
+
+func TestREDEncodingFor(t *testing.T) {
+	sub := newMockParticipant("sub", types.CurrentProtocol, false, false)
+	newTrack := func(disableRed bool, activeRED bool, roomRED bool) *MediaTrack {
+		return NewMediaTrack(MediaTrackParams{
+			TrackInfo:   &livekit.TrackInfo{Sid: "audio", Type: livekit.TrackType_AUDIO, DisableRed: disableRed},
+			AudioConfig: config.AudioConfig{ActiveREDEncoding: activeRED},
+			REDEncoding: roomRED,
+		})
+	}
+
+	require.False(t, newTrack(false, false, false).redEncodingFor(sub))
+	require.True(t, newTrack(false, true, false).redEncodingFor(sub))
+	require.False(t, newTrack(true, true, false).redEncodingFor(sub))
+	// the room generates RED even when the publisher disabled it
+	require.True(t, newTrack(true, false, true).redEncodingFor(sub))
+
+	// entitlements of the subscriber take precedence
+	redAudio := false
+	sub.GetEntitlementsReturns(&routing.Entitlements{REDAudio: &redAudio})
+	require.False(t, newTrack(false, false, true).redEncodingFor(sub))
+	redAudio = true
+	require.True(t, newTrack(true, false, false).redEncodingFor(sub))
+}
@@ -20,6 +20,7 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/pion/rtcp"
 	"github.com/pion/webrtc/v3"
@@ -93,6 +94,11 @@ type MediaTrackReceiverParams struct {
 	AudioConfig         config.AudioConfig
 	Telemetry           telemetry.TelemetryService
 	Logger              logger.Logger
+
+	// video is offered in h264 as well, transcoded for subscribers that cannot decode the published codecs
+	Transcode bool
+	// opus is offered as RED generated by the server to subscribers, unless their entitlements disable it
+	REDEncoding bool
 }
 
 type MediaTrackReceiver struct {
@@ -267,7 +273,7 @@ func (t *MediaTrackReceiver) SetLayerSsrc(mime string, rid string, ssrc uint32)
 	t.lock.Lock()
 	defer t.lock.Unlock()
 
-	layer := buffer.RidToSpatialLayer(rid, t.params.TrackInfo)
+	layer := buffer.RidToSpatialLayer(rid, t.params.TrackInfo, t.params.Logger)
 	if layer == buffer.InvalidLayerSpatial {
 		// non-simulcast case will not have `rid`
 		layer = 0
@@ -508,11 +514,20 @@ func (t *MediaTrackReceiver) AddSubscriber(sub types.LocalParticipant) (types.Su
 		StreamId:       streamId,
 		UpstreamCodecs: potentialCodecs,
 		Logger:         tLogger,
-		DisableRed:     t.trackInfo.GetDisableRed() || !t.params.AudioConfig.ActiveREDEncoding,
+		DisableRed:     !t.redEncodingFor(sub),
+		Transcode:      t.params.Transcode,
 	})
 	return t.MediaTrackSubscriptions.AddSubscriber(sub, wr)
 }
 
+// redEncodingFor returns whether opus is offered to sub as RED, generated by the server when the publisher does not
+// send it. The entitlements of sub take precedence over the room, which takes precedence over the publisher
+func (t *MediaTrackReceiver) redEncodingFor(sub types.LocalParticipant) bool {
+	return sub.GetEntitlements().GetREDAudio(
+		t.params.REDEncoding || (!t.trackInfo.GetDisableRed() && t.params.AudioConfig.ActiveREDEncoding),
+	)
+}
+
 // RemoveSubscriber removes participant from subscription
 // stop all forwarders to the client
 func (t *MediaTrackReceiver) RemoveSubscriber(subscriberID livekit.ParticipantID, willBeResumed bool) {
@@ -793,6 +808,22 @@ func (t *MediaTrackReceiver) Receivers() []sfu.TrackReceiver {
 	return receivers
 }
 
+// LastPacketTime returns when media was last received for any codec of the track, zero if none was received.
+func (t *MediaTrackReceiver) LastPacketTime() time.Time {
+	var last time.Time
+	for _, r := range t.Receivers() {
+		if dr, ok := r.(*DummyReceiver); ok {
+			r = dr.Receiver()
+		}
+		if wr, ok := r.(*sfu.WebRTCReceiver); ok {
+			if at := wr.LastPacketTime(); at.After(last) {
+				last = at
+			}
+		}
+	}
+	return last
+}
+
 func (t *MediaTrackReceiver) SetRTT(rtt uint32) {
 	t.lock.Lock()
 	receivers := t.receiversShadow
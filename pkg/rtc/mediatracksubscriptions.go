@@ -135,6 +135,8 @@ func (t *MediaTrackSubscriptions) AddSubscriber(sub types.LocalParticipant, wr *
 		Pacer:             sub.GetPacer(),
 		Trailer:           trailer,
 		Logger:            LoggerWithTrack(sub.GetLogger().WithComponent(sutils.ComponentSub), trackID, t.params.IsRelayed),
+
+		ForwarderAuditSize: t.params.ReceiverConfig.ForwarderAuditSize,
 	})
 	if err != nil {
 		return nil, err
@@ -244,6 +246,10 @@ func (t *MediaTrackSubscriptions) AddSubscriber(sub types.LocalParticipant, wr *
 		if addTrackParams.Red && (len(codecs) == 1 && codecs[0].MimeType == webrtc.MimeTypeOpus) {
 			addTrackParams.Red = false
 		}
+		if !addTrackParams.Red && len(codecs) > 1 && codecs[0].MimeType == sfu.MimeTypeAudioRed {
+			// RED is generated for the subscriber although the publisher disabled it
+			addTrackParams.Red = true
+		}
 
 		sub.VerifySubscribeParticipantInfo(subTrack.PublisherID(), subTrack.PublisherVersion())
 		if sub.ProtocolVersion().SupportsTransceiverReuse() {
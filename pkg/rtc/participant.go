@@ -16,9 +16,10 @@ package rtc
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
-	"os"
+	"math/rand"
 	"strconv"
 	"strings"
 	"sync"
@@ -40,12 +41,13 @@ import (
 	"github.com/livekit/livekit-server/pkg/sfu"
 	"github.com/livekit/livekit-server/pkg/sfu/buffer"
 	"github.com/livekit/livekit-server/pkg/sfu/connectionquality"
+	"github.com/livekit/livekit-server/pkg/sfu/impairment"
 	"github.com/livekit/livekit-server/pkg/sfu/pacer"
 	"github.com/livekit/livekit-server/pkg/sfu/streamallocator"
+	"github.com/livekit/livekit-server/pkg/sfu/twcc"
 	"github.com/livekit/livekit-server/pkg/telemetry"
 	"github.com/livekit/livekit-server/pkg/telemetry/prometheus"
 	sutils "github.com/livekit/livekit-server/pkg/utils"
-	"github.com/livekit/mediatransportutil/pkg/twcc"
 	"github.com/livekit/protocol/auth"
 	"github.com/livekit/protocol/livekit"
 	"github.com/livekit/protocol/logger"
@@ -58,6 +60,12 @@ const (
 
 	disconnectCleanupDuration = 15 * time.Second
 	migrationWaitDuration     = 3 * time.Second
+
+	publisherREMBInterval = time.Second
+	// allowance for audio tracks without a limit when capping publishers
+	publisherREMBAudioAllowance = 128_000
+
+	defaultPublicClaimsKey = "claims"
 )
 
 type pendingTrackInfo struct {
@@ -101,6 +109,7 @@ type ParticipantParams struct {
 	Logger                       logger.Logger
 	SimTracks                    map[uint32]SimulcastTrackInfo
 	Grants                       *auth.ClaimGrants
+	Entitlements                 *routing.Entitlements
 	InitialVersion               uint32
 	ClientConf                   *livekit.ClientConfiguration
 	ClientInfo                   ClientInfo
@@ -122,6 +131,25 @@ type ParticipantParams struct {
 	SubscriptionLimitAudio       int32
 	SubscriptionLimitVideo       int32
 	PlayoutDelay                 *livekit.PlayoutDelay
+	PublishBitrateLimits         config.PublishBitrateLimitsConfig
+	RTCPFeedback                 config.RTCPFeedbackConfig
+	Watchdog                     config.WatchdogConfig
+	MuteReconciliation           config.MuteReconciliationConfig
+	AuthorizePublish             AuthorizePublishFunc
+	// subscriber count above which packets of published tracks are written in parallel
+	LoadBalanceThreshold int
+	// allows injecting loss and latency on media paths, development only
+	AllowImpairment bool
+	// publishers are not asked for opus in-band FEC
+	DisableOpusFEC bool
+	// bitrate (bps) of the rendition of published video transcoded for subscribers that cannot decode its codec,
+	// 0 disables transcoding
+	TranscodeBitrate int64
+	// opus of published tracks is sent to subscribers as RED, repeating previous packets, even when not sent as RED
+	REDEncoding bool
+	// custom token claims shown to other participants under PublicClaimsKey of the metadata
+	PublicClaims    map[string]interface{}
+	PublicClaimsKey string
 }
 
 type ParticipantImpl struct {
@@ -151,6 +179,8 @@ type ParticipantImpl struct {
 	pendingPublishingTracks map[livekit.TrackID]*pendingTrackInfo
 	// migrated in muted tracks are not fired need close at participant close
 	mutedTrackNotFired []*MediaTrack
+	// dimension caps of pending tracks set when authorizing their publication
+	publishCaps map[livekit.TrackID]*PublishDecision
 
 	*TransportManager
 	*UpTrackManager
@@ -204,6 +234,10 @@ type ParticipantImpl struct {
 	// loggers for publisher and subscriber
 	pubLogger logger.Logger
 	subLogger logger.Logger
+
+	// artificial impairment of media from and to the participant, nil unless allowed
+	uplinkImpairment   *impairment.Impairment
+	downlinkImpairment *impairment.Impairment
 }
 
 func NewParticipant(params ParticipantParams) (*ParticipantImpl, error) {
@@ -221,6 +255,7 @@ func NewParticipant(params ParticipantParams) (*ParticipantImpl, error) {
 		rtcpCh:                  make(chan []rtcp.Packet, 100),
 		pendingTracks:           make(map[string]*pendingTrackInfo),
 		pendingPublishingTracks: make(map[livekit.TrackID]*pendingTrackInfo),
+		publishCaps:             make(map[livekit.TrackID]*PublishDecision),
 		connectedAt:             time.Now(),
 		rttUpdatedAt:            time.Now(),
 		cachedDownTracks:        make(map[livekit.TrackID]*downTrackState),
@@ -239,6 +274,10 @@ func NewParticipant(params ParticipantParams) (*ParticipantImpl, error) {
 	p.state.Store(livekit.ParticipantInfo_JOINING)
 	p.grants = params.Grants
 	p.SetResponseSink(params.Sink)
+	if params.AllowImpairment {
+		p.uplinkImpairment = impairment.New()
+		p.downlinkImpairment = impairment.New()
+	}
 
 	p.supervisor.OnPublicationError(p.onPublicationError)
 
@@ -345,6 +384,10 @@ func (p *ParticipantImpl) GetBufferFactory() *buffer.Factory {
 	return p.params.Config.BufferFactory
 }
 
+func (p *ParticipantImpl) GetImpairments() (*impairment.Impairment, *impairment.Impairment) {
+	return p.uplinkImpairment, p.downlinkImpairment
+}
+
 // SetName attaches name to the participant
 func (p *ParticipantImpl) SetName(name string) {
 	p.lock.Lock()
@@ -478,8 +521,8 @@ func (p *ParticipantImpl) ToProtoWithVersion() (*livekit.ParticipantInfo, utils.
 		State:       p.State(),
 		JoinedAt:    p.ConnectedAt().Unix(),
 		Version:     v,
-		Permission:  p.grants.Video.ToPermission(),
-		Metadata:    p.grants.Metadata,
+		Permission:  p.params.Entitlements.ApplyToPermission(p.grants.Video.ToPermission()),
+		Metadata:    p.metadataWithPublicClaimsLocked(),
 		Region:      p.params.Region,
 		IsPublisher: p.IsPublisher(),
 	}
@@ -494,6 +537,30 @@ func (p *ParticipantImpl) ToProto() *livekit.ParticipantInfo {
 	return pi
 }
 
+// metadataWithPublicClaimsLocked adds the public claims to a JSON object metadata, other metadata is returned as is
+func (p *ParticipantImpl) metadataWithPublicClaimsLocked() string {
+	if len(p.params.PublicClaims) == 0 {
+		return p.grants.Metadata
+	}
+
+	metadata := map[string]interface{}{}
+	if p.grants.Metadata != "" {
+		if err := json.Unmarshal([]byte(p.grants.Metadata), &metadata); err != nil {
+			return p.grants.Metadata
+		}
+	}
+	key := p.params.PublicClaimsKey
+	if key == "" {
+		key = defaultPublicClaimsKey
+	}
+	metadata[key] = p.params.PublicClaims
+	encoded, err := json.Marshal(metadata)
+	if err != nil {
+		return p.grants.Metadata
+	}
+	return string(encoded)
+}
+
 // callbacks for clients
 
 func (p *ParticipantImpl) OnTrackPublished(callback func(types.LocalParticipant, types.MediaTrack)) {
@@ -675,9 +742,22 @@ func (p *ParticipantImpl) AddTrack(req *livekit.AddTrackRequest) {
 		return
 	}
 
+	var decision *PublishDecision
+	if p.params.AuthorizePublish != nil {
+		decision = p.params.AuthorizePublish(p, req)
+		if decision != nil && !decision.Allow {
+			p.pubLogger.Infow("track publication denied", "cid", req.Cid, "reason", decision.Reason)
+			return
+		}
+		if decision != nil && !decision.Apply(req) {
+			p.pubLogger.Infow("track publication denied, no allowed codec", "cid", req.Cid, "codecs", decision.Codecs)
+			return
+		}
+	}
+
 	p.lock.Lock()
 	defer p.lock.Unlock()
-	ti := p.addPendingTrackLocked(req)
+	ti := p.addPendingTrackLocked(req, decision)
 	if ti == nil {
 		return
 	}
@@ -978,6 +1058,11 @@ func (p *ParticipantImpl) GetConnectionQuality() *livekit.ConnectionQualityInfo
 	}
 }
 
+// GetEntitlements returns the entitlements of the participant token, nil when it has none
+func (p *ParticipantImpl) GetEntitlements() *routing.Entitlements {
+	return p.params.Entitlements
+}
+
 func (p *ParticipantImpl) IsPublisher() bool {
 	return p.isPublisher.Load()
 }
@@ -985,7 +1070,7 @@ func (p *ParticipantImpl) IsPublisher() bool {
 func (p *ParticipantImpl) CanPublishSource(source livekit.TrackSource) bool {
 	p.lock.RLock()
 	defer p.lock.RUnlock()
-	return p.grants.Video.GetCanPublishSource(source)
+	return p.grants.Video.GetCanPublishSource(source) && p.params.Entitlements.CanPublishSource(source)
 }
 
 func (p *ParticipantImpl) CanSubscribe() bool {
@@ -1108,6 +1193,7 @@ func (p *ParticipantImpl) setupTransportManager() error {
 		AllowUDPUnstableFallback: p.params.AllowUDPUnstableFallback,
 		TURNSEnabled:             p.params.TURNSEnabled,
 		AllowPlayoutDelay:        p.params.PlayoutDelay.GetEnabled() && p.SupportSyncStreamID(),
+		DownlinkImpairment:       p.downlinkImpairment,
 		Logger:                   p.params.Logger.WithComponent(sutils.ComponentTransport),
 	})
 	if err != nil {
@@ -1210,11 +1296,7 @@ func (p *ParticipantImpl) updateState(state livekit.ParticipantInfo_State) {
 	p.lock.RUnlock()
 	if onStateChange != nil {
 		go func() {
-			defer func() {
-				if r := Recover(p.GetLogger()); r != nil {
-					os.Exit(1)
-				}
-			}()
+			defer p.recoverPanic("state_change")
 			onStateChange(p, oldState)
 		}()
 	}
@@ -1360,10 +1442,19 @@ func (p *ParticipantImpl) onICECandidate(c *webrtc.ICECandidate, target livekit.
 func (p *ParticipantImpl) onPublisherInitialConnected() {
 	p.supervisor.SetPublisherPeerConnectionConnected(true)
 	go p.publisherRTCPWorker()
+	if p.params.PublishBitrateLimits.Enabled() {
+		go p.publisherREMBWorker()
+	}
+	if p.params.MuteReconciliation.MediaTimeout > 0 {
+		go p.muteReconcilerWorker()
+	}
 }
 
 func (p *ParticipantImpl) onSubscriberInitialConnected() {
 	go p.subscriberRTCPWorker()
+	if p.params.Watchdog.StallTimeout > 0 {
+		go p.subscriberWatchdogWorker()
+	}
 
 	p.setDowntracksConnected()
 }
@@ -1414,11 +1505,7 @@ func (p *ParticipantImpl) onAnyTransportFailed() {
 // subscriberRTCPWorker sends SenderReports periodically when the participant is subscribed to
 // other publishedTracks in the room.
 func (p *ParticipantImpl) subscriberRTCPWorker() {
-	defer func() {
-		if r := Recover(p.GetLogger()); r != nil {
-			os.Exit(1)
-		}
-	}()
+	defer p.recoverPanic("subscriber_rtcp")
 	for {
 		if p.IsDisconnected() {
 			return
@@ -1561,7 +1648,7 @@ func (p *ParticipantImpl) onSubscribedMaxQualityChange(trackID livekit.TrackID,
 	})
 }
 
-func (p *ParticipantImpl) addPendingTrackLocked(req *livekit.AddTrackRequest) *livekit.TrackInfo {
+func (p *ParticipantImpl) addPendingTrackLocked(req *livekit.AddTrackRequest, decision *PublishDecision) *livekit.TrackInfo {
 	p.pendingTracksLock.Lock()
 	defer p.pendingTracksLock.Unlock()
 
@@ -1595,6 +1682,9 @@ func (p *ParticipantImpl) addPendingTrackLocked(req *livekit.AddTrackRequest) *l
 		ti.Stream = StreamFromTrackSource(ti.Source)
 	}
 	p.setStableTrackID(req.Cid, ti)
+	if decision != nil && (decision.MaxWidth != 0 || decision.MaxHeight != 0) {
+		p.publishCaps[livekit.TrackID(ti.Sid)] = decision
+	}
 	for _, codec := range req.SimulcastCodecs {
 		mime := codec.Codec
 		if req.Type == livekit.TrackType_VIDEO && !strings.HasPrefix(mime, "video/") {
@@ -1721,7 +1811,7 @@ func (p *ParticipantImpl) mediaTrackReceived(track *webrtc.TrackRemote, rtpRecei
 
 	ssrc := uint32(track.SSRC())
 	if p.twcc == nil {
-		p.twcc = twcc.NewTransportWideCCResponder(ssrc)
+		p.twcc = twcc.NewTransportWideCCResponder(ssrc, p.params.RTCPFeedback.TransportCCInterval)
 		p.twcc.OnFeedback(func(pkts []rtcp.Packet) {
 			p.postRtcp(pkts)
 		})
@@ -1792,6 +1882,11 @@ func (p *ParticipantImpl) addMigrateMutedTrack(cid string, ti *livekit.TrackInfo
 }
 
 func (p *ParticipantImpl) addMediaTrack(signalCid string, sdpCid string, ti *livekit.TrackInfo) *MediaTrack {
+	var maxWidth, maxHeight uint32
+	if decision := p.publishCaps[livekit.TrackID(ti.Sid)]; decision != nil {
+		maxWidth, maxHeight = decision.MaxWidth, decision.MaxHeight
+		delete(p.publishCaps, livekit.TrackID(ti.Sid))
+	}
 	mt := NewMediaTrack(MediaTrackParams{
 		TrackInfo:           proto.Clone(ti).(*livekit.TrackInfo),
 		SignalCid:           signalCid,
@@ -1809,6 +1904,18 @@ func (p *ParticipantImpl) addMediaTrack(signalCid string, sdpCid string, ti *liv
 		SubscriberConfig:    p.params.Config.Subscriber,
 		PLIThrottleConfig:   p.params.PLIThrottleConfig,
 		SimTracks:           p.params.SimTracks,
+
+		PublishBitrateLimits: p.params.PublishBitrateLimits,
+		UplinkImpairment:     p.uplinkImpairment,
+		OnReceiverPanic: func(r any) {
+			ReportParticipantPanic(p, "", "receiver", r)
+		},
+		MaxPublishWidth:  maxWidth,
+		MaxPublishHeight: maxHeight,
+
+		LoadBalanceThreshold: p.params.LoadBalanceThreshold,
+		TranscodeBitrate:     p.params.TranscodeBitrate,
+		REDEncoding:          p.params.REDEncoding,
 	})
 
 	mt.OnSubscribedMaxQualityChange(p.onSubscribedMaxQualityChange)
@@ -2045,25 +2152,89 @@ func (p *ParticipantImpl) getPublishedTrackBySdpCid(clientId string) types.Media
 }
 
 func (p *ParticipantImpl) publisherRTCPWorker() {
-	defer func() {
-		if r := Recover(p.GetLogger()); r != nil {
-			os.Exit(1)
-		}
-	}()
+	defer p.recoverPanic("publisher_rtcp")
 
-	// read from rtcpChan
-	for pkts := range p.rtcpCh {
-		if pkts == nil {
-			p.pubLogger.Debugw("exiting publisher RTCP worker")
+	batcher := newRTCPBatcher(rand.Uint32(), p.params.RTCPFeedback.ReducedSize, p.params.RTCPFeedback.Batch)
+	var flushC <-chan time.Time
+	if p.params.RTCPFeedback.Batch {
+		ticker := time.NewTicker(rtcpBatchMaxHold / 2)
+		defer ticker.Stop()
+		flushC = ticker.C
+	}
+
+	write := func(pkts []rtcp.Packet) {
+		if len(pkts) == 0 {
 			return
 		}
-
 		if err := p.TransportManager.WritePublisherRTCP(pkts); err != nil {
 			if !IsEOF(err) {
 				p.pubLogger.Errorw("could not write RTCP to participant", err)
 			}
 		}
 	}
+
+	// read from rtcpChan
+	for {
+		select {
+		case pkts := <-p.rtcpCh:
+			if pkts == nil {
+				p.pubLogger.Debugw("exiting publisher RTCP worker")
+				return
+			}
+			write(batcher.add(pkts, time.Now()))
+
+		case now := <-flushC:
+			write(batcher.flush(now))
+		}
+	}
+}
+
+// publisherREMBWorker caps the bitrate of the publisher to the sum of the limits of its tracks
+func (p *ParticipantImpl) publisherREMBWorker() {
+	ticker := time.NewTicker(publisherREMBInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if p.IsClosed() || p.IsDisconnected() {
+			return
+		}
+		if remb := p.createPublisherREMB(); remb != nil {
+			p.postRtcp([]rtcp.Packet{remb})
+		}
+	}
+}
+
+// createPublisherREMB returns nil unless all published video tracks are limited, as REMB applies
+// to all tracks of the publisher
+func (p *ParticipantImpl) createPublisherREMB() *rtcp.ReceiverEstimatedMaximumBitrate {
+	var bitrate uint64
+	var ssrcs []uint32
+	hasVideo := false
+	for _, track := range p.GetPublishedTracks() {
+		mt, ok := track.(*MediaTrack)
+		if !ok {
+			continue
+		}
+		limit := mt.MaxPublishBitrate()
+		if mt.Kind() == livekit.TrackType_VIDEO {
+			if limit == 0 {
+				return nil
+			}
+			hasVideo = true
+		} else if limit == 0 {
+			limit = publisherREMBAudioAllowance
+		}
+		bitrate += limit
+		ssrcs = append(ssrcs, mt.SSRCs()...)
+	}
+	if !hasVideo || len(ssrcs) == 0 {
+		return nil
+	}
+
+	return &rtcp.ReceiverEstimatedMaximumBitrate{
+		Bitrate: float32(bitrate),
+		SSRCs:   ssrcs,
+	}
 }
 
 func (p *ParticipantImpl) DebugInfo() map[string]interface{} {
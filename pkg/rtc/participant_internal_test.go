@@ -644,6 +644,15 @@ func TestPreferAudioCodecForRed(t *testing.T) {
 
 }
 
+func TestConfigureOpusFEC(t *testing.T) {
+	p := &ParticipantImpl{}
+	require.Equal(t, "111 minptime=10;useinbandfec=1", p.configureOpusFEC("111 minptime=10;useinbandfec=1"))
+	require.Equal(t, "111 minptime=10;useinbandfec=1", p.configureOpusFEC("111 minptime=10"))
+
+	p.params.DisableOpusFEC = true
+	require.Equal(t, "111 minptime=10;useinbandfec=0", p.configureOpusFEC("111 minptime=10;useinbandfec=1"))
+}
+
 type participantOpts struct {
 	permissions     *livekit.ParticipantPermission
 	protocolVersion types.ProtocolVersion
@@ -0,0 +1,154 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtc
+
+import (
+	"time"
+
+	"github.com/livekit/protocol/livekit"
+
+	"github.com/livekit/livekit-server/pkg/rtc/types"
+	"github.com/livekit/livekit-server/pkg/telemetry/prometheus"
+)
+
+const (
+	muteCorrectionServerMute      = "server_mute"
+	muteCorrectionServerUnmute    = "server_unmute"
+	muteCorrectionPublisherRemute = "publisher_remute"
+)
+
+// GetTrackMuteStates returns the signaled mute state of every published track along with the
+// media observed by the server.
+func (p *ParticipantImpl) GetTrackMuteStates() []types.TrackMuteState {
+	var states []types.TrackMuteState
+	for _, track := range p.GetPublishedTracks() {
+		state := types.TrackMuteState{
+			TrackID: track.ID(),
+			Kind:    track.Kind(),
+			Muted:   track.IsMuted(),
+		}
+		if mt, ok := track.(*MediaTrack); ok {
+			state.MutedByServer = mt.IsMutedByServer()
+			state.LastPacketAt = mt.LastPacketTime()
+		}
+		states = append(states, state)
+	}
+	return states
+}
+
+// muteReconcileState is what the mute reconciler knows of a published track
+type muteReconcileState struct {
+	source        livekit.TrackSource
+	muted         bool
+	mutedByServer bool
+	lastPacketAt  time.Time
+	// media is not expected, nobody subscribes or the publisher was asked to pause all layers
+	idle bool
+}
+
+// muteCorrection returns the correction to apply to a track, empty if it is consistent
+func muteCorrection(state muteReconcileState, now time.Time, timeout time.Duration) string {
+	if state.lastPacketAt.IsZero() {
+		return ""
+	}
+	flowing := now.Sub(state.lastPacketAt) < timeout
+
+	switch {
+	case !state.muted && !flowing:
+		// screen shares of static content legitimately go quiet for long stretches
+		if state.idle || state.source == livekit.TrackSource_SCREEN_SHARE {
+			return ""
+		}
+		return muteCorrectionServerMute
+
+	case state.muted && state.mutedByServer && flowing:
+		return muteCorrectionServerUnmute
+
+	case state.muted && !state.mutedByServer && flowing:
+		return muteCorrectionPublisherRemute
+
+	default:
+		return ""
+	}
+}
+
+// muteReconcilerWorker corrects published tracks whose signaled mute state diverges from the media
+// received. A track signaled as unmuted which stopped receiving media for MediaTimeout is muted by the
+// server so that the room does not wait on a frozen track; it is unmuted as soon as media resumes.
+// Tracks without subscribers, paused by dynacast, or screen shares are not expected to send media and
+// are never muted by the server. A track signaled as muted which keeps receiving media has its mute
+// request sent to the publisher once more. Tracks that never received media are left alone.
+func (p *ParticipantImpl) muteReconcilerWorker() {
+	timeout := p.params.MuteReconciliation.MediaTimeout
+	ticker := time.NewTicker(timeout / 2)
+	defer ticker.Stop()
+
+	remuted := make(map[livekit.TrackID]bool)
+	for now := range ticker.C {
+		if p.IsClosed() || p.IsDisconnected() {
+			return
+		}
+
+		published := make(map[livekit.TrackID]bool)
+		for _, track := range p.GetPublishedTracks() {
+			mt, ok := track.(*MediaTrack)
+			if !ok {
+				continue
+			}
+			published[mt.ID()] = true
+
+			state := muteReconcileState{
+				source:        mt.Source(),
+				muted:         mt.IsMuted(),
+				mutedByServer: mt.IsMutedByServer(),
+				lastPacketAt:  mt.LastPacketTime(),
+				idle:          mt.GetNumSubscribers() == 0 || mt.IsPausedByDynacast(),
+			}
+			switch muteCorrection(state, now, timeout) {
+			case muteCorrectionServerMute:
+				p.pubLogger.Infow("no media received on unmuted track, muting",
+					"trackID", mt.ID(),
+					"lastPacketAt", state.lastPacketAt,
+				)
+				prometheus.RecordMuteCorrection(mt.Kind(), muteCorrectionServerMute)
+				p.setTrackMuted(mt.ID(), true)
+				mt.mutedByServer.Store(true)
+
+			case muteCorrectionServerUnmute:
+				p.pubLogger.Infow("media resumed on track muted by server, unmuting", "trackID", mt.ID())
+				prometheus.RecordMuteCorrection(mt.Kind(), muteCorrectionServerUnmute)
+				p.setTrackMuted(mt.ID(), false)
+
+			case muteCorrectionPublisherRemute:
+				if remuted[mt.ID()] {
+					continue
+				}
+				remuted[mt.ID()] = true
+				p.pubLogger.Infow("media received on muted track, asking publisher to mute", "trackID", mt.ID())
+				prometheus.RecordMuteCorrection(mt.Kind(), muteCorrectionPublisherRemute)
+				p.sendTrackMuted(mt.ID(), true)
+
+			default:
+				delete(remuted, mt.ID())
+			}
+		}
+
+		for trackID := range remuted {
+			if !published[trackID] {
+				delete(remuted, trackID)
+			}
+		}
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/protocol/livekit"
+)
+
+func TestMuteCorrection(t *testing.T) {
+	timeout := 10 * time.Second
+	now := time.Now()
+	stale := now.Add(-2 * timeout)
+	recent := now.Add(-time.Second)
+
+	testCases := []struct {
+		name     string
+		state    muteReconcileState
+		expected string
+	}{
+		{
+			name:     "never received media",
+			state:    muteReconcileState{source: livekit.TrackSource_CAMERA},
+			expected: "",
+		},
+		{
+			name:     "unmuted and flowing",
+			state:    muteReconcileState{source: livekit.TrackSource_CAMERA, lastPacketAt: recent},
+			expected: "",
+		},
+		{
+			name:     "unmuted and stopped",
+			state:    muteReconcileState{source: livekit.TrackSource_CAMERA, lastPacketAt: stale},
+			expected: muteCorrectionServerMute,
+		},
+		{
+			name:     "unmuted and stopped while idle",
+			state:    muteReconcileState{source: livekit.TrackSource_CAMERA, lastPacketAt: stale, idle: true},
+			expected: "",
+		},
+		{
+			name:     "unmuted screen share stopped",
+			state:    muteReconcileState{source: livekit.TrackSource_SCREEN_SHARE, lastPacketAt: stale},
+			expected: "",
+		},
+		{
+			name:     "unmuted microphone stopped",
+			state:    muteReconcileState{source: livekit.TrackSource_MICROPHONE, lastPacketAt: stale},
+			expected: muteCorrectionServerMute,
+		},
+		{
+			name:     "muted by server and resumed",
+			state:    muteReconcileState{source: livekit.TrackSource_CAMERA, muted: true, mutedByServer: true, lastPacketAt: recent},
+			expected: muteCorrectionServerUnmute,
+		},
+		{
+			name:     "muted by server and resumed while idle",
+			state:    muteReconcileState{source: livekit.TrackSource_CAMERA, muted: true, mutedByServer: true, lastPacketAt: recent, idle: true},
+			expected: muteCorrectionServerUnmute,
+		},
+		{
+			name:     "muted by server and stopped",
+			state:    muteReconcileState{source: livekit.TrackSource_CAMERA, muted: true, mutedByServer: true, lastPacketAt: stale},
+			expected: "",
+		},
+		{
+			name:     "muted by publisher and flowing",
+			state:    muteReconcileState{source: livekit.TrackSource_CAMERA, muted: true, lastPacketAt: recent},
+			expected: muteCorrectionPublisherRemute,
+		},
+		{
+			name:     "muted by publisher and stopped",
+			state:    muteReconcileState{source: livekit.TrackSource_CAMERA, muted: true, lastPacketAt: stale},
+			expected: "",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, muteCorrection(tc.state, now, timeout))
+		})
+	}
+}
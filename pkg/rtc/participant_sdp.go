@@ -245,7 +245,7 @@ func (p *ParticipantImpl) configurePublisherAnswer(answer webrtc.SessionDescript
 				}
 			}
 
-			if ti == nil || (ti.DisableDtx && !ti.Stereo) {
+			if ti == nil {
 				// no need to configure
 				continue
 			}
@@ -258,6 +258,7 @@ func (p *ParticipantImpl) configurePublisherAnswer(answer webrtc.SessionDescript
 
 			for i, attr := range m.Attributes {
 				if strings.HasPrefix(attr.String(), fmt.Sprintf("fmtp:%d", opusPT)) {
+					attr.Value = p.configureOpusFEC(attr.Value)
 					if !ti.DisableDtx {
 						attr.Value += ";usedtx=1"
 					}
@@ -281,3 +282,22 @@ func (p *ParticipantImpl) configurePublisherAnswer(answer webrtc.SessionDescript
 	answer.SDP = string(bytes)
 	return answer
 }
+
+// configureOpusFEC sets useinbandfec of an opus fmtp attribute value, publishers add in-band FEC to their audio
+// when it is 1
+func (p *ParticipantImpl) configureOpusFEC(fmtp string) string {
+	pt, params, _ := strings.Cut(fmtp, " ")
+	fec := "useinbandfec=1"
+	if p.params.DisableOpusFEC {
+		fec = "useinbandfec=0"
+	}
+
+	configured := []string{}
+	for _, param := range strings.Split(params, ";") {
+		if param != "" && !strings.HasPrefix(strings.ToLower(strings.TrimSpace(param)), "useinbandfec=") {
+			configured = append(configured, param)
+		}
+	}
+	configured = append(configured, fec)
+	return pt + " " + strings.Join(configured, ";")
+}
@@ -0,0 +1,102 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtc
+
+import (
+	"context"
+	"time"
+
+	"github.com/livekit/protocol/livekit"
+
+	"github.com/livekit/livekit-server/pkg/rtc/types"
+	"github.com/livekit/livekit-server/pkg/sfu"
+	"github.com/livekit/livekit-server/pkg/telemetry/prometheus"
+)
+
+const (
+	watchdogActionReset     = "reset"
+	watchdogActionReconnect = "reconnect"
+)
+
+type stalledTrack struct {
+	resets  int
+	resetAt time.Time
+}
+
+// subscriberWatchdogWorker resets subscribed tracks that stopped forwarding although their publisher
+// keeps sending. If a track stalls again after Watchdog.MaxResets resets, the participant is asked to
+// reconnect. A track that does not stall for two timeouts after a reset counts as recovered.
+func (p *ParticipantImpl) subscriberWatchdogWorker() {
+	timeout := p.params.Watchdog.StallTimeout
+	ticker := time.NewTicker(timeout / 2)
+	defer ticker.Stop()
+
+	stalled := make(map[livekit.TrackID]*stalledTrack)
+	for now := range ticker.C {
+		if p.IsClosed() || p.IsDisconnected() {
+			return
+		}
+
+		subscribed := make(map[livekit.TrackID]bool)
+		for _, st := range p.GetSubscribedTracks() {
+			subscribed[st.ID()] = true
+			dt := st.DownTrack()
+			if dt == nil {
+				continue
+			}
+
+			s := stalled[st.ID()]
+			stall := dt.CheckStall(timeout)
+			if stall == sfu.DownTrackStallNone {
+				if s != nil && now.Sub(s.resetAt) > 2*timeout {
+					delete(stalled, st.ID())
+				}
+				continue
+			}
+
+			if s == nil {
+				s = &stalledTrack{}
+				stalled[st.ID()] = s
+			}
+			if maxResets := p.params.Watchdog.MaxResets; maxResets > 0 && s.resets >= maxResets {
+				p.subLogger.Warnw("subscribed track still stalled, issuing full reconnect", nil,
+					"trackID", st.ID(),
+					"stall", stall,
+					"resets", s.resets,
+				)
+				prometheus.RecordDownTrackStall(string(stall), watchdogActionReconnect)
+				p.IssueFullReconnect(types.ParticipantCloseReasonMediaStalled)
+				return
+			}
+
+			s.resets++
+			s.resetAt = now
+			p.subLogger.Infow("resetting stalled subscribed track",
+				"trackID", st.ID(),
+				"stall", stall,
+				"attempt", s.resets,
+			)
+			prometheus.RecordDownTrackStall(string(stall), watchdogActionReset)
+			p.params.Telemetry.TrackStalled(context.Background(), p.ID(), st.MediaTrack().ToProto())
+			dt.ResetStalled()
+		}
+
+		for trackID := range stalled {
+			if !subscribed[trackID] {
+				delete(stalled, trackID)
+			}
+		}
+	}
+}
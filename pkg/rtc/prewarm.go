@@ -0,0 +1,91 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtc
+
+import (
+	"time"
+
+	"github.com/pion/webrtc/v3"
+
+	"github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/logger"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/rtc/types"
+)
+
+const (
+	prewarmGatherTimeout = 5 * time.Second
+)
+
+// PrewarmTransports sets up a publisher and a subscriber peer connection the way a joining participant
+// would and gathers ICE candidates on them before tearing them down. It moves media engine, interceptor
+// and socket setup ahead of a join spike and surfaces transport configuration errors early.
+func PrewarmTransports(conf *WebRTCConfig, ccConf config.CongestionControlConfig, enabledCodecs []*livekit.Codec, logger logger.Logger) error {
+	for _, params := range []TransportParams{
+		{
+			ProtocolVersion:         types.CurrentProtocol,
+			Config:                  conf,
+			DirectionConfig:         conf.Publisher,
+			CongestionControlConfig: ccConf,
+			EnabledCodecs:           enabledCodecs,
+			Logger:                  logger,
+		},
+		{
+			ProtocolVersion:         types.CurrentProtocol,
+			Config:                  conf,
+			DirectionConfig:         conf.Subscriber,
+			CongestionControlConfig: ccConf,
+			EnabledCodecs:           enabledCodecs,
+			Logger:                  logger,
+			IsOfferer:               true,
+			IsSendSide:              true,
+		},
+	} {
+		pc, _, err := newPeerConnection(params, nil)
+		if err != nil {
+			return err
+		}
+
+		err = gatherCandidates(pc)
+		_ = pc.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func gatherCandidates(pc *webrtc.PeerConnection) error {
+	if _, err := pc.CreateDataChannel(ReliableDataChannel, nil); err != nil {
+		return err
+	}
+
+	gatherComplete := webrtc.GatheringCompletePromise(pc)
+	offer, err := pc.CreateOffer(nil)
+	if err != nil {
+		return err
+	}
+	if err = pc.SetLocalDescription(offer); err != nil {
+		return err
+	}
+
+	select {
+	case <-gatherComplete:
+	case <-time.After(prewarmGatherTimeout):
+	}
+	return nil
+}
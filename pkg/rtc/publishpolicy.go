@@ -0,0 +1,130 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtc
+
+import (
+	"strings"
+
+	"github.com/livekit/protocol/livekit"
+
+	"github.com/livekit/livekit-server/pkg/rtc/types"
+)
+
+// PublishDecision is the outcome of authorizing a track publication. Constraints left at their
+// zero value keep what the client requested.
+type PublishDecision struct {
+	Allow  bool   `json:"allow"`
+	Reason string `json:"reason,omitempty"`
+	// replaces the track name
+	Name string `json:"name,omitempty"`
+	// caps the video layers forwarded from the publisher, layers above it are not requested
+	MaxWidth  uint32 `json:"max_width,omitempty"`
+	MaxHeight uint32 `json:"max_height,omitempty"`
+	// restricts simulcast codecs to these, e.g. vp8 or video/h264
+	Codecs     []string `json:"codecs,omitempty"`
+	DisableRed bool     `json:"disable_red,omitempty"`
+}
+
+// AuthorizePublishFunc is called before a track is published, a nil decision allows it unchanged
+type AuthorizePublishFunc func(participant types.LocalParticipant, req *livekit.AddTrackRequest) *PublishDecision
+
+// Apply rewrites the request with the decision's constraints. It returns false when none of the
+// requested codecs are allowed.
+func (d *PublishDecision) Apply(req *livekit.AddTrackRequest) bool {
+	if d.Name != "" {
+		req.Name = d.Name
+	}
+	if d.DisableRed {
+		req.DisableRed = true
+	}
+
+	if len(d.Codecs) != 0 && len(req.SimulcastCodecs) != 0 {
+		codecs := req.SimulcastCodecs[:0]
+		for _, c := range req.SimulcastCodecs {
+			if d.allowsCodec(c.Codec) {
+				codecs = append(codecs, c)
+			}
+		}
+		if len(codecs) == 0 {
+			return false
+		}
+		req.SimulcastCodecs = codecs
+	}
+
+	if req.Type == livekit.TrackType_VIDEO {
+		req.Width, req.Height = fitDimensions(req.Width, req.Height, d.MaxWidth, d.MaxHeight)
+	}
+	return true
+}
+
+func (d *PublishDecision) allowsCodec(codec string) bool {
+	name := normalizeCodecName(codec)
+	for _, c := range d.Codecs {
+		if normalizeCodecName(c) == name {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeCodecName(codec string) string {
+	codec = strings.ToLower(codec)
+	if i := strings.IndexByte(codec, '/'); i >= 0 {
+		codec = codec[i+1:]
+	}
+	return codec
+}
+
+// fitDimensions scales width and height down to fit the cap, keeping the aspect ratio
+func fitDimensions(width, height, maxWidth, maxHeight uint32) (uint32, uint32) {
+	if maxWidth != 0 && width > maxWidth {
+		height = uint32(uint64(height) * uint64(maxWidth) / uint64(width))
+		width = maxWidth
+	}
+	if maxHeight != 0 && height > maxHeight {
+		width = uint32(uint64(width) * uint64(maxHeight) / uint64(height))
+		height = maxHeight
+	}
+	return width, height
+}
+
+// maxQualityWithin returns the highest layer of the track fitting the dimension cap. When no layer
+// fits, the lowest one is returned, so the track stays available.
+func maxQualityWithin(ti *livekit.TrackInfo, maxWidth, maxHeight uint32) (livekit.VideoQuality, bool) {
+	if (maxWidth == 0 && maxHeight == 0) || len(ti.Layers) == 0 {
+		return livekit.VideoQuality_OFF, false
+	}
+
+	best := livekit.VideoQuality_OFF
+	lowest := livekit.VideoQuality_OFF
+	for _, layer := range ti.Layers {
+		if lowest == livekit.VideoQuality_OFF || layer.Quality < lowest {
+			lowest = layer.Quality
+		}
+		if maxWidth != 0 && layer.Width > maxWidth {
+			continue
+		}
+		if maxHeight != 0 && layer.Height > maxHeight {
+			continue
+		}
+		if best == livekit.VideoQuality_OFF || layer.Quality > best {
+			best = layer.Quality
+		}
+	}
+	if best == livekit.VideoQuality_OFF {
+		best = lowest
+	}
+	return best, best != livekit.VideoQuality_OFF
+}
@@ -0,0 +1,77 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/protocol/livekit"
+)
+
+func TestPublishDecisionApply(t *testing.T) {
+	req := &livekit.AddTrackRequest{
+		Type:   livekit.TrackType_VIDEO,
+		Name:   "camera",
+		Width:  1920,
+		Height: 1080,
+		SimulcastCodecs: []*livekit.SimulcastCodec{
+			{Codec: "vp9", Cid: "a"},
+			{Codec: "video/VP8", Cid: "b"},
+		},
+	}
+	decision := &PublishDecision{
+		Allow:      true,
+		Name:       "lecture",
+		MaxWidth:   1280,
+		Codecs:     []string{"vp8"},
+		DisableRed: true,
+	}
+	require.True(t, decision.Apply(req))
+	require.Equal(t, "lecture", req.Name)
+	require.Equal(t, uint32(1280), req.Width)
+	require.Equal(t, uint32(720), req.Height)
+	require.Len(t, req.SimulcastCodecs, 1)
+	require.Equal(t, "b", req.SimulcastCodecs[0].Cid)
+	require.True(t, req.DisableRed)
+
+	decision = &PublishDecision{Allow: true, Codecs: []string{"h264"}}
+	require.False(t, decision.Apply(req))
+}
+
+func TestMaxQualityWithin(t *testing.T) {
+	ti := &livekit.TrackInfo{
+		Layers: []*livekit.VideoLayer{
+			{Quality: livekit.VideoQuality_LOW, Width: 320, Height: 180},
+			{Quality: livekit.VideoQuality_MEDIUM, Width: 640, Height: 360},
+			{Quality: livekit.VideoQuality_HIGH, Width: 1280, Height: 720},
+		},
+	}
+
+	_, ok := maxQualityWithin(ti, 0, 0)
+	require.False(t, ok)
+
+	quality, ok := maxQualityWithin(ti, 0, 720)
+	require.True(t, ok)
+	require.Equal(t, livekit.VideoQuality_HIGH, quality)
+
+	quality, _ = maxQualityWithin(ti, 1000, 0)
+	require.Equal(t, livekit.VideoQuality_MEDIUM, quality)
+
+	// nothing fits, the lowest layer is kept
+	quality, _ = maxQualityWithin(ti, 100, 100)
+	require.Equal(t, livekit.VideoQuality_LOW, quality)
+}
@@ -0,0 +1,253 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtc
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/livekit/protocol/livekit"
+
+	"github.com/livekit/livekit-server/pkg/sfu/connectionquality"
+	"github.com/livekit/livekit-server/pkg/telemetry"
+)
+
+// samples kept per participant and direction for percentiles. Once full, samples are replaced at random so that
+// long sessions are represented evenly
+const maxQoSSamples = 1000
+
+// QoSParticipantReport summarizes the network quality of a participant session
+type QoSParticipantReport struct {
+	Identity      livekit.ParticipantIdentity `json:"identity"`
+	ParticipantID livekit.ParticipantID       `json:"participant_id"`
+	// tracks published by the participant
+	Upstream *QoSStreamReport `json:"upstream,omitempty"`
+	// tracks the participant subscribed to
+	Downstream *QoSStreamReport `json:"downstream,omitempty"`
+	// share of connection quality samples at each quality, excellent, good, poor or lost
+	Quality map[string]float64 `json:"quality,omitempty"`
+}
+
+// QoSStreamReport aggregates the stats of the tracks of a participant in one direction, a sample being a track
+// over a stats interval
+type QoSStreamReport struct {
+	Samples int `json:"samples"`
+	// percent of packets lost
+	Loss QoSDistribution `json:"loss"`
+	// ms
+	RTT QoSDistribution `json:"rtt"`
+	// bps
+	Bitrate QoSDistribution `json:"bitrate"`
+}
+
+type QoSDistribution struct {
+	Avg float64 `json:"avg"`
+	P50 float64 `json:"p50"`
+	P95 float64 `json:"p95"`
+	P99 float64 `json:"p99"`
+}
+
+type qosSample struct {
+	loss    float64
+	rtt     float64
+	bitrate float64
+}
+
+type qosDirection struct {
+	count   int
+	sum     qosSample
+	samples []qosSample
+}
+
+type qosParticipant struct {
+	identity   livekit.ParticipantIdentity
+	upstream   qosDirection
+	downstream qosDirection
+	quality    map[livekit.ConnectionQuality]int
+}
+
+// QoSStats accumulates the track stats and connection quality of the participants of a room, by session
+type QoSStats struct {
+	lock         sync.Mutex
+	participants map[livekit.ParticipantID]*qosParticipant
+}
+
+func NewQoSStats() *QoSStats {
+	return &QoSStats{
+		participants: make(map[livekit.ParticipantID]*qosParticipant),
+	}
+}
+
+// AddTrackStat records the stat of a track published (upstream) or subscribed to (downstream) by a participant
+func (s *QoSStats) AddTrackStat(pID livekit.ParticipantID, streamType livekit.StreamType, stat *livekit.AnalyticsStat) {
+	var packets, lost uint32
+	var bytes uint64
+	var rtt uint32
+	for _, stream := range stat.Streams {
+		packets += stream.PrimaryPackets + stream.PaddingPackets
+		lost += stream.PacketsLost
+		bytes += stream.PrimaryBytes + stream.RetransmitBytes
+		if stream.Rtt > rtt {
+			rtt = stream.Rtt
+		}
+	}
+	// muted or paused
+	if packets == 0 && lost == 0 {
+		return
+	}
+
+	sample := qosSample{
+		loss:    float64(lost) * 100 / float64(packets+lost),
+		rtt:     float64(rtt),
+		bitrate: float64(bytes*8) / connectionquality.UpdateInterval.Seconds(),
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	p := s.getLocked(pID)
+	if streamType == livekit.StreamType_DOWNSTREAM {
+		p.downstream.add(sample)
+	} else {
+		p.upstream.add(sample)
+	}
+}
+
+// AddQuality records a connection quality sample of a participant
+func (s *QoSStats) AddQuality(pID livekit.ParticipantID, identity livekit.ParticipantIdentity, quality livekit.ConnectionQuality) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	p := s.getLocked(pID)
+	p.identity = identity
+	p.quality[quality]++
+}
+
+// Report returns the network quality of every participant session, by identity
+func (s *QoSStats) Report() []QoSParticipantReport {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	reports := make([]QoSParticipantReport, 0, len(s.participants))
+	for pID, p := range s.participants {
+		report := QoSParticipantReport{
+			Identity:      p.identity,
+			ParticipantID: pID,
+			Upstream:      p.upstream.report(),
+			Downstream:    p.downstream.report(),
+		}
+		total := 0
+		for _, count := range p.quality {
+			total += count
+		}
+		if total > 0 {
+			report.Quality = make(map[string]float64, len(p.quality))
+			for quality, count := range p.quality {
+				report.Quality[strings.ToLower(quality.String())] = float64(count) / float64(total)
+			}
+		}
+		reports = append(reports, report)
+	}
+	sort.Slice(reports, func(i, j int) bool {
+		if reports[i].Identity != reports[j].Identity {
+			return reports[i].Identity < reports[j].Identity
+		}
+		return reports[i].ParticipantID < reports[j].ParticipantID
+	})
+	return reports
+}
+
+func (s *QoSStats) getLocked(pID livekit.ParticipantID) *qosParticipant {
+	p := s.participants[pID]
+	if p == nil {
+		p = &qosParticipant{quality: make(map[livekit.ConnectionQuality]int)}
+		s.participants[pID] = p
+	}
+	return p
+}
+
+func (d *qosDirection) add(sample qosSample) {
+	d.count++
+	d.sum.loss += sample.loss
+	d.sum.rtt += sample.rtt
+	d.sum.bitrate += sample.bitrate
+	if len(d.samples) < maxQoSSamples {
+		d.samples = append(d.samples, sample)
+	} else if i := rand.Intn(d.count); i < maxQoSSamples {
+		d.samples[i] = sample
+	}
+}
+
+func (d *qosDirection) report() *QoSStreamReport {
+	if d.count == 0 {
+		return nil
+	}
+
+	loss := make([]float64, len(d.samples))
+	rtt := make([]float64, len(d.samples))
+	bitrate := make([]float64, len(d.samples))
+	for i, sample := range d.samples {
+		loss[i] = sample.loss
+		rtt[i] = sample.rtt
+		bitrate[i] = sample.bitrate
+	}
+	count := float64(d.count)
+	return &QoSStreamReport{
+		Samples: d.count,
+		Loss:    newQoSDistribution(d.sum.loss/count, loss),
+		RTT:     newQoSDistribution(d.sum.rtt/count, rtt),
+		Bitrate: newQoSDistribution(d.sum.bitrate/count, bitrate),
+	}
+}
+
+func newQoSDistribution(avg float64, values []float64) QoSDistribution {
+	sort.Float64s(values)
+	return QoSDistribution{
+		Avg: avg,
+		P50: percentile(values, 50),
+		P95: percentile(values, 95),
+		P99: percentile(values, 99),
+	}
+}
+
+// percentile of sorted values, nearest rank
+func percentile(values []float64, p float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(values))))
+	if rank < 1 {
+		rank = 1
+	}
+	return values[rank-1]
+}
+
+// ------------------------------------------------
+
+// qosTelemetry records the stats of participant tracks into the QoS stats of their room
+type qosTelemetry struct {
+	telemetry.TelemetryService
+	stats *QoSStats
+}
+
+func (t *qosTelemetry) TrackStats(key telemetry.StatsKey, stat *livekit.AnalyticsStat) {
+	if key.IsTrack() {
+		t.stats.AddTrackStat(key.ParticipantID(), key.StreamType(), stat)
+	}
+	t.TelemetryService.TrackStats(key, stat)
+}
@@ -0,0 +1,69 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/protocol/livekit"
+)
+
+func TestQoSStats(t *testing.T) {
+	s := NewQoSStats()
+	stat := func(packets, lost, rtt uint32) *livekit.AnalyticsStat {
+		return &livekit.AnalyticsStat{
+			Streams: []*livekit.AnalyticsStream{{
+				PrimaryPackets: packets,
+				PrimaryBytes:   uint64(packets) * 1000,
+				PacketsLost:    lost,
+				Rtt:            rtt,
+			}},
+		}
+	}
+
+	for i := uint32(0); i < 10; i++ {
+		s.AddTrackStat("PA_student", livekit.StreamType_UPSTREAM, stat(100-i*10, i*10, 20+i*10))
+	}
+	// idle tracks are not sampled
+	s.AddTrackStat("PA_student", livekit.StreamType_UPSTREAM, stat(0, 0, 0))
+	s.AddTrackStat("PA_teacher", livekit.StreamType_DOWNSTREAM, stat(500, 0, 40))
+	s.AddQuality("PA_student", "student", livekit.ConnectionQuality_EXCELLENT)
+	s.AddQuality("PA_student", "student", livekit.ConnectionQuality_EXCELLENT)
+	s.AddQuality("PA_student", "student", livekit.ConnectionQuality_EXCELLENT)
+	s.AddQuality("PA_student", "student", livekit.ConnectionQuality_POOR)
+	s.AddQuality("PA_teacher", "teacher", livekit.ConnectionQuality_GOOD)
+
+	report := s.Report()
+	require.Len(t, report, 2)
+
+	student := report[0]
+	require.Equal(t, livekit.ParticipantIdentity("student"), student.Identity)
+	require.Nil(t, student.Downstream)
+	require.Equal(t, 10, student.Upstream.Samples)
+	require.InDelta(t, 45, student.Upstream.Loss.Avg, 0.001)
+	require.InDelta(t, 40, student.Upstream.Loss.P50, 0.001)
+	require.InDelta(t, 90, student.Upstream.Loss.P95, 0.001)
+	require.InDelta(t, 65, student.Upstream.RTT.Avg, 0.001)
+	require.InDelta(t, 110, student.Upstream.RTT.P99, 0.001)
+	require.Equal(t, map[string]float64{"excellent": 0.75, "poor": 0.25}, student.Quality)
+
+	teacher := report[1]
+	require.Equal(t, livekit.ParticipantID("PA_teacher"), teacher.ParticipantID)
+	require.Nil(t, teacher.Upstream)
+	require.InDelta(t, 800_000, teacher.Downstream.Bitrate.Avg, 0.001)
+	require.Equal(t, map[string]float64{"good": 1}, teacher.Quality)
+}
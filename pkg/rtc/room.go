@@ -95,6 +95,35 @@ type Room struct {
 
 	trailer []byte
 
+	maxEgressBitrate       atomic.Int64
+	transcodeBitrate       atomic.Int64
+	redEncoding            atomic.Bool
+	departureTimeout       atomic.Uint32
+	bandwidthWorkerStarted atomic.Bool
+	videoAllocation        atomic.String
+	pausedVideoPlaceholder atomic.String
+	welcomePacket          *livekit.UserPacket
+	clientPingInterval     time.Duration
+	clientPingTimeout      time.Duration
+	broadcast              bool
+	maxPublishers          int
+	fanOutThreshold        int
+	e2ee                   *e2eeKeyProvider
+	// only accessed from the audio update worker
+	preferredSpeaker livekit.ParticipantID
+	speakerStats     *SpeakerStats
+	// nil unless the room has a QoS report
+	qosStats atomic.Pointer[QoSStats]
+	// sorted by MinParticipants
+	updateThrottle []config.UpdateThrottleStep
+	// overrides of the estimated bandwidth of subscribers, by identity for the ones with an override of their own
+	bandwidthOverrideLock sync.RWMutex
+	subscriberBandwidth   config.BandwidthOverrideConfig
+	participantBandwidth  map[livekit.ParticipantIdentity]config.BandwidthOverrideConfig
+	// opus in-band FEC asked of publishers, with the bitrate subscribers keep free for it
+	opusFECDisabled bool
+	opusFECHeadroom int64
+
 	onParticipantChanged func(p types.LocalParticipant)
 	onRoomUpdated        func()
 	onClose              func()
@@ -126,6 +155,7 @@ func NewRoom(
 		telemetry:                 telemetry,
 		egressLauncher:            egressLauncher,
 		trackManager:              NewRoomTrackManager(),
+		speakerStats:              NewSpeakerStats(),
 		serverInfo:                serverInfo,
 		participants:              make(map[livekit.ParticipantIdentity]types.LocalParticipant),
 		participantOpts:           make(map[livekit.ParticipantIdentity]*ParticipantOptions),
@@ -134,7 +164,11 @@ func NewRoom(
 		batchedUpdates:            make(map[livekit.ParticipantIdentity]*livekit.ParticipantInfo),
 		closed:                    make(chan struct{}),
 		trailer:                   []byte(utils.RandomSecret()),
+		// sane defaults for ping interval & timeout
+		clientPingInterval: 10 * time.Second,
+		clientPingTimeout:  20 * time.Second,
 	}
+	r.departureTimeout.Store(RoomDepartureGrace)
 	r.protoProxy = utils.NewProtoProxy[*livekit.Room](roomUpdateInterval, r.updateProto)
 	if r.protoRoom.EmptyTimeout == 0 {
 		r.protoRoom.EmptyTimeout = DefaultEmptyTimeout
@@ -286,11 +320,17 @@ func (r *Room) Join(participant types.LocalParticipant, requestSource routing.Me
 			return ErrMaxParticipantsExceeded
 		}
 	}
+	if r.publishersLimitReachedLocked(participant) {
+		return ErrMaxPublishersExceeded
+	}
 
 	if r.FirstJoinedAt() == 0 {
 		r.joinedAt.Store(time.Now().Unix())
 	}
 
+	participant.SetSubscriberPausedVideoPlaceholder(r.PausedVideoPlaceholder())
+	participant.SetSubscriberBandwidthOverride(r.bandwidthOverrideFor(participant.Identity()))
+
 	// it's important to set this before connection, we don't want to miss out on any published tracks
 	participant.OnTrackPublished(r.onTrackPublished)
 	participant.OnStateChange(func(p types.LocalParticipant, oldState livekit.ParticipantInfo_State) {
@@ -311,6 +351,8 @@ func (r *Room) Join(participant types.LocalParticipant, requestSource routing.Me
 
 			// start the workers once connectivity is established
 			p.Start()
+			r.sendWelcomePacket(p)
+			r.sendE2EEKeys(p)
 
 			r.telemetry.ParticipantActive(context.Background(),
 				r.ToProto(),
@@ -522,6 +564,10 @@ func (r *Room) RemoveParticipant(identity livekit.ParticipantIdentity, pID livek
 	_ = p.Close(true, reason, false)
 
 	r.leftAt.Store(time.Now().Unix())
+	if !p.IsRecorder() && r.isEmpty() {
+		r.notifyRoomEmpty()
+	}
+	r.onE2EEParticipantLeft(p, reason)
 
 	if sendUpdates {
 		if r.onParticipantChanged != nil {
@@ -611,6 +657,18 @@ func (r *Room) UpdateSubscriptionPermission(participant types.LocalParticipant,
 	return nil
 }
 
+// UpdateAdminSubscriptionPermission restricts who may subscribe to the tracks of the participant on top of the
+// permissions set by the participant, subscriptions which are no longer allowed are revoked
+func (r *Room) UpdateAdminSubscriptionPermission(participant types.LocalParticipant, subscriptionPermission *livekit.SubscriptionPermission) error {
+	if err := participant.UpdateAdminSubscriptionPermission(subscriptionPermission, r.GetParticipant, r.GetParticipantByID); err != nil {
+		return err
+	}
+	for _, track := range participant.GetPublishedTracks() {
+		r.trackManager.NotifyTrackChanged(track.ID())
+	}
+	return nil
+}
+
 func (r *Room) UpdateVideoLayers(participant types.Participant, updateVideoLayers *livekit.UpdateVideoLayers) error {
 	return participant.UpdateVideoLayers(updateVideoLayers)
 }
@@ -669,7 +727,7 @@ func (r *Room) CloseIfEmpty() {
 	if r.FirstJoinedAt() > 0 && r.LastLeftAt() > 0 {
 		elapsed = time.Now().Unix() - r.LastLeftAt()
 		// need to give time in case participant is reconnecting
-		timeout = RoomDepartureGrace
+		timeout = r.DepartureTimeout()
 	} else {
 		elapsed = time.Now().Unix() - r.protoRoom.CreationTime
 		timeout = r.protoRoom.EmptyTimeout
@@ -693,6 +751,9 @@ func (r *Room) Close() {
 	close(r.closed)
 	r.lock.Unlock()
 	r.Logger.Infow("closing room")
+	if stats := r.speakerStats.Stats(); len(stats) > 0 {
+		r.Logger.Infow("room speaker stats", "speakers", stats)
+	}
 	for _, p := range r.GetParticipants() {
 		_ = p.Close(true, types.ParticipantCloseReasonRoomClose, false)
 	}
@@ -810,7 +871,7 @@ func (r *Room) getOtherParticipantInfo(identity livekit.ParticipantIdentity) []*
 	participants := r.GetParticipants()
 	pi := make([]*livekit.ParticipantInfo, 0, len(participants))
 	for _, p := range participants {
-		if !p.Hidden() && p.Identity() != identity {
+		if !p.Hidden() && !r.isViewer(p) && p.Identity() != identity {
 			pi = append(pi, p.ToProto())
 		}
 	}
@@ -828,11 +889,19 @@ func (r *Room) autoSubscribe(participant types.LocalParticipant) bool {
 	return true
 }
 
+// SetClientPing sets how often clients joining from now on ping over signal, and how long they wait for pongs
+func (r *Room) SetClientPing(interval, timeout time.Duration) {
+	r.lock.Lock()
+	r.clientPingInterval = interval
+	r.clientPingTimeout = timeout
+	r.lock.Unlock()
+}
+
 func (r *Room) createJoinResponseLocked(participant types.LocalParticipant, iceServers []*livekit.ICEServer) *livekit.JoinResponse {
 	// gather other participants and send join response
 	otherParticipants := make([]*livekit.ParticipantInfo, 0, len(r.participants))
 	for _, p := range r.participants {
-		if p.ID() != participant.ID() && !p.Hidden() {
+		if p.ID() != participant.ID() && !p.Hidden() && !r.isViewerLocked(p) {
 			otherParticipants = append(otherParticipants, p.ToProto())
 		}
 	}
@@ -845,13 +914,12 @@ func (r *Room) createJoinResponseLocked(participant types.LocalParticipant, iceS
 		// indicates both server and client support subscriber as primary
 		SubscriberPrimary:   participant.SubscriberAsPrimary(),
 		ClientConfiguration: participant.GetClientConfiguration(),
-		// sane defaults for ping interval & timeout
-		PingInterval:  10,
-		PingTimeout:   20,
-		ServerInfo:    r.serverInfo,
-		ServerVersion: r.serverInfo.Version,
-		ServerRegion:  r.serverInfo.Region,
-		SifTrailer:    r.trailer,
+		PingInterval:        int32(r.clientPingInterval.Seconds()),
+		PingTimeout:         int32(r.clientPingTimeout.Seconds()),
+		ServerInfo:          r.serverInfo,
+		ServerVersion:       r.serverInfo.Version,
+		ServerRegion:        r.serverInfo.Region,
+		SifTrailer:          r.trailer,
 	}
 }
 
@@ -936,6 +1004,9 @@ func (r *Room) onParticipantUpdate(p types.LocalParticipant) {
 }
 
 func (r *Room) onDataPacket(source types.LocalParticipant, dp *livekit.DataPacket) {
+	if source != nil && r.handleE2EEPacket(source, dp) {
+		return
+	}
 	BroadcastDataPacketForRoom(r, source, dp, r.Logger)
 }
 
@@ -969,9 +1040,9 @@ func (r *Room) subscribeToExistingTracks(p types.LocalParticipant) {
 func (r *Room) broadcastParticipantState(p types.LocalParticipant, opts broadcastOptions) {
 	pi := p.ToProto()
 
-	if p.Hidden() {
+	if p.Hidden() || r.isViewer(p) {
 		if !opts.skipSource {
-			// send update only to hidden participant
+			// send update only to hidden participant, or viewer of a broadcast
 			err := p.SendParticipantUpdate([]*livekit.ParticipantInfo{pi})
 			if err != nil {
 				r.Logger.Errorw("could not send update to participant", err,
@@ -1151,6 +1222,7 @@ func (r *Room) audioUpdateWorker() {
 		}
 
 		activeSpeakers := r.GetActiveSpeakers()
+		r.updateSpeakerStats(activeSpeakers)
 		changedSpeakers := make([]*livekit.SpeakerInfo, 0, len(activeSpeakers))
 		nextActiveMap := make(map[livekit.ParticipantID]*livekit.SpeakerInfo, len(activeSpeakers))
 		for _, speaker := range activeSpeakers {
@@ -1176,21 +1248,38 @@ func (r *Room) audioUpdateWorker() {
 			r.sendActiveSpeakers(activeSpeakers)
 			r.sendSpeakerChanges(changedSpeakers)
 		}
+		r.updatePreferredPublisher(activeSpeakers)
 
 		lastActiveMap = nextActiveMap
 
-		time.Sleep(time.Duration(r.audioConfig.UpdateInterval) * time.Millisecond)
+		time.Sleep(r.throttledUpdateInterval(time.Duration(r.audioConfig.UpdateInterval) * time.Millisecond))
 	}
 }
 
+func (r *Room) updateSpeakerStats(activeSpeakers []*livekit.SpeakerInfo) {
+	identities := make([]livekit.ParticipantIdentity, 0, len(activeSpeakers))
+	for _, speaker := range activeSpeakers {
+		if p := r.GetParticipantByID(livekit.ParticipantID(speaker.Sid)); p != nil {
+			identities = append(identities, p.Identity())
+		}
+	}
+	r.speakerStats.Update(time.Now(), identities)
+}
+
+// SpeakerStats returns talk time and interruptions of everyone who spoke in the room
+func (r *Room) SpeakerStats() []SpeakerStat {
+	return r.speakerStats.Stats()
+}
+
 func (r *Room) connectionQualityWorker() {
-	ticker := time.NewTicker(connectionquality.UpdateInterval)
-	defer ticker.Stop()
+	timer := time.NewTimer(connectionquality.UpdateInterval)
+	defer timer.Stop()
 
 	prevConnectionInfos := make(map[livekit.ParticipantID]*livekit.ConnectionQualityInfo)
 	// send updates to only users that are subscribed to each other
 	for !r.IsClosed() {
-		<-ticker.C
+		<-timer.C
+		timer.Reset(r.throttledUpdateInterval(connectionquality.UpdateInterval))
 
 		participants := r.GetParticipants()
 		nowConnectionInfos := make(map[livekit.ParticipantID]*livekit.ConnectionQualityInfo, len(participants))
@@ -1202,6 +1291,9 @@ func (r *Room) connectionQualityWorker() {
 
 			if q := p.GetConnectionQuality(); q != nil {
 				nowConnectionInfos[p.ID()] = q
+				if stats := r.qosStats.Load(); stats != nil {
+					stats.AddQuality(p.ID(), p.Identity(), q.Quality)
+				}
 			}
 		}
 
@@ -1276,6 +1368,7 @@ func (r *Room) DebugInfo() map[string]interface{} {
 		participantInfo[string(p.Identity())] = p.DebugInfo()
 	}
 	info["Participants"] = participantInfo
+	info["SpeakerStats"] = r.SpeakerStats()
 
 	return info
 }
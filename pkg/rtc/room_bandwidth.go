@@ -0,0 +1,337 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtc
+
+import (
+	"sort"
+	"time"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/sfu/streamallocator"
+	"github.com/livekit/protocol/livekit"
+)
+
+const (
+	roomBandwidthUpdateInterval = time.Second
+	defaultOpusFECHeadroom      = 24000
+)
+
+// SetMaxEgressBitrate caps the aggregate bitrate forwarded to all subscribers in the room.
+// The cap is shared fairly between subscribers and enforced by their stream allocators, 0 removes it.
+func (r *Room) SetMaxEgressBitrate(maxEgressBitrate int64) {
+	r.maxEgressBitrate.Store(maxEgressBitrate)
+	if maxEgressBitrate > 0 && r.bandwidthWorkerStarted.CompareAndSwap(false, true) {
+		r.Logger.Infow("limiting room egress bitrate", "maxEgressBitrate", maxEgressBitrate)
+		go r.bandwidthWorker()
+	}
+}
+
+func (r *Room) bandwidthWorker() {
+	ticker := time.NewTicker(roomBandwidthUpdateInterval)
+	defer ticker.Stop()
+
+	for !r.IsClosed() {
+		<-ticker.C
+
+		participants := r.GetParticipants()
+		maxEgressBitrate := r.maxEgressBitrate.Load()
+		if maxEgressBitrate <= 0 {
+			for _, p := range participants {
+				p.SetSubscriberMaxChannelCapacity(0)
+			}
+			continue
+		}
+
+		demands := make(map[livekit.ParticipantID]int64, len(participants))
+		for _, p := range participants {
+			if p.State() != livekit.ParticipantInfo_ACTIVE {
+				continue
+			}
+			demands[p.ID()] = p.GetSubscriberBandwidthDemand()
+		}
+
+		shares := fairShareBandwidth(maxEgressBitrate, demands)
+		for _, p := range participants {
+			if share, ok := shares[p.ID()]; ok {
+				p.SetSubscriberMaxChannelCapacity(share)
+			}
+		}
+	}
+}
+
+// GetSubscriberBandwidthDemand returns the bitrate needed to forward the subscribed tracks of all participants at
+// their optimal layers
+func (r *Room) GetSubscriberBandwidthDemand() int64 {
+	demand := int64(0)
+	for _, p := range r.GetParticipants() {
+		if p.State() == livekit.ParticipantInfo_ACTIVE {
+			demand += p.GetSubscriberBandwidthDemand()
+		}
+	}
+	return demand
+}
+
+// SetSubscriberBandwidthOverride caps or forces the estimated bandwidth of subscribers without an override of their
+// own, the zero override returns them to congestion control
+func (r *Room) SetSubscriberBandwidthOverride(override config.BandwidthOverrideConfig) {
+	r.bandwidthOverrideLock.Lock()
+	changed := r.subscriberBandwidth != override
+	r.subscriberBandwidth = override
+	r.bandwidthOverrideLock.Unlock()
+	if !changed {
+		return
+	}
+
+	r.Logger.Infow("setting subscriber bandwidth override",
+		"maxBitrate", override.MaxBitrate,
+		"forcedBitrate", override.ForcedBitrate,
+	)
+	for _, p := range r.GetParticipants() {
+		p.SetSubscriberBandwidthOverride(r.bandwidthOverrideFor(p.Identity()))
+	}
+}
+
+func (r *Room) SubscriberBandwidthOverride() config.BandwidthOverrideConfig {
+	r.bandwidthOverrideLock.RLock()
+	defer r.bandwidthOverrideLock.RUnlock()
+
+	return r.subscriberBandwidth
+}
+
+// SetParticipantBandwidthOverride caps or forces the estimated bandwidth of one subscriber, it also applies when
+// the participant joins or reconnects later. nil removes it, the override of the room applies again
+func (r *Room) SetParticipantBandwidthOverride(identity livekit.ParticipantIdentity, override *config.BandwidthOverrideConfig) {
+	r.bandwidthOverrideLock.Lock()
+	if override == nil {
+		delete(r.participantBandwidth, identity)
+	} else {
+		if r.participantBandwidth == nil {
+			r.participantBandwidth = make(map[livekit.ParticipantIdentity]config.BandwidthOverrideConfig)
+		}
+		r.participantBandwidth[identity] = *override
+	}
+	r.bandwidthOverrideLock.Unlock()
+
+	if override == nil {
+		r.Logger.Infow("clearing participant bandwidth override", "participant", identity)
+	} else {
+		r.Logger.Infow("setting participant bandwidth override",
+			"participant", identity,
+			"maxBitrate", override.MaxBitrate,
+			"forcedBitrate", override.ForcedBitrate,
+		)
+	}
+	if p := r.GetParticipant(identity); p != nil {
+		p.SetSubscriberBandwidthOverride(r.bandwidthOverrideFor(identity))
+	}
+}
+
+// ParticipantBandwidthOverride returns false when the participant follows the override of the room
+func (r *Room) ParticipantBandwidthOverride(identity livekit.ParticipantIdentity) (config.BandwidthOverrideConfig, bool) {
+	r.bandwidthOverrideLock.RLock()
+	defer r.bandwidthOverrideLock.RUnlock()
+
+	override, ok := r.participantBandwidth[identity]
+	return override, ok
+}
+
+func (r *Room) bandwidthOverrideFor(identity livekit.ParticipantIdentity) streamallocator.BandwidthOverride {
+	r.bandwidthOverrideLock.RLock()
+	defer r.bandwidthOverrideLock.RUnlock()
+
+	override, ok := r.participantBandwidth[identity]
+	if !ok {
+		override = r.subscriberBandwidth
+	}
+	return streamallocator.BandwidthOverride{
+		MaxBitrate:    int64(override.MaxBitrate),
+		ForcedBitrate: int64(override.ForcedBitrate),
+		Reserved:      r.opusFECHeadroom,
+	}
+}
+
+// SetOpusFEC selects whether publishers joining later are asked for opus in-band FEC
+func (r *Room) SetOpusFEC(enabled bool) {
+	r.bandwidthOverrideLock.Lock()
+	r.opusFECDisabled = !enabled
+	r.bandwidthOverrideLock.Unlock()
+}
+
+// SetLossy flags a room of participants on lossy links. Publishers are always asked for opus in-band FEC and
+// subscribers keep fecHeadroom (bps) free of video for the redundancy of the audio they receive
+func (r *Room) SetLossy(fecHeadroom int64) {
+	if fecHeadroom <= 0 {
+		fecHeadroom = defaultOpusFECHeadroom
+	}
+
+	r.bandwidthOverrideLock.Lock()
+	r.opusFECDisabled = false
+	changed := r.opusFECHeadroom != fecHeadroom
+	r.opusFECHeadroom = fecHeadroom
+	r.bandwidthOverrideLock.Unlock()
+	if !changed {
+		return
+	}
+
+	r.Logger.Infow("forcing opus in-band FEC", "fecHeadroom", fecHeadroom)
+	for _, p := range r.GetParticipants() {
+		p.SetSubscriberBandwidthOverride(r.bandwidthOverrideFor(p.Identity()))
+	}
+}
+
+// SetREDEncoding selects whether audio published from now on is sent to subscribers as RED, generated from opus
+// when publishers do not send RED. Entitlements of subscribers take precedence
+func (r *Room) SetREDEncoding(enabled bool) {
+	if r.redEncoding.Swap(enabled) != enabled {
+		r.Logger.Infow("setting RED encoding toward subscribers", "enabled", enabled)
+	}
+}
+
+func (r *Room) REDEncoding() bool {
+	return r.redEncoding.Load()
+}
+
+func (r *Room) OpusFECEnabled() bool {
+	r.bandwidthOverrideLock.RLock()
+	defer r.bandwidthOverrideLock.RUnlock()
+
+	return !r.opusFECDisabled
+}
+
+// OpusFECHeadroom returns the bitrate subscribers keep free of video for opus in-band FEC, 0 unless the room is lossy
+func (r *Room) OpusFECHeadroom() int64 {
+	r.bandwidthOverrideLock.RLock()
+	defer r.bandwidthOverrideLock.RUnlock()
+
+	return r.opusFECHeadroom
+}
+
+// SetVideoAllocation changes how subscribers split their bandwidth between video tracks.
+// With the speaker preset, video of the active speaker gets the highest feasible layers and others are degraded first.
+func (r *Room) SetVideoAllocation(preset config.VideoAllocationPreset) error {
+	if preset == "" {
+		preset = config.VideoAllocationPresetDefault
+	}
+	if !preset.Valid() {
+		return ErrInvalidVideoAllocation
+	}
+
+	if r.videoAllocation.Swap(string(preset)) != string(preset) {
+		r.Logger.Infow("setting video allocation", "preset", preset)
+	}
+	return nil
+}
+
+func (r *Room) VideoAllocation() config.VideoAllocationPreset {
+	if preset := r.videoAllocation.Load(); preset != "" {
+		return config.VideoAllocationPreset(preset)
+	}
+	return config.VideoAllocationPresetDefault
+}
+
+// SetPausedVideoPlaceholder selects what subscribers receive while a video track is paused by their stream allocator
+// or muted by its publisher, e.g. to show a slowly refreshed key frame instead of an arbitrary frozen frame.
+func (r *Room) SetPausedVideoPlaceholder(placeholder config.PausedVideoPlaceholder) error {
+	if placeholder == "" {
+		placeholder = config.PausedVideoPlaceholderOff
+	}
+	if !placeholder.Valid() {
+		return ErrInvalidPausedVideoPlaceholder
+	}
+
+	if r.pausedVideoPlaceholder.Swap(string(placeholder)) != string(placeholder) {
+		r.Logger.Infow("setting paused video placeholder", "placeholder", placeholder)
+		for _, p := range r.GetParticipants() {
+			p.SetSubscriberPausedVideoPlaceholder(placeholder)
+		}
+	}
+	return nil
+}
+
+func (r *Room) PausedVideoPlaceholder() config.PausedVideoPlaceholder {
+	if placeholder := r.pausedVideoPlaceholder.Load(); placeholder != "" {
+		return config.PausedVideoPlaceholder(placeholder)
+	}
+	return config.PausedVideoPlaceholderOff
+}
+
+// called from the audio update worker, the last speaker stays preferred while nobody is speaking
+func (r *Room) updatePreferredPublisher(activeSpeakers []*livekit.SpeakerInfo) {
+	preferred := livekit.ParticipantID("")
+	if r.VideoAllocation() == config.VideoAllocationPresetSpeaker {
+		preferred = r.preferredSpeaker
+		if len(activeSpeakers) != 0 {
+			preferred = livekit.ParticipantID(activeSpeakers[0].Sid)
+		}
+	}
+	if preferred == "" && r.preferredSpeaker == "" {
+		return
+	}
+	r.preferredSpeaker = preferred
+
+	// applied on every update to cover participants that joined since, allocators ignore unchanged values
+	for _, p := range r.GetParticipants() {
+		if p.ID() == preferred {
+			// not subscribed to own tracks
+			continue
+		}
+		p.SetSubscriberPreferredPublisher(preferred)
+	}
+}
+
+func fairShareBandwidth(capacity int64, demands map[livekit.ParticipantID]int64) map[livekit.ParticipantID]int64 {
+	return FairShareBandwidth(capacity, demands)
+}
+
+// FairShareBandwidth splits capacity between consumers using max-min fairness,
+// consumers needing less than an equal split get their demand and the rest is shared among the others.
+// Capacity left once every demand is met is split equally so that consumers have room to grow.
+func FairShareBandwidth[K ~string](capacity int64, demands map[K]int64) map[K]int64 {
+	if len(demands) == 0 {
+		return nil
+	}
+
+	ids := make([]K, 0, len(demands))
+	for id := range demands {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if demands[ids[i]] == demands[ids[j]] {
+			return ids[i] < ids[j]
+		}
+		return demands[ids[i]] < demands[ids[j]]
+	})
+
+	shares := make(map[K]int64, len(demands))
+	remaining := capacity
+	for i, id := range ids {
+		share := remaining / int64(len(ids)-i)
+		if demand := demands[id]; demand < share {
+			share = demand
+		}
+		shares[id] = share
+		remaining -= share
+	}
+
+	extra := remaining / int64(len(ids))
+	for _, id := range ids {
+		shares[id] += extra
+		if shares[id] <= 0 {
+			// 0 means no limit to the stream allocator
+			shares[id] = 1
+		}
+	}
+	return shares
+}
@@ -0,0 +1,175 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/protocol/livekit"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/rtc/types/typesfakes"
+	"github.com/livekit/livekit-server/pkg/sfu/streamallocator"
+)
+
+func TestFairShareBandwidth(t *testing.T) {
+	t.Run("no consumers", func(t *testing.T) {
+		require.Nil(t, fairShareBandwidth(10_000_000, nil))
+	})
+
+	t.Run("contested", func(t *testing.T) {
+		shares := fairShareBandwidth(10_000_000, map[livekit.ParticipantID]int64{
+			"a": 1_000_000,
+			"b": 5_000_000,
+			"c": 8_000_000,
+		})
+		require.Equal(t, map[livekit.ParticipantID]int64{
+			"a": 1_000_000,
+			"b": 4_500_000,
+			"c": 4_500_000,
+		}, shares)
+	})
+
+	t.Run("spare capacity is shared", func(t *testing.T) {
+		shares := fairShareBandwidth(10_000_000, map[livekit.ParticipantID]int64{
+			"a": 1_000_000,
+			"b": 2_000_000,
+		})
+		require.Equal(t, map[livekit.ParticipantID]int64{
+			"a": 4_500_000,
+			"b": 5_500_000,
+		}, shares)
+	})
+
+	t.Run("never unlimited", func(t *testing.T) {
+		shares := fairShareBandwidth(2, map[livekit.ParticipantID]int64{
+			"a": 0,
+			"b": 5_000_000,
+			"c": 5_000_000,
+		})
+		for _, share := range shares {
+			require.Equal(t, int64(1), share)
+		}
+	})
+}
+
+func TestBandwidthOverride(t *testing.T) {
+	rm := newRoomWithParticipants(t, testRoomOpts{num: 2})
+	defer rm.Close()
+	p0 := rm.GetParticipant("p0").(*typesfakes.FakeLocalParticipant)
+	p1 := rm.GetParticipant("p1").(*typesfakes.FakeLocalParticipant)
+	lastOverride := func(p *typesfakes.FakeLocalParticipant) streamallocator.BandwidthOverride {
+		return p.SetSubscriberBandwidthOverrideArgsForCall(p.SetSubscriberBandwidthOverrideCallCount() - 1)
+	}
+
+	// applied on join
+	require.Equal(t, streamallocator.BandwidthOverride{}, lastOverride(p0))
+
+	rm.SetSubscriberBandwidthOverride(config.BandwidthOverrideConfig{MaxBitrate: 1_000_000})
+	require.Equal(t, streamallocator.BandwidthOverride{MaxBitrate: 1_000_000}, lastOverride(p0))
+	require.Equal(t, streamallocator.BandwidthOverride{MaxBitrate: 1_000_000}, lastOverride(p1))
+
+	rm.SetParticipantBandwidthOverride("p1", &config.BandwidthOverrideConfig{ForcedBitrate: 300_000})
+	require.Equal(t, streamallocator.BandwidthOverride{ForcedBitrate: 300_000}, lastOverride(p1))
+	_, ok := rm.ParticipantBandwidthOverride("p0")
+	require.False(t, ok)
+
+	// participants keep their own override when the room changes
+	rm.SetSubscriberBandwidthOverride(config.BandwidthOverrideConfig{})
+	require.Equal(t, streamallocator.BandwidthOverride{}, lastOverride(p0))
+	require.Equal(t, streamallocator.BandwidthOverride{ForcedBitrate: 300_000}, lastOverride(p1))
+
+	rm.SetParticipantBandwidthOverride("p1", nil)
+	require.Equal(t, streamallocator.BandwidthOverride{}, lastOverride(p1))
+}
+
+func TestLossyRoom(t *testing.T) {
+	rm := newRoomWithParticipants(t, testRoomOpts{num: 1})
+	defer rm.Close()
+	p0 := rm.GetParticipant("p0").(*typesfakes.FakeLocalParticipant)
+	lastOverride := func() streamallocator.BandwidthOverride {
+		return p0.SetSubscriberBandwidthOverrideArgsForCall(p0.SetSubscriberBandwidthOverrideCallCount() - 1)
+	}
+
+	rm.SetOpusFEC(false)
+	require.False(t, rm.OpusFECEnabled())
+
+	rm.SetLossy(0)
+	require.True(t, rm.OpusFECEnabled())
+	require.Equal(t, streamallocator.BandwidthOverride{Reserved: defaultOpusFECHeadroom}, lastOverride())
+
+	// headroom is kept along with overrides
+	rm.SetParticipantBandwidthOverride("p0", &config.BandwidthOverrideConfig{MaxBitrate: 1_000_000})
+	require.Equal(t, streamallocator.BandwidthOverride{MaxBitrate: 1_000_000, Reserved: defaultOpusFECHeadroom}, lastOverride())
+}
+
+func TestSetVideoAllocation(t *testing.T) {
+	testCases := []struct {
+		name     string
+		preset   config.VideoAllocationPreset
+		err      error
+		expected config.VideoAllocationPreset
+	}{
+		{name: "empty is default", preset: "", expected: config.VideoAllocationPresetDefault},
+		{name: "speaker", preset: config.VideoAllocationPresetSpeaker, expected: config.VideoAllocationPresetSpeaker},
+		{name: "invalid keeps current", preset: "loudest", err: ErrInvalidVideoAllocation, expected: config.VideoAllocationPresetSpeaker},
+		{name: "back to default", preset: config.VideoAllocationPresetDefault, expected: config.VideoAllocationPresetDefault},
+	}
+
+	rm := newRoomWithParticipants(t, testRoomOpts{num: 1})
+	defer rm.Close()
+	require.Equal(t, config.VideoAllocationPresetDefault, rm.VideoAllocation())
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.ErrorIs(t, rm.SetVideoAllocation(tc.preset), tc.err)
+			require.Equal(t, tc.expected, rm.VideoAllocation())
+		})
+	}
+}
+
+func TestPreferredPublisher(t *testing.T) {
+	rm := newRoomWithParticipants(t, testRoomOpts{num: 2, protocol: 3})
+	defer rm.Close()
+	speaker := rm.GetParticipant("p0").(*typesfakes.FakeLocalParticipant)
+	listener := rm.GetParticipant("p1").(*typesfakes.FakeLocalParticipant)
+	lastPreferred := func() livekit.ParticipantID {
+		if listener.SetSubscriberPreferredPublisherCallCount() == 0 {
+			return ""
+		}
+		return listener.SetSubscriberPreferredPublisherArgsForCall(listener.SetSubscriberPreferredPublisherCallCount() - 1)
+	}
+	speaker.GetAudioLevelReturns(20, true)
+
+	// the default preset does not prefer speakers
+	time.Sleep(3 * audioUpdateInterval * time.Millisecond)
+	require.Empty(t, lastPreferred())
+
+	require.NoError(t, rm.SetVideoAllocation(config.VideoAllocationPresetSpeaker))
+	require.Eventually(t, func() bool { return lastPreferred() == speaker.ID() }, time.Second, 10*time.Millisecond)
+	// speakers are not subscribed to their own tracks
+	require.Zero(t, speaker.SetSubscriberPreferredPublisherCallCount())
+
+	// the last speaker stays preferred once nobody speaks
+	speaker.GetAudioLevelReturns(127, false)
+	time.Sleep(3 * audioUpdateInterval * time.Millisecond)
+	require.Equal(t, speaker.ID(), lastPreferred())
+
+	// cleared when switching back
+	require.NoError(t, rm.SetVideoAllocation(config.VideoAllocationPresetDefault))
+	require.Eventually(t, func() bool { return lastPreferred() == "" }, time.Second, 10*time.Millisecond)
+}
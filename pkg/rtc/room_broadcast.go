@@ -0,0 +1,86 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtc
+
+import (
+	"github.com/livekit/livekit-server/pkg/rtc/types"
+)
+
+const (
+	defaultLoadBalanceThreshold     = 20
+	defaultBroadcastFanOutThreshold = 4
+)
+
+// SetBroadcast switches the room to broadcast mode, for rooms with a few publishers and a large audience.
+// Viewers, participants that cannot publish, are left out of the participant updates others receive so
+// signaling doesn't grow with the square of the audience. At most maxPublishers participants that can publish
+// may join, 0 is unlimited. Packets of tracks published from now on are spread over worker shards once they
+// have more than fanOutThreshold subscribers. All subscribers are served by this node, media of a room is never
+// relayed through other nodes
+func (r *Room) SetBroadcast(maxPublishers int, fanOutThreshold int) {
+	r.lock.Lock()
+	r.broadcast = true
+	r.maxPublishers = maxPublishers
+	r.fanOutThreshold = fanOutThreshold
+	r.lock.Unlock()
+}
+
+func (r *Room) IsBroadcast() bool {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return r.broadcast
+}
+
+// LoadBalanceThreshold returns the number of subscribers of a track above which its packets are written in parallel
+func (r *Room) LoadBalanceThreshold() int {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	if !r.broadcast {
+		return defaultLoadBalanceThreshold
+	}
+	if r.fanOutThreshold > 0 {
+		return r.fanOutThreshold
+	}
+	return defaultBroadcastFanOutThreshold
+}
+
+// isViewerLocked returns true for participants of broadcast rooms that cannot publish
+func (r *Room) isViewerLocked(p types.LocalParticipant) bool {
+	return r.broadcast && !canPublish(p)
+}
+
+func (r *Room) isViewer(p types.LocalParticipant) bool {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return r.isViewerLocked(p)
+}
+
+func (r *Room) publishersLimitReachedLocked(p types.LocalParticipant) bool {
+	if !r.broadcast || r.maxPublishers <= 0 || !canPublish(p) {
+		return false
+	}
+	numPublishers := 0
+	for _, op := range r.participants {
+		if canPublish(op) {
+			numPublishers++
+		}
+	}
+	return numPublishers >= r.maxPublishers
+}
+
+func canPublish(p types.LocalParticipant) bool {
+	grants := p.ClaimGrants()
+	return grants != nil && grants.Video != nil && grants.Video.GetCanPublish()
+}
@@ -0,0 +1,112 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/protocol/auth"
+	"github.com/livekit/protocol/livekit"
+
+	"github.com/livekit/livekit-server/pkg/rtc/types"
+	"github.com/livekit/livekit-server/pkg/rtc/types/typesfakes"
+)
+
+func newBroadcastParticipant(identity livekit.ParticipantIdentity, canPublish bool) *typesfakes.FakeLocalParticipant {
+	p := newMockParticipant(identity, types.CurrentProtocol, false, canPublish)
+	p.ClaimGrantsReturns(&auth.ClaimGrants{Video: &auth.VideoGrant{CanPublish: &canPublish}})
+	return p
+}
+
+func TestBroadcastLoadBalanceThreshold(t *testing.T) {
+	testCases := []struct {
+		name            string
+		broadcast       bool
+		fanOutThreshold int
+		expected        int
+	}{
+		{"regular room", false, 0, defaultLoadBalanceThreshold},
+		{"broadcast default", true, 0, defaultBroadcastFanOutThreshold},
+		{"broadcast configured", true, 8, 8},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rm := newRoomWithParticipants(t, testRoomOpts{num: 0})
+			defer rm.Close()
+			if tc.broadcast {
+				rm.SetBroadcast(0, tc.fanOutThreshold)
+			}
+			require.Equal(t, tc.broadcast, rm.IsBroadcast())
+			require.Equal(t, tc.expected, rm.LoadBalanceThreshold())
+		})
+	}
+}
+
+func TestBroadcastViewers(t *testing.T) {
+	rm := newRoomWithParticipants(t, testRoomOpts{num: 0})
+	defer rm.Close()
+	rm.SetBroadcast(0, 0)
+
+	publisher := newBroadcastParticipant("publisher", true)
+	require.NoError(t, rm.Join(publisher, nil, nil, iceServersForRoom))
+	viewer := newBroadcastParticipant("viewer", false)
+	require.NoError(t, rm.Join(viewer, nil, nil, iceServersForRoom))
+	late := newBroadcastParticipant("late", true)
+	require.NoError(t, rm.Join(late, nil, nil, iceServersForRoom))
+
+	otherIdentities := func(p *typesfakes.FakeLocalParticipant) []string {
+		var identities []string
+		for _, pi := range p.SendJoinResponseArgsForCall(0).OtherParticipants {
+			identities = append(identities, pi.Identity)
+		}
+		return identities
+	}
+	// viewers see the publishers, nobody sees the viewers
+	require.Equal(t, []string{"publisher"}, otherIdentities(viewer))
+	require.Equal(t, []string{"publisher"}, otherIdentities(late))
+	for _, pi := range rm.getOtherParticipantInfo("publisher") {
+		require.NotEqual(t, "viewer", pi.Identity)
+	}
+
+	require.True(t, rm.isViewer(viewer))
+	require.False(t, rm.isViewer(publisher))
+}
+
+func TestBroadcastMaxPublishers(t *testing.T) {
+	rm := newRoomWithParticipants(t, testRoomOpts{num: 0})
+	defer rm.Close()
+	rm.SetBroadcast(1, 0)
+
+	testCases := []struct {
+		identity   livekit.ParticipantIdentity
+		canPublish bool
+		expected   error
+	}{
+		{"publisher", true, nil},
+		{"second publisher", true, ErrMaxPublishersExceeded},
+		{"viewer", false, nil},
+		{"another viewer", false, nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(string(tc.identity), func(t *testing.T) {
+			err := rm.Join(newBroadcastParticipant(tc.identity, tc.canPublish), nil, nil, iceServersForRoom)
+			require.ErrorIs(t, err, tc.expected)
+		})
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtc
+
+import (
+	"context"
+
+	"github.com/livekit/protocol/livekit"
+)
+
+// sent when the last participant left, the room is closed and room_finished sent once the departure timeout passes
+const EventRoomEmpty = "room_empty"
+
+// SetDepartureTimeout sets how many seconds the room lingers after the last participant left before it's closed,
+// 0 restores the default
+func (r *Room) SetDepartureTimeout(seconds uint32) {
+	if seconds == 0 {
+		seconds = RoomDepartureGrace
+	}
+	if r.departureTimeout.Swap(seconds) != seconds {
+		r.Logger.Infow("setting departure timeout", "seconds", seconds)
+	}
+}
+
+func (r *Room) DepartureTimeout() uint32 {
+	return r.departureTimeout.Load()
+}
+
+func (r *Room) isEmpty() bool {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	for _, p := range r.participants {
+		if !p.IsRecorder() {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *Room) notifyRoomEmpty() {
+	if r.IsClosed() || r.telemetry == nil {
+		return
+	}
+
+	r.Logger.Infow("room empty, waiting for participants to return", "departureTimeout", r.DepartureTimeout())
+	r.telemetry.NotifyEvent(context.Background(), &livekit.WebhookEvent{
+		Event: EventRoomEmpty,
+		Room:  r.ToProto(),
+	})
+}
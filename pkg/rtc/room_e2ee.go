@@ -0,0 +1,329 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtc
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"sort"
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/rtc/types"
+	"github.com/livekit/protocol/auth"
+	"github.com/livekit/protocol/livekit"
+)
+
+const (
+	// topic of the data packets carrying keys to participants of end-to-end encrypted rooms
+	E2EEKeyTopic = "lk.e2ee_key"
+	// topic of the data packets participants send to ask for their keys to be rotated
+	E2EERotateTopic = "lk.e2ee_rotate"
+
+	// key indexes wrap around the key ring kept by clients, so that media encrypted with
+	// the previous keys can still be decrypted while a new key is rolled out
+	e2eeKeyRingSize = 16
+	e2eeKeySize     = 32
+)
+
+var e2eeRotateGrants = map[string]func(*auth.VideoGrant) bool{
+	"room_admin":       func(g *auth.VideoGrant) bool { return g.RoomAdmin },
+	"can_publish":      func(g *auth.VideoGrant) bool { return g.GetCanPublish() },
+	"can_publish_data": func(g *auth.VideoGrant) bool { return g.GetCanPublishData() },
+}
+
+// E2EEKey is the payload of data packets with topic E2EEKeyTopic. Per participant keys carry the identity
+// of the participant encrypting with them, the shared key of a room has none.
+type E2EEKey struct {
+	ParticipantIdentity livekit.ParticipantIdentity `json:"participant_identity,omitempty"`
+	KeyIndex            uint8                       `json:"key_index"`
+	Key                 []byte                      `json:"key"`
+}
+
+// e2eeKeyProvider holds the current keys of a room, indexed by participant identity, empty for the shared key
+type e2eeKeyProvider struct {
+	conf config.E2EEConfig
+
+	lock sync.Mutex
+	keys map[livekit.ParticipantIdentity]*E2EEKey
+}
+
+func newE2EEKeyProvider(conf config.E2EEConfig) *e2eeKeyProvider {
+	return &e2eeKeyProvider{
+		conf: conf,
+		keys: make(map[livekit.ParticipantIdentity]*E2EEKey),
+	}
+}
+
+func (k *e2eeKeyProvider) perParticipant() bool {
+	return k.conf.KeyMode == config.E2EEKeyModePerParticipant
+}
+
+// keyIdentity maps a participant to the key it encrypts with
+func (k *e2eeKeyProvider) keyIdentity(identity livekit.ParticipantIdentity) livekit.ParticipantIdentity {
+	if k.perParticipant() {
+		return identity
+	}
+	return ""
+}
+
+func (k *e2eeKeyProvider) canRotate(grants *auth.ClaimGrants) bool {
+	if grants == nil || grants.Video == nil {
+		return false
+	}
+	for _, name := range k.conf.RotateGrants {
+		if has, ok := e2eeRotateGrants[name]; ok && has(grants.Video) {
+			return true
+		}
+	}
+	return false
+}
+
+// ensure returns the key of a participant, creating it if needed
+func (k *e2eeKeyProvider) ensure(identity livekit.ParticipantIdentity) (*E2EEKey, bool, error) {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+
+	if key := k.keys[identity]; key != nil {
+		return key, false, nil
+	}
+	key, err := k.newKeyLocked(identity)
+	return key, err == nil, err
+}
+
+// rotate replaces keys with new ones at the next index. An empty identity rotates every key
+func (k *e2eeKeyProvider) rotate(identity livekit.ParticipantIdentity) ([]*E2EEKey, error) {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+
+	var identities []livekit.ParticipantIdentity
+	if identity == "" {
+		for id := range k.keys {
+			identities = append(identities, id)
+		}
+		if len(identities) == 0 && !k.perParticipant() {
+			identities = append(identities, "")
+		}
+	} else {
+		identities = append(identities, identity)
+	}
+
+	keys := make([]*E2EEKey, 0, len(identities))
+	for _, id := range identities {
+		key, err := k.newKeyLocked(id)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func (k *e2eeKeyProvider) newKeyLocked(identity livekit.ParticipantIdentity) (*E2EEKey, error) {
+	key := &E2EEKey{
+		ParticipantIdentity: identity,
+		Key:                 make([]byte, e2eeKeySize),
+	}
+	if _, err := rand.Read(key.Key); err != nil {
+		return nil, err
+	}
+	if prev := k.keys[identity]; prev != nil {
+		key.KeyIndex = (prev.KeyIndex + 1) % e2eeKeyRingSize
+	}
+	k.keys[identity] = key
+	return key, nil
+}
+
+func (k *e2eeKeyProvider) remove(identity livekit.ParticipantIdentity) {
+	k.lock.Lock()
+	delete(k.keys, identity)
+	k.lock.Unlock()
+}
+
+// all returns the current keys sorted by identity
+func (k *e2eeKeyProvider) all() []*E2EEKey {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+
+	keys := make([]*E2EEKey, 0, len(k.keys))
+	for _, key := range k.keys {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].ParticipantIdentity < keys[j].ParticipantIdentity
+	})
+	return keys
+}
+
+// ------------------------------------------------
+
+// SetE2EE makes the server the key provider of the room. Keys are sent to participants once they are
+// connected and when they are rotated, media is forwarded without being decrypted
+func (r *Room) SetE2EE(conf config.E2EEConfig) {
+	if !conf.Enabled {
+		return
+	}
+
+	r.lock.Lock()
+	r.e2ee = newE2EEKeyProvider(conf)
+	r.lock.Unlock()
+}
+
+func (r *Room) getE2EE() *e2eeKeyProvider {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	return r.e2ee
+}
+
+// E2EEKeyIndexes returns the current index of every key of the room, keyed by the participant
+// encrypting with it, empty for the shared key. nil if the server does not manage keys of the room
+func (r *Room) E2EEKeyIndexes() map[livekit.ParticipantIdentity]uint8 {
+	e2ee := r.getE2EE()
+	if e2ee == nil {
+		return nil
+	}
+
+	indexes := make(map[livekit.ParticipantIdentity]uint8)
+	for _, key := range e2ee.all() {
+		indexes[key.ParticipantIdentity] = key.KeyIndex
+	}
+	return indexes
+}
+
+// RotateE2EEKey replaces keys of the room with new ones and sends them to participants. With per participant keys,
+// identity selects the key of a participant, empty rotates every key
+func (r *Room) RotateE2EEKey(identity livekit.ParticipantIdentity) error {
+	e2ee := r.getE2EE()
+	if e2ee == nil {
+		return ErrE2EENotEnabled
+	}
+
+	keys, err := e2ee.rotate(e2ee.keyIdentity(identity))
+	if err != nil {
+		return err
+	}
+	r.Logger.Infow("rotated e2ee keys", "participant", identity, "count", len(keys))
+	r.broadcastE2EEKeys(keys, nil)
+	return nil
+}
+
+// called once a participant is active, it gets all keys of the room. A participant with a key of its own
+// is announced to the others
+func (r *Room) sendE2EEKeys(p types.LocalParticipant) {
+	e2ee := r.getE2EE()
+	if e2ee == nil {
+		return
+	}
+
+	key, created, err := e2ee.ensure(e2ee.keyIdentity(p.Identity()))
+	if err != nil {
+		r.Logger.Errorw("could not create e2ee key", err, "participant", p.Identity())
+		return
+	}
+	if created && key.ParticipantIdentity != "" {
+		r.broadcastE2EEKeys([]*E2EEKey{key}, p)
+	}
+	r.sendE2EEKeysTo(p, e2ee.all())
+}
+
+func (r *Room) onE2EEParticipantLeft(p types.LocalParticipant, reason types.ParticipantCloseReason) {
+	e2ee := r.getE2EE()
+	if e2ee == nil {
+		return
+	}
+
+	if e2ee.perParticipant() {
+		e2ee.remove(p.Identity())
+	}
+	// participants moving between sessions or nodes keep knowing the keys
+	if !e2ee.conf.RotateOnLeave || reason.ToDisconnectReason() == livekit.DisconnectReason_DUPLICATE_IDENTITY || r.isEmpty() {
+		return
+	}
+	if err := r.RotateE2EEKey(""); err != nil {
+		r.Logger.Warnw("could not rotate e2ee keys", err)
+	}
+}
+
+// handleE2EEPacket takes care of data packets of participants on the key management topics,
+// returns true if the packet must not be forwarded
+func (r *Room) handleE2EEPacket(source types.LocalParticipant, dp *livekit.DataPacket) bool {
+	topic := dp.GetUser().GetTopic()
+	if topic != E2EEKeyTopic && topic != E2EERotateTopic {
+		return false
+	}
+	e2ee := r.getE2EE()
+	if e2ee == nil {
+		return false
+	}
+
+	if topic == E2EEKeyTopic {
+		// keys only come from the server
+		source.GetLogger().Warnw("dropping e2ee key sent by participant", nil)
+		return true
+	}
+	if !e2ee.canRotate(source.ClaimGrants()) {
+		source.GetLogger().Infow("participant is not allowed to rotate e2ee keys")
+		return true
+	}
+	if err := r.RotateE2EEKey(source.Identity()); err != nil {
+		source.GetLogger().Warnw("could not rotate e2ee keys", err)
+	}
+	return true
+}
+
+// broadcastE2EEKeys sends keys to all active participants but skip
+func (r *Room) broadcastE2EEKeys(keys []*E2EEKey, skip types.LocalParticipant) {
+	for _, p := range r.GetParticipants() {
+		if p.State() != livekit.ParticipantInfo_ACTIVE || (skip != nil && p.ID() == skip.ID()) {
+			continue
+		}
+		r.sendE2EEKeysTo(p, keys)
+	}
+}
+
+func (r *Room) sendE2EEKeysTo(p types.LocalParticipant, keys []*E2EEKey) {
+	if !p.ProtocolVersion().HandlesDataPackets() {
+		return
+	}
+
+	topic := E2EEKeyTopic
+	for _, key := range keys {
+		payload, err := json.Marshal(key)
+		if err != nil {
+			r.Logger.Errorw("failed to marshal e2ee key", err)
+			return
+		}
+		dp := &livekit.DataPacket{
+			Kind: livekit.DataPacket_RELIABLE,
+			Value: &livekit.DataPacket_User{
+				User: &livekit.UserPacket{
+					Payload: payload,
+					Topic:   &topic,
+				},
+			},
+		}
+		data, err := proto.Marshal(dp)
+		if err != nil {
+			r.Logger.Errorw("failed to marshal e2ee key data packet", err)
+			return
+		}
+		if err = p.SendDataPacket(dp, data); err != nil {
+			p.GetLogger().Warnw("could not send e2ee key", err)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/protocol/auth"
+	"github.com/livekit/protocol/livekit"
+)
+
+func TestE2EEKeyProvider(t *testing.T) {
+	t.Run("shared", func(t *testing.T) {
+		k := newE2EEKeyProvider(config.E2EEConfig{Enabled: true})
+		require.Equal(t, livekit.ParticipantIdentity(""), k.keyIdentity("alice"))
+
+		key, created, err := k.ensure(k.keyIdentity("alice"))
+		require.NoError(t, err)
+		require.True(t, created)
+		require.Len(t, key.Key, e2eeKeySize)
+		require.Equal(t, uint8(0), key.KeyIndex)
+
+		same, created, err := k.ensure(k.keyIdentity("bob"))
+		require.NoError(t, err)
+		require.False(t, created)
+		require.Equal(t, key, same)
+
+		// index wraps around the key ring
+		for i := 1; i <= e2eeKeyRingSize; i++ {
+			keys, err := k.rotate("")
+			require.NoError(t, err)
+			require.Len(t, keys, 1)
+			require.Equal(t, uint8(i%e2eeKeyRingSize), keys[0].KeyIndex)
+			require.NotEqual(t, key.Key, keys[0].Key)
+		}
+		require.Len(t, k.all(), 1)
+	})
+
+	t.Run("per participant", func(t *testing.T) {
+		k := newE2EEKeyProvider(config.E2EEConfig{Enabled: true, KeyMode: config.E2EEKeyModePerParticipant})
+
+		// nothing to rotate yet
+		keys, err := k.rotate("")
+		require.NoError(t, err)
+		require.Empty(t, keys)
+
+		_, _, err = k.ensure(k.keyIdentity("bob"))
+		require.NoError(t, err)
+		_, _, err = k.ensure(k.keyIdentity("alice"))
+		require.NoError(t, err)
+		all := k.all()
+		require.Len(t, all, 2)
+		require.Equal(t, livekit.ParticipantIdentity("alice"), all[0].ParticipantIdentity)
+		require.Equal(t, livekit.ParticipantIdentity("bob"), all[1].ParticipantIdentity)
+
+		keys, err = k.rotate("bob")
+		require.NoError(t, err)
+		require.Len(t, keys, 1)
+		require.Equal(t, livekit.ParticipantIdentity("bob"), keys[0].ParticipantIdentity)
+		require.Equal(t, uint8(1), keys[0].KeyIndex)
+
+		keys, err = k.rotate("")
+		require.NoError(t, err)
+		require.Len(t, keys, 2)
+
+		k.remove("bob")
+		require.Len(t, k.all(), 1)
+	})
+
+	t.Run("rotate grants", func(t *testing.T) {
+		k := newE2EEKeyProvider(config.E2EEConfig{Enabled: true, RotateGrants: []string{"room_admin"}})
+		require.False(t, k.canRotate(nil))
+		require.False(t, k.canRotate(&auth.ClaimGrants{Video: &auth.VideoGrant{RoomJoin: true}}))
+		require.True(t, k.canRotate(&auth.ClaimGrants{Video: &auth.VideoGrant{RoomJoin: true, RoomAdmin: true}}))
+
+		k = newE2EEKeyProvider(config.E2EEConfig{Enabled: true})
+		require.False(t, k.canRotate(&auth.ClaimGrants{Video: &auth.VideoGrant{RoomAdmin: true}}))
+	})
+}
@@ -0,0 +1,45 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtc
+
+import (
+	"github.com/livekit/livekit-server/pkg/telemetry"
+)
+
+// EnableQoSReport collects the network stats of participants for a QoS report of the room, see QoSReport
+func (r *Room) EnableQoSReport() {
+	if r.qosStats.Load() == nil {
+		r.qosStats.Store(NewQoSStats())
+	}
+}
+
+// QoSTelemetry returns t recording the stats of participant tracks into the QoS stats of the room, t itself when
+// the room has no QoS report
+func (r *Room) QoSTelemetry(t telemetry.TelemetryService) telemetry.TelemetryService {
+	stats := r.qosStats.Load()
+	if stats == nil {
+		return t
+	}
+	return &qosTelemetry{TelemetryService: t, stats: stats}
+}
+
+// QoSReport returns the network quality of the participant sessions of the room, nil when it has no QoS report
+func (r *Room) QoSReport() []QoSParticipantReport {
+	stats := r.qosStats.Load()
+	if stats == nil {
+		return nil
+	}
+	return stats.Report()
+}
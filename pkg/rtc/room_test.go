@@ -375,6 +375,30 @@ func TestRoomClosure(t *testing.T) {
 		require.Equal(t, ErrRoomClosed, rm.Join(p, nil, nil, iceServersForRoom))
 	})
 
+	t.Run("room lingers for its departure timeout", func(t *testing.T) {
+		rm := newRoomWithParticipants(t, testRoomOpts{num: 1})
+		isClosed := false
+		rm.OnClose(func() {
+			isClosed = true
+		})
+		rm.SetDepartureTimeout(60)
+		require.EqualValues(t, 60, rm.DepartureTimeout())
+
+		p := rm.GetParticipants()[0]
+		rm.RemoveParticipant(p.Identity(), p.ID(), types.ParticipantCloseReasonClientRequestLeave)
+
+		time.Sleep(time.Duration(RoomDepartureGrace)*time.Second + defaultDelay)
+
+		rm.CloseIfEmpty()
+		require.False(t, isClosed)
+
+		// back to default
+		rm.SetDepartureTimeout(0)
+		require.Equal(t, RoomDepartureGrace, rm.DepartureTimeout())
+		rm.CloseIfEmpty()
+		require.True(t, isClosed)
+	})
+
 	t.Run("room does not close before empty timeout", func(t *testing.T) {
 		rm := newRoomWithParticipants(t, testRoomOpts{num: 0})
 		isClosed := false
@@ -715,6 +739,58 @@ func TestRoomUpdate(t *testing.T) {
 	})
 }
 
+func TestUpdateThrottle(t *testing.T) {
+	rm := newRoomWithParticipants(t, testRoomOpts{num: 3})
+	defer rm.Close()
+
+	// no throttle by default
+	require.Equal(t, time.Second, rm.throttledUpdateInterval(time.Second))
+
+	rm.SetUpdateThrottle([]config.UpdateThrottleStep{
+		{MinParticipants: 10, IntervalMultiplier: 4},
+		{MinParticipants: 2, IntervalMultiplier: 1.5},
+	})
+	require.Equal(t, 1500*time.Millisecond, rm.throttledUpdateInterval(time.Second))
+
+	rm.SetUpdateThrottle([]config.UpdateThrottleStep{
+		{MinParticipants: 2, IntervalMultiplier: 1.5},
+		{MinParticipants: 3, IntervalMultiplier: 3},
+	})
+	require.Equal(t, 3*time.Second, rm.throttledUpdateInterval(time.Second))
+
+	rm.SetUpdateThrottle([]config.UpdateThrottleStep{
+		{MinParticipants: 10, IntervalMultiplier: 4},
+	})
+	require.Equal(t, time.Second, rm.throttledUpdateInterval(time.Second))
+}
+
+func TestClientPing(t *testing.T) {
+	testCases := []struct {
+		name     string
+		interval time.Duration
+		timeout  time.Duration
+		expected [2]int32
+	}{
+		{name: "defaults", expected: [2]int32{10, 20}},
+		{name: "configured", interval: 5 * time.Second, timeout: 15 * time.Second, expected: [2]int32{5, 15}},
+		{name: "rounded down to seconds", interval: 2500 * time.Millisecond, timeout: 7900 * time.Millisecond, expected: [2]int32{2, 7}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rm := newRoomWithParticipants(t, testRoomOpts{num: 1})
+			defer rm.Close()
+			if tc.interval != 0 {
+				rm.SetClientPing(tc.interval, tc.timeout)
+			}
+
+			pNew := newMockParticipant("new", types.CurrentProtocol, false, false)
+			require.NoError(t, rm.Join(pNew, nil, nil, iceServersForRoom))
+			res := pNew.SendJoinResponseArgsForCall(0)
+			require.Equal(t, tc.expected, [2]int32{res.PingInterval, res.PingTimeout})
+		})
+	}
+}
+
 type testRoomOpts struct {
 	num                  int
 	numHidden            int
@@ -0,0 +1,54 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtc
+
+import (
+	"sort"
+	"time"
+
+	"github.com/livekit/livekit-server/pkg/config"
+)
+
+// SetUpdateThrottle sets the curve used to slow down active speaker and connection quality updates as the room grows.
+// Participant joins and leaves are not throttled.
+func (r *Room) SetUpdateThrottle(steps []config.UpdateThrottleStep) {
+	sorted := make([]config.UpdateThrottleStep, len(steps))
+	copy(sorted, steps)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].MinParticipants < sorted[j].MinParticipants
+	})
+
+	r.lock.Lock()
+	r.updateThrottle = sorted
+	r.lock.Unlock()
+}
+
+func (r *Room) throttledUpdateInterval(interval time.Duration) time.Duration {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	multiplier := 1.0
+	numParticipants := len(r.participants)
+	for _, step := range r.updateThrottle {
+		if numParticipants < step.MinParticipants {
+			break
+		}
+		multiplier = step.IntervalMultiplier
+	}
+	if multiplier <= 1 {
+		return interval
+	}
+	return time.Duration(float64(interval) * multiplier)
+}
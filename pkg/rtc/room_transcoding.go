@@ -0,0 +1,40 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtc
+
+import (
+	"github.com/livekit/livekit-server/pkg/transcode"
+)
+
+const defaultTranscodeBitrate = 600_000
+
+// SetTranscoding has video published from now on transcoded to h264 for subscribers that cannot decode its codec,
+// e.g. AV1 published to a room with H.264-only subscribers. A single rendition of bitrate (bps) is encoded from the
+// lowest layer of a track once a subscriber needs it. Transcoding costs CPU and is only enabled by rooms asking for it
+func (r *Room) SetTranscoding(bitrate int64) {
+	if !transcode.Supported {
+		r.Logger.Warnw("could not enable transcoding", transcode.ErrUnavailable)
+		return
+	}
+	if bitrate <= 0 {
+		bitrate = defaultTranscodeBitrate
+	}
+	r.transcodeBitrate.Store(bitrate)
+}
+
+// TranscodeBitrate returns the bitrate of transcoded renditions, 0 when the room does not transcode
+func (r *Room) TranscodeBitrate() int64 {
+	return r.transcodeBitrate.Load()
+}
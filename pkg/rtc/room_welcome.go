@@ -0,0 +1,54 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtc
+
+import (
+	"google.golang.org/protobuf/proto"
+
+	"github.com/livekit/livekit-server/pkg/rtc/types"
+	"github.com/livekit/protocol/livekit"
+)
+
+// SetWelcomePacket sets a reliable data packet sent to each participant once its connection is established,
+// e.g. settings that clients need before anything goes wrong. nil stops sending one
+func (r *Room) SetWelcomePacket(up *livekit.UserPacket) {
+	r.lock.Lock()
+	r.welcomePacket = up
+	r.lock.Unlock()
+}
+
+func (r *Room) sendWelcomePacket(p types.LocalParticipant) {
+	r.lock.RLock()
+	up := r.welcomePacket
+	r.lock.RUnlock()
+	if up == nil || !p.ProtocolVersion().HandlesDataPackets() {
+		return
+	}
+
+	dp := &livekit.DataPacket{
+		Kind: livekit.DataPacket_RELIABLE,
+		Value: &livekit.DataPacket_User{
+			User: up,
+		},
+	}
+	data, err := proto.Marshal(dp)
+	if err != nil {
+		r.Logger.Errorw("failed to marshal welcome data packet", err)
+		return
+	}
+	if err = p.SendDataPacket(dp, data); err != nil {
+		p.GetLogger().Warnw("could not send welcome data packet", err)
+	}
+}
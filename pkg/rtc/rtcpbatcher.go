@@ -0,0 +1,107 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtc
+
+import (
+	"time"
+
+	"github.com/pion/rtcp"
+)
+
+const (
+	rtcpBatchMaxHold    = 500 * time.Millisecond
+	rtcpBatchMaxPackets = 16
+)
+
+// rtcpBatcher shapes RTCP headed to a publisher. Periodic reports are held when batching,
+// and sent along with the next time sensitive packet (transport-cc, NACK, PLI, ...),
+// or after rtcpBatchMaxHold at the latest. Unless reduced size RTCP is used,
+// every write is made a compound packet led by a receiver report.
+type rtcpBatcher struct {
+	ssrc        uint32
+	reducedSize bool
+	batch       bool
+
+	pending      []rtcp.Packet
+	pendingSince time.Time
+}
+
+func newRTCPBatcher(ssrc uint32, reducedSize bool, batch bool) *rtcpBatcher {
+	return &rtcpBatcher{
+		ssrc:        ssrc,
+		reducedSize: reducedSize,
+		batch:       batch,
+	}
+}
+
+// add returns the packets to write now, nil if they are held
+func (b *rtcpBatcher) add(pkts []rtcp.Packet, now time.Time) []rtcp.Packet {
+	if !b.batch {
+		return b.compound(pkts)
+	}
+
+	deferrable := true
+	for _, pkt := range pkts {
+		switch pkt.(type) {
+		case *rtcp.ReceiverReport, *rtcp.ReceiverEstimatedMaximumBitrate:
+		default:
+			deferrable = false
+		}
+	}
+
+	if len(b.pending) == 0 {
+		b.pendingSince = now
+	}
+	b.pending = append(b.pending, pkts...)
+	if deferrable && len(b.pending) < rtcpBatchMaxPackets {
+		return nil
+	}
+
+	return b.take()
+}
+
+// flush returns held packets once they have waited long enough
+func (b *rtcpBatcher) flush(now time.Time) []rtcp.Packet {
+	if len(b.pending) == 0 || now.Sub(b.pendingSince) < rtcpBatchMaxHold {
+		return nil
+	}
+
+	return b.take()
+}
+
+func (b *rtcpBatcher) take() []rtcp.Packet {
+	pkts := b.compound(b.pending)
+	b.pending = nil
+	return pkts
+}
+
+func (b *rtcpBatcher) compound(pkts []rtcp.Packet) []rtcp.Packet {
+	if b.reducedSize || len(pkts) == 0 {
+		return pkts
+	}
+
+	// receiver reports lead, merged into one when possible
+	out := make([]rtcp.Packet, 0, len(pkts)+1)
+	rr := &rtcp.ReceiverReport{SSRC: b.ssrc}
+	out = append(out, rr)
+	for _, pkt := range pkts {
+		if r, ok := pkt.(*rtcp.ReceiverReport); ok && len(r.ProfileExtensions) == 0 && len(rr.Reports)+len(r.Reports) <= 31 {
+			rr.Reports = append(rr.Reports, r.Reports...)
+			continue
+		}
+		out = append(out, pkt)
+	}
+	return out
+}
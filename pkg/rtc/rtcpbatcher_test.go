@@ -0,0 +1,72 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pion/rtcp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRTCPBatcher(t *testing.T) {
+	rr := func(ssrc uint32) *rtcp.ReceiverReport {
+		return &rtcp.ReceiverReport{SSRC: ssrc, Reports: []rtcp.ReceptionReport{{SSRC: ssrc}}}
+	}
+	pli := &rtcp.PictureLossIndication{MediaSSRC: 3}
+
+	t.Run("pass through", func(t *testing.T) {
+		b := newRTCPBatcher(1, true, false)
+		pkts := []rtcp.Packet{pli}
+		require.Equal(t, pkts, b.add(pkts, time.Now()))
+	})
+
+	t.Run("compound", func(t *testing.T) {
+		b := newRTCPBatcher(1, false, false)
+		pkts := b.add([]rtcp.Packet{pli}, time.Now())
+		require.Len(t, pkts, 2)
+		require.Equal(t, &rtcp.ReceiverReport{SSRC: 1}, pkts[0])
+		require.Equal(t, pli, pkts[1])
+
+		pkts = b.add([]rtcp.Packet{rr(10)}, time.Now())
+		require.Len(t, pkts, 1)
+		require.Equal(t, uint32(1), pkts[0].(*rtcp.ReceiverReport).SSRC)
+		require.Equal(t, uint32(10), pkts[0].(*rtcp.ReceiverReport).Reports[0].SSRC)
+	})
+
+	t.Run("batch", func(t *testing.T) {
+		now := time.Now()
+		b := newRTCPBatcher(1, false, true)
+		require.Nil(t, b.add([]rtcp.Packet{rr(10)}, now))
+		require.Nil(t, b.add([]rtcp.Packet{rr(11)}, now))
+		require.Nil(t, b.add([]rtcp.Packet{&rtcp.ReceiverEstimatedMaximumBitrate{Bitrate: 1000}}, now))
+		require.Nil(t, b.flush(now.Add(rtcpBatchMaxHold/2)))
+
+		// reports for both streams merged, REMB and PLI follow
+		pkts := b.add([]rtcp.Packet{pli}, now)
+		require.Len(t, pkts, 3)
+		require.Len(t, pkts[0].(*rtcp.ReceiverReport).Reports, 2)
+		require.IsType(t, &rtcp.ReceiverEstimatedMaximumBitrate{}, pkts[1])
+		require.Equal(t, pli, pkts[2])
+
+		// nothing held anymore
+		require.Nil(t, b.flush(now.Add(time.Hour)))
+
+		// held packets go out at the latest after max hold
+		require.Nil(t, b.add([]rtcp.Packet{rr(10)}, now))
+		require.Len(t, b.flush(now.Add(rtcpBatchMaxHold)), 1)
+	})
+}
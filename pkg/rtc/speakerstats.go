@@ -0,0 +1,123 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtc
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/livekit/protocol/livekit"
+)
+
+// longest gap between audio level updates counted as speaking time, so a stalled worker doesn't inflate it
+const maxSpeakerStatsInterval = 2 * time.Second
+
+// SpeakerStat summarizes how a participant spoke in the room, across all of its sessions
+type SpeakerStat struct {
+	Identity livekit.ParticipantIdentity `json:"identity"`
+	TalkTime time.Duration               `json:"talk_time"`
+	// number of times the participant started speaking
+	Turns int `json:"turns"`
+	// turns started while someone else was speaking
+	Interruptions int `json:"interruptions"`
+	// times someone else started speaking while the participant was
+	Interrupted int `json:"interrupted"`
+}
+
+// SpeakerStats accumulates talk time and interruptions from the active speakers of each audio level update
+type SpeakerStats struct {
+	lock       sync.Mutex
+	stats      map[livekit.ParticipantIdentity]*SpeakerStat
+	active     map[livekit.ParticipantIdentity]bool
+	lastUpdate time.Time
+}
+
+func NewSpeakerStats() *SpeakerStats {
+	return &SpeakerStats{
+		stats:  make(map[livekit.ParticipantIdentity]*SpeakerStat),
+		active: make(map[livekit.ParticipantIdentity]bool),
+	}
+}
+
+// Update records the participants speaking at now. Speakers active on the previous update are credited
+// with the time in between.
+func (s *SpeakerStats) Update(now time.Time, speakers []livekit.ParticipantIdentity) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if !s.lastUpdate.IsZero() {
+		elapsed := now.Sub(s.lastUpdate)
+		if elapsed > maxSpeakerStatsInterval {
+			elapsed = maxSpeakerStatsInterval
+		}
+		for identity := range s.active {
+			s.getLocked(identity).TalkTime += elapsed
+		}
+	}
+	s.lastUpdate = now
+
+	active := make(map[livekit.ParticipantIdentity]bool, len(speakers))
+	for _, identity := range speakers {
+		active[identity] = true
+	}
+	for identity := range active {
+		if s.active[identity] {
+			continue
+		}
+
+		stat := s.getLocked(identity)
+		stat.Turns++
+		// started while others who were already speaking kept on
+		interrupting := false
+		for other := range s.active {
+			if active[other] {
+				s.getLocked(other).Interrupted++
+				interrupting = true
+			}
+		}
+		if interrupting {
+			stat.Interruptions++
+		}
+	}
+	s.active = active
+}
+
+// Stats returns the stats of everyone who spoke, by talk time
+func (s *SpeakerStats) Stats() []SpeakerStat {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	stats := make([]SpeakerStat, 0, len(s.stats))
+	for _, stat := range s.stats {
+		stats = append(stats, *stat)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].TalkTime != stats[j].TalkTime {
+			return stats[i].TalkTime > stats[j].TalkTime
+		}
+		return stats[i].Identity < stats[j].Identity
+	})
+	return stats
+}
+
+func (s *SpeakerStats) getLocked(identity livekit.ParticipantIdentity) *SpeakerStat {
+	stat := s.stats[identity]
+	if stat == nil {
+		stat = &SpeakerStat{Identity: identity}
+		s.stats[identity] = stat
+	}
+	return stat
+}
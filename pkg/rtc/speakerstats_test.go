@@ -0,0 +1,67 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/protocol/livekit"
+)
+
+func TestSpeakerStats(t *testing.T) {
+	s := NewSpeakerStats()
+	now := time.Now()
+	tick := func(speakers ...livekit.ParticipantIdentity) {
+		s.Update(now, speakers)
+		now = now.Add(500 * time.Millisecond)
+	}
+
+	tick("teacher")
+	tick("teacher")
+	// student starts speaking over the teacher
+	tick("teacher", "student")
+	tick("student")
+	tick()
+	// teacher starts after the student stopped, not an interruption
+	tick("teacher")
+	tick()
+
+	stats := s.Stats()
+	require.Len(t, stats, 2)
+	require.Equal(t, SpeakerStat{
+		Identity:    "teacher",
+		TalkTime:    2 * time.Second,
+		Turns:       2,
+		Interrupted: 1,
+	}, stats[0])
+	require.Equal(t, SpeakerStat{
+		Identity:      "student",
+		TalkTime:      time.Second,
+		Turns:         1,
+		Interruptions: 1,
+	}, stats[1])
+}
+
+func TestSpeakerStatsCapsStalledUpdates(t *testing.T) {
+	s := NewSpeakerStats()
+	now := time.Now()
+	s.Update(now, []livekit.ParticipantIdentity{"teacher"})
+	s.Update(now.Add(time.Minute), nil)
+
+	require.Equal(t, maxSpeakerStatsInterval, s.Stats()[0].TalkTime)
+}
@@ -0,0 +1,65 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtc
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/pion/dtls/v2"
+	"github.com/pion/webrtc/v3"
+)
+
+var srtpProtectionProfiles = map[string]dtls.SRTPProtectionProfile{
+	"AEAD_AES_128_GCM":        dtls.SRTP_AEAD_AES_128_GCM,
+	"AEAD_AES_256_GCM":        dtls.SRTP_AEAD_AES_256_GCM,
+	"AES_CM_128_HMAC_SHA1_80": dtls.SRTP_AES128_CM_HMAC_SHA1_80,
+}
+
+func parseSRTPProtectionProfiles(names []string) ([]dtls.SRTPProtectionProfile, error) {
+	profiles := make([]dtls.SRTPProtectionProfile, 0, len(names))
+	for _, name := range names {
+		profile, ok := srtpProtectionProfiles[name]
+		if !ok {
+			return nil, fmt.Errorf("unsupported SRTP protection profile %s", name)
+		}
+		profiles = append(profiles, profile)
+	}
+	return profiles, nil
+}
+
+func srtpProtectionProfileName(profile dtls.SRTPProtectionProfile) string {
+	for name, p := range srtpProtectionProfiles {
+		if p == profile {
+			return name
+		}
+	}
+	return "unknown"
+}
+
+// negotiatedSRTPProtectionProfile returns the profile selected during the DTLS handshake.
+// Pion does not expose it, so it is read from the transport once the handshake has completed,
+// which is guaranteed by the remote certificate being set.
+func negotiatedSRTPProtectionProfile(transport *webrtc.DTLSTransport) string {
+	if transport == nil || len(transport.GetRemoteCertificate()) == 0 {
+		return "unknown"
+	}
+
+	field := reflect.ValueOf(transport).Elem().FieldByName("srtpProtectionProfile")
+	if !field.IsValid() || !field.CanUint() {
+		return "unknown"
+	}
+	return srtpProtectionProfileName(dtls.SRTPProtectionProfile(field.Uint()))
+}
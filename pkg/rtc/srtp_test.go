@@ -0,0 +1,34 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtc
+
+import (
+	"testing"
+
+	"github.com/pion/dtls/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSRTPProtectionProfiles(t *testing.T) {
+	profiles, err := parseSRTPProtectionProfiles([]string{"AEAD_AES_128_GCM", "AES_CM_128_HMAC_SHA1_80"})
+	require.NoError(t, err)
+	require.Equal(t, []dtls.SRTPProtectionProfile{dtls.SRTP_AEAD_AES_128_GCM, dtls.SRTP_AES128_CM_HMAC_SHA1_80}, profiles)
+
+	_, err = parseSRTPProtectionProfiles([]string{"AES_CM_128_HMAC_SHA1_32"})
+	require.Error(t, err)
+
+	require.Equal(t, "AEAD_AES_256_GCM", srtpProtectionProfileName(dtls.SRTP_AEAD_AES_256_GCM))
+	require.Equal(t, "unknown", negotiatedSRTPProtectionProfile(nil))
+}
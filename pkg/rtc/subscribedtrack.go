@@ -29,6 +29,7 @@ import (
 	"github.com/livekit/livekit-server/pkg/rtc/types"
 	"github.com/livekit/livekit-server/pkg/sfu"
 	"github.com/livekit/livekit-server/pkg/sfu/buffer"
+	"github.com/livekit/livekit-server/pkg/sfu/streamallocator"
 )
 
 const (
@@ -114,11 +115,12 @@ func (t *SubscribedTrack) Bound(err error) {
 		if t.params.AdaptiveStream {
 			desiredLayer = buffer.VideoQualityToSpatialLayer(livekit.VideoQuality_LOW, t.params.MediaTrack.ToProto())
 		} else {
-			desiredLayer = buffer.VideoQualityToSpatialLayer(livekit.VideoQuality_HIGH, t.params.MediaTrack.ToProto())
+			desiredLayer = buffer.VideoQualityToSpatialLayer(t.capQuality(livekit.VideoQuality_HIGH), t.params.MediaTrack.ToProto())
 		}
 		settings := t.settings.Load()
 		if settings != nil {
 			desiredLayer = t.spatialLayerFromSettings(settings)
+			t.DownTrack().SetSubscriberPriority(subscriberPriority(settings.Priority))
 		}
 		t.DownTrack().SetMaxSpatialLayer(desiredLayer)
 	}
@@ -212,7 +214,13 @@ func (t *SubscribedTrack) UpdateVideoLayer() {
 	}
 
 	settings := t.settings.Load()
-	if settings == nil || settings.Disabled {
+	if settings == nil {
+		return
+	}
+
+	// priority applies even when disabled so that it is in place when the track is enabled again
+	t.DownTrack().SetSubscriberPriority(subscriberPriority(settings.Priority))
+	if settings.Disabled {
 		return
 	}
 
@@ -251,5 +259,29 @@ func (t *SubscribedTrack) spatialLayerFromSettings(settings *livekit.UpdateTrack
 		quality = t.MediaTrack().GetQualityForDimension(settings.Width, settings.Height)
 	}
 
-	return buffer.VideoQualityToSpatialLayer(quality, t.params.MediaTrack.ToProto())
+	return buffer.VideoQualityToSpatialLayer(t.capQuality(quality), t.params.MediaTrack.ToProto())
+}
+
+// capQuality limits quality to the maximum the subscriber is entitled to
+func (t *SubscribedTrack) capQuality(quality livekit.VideoQuality) livekit.VideoQuality {
+	maxQuality := t.params.Subscriber.GetEntitlements().GetMaxVideoQuality()
+	if quality != livekit.VideoQuality_OFF && quality > maxQuality {
+		return maxQuality
+	}
+	return quality
+}
+
+// subscriberPriority maps the signaled priority (1 being the highest, 0 unset) onto the stream allocator scale,
+// tracks at lower priorities are degraded first when the subscriber is congested
+func subscriberPriority(priority uint32) uint8 {
+	switch priority {
+	case 0:
+		return 0
+	case 1:
+		return streamallocator.PriorityHigh
+	case 2:
+		return streamallocator.PriorityNormal
+	default:
+		return streamallocator.PriorityLow
+	}
 }
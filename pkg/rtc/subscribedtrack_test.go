@@ -0,0 +1,31 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/livekit-server/pkg/sfu/streamallocator"
+)
+
+func TestSubscriberPriority(t *testing.T) {
+	require.Zero(t, subscriberPriority(0), "unset keeps the allocator default")
+	require.Equal(t, streamallocator.PriorityHigh, subscriberPriority(1))
+	require.Equal(t, streamallocator.PriorityNormal, subscriberPriority(2))
+	require.Equal(t, streamallocator.PriorityLow, subscriberPriority(3))
+	require.Equal(t, streamallocator.PriorityLow, subscriberPriority(10))
+}
@@ -44,6 +44,7 @@ import (
 
 	"github.com/livekit/livekit-server/pkg/config"
 	"github.com/livekit/livekit-server/pkg/rtc/types"
+	"github.com/livekit/livekit-server/pkg/sfu/impairment"
 	"github.com/livekit/livekit-server/pkg/sfu/pacer"
 	"github.com/livekit/livekit-server/pkg/sfu/rtpextension"
 	"github.com/livekit/livekit-server/pkg/sfu/streamallocator"
@@ -255,6 +256,7 @@ type TransportParams struct {
 	IsOfferer               bool
 	IsSendSide              bool
 	AllowPlayoutDelay       bool
+	Impairment              *impairment.Impairment
 }
 
 func newPeerConnection(params TransportParams, onBandwidthEstimator func(estimator cc.BandwidthEstimator)) (*webrtc.PeerConnection, *webrtc.MediaEngine, error) {
@@ -337,6 +339,10 @@ func newPeerConnection(params TransportParams, onBandwidthEstimator func(estimat
 	}
 
 	ir := &interceptor.Registry{}
+	if params.Impairment != nil {
+		// registered first to be closest to the wire
+		ir.Add(NewImpairmentInterceptorFactory(params.Impairment))
+	}
 	if params.IsSendSide {
 		if params.CongestionControlConfig.UseSendSideBWE {
 			gf, err := cc.NewInterceptor(func() (cc.BandwidthEstimator, error) {
@@ -405,6 +411,7 @@ func NewPCTransport(params TransportParams) (*PCTransport, error) {
 	if err := t.createPeerConnection(); err != nil {
 		return nil, err
 	}
+	prometheus.AddPeerConnection()
 
 	go t.processEvents()
 
@@ -653,6 +660,7 @@ func (t *PCTransport) onPeerConnectionStateChange(state webrtc.PeerConnectionSta
 
 			t.maybeNotifyFullyEstablished()
 			t.logICECandidates()
+			t.recordSRTPProtectionProfile()
 		}
 	case webrtc.PeerConnectionStateFailed:
 		t.params.Logger.Infow("peer connection failed")
@@ -662,6 +670,21 @@ func (t *PCTransport) onPeerConnectionStateChange(state webrtc.PeerConnectionSta
 	}
 }
 
+func (t *PCTransport) recordSRTPProtectionProfile() {
+	var transport *webrtc.DTLSTransport
+	if sctp := t.pc.SCTP(); sctp != nil {
+		transport = sctp.Transport()
+	}
+	profile := negotiatedSRTPProtectionProfile(transport)
+
+	direction := "publisher"
+	if t.params.IsSendSide {
+		direction = "subscriber"
+	}
+	t.params.Logger.Debugw("negotiated SRTP protection profile", "profile", profile)
+	prometheus.RecordSRTPProtectionProfile(profile, direction)
+}
+
 func (t *PCTransport) onDataChannel(dc *webrtc.DataChannel) {
 	t.params.Logger.Debugw(dc.Label() + " data channel open")
 	switch dc.Label() {
@@ -900,6 +923,10 @@ func (t *PCTransport) IsEstablished() bool {
 	return t.pc.ConnectionState() != webrtc.PeerConnectionStateNew
 }
 
+func (t *PCTransport) IsConnected() bool {
+	return t.pc.ConnectionState() == webrtc.PeerConnectionStateConnected
+}
+
 func (t *PCTransport) HasEverConnected() bool {
 	t.lock.RLock()
 	defer t.lock.RUnlock()
@@ -950,6 +977,7 @@ func (t *PCTransport) Close() {
 	}
 
 	_ = t.pc.Close()
+	prometheus.SubPeerConnection()
 
 	t.clearConnTimer()
 }
@@ -1161,6 +1189,7 @@ func (t *PCTransport) AddTrackToStreamAllocator(subTrack types.SubscribedTrack)
 
 	t.streamAllocator.AddTrack(subTrack.DownTrack(), streamallocator.AddTrackParams{
 		Source:      subTrack.MediaTrack().Source(),
+		Priority:    subTrack.DownTrack().SubscriberPriority(),
 		IsSimulcast: subTrack.MediaTrack().IsSimulcast(),
 		PublisherID: subTrack.MediaTrack().PublisherID(),
 	})
@@ -1190,6 +1219,46 @@ func (t *PCTransport) SetChannelCapacityOfStreamAllocator(channelCapacity int64)
 	t.streamAllocator.SetChannelCapacity(channelCapacity)
 }
 
+func (t *PCTransport) SetMaxChannelCapacityOfStreamAllocator(maxChannelCapacity int64) {
+	if t.streamAllocator == nil {
+		return
+	}
+
+	t.streamAllocator.SetMaxChannelCapacity(maxChannelCapacity)
+}
+
+func (t *PCTransport) SetBandwidthOverrideOfStreamAllocator(override streamallocator.BandwidthOverride) {
+	if t.streamAllocator == nil {
+		return
+	}
+
+	t.streamAllocator.SetBandwidthOverride(override)
+}
+
+func (t *PCTransport) SetPreferredPublisherOfStreamAllocator(publisherID livekit.ParticipantID) {
+	if t.streamAllocator == nil {
+		return
+	}
+
+	t.streamAllocator.SetPreferredPublisher(publisherID)
+}
+
+func (t *PCTransport) SetPausedVideoPlaceholderOfStreamAllocator(placeholder config.PausedVideoPlaceholder) {
+	if t.streamAllocator == nil {
+		return
+	}
+
+	t.streamAllocator.SetPausedVideoPlaceholder(placeholder)
+}
+
+func (t *PCTransport) GetBandwidthDemandOfStreamAllocator() int64 {
+	if t.streamAllocator == nil {
+		return 0
+	}
+
+	return t.streamAllocator.GetBandwidthDemand()
+}
+
 func (t *PCTransport) GetICEConnectionType() types.ICEConnectionType {
 	unknown := types.ICEConnectionTypeUnknown
 	if t.pc == nil {
@@ -31,6 +31,7 @@ import (
 	"github.com/livekit/livekit-server/pkg/config"
 	"github.com/livekit/livekit-server/pkg/rtc/types"
 	"github.com/livekit/livekit-server/pkg/sfu"
+	"github.com/livekit/livekit-server/pkg/sfu/impairment"
 	"github.com/livekit/livekit-server/pkg/sfu/pacer"
 	"github.com/livekit/livekit-server/pkg/sfu/streamallocator"
 	"github.com/livekit/livekit-server/pkg/telemetry"
@@ -66,6 +67,7 @@ type TransportManagerParams struct {
 	AllowUDPUnstableFallback bool
 	TURNSEnabled             bool
 	AllowPlayoutDelay        bool
+	DownlinkImpairment       *impairment.Impairment
 	Logger                   logger.Logger
 }
 
@@ -186,6 +188,7 @@ func NewTransportManager(params TransportManagerParams) (*TransportManager, erro
 		IsOfferer:               true,
 		IsSendSide:              true,
 		AllowPlayoutDelay:       params.AllowPlayoutDelay,
+		Impairment:              params.DownlinkImpairment,
 	})
 	if err != nil {
 		return nil, err
@@ -804,6 +807,18 @@ func (t *TransportManager) SinceLastSignal() time.Duration {
 	return time.Since(t.lastSignalAt)
 }
 
+// IsUnresponsive returns true when nothing was received over signal for staleTimeout while none of the peer
+// connections is connected. Participants that never connected are left to the join timeout
+func (t *TransportManager) IsUnresponsive(staleTimeout time.Duration) bool {
+	if !t.publisher.HasEverConnected() && !t.subscriber.HasEverConnected() {
+		return false
+	}
+	if t.publisher.IsConnected() || t.subscriber.IsConnected() {
+		return false
+	}
+	return t.SinceLastSignal() > staleTimeout
+}
+
 func (t *TransportManager) LastSeenSignalAt() time.Time {
 	t.lock.RLock()
 	defer t.lock.RUnlock()
@@ -826,3 +841,23 @@ func (t *TransportManager) SetSubscriberAllowPause(allowPause bool) {
 func (t *TransportManager) SetSubscriberChannelCapacity(channelCapacity int64) {
 	t.subscriber.SetChannelCapacityOfStreamAllocator(channelCapacity)
 }
+
+func (t *TransportManager) SetSubscriberMaxChannelCapacity(maxChannelCapacity int64) {
+	t.subscriber.SetMaxChannelCapacityOfStreamAllocator(maxChannelCapacity)
+}
+
+func (t *TransportManager) SetSubscriberBandwidthOverride(override streamallocator.BandwidthOverride) {
+	t.subscriber.SetBandwidthOverrideOfStreamAllocator(override)
+}
+
+func (t *TransportManager) SetSubscriberPreferredPublisher(publisherID livekit.ParticipantID) {
+	t.subscriber.SetPreferredPublisherOfStreamAllocator(publisherID)
+}
+
+func (t *TransportManager) SetSubscriberPausedVideoPlaceholder(placeholder config.PausedVideoPlaceholder) {
+	t.subscriber.SetPausedVideoPlaceholderOfStreamAllocator(placeholder)
+}
+
+func (t *TransportManager) GetSubscriberBandwidthDemand() int64 {
+	return t.subscriber.GetBandwidthDemandOfStreamAllocator()
+}
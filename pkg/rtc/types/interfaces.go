@@ -26,10 +26,13 @@ import (
 	"github.com/livekit/protocol/logger"
 	"github.com/livekit/protocol/utils"
 
+	"github.com/livekit/livekit-server/pkg/config"
 	"github.com/livekit/livekit-server/pkg/routing"
 	"github.com/livekit/livekit-server/pkg/sfu"
 	"github.com/livekit/livekit-server/pkg/sfu/buffer"
+	"github.com/livekit/livekit-server/pkg/sfu/impairment"
 	"github.com/livekit/livekit-server/pkg/sfu/pacer"
+	"github.com/livekit/livekit-server/pkg/sfu/streamallocator"
 )
 
 //go:generate go run github.com/maxbrunsfeld/counterfeiter/v6 -generate
@@ -39,6 +42,8 @@ type WebsocketClient interface {
 	ReadMessage() (messageType int, p []byte, err error)
 	WriteMessage(messageType int, data []byte) error
 	WriteControl(messageType int, data []byte, deadline time.Time) error
+	SetReadDeadline(t time.Time) error
+	SetPongHandler(h func(appData string) error)
 }
 
 type AddSubscriberParams struct {
@@ -103,6 +108,10 @@ const (
 	ParticipantCloseReasonPublicationError
 	ParticipantCloseReasonSubscriptionError
 	ParticipantCloseReasonDataChannelError
+	ParticipantCloseReasonPanic
+	ParticipantCloseReasonNodeDraining
+	ParticipantCloseReasonDeadPeer
+	ParticipantCloseReasonMediaStalled
 )
 
 func (p ParticipantCloseReason) String() string {
@@ -151,6 +160,14 @@ func (p ParticipantCloseReason) String() string {
 		return "SUBSCRIPTION_ERROR"
 	case ParticipantCloseReasonDataChannelError:
 		return "DATA_CHANNEL_ERROR"
+	case ParticipantCloseReasonPanic:
+		return "PANIC"
+	case ParticipantCloseReasonNodeDraining:
+		return "NODE_DRAINING"
+	case ParticipantCloseReasonDeadPeer:
+		return "DEAD_PEER"
+	case ParticipantCloseReasonMediaStalled:
+		return "MEDIA_STALLED"
 	default:
 		return fmt.Sprintf("%d", int(p))
 	}
@@ -165,7 +182,7 @@ func (p ParticipantCloseReason) ToDisconnectReason() livekit.DisconnectReason {
 	case ParticipantCloseReasonVerifyFailed, ParticipantCloseReasonJoinFailed, ParticipantCloseReasonJoinTimeout:
 		// expected to be connected but is not
 		return livekit.DisconnectReason_JOIN_FAILURE
-	case ParticipantCloseReasonPeerConnectionDisconnected:
+	case ParticipantCloseReasonPeerConnectionDisconnected, ParticipantCloseReasonDeadPeer:
 		return livekit.DisconnectReason_STATE_MISMATCH
 	case ParticipantCloseReasonDuplicateIdentity, ParticipantCloseReasonMigrationComplete, ParticipantCloseReasonStale:
 		return livekit.DisconnectReason_DUPLICATE_IDENTITY
@@ -179,9 +196,10 @@ func (p ParticipantCloseReason) ToDisconnectReason() livekit.DisconnectReason {
 		return livekit.DisconnectReason_SERVER_SHUTDOWN
 	case ParticipantCloseReasonSimulateServerLeave:
 		return livekit.DisconnectReason_SERVER_SHUTDOWN
-	case ParticipantCloseReasonOvercommitted:
+	case ParticipantCloseReasonOvercommitted, ParticipantCloseReasonNodeDraining:
 		return livekit.DisconnectReason_SERVER_SHUTDOWN
-	case ParticipantCloseReasonNegotiateFailed, ParticipantCloseReasonPublicationError, ParticipantCloseReasonSubscriptionError, ParticipantCloseReasonDataChannelError:
+	case ParticipantCloseReasonNegotiateFailed, ParticipantCloseReasonPublicationError, ParticipantCloseReasonSubscriptionError, ParticipantCloseReasonDataChannelError,
+		ParticipantCloseReasonPanic, ParticipantCloseReasonMediaStalled:
 		return livekit.DisconnectReason_STATE_MISMATCH
 	default:
 		// the other types will map to unknown reason
@@ -263,6 +281,7 @@ type Participant interface {
 	Close(sendLeave bool, reason ParticipantCloseReason, isExpectedToResume bool) error
 
 	SubscriptionPermission() (*livekit.SubscriptionPermission, utils.TimedVersion)
+	AdminSubscriptionPermission() *livekit.SubscriptionPermission
 
 	// updates from remotes
 	UpdateSubscriptionPermission(
@@ -272,6 +291,11 @@ type Participant interface {
 		resolverBySid func(participantID livekit.ParticipantID) LocalParticipant,
 	) error
 	UpdateVideoLayers(updateVideoLayers *livekit.UpdateVideoLayers) error
+	UpdateAdminSubscriptionPermission(
+		subscriptionPermission *livekit.SubscriptionPermission,
+		resolverByIdentity func(participantIdentity livekit.ParticipantIdentity) LocalParticipant,
+		resolverBySid func(participantID livekit.ParticipantID) LocalParticipant,
+	) error
 
 	DebugInfo() map[string]interface{}
 }
@@ -292,6 +316,17 @@ type AddTrackParams struct {
 	Red    bool
 }
 
+// TrackMuteState is the authoritative mute state of a published track next to what the server observes.
+type TrackMuteState struct {
+	TrackID livekit.TrackID
+	Kind    livekit.TrackType
+	Muted   bool
+	// muted by the server because no media was received although the publisher signaled it as unmuted
+	MutedByServer bool
+	// zero if no media was received yet
+	LastPacketAt time.Time
+}
+
 //counterfeiter:generate . LocalParticipant
 type LocalParticipant interface {
 	Participant
@@ -302,6 +337,7 @@ type LocalParticipant interface {
 	GetTrailer() []byte
 	GetLogger() logger.Logger
 	GetAdaptiveStream() bool
+	GetEntitlements() *routing.Entitlements
 	ProtocolVersion() ProtocolVersion
 	SupportSyncStreamID() bool
 	ConnectedAt() time.Time
@@ -315,12 +351,14 @@ type LocalParticipant interface {
 	GetICEConnectionType() ICEConnectionType
 	GetBufferFactory() *buffer.Factory
 	GetPlayoutDelayConfig() *livekit.PlayoutDelay
+	GetImpairments() (uplink *impairment.Impairment, downlink *impairment.Impairment)
 
 	SetResponseSink(sink routing.MessageSink)
 	CloseSignalConnection(reason SignallingCloseReason)
 	UpdateLastSeenSignal()
 	SetSignalSourceValid(valid bool)
 	HandleSignalSourceClose()
+	IsUnresponsive(staleTimeout time.Duration) bool
 
 	// permissions
 	ClaimGrants() *auth.ClaimGrants
@@ -334,6 +372,7 @@ type LocalParticipant interface {
 	HandleOffer(sdp webrtc.SessionDescription)
 	AddTrack(req *livekit.AddTrackRequest)
 	SetTrackMuted(trackID livekit.TrackID, muted bool, fromAdmin bool)
+	GetTrackMuteStates() []TrackMuteState
 
 	HandleAnswer(sdp webrtc.SessionDescription)
 	Negotiate(force bool)
@@ -409,6 +448,11 @@ type LocalParticipant interface {
 	// down stream bandwidth management
 	SetSubscriberAllowPause(allowPause bool)
 	SetSubscriberChannelCapacity(channelCapacity int64)
+	SetSubscriberMaxChannelCapacity(maxChannelCapacity int64)
+	SetSubscriberBandwidthOverride(override streamallocator.BandwidthOverride)
+	SetSubscriberPreferredPublisher(publisherID livekit.ParticipantID)
+	SetSubscriberPausedVideoPlaceholder(placeholder config.PausedVideoPlaceholder)
+	GetSubscriberBandwidthDemand() int64
 
 	GetPacer() pacer.Pacer
 }
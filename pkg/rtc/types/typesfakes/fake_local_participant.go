@@ -5,11 +5,14 @@ import (
 	"sync"
 	"time"
 
+	"github.com/livekit/livekit-server/pkg/config"
 	"github.com/livekit/livekit-server/pkg/routing"
 	"github.com/livekit/livekit-server/pkg/rtc/types"
 	"github.com/livekit/livekit-server/pkg/sfu"
 	"github.com/livekit/livekit-server/pkg/sfu/buffer"
+	"github.com/livekit/livekit-server/pkg/sfu/impairment"
 	"github.com/livekit/livekit-server/pkg/sfu/pacer"
+	"github.com/livekit/livekit-server/pkg/sfu/streamallocator"
 	"github.com/livekit/protocol/auth"
 	"github.com/livekit/protocol/livekit"
 	"github.com/livekit/protocol/logger"
@@ -62,6 +65,16 @@ type FakeLocalParticipant struct {
 		result2 *webrtc.RTPTransceiver
 		result3 error
 	}
+	AdminSubscriptionPermissionStub        func() *livekit.SubscriptionPermission
+	adminSubscriptionPermissionMutex       sync.RWMutex
+	adminSubscriptionPermissionArgsForCall []struct {
+	}
+	adminSubscriptionPermissionReturns struct {
+		result1 *livekit.SubscriptionPermission
+	}
+	adminSubscriptionPermissionReturnsOnCall map[int]struct {
+		result1 *livekit.SubscriptionPermission
+	}
 	CacheDownTrackStub        func(livekit.TrackID, *webrtc.RTPTransceiver, sfu.DownTrackState)
 	cacheDownTrackMutex       sync.RWMutex
 	cacheDownTrackArgsForCall []struct {
@@ -233,6 +246,16 @@ type FakeLocalParticipant struct {
 	getConnectionQualityReturnsOnCall map[int]struct {
 		result1 *livekit.ConnectionQualityInfo
 	}
+	GetEntitlementsStub        func() *routing.Entitlements
+	getEntitlementsMutex       sync.RWMutex
+	getEntitlementsArgsForCall []struct {
+	}
+	getEntitlementsReturns struct {
+		result1 *routing.Entitlements
+	}
+	getEntitlementsReturnsOnCall map[int]struct {
+		result1 *routing.Entitlements
+	}
 	GetICEConnectionTypeStub        func() types.ICEConnectionType
 	getICEConnectionTypeMutex       sync.RWMutex
 	getICEConnectionTypeArgsForCall []struct {
@@ -243,6 +266,18 @@ type FakeLocalParticipant struct {
 	getICEConnectionTypeReturnsOnCall map[int]struct {
 		result1 types.ICEConnectionType
 	}
+	GetImpairmentsStub        func() (*impairment.Impairment, *impairment.Impairment)
+	getImpairmentsMutex       sync.RWMutex
+	getImpairmentsArgsForCall []struct {
+	}
+	getImpairmentsReturns struct {
+		result1 *impairment.Impairment
+		result2 *impairment.Impairment
+	}
+	getImpairmentsReturnsOnCall map[int]struct {
+		result1 *impairment.Impairment
+		result2 *impairment.Impairment
+	}
 	GetLoggerStub        func() logger.Logger
 	getLoggerMutex       sync.RWMutex
 	getLoggerArgsForCall []struct {
@@ -314,6 +349,26 @@ type FakeLocalParticipant struct {
 	getSubscribedTracksReturnsOnCall map[int]struct {
 		result1 []types.SubscribedTrack
 	}
+	GetSubscriberBandwidthDemandStub        func() int64
+	getSubscriberBandwidthDemandMutex       sync.RWMutex
+	getSubscriberBandwidthDemandArgsForCall []struct {
+	}
+	getSubscriberBandwidthDemandReturns struct {
+		result1 int64
+	}
+	getSubscriberBandwidthDemandReturnsOnCall map[int]struct {
+		result1 int64
+	}
+	GetTrackMuteStatesStub        func() []types.TrackMuteState
+	getTrackMuteStatesMutex       sync.RWMutex
+	getTrackMuteStatesArgsForCall []struct {
+	}
+	getTrackMuteStatesReturns struct {
+		result1 []types.TrackMuteState
+	}
+	getTrackMuteStatesReturnsOnCall map[int]struct {
+		result1 []types.TrackMuteState
+	}
 	GetTrailerStub        func() []byte
 	getTrailerMutex       sync.RWMutex
 	getTrailerArgsForCall []struct {
@@ -468,6 +523,17 @@ type FakeLocalParticipant struct {
 	isSubscribedToReturnsOnCall map[int]struct {
 		result1 bool
 	}
+	IsUnresponsiveStub        func(time.Duration) bool
+	isUnresponsiveMutex       sync.RWMutex
+	isUnresponsiveArgsForCall []struct {
+		arg1 time.Duration
+	}
+	isUnresponsiveReturns struct {
+		result1 bool
+	}
+	isUnresponsiveReturnsOnCall map[int]struct {
+		result1 bool
+	}
 	IssueFullReconnectStub        func(types.ParticipantCloseReason)
 	issueFullReconnectMutex       sync.RWMutex
 	issueFullReconnectArgsForCall []struct {
@@ -722,11 +788,31 @@ type FakeLocalParticipant struct {
 	setSubscriberAllowPauseArgsForCall []struct {
 		arg1 bool
 	}
+	SetSubscriberBandwidthOverrideStub        func(streamallocator.BandwidthOverride)
+	setSubscriberBandwidthOverrideMutex       sync.RWMutex
+	setSubscriberBandwidthOverrideArgsForCall []struct {
+		arg1 streamallocator.BandwidthOverride
+	}
 	SetSubscriberChannelCapacityStub        func(int64)
 	setSubscriberChannelCapacityMutex       sync.RWMutex
 	setSubscriberChannelCapacityArgsForCall []struct {
 		arg1 int64
 	}
+	SetSubscriberMaxChannelCapacityStub        func(int64)
+	setSubscriberMaxChannelCapacityMutex       sync.RWMutex
+	setSubscriberMaxChannelCapacityArgsForCall []struct {
+		arg1 int64
+	}
+	SetSubscriberPausedVideoPlaceholderStub        func(config.PausedVideoPlaceholder)
+	setSubscriberPausedVideoPlaceholderMutex       sync.RWMutex
+	setSubscriberPausedVideoPlaceholderArgsForCall []struct {
+		arg1 config.PausedVideoPlaceholder
+	}
+	SetSubscriberPreferredPublisherStub        func(livekit.ParticipantID)
+	setSubscriberPreferredPublisherMutex       sync.RWMutex
+	setSubscriberPreferredPublisherArgsForCall []struct {
+		arg1 livekit.ParticipantID
+	}
 	SetTrackMutedStub        func(livekit.TrackID, bool, bool)
 	setTrackMutedMutex       sync.RWMutex
 	setTrackMutedArgsForCall []struct {
@@ -824,6 +910,19 @@ type FakeLocalParticipant struct {
 	unsubscribeFromTrackArgsForCall []struct {
 		arg1 livekit.TrackID
 	}
+	UpdateAdminSubscriptionPermissionStub        func(*livekit.SubscriptionPermission, func(participantIdentity livekit.ParticipantIdentity) types.LocalParticipant, func(participantID livekit.ParticipantID) types.LocalParticipant) error
+	updateAdminSubscriptionPermissionMutex       sync.RWMutex
+	updateAdminSubscriptionPermissionArgsForCall []struct {
+		arg1 *livekit.SubscriptionPermission
+		arg2 func(participantIdentity livekit.ParticipantIdentity) types.LocalParticipant
+		arg3 func(participantID livekit.ParticipantID) types.LocalParticipant
+	}
+	updateAdminSubscriptionPermissionReturns struct {
+		result1 error
+	}
+	updateAdminSubscriptionPermissionReturnsOnCall map[int]struct {
+		result1 error
+	}
 	UpdateLastSeenSignalStub        func()
 	updateLastSeenSignalMutex       sync.RWMutex
 	updateLastSeenSignalArgsForCall []struct {
@@ -1117,6 +1216,59 @@ func (fake *FakeLocalParticipant) AddTransceiverFromTrackToSubscriberReturnsOnCa
 	}{result1, result2, result3}
 }
 
+func (fake *FakeLocalParticipant) AdminSubscriptionPermission() *livekit.SubscriptionPermission {
+	fake.adminSubscriptionPermissionMutex.Lock()
+	ret, specificReturn := fake.adminSubscriptionPermissionReturnsOnCall[len(fake.adminSubscriptionPermissionArgsForCall)]
+	fake.adminSubscriptionPermissionArgsForCall = append(fake.adminSubscriptionPermissionArgsForCall, struct {
+	}{})
+	stub := fake.AdminSubscriptionPermissionStub
+	fakeReturns := fake.adminSubscriptionPermissionReturns
+	fake.recordInvocation("AdminSubscriptionPermission", []interface{}{})
+	fake.adminSubscriptionPermissionMutex.Unlock()
+	if stub != nil {
+		return stub()
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *FakeLocalParticipant) AdminSubscriptionPermissionCallCount() int {
+	fake.adminSubscriptionPermissionMutex.RLock()
+	defer fake.adminSubscriptionPermissionMutex.RUnlock()
+	return len(fake.adminSubscriptionPermissionArgsForCall)
+}
+
+func (fake *FakeLocalParticipant) AdminSubscriptionPermissionCalls(stub func() *livekit.SubscriptionPermission) {
+	fake.adminSubscriptionPermissionMutex.Lock()
+	defer fake.adminSubscriptionPermissionMutex.Unlock()
+	fake.AdminSubscriptionPermissionStub = stub
+}
+
+func (fake *FakeLocalParticipant) AdminSubscriptionPermissionReturns(result1 *livekit.SubscriptionPermission) {
+	fake.adminSubscriptionPermissionMutex.Lock()
+	defer fake.adminSubscriptionPermissionMutex.Unlock()
+	fake.AdminSubscriptionPermissionStub = nil
+	fake.adminSubscriptionPermissionReturns = struct {
+		result1 *livekit.SubscriptionPermission
+	}{result1}
+}
+
+func (fake *FakeLocalParticipant) AdminSubscriptionPermissionReturnsOnCall(i int, result1 *livekit.SubscriptionPermission) {
+	fake.adminSubscriptionPermissionMutex.Lock()
+	defer fake.adminSubscriptionPermissionMutex.Unlock()
+	fake.AdminSubscriptionPermissionStub = nil
+	if fake.adminSubscriptionPermissionReturnsOnCall == nil {
+		fake.adminSubscriptionPermissionReturnsOnCall = make(map[int]struct {
+			result1 *livekit.SubscriptionPermission
+		})
+	}
+	fake.adminSubscriptionPermissionReturnsOnCall[i] = struct {
+		result1 *livekit.SubscriptionPermission
+	}{result1}
+}
+
 func (fake *FakeLocalParticipant) CacheDownTrack(arg1 livekit.TrackID, arg2 *webrtc.RTPTransceiver, arg3 sfu.DownTrackState) {
 	fake.cacheDownTrackMutex.Lock()
 	fake.cacheDownTrackArgsForCall = append(fake.cacheDownTrackArgsForCall, struct {
@@ -2010,6 +2162,59 @@ func (fake *FakeLocalParticipant) GetConnectionQualityReturnsOnCall(i int, resul
 	}{result1}
 }
 
+func (fake *FakeLocalParticipant) GetEntitlements() *routing.Entitlements {
+	fake.getEntitlementsMutex.Lock()
+	ret, specificReturn := fake.getEntitlementsReturnsOnCall[len(fake.getEntitlementsArgsForCall)]
+	fake.getEntitlementsArgsForCall = append(fake.getEntitlementsArgsForCall, struct {
+	}{})
+	stub := fake.GetEntitlementsStub
+	fakeReturns := fake.getEntitlementsReturns
+	fake.recordInvocation("GetEntitlements", []interface{}{})
+	fake.getEntitlementsMutex.Unlock()
+	if stub != nil {
+		return stub()
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *FakeLocalParticipant) GetEntitlementsCallCount() int {
+	fake.getEntitlementsMutex.RLock()
+	defer fake.getEntitlementsMutex.RUnlock()
+	return len(fake.getEntitlementsArgsForCall)
+}
+
+func (fake *FakeLocalParticipant) GetEntitlementsCalls(stub func() *routing.Entitlements) {
+	fake.getEntitlementsMutex.Lock()
+	defer fake.getEntitlementsMutex.Unlock()
+	fake.GetEntitlementsStub = stub
+}
+
+func (fake *FakeLocalParticipant) GetEntitlementsReturns(result1 *routing.Entitlements) {
+	fake.getEntitlementsMutex.Lock()
+	defer fake.getEntitlementsMutex.Unlock()
+	fake.GetEntitlementsStub = nil
+	fake.getEntitlementsReturns = struct {
+		result1 *routing.Entitlements
+	}{result1}
+}
+
+func (fake *FakeLocalParticipant) GetEntitlementsReturnsOnCall(i int, result1 *routing.Entitlements) {
+	fake.getEntitlementsMutex.Lock()
+	defer fake.getEntitlementsMutex.Unlock()
+	fake.GetEntitlementsStub = nil
+	if fake.getEntitlementsReturnsOnCall == nil {
+		fake.getEntitlementsReturnsOnCall = make(map[int]struct {
+			result1 *routing.Entitlements
+		})
+	}
+	fake.getEntitlementsReturnsOnCall[i] = struct {
+		result1 *routing.Entitlements
+	}{result1}
+}
+
 func (fake *FakeLocalParticipant) GetICEConnectionType() types.ICEConnectionType {
 	fake.getICEConnectionTypeMutex.Lock()
 	ret, specificReturn := fake.getICEConnectionTypeReturnsOnCall[len(fake.getICEConnectionTypeArgsForCall)]
@@ -2063,6 +2268,62 @@ func (fake *FakeLocalParticipant) GetICEConnectionTypeReturnsOnCall(i int, resul
 	}{result1}
 }
 
+func (fake *FakeLocalParticipant) GetImpairments() (*impairment.Impairment, *impairment.Impairment) {
+	fake.getImpairmentsMutex.Lock()
+	ret, specificReturn := fake.getImpairmentsReturnsOnCall[len(fake.getImpairmentsArgsForCall)]
+	fake.getImpairmentsArgsForCall = append(fake.getImpairmentsArgsForCall, struct {
+	}{})
+	stub := fake.GetImpairmentsStub
+	fakeReturns := fake.getImpairmentsReturns
+	fake.recordInvocation("GetImpairments", []interface{}{})
+	fake.getImpairmentsMutex.Unlock()
+	if stub != nil {
+		return stub()
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeLocalParticipant) GetImpairmentsCallCount() int {
+	fake.getImpairmentsMutex.RLock()
+	defer fake.getImpairmentsMutex.RUnlock()
+	return len(fake.getImpairmentsArgsForCall)
+}
+
+func (fake *FakeLocalParticipant) GetImpairmentsCalls(stub func() (*impairment.Impairment, *impairment.Impairment)) {
+	fake.getImpairmentsMutex.Lock()
+	defer fake.getImpairmentsMutex.Unlock()
+	fake.GetImpairmentsStub = stub
+}
+
+func (fake *FakeLocalParticipant) GetImpairmentsReturns(result1 *impairment.Impairment, result2 *impairment.Impairment) {
+	fake.getImpairmentsMutex.Lock()
+	defer fake.getImpairmentsMutex.Unlock()
+	fake.GetImpairmentsStub = nil
+	fake.getImpairmentsReturns = struct {
+		result1 *impairment.Impairment
+		result2 *impairment.Impairment
+	}{result1, result2}
+}
+
+func (fake *FakeLocalParticipant) GetImpairmentsReturnsOnCall(i int, result1 *impairment.Impairment, result2 *impairment.Impairment) {
+	fake.getImpairmentsMutex.Lock()
+	defer fake.getImpairmentsMutex.Unlock()
+	fake.GetImpairmentsStub = nil
+	if fake.getImpairmentsReturnsOnCall == nil {
+		fake.getImpairmentsReturnsOnCall = make(map[int]struct {
+			result1 *impairment.Impairment
+			result2 *impairment.Impairment
+		})
+	}
+	fake.getImpairmentsReturnsOnCall[i] = struct {
+		result1 *impairment.Impairment
+		result2 *impairment.Impairment
+	}{result1, result2}
+}
+
 func (fake *FakeLocalParticipant) GetLogger() logger.Logger {
 	fake.getLoggerMutex.Lock()
 	ret, specificReturn := fake.getLoggerReturnsOnCall[len(fake.getLoggerArgsForCall)]
@@ -2442,6 +2703,112 @@ func (fake *FakeLocalParticipant) GetSubscribedTracksReturnsOnCall(i int, result
 	}{result1}
 }
 
+func (fake *FakeLocalParticipant) GetSubscriberBandwidthDemand() int64 {
+	fake.getSubscriberBandwidthDemandMutex.Lock()
+	ret, specificReturn := fake.getSubscriberBandwidthDemandReturnsOnCall[len(fake.getSubscriberBandwidthDemandArgsForCall)]
+	fake.getSubscriberBandwidthDemandArgsForCall = append(fake.getSubscriberBandwidthDemandArgsForCall, struct {
+	}{})
+	stub := fake.GetSubscriberBandwidthDemandStub
+	fakeReturns := fake.getSubscriberBandwidthDemandReturns
+	fake.recordInvocation("GetSubscriberBandwidthDemand", []interface{}{})
+	fake.getSubscriberBandwidthDemandMutex.Unlock()
+	if stub != nil {
+		return stub()
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *FakeLocalParticipant) GetSubscriberBandwidthDemandCallCount() int {
+	fake.getSubscriberBandwidthDemandMutex.RLock()
+	defer fake.getSubscriberBandwidthDemandMutex.RUnlock()
+	return len(fake.getSubscriberBandwidthDemandArgsForCall)
+}
+
+func (fake *FakeLocalParticipant) GetSubscriberBandwidthDemandCalls(stub func() int64) {
+	fake.getSubscriberBandwidthDemandMutex.Lock()
+	defer fake.getSubscriberBandwidthDemandMutex.Unlock()
+	fake.GetSubscriberBandwidthDemandStub = stub
+}
+
+func (fake *FakeLocalParticipant) GetSubscriberBandwidthDemandReturns(result1 int64) {
+	fake.getSubscriberBandwidthDemandMutex.Lock()
+	defer fake.getSubscriberBandwidthDemandMutex.Unlock()
+	fake.GetSubscriberBandwidthDemandStub = nil
+	fake.getSubscriberBandwidthDemandReturns = struct {
+		result1 int64
+	}{result1}
+}
+
+func (fake *FakeLocalParticipant) GetSubscriberBandwidthDemandReturnsOnCall(i int, result1 int64) {
+	fake.getSubscriberBandwidthDemandMutex.Lock()
+	defer fake.getSubscriberBandwidthDemandMutex.Unlock()
+	fake.GetSubscriberBandwidthDemandStub = nil
+	if fake.getSubscriberBandwidthDemandReturnsOnCall == nil {
+		fake.getSubscriberBandwidthDemandReturnsOnCall = make(map[int]struct {
+			result1 int64
+		})
+	}
+	fake.getSubscriberBandwidthDemandReturnsOnCall[i] = struct {
+		result1 int64
+	}{result1}
+}
+
+func (fake *FakeLocalParticipant) GetTrackMuteStates() []types.TrackMuteState {
+	fake.getTrackMuteStatesMutex.Lock()
+	ret, specificReturn := fake.getTrackMuteStatesReturnsOnCall[len(fake.getTrackMuteStatesArgsForCall)]
+	fake.getTrackMuteStatesArgsForCall = append(fake.getTrackMuteStatesArgsForCall, struct {
+	}{})
+	stub := fake.GetTrackMuteStatesStub
+	fakeReturns := fake.getTrackMuteStatesReturns
+	fake.recordInvocation("GetTrackMuteStates", []interface{}{})
+	fake.getTrackMuteStatesMutex.Unlock()
+	if stub != nil {
+		return stub()
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *FakeLocalParticipant) GetTrackMuteStatesCallCount() int {
+	fake.getTrackMuteStatesMutex.RLock()
+	defer fake.getTrackMuteStatesMutex.RUnlock()
+	return len(fake.getTrackMuteStatesArgsForCall)
+}
+
+func (fake *FakeLocalParticipant) GetTrackMuteStatesCalls(stub func() []types.TrackMuteState) {
+	fake.getTrackMuteStatesMutex.Lock()
+	defer fake.getTrackMuteStatesMutex.Unlock()
+	fake.GetTrackMuteStatesStub = stub
+}
+
+func (fake *FakeLocalParticipant) GetTrackMuteStatesReturns(result1 []types.TrackMuteState) {
+	fake.getTrackMuteStatesMutex.Lock()
+	defer fake.getTrackMuteStatesMutex.Unlock()
+	fake.GetTrackMuteStatesStub = nil
+	fake.getTrackMuteStatesReturns = struct {
+		result1 []types.TrackMuteState
+	}{result1}
+}
+
+func (fake *FakeLocalParticipant) GetTrackMuteStatesReturnsOnCall(i int, result1 []types.TrackMuteState) {
+	fake.getTrackMuteStatesMutex.Lock()
+	defer fake.getTrackMuteStatesMutex.Unlock()
+	fake.GetTrackMuteStatesStub = nil
+	if fake.getTrackMuteStatesReturnsOnCall == nil {
+		fake.getTrackMuteStatesReturnsOnCall = make(map[int]struct {
+			result1 []types.TrackMuteState
+		})
+	}
+	fake.getTrackMuteStatesReturnsOnCall[i] = struct {
+		result1 []types.TrackMuteState
+	}{result1}
+}
+
 func (fake *FakeLocalParticipant) GetTrailer() []byte {
 	fake.getTrailerMutex.Lock()
 	ret, specificReturn := fake.getTrailerReturnsOnCall[len(fake.getTrailerArgsForCall)]
@@ -3277,6 +3644,67 @@ func (fake *FakeLocalParticipant) IsSubscribedToReturnsOnCall(i int, result1 boo
 	}{result1}
 }
 
+func (fake *FakeLocalParticipant) IsUnresponsive(arg1 time.Duration) bool {
+	fake.isUnresponsiveMutex.Lock()
+	ret, specificReturn := fake.isUnresponsiveReturnsOnCall[len(fake.isUnresponsiveArgsForCall)]
+	fake.isUnresponsiveArgsForCall = append(fake.isUnresponsiveArgsForCall, struct {
+		arg1 time.Duration
+	}{arg1})
+	stub := fake.IsUnresponsiveStub
+	fakeReturns := fake.isUnresponsiveReturns
+	fake.recordInvocation("IsUnresponsive", []interface{}{arg1})
+	fake.isUnresponsiveMutex.Unlock()
+	if stub != nil {
+		return stub(arg1)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *FakeLocalParticipant) IsUnresponsiveCallCount() int {
+	fake.isUnresponsiveMutex.RLock()
+	defer fake.isUnresponsiveMutex.RUnlock()
+	return len(fake.isUnresponsiveArgsForCall)
+}
+
+func (fake *FakeLocalParticipant) IsUnresponsiveCalls(stub func(time.Duration) bool) {
+	fake.isUnresponsiveMutex.Lock()
+	defer fake.isUnresponsiveMutex.Unlock()
+	fake.IsUnresponsiveStub = stub
+}
+
+func (fake *FakeLocalParticipant) IsUnresponsiveArgsForCall(i int) time.Duration {
+	fake.isUnresponsiveMutex.RLock()
+	defer fake.isUnresponsiveMutex.RUnlock()
+	argsForCall := fake.isUnresponsiveArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FakeLocalParticipant) IsUnresponsiveReturns(result1 bool) {
+	fake.isUnresponsiveMutex.Lock()
+	defer fake.isUnresponsiveMutex.Unlock()
+	fake.IsUnresponsiveStub = nil
+	fake.isUnresponsiveReturns = struct {
+		result1 bool
+	}{result1}
+}
+
+func (fake *FakeLocalParticipant) IsUnresponsiveReturnsOnCall(i int, result1 bool) {
+	fake.isUnresponsiveMutex.Lock()
+	defer fake.isUnresponsiveMutex.Unlock()
+	fake.IsUnresponsiveStub = nil
+	if fake.isUnresponsiveReturnsOnCall == nil {
+		fake.isUnresponsiveReturnsOnCall = make(map[int]struct {
+			result1 bool
+		})
+	}
+	fake.isUnresponsiveReturnsOnCall[i] = struct {
+		result1 bool
+	}{result1}
+}
+
 func (fake *FakeLocalParticipant) IssueFullReconnect(arg1 types.ParticipantCloseReason) {
 	fake.issueFullReconnectMutex.Lock()
 	fake.issueFullReconnectArgsForCall = append(fake.issueFullReconnectArgsForCall, struct {
@@ -4763,6 +5191,38 @@ func (fake *FakeLocalParticipant) SetSubscriberAllowPauseArgsForCall(i int) bool
 	return argsForCall.arg1
 }
 
+func (fake *FakeLocalParticipant) SetSubscriberBandwidthOverride(arg1 streamallocator.BandwidthOverride) {
+	fake.setSubscriberBandwidthOverrideMutex.Lock()
+	fake.setSubscriberBandwidthOverrideArgsForCall = append(fake.setSubscriberBandwidthOverrideArgsForCall, struct {
+		arg1 streamallocator.BandwidthOverride
+	}{arg1})
+	stub := fake.SetSubscriberBandwidthOverrideStub
+	fake.recordInvocation("SetSubscriberBandwidthOverride", []interface{}{arg1})
+	fake.setSubscriberBandwidthOverrideMutex.Unlock()
+	if stub != nil {
+		fake.SetSubscriberBandwidthOverrideStub(arg1)
+	}
+}
+
+func (fake *FakeLocalParticipant) SetSubscriberBandwidthOverrideCallCount() int {
+	fake.setSubscriberBandwidthOverrideMutex.RLock()
+	defer fake.setSubscriberBandwidthOverrideMutex.RUnlock()
+	return len(fake.setSubscriberBandwidthOverrideArgsForCall)
+}
+
+func (fake *FakeLocalParticipant) SetSubscriberBandwidthOverrideCalls(stub func(streamallocator.BandwidthOverride)) {
+	fake.setSubscriberBandwidthOverrideMutex.Lock()
+	defer fake.setSubscriberBandwidthOverrideMutex.Unlock()
+	fake.SetSubscriberBandwidthOverrideStub = stub
+}
+
+func (fake *FakeLocalParticipant) SetSubscriberBandwidthOverrideArgsForCall(i int) streamallocator.BandwidthOverride {
+	fake.setSubscriberBandwidthOverrideMutex.RLock()
+	defer fake.setSubscriberBandwidthOverrideMutex.RUnlock()
+	argsForCall := fake.setSubscriberBandwidthOverrideArgsForCall[i]
+	return argsForCall.arg1
+}
+
 func (fake *FakeLocalParticipant) SetSubscriberChannelCapacity(arg1 int64) {
 	fake.setSubscriberChannelCapacityMutex.Lock()
 	fake.setSubscriberChannelCapacityArgsForCall = append(fake.setSubscriberChannelCapacityArgsForCall, struct {
@@ -4795,6 +5255,102 @@ func (fake *FakeLocalParticipant) SetSubscriberChannelCapacityArgsForCall(i int)
 	return argsForCall.arg1
 }
 
+func (fake *FakeLocalParticipant) SetSubscriberMaxChannelCapacity(arg1 int64) {
+	fake.setSubscriberMaxChannelCapacityMutex.Lock()
+	fake.setSubscriberMaxChannelCapacityArgsForCall = append(fake.setSubscriberMaxChannelCapacityArgsForCall, struct {
+		arg1 int64
+	}{arg1})
+	stub := fake.SetSubscriberMaxChannelCapacityStub
+	fake.recordInvocation("SetSubscriberMaxChannelCapacity", []interface{}{arg1})
+	fake.setSubscriberMaxChannelCapacityMutex.Unlock()
+	if stub != nil {
+		fake.SetSubscriberMaxChannelCapacityStub(arg1)
+	}
+}
+
+func (fake *FakeLocalParticipant) SetSubscriberMaxChannelCapacityCallCount() int {
+	fake.setSubscriberMaxChannelCapacityMutex.RLock()
+	defer fake.setSubscriberMaxChannelCapacityMutex.RUnlock()
+	return len(fake.setSubscriberMaxChannelCapacityArgsForCall)
+}
+
+func (fake *FakeLocalParticipant) SetSubscriberMaxChannelCapacityCalls(stub func(int64)) {
+	fake.setSubscriberMaxChannelCapacityMutex.Lock()
+	defer fake.setSubscriberMaxChannelCapacityMutex.Unlock()
+	fake.SetSubscriberMaxChannelCapacityStub = stub
+}
+
+func (fake *FakeLocalParticipant) SetSubscriberMaxChannelCapacityArgsForCall(i int) int64 {
+	fake.setSubscriberMaxChannelCapacityMutex.RLock()
+	defer fake.setSubscriberMaxChannelCapacityMutex.RUnlock()
+	argsForCall := fake.setSubscriberMaxChannelCapacityArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FakeLocalParticipant) SetSubscriberPausedVideoPlaceholder(arg1 config.PausedVideoPlaceholder) {
+	fake.setSubscriberPausedVideoPlaceholderMutex.Lock()
+	fake.setSubscriberPausedVideoPlaceholderArgsForCall = append(fake.setSubscriberPausedVideoPlaceholderArgsForCall, struct {
+		arg1 config.PausedVideoPlaceholder
+	}{arg1})
+	stub := fake.SetSubscriberPausedVideoPlaceholderStub
+	fake.recordInvocation("SetSubscriberPausedVideoPlaceholder", []interface{}{arg1})
+	fake.setSubscriberPausedVideoPlaceholderMutex.Unlock()
+	if stub != nil {
+		fake.SetSubscriberPausedVideoPlaceholderStub(arg1)
+	}
+}
+
+func (fake *FakeLocalParticipant) SetSubscriberPausedVideoPlaceholderCallCount() int {
+	fake.setSubscriberPausedVideoPlaceholderMutex.RLock()
+	defer fake.setSubscriberPausedVideoPlaceholderMutex.RUnlock()
+	return len(fake.setSubscriberPausedVideoPlaceholderArgsForCall)
+}
+
+func (fake *FakeLocalParticipant) SetSubscriberPausedVideoPlaceholderCalls(stub func(config.PausedVideoPlaceholder)) {
+	fake.setSubscriberPausedVideoPlaceholderMutex.Lock()
+	defer fake.setSubscriberPausedVideoPlaceholderMutex.Unlock()
+	fake.SetSubscriberPausedVideoPlaceholderStub = stub
+}
+
+func (fake *FakeLocalParticipant) SetSubscriberPausedVideoPlaceholderArgsForCall(i int) config.PausedVideoPlaceholder {
+	fake.setSubscriberPausedVideoPlaceholderMutex.RLock()
+	defer fake.setSubscriberPausedVideoPlaceholderMutex.RUnlock()
+	argsForCall := fake.setSubscriberPausedVideoPlaceholderArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FakeLocalParticipant) SetSubscriberPreferredPublisher(arg1 livekit.ParticipantID) {
+	fake.setSubscriberPreferredPublisherMutex.Lock()
+	fake.setSubscriberPreferredPublisherArgsForCall = append(fake.setSubscriberPreferredPublisherArgsForCall, struct {
+		arg1 livekit.ParticipantID
+	}{arg1})
+	stub := fake.SetSubscriberPreferredPublisherStub
+	fake.recordInvocation("SetSubscriberPreferredPublisher", []interface{}{arg1})
+	fake.setSubscriberPreferredPublisherMutex.Unlock()
+	if stub != nil {
+		fake.SetSubscriberPreferredPublisherStub(arg1)
+	}
+}
+
+func (fake *FakeLocalParticipant) SetSubscriberPreferredPublisherCallCount() int {
+	fake.setSubscriberPreferredPublisherMutex.RLock()
+	defer fake.setSubscriberPreferredPublisherMutex.RUnlock()
+	return len(fake.setSubscriberPreferredPublisherArgsForCall)
+}
+
+func (fake *FakeLocalParticipant) SetSubscriberPreferredPublisherCalls(stub func(livekit.ParticipantID)) {
+	fake.setSubscriberPreferredPublisherMutex.Lock()
+	defer fake.setSubscriberPreferredPublisherMutex.Unlock()
+	fake.SetSubscriberPreferredPublisherStub = stub
+}
+
+func (fake *FakeLocalParticipant) SetSubscriberPreferredPublisherArgsForCall(i int) livekit.ParticipantID {
+	fake.setSubscriberPreferredPublisherMutex.RLock()
+	defer fake.setSubscriberPreferredPublisherMutex.RUnlock()
+	argsForCall := fake.setSubscriberPreferredPublisherArgsForCall[i]
+	return argsForCall.arg1
+}
+
 func (fake *FakeLocalParticipant) SetTrackMuted(arg1 livekit.TrackID, arg2 bool, arg3 bool) {
 	fake.setTrackMutedMutex.Lock()
 	fake.setTrackMutedArgsForCall = append(fake.setTrackMutedArgsForCall, struct {
@@ -5307,6 +5863,69 @@ func (fake *FakeLocalParticipant) UnsubscribeFromTrackArgsForCall(i int) livekit
 	return argsForCall.arg1
 }
 
+func (fake *FakeLocalParticipant) UpdateAdminSubscriptionPermission(arg1 *livekit.SubscriptionPermission, arg2 func(participantIdentity livekit.ParticipantIdentity) types.LocalParticipant, arg3 func(participantID livekit.ParticipantID) types.LocalParticipant) error {
+	fake.updateAdminSubscriptionPermissionMutex.Lock()
+	ret, specificReturn := fake.updateAdminSubscriptionPermissionReturnsOnCall[len(fake.updateAdminSubscriptionPermissionArgsForCall)]
+	fake.updateAdminSubscriptionPermissionArgsForCall = append(fake.updateAdminSubscriptionPermissionArgsForCall, struct {
+		arg1 *livekit.SubscriptionPermission
+		arg2 func(participantIdentity livekit.ParticipantIdentity) types.LocalParticipant
+		arg3 func(participantID livekit.ParticipantID) types.LocalParticipant
+	}{arg1, arg2, arg3})
+	stub := fake.UpdateAdminSubscriptionPermissionStub
+	fakeReturns := fake.updateAdminSubscriptionPermissionReturns
+	fake.recordInvocation("UpdateAdminSubscriptionPermission", []interface{}{arg1, arg2, arg3})
+	fake.updateAdminSubscriptionPermissionMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *FakeLocalParticipant) UpdateAdminSubscriptionPermissionCallCount() int {
+	fake.updateAdminSubscriptionPermissionMutex.RLock()
+	defer fake.updateAdminSubscriptionPermissionMutex.RUnlock()
+	return len(fake.updateAdminSubscriptionPermissionArgsForCall)
+}
+
+func (fake *FakeLocalParticipant) UpdateAdminSubscriptionPermissionCalls(stub func(*livekit.SubscriptionPermission, func(participantIdentity livekit.ParticipantIdentity) types.LocalParticipant, func(participantID livekit.ParticipantID) types.LocalParticipant) error) {
+	fake.updateAdminSubscriptionPermissionMutex.Lock()
+	defer fake.updateAdminSubscriptionPermissionMutex.Unlock()
+	fake.UpdateAdminSubscriptionPermissionStub = stub
+}
+
+func (fake *FakeLocalParticipant) UpdateAdminSubscriptionPermissionArgsForCall(i int) (*livekit.SubscriptionPermission, func(participantIdentity livekit.ParticipantIdentity) types.LocalParticipant, func(participantID livekit.ParticipantID) types.LocalParticipant) {
+	fake.updateAdminSubscriptionPermissionMutex.RLock()
+	defer fake.updateAdminSubscriptionPermissionMutex.RUnlock()
+	argsForCall := fake.updateAdminSubscriptionPermissionArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeLocalParticipant) UpdateAdminSubscriptionPermissionReturns(result1 error) {
+	fake.updateAdminSubscriptionPermissionMutex.Lock()
+	defer fake.updateAdminSubscriptionPermissionMutex.Unlock()
+	fake.UpdateAdminSubscriptionPermissionStub = nil
+	fake.updateAdminSubscriptionPermissionReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeLocalParticipant) UpdateAdminSubscriptionPermissionReturnsOnCall(i int, result1 error) {
+	fake.updateAdminSubscriptionPermissionMutex.Lock()
+	defer fake.updateAdminSubscriptionPermissionMutex.Unlock()
+	fake.UpdateAdminSubscriptionPermissionStub = nil
+	if fake.updateAdminSubscriptionPermissionReturnsOnCall == nil {
+		fake.updateAdminSubscriptionPermissionReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.updateAdminSubscriptionPermissionReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
 func (fake *FakeLocalParticipant) UpdateLastSeenSignal() {
 	fake.updateLastSeenSignalMutex.Lock()
 	fake.updateLastSeenSignalArgsForCall = append(fake.updateLastSeenSignalArgsForCall, struct {
@@ -5779,6 +6398,8 @@ func (fake *FakeLocalParticipant) WaitUntilSubscribedReturnsOnCall(i int, result
 }
 
 func (fake *FakeLocalParticipant) Invocations() map[string][][]interface{} {
+	fake.getEntitlementsMutex.RLock()
+	defer fake.getEntitlementsMutex.RUnlock()
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
 	fake.addICECandidateMutex.RLock()
@@ -5789,6 +6410,8 @@ func (fake *FakeLocalParticipant) Invocations() map[string][][]interface{} {
 	defer fake.addTrackToSubscriberMutex.RUnlock()
 	fake.addTransceiverFromTrackToSubscriberMutex.RLock()
 	defer fake.addTransceiverFromTrackToSubscriberMutex.RUnlock()
+	fake.adminSubscriptionPermissionMutex.RLock()
+	defer fake.adminSubscriptionPermissionMutex.RUnlock()
 	fake.cacheDownTrackMutex.RLock()
 	defer fake.cacheDownTrackMutex.RUnlock()
 	fake.canPublishDataMutex.RLock()
@@ -5825,6 +6448,8 @@ func (fake *FakeLocalParticipant) Invocations() map[string][][]interface{} {
 	defer fake.getConnectionQualityMutex.RUnlock()
 	fake.getICEConnectionTypeMutex.RLock()
 	defer fake.getICEConnectionTypeMutex.RUnlock()
+	fake.getImpairmentsMutex.RLock()
+	defer fake.getImpairmentsMutex.RUnlock()
 	fake.getLoggerMutex.RLock()
 	defer fake.getLoggerMutex.RUnlock()
 	fake.getPacerMutex.RLock()
@@ -5839,6 +6464,10 @@ func (fake *FakeLocalParticipant) Invocations() map[string][][]interface{} {
 	defer fake.getSubscribedParticipantsMutex.RUnlock()
 	fake.getSubscribedTracksMutex.RLock()
 	defer fake.getSubscribedTracksMutex.RUnlock()
+	fake.getSubscriberBandwidthDemandMutex.RLock()
+	defer fake.getSubscriberBandwidthDemandMutex.RUnlock()
+	fake.getTrackMuteStatesMutex.RLock()
+	defer fake.getTrackMuteStatesMutex.RUnlock()
 	fake.getTrailerMutex.RLock()
 	defer fake.getTrailerMutex.RUnlock()
 	fake.handleAnswerMutex.RLock()
@@ -5873,6 +6502,8 @@ func (fake *FakeLocalParticipant) Invocations() map[string][][]interface{} {
 	defer fake.isRecorderMutex.RUnlock()
 	fake.isSubscribedToMutex.RLock()
 	defer fake.isSubscribedToMutex.RUnlock()
+	fake.isUnresponsiveMutex.RLock()
+	defer fake.isUnresponsiveMutex.RUnlock()
 	fake.issueFullReconnectMutex.RLock()
 	defer fake.issueFullReconnectMutex.RUnlock()
 	fake.maybeStartMigrationMutex.RLock()
@@ -5943,8 +6574,16 @@ func (fake *FakeLocalParticipant) Invocations() map[string][][]interface{} {
 	defer fake.setSignalSourceValidMutex.RUnlock()
 	fake.setSubscriberAllowPauseMutex.RLock()
 	defer fake.setSubscriberAllowPauseMutex.RUnlock()
+	fake.setSubscriberBandwidthOverrideMutex.RLock()
+	defer fake.setSubscriberBandwidthOverrideMutex.RUnlock()
 	fake.setSubscriberChannelCapacityMutex.RLock()
 	defer fake.setSubscriberChannelCapacityMutex.RUnlock()
+	fake.setSubscriberMaxChannelCapacityMutex.RLock()
+	defer fake.setSubscriberMaxChannelCapacityMutex.RUnlock()
+	fake.setSubscriberPausedVideoPlaceholderMutex.RLock()
+	defer fake.setSubscriberPausedVideoPlaceholderMutex.RUnlock()
+	fake.setSubscriberPreferredPublisherMutex.RLock()
+	defer fake.setSubscriberPreferredPublisherMutex.RUnlock()
 	fake.setTrackMutedMutex.RLock()
 	defer fake.setTrackMutedMutex.RUnlock()
 	fake.startMutex.RLock()
@@ -5969,6 +6608,8 @@ func (fake *FakeLocalParticipant) Invocations() map[string][][]interface{} {
 	defer fake.uncacheDownTrackMutex.RUnlock()
 	fake.unsubscribeFromTrackMutex.RLock()
 	defer fake.unsubscribeFromTrackMutex.RUnlock()
+	fake.updateAdminSubscriptionPermissionMutex.RLock()
+	defer fake.updateAdminSubscriptionPermissionMutex.RUnlock()
 	fake.updateLastSeenSignalMutex.RLock()
 	defer fake.updateLastSeenSignalMutex.RUnlock()
 	fake.updateMediaLossMutex.RLock()
@@ -10,6 +10,16 @@ import (
 )
 
 type FakeParticipant struct {
+	AdminSubscriptionPermissionStub        func() *livekit.SubscriptionPermission
+	adminSubscriptionPermissionMutex       sync.RWMutex
+	adminSubscriptionPermissionArgsForCall []struct {
+	}
+	adminSubscriptionPermissionReturns struct {
+		result1 *livekit.SubscriptionPermission
+	}
+	adminSubscriptionPermissionReturnsOnCall map[int]struct {
+		result1 *livekit.SubscriptionPermission
+	}
 	CanSkipBroadcastStub        func() bool
 	canSkipBroadcastMutex       sync.RWMutex
 	canSkipBroadcastArgsForCall []struct {
@@ -191,6 +201,19 @@ type FakeParticipant struct {
 	toProtoReturnsOnCall map[int]struct {
 		result1 *livekit.ParticipantInfo
 	}
+	UpdateAdminSubscriptionPermissionStub        func(*livekit.SubscriptionPermission, func(participantIdentity livekit.ParticipantIdentity) types.LocalParticipant, func(participantID livekit.ParticipantID) types.LocalParticipant) error
+	updateAdminSubscriptionPermissionMutex       sync.RWMutex
+	updateAdminSubscriptionPermissionArgsForCall []struct {
+		arg1 *livekit.SubscriptionPermission
+		arg2 func(participantIdentity livekit.ParticipantIdentity) types.LocalParticipant
+		arg3 func(participantID livekit.ParticipantID) types.LocalParticipant
+	}
+	updateAdminSubscriptionPermissionReturns struct {
+		result1 error
+	}
+	updateAdminSubscriptionPermissionReturnsOnCall map[int]struct {
+		result1 error
+	}
 	UpdateSubscriptionPermissionStub        func(*livekit.SubscriptionPermission, utils.TimedVersion, func(participantIdentity livekit.ParticipantIdentity) types.LocalParticipant, func(participantID livekit.ParticipantID) types.LocalParticipant) error
 	updateSubscriptionPermissionMutex       sync.RWMutex
 	updateSubscriptionPermissionArgsForCall []struct {
@@ -220,6 +243,59 @@ type FakeParticipant struct {
 	invocationsMutex sync.RWMutex
 }
 
+func (fake *FakeParticipant) AdminSubscriptionPermission() *livekit.SubscriptionPermission {
+	fake.adminSubscriptionPermissionMutex.Lock()
+	ret, specificReturn := fake.adminSubscriptionPermissionReturnsOnCall[len(fake.adminSubscriptionPermissionArgsForCall)]
+	fake.adminSubscriptionPermissionArgsForCall = append(fake.adminSubscriptionPermissionArgsForCall, struct {
+	}{})
+	stub := fake.AdminSubscriptionPermissionStub
+	fakeReturns := fake.adminSubscriptionPermissionReturns
+	fake.recordInvocation("AdminSubscriptionPermission", []interface{}{})
+	fake.adminSubscriptionPermissionMutex.Unlock()
+	if stub != nil {
+		return stub()
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *FakeParticipant) AdminSubscriptionPermissionCallCount() int {
+	fake.adminSubscriptionPermissionMutex.RLock()
+	defer fake.adminSubscriptionPermissionMutex.RUnlock()
+	return len(fake.adminSubscriptionPermissionArgsForCall)
+}
+
+func (fake *FakeParticipant) AdminSubscriptionPermissionCalls(stub func() *livekit.SubscriptionPermission) {
+	fake.adminSubscriptionPermissionMutex.Lock()
+	defer fake.adminSubscriptionPermissionMutex.Unlock()
+	fake.AdminSubscriptionPermissionStub = stub
+}
+
+func (fake *FakeParticipant) AdminSubscriptionPermissionReturns(result1 *livekit.SubscriptionPermission) {
+	fake.adminSubscriptionPermissionMutex.Lock()
+	defer fake.adminSubscriptionPermissionMutex.Unlock()
+	fake.AdminSubscriptionPermissionStub = nil
+	fake.adminSubscriptionPermissionReturns = struct {
+		result1 *livekit.SubscriptionPermission
+	}{result1}
+}
+
+func (fake *FakeParticipant) AdminSubscriptionPermissionReturnsOnCall(i int, result1 *livekit.SubscriptionPermission) {
+	fake.adminSubscriptionPermissionMutex.Lock()
+	defer fake.adminSubscriptionPermissionMutex.Unlock()
+	fake.AdminSubscriptionPermissionStub = nil
+	if fake.adminSubscriptionPermissionReturnsOnCall == nil {
+		fake.adminSubscriptionPermissionReturnsOnCall = make(map[int]struct {
+			result1 *livekit.SubscriptionPermission
+		})
+	}
+	fake.adminSubscriptionPermissionReturnsOnCall[i] = struct {
+		result1 *livekit.SubscriptionPermission
+	}{result1}
+}
+
 func (fake *FakeParticipant) CanSkipBroadcast() bool {
 	fake.canSkipBroadcastMutex.Lock()
 	ret, specificReturn := fake.canSkipBroadcastReturnsOnCall[len(fake.canSkipBroadcastArgsForCall)]
@@ -1170,6 +1246,69 @@ func (fake *FakeParticipant) ToProtoReturnsOnCall(i int, result1 *livekit.Partic
 	}{result1}
 }
 
+func (fake *FakeParticipant) UpdateAdminSubscriptionPermission(arg1 *livekit.SubscriptionPermission, arg2 func(participantIdentity livekit.ParticipantIdentity) types.LocalParticipant, arg3 func(participantID livekit.ParticipantID) types.LocalParticipant) error {
+	fake.updateAdminSubscriptionPermissionMutex.Lock()
+	ret, specificReturn := fake.updateAdminSubscriptionPermissionReturnsOnCall[len(fake.updateAdminSubscriptionPermissionArgsForCall)]
+	fake.updateAdminSubscriptionPermissionArgsForCall = append(fake.updateAdminSubscriptionPermissionArgsForCall, struct {
+		arg1 *livekit.SubscriptionPermission
+		arg2 func(participantIdentity livekit.ParticipantIdentity) types.LocalParticipant
+		arg3 func(participantID livekit.ParticipantID) types.LocalParticipant
+	}{arg1, arg2, arg3})
+	stub := fake.UpdateAdminSubscriptionPermissionStub
+	fakeReturns := fake.updateAdminSubscriptionPermissionReturns
+	fake.recordInvocation("UpdateAdminSubscriptionPermission", []interface{}{arg1, arg2, arg3})
+	fake.updateAdminSubscriptionPermissionMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *FakeParticipant) UpdateAdminSubscriptionPermissionCallCount() int {
+	fake.updateAdminSubscriptionPermissionMutex.RLock()
+	defer fake.updateAdminSubscriptionPermissionMutex.RUnlock()
+	return len(fake.updateAdminSubscriptionPermissionArgsForCall)
+}
+
+func (fake *FakeParticipant) UpdateAdminSubscriptionPermissionCalls(stub func(*livekit.SubscriptionPermission, func(participantIdentity livekit.ParticipantIdentity) types.LocalParticipant, func(participantID livekit.ParticipantID) types.LocalParticipant) error) {
+	fake.updateAdminSubscriptionPermissionMutex.Lock()
+	defer fake.updateAdminSubscriptionPermissionMutex.Unlock()
+	fake.UpdateAdminSubscriptionPermissionStub = stub
+}
+
+func (fake *FakeParticipant) UpdateAdminSubscriptionPermissionArgsForCall(i int) (*livekit.SubscriptionPermission, func(participantIdentity livekit.ParticipantIdentity) types.LocalParticipant, func(participantID livekit.ParticipantID) types.LocalParticipant) {
+	fake.updateAdminSubscriptionPermissionMutex.RLock()
+	defer fake.updateAdminSubscriptionPermissionMutex.RUnlock()
+	argsForCall := fake.updateAdminSubscriptionPermissionArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeParticipant) UpdateAdminSubscriptionPermissionReturns(result1 error) {
+	fake.updateAdminSubscriptionPermissionMutex.Lock()
+	defer fake.updateAdminSubscriptionPermissionMutex.Unlock()
+	fake.UpdateAdminSubscriptionPermissionStub = nil
+	fake.updateAdminSubscriptionPermissionReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeParticipant) UpdateAdminSubscriptionPermissionReturnsOnCall(i int, result1 error) {
+	fake.updateAdminSubscriptionPermissionMutex.Lock()
+	defer fake.updateAdminSubscriptionPermissionMutex.Unlock()
+	fake.UpdateAdminSubscriptionPermissionStub = nil
+	if fake.updateAdminSubscriptionPermissionReturnsOnCall == nil {
+		fake.updateAdminSubscriptionPermissionReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.updateAdminSubscriptionPermissionReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
 func (fake *FakeParticipant) UpdateSubscriptionPermission(arg1 *livekit.SubscriptionPermission, arg2 utils.TimedVersion, arg3 func(participantIdentity livekit.ParticipantIdentity) types.LocalParticipant, arg4 func(participantID livekit.ParticipantID) types.LocalParticipant) error {
 	fake.updateSubscriptionPermissionMutex.Lock()
 	ret, specificReturn := fake.updateSubscriptionPermissionReturnsOnCall[len(fake.updateSubscriptionPermissionArgsForCall)]
@@ -1298,6 +1437,8 @@ func (fake *FakeParticipant) UpdateVideoLayersReturnsOnCall(i int, result1 error
 func (fake *FakeParticipant) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
+	fake.adminSubscriptionPermissionMutex.RLock()
+	defer fake.adminSubscriptionPermissionMutex.RUnlock()
 	fake.canSkipBroadcastMutex.RLock()
 	defer fake.canSkipBroadcastMutex.RUnlock()
 	fake.closeMutex.RLock()
@@ -1336,6 +1477,8 @@ func (fake *FakeParticipant) Invocations() map[string][][]interface{} {
 	defer fake.subscriptionPermissionMutex.RUnlock()
 	fake.toProtoMutex.RLock()
 	defer fake.toProtoMutex.RUnlock()
+	fake.updateAdminSubscriptionPermissionMutex.RLock()
+	defer fake.updateAdminSubscriptionPermissionMutex.RUnlock()
 	fake.updateSubscriptionPermissionMutex.RLock()
 	defer fake.updateSubscriptionPermissionMutex.RUnlock()
 	fake.updateVideoLayersMutex.RLock()
@@ -23,6 +23,22 @@ type FakeWebsocketClient struct {
 		result2 []byte
 		result3 error
 	}
+	SetPongHandlerStub        func(func(string) error)
+	setPongHandlerMutex       sync.RWMutex
+	setPongHandlerArgsForCall []struct {
+		arg1 func(string) error
+	}
+	SetReadDeadlineStub        func(time.Time) error
+	setReadDeadlineMutex       sync.RWMutex
+	setReadDeadlineArgsForCall []struct {
+		arg1 time.Time
+	}
+	setReadDeadlineReturns struct {
+		result1 error
+	}
+	setReadDeadlineReturnsOnCall map[int]struct {
+		result1 error
+	}
 	WriteControlStub        func(int, []byte, time.Time) error
 	writeControlMutex       sync.RWMutex
 	writeControlArgsForCall []struct {
@@ -111,6 +127,99 @@ func (fake *FakeWebsocketClient) ReadMessageReturnsOnCall(i int, result1 int, re
 	}{result1, result2, result3}
 }
 
+func (fake *FakeWebsocketClient) SetPongHandler(arg1 func(string) error) {
+	fake.setPongHandlerMutex.Lock()
+	fake.setPongHandlerArgsForCall = append(fake.setPongHandlerArgsForCall, struct {
+		arg1 func(string) error
+	}{arg1})
+	stub := fake.SetPongHandlerStub
+	fake.recordInvocation("SetPongHandler", []interface{}{arg1})
+	fake.setPongHandlerMutex.Unlock()
+	if stub != nil {
+		fake.SetPongHandlerStub(arg1)
+	}
+}
+
+func (fake *FakeWebsocketClient) SetPongHandlerCallCount() int {
+	fake.setPongHandlerMutex.RLock()
+	defer fake.setPongHandlerMutex.RUnlock()
+	return len(fake.setPongHandlerArgsForCall)
+}
+
+func (fake *FakeWebsocketClient) SetPongHandlerCalls(stub func(func(string) error)) {
+	fake.setPongHandlerMutex.Lock()
+	defer fake.setPongHandlerMutex.Unlock()
+	fake.SetPongHandlerStub = stub
+}
+
+func (fake *FakeWebsocketClient) SetPongHandlerArgsForCall(i int) func(string) error {
+	fake.setPongHandlerMutex.RLock()
+	defer fake.setPongHandlerMutex.RUnlock()
+	argsForCall := fake.setPongHandlerArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FakeWebsocketClient) SetReadDeadline(arg1 time.Time) error {
+	fake.setReadDeadlineMutex.Lock()
+	ret, specificReturn := fake.setReadDeadlineReturnsOnCall[len(fake.setReadDeadlineArgsForCall)]
+	fake.setReadDeadlineArgsForCall = append(fake.setReadDeadlineArgsForCall, struct {
+		arg1 time.Time
+	}{arg1})
+	stub := fake.SetReadDeadlineStub
+	fakeReturns := fake.setReadDeadlineReturns
+	fake.recordInvocation("SetReadDeadline", []interface{}{arg1})
+	fake.setReadDeadlineMutex.Unlock()
+	if stub != nil {
+		return stub(arg1)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *FakeWebsocketClient) SetReadDeadlineCallCount() int {
+	fake.setReadDeadlineMutex.RLock()
+	defer fake.setReadDeadlineMutex.RUnlock()
+	return len(fake.setReadDeadlineArgsForCall)
+}
+
+func (fake *FakeWebsocketClient) SetReadDeadlineCalls(stub func(time.Time) error) {
+	fake.setReadDeadlineMutex.Lock()
+	defer fake.setReadDeadlineMutex.Unlock()
+	fake.SetReadDeadlineStub = stub
+}
+
+func (fake *FakeWebsocketClient) SetReadDeadlineArgsForCall(i int) time.Time {
+	fake.setReadDeadlineMutex.RLock()
+	defer fake.setReadDeadlineMutex.RUnlock()
+	argsForCall := fake.setReadDeadlineArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FakeWebsocketClient) SetReadDeadlineReturns(result1 error) {
+	fake.setReadDeadlineMutex.Lock()
+	defer fake.setReadDeadlineMutex.Unlock()
+	fake.SetReadDeadlineStub = nil
+	fake.setReadDeadlineReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeWebsocketClient) SetReadDeadlineReturnsOnCall(i int, result1 error) {
+	fake.setReadDeadlineMutex.Lock()
+	defer fake.setReadDeadlineMutex.Unlock()
+	fake.SetReadDeadlineStub = nil
+	if fake.setReadDeadlineReturnsOnCall == nil {
+		fake.setReadDeadlineReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.setReadDeadlineReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
 func (fake *FakeWebsocketClient) WriteControl(arg1 int, arg2 []byte, arg3 time.Time) error {
 	var arg2Copy []byte
 	if arg2 != nil {
@@ -251,6 +360,10 @@ func (fake *FakeWebsocketClient) Invocations() map[string][][]interface{} {
 	defer fake.invocationsMutex.RUnlock()
 	fake.readMessageMutex.RLock()
 	defer fake.readMessageMutex.RUnlock()
+	fake.setPongHandlerMutex.RLock()
+	defer fake.setPongHandlerMutex.RUnlock()
+	fake.setReadDeadlineMutex.RLock()
+	defer fake.setReadDeadlineMutex.RUnlock()
 	fake.writeControlMutex.RLock()
 	defer fake.writeControlMutex.RUnlock()
 	fake.writeMessageMutex.RLock()
@@ -47,6 +47,9 @@ type UpTrackManager struct {
 	subscriptionPermissionVersion utils.TimedVersion
 	// subscriber permission for published tracks
 	subscriberPermissions map[livekit.ParticipantIdentity]*livekit.TrackPermission // subscriberIdentity => *livekit.TrackPermission
+	// restrictions set by an admin, applied on top of what the participant allows
+	adminSubscriptionPermission *livekit.SubscriptionPermission
+	adminSubscriberPermissions  map[livekit.ParticipantIdentity]*livekit.TrackPermission
 
 	lock sync.RWMutex
 
@@ -190,17 +193,53 @@ func (u *UpTrackManager) UpdateSubscriptionPermission(
 		"permissions", u.subscriptionPermission.String(),
 		"version", u.subscriptionPermissionVersion.ToProto().String(),
 	)
-	if err := u.parseSubscriptionPermissionsLocked(subscriptionPermission, func(pID livekit.ParticipantID) types.LocalParticipant {
+	subscriberPermissions, err := u.parseSubscriptionPermissionsLocked(subscriptionPermission, func(pID livekit.ParticipantID) types.LocalParticipant {
 		u.lock.Unlock()
 		p := resolverBySid(pID)
 		u.lock.Lock()
 		return p
-	}); err != nil {
+	})
+	if err != nil {
 		// when failed, do not override previous permissions
 		u.params.Logger.Errorw("failed updating subscription permission", err)
 		u.lock.Unlock()
 		return err
 	}
+	u.subscriberPermissions = subscriberPermissions
+	u.lock.Unlock()
+
+	u.maybeRevokeSubscriptions(resolverByIdentity)
+
+	return nil
+}
+
+// UpdateAdminSubscriptionPermission restricts who may subscribe to the published tracks regardless of what the
+// participant itself allows, a subscriber needs to be allowed by both. A nil permission lifts the restrictions.
+func (u *UpTrackManager) UpdateAdminSubscriptionPermission(
+	subscriptionPermission *livekit.SubscriptionPermission,
+	resolverByIdentity func(participantIdentity livekit.ParticipantIdentity) types.LocalParticipant,
+	resolverBySid func(participantID livekit.ParticipantID) types.LocalParticipant,
+) error {
+	u.lock.Lock()
+	var subscriberPermissions map[livekit.ParticipantIdentity]*livekit.TrackPermission
+	if subscriptionPermission != nil {
+		var err error
+		subscriberPermissions, err = u.parseSubscriptionPermissionsLocked(subscriptionPermission, func(pID livekit.ParticipantID) types.LocalParticipant {
+			u.lock.Unlock()
+			p := resolverBySid(pID)
+			u.lock.Lock()
+			return p
+		})
+		if err != nil {
+			u.params.Logger.Errorw("failed updating admin subscription permission", err)
+			u.lock.Unlock()
+			return err
+		}
+	}
+
+	u.params.Logger.Debugw("updating admin subscription permission", "permissions", subscriptionPermission.String())
+	u.adminSubscriptionPermission = subscriptionPermission
+	u.adminSubscriberPermissions = subscriberPermissions
 	u.lock.Unlock()
 
 	u.maybeRevokeSubscriptions(resolverByIdentity)
@@ -208,6 +247,13 @@ func (u *UpTrackManager) UpdateSubscriptionPermission(
 	return nil
 }
 
+func (u *UpTrackManager) AdminSubscriptionPermission() *livekit.SubscriptionPermission {
+	u.lock.RLock()
+	defer u.lock.RUnlock()
+
+	return u.adminSubscriptionPermission
+}
+
 func (u *UpTrackManager) SubscriptionPermission() (*livekit.SubscriptionPermission, utils.TimedVersion) {
 	u.lock.RLock()
 	defer u.lock.RUnlock()
@@ -287,14 +333,13 @@ func (u *UpTrackManager) getPublishedTrackLocked(trackID livekit.TrackID) types.
 func (u *UpTrackManager) parseSubscriptionPermissionsLocked(
 	subscriptionPermission *livekit.SubscriptionPermission,
 	resolver func(participantID livekit.ParticipantID) types.LocalParticipant,
-) error {
+) (map[livekit.ParticipantIdentity]*livekit.TrackPermission, error) {
 	// every update overrides the existing
 
 	// all_participants takes precedence
 	if subscriptionPermission.AllParticipants {
 		// everything is allowed, nothing else to do
-		u.subscriberPermissions = nil
-		return nil
+		return nil, nil
 	}
 
 	// per participant permissions
@@ -303,7 +348,7 @@ func (u *UpTrackManager) parseSubscriptionPermissionsLocked(
 		subscriberIdentity := livekit.ParticipantIdentity(trackPerms.ParticipantIdentity)
 		if subscriberIdentity == "" {
 			if trackPerms.ParticipantSid == "" {
-				return ErrSubscriptionPermissionNeedsId
+				return nil, ErrSubscriptionPermissionNeedsId
 			}
 
 			sub := resolver(livekit.ParticipantID(trackPerms.ParticipantSid))
@@ -328,17 +373,24 @@ func (u *UpTrackManager) parseSubscriptionPermissionsLocked(
 		subscriberPermissions[subscriberIdentity] = trackPerms
 	}
 
-	u.subscriberPermissions = subscriberPermissions
-
-	return nil
+	return subscriberPermissions, nil
 }
 
 func (u *UpTrackManager) hasPermissionLocked(trackID livekit.TrackID, subscriberIdentity livekit.ParticipantIdentity) bool {
-	if u.subscriberPermissions == nil {
+	return isAllowedBy(u.subscriberPermissions, trackID, subscriberIdentity) &&
+		isAllowedBy(u.adminSubscriberPermissions, trackID, subscriberIdentity)
+}
+
+func isAllowedBy(
+	subscriberPermissions map[livekit.ParticipantIdentity]*livekit.TrackPermission,
+	trackID livekit.TrackID,
+	subscriberIdentity livekit.ParticipantIdentity,
+) bool {
+	if subscriberPermissions == nil {
 		return true
 	}
 
-	perms, ok := u.subscriberPermissions[subscriberIdentity]
+	perms, ok := subscriberPermissions[subscriberIdentity]
 	if !ok {
 		return false
 	}
@@ -359,22 +411,19 @@ func (u *UpTrackManager) hasPermissionLocked(trackID livekit.TrackID, subscriber
 // returns a list of participants that are allowed to subscribe to the track. if nil is returned, it means everyone is
 // allowed to subscribe to this track
 func (u *UpTrackManager) getAllowedSubscribersLocked(trackID livekit.TrackID) []livekit.ParticipantIdentity {
-	if u.subscriberPermissions == nil {
+	if u.subscriberPermissions == nil && u.adminSubscriberPermissions == nil {
 		return nil
 	}
 
+	candidates := u.subscriberPermissions
+	if candidates == nil {
+		candidates = u.adminSubscriberPermissions
+	}
+
 	allowed := make([]livekit.ParticipantIdentity, 0)
-	for subscriberIdentity, perms := range u.subscriberPermissions {
-		if perms.AllTracks {
+	for subscriberIdentity := range candidates {
+		if u.hasPermissionLocked(trackID, subscriberIdentity) {
 			allowed = append(allowed, subscriberIdentity)
-			continue
-		}
-
-		for _, sid := range perms.TrackSids {
-			if livekit.TrackID(sid) == trackID {
-				allowed = append(allowed, subscriberIdentity)
-				break
-			}
 		}
 	}
 
@@ -326,4 +326,69 @@ func TestSubscriptionPermission(t *testing.T) {
 		require.False(t, um.hasPermissionLocked("screen", "p3"))
 		require.False(t, um.hasPermissionLocked("watch", "p3"))
 	})
+
+	t.Run("admin permission restricts participant permission", func(t *testing.T) {
+		um := NewUpTrackManager(defaultUptrackManagerParams)
+		vg := utils.NewDefaultTimedVersionGenerator()
+
+		tra := &typesfakes.FakeMediaTrack{}
+		tra.IDReturns("audio")
+		um.publishedTracks["audio"] = tra
+
+		trv := &typesfakes.FakeMediaTrack{}
+		trv.IDReturns("video")
+		um.publishedTracks["video"] = trv
+
+		// participant allows everything, admin only allows p1 to all tracks and p2 to audio
+		um.UpdateSubscriptionPermission(&livekit.SubscriptionPermission{AllParticipants: true}, vg.Next(), nil, nil)
+		err := um.UpdateAdminSubscriptionPermission(&livekit.SubscriptionPermission{
+			TrackPermissions: []*livekit.TrackPermission{
+				{
+					ParticipantIdentity: "p1",
+					AllTracks:           true,
+				},
+				{
+					ParticipantIdentity: "p2",
+					TrackSids:           []string{"audio"},
+				},
+			},
+		}, nil, nil)
+		require.NoError(t, err)
+		require.True(t, um.hasPermissionLocked("audio", "p1"))
+		require.True(t, um.hasPermissionLocked("video", "p1"))
+		require.True(t, um.hasPermissionLocked("audio", "p2"))
+		require.False(t, um.hasPermissionLocked("video", "p2"))
+		require.False(t, um.hasPermissionLocked("audio", "p3"))
+
+		// existing subscriptions are revoked
+		require.Equal(t, 1, trv.RevokeDisallowedSubscribersCallCount())
+		require.ElementsMatch(t, []livekit.ParticipantIdentity{"p1"}, trv.RevokeDisallowedSubscribersArgsForCall(0))
+		require.ElementsMatch(t, []livekit.ParticipantIdentity{"p1", "p2"}, tra.RevokeDisallowedSubscribersArgsForCall(0))
+
+		// participant narrows it further, both need to allow
+		um.UpdateSubscriptionPermission(&livekit.SubscriptionPermission{
+			TrackPermissions: []*livekit.TrackPermission{
+				{
+					ParticipantIdentity: "p2",
+					AllTracks:           true,
+				},
+				{
+					ParticipantIdentity: "p3",
+					AllTracks:           true,
+				},
+			},
+		}, vg.Next(), nil, nil)
+		require.False(t, um.hasPermissionLocked("audio", "p1"))
+		require.True(t, um.hasPermissionLocked("audio", "p2"))
+		require.False(t, um.hasPermissionLocked("video", "p2"))
+		require.False(t, um.hasPermissionLocked("audio", "p3"))
+		require.ElementsMatch(t, []livekit.ParticipantIdentity{"p2"}, tra.RevokeDisallowedSubscribersArgsForCall(1))
+
+		// lifting admin restrictions leaves participant permissions in place
+		require.NoError(t, um.UpdateAdminSubscriptionPermission(nil, nil, nil))
+		require.Nil(t, um.AdminSubscriptionPermission())
+		require.False(t, um.hasPermissionLocked("audio", "p1"))
+		require.True(t, um.hasPermissionLocked("video", "p2"))
+		require.True(t, um.hasPermissionLocked("audio", "p3"))
+	})
 }
@@ -37,6 +37,8 @@ type WrappedReceiverParams struct {
 	UpstreamCodecs []webrtc.RTPCodecParameters
 	Logger         logger.Logger
 	DisableRed     bool
+	// video is offered in h264 as well, transcoded for subscribers that cannot decode the published codecs
+	Transcode bool
 }
 
 type WrappedReceiver struct {
@@ -71,6 +73,22 @@ func NewWrappedReceiver(params WrappedReceiverParams) *WrappedReceiver {
 			codecs[0], codecs[1] = codecs[1], codecs[0]
 		}
 	}
+	if params.Transcode && len(codecs) > 0 && strings.HasPrefix(strings.ToLower(codecs[0].MimeType), "video/") {
+		hasH264 := false
+		for _, c := range codecs {
+			if strings.EqualFold(c.MimeType, webrtc.MimeTypeH264) {
+				hasH264 = true
+				break
+			}
+		}
+		if !hasH264 {
+			// offered last, only subscribers that cannot decode the published codecs get the transcoded rendition
+			codecs = append(codecs, webrtc.RTPCodecParameters{
+				RTPCodecCapability: h264TranscodeCodecCapability,
+				PayloadType:        125,
+			})
+		}
+	}
 
 	return &WrappedReceiver{
 		params:    params,
@@ -102,6 +120,20 @@ func (r *WrappedReceiver) DetermineReceiver(codec webrtc.RTPCodecCapability) {
 			break
 		}
 	}
+	if r.TrackReceiver == nil && r.params.Transcode {
+		for _, c := range r.codecs {
+			if !strings.EqualFold(c.MimeType, codec.MimeType) {
+				continue
+			}
+			for _, receiver := range r.receivers {
+				if tr := receiver.GetTranscodedReceiver(c); tr != receiver {
+					r.TrackReceiver = tr
+					break
+				}
+			}
+			break
+		}
+	}
 	if r.TrackReceiver == nil {
 		r.params.Logger.Errorw("can't determine receiver for codec", nil, "codec", codec.MimeType)
 		if len(r.receivers) > 0 {
@@ -310,6 +342,15 @@ func (d *DummyReceiver) GetRedReceiver() sfu.TrackReceiver {
 	return d
 }
 
+func (d *DummyReceiver) GetTranscodedReceiver(codec webrtc.RTPCodecParameters) sfu.TrackReceiver {
+	if r, ok := d.receiver.Load().(sfu.TrackReceiver); ok {
+		if tr := r.GetTranscodedReceiver(codec); tr != r {
+			return tr
+		}
+	}
+	return d
+}
+
 func (d *DummyReceiver) GetCalculatedClockRate(layer int32) uint32 {
 	if r, ok := d.receiver.Load().(sfu.TrackReceiver); ok {
 		return r.GetCalculatedClockRate(layer)
@@ -0,0 +1,273 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-jose/go-jose/v3/jwt"
+
+	"github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/logger"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/telemetry/prometheus"
+)
+
+const (
+	oidcDiscoveryPath = "/.well-known/openid-configuration"
+	// discovery is retried on requests at most this often while the provider is unreachable
+	oidcDiscoveryRetryInterval = 30 * time.Second
+)
+
+// path prefixes of RoomService, campus and debug endpoints
+var adminOIDCPaths = []string{livekit.RoomServicePathPrefix, "/campus", "/debug/"}
+
+var (
+	ErrMissingOIDCToken = errors.New("an OIDC token is required for admin endpoints")
+	ErrInvalidOIDCToken = errors.New("invalid OIDC token")
+	ErrOIDCClaimMissing = errors.New("OIDC token lacks a required claim")
+)
+
+// AdminOIDC requires a token of an OpenID Connect provider on admin endpoints, in addition to the API key token.
+// The keys of the provider are discovered from its issuer URL and refreshed like token keys
+type AdminOIDC struct {
+	conf   *config.AdminOIDCConfig
+	client *http.Client
+
+	lock          sync.Mutex
+	keys          *TokenKeys
+	lastDiscovery time.Time
+	stopped       bool
+}
+
+// NewAdminOIDC returns nil when no issuer is configured
+func NewAdminOIDC(conf *config.AdminOIDCConfig) *AdminOIDC {
+	if conf.Issuer == "" {
+		return nil
+	}
+	return &AdminOIDC{
+		conf:   conf,
+		client: &http.Client{Timeout: jwksFetchTimeout},
+	}
+}
+
+func (o *AdminOIDC) Start() {
+	if o == nil {
+		return
+	}
+	if _, err := o.tokenKeys(); err != nil {
+		// admin requests are rejected until the provider can be reached
+		logger.Warnw("could not discover OIDC provider keys", err, "issuer", o.conf.Issuer)
+	}
+}
+
+func (o *AdminOIDC) Stop() {
+	if o == nil {
+		return
+	}
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	o.stopped = true
+	o.keys.Stop()
+}
+
+// ServeHTTP rejects requests to admin endpoints without a valid OIDC token, it runs after API key auth. The local
+// control socket does not go through middlewares
+func (o *AdminOIDC) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	if !isAdminPath(r.URL.Path) {
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	subject, err := o.verify(r)
+	if err != nil {
+		prometheus.RecordAuthFailure("invalid_oidc_token", GetAPIKey(r.Context()))
+		handleError(w, http.StatusUnauthorized, err, "path", r.URL.Path)
+		return
+	}
+	logger.Debugw("admin request authenticated with OIDC", "subject", subject, "path", r.URL.Path)
+	next.ServeHTTP(w, r)
+}
+
+func isAdminPath(path string) bool {
+	for _, prefix := range adminOIDCPaths {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// verify returns the subject of the OIDC token of the request
+func (o *AdminOIDC) verify(r *http.Request) (string, error) {
+	raw := strings.TrimPrefix(strings.TrimSpace(r.Header.Get(o.conf.Header)), bearerPrefix)
+	if raw == "" {
+		return "", ErrMissingOIDCToken
+	}
+	tok, err := jwt.ParseSigned(raw)
+	if err != nil || len(tok.Headers) != 1 || !isAsymmetricAlgorithm(tok.Headers[0].Algorithm) {
+		return "", ErrInvalidOIDCToken
+	}
+
+	keys, err := o.tokenKeys()
+	if err != nil {
+		return "", err
+	}
+	key, err := keys.GetKey(tok.Headers[0].Algorithm, tok.Headers[0].KeyID, o.conf.Issuer)
+	if err != nil {
+		return "", err
+	}
+
+	claims := jwt.Claims{}
+	custom := map[string]interface{}{}
+	if err = tok.Claims(key, &claims, &custom); err != nil {
+		return "", ErrInvalidOIDCToken
+	}
+	if claims.Expiry == nil {
+		return "", fmt.Errorf("%w: exp claim is required", ErrInvalidOIDCToken)
+	}
+	expected := jwt.Expected{Issuer: o.conf.Issuer, Time: time.Now()}
+	if o.conf.Audience != "" {
+		expected.Audience = jwt.Audience{o.conf.Audience}
+	}
+	if err = claims.ValidateWithLeeway(expected, jwt.DefaultLeeway); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidOIDCToken, err)
+	}
+	for name, allowed := range o.conf.RequiredClaims {
+		if !claimMatches(custom[name], allowed) {
+			return "", fmt.Errorf("%w: %s", ErrOIDCClaimMissing, name)
+		}
+	}
+	return claims.Subject, nil
+}
+
+// tokenKeys returns the keys of the provider, discovering them on first use
+func (o *AdminOIDC) tokenKeys() (*TokenKeys, error) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	if o.keys != nil {
+		return o.keys, nil
+	}
+	if o.stopped {
+		return nil, ErrUnknownTokenKey
+	}
+	if time.Since(o.lastDiscovery) < oidcDiscoveryRetryInterval {
+		return nil, ErrUnknownTokenKey
+	}
+	o.lastDiscovery = time.Now()
+
+	jwksURL := o.conf.JWKSURL
+	if jwksURL == "" {
+		var err error
+		if jwksURL, err = o.discover(); err != nil {
+			return nil, err
+		}
+	}
+	keys, err := NewTokenKeys(&config.TokenKeysConfig{
+		JWKSURL:                jwksURL,
+		JWKSRefreshInterval:    o.conf.KeyRefreshInterval,
+		JWKSMinRefreshInterval: oidcDiscoveryRetryInterval,
+		Issuers:                []string{o.conf.Issuer},
+	})
+	if err != nil {
+		return nil, err
+	}
+	keys.Start()
+	o.keys = keys
+	return keys, nil
+}
+
+// discover returns the key set URL of the provider configuration
+func (o *AdminOIDC) discover() (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), jwksFetchTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(o.conf.Issuer, "/")+oidcDiscoveryPath, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/json")
+	res, err := o.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status fetching OIDC configuration: %d", res.StatusCode)
+	}
+
+	var discovery struct {
+		Issuer  string `json:"issuer"`
+		JWKSURI string `json:"jwks_uri"`
+	}
+	if err = json.NewDecoder(res.Body).Decode(&discovery); err != nil {
+		return "", err
+	}
+	if discovery.Issuer != o.conf.Issuer {
+		return "", fmt.Errorf("OIDC configuration is for issuer %s", discovery.Issuer)
+	}
+	if discovery.JWKSURI == "" {
+		return "", errors.New("OIDC configuration has no jwks_uri")
+	}
+	return discovery.JWKSURI, nil
+}
+
+// claimMatches reports whether a claim value is, or for arrays contains, one of the allowed values. Any value
+// matches when none are listed
+func claimMatches(value interface{}, allowed []string) bool {
+	if value == nil {
+		return false
+	}
+	if len(allowed) == 0 {
+		return true
+	}
+
+	var values []interface{}
+	if vs, ok := value.([]interface{}); ok {
+		values = vs
+	} else {
+		values = []interface{}{value}
+	}
+	for _, v := range values {
+		var s string
+		switch v := v.(type) {
+		case string:
+			s = v
+		case bool:
+			s = strconv.FormatBool(v)
+		case float64:
+			s = strconv.FormatFloat(v, 'f', -1, 64)
+		default:
+			continue
+		}
+		for _, a := range allowed {
+			if s == a {
+				return true
+			}
+		}
+	}
+	return false
+}
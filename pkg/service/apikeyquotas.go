@@ -0,0 +1,75 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+const apiKeyQuotasPath = "/api_key_quotas"
+
+type apiKeyQuotasResponse struct {
+	Month string         `json:"month"`
+	Keys  []*APIKeyUsage `json:"keys"`
+}
+
+// APIKeyQuotaService reports the quotas of API keys and their usage across the cluster, ?api_key=<key> narrows it
+// to one key. Egress bitrate is the one of this node
+type APIKeyQuotaService struct {
+	quotas *APIKeyQuotas
+}
+
+func NewAPIKeyQuotaService(quotas *APIKeyQuotas) *APIKeyQuotaService {
+	return &APIKeyQuotaService{
+		quotas: quotas,
+	}
+}
+
+func (s *APIKeyQuotaService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := EnsureListPermission(r.Context()); err != nil {
+		handleError(w, http.StatusUnauthorized, err)
+		return
+	}
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if s.quotas == nil {
+		handleError(w, http.StatusNotImplemented, ErrQuotasUnsupported)
+		return
+	}
+
+	usage, err := s.quotas.GetUsage(r.Context())
+	if err != nil {
+		handleError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	res := &apiKeyQuotasResponse{
+		Month: quotaMonth(time.Now()),
+		Keys:  []*APIKeyUsage{},
+	}
+	apiKey := r.URL.Query().Get("api_key")
+	for _, u := range usage {
+		if apiKey == "" || u.APIKey == apiKey {
+			res.Keys = append(res.Keys, u)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(res)
+}
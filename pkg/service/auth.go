@@ -18,12 +18,19 @@ import (
 	"context"
 	"errors"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/go-jose/go-jose/v3/jwt"
 	"github.com/twitchtv/twirp"
 
 	"github.com/livekit/protocol/auth"
 	"github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/logger"
+
+	"github.com/livekit/livekit-server/pkg/routing"
+	"github.com/livekit/livekit-server/pkg/telemetry/prometheus"
 )
 
 const (
@@ -34,20 +41,38 @@ const (
 
 type grantsKey struct{}
 
+type apiKeyKey struct{}
+
+type entitlementsKey struct{}
+
+type tokenKey struct{}
+
+type localControlKey struct{}
+
 var (
 	ErrPermissionDenied          = errors.New("permissions denied")
 	ErrMissingAuthorization      = errors.New("invalid authorization header. Must start with " + bearerPrefix)
 	ErrInvalidAuthorizationToken = errors.New("invalid authorization token")
+	ErrTooManyAuthFailures       = errors.New("too many failed authentication attempts")
 )
 
 // authentication middleware
 type APIKeyAuthMiddleware struct {
-	provider auth.KeyProvider
+	provider     auth.KeyProvider
+	lockout      *AuthLockout
+	resumeTokens ResumeTokenStore
+	tokenKeys    *TokenKeys
 }
 
-func NewAPIKeyAuthMiddleware(provider auth.KeyProvider) *APIKeyAuthMiddleware {
+// NewAPIKeyAuthMiddleware creates the auth middleware, lockout is optional. Expired tokens are accepted on
+// signal reconnect when resumeTokens is set and holds a resume token for the session. RS256 and ES256 tokens are
+// only accepted when tokenKeys is set
+func NewAPIKeyAuthMiddleware(provider auth.KeyProvider, lockout *AuthLockout, resumeTokens ResumeTokenStore, tokenKeys *TokenKeys) *APIKeyAuthMiddleware {
 	return &APIKeyAuthMiddleware{
-		provider: provider,
+		provider:     provider,
+		lockout:      lockout,
+		resumeTokens: resumeTokens,
+		tokenKeys:    tokenKeys,
 	}
 }
 
@@ -56,11 +81,20 @@ func (m *APIKeyAuthMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request,
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 	}
 
+	clientIP := m.lockout.ClientIP(r)
+	if lockedFor := m.lockout.LockedFor(clientIP); lockedFor > 0 {
+		prometheus.RecordAuthLockedOutRequest()
+		w.Header().Set("Retry-After", strconv.Itoa(int(lockedFor.Round(time.Second)/time.Second)))
+		handleError(w, http.StatusTooManyRequests, ErrTooManyAuthFailures, "clientIP", clientIP)
+		return
+	}
+
 	authHeader := r.Header.Get(authorizationHeader)
 	var authToken string
 
 	if authHeader != "" {
 		if !strings.HasPrefix(authHeader, bearerPrefix) {
+			m.recordFailure(clientIP, "missing_bearer", "")
 			handleError(w, http.StatusUnauthorized, ErrMissingAuthorization)
 			return
 		}
@@ -74,30 +108,86 @@ func (m *APIKeyAuthMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request,
 	if authToken != "" {
 		v, err := auth.ParseAPIToken(authToken)
 		if err != nil {
+			m.recordFailure(clientIP, "malformed_token", "")
 			handleError(w, http.StatusUnauthorized, ErrInvalidAuthorizationToken)
 			return
 		}
 
-		secret := m.provider.GetSecret(v.APIKey())
-		if secret == "" {
-			handleError(w, http.StatusUnauthorized, errors.New("invalid API key: "+v.APIKey()))
+		key, err := m.verificationKey(v, authToken)
+		if err != nil {
+			m.recordFailure(clientIP, "unknown_key", "")
+			handleError(w, http.StatusUnauthorized, err)
 			return
 		}
 
-		grants, err := v.Verify(secret)
+		grants, err := v.Verify(key)
+		if errors.Is(err, jwt.ErrExpired) && m.resumeTokens != nil && isResumeRequest(r) {
+			grants, err = verifyResume(r.Context(), m.resumeTokens, r, v, authToken, key)
+			if err == nil {
+				logger.Infow("resuming session with expired token",
+					"participant", v.Identity(),
+					"pID", r.FormValue("sid"),
+				)
+			}
+		}
 		if err != nil {
+			m.recordFailure(clientIP, "invalid_token", v.APIKey())
 			handleError(w, http.StatusUnauthorized, errors.New("invalid token: "+authToken+", error: "+err.Error()))
 			return
 		}
+		entitlements, err := routing.ParseEntitlements(authToken)
+		if err != nil {
+			m.recordFailure(clientIP, "invalid_entitlements", v.APIKey())
+			handleError(w, http.StatusUnauthorized, errors.New("invalid token entitlements: "+err.Error()))
+			return
+		}
+		m.lockout.RecordSuccess(clientIP)
+		prometheus.RecordAPIKeyTokenValidated(v.APIKey())
 
 		// set grants in context
 		ctx := r.Context()
-		r = r.WithContext(context.WithValue(ctx, grantsKey{}, grants))
+		ctx = context.WithValue(ctx, grantsKey{}, grants)
+		ctx = context.WithValue(ctx, entitlementsKey{}, entitlements)
+		ctx = context.WithValue(ctx, tokenKey{}, authToken)
+		r = r.WithContext(context.WithValue(ctx, apiKeyKey{}, v.APIKey()))
 	}
 
 	next.ServeHTTP(w, r)
 }
 
+// verificationKey returns the API secret of HMAC signed tokens, or the public key of the identity provider that
+// signed the token. The signing algorithm picks the kind of key, so a public key is never used as an HMAC secret
+func (m *APIKeyAuthMiddleware) verificationKey(v *auth.APIKeyTokenVerifier, rawToken string) (interface{}, error) {
+	tok, err := jwt.ParseSigned(rawToken)
+	if err != nil {
+		return nil, err
+	}
+	if len(tok.Headers) != 1 {
+		return nil, ErrInvalidAuthorizationToken
+	}
+
+	header := tok.Headers[0]
+	if isAsymmetricAlgorithm(header.Algorithm) {
+		return m.tokenKeys.GetKey(header.Algorithm, header.KeyID, v.APIKey())
+	}
+
+	secret := m.provider.GetSecret(v.APIKey())
+	if secret == "" {
+		return nil, errors.New("invalid API key: " + v.APIKey())
+	}
+	return secret, nil
+}
+
+func (m *APIKeyAuthMiddleware) recordFailure(clientIP string, reason string, apiKey string) {
+	prometheus.RecordAuthFailure(reason, apiKey)
+	if lockedFor := m.lockout.RecordFailure(clientIP); lockedFor > 0 {
+		logger.Infow("locking out client after repeated authentication failures",
+			"clientIP", clientIP,
+			"duration", lockedFor,
+		)
+	}
+}
+
 func GetGrants(ctx context.Context) *auth.ClaimGrants {
 	val := ctx.Value(grantsKey{})
 	claims, ok := val.(*auth.ClaimGrants)
@@ -107,10 +197,38 @@ func GetGrants(ctx context.Context) *auth.ClaimGrants {
 	return claims
 }
 
+// GetAPIKey returns the API key the request token was signed with
+func GetAPIKey(ctx context.Context) string {
+	apiKey, _ := ctx.Value(apiKeyKey{}).(string)
+	return apiKey
+}
+
+// GetEntitlements returns the entitlements claim of the request token, nil when it has none
+func GetEntitlements(ctx context.Context) *routing.Entitlements {
+	entitlements, _ := ctx.Value(entitlementsKey{}).(*routing.Entitlements)
+	return entitlements
+}
+
+// GetToken returns the verified request token, to read custom claims from
+func GetToken(ctx context.Context) string {
+	token, _ := ctx.Value(tokenKey{}).(string)
+	return token
+}
+
 func WithGrants(ctx context.Context, grants *auth.ClaimGrants) context.Context {
 	return context.WithValue(ctx, grantsKey{}, grants)
 }
 
+// WithLocalControl marks requests received on the local control socket, they are trusted with all server APIs
+func WithLocalControl(ctx context.Context) context.Context {
+	return context.WithValue(ctx, localControlKey{}, true)
+}
+
+func isLocalControl(ctx context.Context) bool {
+	local, _ := ctx.Value(localControlKey{}).(bool)
+	return local
+}
+
 func SetAuthorizationToken(r *http.Request, token string) {
 	r.Header.Set(authorizationHeader, bearerPrefix+token)
 }
@@ -131,6 +249,9 @@ func EnsureJoinPermission(ctx context.Context) (name livekit.RoomName, err error
 }
 
 func EnsureAdminPermission(ctx context.Context, room livekit.RoomName) error {
+	if isLocalControl(ctx) {
+		return nil
+	}
 	claims := GetGrants(ctx)
 	if claims == nil || claims.Video == nil {
 		return ErrPermissionDenied
@@ -144,6 +265,9 @@ func EnsureAdminPermission(ctx context.Context, room livekit.RoomName) error {
 }
 
 func EnsureCreatePermission(ctx context.Context) error {
+	if isLocalControl(ctx) {
+		return nil
+	}
 	claims := GetGrants(ctx)
 	if claims == nil || claims.Video == nil || !claims.Video.RoomCreate {
 		return ErrPermissionDenied
@@ -152,6 +276,9 @@ func EnsureCreatePermission(ctx context.Context) error {
 }
 
 func EnsureListPermission(ctx context.Context) error {
+	if isLocalControl(ctx) {
+		return nil
+	}
 	claims := GetGrants(ctx)
 	if claims == nil || claims.Video == nil || !claims.Video.RoomList {
 		return ErrPermissionDenied
@@ -160,14 +287,23 @@ func EnsureListPermission(ctx context.Context) error {
 }
 
 func EnsureRecordPermission(ctx context.Context) error {
+	if isLocalControl(ctx) {
+		return nil
+	}
 	claims := GetGrants(ctx)
 	if claims == nil || claims.Video == nil || !claims.Video.RoomRecord {
 		return ErrPermissionDenied
 	}
+	if !GetEntitlements(ctx).GetAllowRecording() {
+		return ErrPermissionDenied
+	}
 	return nil
 }
 
 func EnsureIngressAdminPermission(ctx context.Context) error {
+	if isLocalControl(ctx) {
+		return nil
+	}
 	claims := GetGrants(ctx)
 	if claims == nil || claims.Video == nil || !claims.Video.IngressAdmin {
 		return ErrPermissionDenied
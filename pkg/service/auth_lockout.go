@@ -0,0 +1,188 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/telemetry/prometheus"
+)
+
+// AuthLockout tracks failed authentication attempts per client IP and locks out
+// IPs that exceed the allowed failures, backing off exponentially on repeat offenders.
+// IPv6 clients are tracked by their /64, which is usually assigned to a single host
+type AuthLockout struct {
+	conf    config.AuthLockoutConfig
+	proxies *TrustedProxies
+
+	lock      sync.Mutex
+	attempts  map[string]*authAttempts
+	lastPrune time.Time
+}
+
+type authAttempts struct {
+	failures    int
+	windowStart time.Time
+	lockouts    int
+	lockedUntil time.Time
+}
+
+// NewAuthLockout returns nil when lockout is disabled, a nil *AuthLockout never locks out.
+// Client IPs forwarded by proxies are only used when they come from one of proxies
+func NewAuthLockout(conf *config.AuthLockoutConfig, proxies *TrustedProxies) *AuthLockout {
+	if !conf.Enabled {
+		return nil
+	}
+	return &AuthLockout{
+		conf:     *conf,
+		proxies:  proxies,
+		attempts: make(map[string]*authAttempts),
+	}
+}
+
+// ClientIP returns the IP attempts of r are tracked for
+func (l *AuthLockout) ClientIP(r *http.Request) string {
+	if l == nil {
+		return (*TrustedProxies)(nil).ClientIP(r)
+	}
+	return l.proxies.ClientIP(r)
+}
+
+// LockedFor returns the remaining lockout time for ip, zero when it is allowed
+func (l *AuthLockout) LockedFor(ip string) time.Duration {
+	if l == nil {
+		return 0
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	a := l.attempts[lockoutKey(ip)]
+	if a == nil {
+		return 0
+	}
+	if remaining := time.Until(a.lockedUntil); remaining > 0 {
+		return remaining
+	}
+	return 0
+}
+
+// RecordFailure registers a failed attempt and returns the lockout duration when it triggered one
+func (l *AuthLockout) RecordFailure(ip string) time.Duration {
+	if l == nil {
+		return 0
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	now := time.Now()
+	l.pruneLocked(now, false)
+
+	key := lockoutKey(ip)
+	a := l.attempts[key]
+	if a == nil {
+		if l.conf.MaxClients > 0 && len(l.attempts) >= l.conf.MaxClients {
+			l.evictLocked(now)
+		}
+		a = &authAttempts{windowStart: now}
+		l.attempts[key] = a
+	}
+	if a.lockouts > 0 && now.Sub(a.lockedUntil) > l.conf.MaxDuration {
+		// well behaved for long enough, start backoff over
+		a.lockouts = 0
+	}
+	if now.Sub(a.windowStart) > l.conf.Window {
+		a.failures = 0
+		a.windowStart = now
+	}
+
+	a.failures++
+	if a.failures < l.conf.MaxFailures {
+		return 0
+	}
+
+	duration := l.conf.BaseDuration << a.lockouts
+	if duration > l.conf.MaxDuration || duration <= 0 {
+		duration = l.conf.MaxDuration
+	}
+	a.lockouts++
+	a.failures = 0
+	a.windowStart = now
+	a.lockedUntil = now.Add(duration)
+	prometheus.RecordAuthLockout()
+	return duration
+}
+
+// RecordSuccess clears the failure history of ip
+func (l *AuthLockout) RecordSuccess(ip string) {
+	if l == nil {
+		return
+	}
+
+	l.lock.Lock()
+	delete(l.attempts, lockoutKey(ip))
+	l.lock.Unlock()
+}
+
+func (l *AuthLockout) pruneLocked(now time.Time, force bool) {
+	if !force && now.Sub(l.lastPrune) < l.conf.Window {
+		return
+	}
+	l.lastPrune = now
+
+	for key, a := range l.attempts {
+		if now.Sub(a.windowStart) > l.conf.Window && now.Sub(a.lockedUntil) > l.conf.MaxDuration {
+			delete(l.attempts, key)
+		}
+	}
+}
+
+// evictLocked makes room for another client, dropping expired entries first and otherwise the client
+// with the oldest failures, preferring ones that are not locked out
+func (l *AuthLockout) evictLocked(now time.Time) {
+	l.pruneLocked(now, true)
+	if len(l.attempts) < l.conf.MaxClients {
+		return
+	}
+
+	var (
+		oldestKey    string
+		oldest       *authAttempts
+		oldestLocked bool
+	)
+	for key, a := range l.attempts {
+		locked := a.lockedUntil.After(now)
+		if oldest == nil ||
+			(oldestLocked && !locked) ||
+			(oldestLocked == locked && a.windowStart.Before(oldest.windowStart)) {
+			oldestKey, oldest, oldestLocked = key, a, locked
+		}
+	}
+	delete(l.attempts, oldestKey)
+}
+
+// lockoutKey groups IPv6 addresses by /64, other addresses are tracked as they are
+func lockoutKey(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil || parsed.To4() != nil {
+		return ip
+	}
+	return parsed.Mask(net.CIDRMask(64, 128)).String() + "/64"
+}
@@ -15,15 +15,30 @@
 package service_test
 
 import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/go-jose/go-jose/v3"
+	"github.com/go-jose/go-jose/v3/jwt"
 	"github.com/stretchr/testify/require"
 
 	"github.com/livekit/protocol/auth"
 	"github.com/livekit/protocol/auth/authfakes"
 
+	"github.com/livekit/livekit-server/pkg/config"
 	"github.com/livekit/livekit-server/pkg/service"
 )
 
@@ -33,7 +48,7 @@ func TestAuthMiddleware(t *testing.T) {
 	provider := &authfakes.FakeKeyProvider{}
 	provider.GetSecretReturns(secret)
 
-	m := service.NewAPIKeyAuthMiddleware(provider)
+	m := service.NewAPIKeyAuthMiddleware(provider, nil, nil, nil)
 	var grants *auth.ClaimGrants
 	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		grants = service.GetGrants(r.Context())
@@ -72,3 +87,380 @@ func TestAuthMiddleware(t *testing.T) {
 	require.Nil(t, grants)
 	require.Equal(t, http.StatusUnauthorized, w.Code)
 }
+
+func TestAuthMiddleware_ResumeToken(t *testing.T) {
+	api := "APIabcdefg"
+	secret := "somesecretencodedinbase62"
+	provider := &authfakes.FakeKeyProvider{}
+	provider.GetSecretReturns(secret)
+
+	resumeSecret := "unguessable-resume-secret"
+	secretHash := sha256.Sum256([]byte(resumeSecret))
+	store := service.NewLocalStore()
+	require.NoError(t, store.StoreResumeToken(context.Background(), &service.ResumeToken{
+		ParticipantID: "PA_lecturer",
+		Identity:      "lecturer",
+		Room:          "lecture",
+		SecretHash:    secretHash[:],
+	}, time.Minute))
+
+	m := service.NewAPIKeyAuthMiddleware(provider, nil, store, nil)
+	var grants *auth.ClaimGrants
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		grants = service.GetGrants(r.Context())
+		w.WriteHeader(http.StatusOK)
+	})
+
+	expiredToken := func(identity string, signingSecret string) string {
+		sig, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.HS256, Key: []byte(signingSecret)},
+			(&jose.SignerOptions{}).WithType("JWT"))
+		require.NoError(t, err)
+		token, err := jwt.Signed(sig).Claims(jwt.Claims{
+			Issuer:    api,
+			Subject:   identity,
+			NotBefore: jwt.NewNumericDate(time.Now().Add(-3 * time.Hour)),
+			Expiry:    jwt.NewNumericDate(time.Now().Add(-time.Hour)),
+		}).Claims(&auth.ClaimGrants{
+			Video: &auth.VideoGrant{Room: "lecture", RoomJoin: true},
+		}).CompactSerialize()
+		require.NoError(t, err)
+		return token
+	}
+
+	serve := func(target string, token string) int {
+		grants = nil
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		service.SetAuthorizationToken(r, token)
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, r, handler)
+		return w.Code
+	}
+
+	t.Run("resumes with expired token", func(t *testing.T) {
+		require.Equal(t, http.StatusOK, serve("/rtc?reconnect=1&sid=PA_lecturer&resume_token="+resumeSecret, expiredToken("lecturer", secret)))
+		require.NotNil(t, grants)
+		require.Equal(t, "lecturer", grants.Identity)
+		require.Equal(t, "lecture", grants.Video.Room)
+	})
+
+	t.Run("rejects expired token on new connections", func(t *testing.T) {
+		require.Equal(t, http.StatusUnauthorized, serve("/rtc", expiredToken("lecturer", secret)))
+		require.Nil(t, grants)
+	})
+
+	t.Run("rejects the session sid without its resume token", func(t *testing.T) {
+		require.Equal(t, http.StatusUnauthorized, serve("/rtc?reconnect=1&sid=PA_lecturer", expiredToken("lecturer", secret)))
+		require.Equal(t, http.StatusUnauthorized, serve("/rtc?reconnect=1&sid=PA_lecturer&resume_token=guessed", expiredToken("lecturer", secret)))
+		require.Nil(t, grants)
+	})
+
+	t.Run("rejects unknown sessions", func(t *testing.T) {
+		require.Equal(t, http.StatusUnauthorized, serve("/rtc?reconnect=1&sid=PA_other&resume_token="+resumeSecret, expiredToken("lecturer", secret)))
+	})
+
+	t.Run("rejects other identities", func(t *testing.T) {
+		require.Equal(t, http.StatusUnauthorized, serve("/rtc?reconnect=1&sid=PA_lecturer&resume_token="+resumeSecret, expiredToken("student", secret)))
+	})
+
+	t.Run("rejects invalid signatures", func(t *testing.T) {
+		require.Equal(t, http.StatusUnauthorized, serve("/rtc?reconnect=1&sid=PA_lecturer&resume_token="+resumeSecret, expiredToken("lecturer", "anothersecretencodedinbase62")))
+	})
+
+	t.Run("rejects expired resume tokens", func(t *testing.T) {
+		require.NoError(t, store.RenewResumeToken(context.Background(), "PA_lecturer", -time.Minute))
+		require.Equal(t, http.StatusUnauthorized, serve("/rtc?reconnect=1&sid=PA_lecturer&resume_token="+resumeSecret, expiredToken("lecturer", secret)))
+	})
+}
+
+func TestAuthMiddleware_TokenKeys(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	jwksServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+			{Key: &ecKey.PublicKey, KeyID: "ec", Algorithm: string(jose.ES256), Use: "sig"},
+		}})
+	}))
+	defer jwksServer.Close()
+
+	der, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	require.NoError(t, err)
+	pemFile := filepath.Join(t.TempDir(), "idp.pem")
+	require.NoError(t, os.WriteFile(pemFile, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0600))
+
+	tokenKeys, err := service.NewTokenKeys(&config.TokenKeysConfig{
+		JWKSURL:                jwksServer.URL,
+		JWKSRefreshInterval:    time.Minute,
+		JWKSMinRefreshInterval: time.Minute,
+		Issuers:                []string{"https://idp.example.com"},
+		PEMFiles:               map[string]string{"pem-issuer": pemFile},
+	})
+	require.NoError(t, err)
+	tokenKeys.Start()
+	defer tokenKeys.Stop()
+
+	provider := &authfakes.FakeKeyProvider{}
+	m := service.NewAPIKeyAuthMiddleware(provider, nil, nil, tokenKeys)
+	var grants *auth.ClaimGrants
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		grants = service.GetGrants(r.Context())
+		w.WriteHeader(http.StatusOK)
+	})
+
+	signedToken := func(alg jose.SignatureAlgorithm, key interface{}, kid string, issuer string) string {
+		opts := (&jose.SignerOptions{}).WithType("JWT")
+		if kid != "" {
+			opts = opts.WithHeader("kid", kid)
+		}
+		sig, err := jose.NewSigner(jose.SigningKey{Algorithm: alg, Key: key}, opts)
+		require.NoError(t, err)
+		token, err := jwt.Signed(sig).Claims(jwt.Claims{
+			Issuer:  issuer,
+			Subject: "student",
+			Expiry:  jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		}).Claims(&auth.ClaimGrants{
+			Video: &auth.VideoGrant{Room: "lecture", RoomJoin: true},
+		}).CompactSerialize()
+		require.NoError(t, err)
+		return token
+	}
+
+	serve := func(token string) int {
+		grants = nil
+		r := &http.Request{Header: http.Header{}}
+		service.SetAuthorizationToken(r, token)
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, r, handler)
+		return w.Code
+	}
+
+	t.Run("accepts ES256 tokens of the key set", func(t *testing.T) {
+		require.Equal(t, http.StatusOK, serve(signedToken(jose.ES256, ecKey, "ec", "https://idp.example.com")))
+		require.NotNil(t, grants)
+		require.Equal(t, "student", grants.Identity)
+		require.Equal(t, "lecture", grants.Video.Room)
+	})
+
+	t.Run("accepts RS256 tokens of PEM keys", func(t *testing.T) {
+		require.Equal(t, http.StatusOK, serve(signedToken(jose.RS256, rsaKey, "", "pem-issuer")))
+		require.NotNil(t, grants)
+	})
+
+	t.Run("rejects unknown issuers", func(t *testing.T) {
+		require.Equal(t, http.StatusUnauthorized, serve(signedToken(jose.ES256, ecKey, "ec", "https://other.example.com")))
+	})
+
+	t.Run("rejects unknown keys", func(t *testing.T) {
+		otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusUnauthorized, serve(signedToken(jose.ES256, otherKey, "ec", "https://idp.example.com")))
+		require.Equal(t, http.StatusUnauthorized, serve(signedToken(jose.ES256, otherKey, "other", "https://idp.example.com")))
+	})
+
+	t.Run("rejects HMAC tokens of issuers without secret", func(t *testing.T) {
+		require.Equal(t, http.StatusUnauthorized, serve(signedToken(jose.HS256, der, "", "pem-issuer")))
+		require.Nil(t, grants)
+	})
+}
+
+func TestAuthLockout(t *testing.T) {
+	provider := &authfakes.FakeKeyProvider{}
+	provider.GetSecretReturns("somesecretencodedinbase62")
+
+	lockout := service.NewAuthLockout(&config.AuthLockoutConfig{
+		Enabled:      true,
+		MaxFailures:  2,
+		Window:       time.Minute,
+		BaseDuration: time.Minute,
+		MaxDuration:  time.Hour,
+	}, nil)
+	m := service.NewAPIKeyAuthMiddleware(provider, lockout, nil, nil)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	serve := func(remoteAddr string) int {
+		r := &http.Request{Header: http.Header{}, RemoteAddr: remoteAddr}
+		service.SetAuthorizationToken(r, "invalid token")
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, r, handler)
+		return w.Code
+	}
+
+	require.Equal(t, http.StatusUnauthorized, serve("10.0.0.1:1000"))
+	require.Equal(t, http.StatusUnauthorized, serve("10.0.0.1:1000"))
+	// locked out after max failures
+	require.Equal(t, http.StatusTooManyRequests, serve("10.0.0.1:1000"))
+	require.Greater(t, lockout.LockedFor("10.0.0.1"), 59*time.Second)
+
+	// other clients are not affected
+	require.Equal(t, http.StatusUnauthorized, serve("10.0.0.2:1000"))
+
+	// forwarding headers of untrusted peers neither evade the lockout nor lock out the forwarded IP
+	r := &http.Request{Header: http.Header{}, RemoteAddr: "10.0.0.1:1000"}
+	r.Header.Set("X-Forwarded-For", "10.0.0.3")
+	service.SetAuthorizationToken(r, "invalid token")
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, r, handler)
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+	require.Zero(t, lockout.LockedFor("10.0.0.3"))
+}
+
+func TestAuthLockout_TrustedProxies(t *testing.T) {
+	proxies, err := service.NewTrustedProxies([]string{"10.0.0.0/8"})
+	require.NoError(t, err)
+	lockout := service.NewAuthLockout(&config.AuthLockoutConfig{
+		Enabled:      true,
+		MaxFailures:  1,
+		Window:       time.Minute,
+		BaseDuration: time.Minute,
+		MaxDuration:  time.Hour,
+	}, proxies)
+
+	r := &http.Request{Header: http.Header{}, RemoteAddr: "10.0.0.1:1000"}
+	r.Header.Set("X-Forwarded-For", "203.0.113.1")
+	require.Equal(t, "203.0.113.1", lockout.ClientIP(r))
+
+	r = &http.Request{Header: http.Header{}, RemoteAddr: "198.51.100.1:1000"}
+	r.Header.Set("X-Forwarded-For", "203.0.113.1")
+	require.Equal(t, "198.51.100.1", lockout.ClientIP(r))
+
+	// a disabled lockout still resolves the peer address for logging
+	require.Equal(t, "198.51.100.1", (*service.AuthLockout)(nil).ClientIP(r))
+}
+
+func TestAuthLockout_IPv6(t *testing.T) {
+	lockout := service.NewAuthLockout(&config.AuthLockoutConfig{
+		Enabled:      true,
+		MaxFailures:  2,
+		Window:       time.Minute,
+		BaseDuration: time.Minute,
+		MaxDuration:  time.Hour,
+	}, nil)
+
+	// addresses of the same /64 share failures
+	require.Zero(t, lockout.RecordFailure("2001:db8:1:1::1"))
+	require.Equal(t, time.Minute, lockout.RecordFailure("2001:db8:1:1::2"))
+	require.NotZero(t, lockout.LockedFor("2001:db8:1:1::3"))
+	require.Zero(t, lockout.LockedFor("2001:db8:1:2::1"))
+}
+
+func TestAuthLockout_MaxClients(t *testing.T) {
+	lockout := service.NewAuthLockout(&config.AuthLockoutConfig{
+		Enabled:      true,
+		MaxFailures:  2,
+		Window:       time.Minute,
+		BaseDuration: time.Minute,
+		MaxDuration:  time.Hour,
+		MaxClients:   2,
+	}, nil)
+
+	require.Zero(t, lockout.RecordFailure("10.0.0.1"))
+	require.NotZero(t, lockout.RecordFailure("10.0.0.1"))
+	require.Zero(t, lockout.RecordFailure("10.0.0.2"))
+
+	// tracking another client forgets the oldest one that is not locked out
+	require.Zero(t, lockout.RecordFailure("10.0.0.3"))
+	require.NotZero(t, lockout.LockedFor("10.0.0.1"))
+	require.Zero(t, lockout.RecordFailure("10.0.0.2"), "failures of evicted clients start over")
+
+	// locked out clients are only evicted once every tracked client is locked out, oldest first
+	require.NotZero(t, lockout.RecordFailure("10.0.0.2"))
+	require.Zero(t, lockout.RecordFailure("10.0.0.3"))
+	require.Zero(t, lockout.LockedFor("10.0.0.1"))
+	require.NotZero(t, lockout.LockedFor("10.0.0.2"))
+}
+
+func TestAuthLockout_Backoff(t *testing.T) {
+	lockout := service.NewAuthLockout(&config.AuthLockoutConfig{
+		Enabled:      true,
+		MaxFailures:  1,
+		Window:       time.Minute,
+		BaseDuration: time.Minute,
+		MaxDuration:  3 * time.Minute,
+	}, nil)
+
+	require.Equal(t, time.Minute, lockout.RecordFailure("ip"))
+	require.Equal(t, 2*time.Minute, lockout.RecordFailure("ip"))
+	// capped at max duration
+	require.Equal(t, 3*time.Minute, lockout.RecordFailure("ip"))
+
+	lockout.RecordSuccess("ip")
+	require.Zero(t, lockout.LockedFor("ip"))
+	require.Equal(t, time.Minute, lockout.RecordFailure("ip"))
+
+	// disabled lockout never locks out
+	require.Nil(t, service.NewAuthLockout(&config.AuthLockoutConfig{}, nil))
+}
+
+func TestAdminOIDC(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	var issuer string
+	idp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			_ = json.NewEncoder(w).Encode(map[string]string{"issuer": issuer, "jwks_uri": issuer + "/keys"})
+		case "/keys":
+			_ = json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+				{Key: &key.PublicKey, KeyID: "admin", Algorithm: string(jose.ES256), Use: "sig"},
+			}})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer idp.Close()
+	issuer = idp.URL
+
+	conf := &config.AdminOIDCConfig{
+		Issuer:             issuer,
+		Audience:           "livekit",
+		RequiredClaims:     map[string][]string{"groups": {"livekit-admins"}},
+		Header:             "X-OIDC-Token",
+		KeyRefreshInterval: time.Minute,
+	}
+	o := service.NewAdminOIDC(conf)
+	o.Start()
+	defer o.Stop()
+
+	signedToken := func(audience string, groups []string, expiry time.Duration) string {
+		sig, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.ES256, Key: key}, (&jose.SignerOptions{}).WithType("JWT").WithHeader("kid", "admin"))
+		require.NoError(t, err)
+		token, err := jwt.Signed(sig).Claims(jwt.Claims{
+			Issuer:   issuer,
+			Subject:  "operator",
+			Audience: jwt.Audience{audience},
+			Expiry:   jwt.NewNumericDate(time.Now().Add(expiry)),
+		}).Claims(map[string]interface{}{"groups": groups}).CompactSerialize()
+		require.NoError(t, err)
+		return token
+	}
+
+	serve := func(path string, token string) int {
+		r := httptest.NewRequest(http.MethodPost, path, nil)
+		if token != "" {
+			r.Header.Set("X-OIDC-Token", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		o.ServeHTTP(w, r, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})
+		return w.Code
+	}
+
+	valid := signedToken("livekit", []string{"staff", "livekit-admins"}, time.Hour)
+	require.Equal(t, http.StatusOK, serve("/twirp/livekit.RoomService/ListRooms", valid))
+	require.Equal(t, http.StatusOK, serve("/debug/rooms", valid))
+	require.Equal(t, http.StatusOK, serve("/campus/requestToken", valid))
+
+	// other endpoints only need the API key token
+	require.Equal(t, http.StatusOK, serve("/rtc", ""))
+
+	require.Equal(t, http.StatusUnauthorized, serve("/twirp/livekit.RoomService/ListRooms", ""))
+	require.Equal(t, http.StatusUnauthorized, serve("/campus", signedToken("other", []string{"livekit-admins"}, time.Hour)))
+	require.Equal(t, http.StatusUnauthorized, serve("/campus", signedToken("livekit", []string{"staff"}, time.Hour)))
+	require.Equal(t, http.StatusUnauthorized, serve("/campus", signedToken("livekit", []string{"livekit-admins"}, -time.Hour)))
+}
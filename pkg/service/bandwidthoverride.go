@@ -0,0 +1,125 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/protocol/livekit"
+)
+
+const bandwidthOverridePath = "/rooms/bandwidth_override"
+
+type bandwidthOverrideRequest struct {
+	Room string `json:"room"`
+	// overrides a single subscriber, otherwise the subscribers of the room without an override of their own
+	Identity string `json:"identity,omitempty"`
+	config.BandwidthOverrideConfig
+	// removes the override of the participant, or of the room without identity
+	Clear bool `json:"clear,omitempty"`
+}
+
+type bandwidthOverrideResponse struct {
+	Room     string `json:"room"`
+	Identity string `json:"identity,omitempty"`
+	config.BandwidthOverrideConfig
+	// the participant has no override of its own, the one of the room applies
+	Inherited bool `json:"inherited,omitempty"`
+	// bitrate needed to forward the subscribed tracks of the participant, or of the whole room, at their optimal
+	// layers. compare with the override to see which layers are held back
+	Demand int64 `json:"demand"`
+	// bitrate every subscriber of a lossy room keeps free of video for opus in-band FEC
+	FECHeadroom int64 `json:"fec_headroom,omitempty"`
+}
+
+// BandwidthOverrideService caps or forces the estimated bandwidth of subscribers of a room, or of a single
+// subscriber, overriding congestion control for deterministic QoS tests or to constrain misbehaving networks.
+// Only rooms hosted on the node handling the request can be changed.
+type BandwidthOverrideService struct {
+	roomManager *RoomManager
+}
+
+func NewBandwidthOverrideService(roomManager *RoomManager) *BandwidthOverrideService {
+	return &BandwidthOverrideService{
+		roomManager: roomManager,
+	}
+}
+
+func (s *BandwidthOverrideService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req bandwidthOverrideRequest
+	switch r.Method {
+	case http.MethodGet:
+		req.Room = r.URL.Query().Get("room")
+		req.Identity = r.URL.Query().Get("identity")
+	case http.MethodPost:
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			handleError(w, http.StatusBadRequest, err)
+			return
+		}
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := EnsureAdminPermission(r.Context(), livekit.RoomName(req.Room)); err != nil {
+		handleError(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	room := s.roomManager.GetRoom(r.Context(), livekit.RoomName(req.Room))
+	if room == nil {
+		handleError(w, http.StatusNotFound, ErrRoomNotFound, "room", req.Room)
+		return
+	}
+
+	identity := livekit.ParticipantIdentity(req.Identity)
+	if r.Method == http.MethodPost {
+		override := req.BandwidthOverrideConfig
+		switch {
+		case identity == "" && req.Clear:
+			room.SetSubscriberBandwidthOverride(config.BandwidthOverrideConfig{})
+		case identity == "":
+			room.SetSubscriberBandwidthOverride(override)
+		case req.Clear:
+			room.SetParticipantBandwidthOverride(identity, nil)
+		default:
+			room.SetParticipantBandwidthOverride(identity, &override)
+		}
+	}
+
+	res := &bandwidthOverrideResponse{
+		Room:        req.Room,
+		Identity:    req.Identity,
+		FECHeadroom: room.OpusFECHeadroom(),
+	}
+	if identity == "" {
+		res.BandwidthOverrideConfig = room.SubscriberBandwidthOverride()
+		res.Demand = room.GetSubscriberBandwidthDemand()
+	} else {
+		var ok bool
+		if res.BandwidthOverrideConfig, ok = room.ParticipantBandwidthOverride(identity); !ok {
+			res.BandwidthOverrideConfig = room.SubscriberBandwidthOverride()
+			res.Inherited = true
+		}
+		// participants can be overridden before they join
+		if participant := room.GetParticipant(identity); participant != nil {
+			res.Demand = participant.GetSubscriberBandwidthDemand()
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(res)
+}
@@ -0,0 +1,258 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/livekit/livekit-server/pkg/bridge"
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/protocol/livekit"
+)
+
+const bridgesPath = "/bridges"
+
+type startBridgeRequest struct {
+	Room        string   `json:"room"`
+	RemoteURL   string   `json:"remote_url"`
+	RemoteToken string   `json:"remote_token"`
+	Direction   string   `json:"direction,omitempty"`
+	Identities  []string `json:"identities,omitempty"`
+	Sources     []string `json:"sources,omitempty"`
+}
+
+type stopBridgeRequest struct {
+	BridgeID string `json:"bridge_id"`
+}
+
+type startRTPForwardRequest struct {
+	Room       string `json:"room"`
+	Direction  string `json:"direction"`
+	Preset     string `json:"preset,omitempty"`
+	Identity   string `json:"identity"`
+	Host       string `json:"host,omitempty"`
+	AudioPort  int    `json:"audio_port,omitempty"`
+	VideoPort  int    `json:"video_port,omitempty"`
+	AudioCodec string `json:"audio_codec,omitempty"`
+	VideoCodec string `json:"video_codec,omitempty"`
+}
+
+type stopRTPForwardRequest struct {
+	ForwardID string `json:"forward_id"`
+}
+
+type hangupSIPCallRequest struct {
+	CallID string `json:"call_id"`
+}
+
+type listBridgesResponse struct {
+	Bridges     []*bridge.Info           `json:"bridges"`
+	RTPForwards []*bridge.RTPForwardInfo `json:"rtp_forwards"`
+	SIPCalls    []*bridge.SIPCallInfo    `json:"sip_calls"`
+}
+
+// BridgeService starts and stops bridges between local rooms and rooms of other deployments.
+// Bridges run on the node handling the request.
+type BridgeService struct {
+	manager *bridge.Manager
+}
+
+func NewBridgeService(manager *bridge.Manager) *BridgeService {
+	return &BridgeService{
+		manager: manager,
+	}
+}
+
+func (s *BridgeService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.manager == nil {
+		handleError(w, http.StatusNotFound, ErrBridgeDisabled)
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == bridgesPath:
+		s.list(w, r)
+	case r.Method == http.MethodPost && r.URL.Path == bridgesPath+"/start":
+		s.start(w, r)
+	case r.Method == http.MethodPost && r.URL.Path == bridgesPath+"/stop":
+		s.stop(w, r)
+	case r.Method == http.MethodPost && r.URL.Path == bridgesPath+"/rtp/start":
+		s.startRTPForward(w, r)
+	case r.Method == http.MethodPost && r.URL.Path == bridgesPath+"/rtp/stop":
+		s.stopRTPForward(w, r)
+	case r.Method == http.MethodPost && r.URL.Path == bridgesPath+"/sip/hangup":
+		s.hangupSIPCall(w, r)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (s *BridgeService) list(w http.ResponseWriter, r *http.Request) {
+	res := &listBridgesResponse{Bridges: []*bridge.Info{}, RTPForwards: []*bridge.RTPForwardInfo{}, SIPCalls: []*bridge.SIPCallInfo{}}
+	for _, info := range s.manager.List() {
+		if EnsureAdminPermission(r.Context(), info.Room) == nil {
+			res.Bridges = append(res.Bridges, info)
+		}
+	}
+	for _, info := range s.manager.ListRTPForwards() {
+		if EnsureAdminPermission(r.Context(), info.Room) == nil {
+			res.RTPForwards = append(res.RTPForwards, info)
+		}
+	}
+	// calls that have not reached a room yet are only listed for local control
+	for _, info := range s.manager.ListSIPCalls() {
+		if EnsureAdminPermission(r.Context(), info.Room) == nil {
+			res.SIPCalls = append(res.SIPCalls, info)
+		}
+	}
+	s.writeJSON(w, res)
+}
+
+func (s *BridgeService) start(w http.ResponseWriter, r *http.Request) {
+	var req startBridgeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		handleError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := EnsureAdminPermission(r.Context(), livekit.RoomName(req.Room)); err != nil {
+		handleError(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	info, err := s.manager.Create(config.BridgeSpec{
+		Room:        req.Room,
+		RemoteURL:   req.RemoteURL,
+		RemoteToken: req.RemoteToken,
+		Direction:   req.Direction,
+		Identities:  req.Identities,
+		Sources:     req.Sources,
+	})
+	switch {
+	case errors.Is(err, bridge.ErrInvalidBridge):
+		handleError(w, http.StatusBadRequest, err, "room", req.Room)
+	case err != nil:
+		handleError(w, http.StatusServiceUnavailable, err, "room", req.Room)
+	default:
+		s.writeJSON(w, info)
+	}
+}
+
+func (s *BridgeService) stop(w http.ResponseWriter, r *http.Request) {
+	var req stopBridgeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		handleError(w, http.StatusBadRequest, err)
+		return
+	}
+	info, err := s.manager.Get(req.BridgeID)
+	if err != nil {
+		handleError(w, http.StatusNotFound, err, "bridgeID", req.BridgeID)
+		return
+	}
+	if err = EnsureAdminPermission(r.Context(), info.Room); err != nil {
+		handleError(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	if info, err = s.manager.Stop(req.BridgeID); err != nil {
+		handleError(w, http.StatusNotFound, err, "bridgeID", req.BridgeID)
+		return
+	}
+	s.writeJSON(w, info)
+}
+
+func (s *BridgeService) startRTPForward(w http.ResponseWriter, r *http.Request) {
+	var req startRTPForwardRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		handleError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := EnsureAdminPermission(r.Context(), livekit.RoomName(req.Room)); err != nil {
+		handleError(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	info, err := s.manager.CreateRTPForward(config.RTPForwardSpec{
+		Room:       req.Room,
+		Direction:  req.Direction,
+		Preset:     req.Preset,
+		Identity:   req.Identity,
+		Host:       req.Host,
+		AudioPort:  req.AudioPort,
+		VideoPort:  req.VideoPort,
+		AudioCodec: req.AudioCodec,
+		VideoCodec: req.VideoCodec,
+	})
+	switch {
+	case errors.Is(err, bridge.ErrInvalidRTPForward), errors.Is(err, bridge.ErrUnknownPreset):
+		handleError(w, http.StatusBadRequest, err, "room", req.Room, "preset", req.Preset)
+	case err != nil:
+		handleError(w, http.StatusServiceUnavailable, err, "room", req.Room)
+	default:
+		s.writeJSON(w, info)
+	}
+}
+
+func (s *BridgeService) stopRTPForward(w http.ResponseWriter, r *http.Request) {
+	var req stopRTPForwardRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		handleError(w, http.StatusBadRequest, err)
+		return
+	}
+	info, err := s.manager.GetRTPForward(req.ForwardID)
+	if err != nil {
+		handleError(w, http.StatusNotFound, err, "forwardID", req.ForwardID)
+		return
+	}
+	if err = EnsureAdminPermission(r.Context(), info.Room); err != nil {
+		handleError(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	if info, err = s.manager.StopRTPForward(req.ForwardID); err != nil {
+		handleError(w, http.StatusNotFound, err, "forwardID", req.ForwardID)
+		return
+	}
+	s.writeJSON(w, info)
+}
+
+func (s *BridgeService) hangupSIPCall(w http.ResponseWriter, r *http.Request) {
+	var req hangupSIPCallRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		handleError(w, http.StatusBadRequest, err)
+		return
+	}
+	info, err := s.manager.GetSIPCall(req.CallID)
+	if err != nil {
+		handleError(w, http.StatusNotFound, err, "callID", req.CallID)
+		return
+	}
+	if err = EnsureAdminPermission(r.Context(), info.Room); err != nil {
+		handleError(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	if info, err = s.manager.HangupSIPCall(req.CallID); err != nil {
+		handleError(w, http.StatusNotFound, err, "callID", req.CallID)
+		return
+	}
+	s.writeJSON(w, info)
+}
+
+func (s *BridgeService) writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
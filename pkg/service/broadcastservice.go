@@ -0,0 +1,80 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/livekit/protocol/livekit"
+)
+
+const broadcastPath = "/broadcast"
+
+type broadcastAudience struct {
+	Room       string `json:"room"`
+	Publishers int    `json:"publishers"`
+	Viewers    int    `json:"viewers"`
+}
+
+// BroadcastService reports the audience of a room, counting participants that can publish and viewers.
+// Counts come from the store and cover all nodes of the cluster
+type BroadcastService struct {
+	store ServiceStore
+}
+
+func NewBroadcastService(store ServiceStore) *BroadcastService {
+	return &BroadcastService{
+		store: store,
+	}
+}
+
+func (s *BroadcastService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	roomName := livekit.RoomName(r.URL.Query().Get("room"))
+	if err := EnsureAdminPermission(r.Context(), roomName); err != nil {
+		handleError(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	if _, _, err := s.store.LoadRoom(r.Context(), roomName, false); err != nil {
+		handleError(w, http.StatusNotFound, ErrRoomNotFound, "room", roomName)
+		return
+	}
+	participants, err := s.store.ListParticipants(r.Context(), roomName)
+	if err != nil {
+		handleError(w, http.StatusInternalServerError, err, "room", roomName)
+		return
+	}
+
+	res := &broadcastAudience{Room: string(roomName)}
+	for _, p := range participants {
+		if p.Permission.GetHidden() {
+			continue
+		}
+		if p.Permission.GetCanPublish() {
+			res.Publishers++
+		} else {
+			res.Viewers++
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(res)
+}
@@ -0,0 +1,111 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/protocol/auth"
+	"github.com/livekit/protocol/livekit"
+
+	"github.com/livekit/livekit-server/pkg/service"
+	"github.com/livekit/livekit-server/pkg/service/servicefakes"
+)
+
+func TestBroadcastService(t *testing.T) {
+	participants := []*livekit.ParticipantInfo{
+		{Identity: "host", Permission: &livekit.ParticipantPermission{CanPublish: true}},
+		{Identity: "cohost", Permission: &livekit.ParticipantPermission{CanPublish: true}},
+		{Identity: "viewer1", Permission: &livekit.ParticipantPermission{CanSubscribe: true}},
+		{Identity: "viewer2", Permission: &livekit.ParticipantPermission{CanSubscribe: true}},
+		{Identity: "viewer3"},
+		{Identity: "recorder", Permission: &livekit.ParticipantPermission{Hidden: true}},
+	}
+
+	testCases := []struct {
+		name       string
+		method     string
+		room       string
+		adminRoom  string
+		roomExists bool
+		status     int
+		expected   map[string]interface{}
+	}{
+		{
+			name:       "counts publishers and viewers",
+			method:     http.MethodGet,
+			room:       "keynote",
+			adminRoom:  "keynote",
+			roomExists: true,
+			status:     http.StatusOK,
+			expected:   map[string]interface{}{"room": "keynote", "publishers": float64(2), "viewers": float64(3)},
+		},
+		{
+			name:       "admin of another room",
+			method:     http.MethodGet,
+			room:       "keynote",
+			adminRoom:  "other",
+			roomExists: true,
+			status:     http.StatusUnauthorized,
+		},
+		{
+			name:      "unknown room",
+			method:    http.MethodGet,
+			room:      "keynote",
+			adminRoom: "keynote",
+			status:    http.StatusNotFound,
+		},
+		{
+			name:       "method not allowed",
+			method:     http.MethodPost,
+			room:       "keynote",
+			adminRoom:  "keynote",
+			roomExists: true,
+			status:     http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := &servicefakes.FakeServiceStore{}
+			if tc.roomExists {
+				store.LoadRoomReturns(&livekit.Room{Name: tc.room}, nil, nil)
+			} else {
+				store.LoadRoomReturns(nil, nil, service.ErrRoomNotFound)
+			}
+			store.ListParticipantsReturns(participants, nil)
+
+			ctx := service.WithGrants(context.Background(), &auth.ClaimGrants{
+				Video: &auth.VideoGrant{RoomAdmin: true, Room: tc.adminRoom},
+			})
+			req := httptest.NewRequest(tc.method, "/broadcast?room="+tc.room, nil).WithContext(ctx)
+			w := httptest.NewRecorder()
+			service.NewBroadcastService(store).ServeHTTP(w, req)
+
+			require.Equal(t, tc.status, w.Code)
+			if tc.expected != nil {
+				var res map[string]interface{}
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+				require.Equal(t, tc.expected, res)
+			}
+		})
+	}
+}
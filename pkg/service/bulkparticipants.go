@@ -0,0 +1,290 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+
+	"google.golang.org/protobuf/encoding/protojson"
+
+	"github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/logger"
+)
+
+const (
+	bulkParticipantsPath = "/rooms/participants/bulk"
+
+	bulkActionRemove           = "remove"
+	bulkActionMute             = "mute"
+	bulkActionUpdatePermission = "update_permission"
+	bulkActionSendData         = "send_data"
+
+	bulkMaxIdentities = 1000
+	// participants handled at once, every operation waits for the hosting node to confirm it
+	bulkConcurrency = 16
+)
+
+type bulkParticipantsRequest struct {
+	Room       string   `json:"room"`
+	Action     string   `json:"action"`
+	Identities []string `json:"identities"`
+
+	// mute: tracks of these sources (camera, microphone, screen_share, screen_share_audio), all tracks when empty
+	Muted   bool     `json:"muted,omitempty"`
+	Sources []string `json:"sources,omitempty"`
+
+	// update_permission: ParticipantPermission in its protobuf JSON form
+	Permission json.RawMessage `json:"permission,omitempty"`
+
+	// send_data: data is base64 encoded, sent reliably unless lossy is set
+	Data  []byte `json:"data,omitempty"`
+	Topic string `json:"topic,omitempty"`
+	Lossy bool   `json:"lossy,omitempty"`
+}
+
+type bulkParticipantResult struct {
+	Identity string `json:"identity"`
+	Error    string `json:"error,omitempty"`
+	// tracks muted or unmuted
+	TrackSids []string `json:"track_sids,omitempty"`
+}
+
+type bulkParticipantsResponse struct {
+	Room    string                  `json:"room"`
+	Action  string                  `json:"action"`
+	Failed  int                     `json:"failed"`
+	Results []bulkParticipantResult `json:"results"`
+}
+
+// BulkParticipantsService applies a moderation action (remove, mute, update_permission, send_data) to a list of
+// participants of a room in one request. Every participant gets its own result, a failure does not stop the others.
+type BulkParticipantsService struct {
+	roomService livekit.RoomService
+	store       ObjectStore
+}
+
+func NewBulkParticipantsService(roomService livekit.RoomService, store ObjectStore) *BulkParticipantsService {
+	return &BulkParticipantsService{
+		roomService: roomService,
+		store:       store,
+	}
+}
+
+func (s *BulkParticipantsService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req bulkParticipantsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		handleError(w, http.StatusBadRequest, err)
+		return
+	}
+	if req.Room == "" {
+		handleError(w, http.StatusBadRequest, ErrRoomNameRequired)
+		return
+	}
+	if len(req.Identities) == 0 || len(req.Identities) > bulkMaxIdentities {
+		handleError(w, http.StatusBadRequest, ErrBulkIdentities, "count", len(req.Identities))
+		return
+	}
+
+	ctx := r.Context()
+	if err := EnsureAdminPermission(ctx, livekit.RoomName(req.Room)); err != nil {
+		handleError(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	var apply func(ctx context.Context, identity string) bulkParticipantResult
+	switch req.Action {
+	case bulkActionRemove:
+		apply = func(ctx context.Context, identity string) bulkParticipantResult {
+			_, err := s.roomService.RemoveParticipant(ctx, &livekit.RoomParticipantIdentity{
+				Room:     req.Room,
+				Identity: identity,
+			})
+			return newBulkParticipantResult(identity, err)
+		}
+
+	case bulkActionMute:
+		sources, err := parseTrackSources(req.Sources)
+		if err != nil {
+			handleError(w, http.StatusBadRequest, err, "sources", req.Sources)
+			return
+		}
+		apply = func(ctx context.Context, identity string) bulkParticipantResult {
+			return s.mute(ctx, req.Room, identity, req.Muted, sources)
+		}
+
+	case bulkActionUpdatePermission:
+		permission := &livekit.ParticipantPermission{}
+		if err := protojson.Unmarshal(req.Permission, permission); err != nil {
+			handleError(w, http.StatusBadRequest, err)
+			return
+		}
+		apply = func(ctx context.Context, identity string) bulkParticipantResult {
+			_, err := s.roomService.UpdateParticipant(ctx, &livekit.UpdateParticipantRequest{
+				Room:       req.Room,
+				Identity:   identity,
+				Permission: permission,
+			})
+			return newBulkParticipantResult(identity, err)
+		}
+
+	case bulkActionSendData:
+		s.sendData(w, r, &req)
+		return
+
+	default:
+		handleError(w, http.StatusBadRequest, ErrInvalidBulkAction, "action", req.Action)
+		return
+	}
+
+	results := make([]bulkParticipantResult, len(req.Identities))
+	sem := make(chan struct{}, bulkConcurrency)
+	var wg sync.WaitGroup
+	for i, identity := range req.Identities {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, identity string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			results[i] = apply(ctx, identity)
+		}(i, identity)
+	}
+	wg.Wait()
+
+	s.writeResponse(w, &req, results)
+}
+
+func (s *BulkParticipantsService) mute(ctx context.Context, room string, identity string, muted bool, sources map[livekit.TrackSource]bool) bulkParticipantResult {
+	p, err := s.store.LoadParticipant(ctx, livekit.RoomName(room), livekit.ParticipantIdentity(identity))
+	if err != nil {
+		return newBulkParticipantResult(identity, err)
+	}
+
+	res := bulkParticipantResult{Identity: identity}
+	for _, trackSid := range bulkTrackSids(p, sources) {
+		if _, err := s.roomService.MutePublishedTrack(ctx, &livekit.MuteRoomTrackRequest{
+			Room:     room,
+			Identity: identity,
+			TrackSid: trackSid,
+			Muted:    muted,
+		}); err != nil {
+			res.Error = err.Error()
+			break
+		}
+		res.TrackSids = append(res.TrackSids, trackSid)
+	}
+	return res
+}
+
+// sendData delivers the data with a single message to the participants that are in the room
+func (s *BulkParticipantsService) sendData(w http.ResponseWriter, r *http.Request, req *bulkParticipantsRequest) {
+	ctx := r.Context()
+	results := make([]bulkParticipantResult, len(req.Identities))
+	destinations := make([]string, 0, len(req.Identities))
+	for i, identity := range req.Identities {
+		_, err := s.store.LoadParticipant(ctx, livekit.RoomName(req.Room), livekit.ParticipantIdentity(identity))
+		results[i] = newBulkParticipantResult(identity, err)
+		if err == nil {
+			destinations = append(destinations, identity)
+		}
+	}
+
+	if len(destinations) != 0 {
+		kind := livekit.DataPacket_RELIABLE
+		if req.Lossy {
+			kind = livekit.DataPacket_LOSSY
+		}
+		sendReq := &livekit.SendDataRequest{
+			Room:                  req.Room,
+			Data:                  req.Data,
+			Kind:                  kind,
+			DestinationIdentities: destinations,
+		}
+		if req.Topic != "" {
+			sendReq.Topic = &req.Topic
+		}
+		if _, err := s.roomService.SendData(ctx, sendReq); err != nil {
+			for i := range results {
+				if results[i].Error == "" {
+					results[i].Error = err.Error()
+				}
+			}
+		}
+	}
+
+	s.writeResponse(w, req, results)
+}
+
+func (s *BulkParticipantsService) writeResponse(w http.ResponseWriter, req *bulkParticipantsRequest, results []bulkParticipantResult) {
+	res := &bulkParticipantsResponse{
+		Room:    req.Room,
+		Action:  req.Action,
+		Results: results,
+	}
+	for _, result := range results {
+		if result.Error != "" {
+			res.Failed++
+		}
+	}
+	logger.Infow("bulk participant action", "room", req.Room, "action", req.Action, "count", len(results), "failed", res.Failed)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(res)
+}
+
+func newBulkParticipantResult(identity string, err error) bulkParticipantResult {
+	res := bulkParticipantResult{Identity: identity}
+	if err != nil {
+		res.Error = err.Error()
+	}
+	return res
+}
+
+// parseTrackSources takes lower case track source names, nil means all sources
+func parseTrackSources(names []string) (map[livekit.TrackSource]bool, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	sources := make(map[livekit.TrackSource]bool, len(names))
+	for _, name := range names {
+		source, ok := livekit.TrackSource_value[strings.ToUpper(name)]
+		if !ok || livekit.TrackSource(source) == livekit.TrackSource_UNKNOWN {
+			return nil, ErrInvalidTrackSource
+		}
+		sources[livekit.TrackSource(source)] = true
+	}
+	return sources, nil
+}
+
+// bulkTrackSids returns the published tracks of a participant with one of the sources, all tracks when sources is nil
+func bulkTrackSids(p *livekit.ParticipantInfo, sources map[livekit.TrackSource]bool) []string {
+	var trackSids []string
+	for _, t := range p.Tracks {
+		if sources == nil || sources[t.Source] {
+			trackSids = append(trackSids, t.Sid)
+		}
+	}
+	return trackSids
+}
@@ -0,0 +1,50 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/protocol/livekit"
+)
+
+func TestBulkTrackSids(t *testing.T) {
+	p := &livekit.ParticipantInfo{
+		Tracks: []*livekit.TrackInfo{
+			{Sid: "TR_mic", Source: livekit.TrackSource_MICROPHONE},
+			{Sid: "TR_cam", Source: livekit.TrackSource_CAMERA},
+			{Sid: "TR_screen", Source: livekit.TrackSource_SCREEN_SHARE},
+		},
+	}
+
+	sources, err := parseTrackSources(nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{"TR_mic", "TR_cam", "TR_screen"}, bulkTrackSids(p, sources))
+
+	sources, err = parseTrackSources([]string{"microphone", "screen_share"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"TR_mic", "TR_screen"}, bulkTrackSids(p, sources))
+
+	sources, err = parseTrackSources([]string{"screen_share_audio"})
+	require.NoError(t, err)
+	require.Empty(t, bulkTrackSids(p, sources))
+
+	_, err = parseTrackSources([]string{"unknown"})
+	require.ErrorIs(t, err, ErrInvalidTrackSource)
+	_, err = parseTrackSources([]string{"webcam"})
+	require.ErrorIs(t, err, ErrInvalidTrackSource)
+}
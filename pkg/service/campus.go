@@ -1,10 +1,17 @@
 package service
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"math"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/livekit/livekit-server/pkg/config"
@@ -13,21 +20,33 @@ import (
 	"github.com/livekit/protocol/logger"
 )
 
+const campusMaxRequestSize = 64 * 1024
+
 type CampusService struct {
 	router      routing.MessageRouter
 	currentNode routing.LocalNode
 	config      *config.Config
+	limiter     *IPRateLimiter
+	proxies     *TrustedProxies
 }
 
+// NewCampusService creates the campus service, token requests are rate limited by the client IP as seen through
+// proxies
 func NewCampusService(
 	conf *config.Config,
 	router routing.MessageRouter,
 	currentNode routing.LocalNode,
+	proxies *TrustedProxies,
 ) *CampusService {
 	s := &CampusService{
 		router:      router,
 		currentNode: currentNode,
 		config:      conf,
+		limiter:     NewIPRateLimiter(conf.Campus.RateLimit, conf.Campus.RateWindow),
+		proxies:     proxies,
+	}
+	if conf.Campus.Secret == "" {
+		logger.Warnw("campus token requests are refused until campus.secret is set", nil)
 	}
 	return s
 }
@@ -48,11 +67,29 @@ func (s *CampusService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *CampusService) RequestToken(w http.ResponseWriter, r *http.Request) {
-	payload, err := ioutil.ReadAll(r.Body)
+	if s.config.Campus.Secret == "" {
+		makeErrorResponseWithStatus(http.StatusForbidden, -16, "Token requests are disabled!", w)
+		return
+	}
+
+	clientIP := s.proxies.ClientIP(r)
+	if allowed, retryAfter := s.limiter.Allow(clientIP); !allowed {
+		logger.Infow("campus token request rate limited", "clientIP", clientIP)
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		makeErrorResponseWithStatus(http.StatusTooManyRequests, -13, "Too many requests!", w)
+		return
+	}
+
+	payload, err := ioutil.ReadAll(io.LimitReader(r.Body, campusMaxRequestSize))
 	if err != nil {
 		makeErrorResponse(-1, "No body data found!", w)
 		return
 	}
+	if !s.authenticate(r, payload) {
+		logger.Infow("campus token request not authenticated", "clientIP", clientIP)
+		makeErrorResponseWithStatus(http.StatusUnauthorized, -14, "Request authentication failed!", w)
+		return
+	}
 
 	var request joinRoomTokenRequest
 	err = json.Unmarshal(payload, &request)
@@ -68,19 +105,33 @@ func (s *CampusService) RequestToken(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	template := s.config.Campus.Grants
+	if request.Role != "" {
+		var ok bool
+		if template, ok = s.config.Campus.Roles[request.Role]; !ok {
+			makeErrorResponseWithStatus(http.StatusBadRequest, -15, fmt.Sprintf("Role: %s is not available!", request.Role), w)
+			return
+		}
+	}
+
 	at := auth.NewAccessToken(request.ApiKey, secret)
 	grant := &auth.VideoGrant{
-		RoomJoin:  true,
-		RoomList:  true,
-		RoomAdmin: true,
-		Room:      request.Room,
+		RoomJoin:       template.RoomJoin,
+		RoomList:       template.RoomList,
+		RoomAdmin:      template.RoomAdmin,
+		Room:           request.Room,
+		CanPublish:     template.CanPublish,
+		CanSubscribe:   template.CanSubscribe,
+		CanPublishData: template.CanPublishData,
+		Hidden:         template.Hidden,
+		Recorder:       template.Recorder,
 	}
 
 	userName := request.Name
 	if len(userName) == 0 { // user identity if username is empty
 		userName = request.Identity
 	}
-	at.AddGrant(grant).SetIdentity(request.Identity).SetValidFor(time.Hour * 24).SetName(userName)
+	at.AddGrant(grant).SetIdentity(request.Identity).SetValidFor(s.config.Campus.TokenTTL).SetName(userName)
 
 	token, err := at.ToJWT()
 	if err != nil {
@@ -93,13 +144,40 @@ func (s *CampusService) RequestToken(w http.ResponseWriter, r *http.Request) {
 		"apiKey": request.ApiKey,
 		"token":  token,
 	}
+	if request.Role != "" {
+		content["role"] = request.Role
+	}
 	makeResponse(1, content, w)
 }
 
+// authenticate checks the shared secret header, or the HMAC signature of the body
+func (s *CampusService) authenticate(r *http.Request, payload []byte) bool {
+	secret := s.config.Campus.Secret
+	if secret == "" {
+		return false
+	}
+
+	if header := r.Header.Get("X-Campus-Secret"); header != "" {
+		return subtle.ConstantTimeCompare([]byte(header), []byte(secret)) == 1
+	}
+	signature, err := hex.DecodeString(r.Header.Get("X-Campus-Signature"))
+	if err != nil || len(signature) == 0 {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return hmac.Equal(signature, mac.Sum(nil))
+}
+
 func makeErrorResponse(code int, msg string, w http.ResponseWriter) {
+	makeErrorResponseWithStatus(http.StatusOK, code, msg, w)
+}
+
+func makeErrorResponseWithStatus(status int, code int, msg string, w http.ResponseWriter) {
 	logger.Infow(fmt.Sprintf("*****[Response, Failed! Code: (%d), Msg: (%s)]\n", code, msg))
 
 	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
 	var resp = map[string]interface{}{
 		"code": fmt.Sprint(code), "msg": msg, "data": nil,
 	}
@@ -130,4 +208,6 @@ type joinRoomTokenRequest struct {
 	Identity  string `json:"identity"`
 	Name      string `json:"name"`
 	ApiSecret string
+	// name of a configured role, the default grants apply when empty
+	Role string `json:"role"`
 }
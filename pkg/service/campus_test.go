@@ -0,0 +1,236 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/protocol/auth"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/service"
+)
+
+func TestCampusRequestToken(t *testing.T) {
+	denied := false
+	conf := &config.Config{
+		Keys: map[string]string{"APIabcdefg": "somesecretencodedinbase62"},
+		Campus: config.CampusConfig{
+			Secret:     "campus-secret",
+			RateLimit:  5,
+			RateWindow: time.Minute,
+			Grants:     config.CampusGrantTemplate{RoomJoin: true},
+			Roles: map[string]config.CampusGrantTemplate{
+				"viewer": {RoomJoin: true, CanPublish: &denied, CanPublishData: &denied},
+			},
+			TokenTTL: time.Hour,
+		},
+	}
+	s := service.NewCampusService(conf, nil, nil, nil)
+	body := `{"apiKey": "APIabcdefg", "room": "exam", "identity": "student"}`
+
+	request := func(header, value string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/campus/requestToken", strings.NewReader(body))
+		r.RemoteAddr = "10.0.0.1:1234"
+		if header != "" {
+			r.Header.Set(header, value)
+		}
+		w := httptest.NewRecorder()
+		s.RequestToken(w, r)
+		return w
+	}
+
+	t.Run("rejects unauthenticated requests", func(t *testing.T) {
+		require.Equal(t, http.StatusUnauthorized, request("", "").Code)
+		require.Equal(t, http.StatusUnauthorized, request("X-Campus-Secret", "wrong").Code)
+	})
+
+	t.Run("issues tokens with the configured grants", func(t *testing.T) {
+		mac := hmac.New(sha256.New, []byte("campus-secret"))
+		mac.Write([]byte(body))
+		w := request("X-Campus-Signature", hex.EncodeToString(mac.Sum(nil)))
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var res struct {
+			Data struct {
+				Token string `json:"token"`
+			} `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+		v, err := auth.ParseAPIToken(res.Data.Token)
+		require.NoError(t, err)
+		claims, err := v.Verify("somesecretencodedinbase62")
+		require.NoError(t, err)
+		require.True(t, claims.Video.RoomJoin)
+		require.False(t, claims.Video.RoomAdmin)
+		require.Equal(t, "exam", claims.Video.Room)
+	})
+
+	t.Run("issues tokens with the grants of the requested role", func(t *testing.T) {
+		body = `{"apiKey": "APIabcdefg", "room": "exam", "identity": "student", "role": "viewer"}`
+		w := request("X-Campus-Secret", "campus-secret")
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var res struct {
+			Data struct {
+				Token string `json:"token"`
+			} `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+		v, err := auth.ParseAPIToken(res.Data.Token)
+		require.NoError(t, err)
+		claims, err := v.Verify("somesecretencodedinbase62")
+		require.NoError(t, err)
+		require.True(t, claims.Video.RoomJoin)
+		require.False(t, claims.Video.GetCanPublish())
+		require.False(t, claims.Video.GetCanPublishData())
+		require.True(t, claims.Video.GetCanSubscribe())
+
+		body = `{"apiKey": "APIabcdefg", "room": "exam", "identity": "student", "role": "dean"}`
+		require.Equal(t, http.StatusBadRequest, request("X-Campus-Secret", "campus-secret").Code)
+	})
+
+	t.Run("rate limits by client IP", func(t *testing.T) {
+		w := request("X-Campus-Secret", "campus-secret")
+		require.Equal(t, http.StatusTooManyRequests, w.Code)
+		require.NotEmpty(t, w.Header().Get("Retry-After"))
+
+		// forwarding headers of untrusted peers do not reset the limit
+		require.Equal(t, http.StatusTooManyRequests, request("X-Forwarded-For", "203.0.113.1").Code)
+	})
+}
+
+func TestCampusRequestToken_TrustedProxies(t *testing.T) {
+	conf := &config.Config{
+		Keys: map[string]string{"APIabcdefg": "somesecretencodedinbase62"},
+		Campus: config.CampusConfig{
+			Secret:     "campus-secret",
+			RateLimit:  1,
+			RateWindow: time.Minute,
+			Grants:     config.CampusGrantTemplate{RoomJoin: true},
+			TokenTTL:   time.Hour,
+		},
+	}
+	proxies, err := service.NewTrustedProxies([]string{"10.0.0.0/8"})
+	require.NoError(t, err)
+	s := service.NewCampusService(conf, nil, nil, proxies)
+
+	request := func(clientIP string) int {
+		r := httptest.NewRequest(http.MethodPost, "/campus/requestToken",
+			strings.NewReader(`{"apiKey": "APIabcdefg", "room": "exam", "identity": "student"}`))
+		r.RemoteAddr = "10.0.0.1:1234"
+		r.Header.Set("X-Forwarded-For", clientIP)
+		r.Header.Set("X-Campus-Secret", "campus-secret")
+		w := httptest.NewRecorder()
+		s.RequestToken(w, r)
+		return w.Code
+	}
+
+	// clients behind the proxy are limited separately
+	require.Equal(t, http.StatusOK, request("203.0.113.1"))
+	require.Equal(t, http.StatusTooManyRequests, request("203.0.113.1"))
+	require.Equal(t, http.StatusOK, request("203.0.113.2"))
+}
+
+func TestCampusRequestToken_NoSecret(t *testing.T) {
+	conf := &config.Config{
+		Keys: map[string]string{"APIabcdefg": "somesecretencodedinbase62"},
+		Campus: config.CampusConfig{
+			Grants:   config.CampusGrantTemplate{RoomJoin: true},
+			Roles:    config.DefaultCampusRoles(),
+			TokenTTL: time.Hour,
+		},
+	}
+	s := service.NewCampusService(conf, nil, nil, nil)
+
+	for _, body := range []string{
+		`{"apiKey": "APIabcdefg", "room": "exam", "identity": "student"}`,
+		`{"apiKey": "APIabcdefg", "room": "exam", "identity": "student", "role": "moderator"}`,
+	} {
+		r := httptest.NewRequest(http.MethodPost, "/campus/requestToken", strings.NewReader(body))
+		r.Header.Set("X-Campus-Secret", "")
+		w := httptest.NewRecorder()
+		s.RequestToken(w, r)
+		require.Equal(t, http.StatusForbidden, w.Code)
+		require.NotContains(t, w.Body.String(), `"token"`)
+	}
+}
+
+func TestCampusDefaultRoles(t *testing.T) {
+	conf := &config.Config{
+		Keys: map[string]string{"APIabcdefg": "somesecretencodedinbase62"},
+		Campus: config.CampusConfig{
+			Secret:   "campus-secret",
+			Grants:   config.CampusGrantTemplate{RoomJoin: true},
+			Roles:    config.DefaultCampusRoles(),
+			TokenTTL: time.Hour,
+		},
+	}
+	s := service.NewCampusService(conf, nil, nil, nil)
+
+	testCases := []struct {
+		role   string
+		status int
+	}{
+		{role: "participant", status: http.StatusOK},
+		{role: "moderator", status: http.StatusBadRequest},
+		{role: "publisher", status: http.StatusBadRequest},
+		{role: "viewer", status: http.StatusBadRequest},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.role, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/campus/requestToken", strings.NewReader(
+				`{"apiKey": "APIabcdefg", "room": "exam", "identity": "student", "role": "`+tc.role+`"}`))
+			r.Header.Set("X-Campus-Secret", "campus-secret")
+			w := httptest.NewRecorder()
+			s.RequestToken(w, r)
+			require.Equal(t, tc.status, w.Code)
+			if tc.status != http.StatusOK {
+				return
+			}
+
+			var res struct {
+				Data struct {
+					Token string `json:"token"`
+				} `json:"data"`
+			}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+			v, err := auth.ParseAPIToken(res.Data.Token)
+			require.NoError(t, err)
+			claims, err := v.Verify("somesecretencodedinbase62")
+			require.NoError(t, err)
+			require.True(t, claims.Video.RoomJoin)
+			require.False(t, claims.Video.RoomAdmin)
+			require.False(t, claims.Video.RoomList)
+			require.False(t, claims.Video.Recorder)
+			require.False(t, claims.Video.Hidden)
+		})
+	}
+
+	// no default role carries privileged grants
+	for name, role := range config.DefaultCampusRoles() {
+		require.False(t, role.RoomAdmin || role.RoomList || role.Recorder || role.Hidden, name)
+	}
+}
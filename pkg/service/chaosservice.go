@@ -0,0 +1,152 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"encoding/json"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/logger"
+
+	"github.com/livekit/livekit-server/pkg/routing"
+	"github.com/livekit/livekit-server/pkg/rtc"
+	"github.com/livekit/livekit-server/pkg/rtc/types"
+)
+
+const (
+	chaosPath = "/debug/chaos"
+
+	chaosActionDropRedis  = "drop_redis"
+	chaosActionStallStats = "stall_stats"
+	chaosActionKillRoom   = "kill_room"
+
+	defaultChaosDuration = 30 * time.Second
+)
+
+type chaosRequest struct {
+	Action string `json:"action"`
+	// how long drop_redis and stall_stats last, defaults to 30s
+	DurationMs int64 `json:"duration_ms,omitempty"`
+	// room to kill, a random room on this node when empty
+	Room string `json:"room,omitempty"`
+}
+
+type chaosResponse struct {
+	Action     string `json:"action"`
+	DurationMs int64  `json:"duration_ms,omitempty"`
+	Room       string `json:"room,omitempty"`
+}
+
+// ChaosService injects failures on the node handling the request, so resilience of routing and failover can be tested.
+// It is only served in development mode.
+type ChaosService struct {
+	router      routing.Router
+	roomManager *RoomManager
+}
+
+func NewChaosService(router routing.Router, roomManager *RoomManager) *ChaosService {
+	return &ChaosService{
+		router:      router,
+		roomManager: roomManager,
+	}
+}
+
+func (s *ChaosService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if err := EnsureCreatePermission(r.Context()); err != nil {
+		handleError(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	var req chaosRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		handleError(w, http.StatusBadRequest, err)
+		return
+	}
+	duration := defaultChaosDuration
+	if req.DurationMs > 0 {
+		duration = time.Duration(req.DurationMs) * time.Millisecond
+	}
+	res := &chaosResponse{Action: req.Action}
+
+	switch req.Action {
+	case chaosActionDropRedis, chaosActionStallStats:
+		chaosRouter, ok := s.router.(routing.ChaosRouter)
+		if !ok {
+			handleError(w, http.StatusServiceUnavailable, ErrOperationFailed, "action", req.Action)
+			return
+		}
+		if req.Action == chaosActionDropRedis {
+			if err := chaosRouter.DropRedisConnection(duration); err != nil {
+				handleError(w, http.StatusServiceUnavailable, err, "action", req.Action)
+				return
+			}
+		} else {
+			chaosRouter.StallStats(duration)
+		}
+		res.DurationMs = duration.Milliseconds()
+
+	case chaosActionKillRoom:
+		room := s.pickRoom(livekit.RoomName(req.Room))
+		if room == nil {
+			handleError(w, http.StatusNotFound, ErrRoomNotFound, "room", req.Room)
+			return
+		}
+		killRoom(room)
+		res.Room = string(room.Name())
+
+	default:
+		handleError(w, http.StatusBadRequest, ErrInvalidChaosAction, "action", req.Action)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(res)
+}
+
+func (s *ChaosService) pickRoom(roomName livekit.RoomName) *rtc.Room {
+	s.roomManager.lock.RLock()
+	defer s.roomManager.lock.RUnlock()
+
+	if roomName != "" {
+		return s.roomManager.rooms[roomName]
+	}
+	if len(s.roomManager.rooms) == 0 {
+		return nil
+	}
+	pick := rand.Intn(len(s.roomManager.rooms))
+	for _, room := range s.roomManager.rooms {
+		if pick == 0 {
+			return room
+		}
+		pick--
+	}
+	return nil
+}
+
+// drops participants as if the node failed, then closes the room
+func killRoom(room *rtc.Room) {
+	logger.Infow("chaos: killing room", "room", room.Name(), "roomID", room.ID())
+	for _, p := range room.GetParticipants() {
+		_ = p.Close(false, types.ParticipantCloseReasonSimulateNodeFailure, true)
+	}
+	room.Close()
+}
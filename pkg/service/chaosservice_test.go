@@ -0,0 +1,109 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/protocol/auth"
+	"github.com/livekit/protocol/livekit"
+
+	"github.com/livekit/livekit-server/pkg/routing"
+	"github.com/livekit/livekit-server/pkg/routing/routingfakes"
+	"github.com/livekit/livekit-server/pkg/rtc"
+)
+
+type chaosRouter struct {
+	routingfakes.FakeRouter
+	stalled time.Duration
+	dropped time.Duration
+	dropErr error
+}
+
+func (r *chaosRouter) StallStats(duration time.Duration) {
+	r.stalled = duration
+}
+
+func (r *chaosRouter) DropRedisConnection(duration time.Duration) error {
+	if r.dropErr != nil {
+		return r.dropErr
+	}
+	r.dropped = duration
+	return nil
+}
+
+func TestChaosService(t *testing.T) {
+	testCases := []struct {
+		name     string
+		method   string
+		body     string
+		noGrants bool
+		// router without failure injection
+		plainRouter bool
+		dropErr     bool
+		status      int
+		stalled     time.Duration
+		dropped     time.Duration
+	}{
+		{name: "post only", method: http.MethodGet, status: http.StatusMethodNotAllowed},
+		{name: "no permission", method: http.MethodPost, body: `{"action":"stall_stats"}`, noGrants: true, status: http.StatusUnauthorized},
+		{name: "invalid body", method: http.MethodPost, body: `{`, status: http.StatusBadRequest},
+		{name: "invalid action", method: http.MethodPost, body: `{"action":"reboot"}`, status: http.StatusBadRequest},
+		{name: "stall stats", method: http.MethodPost, body: `{"action":"stall_stats"}`, status: http.StatusOK, stalled: defaultChaosDuration},
+		{name: "stall stats for duration", method: http.MethodPost, body: `{"action":"stall_stats","duration_ms":5000}`, status: http.StatusOK, stalled: 5 * time.Second},
+		{name: "drop redis", method: http.MethodPost, body: `{"action":"drop_redis","duration_ms":1000}`, status: http.StatusOK, dropped: time.Second},
+		{name: "drop redis without redis", method: http.MethodPost, body: `{"action":"drop_redis"}`, dropErr: true, status: http.StatusServiceUnavailable},
+		{name: "router without failure injection", method: http.MethodPost, body: `{"action":"stall_stats"}`, plainRouter: true, status: http.StatusServiceUnavailable},
+		{name: "kill room without rooms", method: http.MethodPost, body: `{"action":"kill_room"}`, status: http.StatusNotFound},
+		{name: "kill unknown room", method: http.MethodPost, body: `{"action":"kill_room","room":"class"}`, status: http.StatusNotFound},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cr := &chaosRouter{}
+			if tc.dropErr {
+				cr.dropErr = routing.ErrRedisNotConfigured
+			}
+			var router routing.Router = cr
+			if tc.plainRouter {
+				router = &routingfakes.FakeRouter{}
+			}
+			s := NewChaosService(router, &RoomManager{rooms: make(map[livekit.RoomName]*rtc.Room)})
+
+			req := httptest.NewRequest(tc.method, chaosPath, bytes.NewBufferString(tc.body))
+			if !tc.noGrants {
+				req = req.WithContext(WithGrants(req.Context(), &auth.ClaimGrants{Video: &auth.VideoGrant{RoomCreate: true}}))
+			}
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, req)
+			require.Equal(t, tc.status, w.Code)
+			require.Equal(t, tc.stalled, cr.stalled)
+			require.Equal(t, tc.dropped, cr.dropped)
+			if tc.status != http.StatusOK {
+				return
+			}
+
+			var res chaosResponse
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&res))
+			require.Equal(t, (tc.stalled + tc.dropped).Milliseconds(), res.DurationMs)
+		})
+	}
+}
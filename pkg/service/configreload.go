@@ -0,0 +1,147 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"context"
+	"sync"
+
+	"github.com/livekit/protocol/auth"
+	"github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/logger"
+	"github.com/livekit/protocol/webhook"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/logging"
+	"github.com/livekit/livekit-server/pkg/webhooks"
+)
+
+// configReloader is implemented by components applying settings of a reloaded config, see config.Watcher
+type configReloader interface {
+	ReloadConfig(conf *config.Config)
+}
+
+// ReloadConfig applies the reloadable settings of conf: logging, API keys, room defaults, webhook URLs and limits.
+// Rooms and participants that already exist keep the settings they were created with.
+func (s *LivekitServer) ReloadConfig(conf *config.Config) {
+	if err := logging.InitFromConfig(&conf.Logging); err != nil {
+		logger.Errorw("could not apply reloaded logging config", err)
+	}
+	if kp, ok := s.keyProvider.(*reloadableKeyProvider); ok {
+		if err := kp.reload(conf); err != nil {
+			logger.Errorw("could not apply reloaded keys, keeping the current ones", err)
+		}
+	}
+	if n, ok := s.webhookNotifier.(*reloadableNotifier); ok {
+		if err := n.reload(conf); err != nil {
+			logger.Errorw("could not apply reloaded webhook config, keeping the current one", err)
+		}
+	}
+
+	for _, c := range []any{s.roomService, s.rtcService, s.roomManager} {
+		if r, ok := c.(configReloader); ok {
+			r.ReloadConfig(conf)
+		}
+	}
+}
+
+// reloadableKeyProvider swaps the API keys when the config is reloaded
+type reloadableKeyProvider struct {
+	lock     sync.RWMutex
+	provider auth.KeyProvider
+}
+
+func newReloadableKeyProvider(provider auth.KeyProvider) *reloadableKeyProvider {
+	return &reloadableKeyProvider{
+		provider: provider,
+	}
+}
+
+func (p *reloadableKeyProvider) GetSecret(key string) string {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	return p.provider.GetSecret(key)
+}
+
+func (p *reloadableKeyProvider) NumKeys() int {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	return p.provider.NumKeys()
+}
+
+func (p *reloadableKeyProvider) reload(conf *config.Config) error {
+	provider, err := loadKeyProvider(conf)
+	if err != nil {
+		return err
+	}
+
+	p.lock.Lock()
+	p.provider = provider
+	p.lock.Unlock()
+	return nil
+}
+
+// reloadableNotifier rebuilds the webhook notifier when the config is reloaded, webhooks may be enabled or
+// disabled at runtime
+type reloadableNotifier struct {
+	keyProvider auth.KeyProvider
+	store       ObjectStore
+	deadLetters *webhooks.DeadLetters
+	archive     *webhooks.Archive
+
+	lock     sync.RWMutex
+	notifier webhook.QueuedNotifier
+}
+
+func newReloadableNotifier(
+	notifier webhook.QueuedNotifier,
+	keyProvider auth.KeyProvider,
+	store ObjectStore,
+	deadLetters *webhooks.DeadLetters,
+	archive *webhooks.Archive,
+) *reloadableNotifier {
+	return &reloadableNotifier{
+		keyProvider: keyProvider,
+		store:       store,
+		deadLetters: deadLetters,
+		archive:     archive,
+		notifier:    notifier,
+	}
+}
+
+func (n *reloadableNotifier) QueueNotify(ctx context.Context, event *livekit.WebhookEvent) error {
+	n.lock.RLock()
+	notifier := n.notifier
+	n.lock.RUnlock()
+
+	if notifier == nil {
+		return nil
+	}
+	return notifier.QueueNotify(ctx, event)
+}
+
+func (n *reloadableNotifier) reload(conf *config.Config) error {
+	notifier, err := loadWebhookNotifier(conf, n.keyProvider, n.store, n.deadLetters, n.archive)
+	if err != nil {
+		return err
+	}
+
+	n.lock.Lock()
+	n.notifier = notifier
+	n.lock.Unlock()
+	return nil
+}
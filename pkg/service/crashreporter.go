@@ -0,0 +1,103 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/logger"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/rtc"
+)
+
+const crashReportTimeout = 10 * time.Second
+
+// CrashReporter writes crash reports of recovered panics to the configured file and URL
+type CrashReporter struct {
+	conf   config.CrashReportConfig
+	nodeID livekit.NodeID
+	client *http.Client
+
+	fileMu sync.Mutex
+}
+
+// NewCrashReporter returns nil when no sink is configured
+func NewCrashReporter(conf config.CrashReportConfig, nodeID livekit.NodeID) *CrashReporter {
+	if conf.File == "" && conf.URL == "" {
+		return nil
+	}
+	return &CrashReporter{
+		conf:   conf,
+		nodeID: nodeID,
+		client: &http.Client{Timeout: crashReportTimeout},
+	}
+}
+
+func (c *CrashReporter) Report(report *rtc.CrashReport) {
+	report.NodeID = c.nodeID
+	data, err := json.Marshal(report)
+	if err != nil {
+		logger.Errorw("could not marshal crash report", err)
+		return
+	}
+
+	if c.conf.File != "" {
+		if err := c.writeFile(data); err != nil {
+			logger.Errorw("could not write crash report", err, "file", c.conf.File)
+		}
+	}
+	if c.conf.URL != "" {
+		go func() {
+			if err := c.post(data); err != nil {
+				logger.Errorw("could not send crash report", err, "url", c.conf.URL)
+			}
+		}()
+	}
+}
+
+func (c *CrashReporter) writeFile(data []byte) error {
+	c.fileMu.Lock()
+	defer c.fileMu.Unlock()
+
+	f, err := os.OpenFile(c.conf.File, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0640)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(append(data, '\n')); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (c *CrashReporter) post(data []byte) error {
+	resp, err := c.client.Post(c.conf.URL, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
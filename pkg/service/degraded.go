@@ -0,0 +1,35 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"errors"
+
+	"github.com/livekit/livekit-server/pkg/routing"
+)
+
+// degradedRouter returns the router while it cannot reach redis and only routes the rooms hosted on this node,
+// nil otherwise
+func degradedRouter(router routing.MessageRouter) routing.DegradedModeRouter {
+	if dr, ok := router.(routing.DegradedModeRouter); ok && dr.IsDegraded() {
+		return dr
+	}
+	return nil
+}
+
+// isPlacementUnavailable is true for joins refused because the room could not be located or placed without redis
+func isPlacementUnavailable(err error) bool {
+	return errors.Is(err, ErrPlacementUnavailable) || errors.Is(err, routing.ErrRedisUnavailable)
+}
@@ -0,0 +1,78 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/livekit/protocol/livekit"
+)
+
+const departureTimeoutPath = "/rooms/departure_timeout"
+
+type departureTimeoutRequest struct {
+	Room    string `json:"room"`
+	Seconds uint32 `json:"seconds"`
+}
+
+// DepartureTimeoutService reads and changes how long a room lingers after everyone left, before it's closed and
+// room_finished is sent. Only rooms hosted on the node handling the request can be changed.
+type DepartureTimeoutService struct {
+	roomManager *RoomManager
+}
+
+func NewDepartureTimeoutService(roomManager *RoomManager) *DepartureTimeoutService {
+	return &DepartureTimeoutService{
+		roomManager: roomManager,
+	}
+}
+
+func (s *DepartureTimeoutService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req departureTimeoutRequest
+	switch r.Method {
+	case http.MethodGet:
+		req.Room = r.URL.Query().Get("room")
+	case http.MethodPost:
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			handleError(w, http.StatusBadRequest, err)
+			return
+		}
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := EnsureAdminPermission(r.Context(), livekit.RoomName(req.Room)); err != nil {
+		handleError(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	room := s.roomManager.GetRoom(r.Context(), livekit.RoomName(req.Room))
+	if room == nil {
+		handleError(w, http.StatusNotFound, ErrRoomNotFound, "room", req.Room)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		room.SetDepartureTimeout(req.Seconds)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(&departureTimeoutRequest{
+		Room:    req.Room,
+		Seconds: room.DepartureTimeout(),
+	})
+}
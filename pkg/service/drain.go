@@ -0,0 +1,247 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/protocol/logger"
+)
+
+const (
+	drainPath          = "/drain"
+	readyPath          = "/ready"
+	drainCheckInterval = 5 * time.Second
+)
+
+type drainStatus struct {
+	Draining     bool  `json:"draining"`
+	Drained      bool  `json:"drained"`
+	StartedAt    int64 `json:"started_at,omitempty"`
+	Deadline     int64 `json:"deadline,omitempty"`
+	Rooms        int   `json:"rooms"`
+	Participants int   `json:"participants"`
+}
+
+// Drain stops this node from accepting new rooms and waits for the rooms it hosts to empty. Participants still
+// connected after drain.max_duration are asked to reconnect, which moves their rooms to other nodes.
+// Draining does not stop the server.
+func (s *LivekitServer) Drain() {
+	now := time.Now()
+	if !s.drainStartedAt.CompareAndSwap(0, now.UnixNano()) {
+		return
+	}
+
+	maxDuration := s.config.Drain.MaxDuration
+	logger.Infow("draining node", "maxDuration", maxDuration)
+	go s.takeOutOfRotation("drain")
+
+	var deadline time.Time
+	if maxDuration > 0 {
+		deadline = now.Add(maxDuration)
+	}
+	go s.drainWorker(deadline)
+}
+
+func (s *LivekitServer) drainWorker(deadline time.Time) {
+	// /ready already fails, keep taking rooms until load balancers stop sending new connections
+	if grace := s.config.Drain.LBGracePeriod; grace > 0 {
+		select {
+		case <-s.doneChan:
+			return
+		case <-time.After(grace):
+		}
+	}
+	s.router.Drain()
+	s.roomManager.Drain()
+
+	ticker := time.NewTicker(drainCheckInterval)
+	defer ticker.Stop()
+
+	for s.roomManager.HasParticipants() {
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			logger.Infow("drain timed out, moving rooms to other nodes")
+			s.roomManager.MigrateRooms(context.Background())
+			break
+		}
+
+		select {
+		case <-s.doneChan:
+			return
+		case <-ticker.C:
+		}
+	}
+	logger.Infow("node drained")
+}
+
+// drainHandler reports the drain status, POST starts draining the node
+func (s *LivekitServer) drainHandler(w http.ResponseWriter, r *http.Request) {
+	if err := EnsureCreatePermission(r.Context()); err != nil {
+		handleError(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		s.Drain()
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	res := &drainStatus{}
+	res.Rooms, res.Participants = s.roomManager.NumRoomsAndParticipants()
+	if startedAt := s.drainStartedAt.Load(); startedAt != 0 {
+		res.Draining = true
+		res.Drained = res.Participants == 0
+		res.StartedAt = time.Unix(0, startedAt).Unix()
+		if maxDuration := s.config.Drain.MaxDuration; maxDuration > 0 {
+			res.Deadline = time.Unix(0, startedAt).Add(maxDuration).Unix()
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(res)
+}
+
+// ready is the readiness probe for load balancers, failing once the node drains or shuts down so new
+// signal connections go to other nodes
+func (s *LivekitServer) ready(w http.ResponseWriter, r *http.Request) {
+	if s.isOutOfRotation() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte("Draining"))
+		return
+	}
+	s.healthCheck(w, r)
+}
+
+func (s *LivekitServer) isOutOfRotation() bool {
+	return s.drainStartedAt.Load() != 0 || s.outOfRotation.Load()
+}
+
+// takeOutOfRotation deregisters the node from service discovery, once
+func (s *LivekitServer) takeOutOfRotation(reason string) {
+	if s.outOfRotation.Swap(true) {
+		return
+	}
+
+	conf := &s.config.Drain
+	if conf.Consul.ServiceID != "" {
+		if err := s.deregisterConsul(&conf.Consul); err != nil {
+			logger.Warnw("could not deregister from consul", err, "serviceID", conf.Consul.ServiceID)
+		} else {
+			logger.Infow("deregistered from consul", "serviceID", conf.Consul.ServiceID)
+		}
+	}
+	for i := range conf.Deregister {
+		d := &conf.Deregister[i]
+		if err := s.callDeregister(d, reason); err != nil {
+			logger.Warnw("could not deregister from load balancer", err, "url", d.URL)
+		} else {
+			logger.Infow("deregistered from load balancer", "url", d.URL)
+		}
+	}
+}
+
+func (s *LivekitServer) deregisterConsul(conf *config.DrainConsulConfig) error {
+	u := strings.TrimSuffix(conf.Address, "/") + "/v1/agent/service/deregister/" + url.PathEscape(conf.ServiceID)
+	req, err := http.NewRequest(http.MethodPut, u, nil)
+	if err != nil {
+		return err
+	}
+	if conf.Token != "" {
+		req.Header.Set("X-Consul-Token", conf.Token)
+	}
+	return s.doDeregister(&http.Client{}, req)
+}
+
+type deregisterRequest struct {
+	NodeID string `json:"node_id"`
+	Region string `json:"region,omitempty"`
+	Reason string `json:"reason"`
+}
+
+func (s *LivekitServer) callDeregister(conf *config.DrainDeregisterConfig, reason string) error {
+	body := []byte(conf.Body)
+	if conf.Body == "" {
+		var err error
+		body, err = json.Marshal(&deregisterRequest{
+			NodeID: s.currentNode.Id,
+			Region: s.config.Region,
+			Reason: reason,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequest(conf.Method, conf.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if conf.Body == "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range conf.Headers {
+		req.Header.Set(k, v)
+	}
+	if conf.BearerTokenFile != "" {
+		token, err := os.ReadFile(conf.BearerTokenFile)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+
+	client := &http.Client{}
+	if conf.CAFile != "" {
+		pem, err := os.ReadFile(conf.CAFile)
+		if err != nil {
+			return err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificates in %s", conf.CAFile)
+		}
+		client.Transport = &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}
+	}
+	return s.doDeregister(client, req)
+}
+
+func (s *LivekitServer) doDeregister(client *http.Client, req *http.Request) error {
+	client.Timeout = s.config.Drain.DeregisterTimeout
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(io.Discard, res.Body)
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d", res.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,296 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/protocol/auth"
+	"github.com/livekit/protocol/livekit"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/routing/routingfakes"
+	"github.com/livekit/livekit-server/pkg/rtc"
+)
+
+func TestDrainHandler(t *testing.T) {
+	router := &routingfakes.FakeRouter{}
+	s := &LivekitServer{
+		config:      &config.Config{Drain: config.DrainConfig{MaxDuration: time.Hour}},
+		router:      router,
+		roomManager: &RoomManager{rooms: make(map[livekit.RoomName]*rtc.Room), migrating: make(map[livekit.RoomName]bool)},
+	}
+	grants := &auth.ClaimGrants{Video: &auth.VideoGrant{RoomCreate: true}}
+
+	testCases := []struct {
+		name     string
+		method   string
+		noGrants bool
+		status   int
+		draining bool
+	}{
+		{name: "not draining", method: http.MethodGet, status: http.StatusOK},
+		{name: "no permission", method: http.MethodPost, noGrants: true, status: http.StatusUnauthorized},
+		{name: "invalid method", method: http.MethodPut, status: http.StatusMethodNotAllowed},
+		{name: "drain", method: http.MethodPost, status: http.StatusOK, draining: true},
+		{name: "draining", method: http.MethodGet, status: http.StatusOK, draining: true},
+		// draining again keeps the start and deadline
+		{name: "drain again", method: http.MethodPost, status: http.StatusOK, draining: true},
+	}
+	var startedAt int64
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, drainPath, nil)
+			if !tc.noGrants {
+				req = req.WithContext(WithGrants(req.Context(), grants))
+			}
+			w := httptest.NewRecorder()
+			s.drainHandler(w, req)
+			require.Equal(t, tc.status, w.Code)
+			if tc.status != http.StatusOK {
+				return
+			}
+
+			var res drainStatus
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&res))
+			require.Equal(t, tc.draining, res.Draining)
+			if !tc.draining {
+				require.Zero(t, res.StartedAt)
+				require.False(t, s.roomManager.IsDraining())
+				return
+			}
+			// rooms stop being taken once draining got going
+			require.Eventually(t, s.roomManager.IsDraining, time.Second, 10*time.Millisecond)
+			// without rooms the node is drained right away
+			require.True(t, res.Drained)
+			require.Equal(t, res.StartedAt+int64(time.Hour/time.Second), res.Deadline)
+			if startedAt == 0 {
+				startedAt = res.StartedAt
+			}
+			require.Equal(t, startedAt, res.StartedAt)
+			require.Equal(t, 1, router.DrainCallCount())
+		})
+	}
+}
+
+func TestDrainingRoomManager(t *testing.T) {
+	store := NewLocalStore()
+	require.NoError(t, store.StoreRoom(context.Background(), &livekit.Room{Name: "class"}, nil))
+	r := &RoomManager{
+		roomStore: store,
+		rooms:     make(map[livekit.RoomName]*rtc.Room),
+		migrating: make(map[livekit.RoomName]bool),
+	}
+	r.Drain()
+
+	// rooms are no longer created on a draining node
+	_, err := r.getOrCreateRoom(context.Background(), "class")
+	require.ErrorIs(t, err, ErrNodeDraining)
+
+	numRooms, numParticipants := r.NumRoomsAndParticipants()
+	require.Zero(t, numRooms)
+	require.Zero(t, numParticipants)
+	require.False(t, r.HasParticipants())
+
+	// only rooms being moved finish migrating
+	r.migrating["class"] = true
+	require.False(t, r.finishMigration("other"))
+	require.True(t, r.finishMigration("class"))
+	require.False(t, r.finishMigration("class"))
+}
+
+func TestReady(t *testing.T) {
+	testCases := []struct {
+		name          string
+		statsAge      time.Duration
+		draining      bool
+		outOfRotation bool
+		status        int
+	}{
+		{name: "ready", status: http.StatusOK},
+		{name: "stale stats", statsAge: time.Minute, status: http.StatusNotAcceptable},
+		{name: "draining", draining: true, status: http.StatusServiceUnavailable},
+		{name: "shutting down", outOfRotation: true, status: http.StatusServiceUnavailable},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := &LivekitServer{
+				config: &config.Config{},
+				currentNode: &livekit.Node{
+					Id:    "node",
+					Stats: &livekit.NodeStats{UpdatedAt: time.Now().Add(-tc.statsAge).Unix()},
+				},
+			}
+			if tc.draining {
+				s.drainStartedAt.Store(time.Now().Unix())
+			}
+			s.outOfRotation.Store(tc.outOfRotation)
+
+			w := httptest.NewRecorder()
+			s.ready(w, httptest.NewRequest(http.MethodGet, readyPath, nil))
+			require.Equal(t, tc.status, w.Code)
+		})
+	}
+}
+
+type deregisterCall struct {
+	method string
+	path   string
+	header http.Header
+	body   string
+}
+
+func TestTakeOutOfRotation(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("secret\n"), 0600))
+
+	testCases := []struct {
+		name       string
+		consul     bool
+		deregister config.DrainDeregisterConfig
+		status     int
+		expected   []deregisterCall
+	}{
+		{
+			name:   "consul",
+			consul: true,
+			status: http.StatusOK,
+			expected: []deregisterCall{{
+				method: http.MethodPut,
+				path:   "/v1/agent/service/deregister/livekit-1",
+				header: http.Header{"X-Consul-Token": {"consul-token"}},
+			}},
+		},
+		{
+			name:       "default body",
+			deregister: config.DrainDeregisterConfig{Method: http.MethodPost},
+			status:     http.StatusOK,
+			expected: []deregisterCall{{
+				method: http.MethodPost,
+				path:   "/deregister",
+				header: http.Header{"Content-Type": {"application/json"}},
+				body:   `{"node_id":"node","region":"us-east","reason":"drain"}`,
+			}},
+		},
+		{
+			name: "custom body and headers",
+			deregister: config.DrainDeregisterConfig{
+				Method:          http.MethodPatch,
+				Body:            `{"metadata":{"labels":{"serving":"false"}}}`,
+				Headers:         map[string]string{"Content-Type": "application/merge-patch+json"},
+				BearerTokenFile: tokenFile,
+			},
+			status: http.StatusOK,
+			expected: []deregisterCall{{
+				method: http.MethodPatch,
+				path:   "/deregister",
+				header: http.Header{
+					"Content-Type":  {"application/merge-patch+json"},
+					"Authorization": {"Bearer secret"},
+				},
+				body: `{"metadata":{"labels":{"serving":"false"}}}`,
+			}},
+		},
+		{
+			// failures are logged, the node still goes out of rotation
+			name:       "endpoint failing",
+			deregister: config.DrainDeregisterConfig{Method: http.MethodPost},
+			status:     http.StatusInternalServerError,
+			expected: []deregisterCall{{
+				method: http.MethodPost,
+				path:   "/deregister",
+			}},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var calls []deregisterCall
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				calls = append(calls, deregisterCall{method: r.Method, path: r.URL.Path, header: r.Header, body: string(body)})
+				w.WriteHeader(tc.status)
+			}))
+			defer ts.Close()
+
+			conf := &config.Config{Region: "us-east", Drain: config.DrainConfig{DeregisterTimeout: time.Second}}
+			if tc.consul {
+				conf.Drain.Consul = config.DrainConsulConfig{Address: ts.URL + "/", ServiceID: "livekit-1", Token: "consul-token"}
+			}
+			if tc.deregister.Method != "" {
+				tc.deregister.URL = ts.URL + "/deregister"
+				conf.Drain.Deregister = []config.DrainDeregisterConfig{tc.deregister}
+			}
+			s := &LivekitServer{config: conf, currentNode: &livekit.Node{Id: "node"}}
+
+			s.takeOutOfRotation("drain")
+			// only the first drain or shutdown deregisters
+			s.takeOutOfRotation("shutdown")
+			require.True(t, s.isOutOfRotation())
+
+			require.Len(t, calls, len(tc.expected))
+			for i, expected := range tc.expected {
+				require.Equal(t, expected.method, calls[i].method)
+				require.Equal(t, expected.path, calls[i].path)
+				for k := range expected.header {
+					require.Equal(t, expected.header.Get(k), calls[i].header.Get(k), k)
+				}
+				if expected.body != "" {
+					require.Equal(t, expected.body, calls[i].body)
+				}
+			}
+		})
+	}
+}
+
+func TestDoDeregister(t *testing.T) {
+	testCases := []struct {
+		name   string
+		status int
+		err    bool
+	}{
+		{name: "ok", status: http.StatusOK},
+		{name: "no content", status: http.StatusNoContent},
+		{name: "not found", status: http.StatusNotFound, err: true},
+		{name: "server error", status: http.StatusBadGateway, err: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tc.status)
+			}))
+			defer ts.Close()
+
+			s := &LivekitServer{config: &config.Config{Drain: config.DrainConfig{DeregisterTimeout: time.Second}}}
+			req, err := http.NewRequest(http.MethodPost, ts.URL, nil)
+			require.NoError(t, err)
+			err = s.doDeregister(&http.Client{}, req)
+			if tc.err {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/livekit/protocol/livekit"
+)
+
+const e2eePath = "/rooms/e2ee"
+
+type e2eeRequest struct {
+	Room string `json:"room"`
+	// rotates the key of a participant when keys are per participant, all keys when empty
+	ParticipantIdentity string `json:"participant_identity,omitempty"`
+}
+
+type e2eeResponse struct {
+	Room string `json:"room"`
+	// current index of each key, keyed by the identity of the participant encrypting with it, empty for a shared key
+	KeyIndexes map[livekit.ParticipantIdentity]uint8 `json:"key_indexes"`
+}
+
+// E2EEService lists the keys of an end-to-end encrypted room and rotates them, key material is only ever sent
+// to participants. Only rooms hosted on the node handling the request can be changed.
+type E2EEService struct {
+	roomManager *RoomManager
+}
+
+func NewE2EEService(roomManager *RoomManager) *E2EEService {
+	return &E2EEService{
+		roomManager: roomManager,
+	}
+}
+
+func (s *E2EEService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req e2eeRequest
+	switch r.Method {
+	case http.MethodGet:
+		req.Room = r.URL.Query().Get("room")
+	case http.MethodPost:
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			handleError(w, http.StatusBadRequest, err)
+			return
+		}
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := EnsureAdminPermission(r.Context(), livekit.RoomName(req.Room)); err != nil {
+		handleError(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	room := s.roomManager.GetRoom(r.Context(), livekit.RoomName(req.Room))
+	if room == nil {
+		handleError(w, http.StatusNotFound, ErrRoomNotFound, "room", req.Room)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		if err := room.RotateE2EEKey(livekit.ParticipantIdentity(req.ParticipantIdentity)); err != nil {
+			handleError(w, http.StatusBadRequest, err, "room", req.Room, "participant", req.ParticipantIdentity)
+			return
+		}
+	}
+
+	indexes := room.E2EEKeyIndexes()
+	if indexes == nil {
+		handleError(w, http.StatusBadRequest, ErrE2EEDisabled, "room", req.Room)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(&e2eeResponse{
+		Room:       req.Room,
+		KeyIndexes: indexes,
+	})
+}
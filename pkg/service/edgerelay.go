@@ -0,0 +1,536 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pion/turn/v2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/logger"
+	"github.com/livekit/protocol/rpc"
+	"github.com/livekit/protocol/utils"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/routing"
+	"github.com/livekit/livekit-server/pkg/telemetry/prometheus"
+)
+
+// there is no generated service, the relay exchanges the signal relay messages of psrpc
+const (
+	edgeRelayMethod = "/livekit.EdgeRelay/RelaySignal"
+
+	edgeSecretMetadata    = "x-livekit-edge-secret"
+	edgeAPIKeyMetadata    = "x-livekit-api-key"
+	edgeErrorCodeMetadata = "x-livekit-error-code"
+
+	errorCodeRoomSealed = "room_sealed"
+
+	// core nodes wait this long for the RTC node to answer a relayed join
+	edgeRelayJoinTimeout  = 10 * time.Second
+	edgeRelayKeepalive    = 30 * time.Second
+	edgeTURNCredentialTTL = 24 * time.Hour
+)
+
+var edgeRelayStream = grpc.StreamDesc{
+	StreamName:    "RelaySignal",
+	ServerStreams: true,
+	ClientStreams: true,
+}
+
+var edgeRelayService = grpc.ServiceDesc{
+	ServiceName: "livekit.EdgeRelay",
+	HandlerType: (*interface{})(nil),
+}
+
+func init() {
+	// not set in the declarations, the handler opens edgeRelayStream itself when the room is on another core node
+	edgeRelayStream.Handler = relaySignalHandler
+	edgeRelayService.Streams = []grpc.StreamDesc{edgeRelayStream}
+}
+
+// join refusals of core nodes, relayed to clients of edges with the same error code
+var edgeJoinErrors = map[string]error{
+	errorCodeRoomNotFound:         ErrRoomNotCreated,
+	errorCodeSessionLimitExceeded: ErrSessionLimitExceeded,
+	errorCodeQuotaExceeded:        ErrQuotaExceeded,
+	errorCodePlacementUnavailable: ErrPlacementUnavailable,
+	errorCodeRoomSealed:           ErrRoomSealed,
+}
+
+func joinErrorCode(err error) string {
+	switch {
+	case errors.Is(err, ErrRoomNotFound), errors.Is(err, ErrRoomNotCreated):
+		return errorCodeRoomNotFound
+	case errors.Is(err, ErrSessionLimitExceeded):
+		return errorCodeSessionLimitExceeded
+	case errors.Is(err, ErrQuotaExceeded):
+		return errorCodeQuotaExceeded
+	case errors.Is(err, ErrRoomSealed):
+		return errorCodeRoomSealed
+	case isPlacementUnavailable(err):
+		return errorCodePlacementUnavailable
+	}
+	return ""
+}
+
+// EdgeRelayClient relays the signal connections an edge node terminates to core nodes. Edges host no rooms,
+// core nodes place the room and check limits, quotas and seals as for their own connections
+type EdgeRelayClient struct {
+	conf *config.Config
+	conn *grpc.ClientConn
+}
+
+// NewEdgeRelayClient returns nil when the node is not an edge
+func NewEdgeRelayClient(conf *config.Config) (*EdgeRelayClient, error) {
+	if !conf.Edge.IsEdge() {
+		return nil, nil
+	}
+
+	creds := insecure.NewCredentials()
+	if conf.Edge.TLS {
+		tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+		if conf.Edge.CAFile != "" {
+			pem, err := os.ReadFile(conf.Edge.CAFile)
+			if err != nil {
+				return nil, err
+			}
+			tlsConfig.RootCAs = x509.NewCertPool()
+			if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("no certificates found in %s", conf.Edge.CAFile)
+			}
+		}
+		creds = credentials.NewTLS(tlsConfig)
+	}
+	// connects lazily
+	conn, err := grpc.Dial(conf.Edge.CoreAddress,
+		grpc.WithTransportCredentials(creds),
+		grpc.WithKeepaliveParams(keepalive.ClientParameters{Time: edgeRelayKeepalive, PermitWithoutStream: true}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	logger.Infow("relaying signal connections to core nodes", "coreAddress", conf.Edge.CoreAddress, "turn", conf.Edge.TURN)
+	return &EdgeRelayClient{
+		conf: conf,
+		conn: conn,
+	}, nil
+}
+
+func (c *EdgeRelayClient) Close() {
+	if c == nil {
+		return
+	}
+	_ = c.conn.Close()
+}
+
+// StartParticipantSignal opens a relay to a core node, it mirrors MessageRouter.StartParticipantSignal. When the
+// core node refuses the join, the response source closes and Err returns the refusal
+func (c *EdgeRelayClient) StartParticipantSignal(ctx context.Context, roomName livekit.RoomName, pi routing.ParticipantInit) (livekit.ConnectionID, routing.MessageSink, routing.MessageSource, error) {
+	connectionID := livekit.ConnectionID(utils.NewGuid("CO_"))
+	ss, err := pi.ToStartSession(roomName, connectionID)
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	// the relay lasts as long as the signal connection, not the join request
+	streamCtx, cancel := context.WithCancel(metadata.AppendToOutgoingContext(context.Background(),
+		edgeSecretMetadata, c.conf.Edge.Secret,
+		edgeAPIKeyMetadata, GetAPIKey(ctx),
+	))
+	stream, err := c.conn.NewStream(streamCtx, &edgeRelayStream, edgeRelayMethod)
+	if err != nil {
+		cancel()
+		return "", nil, nil, err
+	}
+	if err = stream.SendMsg(&rpc.RelaySignalRequest{StartSession: ss}); err != nil {
+		cancel()
+		return "", nil, nil, err
+	}
+
+	sink := &edgeRequestSink{
+		stream:       stream,
+		connectionID: connectionID,
+	}
+	source := &edgeResponseSource{
+		MessageChannel: routing.NewDefaultMessageChannel(connectionID),
+	}
+	go func() {
+		defer cancel()
+		defer source.Close()
+
+		participantID := pi.ID
+		for {
+			res := &rpc.RelaySignalResponse{}
+			if err := stream.RecvMsg(res); err != nil {
+				if err != io.EOF {
+					source.setErr(edgeRelayError(stream, err))
+				}
+				return
+			}
+			for _, msg := range res.Responses {
+				if join := msg.GetJoin(); join != nil {
+					participantID = livekit.ParticipantID(join.GetParticipant().GetSid())
+				}
+				c.addTURNServer(roomName, participantID, msg)
+				if err := source.WriteMessage(msg); err != nil {
+					logger.Warnw("could not forward relayed signal response", err, "room", roomName, "connID", connectionID)
+					return
+				}
+			}
+			if res.Close {
+				return
+			}
+		}
+	}()
+	return connectionID, sink, source, nil
+}
+
+// addTURNServer hands out the TURN server of the edge ahead of those of the core node, for clients that cannot
+// reach media nodes directly
+func (c *EdgeRelayClient) addTURNServer(roomName livekit.RoomName, participantID livekit.ParticipantID, msg *livekit.SignalResponse) {
+	if !c.conf.Edge.TURN || participantID == "" {
+		return
+	}
+
+	urls := embeddedTURNURLs(c.conf, false)
+	if len(urls) == 0 {
+		return
+	}
+	username := strconv.FormatInt(time.Now().Add(edgeTURNCredentialTTL).Unix(), 10) + ":" + turnUsername(roomName, participantID)
+	iceServer := &livekit.ICEServer{
+		Urls:       urls,
+		Username:   username,
+		Credential: edgeTURNPassword(c.conf.Edge.Secret, username),
+	}
+	switch m := msg.Message.(type) {
+	case *livekit.SignalResponse_Join:
+		m.Join.IceServers = append([]*livekit.ICEServer{iceServer}, m.Join.IceServers...)
+	case *livekit.SignalResponse_Reconnect:
+		m.Reconnect.IceServers = append([]*livekit.ICEServer{iceServer}, m.Reconnect.IceServers...)
+	}
+}
+
+// edgeRelayError returns the join refusal a core node ended the relay with, from the error code of its trailer
+func edgeRelayError(stream grpc.ClientStream, err error) error {
+	if values := stream.Trailer().Get(edgeErrorCodeMetadata); len(values) == 1 {
+		if joinErr, ok := edgeJoinErrors[values[0]]; ok {
+			return joinErr
+		}
+	}
+	return err
+}
+
+type edgeRequestSink struct {
+	lock         sync.Mutex
+	stream       grpc.ClientStream
+	connectionID livekit.ConnectionID
+	closed       bool
+}
+
+func (s *edgeRequestSink) WriteMessage(msg proto.Message) error {
+	req, ok := msg.(*livekit.SignalRequest)
+	if !ok {
+		return fmt.Errorf("unexpected message type: %T", msg)
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.closed {
+		return routing.ErrChannelClosed
+	}
+	return s.stream.SendMsg(&rpc.RelaySignalRequest{Requests: []*livekit.SignalRequest{req}})
+}
+
+func (s *edgeRequestSink) IsClosed() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.closed
+}
+
+// Close tells the core node the client left, the core node ends the relay once the participant is closed
+func (s *edgeRequestSink) Close() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed = true
+	_ = s.stream.SendMsg(&rpc.RelaySignalRequest{Close: true})
+	_ = s.stream.CloseSend()
+}
+
+func (s *edgeRequestSink) ConnectionID() livekit.ConnectionID {
+	return s.connectionID
+}
+
+type edgeResponseSource struct {
+	*routing.MessageChannel
+
+	lock sync.Mutex
+	err  error
+}
+
+func (s *edgeResponseSource) setErr(err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.err = err
+}
+
+// Err returns the error the relay ended with, set before the source closes
+func (s *edgeResponseSource) Err() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.err
+}
+
+// edgeTURNPassword signs the TURN username an edge hands out, so that the edge TURN server can verify it without
+// the room state of core nodes
+func edgeTURNPassword(secret, username string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(username))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// newEdgeTurnAuthHandler accepts the unexpired credentials signed by edgeTURNPassword
+func newEdgeTurnAuthHandler(conf *config.EdgeConfig, allocations *TurnAllocations) turn.AuthHandler {
+	return func(username, realm string, srcAddr net.Addr) (key []byte, ok bool) {
+		expiry, _, found := strings.Cut(username, ":")
+		expiresAt, err := strconv.ParseInt(expiry, 10, 64)
+		if !found || err != nil || time.Now().Unix() > expiresAt {
+			prometheus.RecordTurnAllocationFailure(turnFailureAuth)
+			return nil, false
+		}
+
+		if !allocations.authorize(username, srcAddr) {
+			logger.Infow("TURN allocation quota reached", "username", username, "clientAddr", srcAddr)
+			prometheus.RecordTurnAllocationFailure(turnFailureQuota)
+			return nil, false
+		}
+
+		return turn.GenerateAuthKey(username, LivekitRealm, edgeTURNPassword(conf.Secret, username)), true
+	}
+}
+
+// EdgeRelayServer accepts the signal connections edge nodes relay to this core node
+type EdgeRelayServer struct {
+	conf       *config.Config
+	rtcService *RTCService
+	server     *grpc.Server
+}
+
+// NewEdgeRelayServer returns nil when no relay port is configured
+func NewEdgeRelayServer(conf *config.Config, rtcService *RTCService) (*EdgeRelayServer, error) {
+	if conf.Edge.RelayPort == 0 {
+		return nil, nil
+	}
+
+	opts := []grpc.ServerOption{
+		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{MinTime: edgeRelayKeepalive / 2, PermitWithoutStream: true}),
+	}
+	if conf.Edge.CertFile != "" {
+		creds, err := credentials.NewServerTLSFromFile(conf.Edge.CertFile, conf.Edge.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, grpc.Creds(creds))
+	}
+
+	s := &EdgeRelayServer{
+		conf:       conf,
+		rtcService: rtcService,
+		server:     grpc.NewServer(opts...),
+	}
+	s.server.RegisterService(&edgeRelayService, s)
+	return s, nil
+}
+
+func (s *EdgeRelayServer) Start() error {
+	if s == nil {
+		return nil
+	}
+
+	ln, err := net.Listen("tcp", fmt.Sprintf(":%d", s.conf.Edge.RelayPort))
+	if err != nil {
+		return err
+	}
+	go func() {
+		if err := s.server.Serve(ln); err != nil {
+			logger.Errorw("edge relay server stopped", err)
+		}
+	}()
+	logger.Infow("accepting signal relays from edge nodes", "port", s.conf.Edge.RelayPort, "tls", s.conf.Edge.CertFile != "")
+	return nil
+}
+
+// Stop ends the relays, clients of edges reconnect like on any signal disconnection
+func (s *EdgeRelayServer) Stop() {
+	if s == nil {
+		return
+	}
+	s.server.Stop()
+}
+
+func relaySignalHandler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(*EdgeRelayServer).relaySignal(stream)
+}
+
+func (s *EdgeRelayServer) relaySignal(stream grpc.ServerStream) error {
+	ctx := stream.Context()
+	md, _ := metadata.FromIncomingContext(ctx)
+	secrets := md.Get(edgeSecretMetadata)
+	if len(secrets) != 1 || subtle.ConstantTimeCompare([]byte(secrets[0]), []byte(s.conf.Edge.Secret)) != 1 {
+		prometheus.RecordAuthFailure("invalid_edge_secret", "")
+		return status.Error(codes.Unauthenticated, "invalid edge secret")
+	}
+	var apiKey string
+	if keys := md.Get(edgeAPIKeyMetadata); len(keys) == 1 {
+		apiKey = keys[0]
+	}
+
+	req := &rpc.RelaySignalRequest{}
+	if err := stream.RecvMsg(req); err != nil {
+		return err
+	}
+	if req.StartSession == nil {
+		return status.Error(codes.InvalidArgument, "relay must start with a session")
+	}
+	pi, err := routing.ParticipantInitFromStartSession(req.StartSession, s.conf.Region)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	roomName := livekit.RoomName(req.StartSession.RoomName)
+	l := logger.GetLogger().WithValues("room", roomName, "participant", pi.Identity, "edge", true)
+
+	cr, initialResponse, lease, err := s.rtcService.startRelayedConnection(ctx, roomName, *pi, apiKey)
+	if err != nil {
+		l.Infow("refused relayed join", "error", err)
+		if code := joinErrorCode(err); code != "" {
+			stream.SetTrailer(metadata.Pairs(edgeErrorCodeMetadata, code))
+		}
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+	defer lease.Release()
+	defer func() {
+		cr.ResponseSource.Close()
+		cr.RequestSink.Close()
+	}()
+	l.Infow("relaying signal connection of edge", "connID", cr.ConnectionID, "reconnect", pi.Reconnect)
+
+	if err = stream.SendMsg(&rpc.RelaySignalResponse{Responses: []*livekit.SignalResponse{initialResponse}}); err != nil {
+		return err
+	}
+
+	// requests of the client, the participant is closed with the sink once the edge is gone
+	go func() {
+		defer cr.RequestSink.Close()
+		for {
+			req := &rpc.RelaySignalRequest{}
+			if err := stream.RecvMsg(req); err != nil {
+				return
+			}
+			for _, r := range req.Requests {
+				if err := cr.RequestSink.WriteMessage(r); err != nil {
+					l.Warnw("could not forward relayed signal request", err, "connID", cr.ConnectionID)
+					return
+				}
+			}
+			if req.Close {
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg := <-cr.ResponseSource.ReadChan():
+			if msg == nil {
+				// the participant was closed on the RTC node
+				return stream.SendMsg(&rpc.RelaySignalResponse{Close: true})
+			}
+			res, ok := msg.(*livekit.SignalResponse)
+			if !ok {
+				l.Errorw("unexpected message type", nil, "type", fmt.Sprintf("%T", msg), "connID", cr.ConnectionID)
+				continue
+			}
+			if err = stream.SendMsg(&rpc.RelaySignalResponse{Responses: []*livekit.SignalResponse{res}}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// startRelayedConnection checks and starts the join an edge relayed, as ServeHTTP does for its own clients
+func (s *RTCService) startRelayedConnection(ctx context.Context, roomName livekit.RoomName, pi routing.ParticipantInit, apiKey string) (connectionResult, *livekit.SignalResponse, *sessionLease, error) {
+	var cr connectionResult
+	// without redis, only the rooms hosted on this node can be joined and limits are not enforced
+	dr := degradedRouter(s.router)
+	if dr != nil && !dr.IsLocalRoom(roomName) {
+		return cr, nil, nil, ErrPlacementUnavailable
+	}
+	if dr == nil {
+		if err := s.roomAllocator.ValidateCreateRoom(ctx, roomName); err != nil {
+			return cr, nil, nil, err
+		}
+	}
+	if err := checkRoomSeal(ctx, s.store, roomName, &pi); err != nil {
+		return cr, nil, nil, err
+	}
+
+	var lease *sessionLease
+	if dr == nil {
+		var err error
+		if lease, err = acquireSessionLimits(ctx, s.store, &s.config.SessionLimits, &s.config.Quotas, apiKey, roomName, pi.Identity); err != nil {
+			return cr, nil, nil, err
+		}
+		if err = checkParticipantMinutesQuota(ctx, s.store, &s.config.Quotas, apiKey); err != nil {
+			lease.Release()
+			return cr, nil, nil, err
+		}
+	}
+
+	cr, initialResponse, err := s.startConnection(ctx, roomName, pi, edgeRelayJoinTimeout)
+	if err != nil {
+		lease.Release()
+		return cr, nil, nil, err
+	}
+	return cr, initialResponse, lease, nil
+}
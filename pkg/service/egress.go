@@ -44,12 +44,14 @@ type egressLauncher struct {
 	client    rpc.EgressClient
 	es        EgressStore
 	telemetry telemetry.TelemetryService
+	workers   *IOWorkerRegistry
 }
 
 func NewEgressLauncher(
 	client rpc.EgressClient,
 	es EgressStore,
-	ts telemetry.TelemetryService) rtc.EgressLauncher {
+	ts telemetry.TelemetryService,
+	workers *IOWorkerRegistry) rtc.EgressLauncher {
 	if client == nil {
 		return nil
 	}
@@ -58,6 +60,7 @@ func NewEgressLauncher(
 		client:    client,
 		es:        es,
 		telemetry: ts,
+		workers:   workers,
 	}
 }
 
@@ -192,6 +195,13 @@ func (s *egressLauncher) StartEgressWithClusterId(ctx context.Context, clusterId
 		req.EgressId = utils.NewGuid(utils.EgressPrefix)
 	}
 
+	if clusterId == "" {
+		var err error
+		if clusterId, err = s.workers.SelectEgressCluster(ctx); err != nil {
+			return nil, err
+		}
+	}
+
 	info, err := s.client.StartEgress(ctx, clusterId, req)
 	if err != nil {
 		return nil, err
@@ -230,9 +240,11 @@ func (s *EgressService) UpdateLayout(ctx context.Context, req *livekit.UpdateLay
 		return nil, err
 	}
 
-	grants := GetGrants(ctx)
-	grants.Video.Room = info.RoomName
-	grants.Video.RoomAdmin = true
+	// requests on the local control socket carry no grants
+	if grants := GetGrants(ctx); grants != nil {
+		grants.Video.Room = info.RoomName
+		grants.Video.RoomAdmin = true
+	}
 
 	_, err = s.roomService.UpdateParticipant(ctx, &livekit.UpdateParticipantRequest{
 		Room:     info.RoomName,
@@ -19,18 +19,65 @@ import (
 )
 
 var (
+	ErrAllocationNotFound    = psrpc.NewErrorf(psrpc.NotFound, "TURN allocation does not exist")
+	ErrBridgeDisabled        = psrpc.NewErrorf(psrpc.Unavailable, "bridging is not enabled")
+	ErrBulkIdentities        = psrpc.NewErrorf(psrpc.InvalidArgument, "between 1 and 1000 identities are required")
+	ErrE2EEDisabled          = psrpc.NewErrorf(psrpc.Unavailable, "server side e2ee key management is not enabled")
 	ErrEgressNotFound        = psrpc.NewErrorf(psrpc.NotFound, "egress does not exist")
 	ErrEgressNotConnected    = psrpc.NewErrorf(psrpc.Internal, "egress not connected (redis required)")
+	ErrHLSDisabled           = psrpc.NewErrorf(psrpc.Unavailable, "HLS is not enabled")
 	ErrIdentityEmpty         = psrpc.NewErrorf(psrpc.InvalidArgument, "identity cannot be empty")
+	ErrImpairmentDisabled    = psrpc.NewErrorf(psrpc.Unavailable, "impairment is only available in development mode")
+	ErrInvalidBulkAction     = psrpc.NewErrorf(psrpc.InvalidArgument, "action must be one of remove, mute, update_permission or send_data")
+	ErrInvalidChaosAction    = psrpc.NewErrorf(psrpc.InvalidArgument, "action must be one of drop_redis, stall_stats or kill_room")
 	ErrIngressNotConnected   = psrpc.NewErrorf(psrpc.Internal, "ingress not connected (redis required)")
 	ErrIngressNotFound       = psrpc.NewErrorf(psrpc.NotFound, "ingress does not exist")
+	ErrInvalidSealGrant      = psrpc.NewErrorf(psrpc.InvalidArgument, "grants bypassing a seal must be room_admin, room_record, hidden or recorder")
+	ErrInvalidTrackSource    = psrpc.NewErrorf(psrpc.InvalidArgument, "track sources must be camera, microphone, screen_share or screen_share_audio")
+	ErrInvalidIOWorker       = psrpc.NewErrorf(psrpc.InvalidArgument, "worker id and a kind of egress or ingress are required")
+	ErrLatencyUnavailable    = psrpc.NewErrorf(psrpc.Unavailable, "node latencies are only measured with redis")
+	ErrInvalidRoomRegions    = psrpc.NewErrorf(psrpc.InvalidArgument, "at least one region is required to pin a room")
+	ErrInvalidDeleteDelay    = psrpc.NewErrorf(psrpc.InvalidArgument, "delete delay must be a number of seconds, up to 24 hours")
+	ErrInvalidUsageWindow    = psrpc.NewErrorf(psrpc.InvalidArgument, "window must be a duration of up to 1h")
 	ErrIngressNonReusable    = psrpc.NewErrorf(psrpc.InvalidArgument, "ingress is not reusable and cannot be modified")
 	ErrMetadataExceedsLimits = psrpc.NewErrorf(psrpc.InvalidArgument, "metadata size exceeds limits")
+	ErrNoIOWorkersAvailable  = psrpc.NewErrorf(psrpc.Unavailable, "no live workers available")
+	ErrNoNodesInRegions      = psrpc.NewErrorf(psrpc.Unavailable, "no available nodes in the regions the room is pinned to")
+	ErrNodeDraining          = psrpc.NewErrorf(psrpc.Unavailable, "node is draining and does not accept new rooms")
 	ErrOperationFailed       = psrpc.NewErrorf(psrpc.Internal, "operation cannot be completed")
+	ErrPlacementUnavailable  = psrpc.NewErrorf(psrpc.Unavailable, "redis is unreachable, only rooms already hosted on this node can be joined")
+	ErrPostRoomDisabled      = psrpc.NewErrorf(psrpc.Unavailable, "no post room pipeline is configured")
+	ErrQuotaExceeded         = psrpc.NewErrorf(psrpc.ResourceExhausted, "API key quota exceeded")
+	ErrQuotasUnsupported     = psrpc.NewErrorf(psrpc.Unavailable, "room store does not support API key quotas")
+	ErrPublishHookNoAPIKey   = psrpc.NewErrorf(psrpc.InvalidArgument, "api_key is required to use the publish hook")
 	ErrParticipantNotFound   = psrpc.NewErrorf(psrpc.NotFound, "participant does not exist")
 	ErrRoomNotFound          = psrpc.NewErrorf(psrpc.NotFound, "requested room does not exist")
+	ErrRoomNotCreated        = psrpc.NewErrorf(psrpc.NotFound, "room does not exist, it needs to be created before joining")
+	ErrRecordingDisabled     = psrpc.NewErrorf(psrpc.Unavailable, "recording is not enabled")
 	ErrRoomLockFailed        = psrpc.NewErrorf(psrpc.Internal, "could not lock room")
+	ErrRestreamURLRequired   = psrpc.NewErrorf(psrpc.InvalidArgument, "rtmp:// or rtmps:// stream urls are required")
+	ErrResourceBudget        = psrpc.NewErrorf(psrpc.Unavailable, "node is over its resource budget and does not accept new participants")
+	ErrRoomTemplateNotFound  = psrpc.NewErrorf(psrpc.NotFound, "room template does not exist")
+	ErrRoomPinnedToRegion    = psrpc.NewErrorf(psrpc.FailedPrecondition, "room is pinned to other regions")
+	ErrRoomSealed            = psrpc.NewErrorf(psrpc.PermissionDenied, "room is sealed, no new participants can join")
+	ErrRoomSealUnsupported   = psrpc.NewErrorf(psrpc.Unavailable, "room store does not support sealing rooms")
+	ErrClosureUnsupported    = psrpc.NewErrorf(psrpc.Unavailable, "room store does not support delayed room closures")
+	ErrRoomNameRequired      = psrpc.NewErrorf(psrpc.InvalidArgument, "room name is required")
 	ErrRoomUnlockFailed      = psrpc.NewErrorf(psrpc.Internal, "could not unlock room, lock token does not match")
+	ErrScheduleInvalid       = psrpc.NewErrorf(psrpc.InvalidArgument, "start_at must be in the future")
+	ErrScheduleStarted       = psrpc.NewErrorf(psrpc.FailedPrecondition, "scheduled room has already started")
+	ErrScheduleNotFound      = psrpc.NewErrorf(psrpc.NotFound, "room is not scheduled")
+	ErrScheduleUnsupported   = psrpc.NewErrorf(psrpc.Unavailable, "room store does not support scheduling rooms")
+	ErrSessionLimitExceeded  = psrpc.NewErrorf(psrpc.ResourceExhausted, "too many concurrent sessions for this identity or API key")
+	ErrSignalRateLimited     = psrpc.NewErrorf(psrpc.ResourceExhausted, "too many connection attempts, retry later")
 	ErrTrackNotFound         = psrpc.NewErrorf(psrpc.NotFound, "track is not found")
+	ErrTrackMuteRequired     = psrpc.NewErrorf(psrpc.InvalidArgument, "track_sid and muted are required to set the mute state")
+	ErrTranscriptionDisabled = psrpc.NewErrorf(psrpc.Unavailable, "transcription is not enabled")
+	ErrWHEPClientOffer       = psrpc.NewErrorf(psrpc.InvalidArgument, "WHEP sessions are offered by the server, the request must not have an offer")
+	ErrWHEPNoTracks          = psrpc.NewErrorf(psrpc.NotFound, "no published tracks to play")
+	ErrWHIPNoMedia           = psrpc.NewErrorf(psrpc.InvalidArgument, "offer has no audio or video to publish")
 	ErrWebHookMissingAPIKey  = psrpc.NewErrorf(psrpc.InvalidArgument, "api_key is required to use webhooks")
+	ErrDeadLettersDisabled   = psrpc.NewErrorf(psrpc.Unavailable, "webhook dead letters are not enabled")
+	ErrArchiveDisabled       = psrpc.NewErrorf(psrpc.Unavailable, "webhook archiving is not enabled")
+	ErrInvalidArchiveDay     = psrpc.NewErrorf(psrpc.InvalidArgument, "date must be formatted as YYYY-MM-DD")
 )
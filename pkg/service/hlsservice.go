@@ -0,0 +1,239 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/hls"
+	"github.com/livekit/protocol/livekit"
+)
+
+const hlsMimeType = "application/vnd.apple.mpegurl"
+
+type hlsRequest struct {
+	Room                string `json:"room"`
+	ParticipantIdentity string `json:"participant_identity,omitempty"`
+}
+
+type listHLSResponse struct {
+	Streams []*hls.Info `json:"streams"`
+}
+
+// HLSService starts and stops HLS packaging of rooms hosted on this node and serves their
+// playlists and segments to viewers
+type HLSService struct {
+	conf        config.HLSConfig
+	manager     *hls.Manager
+	roomManager *RoomManager
+}
+
+func NewHLSService(conf *config.Config, manager *hls.Manager, roomManager *RoomManager) *HLSService {
+	return &HLSService{
+		conf:        conf.HLS,
+		manager:     manager,
+		roomManager: roomManager,
+	}
+}
+
+func (s *HLSService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.manager == nil {
+		handleError(w, http.StatusNotFound, ErrHLSDisabled)
+		return
+	}
+
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, hls.PathPrefix), "/")
+	switch {
+	case r.Method == http.MethodGet && path == "":
+		s.list(w, r)
+	case r.Method == http.MethodPost && (path == "start" || path == "stop"):
+		s.control(w, r, path)
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		s.serve(w, r, strings.Split(path, "/"))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (s *HLSService) list(w http.ResponseWriter, r *http.Request) {
+	if err := EnsureRecordPermission(r.Context()); err != nil {
+		handleError(w, http.StatusUnauthorized, err)
+		return
+	}
+	s.writeJSON(w, &listHLSResponse{Streams: s.manager.List()})
+}
+
+func (s *HLSService) control(w http.ResponseWriter, r *http.Request, action string) {
+	if err := EnsureRecordPermission(r.Context()); err != nil {
+		handleError(w, http.StatusUnauthorized, err)
+		return
+	}
+	var req hlsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		handleError(w, http.StatusBadRequest, err)
+		return
+	}
+	if req.Room == "" {
+		handleError(w, http.StatusBadRequest, ErrRoomNameRequired)
+		return
+	}
+
+	var info *hls.Info
+	var err error
+	if action == "start" {
+		room := s.roomManager.GetRoom(r.Context(), livekit.RoomName(req.Room))
+		if room == nil {
+			handleError(w, http.StatusNotFound, ErrRoomNotFound, "room", req.Room)
+			return
+		}
+		info, err = s.manager.Start(room, livekit.ParticipantIdentity(req.ParticipantIdentity))
+	} else {
+		info, err = s.manager.Stop(livekit.RoomName(req.Room))
+	}
+
+	switch {
+	case errors.Is(err, hls.ErrNotPackaging):
+		handleError(w, http.StatusNotFound, err, "room", req.Room)
+	case errors.Is(err, hls.ErrAlreadyPackaging):
+		handleError(w, http.StatusConflict, err, "room", req.Room)
+	case err != nil:
+		handleError(w, http.StatusInternalServerError, err, "room", req.Room)
+	default:
+		s.writeJSON(w, info)
+	}
+}
+
+// serve handles /hls/<room>/master.m3u8 and /hls/<room>/<rendition>/<file>
+func (s *HLSService) serve(w http.ResponseWriter, r *http.Request, parts []string) {
+	if len(parts) < 2 || len(parts) > 3 {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	roomName := livekit.RoomName(parts[0])
+	if !s.conf.PublicPlayback {
+		if err := ensurePlaybackPermission(r.Context(), roomName); err != nil {
+			handleError(w, http.StatusUnauthorized, err)
+			return
+		}
+	}
+
+	// players do not carry the token of the playlist over to the URIs it lists
+	var query string
+	if token := r.URL.Query().Get(accessTokenParam); token != "" {
+		query = accessTokenParam + "=" + url.QueryEscape(token)
+	}
+
+	if len(parts) == 2 {
+		if parts[1] != hls.MasterFilename {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		playlist, err := s.manager.MasterPlaylist(roomName, query)
+		if err != nil {
+			handleError(w, http.StatusNotFound, err, "room", roomName)
+			return
+		}
+		s.writePlaylist(w, playlist)
+		return
+	}
+
+	if parts[2] == hls.PlaylistFilename {
+		s.serveMediaPlaylist(w, r, roomName, parts[1], query)
+		return
+	}
+	path, err := s.manager.FilePath(roomName, parts[1], parts[2])
+	if err != nil {
+		handleError(w, http.StatusNotFound, err, "room", roomName)
+		return
+	}
+	// segments and parts never change
+	w.Header().Set("Cache-Control", "max-age=3600")
+	http.ServeFile(w, r, path)
+}
+
+func (s *HLSService) serveMediaPlaylist(w http.ResponseWriter, r *http.Request, roomName livekit.RoomName, rendition string, query string) {
+	msn, part := int64(-1), int64(-1)
+	if v := r.URL.Query().Get("_HLS_msn"); v != "" {
+		n, err := strconv.ParseUint(v, 10, 63)
+		if err != nil {
+			handleError(w, http.StatusBadRequest, err)
+			return
+		}
+		msn = int64(n)
+	}
+	if v := r.URL.Query().Get("_HLS_part"); v != "" {
+		n, err := strconv.ParseUint(v, 10, 31)
+		if err != nil || msn < 0 {
+			handleError(w, http.StatusBadRequest, errors.New("_HLS_part requires _HLS_msn"))
+			return
+		}
+		part = int64(n)
+	}
+
+	// blocking requests give up after three target durations
+	ctx, cancel := context.WithTimeout(r.Context(), 3*s.conf.SegmentDuration)
+	defer cancel()
+	playlist, err := s.manager.MediaPlaylist(ctx, roomName, rendition, msn, part, true, query)
+	switch {
+	case errors.Is(err, hls.ErrPlaylistTooFarAhead):
+		handleError(w, http.StatusBadRequest, err, "room", roomName)
+	case errors.Is(err, context.DeadlineExceeded):
+		handleError(w, http.StatusServiceUnavailable, err, "room", roomName)
+	case err != nil:
+		handleError(w, http.StatusNotFound, err, "room", roomName)
+	default:
+		s.writePlaylist(w, playlist)
+	}
+}
+
+func (s *HLSService) writePlaylist(w http.ResponseWriter, playlist string) {
+	w.Header().Set("Content-Type", hlsMimeType)
+	w.Header().Set("Cache-Control", "no-cache")
+	_, _ = w.Write([]byte(playlist))
+}
+
+func (s *HLSService) writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// ensurePlaybackPermission allows tokens that may subscribe in the room, administer it, or record
+func ensurePlaybackPermission(ctx context.Context, room livekit.RoomName) error {
+	if isLocalControl(ctx) {
+		return nil
+	}
+	claims := GetGrants(ctx)
+	if claims == nil || claims.Video == nil {
+		return ErrPermissionDenied
+	}
+	v := claims.Video
+	if v.RoomRecord {
+		return nil
+	}
+	if livekit.RoomName(v.Room) != room {
+		return ErrPermissionDenied
+	}
+	if v.RoomAdmin || (v.RoomJoin && v.GetCanSubscribe()) {
+		return nil
+	}
+	return ErrPermissionDenied
+}
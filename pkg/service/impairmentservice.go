@@ -0,0 +1,140 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/livekit/livekit-server/pkg/sfu/impairment"
+	"github.com/livekit/protocol/livekit"
+)
+
+const impairmentPath = "/debug/impairment"
+
+type impairmentParams struct {
+	LossPercent float64 `json:"loss_percent"`
+	LatencyMs   int64   `json:"latency_ms"`
+	JitterMs    int64   `json:"jitter_ms"`
+}
+
+func (p *impairmentParams) toParams() impairment.Params {
+	if p == nil {
+		return impairment.Params{}
+	}
+	return impairment.Params{
+		LossPercent: p.LossPercent,
+		Latency:     time.Duration(p.LatencyMs) * time.Millisecond,
+		Jitter:      time.Duration(p.JitterMs) * time.Millisecond,
+	}
+}
+
+func impairmentParamsFrom(params impairment.Params) *impairmentParams {
+	return &impairmentParams{
+		LossPercent: params.LossPercent,
+		LatencyMs:   params.Latency.Milliseconds(),
+		JitterMs:    params.Jitter.Milliseconds(),
+	}
+}
+
+// uplink and downlink are applied as given, an omitted direction is cleared
+type impairmentRequest struct {
+	Room     string            `json:"room"`
+	Identity string            `json:"identity"`
+	Uplink   *impairmentParams `json:"uplink,omitempty"`
+	Downlink *impairmentParams `json:"downlink,omitempty"`
+}
+
+type impairmentResponse struct {
+	Room     string            `json:"room"`
+	Identity string            `json:"identity"`
+	Uplink   *impairmentParams `json:"uplink"`
+	Downlink *impairmentParams `json:"downlink"`
+}
+
+// ImpairmentService injects artificial loss, latency and jitter on the media paths of a participant,
+// letting clients be tested against controlled network conditions. It is only served in development mode,
+// and only for rooms hosted on the node handling the request.
+type ImpairmentService struct {
+	roomManager *RoomManager
+}
+
+func NewImpairmentService(roomManager *RoomManager) *ImpairmentService {
+	return &ImpairmentService{
+		roomManager: roomManager,
+	}
+}
+
+func (s *ImpairmentService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req impairmentRequest
+	switch r.Method {
+	case http.MethodGet:
+		req.Room = r.URL.Query().Get("room")
+		req.Identity = r.URL.Query().Get("identity")
+	case http.MethodPost:
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			handleError(w, http.StatusBadRequest, err)
+			return
+		}
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := EnsureAdminPermission(r.Context(), livekit.RoomName(req.Room)); err != nil {
+		handleError(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	room := s.roomManager.GetRoom(r.Context(), livekit.RoomName(req.Room))
+	if room == nil {
+		handleError(w, http.StatusNotFound, ErrRoomNotFound, "room", req.Room)
+		return
+	}
+	participant := room.GetParticipant(livekit.ParticipantIdentity(req.Identity))
+	if participant == nil {
+		handleError(w, http.StatusNotFound, ErrParticipantNotFound, "room", req.Room, "participant", req.Identity)
+		return
+	}
+	uplink, downlink := participant.GetImpairments()
+	if uplink == nil || downlink == nil {
+		handleError(w, http.StatusServiceUnavailable, ErrImpairmentDisabled, "room", req.Room, "participant", req.Identity)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		if err := uplink.SetParams(req.Uplink.toParams()); err != nil {
+			handleError(w, http.StatusBadRequest, err)
+			return
+		}
+		if err := downlink.SetParams(req.Downlink.toParams()); err != nil {
+			handleError(w, http.StatusBadRequest, err)
+			return
+		}
+		participant.GetLogger().Infow("updated media impairment",
+			"uplink", uplink.Params(),
+			"downlink", downlink.Params(),
+		)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(&impairmentResponse{
+		Room:     req.Room,
+		Identity: req.Identity,
+		Uplink:   impairmentParamsFrom(uplink.Params()),
+		Downlink: impairmentParamsFrom(downlink.Params()),
+	})
+}
@@ -42,6 +42,7 @@ type IngressService struct {
 	roomService livekit.RoomService
 	telemetry   telemetry.TelemetryService
 	launcher    IngressLauncher
+	workers     *IOWorkerRegistry
 }
 
 func NewIngressServiceWithIngressLauncher(
@@ -75,10 +76,12 @@ func NewIngressService(
 	store IngressStore,
 	rs livekit.RoomService,
 	ts telemetry.TelemetryService,
+	workers *IOWorkerRegistry,
 ) *IngressService {
 	s := NewIngressServiceWithIngressLauncher(conf, nodeID, bus, psrpcClient, store, rs, ts, nil)
 
 	s.launcher = s
+	s.workers = workers
 
 	return s
 }
@@ -123,6 +126,9 @@ func (s *IngressService) CreateIngressWithUrl(ctx context.Context, urlStr string
 	if s.store == nil {
 		return nil, ErrIngressNotConnected
 	}
+	if err = s.workers.EnsureIngressAvailable(ctx); err != nil {
+		return nil, err
+	}
 
 	if req.InputType == livekit.IngressInput_URL_INPUT {
 		if req.Url == "" {
@@ -71,6 +71,90 @@ type IngressStore interface {
 	DeleteIngress(ctx context.Context, info *livekit.IngressInfo) error
 }
 
+// remembers the regions rooms are pinned to
+type RoomRegionStore interface {
+	StoreRoomRegions(ctx context.Context, roomName livekit.RoomName, regions []string) error
+	LoadRoomRegions(ctx context.Context, roomName livekit.RoomName) ([]string, error)
+}
+
+// remembers the template rooms were created with
+type RoomTemplateStore interface {
+	StoreRoomTemplate(ctx context.Context, roomName livekit.RoomName, template string) error
+	LoadRoomTemplate(ctx context.Context, roomName livekit.RoomName) (string, error)
+}
+
+// resume tokens of joined participants, expiring after ttl unless renewed
+type ResumeTokenStore interface {
+	StoreResumeToken(ctx context.Context, token *ResumeToken, ttl time.Duration) error
+	// RenewResumeToken extends the token of the participant, if any, to expire after ttl
+	RenewResumeToken(ctx context.Context, participantID livekit.ParticipantID, ttl time.Duration) error
+	LoadResumeToken(ctx context.Context, participantID livekit.ParticipantID) (*ResumeToken, error)
+	DeleteResumeToken(ctx context.Context, participantID livekit.ParticipantID) error
+}
+
+// seals keeping new participants out of rooms that already started
+type RoomSealStore interface {
+	StoreRoomSeal(ctx context.Context, seal *RoomSeal) error
+	LoadRoomSeal(ctx context.Context, roomName livekit.RoomName) (*RoomSeal, error)
+	DeleteRoomSeal(ctx context.Context, roomName livekit.RoomName) error
+}
+
+// delayed room closures, counted down by the node hosting the room
+type RoomClosureStore interface {
+	StoreRoomClosure(ctx context.Context, closure *RoomClosure) error
+	// LoadRoomClosure returns nil if no closure is scheduled
+	LoadRoomClosure(ctx context.Context, roomName livekit.RoomName) (*RoomClosure, error)
+	DeleteRoomClosure(ctx context.Context, roomName livekit.RoomName) error
+}
+
+// rooms scheduled to be created in advance, by room name
+type ScheduledRoomStore interface {
+	StoreScheduledRoom(ctx context.Context, sr *ScheduledRoom) error
+	// LoadScheduledRoom returns nil if the room is not scheduled
+	LoadScheduledRoom(ctx context.Context, roomName livekit.RoomName) (*ScheduledRoom, error)
+	ListScheduledRooms(ctx context.Context) ([]*ScheduledRoom, error)
+	DeleteScheduledRoom(ctx context.Context, roomName livekit.RoomName) error
+}
+
+// concurrent sessions counted against a limit, expiring after ttl unless refreshed. A key holds slots,
+// each owned by one session at a time
+type SessionLimitStore interface {
+	// AcquireSession makes sessionID the owner of slot, unless the slot is new and key already holds
+	// limit slots. Returns false when the limit is reached
+	AcquireSession(ctx context.Context, key string, slot string, sessionID string, limit int, ttl time.Duration) (bool, error)
+	RefreshSession(ctx context.Context, key string, slot string, sessionID string, ttl time.Duration) error
+	// ReleaseSession frees slot, unless another session took it over
+	ReleaseSession(ctx context.Context, key string, slot string, sessionID string) error
+	// CountSessions returns the number of unexpired slots of key
+	CountSessions(ctx context.Context, key string) (int, error)
+}
+
+// rooms and participant minutes attributed to API keys, for quotas. Months are formatted as 2006-01
+type APIKeyQuotaStore interface {
+	// StoreRoomAPIKey attributes a room to the key it was created with, until the room is deleted
+	StoreRoomAPIKey(ctx context.Context, roomName livekit.RoomName, apiKey string) error
+	LoadRoomAPIKey(ctx context.Context, roomName livekit.RoomName) (string, error)
+	// CountAPIKeyRooms returns the number of rooms attributed to each key
+	CountAPIKeyRooms(ctx context.Context) (map[string]int, error)
+	AddParticipantMinutes(ctx context.Context, month string, minutes map[string]float64) error
+	LoadParticipantMinutes(ctx context.Context, month string) (map[string]float64, error)
+}
+
+// rooms and their settings kept across restarts of the whole cluster
+type RoomPersistence interface {
+	// SaveRooms replaces the persisted rooms
+	SaveRooms(ctx context.Context, rooms []*PersistedRoom) error
+	LoadRooms(ctx context.Context) ([]*PersistedRoom, error)
+	Close() error
+}
+
+// liveness registry of external egress/ingress workers
+type IOWorkerStore interface {
+	StoreIOWorker(ctx context.Context, worker *IOWorker) error
+	ListIOWorkers(ctx context.Context, kind IOWorkerKind) ([]*IOWorker, error)
+	DeleteIOWorker(ctx context.Context, kind IOWorkerKind, workerID string) error
+}
+
 //counterfeiter:generate . RoomAllocator
 type RoomAllocator interface {
 	CreateRoom(ctx context.Context, req *livekit.CreateRoomRequest) (*livekit.Room, error)
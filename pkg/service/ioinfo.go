@@ -33,6 +33,8 @@ type IOInfoService struct {
 	is        IngressStore
 	telemetry telemetry.TelemetryService
 	shutdown  chan struct{}
+
+	onEgressUpdated func(ctx context.Context, info *livekit.EgressInfo)
 }
 
 func NewIOInfoService(
@@ -60,6 +62,11 @@ func NewIOInfoService(
 	return s, nil
 }
 
+// OnEgressUpdated registers a callback for updates reported by egress workers
+func (s *IOInfoService) OnEgressUpdated(f func(ctx context.Context, info *livekit.EgressInfo)) {
+	s.onEgressUpdated = f
+}
+
 func (s *IOInfoService) Start() error {
 	if s.es != nil {
 		rs := s.es.(*RedisStore)
@@ -98,6 +105,9 @@ func (s *IOInfoService) UpdateEgressInfo(ctx context.Context, info *livekit.Egre
 		logger.Errorw("could not update egress", err)
 		return nil, err
 	}
+	if s.onEgressUpdated != nil {
+		s.onEgressUpdated(ctx, info)
+	}
 
 	return &emptypb.Empty{}, nil
 }
@@ -0,0 +1,270 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/telemetry"
+	"github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/logger"
+)
+
+type IOWorkerKind string
+
+const (
+	IOWorkerEgress  IOWorkerKind = "egress"
+	IOWorkerIngress IOWorkerKind = "ingress"
+
+	// webhook events sent when no live worker of a kind is available
+	EventEgressWorkersUnavailable  = "egress_workers_unavailable"
+	EventIngressWorkersUnavailable = "ingress_workers_unavailable"
+)
+
+// IOWorker is the last reported state of an external egress or ingress worker
+type IOWorker struct {
+	ID        string       `json:"id"`
+	Kind      IOWorkerKind `json:"kind"`
+	ClusterID string       `json:"cluster_id,omitempty"`
+	// maximum number of concurrent requests, 0 when unknown
+	Capacity int   `json:"capacity,omitempty"`
+	Active   int   `json:"active"`
+	Draining bool  `json:"draining,omitempty"`
+	LastSeen int64 `json:"last_seen"`
+	Alive    bool  `json:"alive"`
+}
+
+func (w *IOWorker) hasCapacity() bool {
+	return !w.Draining && (w.Capacity == 0 || w.Active < w.Capacity)
+}
+
+// IOWorkerRegistry keeps track of egress/ingress worker liveness reported through heartbeats.
+// Deployments whose workers never report keep the previous behavior, placement is only
+// restricted once workers of a kind have registered.
+type IOWorkerRegistry struct {
+	conf  config.IOWorkersConfig
+	store IOWorkerStore
+	ts    telemetry.TelemetryService
+
+	lock      sync.Mutex
+	lastAlert map[IOWorkerKind]time.Time
+}
+
+// NewIOWorkerRegistry returns nil without a Redis store, a nil registry never restricts placement
+func NewIOWorkerRegistry(conf *config.Config, s ObjectStore, ts telemetry.TelemetryService) *IOWorkerRegistry {
+	store, ok := s.(IOWorkerStore)
+	if !ok {
+		return nil
+	}
+	return &IOWorkerRegistry{
+		conf:      conf.IOWorkers,
+		store:     store,
+		ts:        ts,
+		lastAlert: make(map[IOWorkerKind]time.Time),
+	}
+}
+
+func (r *IOWorkerRegistry) Heartbeat(ctx context.Context, w *IOWorker) error {
+	w.LastSeen = time.Now().Unix()
+	return r.store.StoreIOWorker(ctx, w)
+}
+
+// ListWorkers returns workers of kind with their liveness, workers unseen for long are purged
+func (r *IOWorkerRegistry) ListWorkers(ctx context.Context, kind IOWorkerKind) ([]*IOWorker, error) {
+	workers, err := r.store.ListIOWorkers(ctx, kind)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	live := workers[:0]
+	for _, w := range workers {
+		lastSeen := time.Unix(w.LastSeen, 0)
+		if now.Sub(lastSeen) > r.conf.PurgeAfter {
+			if err := r.store.DeleteIOWorker(ctx, kind, w.ID); err != nil {
+				logger.Warnw("could not purge io worker", err, "workerID", w.ID)
+			}
+			continue
+		}
+		w.Alive = now.Sub(lastSeen) <= r.conf.HeartbeatTimeout
+		live = append(live, w)
+	}
+	return live, nil
+}
+
+// SelectEgressCluster picks the cluster of the live egress worker with the most spare capacity.
+// It returns an empty cluster when no worker ever registered, leaving placement to the egress bus.
+func (r *IOWorkerRegistry) SelectEgressCluster(ctx context.Context) (string, error) {
+	if r == nil {
+		return "", nil
+	}
+
+	candidates, registered, err := r.available(ctx, IOWorkerEgress)
+	if err != nil || !registered {
+		// never block egress on the registry itself
+		return "", nil
+	}
+	if len(candidates) == 0 {
+		return "", ErrNoIOWorkersAvailable
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return spareCapacity(candidates[i]) > spareCapacity(candidates[j])
+	})
+	return candidates[0].ClusterID, nil
+}
+
+// EnsureIngressAvailable fails when ingress workers registered but none of them is alive
+func (r *IOWorkerRegistry) EnsureIngressAvailable(ctx context.Context) error {
+	if r == nil {
+		return nil
+	}
+
+	candidates, registered, err := r.available(ctx, IOWorkerIngress)
+	if err != nil || !registered {
+		return nil
+	}
+	if len(candidates) == 0 {
+		return ErrNoIOWorkersAvailable
+	}
+	return nil
+}
+
+func (r *IOWorkerRegistry) available(ctx context.Context, kind IOWorkerKind) ([]*IOWorker, bool, error) {
+	workers, err := r.ListWorkers(ctx, kind)
+	if err != nil {
+		logger.Warnw("could not list io workers", err, "kind", kind)
+		return nil, false, err
+	}
+	if len(workers) == 0 {
+		return nil, false, nil
+	}
+
+	var candidates []*IOWorker
+	for _, w := range workers {
+		if w.Alive && w.hasCapacity() {
+			candidates = append(candidates, w)
+		}
+	}
+	if len(candidates) == 0 {
+		r.alertUnavailable(ctx, kind, workers)
+	}
+	return candidates, true, nil
+}
+
+func (r *IOWorkerRegistry) alertUnavailable(ctx context.Context, kind IOWorkerKind, workers []*IOWorker) {
+	r.lock.Lock()
+	if time.Since(r.lastAlert[kind]) < r.conf.AlertInterval {
+		r.lock.Unlock()
+		return
+	}
+	r.lastAlert[kind] = time.Now()
+	r.lock.Unlock()
+
+	logger.Warnw("no io workers available", nil, "kind", kind, "registered", len(workers))
+	event := EventEgressWorkersUnavailable
+	if kind == IOWorkerIngress {
+		event = EventIngressWorkersUnavailable
+	}
+	r.ts.NotifyEvent(ctx, &livekit.WebhookEvent{Event: event})
+}
+
+func spareCapacity(w *IOWorker) int {
+	if w.Capacity == 0 {
+		// unknown capacity ranks after workers known to have room
+		return 0
+	}
+	return w.Capacity - w.Active
+}
+
+// ------------------------------------------------
+
+type ioWorkersResponse struct {
+	Workers []*IOWorker `json:"workers"`
+}
+
+// ServeHTTP accepts worker heartbeats on POST and lists workers on GET
+func (r *IOWorkerRegistry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if r == nil {
+		handleError(w, http.StatusNotFound, ErrEgressNotConnected)
+		return
+	}
+
+	switch req.Method {
+	case http.MethodPost:
+		var worker IOWorker
+		if err := json.NewDecoder(req.Body).Decode(&worker); err != nil || worker.ID == "" ||
+			(worker.Kind != IOWorkerEgress && worker.Kind != IOWorkerIngress) {
+			handleError(w, http.StatusBadRequest, ErrInvalidIOWorker)
+			return
+		}
+		if err := ensureIOWorkerPermission(req.Context(), worker.Kind); err != nil {
+			handleError(w, http.StatusUnauthorized, err)
+			return
+		}
+		if err := r.Heartbeat(req.Context(), &worker); err != nil {
+			handleError(w, http.StatusInternalServerError, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	case http.MethodGet:
+		kind := IOWorkerKind(req.URL.Query().Get("kind"))
+		if err := ensureIOWorkerPermission(req.Context(), kind); err != nil {
+			handleError(w, http.StatusUnauthorized, err)
+			return
+		}
+		res := &ioWorkersResponse{Workers: []*IOWorker{}}
+		for _, k := range []IOWorkerKind{IOWorkerEgress, IOWorkerIngress} {
+			if kind != "" && kind != k {
+				continue
+			}
+			workers, err := r.ListWorkers(req.Context(), k)
+			if err != nil {
+				handleError(w, http.StatusInternalServerError, err)
+				return
+			}
+			res.Workers = append(res.Workers, workers...)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(res)
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func ensureIOWorkerPermission(ctx context.Context, kind IOWorkerKind) error {
+	switch kind {
+	case IOWorkerEgress:
+		return EnsureRecordPermission(ctx)
+	case IOWorkerIngress:
+		return EnsureIngressAdminPermission(ctx)
+	case "":
+		// listing every kind
+		if err := EnsureRecordPermission(ctx); err != nil {
+			return err
+		}
+		return EnsureIngressAdminPermission(ctx)
+	default:
+		return ErrInvalidIOWorker
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/service"
+	"github.com/livekit/livekit-server/pkg/telemetry/telemetryfakes"
+)
+
+func TestIOWorkerRegistry(t *testing.T) {
+	ctx := context.Background()
+	rs := service.NewRedisStore(redisClient())
+	ts := &telemetryfakes.FakeTelemetryService{}
+	conf, err := config.NewConfig("", true, nil, nil)
+	require.NoError(t, err)
+	registry := service.NewIOWorkerRegistry(conf, rs, ts)
+	require.NotNil(t, registry)
+
+	t.Cleanup(func() {
+		for _, id := range []string{"w1", "w2"} {
+			_ = rs.DeleteIOWorker(ctx, service.IOWorkerEgress, id)
+		}
+	})
+
+	// no registered workers, placement is left to the egress bus
+	clusterID, err := registry.SelectEgressCluster(ctx)
+	require.NoError(t, err)
+	require.Empty(t, clusterID)
+
+	require.NoError(t, registry.Heartbeat(ctx, &service.IOWorker{
+		ID: "w1", Kind: service.IOWorkerEgress, ClusterID: "full", Capacity: 2, Active: 2,
+	}))
+	require.NoError(t, registry.Heartbeat(ctx, &service.IOWorker{
+		ID: "w2", Kind: service.IOWorkerEgress, ClusterID: "spare", Capacity: 2, Active: 1,
+	}))
+	clusterID, err = registry.SelectEgressCluster(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "spare", clusterID)
+
+	// stale heartbeat, worker is dead
+	require.NoError(t, rs.StoreIOWorker(ctx, &service.IOWorker{
+		ID: "w2", Kind: service.IOWorkerEgress, ClusterID: "spare", Capacity: 2,
+		LastSeen: time.Now().Add(-time.Minute).Unix(),
+	}))
+	_, err = registry.SelectEgressCluster(ctx)
+	require.ErrorIs(t, err, service.ErrNoIOWorkersAvailable)
+	require.Equal(t, 1, ts.NotifyEventCallCount())
+
+	// alerts are rate limited
+	_, err = registry.SelectEgressCluster(ctx)
+	require.ErrorIs(t, err, service.ErrNoIOWorkersAvailable)
+	require.Equal(t, 1, ts.NotifyEventCallCount())
+
+	// ingress is unaffected by egress workers
+	require.NoError(t, registry.EnsureIngressAvailable(ctx))
+}
@@ -0,0 +1,81 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/livekit/livekit-server/pkg/telemetry/prometheus"
+)
+
+const keyUsagePath = "/key_usage"
+
+type keyUsageResponse struct {
+	NodeID string                 `json:"node_id"`
+	Window string                 `json:"window,omitempty"`
+	Keys   []*prometheus.KeyUsage `json:"keys"`
+}
+
+// KeyUsageService reports tokens validated, rooms created and participant minutes per API key on this node, over
+// the last ?window=<duration> up to an hour, or since the node started. ?api_key=<key> narrows it to one key.
+// Usage across nodes is available from the livekit_api_key_* metrics.
+type KeyUsageService struct {
+	nodeID string
+}
+
+func NewKeyUsageService(nodeID string) *KeyUsageService {
+	return &KeyUsageService{
+		nodeID: nodeID,
+	}
+}
+
+func (s *KeyUsageService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := EnsureListPermission(r.Context()); err != nil {
+		handleError(w, http.StatusUnauthorized, err)
+		return
+	}
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	var window time.Duration
+	if v := r.URL.Query().Get("window"); v != "" {
+		var err error
+		if window, err = time.ParseDuration(v); err != nil || window <= 0 || window > time.Hour {
+			handleError(w, http.StatusBadRequest, ErrInvalidUsageWindow)
+			return
+		}
+	}
+
+	res := &keyUsageResponse{
+		NodeID: s.nodeID,
+		Keys:   []*prometheus.KeyUsage{},
+	}
+	if window > 0 {
+		res.Window = window.String()
+	}
+	apiKey := r.URL.Query().Get("api_key")
+	for _, u := range prometheus.GetAPIKeyUsage(window) {
+		if apiKey == "" || u.APIKey == apiKey {
+			res.Keys = append(res.Keys, u)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(res)
+}
@@ -0,0 +1,77 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/protocol/auth"
+
+	"github.com/livekit/livekit-server/pkg/telemetry/prometheus"
+)
+
+func TestKeyUsageService(t *testing.T) {
+	prometheus.RecordAPIKeyTokenValidated("usage_key")
+	s := NewKeyUsageService("ND_local")
+
+	testCases := []struct {
+		name     string
+		method   string
+		query    string
+		noGrants bool
+		status   int
+		window   string
+		keys     []string
+	}{
+		{name: "no permission", method: http.MethodGet, noGrants: true, status: http.StatusUnauthorized},
+		{name: "get only", method: http.MethodPost, status: http.StatusMethodNotAllowed},
+		{name: "invalid window", method: http.MethodGet, query: "?window=soon", status: http.StatusBadRequest},
+		{name: "window over an hour", method: http.MethodGet, query: "?window=2h", status: http.StatusBadRequest},
+		{name: "negative window", method: http.MethodGet, query: "?window=-5m", status: http.StatusBadRequest},
+		{name: "by key", method: http.MethodGet, query: "?api_key=usage_key", status: http.StatusOK, keys: []string{"usage_key"}},
+		{name: "by key in window", method: http.MethodGet, query: "?api_key=usage_key&window=5m", status: http.StatusOK, window: "5m0s", keys: []string{"usage_key"}},
+		{name: "unknown key", method: http.MethodGet, query: "?api_key=other_key", status: http.StatusOK, keys: []string{}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, keyUsagePath+tc.query, nil)
+			if !tc.noGrants {
+				req = req.WithContext(WithGrants(req.Context(), &auth.ClaimGrants{Video: &auth.VideoGrant{RoomList: true}}))
+			}
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, req)
+			require.Equal(t, tc.status, w.Code)
+			if tc.status != http.StatusOK {
+				return
+			}
+
+			var res keyUsageResponse
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&res))
+			require.Equal(t, "ND_local", res.NodeID)
+			require.Equal(t, tc.window, res.Window)
+			keys := []string{}
+			for _, u := range res.Keys {
+				keys = append(keys, u.APIKey)
+				require.EqualValues(t, 1, u.TokensValidated)
+			}
+			require.Equal(t, tc.keys, keys)
+		})
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"errors"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/livekit/livekit-server/pkg/config"
+)
+
+const metricsPath = "/metrics"
+
+type listenerServer struct {
+	config config.ListenerConfig
+	server *http.Server
+}
+
+// newListenerHandler only serves the endpoints belonging to the listener's roles
+func newListenerHandler(roles []config.ListenerRole, handler http.Handler, metricsHandler http.Handler) http.Handler {
+	allowed := make(map[config.ListenerRole]bool, len(roles))
+	for _, role := range roles {
+		allowed[role] = true
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		role := listenerRoleForPath(r.URL.Path)
+		if !allowed[role] {
+			http.NotFound(w, r)
+			return
+		}
+		if role == config.ListenerRoleMetrics {
+			metricsHandler.ServeHTTP(w, r)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+func listenerRoleForPath(path string) config.ListenerRole {
+	switch {
+	case path == "/rtc" || strings.HasPrefix(path, "/rtc/"):
+		return config.ListenerRoleSignal
+	case path == "/" || path == "/status" || path == readyPath:
+		return config.ListenerRoleHealth
+	case path == metricsPath:
+		return config.ListenerRoleMetrics
+	default:
+		return config.ListenerRoleAPI
+	}
+}
+
+// serves API and health endpoints on the control socket, requests are trusted without tokens
+func newControlSocketHandler(handler http.Handler) http.Handler {
+	handler = newListenerHandler([]config.ListenerRole{config.ListenerRoleAPI, config.ListenerRoleHealth}, handler, nil)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler.ServeHTTP(w, r.WithContext(WithLocalControl(r.Context())))
+	})
+}
+
+// listenControlSocket binds the socket in a staging directory only the server can access and moves it into place
+// once its mode is set, so that it is never reachable with looser permissions than configured
+func listenControlSocket(conf *config.ControlSocketConfig) (net.Listener, error) {
+	mode, err := conf.FileMode()
+	if err != nil {
+		return nil, err
+	}
+
+	// remove a socket left behind by an unclean shutdown
+	if info, err := os.Lstat(conf.Path); err == nil {
+		if info.Mode().Type() != fs.ModeSocket {
+			return nil, errors.New("control socket path exists and is not a socket: " + conf.Path)
+		}
+		if err = os.Remove(conf.Path); err != nil {
+			return nil, err
+		}
+	}
+
+	// created with mode 0700
+	stagingDir, err := os.MkdirTemp(filepath.Dir(conf.Path), ".ctl")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(stagingDir)
+
+	// kept short, socket paths are limited to about 100 bytes
+	stagingPath := filepath.Join(stagingDir, "s")
+	ln, err := net.ListenUnix("unix", &net.UnixAddr{Name: stagingPath, Net: "unix"})
+	if err != nil {
+		return nil, err
+	}
+	// the staging path is gone once the socket is moved, it's removed from its final path on close
+	ln.SetUnlinkOnClose(false)
+
+	if err = os.Chmod(stagingPath, mode); err != nil {
+		_ = ln.Close()
+		return nil, err
+	}
+	if err = os.Rename(stagingPath, conf.Path); err != nil {
+		_ = ln.Close()
+		return nil, err
+	}
+	return &controlSocketListener{UnixListener: ln, path: conf.Path}, nil
+}
+
+type controlSocketListener struct {
+	*net.UnixListener
+	path string
+}
+
+func (l *controlSocketListener) Close() error {
+	err := l.UnixListener.Close()
+	_ = os.Remove(l.path)
+	return err
+}
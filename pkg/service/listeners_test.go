@@ -0,0 +1,146 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/protocol/livekit"
+
+	"github.com/livekit/livekit-server/pkg/config"
+)
+
+func TestListenerHandler(t *testing.T) {
+	api := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("api"))
+	})
+	metrics := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("metrics"))
+	})
+
+	testCases := []struct {
+		name   string
+		roles  []config.ListenerRole
+		path   string
+		served string
+	}{
+		{name: "signal", roles: []config.ListenerRole{config.ListenerRoleSignal}, path: "/rtc", served: "api"},
+		{name: "signal validate", roles: []config.ListenerRole{config.ListenerRoleSignal}, path: "/rtc/validate", served: "api"},
+		{name: "signal only", roles: []config.ListenerRole{config.ListenerRoleSignal}, path: "/twirp/livekit.RoomService/ListRooms"},
+		{name: "api", roles: []config.ListenerRole{config.ListenerRoleAPI}, path: "/twirp/livekit.RoomService/ListRooms", served: "api"},
+		{name: "api only", roles: []config.ListenerRole{config.ListenerRoleAPI}, path: "/rtc"},
+		{name: "rtc prefix is not signal", roles: []config.ListenerRole{config.ListenerRoleSignal}, path: "/rtcx"},
+		{name: "health", roles: []config.ListenerRole{config.ListenerRoleHealth}, path: "/", served: "api"},
+		{name: "readiness", roles: []config.ListenerRole{config.ListenerRoleHealth}, path: readyPath, served: "api"},
+		{name: "metrics", roles: []config.ListenerRole{config.ListenerRoleMetrics}, path: metricsPath, served: "metrics"},
+		{name: "metrics only", roles: []config.ListenerRole{config.ListenerRoleMetrics}, path: "/"},
+		{name: "several roles", roles: []config.ListenerRole{config.ListenerRoleHealth, config.ListenerRoleMetrics}, path: metricsPath, served: "metrics"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			newListenerHandler(tc.roles, api, metrics).ServeHTTP(w, httptest.NewRequest(http.MethodGet, tc.path, nil))
+			if tc.served == "" {
+				require.Equal(t, http.StatusNotFound, w.Code)
+				return
+			}
+			require.Equal(t, http.StatusOK, w.Code)
+			require.Equal(t, tc.served, w.Body.String())
+		})
+	}
+}
+
+func TestControlSocket(t *testing.T) {
+	// stands in for an admin API, unauthenticated requests are only allowed as local control
+	api := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := EnsureAdminPermission(r.Context(), livekit.RoomName("room")); err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+
+	t.Run("owner only before reachable", func(t *testing.T) {
+		dir := t.TempDir()
+		conf := &config.ControlSocketConfig{Path: filepath.Join(dir, "control.sock"), Mode: "0600"}
+		ln, err := listenControlSocket(conf)
+		require.NoError(t, err)
+		defer ln.Close()
+
+		info, err := os.Lstat(conf.Path)
+		require.NoError(t, err)
+		require.Equal(t, os.ModeSocket, info.Mode().Type())
+		require.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+		// the staging directory is gone
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+
+		srv := &http.Server{Handler: newControlSocketHandler(api)}
+		go func() { _ = srv.Serve(ln) }()
+		defer srv.Close()
+
+		client := &http.Client{Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", conf.Path)
+			},
+		}}
+		res, err := client.Get("http://control/twirp/livekit.RoomService/ListRooms")
+		require.NoError(t, err)
+		_ = res.Body.Close()
+		require.Equal(t, http.StatusOK, res.StatusCode)
+	})
+
+	t.Run("unauthenticated requests are rejected elsewhere", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		api.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/twirp/livekit.RoomService/ListRooms", nil))
+		require.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("replaces a stale socket", func(t *testing.T) {
+		conf := &config.ControlSocketConfig{Path: filepath.Join(t.TempDir(), "control.sock")}
+		stale, err := net.Listen("unix", conf.Path)
+		require.NoError(t, err)
+		stale.(*net.UnixListener).SetUnlinkOnClose(false)
+		require.NoError(t, stale.Close())
+
+		ln, err := listenControlSocket(conf)
+		require.NoError(t, err)
+		info, err := os.Lstat(conf.Path)
+		require.NoError(t, err)
+		require.Equal(t, os.FileMode(0660), info.Mode().Perm())
+
+		// removed on close
+		require.NoError(t, ln.Close())
+		_, err = os.Lstat(conf.Path)
+		require.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("refuses to replace other files", func(t *testing.T) {
+		conf := &config.ControlSocketConfig{Path: filepath.Join(t.TempDir(), "control.sock")}
+		require.NoError(t, os.WriteFile(conf.Path, nil, 0600))
+		_, err := listenControlSocket(conf)
+		require.Error(t, err)
+	})
+}
@@ -31,6 +31,24 @@ type LocalStore struct {
 	roomInternal map[livekit.RoomName]*livekit.RoomInternal
 	// map of roomName => { identity: participant }
 	participants map[livekit.RoomName]map[livekit.ParticipantIdentity]*livekit.ParticipantInfo
+	// map of roomName => name of the template the room was created with
+	templates map[livekit.RoomName]string
+	// map of participantID => resume token
+	resumeTokens map[livekit.ParticipantID]*ResumeToken
+	// map of roomName => seal
+	seals map[livekit.RoomName]*RoomSeal
+	// map of roomName => delayed closure
+	closures map[livekit.RoomName]*RoomClosure
+	// map of roomName => regions the room is pinned to
+	regions map[livekit.RoomName][]string
+	// map of roomName => rooms scheduled in advance
+	scheduled map[livekit.RoomName]*ScheduledRoom
+	// map of session limit key => { slot: owning session }
+	sessions map[string]map[string]*localSession
+	// map of roomName => API key the room was created with
+	apiKeys map[livekit.RoomName]string
+	// map of month => { API key: participant minutes }
+	participantMinutes map[string]map[string]float64
 
 	lock       sync.RWMutex
 	globalLock sync.Mutex
@@ -41,7 +59,17 @@ func NewLocalStore() *LocalStore {
 		rooms:        make(map[livekit.RoomName]*livekit.Room),
 		roomInternal: make(map[livekit.RoomName]*livekit.RoomInternal),
 		participants: make(map[livekit.RoomName]map[livekit.ParticipantIdentity]*livekit.ParticipantInfo),
+		templates:    make(map[livekit.RoomName]string),
+		resumeTokens: make(map[livekit.ParticipantID]*ResumeToken),
+		seals:        make(map[livekit.RoomName]*RoomSeal),
+		closures:     make(map[livekit.RoomName]*RoomClosure),
+		regions:      make(map[livekit.RoomName][]string),
+		scheduled:    make(map[livekit.RoomName]*ScheduledRoom),
+		sessions:     make(map[string]map[string]*localSession),
+		apiKeys:      make(map[livekit.RoomName]string),
 		lock:         sync.RWMutex{},
+
+		participantMinutes: make(map[string]map[string]float64),
 	}
 }
 
@@ -102,9 +130,291 @@ func (s *LocalStore) DeleteRoom(ctx context.Context, roomName livekit.RoomName)
 	delete(s.participants, livekit.RoomName(room.Name))
 	delete(s.rooms, livekit.RoomName(room.Name))
 	delete(s.roomInternal, livekit.RoomName(room.Name))
+	delete(s.templates, livekit.RoomName(room.Name))
+	delete(s.seals, livekit.RoomName(room.Name))
+	delete(s.closures, livekit.RoomName(room.Name))
+	delete(s.regions, livekit.RoomName(room.Name))
+	delete(s.apiKeys, livekit.RoomName(room.Name))
+	return nil
+}
+
+func (s *LocalStore) StoreRoomTemplate(_ context.Context, roomName livekit.RoomName, template string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if template == "" {
+		delete(s.templates, roomName)
+	} else {
+		s.templates[roomName] = template
+	}
+	return nil
+}
+
+func (s *LocalStore) LoadRoomTemplate(_ context.Context, roomName livekit.RoomName) (string, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.templates[roomName], nil
+}
+
+func (s *LocalStore) StoreResumeToken(_ context.Context, token *ResumeToken, ttl time.Duration) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	stored := *token
+	stored.ExpiresAt = time.Now().Add(ttl).Unix()
+	s.resumeTokens[token.ParticipantID] = &stored
+	return nil
+}
+
+func (s *LocalStore) RenewResumeToken(_ context.Context, participantID livekit.ParticipantID, ttl time.Duration) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if token := s.resumeTokens[participantID]; token != nil {
+		renewed := *token
+		renewed.ExpiresAt = time.Now().Add(ttl).Unix()
+		s.resumeTokens[participantID] = &renewed
+	}
+	return nil
+}
+
+func (s *LocalStore) LoadResumeToken(_ context.Context, participantID livekit.ParticipantID) (*ResumeToken, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	token := s.resumeTokens[participantID]
+	if token != nil && token.ExpiresAt < time.Now().Unix() {
+		delete(s.resumeTokens, participantID)
+		return nil, nil
+	}
+	return token, nil
+}
+
+func (s *LocalStore) DeleteResumeToken(_ context.Context, participantID livekit.ParticipantID) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.resumeTokens, participantID)
+	return nil
+}
+
+func (s *LocalStore) StoreRoomRegions(_ context.Context, roomName livekit.RoomName, regions []string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if len(regions) == 0 {
+		delete(s.regions, roomName)
+	} else {
+		s.regions[roomName] = regions
+	}
+	return nil
+}
+
+func (s *LocalStore) LoadRoomRegions(_ context.Context, roomName livekit.RoomName) ([]string, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.regions[roomName], nil
+}
+
+func (s *LocalStore) StoreRoomSeal(_ context.Context, seal *RoomSeal) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.seals[seal.Room] = seal
+	return nil
+}
+
+func (s *LocalStore) LoadRoomSeal(_ context.Context, roomName livekit.RoomName) (*RoomSeal, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.seals[roomName], nil
+}
+
+func (s *LocalStore) DeleteRoomSeal(_ context.Context, roomName livekit.RoomName) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.seals, roomName)
+	return nil
+}
+
+func (s *LocalStore) StoreRoomClosure(_ context.Context, closure *RoomClosure) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.closures[closure.Room] = closure
+	return nil
+}
+
+func (s *LocalStore) LoadRoomClosure(_ context.Context, roomName livekit.RoomName) (*RoomClosure, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.closures[roomName], nil
+}
+
+func (s *LocalStore) DeleteRoomClosure(_ context.Context, roomName livekit.RoomName) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.closures, roomName)
+	return nil
+}
+
+func (s *LocalStore) StoreScheduledRoom(_ context.Context, sr *ScheduledRoom) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.scheduled[livekit.RoomName(sr.Room)] = sr
+	return nil
+}
+
+func (s *LocalStore) LoadScheduledRoom(_ context.Context, roomName livekit.RoomName) (*ScheduledRoom, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.scheduled[roomName], nil
+}
+
+func (s *LocalStore) ListScheduledRooms(_ context.Context) ([]*ScheduledRoom, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	scheduled := make([]*ScheduledRoom, 0, len(s.scheduled))
+	for _, sr := range s.scheduled {
+		scheduled = append(scheduled, sr)
+	}
+	return scheduled, nil
+}
+
+func (s *LocalStore) DeleteScheduledRoom(_ context.Context, roomName livekit.RoomName) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.scheduled, roomName)
+	return nil
+}
+
+type localSession struct {
+	id       string
+	expireAt time.Time
+}
+
+func (s *LocalStore) AcquireSession(_ context.Context, key string, slot string, sessionID string, limit int, ttl time.Duration) (bool, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	now := time.Now()
+	slots := s.sessions[key]
+	if slots == nil {
+		slots = make(map[string]*localSession)
+		s.sessions[key] = slots
+	}
+	for sl, session := range slots {
+		if !session.expireAt.After(now) {
+			delete(slots, sl)
+		}
+	}
+	if _, ok := slots[slot]; !ok && len(slots) >= limit {
+		return false, nil
+	}
+	slots[slot] = &localSession{id: sessionID, expireAt: now.Add(ttl)}
+	return true, nil
+}
+
+func (s *LocalStore) RefreshSession(_ context.Context, key string, slot string, sessionID string, ttl time.Duration) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if session := s.sessions[key][slot]; session != nil && session.id == sessionID {
+		session.expireAt = time.Now().Add(ttl)
+	}
+	return nil
+}
+
+func (s *LocalStore) ReleaseSession(_ context.Context, key string, slot string, sessionID string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	slots := s.sessions[key]
+	if session := slots[slot]; session != nil && session.id == sessionID {
+		delete(slots, slot)
+		if len(slots) == 0 {
+			delete(s.sessions, key)
+		}
+	}
+	return nil
+}
+
+func (s *LocalStore) CountSessions(_ context.Context, key string) (int, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	now := time.Now()
+	count := 0
+	for _, session := range s.sessions[key] {
+		if session.expireAt.After(now) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *LocalStore) StoreRoomAPIKey(_ context.Context, roomName livekit.RoomName, apiKey string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.apiKeys[roomName] = apiKey
 	return nil
 }
 
+func (s *LocalStore) LoadRoomAPIKey(_ context.Context, roomName livekit.RoomName) (string, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.apiKeys[roomName], nil
+}
+
+func (s *LocalStore) CountAPIKeyRooms(_ context.Context) (map[string]int, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	counts := make(map[string]int)
+	for _, apiKey := range s.apiKeys {
+		counts[apiKey]++
+	}
+	return counts, nil
+}
+
+func (s *LocalStore) AddParticipantMinutes(_ context.Context, month string, minutes map[string]float64) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	usage := s.participantMinutes[month]
+	if usage == nil {
+		usage = make(map[string]float64)
+		s.participantMinutes[month] = usage
+	}
+	for apiKey, m := range minutes {
+		usage[apiKey] += m
+	}
+	return nil
+}
+
+func (s *LocalStore) LoadParticipantMinutes(_ context.Context, month string) (map[string]float64, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	minutes := make(map[string]float64, len(s.participantMinutes[month]))
+	for apiKey, m := range s.participantMinutes[month] {
+		minutes[apiKey] = m
+	}
+	return minutes, nil
+}
+
 func (s *LocalStore) LockRoom(_ context.Context, _ livekit.RoomName, _ time.Duration) (string, error) {
 	// local rooms lock & unlock globally
 	s.globalLock.Lock()
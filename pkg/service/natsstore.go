@@ -0,0 +1,402 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/utils"
+
+	"github.com/livekit/livekit-server/pkg/routing"
+)
+
+const (
+	// key-value bucket of room_name => Room proto
+	NATSRoomsBucket = "livekit_rooms"
+	// key-value bucket of room_name => RoomInternal proto
+	NATSRoomInternalBucket = "livekit_room_internal"
+	// key-value bucket of room_name.identity => ParticipantInfo proto
+	NATSRoomParticipantsBucket = "livekit_room_participants"
+	// key-value bucket of room_name => room settings kept apart from the room: template, seal and regions
+	NATSRoomSettingsBucket = "livekit_room_settings"
+	// key-value bucket of room_name => lock token and expiry
+	NATSRoomLocksBucket = "livekit_room_locks"
+)
+
+// NATSStore keeps rooms and participants in JetStream key-value buckets, for deployments using NATS instead
+// of Redis. Egress, ingress and resume tokens are not stored
+type NATSStore struct {
+	rooms        nats.KeyValue
+	roomInternal nats.KeyValue
+	participants nats.KeyValue
+	settings     nats.KeyValue
+	locks        nats.KeyValue
+}
+
+func NewNATSStore(nc *nats.Conn, replicas int) (*NATSStore, error) {
+	s := &NATSStore{}
+	for bucket, kv := range map[string]*nats.KeyValue{
+		NATSRoomsBucket:            &s.rooms,
+		NATSRoomInternalBucket:     &s.roomInternal,
+		NATSRoomParticipantsBucket: &s.participants,
+		NATSRoomSettingsBucket:     &s.settings,
+		NATSRoomLocksBucket:        &s.locks,
+	} {
+		var err error
+		if *kv, err = routing.NATSKeyValue(nc, bucket, 0, replicas); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+func (s *NATSStore) StoreRoom(_ context.Context, room *livekit.Room, internal *livekit.RoomInternal) error {
+	if room.CreationTime == 0 {
+		room.CreationTime = time.Now().Unix()
+	}
+
+	roomData, err := proto.Marshal(room)
+	if err != nil {
+		return err
+	}
+	if _, err = s.rooms.Put(routing.NATSKey(room.Name), roomData); err != nil {
+		return errors.Wrap(err, "could not create room")
+	}
+
+	if internal == nil {
+		return s.roomInternal.Delete(routing.NATSKey(room.Name))
+	}
+	internalData, err := proto.Marshal(internal)
+	if err != nil {
+		return err
+	}
+	if _, err = s.roomInternal.Put(routing.NATSKey(room.Name), internalData); err != nil {
+		return errors.Wrap(err, "could not create room")
+	}
+	return nil
+}
+
+func (s *NATSStore) LoadRoom(_ context.Context, roomName livekit.RoomName, includeInternal bool) (*livekit.Room, *livekit.RoomInternal, error) {
+	entry, err := s.rooms.Get(routing.NATSKey(string(roomName)))
+	if err == nats.ErrKeyNotFound {
+		return nil, nil, ErrRoomNotFound
+	} else if err != nil {
+		return nil, nil, err
+	}
+	room := &livekit.Room{}
+	if err = proto.Unmarshal(entry.Value(), room); err != nil {
+		return nil, nil, err
+	}
+
+	var internal *livekit.RoomInternal
+	if includeInternal {
+		entry, err = s.roomInternal.Get(routing.NATSKey(string(roomName)))
+		if err == nil {
+			internal = &livekit.RoomInternal{}
+			if err = proto.Unmarshal(entry.Value(), internal); err != nil {
+				return nil, nil, err
+			}
+		} else if err != nats.ErrKeyNotFound {
+			return nil, nil, err
+		}
+	}
+
+	return room, internal, nil
+}
+
+func (s *NATSStore) ListRooms(_ context.Context, roomNames []livekit.RoomName) ([]*livekit.Room, error) {
+	var keys []string
+	if roomNames == nil {
+		var err error
+		keys, err = s.rooms.Keys()
+		if err != nil && err != nats.ErrNoKeysFound {
+			return nil, errors.Wrap(err, "could not get rooms")
+		}
+	} else {
+		for _, name := range roomNames {
+			keys = append(keys, routing.NATSKey(string(name)))
+		}
+	}
+
+	rooms := make([]*livekit.Room, 0, len(keys))
+	for _, key := range keys {
+		entry, err := s.rooms.Get(key)
+		if err == nats.ErrKeyNotFound {
+			continue
+		} else if err != nil {
+			return nil, errors.Wrap(err, "could not get rooms")
+		}
+		room := livekit.Room{}
+		if err := proto.Unmarshal(entry.Value(), &room); err != nil {
+			return nil, err
+		}
+		rooms = append(rooms, &room)
+	}
+	return rooms, nil
+}
+
+func (s *NATSStore) DeleteRoom(ctx context.Context, roomName livekit.RoomName) error {
+	_, _, err := s.LoadRoom(ctx, roomName, false)
+	if err == ErrRoomNotFound {
+		return nil
+	}
+
+	key := routing.NATSKey(string(roomName))
+	if err = s.rooms.Delete(key); err != nil {
+		return err
+	}
+	_ = s.roomInternal.Delete(key)
+	for _, setting := range []string{natsTemplateSetting, natsSealSetting, natsRegionsSetting} {
+		_ = s.settings.Delete(natsSettingKey(roomName, setting))
+	}
+
+	keys, err := s.participantKeys(roomName)
+	if err != nil {
+		return err
+	}
+	for _, k := range keys {
+		if err = s.participants.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const (
+	natsTemplateSetting = "template"
+	natsSealSetting     = "seal"
+	natsRegionsSetting  = "regions"
+)
+
+func natsSettingKey(roomName livekit.RoomName, setting string) string {
+	return routing.NATSKey(string(roomName)) + "." + setting
+}
+
+func (s *NATSStore) storeSetting(roomName livekit.RoomName, setting string, data []byte) error {
+	if len(data) == 0 {
+		return s.settings.Delete(natsSettingKey(roomName, setting))
+	}
+	_, err := s.settings.Put(natsSettingKey(roomName, setting), data)
+	return err
+}
+
+func (s *NATSStore) loadSetting(roomName livekit.RoomName, setting string) ([]byte, error) {
+	entry, err := s.settings.Get(natsSettingKey(roomName, setting))
+	if err == nats.ErrKeyNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return entry.Value(), nil
+}
+
+func (s *NATSStore) StoreRoomTemplate(_ context.Context, roomName livekit.RoomName, template string) error {
+	return s.storeSetting(roomName, natsTemplateSetting, []byte(template))
+}
+
+func (s *NATSStore) LoadRoomTemplate(_ context.Context, roomName livekit.RoomName) (string, error) {
+	data, err := s.loadSetting(roomName, natsTemplateSetting)
+	return string(data), err
+}
+
+func (s *NATSStore) StoreRoomRegions(_ context.Context, roomName livekit.RoomName, regions []string) error {
+	if len(regions) == 0 {
+		return s.storeSetting(roomName, natsRegionsSetting, nil)
+	}
+	data, err := json.Marshal(regions)
+	if err != nil {
+		return err
+	}
+	return s.storeSetting(roomName, natsRegionsSetting, data)
+}
+
+func (s *NATSStore) LoadRoomRegions(_ context.Context, roomName livekit.RoomName) ([]string, error) {
+	data, err := s.loadSetting(roomName, natsRegionsSetting)
+	if err != nil || data == nil {
+		return nil, err
+	}
+	var regions []string
+	if err = json.Unmarshal(data, &regions); err != nil {
+		return nil, err
+	}
+	return regions, nil
+}
+
+func (s *NATSStore) StoreRoomSeal(_ context.Context, seal *RoomSeal) error {
+	data, err := json.Marshal(seal)
+	if err != nil {
+		return err
+	}
+	return s.storeSetting(seal.Room, natsSealSetting, data)
+}
+
+func (s *NATSStore) LoadRoomSeal(_ context.Context, roomName livekit.RoomName) (*RoomSeal, error) {
+	data, err := s.loadSetting(roomName, natsSealSetting)
+	if err != nil || data == nil {
+		return nil, err
+	}
+	seal := &RoomSeal{}
+	if err = json.Unmarshal(data, seal); err != nil {
+		return nil, err
+	}
+	return seal, nil
+}
+
+func (s *NATSStore) DeleteRoomSeal(_ context.Context, roomName livekit.RoomName) error {
+	return s.storeSetting(roomName, natsSealSetting, nil)
+}
+
+// LockRoom stores the lock token with its expiry, an expired lock is taken over by the next caller
+func (s *NATSStore) LockRoom(_ context.Context, roomName livekit.RoomName, duration time.Duration) (string, error) {
+	token := utils.NewGuid("LOCK")
+	key := routing.NATSKey(string(roomName))
+
+	startTime := time.Now()
+	for {
+		value := []byte(token + "|" + strconv.FormatInt(time.Now().Add(duration).UnixNano(), 10))
+		_, err := s.locks.Create(key, value)
+		if err == nil {
+			return token, nil
+		} else if err != nats.ErrKeyExists {
+			return "", err
+		}
+
+		entry, err := s.locks.Get(key)
+		if err == nil {
+			if _, expiresAt := parseNATSLock(entry.Value()); time.Now().After(expiresAt) {
+				if _, err = s.locks.Update(key, value, entry.Revision()); err == nil {
+					return token, nil
+				}
+			}
+		}
+
+		// stop waiting past lock duration
+		if time.Since(startTime) > duration {
+			break
+		}
+
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	return "", ErrRoomLockFailed
+}
+
+func (s *NATSStore) UnlockRoom(_ context.Context, roomName livekit.RoomName, uid string) error {
+	key := routing.NATSKey(string(roomName))
+	entry, err := s.locks.Get(key)
+	if err == nats.ErrKeyNotFound {
+		return ErrRoomUnlockFailed
+	} else if err != nil {
+		return err
+	}
+
+	// uid does not match
+	if token, _ := parseNATSLock(entry.Value()); token != uid {
+		return ErrRoomUnlockFailed
+	}
+	return s.locks.Delete(key, nats.LastRevision(entry.Revision()))
+}
+
+func parseNATSLock(value []byte) (string, time.Time) {
+	token, expiry, _ := strings.Cut(string(value), "|")
+	expiresAt, _ := strconv.ParseInt(expiry, 10, 64)
+	return token, time.Unix(0, expiresAt)
+}
+
+func natsParticipantKey(roomName livekit.RoomName, identity livekit.ParticipantIdentity) string {
+	return routing.NATSKey(string(roomName)) + "." + routing.NATSKey(string(identity))
+}
+
+func (s *NATSStore) StoreParticipant(_ context.Context, roomName livekit.RoomName, participant *livekit.ParticipantInfo) error {
+	data, err := proto.Marshal(participant)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.participants.Put(natsParticipantKey(roomName, livekit.ParticipantIdentity(participant.Identity)), data)
+	return err
+}
+
+func (s *NATSStore) LoadParticipant(_ context.Context, roomName livekit.RoomName, identity livekit.ParticipantIdentity) (*livekit.ParticipantInfo, error) {
+	entry, err := s.participants.Get(natsParticipantKey(roomName, identity))
+	if err == nats.ErrKeyNotFound {
+		return nil, ErrParticipantNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	pi := livekit.ParticipantInfo{}
+	if err := proto.Unmarshal(entry.Value(), &pi); err != nil {
+		return nil, err
+	}
+	return &pi, nil
+}
+
+func (s *NATSStore) ListParticipants(_ context.Context, roomName livekit.RoomName) ([]*livekit.ParticipantInfo, error) {
+	var participants []*livekit.ParticipantInfo
+	err := s.watchParticipants(roomName, func(entry nats.KeyValueEntry) error {
+		pi := livekit.ParticipantInfo{}
+		if err := proto.Unmarshal(entry.Value(), &pi); err != nil {
+			return err
+		}
+		participants = append(participants, &pi)
+		return nil
+	})
+	return participants, err
+}
+
+func (s *NATSStore) DeleteParticipant(_ context.Context, roomName livekit.RoomName, identity livekit.ParticipantIdentity) error {
+	return s.participants.Delete(natsParticipantKey(roomName, identity))
+}
+
+func (s *NATSStore) participantKeys(roomName livekit.RoomName) ([]string, error) {
+	var keys []string
+	err := s.watchParticipants(roomName, func(entry nats.KeyValueEntry) error {
+		keys = append(keys, entry.Key())
+		return nil
+	})
+	return keys, err
+}
+
+// watchParticipants calls f with the current participants of the room
+func (s *NATSStore) watchParticipants(roomName livekit.RoomName, f func(entry nats.KeyValueEntry) error) error {
+	w, err := s.participants.Watch(routing.NATSKey(string(roomName))+".*", nats.IgnoreDeletes())
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = w.Stop()
+	}()
+
+	for entry := range w.Updates() {
+		// a nil entry marks the end of the current values
+		if entry == nil {
+			return nil
+		}
+		if err := f(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/livekit/protocol/livekit"
+
+	"github.com/livekit/livekit-server/pkg/routing"
+)
+
+const nodeLatencyPath = "/nodes/latency"
+
+type nodeLatencyNode struct {
+	ID     string `json:"id"`
+	Region string `json:"region,omitempty"`
+	IP     string `json:"ip,omitempty"`
+}
+
+type nodeLatency struct {
+	From  string  `json:"from"`
+	To    string  `json:"to"`
+	RTTMs float64 `json:"rtt_ms"`
+}
+
+type nodeRelayPath struct {
+	Nodes []livekit.NodeID `json:"nodes"`
+	RTTMs float64          `json:"rtt_ms"`
+}
+
+type nodeLatencyResponse struct {
+	Nodes     []*nodeLatencyNode `json:"nodes"`
+	Latencies []*nodeLatency     `json:"latencies"`
+	// only with ?from=<node_id>&to=<node_id>
+	RelayPath *nodeRelayPath `json:"relay_path,omitempty"`
+}
+
+// NodeLatencyService renders the RTTs measured between the nodes of the cluster, as JSON or, with
+// ?format=text, as a matrix of milliseconds. With ?from=<node_id>&to=<node_id>, it also returns the
+// lowest-latency relay path between the two nodes
+type NodeLatencyService struct {
+	router routing.Router
+}
+
+func NewNodeLatencyService(router routing.Router) *NodeLatencyService {
+	return &NodeLatencyService{
+		router: router,
+	}
+}
+
+func (s *NodeLatencyService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := EnsureListPermission(r.Context()); err != nil {
+		handleError(w, http.StatusUnauthorized, err)
+		return
+	}
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	lr, ok := s.router.(routing.LatencyReporter)
+	if !ok {
+		handleError(w, http.StatusServiceUnavailable, ErrLatencyUnavailable)
+		return
+	}
+	latencies, err := lr.GetLatencyMap()
+	if err != nil {
+		handleError(w, http.StatusInternalServerError, err)
+		return
+	}
+	nodes, err := s.router.ListNodes()
+	if err != nil {
+		handleError(w, http.StatusInternalServerError, err)
+		return
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].Id < nodes[j].Id
+	})
+
+	if r.URL.Query().Get("format") == "text" {
+		w.Header().Set("Content-Type", "text/plain")
+		renderLatencyMatrix(w, nodes, latencies)
+		return
+	}
+
+	res := &nodeLatencyResponse{
+		Nodes:     make([]*nodeLatencyNode, 0, len(nodes)),
+		Latencies: make([]*nodeLatency, 0),
+	}
+	for _, node := range nodes {
+		res.Nodes = append(res.Nodes, &nodeLatencyNode{
+			ID:     node.Id,
+			Region: node.Region,
+			IP:     node.Ip,
+		})
+	}
+	for from, rtts := range latencies {
+		for to, rtt := range rtts {
+			res.Latencies = append(res.Latencies, &nodeLatency{
+				From:  string(from),
+				To:    string(to),
+				RTTMs: toMilliseconds(rtt),
+			})
+		}
+	}
+	sort.Slice(res.Latencies, func(i, j int) bool {
+		if res.Latencies[i].From != res.Latencies[j].From {
+			return res.Latencies[i].From < res.Latencies[j].From
+		}
+		return res.Latencies[i].To < res.Latencies[j].To
+	})
+
+	from, to := r.URL.Query().Get("from"), r.URL.Query().Get("to")
+	if from != "" && to != "" {
+		if path, rtt := latencies.RelayPath(livekit.NodeID(from), livekit.NodeID(to)); path != nil {
+			res.RelayPath = &nodeRelayPath{
+				Nodes: path,
+				RTTMs: toMilliseconds(rtt),
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(res)
+}
+
+// one row per measuring node, one column per probed node
+func renderLatencyMatrix(w http.ResponseWriter, nodes []*livekit.Node, latencies routing.LatencyMap) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	_, _ = fmt.Fprint(tw, "\t")
+	for _, node := range nodes {
+		_, _ = fmt.Fprintf(tw, "%s\t", node.Id)
+	}
+	_, _ = fmt.Fprintln(tw)
+
+	for _, from := range nodes {
+		_, _ = fmt.Fprintf(tw, "%s (%s)\t", from.Id, from.Region)
+		for _, to := range nodes {
+			if rtt, ok := latencies.Latency(livekit.NodeID(from.Id), livekit.NodeID(to.Id)); ok {
+				_, _ = fmt.Fprintf(tw, "%.1fms\t", toMilliseconds(rtt))
+			} else {
+				_, _ = fmt.Fprint(tw, "-\t")
+			}
+		}
+		_, _ = fmt.Fprintln(tw)
+	}
+	_ = tw.Flush()
+}
+
+func toMilliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
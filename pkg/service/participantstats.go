@@ -0,0 +1,132 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/pion/webrtc/v3"
+
+	"github.com/livekit/protocol/livekit"
+)
+
+const participantStatsPath = "/rooms/participant_stats"
+
+type subscribedTrackAllocation struct {
+	Deviation          string  `json:"deviation"`
+	PauseReason        string  `json:"pause_reason"`
+	TargetSpatial      int32   `json:"target_spatial"`
+	TargetTemporal     int32   `json:"target_temporal"`
+	CurrentSpatial     int32   `json:"current_spatial"`
+	CurrentTemporal    int32   `json:"current_temporal"`
+	MaxSpatial         int32   `json:"max_spatial"`
+	MaxTemporal        int32   `json:"max_temporal"`
+	PublishedSpatial   int32   `json:"published_spatial"`
+	PublishedTemporal  int32   `json:"published_temporal"`
+	BandwidthRequested int64   `json:"bandwidth_requested"`
+	DistanceToDesired  float64 `json:"distance_to_desired"`
+}
+
+type subscribedTrackStat struct {
+	TrackSid          string                     `json:"track_sid"`
+	PublisherIdentity string                     `json:"publisher_identity"`
+	MimeType          string                     `json:"mime_type"`
+	Allocation        *subscribedTrackAllocation `json:"allocation,omitempty"`
+}
+
+type participantStatsResponse struct {
+	Room             string                `json:"room"`
+	Identity         string                `json:"identity"`
+	SubscribedTracks []subscribedTrackStat `json:"subscribed_tracks"`
+}
+
+// ParticipantStatsService reports the subscribed tracks of a participant along with the stream allocator decision
+// for each video track, i. e. the layer it is targeting and why that differs from the layer the subscriber asked for.
+// Only rooms hosted on the node handling the request are known.
+type ParticipantStatsService struct {
+	roomManager *RoomManager
+}
+
+func NewParticipantStatsService(roomManager *RoomManager) *ParticipantStatsService {
+	return &ParticipantStatsService{
+		roomManager: roomManager,
+	}
+}
+
+func (s *ParticipantStatsService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	roomName := livekit.RoomName(r.URL.Query().Get("room"))
+	identity := livekit.ParticipantIdentity(r.URL.Query().Get("identity"))
+	if err := EnsureAdminPermission(r.Context(), roomName); err != nil {
+		handleError(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	room := s.roomManager.GetRoom(r.Context(), roomName)
+	if room == nil {
+		handleError(w, http.StatusNotFound, ErrRoomNotFound, "room", roomName)
+		return
+	}
+
+	participant := room.GetParticipant(identity)
+	if participant == nil {
+		handleError(w, http.StatusNotFound, ErrParticipantNotFound, "room", roomName, "participant", identity)
+		return
+	}
+
+	res := &participantStatsResponse{
+		Room:             string(roomName),
+		Identity:         string(identity),
+		SubscribedTracks: []subscribedTrackStat{},
+	}
+	for _, st := range participant.GetSubscribedTracks() {
+		dt := st.DownTrack()
+		if dt == nil {
+			continue
+		}
+
+		stat := subscribedTrackStat{
+			TrackSid:          string(st.ID()),
+			PublisherIdentity: string(st.PublisherIdentity()),
+			MimeType:          dt.Codec().MimeType,
+		}
+		if dt.Kind() == webrtc.RTPCodecTypeVideo {
+			state := dt.AllocationState()
+			stat.Allocation = &subscribedTrackAllocation{
+				Deviation:          string(state.Deviation),
+				PauseReason:        state.PauseReason.String(),
+				TargetSpatial:      state.TargetLayer.Spatial,
+				TargetTemporal:     state.TargetLayer.Temporal,
+				CurrentSpatial:     state.CurrentLayer.Spatial,
+				CurrentTemporal:    state.CurrentLayer.Temporal,
+				MaxSpatial:         state.MaxLayer.Spatial,
+				MaxTemporal:        state.MaxLayer.Temporal,
+				PublishedSpatial:   state.MaxPublishedLayer.Spatial,
+				PublishedTemporal:  state.MaxPublishedLayer.Temporal,
+				BandwidthRequested: state.BandwidthRequested,
+				DistanceToDesired:  state.DistanceToDesired,
+			}
+		}
+		res.SubscribedTracks = append(res.SubscribedTracks, stat)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(res)
+}
@@ -0,0 +1,93 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/livekit/livekit-server/pkg/postroom"
+	"github.com/livekit/protocol/livekit"
+)
+
+const postRoomPath = "/post_room"
+
+type retryPostRoomRequest struct {
+	ID string `json:"id"`
+}
+
+type listPostRoomRunsResponse struct {
+	Runs []*postroom.Run `json:"runs"`
+}
+
+// PostRoomService reports the pipelines run for rooms that finished on this node, and retries failed runs
+type PostRoomService struct {
+	manager *postroom.Manager
+}
+
+func NewPostRoomService(manager *postroom.Manager) *PostRoomService {
+	return &PostRoomService{
+		manager: manager,
+	}
+}
+
+func (s *PostRoomService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.manager == nil {
+		handleError(w, http.StatusNotFound, ErrPostRoomDisabled)
+		return
+	}
+	if err := EnsureRecordPermission(r.Context()); err != nil {
+		handleError(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, postRoomPath), "/")
+	switch {
+	case r.Method == http.MethodGet && path == "":
+		runs := s.manager.List(livekit.RoomName(r.URL.Query().Get("room")))
+		s.writeJSON(w, &listPostRoomRunsResponse{Runs: runs})
+	case r.Method == http.MethodPost && path == "retry":
+		s.retry(w, r)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (s *PostRoomService) retry(w http.ResponseWriter, r *http.Request) {
+	var req retryPostRoomRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		handleError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	run, err := s.manager.Retry(req.ID)
+	switch {
+	case errors.Is(err, postroom.ErrRunNotFound):
+		handleError(w, http.StatusNotFound, err, "id", req.ID)
+	case errors.Is(err, postroom.ErrRunNotFailed):
+		handleError(w, http.StatusConflict, err, "id", req.ID)
+	case err != nil:
+		handleError(w, http.StatusInternalServerError, err, "id", req.ID)
+	default:
+		s.writeJSON(w, run)
+	}
+}
+
+func (s *PostRoomService) writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
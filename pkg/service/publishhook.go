@@ -0,0 +1,174 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/livekit/protocol/auth"
+	"github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/logger"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/rtc"
+	"github.com/livekit/livekit-server/pkg/rtc/types"
+)
+
+const publishHookMaxResponseSize = 64 * 1024
+
+// PublishHook asks an external service whether a track may be published. Requests are signed like
+// webhooks, with the sha256 of the body in the token of the Authorization header.
+type PublishHook struct {
+	conf   config.PublishHookConfig
+	apiKey string
+	secret string
+	client *http.Client
+}
+
+type publishHookRequest struct {
+	Room          livekit.RoomName            `json:"room"`
+	Participant   livekit.ParticipantIdentity `json:"participant"`
+	ParticipantID livekit.ParticipantID       `json:"participant_id"`
+	Track         publishHookTrack            `json:"track"`
+}
+
+type publishHookTrack struct {
+	Cid       string             `json:"cid"`
+	Name      string             `json:"name,omitempty"`
+	Type      string             `json:"type"`
+	Source    string             `json:"source"`
+	Width     uint32             `json:"width,omitempty"`
+	Height    uint32             `json:"height,omitempty"`
+	Codecs    []string           `json:"codecs,omitempty"`
+	Layers    []publishHookLayer `json:"layers,omitempty"`
+	Encrypted bool               `json:"encrypted,omitempty"`
+}
+
+type publishHookLayer struct {
+	Quality string `json:"quality"`
+	Width   uint32 `json:"width"`
+	Height  uint32 `json:"height"`
+	Bitrate uint32 `json:"bitrate,omitempty"`
+}
+
+// NewPublishHook returns nil when no URL is configured
+func NewPublishHook(conf *config.Config, provider auth.KeyProvider) (*PublishHook, error) {
+	hc := conf.PublishHook
+	if hc.URL == "" {
+		return nil, nil
+	}
+	apiKey := hc.APIKey
+	if apiKey == "" {
+		apiKey = conf.WebHook.APIKey
+	}
+	secret := provider.GetSecret(apiKey)
+	if secret == "" {
+		return nil, ErrPublishHookNoAPIKey
+	}
+	return &PublishHook{
+		conf:   hc,
+		apiKey: apiKey,
+		secret: secret,
+		client: &http.Client{Timeout: hc.Timeout},
+	}, nil
+}
+
+// Authorize returns the decision of the service. When it cannot be reached or fails, publishing is
+// allowed unchanged with fail_open, and denied otherwise.
+func (h *PublishHook) Authorize(roomName livekit.RoomName, participant types.LocalParticipant, req *livekit.AddTrackRequest) *rtc.PublishDecision {
+	decision, err := h.request(newPublishHookRequest(roomName, participant, req))
+	if err != nil {
+		logger.Warnw("publish hook failed", err,
+			"room", roomName,
+			"participant", participant.Identity(),
+			"failOpen", h.conf.FailOpen,
+		)
+		return &rtc.PublishDecision{Allow: h.conf.FailOpen, Reason: "publish hook failed"}
+	}
+	return decision
+}
+
+func (h *PublishHook) request(hr *publishHookRequest) (*rtc.PublishDecision, error) {
+	body, err := json.Marshal(hr)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(body)
+	token, err := auth.NewAccessToken(h.apiKey, h.secret).
+		SetValidFor(5 * time.Minute).
+		SetSha256(base64.StdEncoding.EncodeToString(sum[:])).
+		ToJWT()
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := http.NewRequest(http.MethodPost, h.conf.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	r.Header.Set("Authorization", token)
+	r.Header.Set("Content-Type", "application/json")
+
+	resp, err := h.client.Do(r)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	decision := &rtc.PublishDecision{}
+	if err = json.NewDecoder(io.LimitReader(resp.Body, publishHookMaxResponseSize)).Decode(decision); err != nil {
+		return nil, err
+	}
+	return decision, nil
+}
+
+func newPublishHookRequest(roomName livekit.RoomName, participant types.LocalParticipant, req *livekit.AddTrackRequest) *publishHookRequest {
+	hr := &publishHookRequest{
+		Room:          roomName,
+		Participant:   participant.Identity(),
+		ParticipantID: participant.ID(),
+		Track: publishHookTrack{
+			Cid:       req.Cid,
+			Name:      req.Name,
+			Type:      req.Type.String(),
+			Source:    req.Source.String(),
+			Width:     req.Width,
+			Height:    req.Height,
+			Encrypted: req.Encryption != livekit.Encryption_NONE,
+		},
+	}
+	for _, c := range req.SimulcastCodecs {
+		hr.Track.Codecs = append(hr.Track.Codecs, c.Codec)
+	}
+	for _, l := range req.Layers {
+		hr.Track.Layers = append(hr.Track.Layers, publishHookLayer{
+			Quality: l.Quality.String(),
+			Width:   l.Width,
+			Height:  l.Height,
+			Bitrate: l.Bitrate,
+		})
+	}
+	return hr
+}
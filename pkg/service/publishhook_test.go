@@ -0,0 +1,95 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service_test
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/protocol/auth"
+	"github.com/livekit/protocol/auth/authfakes"
+	"github.com/livekit/protocol/livekit"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/rtc/types/typesfakes"
+	"github.com/livekit/livekit-server/pkg/service"
+)
+
+func TestPublishHook(t *testing.T) {
+	secret := "somesecretencodedinbase62"
+	provider := &authfakes.FakeKeyProvider{}
+	provider.GetSecretReturns(secret)
+
+	var received map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		v, err := auth.ParseAPIToken(r.Header.Get("Authorization"))
+		require.NoError(t, err)
+		claims, err := v.Verify(secret)
+		require.NoError(t, err)
+		sum := sha256.Sum256(body)
+		require.Equal(t, base64.StdEncoding.EncodeToString(sum[:]), claims.Sha256)
+
+		require.NoError(t, json.Unmarshal(body, &received))
+		_, _ = w.Write([]byte(`{"allow": true, "max_width": 1280, "max_height": 720}`))
+	}))
+	defer srv.Close()
+
+	conf := &config.Config{
+		WebHook:     config.WebHookConfig{APIKey: "APIabcdefg"},
+		PublishHook: config.PublishHookConfig{URL: srv.URL, Timeout: time.Second},
+	}
+	hook, err := service.NewPublishHook(conf, provider)
+	require.NoError(t, err)
+
+	participant := &typesfakes.FakeLocalParticipant{}
+	participant.IdentityReturns("teacher")
+	req := &livekit.AddTrackRequest{
+		Cid:    "cid",
+		Type:   livekit.TrackType_VIDEO,
+		Source: livekit.TrackSource_CAMERA,
+		Width:  1920,
+		Height: 1080,
+	}
+	decision := hook.Authorize("lecture", participant, req)
+	require.True(t, decision.Allow)
+	require.Equal(t, uint32(1280), decision.MaxWidth)
+	require.Equal(t, uint32(720), decision.MaxHeight)
+	require.Equal(t, "lecture", received["room"])
+	require.Equal(t, "teacher", received["participant"])
+	require.Equal(t, "CAMERA", received["track"].(map[string]any)["source"])
+
+	t.Run("fails closed when unreachable", func(t *testing.T) {
+		conf.PublishHook.URL = "http://127.0.0.1:1"
+		hook, err := service.NewPublishHook(conf, provider)
+		require.NoError(t, err)
+		require.False(t, hook.Authorize("lecture", participant, req).Allow)
+
+		conf.PublishHook.FailOpen = true
+		hook, err = service.NewPublishHook(conf, provider)
+		require.NoError(t, err)
+		require.True(t, hook.Authorize("lecture", participant, req).Allow)
+	})
+}
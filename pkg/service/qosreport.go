@@ -0,0 +1,107 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/logger"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/rtc"
+	"github.com/livekit/livekit-server/pkg/storage"
+	"github.com/livekit/livekit-server/pkg/webhooks"
+)
+
+// QoSReportHeader is the object key of the QoS report of the room in room_finished webhooks, in the bucket of the
+// storage profile of the report
+const QoSReportHeader = "X-Livekit-Qos-Report"
+
+const qosReportTimeout = 10 * time.Second
+
+type qosReport struct {
+	Room         string                     `json:"room"`
+	RoomID       string                     `json:"room_id"`
+	CreatedAt    int64                      `json:"created_at"`
+	EndedAt      int64                      `json:"ended_at"`
+	Participants []rtc.QoSParticipantReport `json:"participants"`
+}
+
+// QoSReports stores the network QoS report of rooms as they close, as qos/<room>/<room sid>.json
+type QoSReports struct {
+	conf    *config.QoSReportConfig
+	storage *storage.Storage
+}
+
+// NewQoSReports returns nil when reports are disabled
+func NewQoSReports(conf *config.Config, s *storage.Storage) *QoSReports {
+	if !conf.QoSReport.Enabled {
+		return nil
+	}
+	return &QoSReports{
+		conf:    &conf.QoSReport,
+		storage: s,
+	}
+}
+
+func (q *QoSReports) Enabled() bool {
+	return q != nil
+}
+
+// Store uploads the report of a closed room and returns ctx with the report referenced by room_finished webhooks
+// queued with it. ctx is returned as is when the report could not be stored
+func (q *QoSReports) Store(ctx context.Context, room *livekit.Room, participants []rtc.QoSParticipantReport) context.Context {
+	if q == nil || participants == nil {
+		return ctx
+	}
+
+	profile := q.conf.StorageProfile
+	if profile == "" {
+		profile = q.storage.ProfileFor(livekit.RoomName(room.Name))
+	}
+	objects, err := q.storage.Objects(profile)
+	if err != nil {
+		logger.Warnw("could not store QoS report", err, "room", room.Name, "roomID", room.Sid, "profile", profile)
+		return ctx
+	}
+
+	data, err := json.Marshal(&qosReport{
+		Room:         room.Name,
+		RoomID:       room.Sid,
+		CreatedAt:    room.CreationTime,
+		EndedAt:      time.Now().Unix(),
+		Participants: participants,
+	})
+	if err != nil {
+		logger.Errorw("could not encode QoS report", err, "room", room.Name, "roomID", room.Sid)
+		return ctx
+	}
+
+	key := qosReportKey(room)
+	putCtx, cancel := context.WithTimeout(context.Background(), qosReportTimeout)
+	defer cancel()
+	if err = objects.Put(putCtx, key, "application/json", data); err != nil {
+		logger.Warnw("could not store QoS report", err, "room", room.Name, "roomID", room.Sid, "profile", profile)
+		return ctx
+	}
+	return webhooks.WithHeader(ctx, QoSReportHeader, q.storage.ObjectKey(profile, key))
+}
+
+func qosReportKey(room *livekit.Room) string {
+	return "qos/" + room.Name + "/" + room.Sid + ".json"
+}
@@ -0,0 +1,378 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/logger"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/rtc"
+	"github.com/livekit/livekit-server/pkg/telemetry/prometheus"
+)
+
+const (
+	quotaRooms                     = "rooms"
+	quotaParticipants              = "participants"
+	quotaMonthlyParticipantMinutes = "monthly_participant_minutes"
+
+	// participant minutes of this node are added to the cluster usage at this interval
+	quotaUsageInterval  = 30 * time.Second
+	quotaEgressInterval = time.Second
+)
+
+func quotaMonth(t time.Time) string {
+	return t.UTC().Format("2006-01")
+}
+
+// participants of a key are counted with the session limit slots, see acquireSessionLimits
+func quotaParticipantsKey(apiKey string) string {
+	return "quota:" + apiKey
+}
+
+// checkRoomQuota returns ErrQuotaExceeded when the rooms created with apiKey reached its max_rooms quota
+func checkRoomQuota(ctx context.Context, store ObjectStore, conf *config.QuotaConfig, apiKey string) error {
+	qs, ok := store.(APIKeyQuotaStore)
+	if !ok || apiKey == "" {
+		return nil
+	}
+	maxRooms := conf.ForKey(apiKey).MaxRooms
+	if maxRooms <= 0 {
+		return nil
+	}
+
+	counts, err := qs.CountAPIKeyRooms(ctx)
+	if err != nil {
+		return err
+	}
+	if counts[apiKey] >= maxRooms {
+		logger.Infow("room quota reached", "apiKey", apiKey, "quota", maxRooms)
+		prometheus.RecordAPIKeyQuotaExceeded(apiKey, quotaRooms)
+		return ErrQuotaExceeded
+	}
+	return nil
+}
+
+// checkParticipantMinutesQuota returns ErrQuotaExceeded when apiKey used up its participant minutes of the month
+func checkParticipantMinutesQuota(ctx context.Context, store ServiceStore, conf *config.QuotaConfig, apiKey string) error {
+	qs, ok := store.(APIKeyQuotaStore)
+	if !ok || apiKey == "" {
+		return nil
+	}
+	maxMinutes := conf.ForKey(apiKey).MonthlyParticipantMinutes
+	if maxMinutes <= 0 {
+		return nil
+	}
+
+	minutes, err := qs.LoadParticipantMinutes(ctx, quotaMonth(time.Now()))
+	if err != nil {
+		return err
+	}
+	if minutes[apiKey] >= float64(maxMinutes) {
+		logger.Infow("participant minutes quota reached", "apiKey", apiKey, "quota", maxMinutes)
+		prometheus.RecordAPIKeyQuotaExceeded(apiKey, quotaMonthlyParticipantMinutes)
+		return ErrQuotaExceeded
+	}
+	return nil
+}
+
+// APIKeyQuotaLimits are the quotas of an API key, 0 is unlimited
+type APIKeyQuotaLimits struct {
+	MaxRooms                  int    `json:"max_rooms"`
+	MaxParticipants           int    `json:"max_participants"`
+	MaxEgressBitrate          uint64 `json:"max_egress_bitrate"`
+	MonthlyParticipantMinutes int64  `json:"monthly_participant_minutes"`
+}
+
+// APIKeyUsage is the usage of an API key counted against its quotas
+type APIKeyUsage struct {
+	APIKey string            `json:"api_key"`
+	Quotas APIKeyQuotaLimits `json:"quotas"`
+	// open rooms created with the key, across the cluster
+	Rooms int `json:"rooms"`
+	// participants connected with tokens of the key, across the cluster. Only counted when the key has a
+	// max_participants quota
+	Participants int `json:"participants"`
+	// participant minutes of the current month, across the cluster
+	MonthlyParticipantMinutes float64 `json:"monthly_participant_minutes"`
+	// bitrate subscribers of rooms created with the key demand on this node
+	EgressBitrate int64 `json:"egress_bitrate"`
+}
+
+// APIKeyQuotas accounts the usage of API keys against their quotas. It adds the participant minutes of signal
+// connections on this node to the cluster usage, and shares the egress bitrate quota of keys between the rooms
+// hosted on this node
+type APIKeyQuotas struct {
+	conf        *config.QuotaConfig
+	store       APIKeyQuotaStore
+	roomManager *RoomManager
+
+	lock sync.Mutex
+	// participant minutes per key since the node started, already added to the cluster usage
+	flushed map[string]float64
+	// API keys of the rooms hosted on this node
+	roomAPIKeys map[livekit.RoomName]string
+	// rooms whose egress bitrate is limited by the quota of their key
+	limited       map[livekit.RoomName]bool
+	egressBitrate map[string]int64
+
+	doneChan chan struct{}
+}
+
+// NewAPIKeyQuotas returns nil when the store cannot keep usage
+func NewAPIKeyQuotas(conf *config.Config, store ObjectStore, roomManager *RoomManager) *APIKeyQuotas {
+	qs, ok := store.(APIKeyQuotaStore)
+	if !ok {
+		if conf.Quotas.Enabled() {
+			logger.Warnw("API key quotas are not supported by the store and will not be enforced", nil)
+		}
+		return nil
+	}
+
+	return &APIKeyQuotas{
+		conf:          &conf.Quotas,
+		store:         qs,
+		roomManager:   roomManager,
+		flushed:       make(map[string]float64),
+		roomAPIKeys:   make(map[livekit.RoomName]string),
+		limited:       make(map[livekit.RoomName]bool),
+		egressBitrate: make(map[string]int64),
+		doneChan:      make(chan struct{}),
+	}
+}
+
+func (q *APIKeyQuotas) Start() {
+	if q == nil {
+		return
+	}
+	go q.worker()
+}
+
+func (q *APIKeyQuotas) Stop() {
+	if q == nil {
+		return
+	}
+	close(q.doneChan)
+	q.flushParticipantMinutes()
+}
+
+func (q *APIKeyQuotas) worker() {
+	usageTicker := time.NewTicker(quotaUsageInterval)
+	defer usageTicker.Stop()
+	egressTicker := time.NewTicker(quotaEgressInterval)
+	defer egressTicker.Stop()
+
+	for {
+		select {
+		case <-q.doneChan:
+			return
+		case <-usageTicker.C:
+			q.flushParticipantMinutes()
+			q.reportUsage()
+		case <-egressTicker.C:
+			q.updateEgressBitrate()
+		}
+	}
+}
+
+func (q *APIKeyQuotas) flushParticipantMinutes() {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	minutes := make(map[string]float64)
+	for _, u := range prometheus.GetAPIKeyUsage(0) {
+		if delta := u.ParticipantMinutes - q.flushed[u.APIKey]; delta > 0 {
+			minutes[u.APIKey] = delta
+		}
+	}
+	if len(minutes) == 0 {
+		return
+	}
+
+	if err := q.store.AddParticipantMinutes(context.Background(), quotaMonth(time.Now()), minutes); err != nil {
+		logger.Warnw("could not add participant minutes", err)
+		return
+	}
+	for apiKey, delta := range minutes {
+		q.flushed[apiKey] += delta
+	}
+}
+
+func (q *APIKeyQuotas) reportUsage() {
+	usage, err := q.GetUsage(context.Background())
+	if err != nil {
+		logger.Warnw("could not load API key usage", err)
+		return
+	}
+	for _, u := range usage {
+		prometheus.RecordAPIKeyQuotaUsage(u.APIKey, quotaRooms, float64(u.Rooms))
+		prometheus.RecordAPIKeyQuotaUsage(u.APIKey, quotaParticipants, float64(u.Participants))
+		prometheus.RecordAPIKeyQuotaUsage(u.APIKey, quotaMonthlyParticipantMinutes, u.MonthlyParticipantMinutes)
+	}
+}
+
+// updateEgressBitrate shares the egress bitrate quota of each key between its rooms on this node by their demand,
+// rooms of keys within their quota are limited by room.max_egress_bitrate only
+func (q *APIKeyQuotas) updateEgressBitrate() {
+	q.roomManager.lock.RLock()
+	rooms := make([]*rtc.Room, 0, len(q.roomManager.rooms))
+	for _, room := range q.roomManager.rooms {
+		rooms = append(rooms, room)
+	}
+	q.roomManager.lock.RUnlock()
+
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	hosted := make(map[livekit.RoomName]bool, len(rooms))
+	demands := make(map[string]map[livekit.RoomName]int64)
+	byName := make(map[livekit.RoomName]*rtc.Room, len(rooms))
+	for _, room := range rooms {
+		if room.IsClosed() {
+			continue
+		}
+		hosted[room.Name()] = true
+		apiKey, ok := q.roomAPIKeys[room.Name()]
+		if !ok {
+			var err error
+			if apiKey, err = q.store.LoadRoomAPIKey(context.Background(), room.Name()); err != nil {
+				continue
+			}
+			q.roomAPIKeys[room.Name()] = apiKey
+		}
+		if apiKey == "" {
+			continue
+		}
+		if demands[apiKey] == nil {
+			demands[apiKey] = make(map[livekit.RoomName]int64)
+		}
+		demands[apiKey][room.Name()] = room.GetSubscriberBandwidthDemand()
+		byName[room.Name()] = room
+	}
+	for roomName := range q.roomAPIKeys {
+		if !hosted[roomName] {
+			delete(q.roomAPIKeys, roomName)
+			delete(q.limited, roomName)
+		}
+	}
+
+	roomLimit := int64(q.roomManager.liveConfig().Room.MaxEgressBitrate)
+	egressBitrate := make(map[string]int64, len(demands))
+	for apiKey, roomDemands := range demands {
+		total := int64(0)
+		for _, demand := range roomDemands {
+			total += demand
+		}
+		egressBitrate[apiKey] = total
+		prometheus.RecordAPIKeyEgressBitrate(apiKey, total)
+
+		var shares map[livekit.RoomName]int64
+		if maxBitrate := int64(q.conf.ForKey(apiKey).MaxEgressBitrate); maxBitrate > 0 && total > maxBitrate {
+			shares = rtc.FairShareBandwidth(maxBitrate, roomDemands)
+		}
+		for roomName := range roomDemands {
+			share, ok := shares[roomName]
+			if !ok {
+				if q.limited[roomName] {
+					delete(q.limited, roomName)
+					byName[roomName].SetMaxEgressBitrate(roomLimit)
+				}
+				continue
+			}
+			if roomLimit > 0 && roomLimit < share {
+				share = roomLimit
+			}
+			q.limited[roomName] = true
+			byName[roomName].SetMaxEgressBitrate(share)
+		}
+	}
+	for apiKey := range q.egressBitrate {
+		if _, ok := egressBitrate[apiKey]; !ok {
+			prometheus.RecordAPIKeyEgressBitrate(apiKey, 0)
+		}
+	}
+	q.egressBitrate = egressBitrate
+}
+
+// GetUsage returns the usage of the keys that have rooms, participant minutes this month or quotas
+func (q *APIKeyQuotas) GetUsage(ctx context.Context) ([]*APIKeyUsage, error) {
+	rooms, err := q.store.CountAPIKeyRooms(ctx)
+	if err != nil {
+		return nil, err
+	}
+	minutes, err := q.store.LoadParticipantMinutes(ctx, quotaMonth(time.Now()))
+	if err != nil {
+		return nil, err
+	}
+
+	apiKeys := make(map[string]bool)
+	for apiKey := range rooms {
+		apiKeys[apiKey] = true
+	}
+	for apiKey := range minutes {
+		apiKeys[apiKey] = true
+	}
+	for apiKey := range q.conf.Keys {
+		apiKeys[apiKey] = true
+	}
+	q.lock.Lock()
+	for apiKey := range q.egressBitrate {
+		apiKeys[apiKey] = true
+	}
+	q.lock.Unlock()
+
+	usage := make([]*APIKeyUsage, 0, len(apiKeys))
+	for apiKey := range apiKeys {
+		u, err := q.getKeyUsage(ctx, apiKey, rooms, minutes)
+		if err != nil {
+			return nil, err
+		}
+		usage = append(usage, u)
+	}
+	sort.Slice(usage, func(i, j int) bool {
+		return usage[i].APIKey < usage[j].APIKey
+	})
+	return usage, nil
+}
+
+func (q *APIKeyQuotas) getKeyUsage(ctx context.Context, apiKey string, rooms map[string]int, minutes map[string]float64) (*APIKeyUsage, error) {
+	quotas := q.conf.ForKey(apiKey)
+	u := &APIKeyUsage{
+		APIKey: apiKey,
+		Quotas: APIKeyQuotaLimits{
+			MaxRooms:                  quotas.MaxRooms,
+			MaxParticipants:           quotas.MaxParticipants,
+			MaxEgressBitrate:          quotas.MaxEgressBitrate,
+			MonthlyParticipantMinutes: quotas.MonthlyParticipantMinutes,
+		},
+		Rooms:                     rooms[apiKey],
+		MonthlyParticipantMinutes: minutes[apiKey],
+	}
+	if ls, ok := q.store.(SessionLimitStore); ok && u.Quotas.MaxParticipants > 0 {
+		var err error
+		if u.Participants, err = ls.CountSessions(ctx, quotaParticipantsKey(apiKey)); err != nil {
+			return nil, err
+		}
+	}
+	q.lock.Lock()
+	u.EgressBitrate = q.egressBitrate[apiKey]
+	q.lock.Unlock()
+	return u, nil
+}
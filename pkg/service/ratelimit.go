@@ -0,0 +1,85 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"sync"
+	"time"
+)
+
+// IPRateLimiter allows a number of requests per client IP within a fixed window
+type IPRateLimiter struct {
+	limit  int
+	window time.Duration
+
+	lock      sync.Mutex
+	windows   map[string]*rateWindow
+	lastPrune time.Time
+}
+
+type rateWindow struct {
+	start    time.Time
+	requests int
+}
+
+// NewIPRateLimiter returns nil when limit is 0, a nil *IPRateLimiter allows every request
+func NewIPRateLimiter(limit int, window time.Duration) *IPRateLimiter {
+	if limit <= 0 {
+		return nil
+	}
+	return &IPRateLimiter{
+		limit:   limit,
+		window:  window,
+		windows: make(map[string]*rateWindow),
+	}
+}
+
+// Allow counts a request from ip, returning false with the time until the next window when the limit
+// is exceeded
+func (l *IPRateLimiter) Allow(ip string) (bool, time.Duration) {
+	if l == nil {
+		return true, 0
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	now := time.Now()
+	l.pruneLocked(now)
+
+	w := l.windows[ip]
+	if w == nil || now.Sub(w.start) >= l.window {
+		w = &rateWindow{start: now}
+		l.windows[ip] = w
+	}
+	if w.requests >= l.limit {
+		return false, w.start.Add(l.window).Sub(now)
+	}
+	w.requests++
+	return true, 0
+}
+
+func (l *IPRateLimiter) pruneLocked(now time.Time) {
+	if now.Sub(l.lastPrune) < l.window {
+		return
+	}
+	l.lastPrune = now
+
+	for ip, w := range l.windows {
+		if now.Sub(w.start) >= l.window {
+			delete(l.windows, ip)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"encoding/json"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/logger"
+
+	"github.com/livekit/livekit-server/pkg/config"
+)
+
+const (
+	// topic of the data packet sending the reconnect policy to participants once they are connected
+	ReconnectPolicyTopic = "lk.reconnect_policy"
+	// the reconnect policy is also set on signal connection and validation responses
+	reconnectPolicyHeader = "X-LiveKit-Reconnect-Policy"
+)
+
+type reconnectPolicy struct {
+	InitialDelayMs int64   `json:"initial_delay_ms"`
+	MaxDelayMs     int64   `json:"max_delay_ms"`
+	Multiplier     float64 `json:"multiplier"`
+	MaxAttempts    int     `json:"max_attempts"`
+	Jitter         float64 `json:"jitter"`
+}
+
+// marshalReconnectPolicy returns nil when no policy is configured
+func marshalReconnectPolicy(conf *config.ReconnectPolicyConfig) []byte {
+	if conf.InitialDelay <= 0 {
+		return nil
+	}
+	policy, err := json.Marshal(&reconnectPolicy{
+		InitialDelayMs: conf.InitialDelay.Milliseconds(),
+		MaxDelayMs:     conf.MaxDelay.Milliseconds(),
+		Multiplier:     conf.Multiplier,
+		MaxAttempts:    conf.MaxAttempts,
+		Jitter:         conf.Jitter,
+	})
+	if err != nil {
+		logger.Errorw("could not marshal reconnect policy", err)
+		return nil
+	}
+	return policy
+}
+
+// reconnectPolicyPacket returns the data packet announcing the policy, or nil when none is configured
+func reconnectPolicyPacket(conf *config.ReconnectPolicyConfig) *livekit.UserPacket {
+	policy := marshalReconnectPolicy(conf)
+	if policy == nil {
+		return nil
+	}
+	topic := ReconnectPolicyTopic
+	return &livekit.UserPacket{
+		Payload: policy,
+		Topic:   &topic,
+	}
+}
+
+// rejectRateLimitedSignal refuses signal connections of client IPs over the rate limit, telling them to
+// retry once the window passed, spread out by the jitter of the policy
+func rejectRateLimitedSignal(w http.ResponseWriter, r *http.Request, limiter *IPRateLimiter, conf *config.ReconnectPolicyConfig) bool {
+	clientIP := GetClientIP(r)
+	allowed, retryAfter := limiter.Allow(clientIP)
+	if allowed {
+		return false
+	}
+
+	if conf.Jitter > 0 {
+		retryAfter += time.Duration(rand.Float64() * conf.Jitter * float64(conf.RateWindow))
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	handleError(w, http.StatusTooManyRequests, ErrSignalRateLimited, "clientIP", clientIP)
+	return true
+}
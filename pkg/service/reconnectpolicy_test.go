@@ -0,0 +1,75 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/livekit-server/pkg/config"
+)
+
+func TestReconnectPolicyPacket(t *testing.T) {
+	conf := config.DefaultConfig.Reconnect
+	require.Nil(t, reconnectPolicyPacket(&conf))
+
+	conf.InitialDelay = 500 * time.Millisecond
+	up := reconnectPolicyPacket(&conf)
+	require.NotNil(t, up)
+	require.Equal(t, ReconnectPolicyTopic, up.GetTopic())
+
+	var policy reconnectPolicy
+	require.NoError(t, json.Unmarshal(up.Payload, &policy))
+	require.Equal(t, reconnectPolicy{
+		InitialDelayMs: 500,
+		MaxDelayMs:     30000,
+		Multiplier:     2,
+		MaxAttempts:    10,
+		Jitter:         0.5,
+	}, policy)
+}
+
+func TestRejectRateLimitedSignal(t *testing.T) {
+	conf := config.ReconnectPolicyConfig{
+		Jitter:     0.5,
+		RateLimit:  1,
+		RateWindow: 10 * time.Second,
+	}
+	limiter := NewIPRateLimiter(conf.RateLimit, conf.RateWindow)
+	connect := func() *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/rtc", nil)
+		r.RemoteAddr = "10.0.0.1:1234"
+		w := httptest.NewRecorder()
+		if !rejectRateLimitedSignal(w, r, limiter, &conf) {
+			w.WriteHeader(http.StatusOK)
+		}
+		return w
+	}
+
+	require.Equal(t, http.StatusOK, connect().Code)
+	w := connect()
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+	retryAfter, err := strconv.Atoi(w.Header().Get("Retry-After"))
+	require.NoError(t, err)
+	// rest of the window, plus up to half of it as jitter
+	require.GreaterOrEqual(t, retryAfter, 9)
+	require.LessOrEqual(t, retryAfter, 15)
+}
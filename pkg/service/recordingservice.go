@@ -0,0 +1,171 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/recording"
+	"github.com/livekit/protocol/livekit"
+)
+
+const (
+	recordingsPath         = "/recordings"
+	recordingsDownloadPath = "/recordings/download/"
+)
+
+type recordingRequest struct {
+	Room        string `json:"room,omitempty"`
+	RecordingID string `json:"recording_id,omitempty"`
+}
+
+type listRecordingsResponse struct {
+	Recordings []*recording.Info `json:"recordings"`
+}
+
+// RecordingService exposes the built-in recorder over HTTP. Recordings can only be started
+// for rooms hosted on this node.
+type RecordingService struct {
+	conf        config.RecordingConfig
+	manager     *recording.Manager
+	roomManager *RoomManager
+}
+
+func NewRecordingService(conf *config.Config, manager *recording.Manager, roomManager *RoomManager) *RecordingService {
+	return &RecordingService{
+		conf:        conf.Recording,
+		manager:     manager,
+		roomManager: roomManager,
+	}
+}
+
+func (s *RecordingService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.manager == nil {
+		handleError(w, http.StatusNotFound, ErrRecordingDisabled)
+		return
+	}
+	if err := EnsureRecordPermission(r.Context()); err != nil {
+		handleError(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	if strings.HasPrefix(r.URL.Path, recordingsDownloadPath) {
+		s.download(w, r)
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == recordingsPath:
+		s.list(w, r)
+	case r.Method == http.MethodPost && strings.HasPrefix(r.URL.Path, recordingsPath+"/"):
+		s.control(w, r, strings.TrimPrefix(r.URL.Path, recordingsPath+"/"))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (s *RecordingService) control(w http.ResponseWriter, r *http.Request, action string) {
+	var req recordingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		handleError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var info *recording.Info
+	var err error
+	switch action {
+	case "start":
+		room := s.roomManager.GetRoom(r.Context(), livekit.RoomName(req.Room))
+		if room == nil {
+			handleError(w, http.StatusNotFound, ErrRoomNotFound, "room", req.Room)
+			return
+		}
+		info, err = s.manager.Start(r.Context(), room)
+	case "pause":
+		info, err = s.manager.Pause(r.Context(), req.RecordingID)
+	case "resume":
+		info, err = s.manager.Resume(r.Context(), req.RecordingID)
+	case "stop":
+		info, err = s.manager.Stop(r.Context(), req.RecordingID)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	switch {
+	case errors.Is(err, recording.ErrRecordingNotFound):
+		handleError(w, http.StatusNotFound, err, "recordingID", req.RecordingID)
+	case errors.Is(err, recording.ErrAlreadyRecording), errors.Is(err, recording.ErrInvalidState):
+		handleError(w, http.StatusConflict, err, "room", req.Room, "recordingID", req.RecordingID)
+	case err != nil:
+		handleError(w, http.StatusInternalServerError, err)
+	default:
+		s.writeJSON(w, s.withDownloadURLs(info))
+	}
+}
+
+func (s *RecordingService) list(w http.ResponseWriter, r *http.Request) {
+	infos, err := s.manager.List(livekit.RoomName(r.URL.Query().Get("room")))
+	if err != nil {
+		handleError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	res := &listRecordingsResponse{Recordings: make([]*recording.Info, 0, len(infos))}
+	for _, info := range infos {
+		res.Recordings = append(res.Recordings, s.withDownloadURLs(info))
+	}
+	s.writeJSON(w, res)
+}
+
+func (s *RecordingService) download(w http.ResponseWriter, r *http.Request) {
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, recordingsDownloadPath), "/", 2)
+	if len(parts) != 2 {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	path, err := s.manager.FilePath(parts[0], parts[1])
+	if err != nil {
+		handleError(w, http.StatusNotFound, err)
+		return
+	}
+	w.Header().Set("Content-Disposition", "attachment; filename=\""+parts[1]+"\"")
+	http.ServeFile(w, r, path)
+}
+
+func (s *RecordingService) withDownloadURLs(info *recording.Info) *recording.Info {
+	base := strings.TrimSuffix(s.conf.DownloadBaseURL, "/")
+	if base == "" {
+		base = strings.TrimSuffix(recordingsDownloadPath, "/")
+	}
+	for _, f := range info.Files {
+		if f.Location != "" {
+			f.DownloadURL = f.Location
+			continue
+		}
+		f.DownloadURL = base + "/" + info.ID + "/" + f.Filename
+	}
+	info.ManifestURL = base + "/" + info.ID + "/" + recording.ManifestFilename
+	return info
+}
+
+func (s *RecordingService) writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
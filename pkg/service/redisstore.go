@@ -16,9 +16,11 @@ package service
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	goversion "github.com/hashicorp/go-version"
@@ -26,6 +28,8 @@ import (
 	"github.com/redis/go-redis/v9"
 	"google.golang.org/protobuf/proto"
 
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/webhooks"
 	"github.com/livekit/livekit-server/version"
 	"github.com/livekit/protocol/ingress"
 	"github.com/livekit/protocol/livekit"
@@ -39,6 +43,25 @@ const (
 	// RoomsKey is hash of room_name => Room proto
 	RoomsKey        = "rooms"
 	RoomInternalKey = "room_internal"
+	// RoomTemplatesKey is hash of room_name => name of the template the room was created with
+	RoomTemplatesKey = "room_templates"
+	// RoomSealsKey is hash of room_name => RoomSeal json
+	RoomSealsKey = "room_seals"
+	// RoomClosuresKey is hash of room_name => RoomClosure json
+	RoomClosuresKey = "room_closures"
+	// RoomRegionsKey is hash of room_name => json list of the regions the room is pinned to
+	RoomRegionsKey = "room_regions"
+	// ScheduledRoomsKey is hash of room_name => ScheduledRoom json
+	ScheduledRoomsKey = "scheduled_rooms"
+	// APIKeyRoomsKey is hash of room_name => API key the room was created with
+	APIKeyRoomsKey = "api_key_rooms"
+	// APIKeyParticipantMinutesPrefix is a hash of API key => participant minutes, per month
+	APIKeyParticipantMinutesPrefix = "api_key_participant_minutes:"
+
+	// WebhookDeadLettersKey is hash of delivery id => webhooks.Delivery json, WebhookDeadLetterOrderKey is a
+	// sorted set of delivery id => failure time in unix seconds
+	WebhookDeadLettersKey     = "{webhook_dead_letters}"
+	WebhookDeadLetterOrderKey = "{webhook_dead_letters}_order"
 
 	// EgressKey is a hash of egressID => egress info
 	EgressKey        = "egress"
@@ -51,20 +74,34 @@ const (
 	IngressStatePrefix = "{ingress}_state:"
 	RoomIngressPrefix  = "room_{ingress}:"
 
+	// IOWorkersPrefix is a hash of workerID => IOWorker json, per worker kind
+	IOWorkersPrefix = "io_workers:"
+
 	// RoomParticipantsPrefix is hash of participant_name => ParticipantInfo
 	RoomParticipantsPrefix = "room_participants:"
 
+	// ResumeTokenPrefix is a simple key containing ResumeToken json, per participant sid
+	ResumeTokenPrefix = "resume_token:"
+
 	// RoomLockPrefix is a simple key containing a provided lock uid
 	RoomLockPrefix = "room_lock:"
 
+	// SessionSlotsPrefix is a sorted set of slot => expiry in unix ms, SessionOwnersPrefix is a hash of
+	// slot => session id, per session limit key
+	SessionSlotsPrefix  = "session_slots:"
+	SessionOwnersPrefix = "session_owners:"
+
 	maxRetries = 5
 )
 
 type RedisStore struct {
-	rc           redis.UniversalClient
-	unlockScript *redis.Script
-	ctx          context.Context
-	done         chan struct{}
+	rc                   redis.UniversalClient
+	unlockScript         *redis.Script
+	acquireSessionScript *redis.Script
+	refreshSessionScript *redis.Script
+	releaseSessionScript *redis.Script
+	ctx                  context.Context
+	done                 chan struct{}
 }
 
 func NewRedisStore(rc redis.UniversalClient) *RedisStore {
@@ -73,10 +110,44 @@ func NewRedisStore(rc redis.UniversalClient) *RedisStore {
 					 else return 0 
 					 end`
 
+	// KEYS: slots, owners. ARGV: slot, session id, limit, now, expiry, ttl in ms
+	acquireSessionScript := `local expired = redis.call("zrangebyscore", KEYS[1], "-inf", ARGV[4])
+					 if #expired > 0 then
+						redis.call("zremrangebyscore", KEYS[1], "-inf", ARGV[4])
+						redis.call("hdel", KEYS[2], unpack(expired))
+					 end
+					 if not redis.call("zscore", KEYS[1], ARGV[1]) and redis.call("zcard", KEYS[1]) >= tonumber(ARGV[3]) then
+						return 0
+					 end
+					 redis.call("zadd", KEYS[1], ARGV[5], ARGV[1])
+					 redis.call("hset", KEYS[2], ARGV[1], ARGV[2])
+					 redis.call("pexpire", KEYS[1], ARGV[6])
+					 redis.call("pexpire", KEYS[2], ARGV[6])
+					 return 1`
+
+	// KEYS: slots, owners. ARGV: slot, session id, expiry, ttl in ms
+	refreshSessionScript := `if redis.call("hget", KEYS[2], ARGV[1]) == ARGV[2] then
+						redis.call("zadd", KEYS[1], ARGV[3], ARGV[1])
+						redis.call("pexpire", KEYS[1], ARGV[4])
+						redis.call("pexpire", KEYS[2], ARGV[4])
+						return 1
+					 else return 0
+					 end`
+
+	// KEYS: slots, owners. ARGV: slot, session id
+	releaseSessionScript := `if redis.call("hget", KEYS[2], ARGV[1]) == ARGV[2] then
+						redis.call("zrem", KEYS[1], ARGV[1])
+						return redis.call("hdel", KEYS[2], ARGV[1])
+					 else return 0
+					 end`
+
 	return &RedisStore{
-		ctx:          context.Background(),
-		rc:           rc,
-		unlockScript: redis.NewScript(unlockScript),
+		ctx:                  context.Background(),
+		rc:                   rc,
+		unlockScript:         redis.NewScript(unlockScript),
+		acquireSessionScript: redis.NewScript(acquireSessionScript),
+		refreshSessionScript: redis.NewScript(refreshSessionScript),
+		releaseSessionScript: redis.NewScript(releaseSessionScript),
 	}
 }
 
@@ -229,12 +300,414 @@ func (s *RedisStore) DeleteRoom(ctx context.Context, roomName livekit.RoomName)
 	pp := s.rc.Pipeline()
 	pp.HDel(s.ctx, RoomsKey, string(roomName))
 	pp.HDel(s.ctx, RoomInternalKey, string(roomName))
+	pp.HDel(s.ctx, RoomTemplatesKey, string(roomName))
+	pp.HDel(s.ctx, RoomSealsKey, string(roomName))
+	pp.HDel(s.ctx, RoomClosuresKey, string(roomName))
+	pp.HDel(s.ctx, RoomRegionsKey, string(roomName))
+	pp.HDel(s.ctx, APIKeyRoomsKey, string(roomName))
 	pp.Del(s.ctx, RoomParticipantsPrefix+string(roomName))
 
 	_, err = pp.Exec(s.ctx)
 	return err
 }
 
+func (s *RedisStore) StoreRoomTemplate(_ context.Context, roomName livekit.RoomName, template string) error {
+	if template == "" {
+		return s.rc.HDel(s.ctx, RoomTemplatesKey, string(roomName)).Err()
+	}
+	return s.rc.HSet(s.ctx, RoomTemplatesKey, string(roomName), template).Err()
+}
+
+func (s *RedisStore) LoadRoomTemplate(_ context.Context, roomName livekit.RoomName) (string, error) {
+	template, err := s.rc.HGet(s.ctx, RoomTemplatesKey, string(roomName)).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	return template, err
+}
+
+func (s *RedisStore) StoreRoomRegions(_ context.Context, roomName livekit.RoomName, regions []string) error {
+	if len(regions) == 0 {
+		return s.rc.HDel(s.ctx, RoomRegionsKey, string(roomName)).Err()
+	}
+	data, err := json.Marshal(regions)
+	if err != nil {
+		return err
+	}
+	return s.rc.HSet(s.ctx, RoomRegionsKey, string(roomName), data).Err()
+}
+
+func (s *RedisStore) LoadRoomRegions(_ context.Context, roomName livekit.RoomName) ([]string, error) {
+	data, err := s.rc.HGet(s.ctx, RoomRegionsKey, string(roomName)).Result()
+	if err == redis.Nil {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var regions []string
+	if err = json.Unmarshal([]byte(data), &regions); err != nil {
+		return nil, err
+	}
+	return regions, nil
+}
+
+func (s *RedisStore) StoreRoomSeal(_ context.Context, seal *RoomSeal) error {
+	data, err := json.Marshal(seal)
+	if err != nil {
+		return err
+	}
+	return s.rc.HSet(s.ctx, RoomSealsKey, string(seal.Room), data).Err()
+}
+
+func (s *RedisStore) LoadRoomSeal(_ context.Context, roomName livekit.RoomName) (*RoomSeal, error) {
+	data, err := s.rc.HGet(s.ctx, RoomSealsKey, string(roomName)).Result()
+	if err == redis.Nil {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	seal := &RoomSeal{}
+	if err = json.Unmarshal([]byte(data), seal); err != nil {
+		return nil, err
+	}
+	return seal, nil
+}
+
+func (s *RedisStore) DeleteRoomSeal(_ context.Context, roomName livekit.RoomName) error {
+	return s.rc.HDel(s.ctx, RoomSealsKey, string(roomName)).Err()
+}
+
+func (s *RedisStore) StoreRoomClosure(_ context.Context, closure *RoomClosure) error {
+	data, err := json.Marshal(closure)
+	if err != nil {
+		return err
+	}
+	return s.rc.HSet(s.ctx, RoomClosuresKey, string(closure.Room), data).Err()
+}
+
+func (s *RedisStore) LoadRoomClosure(_ context.Context, roomName livekit.RoomName) (*RoomClosure, error) {
+	data, err := s.rc.HGet(s.ctx, RoomClosuresKey, string(roomName)).Result()
+	if err == redis.Nil {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	closure := &RoomClosure{}
+	if err = json.Unmarshal([]byte(data), closure); err != nil {
+		return nil, err
+	}
+	return closure, nil
+}
+
+func (s *RedisStore) DeleteRoomClosure(_ context.Context, roomName livekit.RoomName) error {
+	return s.rc.HDel(s.ctx, RoomClosuresKey, string(roomName)).Err()
+}
+
+func (s *RedisStore) StoreScheduledRoom(_ context.Context, sr *ScheduledRoom) error {
+	data, err := json.Marshal(sr)
+	if err != nil {
+		return err
+	}
+	return s.rc.HSet(s.ctx, ScheduledRoomsKey, sr.Room, data).Err()
+}
+
+func (s *RedisStore) LoadScheduledRoom(_ context.Context, roomName livekit.RoomName) (*ScheduledRoom, error) {
+	data, err := s.rc.HGet(s.ctx, ScheduledRoomsKey, string(roomName)).Result()
+	if err == redis.Nil {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	sr := &ScheduledRoom{}
+	if err = json.Unmarshal([]byte(data), sr); err != nil {
+		return nil, err
+	}
+	return sr, nil
+}
+
+func (s *RedisStore) ListScheduledRooms(_ context.Context) ([]*ScheduledRoom, error) {
+	items, err := s.rc.HVals(s.ctx, ScheduledRoomsKey).Result()
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	scheduled := make([]*ScheduledRoom, 0, len(items))
+	for _, item := range items {
+		sr := &ScheduledRoom{}
+		if err = json.Unmarshal([]byte(item), sr); err != nil {
+			return nil, err
+		}
+		scheduled = append(scheduled, sr)
+	}
+	return scheduled, nil
+}
+
+func (s *RedisStore) DeleteScheduledRoom(_ context.Context, roomName livekit.RoomName) error {
+	return s.rc.HDel(s.ctx, ScheduledRoomsKey, string(roomName)).Err()
+}
+
+func (s *RedisStore) StoreWebhookDeadLetter(_ context.Context, d *webhooks.Delivery, maxSize int) error {
+	data, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.rc.TxPipelined(s.ctx, func(p redis.Pipeliner) error {
+		p.HSet(s.ctx, WebhookDeadLettersKey, d.ID, data)
+		p.ZAdd(s.ctx, WebhookDeadLetterOrderKey, redis.Z{Score: float64(d.FailedAt), Member: d.ID})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// the newest maxSize dead letters are kept
+	discarded, err := s.rc.ZRange(s.ctx, WebhookDeadLetterOrderKey, 0, int64(-maxSize-1)).Result()
+	if err != nil || len(discarded) == 0 {
+		return err
+	}
+	_, err = s.rc.TxPipelined(s.ctx, func(p redis.Pipeliner) error {
+		p.HDel(s.ctx, WebhookDeadLettersKey, discarded...)
+		p.ZRem(s.ctx, WebhookDeadLetterOrderKey, discarded)
+		return nil
+	})
+	return err
+}
+
+func (s *RedisStore) LoadWebhookDeadLetter(_ context.Context, id string) (*webhooks.Delivery, error) {
+	data, err := s.rc.HGet(s.ctx, WebhookDeadLettersKey, id).Result()
+	if err == redis.Nil {
+		return nil, webhooks.ErrDeadLetterNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	d := &webhooks.Delivery{}
+	if err = json.Unmarshal([]byte(data), d); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (s *RedisStore) ListWebhookDeadLetters(_ context.Context) ([]*webhooks.Delivery, error) {
+	items, err := s.rc.HVals(s.ctx, WebhookDeadLettersKey).Result()
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	deliveries := make([]*webhooks.Delivery, 0, len(items))
+	for _, item := range items {
+		d := &webhooks.Delivery{}
+		if err = json.Unmarshal([]byte(item), d); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, nil
+}
+
+func (s *RedisStore) DeleteWebhookDeadLetter(_ context.Context, id string) error {
+	_, err := s.rc.TxPipelined(s.ctx, func(p redis.Pipeliner) error {
+		p.HDel(s.ctx, WebhookDeadLettersKey, id)
+		p.ZRem(s.ctx, WebhookDeadLetterOrderKey, id)
+		return nil
+	})
+	return err
+}
+
+// sessionLimitKeys returns the keys of a session limit, hash tagged so that they are in the same cluster slot
+func sessionLimitKeys(key string) []string {
+	tagged := "{" + key + "}"
+	return []string{SessionSlotsPrefix + tagged, SessionOwnersPrefix + tagged}
+}
+
+func (s *RedisStore) AcquireSession(_ context.Context, key string, slot string, sessionID string, limit int, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	res, err := s.acquireSessionScript.Run(s.ctx, s.rc, sessionLimitKeys(key),
+		slot, sessionID, limit, now.UnixMilli(), now.Add(ttl).UnixMilli(), ttl.Milliseconds(),
+	).Int()
+	if err != nil {
+		return false, err
+	}
+	return res == 1, nil
+}
+
+func (s *RedisStore) RefreshSession(_ context.Context, key string, slot string, sessionID string, ttl time.Duration) error {
+	return s.refreshSessionScript.Run(s.ctx, s.rc, sessionLimitKeys(key),
+		slot, sessionID, time.Now().Add(ttl).UnixMilli(), ttl.Milliseconds(),
+	).Err()
+}
+
+func (s *RedisStore) ReleaseSession(_ context.Context, key string, slot string, sessionID string) error {
+	return s.releaseSessionScript.Run(s.ctx, s.rc, sessionLimitKeys(key), slot, sessionID).Err()
+}
+
+func (s *RedisStore) CountSessions(_ context.Context, key string) (int, error) {
+	n, err := s.rc.ZCount(s.ctx, sessionLimitKeys(key)[0], strconv.FormatInt(time.Now().UnixMilli(), 10), "+inf").Result()
+	return int(n), err
+}
+
+func (s *RedisStore) StoreRoomAPIKey(_ context.Context, roomName livekit.RoomName, apiKey string) error {
+	return s.rc.HSet(s.ctx, APIKeyRoomsKey, string(roomName), apiKey).Err()
+}
+
+func (s *RedisStore) LoadRoomAPIKey(_ context.Context, roomName livekit.RoomName) (string, error) {
+	apiKey, err := s.rc.HGet(s.ctx, APIKeyRoomsKey, string(roomName)).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	return apiKey, err
+}
+
+func (s *RedisStore) CountAPIKeyRooms(_ context.Context) (map[string]int, error) {
+	items, err := s.rc.HVals(s.ctx, APIKeyRoomsKey).Result()
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int)
+	for _, apiKey := range items {
+		counts[apiKey]++
+	}
+	return counts, nil
+}
+
+func (s *RedisStore) AddParticipantMinutes(_ context.Context, month string, minutes map[string]float64) error {
+	key := APIKeyParticipantMinutesPrefix + month
+	pp := s.rc.Pipeline()
+	for apiKey, m := range minutes {
+		pp.HIncrByFloat(s.ctx, key, apiKey, m)
+	}
+	// kept a while after the month ends for reporting
+	pp.Expire(s.ctx, key, 100*24*time.Hour)
+	_, err := pp.Exec(s.ctx)
+	return err
+}
+
+func (s *RedisStore) LoadParticipantMinutes(_ context.Context, month string) (map[string]float64, error) {
+	items, err := s.rc.HGetAll(s.ctx, APIKeyParticipantMinutesPrefix+month).Result()
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	minutes := make(map[string]float64, len(items))
+	for apiKey, item := range items {
+		if minutes[apiKey], err = strconv.ParseFloat(item, 64); err != nil {
+			return nil, err
+		}
+	}
+	return minutes, nil
+}
+
+// PurgeRoomKeys deletes the keys matching patterns, where {room} is replaced with the room name and * matches any
+// characters, and returns the number of deleted keys. Patterns that could match keys of other rooms are refused
+// before anything is deleted
+func (s *RedisStore) PurgeRoomKeys(ctx context.Context, roomName livekit.RoomName, patterns []string) (int, error) {
+	for _, pattern := range patterns {
+		// SCAN patterns matching beyond the room could delete keys of other rooms
+		if err := config.ValidatePurgeStoreKey(pattern); err != nil {
+			return 0, err
+		}
+	}
+
+	deleted := 0
+	for _, pattern := range patterns {
+		if !strings.Contains(pattern, "*") {
+			n, err := s.rc.Del(ctx, strings.ReplaceAll(pattern, "{room}", string(roomName))).Result()
+			if err != nil {
+				return deleted, err
+			}
+			deleted += int(n)
+			continue
+		}
+
+		parts := strings.Split(pattern, "*")
+		for i, part := range parts {
+			parts[i] = escapeScanPattern(strings.ReplaceAll(part, "{room}", string(roomName)))
+		}
+		match := strings.Join(parts, "*")
+		scan := func(ctx context.Context, c redis.UniversalClient) error {
+			iter := c.Scan(ctx, 0, match, 100).Iterator()
+			for iter.Next(ctx) {
+				// keys are deleted one by one, they may hash to different cluster slots
+				n, err := c.Del(ctx, iter.Val()).Result()
+				if err != nil {
+					return err
+				}
+				deleted += int(n)
+			}
+			return iter.Err()
+		}
+
+		var err error
+		if cc, ok := s.rc.(*redis.ClusterClient); ok {
+			var lock sync.Mutex
+			err = cc.ForEachMaster(ctx, func(ctx context.Context, c *redis.Client) error {
+				lock.Lock()
+				defer lock.Unlock()
+				return scan(ctx, c)
+			})
+		} else {
+			err = scan(ctx, s.rc)
+		}
+		if err != nil {
+			return deleted, err
+		}
+	}
+	return deleted, nil
+}
+
+// escapeScanPattern escapes the glob characters of SCAN patterns
+func escapeScanPattern(s string) string {
+	var b strings.Builder
+	for _, c := range s {
+		switch c {
+		case '*', '?', '[', ']', '\\':
+			b.WriteRune('\\')
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+func (s *RedisStore) StoreResumeToken(_ context.Context, token *ResumeToken, ttl time.Duration) error {
+	stored := *token
+	stored.ExpiresAt = time.Now().Add(ttl).Unix()
+	data, err := json.Marshal(&stored)
+	if err != nil {
+		return err
+	}
+	return s.rc.Set(s.ctx, ResumeTokenPrefix+string(token.ParticipantID), data, ttl).Err()
+}
+
+func (s *RedisStore) RenewResumeToken(_ context.Context, participantID livekit.ParticipantID, ttl time.Duration) error {
+	// the expiry in the stored json is not updated, the key expiring is authoritative
+	return s.rc.Expire(s.ctx, ResumeTokenPrefix+string(participantID), ttl).Err()
+}
+
+func (s *RedisStore) LoadResumeToken(_ context.Context, participantID livekit.ParticipantID) (*ResumeToken, error) {
+	data, err := s.rc.Get(s.ctx, ResumeTokenPrefix+string(participantID)).Result()
+	if err == redis.Nil {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	token := &ResumeToken{}
+	if err = json.Unmarshal([]byte(data), token); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+func (s *RedisStore) DeleteResumeToken(_ context.Context, participantID livekit.ParticipantID) error {
+	return s.rc.Del(s.ctx, ResumeTokenPrefix+string(participantID)).Err()
+}
+
 func (s *RedisStore) LockRoom(_ context.Context, roomName livekit.RoomName, duration time.Duration) (string, error) {
 	token := utils.NewGuid("LOCK")
 	key := RoomLockPrefix + string(roomName)
@@ -812,3 +1285,32 @@ func (s *RedisStore) DeleteIngress(_ context.Context, info *livekit.IngressInfo)
 
 	return nil
 }
+
+func (s *RedisStore) StoreIOWorker(_ context.Context, worker *IOWorker) error {
+	data, err := json.Marshal(worker)
+	if err != nil {
+		return err
+	}
+	return s.rc.HSet(s.ctx, IOWorkersPrefix+string(worker.Kind), worker.ID, data).Err()
+}
+
+func (s *RedisStore) ListIOWorkers(_ context.Context, kind IOWorkerKind) ([]*IOWorker, error) {
+	data, err := s.rc.HGetAll(s.ctx, IOWorkersPrefix+string(kind)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	workers := make([]*IOWorker, 0, len(data))
+	for _, d := range data {
+		worker := &IOWorker{}
+		if err = json.Unmarshal([]byte(d), worker); err != nil {
+			return nil, err
+		}
+		workers = append(workers, worker)
+	}
+	return workers, nil
+}
+
+func (s *RedisStore) DeleteIOWorker(_ context.Context, kind IOWorkerKind, workerID string) error {
+	return s.rc.HDel(s.ctx, IOWorkersPrefix+string(kind), workerID).Err()
+}
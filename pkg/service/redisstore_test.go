@@ -58,6 +58,34 @@ func TestRoomInternal(t *testing.T) {
 	require.NoError(t, rs.DeleteRoom(ctx, "test_room"))
 }
 
+func TestRoomClosureStore(t *testing.T) {
+	ctx := context.Background()
+	rs := service.NewRedisStore(redisClient())
+	roomName := livekit.RoomName("closure_room")
+	require.NoError(t, rs.StoreRoom(ctx, &livekit.Room{Name: string(roomName)}, nil))
+
+	closure, err := rs.LoadRoomClosure(ctx, roomName)
+	require.NoError(t, err)
+	require.Nil(t, closure)
+
+	require.NoError(t, rs.StoreRoomClosure(ctx, &service.RoomClosure{Room: roomName, ClosesAt: 100}))
+	closure, err = rs.LoadRoomClosure(ctx, roomName)
+	require.NoError(t, err)
+	require.EqualValues(t, 100, closure.ClosesAt)
+
+	require.NoError(t, rs.DeleteRoomClosure(ctx, roomName))
+	closure, err = rs.LoadRoomClosure(ctx, roomName)
+	require.NoError(t, err)
+	require.Nil(t, closure)
+
+	// closures go away with the room
+	require.NoError(t, rs.StoreRoomClosure(ctx, &service.RoomClosure{Room: roomName, ClosesAt: 100}))
+	require.NoError(t, rs.DeleteRoom(ctx, roomName))
+	closure, err = rs.LoadRoomClosure(ctx, roomName)
+	require.NoError(t, err)
+	require.Nil(t, closure)
+}
+
 func TestParticipantPersistence(t *testing.T) {
 	ctx := context.Background()
 	rs := service.NewRedisStore(redisClient())
@@ -155,6 +183,79 @@ func TestRoomLock(t *testing.T) {
 	})
 }
 
+func TestPurgeRoomKeys(t *testing.T) {
+	ctx := context.Background()
+	rc := redisClient()
+	rs := service.NewRedisStore(rc)
+
+	roomName := livekit.RoomName("purge*room")
+	keys := []string{"purge_test:purge*room", "purge_test:purge*room:a", "purge_test:purge*room:b"}
+	for _, key := range keys {
+		require.NoError(t, rc.Set(ctx, key, "1", time.Minute).Err())
+	}
+	// the room name is matched literally
+	require.NoError(t, rc.Set(ctx, "purge_test:purge_other_room:a", "1", time.Minute).Err())
+	defer rc.Del(ctx, "purge_test:purge_other_room:a")
+
+	deleted, err := rs.PurgeRoomKeys(ctx, roomName, []string{"purge_test:{room}", "purge_test:{room}:*"})
+	require.NoError(t, err)
+	require.Equal(t, 3, deleted)
+
+	n, err := rc.Exists(ctx, keys...).Result()
+	require.NoError(t, err)
+	require.Zero(t, n)
+	n, err = rc.Exists(ctx, "purge_test:purge_other_room:a").Result()
+	require.NoError(t, err)
+	require.Equal(t, int64(1), n)
+}
+
+func TestPurgeRoomKeysScopedToRoom(t *testing.T) {
+	ctx := context.Background()
+	rc := redisClient()
+	rs := service.NewRedisStore(rc)
+
+	keys := []string{"purge_scope:a", "purge_scope:a:1", "purge_scope:ab", "purge_scope:ab:1"}
+	for _, key := range keys {
+		require.NoError(t, rc.Set(ctx, key, "1", time.Minute).Err())
+	}
+	defer rc.Del(ctx, keys...)
+
+	testCases := []struct {
+		name     string
+		patterns []string
+		deleted  int
+		err      bool
+	}{
+		{name: "wildcard after room", patterns: []string{"purge_scope:{room}*"}, err: true},
+		{name: "wildcard before room", patterns: []string{"purge_scope:*{room}"}, err: true},
+		{name: "no literal prefix", patterns: []string{"*:{room}:*"}, err: true},
+		{name: "no room", patterns: []string{"purge_scope:*"}, err: true},
+		// nothing is deleted if any pattern is refused
+		{name: "one refused", patterns: []string{"purge_scope:{room}", "{room}:*"}, err: true},
+		{name: "scoped", patterns: []string{"purge_scope:{room}", "purge_scope:{room}:*"}, deleted: 2},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			deleted, err := rs.PurgeRoomKeys(ctx, "a", tc.patterns)
+			if tc.err {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tc.deleted, deleted)
+
+			// keys of room "ab" are never purged
+			n, err := rc.Exists(ctx, "purge_scope:ab", "purge_scope:ab:1").Result()
+			require.NoError(t, err)
+			require.Equal(t, int64(2), n)
+		})
+	}
+
+	n, err := rc.Exists(ctx, "purge_scope:a", "purge_scope:a:1").Result()
+	require.NoError(t, err)
+	require.Zero(t, n)
+}
+
 func TestEgressStore(t *testing.T) {
 	ctx := context.Background()
 	rc := redisClient()
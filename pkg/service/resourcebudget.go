@@ -0,0 +1,135 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"time"
+
+	"go.uber.org/atomic"
+
+	"github.com/livekit/protocol/logger"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/telemetry/prometheus"
+)
+
+const (
+	budgetGoroutines = "goroutines"
+	budgetFDs        = "fds"
+)
+
+type budgetCheck struct {
+	resource string
+	usage    float64
+	limit    float64
+}
+
+// ResourceBudget samples the resources held by the node and compares them with the soft limits of
+// resource_budget, warning when one is exceeded and, if configured, refusing new participants until usage is
+// back under the limits
+type ResourceBudget struct {
+	conf *config.ResourceBudgetConfig
+
+	// resources above their limit, only accessed by the worker
+	exceeded  map[string]bool
+	refusing  atomic.Bool
+	closeChan chan struct{}
+}
+
+func NewResourceBudget(conf *config.ResourceBudgetConfig) *ResourceBudget {
+	return &ResourceBudget{
+		conf:      conf,
+		exceeded:  make(map[string]bool),
+		closeChan: make(chan struct{}),
+	}
+}
+
+func (b *ResourceBudget) Start() {
+	go b.worker()
+}
+
+func (b *ResourceBudget) Stop() {
+	close(b.closeChan)
+}
+
+// IsRefusingJoins is true while new participants are refused
+func (b *ResourceBudget) IsRefusingJoins() bool {
+	if b == nil {
+		return false
+	}
+	return b.refusing.Load()
+}
+
+func (b *ResourceBudget) worker() {
+	ticker := time.NewTicker(b.conf.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		b.check()
+
+		select {
+		case <-b.closeChan:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (b *ResourceBudget) check() {
+	refuse := false
+	for _, c := range budgetChecks(b.conf, prometheus.GetResourceUsage()) {
+		exceeded := c.limit > 0 && c.usage > c.limit
+		if exceeded && b.conf.RefuseJoins {
+			refuse = true
+		}
+		if exceeded == b.exceeded[c.resource] {
+			continue
+		}
+
+		b.exceeded[c.resource] = exceeded
+		prometheus.RecordBudgetExceeded(c.resource, exceeded)
+		if exceeded {
+			logger.Warnw("resource budget exceeded, the node may be leaking", nil,
+				"resource", c.resource,
+				"usage", c.usage,
+				"limit", c.limit,
+				"refuseJoins", b.conf.RefuseJoins,
+			)
+		} else {
+			logger.Infow("resource usage back within budget", "resource", c.resource, "usage", c.usage, "limit", c.limit)
+		}
+	}
+
+	if b.refusing.Swap(refuse) != refuse && refuse {
+		logger.Warnw("refusing new participants until resource usage is back within budget", nil)
+	}
+}
+
+// budgetChecks pairs the sampled usage of each resource with its limit
+func budgetChecks(conf *config.ResourceBudgetConfig, usage prometheus.ResourceUsage) []budgetCheck {
+	checks := []budgetCheck{
+		{budgetGoroutines, float64(usage.Goroutines), float64(conf.MaxGoroutines)},
+		{prometheus.ObjectRooms, float64(usage.Objects[prometheus.ObjectRooms]), float64(conf.MaxObjects.Rooms)},
+		{prometheus.ObjectParticipants, float64(usage.Objects[prometheus.ObjectParticipants]), float64(conf.MaxObjects.Participants)},
+		{prometheus.ObjectPublishedTracks, float64(usage.Objects[prometheus.ObjectPublishedTracks]), float64(conf.MaxObjects.PublishedTracks)},
+		{prometheus.ObjectSubscribedTracks, float64(usage.Objects[prometheus.ObjectSubscribedTracks]), float64(conf.MaxObjects.SubscribedTracks)},
+		{prometheus.ObjectPeerConnections, float64(usage.Objects[prometheus.ObjectPeerConnections]), float64(conf.MaxObjects.PeerConnections)},
+	}
+	// unavailable off linux
+	if usage.FDLimit > 0 {
+		checks = append(checks, budgetCheck{budgetFDs, float64(usage.OpenFDs) / float64(usage.FDLimit), conf.MaxFDUsage})
+	}
+	return checks
+}
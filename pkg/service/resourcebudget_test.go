@@ -0,0 +1,74 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/telemetry/prometheus"
+)
+
+func TestBudgetChecks(t *testing.T) {
+	conf := &config.ResourceBudgetConfig{
+		MaxGoroutines: 1000,
+		MaxFDUsage:    0.8,
+		MaxObjects:    config.ResourceObjectLimits{Participants: 100},
+	}
+	usage := prometheus.ResourceUsage{
+		Goroutines: 1200,
+		OpenFDs:    512,
+		FDLimit:    1024,
+		Objects:    map[string]int{prometheus.ObjectParticipants: 40},
+	}
+
+	checks := budgetChecks(conf, usage)
+	require.Contains(t, checks, budgetCheck{budgetGoroutines, 1200, 1000})
+	require.Contains(t, checks, budgetCheck{prometheus.ObjectParticipants, 40, 100})
+	require.Contains(t, checks, budgetCheck{prometheus.ObjectRooms, 0, 0})
+	require.Contains(t, checks, budgetCheck{budgetFDs, 0.5, 0.8})
+
+	// file descriptors are not checked without a limit
+	usage.FDLimit = 0
+	for _, c := range budgetChecks(conf, usage) {
+		require.NotEqual(t, budgetFDs, c.resource)
+	}
+}
+
+func TestResourceBudget_RefuseJoins(t *testing.T) {
+	conf := &config.ResourceBudgetConfig{
+		CheckInterval: time.Minute,
+		MaxGoroutines: 1,
+	}
+	budget := NewResourceBudget(conf)
+	budget.check()
+	require.True(t, budget.exceeded[budgetGoroutines])
+	require.False(t, budget.IsRefusingJoins())
+
+	conf.RefuseJoins = true
+	budget.check()
+	require.True(t, budget.IsRefusingJoins())
+
+	conf.MaxGoroutines = 0
+	budget.check()
+	require.False(t, budget.exceeded[budgetGoroutines])
+	require.False(t, budget.IsRefusingJoins())
+
+	var unset *ResourceBudget
+	require.False(t, unset.IsRefusingJoins())
+}
@@ -0,0 +1,337 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/logger"
+)
+
+const restreamPath = "/rooms/restream"
+
+type restreamRequest struct {
+	Room string   `json:"room"`
+	Urls []string `json:"urls"`
+	// restreams the camera or screen share and microphone of a participant instead of a composite
+	ParticipantIdentity string `json:"participant_identity,omitempty"`
+	Layout              string `json:"layout,omitempty"`
+	AudioOnly           bool   `json:"audio_only,omitempty"`
+	VideoOnly           bool   `json:"video_only,omitempty"`
+}
+
+// restreamStatus is reported in the room metadata for every restream of the room. Stream URLs
+// are left out, they carry stream keys
+type restreamStatus struct {
+	Status              string `json:"status"`
+	StartedAt           int64  `json:"started_at,omitempty"`
+	ParticipantIdentity string `json:"participant_identity,omitempty"`
+	Error               string `json:"error,omitempty"`
+}
+
+// RestreamService restreams rooms to RTMP endpoints such as YouTube or Twitch through egress,
+// either as a composite or the tracks of a single participant. Active restreams are reported in
+// the room metadata, egress webhooks report their progress.
+type RestreamService struct {
+	conf          config.RestreamConfig
+	egressService *EgressService
+	roomService   livekit.RoomService
+	store         ObjectStore
+
+	// serializes metadata updates
+	lock sync.Mutex
+}
+
+func NewRestreamService(
+	conf *config.Config,
+	egressService *EgressService,
+	roomService livekit.RoomService,
+	store ObjectStore,
+	ioService *IOInfoService,
+) *RestreamService {
+	s := &RestreamService{
+		conf:          conf.Restream,
+		egressService: egressService,
+		roomService:   roomService,
+		store:         store,
+	}
+	ioService.OnEgressUpdated(s.onEgressUpdated)
+	return s
+}
+
+func (s *RestreamService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := EnsureRecordPermission(r.Context()); err != nil {
+		handleError(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		s.list(w, r)
+	case http.MethodPost:
+		s.start(w, r)
+	case http.MethodDelete:
+		s.stop(w, r)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *RestreamService) start(w http.ResponseWriter, r *http.Request) {
+	var req restreamRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		handleError(w, http.StatusBadRequest, err)
+		return
+	}
+	if req.Room == "" {
+		handleError(w, http.StatusBadRequest, ErrRoomNameRequired)
+		return
+	}
+	if len(req.Urls) == 0 {
+		handleError(w, http.StatusBadRequest, ErrRestreamURLRequired)
+		return
+	}
+	for _, u := range req.Urls {
+		if !strings.HasPrefix(u, "rtmp://") && !strings.HasPrefix(u, "rtmps://") {
+			handleError(w, http.StatusBadRequest, ErrRestreamURLRequired)
+			return
+		}
+	}
+
+	ctx := r.Context()
+	stream := &livekit.StreamOutput{
+		Protocol: livekit.StreamProtocol_RTMP,
+		Urls:     req.Urls,
+	}
+	var info *livekit.EgressInfo
+	var err error
+	if req.ParticipantIdentity != "" {
+		var tc *livekit.TrackCompositeEgressRequest
+		if tc, err = s.participantRequest(ctx, &req); err != nil {
+			handleError(w, http.StatusNotFound, err, "room", req.Room, "participant", req.ParticipantIdentity)
+			return
+		}
+		tc.Output = &livekit.TrackCompositeEgressRequest_Stream{Stream: stream}
+		info, err = s.egressService.StartTrackCompositeEgress(ctx, tc)
+	} else {
+		info, err = s.egressService.StartRoomCompositeEgress(ctx, &livekit.RoomCompositeEgressRequest{
+			RoomName:  req.Room,
+			Layout:    req.Layout,
+			AudioOnly: req.AudioOnly,
+			VideoOnly: req.VideoOnly,
+			Output:    &livekit.RoomCompositeEgressRequest_Stream{Stream: stream},
+		})
+	}
+	if err != nil {
+		handleError(w, http.StatusInternalServerError, err, "room", req.Room)
+		return
+	}
+
+	logger.Infow("started restream", "room", req.Room, "egressID", info.EgressId, "participant", req.ParticipantIdentity)
+	s.updateMetadata(ctx, livekit.RoomName(req.Room), info.EgressId, &restreamStatus{
+		Status:              info.Status.String(),
+		StartedAt:           time.Now().Unix(),
+		ParticipantIdentity: req.ParticipantIdentity,
+	})
+	s.writeInfo(w, info)
+}
+
+// participantRequest picks the screen share or camera and the microphone of a participant
+func (s *RestreamService) participantRequest(ctx context.Context, req *restreamRequest) (*livekit.TrackCompositeEgressRequest, error) {
+	p, err := s.store.LoadParticipant(ctx, livekit.RoomName(req.Room), livekit.ParticipantIdentity(req.ParticipantIdentity))
+	if err != nil {
+		return nil, err
+	}
+
+	tc := &livekit.TrackCompositeEgressRequest{RoomName: req.Room}
+	for _, t := range p.Tracks {
+		switch {
+		case t.Type == livekit.TrackType_AUDIO && t.Source == livekit.TrackSource_MICROPHONE && !req.VideoOnly:
+			tc.AudioTrackId = t.Sid
+		case t.Type == livekit.TrackType_VIDEO && t.Source == livekit.TrackSource_SCREEN_SHARE && !req.AudioOnly:
+			tc.VideoTrackId = t.Sid
+		case t.Type == livekit.TrackType_VIDEO && t.Source == livekit.TrackSource_CAMERA && !req.AudioOnly && tc.VideoTrackId == "":
+			tc.VideoTrackId = t.Sid
+		}
+	}
+	if tc.AudioTrackId == "" && tc.VideoTrackId == "" {
+		return nil, ErrTrackNotFound
+	}
+	return tc, nil
+}
+
+func (s *RestreamService) stop(w http.ResponseWriter, r *http.Request) {
+	egressID := r.URL.Query().Get("egress_id")
+	info, err := s.egressService.StopEgress(r.Context(), &livekit.StopEgressRequest{EgressId: egressID})
+	if err != nil {
+		handleError(w, http.StatusInternalServerError, err, "egressID", egressID)
+		return
+	}
+	s.writeInfo(w, info)
+}
+
+func (s *RestreamService) list(w http.ResponseWriter, r *http.Request) {
+	res, err := s.egressService.ListEgress(r.Context(), &livekit.ListEgressRequest{
+		RoomName: r.URL.Query().Get("room"),
+		Active:   true,
+	})
+	if err != nil {
+		handleError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	restreams := &livekit.ListEgressResponse{}
+	for _, info := range res.Items {
+		if isRestream(info) {
+			restreams.Items = append(restreams.Items, info)
+		}
+	}
+	s.writeInfo(w, restreams)
+}
+
+func (s *RestreamService) writeInfo(w http.ResponseWriter, m proto.Message) {
+	data, err := protojson.Marshal(m)
+	if err != nil {
+		handleError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(data)
+}
+
+// onEgressUpdated keeps the room metadata up to date with restreams started by any client
+func (s *RestreamService) onEgressUpdated(ctx context.Context, info *livekit.EgressInfo) {
+	if !isRestream(info) {
+		return
+	}
+	status := &restreamStatus{
+		Status:    info.Status.String(),
+		StartedAt: info.StartedAt / int64(time.Second),
+		Error:     info.Error,
+	}
+	switch info.Status {
+	case livekit.EgressStatus_EGRESS_COMPLETE,
+		livekit.EgressStatus_EGRESS_FAILED,
+		livekit.EgressStatus_EGRESS_ABORTED,
+		livekit.EgressStatus_EGRESS_LIMIT_REACHED:
+		status = nil
+	}
+	s.updateMetadata(ctx, livekit.RoomName(info.RoomName), info.EgressId, status)
+}
+
+func isRestream(info *livekit.EgressInfo) bool {
+	if info.GetRoomComposite().GetStream() != nil || info.GetTrackComposite().GetStream() != nil {
+		return true
+	}
+	for _, o := range info.GetRoomComposite().GetStreamOutputs() {
+		if o.Protocol == livekit.StreamProtocol_RTMP {
+			return true
+		}
+	}
+	return false
+}
+
+// updateMetadata sets the status of a restream under the configured key of the room metadata,
+// a nil status removes it. Metadata that is not a JSON object is left alone.
+func (s *RestreamService) updateMetadata(ctx context.Context, roomName livekit.RoomName, egressID string, status *restreamStatus) {
+	if s.conf.MetadataKey == "" || roomName == "" {
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	room, _, err := s.store.LoadRoom(ctx, roomName, false)
+	if err != nil {
+		// the room closed, its restreams ended with it
+		return
+	}
+	metadata, ok := mergeRestreamStatus(room.Metadata, s.conf.MetadataKey, egressID, status)
+	if !ok {
+		logger.Debugw("room metadata is not a JSON object, not reporting restreams", "room", roomName)
+		return
+	}
+	if metadata == room.Metadata {
+		return
+	}
+	_, err = s.roomService.UpdateRoomMetadata(WithLocalControl(ctx), &livekit.UpdateRoomMetadataRequest{
+		Room:     string(roomName),
+		Metadata: metadata,
+	})
+	if err != nil {
+		logger.Warnw("could not report restream in room metadata", err, "room", roomName, "egressID", egressID)
+	}
+}
+
+func mergeRestreamStatus(metadata string, key string, egressID string, status *restreamStatus) (string, bool) {
+	fields := make(map[string]json.RawMessage)
+	if metadata != "" {
+		if err := json.Unmarshal([]byte(metadata), &fields); err != nil {
+			return "", false
+		}
+	}
+	restreams := make(map[string]*restreamStatus)
+	if raw, ok := fields[key]; ok {
+		if err := json.Unmarshal(raw, &restreams); err != nil {
+			return "", false
+		}
+	}
+
+	if status == nil {
+		if _, ok := restreams[egressID]; !ok {
+			return metadata, true
+		}
+		delete(restreams, egressID)
+	} else {
+		if current, ok := restreams[egressID]; ok {
+			// updates from egress do not know the participant
+			if status.ParticipantIdentity == "" {
+				status.ParticipantIdentity = current.ParticipantIdentity
+			}
+			if status.StartedAt == 0 {
+				status.StartedAt = current.StartedAt
+			}
+		}
+		restreams[egressID] = status
+	}
+
+	if len(restreams) == 0 {
+		delete(fields, key)
+	} else {
+		raw, err := json.Marshal(restreams)
+		if err != nil {
+			return "", false
+		}
+		fields[key] = raw
+	}
+	if len(fields) == 0 {
+		return "", true
+	}
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return "", false
+	}
+	return string(data), true
+}
@@ -0,0 +1,86 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/protocol/livekit"
+)
+
+func TestMergeRestreamStatus(t *testing.T) {
+	t.Run("adds to application metadata", func(t *testing.T) {
+		metadata, ok := mergeRestreamStatus(`{"course":"physics"}`, "restream", "EG_1", &restreamStatus{
+			Status:              livekit.EgressStatus_EGRESS_STARTING.String(),
+			StartedAt:           100,
+			ParticipantIdentity: "teacher",
+		})
+		require.True(t, ok)
+
+		var fields map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(metadata), &fields))
+		require.Equal(t, "physics", fields["course"])
+		require.Equal(t, map[string]interface{}{
+			"EG_1": map[string]interface{}{
+				"status":               "EGRESS_STARTING",
+				"started_at":           float64(100),
+				"participant_identity": "teacher",
+			},
+		}, fields["restream"])
+
+		// updates from egress keep the participant
+		metadata, ok = mergeRestreamStatus(metadata, "restream", "EG_1", &restreamStatus{
+			Status: livekit.EgressStatus_EGRESS_ACTIVE.String(),
+		})
+		require.True(t, ok)
+		require.JSONEq(t, `{"course":"physics","restream":{"EG_1":{"status":"EGRESS_ACTIVE","started_at":100,"participant_identity":"teacher"}}}`, metadata)
+
+		// ended restreams are removed
+		metadata, ok = mergeRestreamStatus(metadata, "restream", "EG_1", nil)
+		require.True(t, ok)
+		require.JSONEq(t, `{"course":"physics"}`, metadata)
+	})
+
+	t.Run("empty metadata", func(t *testing.T) {
+		metadata, ok := mergeRestreamStatus("", "restream", "EG_1", &restreamStatus{Status: "EGRESS_ACTIVE"})
+		require.True(t, ok)
+		require.JSONEq(t, `{"restream":{"EG_1":{"status":"EGRESS_ACTIVE"}}}`, metadata)
+
+		metadata, ok = mergeRestreamStatus(metadata, "restream", "EG_1", nil)
+		require.True(t, ok)
+		require.Equal(t, "", metadata)
+	})
+
+	t.Run("metadata that is not an object is left alone", func(t *testing.T) {
+		_, ok := mergeRestreamStatus("lecture 4", "restream", "EG_1", &restreamStatus{Status: "EGRESS_ACTIVE"})
+		require.False(t, ok)
+	})
+}
+
+func TestIsRestream(t *testing.T) {
+	require.True(t, isRestream(&livekit.EgressInfo{
+		Request: &livekit.EgressInfo_RoomComposite{RoomComposite: &livekit.RoomCompositeEgressRequest{
+			Output: &livekit.RoomCompositeEgressRequest_Stream{Stream: &livekit.StreamOutput{}},
+		}},
+	}))
+	require.False(t, isRestream(&livekit.EgressInfo{
+		Request: &livekit.EgressInfo_RoomComposite{RoomComposite: &livekit.RoomCompositeEgressRequest{
+			Output: &livekit.RoomCompositeEgressRequest_File{File: &livekit.EncodedFileOutput{}},
+		}},
+	}))
+}
@@ -0,0 +1,127 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-jose/go-jose/v3/jwt"
+
+	"github.com/livekit/protocol/auth"
+	"github.com/livekit/protocol/livekit"
+)
+
+var ErrResumeTokenInvalid = errors.New("access token expired and session cannot be resumed")
+
+// the resume token of a new session is returned in this header of the websocket upgrade carrying the join
+// response, reconnects pass it back in the resume_token query parameter
+const resumeTokenHeader = "X-Livekit-Resume-Token"
+
+// ResumeToken admits a signal reconnect of a joined participant presenting the secret it was issued on join.
+// It allows resuming with the access token the participant joined with, even after that token expired
+type ResumeToken struct {
+	ParticipantID livekit.ParticipantID       `json:"participant_id"`
+	Identity      livekit.ParticipantIdentity `json:"identity"`
+	Room          livekit.RoomName            `json:"room"`
+	// SHA-256 of the secret handed to the participant, the secret itself is not stored
+	SecretHash []byte `json:"secret_hash"`
+	ExpiresAt  int64  `json:"expires_at"`
+}
+
+// issueResumeToken stores a resume token for a new session, returning the secret to hand to the participant
+func issueResumeToken(ctx context.Context, store ResumeTokenStore, ttl time.Duration, roomName livekit.RoomName, identity livekit.ParticipantIdentity, participantID livekit.ParticipantID) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	secret := base64.RawURLEncoding.EncodeToString(b)
+	hash := sha256.Sum256([]byte(secret))
+
+	err := store.StoreResumeToken(ctx, &ResumeToken{
+		ParticipantID: participantID,
+		Identity:      identity,
+		Room:          roomName,
+		SecretHash:    hash[:],
+	}, ttl)
+	if err != nil {
+		return "", err
+	}
+	return secret, nil
+}
+
+func (t *ResumeToken) matches(secret string) bool {
+	hash := sha256.Sum256([]byte(secret))
+	return subtle.ConstantTimeCompare(hash[:], t.SecretHash) == 1
+}
+
+// renew resume tokens well ahead of expiry, so they outlive the session by about ttl
+func resumeTokenRenewInterval(ttl time.Duration) time.Duration {
+	return ttl / 3
+}
+
+// isResumeRequest reports whether r reconnects a signal connection to an existing session
+func isResumeRequest(r *http.Request) bool {
+	if r.URL == nil || (r.URL.Path != "/rtc" && r.URL.Path != "/rtc/validate") {
+		return false
+	}
+	return boolValue(r.FormValue("reconnect")) && r.FormValue("sid") != "" && r.FormValue("resume_token") != ""
+}
+
+// verifyResume accepts an expired access token for the session of the request's sid, if its signature is valid
+// and the request presents the secret of the live resume token issued to the session, for the same identity and room
+func verifyResume(ctx context.Context, store ResumeTokenStore, r *http.Request, v *auth.APIKeyTokenVerifier, rawToken string, key interface{}) (*auth.ClaimGrants, error) {
+	tok, err := jwt.ParseSigned(rawToken)
+	if err != nil {
+		return nil, err
+	}
+	if secret, ok := key.(string); ok {
+		key = []byte(secret)
+	}
+	claims := jwt.Claims{}
+	grants := &auth.ClaimGrants{}
+	if err = tok.Claims(key, &claims, grants); err != nil {
+		return nil, err
+	}
+	if claims.Issuer != v.APIKey() {
+		return nil, ErrResumeTokenInvalid
+	}
+	grants.Identity = v.Identity()
+
+	token, err := store.LoadResumeToken(ctx, livekit.ParticipantID(r.FormValue("sid")))
+	if err != nil {
+		return nil, err
+	}
+	if token == nil || !token.matches(r.FormValue("resume_token")) || string(token.Identity) != grants.Identity {
+		return nil, ErrResumeTokenInvalid
+	}
+	if grants.Video == nil {
+		return nil, ErrResumeTokenInvalid
+	}
+	room := grants.Video.Room
+	if room == "" {
+		room = r.FormValue("room")
+	}
+	if room != string(token.Room) {
+		return nil, ErrResumeTokenInvalid
+	}
+	return grants, nil
+}
@@ -16,8 +16,13 @@ package service
 
 import (
 	"context"
+	"strings"
 	"time"
 
+	"github.com/pion/webrtc/v3"
+	"go.uber.org/atomic"
+	"golang.org/x/exp/slices"
+
 	"github.com/livekit/protocol/livekit"
 	"github.com/livekit/protocol/logger"
 	"github.com/livekit/protocol/utils"
@@ -25,10 +30,11 @@ import (
 	"github.com/livekit/livekit-server/pkg/config"
 	"github.com/livekit/livekit-server/pkg/routing"
 	"github.com/livekit/livekit-server/pkg/routing/selector"
+	"github.com/livekit/livekit-server/pkg/telemetry/prometheus"
 )
 
 type StandardRoomAllocator struct {
-	config    *config.Config
+	config    atomic.Pointer[config.Config]
 	router    routing.Router
 	selector  selector.NodeSelector
 	roomStore ObjectStore
@@ -39,18 +45,35 @@ func NewRoomAllocator(conf *config.Config, router routing.Router, rs ObjectStore
 	if err != nil {
 		return nil, err
 	}
+	if ls, ok := ns.(selector.LatencyAwareNodeSelector); ok {
+		if lr, ok := router.(routing.LatencyReporter); ok {
+			ls.SetLatencyProvider(lr)
+		} else {
+			logger.Warnw("latency aware node selector requires redis, selecting without latencies", nil, "kind", conf.NodeSelector.Kind)
+		}
+	}
 
-	return &StandardRoomAllocator{
-		config:    conf,
+	ra := &StandardRoomAllocator{
 		router:    router,
 		selector:  ns,
 		roomStore: rs,
-	}, nil
+	}
+	ra.config.Store(conf)
+	return ra, nil
+}
+
+// ReloadConfig applies reloaded room defaults and limits to rooms created from now on
+func (r *StandardRoomAllocator) ReloadConfig(conf *config.Config) {
+	r.config.Store(conf)
 }
 
 // CreateRoom creates a new room from a request and allocates it to a node to handle
 // it'll also monitor its state, and cleans it up when appropriate
 func (r *StandardRoomAllocator) CreateRoom(ctx context.Context, req *livekit.CreateRoomRequest) (*livekit.Room, error) {
+	if degradedRouter(r.router) != nil {
+		return nil, ErrPlacementUnavailable
+	}
+
 	token, err := r.roomStore.LockRoom(ctx, livekit.RoomName(req.Name), 5*time.Second)
 	if err != nil {
 		return nil, err
@@ -60,6 +83,7 @@ func (r *StandardRoomAllocator) CreateRoom(ctx context.Context, req *livekit.Cre
 	}()
 
 	// find existing room and update it
+	conf := r.config.Load()
 	rm, internal, err := r.roomStore.LoadRoom(ctx, livekit.RoomName(req.Name), true)
 	if err == ErrRoomNotFound {
 		rm = &livekit.Room{
@@ -68,10 +92,27 @@ func (r *StandardRoomAllocator) CreateRoom(ctx context.Context, req *livekit.Cre
 			CreationTime: time.Now().Unix(),
 			TurnPassword: utils.RandomSecret(),
 		}
-		applyDefaultRoomConfig(rm, &r.config.Room)
+		applyDefaultRoomConfig(rm, &conf.Room)
 	} else if err != nil {
 		return nil, err
 	}
+	isNew := err == ErrRoomNotFound
+	if isNew {
+		if err = checkRoomQuota(ctx, r.roomStore, &conf.Quotas, GetAPIKey(ctx)); err != nil {
+			return nil, err
+		}
+	}
+
+	template := roomTemplateFromContext(ctx)
+	if template != "" {
+		tmpl, err := conf.Room.ResolveTemplate(template)
+		if err != nil {
+			return nil, ErrRoomTemplateNotFound
+		}
+		if len(tmpl.EnabledCodecs) != 0 {
+			rm.EnabledCodecs = toRoomCodecs(tmpl.EnabledCodecs, conf.Room.EnableH265)
+		}
+	}
 
 	if req.EmptyTimeout > 0 {
 		rm.EmptyTimeout = req.EmptyTimeout
@@ -95,6 +136,30 @@ func (r *StandardRoomAllocator) CreateRoom(ctx context.Context, req *livekit.Cre
 	if err = r.roomStore.StoreRoom(ctx, rm, internal); err != nil {
 		return nil, err
 	}
+	if apiKey := GetAPIKey(ctx); isNew && apiKey != "" {
+		prometheus.RecordAPIKeyRoomCreated(apiKey)
+		if qs, ok := r.roomStore.(APIKeyQuotaStore); ok {
+			if err = qs.StoreRoomAPIKey(ctx, livekit.RoomName(rm.Name), apiKey); err != nil {
+				return nil, err
+			}
+		}
+	}
+	// new rooms also clear the template left behind by an earlier room of the same name
+	if ts, ok := r.roomStore.(RoomTemplateStore); ok && (isNew || template != "") {
+		if err = ts.StoreRoomTemplate(ctx, livekit.RoomName(rm.Name), template); err != nil {
+			return nil, err
+		}
+	}
+	regions := roomRegionsFromContext(ctx)
+	if rs, ok := r.roomStore.(RoomRegionStore); ok {
+		if isNew || len(regions) != 0 {
+			if err = rs.StoreRoomRegions(ctx, livekit.RoomName(rm.Name), regions); err != nil {
+				return nil, err
+			}
+		} else if regions, err = rs.LoadRoomRegions(ctx, livekit.RoomName(rm.Name)); err != nil {
+			return nil, err
+		}
+	}
 
 	// check if room already assigned
 	existing, err := r.router.GetNodeForRoom(ctx, livekit.RoomName(rm.Name))
@@ -105,7 +170,7 @@ func (r *StandardRoomAllocator) CreateRoom(ctx context.Context, req *livekit.Cre
 	// if already assigned and still available, keep it on that node
 	if err == nil && selector.IsAvailable(existing) {
 		// if node hosting the room is full, deny entry
-		if selector.LimitsReached(r.config.Limit, existing.Stats) {
+		if selector.LimitsReached(conf.Limit, existing.Stats) {
 			return nil, routing.ErrNodeLimitReached
 		}
 
@@ -120,6 +185,12 @@ func (r *StandardRoomAllocator) CreateRoom(ctx context.Context, req *livekit.Cre
 			return nil, err
 		}
 
+		if len(regions) != 0 {
+			if nodes = filterNodesByRegion(nodes, regions); len(nodes) == 0 {
+				return nil, ErrNoNodesInRegions
+			}
+		}
+
 		node, err := r.selector.SelectNode(nodes)
 		if err != nil {
 			return nil, err
@@ -138,9 +209,12 @@ func (r *StandardRoomAllocator) CreateRoom(ctx context.Context, req *livekit.Cre
 }
 
 func (r *StandardRoomAllocator) ValidateCreateRoom(ctx context.Context, roomName livekit.RoomName) error {
-	// when auto create is disabled, we'll check to ensure it's already created
-	if !r.config.Room.AutoCreate {
+	// when the room can't be created on join, we'll check to ensure it's already created
+	if !canAutoCreateRoom(ctx, &r.config.Load().Room, roomName) {
 		_, _, err := r.roomStore.LoadRoom(ctx, roomName, false)
+		if err == ErrRoomNotFound {
+			return ErrRoomNotCreated
+		}
 		if err != nil {
 			return err
 		}
@@ -148,17 +222,63 @@ func (r *StandardRoomAllocator) ValidateCreateRoom(ctx context.Context, roomName
 	return nil
 }
 
+// canAutoCreateRoom applies the auto create policy to a join request
+func canAutoCreateRoom(ctx context.Context, conf *config.RoomConfig, roomName livekit.RoomName) bool {
+	if !conf.AutoCreate {
+		return false
+	}
+	if len(conf.AutoCreatePrefixes) != 0 {
+		matched := false
+		for _, prefix := range conf.AutoCreatePrefixes {
+			if strings.HasPrefix(string(roomName), prefix) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if len(conf.AutoCreateAPIKeys) != 0 && !slices.Contains(conf.AutoCreateAPIKeys, GetAPIKey(ctx)) {
+		return false
+	}
+	if conf.AutoCreateRequireGrant {
+		claims := GetGrants(ctx)
+		if claims == nil || claims.Video == nil || !claims.Video.RoomCreate {
+			return false
+		}
+	}
+	return true
+}
+
 func applyDefaultRoomConfig(room *livekit.Room, conf *config.RoomConfig) {
 	room.EmptyTimeout = conf.EmptyTimeout
 	room.MaxParticipants = conf.MaxParticipants
-	for _, codec := range conf.EnabledCodecs {
-		room.EnabledCodecs = append(room.EnabledCodecs, &livekit.Codec{
+	room.EnabledCodecs = toRoomCodecs(conf.EnabledCodecs, conf.EnableH265)
+	room.PlayoutDelay = &livekit.PlayoutDelay{
+		Enabled: conf.PlayoutDelay.Enabled,
+		Min:     uint32(conf.PlayoutDelay.Min),
+	}
+}
+
+// toRoomCodecs adds H.265 when it is enabled and removes it otherwise, so that it's only negotiated behind the flag
+func toRoomCodecs(codecs []config.CodecSpec, enableH265 bool) []*livekit.Codec {
+	var roomCodecs []*livekit.Codec
+	hasH265 := false
+	for _, codec := range codecs {
+		if strings.EqualFold(codec.Mime, webrtc.MimeTypeH265) {
+			if !enableH265 {
+				continue
+			}
+			hasH265 = true
+		}
+		roomCodecs = append(roomCodecs, &livekit.Codec{
 			Mime:     codec.Mime,
 			FmtpLine: codec.FmtpLine,
 		})
 	}
-	room.PlayoutDelay = &livekit.PlayoutDelay{
-		Enabled: conf.PlayoutDelay.Enabled,
-		Min:     uint32(conf.PlayoutDelay.Min),
+	if enableH265 && !hasH265 {
+		roomCodecs = append(roomCodecs, &livekit.Codec{Mime: webrtc.MimeTypeH265})
 	}
+	return roomCodecs
 }
@@ -16,10 +16,13 @@ package service_test
 
 import (
 	"context"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/stretchr/testify/require"
 
+	"github.com/livekit/protocol/auth"
 	"github.com/livekit/protocol/livekit"
 
 	"github.com/livekit/livekit-server/pkg/config"
@@ -45,6 +48,84 @@ func TestCreateRoom(t *testing.T) {
 		require.NotEmpty(t, room.EnabledCodecs)
 	})
 
+	t.Run("room template settings are applied", func(t *testing.T) {
+		conf, err := config.NewConfig("", true, nil, nil)
+		require.NoError(t, err)
+		conf.Room.Templates = map[string]config.RoomTemplate{
+			"audio": {EnabledCodecs: []config.CodecSpec{{Mime: "audio/opus"}}},
+		}
+
+		node, err := routing.NewLocalNode(conf)
+		require.NoError(t, err)
+
+		ra, _ := newTestRoomAllocator(t, conf, node)
+
+		var ctx context.Context
+		handler := service.WithRoomTemplate(&conf.Room, http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+			ctx = r.Context()
+		}))
+
+		req := httptest.NewRequest(http.MethodPost, "/twirp/livekit.RoomService/CreateRoom", nil)
+		req.Header.Set(service.RoomTemplateHeader, "unknown")
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, req)
+		require.Equal(t, http.StatusNotFound, res.Code)
+		require.Nil(t, ctx)
+
+		req.Header.Set(service.RoomTemplateHeader, "audio")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		require.NotNil(t, ctx)
+
+		room, err := ra.CreateRoom(ctx, &livekit.CreateRoomRequest{Name: "podcast"})
+		require.NoError(t, err)
+		require.Len(t, room.EnabledCodecs, 1)
+		require.Equal(t, "audio/opus", room.EnabledCodecs[0].Mime)
+	})
+
+	t.Run("rooms pinned to regions are placed on nodes of those regions", func(t *testing.T) {
+		conf, err := config.NewConfig("", true, nil, nil)
+		require.NoError(t, err)
+
+		nodes := make([]*livekit.Node, 0, 2)
+		for _, region := range []string{"us-west", "eu-central"} {
+			node, err := routing.NewLocalNode(conf)
+			require.NoError(t, err)
+			node.Region = region
+			nodes = append(nodes, node)
+		}
+		store := &servicefakes.FakeObjectStore{}
+		store.LoadRoomReturns(nil, nil, service.ErrRoomNotFound)
+		router := &routingfakes.FakeRouter{}
+		router.GetNodeForRoomReturns(nil, routing.ErrNotFound)
+		router.ListNodesReturns(nodes, nil)
+		ra, err := service.NewRoomAllocator(conf, router, store)
+		require.NoError(t, err)
+
+		var ctx context.Context
+		handler := service.WithRoomRegions(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+			ctx = r.Context()
+		}))
+		req := httptest.NewRequest(http.MethodPost, "/twirp/livekit.RoomService/CreateRoom", nil)
+		req.Header.Set(service.RoomRegionsHeader, " , ")
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, req)
+		require.Equal(t, http.StatusBadRequest, res.Code)
+
+		req.Header.Set(service.RoomRegionsHeader, "eu-central, eu-west")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		for i := 0; i < 5; i++ {
+			_, err = ra.CreateRoom(ctx, &livekit.CreateRoomRequest{Name: "pinned"})
+			require.NoError(t, err)
+			_, _, nodeID := router.SetNodeForRoomArgsForCall(router.SetNodeForRoomCallCount() - 1)
+			require.Equal(t, livekit.NodeID(nodes[1].Id), nodeID)
+		}
+
+		req.Header.Set(service.RoomRegionsHeader, "ap-south")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		_, err = ra.CreateRoom(ctx, &livekit.CreateRoomRequest{Name: "pinned"})
+		require.ErrorIs(t, err, service.ErrNoNodesInRegions)
+	})
+
 	t.Run("reject new participants when track limit has been reached", func(t *testing.T) {
 		conf, err := config.NewConfig("", true, nil, nil)
 		require.NoError(t, err)
@@ -78,6 +159,54 @@ func TestCreateRoom(t *testing.T) {
 	})
 }
 
+func TestValidateCreateRoom(t *testing.T) {
+	t.Run("auto create disabled", func(t *testing.T) {
+		conf, err := config.NewConfig("", true, nil, nil)
+		require.NoError(t, err)
+		conf.Room.AutoCreate = false
+
+		node, err := routing.NewLocalNode(conf)
+		require.NoError(t, err)
+		ra, _ := newTestRoomAllocator(t, conf, node)
+
+		err = ra.ValidateCreateRoom(context.Background(), "myroom")
+		require.ErrorIs(t, err, service.ErrRoomNotCreated)
+	})
+
+	t.Run("auto create restricted to prefixes", func(t *testing.T) {
+		conf, err := config.NewConfig("", true, nil, nil)
+		require.NoError(t, err)
+		conf.Room.AutoCreatePrefixes = []string{"class-", "exam-"}
+
+		node, err := routing.NewLocalNode(conf)
+		require.NoError(t, err)
+		ra, _ := newTestRoomAllocator(t, conf, node)
+
+		require.NoError(t, ra.ValidateCreateRoom(context.Background(), "exam-101"))
+		require.ErrorIs(t, ra.ValidateCreateRoom(context.Background(), "exma-101"), service.ErrRoomNotCreated)
+	})
+
+	t.Run("auto create requires roomCreate grant", func(t *testing.T) {
+		conf, err := config.NewConfig("", true, nil, nil)
+		require.NoError(t, err)
+		conf.Room.AutoCreateRequireGrant = true
+
+		node, err := routing.NewLocalNode(conf)
+		require.NoError(t, err)
+		ra, _ := newTestRoomAllocator(t, conf, node)
+
+		joinOnly := service.WithGrants(context.Background(), &auth.ClaimGrants{
+			Video: &auth.VideoGrant{RoomJoin: true, Room: "myroom"},
+		})
+		require.ErrorIs(t, ra.ValidateCreateRoom(joinOnly, "myroom"), service.ErrRoomNotCreated)
+
+		canCreate := service.WithGrants(context.Background(), &auth.ClaimGrants{
+			Video: &auth.VideoGrant{RoomJoin: true, RoomCreate: true, Room: "myroom"},
+		})
+		require.NoError(t, ra.ValidateCreateRoom(canCreate, "myroom"))
+	})
+}
+
 func newTestRoomAllocator(t *testing.T, conf *config.Config, node *livekit.Node) (service.RoomAllocator, *config.Config) {
 	store := &servicefakes.FakeObjectStore{}
 	store.LoadRoomReturns(nil, nil, service.ErrRoomNotFound)
@@ -89,3 +218,27 @@ func newTestRoomAllocator(t *testing.T, conf *config.Config, node *livekit.Node)
 	require.NoError(t, err)
 	return ra, conf
 }
+
+type degradedRouter struct {
+	*routingfakes.FakeRouter
+}
+
+func (degradedRouter) IsDegraded() bool                           { return true }
+func (degradedRouter) IsLocalRoom(roomName livekit.RoomName) bool { return roomName == "local" }
+func (degradedRouter) SetLocalRoom(_ livekit.RoomName, _ bool)    {}
+func (degradedRouter) OnRecovered(_ func())                       {}
+
+func TestCreateRoomDegraded(t *testing.T) {
+	conf, err := config.NewConfig("", true, nil, nil)
+	require.NoError(t, err)
+
+	store := &servicefakes.FakeObjectStore{}
+	store.LoadRoomReturns(nil, nil, service.ErrRoomNotFound)
+	ra, err := service.NewRoomAllocator(conf, degradedRouter{&routingfakes.FakeRouter{}}, store)
+	require.NoError(t, err)
+
+	// rooms cannot be placed without redis
+	_, err = ra.CreateRoom(context.Background(), &livekit.CreateRoomRequest{Name: "local"})
+	require.ErrorIs(t, err, service.ErrPlacementUnavailable)
+	require.Zero(t, store.LockRoomCallCount())
+}
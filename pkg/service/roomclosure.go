@@ -0,0 +1,421 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/twitchtv/twirp"
+
+	"github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/logger"
+
+	"github.com/livekit/livekit-server/pkg/routing"
+	"github.com/livekit/livekit-server/pkg/rtc"
+)
+
+const (
+	// DeleteRoom requests carrying this header close the room after the given number of seconds
+	DeleteRoomDelayHeader = "Livekit-Delete-Delay"
+	// topic of the data packets announcing a scheduled closure to participants
+	RoomClosingTopic = "lk.room_closing"
+
+	roomClosurePath    = "/rooms/closure"
+	maxDeleteRoomDelay = 24 * time.Hour
+	// how often a countdown checks the store for its closure being cancelled or moved
+	roomClosureCheckInterval = 10 * time.Second
+)
+
+// remaining seconds at which the countdown is announced, in addition to when the closure is scheduled
+var roomClosingAnnouncements = []int{3600, 1800, 900, 600, 300, 120, 60, 30, 10, 5, 4, 3, 2, 1}
+
+// RoomClosure is a closure of a room scheduled for later. It is kept in the store, the node hosting the room
+// counts down to it and resumes the countdown when the room is recreated, e.g. after the node restarted
+type RoomClosure struct {
+	Room     livekit.RoomName `json:"room"`
+	ClosesAt int64            `json:"closes_at"`
+}
+
+type deleteRoomDelayKey struct{}
+
+// WithDeleteRoomDelay passes the delete delay header of Twirp requests on to RoomService
+func WithDeleteRoomDelay(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if v := r.Header.Get(DeleteRoomDelayHeader); v != "" {
+			seconds, err := strconv.Atoi(v)
+			if err != nil || seconds < 0 {
+				handleError(w, http.StatusBadRequest, ErrInvalidDeleteDelay, "delay", v)
+				return
+			}
+			r = r.WithContext(context.WithValue(r.Context(), deleteRoomDelayKey{}, time.Duration(seconds)*time.Second))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func deleteRoomDelayFromContext(ctx context.Context) time.Duration {
+	delay, _ := ctx.Value(deleteRoomDelayKey{}).(time.Duration)
+	return delay
+}
+
+type roomClosingMessage struct {
+	SecondsRemaining int   `json:"seconds_remaining,omitempty"`
+	ClosesAt         int64 `json:"closes_at,omitempty"`
+	Cancelled        bool  `json:"cancelled,omitempty"`
+}
+
+// scheduleRoomClosure stores the closure and hands it to the node hosting the room, which starts counting down
+func scheduleRoomClosure(ctx context.Context, store ServiceStore, router routing.MessageRouter, roomName livekit.RoomName, delay time.Duration) (*RoomClosure, error) {
+	closureStore, ok := store.(RoomClosureStore)
+	if !ok {
+		return nil, ErrClosureUnsupported
+	}
+	if delay <= 0 || delay > maxDeleteRoomDelay {
+		return nil, ErrInvalidDeleteDelay
+	}
+
+	closure := &RoomClosure{
+		Room:     roomName,
+		ClosesAt: time.Now().Add(delay).Unix(),
+	}
+	if err := closureStore.StoreRoomClosure(ctx, closure); err != nil {
+		return nil, err
+	}
+	logger.Infow("scheduling room closure", "room", roomName, "delay", delay)
+
+	// the hosting node finds the closure in the store and counts down instead of deleting the room
+	err := router.WriteRoomRTC(ctx, roomName, &livekit.RTCNodeMessage{
+		Message: &livekit.RTCNodeMessage_DeleteRoom{
+			DeleteRoom: &livekit.DeleteRoomRequest{Room: string(roomName)},
+		},
+	})
+	return closure, err
+}
+
+// cancelRoomClosure removes a scheduled closure, the countdown stops once the hosting node checks the store.
+// Returns false if no closure was scheduled
+func cancelRoomClosure(ctx context.Context, store ServiceStore, router routing.MessageRouter, roomName livekit.RoomName) (bool, error) {
+	closureStore, ok := store.(RoomClosureStore)
+	if !ok {
+		return false, nil
+	}
+	closure, err := closureStore.LoadRoomClosure(ctx, roomName)
+	if err != nil || closure == nil {
+		return false, err
+	}
+	if err = closureStore.DeleteRoomClosure(ctx, roomName); err != nil {
+		return false, err
+	}
+	logger.Infow("cancelled room closure", "room", roomName)
+
+	payload, err := json.Marshal(&roomClosingMessage{Cancelled: true})
+	if err != nil {
+		return true, err
+	}
+	topic := RoomClosingTopic
+	err = router.WriteRoomRTC(ctx, roomName, &livekit.RTCNodeMessage{
+		Message: &livekit.RTCNodeMessage_SendData{
+			SendData: &livekit.SendDataRequest{
+				Room:  string(roomName),
+				Data:  payload,
+				Kind:  livekit.DataPacket_RELIABLE,
+				Topic: &topic,
+			},
+		},
+	})
+	if err != nil {
+		logger.Warnw("could not announce cancelled room closure", err, "room", roomName)
+	}
+	return true, nil
+}
+
+// ------------------------------------------------
+
+// roomClosureScheduler counts down scheduled room closures on the node hosting the rooms
+type roomClosureScheduler struct {
+	checkInterval time.Duration
+
+	lock    sync.Mutex
+	pending map[livekit.RoomName]chan struct{}
+}
+
+func newRoomClosureScheduler(checkInterval time.Duration) *roomClosureScheduler {
+	return &roomClosureScheduler{
+		checkInterval: checkInterval,
+		pending:       make(map[livekit.RoomName]chan struct{}),
+	}
+}
+
+// schedule replaces any earlier countdown of the room. announce is called with the remaining time on every
+// countdown step and closeRoom once it elapses. isScheduled is checked every checkInterval and before each step,
+// the countdown stops once it returns false
+func (c *roomClosureScheduler) schedule(
+	roomName livekit.RoomName,
+	closesAt time.Time,
+	isScheduled func() bool,
+	announce func(remaining time.Duration, closesAt time.Time),
+	closeRoom func(),
+) {
+	cancelChan := make(chan struct{})
+	c.lock.Lock()
+	if prev, ok := c.pending[roomName]; ok {
+		close(prev)
+	}
+	c.pending[roomName] = cancelChan
+	c.lock.Unlock()
+
+	// waits till at, returns false when the countdown was cancelled or replaced
+	waitUntil := func(at time.Time) bool {
+		for {
+			wait := time.Until(at)
+			if wait > c.checkInterval {
+				wait = c.checkInterval
+			}
+			if wait > 0 {
+				select {
+				case <-cancelChan:
+					return false
+				case <-time.After(wait):
+				}
+			}
+			if !isScheduled() {
+				c.remove(roomName, cancelChan)
+				return false
+			}
+			if !time.Now().Before(at) {
+				return true
+			}
+		}
+	}
+
+	go func() {
+		announce(time.Until(closesAt), closesAt)
+		for _, seconds := range roomClosingAnnouncements {
+			remaining := time.Duration(seconds) * time.Second
+			if !time.Now().Add(remaining).Before(closesAt) {
+				continue
+			}
+			if !waitUntil(closesAt.Add(-remaining)) {
+				return
+			}
+			announce(remaining, closesAt)
+		}
+
+		if !waitUntil(closesAt) || !c.remove(roomName, cancelChan) {
+			return
+		}
+		closeRoom()
+	}()
+}
+
+// cancel stops a pending countdown, returns true if the room had one
+func (c *roomClosureScheduler) cancel(roomName livekit.RoomName) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	cancelChan, ok := c.pending[roomName]
+	if ok {
+		close(cancelChan)
+		delete(c.pending, roomName)
+	}
+	return ok
+}
+
+// remove drops the countdown if it's still the current one of the room
+func (c *roomClosureScheduler) remove(roomName livekit.RoomName, cancelChan chan struct{}) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.pending[roomName] != cancelChan {
+		// rescheduled while waiting
+		return false
+	}
+	delete(c.pending, roomName)
+	return true
+}
+
+// ------------------------------------------------
+
+// loadRoomClosure returns the closure scheduled for the room, if any
+func (r *RoomManager) loadRoomClosure(ctx context.Context, roomName livekit.RoomName) *RoomClosure {
+	closureStore, ok := r.roomStore.(RoomClosureStore)
+	if !ok {
+		return nil
+	}
+	closure, err := closureStore.LoadRoomClosure(ctx, roomName)
+	if err != nil {
+		logger.Warnw("could not load room closure", err, "room", roomName)
+		return nil
+	}
+	return closure
+}
+
+// resumeRoomClosure restarts the countdown of a scheduled closure when the room is created on this node.
+// Closures that elapsed while the room was not hosted anywhere are dropped
+func (r *RoomManager) resumeRoomClosure(ctx context.Context, room *rtc.Room) {
+	closure := r.loadRoomClosure(ctx, room.Name())
+	if closure == nil {
+		return
+	}
+	if time.Unix(closure.ClosesAt, 0).After(time.Now()) {
+		r.startRoomClosure(room, closure)
+		return
+	}
+	if err := r.roomStore.(RoomClosureStore).DeleteRoomClosure(ctx, room.Name()); err != nil {
+		room.Logger.Warnw("could not delete elapsed room closure", err)
+	}
+}
+
+// startRoomClosure counts down to the closure, announcing it to participants
+func (r *RoomManager) startRoomClosure(room *rtc.Room, closure *RoomClosure) {
+	room.Logger.Infow("counting down to room closure", "closesAt", time.Unix(closure.ClosesAt, 0))
+	r.closures.schedule(
+		room.Name(),
+		time.Unix(closure.ClosesAt, 0),
+		func() bool {
+			current := r.loadRoomClosure(context.Background(), room.Name())
+			return current != nil && current.ClosesAt == closure.ClosesAt
+		},
+		func(remaining time.Duration, closesAt time.Time) {
+			payload, err := json.Marshal(&roomClosingMessage{
+				SecondsRemaining: int(remaining.Round(time.Second) / time.Second),
+				ClosesAt:         closesAt.Unix(),
+			})
+			if err != nil {
+				return
+			}
+			topic := RoomClosingTopic
+			room.SendDataPacket(&livekit.UserPacket{
+				Payload: payload,
+				Topic:   &topic,
+			}, livekit.DataPacket_RELIABLE)
+		},
+		func() {
+			r.closeRoom(room)
+		},
+	)
+}
+
+// ------------------------------------------------
+
+type roomClosureRequest struct {
+	Room string `json:"room"`
+	// seconds till the room is closed, schedules a closure
+	Delay int `json:"delay,omitempty"`
+	// cancels the scheduled closure
+	Cancel bool `json:"cancel,omitempty"`
+}
+
+type roomClosureResponse struct {
+	Room      string `json:"room"`
+	Scheduled bool   `json:"scheduled"`
+	ClosesAt  int64  `json:"closes_at,omitempty"`
+}
+
+// RoomClosureService schedules, reports and cancels delayed closures of rooms
+type RoomClosureService struct {
+	store  ObjectStore
+	router routing.MessageRouter
+}
+
+func NewRoomClosureService(store ObjectStore, router routing.MessageRouter) *RoomClosureService {
+	return &RoomClosureService{
+		store:  store,
+		router: router,
+	}
+}
+
+func (s *RoomClosureService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req roomClosureRequest
+	switch r.Method {
+	case http.MethodGet:
+		req.Room = r.URL.Query().Get("room")
+	case http.MethodPost:
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			handleError(w, http.StatusBadRequest, err)
+			return
+		}
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	if req.Room == "" {
+		handleError(w, http.StatusBadRequest, ErrRoomNameRequired)
+		return
+	}
+	roomName := livekit.RoomName(req.Room)
+	if err := EnsureCreatePermission(r.Context()); err != nil {
+		handleError(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	closureStore, ok := s.store.(RoomClosureStore)
+	if !ok {
+		handleError(w, http.StatusNotImplemented, ErrClosureUnsupported)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		if _, _, err := s.store.LoadRoom(r.Context(), roomName, false); err != nil {
+			handleError(w, http.StatusNotFound, err, "room", req.Room)
+			return
+		}
+		if req.Cancel {
+			if _, err := cancelRoomClosure(r.Context(), s.store, s.router, roomName); err != nil {
+				handleError(w, http.StatusInternalServerError, err, "room", req.Room)
+				return
+			}
+		} else if _, err := scheduleRoomClosure(r.Context(), s.store, s.router, roomName, time.Duration(req.Delay)*time.Second); err != nil {
+			status := http.StatusInternalServerError
+			if err == ErrInvalidDeleteDelay {
+				status = http.StatusBadRequest
+			}
+			handleError(w, status, err, "room", req.Room, "delay", req.Delay)
+			return
+		}
+	}
+
+	closure, err := closureStore.LoadRoomClosure(r.Context(), roomName)
+	if err != nil {
+		handleError(w, http.StatusInternalServerError, err, "room", req.Room)
+		return
+	}
+
+	res := &roomClosureResponse{Room: req.Room}
+	if closure != nil {
+		res.Scheduled = true
+		res.ClosesAt = closure.ClosesAt
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(res)
+}
+
+// twirpRoomClosureError maps errors of scheduling a closure through DeleteRoom
+func twirpRoomClosureError(err error) error {
+	switch err {
+	case ErrInvalidDeleteDelay:
+		return twirp.InvalidArgumentError(DeleteRoomDelayHeader, err.Error())
+	case ErrClosureUnsupported:
+		return twirp.NewError(twirp.Unimplemented, err.Error())
+	default:
+		return err
+	}
+}
@@ -0,0 +1,220 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+
+	"github.com/livekit/protocol/auth"
+	"github.com/livekit/protocol/livekit"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/routing/routingfakes"
+)
+
+func TestRoomClosureScheduler(t *testing.T) {
+	t.Run("counts down and closes", func(t *testing.T) {
+		c := newRoomClosureScheduler(20 * time.Millisecond)
+		var lock sync.Mutex
+		var announced []int
+		closed := atomic.NewBool(false)
+		c.schedule("class", time.Now().Add(1800*time.Millisecond),
+			func() bool { return true },
+			func(remaining time.Duration, _ time.Time) {
+				lock.Lock()
+				announced = append(announced, int(remaining.Round(time.Second)/time.Second))
+				lock.Unlock()
+			},
+			func() { closed.Store(true) },
+		)
+
+		require.Eventually(t, closed.Load, 3*time.Second, 10*time.Millisecond)
+		lock.Lock()
+		require.Equal(t, []int{2, 1}, announced)
+		lock.Unlock()
+		require.False(t, c.cancel("class"))
+	})
+
+	t.Run("stops once removed from the store", func(t *testing.T) {
+		c := newRoomClosureScheduler(20 * time.Millisecond)
+		scheduled := atomic.NewBool(true)
+		closed := atomic.NewBool(false)
+		c.schedule("class", time.Now().Add(300*time.Millisecond),
+			scheduled.Load,
+			func(time.Duration, time.Time) {},
+			func() { closed.Store(true) },
+		)
+
+		scheduled.Store(false)
+		time.Sleep(500 * time.Millisecond)
+		require.False(t, closed.Load())
+		require.False(t, c.cancel("class"))
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		c := newRoomClosureScheduler(20 * time.Millisecond)
+		closed := atomic.NewBool(false)
+		c.schedule("class", time.Now().Add(200*time.Millisecond),
+			func() bool { return true },
+			func(time.Duration, time.Time) {},
+			func() { closed.Store(true) },
+		)
+
+		require.True(t, c.cancel("class"))
+		time.Sleep(400 * time.Millisecond)
+		require.False(t, closed.Load())
+	})
+
+	t.Run("reschedule replaces the countdown", func(t *testing.T) {
+		c := newRoomClosureScheduler(20 * time.Millisecond)
+		closes := atomic.NewInt32(0)
+		for _, delay := range []time.Duration{200 * time.Millisecond, 400 * time.Millisecond} {
+			c.schedule("class", time.Now().Add(delay),
+				func() bool { return true },
+				func(time.Duration, time.Time) {},
+				func() { closes.Inc() },
+			)
+		}
+
+		time.Sleep(300 * time.Millisecond)
+		require.Zero(t, closes.Load())
+		require.Eventually(t, func() bool { return closes.Load() == 1 }, time.Second, 10*time.Millisecond)
+		time.Sleep(100 * time.Millisecond)
+		require.EqualValues(t, 1, closes.Load())
+	})
+}
+
+func TestRoomClosureService(t *testing.T) {
+	store := NewLocalStore()
+	require.NoError(t, store.StoreRoom(context.Background(), &livekit.Room{Name: "class"}, nil))
+	router := &routingfakes.FakeRouter{}
+	s := NewRoomClosureService(store, router)
+	ctx := WithGrants(context.Background(), &auth.ClaimGrants{Video: &auth.VideoGrant{RoomCreate: true}})
+
+	type step struct {
+		name      string
+		method    string
+		body      string
+		noGrants  bool
+		status    int
+		scheduled bool
+		// RTC message written to the room, if any
+		message func(*livekit.RTCNodeMessage) bool
+	}
+	isDeleteRoom := func(msg *livekit.RTCNodeMessage) bool {
+		return msg.GetDeleteRoom().GetRoom() == "class"
+	}
+	isCancelled := func(msg *livekit.RTCNodeMessage) bool {
+		var closing roomClosingMessage
+		if msg.GetSendData() == nil || json.Unmarshal(msg.GetSendData().Data, &closing) != nil {
+			return false
+		}
+		return closing.Cancelled && msg.GetSendData().GetTopic() == RoomClosingTopic
+	}
+
+	steps := []step{
+		{name: "not scheduled", method: http.MethodGet, status: http.StatusOK},
+		{name: "no permission", method: http.MethodPost, body: `{"room":"class","delay":60}`, noGrants: true, status: http.StatusUnauthorized},
+		{name: "unknown room", method: http.MethodPost, body: `{"room":"other","delay":60}`, status: http.StatusNotFound},
+		{name: "delay out of range", method: http.MethodPost, body: `{"room":"class","delay":90000}`, status: http.StatusBadRequest},
+		{name: "schedule", method: http.MethodPost, body: `{"room":"class","delay":60}`, status: http.StatusOK, scheduled: true, message: isDeleteRoom},
+		{name: "scheduled", method: http.MethodGet, status: http.StatusOK, scheduled: true},
+		{name: "cancel", method: http.MethodPost, body: `{"room":"class","cancel":true}`, status: http.StatusOK, message: isCancelled},
+		{name: "cancelled", method: http.MethodGet, status: http.StatusOK},
+	}
+	for _, st := range steps {
+		t.Run(st.name, func(t *testing.T) {
+			calls := router.WriteRoomRTCCallCount()
+			req := httptest.NewRequest(st.method, roomClosurePath+"?room=class", bytes.NewBufferString(st.body))
+			if !st.noGrants {
+				req = req.WithContext(ctx)
+			}
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, req)
+			require.Equal(t, st.status, w.Code)
+
+			if st.message != nil {
+				require.Equal(t, calls+1, router.WriteRoomRTCCallCount())
+				_, _, msg := router.WriteRoomRTCArgsForCall(calls)
+				require.True(t, st.message(msg))
+			} else {
+				require.Equal(t, calls, router.WriteRoomRTCCallCount())
+			}
+			if st.status != http.StatusOK {
+				return
+			}
+
+			var res roomClosureResponse
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&res))
+			require.Equal(t, st.scheduled, res.Scheduled)
+			closure, err := store.LoadRoomClosure(context.Background(), "class")
+			require.NoError(t, err)
+			if st.scheduled {
+				require.NotNil(t, closure)
+				require.Equal(t, closure.ClosesAt, res.ClosesAt)
+				require.InDelta(t, time.Now().Add(time.Minute).Unix(), res.ClosesAt, 2)
+			} else {
+				require.Nil(t, closure)
+			}
+		})
+	}
+}
+
+func TestDeleteRoomClosure(t *testing.T) {
+	store := NewLocalStore()
+	require.NoError(t, store.StoreRoom(context.Background(), &livekit.Room{Name: "class"}, nil))
+	router := &routingfakes.FakeRouter{}
+	router.WriteRoomRTCCalls(func(ctx context.Context, roomName livekit.RoomName, msg *livekit.RTCNodeMessage) error {
+		// the hosting node closes the room unless a closure is scheduled
+		if closure, _ := store.LoadRoomClosure(ctx, roomName); closure == nil {
+			return store.DeleteRoom(ctx, roomName)
+		}
+		return nil
+	})
+	s, err := NewRoomService(config.RoomConfig{}, config.APIConfig{ExecutionTimeout: time.Second, CheckInterval: 10 * time.Millisecond}, router, nil, store, nil)
+	require.NoError(t, err)
+	ctx := WithGrants(context.Background(), &auth.ClaimGrants{Video: &auth.VideoGrant{RoomCreate: true}})
+
+	// delayed, the closure is kept in the store for the hosting node to count down
+	_, err = s.DeleteRoom(context.WithValue(ctx, deleteRoomDelayKey{}, time.Minute), &livekit.DeleteRoomRequest{Room: "class"})
+	require.NoError(t, err)
+	closure, err := store.LoadRoomClosure(ctx, "class")
+	require.NoError(t, err)
+	require.NotNil(t, closure)
+	_, _, err = store.LoadRoom(ctx, "class", false)
+	require.NoError(t, err)
+
+	_, err = s.DeleteRoom(context.WithValue(ctx, deleteRoomDelayKey{}, 25*time.Hour), &livekit.DeleteRoomRequest{Room: "class"})
+	require.Error(t, err)
+
+	// deleting right away drops the closure
+	_, err = s.DeleteRoom(ctx, &livekit.DeleteRoomRequest{Room: "class"})
+	require.NoError(t, err)
+	closure, err = store.LoadRoomClosure(ctx, "class")
+	require.NoError(t, err)
+	require.Nil(t, closure)
+	_, _, err = store.LoadRoom(ctx, "class", false)
+	require.ErrorIs(t, err, ErrRoomNotFound)
+}
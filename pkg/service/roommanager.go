@@ -17,11 +17,12 @@ package service
 import (
 	"context"
 	"fmt"
-	"os"
 	"sync"
 	"time"
 
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/atomic"
 
 	"github.com/livekit/livekit-server/pkg/telemetry/prometheus"
 	"github.com/livekit/livekit-server/version"
@@ -33,10 +34,12 @@ import (
 
 	"github.com/livekit/livekit-server/pkg/clientconfiguration"
 	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/postroom"
 	"github.com/livekit/livekit-server/pkg/routing"
 	"github.com/livekit/livekit-server/pkg/rtc"
 	"github.com/livekit/livekit-server/pkg/rtc/types"
 	"github.com/livekit/livekit-server/pkg/telemetry"
+	"github.com/livekit/livekit-server/pkg/telemetry/tracing"
 )
 
 const (
@@ -66,10 +69,24 @@ type RoomManager struct {
 	clientConfManager clientconfiguration.ClientConfigurationManager
 	egressLauncher    rtc.EgressLauncher
 	versionGenerator  utils.TimedVersionGenerator
+	publishHook       *PublishHook
+	postRoom          *postroom.Manager
+	budget            *ResourceBudget
+	qosReports        *QoSReports
 
 	rooms map[livekit.RoomName]*rtc.Room
+	// rooms closed to move them to another node, their state is kept
+	migrating map[livekit.RoomName]bool
+	draining  atomic.Bool
+	// rooms closed while redis was unreachable, deleted from the store once it is back
+	unsyncedDeletes map[livekit.RoomName]bool
+	// countdowns of delayed closures of rooms hosted here
+	closures *roomClosureScheduler
 
 	iceConfigCache map[livekit.ParticipantIdentity]*iceConfigCacheEntry
+
+	// config with reloaded settings, room defaults, limits and keys are read from it
+	reloadedConfig atomic.Pointer[config.Config]
 }
 
 func NewLocalRoomManager(
@@ -98,7 +115,10 @@ func NewLocalRoomManager(
 		egressLauncher:    egressLauncher,
 		versionGenerator:  versionGenerator,
 
-		rooms: make(map[livekit.RoomName]*rtc.Room),
+		rooms:           make(map[livekit.RoomName]*rtc.Room),
+		migrating:       make(map[livekit.RoomName]bool),
+		unsyncedDeletes: make(map[livekit.RoomName]bool),
+		closures:        newRoomClosureScheduler(roomClosureCheckInterval),
 
 		iceConfigCache: make(map[livekit.ParticipantIdentity]*iceConfigCacheEntry),
 
@@ -114,6 +134,9 @@ func NewLocalRoomManager(
 	// hook up to router
 	router.OnNewParticipantRTC(r.StartSession)
 	router.OnRTCMessage(r.handleRTCMessage)
+	if dr, ok := router.(routing.DegradedModeRouter); ok {
+		dr.OnRecovered(r.resyncRooms)
+	}
 	return r, nil
 }
 
@@ -123,12 +146,24 @@ func (r *RoomManager) GetRoom(_ context.Context, roomName livekit.RoomName) *rtc
 	return r.rooms[roomName]
 }
 
+// PrewarmTransports pre-warms transports for a room hosted on this node, see rtc.PrewarmTransports
+func (r *RoomManager) PrewarmTransports(ctx context.Context, roomName livekit.RoomName) error {
+	room := r.GetRoom(ctx, roomName)
+	if room == nil || !room.Hold() {
+		return ErrRoomNotFound
+	}
+	defer room.Release()
+
+	return rtc.PrewarmTransports(r.rtcConfig, r.config.RTC.CongestionControl, room.ToProto().EnabledCodecs, room.Logger)
+}
+
 // DeleteRoom completely deletes all room information, including active sessions, room store, and routing info
 func (r *RoomManager) DeleteRoom(ctx context.Context, roomName livekit.RoomName) error {
 	logger.Infow("deleting room state", "room", roomName)
 	r.lock.Lock()
 	delete(r.rooms, roomName)
 	r.lock.Unlock()
+	r.setLocalRoom(roomName, false)
 
 	var err, err2 error
 	wg := sync.WaitGroup{}
@@ -146,6 +181,11 @@ func (r *RoomManager) DeleteRoom(ctx context.Context, roomName livekit.RoomName)
 
 	wg.Wait()
 	if err2 != nil {
+		if degradedRouter(r.router) != nil {
+			r.lock.Lock()
+			r.unsyncedDeletes[roomName] = true
+			r.lock.Unlock()
+		}
 		err = err2
 	}
 
@@ -197,6 +237,135 @@ func (r *RoomManager) HasParticipants() bool {
 	return false
 }
 
+// Drain stops creating rooms on this node, rooms already hosted here carry on
+func (r *RoomManager) Drain() {
+	r.draining.Store(true)
+}
+
+func (r *RoomManager) IsDraining() bool {
+	return r.draining.Load()
+}
+
+// NumRoomsAndParticipants counts the rooms hosted on this node and the participants in them
+func (r *RoomManager) NumRoomsAndParticipants() (int, int) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	numParticipants := 0
+	for _, room := range r.rooms {
+		numParticipants += len(room.GetParticipants())
+	}
+	return len(r.rooms), numParticipants
+}
+
+// MigrateRooms moves the rooms hosted on this node elsewhere. Participants are asked to do a full reconnect,
+// and with the routing of the room cleared, their joins create the room on another node from its stored state.
+func (r *RoomManager) MigrateRooms(ctx context.Context) {
+	r.lock.RLock()
+	rooms := make([]*rtc.Room, 0, len(r.rooms))
+	for _, rm := range r.rooms {
+		rooms = append(rooms, rm)
+	}
+	r.lock.RUnlock()
+
+	for _, room := range rooms {
+		roomName := room.Name()
+		r.lock.Lock()
+		r.migrating[roomName] = true
+		r.lock.Unlock()
+		r.setLocalRoom(roomName, false)
+
+		if err := r.router.ClearRoomState(ctx, roomName); err != nil {
+			room.Logger.Warnw("could not clear room routing", err)
+		}
+		participants := room.GetParticipants()
+		room.Logger.Infow("moving room off draining node", "participants", len(participants))
+		for _, p := range participants {
+			p.IssueFullReconnect(types.ParticipantCloseReasonNodeDraining)
+		}
+		room.Close()
+	}
+}
+
+// finishMigration returns true if the room was closed to be moved to another node, forgetting it locally
+func (r *RoomManager) finishMigration(roomName livekit.RoomName) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if !r.migrating[roomName] {
+		return false
+	}
+	delete(r.migrating, roomName)
+	delete(r.rooms, roomName)
+	return true
+}
+
+// setLocalRoom tells a router with a degraded mode which rooms it keeps routing without redis
+func (r *RoomManager) setLocalRoom(roomName livekit.RoomName, local bool) {
+	if dr, ok := r.router.(routing.DegradedModeRouter); ok {
+		dr.SetLocalRoom(roomName, local)
+	}
+}
+
+// resyncRooms runs once redis is reachable again. Rooms hosted on this node and their participants are stored
+// again, as their updates were lost meanwhile, and the rooms that closed are deleted
+func (r *RoomManager) resyncRooms() {
+	ctx := context.Background()
+
+	r.lock.Lock()
+	deleted := r.unsyncedDeletes
+	r.unsyncedDeletes = make(map[livekit.RoomName]bool)
+	rooms := make([]*rtc.Room, 0, len(r.rooms))
+	for _, room := range r.rooms {
+		rooms = append(rooms, room)
+	}
+	r.lock.Unlock()
+
+	for roomName := range deleted {
+		if err := r.roomStore.DeleteRoom(ctx, roomName); err != nil {
+			logger.Warnw("could not delete room closed while redis was unreachable", err, "room", roomName)
+		}
+	}
+
+	dr, _ := r.router.(routing.DegradedModeRouter)
+	for _, room := range rooms {
+		roomName := room.Name()
+		// placed on another node meanwhile
+		if dr != nil && !dr.IsLocalRoom(roomName) {
+			continue
+		}
+		if err := r.roomStore.StoreRoom(ctx, room.ToProto(), room.Internal()); err != nil {
+			room.Logger.Warnw("could not store room after redis recovered", err)
+			continue
+		}
+
+		participants := room.GetParticipants()
+		present := make(map[livekit.ParticipantIdentity]bool, len(participants))
+		for _, p := range participants {
+			present[p.Identity()] = true
+			if err := r.roomStore.StoreParticipant(ctx, roomName, p.ToProto()); err != nil {
+				room.Logger.Warnw("could not store participant after redis recovered", err, "participant", p.Identity())
+			}
+		}
+		// participants that left while redis was unreachable
+		stored, err := r.roomStore.ListParticipants(ctx, roomName)
+		if err != nil {
+			room.Logger.Warnw("could not list stored participants", err)
+			continue
+		}
+		for _, pi := range stored {
+			identity := livekit.ParticipantIdentity(pi.Identity)
+			if present[identity] {
+				continue
+			}
+			if err = r.roomStore.DeleteParticipant(ctx, roomName, identity); err != nil {
+				room.Logger.Warnw("could not delete participant", err, "participant", identity)
+			}
+		}
+	}
+	logger.Infow("resynchronized rooms after redis recovered", "rooms", len(rooms), "deleted", len(deleted))
+}
+
 func (r *RoomManager) Stop() {
 	// disconnect all clients
 	r.lock.RLock()
@@ -231,7 +400,9 @@ func (r *RoomManager) StartSession(
 	requestSource routing.MessageSource,
 	responseSink routing.MessageSink,
 ) error {
-	room, err := r.getOrCreateRoom(ctx, roomName)
+	roomCtx, span := tracing.Start(ctx, "roomManager.GetOrCreateRoom")
+	room, err := r.getOrCreateRoom(roomCtx, roomName)
+	tracing.End(span, err)
 	if err != nil {
 		return err
 	}
@@ -255,10 +426,8 @@ func (r *RoomManager) StartSession(
 				// a full reconnect when that condition occurred.
 				//
 				// It is possible that the client did not get that send request. So, send it again.
-				logger.Infow("cannot restart a closed participant",
-					"room", roomName,
+				participant.GetLogger().Infow("cannot restart a closed participant",
 					"nodeID", r.currentNode.Id,
-					"participant", pi.Identity,
 					"reason", pi.ReconnectReason,
 				)
 				_ = responseSink.WriteMessage(&livekit.SignalResponse{
@@ -272,10 +441,8 @@ func (r *RoomManager) StartSession(
 				return errors.New("could not restart closed participant")
 			}
 
-			logger.Infow("resuming RTC session",
-				"room", roomName,
+			participant.GetLogger().Infow("resuming RTC session",
 				"nodeID", r.currentNode.Id,
-				"participant", pi.Identity,
 				"reason", pi.ReconnectReason,
 			)
 			iceConfig := r.getIceConfig(participant)
@@ -288,11 +455,12 @@ func (r *RoomManager) StartSession(
 				responseSink,
 				r.iceServersForRoom(
 					protoRoom,
+					participant.ID(),
 					iceConfig.PreferenceSubscriber == livekit.ICECandidateType_ICT_TLS,
 				),
 				pi.ReconnectReason,
 			); err != nil {
-				logger.Warnw("could not resume participant", err, "participant", pi.Identity)
+				participant.GetLogger().Warnw("could not resume participant", err)
 				return err
 			}
 			r.telemetry.ParticipantResumed(ctx, room.ToProto(), participant.ToProto(), livekit.NodeID(r.currentNode.Id), pi.ReconnectReason)
@@ -315,10 +483,29 @@ func (r *RoomManager) StartSession(
 			},
 		})
 		return errors.New("could not restart participant")
+	} else if err = checkRoomSeal(ctx, r.roomStore, roomName, &pi); err != nil {
+		// seal was set after the join was validated
+		_ = responseSink.WriteMessage(&livekit.SignalResponse{
+			Message: &livekit.SignalResponse_Leave{
+				Leave: &livekit.LeaveRequest{
+					Reason: livekit.DisconnectReason_JOIN_FAILURE,
+				},
+			},
+		})
+		return err
+	} else if r.budget.IsRefusingJoins() {
+		_ = responseSink.WriteMessage(&livekit.SignalResponse{
+			Message: &livekit.SignalResponse_Leave{
+				Leave: &livekit.LeaveRequest{
+					Reason: livekit.DisconnectReason_JOIN_FAILURE,
+				},
+			},
+		})
+		return ErrResourceBudget
 	}
 
-	logger.Debugw("starting RTC session",
-		"room", roomName,
+	rLogger := rtc.LoggerWithRoom(logger.GetLogger(), room.Name(), room.ID())
+	rLogger.Debugw("starting RTC session",
 		"nodeID", r.currentNode.Id,
 		"participant", pi.Identity,
 		"sdk", pi.Client.Sdk,
@@ -336,7 +523,7 @@ func (r *RoomManager) StartSession(
 	rtcConf.SetBufferFactory(room.GetBufferFactory())
 	sid := livekit.ParticipantID(utils.NewGuid(utils.ParticipantPrefix))
 	pLogger := rtc.LoggerWithParticipant(
-		rtc.LoggerWithRoom(logger.GetLogger(), room.Name(), room.ID()),
+		rLogger,
 		pi.Identity,
 		sid,
 		false)
@@ -373,12 +560,13 @@ func (r *RoomManager) StartSession(
 		AudioConfig:             r.config.Audio,
 		VideoConfig:             r.config.Video,
 		ProtocolVersion:         pv,
-		Telemetry:               r.telemetry,
+		Telemetry:               room.QoSTelemetry(r.telemetry),
 		Trailer:                 room.Trailer(),
 		PLIThrottleConfig:       r.config.RTC.PLIThrottle,
 		CongestionControlConfig: r.config.RTC.CongestionControl,
 		EnabledCodecs:           protoRoom.EnabledCodecs,
 		Grants:                  pi.Grants,
+		Entitlements:            pi.Entitlements,
 		Logger:                  pLogger,
 		ClientConf:              clientConf,
 		ClientInfo:              rtc.ClientInfo{ClientInfo: pi.Client},
@@ -398,9 +586,21 @@ func (r *RoomManager) StartSession(
 		VersionGenerator:             r.versionGenerator,
 		TrackResolver:                room.ResolveMediaTrackForSubscriber,
 		SubscriberAllowPause:         subscriberAllowPause,
-		SubscriptionLimitAudio:       r.config.Limit.SubscriptionLimitAudio,
-		SubscriptionLimitVideo:       r.config.Limit.SubscriptionLimitVideo,
+		SubscriptionLimitAudio:       r.liveConfig().Limit.SubscriptionLimitAudio,
+		SubscriptionLimitVideo:       r.liveConfig().Limit.SubscriptionLimitVideo,
 		PlayoutDelay:                 protoRoom.PlayoutDelay,
+		PublishBitrateLimits:         r.config.RTC.PublishBitrateLimits,
+		RTCPFeedback:                 r.config.RTC.RTCPFeedback,
+		Watchdog:                     r.config.RTC.Watchdog,
+		MuteReconciliation:           r.config.RTC.MuteReconciliation,
+		AuthorizePublish:             r.authorizePublishFunc(room.Name()),
+		LoadBalanceThreshold:         room.LoadBalanceThreshold(),
+		AllowImpairment:              r.config.Development,
+		DisableOpusFEC:               !room.OpusFECEnabled(),
+		TranscodeBitrate:             room.TranscodeBitrate(),
+		REDEncoding:                  room.REDEncoding(),
+		PublicClaims:                 pi.PublicClaims,
+		PublicClaimsKey:              r.liveConfig().Room.PublicClaims.MetadataKey,
 	})
 	if err != nil {
 		return err
@@ -411,7 +611,10 @@ func (r *RoomManager) StartSession(
 	opts := rtc.ParticipantOptions{
 		AutoSubscribe: pi.AutoSubscribe,
 	}
-	if err = room.Join(participant, requestSource, &opts, r.iceServersForRoom(protoRoom, iceConfig.PreferenceSubscriber == livekit.ICECandidateType_ICT_TLS)); err != nil {
+	_, span = tracing.Start(ctx, "room.Join", attribute.String("participantID", string(sid)))
+	err = room.Join(participant, requestSource, &opts, r.iceServersForRoom(protoRoom, sid, iceConfig.PreferenceSubscriber == livekit.ICECandidateType_ICT_TLS))
+	tracing.End(span, err)
+	if err != nil {
 		pLogger.Errorw("could not join room", err)
 		_ = participant.Close(true, types.ParticipantCloseReasonJoinFailed, false)
 		return err
@@ -424,7 +627,7 @@ func (r *RoomManager) StartSession(
 		if !participant.Hidden() {
 			err = r.roomStore.StoreRoom(ctx, proto, room.Internal())
 			if err != nil {
-				logger.Errorw("could not store room", err)
+				pLogger.Errorw("could not store room", err)
 			}
 		}
 	}
@@ -438,6 +641,11 @@ func (r *RoomManager) StartSession(
 		if err := r.roomStore.DeleteParticipant(ctx, roomName, p.Identity()); err != nil {
 			pLogger.Errorw("could not delete participant", err)
 		}
+		if store := r.resumeTokenStore(); store != nil {
+			if err := store.DeleteResumeToken(ctx, p.ID()); err != nil {
+				pLogger.Warnw("could not delete resume token", err)
+			}
+		}
 
 		// update room store with new numParticipants
 		proto := room.ToProto()
@@ -447,7 +655,7 @@ func (r *RoomManager) StartSession(
 	participant.OnClaimsChanged(func(participant types.LocalParticipant) {
 		pLogger.Debugw("refreshing client token after claims change")
 		if err := r.refreshToken(participant); err != nil {
-			logger.Errorw("could not refresh token", err)
+			pLogger.Errorw("could not refresh token", err)
 		}
 	})
 	participant.OnICEConfigChanged(func(participant types.LocalParticipant, iceConfig *livekit.ICEConfig) {
@@ -463,6 +671,24 @@ func (r *RoomManager) StartSession(
 	return nil
 }
 
+// loadRoomTemplate returns the template the room was created with, if any
+func (r *RoomManager) loadRoomTemplate(ctx context.Context, roomName livekit.RoomName) *config.RoomTemplate {
+	ts, ok := r.roomStore.(RoomTemplateStore)
+	if !ok {
+		return nil
+	}
+	name, err := ts.LoadRoomTemplate(ctx, roomName)
+	if err != nil || name == "" {
+		return nil
+	}
+	tmpl, err := r.liveConfig().Room.ResolveTemplate(name)
+	if err != nil {
+		logger.Warnw("could not resolve room template", err, "room", roomName, "template", name)
+		return nil
+	}
+	return tmpl
+}
+
 // create the actual room object, to be used on RTC node
 func (r *RoomManager) getOrCreateRoom(ctx context.Context, roomName livekit.RoomName) (*rtc.Room, error) {
 	r.lock.RLock()
@@ -478,6 +704,7 @@ func (r *RoomManager) getOrCreateRoom(ctx context.Context, roomName livekit.Room
 	if err != nil {
 		return nil, err
 	}
+	tmpl := r.loadRoomTemplate(ctx, roomName)
 
 	r.lock.Lock()
 
@@ -493,16 +720,56 @@ func (r *RoomManager) getOrCreateRoom(ctx context.Context, roomName livekit.Room
 		currentRoom = r.rooms[roomName]
 	}
 
+	if r.draining.Load() {
+		r.lock.Unlock()
+		return nil, ErrNodeDraining
+	}
+
 	// construct ice servers
 	newRoom := rtc.NewRoom(ri, internal, *r.rtcConfig, &r.config.Audio, r.serverInfo, r.telemetry, r.egressLauncher)
+	roomConf := &r.liveConfig().Room
+	newRoom.SetMaxEgressBitrate(int64(roomConf.MaxEgressBitrate))
+	newRoom.SetSubscriberBandwidthOverride(roomConf.SubscriberBandwidth)
+	newRoom.SetUpdateThrottle(roomConf.UpdateThrottle)
+	newRoom.SetDepartureTimeout(roomConf.DepartureTimeout)
+	newRoom.SetWelcomePacket(reconnectPolicyPacket(&r.config.Reconnect))
+	newRoom.SetClientPing(r.config.Heartbeat.ClientPingInterval, r.config.Heartbeat.ClientPingTimeout)
+	if err := newRoom.SetVideoAllocation(roomConf.VideoAllocation); err != nil {
+		newRoom.Logger.Warnw("could not set video allocation", err)
+	}
+	if err := newRoom.SetPausedVideoPlaceholder(roomConf.PausedVideoPlaceholder); err != nil {
+		newRoom.Logger.Warnw("could not set paused video placeholder", err)
+	}
+	newRoom.SetE2EE(roomConf.E2EE)
+	newRoom.SetOpusFEC(!roomConf.DisableOpusFEC)
+	if tmpl != nil && tmpl.Broadcast != nil {
+		newRoom.SetBroadcast(tmpl.Broadcast.MaxPublishers, tmpl.Broadcast.FanOutThreshold)
+	}
+	if tmpl != nil && tmpl.Lossy != nil {
+		newRoom.SetLossy(int64(tmpl.Lossy.FECHeadroom))
+		newRoom.SetREDEncoding(tmpl.Lossy.REDEncoding)
+	}
+	if tmpl != nil && tmpl.Transcoding != nil {
+		newRoom.SetTranscoding(int64(tmpl.Transcoding.Bitrate))
+	}
+	if r.qosReports.Enabled() {
+		newRoom.EnableQoSReport()
+	}
 
 	newRoom.OnClose(func() {
+		r.closures.cancel(roomName)
 		roomInfo := newRoom.ToProto()
-		r.telemetry.RoomEnded(ctx, roomInfo)
 		prometheus.RoomEnded(time.Unix(roomInfo.CreationTime, 0))
+		if r.finishMigration(roomName) {
+			newRoom.Logger.Infow("room moved off draining node")
+			return
+		}
+		// room_finished references the QoS report once stored
+		r.telemetry.RoomEnded(r.qosReports.Store(ctx, roomInfo, newRoom.QoSReport()), roomInfo)
 		if err := r.DeleteRoom(ctx, roomName); err != nil {
 			newRoom.Logger.Errorw("could not delete room", err)
 		}
+		r.postRoom.RoomFinished(newPostRoomSummary(roomInfo, newRoom.SpeakerStats()))
 
 		newRoom.Logger.Infow("room closed")
 	})
@@ -522,13 +789,16 @@ func (r *RoomManager) getOrCreateRoom(ctx context.Context, roomName livekit.Room
 	})
 
 	r.rooms[roomName] = newRoom
+	delete(r.unsyncedDeletes, roomName)
 
 	r.lock.Unlock()
+	r.setLocalRoom(roomName, true)
 
 	newRoom.Hold()
 
 	r.telemetry.RoomStarted(ctx, newRoom.ToProto())
 	prometheus.RoomStarted()
+	r.resumeRoomClosure(ctx, newRoom)
 
 	return newRoom, nil
 }
@@ -547,8 +817,8 @@ func (r *RoomManager) rtcSessionWorker(room *rtc.Room, participant types.LocalPa
 	}()
 
 	defer func() {
-		if r := rtc.Recover(pLogger); r != nil {
-			os.Exit(1)
+		if r := recover(); r != nil {
+			rtc.ReportParticipantPanic(participant, room.Name(), "session", r)
 		}
 	}()
 
@@ -556,6 +826,13 @@ func (r *RoomManager) rtcSessionWorker(room *rtc.Room, participant types.LocalPa
 	_ = r.refreshToken(participant)
 	tokenTicker := time.NewTicker(tokenRefreshInterval)
 	defer tokenTicker.Stop()
+	var resumeTokenC <-chan time.Time
+	if r.resumeTokenStore() != nil {
+		resumeTicker := time.NewTicker(resumeTokenRenewInterval(r.config.ResumeToken.TTL))
+		defer resumeTicker.Stop()
+		resumeTokenC = resumeTicker.C
+	}
+	staleTimeout := r.config.Heartbeat.StaleTimeout
 	stateCheckTicker := time.NewTicker(time.Millisecond * 500)
 	defer stateCheckTicker.Stop()
 	for {
@@ -565,11 +842,20 @@ func (r *RoomManager) rtcSessionWorker(room *rtc.Room, participant types.LocalPa
 			if participant.IsDisconnected() {
 				return
 			}
+			// neither signal nor media heard from the client, remove it rather than wait for transports to time out
+			if staleTimeout > 0 && participant.IsUnresponsive(staleTimeout) {
+				pLogger.Infow("removing unresponsive participant", "connID", requestSource.ConnectionID(), "staleTimeout", staleTimeout)
+				prometheus.RecordDeadPeer()
+				room.RemoveParticipant(participant.Identity(), participant.ID(), types.ParticipantCloseReasonDeadPeer)
+				return
+			}
 		case <-tokenTicker.C:
 			// refresh token with the first API Key/secret pair
 			if err := r.refreshToken(participant); err != nil {
 				pLogger.Errorw("could not refresh token", err, "connID", requestSource.ConnectionID())
 			}
+		case <-resumeTokenC:
+			r.renewResumeToken(participant)
 		case obj := <-requestSource.ReadChan():
 			// In single node mode, the request source is directly tied to the signal message channel
 			// this means ICE restart isn't possible in single node mode
@@ -598,11 +884,16 @@ func (r *RoomManager) handleRTCMessage(ctx context.Context, roomName livekit.Roo
 
 	if room == nil {
 		if _, ok := msg.Message.(*livekit.RTCNodeMessage_DeleteRoom); ok {
+			if closure := r.loadRoomClosure(ctx, roomName); closure != nil && time.Unix(closure.ClosesAt, 0).After(time.Now()) {
+				// counted down once participants join
+				logger.Debugw("room closure scheduled for non-rtc room", "room", roomName)
+				return
+			}
 			// special case of a non-RTC room e.g. room created but no participants joined
-			logger.Debugw("Deleting non-rtc room, loading from roomstore")
+			logger.Debugw("Deleting non-rtc room, loading from roomstore", "room", roomName)
 			err := r.roomStore.DeleteRoom(ctx, roomName)
 			if err != nil {
-				logger.Debugw("Error deleting non-rtc room", "err", err)
+				logger.Debugw("Error deleting non-rtc room", "room", roomName, "err", err)
 			}
 			return
 		} else {
@@ -637,7 +928,7 @@ func (r *RoomManager) handleRTCMessage(ctx context.Context, roomName livekit.Roo
 		}
 		pLogger.Debugw("setting track muted",
 			"trackID", rm.MuteTrack.TrackSid, "muted", rm.MuteTrack.Muted)
-		if !rm.MuteTrack.Muted && !r.config.Room.EnableRemoteUnmute {
+		if !rm.MuteTrack.Muted && !r.liveConfig().Room.EnableRemoteUnmute {
 			pLogger.Errorw("cannot unmute track, remote unmute is disabled", nil)
 			return
 		}
@@ -653,11 +944,12 @@ func (r *RoomManager) handleRTCMessage(ctx context.Context, roomName livekit.Roo
 			participant.SetPermission(rm.UpdateParticipant.Permission)
 		}
 	case *livekit.RTCNodeMessage_DeleteRoom:
-		room.Logger.Infow("deleting room")
-		for _, p := range room.GetParticipants() {
-			_ = p.Close(true, types.ParticipantCloseReasonServiceRequestDeleteRoom, false)
+		if closure := r.loadRoomClosure(ctx, roomName); closure != nil && time.Unix(closure.ClosesAt, 0).After(time.Now()) {
+			r.startRoomClosure(room, closure)
+			return
 		}
-		room.Close()
+		r.closures.cancel(roomName)
+		r.closeRoom(room)
 	case *livekit.RTCNodeMessage_UpdateSubscriptions:
 		if participant == nil {
 			return
@@ -684,7 +976,16 @@ func (r *RoomManager) handleRTCMessage(ctx context.Context, roomName livekit.Roo
 	}
 }
 
-func (r *RoomManager) iceServersForRoom(ri *livekit.Room, tlsOnly bool) []*livekit.ICEServer {
+// closeRoom disconnects all participants and closes the room
+func (r *RoomManager) closeRoom(room *rtc.Room) {
+	room.Logger.Infow("deleting room")
+	for _, p := range room.GetParticipants() {
+		_ = p.Close(true, types.ParticipantCloseReasonServiceRequestDeleteRoom, false)
+	}
+	room.Close()
+}
+
+func (r *RoomManager) iceServersForRoom(ri *livekit.Room, participantID livekit.ParticipantID, tlsOnly bool) []*livekit.ICEServer {
 	var iceServers []*livekit.ICEServer
 	rtcConf := r.config.RTC
 
@@ -695,19 +996,15 @@ func (r *RoomManager) iceServersForRoom(ri *livekit.Room, tlsOnly bool) []*livek
 
 	hasSTUN := false
 	if r.config.TURN.Enabled {
-		var urls []string
+		urls := embeddedTURNURLs(r.config, tlsOnly)
 		if r.config.TURN.UDPPort > 0 && !tlsOnly {
 			// UDP TURN is used as STUN
 			hasSTUN = true
-			urls = append(urls, fmt.Sprintf("turn:%s:%d?transport=udp", r.config.RTC.NodeIP, r.config.TURN.UDPPort))
-		}
-		if r.config.TURN.TLSPort > 0 {
-			urls = append(urls, fmt.Sprintf("turns:%s:443?transport=tcp", r.config.TURN.Domain))
 		}
 		if len(urls) > 0 {
 			iceServers = append(iceServers, &livekit.ICEServer{
 				Urls:       urls,
-				Username:   ri.Name,
+				Username:   turnUsername(livekit.RoomName(ri.Name), participantID),
 				Credential: ri.TurnPassword,
 			})
 		}
@@ -745,8 +1042,32 @@ func (r *RoomManager) iceServersForRoom(ri *livekit.Room, tlsOnly bool) []*livek
 	return iceServers
 }
 
+// embeddedTURNURLs returns the URLs of the TURN server embedded in this node
+func embeddedTURNURLs(conf *config.Config, tlsOnly bool) []string {
+	var urls []string
+	if conf.TURN.UDPPort > 0 && !tlsOnly {
+		urls = append(urls, fmt.Sprintf("turn:%s:%d?transport=udp", conf.RTC.NodeIP, conf.TURN.UDPPort))
+	}
+	if conf.TURN.TLSPort > 0 {
+		urls = append(urls, fmt.Sprintf("turns:%s:443?transport=tcp", conf.TURN.Domain))
+	}
+	return urls
+}
+
+// ReloadConfig applies reloaded room defaults and limits to rooms and participants created from now on
+func (r *RoomManager) ReloadConfig(conf *config.Config) {
+	r.reloadedConfig.Store(conf)
+}
+
+func (r *RoomManager) liveConfig() *config.Config {
+	if conf := r.reloadedConfig.Load(); conf != nil {
+		return conf
+	}
+	return r.config
+}
+
 func (r *RoomManager) refreshToken(participant types.LocalParticipant) error {
-	for key, secret := range r.config.Keys {
+	for key, secret := range r.liveConfig().Keys {
 		grants := participant.ClaimGrants()
 		token := auth.NewAccessToken(key, secret)
 		token.SetName(grants.Name).
@@ -766,6 +1087,69 @@ func (r *RoomManager) refreshToken(participant types.LocalParticipant) error {
 	return nil
 }
 
+// resumeTokenStore returns the store of resume tokens, nil when they are disabled
+func (r *RoomManager) resumeTokenStore() ResumeTokenStore {
+	if !r.config.ResumeToken.Enabled {
+		return nil
+	}
+	store, _ := r.roomStore.(ResumeTokenStore)
+	return store
+}
+
+// renewResumeToken keeps the resume token issued on join alive while the participant's session is
+func (r *RoomManager) renewResumeToken(participant types.LocalParticipant) {
+	store := r.resumeTokenStore()
+	if store == nil {
+		return
+	}
+
+	if err := store.RenewResumeToken(context.Background(), participant.ID(), r.config.ResumeToken.TTL); err != nil {
+		participant.GetLogger().Warnw("could not renew resume token", err)
+	}
+}
+
+// SetPublishHook sets the service authorizing publications of participants joining afterwards
+func (r *RoomManager) SetPublishHook(hook *PublishHook) {
+	r.publishHook = hook
+}
+
+// SetPostRoom sets the pipelines run once rooms have finished
+func (r *RoomManager) SetPostRoom(m *postroom.Manager) {
+	r.postRoom = m
+}
+
+// SetResourceBudget refuses new participants while the node is over budget
+func (r *RoomManager) SetResourceBudget(budget *ResourceBudget) {
+	r.budget = budget
+}
+
+// SetQoSReports has a QoS report stored for rooms created from now on, when they close
+func (r *RoomManager) SetQoSReports(reports *QoSReports) {
+	r.qosReports = reports
+}
+
+func newPostRoomSummary(room *livekit.Room, speakers []rtc.SpeakerStat) *postroom.Summary {
+	endedAt := time.Now().Unix()
+	return &postroom.Summary{
+		RoomName:  livekit.RoomName(room.Name),
+		RoomID:    livekit.RoomID(room.Sid),
+		Metadata:  room.Metadata,
+		CreatedAt: room.CreationTime,
+		EndedAt:   endedAt,
+		Duration:  endedAt - room.CreationTime,
+		Speakers:  speakers,
+	}
+}
+
+func (r *RoomManager) authorizePublishFunc(roomName livekit.RoomName) rtc.AuthorizePublishFunc {
+	if r.publishHook == nil {
+		return nil
+	}
+	return func(participant types.LocalParticipant, req *livekit.AddTrackRequest) *rtc.PublishDecision {
+		return r.publishHook.Authorize(roomName, participant, req)
+	}
+}
+
 func (r *RoomManager) setIceConfig(participant types.LocalParticipant) *livekit.ICEConfig {
 	iceConfig := r.getIceConfig(participant)
 	if iceConfig == nil {
@@ -0,0 +1,178 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/logger"
+
+	"github.com/livekit/livekit-server/pkg/config"
+)
+
+// PersistedRoom is a room with the settings that were stored for it
+type PersistedRoom struct {
+	Room     *livekit.Room
+	Internal *livekit.RoomInternal
+	Template string
+	Regions  []string
+	Seal     *RoomSeal
+}
+
+// RoomPersister mirrors the rooms of the room store to a RoomPersistence, and restores rooms missing from
+// the room store when started, e.g. after Redis lost its data in a restart of the whole cluster
+type RoomPersister struct {
+	store       ObjectStore
+	persistence RoomPersistence
+	interval    time.Duration
+	done        chan struct{}
+}
+
+func NewRoomPersister(conf *config.Config, store ObjectStore) (*RoomPersister, error) {
+	if !conf.Persistence.IsConfigured() {
+		return nil, nil
+	}
+
+	db, err := sql.Open(conf.Persistence.Driver, conf.Persistence.DSN)
+	if err != nil {
+		return nil, err
+	}
+	persistence, err := NewSQLRoomStore(db)
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return &RoomPersister{
+		store:       store,
+		persistence: persistence,
+		interval:    conf.Persistence.SyncInterval,
+		done:        make(chan struct{}),
+	}, nil
+}
+
+func (p *RoomPersister) Start() error {
+	if p == nil {
+		return nil
+	}
+	if err := p.restore(context.Background()); err != nil {
+		return err
+	}
+	go p.syncWorker()
+	return nil
+}
+
+// Stop stops syncing without a last sync, so that rooms closed by shutting down the node stay persisted
+func (p *RoomPersister) Stop() {
+	if p == nil {
+		return
+	}
+	close(p.done)
+	_ = p.persistence.Close()
+}
+
+func (p *RoomPersister) restore(ctx context.Context) error {
+	rooms, err := p.persistence.LoadRooms(ctx)
+	if err != nil {
+		return err
+	}
+
+	restored := 0
+	for _, pr := range rooms {
+		roomName := livekit.RoomName(pr.Room.Name)
+		if _, _, err = p.store.LoadRoom(ctx, roomName, false); err == nil {
+			continue
+		} else if !errors.Is(err, ErrRoomNotFound) {
+			return err
+		}
+
+		if err = p.store.StoreRoom(ctx, pr.Room, pr.Internal); err != nil {
+			return err
+		}
+		if ts, ok := p.store.(RoomTemplateStore); ok && pr.Template != "" {
+			if err = ts.StoreRoomTemplate(ctx, roomName, pr.Template); err != nil {
+				return err
+			}
+		}
+		if rs, ok := p.store.(RoomRegionStore); ok && len(pr.Regions) != 0 {
+			if err = rs.StoreRoomRegions(ctx, roomName, pr.Regions); err != nil {
+				return err
+			}
+		}
+		if ss, ok := p.store.(RoomSealStore); ok && pr.Seal != nil {
+			if err = ss.StoreRoomSeal(ctx, pr.Seal); err != nil {
+				return err
+			}
+		}
+		restored++
+	}
+	logger.Infow("restored persisted rooms", "persisted", len(rooms), "restored", restored)
+	return nil
+}
+
+func (p *RoomPersister) syncWorker() {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-ticker.C:
+			if err := p.sync(context.Background()); err != nil {
+				logger.Warnw("could not persist rooms", err)
+			}
+		}
+	}
+}
+
+func (p *RoomPersister) sync(ctx context.Context) error {
+	rooms, err := p.store.ListRooms(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	persisted := make([]*PersistedRoom, 0, len(rooms))
+	for _, room := range rooms {
+		roomName := livekit.RoomName(room.Name)
+		pr := &PersistedRoom{Room: room}
+		if _, pr.Internal, err = p.store.LoadRoom(ctx, roomName, true); errors.Is(err, ErrRoomNotFound) {
+			// closed since listed
+			continue
+		} else if err != nil {
+			return err
+		}
+		if ts, ok := p.store.(RoomTemplateStore); ok {
+			if pr.Template, err = ts.LoadRoomTemplate(ctx, roomName); err != nil {
+				return err
+			}
+		}
+		if rs, ok := p.store.(RoomRegionStore); ok {
+			if pr.Regions, err = rs.LoadRoomRegions(ctx, roomName); err != nil {
+				return err
+			}
+		}
+		if ss, ok := p.store.(RoomSealStore); ok {
+			if pr.Seal, err = ss.LoadRoomSeal(ctx, roomName); err != nil {
+				return err
+			}
+		}
+		persisted = append(persisted, pr)
+	}
+	return p.persistence.SaveRooms(ctx, persisted)
+}
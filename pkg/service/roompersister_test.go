@@ -0,0 +1,74 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/protocol/livekit"
+)
+
+type memoryRoomPersistence struct {
+	rooms []*PersistedRoom
+}
+
+func (m *memoryRoomPersistence) SaveRooms(_ context.Context, rooms []*PersistedRoom) error {
+	m.rooms = rooms
+	return nil
+}
+
+func (m *memoryRoomPersistence) LoadRooms(_ context.Context) ([]*PersistedRoom, error) {
+	return m.rooms, nil
+}
+
+func (m *memoryRoomPersistence) Close() error {
+	return nil
+}
+
+func TestRoomPersister(t *testing.T) {
+	ctx := context.Background()
+	persistence := &memoryRoomPersistence{}
+
+	store := NewLocalStore()
+	require.NoError(t, store.StoreRoom(ctx, &livekit.Room{Name: "standup", Metadata: "daily"}, nil))
+	require.NoError(t, store.StoreRoomTemplate(ctx, "standup", "meeting"))
+	require.NoError(t, store.StoreRoomSeal(ctx, &RoomSeal{Room: "standup", SealedAt: 1}))
+	p := &RoomPersister{store: store, persistence: persistence}
+	require.NoError(t, p.sync(ctx))
+	require.Len(t, persistence.rooms, 1)
+
+	// a new store after a restart gets the room back
+	restarted := NewLocalStore()
+	p = &RoomPersister{store: restarted, persistence: persistence}
+	require.NoError(t, p.restore(ctx))
+
+	room, _, err := restarted.LoadRoom(ctx, "standup", false)
+	require.NoError(t, err)
+	require.Equal(t, "daily", room.Metadata)
+	template, err := restarted.LoadRoomTemplate(ctx, "standup")
+	require.NoError(t, err)
+	require.Equal(t, "meeting", template)
+	seal, err := restarted.LoadRoomSeal(ctx, "standup")
+	require.NoError(t, err)
+	require.NotNil(t, seal)
+
+	// closed rooms are no longer persisted
+	require.NoError(t, restarted.DeleteRoom(ctx, "standup"))
+	require.NoError(t, p.sync(ctx))
+	require.Empty(t, persistence.rooms)
+}
@@ -0,0 +1,123 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/livekit/protocol/livekit"
+
+	"github.com/livekit/livekit-server/pkg/config"
+)
+
+const (
+	// CreateRoom requests carrying this header pin the room to the listed regions, separated by commas.
+	// The room is only hosted by nodes of these regions, joins through other regions are redirected
+	RoomRegionsHeader = "Livekit-Room-Regions"
+)
+
+type roomRegionsKey struct{}
+
+// WithRoomRegions passes the room regions header of Twirp requests on to RoomService
+func WithRoomRegions(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if header := r.Header.Get(RoomRegionsHeader); header != "" {
+			regions := parseRoomRegions(header)
+			if len(regions) == 0 {
+				handleError(w, http.StatusBadRequest, ErrInvalidRoomRegions, "regions", header)
+				return
+			}
+			r = r.WithContext(context.WithValue(r.Context(), roomRegionsKey{}, regions))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func roomRegionsFromContext(ctx context.Context) []string {
+	regions, _ := ctx.Value(roomRegionsKey{}).([]string)
+	return regions
+}
+
+func parseRoomRegions(header string) []string {
+	var regions []string
+	for _, region := range strings.Split(header, ",") {
+		if region = strings.TrimSpace(region); region != "" {
+			regions = append(regions, region)
+		}
+	}
+	return regions
+}
+
+func inRegions(region string, regions []string) bool {
+	if len(regions) == 0 {
+		return true
+	}
+	for _, r := range regions {
+		if r == region {
+			return true
+		}
+	}
+	return false
+}
+
+// filterNodesByRegion returns the nodes in one of the regions, all nodes when regions is empty
+func filterNodesByRegion(nodes []*livekit.Node, regions []string) []*livekit.Node {
+	if len(regions) == 0 {
+		return nodes
+	}
+	var filtered []*livekit.Node
+	for _, node := range nodes {
+		if inRegions(node.Region, regions) {
+			filtered = append(filtered, node)
+		}
+	}
+	return filtered
+}
+
+// roomRegionRedirect is returned by join validation when the room is pinned to regions the node is not in
+type roomRegionRedirect struct {
+	url string
+}
+
+func (e *roomRegionRedirect) Error() string {
+	return "room is pinned to another region, connect to " + e.url
+}
+
+// checkRoomRegions returns a redirect to the signal URL of the first allowed region when the room is pinned to
+// regions the node is not in, or ErrRoomPinnedToRegion when none of them has a URL configured
+func checkRoomRegions(ctx context.Context, store ServiceStore, conf *config.NodeSelectorConfig, roomName livekit.RoomName, region string, r *http.Request) error {
+	regionStore, ok := store.(RoomRegionStore)
+	if !ok {
+		return nil
+	}
+	regions, err := regionStore.LoadRoomRegions(ctx, roomName)
+	if err != nil {
+		return err
+	}
+	if inRegions(region, regions) {
+		return nil
+	}
+
+	for _, allowed := range regions {
+		for _, rc := range conf.Regions {
+			if rc.Name == allowed && rc.URL != "" {
+				return &roomRegionRedirect{url: strings.TrimSuffix(rc.URL, "/") + r.URL.RequestURI()}
+			}
+		}
+	}
+	return ErrRoomPinnedToRegion
+}
@@ -0,0 +1,339 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protojson"
+
+	"github.com/livekit/protocol/auth"
+	"github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/logger"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/telemetry"
+)
+
+const (
+	roomSchedulePath = "/rooms/schedule"
+
+	// sent when a scheduled room was created, and when it was closed at the end of its duration
+	EventScheduledRoomStarted = "scheduled_room_started"
+	EventScheduledRoomEnded   = "scheduled_room_ended"
+
+	roomScheduleCheckInterval = time.Second
+	roomScheduleLockTimeout   = 10 * time.Second
+)
+
+// ScheduledRoom is a room created at StartAt, and closed after Duration seconds unless 0
+type ScheduledRoom struct {
+	Room            string `json:"room"`
+	StartAt         int64  `json:"start_at"`
+	Duration        uint32 `json:"duration,omitempty"`
+	EmptyTimeout    uint32 `json:"empty_timeout,omitempty"`
+	MaxParticipants uint32 `json:"max_participants,omitempty"`
+	Metadata        string `json:"metadata,omitempty"`
+	Template        string `json:"template,omitempty"`
+	// livekit.RoomEgress in its JSON form, egress started with the room
+	Egress json.RawMessage `json:"egress,omitempty"`
+	// API key the room was scheduled with, the room counts as created with it
+	APIKey    string `json:"api_key,omitempty"`
+	StartedAt int64  `json:"started_at,omitempty"`
+}
+
+func (sr *ScheduledRoom) createRoomRequest() (*livekit.CreateRoomRequest, error) {
+	req := &livekit.CreateRoomRequest{
+		Name:            sr.Room,
+		EmptyTimeout:    sr.EmptyTimeout,
+		MaxParticipants: sr.MaxParticipants,
+		Metadata:        sr.Metadata,
+	}
+	if len(sr.Egress) != 0 {
+		req.Egress = &livekit.RoomEgress{}
+		if err := protojson.Unmarshal(sr.Egress, req.Egress); err != nil {
+			return nil, err
+		}
+	}
+	return req, nil
+}
+
+// RoomScheduler creates scheduled rooms when they are due and closes them once their duration passed.
+// Every node checks the schedule, a lock on the scheduled room makes sure only one of them acts on it.
+// Rooms are scheduled through POST, listed through GET and unscheduled through DELETE
+type RoomScheduler struct {
+	conf        *config.RoomConfig
+	roomService livekit.RoomService
+	store       ObjectStore
+	telemetry   telemetry.TelemetryService
+	done        chan struct{}
+}
+
+func NewRoomScheduler(conf *config.RoomConfig, roomService livekit.RoomService, store ObjectStore, telemetry telemetry.TelemetryService) *RoomScheduler {
+	return &RoomScheduler{
+		conf:        conf,
+		roomService: roomService,
+		store:       store,
+		telemetry:   telemetry,
+		done:        make(chan struct{}),
+	}
+}
+
+func (s *RoomScheduler) Start() {
+	if _, ok := s.store.(ScheduledRoomStore); ok {
+		go s.worker()
+	}
+}
+
+func (s *RoomScheduler) Stop() {
+	close(s.done)
+}
+
+func (s *RoomScheduler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	store, ok := s.store.(ScheduledRoomStore)
+	if !ok {
+		handleError(w, http.StatusNotImplemented, ErrScheduleUnsupported)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		if err := EnsureListPermission(r.Context()); err != nil {
+			handleError(w, http.StatusUnauthorized, err)
+			return
+		}
+		scheduled, err := store.ListScheduledRooms(r.Context())
+		if err != nil {
+			handleError(w, http.StatusInternalServerError, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(scheduled)
+
+	case http.MethodPost:
+		if err := EnsureCreatePermission(r.Context()); err != nil {
+			handleError(w, http.StatusUnauthorized, err)
+			return
+		}
+		var sr ScheduledRoom
+		if err := json.NewDecoder(r.Body).Decode(&sr); err != nil {
+			handleError(w, http.StatusBadRequest, err)
+			return
+		}
+		code, err := s.schedule(r.Context(), store, &sr)
+		if err != nil {
+			handleError(w, code, err, "room", sr.Room)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(&sr)
+
+	case http.MethodDelete:
+		if err := EnsureCreatePermission(r.Context()); err != nil {
+			handleError(w, http.StatusUnauthorized, err)
+			return
+		}
+		roomName := livekit.RoomName(r.URL.Query().Get("room"))
+		if roomName == "" {
+			handleError(w, http.StatusBadRequest, ErrRoomNameRequired)
+			return
+		}
+		if sr, err := store.LoadScheduledRoom(r.Context(), roomName); err != nil {
+			handleError(w, http.StatusInternalServerError, err, "room", roomName)
+			return
+		} else if sr == nil {
+			handleError(w, http.StatusNotFound, ErrScheduleNotFound, "room", roomName)
+			return
+		}
+		// rooms that already started are kept open, only their closure is dropped
+		if err := store.DeleteScheduledRoom(r.Context(), roomName); err != nil {
+			handleError(w, http.StatusInternalServerError, err, "room", roomName)
+			return
+		}
+		logger.Infow("unscheduled room", "room", roomName)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *RoomScheduler) schedule(ctx context.Context, store ScheduledRoomStore, sr *ScheduledRoom) (int, error) {
+	if sr.Room == "" {
+		return http.StatusBadRequest, ErrRoomNameRequired
+	}
+	if sr.StartAt <= time.Now().Unix() {
+		return http.StatusBadRequest, ErrScheduleInvalid
+	}
+	if sr.Template != "" {
+		if _, ok := s.conf.Templates[sr.Template]; !ok {
+			return http.StatusNotFound, ErrRoomTemplateNotFound
+		}
+	}
+	if _, err := sr.createRoomRequest(); err != nil {
+		return http.StatusBadRequest, err
+	}
+	sr.APIKey = GetAPIKey(ctx)
+	sr.StartedAt = 0
+
+	roomName := livekit.RoomName(sr.Room)
+	token, err := s.store.LockRoom(ctx, scheduleLockName(roomName), roomScheduleLockTimeout)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	defer func() {
+		_ = s.store.UnlockRoom(ctx, scheduleLockName(roomName), token)
+	}()
+
+	// a schedule can be replaced until it started
+	if existing, err := store.LoadScheduledRoom(ctx, roomName); err != nil {
+		return http.StatusInternalServerError, err
+	} else if existing != nil && existing.StartedAt != 0 {
+		return http.StatusConflict, ErrScheduleStarted
+	}
+	if err = store.StoreScheduledRoom(ctx, sr); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	logger.Infow("scheduled room", "room", sr.Room, "startAt", time.Unix(sr.StartAt, 0), "duration", sr.Duration)
+	return http.StatusOK, nil
+}
+
+// scheduleLockName keeps the lock of a schedule apart from the lock of its room, taken when creating the room
+func scheduleLockName(roomName livekit.RoomName) livekit.RoomName {
+	return "scheduled:" + roomName
+}
+
+func (s *RoomScheduler) worker() {
+	ticker := time.NewTicker(roomScheduleCheckInterval)
+	defer ticker.Stop()
+
+	store := s.store.(ScheduledRoomStore)
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			scheduled, err := store.ListScheduledRooms(context.Background())
+			if err != nil {
+				logger.Warnw("could not list scheduled rooms", err)
+				continue
+			}
+			now := time.Now().Unix()
+			for _, sr := range scheduled {
+				if sr.StartedAt == 0 && sr.StartAt <= now ||
+					sr.StartedAt != 0 && sr.StartedAt+int64(sr.Duration) <= now {
+					s.process(store, livekit.RoomName(sr.Room))
+				}
+			}
+		}
+	}
+}
+
+// process starts or ends a due room. The schedule is claimed while holding its lock, the room is created
+// or closed after releasing it, as the local store has a single lock for all rooms
+func (s *RoomScheduler) process(store ScheduledRoomStore, roomName livekit.RoomName) {
+	sr, start := s.claim(store, roomName)
+	if sr == nil {
+		return
+	}
+
+	ctx := context.WithValue(context.Background(), apiKeyKey{}, sr.APIKey)
+	ctx = WithGrants(ctx, &auth.ClaimGrants{Video: &auth.VideoGrant{RoomCreate: true}})
+	if !start {
+		// the room may have closed earlier, e.g. after its empty timeout
+		if _, err := s.roomService.DeleteRoom(ctx, &livekit.DeleteRoomRequest{Room: sr.Room}); err != nil {
+			logger.Infow("could not close scheduled room", "room", roomName, "error", err)
+		}
+		logger.Infow("ended scheduled room", "room", roomName)
+		s.telemetry.NotifyEvent(ctx, &livekit.WebhookEvent{
+			Event: EventScheduledRoomEnded,
+			Room:  &livekit.Room{Name: sr.Room},
+		})
+		return
+	}
+
+	req, err := sr.createRoomRequest()
+	if err != nil {
+		logger.Errorw("invalid scheduled room", err, "room", roomName)
+		_ = store.DeleteScheduledRoom(ctx, roomName)
+		return
+	}
+	if sr.Template != "" {
+		ctx = context.WithValue(ctx, roomTemplateKey{}, sr.Template)
+	}
+	room, err := s.roomService.CreateRoom(ctx, req)
+	if err != nil {
+		logger.Errorw("could not create scheduled room, retrying", err, "room", roomName)
+		sr.StartedAt = 0
+		if err = store.StoreScheduledRoom(ctx, sr); err != nil {
+			logger.Errorw("could not update scheduled room", err, "room", roomName)
+		}
+		return
+	}
+	logger.Infow("started scheduled room", "room", roomName, "roomID", room.Sid, "delay", time.Now().Unix()-sr.StartAt)
+	s.telemetry.NotifyEvent(ctx, &livekit.WebhookEvent{
+		Event: EventScheduledRoomStarted,
+		Room:  room,
+	})
+}
+
+// claim returns the schedule of a room that is due, and whether it is due to start or to end. Another
+// node may have claimed it meanwhile
+func (s *RoomScheduler) claim(store ScheduledRoomStore, roomName livekit.RoomName) (*ScheduledRoom, bool) {
+	ctx := context.Background()
+	token, err := s.store.LockRoom(ctx, scheduleLockName(roomName), roomScheduleLockTimeout)
+	if err != nil {
+		logger.Warnw("could not lock scheduled room", err, "room", roomName)
+		return nil, false
+	}
+	defer func() {
+		_ = s.store.UnlockRoom(ctx, scheduleLockName(roomName), token)
+	}()
+
+	sr, err := store.LoadScheduledRoom(ctx, roomName)
+	if err != nil || sr == nil {
+		return nil, false
+	}
+
+	now := time.Now().Unix()
+	switch {
+	case sr.StartedAt == 0 && sr.StartAt <= now:
+		if sr.Duration == 0 {
+			err = store.DeleteScheduledRoom(ctx, roomName)
+		} else {
+			started := *sr
+			started.StartedAt = now
+			err = store.StoreScheduledRoom(ctx, &started)
+		}
+		if err != nil {
+			logger.Errorw("could not update scheduled room", err, "room", roomName)
+			return nil, false
+		}
+		return sr, true
+
+	case sr.StartedAt != 0 && sr.StartedAt+int64(sr.Duration) <= now:
+		if err = store.DeleteScheduledRoom(ctx, roomName); err != nil {
+			logger.Errorw("could not update scheduled room", err, "room", roomName)
+			return nil, false
+		}
+		return sr, false
+
+	default:
+		return nil, false
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/protocol/livekit"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/telemetry/telemetryfakes"
+)
+
+type scheduledRoomService struct {
+	livekit.RoomService
+	created []*livekit.CreateRoomRequest
+	deleted []string
+}
+
+func (s *scheduledRoomService) CreateRoom(ctx context.Context, req *livekit.CreateRoomRequest) (*livekit.Room, error) {
+	if err := EnsureCreatePermission(ctx); err != nil {
+		return nil, err
+	}
+	s.created = append(s.created, req)
+	return &livekit.Room{Name: req.Name, Sid: "RM_scheduled", Metadata: req.Metadata}, nil
+}
+
+func (s *scheduledRoomService) DeleteRoom(_ context.Context, req *livekit.DeleteRoomRequest) (*livekit.DeleteRoomResponse, error) {
+	s.deleted = append(s.deleted, req.Room)
+	return &livekit.DeleteRoomResponse{}, nil
+}
+
+func TestRoomScheduler(t *testing.T) {
+	ctx := context.Background()
+	store := NewLocalStore()
+	roomService := &scheduledRoomService{}
+	ts := &telemetryfakes.FakeTelemetryService{}
+	s := NewRoomScheduler(&config.RoomConfig{}, roomService, store, ts)
+
+	_, err := s.schedule(ctx, store, &ScheduledRoom{Room: "keynote", StartAt: time.Now().Unix() - 1})
+	require.ErrorIs(t, err, ErrScheduleInvalid)
+	_, err = s.schedule(ctx, store, &ScheduledRoom{Room: "keynote", StartAt: time.Now().Unix() + 60, Egress: []byte("{")})
+	require.Error(t, err)
+
+	_, err = s.schedule(ctx, store, &ScheduledRoom{
+		Room:            "keynote",
+		StartAt:         time.Now().Unix() + 60,
+		Duration:        3600,
+		MaxParticipants: 500,
+		Metadata:        "welcome",
+	})
+	require.NoError(t, err)
+
+	// not due yet
+	s.process(store, "keynote")
+	require.Empty(t, roomService.created)
+
+	sr, err := store.LoadScheduledRoom(ctx, "keynote")
+	require.NoError(t, err)
+	sr.StartAt = time.Now().Unix()
+	require.NoError(t, store.StoreScheduledRoom(ctx, sr))
+
+	s.process(store, "keynote")
+	require.Len(t, roomService.created, 1)
+	require.Equal(t, uint32(500), roomService.created[0].MaxParticipants)
+	require.Equal(t, "welcome", roomService.created[0].Metadata)
+	require.Equal(t, 1, ts.NotifyEventCallCount())
+	_, event := ts.NotifyEventArgsForCall(0)
+	require.Equal(t, EventScheduledRoomStarted, event.Event)
+
+	// started schedules cannot be replaced
+	_, err = s.schedule(ctx, store, &ScheduledRoom{Room: "keynote", StartAt: time.Now().Unix() + 60})
+	require.ErrorIs(t, err, ErrScheduleStarted)
+
+	sr, err = store.LoadScheduledRoom(ctx, "keynote")
+	require.NoError(t, err)
+	require.NotZero(t, sr.StartedAt)
+	sr.StartedAt -= int64(sr.Duration)
+	require.NoError(t, store.StoreScheduledRoom(ctx, sr))
+
+	s.process(store, "keynote")
+	require.Equal(t, []string{"keynote"}, roomService.deleted)
+	sr, err = store.LoadScheduledRoom(ctx, "keynote")
+	require.NoError(t, err)
+	require.Nil(t, sr)
+}
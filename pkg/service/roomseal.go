@@ -0,0 +1,226 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/livekit/protocol/auth"
+	"github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/logger"
+
+	"github.com/livekit/livekit-server/pkg/routing"
+)
+
+const (
+	roomSealPath = "/rooms/seal"
+	// topic of the data packets announcing that a room was sealed or unsealed
+	RoomSealedTopic = "lk.room_sealed"
+)
+
+// grants that may be listed to let their holders join sealed rooms
+var roomSealBypassGrants = map[string]func(*auth.VideoGrant) bool{
+	"room_admin":  func(g *auth.VideoGrant) bool { return g.RoomAdmin },
+	"room_record": func(g *auth.VideoGrant) bool { return g.RoomRecord },
+	"hidden":      func(g *auth.VideoGrant) bool { return g.Hidden },
+	"recorder":    func(g *auth.VideoGrant) bool { return g.Recorder },
+}
+
+// RoomSeal keeps new participants out of a room. Participants already in the room stay and may
+// reconnect, identities and holders of grants on the allowlist can still join.
+type RoomSeal struct {
+	Room       livekit.RoomName `json:"room"`
+	SealedAt   int64            `json:"sealed_at"`
+	Identities []string         `json:"identities,omitempty"`
+	Grants     []string         `json:"grants,omitempty"`
+}
+
+// Admits returns true if a participant joining with the identity and grants bypasses the seal
+func (s *RoomSeal) Admits(identity livekit.ParticipantIdentity, grants *auth.ClaimGrants) bool {
+	if s == nil {
+		return true
+	}
+	for _, id := range s.Identities {
+		if id == string(identity) {
+			return true
+		}
+	}
+	if grants == nil || grants.Video == nil {
+		return false
+	}
+	for _, name := range s.Grants {
+		if has, ok := roomSealBypassGrants[name]; ok && has(grants.Video) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkRoomSeal returns ErrRoomSealed if a new participant may not join the room
+func checkRoomSeal(ctx context.Context, store ServiceStore, roomName livekit.RoomName, pi *routing.ParticipantInit) error {
+	sealStore, ok := store.(RoomSealStore)
+	if !ok || pi.Reconnect {
+		return nil
+	}
+	seal, err := sealStore.LoadRoomSeal(ctx, roomName)
+	if err != nil {
+		return err
+	}
+	if seal.Admits(pi.Identity, pi.Grants) {
+		return nil
+	}
+	// participants already in the room may replace their session
+	if _, err = store.LoadParticipant(ctx, roomName, pi.Identity); err == nil {
+		return nil
+	}
+	return ErrRoomSealed
+}
+
+type roomSealRequest struct {
+	Room       string   `json:"room"`
+	Sealed     bool     `json:"sealed"`
+	SealedAt   int64    `json:"sealed_at,omitempty"`
+	Identities []string `json:"identities,omitempty"`
+	Grants     []string `json:"grants,omitempty"`
+}
+
+type roomSealedMessage struct {
+	Sealed   bool  `json:"sealed"`
+	SealedAt int64 `json:"sealed_at,omitempty"`
+}
+
+// RoomSealService seals rooms against further joins, e.g. once an exam has started, and reports
+// whether a room is sealed. Changes are announced to participants of the room.
+type RoomSealService struct {
+	store  ObjectStore
+	router routing.MessageRouter
+}
+
+func NewRoomSealService(store ObjectStore, router routing.MessageRouter) *RoomSealService {
+	return &RoomSealService{
+		store:  store,
+		router: router,
+	}
+}
+
+func (s *RoomSealService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req roomSealRequest
+	switch r.Method {
+	case http.MethodGet:
+		req.Room = r.URL.Query().Get("room")
+	case http.MethodPost:
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			handleError(w, http.StatusBadRequest, err)
+			return
+		}
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	if req.Room == "" {
+		handleError(w, http.StatusBadRequest, ErrRoomNameRequired)
+		return
+	}
+	roomName := livekit.RoomName(req.Room)
+	if err := EnsureAdminPermission(r.Context(), roomName); err != nil {
+		handleError(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	sealStore, ok := s.store.(RoomSealStore)
+	if !ok {
+		handleError(w, http.StatusNotImplemented, ErrRoomSealUnsupported)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		for _, name := range req.Grants {
+			if _, ok := roomSealBypassGrants[name]; !ok {
+				handleError(w, http.StatusBadRequest, ErrInvalidSealGrant, "grant", name)
+				return
+			}
+		}
+		if _, _, err := s.store.LoadRoom(r.Context(), roomName, false); err != nil {
+			handleError(w, http.StatusNotFound, err, "room", req.Room)
+			return
+		}
+		if err := s.setSeal(r.Context(), sealStore, roomName, &req); err != nil {
+			handleError(w, http.StatusInternalServerError, err, "room", req.Room)
+			return
+		}
+	}
+
+	seal, err := sealStore.LoadRoomSeal(r.Context(), roomName)
+	if err != nil {
+		handleError(w, http.StatusInternalServerError, err, "room", req.Room)
+		return
+	}
+
+	res := &roomSealRequest{Room: req.Room}
+	if seal != nil {
+		res.Sealed = true
+		res.SealedAt = seal.SealedAt
+		res.Identities = seal.Identities
+		res.Grants = seal.Grants
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(res)
+}
+
+func (s *RoomSealService) setSeal(ctx context.Context, store RoomSealStore, roomName livekit.RoomName, req *roomSealRequest) error {
+	msg := &roomSealedMessage{Sealed: req.Sealed}
+	if req.Sealed {
+		seal := &RoomSeal{
+			Room:       roomName,
+			SealedAt:   time.Now().Unix(),
+			Identities: req.Identities,
+			Grants:     req.Grants,
+		}
+		if err := store.StoreRoomSeal(ctx, seal); err != nil {
+			return err
+		}
+		msg.SealedAt = seal.SealedAt
+		logger.Infow("sealed room", "room", roomName, "identities", req.Identities, "grants", req.Grants)
+	} else {
+		if err := store.DeleteRoomSeal(ctx, roomName); err != nil {
+			return err
+		}
+		logger.Infow("unsealed room", "room", roomName)
+	}
+
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	topic := RoomSealedTopic
+	err = s.router.WriteRoomRTC(ctx, roomName, &livekit.RTCNodeMessage{
+		Message: &livekit.RTCNodeMessage_SendData{
+			SendData: &livekit.SendDataRequest{
+				Room:  string(roomName),
+				Data:  payload,
+				Kind:  livekit.DataPacket_RELIABLE,
+				Topic: &topic,
+			},
+		},
+	})
+	if err != nil {
+		logger.Warnw("could not announce room seal", err, "room", roomName)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/protocol/auth"
+	"github.com/livekit/protocol/livekit"
+
+	"github.com/livekit/livekit-server/pkg/service"
+)
+
+func TestRoomSealAdmits(t *testing.T) {
+	seal := &service.RoomSeal{
+		Room:       "exam",
+		Identities: []string{"proctor"},
+		Grants:     []string{"recorder"},
+	}
+	student := &auth.ClaimGrants{Video: &auth.VideoGrant{RoomJoin: true, Room: "exam"}}
+	recorder := &auth.ClaimGrants{Video: &auth.VideoGrant{RoomJoin: true, Room: "exam", Recorder: true}}
+	admin := &auth.ClaimGrants{Video: &auth.VideoGrant{RoomJoin: true, Room: "exam", RoomAdmin: true}}
+
+	require.False(t, seal.Admits("student", student))
+	require.True(t, seal.Admits("proctor", student))
+	require.True(t, seal.Admits("egress", recorder))
+	require.False(t, seal.Admits("admin", admin))
+	require.False(t, seal.Admits("student", nil))
+
+	// rooms without a seal admit anyone
+	var unsealed *service.RoomSeal
+	require.True(t, unsealed.Admits("student", student))
+}
+
+func TestLocalStoreRoomSeal(t *testing.T) {
+	ctx := context.Background()
+	s := service.NewLocalStore()
+	roomName := livekit.RoomName("exam")
+	require.NoError(t, s.StoreRoom(ctx, &livekit.Room{Name: string(roomName)}, nil))
+
+	seal, err := s.LoadRoomSeal(ctx, roomName)
+	require.NoError(t, err)
+	require.Nil(t, seal)
+
+	require.NoError(t, s.StoreRoomSeal(ctx, &service.RoomSeal{Room: roomName, SealedAt: 1}))
+	seal, err = s.LoadRoomSeal(ctx, roomName)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, seal.SealedAt)
+
+	// seals go away with the room
+	require.NoError(t, s.DeleteRoom(ctx, roomName))
+	seal, err = s.LoadRoomSeal(ctx, roomName)
+	require.NoError(t, err)
+	require.Nil(t, seal)
+}
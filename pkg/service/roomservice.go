@@ -23,6 +23,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/thoas/go-funk"
 	"github.com/twitchtv/twirp"
+	"go.uber.org/atomic"
 	"google.golang.org/protobuf/proto"
 
 	"github.com/livekit/livekit-server/pkg/config"
@@ -35,7 +36,7 @@ import (
 
 // A rooms service that supports a single node
 type RoomService struct {
-	roomConf       config.RoomConfig
+	roomConf       atomic.Pointer[config.RoomConfig]
 	apiConf        config.APIConfig
 	router         routing.MessageRouter
 	roomAllocator  RoomAllocator
@@ -52,21 +53,38 @@ func NewRoomService(
 	egressLauncher rtc.EgressLauncher,
 ) (svc *RoomService, err error) {
 	svc = &RoomService{
-		roomConf:       roomConf,
 		apiConf:        apiConf,
 		router:         router,
 		roomAllocator:  roomAllocator,
 		roomStore:      serviceStore,
 		egressLauncher: egressLauncher,
 	}
+	svc.roomConf.Store(&roomConf)
 	return
 }
 
+// ReloadConfig applies reloaded room defaults to requests handled from now on
+func (s *RoomService) ReloadConfig(conf *config.Config) {
+	s.roomConf.Store(&conf.Room)
+	if r, ok := s.roomAllocator.(configReloader); ok {
+		r.ReloadConfig(conf)
+	}
+}
+
 func (s *RoomService) CreateRoom(ctx context.Context, req *livekit.CreateRoomRequest) (*livekit.Room, error) {
 	AppendLogFields(ctx, "room", req.Name, "request", req)
 	if err := EnsureCreatePermission(ctx); err != nil {
 		return nil, twirpAuthError(err)
-	} else if req.Egress != nil && s.egressLauncher == nil {
+	}
+	if name := roomTemplateFromContext(ctx); name != "" {
+		tmpl, err := s.roomConf.Load().ResolveTemplate(name)
+		if err != nil {
+			return nil, ErrRoomTemplateNotFound
+		}
+		req = applyRoomTemplate(req, tmpl)
+		AppendLogFields(ctx, "template", name)
+	}
+	if req.Egress != nil && s.egressLauncher == nil {
 		return nil, ErrEgressNotConnected
 	}
 
@@ -142,22 +160,43 @@ func (s *RoomService) DeleteRoom(ctx context.Context, req *livekit.DeleteRoomReq
 		return nil, twirpAuthError(err)
 	}
 
-	if _, _, err := s.roomStore.LoadRoom(ctx, livekit.RoomName(req.Room), false); err == ErrRoomNotFound {
+	roomName := livekit.RoomName(req.Room)
+	if _, _, err := s.roomStore.LoadRoom(ctx, roomName, false); err == ErrRoomNotFound {
 		return nil, twirp.NotFoundError("room not found")
 	}
 
-	err := s.router.WriteRoomRTC(ctx, livekit.RoomName(req.Room), &livekit.RTCNodeMessage{
+	if delay := deleteRoomDelayFromContext(ctx); delay > 0 {
+		if _, err := scheduleRoomClosure(ctx, s.roomStore, s.router, roomName, delay); err != nil {
+			return nil, twirpRoomClosureError(err)
+		}
+		return &livekit.DeleteRoomResponse{}, nil
+	}
+	if closureStore, ok := s.roomStore.(RoomClosureStore); ok {
+		// the hosting node closes the room right away once no closure is scheduled
+		if err := closureStore.DeleteRoomClosure(ctx, roomName); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.deleteRoom(ctx, roomName); err != nil {
+		return nil, err
+	}
+	return &livekit.DeleteRoomResponse{}, nil
+}
+
+func (s *RoomService) deleteRoom(ctx context.Context, roomName livekit.RoomName) error {
+	err := s.router.WriteRoomRTC(ctx, roomName, &livekit.RTCNodeMessage{
 		Message: &livekit.RTCNodeMessage_DeleteRoom{
-			DeleteRoom: req,
+			DeleteRoom: &livekit.DeleteRoomRequest{Room: string(roomName)},
 		},
 	})
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	// we should not return until when the room is confirmed deleted
-	err = s.confirmExecution(func() error {
-		_, _, err := s.roomStore.LoadRoom(ctx, livekit.RoomName(req.Room), false)
+	return s.confirmExecution(func() error {
+		_, _, err := s.roomStore.LoadRoom(ctx, roomName, false)
 		if err == nil {
 			return ErrOperationFailed
 		} else if err != ErrRoomNotFound {
@@ -166,11 +205,6 @@ func (s *RoomService) DeleteRoom(ctx context.Context, req *livekit.DeleteRoomReq
 			return nil
 		}
 	})
-	if err != nil {
-		return nil, err
-	}
-
-	return &livekit.DeleteRoomResponse{}, nil
 }
 
 func (s *RoomService) ListParticipants(ctx context.Context, req *livekit.ListParticipantsRequest) (*livekit.ListParticipantsResponse, error) {
@@ -284,7 +318,7 @@ func (s *RoomService) MutePublishedTrack(ctx context.Context, req *livekit.MuteR
 
 func (s *RoomService) UpdateParticipant(ctx context.Context, req *livekit.UpdateParticipantRequest) (*livekit.ParticipantInfo, error) {
 	AppendLogFields(ctx, "room", req.Room, "participant", req.Identity)
-	maxMetadataSize := int(s.roomConf.MaxMetadataSize)
+	maxMetadataSize := int(s.roomConf.Load().MaxMetadataSize)
 	if maxMetadataSize > 0 && len(req.Metadata) > maxMetadataSize {
 		return nil, twirp.InvalidArgumentError(ErrMetadataExceedsLimits.Error(), strconv.Itoa(maxMetadataSize))
 	}
@@ -362,7 +396,7 @@ func (s *RoomService) SendData(ctx context.Context, req *livekit.SendDataRequest
 
 func (s *RoomService) UpdateRoomMetadata(ctx context.Context, req *livekit.UpdateRoomMetadataRequest) (*livekit.Room, error) {
 	AppendLogFields(ctx, "room", req.Room, "size", len(req.Metadata))
-	maxMetadataSize := int(s.roomConf.MaxMetadataSize)
+	maxMetadataSize := int(s.roomConf.Load().MaxMetadataSize)
 	if maxMetadataSize > 0 && len(req.Metadata) > maxMetadataSize {
 		return nil, twirp.InvalidArgumentError(ErrMetadataExceedsLimits.Error(), strconv.Itoa(maxMetadataSize))
 	}
@@ -133,7 +133,7 @@ func newTestRoomService(conf config.RoomConfig) *TestRoomService {
 		panic(err)
 	}
 	return &TestRoomService{
-		RoomService: *svc,
+		RoomService: svc,
 		router:      router,
 		allocator:   allocator,
 		store:       store,
@@ -141,7 +141,7 @@ func newTestRoomService(conf config.RoomConfig) *TestRoomService {
 }
 
 type TestRoomService struct {
-	service.RoomService
+	*service.RoomService
 	router    *routingfakes.FakeRouter
 	allocator *servicefakes.FakeRoomAllocator
 	store     *servicefakes.FakeServiceStore
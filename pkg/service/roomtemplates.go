@@ -0,0 +1,258 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/logger"
+	"github.com/livekit/protocol/webhook"
+)
+
+const (
+	// CreateRoom requests carrying this header create the room with the settings of the named template
+	RoomTemplateHeader = "Livekit-Room-Template"
+
+	roomTemplatesPath = "/rooms/templates"
+)
+
+type roomTemplateKey struct{}
+
+// WithRoomTemplate passes the room template header of Twirp requests on to RoomService
+func WithRoomTemplate(conf *config.RoomConfig, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if name := r.Header.Get(RoomTemplateHeader); name != "" {
+			if _, ok := conf.Templates[name]; !ok {
+				handleError(w, http.StatusNotFound, ErrRoomTemplateNotFound, "template", name)
+				return
+			}
+			r = r.WithContext(context.WithValue(r.Context(), roomTemplateKey{}, name))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func roomTemplateFromContext(ctx context.Context) string {
+	name, _ := ctx.Value(roomTemplateKey{}).(string)
+	return name
+}
+
+// applyRoomTemplate returns a copy of req with the settings it leaves empty taken from the template
+func applyRoomTemplate(req *livekit.CreateRoomRequest, tmpl *config.RoomTemplate) *livekit.CreateRoomRequest {
+	req = proto.Clone(req).(*livekit.CreateRoomRequest)
+	if req.EmptyTimeout == 0 {
+		req.EmptyTimeout = tmpl.EmptyTimeout
+	}
+	if req.MaxParticipants == 0 {
+		req.MaxParticipants = tmpl.MaxParticipants
+	}
+	if req.Metadata == "" {
+		req.Metadata = tmpl.Metadata
+	}
+	if req.MinPlayoutDelay == 0 {
+		req.MinPlayoutDelay = tmpl.MinPlayoutDelay
+	}
+	if req.Egress == nil && tmpl.Recording != nil {
+		req.Egress = roomTemplateEgress(req.Name, tmpl.Recording)
+	}
+	return req
+}
+
+func roomTemplateEgress(roomName string, rec *config.RoomTemplateRecording) *livekit.RoomEgress {
+	if !rec.RoomComposite && !rec.Tracks {
+		return nil
+	}
+
+	egress := &livekit.RoomEgress{}
+	if rec.RoomComposite {
+		egress.Room = &livekit.RoomCompositeEgressRequest{
+			RoomName:  roomName,
+			Layout:    rec.Layout,
+			AudioOnly: rec.AudioOnly,
+			FileOutputs: []*livekit.EncodedFileOutput{{
+				Filepath: rec.Filepath,
+			}},
+		}
+	}
+	if rec.Tracks {
+		egress.Tracks = &livekit.AutoTrackEgress{
+			Filepath: rec.TrackFilepath,
+		}
+	}
+	return egress
+}
+
+type roomTemplateInfo struct {
+	Name            string                     `json:"name"`
+	Inherits        string                     `json:"inherits,omitempty"`
+	Description     string                     `json:"description,omitempty"`
+	EnabledCodecs   []string                   `json:"enabled_codecs,omitempty"`
+	MaxParticipants uint32                     `json:"max_participants,omitempty"`
+	EmptyTimeout    uint32                     `json:"empty_timeout,omitempty"`
+	MinPlayoutDelay uint32                     `json:"min_playout_delay,omitempty"`
+	Metadata        string                     `json:"metadata,omitempty"`
+	Recording       *roomTemplateRecordingInfo `json:"recording,omitempty"`
+}
+
+type roomTemplateRecordingInfo struct {
+	RoomComposite bool   `json:"room_composite,omitempty"`
+	Layout        string `json:"layout,omitempty"`
+	AudioOnly     bool   `json:"audio_only,omitempty"`
+	Tracks        bool   `json:"tracks,omitempty"`
+}
+
+type listRoomTemplatesResponse struct {
+	Templates []*roomTemplateInfo `json:"templates"`
+}
+
+// RoomTemplateService lists the configured room templates with their inherited settings resolved
+type RoomTemplateService struct {
+	conf *config.RoomConfig
+}
+
+func NewRoomTemplateService(conf *config.RoomConfig) *RoomTemplateService {
+	return &RoomTemplateService{
+		conf: conf,
+	}
+}
+
+func (s *RoomTemplateService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if err := EnsureListPermission(r.Context()); err != nil {
+		handleError(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	res := &listRoomTemplatesResponse{
+		Templates: make([]*roomTemplateInfo, 0, len(s.conf.Templates)),
+	}
+	for name := range s.conf.Templates {
+		tmpl, err := s.conf.ResolveTemplate(name)
+		if err != nil {
+			handleError(w, http.StatusInternalServerError, err, "template", name)
+			return
+		}
+		res.Templates = append(res.Templates, newRoomTemplateInfo(name, tmpl))
+	}
+	sort.Slice(res.Templates, func(i, j int) bool {
+		return res.Templates[i].Name < res.Templates[j].Name
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(res)
+}
+
+func newRoomTemplateInfo(name string, tmpl *config.RoomTemplate) *roomTemplateInfo {
+	info := &roomTemplateInfo{
+		Name:            name,
+		Inherits:        tmpl.Inherits,
+		Description:     tmpl.Description,
+		MaxParticipants: tmpl.MaxParticipants,
+		EmptyTimeout:    tmpl.EmptyTimeout,
+		MinPlayoutDelay: tmpl.MinPlayoutDelay,
+		Metadata:        tmpl.Metadata,
+	}
+	for _, codec := range tmpl.EnabledCodecs {
+		info.EnabledCodecs = append(info.EnabledCodecs, codec.Mime)
+	}
+	if rec := tmpl.Recording; rec != nil {
+		info.Recording = &roomTemplateRecordingInfo{
+			RoomComposite: rec.RoomComposite,
+			Layout:        rec.Layout,
+			AudioOnly:     rec.AudioOnly,
+			Tracks:        rec.Tracks,
+		}
+	}
+	return info
+}
+
+// roomTemplateNotifier sends webhook events of rooms created with a template to the webhook URLs of the template,
+// in addition to the default notifier
+type roomTemplateNotifier struct {
+	webhook.QueuedNotifier
+	store     RoomTemplateStore
+	templates map[string]webhook.QueuedNotifier
+
+	lock sync.Mutex
+	// room sid => template, resolved once per room so that room_finished is still routed after the room is deleted
+	rooms map[string]string
+}
+
+// newRoomTemplateNotifier returns notifier as is when no template has webhook URLs. notifier may be nil
+// when only templates have webhook URLs
+func newRoomTemplateNotifier(
+	notifier webhook.QueuedNotifier,
+	store ObjectStore,
+	templates map[string]webhook.QueuedNotifier,
+) webhook.QueuedNotifier {
+	ts, ok := store.(RoomTemplateStore)
+	if !ok || len(templates) == 0 {
+		return notifier
+	}
+	return &roomTemplateNotifier{
+		QueuedNotifier: notifier,
+		store:          ts,
+		templates:      templates,
+		rooms:          make(map[string]string),
+	}
+}
+
+func (n *roomTemplateNotifier) QueueNotify(ctx context.Context, event *livekit.WebhookEvent) error {
+	var err error
+	if n.QueuedNotifier != nil {
+		err = n.QueuedNotifier.QueueNotify(ctx, event)
+	}
+	if event.Room == nil {
+		return err
+	}
+
+	if notifier := n.templates[n.roomTemplate(ctx, event)]; notifier != nil {
+		if tErr := notifier.QueueNotify(ctx, event); tErr != nil {
+			logger.Warnw("failed to notify room template webhook", tErr, "event", event.Event, "room", event.Room.Name)
+		}
+	}
+	return err
+}
+
+func (n *roomTemplateNotifier) roomTemplate(ctx context.Context, event *livekit.WebhookEvent) string {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	template, ok := n.rooms[event.Room.Sid]
+	if !ok {
+		var err error
+		template, err = n.store.LoadRoomTemplate(ctx, livekit.RoomName(event.Room.Name))
+		if err != nil {
+			logger.Warnw("could not load room template", err, "room", event.Room.Name)
+			return ""
+		}
+		n.rooms[event.Room.Sid] = template
+	}
+	if event.Event == webhook.EventRoomFinished {
+		delete(n.rooms, event.Room.Sid)
+	}
+	return template
+}
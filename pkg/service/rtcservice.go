@@ -21,7 +21,6 @@ import (
 	"io"
 	"math/rand"
 	"net/http"
-	"os"
 	"strconv"
 	"strings"
 	"sync"
@@ -29,6 +28,8 @@ import (
 
 	"github.com/gorilla/websocket"
 	"github.com/ua-parser/uap-go/uaparser"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/atomic"
 	"golang.org/x/exp/maps"
 
 	"github.com/livekit/livekit-server/pkg/utils"
@@ -42,6 +43,16 @@ import (
 	"github.com/livekit/livekit-server/pkg/rtc"
 	"github.com/livekit/livekit-server/pkg/telemetry"
 	"github.com/livekit/livekit-server/pkg/telemetry/prometheus"
+	"github.com/livekit/livekit-server/pkg/telemetry/tracing"
+)
+
+const (
+	// set on join refusals, so clients can tell them apart from other failures
+	errorCodeHeader               = "X-LiveKit-Error-Code"
+	errorCodeRoomNotFound         = "room_not_found"
+	errorCodeSessionLimitExceeded = "session_limit_exceeded"
+	errorCodeQuotaExceeded        = "quota_exceeded"
+	errorCodePlacementUnavailable = "placement_unavailable"
 )
 
 type RTCService struct {
@@ -52,10 +63,16 @@ type RTCService struct {
 	currentNode   routing.LocalNode
 	config        *config.Config
 	isDev         bool
-	limits        config.LimitConfig
+	limits        atomic.Pointer[config.LimitConfig]
 	parser        *uaparser.Parser
 	telemetry     telemetry.TelemetryService
 
+	reconnectPolicy []byte
+	signalLimiter   *IPRateLimiter
+	// set on edge nodes, which relay joins to core nodes
+	edge   *EdgeRelayClient
+	budget *ResourceBudget
+
 	mu          sync.Mutex
 	connections map[*websocket.Conn]struct{}
 }
@@ -76,10 +93,16 @@ func NewRTCService(
 		currentNode:   currentNode,
 		config:        conf,
 		isDev:         conf.Development,
-		limits:        conf.Limit,
 		parser:        uaparser.NewFromSaved(),
 		telemetry:     telemetry,
 		connections:   map[*websocket.Conn]struct{}{},
+
+		reconnectPolicy: marshalReconnectPolicy(&conf.Reconnect),
+		signalLimiter:   NewIPRateLimiter(conf.Reconnect.RateLimit, conf.Reconnect.RateWindow),
+	}
+	s.limits.Store(&conf.Limit)
+	if _, ok := store.(SessionLimitStore); !ok && conf.SessionLimits.Enabled() {
+		logger.Warnw("session limits are not supported by the store and will not be enforced", nil)
 	}
 
 	// allow connections from any origin, since script may be hosted anywhere
@@ -91,12 +114,30 @@ func NewRTCService(
 	return s
 }
 
+// SetEdgeRelay relays the joins of this node to core nodes
+func (s *RTCService) SetEdgeRelay(edge *EdgeRelayClient) {
+	s.edge = edge
+}
+
+// SetResourceBudget refuses new participants of rooms hosted on this node while it is over budget
+func (s *RTCService) SetResourceBudget(budget *ResourceBudget) {
+	s.budget = budget
+}
+
+// ReloadConfig applies reloaded limits to connections made from now on
+func (s *RTCService) ReloadConfig(conf *config.Config) {
+	s.limits.Store(&conf.Limit)
+}
+
 func (s *RTCService) Validate(w http.ResponseWriter, r *http.Request) {
 	_, _, code, err := s.validate(r)
 	if err != nil {
-		handleError(w, code, err)
+		handleValidateError(w, code, err)
 		return
 	}
+	if s.reconnectPolicy != nil {
+		w.Header().Set(reconnectPolicyHeader, string(s.reconnectPolicy))
+	}
 	_, _ = w.Write([]byte("success"))
 }
 
@@ -142,13 +183,26 @@ func (s *RTCService) validate(r *http.Request) (livekit.RoomName, routing.Partic
 		claims.Identity += "#" + publishParam
 	}
 
+	// without redis, only the rooms hosted on this node can be joined and they need no placement
+	localOnly := false
+	if dr := degradedRouter(s.router); dr != nil && s.edge == nil {
+		if !dr.IsLocalRoom(roomName) {
+			return "", pi, http.StatusServiceUnavailable, ErrPlacementUnavailable
+		}
+		localOnly = true
+	}
+	// edges leave placement and room checks to core nodes
+	checkRoom := !localOnly && s.edge == nil
+
 	// room allocator validations
-	err = s.roomAllocator.ValidateCreateRoom(r.Context(), roomName)
-	if err != nil {
-		if errors.Is(err, ErrRoomNotFound) {
-			return "", pi, http.StatusNotFound, err
-		} else {
-			return "", pi, http.StatusInternalServerError, err
+	if checkRoom {
+		err = s.roomAllocator.ValidateCreateRoom(r.Context(), roomName)
+		if err != nil {
+			if errors.Is(err, ErrRoomNotFound) || errors.Is(err, ErrRoomNotCreated) {
+				return "", pi, http.StatusNotFound, err
+			} else {
+				return "", pi, http.StatusInternalServerError, err
+			}
 		}
 	}
 
@@ -156,9 +210,12 @@ func (s *RTCService) validate(r *http.Request) (livekit.RoomName, routing.Partic
 	if router, ok := s.router.(routing.Router); ok {
 		region = router.GetRegion()
 		if foundNode, err := router.GetNodeForRoom(r.Context(), roomName); err == nil {
-			if selector.LimitsReached(s.limits, foundNode.Stats) {
+			if selector.LimitsReached(*s.limits.Load(), foundNode.Stats) {
 				return "", pi, http.StatusServiceUnavailable, rtc.ErrLimitExceeded
 			}
+			if foundNode.Id == s.currentNode.Id && !boolValue(reconnectParam) && s.budget.IsRefusingJoins() {
+				return "", pi, http.StatusServiceUnavailable, ErrResourceBudget
+			}
 		}
 	}
 
@@ -171,6 +228,13 @@ func (s *RTCService) validate(r *http.Request) (livekit.RoomName, routing.Partic
 		Client:          s.ParseClientInfo(r),
 		Grants:          claims,
 		Region:          region,
+		Entitlements:    GetEntitlements(r.Context()),
+	}
+	publicClaims := &s.config.Room.PublicClaims
+	if token := GetToken(r.Context()); token != "" && len(publicClaims.Claims) != 0 && pi.Entitlements.GetShareClaims(publicClaims.RequireGrant) {
+		if pi.PublicClaims, err = routing.ParsePublicClaims(token, publicClaims.Claims); err != nil {
+			return "", pi, http.StatusUnauthorized, err
+		}
 	}
 	if pi.Reconnect {
 		pi.ID = livekit.ParticipantID(participantID)
@@ -187,9 +251,42 @@ func (s *RTCService) validate(r *http.Request) (livekit.RoomName, routing.Partic
 		pi.SubscriberAllowPause = &subscriberAllowPause
 	}
 
+	if checkRoom {
+		if err = checkRoomRegions(r.Context(), s.store, &s.config.NodeSelector, roomName, region, r); err != nil {
+			var redirect *roomRegionRedirect
+			if errors.As(err, &redirect) {
+				return "", routing.ParticipantInit{}, http.StatusTemporaryRedirect, err
+			} else if errors.Is(err, ErrRoomPinnedToRegion) {
+				return "", routing.ParticipantInit{}, http.StatusForbidden, err
+			}
+			return "", routing.ParticipantInit{}, http.StatusInternalServerError, err
+		}
+	}
+
+	if s.edge == nil {
+		if err = checkRoomSeal(r.Context(), s.store, roomName, &pi); err != nil {
+			if errors.Is(err, ErrRoomSealed) {
+				return "", routing.ParticipantInit{}, http.StatusForbidden, err
+			}
+			return "", routing.ParticipantInit{}, http.StatusInternalServerError, err
+		}
+	}
+
 	return roomName, pi, http.StatusOK, nil
 }
 
+func handleValidateError(w http.ResponseWriter, code int, err error) {
+	var redirect *roomRegionRedirect
+	if errors.Is(err, ErrRoomNotCreated) {
+		w.Header().Set(errorCodeHeader, errorCodeRoomNotFound)
+	} else if errors.Is(err, ErrPlacementUnavailable) {
+		w.Header().Set(errorCodeHeader, errorCodePlacementUnavailable)
+	} else if errors.As(err, &redirect) {
+		w.Header().Set("Location", redirect.url)
+	}
+	handleError(w, code, err)
+}
+
 func (s *RTCService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// reject non websocket requests
 	if !websocket.IsWebSocketUpgrade(r) {
@@ -197,9 +294,13 @@ func (s *RTCService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if rejectRateLimitedSignal(w, r, s.signalLimiter, &s.config.Reconnect) {
+		return
+	}
+
 	roomName, pi, code, err := s.validate(r)
 	if err != nil {
-		handleError(w, code, err)
+		handleValidateError(w, code, err)
 		return
 	}
 
@@ -209,33 +310,88 @@ func (s *RTCService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		"room", roomName,
 		"remote", false,
 	}
+	sLogger := rtc.LoggerWithParticipant(
+		rtc.LoggerWithRoom(logger.GetLogger(), roomName, ""),
+		pi.Identity,
+		pi.ID,
+		false,
+	)
+
+	// sessions and quotas are counted in redis, they are not enforced while it is unreachable. Core nodes enforce
+	// them for edges
+	var lease *sessionLease
+	if degradedRouter(s.router) == nil && s.edge == nil {
+		lease, err = acquireSessionLimits(r.Context(), s.store, &s.config.SessionLimits, &s.config.Quotas, GetAPIKey(r.Context()), roomName, pi.Identity)
+		if err == nil {
+			if err = checkParticipantMinutesQuota(r.Context(), s.store, &s.config.Quotas, GetAPIKey(r.Context())); err != nil {
+				lease.Release()
+			}
+		}
+	}
+	if err != nil {
+		if errors.Is(err, ErrSessionLimitExceeded) {
+			w.Header().Set(errorCodeHeader, errorCodeSessionLimitExceeded)
+			handleError(w, http.StatusTooManyRequests, err, loggerFields...)
+		} else if errors.Is(err, ErrQuotaExceeded) {
+			w.Header().Set(errorCodeHeader, errorCodeQuotaExceeded)
+			handleError(w, http.StatusTooManyRequests, err, loggerFields...)
+		} else {
+			handleError(w, http.StatusInternalServerError, err, loggerFields...)
+		}
+		return
+	}
+	defer lease.Release()
 
 	// give it a few attempts to start session
+	joinCtx, joinSpan := tracing.Start(tracing.ExtractHTTP(r.Context(), r.Header), "signal.Join",
+		attribute.String("room", string(roomName)),
+		attribute.String("participant", string(pi.Identity)),
+		attribute.Bool("reconnect", pi.Reconnect),
+	)
+	joinStart := time.Now()
 	var cr connectionResult
 	var initialResponse *livekit.SignalResponse
 	for i := 0; i < 3; i++ {
-		if err = r.Context().Err(); err != nil {
+		if err = joinCtx.Err(); err != nil {
 			break
 		}
 
 		connectionTimeout := 3 * time.Second * time.Duration(i+1)
-		ctx := utils.ContextWithAttempt(r.Context(), i)
+		ctx := utils.ContextWithAttempt(utils.ContextWithLogger(joinCtx, sLogger), i)
 		cr, initialResponse, err = s.startConnection(ctx, roomName, pi, connectionTimeout)
-		if err == nil {
+		if err == nil || joinErrorCode(err) != "" {
 			break
 		}
 		if i < 2 {
-			fieldsWithAttempt := append(loggerFields, "attempt", i)
-			logger.Warnw("failed to start connection, retrying", err, fieldsWithAttempt...)
+			sLogger.Warnw("failed to start connection, retrying", err, "attempt", i)
 		}
 	}
 	if err != nil {
-		prometheus.IncrementParticipantJoinFail(1)
-		handleError(w, http.StatusInternalServerError, err, loggerFields...)
+		tracing.End(joinSpan, err)
+		prometheus.IncrementParticipantJoinFail(joinCtx, 1)
+		// refusals of core nodes are relayed on edges
+		switch code := joinErrorCode(err); code {
+		case errorCodeQuotaExceeded, errorCodeSessionLimitExceeded:
+			w.Header().Set(errorCodeHeader, code)
+			handleError(w, http.StatusTooManyRequests, err, loggerFields...)
+		case errorCodePlacementUnavailable:
+			w.Header().Set(errorCodeHeader, code)
+			handleError(w, http.StatusServiceUnavailable, ErrPlacementUnavailable, loggerFields...)
+		case errorCodeRoomNotFound:
+			w.Header().Set(errorCodeHeader, code)
+			handleError(w, http.StatusNotFound, err, loggerFields...)
+		case errorCodeRoomSealed:
+			handleError(w, http.StatusForbidden, err, loggerFields...)
+		default:
+			handleError(w, http.StatusInternalServerError, err, loggerFields...)
+		}
 		return
 	}
 
+	joinSpan.SetAttributes(attribute.String("connID", string(cr.ConnectionID)))
+	tracing.End(joinSpan, nil)
 	prometheus.IncrementParticipantJoin(1)
+	prometheus.RecordParticipantJoinLatency(joinCtx, time.Since(joinStart))
 
 	if !pi.Reconnect && initialResponse.GetJoin() != nil {
 		pi.ID = livekit.ParticipantID(initialResponse.GetJoin().GetParticipant().GetSid())
@@ -249,6 +405,7 @@ func (s *RTCService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			s.telemetry)
 	}
 
+	// the participant SID of new joins is only known from the join response
 	pLogger := rtc.LoggerWithParticipant(
 		rtc.LoggerWithRoom(logger.GetLogger(), roomName, livekit.RoomID(cr.Room.Sid)),
 		pi.Identity,
@@ -270,7 +427,20 @@ func (s *RTCService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}()
 
 	// upgrade only once the basics are good to go
-	conn, err := s.upgrader.Upgrade(w, r, nil)
+	responseHeader := http.Header{}
+	if s.reconnectPolicy != nil {
+		responseHeader[reconnectPolicyHeader] = []string{string(s.reconnectPolicy)}
+	}
+	// new sessions get the secret they can resume with once their access token expired
+	if store, ok := s.store.(ResumeTokenStore); ok && s.config.ResumeToken.Enabled && !pi.Reconnect && pi.ID != "" {
+		secret, err := issueResumeToken(r.Context(), store, s.config.ResumeToken.TTL, roomName, pi.Identity, pi.ID)
+		if err != nil {
+			pLogger.Warnw("could not issue resume token", err)
+		} else {
+			responseHeader.Set(resumeTokenHeader, secret)
+		}
+	}
+	conn, err := s.upgrader.Upgrade(w, r, responseHeader)
 	if err != nil {
 		handleError(w, http.StatusInternalServerError, err, loggerFields...)
 		return
@@ -287,7 +457,10 @@ func (s *RTCService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}()
 
 	// websocket established
-	sigConn := NewWSSignalConnection(conn)
+	if apiKey := GetAPIKey(r.Context()); apiKey != "" {
+		defer prometheus.AddAPIKeyParticipant(apiKey)()
+	}
+	sigConn := NewWSSignalConnection(conn, &s.config.Heartbeat)
 	if count, err := sigConn.WriteResponse(initialResponse); err != nil {
 		pLogger.Warnw("could not write initial response", err)
 		return
@@ -311,8 +484,13 @@ func (s *RTCService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			_ = conn.Close()
 		}()
 		defer func() {
-			if r := rtc.Recover(pLogger); r != nil {
-				os.Exit(1)
+			if r := recover(); r != nil {
+				rtc.ReportPanic(pLogger, rtc.CrashReport{
+					Component:     "signal",
+					Room:          roomName,
+					Participant:   pi.Identity,
+					ParticipantID: pi.ID,
+				}, r)
 			}
 		}()
 		for {
@@ -373,6 +551,9 @@ func (s *RTCService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 					websocket.CloseNoStatusReceived,
 				) {
 				pLogger.Debugw("exit ws read loop for closed connection", "connID", cr.ConnectionID, "wsError", err)
+			} else if isTimeout(err) {
+				pLogger.Infow("closing unresponsive websocket", "connID", cr.ConnectionID, "pongTimeout", s.config.Heartbeat.PongTimeout)
+				prometheus.RecordSignalPongTimeout()
 			} else {
 				pLogger.Errorw("error reading from websocket", err, "connID", cr.ConnectionID)
 			}
@@ -517,13 +698,37 @@ type connectionResult struct {
 func (s *RTCService) startConnection(ctx context.Context, roomName livekit.RoomName, pi routing.ParticipantInit, timeout time.Duration) (connectionResult, *livekit.SignalResponse, error) {
 	var cr connectionResult
 	var err error
-	cr.Room, err = s.roomAllocator.CreateRoom(ctx, &livekit.CreateRoomRequest{Name: string(roomName)})
-	if err != nil {
-		return cr, nil, err
+	if s.edge != nil {
+		// the room is created by the core node the join is relayed to
+		cr.Room = &livekit.Room{Name: string(roomName)}
+	} else if dr := degradedRouter(s.router); dr != nil && dr.IsLocalRoom(roomName) {
+		// the room keeps running on this node, its stored state cannot be loaded
+		cr.Room = &livekit.Room{Name: string(roomName)}
+	} else {
+		createCtx, span := tracing.Start(ctx, "roomAllocator.CreateRoom")
+		cr.Room, err = s.roomAllocator.CreateRoom(createCtx, &livekit.CreateRoomRequest{Name: string(roomName)})
+		tracing.End(span, err)
+		if err != nil {
+			return cr, nil, err
+		}
+	}
+
+	// the room SID is known once the room is created, relayed joins learn it from the core node
+	if cr.Room.GetSid() != "" {
+		ctx = utils.ContextWithLogger(ctx, rtc.LoggerWithParticipant(
+			rtc.LoggerWithRoom(logger.GetLogger(), roomName, livekit.RoomID(cr.Room.Sid)),
+			pi.Identity,
+			pi.ID,
+			false,
+		))
 	}
 
 	// this needs to be started first *before* using router functions on this node
-	cr.ConnectionID, cr.RequestSink, cr.ResponseSource, err = s.router.StartParticipantSignal(ctx, roomName, pi)
+	if s.edge != nil {
+		cr.ConnectionID, cr.RequestSink, cr.ResponseSource, err = s.edge.StartParticipantSignal(ctx, roomName, pi)
+	} else {
+		cr.ConnectionID, cr.RequestSink, cr.ResponseSource, err = s.router.StartParticipantSignal(ctx, roomName, pi)
+	}
 	if err != nil {
 		return cr, nil, err
 	}
@@ -531,11 +736,17 @@ func (s *RTCService) startConnection(ctx context.Context, roomName livekit.RoomN
 	// wait for the first message before upgrading to websocket. If no one is
 	// responding to our connection attempt, we should terminate the connection
 	// instead of waiting forever on the WebSocket
+	_, span := tracing.Start(ctx, "signal.ReadInitialResponse")
 	initialResponse, err := readInitialResponse(cr.ResponseSource, timeout)
+	tracing.End(span, err)
 	if err != nil {
 		// close the connection to avoid leaking
 		cr.RequestSink.Close()
 		cr.ResponseSource.Close()
+		if relayed, ok := cr.ResponseSource.(*edgeResponseSource); ok && relayed.Err() != nil {
+			// refused by the core node
+			return cr, nil, relayed.Err()
+		}
 		return cr, nil, err
 	}
 	return cr, initialResponse, nil
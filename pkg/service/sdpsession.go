@@ -0,0 +1,215 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pion/sdp/v3"
+	"github.com/pion/webrtc/v3"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/logger"
+
+	"github.com/livekit/livekit-server/pkg/routing"
+)
+
+const (
+	sdpSignalTimeout = 10 * time.Second
+	// server candidates trickled within this time after the session description are added to it,
+	// WHIP and WHEP clients don't receive candidates otherwise
+	sdpCandidateWait = 500 * time.Millisecond
+	sdpMaxSize       = 1 << 20
+)
+
+var (
+	errSDPSessionLeft    = errors.New("participant left during negotiation")
+	errSDPSessionTimeout = errors.New("timed out while waiting for signal response")
+)
+
+// sdpResourceSegments returns the unescaped path segments following the prefix
+func sdpResourceSegments(path, prefix string) []string {
+	segments := strings.Split(strings.Trim(strings.TrimPrefix(path, prefix), "/"), "/")
+	if len(segments) == 1 && segments[0] == "" {
+		return nil
+	}
+	for i, segment := range segments {
+		segments[i], _ = url.PathUnescape(segment)
+	}
+	return segments
+}
+
+func sdpResource(prefix string, roomName livekit.RoomName, identity livekit.ParticipantIdentity) string {
+	return prefix + "/" + url.PathEscape(string(roomName)) + "/" + url.PathEscape(string(identity))
+}
+
+// authorizeSDPResource allows the participant of the session, and room admins when allowAdmin is set
+func authorizeSDPResource(r *http.Request, roomName livekit.RoomName, identity livekit.ParticipantIdentity, allowAdmin bool) error {
+	claims := GetGrants(r.Context())
+	if claims == nil || claims.Identity != string(identity) {
+		if !allowAdmin {
+			return ErrPermissionDenied
+		}
+		return EnsureAdminPermission(r.Context(), roomName)
+	}
+	_, err := EnsureJoinPermission(r.Context())
+	return err
+}
+
+// teardownSDPSession ends the session of a participant, also when it was started on another node.
+// closeLocal closes it when it is running on this node.
+func teardownSDPSession(
+	w http.ResponseWriter,
+	r *http.Request,
+	router routing.MessageRouter,
+	roomName livekit.RoomName,
+	identity livekit.ParticipantIdentity,
+	closeLocal func() bool,
+) {
+	if err := authorizeSDPResource(r, roomName, identity, true); err != nil {
+		handleError(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	if closeLocal() {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	err := router.WriteParticipantRTC(r.Context(), roomName, identity, &livekit.RTCNodeMessage{
+		Message: &livekit.RTCNodeMessage_RemoveParticipant{
+			RemoveParticipant: &livekit.RoomParticipantIdentity{
+				Room:     string(roomName),
+				Identity: string(identity),
+			},
+		},
+	})
+	if err != nil {
+		handleError(w, http.StatusNotFound, ErrParticipantNotFound, "room", roomName, "participant", identity)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// sdpWithCandidates adds trickled candidates to the sections of the description they belong to
+func sdpWithCandidates(description string, candidates []webrtc.ICECandidateInit) (string, error) {
+	parsed := sdp.SessionDescription{}
+	if err := parsed.Unmarshal([]byte(description)); err != nil {
+		return "", err
+	}
+	if len(parsed.MediaDescriptions) == 0 {
+		return description, nil
+	}
+
+	for _, c := range candidates {
+		md := parsed.MediaDescriptions[0]
+		if c.SDPMLineIndex != nil && int(*c.SDPMLineIndex) < len(parsed.MediaDescriptions) {
+			md = parsed.MediaDescriptions[*c.SDPMLineIndex]
+		}
+		value := strings.TrimPrefix(c.Candidate, "candidate:")
+		exists := false
+		for _, a := range md.Attributes {
+			if a.Key == "candidate" && a.Value == value {
+				exists = true
+				break
+			}
+		}
+		if !exists {
+			md.WithValueAttribute("candidate", value)
+		}
+	}
+	for _, md := range parsed.MediaDescriptions {
+		if _, ok := md.Attribute("end-of-candidates"); !ok {
+			md.WithPropertyAttribute("end-of-candidates")
+		}
+	}
+
+	munged, err := parsed.Marshal()
+	if err != nil {
+		return "", err
+	}
+	return string(munged), nil
+}
+
+// ----------------------------------------------------
+
+// sdpSession is the signal connection of a participant negotiating over HTTP
+type sdpSession struct {
+	kind     string
+	cr       connectionResult
+	resource string
+	logger   logger.Logger
+
+	closeOnce sync.Once
+}
+
+func (s *sdpSession) read(timeout <-chan time.Time) (*livekit.SignalResponse, error) {
+	select {
+	case <-timeout:
+		return nil, errSDPSessionTimeout
+	case msg := <-s.cr.ResponseSource.ReadChan():
+		return sdpSessionResponse(msg)
+	}
+}
+
+func sdpSessionResponse(msg proto.Message) (*livekit.SignalResponse, error) {
+	if msg == nil {
+		return nil, errors.New("connection closed by media")
+	}
+	res, ok := msg.(*livekit.SignalResponse)
+	if !ok {
+		return nil, fmt.Errorf("unexpected message type: %T", msg)
+	}
+	if res.GetLeave() != nil {
+		return nil, errSDPSessionLeft
+	}
+	return res, nil
+}
+
+func (s *sdpSession) write(req *livekit.SignalRequest) error {
+	return s.cr.RequestSink.WriteMessage(req)
+}
+
+// run drains responses until the participant is closed
+func (s *sdpSession) run() {
+	defer s.close()
+	for msg := range s.cr.ResponseSource.ReadChan() {
+		if res, ok := msg.(*livekit.SignalResponse); ok && res.GetLeave() != nil {
+			return
+		}
+	}
+}
+
+func (s *sdpSession) leave() {
+	_ = s.write(&livekit.SignalRequest{
+		Message: &livekit.SignalRequest_Leave{Leave: &livekit.LeaveRequest{}},
+	})
+	s.close()
+}
+
+func (s *sdpSession) close() {
+	s.closeOnce.Do(func() {
+		s.logger.Infow(s.kind+" session finished", "connID", s.cr.ConnectionID)
+		s.cr.RequestSink.Close()
+		s.cr.ResponseSource.Close()
+	})
+}
@@ -16,6 +16,7 @@ package service
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -28,36 +29,73 @@ import (
 	"time"
 
 	"github.com/pion/turn/v2"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/pion/webrtc/v3"
 	"github.com/rs/cors"
 	"github.com/twitchtv/twirp"
 	"github.com/urfave/negroni/v3"
 	"go.uber.org/atomic"
 	"golang.org/x/sync/errgroup"
 
+	"github.com/livekit/livekit-server/pkg/bridge"
 	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/hls"
+	"github.com/livekit/livekit-server/pkg/postroom"
+	"github.com/livekit/livekit-server/pkg/recording"
 	"github.com/livekit/livekit-server/pkg/routing"
+	"github.com/livekit/livekit-server/pkg/rtc"
+	"github.com/livekit/livekit-server/pkg/telemetry/prometheus"
+	"github.com/livekit/livekit-server/pkg/transcription"
 	sutils "github.com/livekit/livekit-server/pkg/utils"
+	"github.com/livekit/livekit-server/pkg/webhooks"
 	"github.com/livekit/livekit-server/version"
 	"github.com/livekit/protocol/auth"
 	"github.com/livekit/protocol/livekit"
 	"github.com/livekit/protocol/logger"
+	"github.com/livekit/protocol/webhook"
 )
 
 type LivekitServer struct {
-	config       *config.Config
-	ioService    *IOInfoService
-	rtcService   *RTCService
-	httpServer   *http.Server
-	promServer   *http.Server
-	router       routing.Router
-	roomManager  *RoomManager
-	signalServer *SignalServer
-	turnServer   *turn.Server
-	currentNode  routing.LocalNode
-	running      atomic.Bool
-	doneChan     chan struct{}
-	closedChan   chan struct{}
+	config         *config.Config
+	ioService      *IOInfoService
+	rtcService     *RTCService
+	httpServer     *http.Server
+	listeners      []*listenerServer
+	unixServer     *http.Server
+	promServer     *http.Server
+	acmeServer     *http.Server
+	tlsConfig      *tls.Config
+	router         routing.Router
+	roomManager    *RoomManager
+	recordings     *recording.Manager
+	hls            *hls.Manager
+	transcriptions *transcription.Manager
+	postRoom       *postroom.Manager
+	roomPersister  *RoomPersister
+	roomScheduler  *RoomScheduler
+	quotas         *APIKeyQuotas
+	tokenKeys      *TokenKeys
+	adminOIDC      *AdminOIDC
+	budget         *ResourceBudget
+	edgeClient     *EdgeRelayClient
+	edgeServer     *EdgeRelayServer
+	webhookArchive *webhooks.Archive
+	bridges        *bridge.Manager
+	signalServer   *SignalServer
+	turnServer     *turn.Server
+	currentNode    routing.LocalNode
+	running        atomic.Bool
+	startedChan    chan struct{}
+	doneChan       chan struct{}
+	closedChan     chan struct{}
+	// unix nanoseconds when draining started, 0 when not draining
+	drainStartedAt atomic.Int64
+	// set once the node was deregistered from load balancers, on drain or shutdown
+	outOfRotation atomic.Bool
+
+	// components applying reloaded config
+	roomService     livekit.RoomService
+	keyProvider     auth.KeyProvider
+	webhookNotifier webhook.QueuedNotifier
 }
 
 func NewLivekitServer(conf *config.Config,
@@ -65,25 +103,78 @@ func NewLivekitServer(conf *config.Config,
 	egressService *EgressService,
 	ingressService *IngressService,
 	ioService *IOInfoService,
+	ioWorkers *IOWorkerRegistry,
+	recordingService *RecordingService,
+	hlsService *HLSService,
+	bridgeService *BridgeService,
 	rtcService *RTCService,
 	keyProvider auth.KeyProvider,
+	webhookNotifier webhook.QueuedNotifier,
+	webhookDeadLetters *webhooks.DeadLetters,
+	webhookArchive *webhooks.Archive,
+	qosReports *QoSReports,
 	router routing.Router,
 	roomManager *RoomManager,
 	signalServer *SignalServer,
 	turnServer *turn.Server,
+	turnAllocations *TurnAllocations,
 	currentNode routing.LocalNode,
 ) (s *LivekitServer, err error) {
 	s = &LivekitServer{
-		config:       conf,
-		ioService:    ioService,
-		rtcService:   rtcService,
-		router:       router,
-		roomManager:  roomManager,
-		signalServer: signalServer,
+		config:         conf,
+		ioService:      ioService,
+		rtcService:     rtcService,
+		router:         router,
+		roomManager:    roomManager,
+		recordings:     recordingService.manager,
+		hls:            hlsService.manager,
+		transcriptions: transcription.NewManager(conf),
+		bridges:        bridgeService.manager,
+		signalServer:   signalServer,
+		webhookArchive: webhookArchive,
 		// turn server starts automatically
 		turnServer:  turnServer,
 		currentNode: currentNode,
+		startedChan: make(chan struct{}),
 		closedChan:  make(chan struct{}),
+
+		roomService:     roomService,
+		keyProvider:     keyProvider,
+		webhookNotifier: webhookNotifier,
+	}
+
+	var recordings postroom.Recordings
+	if s.recordings != nil {
+		recordings = s.recordings
+	}
+	purger, _ := roomManager.roomStore.(postroom.KeyPurger)
+	if s.postRoom, err = postroom.NewManager(conf, keyProvider, recordings, purger); err != nil {
+		return
+	}
+	roomManager.SetPostRoom(s.postRoom)
+	if s.roomPersister, err = NewRoomPersister(conf, roomManager.roomStore); err != nil {
+		return
+	}
+	s.roomScheduler = NewRoomScheduler(&conf.Room, roomService, roomManager.roomStore, roomManager.telemetry)
+	s.quotas = NewAPIKeyQuotas(conf, roomManager.roomStore, roomManager)
+	if s.tokenKeys, err = NewTokenKeys(&conf.TokenKeys); err != nil {
+		return
+	}
+	s.adminOIDC = NewAdminOIDC(&conf.AdminOIDC)
+	trustedProxies, err := NewTrustedProxies(conf.TrustedProxies)
+	if err != nil {
+		return
+	}
+	if s.edgeClient, err = NewEdgeRelayClient(conf); err != nil {
+		return
+	}
+	rtcService.SetEdgeRelay(s.edgeClient)
+	s.budget = NewResourceBudget(&conf.ResourceBudget)
+	rtcService.SetResourceBudget(s.budget)
+	roomManager.SetResourceBudget(s.budget)
+	roomManager.SetQoSReports(qosReports)
+	if s.edgeServer, err = NewEdgeRelayServer(conf, rtcService); err != nil {
+		return
 	}
 
 	middlewares := []negroni.Handler{
@@ -95,12 +186,18 @@ func NewLivekitServer(conf *config.Config,
 				return true
 			},
 			AllowedHeaders: []string{"*"},
+			// WHEP players answer with PATCH, WHIP and WHEP sessions end with DELETE on their Location
+			AllowedMethods: []string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPatch, http.MethodDelete},
+			ExposedHeaders: []string{"Location"},
 			// allow preflight to be cached for a day
 			MaxAge: 86400,
 		}),
 	}
 	if keyProvider != nil {
-		middlewares = append(middlewares, NewAPIKeyAuthMiddleware(keyProvider))
+		middlewares = append(middlewares, NewAPIKeyAuthMiddleware(keyProvider, NewAuthLockout(&conf.AuthLockout, trustedProxies), roomManager.resumeTokenStore(), s.tokenKeys))
+	}
+	if s.adminOIDC != nil {
+		middlewares = append(middlewares, s.adminOIDC)
 	}
 
 	twirpLoggingHook := TwirpLogger(logger.GetLogger().WithComponent(sutils.ComponentAPI))
@@ -120,29 +217,115 @@ func NewLivekitServer(conf *config.Config,
 		mux = http.DefaultServeMux
 		mux.HandleFunc("/debug/goroutine", s.debugGoroutines)
 		mux.HandleFunc("/debug/rooms", s.debugInfo)
+		mux.Handle(impairmentPath, NewImpairmentService(roomManager))
+		mux.Handle(chaosPath, NewChaosService(router, roomManager))
 	}
-	mux.Handle(roomServer.PathPrefix(), roomServer)
+	mux.Handle(roomServer.PathPrefix(), WithRoomTemplate(&conf.Room, WithRoomRegions(WithDeleteRoomDelay(roomServer))))
 	mux.Handle(egressServer.PathPrefix(), egressServer)
 	mux.Handle(ingressServer.PathPrefix(), ingressServer)
+	mux.Handle("/io/workers", ioWorkers)
+	mux.Handle(recordingsPath, recordingService)
+	mux.Handle(recordingsPath+"/", recordingService)
+	mux.Handle(hls.PathPrefix, hlsService)
+	mux.Handle(hls.PathPrefix+"/", hlsService)
+	transcriptionService := NewTranscriptionService(s.transcriptions, roomManager)
+	mux.Handle(transcriptionPath, transcriptionService)
+	mux.Handle(transcriptionPath+"/", transcriptionService)
+	deadLetterService := NewWebhookDeadLetterService(webhookDeadLetters)
+	mux.Handle(webhookDeadLettersPath, deadLetterService)
+	mux.Handle(webhookDeadLettersPath+"/", deadLetterService)
+	archiveService := NewWebhookArchiveService(webhookArchive)
+	mux.Handle(webhookArchivePath, archiveService)
+	mux.Handle(webhookArchivePath+"/", archiveService)
+	postRoomService := NewPostRoomService(s.postRoom)
+	mux.Handle(postRoomPath, postRoomService)
+	mux.Handle(postRoomPath+"/", postRoomService)
+	mux.Handle(bridgesPath, bridgeService)
+	mux.Handle(bridgesPath+"/", bridgeService)
+	mux.Handle(videoAllocationPath, NewVideoAllocationService(roomManager))
+	mux.Handle(bandwidthOverridePath, NewBandwidthOverrideService(roomManager))
+	mux.Handle(pausedVideoPlaceholderPath, NewPausedVideoPlaceholderService(roomManager))
+	mux.Handle(e2eePath, NewE2EEService(roomManager))
+	mux.Handle(departureTimeoutPath, NewDepartureTimeoutService(roomManager))
+	mux.Handle(speakerStatsPath, NewSpeakerStatsService(roomManager))
+	mux.Handle(participantStatsPath, NewParticipantStatsService(roomManager))
+	mux.Handle(broadcastPath, NewBroadcastService(roomManager.roomStore))
+	mux.Handle(subscriptionPermissionsPath, NewSubscriptionPermissionsService(roomManager))
+	mux.Handle(trackMutePath, NewTrackMuteService(roomManager))
+	mux.Handle(roomSealPath, NewRoomSealService(roomManager.roomStore, router))
+	mux.Handle(roomClosurePath, NewRoomClosureService(roomManager.roomStore, router))
+	mux.Handle(restreamPath, NewRestreamService(conf, egressService, roomService, roomManager.roomStore, ioService))
+	mux.Handle(bulkParticipantsPath, NewBulkParticipantsService(roomService, roomManager.roomStore))
+	mux.Handle(turnAllocationsPath, NewTurnAllocationService(turnAllocations))
+	mux.Handle(roomTemplatesPath, NewRoomTemplateService(&conf.Room))
+	mux.Handle(nodeLatencyPath, NewNodeLatencyService(router))
+	mux.Handle(keyUsagePath, NewKeyUsageService(currentNode.Id))
+	mux.Handle(apiKeyQuotasPath, NewAPIKeyQuotaService(s.quotas))
+	mux.Handle(warmRoomPath, NewWarmRoomService(roomService, router, roomManager, currentNode))
+	mux.Handle(roomSchedulePath, s.roomScheduler)
+	whipService := NewWHIPService(rtcService)
+	mux.Handle(whipPath, whipService)
+	mux.Handle(whipPath+"/", whipService)
+	whepService := NewWHEPService(rtcService)
+	mux.Handle(whepPath, whepService)
+	mux.Handle(whepPath+"/", whepService)
 	mux.Handle("/rtc", rtcService)
 	mux.HandleFunc("/rtc/validate", rtcService.Validate)
+	mux.HandleFunc("/status", s.status)
+	mux.HandleFunc(drainPath, s.drainHandler)
+	mux.HandleFunc(readyPath, s.ready)
 	mux.HandleFunc("/", s.defaultHandler)
 
 	// campus service
-	campusService := NewCampusService(conf, router, currentNode)
+	campusService := NewCampusService(conf, router, currentNode, trustedProxies)
 	mux.Handle("/campus", campusService)
 	mux.HandleFunc("/campus/requestToken", campusService.RequestToken)
 
 	s.httpServer = &http.Server{
 		Handler: configureMiddlewares(mux, middlewares...),
 	}
+	if conf.ControlSocket.Path != "" {
+		s.unixServer = &http.Server{
+			Handler: configureMiddlewares(newControlSocketHandler(mux), negroni.NewRecovery()),
+		}
+	}
+	for _, lc := range conf.Listeners {
+		s.listeners = append(s.listeners, &listenerServer{
+			config: lc,
+			server: &http.Server{
+				Handler: newListenerHandler(lc.Roles, s.httpServer.Handler, prometheus.Handler(conf.PrometheusExemplars)),
+			},
+		})
+	}
+
+	if conf.TLS.IsEnabled() {
+		var challengeHandler http.Handler
+		if s.tlsConfig, challengeHandler, err = newTLSConfig(&conf.TLS); err != nil {
+			return
+		}
+		if challengeHandler != nil {
+			s.acmeServer = &http.Server{
+				Handler: challengeHandler,
+			}
+		}
+	}
 
 	if conf.PrometheusPort > 0 {
 		s.promServer = &http.Server{
-			Handler: promhttp.Handler(),
+			Handler: prometheus.Handler(conf.PrometheusExemplars),
 		}
 	}
 
+	if reporter := NewCrashReporter(conf.CrashReport, livekit.NodeID(currentNode.Id)); reporter != nil {
+		rtc.SetCrashReporter(reporter)
+	}
+
+	var publishHook *PublishHook
+	if publishHook, err = NewPublishHook(conf, keyProvider); err != nil {
+		return
+	}
+	roomManager.SetPublishHook(publishHook)
+
 	// clean up old rooms on startup
 	if err = roomManager.CleanupRooms(); err != nil {
 		return
@@ -166,6 +349,11 @@ func (s *LivekitServer) IsRunning() bool {
 	return s.running.Load()
 }
 
+// Started is closed once the server accepts connections
+func (s *LivekitServer) Started() <-chan struct{} {
+	return s.startedChan
+}
+
 func (s *LivekitServer) Start() error {
 	if s.running.Load() {
 		return errors.New("already running")
@@ -189,6 +377,15 @@ func (s *LivekitServer) Start() error {
 		return err
 	}
 
+	if err := s.roomPersister.Start(); err != nil {
+		return err
+	}
+	s.roomScheduler.Start()
+	s.quotas.Start()
+	s.tokenKeys.Start()
+	s.adminOIDC.Start()
+	s.budget.Start()
+
 	addresses := s.config.BindAddresses
 	if addresses == nil {
 		addresses = []string{""}
@@ -197,15 +394,30 @@ func (s *LivekitServer) Start() error {
 	// ensure we could listen
 	listeners := make([]net.Listener, 0)
 	promListeners := make([]net.Listener, 0)
+	acmeListeners := make([]net.Listener, 0)
 	for _, addr := range addresses {
-		ln, err := net.Listen("tcp", net.JoinHostPort(addr, strconv.Itoa(int(s.config.Port))))
-		if err != nil {
-			return err
+		// configured listeners replace the main listeners
+		if len(s.listeners) == 0 {
+			ln, err := net.Listen("tcp", net.JoinHostPort(addr, strconv.Itoa(int(s.config.Port))))
+			if err != nil {
+				return err
+			}
+			if s.tlsConfig != nil {
+				ln = tls.NewListener(ln, s.tlsConfig)
+			}
+			listeners = append(listeners, ln)
+		}
+
+		if s.acmeServer != nil {
+			ln, err := net.Listen("tcp", net.JoinHostPort(addr, strconv.Itoa(int(s.config.TLS.ACME.HTTPChallengePort))))
+			if err != nil {
+				return err
+			}
+			acmeListeners = append(acmeListeners, ln)
 		}
-		listeners = append(listeners, ln)
 
 		if s.promServer != nil {
-			ln, err = net.Listen("tcp", net.JoinHostPort(addr, strconv.Itoa(int(s.config.PrometheusPort))))
+			ln, err := net.Listen("tcp", net.JoinHostPort(addr, strconv.Itoa(int(s.config.PrometheusPort))))
 			if err != nil {
 				return err
 			}
@@ -213,6 +425,27 @@ func (s *LivekitServer) Start() error {
 		}
 	}
 
+	roleListeners := make([]net.Listener, 0, len(s.listeners))
+	for _, ls := range s.listeners {
+		ln, err := net.Listen("tcp", ls.config.Address)
+		if err != nil {
+			return err
+		}
+		if ls.config.TLS {
+			ln = tls.NewListener(ln, s.tlsConfig)
+		}
+		roleListeners = append(roleListeners, ln)
+	}
+
+	var controlListener net.Listener
+	if s.unixServer != nil {
+		ln, err := listenControlSocket(&s.config.ControlSocket)
+		if err != nil {
+			return err
+		}
+		controlListener = ln
+	}
+
 	values := []interface{}{
 		"portHttp", s.config.Port,
 		"nodeID", s.currentNode.Id,
@@ -222,6 +455,12 @@ func (s *LivekitServer) Start() error {
 	if s.config.BindAddresses != nil {
 		values = append(values, "bindAddresses", s.config.BindAddresses)
 	}
+	if len(s.config.Listeners) != 0 {
+		values = append(values, "listeners", s.config.Listeners)
+	}
+	if s.unixServer != nil {
+		values = append(values, "controlSocket", s.config.ControlSocket.Path)
+	}
 	if s.config.RTC.TCPPort != 0 {
 		values = append(values, "rtc.portTCP", s.config.RTC.TCPPort)
 	}
@@ -235,10 +474,22 @@ func (s *LivekitServer) Start() error {
 	if s.config.PrometheusPort != 0 {
 		values = append(values, "portPrometheus", s.config.PrometheusPort)
 	}
+	if s.tlsConfig != nil {
+		values = append(values, "tls", true)
+	}
+	if s.acmeServer != nil {
+		values = append(values, "portACMEChallenge", s.config.TLS.ACME.HTTPChallengePort)
+	}
 	if s.config.Region != "" {
 		values = append(values, "region", s.config.Region)
 	}
+	if s.config.RTC.UseICELite {
+		values = append(values, "rtc.iceLite", true)
+	}
 	logger.Infow("starting LiveKit server", values...)
+	if err := s.config.RTC.CheckICELite(); err != nil {
+		logger.Warnw("clients behind NAT may fail to connect", err, "nodeIP", s.config.RTC.NodeIP)
+	}
 	if runtime.GOOS == "windows" {
 		logger.Infow("Windows detected, capacity management is unavailable")
 	}
@@ -247,9 +498,16 @@ func (s *LivekitServer) Start() error {
 		go s.promServer.Serve(promLn)
 	}
 
+	for _, acmeLn := range acmeListeners {
+		go s.acmeServer.Serve(acmeLn)
+	}
+
 	if err := s.signalServer.Start(); err != nil {
 		return err
 	}
+	if err := s.edgeServer.Start(); err != nil {
+		return err
+	}
 
 	httpGroup := &errgroup.Group{}
 	for _, ln := range listeners {
@@ -258,6 +516,17 @@ func (s *LivekitServer) Start() error {
 			return s.httpServer.Serve(l)
 		})
 	}
+	for i, ln := range roleListeners {
+		srv, l := s.listeners[i].server, ln
+		httpGroup.Go(func() error {
+			return srv.Serve(l)
+		})
+	}
+	if controlListener != nil {
+		httpGroup.Go(func() error {
+			return s.unixServer.Serve(controlListener)
+		})
+	}
 	go func() {
 		if err := httpGroup.Wait(); err != http.ErrServerClosed {
 			logger.Errorw("could not start server", err)
@@ -271,6 +540,14 @@ func (s *LivekitServer) Start() error {
 	time.Sleep(100 * time.Millisecond)
 
 	s.running.Store(true)
+	select {
+	case <-s.startedChan:
+	default:
+		close(s.startedChan)
+	}
+
+	// bridges join local rooms through the listeners
+	s.bridges.Start()
 
 	<-s.doneChan
 
@@ -278,11 +555,34 @@ func (s *LivekitServer) Start() error {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
 	defer cancel()
 	_ = s.httpServer.Shutdown(ctx)
+	for _, ls := range s.listeners {
+		_ = ls.server.Shutdown(ctx)
+	}
+	if s.unixServer != nil {
+		_ = s.unixServer.Shutdown(ctx)
+	}
+	if s.acmeServer != nil {
+		_ = s.acmeServer.Shutdown(ctx)
+	}
 
 	if s.turnServer != nil {
 		_ = s.turnServer.Close()
 	}
 
+	s.bridges.Close()
+	s.recordings.Close()
+	s.hls.Close()
+	s.transcriptions.Close()
+	s.postRoom.Close()
+	s.roomPersister.Stop()
+	s.roomScheduler.Stop()
+	s.quotas.Stop()
+	s.tokenKeys.Stop()
+	s.adminOIDC.Stop()
+	s.budget.Stop()
+	s.webhookArchive.Stop()
+	s.edgeServer.Stop()
+	s.edgeClient.Close()
 	s.roomManager.Stop()
 	s.signalServer.Stop()
 	s.ioService.Stop()
@@ -292,6 +592,13 @@ func (s *LivekitServer) Start() error {
 }
 
 func (s *LivekitServer) Stop(force bool) {
+	wasDraining := s.isOutOfRotation()
+	s.takeOutOfRotation("shutdown")
+	if grace := s.config.Drain.LBGracePeriod; !force && !wasDraining && grace > 0 {
+		logger.Infow("waiting for load balancers to stop sending connections", "gracePeriod", grace)
+		time.Sleep(grace)
+	}
+
 	// wait for all participants to exit
 	s.router.Drain()
 	partTicker := time.NewTicker(5 * time.Second)
@@ -348,6 +655,12 @@ func (s *LivekitServer) defaultHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *LivekitServer) healthCheck(w http.ResponseWriter, _ *http.Request) {
+	if s.config.Drain.FailHealthCheck && s.isOutOfRotation() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte("Draining"))
+		return
+	}
+
 	var updatedAt time.Time
 	if s.Node().Stats != nil {
 		updatedAt = time.Unix(s.Node().Stats.UpdatedAt, 0)
@@ -362,6 +675,45 @@ func (s *LivekitServer) healthCheck(w http.ResponseWriter, _ *http.Request) {
 	_, _ = w.Write([]byte("OK"))
 }
 
+type nodeStatus struct {
+	NodeID  string      `json:"node_id"`
+	Region  string      `json:"region,omitempty"`
+	Version string      `json:"version"`
+	ICELite bool        `json:"ice_lite"`
+	DTLS    *dtlsStatus `json:"dtls,omitempty"`
+}
+
+type dtlsStatus struct {
+	Fingerprints []webrtc.DTLSFingerprint `json:"fingerprints"`
+	Expires      time.Time                `json:"expires"`
+}
+
+// status describes the node to clients, including the fingerprints of the DTLS certificate
+// when all connections share one, which clients may pin
+func (s *LivekitServer) status(w http.ResponseWriter, _ *http.Request) {
+	res := &nodeStatus{
+		NodeID:  s.currentNode.Id,
+		Region:  s.config.Region,
+		Version: version.Version,
+		ICELite: s.config.RTC.UseICELite,
+	}
+
+	fingerprints, expires, err := s.roomManager.rtcConfig.DTLSFingerprints()
+	if err != nil {
+		handleError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if len(fingerprints) != 0 {
+		res.DTLS = &dtlsStatus{
+			Fingerprints: fingerprints,
+			Expires:      expires,
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(res)
+}
+
 // worker to perform periodic tasks per node
 func (s *LivekitServer) backgroundWorker() {
 	roomTicker := time.NewTicker(1 * time.Second)
@@ -0,0 +1,139 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/logger"
+	"github.com/livekit/protocol/utils"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/telemetry/prometheus"
+)
+
+const (
+	sessionLimitTTL             = 30 * time.Second
+	sessionLimitRefreshInterval = 10 * time.Second
+)
+
+// sessionLease holds the session limit slots of a signal connection until it is released
+type sessionLease struct {
+	store     SessionLimitStore
+	sessionID string
+	slot      string
+	keys      []string
+	done      chan struct{}
+}
+
+// acquireSessionLimits counts a joining participant against the session limits of its identity and API key,
+// returning ErrSessionLimitExceeded when either is reached, and against the max_participants quota of its API key,
+// returning ErrQuotaExceeded when it is reached. The lease is nil when no limits apply
+func acquireSessionLimits(
+	ctx context.Context,
+	store ServiceStore,
+	conf *config.SessionLimitConfig,
+	quotas *config.QuotaConfig,
+	apiKey string,
+	roomName livekit.RoomName,
+	identity livekit.ParticipantIdentity,
+) (*sessionLease, error) {
+	limitStore, ok := store.(SessionLimitStore)
+	if !ok {
+		return nil, nil
+	}
+
+	limits := conf.ForKey(apiKey)
+	type sessionLimit struct {
+		key string
+		max int
+		err error
+	}
+	var toAcquire []sessionLimit
+	if limits.MaxPerIdentity > 0 {
+		toAcquire = append(toAcquire, sessionLimit{"identity:" + apiKey + ":" + string(identity), limits.MaxPerIdentity, ErrSessionLimitExceeded})
+	}
+	if limits.MaxPerAPIKey > 0 && apiKey != "" {
+		toAcquire = append(toAcquire, sessionLimit{"api_key:" + apiKey, limits.MaxPerAPIKey, ErrSessionLimitExceeded})
+	}
+	if maxParticipants := quotas.ForKey(apiKey).MaxParticipants; maxParticipants > 0 && apiKey != "" {
+		toAcquire = append(toAcquire, sessionLimit{quotaParticipantsKey(apiKey), maxParticipants, ErrQuotaExceeded})
+	}
+	if len(toAcquire) == 0 {
+		return nil, nil
+	}
+
+	l := &sessionLease{
+		store:     limitStore,
+		sessionID: utils.NewGuid("SL_"),
+		slot:      string(roomName) + "/" + string(identity),
+	}
+	for _, limit := range toAcquire {
+		acquired, err := limitStore.AcquireSession(ctx, limit.key, l.slot, l.sessionID, limit.max, sessionLimitTTL)
+		if err != nil || !acquired {
+			l.releaseKeys()
+			if err != nil {
+				return nil, err
+			}
+			logger.Infow("session limit reached", "key", limit.key, "limit", limit.max, "room", roomName, "participant", identity)
+			if limit.err == ErrQuotaExceeded {
+				prometheus.RecordAPIKeyQuotaExceeded(apiKey, quotaParticipants)
+			}
+			return nil, limit.err
+		}
+		l.keys = append(l.keys, limit.key)
+	}
+
+	l.done = make(chan struct{})
+	go l.refreshWorker()
+	return l, nil
+}
+
+func (l *sessionLease) refreshWorker() {
+	ticker := time.NewTicker(sessionLimitRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.done:
+			return
+		case <-ticker.C:
+			for _, key := range l.keys {
+				if err := l.store.RefreshSession(context.Background(), key, l.slot, l.sessionID, sessionLimitTTL); err != nil {
+					logger.Warnw("could not refresh session", err, "key", key, "slot", l.slot)
+				}
+			}
+		}
+	}
+}
+
+// Release frees the slots of the lease, it is safe to call on a nil lease
+func (l *sessionLease) Release() {
+	if l == nil {
+		return
+	}
+	close(l.done)
+	l.releaseKeys()
+}
+
+func (l *sessionLease) releaseKeys() {
+	for _, key := range l.keys {
+		if err := l.store.ReleaseSession(context.Background(), key, l.slot, l.sessionID); err != nil {
+			logger.Warnw("could not release session", err, "key", key, "slot", l.slot)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/protocol/livekit"
+
+	"github.com/livekit/livekit-server/pkg/service"
+)
+
+func TestLocalStoreSessionLimits(t *testing.T) {
+	ctx := context.Background()
+	s := service.NewLocalStore()
+	key := "identity:key:alice"
+
+	ok, err := s.AcquireSession(ctx, key, "room1/alice", "s1", 1, time.Minute)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	// another room is over the limit
+	ok, err = s.AcquireSession(ctx, key, "room2/alice", "s2", 1, time.Minute)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	// rejoining the same room takes over the slot, the old session cannot release it anymore
+	ok, err = s.AcquireSession(ctx, key, "room1/alice", "s3", 1, time.Minute)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.NoError(t, s.ReleaseSession(ctx, key, "room1/alice", "s1"))
+	ok, err = s.AcquireSession(ctx, key, "room2/alice", "s2", 1, time.Minute)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	require.NoError(t, s.ReleaseSession(ctx, key, "room1/alice", "s3"))
+	ok, err = s.AcquireSession(ctx, key, "room2/alice", "s2", 1, time.Minute)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	// expired sessions free their slot
+	ok, err = s.AcquireSession(ctx, "api_key:key", "room1/bob", "s4", 1, time.Millisecond)
+	require.NoError(t, err)
+	require.True(t, ok)
+	time.Sleep(5 * time.Millisecond)
+	ok, err = s.AcquireSession(ctx, "api_key:key", "room1/carol", "s5", 1, time.Minute)
+	require.NoError(t, err)
+	require.True(t, ok)
+}
+
+func TestLocalStoreAPIKeyQuotas(t *testing.T) {
+	ctx := context.Background()
+	s := service.NewLocalStore()
+
+	ok, err := s.AcquireSession(ctx, "quota:key", "room1/alice", "s1", 2, time.Minute)
+	require.NoError(t, err)
+	require.True(t, ok)
+	count, err := s.CountSessions(ctx, "quota:key")
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+
+	// rooms are attributed to their key until deleted
+	require.NoError(t, s.StoreRoom(ctx, &livekit.Room{Name: "room1"}, nil))
+	require.NoError(t, s.StoreRoomAPIKey(ctx, "room1", "key"))
+	require.NoError(t, s.StoreRoom(ctx, &livekit.Room{Name: "room2"}, nil))
+	require.NoError(t, s.StoreRoomAPIKey(ctx, "room2", "key"))
+	counts, err := s.CountAPIKeyRooms(ctx)
+	require.NoError(t, err)
+	require.Equal(t, map[string]int{"key": 2}, counts)
+
+	require.NoError(t, s.DeleteRoom(ctx, "room1"))
+	counts, err = s.CountAPIKeyRooms(ctx)
+	require.NoError(t, err)
+	require.Equal(t, map[string]int{"key": 1}, counts)
+
+	// participant minutes add up per month
+	require.NoError(t, s.AddParticipantMinutes(ctx, "2023-05", map[string]float64{"key": 1.5}))
+	require.NoError(t, s.AddParticipantMinutes(ctx, "2023-05", map[string]float64{"key": 2, "other": 1}))
+	minutes, err := s.LoadParticipantMinutes(ctx, "2023-05")
+	require.NoError(t, err)
+	require.Equal(t, map[string]float64{"key": 3.5, "other": 1}, minutes)
+	minutes, err = s.LoadParticipantMinutes(ctx, "2023-06")
+	require.NoError(t, err)
+	require.Empty(t, minutes)
+}
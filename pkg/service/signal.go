@@ -18,11 +18,13 @@ import (
 	"context"
 
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
 	"google.golang.org/protobuf/proto"
 
 	"github.com/livekit/livekit-server/pkg/config"
 	"github.com/livekit/livekit-server/pkg/routing"
 	"github.com/livekit/livekit-server/pkg/telemetry/prometheus"
+	"github.com/livekit/livekit-server/pkg/telemetry/tracing"
 	"github.com/livekit/protocol/livekit"
 	"github.com/livekit/protocol/logger"
 	"github.com/livekit/protocol/rpc"
@@ -65,6 +67,11 @@ func NewSignalServer(
 	return &SignalServer{s, nodeID}, nil
 }
 
+// implemented by routers that keep participant => RTC node mappings in a shared store
+type participantRTCNodeSetter interface {
+	SetParticipantRTCNode(participantKey livekit.ParticipantKey, participantKeyB62 livekit.ParticipantKey, nodeID string) error
+}
+
 func NewDefaultSignalServer(
 	currentNode routing.LocalNode,
 	bus psrpc.MessageBus,
@@ -82,7 +89,7 @@ func NewDefaultSignalServer(
 	) error {
 		prometheus.IncrementParticipantRtcInit(1)
 
-		if rr, ok := router.(*routing.RedisRouter); ok {
+		if rr, ok := router.(participantRTCNodeSetter); ok {
 			rtcNode, err := router.GetNodeForRoom(ctx, roomName)
 			if err != nil {
 				return err
@@ -174,7 +181,14 @@ func (r *signalService) RelaySignal(stream psrpc.ServerStream[*rpc.RelaySignalRe
 	// copy the incoming rpc headers to avoid dropping any session vars.
 	ctx := metadata.NewContextWithIncomingHeader(context.Background(), metadata.IncomingHeader(stream.Context()))
 
+	// continues the trace of the join on the signal node
+	ctx, span := tracing.Start(tracing.ExtractMetadata(ctx), "rtc.StartSession",
+		attribute.String("room", ss.RoomName),
+		attribute.String("participant", ss.Identity),
+		attribute.String("connID", ss.ConnectionId),
+	)
 	err = r.sessionHandler(ctx, livekit.RoomName(ss.RoomName), *pi, livekit.ConnectionID(ss.ConnectionId), reqChan, sink)
+	tracing.End(span, err)
 	if err != nil {
 		l.Errorw("could not handle new participant", err)
 		return
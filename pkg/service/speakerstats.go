@@ -0,0 +1,85 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/livekit/protocol/livekit"
+)
+
+const speakerStatsPath = "/rooms/speaker_stats"
+
+type speakerStat struct {
+	Identity        string  `json:"identity"`
+	TalkTimeSeconds float64 `json:"talk_time_seconds"`
+	Turns           int     `json:"turns"`
+	Interruptions   int     `json:"interruptions"`
+	Interrupted     int     `json:"interrupted"`
+}
+
+type speakerStatsResponse struct {
+	Room     string        `json:"room"`
+	Speakers []speakerStat `json:"speakers"`
+}
+
+// SpeakerStatsService reports how long each participant of a room spoke and how often they interrupted or were
+// interrupted. Only rooms hosted on the node handling the request are known.
+type SpeakerStatsService struct {
+	roomManager *RoomManager
+}
+
+func NewSpeakerStatsService(roomManager *RoomManager) *SpeakerStatsService {
+	return &SpeakerStatsService{
+		roomManager: roomManager,
+	}
+}
+
+func (s *SpeakerStatsService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	roomName := livekit.RoomName(r.URL.Query().Get("room"))
+	if err := EnsureAdminPermission(r.Context(), roomName); err != nil {
+		handleError(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	room := s.roomManager.GetRoom(r.Context(), roomName)
+	if room == nil {
+		handleError(w, http.StatusNotFound, ErrRoomNotFound, "room", roomName)
+		return
+	}
+
+	res := &speakerStatsResponse{
+		Room:     string(roomName),
+		Speakers: []speakerStat{},
+	}
+	for _, stat := range room.SpeakerStats() {
+		res.Speakers = append(res.Speakers, speakerStat{
+			Identity:        string(stat.Identity),
+			TalkTimeSeconds: stat.TalkTime.Seconds(),
+			Turns:           stat.Turns,
+			Interruptions:   stat.Interruptions,
+			Interrupted:     stat.Interrupted,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(res)
+}
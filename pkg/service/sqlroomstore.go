@@ -0,0 +1,147 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protojson"
+
+	"github.com/livekit/protocol/livekit"
+)
+
+const sqlCreateRoomsTable = `CREATE TABLE IF NOT EXISTS livekit_rooms (
+	name       TEXT PRIMARY KEY,
+	room       TEXT NOT NULL,
+	internal   TEXT NOT NULL DEFAULT '',
+	template   TEXT NOT NULL DEFAULT '',
+	regions    TEXT NOT NULL DEFAULT '',
+	seal       TEXT NOT NULL DEFAULT '',
+	synced_at  BIGINT NOT NULL
+)`
+
+const sqlUpsertRoom = `INSERT INTO livekit_rooms (name, room, internal, template, regions, seal, synced_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)
+	ON CONFLICT (name) DO UPDATE SET
+		room = EXCLUDED.room,
+		internal = EXCLUDED.internal,
+		template = EXCLUDED.template,
+		regions = EXCLUDED.regions,
+		seal = EXCLUDED.seal,
+		synced_at = EXCLUDED.synced_at`
+
+// SQLRoomStore persists rooms and their settings in a single table. Statements use the Postgres dialect,
+// which is also understood by SQLite and CockroachDB
+type SQLRoomStore struct {
+	db *sql.DB
+}
+
+func NewSQLRoomStore(db *sql.DB) (*SQLRoomStore, error) {
+	if _, err := db.Exec(sqlCreateRoomsTable); err != nil {
+		return nil, err
+	}
+	return &SQLRoomStore{db: db}, nil
+}
+
+func (s *SQLRoomStore) SaveRooms(ctx context.Context, rooms []*PersistedRoom) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	syncedAt := time.Now().UnixNano()
+	for _, pr := range rooms {
+		room, err := protojson.Marshal(pr.Room)
+		if err != nil {
+			return err
+		}
+		var internal, regions, seal []byte
+		if pr.Internal != nil {
+			if internal, err = protojson.Marshal(pr.Internal); err != nil {
+				return err
+			}
+		}
+		if len(pr.Regions) != 0 {
+			if regions, err = json.Marshal(pr.Regions); err != nil {
+				return err
+			}
+		}
+		if pr.Seal != nil {
+			if seal, err = json.Marshal(pr.Seal); err != nil {
+				return err
+			}
+		}
+		if _, err = tx.ExecContext(ctx, sqlUpsertRoom,
+			pr.Room.Name, string(room), string(internal), pr.Template, string(regions), string(seal), syncedAt,
+		); err != nil {
+			return err
+		}
+	}
+
+	// rooms not synced this time are gone
+	if _, err = tx.ExecContext(ctx, "DELETE FROM livekit_rooms WHERE synced_at <> $1", syncedAt); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLRoomStore) LoadRooms(ctx context.Context) ([]*PersistedRoom, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT room, internal, template, regions, seal FROM livekit_rooms")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rooms []*PersistedRoom
+	for rows.Next() {
+		var room, internal, regions, seal string
+		pr := &PersistedRoom{Room: &livekit.Room{}}
+		if err = rows.Scan(&room, &internal, &pr.Template, &regions, &seal); err != nil {
+			return nil, err
+		}
+		if err = protojson.Unmarshal([]byte(room), pr.Room); err != nil {
+			return nil, err
+		}
+		if internal != "" {
+			pr.Internal = &livekit.RoomInternal{}
+			if err = protojson.Unmarshal([]byte(internal), pr.Internal); err != nil {
+				return nil, err
+			}
+		}
+		if regions != "" {
+			if err = json.Unmarshal([]byte(regions), &pr.Regions); err != nil {
+				return nil, err
+			}
+		}
+		if seal != "" {
+			pr.Seal = &RoomSeal{}
+			if err = json.Unmarshal([]byte(seal), pr.Seal); err != nil {
+				return nil, err
+			}
+		}
+		rooms = append(rooms, pr)
+	}
+	return rooms, rows.Err()
+}
+
+func (s *SQLRoomStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,124 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"google.golang.org/protobuf/encoding/protojson"
+
+	"github.com/livekit/protocol/livekit"
+)
+
+const subscriptionPermissionsPath = "/rooms/subscription_permissions"
+
+type subscriptionPermissionsRequest struct {
+	Room     string `json:"room"`
+	Identity string `json:"identity"`
+	// SubscriptionPermission in its protobuf JSON form, lifts the restrictions when omitted
+	Permission json.RawMessage `json:"permission,omitempty"`
+}
+
+type subscriptionPermissionsResponse struct {
+	Room     string `json:"room"`
+	Identity string `json:"identity"`
+	// permissions set by the participant itself
+	ParticipantPermission json.RawMessage `json:"participant_permission,omitempty"`
+	// restrictions set through this service, a subscriber has to be allowed by both
+	AdminPermission json.RawMessage `json:"admin_permission,omitempty"`
+}
+
+// SubscriptionPermissionsService lets admins restrict which participants may subscribe to the tracks of a publisher,
+// on top of what the publisher allows. Existing subscriptions that are no longer allowed are revoked.
+// Only rooms hosted on the node handling the request can be changed.
+type SubscriptionPermissionsService struct {
+	roomManager *RoomManager
+}
+
+func NewSubscriptionPermissionsService(roomManager *RoomManager) *SubscriptionPermissionsService {
+	return &SubscriptionPermissionsService{
+		roomManager: roomManager,
+	}
+}
+
+func (s *SubscriptionPermissionsService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req subscriptionPermissionsRequest
+	switch r.Method {
+	case http.MethodGet:
+		req.Room = r.URL.Query().Get("room")
+		req.Identity = r.URL.Query().Get("identity")
+	case http.MethodPost:
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			handleError(w, http.StatusBadRequest, err)
+			return
+		}
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := EnsureAdminPermission(r.Context(), livekit.RoomName(req.Room)); err != nil {
+		handleError(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	room := s.roomManager.GetRoom(r.Context(), livekit.RoomName(req.Room))
+	if room == nil {
+		handleError(w, http.StatusNotFound, ErrRoomNotFound, "room", req.Room)
+		return
+	}
+
+	participant := room.GetParticipant(livekit.ParticipantIdentity(req.Identity))
+	if participant == nil {
+		handleError(w, http.StatusNotFound, ErrParticipantNotFound, "room", req.Room, "participant", req.Identity)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		var permission *livekit.SubscriptionPermission
+		if len(req.Permission) != 0 && string(req.Permission) != "null" {
+			permission = &livekit.SubscriptionPermission{}
+			if err := protojson.Unmarshal(req.Permission, permission); err != nil {
+				handleError(w, http.StatusBadRequest, err, "room", req.Room, "participant", req.Identity)
+				return
+			}
+		}
+		if err := room.UpdateAdminSubscriptionPermission(participant, permission); err != nil {
+			handleError(w, http.StatusBadRequest, err, "room", req.Room, "participant", req.Identity)
+			return
+		}
+	}
+
+	participantPermission, _ := participant.SubscriptionPermission()
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(&subscriptionPermissionsResponse{
+		Room:                  req.Room,
+		Identity:              req.Identity,
+		ParticipantPermission: marshalSubscriptionPermission(participantPermission),
+		AdminPermission:       marshalSubscriptionPermission(participant.AdminSubscriptionPermission()),
+	})
+}
+
+func marshalSubscriptionPermission(permission *livekit.SubscriptionPermission) json.RawMessage {
+	if permission == nil {
+		return nil
+	}
+	data, err := protojson.Marshal(permission)
+	if err != nil {
+		return nil
+	}
+	return data
+}
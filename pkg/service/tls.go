@@ -0,0 +1,221 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+	"golang.org/x/crypto/ocsp"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/protocol/logger"
+)
+
+const (
+	certReloadCheckInterval = time.Minute
+	ocspFetchTimeout        = 10 * time.Second
+	ocspRetryInterval       = 10 * time.Minute
+)
+
+// newTLSConfig creates the TLS config for the main listener. When ACME with HTTP-01 is configured,
+// the returned handler must be served on the challenge port.
+func newTLSConfig(conf *config.TLSConfig) (*tls.Config, http.Handler, error) {
+	if conf.ACME.Enabled {
+		m := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(conf.ACME.Domains...),
+			Cache:      autocert.DirCache(conf.ACME.CacheDir),
+			Email:      conf.ACME.Email,
+		}
+		if conf.ACME.DirectoryURL != "" {
+			m.Client = &acme.Client{DirectoryURL: conf.ACME.DirectoryURL}
+		}
+
+		tlsConfig := m.TLSConfig()
+		tlsConfig.MinVersion = tls.VersionTLS12
+		// h2 is not offered since WebSocket upgrades require HTTP/1.1,
+		// acme-tls/1 is kept to answer TLS-ALPN-01 challenges
+		tlsConfig.NextProtos = []string{"http/1.1", acme.ALPNProto}
+
+		var challengeHandler http.Handler
+		if conf.ACME.HTTPChallengePort != 0 {
+			challengeHandler = m.HTTPHandler(nil)
+		}
+		return tlsConfig, challengeHandler, nil
+	}
+
+	reloader, err := newCertReloader(conf.CertFile, conf.KeyFile, conf.OCSPStapling)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+		NextProtos:     []string{"http/1.1"},
+	}, nil, nil
+}
+
+// certReloader serves a certificate loaded from disk, picking up renewed files without a restart
+type certReloader struct {
+	certFile     string
+	keyFile      string
+	ocspStapling bool
+
+	lock         sync.Mutex
+	cert         *tls.Certificate
+	modTime      time.Time
+	lastChecked  time.Time
+	ocspRefresh  time.Time
+	ocspFetching bool
+}
+
+func newCertReloader(certFile, keyFile string, ocspStapling bool) (*certReloader, error) {
+	r := &certReloader{
+		certFile:     certFile,
+		keyFile:      keyFile,
+		ocspStapling: ocspStapling,
+	}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *certReloader) GetCertificate(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if time.Since(r.lastChecked) > certReloadCheckInterval {
+		r.lastChecked = time.Now()
+		if st, err := os.Stat(r.certFile); err == nil && st.ModTime().After(r.modTime) {
+			if err := r.loadLocked(); err != nil {
+				logger.Errorw("could not reload TLS certificate", err, "certFile", r.certFile)
+			} else {
+				logger.Infow("reloaded TLS certificate", "certFile", r.certFile)
+			}
+		}
+	}
+
+	if r.ocspStapling && !r.ocspFetching && time.Now().After(r.ocspRefresh) {
+		// never block handshakes on the OCSP responder
+		r.ocspFetching = true
+		go r.stapleOCSP(r.cert)
+	}
+	return r.cert, nil
+}
+
+func (r *certReloader) load() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.loadLocked()
+}
+
+func (r *certReloader) loadLocked() error {
+	st, err := os.Stat(r.certFile)
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+	if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+		return err
+	}
+
+	r.cert = &cert
+	r.modTime = st.ModTime()
+	r.lastChecked = time.Now()
+	// staple on next handshake
+	r.ocspRefresh = time.Time{}
+	return nil
+}
+
+func (r *certReloader) stapleOCSP(cert *tls.Certificate) {
+	staple, nextUpdate, err := fetchOCSPStaple(cert)
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.ocspFetching = false
+	if err != nil {
+		logger.Warnw("could not fetch OCSP response", err, "certFile", r.certFile)
+		r.ocspRefresh = time.Now().Add(ocspRetryInterval)
+		return
+	}
+	if r.cert.Leaf != cert.Leaf {
+		// certificate was reloaded while fetching, the new one will be stapled on the next handshake
+		return
+	}
+
+	// certificates are shared with in-flight handshakes, replace instead of mutating
+	stapled := *r.cert
+	stapled.OCSPStaple = staple
+	r.cert = &stapled
+
+	// refresh halfway to expiry of the response
+	r.ocspRefresh = time.Now().Add(time.Until(nextUpdate) / 2)
+	if time.Until(r.ocspRefresh) < ocspRetryInterval {
+		r.ocspRefresh = time.Now().Add(ocspRetryInterval)
+	}
+}
+
+func fetchOCSPStaple(cert *tls.Certificate) ([]byte, time.Time, error) {
+	if len(cert.Leaf.OCSPServer) == 0 {
+		return nil, time.Time{}, errors.New("certificate does not specify an OCSP server")
+	}
+	if len(cert.Certificate) < 2 {
+		return nil, time.Time{}, errors.New("certificate chain does not include the issuer")
+	}
+	issuer, err := x509.ParseCertificate(cert.Certificate[1])
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	req, err := ocsp.CreateRequest(cert.Leaf, issuer, nil)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	client := &http.Client{Timeout: ocspFetchTimeout}
+	resp, err := client.Post(cert.Leaf.OCSPServer[0], "application/ocsp-request", bytes.NewReader(req))
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	parsed, err := ocsp.ParseResponseForCert(body, cert.Leaf, issuer)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	if parsed.Status != ocsp.Good {
+		return nil, time.Time{}, fmt.Errorf("unexpected OCSP status %d", parsed.Status)
+	}
+	return body, parsed.NextUpdate, nil
+}
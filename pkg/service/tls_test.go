@@ -0,0 +1,296 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/ocsp"
+
+	"github.com/livekit/livekit-server/pkg/config"
+)
+
+type testCA struct {
+	key    *ecdsa.PrivateKey
+	cert   *x509.Certificate
+	serial atomic.Int64
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	ca := &testCA{key: key, cert: cert}
+	ca.serial.Store(1)
+	return ca
+}
+
+// issue signs a leaf certificate for pub, serial numbers are unique per CA
+func (ca *testCA) issue(t *testing.T, pub crypto.PublicKey, domain string, lifetime time.Duration, ocspServer string) *x509.Certificate {
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(ca.serial.Add(1)),
+		Subject:      pkix.Name{CommonName: domain},
+		DNSNames:     []string{domain},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(lifetime),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	if ocspServer != "" {
+		template.OCSPServer = []string{ocspServer}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, pub, ca.key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert
+}
+
+// writeCertFiles issues a certificate and writes the chain and key as PEM files
+func (ca *testCA) writeCertFiles(t *testing.T, certFile, keyFile string, ocspServer string) *x509.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	leaf := ca.issue(t, key.Public(), "livekit.example.com", time.Hour, ocspServer)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	chain := append(
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leaf.Raw}),
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw})...,
+	)
+	require.NoError(t, os.WriteFile(certFile, chain, 0600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+	return leaf
+}
+
+// ocspResponder answers OCSP requests for certificates issued by ca with the given status
+func (ca *testCA) ocspResponder(t *testing.T, status int) *httptest.Server {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		req, err := ocsp.ParseRequest(body)
+		require.NoError(t, err)
+
+		now := time.Now()
+		resp, err := ocsp.CreateResponse(ca.cert, ca.cert, ocsp.Response{
+			Status:       status,
+			SerialNumber: req.SerialNumber,
+			ThisUpdate:   now.Add(-time.Minute),
+			NextUpdate:   now.Add(4 * time.Hour),
+			RevokedAt:    now.Add(-time.Minute),
+		}, ca.key)
+		require.NoError(t, err)
+		w.Header().Set("Content-Type", "application/ocsp-response")
+		_, _ = w.Write(resp)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestCertReloader(t *testing.T) {
+	ca := newTestCA(t)
+
+	setup := func(t *testing.T) (*certReloader, *x509.Certificate, string, string) {
+		dir := t.TempDir()
+		certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+		leaf := ca.writeCertFiles(t, certFile, keyFile, "")
+		r, err := newCertReloader(certFile, keyFile, false)
+		require.NoError(t, err)
+		return r, leaf, certFile, keyFile
+	}
+
+	expireCheck := func(r *certReloader, certFile string) {
+		// renewed files are only picked up once the check interval has passed
+		future := time.Now().Add(time.Minute)
+		_ = os.Chtimes(certFile, future, future)
+		r.lock.Lock()
+		r.lastChecked = time.Time{}
+		r.lock.Unlock()
+	}
+
+	t.Run("serves renewed certificate", func(t *testing.T) {
+		r, leaf, certFile, keyFile := setup(t)
+		cert, err := r.GetCertificate(nil)
+		require.NoError(t, err)
+		require.Equal(t, leaf.SerialNumber, cert.Leaf.SerialNumber)
+		require.Len(t, cert.Certificate, 2)
+
+		renewed := ca.writeCertFiles(t, certFile, keyFile, "")
+		future := time.Now().Add(time.Minute)
+		require.NoError(t, os.Chtimes(certFile, future, future))
+		cert, err = r.GetCertificate(nil)
+		require.NoError(t, err)
+		require.Equal(t, leaf.SerialNumber, cert.Leaf.SerialNumber, "checked at most once per interval")
+
+		expireCheck(r, certFile)
+		cert, err = r.GetCertificate(nil)
+		require.NoError(t, err)
+		require.Equal(t, renewed.SerialNumber, cert.Leaf.SerialNumber)
+	})
+
+	t.Run("keeps serving previous certificate when renewed files are invalid", func(t *testing.T) {
+		r, leaf, certFile, _ := setup(t)
+		require.NoError(t, os.WriteFile(certFile, []byte("not a certificate"), 0600))
+		expireCheck(r, certFile)
+
+		cert, err := r.GetCertificate(nil)
+		require.NoError(t, err)
+		require.Equal(t, leaf.SerialNumber, cert.Leaf.SerialNumber)
+	})
+
+	t.Run("fails without certificate", func(t *testing.T) {
+		dir := t.TempDir()
+		_, err := newCertReloader(filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem"), false)
+		require.Error(t, err)
+	})
+}
+
+func TestOCSPStapling(t *testing.T) {
+	ca := newTestCA(t)
+
+	setup := func(t *testing.T, ocspServer string) *certReloader {
+		dir := t.TempDir()
+		certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+		ca.writeCertFiles(t, certFile, keyFile, ocspServer)
+		r, err := newCertReloader(certFile, keyFile, true)
+		require.NoError(t, err)
+		return r
+	}
+
+	t.Run("staples good response", func(t *testing.T) {
+		r := setup(t, ca.ocspResponder(t, ocsp.Good).URL)
+
+		// the first handshake is not blocked on the responder
+		first, err := r.GetCertificate(nil)
+		require.NoError(t, err)
+		require.Nil(t, first.OCSPStaple)
+
+		require.Eventually(t, func() bool {
+			cert, _ := r.GetCertificate(nil)
+			return cert.OCSPStaple != nil
+		}, 5*time.Second, 10*time.Millisecond)
+		require.Nil(t, first.OCSPStaple, "certificates handed out are not mutated")
+
+		r.lock.Lock()
+		refresh := time.Until(r.ocspRefresh)
+		r.lock.Unlock()
+		require.InDelta(t, 2*time.Hour, refresh, float64(time.Minute))
+	})
+
+	t.Run("retries after failure", func(t *testing.T) {
+		r := setup(t, ca.ocspResponder(t, ocsp.Revoked).URL)
+		r.lock.Lock()
+		cert := r.cert
+		r.ocspFetching = true
+		r.lock.Unlock()
+
+		r.stapleOCSP(cert)
+		r.lock.Lock()
+		defer r.lock.Unlock()
+		require.Nil(t, r.cert.OCSPStaple)
+		require.False(t, r.ocspFetching)
+		require.InDelta(t, ocspRetryInterval, time.Until(r.ocspRefresh), float64(time.Minute))
+	})
+
+	t.Run("requires responder and issuer", func(t *testing.T) {
+		r := setup(t, "")
+		_, _, err := fetchOCSPStaple(r.cert)
+		require.Error(t, err)
+
+		r = setup(t, "http://127.0.0.1:1")
+		leafOnly := *r.cert
+		leafOnly.Certificate = leafOnly.Certificate[:1]
+		_, _, err = fetchOCSPStaple(&leafOnly)
+		require.Error(t, err)
+	})
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	t.Run("certificate files", func(t *testing.T) {
+		dir := t.TempDir()
+		conf := &config.TLSConfig{
+			CertFile: filepath.Join(dir, "cert.pem"),
+			KeyFile:  filepath.Join(dir, "key.pem"),
+		}
+		_, _, err := newTLSConfig(conf)
+		require.Error(t, err)
+
+		leaf := newTestCA(t).writeCertFiles(t, conf.CertFile, conf.KeyFile, "")
+		tlsConfig, handler, err := newTLSConfig(conf)
+		require.NoError(t, err)
+		require.Nil(t, handler)
+		require.Equal(t, uint16(tls.VersionTLS12), tlsConfig.MinVersion)
+		require.Equal(t, []string{"http/1.1"}, tlsConfig.NextProtos)
+
+		cert, err := tlsConfig.GetCertificate(&tls.ClientHelloInfo{ServerName: "livekit.example.com"})
+		require.NoError(t, err)
+		require.Equal(t, leaf.SerialNumber, cert.Leaf.SerialNumber)
+	})
+
+	t.Run("acme", func(t *testing.T) {
+		conf := &config.TLSConfig{
+			ACME: config.ACMEConfig{
+				Enabled:  true,
+				Domains:  []string{"livekit.example.com"},
+				CacheDir: t.TempDir(),
+			},
+		}
+		tlsConfig, handler, err := newTLSConfig(conf)
+		require.NoError(t, err)
+		require.Nil(t, handler)
+		require.Equal(t, uint16(tls.VersionTLS12), tlsConfig.MinVersion)
+		require.Equal(t, []string{"http/1.1", acme.ALPNProto}, tlsConfig.NextProtos)
+
+		// only whitelisted domains are served
+		_, err = tlsConfig.GetCertificate(&tls.ClientHelloInfo{ServerName: "other.example.com"})
+		require.Error(t, err)
+
+		conf.ACME.HTTPChallengePort = 80
+		_, handler, err = newTLSConfig(conf)
+		require.NoError(t, err)
+		require.NotNil(t, handler)
+	})
+}
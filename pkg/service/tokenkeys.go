@@ -0,0 +1,284 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-jose/go-jose/v3"
+
+	"github.com/livekit/protocol/logger"
+
+	"github.com/livekit/livekit-server/pkg/config"
+)
+
+const jwksFetchTimeout = 10 * time.Second
+
+var (
+	ErrUnknownTokenKey       = errors.New("no public key found for token")
+	ErrTokenIssuerNotAllowed = errors.New("token issuer is not allowed")
+)
+
+// isAsymmetricAlgorithm reports whether tokens signed with alg are verified with public keys instead of API secrets
+func isAsymmetricAlgorithm(alg string) bool {
+	switch jose.SignatureAlgorithm(alg) {
+	case jose.RS256, jose.RS384, jose.RS512, jose.ES256, jose.ES384, jose.ES512:
+		return true
+	default:
+		return false
+	}
+}
+
+// TokenKeys holds the public keys of identity providers that sign access tokens with RS256 or ES256, loaded from
+// PEM files and a JSON Web Key Set that is refreshed periodically
+type TokenKeys struct {
+	conf    *config.TokenKeysConfig
+	client  *http.Client
+	issuers map[string]bool
+	pemKeys map[string]interface{}
+
+	lock sync.RWMutex
+	jwks jose.JSONWebKeySet
+
+	// serializes fetches of the key set
+	fetchLock   sync.Mutex
+	lastFetched time.Time
+
+	doneChan chan struct{}
+}
+
+// NewTokenKeys returns nil when token keys are not configured
+func NewTokenKeys(conf *config.TokenKeysConfig) (*TokenKeys, error) {
+	if !conf.Enabled() {
+		return nil, nil
+	}
+
+	k := &TokenKeys{
+		conf:     conf,
+		client:   &http.Client{Timeout: jwksFetchTimeout},
+		issuers:  make(map[string]bool),
+		pemKeys:  make(map[string]interface{}),
+		doneChan: make(chan struct{}),
+	}
+	for _, issuer := range conf.Issuers {
+		k.issuers[issuer] = true
+	}
+	for issuer, file := range conf.PEMFiles {
+		key, err := loadPublicKeyPEM(file)
+		if err != nil {
+			return nil, fmt.Errorf("could not load token key of issuer %s: %w", issuer, err)
+		}
+		k.pemKeys[issuer] = key
+	}
+	return k, nil
+}
+
+func (k *TokenKeys) Start() {
+	if k == nil || k.conf.JWKSURL == "" {
+		return
+	}
+	if err := k.refresh(true); err != nil {
+		// tokens are verified once the key set can be fetched
+		logger.Warnw("could not fetch token key set", err, "url", k.conf.JWKSURL)
+	}
+	go k.worker()
+}
+
+func (k *TokenKeys) Stop() {
+	if k == nil || k.conf.JWKSURL == "" {
+		return
+	}
+	close(k.doneChan)
+}
+
+// GetKey returns the public key verifying a token signed with alg by issuer. Keys of the key set are selected by
+// kid, or used when they are the only candidate of tokens without kid
+func (k *TokenKeys) GetKey(alg string, kid string, issuer string) (interface{}, error) {
+	if k == nil {
+		return nil, ErrUnknownTokenKey
+	}
+	if key, ok := k.pemKeys[issuer]; ok {
+		return key, nil
+	}
+	if k.conf.JWKSURL == "" {
+		return nil, ErrUnknownTokenKey
+	}
+	if len(k.issuers) != 0 && !k.issuers[issuer] {
+		return nil, ErrTokenIssuerNotAllowed
+	}
+
+	if key := k.jwksKey(alg, kid); key != nil {
+		return key, nil
+	}
+	// the identity provider may have rotated its keys
+	if err := k.refresh(false); err != nil {
+		return nil, err
+	}
+	if key := k.jwksKey(alg, kid); key != nil {
+		return key, nil
+	}
+	return nil, ErrUnknownTokenKey
+}
+
+func (k *TokenKeys) jwksKey(alg string, kid string) interface{} {
+	k.lock.RLock()
+	defer k.lock.RUnlock()
+
+	candidates := k.jwks.Keys
+	if kid != "" {
+		candidates = k.jwks.Key(kid)
+	}
+
+	var found interface{}
+	for _, key := range candidates {
+		if key.Use != "" && key.Use != "sig" {
+			continue
+		}
+		if key.Algorithm != "" && key.Algorithm != alg {
+			continue
+		}
+		public := key.Public()
+		if !public.Valid() || !keyMatchesAlgorithm(public.Key, alg) {
+			continue
+		}
+		if found != nil {
+			// ambiguous without kid
+			return nil
+		}
+		found = public.Key
+	}
+	return found
+}
+
+func (k *TokenKeys) worker() {
+	ticker := time.NewTicker(k.conf.JWKSRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-k.doneChan:
+			return
+		case <-ticker.C:
+			if err := k.refresh(true); err != nil {
+				logger.Warnw("could not refresh token key set", err, "url", k.conf.JWKSURL)
+			}
+		}
+	}
+}
+
+// refresh fetches the key set, unless it was fetched within the min refresh interval and force is not set
+func (k *TokenKeys) refresh(force bool) error {
+	k.fetchLock.Lock()
+	defer k.fetchLock.Unlock()
+
+	if !force && time.Since(k.lastFetched) < k.conf.JWKSMinRefreshInterval {
+		return nil
+	}
+	k.lastFetched = time.Now()
+
+	jwks, err := k.fetch()
+	if err != nil {
+		return err
+	}
+	k.lock.Lock()
+	k.jwks = *jwks
+	k.lock.Unlock()
+	logger.Debugw("fetched token key set", "url", k.conf.JWKSURL, "keys", len(jwks.Keys))
+	return nil
+}
+
+func (k *TokenKeys) fetch() (*jose.JSONWebKeySet, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), jwksFetchTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, k.conf.JWKSURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	res, err := k.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status fetching token key set: %d", res.StatusCode)
+	}
+
+	jwks := &jose.JSONWebKeySet{}
+	if err = json.NewDecoder(res.Body).Decode(jwks); err != nil {
+		return nil, err
+	}
+	return jwks, nil
+}
+
+func keyMatchesAlgorithm(key interface{}, alg string) bool {
+	switch key.(type) {
+	case *rsa.PublicKey:
+		return strings.HasPrefix(alg, "RS")
+	case *ecdsa.PublicKey:
+		return strings.HasPrefix(alg, "ES")
+	default:
+		return false
+	}
+}
+
+// loadPublicKeyPEM reads an RSA or EC public key, or the key of a certificate, from a PEM file
+func loadPublicKeyPEM(file string) (interface{}, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+
+	var key interface{}
+	switch block.Type {
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		key = cert.PublicKey
+	case "RSA PUBLIC KEY":
+		key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch key.(type) {
+	case *rsa.PublicKey, *ecdsa.PublicKey:
+		return key, nil
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T", key)
+	}
+}
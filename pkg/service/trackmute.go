@@ -0,0 +1,125 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/livekit/protocol/livekit"
+)
+
+const trackMutePath = "/rooms/track_mute"
+
+type trackMuteRequest struct {
+	Room     string `json:"room"`
+	Identity string `json:"identity"`
+	// set with Muted to force the mute state of a single track
+	TrackSid string `json:"track_sid,omitempty"`
+	Muted    *bool  `json:"muted,omitempty"`
+}
+
+type trackMuteState struct {
+	TrackSid      string    `json:"track_sid"`
+	Kind          string    `json:"kind"`
+	Muted         bool      `json:"muted"`
+	MutedByServer bool      `json:"muted_by_server"`
+	LastPacketAt  time.Time `json:"last_packet_at,omitempty"`
+}
+
+type trackMuteResponse struct {
+	Room     string            `json:"room"`
+	Identity string            `json:"identity"`
+	Tracks   []*trackMuteState `json:"tracks"`
+}
+
+// TrackMuteService reports the authoritative mute state of the tracks published by a participant along with
+// when media was last received for them, and lets admins force the mute state of a track. A forced state is
+// sent to the publisher and to everyone in the room, and replaces any correction made by the server.
+// Only rooms hosted on the node handling the request can be queried.
+type TrackMuteService struct {
+	roomManager *RoomManager
+}
+
+func NewTrackMuteService(roomManager *RoomManager) *TrackMuteService {
+	return &TrackMuteService{
+		roomManager: roomManager,
+	}
+}
+
+func (s *TrackMuteService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req trackMuteRequest
+	switch r.Method {
+	case http.MethodGet:
+		req.Room = r.URL.Query().Get("room")
+		req.Identity = r.URL.Query().Get("identity")
+	case http.MethodPost:
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			handleError(w, http.StatusBadRequest, err)
+			return
+		}
+		if req.TrackSid == "" || req.Muted == nil {
+			handleError(w, http.StatusBadRequest, ErrTrackMuteRequired, "room", req.Room, "participant", req.Identity)
+			return
+		}
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := EnsureAdminPermission(r.Context(), livekit.RoomName(req.Room)); err != nil {
+		handleError(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	room := s.roomManager.GetRoom(r.Context(), livekit.RoomName(req.Room))
+	if room == nil {
+		handleError(w, http.StatusNotFound, ErrRoomNotFound, "room", req.Room)
+		return
+	}
+
+	participant := room.GetParticipant(livekit.ParticipantIdentity(req.Identity))
+	if participant == nil {
+		handleError(w, http.StatusNotFound, ErrParticipantNotFound, "room", req.Room, "participant", req.Identity)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		trackID := livekit.TrackID(req.TrackSid)
+		if participant.GetPublishedTrack(trackID) == nil {
+			handleError(w, http.StatusNotFound, ErrTrackNotFound, "room", req.Room, "participant", req.Identity, "track", req.TrackSid)
+			return
+		}
+		participant.SetTrackMuted(trackID, *req.Muted, true)
+	}
+
+	res := &trackMuteResponse{
+		Room:     req.Room,
+		Identity: req.Identity,
+		Tracks:   []*trackMuteState{},
+	}
+	for _, state := range participant.GetTrackMuteStates() {
+		res.Tracks = append(res.Tracks, &trackMuteState{
+			TrackSid:      string(state.TrackID),
+			Kind:          state.Kind.String(),
+			Muted:         state.Muted,
+			MutedByServer: state.MutedByServer,
+			LastPacketAt:  state.LastPacketAt,
+		})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(res)
+}
@@ -0,0 +1,110 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/livekit/livekit-server/pkg/transcription"
+	"github.com/livekit/protocol/livekit"
+)
+
+const transcriptionPath = "/transcription"
+
+type transcriptionRequest struct {
+	Room string `json:"room"`
+}
+
+type listTranscriptionsResponse struct {
+	Transcriptions []*transcription.Info `json:"transcriptions"`
+}
+
+// TranscriptionService starts and stops transcribing rooms hosted on this node
+type TranscriptionService struct {
+	manager     *transcription.Manager
+	roomManager *RoomManager
+}
+
+func NewTranscriptionService(manager *transcription.Manager, roomManager *RoomManager) *TranscriptionService {
+	return &TranscriptionService{
+		manager:     manager,
+		roomManager: roomManager,
+	}
+}
+
+func (s *TranscriptionService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.manager == nil {
+		handleError(w, http.StatusNotFound, ErrTranscriptionDisabled)
+		return
+	}
+	if err := EnsureRecordPermission(r.Context()); err != nil {
+		handleError(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, transcriptionPath), "/")
+	switch {
+	case r.Method == http.MethodGet && path == "":
+		s.writeJSON(w, &listTranscriptionsResponse{Transcriptions: s.manager.List()})
+	case r.Method == http.MethodPost && (path == "start" || path == "stop"):
+		s.control(w, r, path)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (s *TranscriptionService) control(w http.ResponseWriter, r *http.Request, action string) {
+	var req transcriptionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		handleError(w, http.StatusBadRequest, err)
+		return
+	}
+	if req.Room == "" {
+		handleError(w, http.StatusBadRequest, ErrRoomNameRequired)
+		return
+	}
+
+	var info *transcription.Info
+	var err error
+	if action == "start" {
+		room := s.roomManager.GetRoom(r.Context(), livekit.RoomName(req.Room))
+		if room == nil {
+			handleError(w, http.StatusNotFound, ErrRoomNotFound, "room", req.Room)
+			return
+		}
+		info, err = s.manager.Start(room)
+	} else {
+		info, err = s.manager.Stop(livekit.RoomName(req.Room))
+	}
+
+	switch {
+	case errors.Is(err, transcription.ErrNotTranscribing):
+		handleError(w, http.StatusNotFound, err, "room", req.Room)
+	case errors.Is(err, transcription.ErrAlreadyTranscribing):
+		handleError(w, http.StatusConflict, err, "room", req.Room)
+	case err != nil:
+		handleError(w, http.StatusInternalServerError, err, "room", req.Room)
+	default:
+		s.writeJSON(w, info)
+	}
+}
+
+func (s *TranscriptionService) writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -19,6 +19,7 @@ import (
 	"crypto/tls"
 	"net"
 	"strconv"
+	"strings"
 
 	"github.com/pion/turn/v2"
 	"github.com/pkg/errors"
@@ -26,6 +27,7 @@ import (
 	"github.com/livekit/protocol/livekit"
 	"github.com/livekit/protocol/logger"
 	"github.com/livekit/protocol/logger/pionlogger"
+	"github.com/livekit/protocol/utils"
 
 	"github.com/livekit/livekit-server/pkg/config"
 	"github.com/livekit/livekit-server/pkg/telemetry"
@@ -38,9 +40,11 @@ const (
 	allocateRetries = 50
 	turnMinPort     = 1024
 	turnMaxPort     = 30000
+
+	turnUsernameSeparator = "/"
 )
 
-func NewTurnServer(conf *config.Config, authHandler turn.AuthHandler, standalone bool) (*turn.Server, error) {
+func NewTurnServer(conf *config.Config, authHandler turn.AuthHandler, allocations *TurnAllocations, standalone bool) (*turn.Server, error) {
 	turnConf := conf.TURN
 	if !turnConf.Enabled {
 		return nil, nil
@@ -69,6 +73,8 @@ func NewTurnServer(conf *config.Config, authHandler turn.AuthHandler, standalone
 
 	logValues = append(logValues, "turn.relay_range_start", turnConf.RelayPortRangeStart)
 	logValues = append(logValues, "turn.relay_range_end", turnConf.RelayPortRangeEnd)
+	logValues = append(logValues, "turn.max_allocations_per_user", turnConf.MaxAllocationsPerUser)
+	logValues = append(logValues, "turn.max_allocation_bitrate", turnConf.MaxAllocationBitrate)
 
 	if turnConf.TLSPort > 0 {
 		if turnConf.Domain == "" {
@@ -80,15 +86,26 @@ func NewTurnServer(conf *config.Config, authHandler turn.AuthHandler, standalone
 		}
 
 		if !turnConf.ExternalTLS {
-			cert, err := tls.LoadX509KeyPair(turnConf.CertFile, turnConf.KeyFile)
-			if err != nil {
-				return nil, errors.Wrap(err, "TURN tls cert required")
+			// certificates are resolved per handshake so renewals apply without restarting the listener
+			var getCertificate func(*tls.ClientHelloInfo) (*tls.Certificate, error)
+			if turnConf.ACME.Enabled {
+				m, err := newDNSCertManager(&turnConf.ACME, turnConf.Domain)
+				if err != nil {
+					return nil, errors.Wrap(err, "could not obtain TURN tls cert")
+				}
+				getCertificate = m.GetCertificate
+			} else {
+				reloader, err := newCertReloader(turnConf.CertFile, turnConf.KeyFile, false)
+				if err != nil {
+					return nil, errors.Wrap(err, "TURN tls cert required")
+				}
+				getCertificate = reloader.GetCertificate
 			}
 
 			tlsListener, err := tls.Listen("tcp4", "0.0.0.0:"+strconv.Itoa(turnConf.TLSPort),
 				&tls.Config{
-					MinVersion:   tls.VersionTLS12,
-					Certificates: []tls.Certificate{cert},
+					MinVersion:     tls.VersionTLS12,
+					GetCertificate: getCertificate,
 				})
 			if err != nil {
 				return nil, errors.Wrap(err, "could not listen on TURN TCP port")
@@ -98,8 +115,8 @@ func NewTurnServer(conf *config.Config, authHandler turn.AuthHandler, standalone
 			}
 
 			listenerConfig := turn.ListenerConfig{
-				Listener:              tlsListener,
-				RelayAddressGenerator: relayAddrGen,
+				Listener:              allocations.listener(tlsListener),
+				RelayAddressGenerator: allocations.relayAddressGenerator(relayAddrGen, "tls"),
 			}
 			serverConfig.ListenerConfigs = append(serverConfig.ListenerConfigs, listenerConfig)
 		} else {
@@ -112,12 +129,12 @@ func NewTurnServer(conf *config.Config, authHandler turn.AuthHandler, standalone
 			}
 
 			listenerConfig := turn.ListenerConfig{
-				Listener:              tcpListener,
-				RelayAddressGenerator: relayAddrGen,
+				Listener:              allocations.listener(tcpListener),
+				RelayAddressGenerator: allocations.relayAddressGenerator(relayAddrGen, "tls"),
 			}
 			serverConfig.ListenerConfigs = append(serverConfig.ListenerConfigs, listenerConfig)
 		}
-		logValues = append(logValues, "turn.portTLS", turnConf.TLSPort, "turn.externalTLS", turnConf.ExternalTLS)
+		logValues = append(logValues, "turn.portTLS", turnConf.TLSPort, "turn.externalTLS", turnConf.ExternalTLS, "turn.acme", turnConf.ACME.Enabled)
 	}
 
 	if turnConf.UDPPort > 0 {
@@ -131,8 +148,8 @@ func NewTurnServer(conf *config.Config, authHandler turn.AuthHandler, standalone
 		}
 
 		packetConfig := turn.PacketConnConfig{
-			PacketConn:            udpListener,
-			RelayAddressGenerator: relayAddrGen,
+			PacketConn:            allocations.packetConn(udpListener),
+			RelayAddressGenerator: allocations.relayAddressGenerator(relayAddrGen, "udp"),
 		}
 		serverConfig.PacketConnConfigs = append(serverConfig.PacketConnConfigs, packetConfig)
 		logValues = append(logValues, "turn.portUDP", turnConf.UDPPort)
@@ -142,14 +159,40 @@ func NewTurnServer(conf *config.Config, authHandler turn.AuthHandler, standalone
 	return turn.NewServer(serverConfig)
 }
 
-func newTurnAuthHandler(roomStore ObjectStore) turn.AuthHandler {
+func newTurnAuthHandler(conf *config.Config, roomStore ObjectStore, allocations *TurnAllocations) turn.AuthHandler {
+	if conf.Edge.IsEdge() {
+		// edges host no rooms, they sign the credentials of their own TURN server
+		return newEdgeTurnAuthHandler(&conf.Edge, allocations)
+	}
 	return func(username, realm string, srcAddr net.Addr) (key []byte, ok bool) {
-		// room id should be the username, create a hashed room id
-		rm, _, err := roomStore.LoadRoom(context.Background(), livekit.RoomName(username), false)
+		rm, _, err := roomStore.LoadRoom(context.Background(), turnUsernameRoom(username), false)
 		if err != nil {
+			prometheus.RecordTurnAllocationFailure(turnFailureAuth)
+			return nil, false
+		}
+
+		if !allocations.authorize(username, srcAddr) {
+			logger.Infow("TURN allocation quota reached", "username", username, "clientAddr", srcAddr)
+			prometheus.RecordTurnAllocationFailure(turnFailureQuota)
 			return nil, false
 		}
 
 		return turn.GenerateAuthKey(username, LivekitRealm, rm.TurnPassword), true
 	}
 }
+
+// turnUsername gives each participant a TURN username of its own, so that allocations can be capped per participant.
+// All participants of a room share the room's TURN password.
+func turnUsername(roomName livekit.RoomName, participantID livekit.ParticipantID) string {
+	return string(roomName) + turnUsernameSeparator + string(participantID)
+}
+
+// turnUsernameRoom returns the room of a TURN username, which is the room name itself for credentials handed out
+// before usernames were per participant
+func turnUsernameRoom(username string) livekit.RoomName {
+	if i := strings.LastIndex(username, turnUsernameSeparator); i >= 0 &&
+		strings.HasPrefix(username[i+len(turnUsernameSeparator):], utils.ParticipantPrefix) {
+		return livekit.RoomName(username[:i])
+	}
+	return livekit.RoomName(username)
+}
@@ -0,0 +1,446 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/acme"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/protocol/logger"
+)
+
+const (
+	acmeRenewCheckInterval = 12 * time.Hour
+	acmeRetryInterval      = time.Hour
+	acmeObtainTimeout      = 10 * time.Minute
+	dnsProviderTimeout     = time.Minute
+)
+
+var ErrUnknownDNSProvider = errors.New("unknown DNS provider")
+
+// DNSProvider publishes and removes the TXT records used by the ACME DNS-01 challenge
+type DNSProvider interface {
+	Present(ctx context.Context, fqdn, value string) error
+	CleanUp(ctx context.Context, fqdn, value string) error
+}
+
+type DNSProviderFactory func(conf map[string]string) (DNSProvider, error)
+
+var (
+	dnsProvidersLock sync.RWMutex
+	dnsProviders     = map[string]DNSProviderFactory{
+		"exec":    newExecDNSProvider,
+		"httpreq": newHTTPReqDNSProvider,
+	}
+)
+
+// RegisterDNSProvider makes a DNS provider available to turn.acme.dns_provider
+func RegisterDNSProvider(name string, factory DNSProviderFactory) {
+	dnsProvidersLock.Lock()
+	defer dnsProvidersLock.Unlock()
+	dnsProviders[name] = factory
+}
+
+func newDNSProvider(name string, conf map[string]string) (DNSProvider, error) {
+	dnsProvidersLock.RLock()
+	factory, ok := dnsProviders[name]
+	dnsProvidersLock.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownDNSProvider, name)
+	}
+	return factory(conf)
+}
+
+// ------------------------------------------------
+
+// dnsCertManager obtains a certificate with DNS-01 and renews it in the background.
+// Renewed certificates are served to new handshakes through GetCertificate, so listeners keep running.
+type dnsCertManager struct {
+	conf     config.TURNACMEConfig
+	domain   string
+	provider DNSProvider
+	client   *acme.Client
+
+	lock sync.RWMutex
+	cert *tls.Certificate
+}
+
+func newDNSCertManager(conf *config.TURNACMEConfig, domain string) (*dnsCertManager, error) {
+	provider, err := newDNSProvider(conf.DNSProvider, conf.DNSProviderConfig)
+	if err != nil {
+		return nil, err
+	}
+	if err = os.MkdirAll(conf.CacheDir, 0700); err != nil {
+		return nil, err
+	}
+
+	m := &dnsCertManager{
+		conf:     *conf,
+		domain:   domain,
+		provider: provider,
+	}
+	accountKey, err := m.loadAccountKey()
+	if err != nil {
+		return nil, err
+	}
+	m.client = &acme.Client{
+		Key:          accountKey,
+		DirectoryURL: conf.DirectoryURL,
+	}
+
+	if cert, err := m.loadCert(); err == nil {
+		m.cert = cert
+	} else if !os.IsNotExist(err) {
+		logger.Warnw("could not load cached TURN certificate", err, "domain", domain)
+	}
+
+	if m.needsRenewal() {
+		// TURN/TLS cannot start without a certificate, the first one is obtained synchronously
+		ctx, cancel := context.WithTimeout(context.Background(), acmeObtainTimeout)
+		defer cancel()
+		if err = m.renew(ctx); err != nil && m.cert == nil {
+			return nil, err
+		} else if err != nil {
+			logger.Warnw("could not renew TURN certificate, using cached certificate", err, "domain", domain)
+		}
+	}
+
+	go m.renewWorker()
+	return m, nil
+}
+
+func (m *dnsCertManager) GetCertificate(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	return m.cert, nil
+}
+
+func (m *dnsCertManager) needsRenewal() bool {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	return m.cert == nil || time.Until(m.cert.Leaf.NotAfter) < m.conf.RenewBefore
+}
+
+func (m *dnsCertManager) renewWorker() {
+	wait := acmeRenewCheckInterval
+	for {
+		time.Sleep(wait)
+		wait = acmeRenewCheckInterval
+		if !m.needsRenewal() {
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), acmeObtainTimeout)
+		err := m.renew(ctx)
+		cancel()
+		if err != nil {
+			logger.Errorw("could not renew TURN certificate", err, "domain", m.domain)
+			wait = acmeRetryInterval
+		}
+	}
+}
+
+func (m *dnsCertManager) renew(ctx context.Context) error {
+	cert, err := m.obtain(ctx)
+	if err != nil {
+		return err
+	}
+	if err = m.storeCert(cert); err != nil {
+		logger.Warnw("could not persist TURN certificate", err, "domain", m.domain)
+	}
+
+	m.lock.Lock()
+	m.cert = cert
+	m.lock.Unlock()
+
+	logger.Infow("obtained TURN certificate", "domain", m.domain, "notAfter", cert.Leaf.NotAfter)
+	return nil
+}
+
+func (m *dnsCertManager) obtain(ctx context.Context) (*tls.Certificate, error) {
+	account := &acme.Account{}
+	if m.conf.Email != "" {
+		account.Contact = []string{"mailto:" + m.conf.Email}
+	}
+	if _, err := m.client.Register(ctx, account, acme.AcceptTOS); err != nil && !errors.Is(err, acme.ErrAccountAlreadyExists) {
+		return nil, err
+	}
+
+	order, err := m.client.AuthorizeOrder(ctx, acme.DomainIDs(m.domain))
+	if err != nil {
+		return nil, err
+	}
+	for _, authzURL := range order.AuthzURLs {
+		if err = m.authorize(ctx, authzURL); err != nil {
+			return nil, err
+		}
+	}
+	if order, err = m.client.WaitOrder(ctx, order.URI); err != nil {
+		return nil, err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{DNSNames: []string{m.domain}}, key)
+	if err != nil {
+		return nil, err
+	}
+	der, _, err := m.client.CreateOrderCert(ctx, order.FinalizeURL, csr, true)
+	if err != nil {
+		return nil, err
+	}
+
+	leaf, err := x509.ParseCertificate(der[0])
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Certificate{
+		Certificate: der,
+		PrivateKey:  key,
+		Leaf:        leaf,
+	}, nil
+}
+
+func (m *dnsCertManager) authorize(ctx context.Context, authzURL string) error {
+	authz, err := m.client.GetAuthorization(ctx, authzURL)
+	if err != nil {
+		return err
+	}
+	if authz.Status == acme.StatusValid {
+		return nil
+	}
+
+	var challenge *acme.Challenge
+	for _, c := range authz.Challenges {
+		if c.Type == "dns-01" {
+			challenge = c
+			break
+		}
+	}
+	if challenge == nil {
+		return fmt.Errorf("no dns-01 challenge offered for %s", authz.Identifier.Value)
+	}
+
+	value, err := m.client.DNS01ChallengeRecord(challenge.Token)
+	if err != nil {
+		return err
+	}
+	fqdn := "_acme-challenge." + strings.TrimPrefix(authz.Identifier.Value, "*.") + "."
+
+	presentCtx, cancel := context.WithTimeout(ctx, dnsProviderTimeout)
+	err = m.provider.Present(presentCtx, fqdn, value)
+	cancel()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		cleanupCtx, cancel := context.WithTimeout(context.Background(), dnsProviderTimeout)
+		defer cancel()
+		if err := m.provider.CleanUp(cleanupCtx, fqdn, value); err != nil {
+			logger.Warnw("could not clean up DNS challenge record", err, "fqdn", fqdn)
+		}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(m.conf.PropagationDelay):
+	}
+
+	if _, err = m.client.Accept(ctx, challenge); err != nil {
+		return err
+	}
+	_, err = m.client.WaitAuthorization(ctx, authz.URI)
+	return err
+}
+
+func (m *dnsCertManager) certPath() string {
+	return filepath.Join(m.conf.CacheDir, m.domain+".pem")
+}
+
+func (m *dnsCertManager) loadCert() (*tls.Certificate, error) {
+	data, err := os.ReadFile(m.certPath())
+	if err != nil {
+		return nil, err
+	}
+	// certificate chain and key are stored in the same file
+	cert, err := tls.X509KeyPair(data, data)
+	if err != nil {
+		return nil, err
+	}
+	if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+		return nil, err
+	}
+	return &cert, nil
+}
+
+func (m *dnsCertManager) storeCert(cert *tls.Certificate) error {
+	keyDER, err := x509.MarshalECPrivateKey(cert.PrivateKey.(*ecdsa.PrivateKey))
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err = pem.Encode(&buf, &pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}); err != nil {
+		return err
+	}
+	for _, der := range cert.Certificate {
+		if err = pem.Encode(&buf, &pem.Block{Type: "CERTIFICATE", Bytes: der}); err != nil {
+			return err
+		}
+	}
+	return writeFileAtomic(m.certPath(), buf.Bytes())
+}
+
+func (m *dnsCertManager) loadAccountKey() (crypto.Signer, error) {
+	path := filepath.Join(m.conf.CacheDir, "acme_account.key")
+	if data, err := os.ReadFile(path); err == nil {
+		block, _ := pem.Decode(data)
+		if block == nil {
+			return nil, fmt.Errorf("invalid ACME account key in %s", path)
+		}
+		return x509.ParseECPrivateKey(block.Bytes)
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if err = writeFileAtomic(path, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// ------------------------------------------------
+
+// execDNSProvider runs a user supplied command as `<command> present|cleanup <fqdn> <value>`
+type execDNSProvider struct {
+	command string
+}
+
+func newExecDNSProvider(conf map[string]string) (DNSProvider, error) {
+	command := conf["command"]
+	if command == "" {
+		return nil, errors.New("exec DNS provider requires command")
+	}
+	return &execDNSProvider{command: command}, nil
+}
+
+func (p *execDNSProvider) Present(ctx context.Context, fqdn, value string) error {
+	return p.run(ctx, "present", fqdn, value)
+}
+
+func (p *execDNSProvider) CleanUp(ctx context.Context, fqdn, value string) error {
+	return p.run(ctx, "cleanup", fqdn, value)
+}
+
+func (p *execDNSProvider) run(ctx context.Context, action, fqdn, value string) error {
+	out, err := exec.CommandContext(ctx, p.command, action, fqdn, value).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s %s failed: %w: %s", p.command, action, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// httpReqDNSProvider posts challenge records to an HTTP endpoint that manages DNS
+type httpReqDNSProvider struct {
+	url      string
+	username string
+	password string
+	client   *http.Client
+}
+
+func newHTTPReqDNSProvider(conf map[string]string) (DNSProvider, error) {
+	url := conf["url"]
+	if url == "" {
+		return nil, errors.New("httpreq DNS provider requires url")
+	}
+	return &httpReqDNSProvider{
+		url:      strings.TrimSuffix(url, "/"),
+		username: conf["username"],
+		password: conf["password"],
+		client:   &http.Client{},
+	}, nil
+}
+
+func (p *httpReqDNSProvider) Present(ctx context.Context, fqdn, value string) error {
+	return p.post(ctx, "/present", fqdn, value)
+}
+
+func (p *httpReqDNSProvider) CleanUp(ctx context.Context, fqdn, value string) error {
+	return p.post(ctx, "/cleanup", fqdn, value)
+}
+
+func (p *httpReqDNSProvider) post(ctx context.Context, path, fqdn, value string) error {
+	body, err := json.Marshal(map[string]string{"fqdn": fqdn, "value": value})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.username != "" {
+		req.SetBasicAuth(p.username, p.password)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d from %s", resp.StatusCode, p.url+path)
+	}
+	return nil
+}
@@ -0,0 +1,361 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/livekit-server/pkg/config"
+)
+
+const testTURNDomain = "turn.example.com"
+
+// fakeACMEServer implements just enough of RFC 8555 to issue certificates with a single dns-01 authorization.
+// Request signatures are not verified.
+type fakeACMEServer struct {
+	*httptest.Server
+	ca       *testCA
+	lifetime time.Duration
+
+	lock     sync.Mutex
+	accounts int
+	orders   int
+	accepted bool
+	issued   *x509.Certificate
+}
+
+func newFakeACMEServer(t *testing.T, lifetime time.Duration) *fakeACMEServer {
+	s := &fakeACMEServer{
+		ca:       newTestCA(t),
+		lifetime: lifetime,
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		s.serve(t, w, r)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *fakeACMEServer) serve(t *testing.T, w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Replay-Nonce", fmt.Sprintf("nonce-%d", time.Now().UnixNano()))
+	order := map[string]interface{}{
+		"identifiers":    []map[string]string{{"type": "dns", "value": testTURNDomain}},
+		"authorizations": []string{s.URL + "/authz"},
+		"finalize":       s.URL + "/finalize",
+	}
+
+	switch r.URL.Path {
+	case "/directory":
+		writeACMEResponse(w, http.StatusOK, map[string]string{
+			"newNonce":   s.URL + "/nonce",
+			"newAccount": s.URL + "/account",
+			"newOrder":   s.URL + "/order",
+		})
+
+	case "/nonce":
+		w.WriteHeader(http.StatusOK)
+
+	case "/account":
+		status := http.StatusCreated
+		if s.accounts > 0 {
+			status = http.StatusOK
+		}
+		s.accounts++
+		w.Header().Set("Location", s.URL+"/account/1")
+		writeACMEResponse(w, status, map[string]string{"status": "valid"})
+
+	case "/order":
+		s.orders++
+		s.accepted = false
+		s.issued = nil
+		order["status"] = "pending"
+		w.Header().Set("Location", s.URL+"/order/1")
+		writeACMEResponse(w, http.StatusCreated, order)
+
+	case "/order/1":
+		order["status"] = "pending"
+		if s.issued != nil {
+			order["status"] = "valid"
+			order["certificate"] = s.URL + "/cert"
+		} else if s.accepted {
+			order["status"] = "ready"
+		}
+		w.Header().Set("Location", s.URL+"/order/1")
+		writeACMEResponse(w, http.StatusOK, order)
+
+	case "/authz":
+		status := "pending"
+		if s.accepted {
+			status = "valid"
+		}
+		writeACMEResponse(w, http.StatusOK, map[string]interface{}{
+			"status":     status,
+			"identifier": map[string]string{"type": "dns", "value": testTURNDomain},
+			"challenges": []map[string]string{
+				{"type": "http-01", "url": s.URL + "/challenge/http", "token": "http-token", "status": "pending"},
+				{"type": "dns-01", "url": s.URL + "/challenge/dns", "token": "dns-token", "status": "pending"},
+			},
+		})
+
+	case "/challenge/dns":
+		s.accepted = true
+		writeACMEResponse(w, http.StatusOK, map[string]string{
+			"type": "dns-01", "url": s.URL + "/challenge/dns", "token": "dns-token", "status": "processing",
+		})
+
+	case "/finalize":
+		var req struct {
+			CSR string `json:"csr"`
+		}
+		require.NoError(t, json.Unmarshal(jwsPayload(t, r), &req))
+		der, err := base64.RawURLEncoding.DecodeString(req.CSR)
+		require.NoError(t, err)
+		csr, err := x509.ParseCertificateRequest(der)
+		require.NoError(t, err)
+		require.Equal(t, []string{testTURNDomain}, csr.DNSNames)
+
+		s.issued = s.ca.issue(t, csr.PublicKey, testTURNDomain, s.lifetime, "")
+		order["status"] = "valid"
+		order["certificate"] = s.URL + "/cert"
+		w.Header().Set("Location", s.URL+"/order/1")
+		writeACMEResponse(w, http.StatusOK, order)
+
+	case "/cert":
+		w.Header().Set("Content-Type", "application/pem-certificate-chain")
+		_ = pem.Encode(w, &pem.Block{Type: "CERTIFICATE", Bytes: s.issued.Raw})
+		_ = pem.Encode(w, &pem.Block{Type: "CERTIFICATE", Bytes: s.ca.cert.Raw})
+
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (s *fakeACMEServer) numOrders() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.orders
+}
+
+func writeACMEResponse(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func jwsPayload(t *testing.T, r *http.Request) []byte {
+	var jws struct {
+		Payload string `json:"payload"`
+	}
+	require.NoError(t, json.NewDecoder(r.Body).Decode(&jws))
+	payload, err := base64.RawURLEncoding.DecodeString(jws.Payload)
+	require.NoError(t, err)
+	return payload
+}
+
+type testDNSProvider struct {
+	lock    sync.Mutex
+	records map[string]string
+	cleaned map[string]string
+}
+
+func (p *testDNSProvider) Present(_ context.Context, fqdn, value string) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.records[fqdn] = value
+	return nil
+}
+
+func (p *testDNSProvider) CleanUp(_ context.Context, fqdn, value string) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.cleaned[fqdn] = value
+	return nil
+}
+
+func newTestACMEConfig(t *testing.T, s *fakeACMEServer) (*config.TURNACMEConfig, *testDNSProvider) {
+	provider := &testDNSProvider{
+		records: make(map[string]string),
+		cleaned: make(map[string]string),
+	}
+	name := "test-" + t.Name()
+	RegisterDNSProvider(name, func(_ map[string]string) (DNSProvider, error) {
+		return provider, nil
+	})
+	return &config.TURNACMEConfig{
+		Enabled:      true,
+		DirectoryURL: s.URL + "/directory",
+		CacheDir:     t.TempDir(),
+		RenewBefore:  time.Hour,
+		DNSProvider:  name,
+	}, provider
+}
+
+func TestDNSCertManager(t *testing.T) {
+	t.Run("obtains certificate with dns-01", func(t *testing.T) {
+		s := newFakeACMEServer(t, 24*time.Hour)
+		conf, provider := newTestACMEConfig(t, s)
+
+		m, err := newDNSCertManager(conf, testTURNDomain)
+		require.NoError(t, err)
+		require.Equal(t, 1, s.numOrders())
+
+		cert, err := m.GetCertificate(nil)
+		require.NoError(t, err)
+		require.Equal(t, []string{testTURNDomain}, cert.Leaf.DNSNames)
+		require.Len(t, cert.Certificate, 2, "issuer is served with the certificate")
+		require.NoError(t, cert.Leaf.CheckSignatureFrom(s.ca.cert))
+
+		record, err := m.client.DNS01ChallengeRecord("dns-token")
+		require.NoError(t, err)
+		fqdn := "_acme-challenge." + testTURNDomain + "."
+		require.Equal(t, map[string]string{fqdn: record}, provider.records)
+		require.Equal(t, map[string]string{fqdn: record}, provider.cleaned)
+
+		require.FileExists(t, filepath.Join(conf.CacheDir, testTURNDomain+".pem"))
+		require.FileExists(t, filepath.Join(conf.CacheDir, "acme_account.key"))
+	})
+
+	t.Run("reuses cached certificate and account", func(t *testing.T) {
+		s := newFakeACMEServer(t, 24*time.Hour)
+		conf, _ := newTestACMEConfig(t, s)
+
+		m, err := newDNSCertManager(conf, testTURNDomain)
+		require.NoError(t, err)
+		cert, _ := m.GetCertificate(nil)
+
+		restarted, err := newDNSCertManager(conf, testTURNDomain)
+		require.NoError(t, err)
+		require.Equal(t, 1, s.numOrders())
+		require.Equal(t, m.client.Key.Public(), restarted.client.Key.Public())
+
+		cached, _ := restarted.GetCertificate(nil)
+		require.Equal(t, cert.Leaf.SerialNumber, cached.Leaf.SerialNumber)
+		require.Equal(t, cert.Certificate, cached.Certificate)
+	})
+
+	t.Run("serves renewed certificate", func(t *testing.T) {
+		// issued certificates are always within the renewal window
+		s := newFakeACMEServer(t, 30*time.Minute)
+		conf, _ := newTestACMEConfig(t, s)
+
+		m, err := newDNSCertManager(conf, testTURNDomain)
+		require.NoError(t, err)
+		cert, _ := m.GetCertificate(nil)
+		require.True(t, m.needsRenewal())
+
+		require.NoError(t, m.renew(context.Background()))
+		require.Equal(t, 2, s.numOrders())
+		renewed, _ := m.GetCertificate(nil)
+		require.NotEqual(t, cert.Leaf.SerialNumber, renewed.Leaf.SerialNumber)
+
+		// the renewed certificate is persisted
+		restarted, err := m.loadCert()
+		require.NoError(t, err)
+		require.Equal(t, renewed.Leaf.SerialNumber, restarted.Leaf.SerialNumber)
+	})
+
+	t.Run("falls back to cached certificate when renewal fails", func(t *testing.T) {
+		s := newFakeACMEServer(t, 30*time.Minute)
+		conf, _ := newTestACMEConfig(t, s)
+
+		m, err := newDNSCertManager(conf, testTURNDomain)
+		require.NoError(t, err)
+		cert, _ := m.GetCertificate(nil)
+
+		s.Close()
+		restarted, err := newDNSCertManager(conf, testTURNDomain)
+		require.NoError(t, err)
+		cached, _ := restarted.GetCertificate(nil)
+		require.Equal(t, cert.Leaf.SerialNumber, cached.Leaf.SerialNumber)
+
+		// without a cached certificate there is nothing to serve
+		require.NoError(t, os.Remove(m.certPath()))
+		_, err = newDNSCertManager(conf, testTURNDomain)
+		require.Error(t, err)
+	})
+
+	t.Run("unknown provider", func(t *testing.T) {
+		_, err := newDNSCertManager(&config.TURNACMEConfig{
+			DNSProvider: "unknown",
+			CacheDir:    t.TempDir(),
+		}, testTURNDomain)
+		require.ErrorIs(t, err, ErrUnknownDNSProvider)
+	})
+}
+
+func TestHTTPReqDNSProvider(t *testing.T) {
+	var (
+		lock     sync.Mutex
+		requests []string
+		status   = http.StatusOK
+	)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		username, password, ok := r.BasicAuth()
+		require.True(t, ok)
+		require.Equal(t, "user", username)
+		require.Equal(t, "secret", password)
+
+		var body map[string]string
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		requests = append(requests, r.URL.Path+" "+body["fqdn"]+" "+body["value"])
+		w.WriteHeader(status)
+	}))
+	defer s.Close()
+
+	_, err := newDNSProvider("httpreq", map[string]string{})
+	require.Error(t, err)
+
+	p, err := newDNSProvider("httpreq", map[string]string{
+		"url":      s.URL + "/",
+		"username": "user",
+		"password": "secret",
+	})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	require.NoError(t, p.Present(ctx, "_acme-challenge.turn.example.com.", "record"))
+	require.NoError(t, p.CleanUp(ctx, "_acme-challenge.turn.example.com.", "record"))
+
+	lock.Lock()
+	status = http.StatusInternalServerError
+	lock.Unlock()
+	require.Error(t, p.Present(ctx, "_acme-challenge.turn.example.com.", "record"))
+
+	lock.Lock()
+	defer lock.Unlock()
+	require.Equal(t, []string{
+		"/present _acme-challenge.turn.example.com. record",
+		"/cleanup _acme-challenge.turn.example.com. record",
+		"/present _acme-challenge.turn.example.com. record",
+	}, requests)
+}
@@ -0,0 +1,458 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pion/stun"
+	"github.com/pion/turn/v2"
+	"go.uber.org/atomic"
+
+	"github.com/livekit/protocol/logger"
+	"github.com/livekit/protocol/utils"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/telemetry/prometheus"
+)
+
+const (
+	turnAllocationsPath = "/turn/allocations"
+
+	turnFailureAuth      = "auth"
+	turnFailureRelayPort = "relay_port"
+	turnFailureQuota     = "quota"
+
+	// clients that authenticated but did not get an allocation count against the quota of their user till then
+	turnPendingClientTimeout = 30 * time.Second
+)
+
+var turnAllocateSuccessType = stun.NewType(stun.MethodAllocate, stun.ClassSuccessResponse).Value()
+
+// TurnAllocationInfo describes an active TURN allocation of the embedded TURN server
+type TurnAllocationInfo struct {
+	ID string `json:"id"`
+	// TURN username the allocation was authenticated with, empty until the client was told its relay address
+	Username string `json:"username,omitempty"`
+	// transport between client and TURN server, udp, tls or tcp
+	Transport    string `json:"transport"`
+	RelayAddress string `json:"relay_address"`
+	CreatedAt    int64  `json:"created_at"`
+	// bytes received from and sent to peers through the relay
+	BytesIn  uint64 `json:"bytes_in"`
+	BytesOut uint64 `json:"bytes_out"`
+}
+
+// TurnAllocations tracks the relay sockets of the embedded TURN server, one per allocation, and the clients
+// holding them. Clients are attributed to the TURN username they authenticated with, which caps the allocations
+// of a user, and relayed traffic of every allocation is limited to a bitrate.
+type TurnAllocations struct {
+	maxPerUser int
+	maxBitrate uint64
+
+	lock        sync.Mutex
+	allocations map[string]*turnAllocation
+	// by client transport address
+	clients map[string]*turnClient
+}
+
+type turnClient struct {
+	username        string
+	authenticatedAt time.Time
+	allocation      *turnAllocation
+}
+
+func NewTurnAllocations(conf *config.Config) *TurnAllocations {
+	return &TurnAllocations{
+		maxPerUser:  conf.TURN.MaxAllocationsPerUser,
+		maxBitrate:  conf.TURN.MaxAllocationBitrate,
+		allocations: make(map[string]*turnAllocation),
+		clients:     make(map[string]*turnClient),
+	}
+}
+
+// relayAddressGenerator tracks the allocations relayed for clients of a TURN listener using transport
+func (t *TurnAllocations) relayAddressGenerator(gen turn.RelayAddressGenerator, transport string) turn.RelayAddressGenerator {
+	if t == nil {
+		return gen
+	}
+	return &trackedRelayAddressGenerator{
+		RelayAddressGenerator: gen,
+		allocations:           t,
+		transport:             transport,
+	}
+}
+
+func (t *TurnAllocations) List() []*TurnAllocationInfo {
+	t.lock.Lock()
+	infos := make([]*TurnAllocationInfo, 0, len(t.allocations))
+	for _, a := range t.allocations {
+		infos = append(infos, a.toInfo())
+	}
+	t.lock.Unlock()
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].CreatedAt < infos[j].CreatedAt
+	})
+	return infos
+}
+
+// Kill closes the relay socket of the allocation, ending relaying for the client. It returns false when the
+// allocation doesn't exist
+func (t *TurnAllocations) Kill(id string) bool {
+	t.lock.Lock()
+	a := t.allocations[id]
+	t.lock.Unlock()
+	if a == nil {
+		return false
+	}
+
+	logger.Infow("killing TURN allocation", "allocationID", id, "relayAddress", a.relayAddress)
+	_ = a.Close()
+	return true
+}
+
+// packetConn observes responses sent to clients of a TURN UDP listener
+func (t *TurnAllocations) packetConn(conn net.PacketConn) net.PacketConn {
+	if t == nil {
+		return conn
+	}
+	return &turnClientPacketConn{
+		PacketConn:  conn,
+		allocations: t,
+	}
+}
+
+// listener observes responses sent to clients of a TURN TCP or TLS listener
+func (t *TurnAllocations) listener(l net.Listener) net.Listener {
+	if t == nil {
+		return l
+	}
+	return &turnClientListener{
+		Listener:    l,
+		allocations: t,
+	}
+}
+
+// authorize is called for every authenticated request of a client. A client that does not hold an allocation yet
+// is refused once its user reached the allocation quota.
+func (t *TurnAllocations) authorize(username string, clientAddr net.Addr) bool {
+	if t == nil {
+		return true
+	}
+
+	now := time.Now()
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	key := clientAddr.String()
+	if c := t.clients[key]; c != nil && c.username == username {
+		if c.allocation == nil {
+			c.authenticatedAt = now
+		}
+		return true
+	}
+
+	held := 0
+	for k, c := range t.clients {
+		if c.allocation == nil && now.Sub(c.authenticatedAt) > turnPendingClientTimeout {
+			delete(t.clients, k)
+			continue
+		}
+		if c.username == username {
+			held++
+		}
+	}
+	if t.maxPerUser > 0 && held >= t.maxPerUser {
+		return false
+	}
+
+	t.clients[key] = &turnClient{
+		username:        username,
+		authenticatedAt: now,
+	}
+	return true
+}
+
+// observeResponse attributes the allocation whose relay address is sent to a client in an allocate success response
+func (t *TurnAllocations) observeResponse(clientAddr net.Addr, p []byte) {
+	if len(p) < 2 || binary.BigEndian.Uint16(p) != turnAllocateSuccessType || !stun.IsMessage(p) {
+		return
+	}
+
+	m := &stun.Message{Raw: p}
+	if err := m.Decode(); err != nil {
+		return
+	}
+	var relayed stun.XORMappedAddress
+	if err := relayed.GetFromAs(m, stun.AttrXORRelayedAddress); err != nil {
+		return
+	}
+	relayAddress := (&net.UDPAddr{IP: relayed.IP, Port: relayed.Port}).String()
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	key := clientAddr.String()
+	c := t.clients[key]
+	if c == nil || c.allocation != nil {
+		return
+	}
+	for _, a := range t.allocations {
+		if a.relayAddress == relayAddress && a.clientAddr == "" {
+			a.clientAddr = key
+			a.username = c.username
+			c.allocation = a
+			return
+		}
+	}
+}
+
+func (t *TurnAllocations) add(a *turnAllocation) {
+	t.lock.Lock()
+	t.allocations[a.id] = a
+	t.lock.Unlock()
+	prometheus.AddTurnAllocation(a.transport)
+}
+
+func (t *TurnAllocations) remove(a *turnAllocation) {
+	t.lock.Lock()
+	delete(t.allocations, a.id)
+	if c := t.clients[a.clientAddr]; c != nil && c.allocation == a {
+		delete(t.clients, a.clientAddr)
+	}
+	t.lock.Unlock()
+	prometheus.SubTurnAllocation(a.transport)
+}
+
+type trackedRelayAddressGenerator struct {
+	turn.RelayAddressGenerator
+	allocations *TurnAllocations
+	transport   string
+}
+
+func (g *trackedRelayAddressGenerator) AllocatePacketConn(network string, requestedPort int) (net.PacketConn, net.Addr, error) {
+	conn, addr, err := g.RelayAddressGenerator.AllocatePacketConn(network, requestedPort)
+	if err != nil {
+		prometheus.RecordTurnAllocationFailure(turnFailureRelayPort)
+		return nil, addr, err
+	}
+
+	a := &turnAllocation{
+		PacketConn:   conn,
+		id:           utils.NewGuid("TA_"),
+		transport:    g.transport,
+		relayAddress: addr.String(),
+		createdAt:    time.Now(),
+		allocations:  g.allocations,
+		inLimiter:    newTurnBitrateLimiter(g.allocations.maxBitrate),
+		outLimiter:   newTurnBitrateLimiter(g.allocations.maxBitrate),
+	}
+	g.allocations.add(a)
+	return a, addr, nil
+}
+
+type turnAllocation struct {
+	net.PacketConn
+	id           string
+	transport    string
+	relayAddress string
+	createdAt    time.Time
+	allocations  *TurnAllocations
+	inLimiter    *turnBitrateLimiter
+	outLimiter   *turnBitrateLimiter
+
+	// protected by allocations.lock
+	clientAddr string
+	username   string
+
+	bytesIn   atomic.Uint64
+	bytesOut  atomic.Uint64
+	closeOnce sync.Once
+}
+
+func (a *turnAllocation) ReadFrom(p []byte) (int, net.Addr, error) {
+	for {
+		n, addr, err := a.PacketConn.ReadFrom(p)
+		if n > 0 && err == nil && !a.inLimiter.allow(n) {
+			prometheus.IncrementTurnDroppedBytes(a.transport, prometheus.Incoming, uint64(n))
+			continue
+		}
+		if n > 0 {
+			a.bytesIn.Add(uint64(n))
+			prometheus.IncrementTurnRelayedBytes(a.transport, prometheus.Incoming, uint64(n))
+		}
+		return n, addr, err
+	}
+}
+
+func (a *turnAllocation) WriteTo(p []byte, addr net.Addr) (int, error) {
+	if !a.outLimiter.allow(len(p)) {
+		// dropped like any datagram lost on the way
+		prometheus.IncrementTurnDroppedBytes(a.transport, prometheus.Outgoing, uint64(len(p)))
+		return len(p), nil
+	}
+	n, err := a.PacketConn.WriteTo(p, addr)
+	if n > 0 {
+		a.bytesOut.Add(uint64(n))
+		prometheus.IncrementTurnRelayedBytes(a.transport, prometheus.Outgoing, uint64(n))
+	}
+	return n, err
+}
+
+func (a *turnAllocation) Close() error {
+	a.closeOnce.Do(func() {
+		a.allocations.remove(a)
+	})
+	return a.PacketConn.Close()
+}
+
+// toInfo is called with allocations.lock held
+func (a *turnAllocation) toInfo() *TurnAllocationInfo {
+	return &TurnAllocationInfo{
+		ID:           a.id,
+		Username:     a.username,
+		Transport:    a.transport,
+		RelayAddress: a.relayAddress,
+		CreatedAt:    a.createdAt.Unix(),
+		BytesIn:      a.bytesIn.Load(),
+		BytesOut:     a.bytesOut.Load(),
+	}
+}
+
+// turnBitrateLimiter is a token bucket holding up to one second of traffic, a nil limiter allows everything
+type turnBitrateLimiter struct {
+	lock   sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+func newTurnBitrateLimiter(bitrate uint64) *turnBitrateLimiter {
+	if bitrate == 0 {
+		return nil
+	}
+	rate := float64(bitrate) / 8
+	return &turnBitrateLimiter{
+		rate:   rate,
+		tokens: rate,
+		last:   time.Now(),
+	}
+}
+
+func (l *turnBitrateLimiter) allow(bytes int) bool {
+	if l == nil {
+		return true
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.rate {
+		l.tokens = l.rate
+	}
+	l.last = now
+
+	if l.tokens < float64(bytes) {
+		return false
+	}
+	l.tokens -= float64(bytes)
+	return true
+}
+
+type turnClientPacketConn struct {
+	net.PacketConn
+	allocations *TurnAllocations
+}
+
+func (c *turnClientPacketConn) WriteTo(p []byte, addr net.Addr) (int, error) {
+	c.allocations.observeResponse(addr, p)
+	return c.PacketConn.WriteTo(p, addr)
+}
+
+type turnClientListener struct {
+	net.Listener
+	allocations *TurnAllocations
+}
+
+func (l *turnClientListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &turnClientConn{
+		Conn:        conn,
+		allocations: l.allocations,
+	}, nil
+}
+
+// turnClientConn relies on the TURN server writing one whole message per Write
+type turnClientConn struct {
+	net.Conn
+	allocations *TurnAllocations
+}
+
+func (c *turnClientConn) Write(p []byte) (int, error) {
+	c.allocations.observeResponse(c.RemoteAddr(), p)
+	return c.Conn.Write(p)
+}
+
+type listTurnAllocationsResponse struct {
+	Allocations []*TurnAllocationInfo `json:"allocations"`
+}
+
+// TurnAllocationService lists the allocations of the TURN server embedded in this node, and kills them with
+// DELETE /turn/allocations?id=<id>
+type TurnAllocationService struct {
+	allocations *TurnAllocations
+}
+
+func NewTurnAllocationService(allocations *TurnAllocations) *TurnAllocationService {
+	return &TurnAllocationService{
+		allocations: allocations,
+	}
+}
+
+func (s *TurnAllocationService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := EnsureCreatePermission(r.Context()); err != nil {
+		handleError(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(&listTurnAllocationsResponse{
+			Allocations: s.allocations.List(),
+		})
+	case http.MethodDelete:
+		id := r.URL.Query().Get("id")
+		if !s.allocations.Kill(id) {
+			handleError(w, http.StatusNotFound, ErrAllocationNotFound, "allocationID", id)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
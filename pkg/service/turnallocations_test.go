@@ -0,0 +1,180 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"net"
+	"testing"
+
+	"github.com/pion/stun"
+	"github.com/pion/turn/v2"
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/protocol/livekit"
+
+	"github.com/livekit/livekit-server/pkg/config"
+)
+
+type loopbackRelayAddressGenerator struct {
+	turn.RelayAddressGenerator
+}
+
+func (g *loopbackRelayAddressGenerator) AllocatePacketConn(network string, _ int) (net.PacketConn, net.Addr, error) {
+	conn, err := net.ListenPacket(network, "127.0.0.1:0")
+	if err != nil {
+		return nil, nil, err
+	}
+	return conn, conn.LocalAddr(), nil
+}
+
+func TestTurnAllocations(t *testing.T) {
+	allocations := NewTurnAllocations(&config.Config{})
+	gen := allocations.relayAddressGenerator(&loopbackRelayAddressGenerator{}, "udp")
+
+	relay, relayAddr, err := gen.AllocatePacketConn("udp4", 0)
+	require.NoError(t, err)
+	defer relay.Close()
+
+	peer, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer peer.Close()
+
+	_, err = peer.WriteTo([]byte("hello"), relayAddr)
+	require.NoError(t, err)
+	buf := make([]byte, 16)
+	n, _, err := relay.ReadFrom(buf)
+	require.NoError(t, err)
+	require.Equal(t, 5, n)
+	_, err = relay.WriteTo([]byte("hi"), peer.LocalAddr())
+	require.NoError(t, err)
+
+	infos := allocations.List()
+	require.Len(t, infos, 1)
+	require.Equal(t, "udp", infos[0].Transport)
+	require.Equal(t, relayAddr.String(), infos[0].RelayAddress)
+	require.Equal(t, uint64(5), infos[0].BytesIn)
+	require.Equal(t, uint64(2), infos[0].BytesOut)
+
+	require.True(t, allocations.Kill(infos[0].ID))
+	require.Empty(t, allocations.List())
+	require.False(t, allocations.Kill(infos[0].ID))
+
+	// the relay socket is closed, ending the allocation
+	_, _, err = relay.ReadFrom(buf)
+	require.Error(t, err)
+}
+
+func TestTurnAllocationQuota(t *testing.T) {
+	conf := &config.Config{}
+	conf.TURN.MaxAllocationsPerUser = 1
+	allocations := NewTurnAllocations(conf)
+	gen := allocations.relayAddressGenerator(&loopbackRelayAddressGenerator{}, "udp")
+
+	username := turnUsername("myroom", "PA_abc")
+	client := &net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 5000}
+	otherClient := &net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 5001}
+
+	require.True(t, allocations.authorize(username, client))
+	// requests of the same client keep being authorized
+	require.True(t, allocations.authorize(username, client))
+	// a pending client counts against the quota
+	require.False(t, allocations.authorize(username, otherClient))
+	require.True(t, allocations.authorize(turnUsername("myroom", "PA_def"), otherClient))
+
+	relay, relayAddr, err := gen.AllocatePacketConn("udp4", 0)
+	require.NoError(t, err)
+	defer relay.Close()
+
+	m := stun.MustBuild(stun.NewType(stun.MethodAllocate, stun.ClassSuccessResponse), stun.TransactionID)
+	udpAddr := relayAddr.(*net.UDPAddr)
+	require.NoError(t, (&stun.XORMappedAddress{IP: udpAddr.IP, Port: udpAddr.Port}).AddToAs(m, stun.AttrXORRelayedAddress))
+	_, err = allocations.packetConn(&loopbackPacketConn{}).WriteTo(m.Raw, client)
+	require.NoError(t, err)
+
+	infos := allocations.List()
+	require.Len(t, infos, 1)
+	require.Equal(t, username, infos[0].Username)
+
+	// ending the allocation frees the quota
+	require.True(t, allocations.Kill(infos[0].ID))
+	require.True(t, allocations.authorize(username, &net.UDPAddr{IP: net.IPv4(10, 0, 0, 2), Port: 5000}))
+}
+
+type loopbackPacketConn struct {
+	net.PacketConn
+}
+
+func (c *loopbackPacketConn) WriteTo(p []byte, _ net.Addr) (int, error) {
+	return len(p), nil
+}
+
+func TestTurnBitrateLimiter(t *testing.T) {
+	require.Nil(t, newTurnBitrateLimiter(0))
+	require.True(t, (*turnBitrateLimiter)(nil).allow(1500))
+
+	// 1000 bytes per second
+	l := newTurnBitrateLimiter(8000)
+	require.True(t, l.allow(600))
+	require.False(t, l.allow(600))
+	require.True(t, l.allow(300))
+}
+
+func TestTurnUsernameRoom(t *testing.T) {
+	require.Equal(t, livekit.RoomName("myroom"), turnUsernameRoom(turnUsername("myroom", "PA_abc")))
+	require.Equal(t, livekit.RoomName("a/b"), turnUsernameRoom(turnUsername("a/b", "PA_abc")))
+	// usernames handed out before they were per participant
+	require.Equal(t, livekit.RoomName("myroom"), turnUsernameRoom("myroom"))
+	require.Equal(t, livekit.RoomName("a/b"), turnUsernameRoom("a/b"))
+}
+
+func TestEdgeTurnAuthHandler(t *testing.T) {
+	conf := &config.Config{
+		Edge: config.EdgeConfig{CoreAddress: "core:7890", Secret: "secret", TURN: true},
+		TURN: config.TURNConfig{Enabled: true, UDPPort: 3478},
+	}
+	conf.RTC.NodeIP = "10.0.0.1"
+	client := &EdgeRelayClient{conf: conf}
+	authHandler := newEdgeTurnAuthHandler(&conf.Edge, nil)
+	addr := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 5000}
+
+	res := &livekit.SignalResponse{Message: &livekit.SignalResponse_Join{Join: &livekit.JoinResponse{
+		IceServers: []*livekit.ICEServer{{Urls: []string{"turn:core:3478"}}},
+	}}}
+	client.addTURNServer("room", "PA_participant", res)
+	iceServers := res.GetJoin().IceServers
+	require.Len(t, iceServers, 2)
+	require.Equal(t, []string{"turn:10.0.0.1:3478?transport=udp"}, iceServers[0].Urls)
+
+	username := iceServers[0].Username
+	key, ok := authHandler(username, LivekitRealm, addr)
+	require.True(t, ok)
+	require.Equal(t, turn.GenerateAuthKey(username, LivekitRealm, iceServers[0].Credential), key)
+
+	t.Run("other secret", func(t *testing.T) {
+		key, ok := newEdgeTurnAuthHandler(&config.EdgeConfig{Secret: "other"}, nil)(username, LivekitRealm, addr)
+		require.True(t, ok)
+		require.NotEqual(t, turn.GenerateAuthKey(username, LivekitRealm, iceServers[0].Credential), key)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		_, ok := authHandler("1:room_PA_participant", LivekitRealm, addr)
+		require.False(t, ok)
+	})
+
+	t.Run("unsigned", func(t *testing.T) {
+		_, ok := authHandler("room", LivekitRealm, addr)
+		require.False(t, ok)
+	})
+}
@@ -201,7 +201,7 @@ func statusReporterResponseSent(ctx context.Context) {
 		code = r.error.Code()
 	}
 
-	prometheus.TwirpRequestStatusCounter.WithLabelValues(r.service, r.method, statusFamily, string(code)).Add(1)
+	prometheus.IncrementTwirpRequestStatus(ctx, r.service, r.method, statusFamily, string(code))
 }
 
 func statusReporterErrorReceived(ctx context.Context, e twirp.Error) context.Context {
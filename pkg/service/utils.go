@@ -15,9 +15,11 @@
 package service
 
 import (
+	"fmt"
 	"net"
 	"net/http"
 	"regexp"
+	"strings"
 
 	"github.com/livekit/protocol/logger"
 )
@@ -52,3 +54,86 @@ func GetClientIP(r *http.Request) string {
 	ip, _, _ := net.SplitHostPort(r.RemoteAddr)
 	return ip
 }
+
+// TrustedProxies resolves the IP of the client behind a request. Forwarding headers can be set by any client,
+// so they are only read from requests coming from one of the configured proxies
+type TrustedProxies struct {
+	networks []*net.IPNet
+}
+
+// NewTrustedProxies parses proxy IPs or CIDRs. It returns nil when there are none, a nil *TrustedProxies
+// trusts no proxy
+func NewTrustedProxies(proxies []string) (*TrustedProxies, error) {
+	if len(proxies) == 0 {
+		return nil, nil
+	}
+
+	p := &TrustedProxies{}
+	for _, proxy := range proxies {
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", proxy)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			p.networks = append(p.networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", proxy, err)
+		}
+		p.networks = append(p.networks, network)
+	}
+	return p, nil
+}
+
+// ClientIP returns the peer address of the request, or the client address forwarded by trusted proxies
+func (p *TrustedProxies) ClientIP(r *http.Request) string {
+	remote, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		remote = r.RemoteAddr
+	}
+	if !p.isTrusted(remote) {
+		return remote
+	}
+
+	// proxies append the address they received the request from, the closest untrusted one is the client
+	if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) != 0 {
+		hops := strings.Split(strings.Join(forwarded, ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			if net.ParseIP(hop) == nil {
+				break
+			}
+			if !p.isTrusted(hop) || i == 0 {
+				return hop
+			}
+		}
+	}
+	for _, header := range []string{"X-Real-IP", "CF-Connecting-IP"} {
+		if ip := strings.TrimSpace(r.Header.Get(header)); net.ParseIP(ip) != nil {
+			return ip
+		}
+	}
+	return remote
+}
+
+func (p *TrustedProxies) isTrusted(addr string) bool {
+	if p == nil {
+		return false
+	}
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, network := range p.networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -15,6 +15,7 @@
 package service_test
 
 import (
+	"net/http"
 	"testing"
 
 	"github.com/redis/go-redis/v9"
@@ -41,3 +42,79 @@ func TestIsValidDomain(t *testing.T) {
 		require.Equal(t, service.IsValidDomain(key), result)
 	}
 }
+
+func TestTrustedProxies(t *testing.T) {
+	_, err := service.NewTrustedProxies([]string{"proxy.local"})
+	require.Error(t, err)
+	_, err = service.NewTrustedProxies([]string{"10.0.0.0/33"})
+	require.Error(t, err)
+
+	proxies, err := service.NewTrustedProxies([]string{"10.0.0.0/8", "192.0.2.10", "2001:db8::/32"})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name       string
+		proxies    *service.TrustedProxies
+		remoteAddr string
+		headers    map[string]string
+		expected   string
+	}{
+		{
+			name:       "headers are ignored without trusted proxies",
+			remoteAddr: "198.51.100.1:1000",
+			headers:    map[string]string{"X-Forwarded-For": "203.0.113.1", "CF-Connecting-IP": "203.0.113.2"},
+			expected:   "198.51.100.1",
+		},
+		{
+			name:       "headers are ignored from untrusted peers",
+			proxies:    proxies,
+			remoteAddr: "198.51.100.1:1000",
+			headers:    map[string]string{"X-Forwarded-For": "203.0.113.1", "X-Real-IP": "203.0.113.2"},
+			expected:   "198.51.100.1",
+		},
+		{
+			name:       "forwarded by a trusted proxy",
+			proxies:    proxies,
+			remoteAddr: "10.1.2.3:1000",
+			headers:    map[string]string{"X-Forwarded-For": "203.0.113.1"},
+			expected:   "203.0.113.1",
+		},
+		{
+			name:       "addresses prepended by the client are skipped",
+			proxies:    proxies,
+			remoteAddr: "10.1.2.3:1000",
+			headers:    map[string]string{"X-Forwarded-For": "198.51.100.99, 203.0.113.1, 192.0.2.10"},
+			expected:   "203.0.113.1",
+		},
+		{
+			name:       "only proxies forwarded",
+			proxies:    proxies,
+			remoteAddr: "10.1.2.3:1000",
+			headers:    map[string]string{"X-Forwarded-For": "10.0.0.1, 192.0.2.10"},
+			expected:   "10.0.0.1",
+		},
+		{
+			name:       "real ip of a trusted proxy",
+			proxies:    proxies,
+			remoteAddr: "[2001:db8::1]:1000",
+			headers:    map[string]string{"X-Real-IP": "2001:db9::1"},
+			expected:   "2001:db9::1",
+		},
+		{
+			name:       "invalid forwarded address",
+			proxies:    proxies,
+			remoteAddr: "192.0.2.10:1000",
+			headers:    map[string]string{"CF-Connecting-IP": "unknown"},
+			expected:   "192.0.2.10",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := &http.Request{Header: http.Header{}, RemoteAddr: tc.remoteAddr}
+			for k, v := range tc.headers {
+				r.Header.Set(k, v)
+			}
+			require.Equal(t, tc.expected, tc.proxies.ClientIP(r))
+		})
+	}
+}
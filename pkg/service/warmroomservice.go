@@ -0,0 +1,122 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/logger"
+
+	"github.com/livekit/livekit-server/pkg/routing"
+)
+
+const warmRoomPath = "/rooms/warm"
+
+type warmRoomRequest struct {
+	Room            string `json:"room"`
+	NodeID          string `json:"node_id,omitempty"`
+	EmptyTimeout    uint32 `json:"empty_timeout,omitempty"`
+	MaxParticipants uint32 `json:"max_participants,omitempty"`
+	// set up and tear down peer connections on the hosting node to take initialization off the first joins
+	PrewarmTransports bool `json:"prewarm_transports,omitempty"`
+}
+
+type warmRoomResponse struct {
+	Room                string `json:"room"`
+	Sid                 string `json:"sid"`
+	NodeID              string `json:"node_id"`
+	PrewarmedTransports bool   `json:"prewarmed_transports"`
+}
+
+// WarmRoomService provisions a room ahead of participants arriving, e.g. for scheduled sessions with a
+// join spike. The room is created on the requested node, defaulting to the node handling the request.
+// Transports can only be pre-warmed by the node hosting the room, the response carries that node's id.
+type WarmRoomService struct {
+	roomService livekit.RoomService
+	router      routing.Router
+	roomManager *RoomManager
+	currentNode routing.LocalNode
+}
+
+func NewWarmRoomService(roomService livekit.RoomService, router routing.Router, roomManager *RoomManager, currentNode routing.LocalNode) *WarmRoomService {
+	return &WarmRoomService{
+		roomService: roomService,
+		router:      router,
+		roomManager: roomManager,
+		currentNode: currentNode,
+	}
+}
+
+func (s *WarmRoomService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := EnsureCreatePermission(r.Context()); err != nil {
+		handleError(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	var req warmRoomRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		handleError(w, http.StatusBadRequest, err)
+		return
+	}
+	if req.Room == "" {
+		handleError(w, http.StatusBadRequest, ErrRoomNameRequired)
+		return
+	}
+	if req.NodeID == "" {
+		req.NodeID = s.currentNode.Id
+	}
+
+	// creating a room starts it on the selected node
+	rm, err := s.roomService.CreateRoom(r.Context(), &livekit.CreateRoomRequest{
+		Name:            req.Room,
+		EmptyTimeout:    req.EmptyTimeout,
+		MaxParticipants: req.MaxParticipants,
+		NodeId:          req.NodeID,
+	})
+	if err != nil {
+		handleError(w, http.StatusInternalServerError, err, "room", req.Room, "nodeID", req.NodeID)
+		return
+	}
+
+	node, err := s.router.GetNodeForRoom(r.Context(), livekit.RoomName(req.Room))
+	if err != nil {
+		handleError(w, http.StatusInternalServerError, err, "room", req.Room)
+		return
+	}
+
+	res := &warmRoomResponse{
+		Room:   rm.Name,
+		Sid:    rm.Sid,
+		NodeID: node.Id,
+	}
+	if req.PrewarmTransports && node.Id == s.currentNode.Id {
+		if err := s.roomManager.PrewarmTransports(r.Context(), livekit.RoomName(req.Room)); err != nil {
+			handleError(w, http.StatusInternalServerError, err, "room", req.Room)
+			return
+		}
+		res.PrewarmedTransports = true
+	}
+	logger.Infow("warmed room", "room", res.Room, "roomID", res.Sid, "nodeID", res.NodeID, "prewarmedTransports", res.PrewarmedTransports)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(res)
+}
@@ -0,0 +1,105 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/protocol/auth"
+	"github.com/livekit/protocol/livekit"
+
+	"github.com/livekit/livekit-server/pkg/routing/routingfakes"
+)
+
+type warmedRoomService struct {
+	livekit.RoomService
+	created []*livekit.CreateRoomRequest
+}
+
+func (s *warmedRoomService) CreateRoom(_ context.Context, req *livekit.CreateRoomRequest) (*livekit.Room, error) {
+	s.created = append(s.created, req)
+	return &livekit.Room{Name: req.Name, Sid: "RM_warm"}, nil
+}
+
+func TestWarmRoomService(t *testing.T) {
+	testCases := []struct {
+		name     string
+		method   string
+		body     string
+		noGrants bool
+		// node hosting the room
+		roomNode string
+		status   int
+		created  *livekit.CreateRoomRequest
+		expected *warmRoomResponse
+	}{
+		{name: "post only", method: http.MethodGet, status: http.StatusMethodNotAllowed},
+		{name: "no permission", method: http.MethodPost, body: `{"room":"class"}`, noGrants: true, status: http.StatusUnauthorized},
+		{name: "invalid body", method: http.MethodPost, body: `{`, status: http.StatusBadRequest},
+		{name: "no room", method: http.MethodPost, body: `{}`, status: http.StatusBadRequest},
+		{
+			name:     "current node by default",
+			method:   http.MethodPost,
+			body:     `{"room":"class","empty_timeout":600,"max_participants":50}`,
+			roomNode: "ND_local",
+			status:   http.StatusOK,
+			created:  &livekit.CreateRoomRequest{Name: "class", EmptyTimeout: 600, MaxParticipants: 50, NodeId: "ND_local"},
+			expected: &warmRoomResponse{Room: "class", Sid: "RM_warm", NodeID: "ND_local"},
+		},
+		{
+			// transports are only pre-warmed by the hosting node
+			name:     "other node",
+			method:   http.MethodPost,
+			body:     `{"room":"class","node_id":"ND_remote","prewarm_transports":true}`,
+			roomNode: "ND_remote",
+			status:   http.StatusOK,
+			created:  &livekit.CreateRoomRequest{Name: "class", NodeId: "ND_remote"},
+			expected: &warmRoomResponse{Room: "class", Sid: "RM_warm", NodeID: "ND_remote"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			roomService := &warmedRoomService{}
+			router := &routingfakes.FakeRouter{}
+			router.GetNodeForRoomReturns(&livekit.Node{Id: tc.roomNode}, nil)
+			s := NewWarmRoomService(roomService, router, nil, &livekit.Node{Id: "ND_local"})
+
+			req := httptest.NewRequest(tc.method, warmRoomPath, bytes.NewBufferString(tc.body))
+			if !tc.noGrants {
+				req = req.WithContext(WithGrants(req.Context(), &auth.ClaimGrants{Video: &auth.VideoGrant{RoomCreate: true}}))
+			}
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, req)
+			require.Equal(t, tc.status, w.Code)
+			if tc.created == nil {
+				require.Empty(t, roomService.created)
+				return
+			}
+
+			require.Len(t, roomService.created, 1)
+			require.Equal(t, tc.created, roomService.created[0])
+			var res warmRoomResponse
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&res))
+			require.Equal(t, tc.expected, &res)
+		})
+	}
+}
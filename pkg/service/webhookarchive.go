@@ -0,0 +1,89 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/livekit/livekit-server/pkg/webhooks"
+)
+
+const webhookArchivePath = "/webhooks/archive"
+
+type listArchivedWebhooksResponse struct {
+	Date        string   `json:"date"`
+	DeliveryIDs []string `json:"delivery_ids"`
+}
+
+// WebhookArchiveService retrieves archived webhook payloads. GET /webhooks/archive?date=YYYY-MM-DD lists the
+// deliveries of events created that day (UTC), GET /webhooks/archive/<delivery id>?date=YYYY-MM-DD returns one
+type WebhookArchiveService struct {
+	archive *webhooks.Archive
+}
+
+func NewWebhookArchiveService(archive *webhooks.Archive) *WebhookArchiveService {
+	return &WebhookArchiveService{
+		archive: archive,
+	}
+}
+
+func (s *WebhookArchiveService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.archive == nil {
+		handleError(w, http.StatusNotFound, ErrArchiveDisabled)
+		return
+	}
+	if err := EnsureListPermission(r.Context()); err != nil {
+		handleError(w, http.StatusUnauthorized, err)
+		return
+	}
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	date := r.URL.Query().Get("date")
+	day, err := time.Parse(webhooks.ArchiveDayLayout, date)
+	if err != nil {
+		handleError(w, http.StatusBadRequest, ErrInvalidArchiveDay, "date", date)
+		return
+	}
+
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, webhookArchivePath), "/")
+	if id == "" {
+		ids, err := s.archive.List(r.Context(), day)
+		if err != nil {
+			handleError(w, http.StatusInternalServerError, err, "date", date)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(&listArchivedWebhooksResponse{Date: date, DeliveryIDs: ids})
+		return
+	}
+
+	ad, err := s.archive.Load(r.Context(), day, id)
+	if errors.Is(err, webhooks.ErrArchivedDeliveryNotFound) {
+		handleError(w, http.StatusNotFound, err, "deliveryID", id)
+		return
+	} else if err != nil {
+		handleError(w, http.StatusInternalServerError, err, "deliveryID", id)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(ad)
+}
@@ -0,0 +1,137 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/livekit/livekit-server/pkg/webhooks"
+)
+
+const webhookDeadLettersPath = "/webhooks/dead_letters"
+
+type replayDeadLettersRequest struct {
+	ID string `json:"id,omitempty"`
+	// replay every dead letter
+	All bool `json:"all,omitempty"`
+}
+
+type replayedDeadLetter struct {
+	*webhooks.Delivery
+	Delivered bool `json:"delivered"`
+}
+
+type listDeadLettersResponse struct {
+	DeadLetters []*webhooks.Delivery `json:"dead_letters"`
+}
+
+type replayDeadLettersResponse struct {
+	Replayed []*replayedDeadLetter `json:"replayed"`
+}
+
+// WebhookDeadLetterService lists webhook events that could not be delivered, replays them and discards them
+type WebhookDeadLetterService struct {
+	deadLetters *webhooks.DeadLetters
+}
+
+func NewWebhookDeadLetterService(deadLetters *webhooks.DeadLetters) *WebhookDeadLetterService {
+	return &WebhookDeadLetterService{
+		deadLetters: deadLetters,
+	}
+}
+
+func (s *WebhookDeadLetterService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.deadLetters == nil {
+		handleError(w, http.StatusNotFound, ErrDeadLettersDisabled)
+		return
+	}
+	if err := EnsureCreatePermission(r.Context()); err != nil {
+		handleError(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, webhookDeadLettersPath), "/")
+	switch {
+	case r.Method == http.MethodGet && path == "":
+		deliveries, err := s.deadLetters.List(r.Context())
+		if err != nil {
+			handleError(w, http.StatusInternalServerError, err)
+			return
+		}
+		s.writeJSON(w, &listDeadLettersResponse{DeadLetters: deliveries})
+	case r.Method == http.MethodPost && path == "replay":
+		s.replay(w, r)
+	case r.Method == http.MethodDelete && path == "":
+		id := r.URL.Query().Get("id")
+		if err := s.deadLetters.Discard(r.Context(), id); errors.Is(err, webhooks.ErrDeadLetterNotFound) {
+			handleError(w, http.StatusNotFound, err, "deliveryID", id)
+			return
+		} else if err != nil {
+			handleError(w, http.StatusInternalServerError, err, "deliveryID", id)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (s *WebhookDeadLetterService) replay(w http.ResponseWriter, r *http.Request) {
+	var req replayDeadLettersRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		handleError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	ids := []string{req.ID}
+	if req.All {
+		deliveries, err := s.deadLetters.List(r.Context())
+		if err != nil {
+			handleError(w, http.StatusInternalServerError, err)
+			return
+		}
+		ids = ids[:0]
+		for _, d := range deliveries {
+			ids = append(ids, d.ID)
+		}
+	}
+
+	res := &replayDeadLettersResponse{Replayed: []*replayedDeadLetter{}}
+	for _, id := range ids {
+		d, err := s.deadLetters.Replay(r.Context(), id)
+		if errors.Is(err, webhooks.ErrDeadLetterNotFound) {
+			if !req.All {
+				handleError(w, http.StatusNotFound, err, "deliveryID", id)
+				return
+			}
+			// replayed by another request in the meantime
+			continue
+		}
+		if d == nil {
+			handleError(w, http.StatusInternalServerError, err, "deliveryID", id)
+			return
+		}
+		res.Replayed = append(res.Replayed, &replayedDeadLetter{Delivery: d, Delivered: err == nil})
+	}
+	s.writeJSON(w, res)
+}
+
+func (s *WebhookDeadLetterService) writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
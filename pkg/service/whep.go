@@ -0,0 +1,456 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pion/sdp/v3"
+	"github.com/pion/webrtc/v3"
+	"go.uber.org/atomic"
+
+	"github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/logger"
+
+	"github.com/livekit/livekit-server/pkg/routing"
+	"github.com/livekit/livekit-server/pkg/rtc"
+	"github.com/livekit/livekit-server/pkg/telemetry/prometheus"
+	"github.com/livekit/livekit-server/pkg/utils"
+)
+
+const (
+	whepPath = "/whep"
+
+	// the subscriber connection is not primary below protocol 3, so it is offered once tracks are
+	// subscribed rather than right after joining, and the first offer carries them
+	whepProtocol = 2
+	// players not answering within this time after the offer are disconnected
+	whepAnswerTimeout = 30 * time.Second
+)
+
+var errWHEPAnswered = errors.New("session is already answered")
+
+// WHEPService lets simple players watch a room with WHEP. POST /whep, or /whep/<room> when the token
+// isn't bound to a room, joins a subscribe-only participant as the token identity. The server offers,
+// so the request has no body: the response carries the offer and the resource URL
+// /whep/<room>/<identity>, and the player sends its answer with PATCH on that URL, to the same node.
+// DELETE tears the session down.
+//
+// Tracks published when the player joins are offered, ?participant=<identity> and ?track=<sid> select
+// some of them. Tracks published later are not delivered, players reconnect to pick them up. A
+// composited view is played by selecting the participant publishing a room composite.
+type WHEPService struct {
+	rtcService *RTCService
+
+	mu       sync.Mutex
+	sessions map[string]*whepSession
+}
+
+func NewWHEPService(rtcService *RTCService) *WHEPService {
+	return &WHEPService{
+		rtcService: rtcService,
+		sessions:   make(map[string]*whepSession),
+	}
+}
+
+func (s *WHEPService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	segments := sdpResourceSegments(r.URL.Path, whepPath)
+
+	switch {
+	case r.Method == http.MethodPost && len(segments) <= 1:
+		roomName := livekit.RoomName("")
+		if len(segments) == 1 {
+			roomName = livekit.RoomName(segments[0])
+		}
+		s.play(w, r, roomName)
+	case r.Method == http.MethodPatch && len(segments) == 2:
+		s.answer(w, r, livekit.RoomName(segments[0]), livekit.ParticipantIdentity(segments[1]))
+	case r.Method == http.MethodDelete && len(segments) == 2:
+		teardownSDPSession(w, r, s.rtcService.router, livekit.RoomName(segments[0]), livekit.ParticipantIdentity(segments[1]), func() bool {
+			return s.closeSession(whepResource(livekit.RoomName(segments[0]), livekit.ParticipantIdentity(segments[1])))
+		})
+	case len(segments) > 2:
+		w.WriteHeader(http.StatusNotFound)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *WHEPService) play(w http.ResponseWriter, r *http.Request, roomName livekit.RoomName) {
+	claims := GetGrants(r.Context())
+	if claims == nil || claims.Video == nil || !claims.Video.GetCanSubscribe() {
+		handleError(w, http.StatusUnauthorized, rtc.ErrPermissionDenied)
+		return
+	}
+	onlyName, err := EnsureJoinPermission(r.Context())
+	if err != nil {
+		handleError(w, http.StatusUnauthorized, err)
+		return
+	}
+	if onlyName != "" {
+		roomName = onlyName
+	}
+	if roomName == "" {
+		handleError(w, http.StatusBadRequest, ErrRoomNameRequired)
+		return
+	}
+	if claims.Identity == "" {
+		handleError(w, http.StatusBadRequest, ErrIdentityEmpty)
+		return
+	}
+	if body, _ := io.ReadAll(io.LimitReader(r.Body, 1)); len(body) != 0 {
+		handleError(w, http.StatusNotAcceptable, ErrWHEPClientOffer)
+		return
+	}
+
+	if err = s.rtcService.roomAllocator.ValidateCreateRoom(r.Context(), roomName); err != nil {
+		if errors.Is(err, ErrRoomNotFound) || errors.Is(err, ErrRoomNotCreated) {
+			handleError(w, http.StatusNotFound, err)
+		} else {
+			handleError(w, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	// players only watch what they selected
+	claims.Video.SetCanPublish(false)
+	claims.Video.SetCanPublishData(false)
+	ci := s.rtcService.ParseClientInfo(r)
+	ci.Protocol = whepProtocol
+	pi := routing.ParticipantInit{
+		Identity:      livekit.ParticipantIdentity(claims.Identity),
+		Name:          livekit.ParticipantName(claims.Name),
+		AutoSubscribe: false,
+		Client:        ci,
+		Grants:        claims,
+		Entitlements:  GetEntitlements(r.Context()),
+	}
+	resource := whepResource(roomName, pi.Identity)
+	pLogger := rtc.LoggerWithParticipant(
+		rtc.LoggerWithRoom(logger.GetLogger(), roomName, ""),
+		pi.Identity,
+		"",
+		false,
+	)
+
+	// a reconnecting player replaces its previous session
+	s.closeSession(resource)
+
+	// the session outlives the request
+	ctx := utils.ContextWithLogger(context.Background(), pLogger)
+	joinStart := time.Now()
+	cr, initialResponse, err := s.rtcService.startConnection(ctx, roomName, pi, sdpSignalTimeout)
+	if err == nil && initialResponse.GetJoin() == nil {
+		cr.RequestSink.Close()
+		cr.ResponseSource.Close()
+		err = fmt.Errorf("unexpected initial response: %T", initialResponse.GetMessage())
+	}
+	if err != nil {
+		prometheus.IncrementParticipantJoinFail(r.Context(), 1)
+		handleError(w, http.StatusInternalServerError, err, "room", roomName, "participant", pi.Identity)
+		return
+	}
+	prometheus.IncrementParticipantJoin(1)
+	prometheus.RecordParticipantJoinLatency(r.Context(), time.Since(joinStart))
+
+	session := &whepSession{
+		sdpSession: &sdpSession{
+			kind:     "WHEP",
+			cr:       cr,
+			resource: resource,
+			logger: rtc.LoggerWithParticipant(
+				rtc.LoggerWithRoom(logger.GetLogger(), roomName, livekit.RoomID(cr.Room.Sid)),
+				pi.Identity,
+				livekit.ParticipantID(initialResponse.GetJoin().GetParticipant().GetSid()),
+				false,
+			),
+		},
+		selection: newWHEPSelection(r.URL.Query(), initialResponse.GetJoin()),
+	}
+	offer, err := session.negotiate()
+	if err != nil {
+		session.leave()
+		if errors.Is(err, ErrWHEPNoTracks) {
+			handleError(w, http.StatusNotFound, err)
+		} else {
+			handleError(w, http.StatusInternalServerError, err, "room", roomName, "participant", pi.Identity)
+		}
+		return
+	}
+
+	s.mu.Lock()
+	s.sessions[resource] = session
+	s.mu.Unlock()
+	go func() {
+		session.run()
+		s.mu.Lock()
+		if s.sessions[resource] == session {
+			delete(s.sessions, resource)
+		}
+		s.mu.Unlock()
+	}()
+
+	session.logger.Infow("WHEP session started", "connID", cr.ConnectionID, "tracks", len(session.offered))
+	w.Header().Set("Content-Type", "application/sdp")
+	w.Header().Set("Location", resource)
+	w.Header().Set("Access-Control-Expose-Headers", "Location")
+	w.WriteHeader(http.StatusCreated)
+	_, _ = w.Write([]byte(offer))
+}
+
+// answer completes the negotiation of a session with the answer of the player
+func (s *WHEPService) answer(w http.ResponseWriter, r *http.Request, roomName livekit.RoomName, identity livekit.ParticipantIdentity) {
+	if err := authorizeSDPResource(r, roomName, identity, false); err != nil {
+		handleError(w, http.StatusUnauthorized, err)
+		return
+	}
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), "application/sdp") {
+		// trickle ICE and ICE restarts are not supported
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		return
+	}
+
+	s.mu.Lock()
+	session := s.sessions[whepResource(roomName, identity)]
+	s.mu.Unlock()
+	if session == nil {
+		handleError(w, http.StatusNotFound, ErrParticipantNotFound, "room", roomName, "participant", identity)
+		return
+	}
+
+	answer, err := io.ReadAll(io.LimitReader(r.Body, sdpMaxSize))
+	if err != nil {
+		handleError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err = session.answer(string(answer)); err != nil {
+		if errors.Is(err, errWHEPAnswered) {
+			handleError(w, http.StatusConflict, err)
+		} else {
+			handleError(w, http.StatusInternalServerError, err, "room", roomName, "participant", identity)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *WHEPService) closeSession(resource string) bool {
+	s.mu.Lock()
+	session := s.sessions[resource]
+	delete(s.sessions, resource)
+	s.mu.Unlock()
+
+	if session == nil {
+		return false
+	}
+	session.leave()
+	return true
+}
+
+func whepResource(roomName livekit.RoomName, identity livekit.ParticipantIdentity) string {
+	return sdpResource(whepPath, roomName, identity)
+}
+
+// whepOfferedTracks returns the tracks sent in the sections of the offer, from their msid
+func whepOfferedTracks(offer string) ([]livekit.TrackID, error) {
+	parsed := sdp.SessionDescription{}
+	if err := parsed.Unmarshal([]byte(offer)); err != nil {
+		return nil, err
+	}
+
+	var trackIDs []livekit.TrackID
+	for _, md := range parsed.MediaDescriptions {
+		if md.MediaName.Media != "audio" && md.MediaName.Media != "video" {
+			continue
+		}
+		if _, ok := md.Attribute(sdp.AttrKeyInactive); ok {
+			continue
+		}
+		if msid, ok := md.Attribute(sdp.AttrKeyMsid); ok {
+			if parts := strings.Fields(msid); len(parts) == 2 {
+				trackIDs = append(trackIDs, livekit.TrackID(parts[1]))
+			}
+		}
+	}
+	return trackIDs, nil
+}
+
+// ----------------------------------------------------
+
+// whepSelection holds the tracks a player asked for among those published when it joined, no
+// participant or track selects every track
+type whepSelection struct {
+	identities map[livekit.ParticipantIdentity]bool
+	trackIDs   map[livekit.TrackID]bool
+	owners     map[livekit.TrackID]livekit.ParticipantIdentity
+	published  []livekit.TrackID
+}
+
+func newWHEPSelection(query url.Values, join *livekit.JoinResponse) *whepSelection {
+	sel := &whepSelection{
+		identities: make(map[livekit.ParticipantIdentity]bool),
+		trackIDs:   make(map[livekit.TrackID]bool),
+		owners:     make(map[livekit.TrackID]livekit.ParticipantIdentity),
+	}
+	for _, identity := range query["participant"] {
+		sel.identities[livekit.ParticipantIdentity(identity)] = true
+	}
+	for _, trackID := range query["track"] {
+		sel.trackIDs[livekit.TrackID(trackID)] = true
+	}
+	for _, p := range join.GetOtherParticipants() {
+		for _, t := range p.Tracks {
+			sel.owners[livekit.TrackID(t.Sid)] = livekit.ParticipantIdentity(p.Identity)
+			sel.published = append(sel.published, livekit.TrackID(t.Sid))
+		}
+	}
+	return sel
+}
+
+func (s *whepSelection) includes(trackID livekit.TrackID) bool {
+	if len(s.identities) == 0 && len(s.trackIDs) == 0 {
+		return true
+	}
+	return s.trackIDs[trackID] || s.identities[s.owners[trackID]]
+}
+
+func (s *whepSelection) tracks() []string {
+	var trackIDs []string
+	for _, trackID := range s.published {
+		if s.includes(trackID) {
+			trackIDs = append(trackIDs, string(trackID))
+		}
+	}
+	return trackIDs
+}
+
+// ----------------------------------------------------
+
+type whepSession struct {
+	*sdpSession
+
+	selection *whepSelection
+	// tracks negotiated with the player
+	offered  map[livekit.TrackID]bool
+	answered atomic.Bool
+}
+
+// negotiate subscribes to the selected tracks, then returns the offer of the subscriber connection
+// with the server candidates
+func (s *whepSession) negotiate() (string, error) {
+	selected := s.selection.tracks()
+	if len(selected) == 0 {
+		return "", ErrWHEPNoTracks
+	}
+	if err := s.subscribe(selected, true); err != nil {
+		return "", err
+	}
+
+	timeout := time.NewTimer(sdpSignalTimeout)
+	defer timeout.Stop()
+
+	var offer string
+	var candidates []webrtc.ICECandidateInit
+	var gathered <-chan time.Time
+	for {
+		select {
+		case <-timeout.C:
+			return "", errSDPSessionTimeout
+		case <-gathered:
+			trackIDs, err := whepOfferedTracks(offer)
+			if err != nil {
+				return "", err
+			}
+			s.offered = make(map[livekit.TrackID]bool, len(trackIDs))
+			for _, trackID := range trackIDs {
+				s.offered[trackID] = true
+			}
+			return sdpWithCandidates(offer, candidates)
+		case msg := <-s.cr.ResponseSource.ReadChan():
+			res, err := sdpSessionResponse(msg)
+			if err != nil {
+				return "", err
+			}
+			switch {
+			case res.GetOffer() != nil && offer == "":
+				offer = res.GetOffer().Sdp
+				gathered = time.After(sdpCandidateWait)
+			case res.GetTrickle() != nil && res.GetTrickle().Target == livekit.SignalTarget_SUBSCRIBER:
+				if candidate, err := rtc.FromProtoTrickle(res.GetTrickle()); err == nil {
+					candidates = append(candidates, candidate)
+				}
+			}
+		}
+	}
+}
+
+// answer sends the answer of the player. Selected tracks subscribed too late to be in the offer are
+// unsubscribed, the player cannot renegotiate to receive them.
+func (s *whepSession) answer(answer string) error {
+	if s.answered.Swap(true) {
+		return errWHEPAnswered
+	}
+	if err := s.write(&livekit.SignalRequest{
+		Message: &livekit.SignalRequest_Answer{
+			Answer: &livekit.SessionDescription{Type: "answer", Sdp: answer},
+		},
+	}); err != nil {
+		return err
+	}
+
+	var missed []string
+	for _, trackID := range s.selection.tracks() {
+		if !s.offered[livekit.TrackID(trackID)] {
+			missed = append(missed, trackID)
+		}
+	}
+	if len(missed) == 0 {
+		return nil
+	}
+	s.logger.Infow("selected tracks were not offered", "trackIDs", missed)
+	return s.subscribe(missed, false)
+}
+
+func (s *whepSession) subscribe(trackIDs []string, subscribe bool) error {
+	return s.write(&livekit.SignalRequest{
+		Message: &livekit.SignalRequest_Subscription{
+			Subscription: &livekit.UpdateSubscription{
+				TrackSids: trackIDs,
+				Subscribe: subscribe,
+			},
+		},
+	})
+}
+
+// run disconnects players not answering in time, then drains responses until the participant is closed
+func (s *whepSession) run() {
+	answerTimeout := time.AfterFunc(whepAnswerTimeout, func() {
+		if !s.answered.Load() {
+			s.logger.Infow("WHEP player did not answer")
+			s.leave()
+		}
+	})
+	defer answerTimeout.Stop()
+
+	s.sdpSession.run()
+}
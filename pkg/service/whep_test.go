@@ -0,0 +1,72 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/protocol/livekit"
+)
+
+const whepTestOffer = "v=0\r\n" +
+	"o=- 0 0 IN IP4 127.0.0.1\r\n" +
+	"s=-\r\n" +
+	"t=0 0\r\n" +
+	"a=group:BUNDLE 0 1 2\r\n" +
+	"m=audio 9 UDP/TLS/RTP/SAVPF 111\r\n" +
+	"c=IN IP4 0.0.0.0\r\n" +
+	"a=mid:0\r\n" +
+	"a=sendonly\r\n" +
+	"a=msid:PA_host|TR_mic TR_mic\r\n" +
+	"a=rtpmap:111 opus/48000/2\r\n" +
+	"m=video 9 UDP/TLS/RTP/SAVPF 96\r\n" +
+	"c=IN IP4 0.0.0.0\r\n" +
+	"a=mid:1\r\n" +
+	"a=sendonly\r\n" +
+	"a=msid:PA_host|TR_cam TR_cam\r\n" +
+	"a=rtpmap:96 VP8/90000\r\n" +
+	"m=application 9 UDP/DTLS/SCTP webrtc-datachannel\r\n" +
+	"c=IN IP4 0.0.0.0\r\n" +
+	"a=mid:2\r\n"
+
+func TestWHEPOfferedTracks(t *testing.T) {
+	trackIDs, err := whepOfferedTracks(whepTestOffer)
+	require.NoError(t, err)
+	require.Equal(t, []livekit.TrackID{"TR_mic", "TR_cam"}, trackIDs)
+}
+
+func TestWHEPSelection(t *testing.T) {
+	join := &livekit.JoinResponse{
+		OtherParticipants: []*livekit.ParticipantInfo{
+			{Identity: "host", Tracks: []*livekit.TrackInfo{{Sid: "TR_mic"}, {Sid: "TR_cam"}}},
+			{Identity: "guest", Tracks: []*livekit.TrackInfo{{Sid: "TR_guest"}}},
+		},
+	}
+
+	sel := newWHEPSelection(url.Values{}, join)
+	require.Equal(t, []string{"TR_mic", "TR_cam", "TR_guest"}, sel.tracks())
+
+	sel = newWHEPSelection(url.Values{"participant": {"host"}}, join)
+	require.Equal(t, []string{"TR_mic", "TR_cam"}, sel.tracks())
+
+	sel = newWHEPSelection(url.Values{"participant": {"host"}, "track": {"TR_guest"}}, join)
+	require.Equal(t, []string{"TR_mic", "TR_cam", "TR_guest"}, sel.tracks())
+
+	sel = newWHEPSelection(url.Values{"track": {"TR_missing"}}, join)
+	require.Empty(t, sel.tracks())
+}
@@ -0,0 +1,333 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pion/sdp/v3"
+	"github.com/pion/webrtc/v3"
+
+	"github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/logger"
+
+	"github.com/livekit/livekit-server/pkg/routing"
+	"github.com/livekit/livekit-server/pkg/rtc"
+	"github.com/livekit/livekit-server/pkg/rtc/types"
+	"github.com/livekit/livekit-server/pkg/telemetry/prometheus"
+	"github.com/livekit/livekit-server/pkg/utils"
+)
+
+const whipPath = "/whip"
+
+// WHIPService lets broadcast encoders such as OBS publish into a room with WHIP. POST /whip, or
+// /whip/<room> when the token isn't bound to a room, with an SDP offer joins a publish-only participant
+// as the token identity, and answers with the resource URL /whip/<room>/<identity>. DELETE on that URL
+// tears the session down. Trickle ICE is not supported, candidates are exchanged in the SDP.
+type WHIPService struct {
+	rtcService *RTCService
+
+	mu       sync.Mutex
+	sessions map[string]*sdpSession
+}
+
+func NewWHIPService(rtcService *RTCService) *WHIPService {
+	return &WHIPService{
+		rtcService: rtcService,
+		sessions:   make(map[string]*sdpSession),
+	}
+}
+
+func (s *WHIPService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	segments := sdpResourceSegments(r.URL.Path, whipPath)
+
+	switch {
+	case r.Method == http.MethodPost && len(segments) <= 1:
+		roomName := livekit.RoomName("")
+		if len(segments) == 1 {
+			roomName = livekit.RoomName(segments[0])
+		}
+		s.publish(w, r, roomName)
+	case r.Method == http.MethodDelete && len(segments) == 2:
+		s.teardown(w, r, livekit.RoomName(segments[0]), livekit.ParticipantIdentity(segments[1]))
+	case len(segments) > 2:
+		w.WriteHeader(http.StatusNotFound)
+	default:
+		// includes PATCH, trickle ICE and ICE restarts are not supported
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *WHIPService) publish(w http.ResponseWriter, r *http.Request, roomName livekit.RoomName) {
+	claims := GetGrants(r.Context())
+	if claims == nil || claims.Video == nil || !claims.Video.GetCanPublish() {
+		handleError(w, http.StatusUnauthorized, rtc.ErrPermissionDenied)
+		return
+	}
+	onlyName, err := EnsureJoinPermission(r.Context())
+	if err != nil {
+		handleError(w, http.StatusUnauthorized, err)
+		return
+	}
+	if onlyName != "" {
+		roomName = onlyName
+	}
+	if roomName == "" {
+		handleError(w, http.StatusBadRequest, ErrRoomNameRequired)
+		return
+	}
+	if claims.Identity == "" {
+		handleError(w, http.StatusBadRequest, ErrIdentityEmpty)
+		return
+	}
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), "application/sdp") {
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		return
+	}
+
+	offer, err := io.ReadAll(io.LimitReader(r.Body, sdpMaxSize))
+	if err != nil {
+		handleError(w, http.StatusBadRequest, err)
+		return
+	}
+	tracks, err := whipTracksFromOffer(string(offer))
+	if err != nil {
+		handleError(w, http.StatusBadRequest, err, "room", roomName, "participant", claims.Identity)
+		return
+	}
+
+	if err = s.rtcService.roomAllocator.ValidateCreateRoom(r.Context(), roomName); err != nil {
+		if errors.Is(err, ErrRoomNotFound) || errors.Is(err, ErrRoomNotCreated) {
+			handleError(w, http.StatusNotFound, err)
+		} else {
+			handleError(w, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	// encoders only publish
+	claims.Video.SetCanSubscribe(false)
+	ci := s.rtcService.ParseClientInfo(r)
+	if ci.Protocol == 0 {
+		ci.Protocol = types.CurrentProtocol
+	}
+	pi := routing.ParticipantInit{
+		Identity:      livekit.ParticipantIdentity(claims.Identity),
+		Name:          livekit.ParticipantName(claims.Name),
+		AutoSubscribe: false,
+		Client:        ci,
+		Grants:        claims,
+		Entitlements:  GetEntitlements(r.Context()),
+	}
+	resource := whipResource(roomName, pi.Identity)
+	pLogger := rtc.LoggerWithParticipant(
+		rtc.LoggerWithRoom(logger.GetLogger(), roomName, ""),
+		pi.Identity,
+		"",
+		false,
+	)
+
+	// a republishing encoder replaces its previous session
+	s.closeSession(resource)
+
+	// the session outlives the request
+	ctx := utils.ContextWithLogger(context.Background(), pLogger)
+	joinStart := time.Now()
+	cr, initialResponse, err := s.rtcService.startConnection(ctx, roomName, pi, sdpSignalTimeout)
+	if err == nil && initialResponse.GetJoin() == nil {
+		cr.RequestSink.Close()
+		cr.ResponseSource.Close()
+		err = fmt.Errorf("unexpected initial response: %T", initialResponse.GetMessage())
+	}
+	if err != nil {
+		prometheus.IncrementParticipantJoinFail(r.Context(), 1)
+		handleError(w, http.StatusInternalServerError, err, "room", roomName, "participant", pi.Identity)
+		return
+	}
+	prometheus.IncrementParticipantJoin(1)
+	prometheus.RecordParticipantJoinLatency(r.Context(), time.Since(joinStart))
+
+	session := &sdpSession{
+		kind:     "WHIP",
+		cr:       cr,
+		resource: resource,
+		logger: rtc.LoggerWithParticipant(
+			rtc.LoggerWithRoom(logger.GetLogger(), roomName, livekit.RoomID(cr.Room.Sid)),
+			pi.Identity,
+			livekit.ParticipantID(initialResponse.GetJoin().GetParticipant().GetSid()),
+			false,
+		),
+	}
+	answer, err := session.negotiate(string(offer), tracks)
+	if err != nil {
+		session.close()
+		handleError(w, http.StatusInternalServerError, err, "room", roomName, "participant", pi.Identity)
+		return
+	}
+
+	s.mu.Lock()
+	s.sessions[resource] = session
+	s.mu.Unlock()
+	go func() {
+		session.run()
+		s.mu.Lock()
+		if s.sessions[resource] == session {
+			delete(s.sessions, resource)
+		}
+		s.mu.Unlock()
+	}()
+
+	session.logger.Infow("WHIP session started", "connID", cr.ConnectionID, "tracks", len(tracks))
+	w.Header().Set("Content-Type", "application/sdp")
+	w.Header().Set("Location", resource)
+	w.Header().Set("Access-Control-Expose-Headers", "Location")
+	w.WriteHeader(http.StatusCreated)
+	_, _ = w.Write([]byte(answer))
+}
+
+// teardown ends the session of an encoder, also when it was started on another node
+func (s *WHIPService) teardown(w http.ResponseWriter, r *http.Request, roomName livekit.RoomName, identity livekit.ParticipantIdentity) {
+	teardownSDPSession(w, r, s.rtcService.router, roomName, identity, func() bool {
+		return s.closeSession(whipResource(roomName, identity))
+	})
+}
+
+func (s *WHIPService) closeSession(resource string) bool {
+	s.mu.Lock()
+	session := s.sessions[resource]
+	delete(s.sessions, resource)
+	s.mu.Unlock()
+
+	if session == nil {
+		return false
+	}
+	session.leave()
+	return true
+}
+
+func whipResource(roomName livekit.RoomName, identity livekit.ParticipantIdentity) string {
+	return sdpResource(whipPath, roomName, identity)
+}
+
+// whipTracksFromOffer announces every sent audio and video section of the offer as a track
+func whipTracksFromOffer(offer string) ([]*livekit.AddTrackRequest, error) {
+	parsed := sdp.SessionDescription{}
+	if err := parsed.Unmarshal([]byte(offer)); err != nil {
+		return nil, err
+	}
+
+	var tracks []*livekit.AddTrackRequest
+	for i, md := range parsed.MediaDescriptions {
+		req := &livekit.AddTrackRequest{
+			Name: md.MediaName.Media,
+		}
+		switch md.MediaName.Media {
+		case "audio":
+			req.Type = livekit.TrackType_AUDIO
+			req.Source = livekit.TrackSource_MICROPHONE
+		case "video":
+			req.Type = livekit.TrackType_VIDEO
+			req.Source = livekit.TrackSource_CAMERA
+		default:
+			continue
+		}
+		if _, ok := md.Attribute(sdp.AttrKeyRecvOnly); ok {
+			continue
+		}
+		if _, ok := md.Attribute(sdp.AttrKeyInactive); ok {
+			continue
+		}
+
+		if msid, ok := md.Attribute(sdp.AttrKeyMsid); ok {
+			if parts := strings.Fields(msid); len(parts) == 2 {
+				req.Cid = parts[1]
+			}
+		}
+		if req.Cid == "" {
+			req.Cid = fmt.Sprintf("whip-%s-%d", md.MediaName.Media, i)
+		}
+		tracks = append(tracks, req)
+	}
+	if len(tracks) == 0 {
+		return nil, ErrWHIPNoMedia
+	}
+	return tracks, nil
+}
+
+// ----------------------------------------------------
+
+// negotiate announces the tracks, then exchanges the offer for an answer with the server candidates
+func (s *sdpSession) negotiate(offer string, tracks []*livekit.AddTrackRequest) (string, error) {
+	timeout := time.NewTimer(sdpSignalTimeout)
+	defer timeout.Stop()
+
+	for _, req := range tracks {
+		if err := s.write(&livekit.SignalRequest{
+			Message: &livekit.SignalRequest_AddTrack{AddTrack: req},
+		}); err != nil {
+			return "", err
+		}
+	}
+	for published := 0; published < len(tracks); {
+		res, err := s.read(timeout.C)
+		if err != nil {
+			return "", err
+		}
+		if res.GetTrackPublished() != nil {
+			published++
+		}
+	}
+
+	if err := s.write(&livekit.SignalRequest{
+		Message: &livekit.SignalRequest_Offer{
+			Offer: &livekit.SessionDescription{Type: "offer", Sdp: offer},
+		},
+	}); err != nil {
+		return "", err
+	}
+
+	var answer string
+	var candidates []webrtc.ICECandidateInit
+	var gathered <-chan time.Time
+	for {
+		select {
+		case <-timeout.C:
+			return "", errSDPSessionTimeout
+		case <-gathered:
+			return sdpWithCandidates(answer, candidates)
+		case msg := <-s.cr.ResponseSource.ReadChan():
+			res, err := sdpSessionResponse(msg)
+			if err != nil {
+				return "", err
+			}
+			switch {
+			case res.GetAnswer() != nil:
+				answer = res.GetAnswer().Sdp
+				gathered = time.After(sdpCandidateWait)
+			case res.GetTrickle() != nil && res.GetTrickle().Target == livekit.SignalTarget_PUBLISHER:
+				if candidate, err := rtc.FromProtoTrickle(res.GetTrickle()); err == nil {
+					candidates = append(candidates, candidate)
+				}
+			}
+		}
+	}
+}
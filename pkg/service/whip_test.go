@@ -0,0 +1,77 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/protocol/livekit"
+)
+
+const whipTestOffer = "v=0\r\n" +
+	"o=- 0 0 IN IP4 127.0.0.1\r\n" +
+	"s=-\r\n" +
+	"t=0 0\r\n" +
+	"a=group:BUNDLE 0 1\r\n" +
+	"m=audio 9 UDP/TLS/RTP/SAVPF 111\r\n" +
+	"c=IN IP4 0.0.0.0\r\n" +
+	"a=mid:0\r\n" +
+	"a=sendonly\r\n" +
+	"a=msid:obs audio-track\r\n" +
+	"a=rtpmap:111 opus/48000/2\r\n" +
+	"m=video 9 UDP/TLS/RTP/SAVPF 96\r\n" +
+	"c=IN IP4 0.0.0.0\r\n" +
+	"a=mid:1\r\n" +
+	"a=sendonly\r\n" +
+	"a=rtpmap:96 H264/90000\r\n"
+
+func TestWHIPTracksFromOffer(t *testing.T) {
+	tracks, err := whipTracksFromOffer(whipTestOffer)
+	require.NoError(t, err)
+	require.Len(t, tracks, 2)
+
+	require.Equal(t, livekit.TrackType_AUDIO, tracks[0].Type)
+	require.Equal(t, livekit.TrackSource_MICROPHONE, tracks[0].Source)
+	require.Equal(t, "audio-track", tracks[0].Cid)
+
+	require.Equal(t, livekit.TrackType_VIDEO, tracks[1].Type)
+	require.Equal(t, livekit.TrackSource_CAMERA, tracks[1].Source)
+	require.Equal(t, "whip-video-1", tracks[1].Cid)
+
+	recvOnly := strings.ReplaceAll(whipTestOffer, "a=sendonly", "a=recvonly")
+	_, err = whipTracksFromOffer(recvOnly)
+	require.ErrorIs(t, err, ErrWHIPNoMedia)
+}
+
+func TestSDPWithCandidates(t *testing.T) {
+	videoIndex := uint16(1)
+	answer, err := sdpWithCandidates(whipTestOffer, []webrtc.ICECandidateInit{
+		{Candidate: "candidate:1 1 udp 2130706431 10.0.0.1 7882 typ host"},
+		{Candidate: "candidate:2 1 udp 2130706431 10.0.0.2 7882 typ host", SDPMLineIndex: &videoIndex},
+		{Candidate: "candidate:1 1 udp 2130706431 10.0.0.1 7882 typ host"},
+	})
+	require.NoError(t, err)
+
+	sections := strings.Split(answer, "m=")
+	require.Len(t, sections, 3)
+	require.Equal(t, 1, strings.Count(sections[1], "a=candidate:1 1 udp 2130706431 10.0.0.1 7882 typ host"))
+	require.Contains(t, sections[2], "a=candidate:2 1 udp 2130706431 10.0.0.2 7882 typ host")
+	require.Contains(t, sections[1], "a=end-of-candidates")
+	require.Contains(t, sections[2], "a=end-of-candidates")
+}
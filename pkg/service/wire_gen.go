@@ -8,22 +8,30 @@ package service
 
 import (
 	"fmt"
+	"github.com/livekit/livekit-server/pkg/bridge"
 	"github.com/livekit/livekit-server/pkg/clientconfiguration"
 	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/hls"
+	"github.com/livekit/livekit-server/pkg/recording"
 	"github.com/livekit/livekit-server/pkg/routing"
+	"github.com/livekit/livekit-server/pkg/storage"
 	"github.com/livekit/livekit-server/pkg/telemetry"
+	"github.com/livekit/livekit-server/pkg/webhooks"
 	"github.com/livekit/protocol/auth"
 	"github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/logger"
 	redis2 "github.com/livekit/protocol/redis"
 	"github.com/livekit/protocol/rpc"
 	"github.com/livekit/protocol/utils"
 	"github.com/livekit/protocol/webhook"
 	"github.com/livekit/psrpc"
+	"github.com/nats-io/nats.go"
 	"github.com/pion/turn/v2"
 	"github.com/pkg/errors"
 	"github.com/redis/go-redis/v9"
 	"gopkg.in/yaml.v3"
 	"os"
+	"strings"
 )
 
 import (
@@ -39,15 +47,25 @@ func InitializeServer(conf *config.Config, currentNode routing.LocalNode) (*Live
 	if err != nil {
 		return nil, err
 	}
+	conn, err := createNATSConn(conf)
+	if err != nil {
+		return nil, err
+	}
 	nodeID := getNodeID(currentNode)
-	messageBus := getMessageBus(universalClient)
+	messageBus := getMessageBus(universalClient, conn)
 	signalRelayConfig := getSignalRelayConfig(conf)
 	signalClient, err := routing.NewSignalClient(nodeID, messageBus, signalRelayConfig)
 	if err != nil {
 		return nil, err
 	}
-	router := routing.CreateRouter(conf, universalClient, currentNode, signalClient)
-	objectStore := createStore(universalClient)
+	router, err := routing.CreateRouter(conf, universalClient, conn, currentNode, signalClient)
+	if err != nil {
+		return nil, err
+	}
+	objectStore, err := createStore(conf, universalClient, conn)
+	if err != nil {
+		return nil, err
+	}
 	roomAllocator, err := NewRoomAllocator(conf, router, objectStore)
 	if err != nil {
 		return nil, err
@@ -61,13 +79,23 @@ func InitializeServer(conf *config.Config, currentNode routing.LocalNode) (*Live
 	if err != nil {
 		return nil, err
 	}
-	queuedNotifier, err := createWebhookNotifier(conf, keyProvider)
+	storageStorage, err := storage.NewStorage(conf)
+	if err != nil {
+		return nil, err
+	}
+	archive, err := createWebhookArchive(conf, storageStorage)
+	if err != nil {
+		return nil, err
+	}
+	deadLetters := createWebhookDeadLetters(conf, keyProvider, objectStore, archive)
+	queuedNotifier, err := createWebhookNotifier(conf, keyProvider, objectStore, deadLetters, archive)
 	if err != nil {
 		return nil, err
 	}
 	analyticsService := telemetry.NewAnalyticsService(conf, currentNode)
 	telemetryService := telemetry.NewTelemetryService(queuedNotifier, analyticsService)
-	rtcEgressLauncher := NewEgressLauncher(egressClient, egressStore, telemetryService)
+	ioWorkerRegistry := NewIOWorkerRegistry(conf, objectStore, telemetryService)
+	rtcEgressLauncher := NewEgressLauncher(egressClient, egressStore, telemetryService, ioWorkerRegistry)
 	roomService, err := NewRoomService(roomConfig, apiConfig, router, roomAllocator, objectStore, rtcEgressLauncher)
 	if err != nil {
 		return nil, err
@@ -79,7 +107,7 @@ func InitializeServer(conf *config.Config, currentNode routing.LocalNode) (*Live
 		return nil, err
 	}
 	ingressStore := getIngressStore(objectStore)
-	ingressService := NewIngressService(ingressConfig, nodeID, messageBus, ingressClient, ingressStore, roomService, telemetryService)
+	ingressService := NewIngressService(ingressConfig, nodeID, messageBus, ingressClient, ingressStore, roomService, telemetryService, ioWorkerRegistry)
 	ioInfoService, err := NewIOInfoService(nodeID, messageBus, egressStore, ingressStore, telemetryService)
 	if err != nil {
 		return nil, err
@@ -95,12 +123,20 @@ func InitializeServer(conf *config.Config, currentNode routing.LocalNode) (*Live
 	if err != nil {
 		return nil, err
 	}
-	authHandler := newTurnAuthHandler(objectStore)
-	server, err := newInProcessTurnServer(conf, authHandler)
+	manager := recording.NewManager(conf, telemetryService, storageStorage)
+	recordingService := NewRecordingService(conf, manager, roomManager)
+	hlsManager := hls.NewManager(conf, storageStorage)
+	hlsService := NewHLSService(conf, hlsManager, roomManager)
+	bridgeManager := bridge.NewManager(conf, keyProvider)
+	bridgeService := NewBridgeService(bridgeManager)
+	turnAllocations := NewTurnAllocations(conf)
+	authHandler := newTurnAuthHandler(conf, objectStore, turnAllocations)
+	server, err := newInProcessTurnServer(conf, authHandler, turnAllocations)
 	if err != nil {
 		return nil, err
 	}
-	livekitServer, err := NewLivekitServer(conf, roomService, egressService, ingressService, ioInfoService, rtcService, keyProvider, router, roomManager, signalServer, server, currentNode)
+	qoSReports := NewQoSReports(conf, storageStorage)
+	livekitServer, err := NewLivekitServer(conf, roomService, egressService, ingressService, ioInfoService, ioWorkerRegistry, recordingService, hlsService, bridgeService, rtcService, keyProvider, queuedNotifier, deadLetters, archive, qoSReports, router, roomManager, signalServer, server, turnAllocations, currentNode)
 	if err != nil {
 		return nil, err
 	}
@@ -112,14 +148,21 @@ func InitializeRouter(conf *config.Config, currentNode routing.LocalNode) (routi
 	if err != nil {
 		return nil, err
 	}
+	conn, err := createNATSConn(conf)
+	if err != nil {
+		return nil, err
+	}
 	nodeID := getNodeID(currentNode)
-	messageBus := getMessageBus(universalClient)
+	messageBus := getMessageBus(universalClient, conn)
 	signalRelayConfig := getSignalRelayConfig(conf)
 	signalClient, err := routing.NewSignalClient(nodeID, messageBus, signalRelayConfig)
 	if err != nil {
 		return nil, err
 	}
-	router := routing.CreateRouter(conf, universalClient, currentNode, signalClient)
+	router, err := routing.CreateRouter(conf, universalClient, conn, currentNode, signalClient)
+	if err != nil {
+		return nil, err
+	}
 	return router, nil
 }
 
@@ -130,6 +173,14 @@ func getNodeID(currentNode routing.LocalNode) livekit.NodeID {
 }
 
 func createKeyProvider(conf *config.Config) (auth.KeyProvider, error) {
+	provider, err := loadKeyProvider(conf)
+	if err != nil {
+		return nil, err
+	}
+	return newReloadableKeyProvider(provider), nil
+}
+
+func loadKeyProvider(conf *config.Config) (auth.KeyProvider, error) {
 
 	if conf.KeyFile != "" {
 		var otherFilter os.FileMode = 0007
@@ -151,24 +202,75 @@ func createKeyProvider(conf *config.Config) (auth.KeyProvider, error) {
 		}
 	}
 
-	if len(conf.Keys) == 0 {
+	// tokens signed by an identity provider do not need API secrets
+	if len(conf.Keys) == 0 && !conf.TokenKeys.Enabled() {
 		return nil, errors.New("one of key-file or keys must be provided in order to support a secure installation")
 	}
 
 	return auth.NewFileBasedKeyProviderFromMap(conf.Keys), nil
 }
 
-func createWebhookNotifier(conf *config.Config, provider auth.KeyProvider) (webhook.QueuedNotifier, error) {
+func createWebhookArchive(conf *config.Config, s *storage.Storage) (*webhooks.Archive, error) {
+	if conf.WebHook.Archive.StorageProfile == "" {
+		return nil, nil
+	}
+	objects, err := s.Objects(conf.WebHook.Archive.StorageProfile)
+	if err != nil {
+		return nil, err
+	}
+	return webhooks.NewArchive(&conf.WebHook, objects), nil
+}
+
+func createWebhookDeadLetters(conf *config.Config, provider auth.KeyProvider, store ObjectStore, archive *webhooks.Archive) *webhooks.DeadLetters {
+	dls, _ := store.(webhooks.DeadLetterStore)
+	return webhooks.NewDeadLetters(&conf.WebHook, dls, provider, archive)
+}
+
+func createWebhookNotifier(
+	conf *config.Config,
+	provider auth.KeyProvider,
+	store ObjectStore,
+	deadLetters *webhooks.DeadLetters,
+	archive *webhooks.Archive,
+) (webhook.QueuedNotifier, error) {
+	notifier, err := loadWebhookNotifier(conf, provider, store, deadLetters, archive)
+	if err != nil {
+		return nil, err
+	}
+	return newReloadableNotifier(notifier, provider, store, deadLetters, archive), nil
+}
+
+func loadWebhookNotifier(
+	conf *config.Config,
+	provider auth.KeyProvider,
+	store ObjectStore,
+	deadLetters *webhooks.DeadLetters,
+	archive *webhooks.Archive,
+) (webhook.QueuedNotifier, error) {
 	wc := conf.WebHook
-	if len(wc.URLs) == 0 {
+	templateURLs := make(map[string][]string)
+	for name := range conf.Room.Templates {
+		if tmpl, err := conf.Room.ResolveTemplate(name); err == nil && len(tmpl.WebhookURLs) != 0 {
+			templateURLs[name] = tmpl.WebhookURLs
+		}
+	}
+	if len(wc.URLs) == 0 && len(templateURLs) == 0 {
 		return nil, nil
 	}
-	secret := provider.GetSecret(wc.APIKey)
-	if secret == "" {
+	if provider.GetSecret(wc.APIKey) == "" {
 		return nil, ErrWebHookMissingAPIKey
 	}
 
-	return webhook.NewDefaultNotifier(wc.APIKey, secret, wc.URLs), nil
+	redactor := telemetry.NewRedactor(&conf.Redaction)
+	var notifier webhook.QueuedNotifier
+	if len(wc.URLs) != 0 {
+		notifier = telemetry.NewRedactingNotifier(webhooks.NewNotifier(&wc, wc.APIKey, wc.URLs, provider, deadLetters, archive), redactor)
+	}
+	templates := make(map[string]webhook.QueuedNotifier, len(templateURLs))
+	for name, urls := range templateURLs {
+		templates[name] = telemetry.NewRedactingNotifier(webhooks.NewNotifier(&wc, wc.APIKey, urls, provider, deadLetters, archive), redactor)
+	}
+	return newRoomTemplateNotifier(notifier, store, templates), nil
 }
 
 func createRedisClient(conf *config.Config) (redis.UniversalClient, error) {
@@ -178,18 +280,49 @@ func createRedisClient(conf *config.Config) (redis.UniversalClient, error) {
 	return redis2.GetRedisClient(&conf.Redis)
 }
 
-func createStore(rc redis.UniversalClient) ObjectStore {
+func createNATSConn(conf *config.Config) (*nats.Conn, error) {
+	if !conf.NATS.IsConfigured() {
+		return nil, nil
+	}
+	opts := []nats.Option{
+		nats.Name("livekit-server"),
+		nats.MaxReconnects(-1),
+	}
+	if conf.NATS.Username != "" {
+		opts = append(opts, nats.UserInfo(conf.NATS.Username, conf.NATS.Password))
+	}
+	if conf.NATS.Token != "" {
+		opts = append(opts, nats.Token(conf.NATS.Token))
+	}
+	if conf.NATS.CredentialsFile != "" {
+		opts = append(opts, nats.UserCredentials(conf.NATS.CredentialsFile))
+	}
+	logger.Infow("connecting to nats", "addr", conf.NATS.URLs)
+	nc, err := nats.Connect(strings.Join(conf.NATS.URLs, ","), opts...)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to connect to nats")
+	}
+	return nc, nil
+}
+
+func createStore(conf *config.Config, rc redis.UniversalClient, nc *nats.Conn) (ObjectStore, error) {
 	if rc != nil {
-		return NewRedisStore(rc)
+		return NewRedisStore(rc), nil
 	}
-	return NewLocalStore()
+	if nc != nil {
+		return NewNATSStore(nc, conf.NATS.Replicas)
+	}
+	return NewLocalStore(), nil
 }
 
-func getMessageBus(rc redis.UniversalClient) psrpc.MessageBus {
-	if rc == nil {
-		return psrpc.NewLocalMessageBus()
+func getMessageBus(rc redis.UniversalClient, nc *nats.Conn) psrpc.MessageBus {
+	if rc != nil {
+		return psrpc.NewRedisMessageBus(rc)
+	}
+	if nc != nil {
+		return psrpc.NewNatsMessageBus(nc)
 	}
-	return psrpc.NewRedisMessageBus(rc)
+	return psrpc.NewLocalMessageBus()
 }
 
 func getEgressStore(s ObjectStore) EgressStore {
@@ -226,6 +359,6 @@ func getSignalRelayConfig(config2 *config.Config) config.SignalRelayConfig {
 	return config2.SignalRelay
 }
 
-func newInProcessTurnServer(conf *config.Config, authHandler turn.AuthHandler) (*turn.Server, error) {
-	return NewTurnServer(conf, authHandler, false)
+func newInProcessTurnServer(conf *config.Config, authHandler turn.AuthHandler, allocations *TurnAllocations) (*turn.Server, error) {
+	return NewTurnServer(conf, authHandler, allocations, false)
 }
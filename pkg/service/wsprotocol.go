@@ -15,6 +15,8 @@
 package service
 
 import (
+	"errors"
+	"net"
 	"sync"
 	"time"
 
@@ -25,30 +27,54 @@ import (
 	"github.com/livekit/protocol/livekit"
 	"github.com/livekit/protocol/logger"
 
+	"github.com/livekit/livekit-server/pkg/config"
 	"github.com/livekit/livekit-server/pkg/rtc/types"
 )
 
 const (
-	pingFrequency = 10 * time.Second
-	pingTimeout   = 2 * time.Second
+	pingTimeout = 2 * time.Second
 )
 
 type WSSignalConnection struct {
-	conn    types.WebsocketClient
-	mu      sync.Mutex
-	useJSON bool
+	conn         types.WebsocketClient
+	mu           sync.Mutex
+	useJSON      bool
+	pingInterval time.Duration
+	pongTimeout  time.Duration
 }
 
-func NewWSSignalConnection(conn types.WebsocketClient) *WSSignalConnection {
+func NewWSSignalConnection(conn types.WebsocketClient, conf *config.HeartbeatConfig) *WSSignalConnection {
 	wsc := &WSSignalConnection{
-		conn:    conn,
-		mu:      sync.Mutex{},
-		useJSON: false,
+		conn:         conn,
+		mu:           sync.Mutex{},
+		useJSON:      false,
+		pingInterval: conf.PingInterval,
+		pongTimeout:  conf.PongTimeout,
+	}
+	if wsc.pongTimeout > 0 {
+		// the connection is considered dead when nothing, not even a pong, arrives within the timeout
+		wsc.extendReadDeadline()
+		conn.SetPongHandler(func(string) error {
+			wsc.extendReadDeadline()
+			return nil
+		})
 	}
 	go wsc.pingWorker()
 	return wsc
 }
 
+func (c *WSSignalConnection) extendReadDeadline() {
+	if c.pongTimeout > 0 {
+		_ = c.conn.SetReadDeadline(time.Now().Add(c.pongTimeout))
+	}
+}
+
+// isTimeout returns true when a read failed because the client stopped responding to pings
+func isTimeout(err error) bool {
+	var ne net.Error
+	return errors.As(err, &ne) && ne.Timeout()
+}
+
 func (c *WSSignalConnection) ReadRequest() (*livekit.SignalRequest, int, error) {
 	for {
 		// handle special messages and pass on the rest
@@ -56,6 +82,7 @@ func (c *WSSignalConnection) ReadRequest() (*livekit.SignalRequest, int, error)
 		if err != nil {
 			return nil, 0, err
 		}
+		c.extendReadDeadline()
 
 		msg := &livekit.SignalRequest{}
 		switch messageType {
@@ -107,7 +134,7 @@ func (c *WSSignalConnection) WriteResponse(msg *livekit.SignalResponse) (int, er
 
 func (c *WSSignalConnection) pingWorker() {
 	for {
-		<-time.After(pingFrequency)
+		<-time.After(c.pingInterval)
 		err := c.conn.WriteControl(websocket.PingMessage, []byte(""), time.Now().Add(pingTimeout))
 		if err != nil {
 			return
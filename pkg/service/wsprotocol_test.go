@@ -0,0 +1,104 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/livekit/protocol/livekit"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/rtc/types/typesfakes"
+)
+
+func TestWSSignalConnectionPongTimeout(t *testing.T) {
+	payload, err := proto.Marshal(&livekit.SignalRequest{Message: &livekit.SignalRequest_Ping{Ping: 1}})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name        string
+		pongTimeout time.Duration
+	}{
+		{name: "disabled"},
+		{name: "enabled", pongTimeout: 20 * time.Second},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			conn := &typesfakes.FakeWebsocketClient{}
+			conn.ReadMessageReturns(websocket.BinaryMessage, payload, nil)
+			wsc := NewWSSignalConnection(conn, &config.HeartbeatConfig{PingInterval: time.Hour, PongTimeout: tc.pongTimeout})
+
+			requireDeadline := func(calls int) {
+				require.Equal(t, calls, conn.SetReadDeadlineCallCount())
+				if calls > 0 {
+					require.WithinDuration(t, time.Now().Add(tc.pongTimeout), conn.SetReadDeadlineArgsForCall(calls-1), time.Second)
+				}
+			}
+
+			if tc.pongTimeout == 0 {
+				require.Zero(t, conn.SetPongHandlerCallCount())
+				_, _, err := wsc.ReadRequest()
+				require.NoError(t, err)
+				requireDeadline(0)
+				return
+			}
+
+			// the deadline is armed right away, then pushed out by pongs and messages
+			requireDeadline(1)
+			require.Equal(t, 1, conn.SetPongHandlerCallCount())
+			require.NoError(t, conn.SetPongHandlerArgsForCall(0)(""))
+			requireDeadline(2)
+
+			req, _, err := wsc.ReadRequest()
+			require.NoError(t, err)
+			require.Equal(t, int64(1), req.GetPing())
+			requireDeadline(3)
+
+			// failed reads don't extend it
+			conn.ReadMessageReturns(0, nil, io.EOF)
+			_, _, err = wsc.ReadRequest()
+			require.ErrorIs(t, err, io.EOF)
+			requireDeadline(3)
+		})
+	}
+}
+
+func TestIsTimeout(t *testing.T) {
+	testCases := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{name: "deadline exceeded", err: os.ErrDeadlineExceeded, expected: true},
+		{name: "wrapped deadline exceeded", err: fmt.Errorf("read: %w", os.ErrDeadlineExceeded), expected: true},
+		{name: "closed", err: &websocket.CloseError{Code: websocket.CloseNormalClosure}},
+		{name: "eof", err: io.EOF},
+		{name: "other", err: errors.New("broken pipe")},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, isTimeout(tc.err))
+		})
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sfu
+
+import (
+	"github.com/pion/webrtc/v3"
+	"go.uber.org/atomic"
+
+	"github.com/livekit/livekit-server/pkg/sfu/buffer"
+	"github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/logger"
+)
+
+// AudioTapSink receives the audio forked by an AudioTap. The payload is only valid for the duration of the call.
+type AudioTapSink interface {
+	WriteAudio(payload []byte, extTimestamp uint64) error
+}
+
+// AudioTap is attached to the receiver of an audio track in place of a down track and forks the payloads
+// published to a sink, without the pacing, munging and RTCP handling of a down track
+type AudioTap struct {
+	trackID      livekit.TrackID
+	subscriberID livekit.ParticipantID
+	sink         AudioTapSink
+	logger       logger.Logger
+
+	closed  atomic.Bool
+	onClose func()
+}
+
+func NewAudioTap(trackID livekit.TrackID, subscriberID livekit.ParticipantID, sink AudioTapSink, logger logger.Logger) *AudioTap {
+	return &AudioTap{
+		trackID:      trackID,
+		subscriberID: subscriberID,
+		sink:         sink,
+		logger:       logger,
+	}
+}
+
+// OnClose is called once when the tap is closed, either by the receiver when the track ends or by its owner
+func (a *AudioTap) OnClose(f func()) {
+	a.onClose = f
+}
+
+func (a *AudioTap) WriteRTP(p *buffer.ExtPacket, _ int32) error {
+	if a.closed.Load() || len(p.Packet.Payload) == 0 {
+		return nil
+	}
+	if err := a.sink.WriteAudio(p.Packet.Payload, p.ExtTimestamp); err != nil {
+		a.logger.Debugw("could not write tapped audio", "error", err)
+		return err
+	}
+	return nil
+}
+
+func (a *AudioTap) Close() {
+	if a.closed.Swap(true) {
+		return
+	}
+	if a.onClose != nil {
+		a.onClose()
+	}
+}
+
+func (a *AudioTap) UpTrackLayersChange()                       {}
+func (a *AudioTap) UpTrackBitrateAvailabilityChange()          {}
+func (a *AudioTap) UpTrackMaxPublishedLayerChange(_ int32)     {}
+func (a *AudioTap) UpTrackMaxTemporalLayerSeenChange(_ int32)  {}
+func (a *AudioTap) UpTrackBitrateReport(_ []int32, _ Bitrates) {}
+func (a *AudioTap) TrackInfoAvailable()                        {}
+func (a *AudioTap) Resync()                                    {}
+func (a *AudioTap) ID() string                                 { return string(a.trackID) }
+func (a *AudioTap) SubscriberID() livekit.ParticipantID        { return a.subscriberID }
+func (a *AudioTap) IsClosed() bool                             { return a.closed.Load() }
+func (a *AudioTap) HandleRTCPSenderReportData(_ webrtc.PayloadType, _ int32, _ *buffer.RTCPSenderReportData) error {
+	return nil
+}
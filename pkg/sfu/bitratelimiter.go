@@ -0,0 +1,156 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sfu
+
+import (
+	"sync"
+	"time"
+
+	"github.com/livekit/protocol/logger"
+
+	"github.com/livekit/livekit-server/pkg/sfu/buffer"
+)
+
+const (
+	bitrateLimiterWindow = time.Second
+	// publishers overshoot briefly while adapting, e.g. on key frames
+	bitrateLimiterTolerance = 1.2
+)
+
+type BitrateLimiterParams struct {
+	MaxBitrate uint64
+	// how long the limit can be exceeded before packets are dropped, 0 disables dropping
+	DropAfter time.Duration
+	Logger    logger.Logger
+	// forwarding resumes on a key frame, OnResume is called for each layer to request one
+	KeyFrameOnResume bool
+	OnResume         func(layer int32)
+}
+
+// BitrateLimiter enforces the maximum bitrate of a published track. Publishers are expected to
+// follow REMB feedback, tracks exceeding the limit regardless are suspended as a last resort,
+// until the publisher is back under the limit.
+type BitrateLimiter struct {
+	params BitrateLimiterParams
+
+	lock        sync.Mutex
+	windowStart time.Time
+	windowBytes uint64
+	bitrate     uint64
+	overSince   time.Time
+	suspended   bool
+	// layers that had packets dropped while suspended
+	paused [buffer.DefaultMaxLayerSpatial + 1]bool
+	// layers waiting for a key frame after resuming
+	resuming [buffer.DefaultMaxLayerSpatial + 1]bool
+}
+
+func NewBitrateLimiter(params BitrateLimiterParams) *BitrateLimiter {
+	return &BitrateLimiter{
+		params: params,
+	}
+}
+
+func (l *BitrateLimiter) MaxBitrate() uint64 {
+	return l.params.MaxBitrate
+}
+
+// Bitrate returns the bitrate received over the last complete window
+func (l *BitrateLimiter) Bitrate() uint64 {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.bitrate
+}
+
+func (l *BitrateLimiter) IsSuspended() bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.suspended
+}
+
+// Admit accounts for a received packet and returns whether it should be forwarded
+func (l *BitrateLimiter) Admit(pkt *buffer.ExtPacket, layer int32, now time.Time) bool {
+	l.lock.Lock()
+	if l.windowStart.IsZero() {
+		l.windowStart = now
+	}
+	l.windowBytes += uint64(len(pkt.RawPacket))
+
+	var resumed []int32
+	if elapsed := now.Sub(l.windowStart); elapsed >= bitrateLimiterWindow {
+		l.bitrate = l.windowBytes * 8 * uint64(time.Second) / uint64(elapsed)
+		l.windowStart = now
+		l.windowBytes = 0
+		resumed = l.updateLocked(now)
+	}
+
+	forward := true
+	switch {
+	case l.suspended:
+		forward = false
+		if layer >= 0 && int(layer) < len(l.paused) {
+			l.paused[layer] = true
+		}
+	case layer >= 0 && int(layer) < len(l.resuming) && l.resuming[layer]:
+		if pkt.KeyFrame {
+			l.resuming[layer] = false
+		} else {
+			forward = false
+		}
+	}
+	l.lock.Unlock()
+
+	if l.params.OnResume != nil {
+		for _, layer := range resumed {
+			l.params.OnResume(layer)
+		}
+	}
+	return forward
+}
+
+// updateLocked returns the layers to request a key frame for when the track resumes
+func (l *BitrateLimiter) updateLocked(now time.Time) []int32 {
+	if float64(l.bitrate) <= float64(l.params.MaxBitrate)*bitrateLimiterTolerance {
+		l.overSince = time.Time{}
+		if !l.suspended {
+			return nil
+		}
+		l.params.Logger.Infow("publisher back under bitrate limit, resuming track", "bitrate", l.bitrate, "maxBitrate", l.params.MaxBitrate)
+		l.suspended = false
+		paused := l.paused
+		l.paused = [buffer.DefaultMaxLayerSpatial + 1]bool{}
+		if !l.params.KeyFrameOnResume {
+			return nil
+		}
+		var resumed []int32
+		for layer, wasPaused := range paused {
+			if wasPaused {
+				l.resuming[layer] = true
+				resumed = append(resumed, int32(layer))
+			}
+		}
+		return resumed
+	}
+
+	if l.overSince.IsZero() {
+		l.overSince = now
+		l.params.Logger.Debugw("publisher exceeding bitrate limit", "bitrate", l.bitrate, "maxBitrate", l.params.MaxBitrate)
+	}
+	if !l.suspended && l.params.DropAfter > 0 && now.Sub(l.overSince) >= l.params.DropAfter {
+		l.params.Logger.Warnw("publisher ignoring bitrate limit, suspending track", nil, "bitrate", l.bitrate, "maxBitrate", l.params.MaxBitrate, "exceededFor", now.Sub(l.overSince))
+		l.suspended = true
+	}
+	return nil
+}
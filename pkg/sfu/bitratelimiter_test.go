@@ -0,0 +1,102 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sfu
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/protocol/logger"
+
+	"github.com/livekit/livekit-server/pkg/sfu/buffer"
+)
+
+// sendAtBitrate feeds a second of 1000 byte packets at the given bitrate, returning how many were admitted
+func sendAtBitrate(l *BitrateLimiter, start time.Time, bitrate int, keyFrame bool) (time.Time, int) {
+	pkt := &buffer.ExtPacket{RawPacket: make([]byte, 1000), KeyFrame: keyFrame}
+	numPackets := bitrate / 8000
+	admitted := 0
+	now := start
+	for i := 0; i < numPackets; i++ {
+		now = start.Add(time.Duration(i+1) * time.Second / time.Duration(numPackets))
+		if l.Admit(pkt, 0, now) {
+			admitted++
+		}
+	}
+	return now, admitted
+}
+
+func TestBitrateLimiter(t *testing.T) {
+	t.Run("forwards while under the limit", func(t *testing.T) {
+		l := NewBitrateLimiter(BitrateLimiterParams{
+			MaxBitrate: 1_000_000,
+			DropAfter:  time.Second,
+			Logger:     logger.GetLogger(),
+		})
+		now := time.Now()
+		for i := 0; i < 5; i++ {
+			var admitted int
+			now, admitted = sendAtBitrate(l, now, 800_000, false)
+			require.Equal(t, 100, admitted)
+		}
+		require.False(t, l.IsSuspended())
+	})
+
+	t.Run("suspends after the limit is exceeded for drop after, resuming on a key frame", func(t *testing.T) {
+		var resumed []int32
+		l := NewBitrateLimiter(BitrateLimiterParams{
+			MaxBitrate:       1_000_000,
+			DropAfter:        2 * time.Second,
+			Logger:           logger.GetLogger(),
+			KeyFrameOnResume: true,
+			OnResume: func(layer int32) {
+				resumed = append(resumed, layer)
+			},
+		})
+		now := time.Now()
+		for i := 0; i < 4; i++ {
+			now, _ = sendAtBitrate(l, now, 2_000_000, false)
+		}
+		require.True(t, l.IsSuspended())
+
+		now, admitted := sendAtBitrate(l, now, 2_000_000, false)
+		require.Zero(t, admitted)
+
+		// back under the limit once a window is complete, waiting for a key frame
+		now, _ = sendAtBitrate(l, now, 800_000, false)
+		now, admitted = sendAtBitrate(l, now, 800_000, false)
+		require.False(t, l.IsSuspended())
+		require.Zero(t, admitted)
+		// only the layer that was paused asks for a key frame
+		require.Equal(t, []int32{0}, resumed)
+		require.True(t, l.Admit(&buffer.ExtPacket{RawPacket: make([]byte, 1000), KeyFrame: true}, 0, now))
+		require.True(t, l.Admit(&buffer.ExtPacket{RawPacket: make([]byte, 1000)}, 0, now))
+	})
+
+	t.Run("never drops without drop after", func(t *testing.T) {
+		l := NewBitrateLimiter(BitrateLimiterParams{
+			MaxBitrate: 1_000_000,
+			Logger:     logger.GetLogger(),
+		})
+		now := time.Now()
+		for i := 0; i < 5; i++ {
+			var admitted int
+			now, admitted = sendAtBitrate(l, now, 3_000_000, false)
+			require.Equal(t, 375, admitted)
+		}
+	})
+}
@@ -30,12 +30,13 @@ import (
 	"go.uber.org/atomic"
 
 	"github.com/livekit/livekit-server/pkg/sfu/audio"
+	"github.com/livekit/livekit-server/pkg/sfu/impairment"
+	"github.com/livekit/livekit-server/pkg/sfu/twcc"
 	"github.com/livekit/livekit-server/pkg/sfu/utils"
 	sutils "github.com/livekit/livekit-server/pkg/utils"
 	"github.com/livekit/mediatransportutil"
 	"github.com/livekit/mediatransportutil/pkg/bucket"
 	"github.com/livekit/mediatransportutil/pkg/nack"
-	"github.com/livekit/mediatransportutil/pkg/twcc"
 	"github.com/livekit/protocol/livekit"
 	"github.com/livekit/protocol/logger"
 
@@ -117,9 +118,15 @@ type Buffer struct {
 	ddExt    uint8
 	ddParser *DependencyDescriptorParser
 
+	// layer and OBU type of the last AV1 packet, for packets continuing its OBU
+	av1Layer   VideoLayer
+	av1OBUType uint8
+
 	paused              bool
 	frameRateCalculator [DefaultMaxLayerSpatial + 1]FrameRateCalculator
 	frameRateCalculated bool
+
+	impairment *impairment.Impairment
 }
 
 // NewBuffer constructs a new Buffer
@@ -161,6 +168,15 @@ func (b *Buffer) SetTWCC(twcc *twcc.Responder) {
 	b.twcc = twcc
 }
 
+// SetImpairment drops and delays incoming packets before they are processed,
+// emulating a lossy uplink from the publisher.
+func (b *Buffer) SetImpairment(imp *impairment.Impairment) {
+	b.Lock()
+	defer b.Unlock()
+
+	b.impairment = imp
+}
+
 func (b *Buffer) SetAudioLevelParams(audioLevelParams audio.AudioLevelParams) {
 	b.Lock()
 	defer b.Unlock()
@@ -264,6 +280,28 @@ func (b *Buffer) Bind(params webrtc.RTPParameters, codec webrtc.RTPCodecCapabili
 
 // Write adds an RTP Packet, out of order, new packet may be arrived later
 func (b *Buffer) Write(pkt []byte) (n int, err error) {
+	b.RLock()
+	imp := b.impairment
+	b.RUnlock()
+	if imp != nil {
+		drop, delay := imp.Apply()
+		if drop {
+			return
+		}
+		if delay > 0 {
+			packet := make([]byte, len(pkt))
+			copy(packet, pkt)
+			time.AfterFunc(delay, func() {
+				_, _ = b.write(packet)
+			})
+			return
+		}
+	}
+
+	return b.write(pkt)
+}
+
+func (b *Buffer) write(pkt []byte) (n int, err error) {
 	b.Lock()
 	defer b.Unlock()
 
@@ -642,7 +680,32 @@ func (b *Buffer) getExtPacket(rtpPacket *rtp.Packet, arrivalTime time.Time, flow
 		ep.KeyFrame = IsVP9KeyFrame(rtpPacket.Payload)
 	case "video/h264":
 		ep.KeyFrame = IsH264KeyFrame(rtpPacket.Payload)
+	case "video/h265":
+		ep.KeyFrame = IsH265KeyFrame(rtpPacket.Payload)
 	case "video/av1":
+		if ep.DependencyDescriptor == nil {
+			var av1Packet AV1
+			if err := av1Packet.Unmarshal(rtpPacket.Payload); err != nil {
+				b.logger.Warnw("could not unmarshal AV1 packet", err)
+				return nil
+			}
+			switch {
+			case av1Packet.HasLayer:
+				b.av1Layer = VideoLayer{
+					Spatial:  int32(av1Packet.SID),
+					Temporal: int32(av1Packet.TID),
+				}
+			case !av1Packet.Z:
+				// OBUs without extension, single layer stream
+				b.av1Layer = VideoLayer{}
+			}
+			if av1Packet.LastOBUType == 0 {
+				av1Packet.LastOBUType = b.av1OBUType
+			}
+			b.av1OBUType = av1Packet.LastOBUType
+			ep.VideoLayer = b.av1Layer
+			ep.Payload = av1Packet
+		}
 		ep.KeyFrame = IsAV1KeyFrame(rtpPacket.Payload)
 	}
 
@@ -70,6 +70,10 @@ type VP8 struct {
 
 	// IsKeyFrame is a helper to detect if current packet is a keyframe
 	IsKeyFrame bool
+
+	// frame dimensions, only available on the first packet of a keyframe
+	Width  uint16
+	Height uint16
 }
 
 // Unmarshal parses the passed byte slice and stores the result in the VP8 this method is called upon
@@ -158,6 +162,12 @@ func (v *VP8) Unmarshal(payload []byte) error {
 		v.IsKeyFrame = payload[idx]&0x01 == 0 && v.S
 	}
 	v.HeaderSize = idx
+
+	// keyframe header: 3 byte frame tag, 3 byte start code, then 14 bit width and height (little endian)
+	if v.IsKeyFrame && payloadLen >= idx+10 && payload[idx+3] == 0x9d && payload[idx+4] == 0x01 && payload[idx+5] == 0x2a {
+		v.Width = binary.LittleEndian.Uint16(payload[idx+6:]) & 0x3fff
+		v.Height = binary.LittleEndian.Uint16(payload[idx+8:]) & 0x3fff
+	}
 	return nil
 }
 
@@ -311,6 +321,45 @@ func IsH264KeyFrame(payload []byte) bool {
 
 // -------------------------------------
 
+// IsH265KeyFrame detects if h265 payload is a keyframe, i. e. starts with a VPS or SPS (RFC 7798)
+func IsH265KeyFrame(payload []byte) bool {
+	if len(payload) < 2 {
+		return false
+	}
+	nalu := (payload[0] >> 1) & 0x3F
+	switch {
+	case nalu < 48:
+		// single NAL unit
+		return nalu == 32 || nalu == 33
+	case nalu == 48:
+		// aggregation packet, without DONL as sprop-max-don-diff is not negotiated
+		i := 2
+		for i+2 <= len(payload) {
+			length := int(payload[i])<<8 | int(payload[i+1])
+			i += 2
+			if length < 2 || i+length > len(payload) {
+				return false
+			}
+			if n := (payload[i] >> 1) & 0x3F; n == 32 || n == 33 {
+				return true
+			}
+			i += length
+		}
+		return false
+	case nalu == 49:
+		// fragmentation unit
+		if len(payload) < 3 || payload[2]&0x80 == 0 {
+			// not a starting fragment
+			return false
+		}
+		n := payload[2] & 0x3F
+		return n == 32 || n == 33
+	}
+	return false
+}
+
+// -------------------------------------
+
 // IsVP9KeyFrame detects if vp9 payload is a keyframe
 // taken from https://github.com/jech/galene/blob/master/codecs/codecs.go
 // all credits belongs to Juliusz Chroboczek @jech and the awesome Galene SFU
@@ -391,15 +440,20 @@ func IsAV1KeyFrame(payload []byte) bool {
 		default:
 			// OBU_FRAME_HEADER or OBU_FRAME
 			if tpe == 3 || tpe == 6 {
-				if len(obu) < 2 {
+				// frame header follows the extension header of layered streams
+				hdr := 1
+				if (obu[0] & 0x04) != 0 {
+					hdr = 2
+				}
+				if len(obu) < hdr+1 {
 					return false
 				}
 				// show_existing_frame == 0
-				if (obu[1] & 0x80) != 0 {
+				if (obu[hdr] & 0x80) != 0 {
 					return false
 				}
 				// frame_type == KEY_FRAME
-				return (obu[1] & 0x60) == 0
+				return (obu[hdr] & 0x60) == 0
 			}
 		}
 		if truncated || i >= int(w) {
@@ -413,3 +467,113 @@ func IsAV1KeyFrame(payload []byte) bool {
 }
 
 // -------------------------------------
+
+// AV1 OBU types, https://aomediacodec.github.io/av1-spec/#obu-header-semantics
+const (
+	av1OBUTileGroup = 4
+	av1OBUFrame     = 6
+)
+
+// AV1 is a helper to get the layer of an AV1 packet from the OBU extension header, streams sent without
+// dependency descriptor signal their scalability only there
+/*
+	AV1 Aggregation Header
+			0 1 2 3 4 5 6 7
+			+-+-+-+-+-+-+-+-+
+			|Z|Y| W |N|-|-|-|
+			+-+-+-+-+-+-+-+-+
+
+	OBU Header and Extension
+			0 1 2 3 4 5 6 7                      0 1 2 3 4 5 6 7
+			+-+-+-+-+-+-+-+-+                   +-+-+-+-+-+-+-+-+
+			|F| type  |X|S|-| (REQUIRED)   X:   | TID |SID|-|-|-| (OPTIONAL)
+			+-+-+-+-+-+-+-+-+                   +-+-+-+-+-+-+-+-+
+*/
+type AV1 struct {
+	Z bool  /* first OBU element continues an OBU of the previous packet */
+	Y bool  /* last OBU element continues in the next packet */
+	W uint8 /* number of OBU elements, 0 if each element has a length */
+	N bool  /* first packet of a coded video sequence */
+
+	// HasLayer is set when an OBU starting in this packet has an extension header
+	HasLayer bool
+	TID      uint8 /* 3 bits temporal layer idx */
+	SID      uint8 /* 2 bits spatial layer idx */
+
+	// type of the last OBU element, 0 when it continues an OBU of a previous packet
+	LastOBUType uint8
+}
+
+// Unmarshal parses the passed byte slice and stores the result in the AV1 this method is called upon
+func (a *AV1) Unmarshal(payload []byte) error {
+	if payload == nil {
+		return errNilPacket
+	}
+	if len(payload) < 2 {
+		return errShortPacket
+	}
+
+	a.Z = payload[0]&0x80 > 0
+	a.Y = payload[0]&0x40 > 0
+	a.W = (payload[0] & 0x30) >> 4
+	a.N = payload[0]&0x08 > 0
+	a.HasLayer = false
+	a.TID = 0
+	a.SID = 0
+	a.LastOBUType = 0
+
+	offset := 1
+	for i := 0; offset < len(payload); i++ {
+		last := int(a.W) == i+1
+		length := len(payload) - offset
+		if !last {
+			// leb128 length of the element
+			length = 0
+			for n := 0; ; n++ {
+				if offset >= len(payload) || n == 8 {
+					return errShortPacket
+				}
+				b := payload[offset]
+				offset++
+				length |= int(b&0x7f) << (n * 7)
+				if b&0x80 == 0 {
+					break
+				}
+			}
+		}
+		if length == 0 || offset+length > len(payload) {
+			return errInvalidPacket
+		}
+		last = last || offset+length == len(payload)
+
+		if i > 0 || !a.Z {
+			header := payload[offset]
+			if header&0x04 > 0 && !a.HasLayer {
+				if length < 2 {
+					return errShortPacket
+				}
+				a.HasLayer = true
+				a.TID = payload[offset+1] >> 5
+				a.SID = (payload[offset+1] >> 3) & 0x3
+			}
+			if last {
+				a.LastOBUType = (header >> 3) & 0xf
+			}
+		}
+
+		offset += length
+		if last {
+			break
+		}
+	}
+	return nil
+}
+
+// EndsFrame reports whether the packet completes the frame of its layer. Encoders put each layer frame in a
+// single OBU_FRAME, or in a frame header followed by tile groups, so a completed OBU of those types ends it.
+// LastOBUType has to be carried over from the packet starting the OBU when the last element is a continuation
+func (a *AV1) EndsFrame() bool {
+	return !a.Y && (a.LastOBUType == av1OBUFrame || a.LastOBUType == av1OBUTileGroup)
+}
+
+// -------------------------------------
@@ -38,6 +38,9 @@ func TestVP8Helper_Unmarshal(t *testing.T) {
 		tlzIdx          uint8
 		checkTempID     bool
 		temporalID      uint8
+		checkResolution bool
+		width           uint16
+		height          uint16
 	}{
 		{
 			name:    "Empty or nil payload must return error",
@@ -80,6 +83,15 @@ func TestVP8Helper_Unmarshal(t *testing.T) {
 			checkKeyFrame: true,
 			keyFrame:      true,
 		},
+		{
+			name:            "Resolution must be parsed from keyframe header",
+			args:            args{payload: []byte{0x10, 0x50, 0x2c, 0x00, 0x9d, 0x01, 0x2a, 0x80, 0x02, 0x68, 0x01}},
+			checkKeyFrame:   true,
+			keyFrame:        true,
+			checkResolution: true,
+			width:           640,
+			height:          360,
+		},
 	}
 	for _, tt := range tests {
 		tt := tt
@@ -94,6 +106,10 @@ func TestVP8Helper_Unmarshal(t *testing.T) {
 			if tt.checkKeyFrame {
 				require.Equal(t, tt.keyFrame, p.IsKeyFrame)
 			}
+			if tt.checkResolution {
+				require.Equal(t, tt.width, p.Width)
+				require.Equal(t, tt.height, p.Height)
+			}
 			if tt.checkPictureID {
 				require.Equal(t, tt.pictureID, p.PictureID)
 			}
@@ -107,4 +123,101 @@ func TestVP8Helper_Unmarshal(t *testing.T) {
 	}
 }
 
+func TestAV1Helper_Unmarshal(t *testing.T) {
+	tests := []struct {
+		name        string
+		payload     []byte
+		wantErr     bool
+		hasLayer    bool
+		layer       VideoLayer
+		lastOBUType uint8
+		endsFrame   bool
+		keyFrame    bool
+	}{
+		{
+			name: "key frame with sequence header",
+			// W=2, N=1, sequence header, then OBU_FRAME of S0T0
+			payload:     []byte{0x28, 0x03, 0x08, 0x00, 0x00, 0x34, 0x00, 0x10, 0x00},
+			hasLayer:    true,
+			layer:       VideoLayer{Spatial: 0, Temporal: 0},
+			lastOBUType: av1OBUFrame,
+			endsFrame:   true,
+			keyFrame:    true,
+		},
+		{
+			name: "first fragment of upper layer frame",
+			// Y=1, W=1, OBU_FRAME of S1T2
+			payload:     []byte{0x50, 0x34, 0x48, 0xaa},
+			hasLayer:    true,
+			layer:       VideoLayer{Spatial: 1, Temporal: 2},
+			lastOBUType: av1OBUFrame,
+		},
+		{
+			name:    "continuation fragment",
+			payload: []byte{0x90, 0x01, 0x02},
+		},
+		{
+			name:        "single layer",
+			payload:     []byte{0x10, 0x30, 0x10},
+			lastOBUType: av1OBUFrame,
+			endsFrame:   true,
+		},
+		{
+			name:    "element longer than packet",
+			payload: []byte{0x20, 0x05, 0x08},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			p := &AV1{}
+			err := p.Unmarshal(tt.payload)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.hasLayer, p.HasLayer)
+			require.Equal(t, tt.layer, VideoLayer{Spatial: int32(p.SID), Temporal: int32(p.TID)})
+			require.Equal(t, tt.lastOBUType, p.LastOBUType)
+			require.Equal(t, tt.endsFrame, p.EndsFrame())
+			require.Equal(t, tt.keyFrame, IsAV1KeyFrame(tt.payload))
+		})
+	}
+}
+
+func TestIsH265KeyFrame(t *testing.T) {
+	tests := []struct {
+		name     string
+		payload  []byte
+		keyFrame bool
+	}{
+		{name: "VPS", payload: []byte{0x40, 0x01, 0x0c}, keyFrame: true},
+		{name: "SPS", payload: []byte{0x42, 0x01, 0x01}, keyFrame: true},
+		{name: "IDR slice without parameter sets", payload: []byte{0x26, 0x01, 0xaf}},
+		{name: "trailing picture", payload: []byte{0x02, 0x01, 0xd0}},
+		{
+			name: "aggregation packet with VPS",
+			// AP holding VPS and SPS
+			payload:  []byte{0x60, 0x01, 0x00, 0x03, 0x40, 0x01, 0x0c, 0x00, 0x03, 0x42, 0x01, 0x01},
+			keyFrame: true,
+		},
+		{
+			name:    "aggregation packet with truncated unit",
+			payload: []byte{0x60, 0x01, 0x00, 0x08, 0x40, 0x01},
+		},
+		{name: "start fragment of SPS", payload: []byte{0x62, 0x01, 0xa1, 0x01}, keyFrame: true},
+		{name: "middle fragment of SPS", payload: []byte{0x62, 0x01, 0x21, 0x01}},
+		{name: "start fragment of IDR slice", payload: []byte{0x62, 0x01, 0x93, 0xaf}},
+		{name: "too short", payload: []byte{0x40}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.keyFrame, IsH265KeyFrame(tt.payload))
+		})
+	}
+}
+
 // ------------------------------------------
@@ -38,7 +38,7 @@ func LayerPresenceFromTrackInfo(trackInfo *livekit.TrackInfo) *[livekit.VideoQua
 	return &layerPresence
 }
 
-func RidToSpatialLayer(rid string, trackInfo *livekit.TrackInfo) int32 {
+func RidToSpatialLayer(rid string, trackInfo *livekit.TrackInfo, l logger.Logger) int32 {
 	lp := LayerPresenceFromTrackInfo(trackInfo)
 	if lp == nil {
 		switch rid {
@@ -92,13 +92,13 @@ func RidToSpatialLayer(rid string, trackInfo *livekit.TrackInfo) int32 {
 			return 2
 
 		case lp[livekit.VideoQuality_LOW] && lp[livekit.VideoQuality_MEDIUM]:
-			logger.Warnw("unexpected rid f with only two qualities, low and medium", nil)
+			l.Warnw("unexpected rid f with only two qualities, low and medium", nil)
 			return 1
 		case lp[livekit.VideoQuality_LOW] && lp[livekit.VideoQuality_HIGH]:
-			logger.Warnw("unexpected rid f with only two qualities, low and high", nil)
+			l.Warnw("unexpected rid f with only two qualities, low and high", nil)
 			return 1
 		case lp[livekit.VideoQuality_MEDIUM] && lp[livekit.VideoQuality_HIGH]:
-			logger.Warnw("unexpected rid f with only two qualities, medium and high", nil)
+			l.Warnw("unexpected rid f with only two qualities, medium and high", nil)
 			return 1
 
 		default:
@@ -112,7 +112,7 @@ func RidToSpatialLayer(rid string, trackInfo *livekit.TrackInfo) int32 {
 	}
 }
 
-func SpatialLayerToRid(layer int32, trackInfo *livekit.TrackInfo) string {
+func SpatialLayerToRid(layer int32, trackInfo *livekit.TrackInfo, l logger.Logger) string {
 	lp := LayerPresenceFromTrackInfo(trackInfo)
 	if lp == nil {
 		switch layer {
@@ -164,13 +164,13 @@ func SpatialLayerToRid(layer int32, trackInfo *livekit.TrackInfo) string {
 			return FullResolution
 
 		case lp[livekit.VideoQuality_LOW] && lp[livekit.VideoQuality_MEDIUM]:
-			logger.Warnw("unexpected layer 2 with only two qualities, low and medium", nil)
+			l.Warnw("unexpected layer 2 with only two qualities, low and medium", nil)
 			return HalfResolution
 		case lp[livekit.VideoQuality_LOW] && lp[livekit.VideoQuality_HIGH]:
-			logger.Warnw("unexpected layer 2 with only two qualities, low and high", nil)
+			l.Warnw("unexpected layer 2 with only two qualities, low and high", nil)
 			return HalfResolution
 		case lp[livekit.VideoQuality_MEDIUM] && lp[livekit.VideoQuality_HIGH]:
-			logger.Warnw("unexpected layer 2 with only two qualities, medium and high", nil)
+			l.Warnw("unexpected layer 2 with only two qualities, medium and high", nil)
 			return HalfResolution
 
 		default:
@@ -182,11 +182,11 @@ func SpatialLayerToRid(layer int32, trackInfo *livekit.TrackInfo) string {
 	}
 }
 
-func VideoQualityToRid(quality livekit.VideoQuality, trackInfo *livekit.TrackInfo) string {
-	return SpatialLayerToRid(VideoQualityToSpatialLayer(quality, trackInfo), trackInfo)
+func VideoQualityToRid(quality livekit.VideoQuality, trackInfo *livekit.TrackInfo, l logger.Logger) string {
+	return SpatialLayerToRid(VideoQualityToSpatialLayer(quality, trackInfo), trackInfo, l)
 }
 
-func SpatialLayerToVideoQuality(layer int32, trackInfo *livekit.TrackInfo) livekit.VideoQuality {
+func SpatialLayerToVideoQuality(layer int32, trackInfo *livekit.TrackInfo, l logger.Logger) livekit.VideoQuality {
 	lp := LayerPresenceFromTrackInfo(trackInfo)
 	if lp == nil {
 		switch layer {
@@ -235,7 +235,7 @@ func SpatialLayerToVideoQuality(layer int32, trackInfo *livekit.TrackInfo) livek
 			return livekit.VideoQuality_HIGH
 
 		default:
-			logger.Errorw("invalid layer", nil, "layer", layer, "trackInfo", trackInfo)
+			l.Errorw("invalid layer", nil, "layer", layer, "trackInfo", trackInfo)
 			return livekit.VideoQuality_HIGH
 		}
 
@@ -245,7 +245,7 @@ func SpatialLayerToVideoQuality(layer int32, trackInfo *livekit.TrackInfo) livek
 			return livekit.VideoQuality_HIGH
 
 		default:
-			logger.Errorw("invalid layer", nil, "layer", layer, "trackInfo", trackInfo)
+			l.Errorw("invalid layer", nil, "layer", layer, "trackInfo", trackInfo)
 			return livekit.VideoQuality_HIGH
 		}
 	}
@@ -20,6 +20,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/logger"
 )
 
 func TestRidConversion(t *testing.T) {
@@ -160,10 +161,10 @@ func TestRidConversion(t *testing.T) {
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 			for testRid, expectedResult := range test.ridToLayer {
-				actualLayer := RidToSpatialLayer(testRid, test.trackInfo)
+				actualLayer := RidToSpatialLayer(testRid, test.trackInfo, logger.GetLogger())
 				require.Equal(t, expectedResult.layer, actualLayer)
 
-				actualRid := SpatialLayerToRid(actualLayer, test.trackInfo)
+				actualRid := SpatialLayerToRid(actualLayer, test.trackInfo, logger.GetLogger())
 				require.Equal(t, expectedResult.rid, actualRid)
 			}
 		})
@@ -302,7 +303,7 @@ func TestQualityConversion(t *testing.T) {
 				actualLayer := VideoQualityToSpatialLayer(testQuality, test.trackInfo)
 				require.Equal(t, expectedResult.layer, actualLayer)
 
-				actualQuality := SpatialLayerToVideoQuality(actualLayer, test.trackInfo)
+				actualQuality := SpatialLayerToVideoQuality(actualLayer, test.trackInfo, logger.GetLogger())
 				require.Equal(t, expectedResult.quality, actualQuality)
 			}
 		})
@@ -434,7 +435,7 @@ func TestVideoQualityToRidConversion(t *testing.T) {
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 			for testQuality, expectedRid := range test.qualityToRid {
-				actualRid := VideoQualityToRid(testQuality, test.trackInfo)
+				actualRid := VideoQualityToRid(testQuality, test.trackInfo, logger.GetLogger())
 				require.Equal(t, expectedRid, actualRid)
 			}
 		})
@@ -431,11 +431,12 @@ func toAnalyticsStream(ssrc uint32, deltaStats *buffer.RTPDeltaInfo) *livekit.An
 	}
 }
 
+// layer stats are media only, retransmissions and padding are reported per stream
 func toAnalyticsVideoLayer(layer int32, layerStats *buffer.RTPDeltaInfo) *livekit.AnalyticsVideoLayer {
 	avl := &livekit.AnalyticsVideoLayer{
 		Layer:   layer,
-		Packets: layerStats.Packets + layerStats.PacketsDuplicate + layerStats.PacketsPadding,
-		Bytes:   layerStats.Bytes + layerStats.BytesDuplicate + layerStats.BytesPadding,
+		Packets: layerStats.Packets,
+		Bytes:   layerStats.Bytes,
 		Frames:  layerStats.Frames,
 	}
 	if avl.Packets == 0 || avl.Bytes == 0 || avl.Frames == 0 {
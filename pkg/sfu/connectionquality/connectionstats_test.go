@@ -820,3 +820,44 @@ func TestConnectionQuality(t *testing.T) {
 		}
 	})
 }
+
+func TestToAnalyticsVideoLayer(t *testing.T) {
+	testCases := []struct {
+		name     string
+		stats    *buffer.RTPDeltaInfo
+		expected *livekit.AnalyticsVideoLayer
+	}{
+		{
+			name: "media only",
+			stats: &buffer.RTPDeltaInfo{
+				Packets:          100,
+				Bytes:            100000,
+				PacketsDuplicate: 10,
+				BytesDuplicate:   10000,
+				PacketsPadding:   5,
+				BytesPadding:     1250,
+				Frames:           30,
+			},
+			expected: &livekit.AnalyticsVideoLayer{Layer: 1, Packets: 100, Bytes: 100000, Frames: 30},
+		},
+		{
+			name: "retransmissions and padding only",
+			stats: &buffer.RTPDeltaInfo{
+				PacketsDuplicate: 10,
+				BytesDuplicate:   10000,
+				PacketsPadding:   5,
+				BytesPadding:     1250,
+				Frames:           30,
+			},
+		},
+		{
+			name:  "no frames",
+			stats: &buffer.RTPDeltaInfo{Packets: 100, Bytes: 100000},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, toAnalyticsVideoLayer(1, tc.stats))
+		})
+	}
+}
@@ -33,6 +33,7 @@ import (
 	"github.com/livekit/protocol/livekit"
 	"github.com/livekit/protocol/logger"
 
+	"github.com/livekit/livekit-server/pkg/config"
 	"github.com/livekit/livekit-server/pkg/sfu/buffer"
 	"github.com/livekit/livekit-server/pkg/sfu/connectionquality"
 	dd "github.com/livekit/livekit-server/pkg/sfu/dependencydescriptor"
@@ -55,6 +56,7 @@ type TrackSender interface {
 	SubscriberID() livekit.ParticipantID
 	TrackInfoAvailable()
 	HandleRTCPSenderReportData(payloadType webrtc.PayloadType, layer int32, srData *buffer.RTCPSenderReportData) error
+	Resync()
 }
 
 // -------------------------------------------------------------------
@@ -73,6 +75,10 @@ const (
 
 	waitBeforeSendPaddingOnMute = 100 * time.Millisecond
 	maxPaddingOnMuteDuration    = 5 * time.Second
+
+	// placeholder frames sent while a video track is paused
+	placeholderInterval           = 2 * time.Second
+	placeholderKeyFrameMaxPackets = 30
 )
 
 // -------------------------------------------------------------------
@@ -167,6 +173,9 @@ type DownTrackStreamAllocatorListener interface {
 	// subscribed max video layer changed
 	OnSubscribedLayerChanged(dt *DownTrack, layers buffer.VideoLayer)
 
+	// subscriber priority hint changed
+	OnSubscriberPriorityChanged(dt *DownTrack)
+
 	// stream resumed
 	OnResume(dt *DownTrack)
 
@@ -196,6 +205,9 @@ type DowntrackParams struct {
 	Pacer             pacer.Pacer
 	Logger            logger.Logger
 	Trailer           []byte
+
+	// number of forwarder rewrite decisions to keep for debugging, 0 disables
+	ForwarderAuditSize int
 }
 
 // DownTrack implements TrackLocal, is the track used to write packets
@@ -251,9 +263,20 @@ type DownTrack struct {
 	deltaStatsSenderSnapshotId uint32
 
 	isNACKThrottled atomic.Bool
+	// set by stream allocator to keep repair traffic within its budget
+	retransmitLimiter retransmitLimiter
+
+	stallDetector stallDetector
 
 	activePaddingOnMuteUpTrack atomic.Bool
 
+	subscriberPriority atomic.Uint32
+
+	pausedVideoPlaceholder atomic.String
+	placeholderActive      atomic.Bool
+	placeholderGeneration  atomic.Uint32
+	keyFrameCache          *keyFrameCache
+
 	streamAllocatorLock             sync.RWMutex
 	streamAllocatorListener         DownTrackStreamAllocatorListener
 	streamAllocatorReportGeneration int
@@ -296,6 +319,7 @@ func NewDownTrack(params DowntrackParams) (*DownTrack, error) {
 		codec:              codecs[0].RTPCodecCapability,
 		pacer:              params.Pacer,
 		maxLayerNotifierCh: make(chan struct{}, 1),
+		keyFrameCache:      newKeyFrameCache(placeholderKeyFrameMaxPackets),
 	}
 	d.forwarder = NewForwarder(
 		d.kind,
@@ -303,6 +327,9 @@ func NewDownTrack(params DowntrackParams) (*DownTrack, error) {
 		d.params.Receiver.GetReferenceLayerRTPTimestamp,
 		d.getExpectedRTPTimestamp,
 	)
+	if params.ForwarderAuditSize > 0 {
+		d.forwarder.EnableAudit(params.ForwarderAuditSize)
+	}
 
 	d.rtpStats = buffer.NewRTPStatsSender(buffer.RTPStatsParams{
 		ClockRate: d.codec.ClockRate,
@@ -661,6 +688,7 @@ func (d *DownTrack) WriteRTP(extPkt *buffer.ExtPacket, layer int32) error {
 	if !d.writable.Load() {
 		return nil
 	}
+	d.stallDetector.onReceived(extPkt.Arrival)
 
 	tp, err := d.forwarder.GetTranslationParams(extPkt, layer)
 	if tp.shouldDrop {
@@ -672,10 +700,16 @@ func (d *DownTrack) WriteRTP(extPkt *buffer.ExtPacket, layer int32) error {
 
 	var payload []byte
 	pool := PacketFactory.Get().(*[]byte)
-	if len(tp.codecBytes) != 0 {
-		incomingVP8, ok := extPkt.Payload.(buffer.VP8)
-		if ok {
-			payload = d.translateVP8PacketTo(extPkt.Packet, &incomingVP8, tp.codecBytes, pool)
+	incomingVP8, isVP8 := extPkt.Payload.(buffer.VP8)
+	if len(tp.codecBytes) != 0 && isVP8 {
+		payload = d.translateVP8PacketTo(extPkt.Packet, &incomingVP8, tp.codecBytes, pool)
+	}
+	if d.PausedVideoPlaceholder() == config.PausedVideoPlaceholderKeyFrame {
+		switch {
+		case isVP8:
+			d.keyFrameCache.add(extPkt.ExtSequenceNumber, extPkt.Packet.Timestamp, extPkt.KeyFrame, extPkt.Packet.Marker, extPkt.Packet.Payload[incomingVP8.HeaderSize:])
+		case d.mime == "video/h264" || d.mime == "video/h265":
+			d.keyFrameCache.add(extPkt.ExtSequenceNumber, extPkt.Packet.Timestamp, extPkt.KeyFrame, extPkt.Packet.Marker, extPkt.Packet.Payload)
 		}
 	}
 	if payload == nil {
@@ -732,6 +766,7 @@ func (d *DownTrack) WriteRTP(extPkt *buffer.ExtPacket, layer int32) error {
 		},
 		OnSent: d.packetSent,
 	})
+	d.stallDetector.onEnqueued(time.Now())
 	return nil
 }
 
@@ -959,6 +994,7 @@ func (d *DownTrack) CloseWithFlush(flush bool) {
 	}
 
 	d.stopKeyFrameRequester()
+	d.placeholderGeneration.Inc()
 	d.ClearStreamAllocatorReportInterval()
 }
 
@@ -986,6 +1022,146 @@ func (d *DownTrack) SetMaxTemporalLayer(temporalLayer int32) {
 	}
 }
 
+// SetSubscriberPriority records the priority hinted by the subscriber for this track,
+// 0 lets the stream allocator pick a default based on track source.
+func (d *DownTrack) SetSubscriberPriority(priority uint8) {
+	if d.subscriberPriority.Swap(uint32(priority)) == uint32(priority) {
+		return
+	}
+
+	if sal := d.getStreamAllocatorListener(); sal != nil {
+		sal.OnSubscriberPriorityChanged(d)
+	}
+}
+
+func (d *DownTrack) SubscriberPriority() uint8 {
+	return uint8(d.subscriberPriority.Load())
+}
+
+// SetPausedVideoPlaceholder selects what is sent to the subscriber while the track is paused by the
+// stream allocator or muted by its publisher, instead of leaving the subscriber frozen at the last frame.
+func (d *DownTrack) SetPausedVideoPlaceholder(placeholder config.PausedVideoPlaceholder) {
+	if d.kind != webrtc.RTPCodecTypeVideo {
+		return
+	}
+	if placeholder == "" {
+		placeholder = config.PausedVideoPlaceholderOff
+	}
+	if d.pausedVideoPlaceholder.Swap(string(placeholder)) == string(placeholder) {
+		return
+	}
+	if placeholder != config.PausedVideoPlaceholderKeyFrame {
+		d.keyFrameCache.reset()
+	}
+
+	// restart a placeholder already being sent with the new one
+	d.placeholderActive.Store(false)
+	d.placeholderGeneration.Inc()
+	d.updatePlaceholder(d.forwarder.PauseReason())
+}
+
+func (d *DownTrack) PausedVideoPlaceholder() config.PausedVideoPlaceholder {
+	if placeholder := d.pausedVideoPlaceholder.Load(); placeholder != "" {
+		return config.PausedVideoPlaceholder(placeholder)
+	}
+	return config.PausedVideoPlaceholderOff
+}
+
+func (d *DownTrack) updatePlaceholder(pauseReason VideoPauseReason) {
+	placeholder := d.PausedVideoPlaceholder()
+	active := placeholder != config.PausedVideoPlaceholderOff &&
+		(pauseReason == VideoPauseReasonBandwidth || pauseReason == VideoPauseReasonPubMuted) &&
+		!d.isClosed.Load()
+	if d.placeholderActive.Swap(active) == active {
+		return
+	}
+
+	generation := d.placeholderGeneration.Inc()
+	if active {
+		d.params.Logger.Debugw("sending placeholder for paused video", "placeholder", placeholder, "pauseReason", pauseReason)
+		go d.placeholderWorker(placeholder, generation)
+	}
+}
+
+func (d *DownTrack) placeholderWorker(placeholder config.PausedVideoPlaceholder, generation uint32) {
+	ticker := time.NewTicker(placeholderInterval)
+	defer ticker.Stop()
+
+	for generation == d.placeholderGeneration.Load() {
+		if err := d.writePlaceholderFrame(placeholder); err != nil {
+			d.params.Logger.Warnw("could not write placeholder frame", err)
+			return
+		}
+		<-ticker.C
+	}
+}
+
+// writePlaceholderFrame repeats the last key frame forwarded, falling back to a blank key frame when there is none
+func (d *DownTrack) writePlaceholderFrame(placeholder config.PausedVideoPlaceholder) error {
+	if !d.writable.Load() || !d.rtpStats.IsActive() {
+		return nil
+	}
+
+	var getBlankFrame func(bool) ([]byte, error)
+	switch d.mime {
+	case "video/vp8":
+		getBlankFrame = d.getVP8BlankFrame
+	case "video/h264":
+		getBlankFrame = d.getH264BlankFrame
+	default:
+		return nil
+	}
+
+	var payloads [][]byte
+	if placeholder == config.PausedVideoPlaceholderKeyFrame {
+		payloads = d.keyFrameCache.get()
+	}
+	if len(payloads) == 0 {
+		snts, frameEndNeeded, err := d.forwarder.GetSnTsForBlankFrames(30, 1)
+		if err != nil {
+			return err
+		}
+		for _, st := range snts {
+			payload, err := getBlankFrame(frameEndNeeded)
+			if err != nil {
+				return err
+			}
+			d.writeGeneratedRTP(st, true, payload)
+			frameEndNeeded = false
+		}
+		return nil
+	}
+
+	snts, frameEndNeeded, err := d.forwarder.GetSnTsForPlaceholderFrame(30, len(payloads))
+	if err != nil {
+		return err
+	}
+	if frameEndNeeded {
+		payload, err := getBlankFrame(true)
+		if err != nil {
+			return err
+		}
+		d.writeGeneratedRTP(snts[0], true, payload)
+		snts = snts[1:]
+	}
+	for i, st := range snts {
+		payload := payloads[i]
+		if d.mime == "video/vp8" {
+			// packets of the repeated frame get a new picture id, with the start of partition bit only on the first one
+			descriptor, err := d.forwarder.GetPadding(i != 0)
+			if err != nil {
+				return err
+			}
+			if i != 0 {
+				descriptor[0] &^= 0x10
+			}
+			payload = append(descriptor, payload...)
+		}
+		d.writeGeneratedRTP(st, i == len(snts)-1, payload)
+	}
+	return nil
+}
+
 func (d *DownTrack) MaxLayer() buffer.VideoLayer {
 	return d.forwarder.MaxLayer()
 }
@@ -1038,6 +1214,8 @@ func (d *DownTrack) maybeAddTransition(_bitrate int64, distance float64, pauseRe
 		return
 	}
 
+	d.updatePlaceholder(pauseReason)
+
 	if pauseReason == VideoPauseReasonBandwidth {
 		d.connectionStats.UpdatePause(true)
 	} else {
@@ -1134,6 +1312,15 @@ func (d *DownTrack) BandwidthRequested() int64 {
 	return d.forwarder.BandwidthRequested(brs)
 }
 
+func (d *DownTrack) BandwidthOptimal() int64 {
+	_, brs := d.params.Receiver.GetLayeredBitrate()
+	return d.forwarder.GetOptimalBandwidthNeeded(brs)
+}
+
+func (d *DownTrack) AllocationState() VideoAllocationState {
+	return d.forwarder.AllocationState()
+}
+
 func (d *DownTrack) DistanceToDesired() float64 {
 	al, brs := d.params.Receiver.GetLayeredBitrate()
 	return d.forwarder.DistanceToDesired(al, brs)
@@ -1206,6 +1393,27 @@ func (d *DownTrack) Resync() {
 	d.forwarder.Resync()
 }
 
+// CheckStall reports whether the down track has not sent any media for timeout although its publisher
+// kept sending. Down tracks that are not expected to forward, i. e. not writable, muted or without
+// a target layer, are never stalled.
+func (d *DownTrack) CheckStall(timeout time.Duration) DownTrackStall {
+	if !d.writable.Load() || d.forwarder.IsAnyMuted() {
+		return DownTrackStallNone
+	}
+	if d.kind == webrtc.RTPCodecTypeVideo && !d.forwarder.TargetLayer().IsValid() {
+		return DownTrackStallNone
+	}
+
+	return d.stallDetector.check(time.Now(), timeout)
+}
+
+// ResetStalled resyncs the forwarder and requests a key frame to recover a stalled down track
+func (d *DownTrack) ResetStalled() {
+	d.stallDetector.onReset(time.Now())
+	d.forwarder.Resync()
+	d.maybeStartKeyFrameRequester()
+}
+
 func (d *DownTrack) CreateSourceDescriptionChunks() []rtcp.SourceDescriptionChunk {
 	if !d.bound.Load() || d.transceiver.Load() == nil {
 		return nil
@@ -1291,17 +1499,6 @@ func (d *DownTrack) writeBlankFrameRTP(duration float32, generation uint32) chan
 			}
 
 			for i := 0; i < len(snts); i++ {
-				hdr := rtp.Header{
-					Version:        2,
-					Padding:        false,
-					Marker:         true,
-					PayloadType:    d.payloadType,
-					SequenceNumber: uint16(snts[i].extSequenceNumber),
-					Timestamp:      uint32(snts[i].extTimestamp),
-					SSRC:           d.ssrc,
-					CSRC:           []uint32{},
-				}
-
 				payload, err := getBlankFrame(frameEndNeeded)
 				if err != nil {
 					d.params.Logger.Warnw("could not get blank frame", err)
@@ -1309,18 +1506,7 @@ func (d *DownTrack) writeBlankFrameRTP(duration float32, generation uint32) chan
 					return
 				}
 
-				d.pacer.Enqueue(pacer.Packet{
-					Header:             &hdr,
-					Payload:            payload,
-					AbsSendTimeExtID:   uint8(d.absSendTimeExtID),
-					TransportWideExtID: uint8(d.transportWideExtID),
-					WriteStream:        d.writeStream,
-					Metadata: sendPacketMetadata{
-						extSequenceNumber: snts[i].extSequenceNumber,
-						extTimestamp:      snts[i].extTimestamp,
-					},
-					OnSent: d.packetSent,
-				})
+				d.writeGeneratedRTP(snts[i], true, payload)
 
 				// only the first frame will need frameEndNeeded to close out the
 				// previous picture, rest are small key frames (for the video case)
@@ -1335,6 +1521,33 @@ func (d *DownTrack) writeBlankFrameRTP(duration float32, generation uint32) chan
 	return done
 }
 
+// writeGeneratedRTP sends a packet generated by the server, e.g. blank frames, on the down track
+func (d *DownTrack) writeGeneratedRTP(snts SnTs, marker bool, payload []byte) {
+	hdr := rtp.Header{
+		Version:        2,
+		Padding:        false,
+		Marker:         marker,
+		PayloadType:    d.payloadType,
+		SequenceNumber: uint16(snts.extSequenceNumber),
+		Timestamp:      uint32(snts.extTimestamp),
+		SSRC:           d.ssrc,
+		CSRC:           []uint32{},
+	}
+
+	d.pacer.Enqueue(pacer.Packet{
+		Header:             &hdr,
+		Payload:            payload,
+		AbsSendTimeExtID:   uint8(d.absSendTimeExtID),
+		TransportWideExtID: uint8(d.transportWideExtID),
+		WriteStream:        d.writeStream,
+		Metadata: sendPacketMetadata{
+			extSequenceNumber: snts.extSequenceNumber,
+			extTimestamp:      snts.extTimestamp,
+		},
+		OnSent: d.packetSent,
+	})
+}
+
 func (d *DownTrack) maybeAddTrailer(buf []byte) int {
 	if len(buf) < len(d.params.Trailer) {
 		d.params.Logger.Warnw("trailer too big", nil, "bufLen", len(buf), "trailerLen", len(d.params.Trailer))
@@ -1517,6 +1730,12 @@ func (d *DownTrack) SetConnected() {
 	}
 }
 
+// SetMaxRetransmitBitrate caps the bitrate of retransmissions, NACKed packets over it are not retransmitted.
+// 0 removes the cap
+func (d *DownTrack) SetMaxRetransmitBitrate(bitrate int64) {
+	d.retransmitLimiter.setBitrate(bitrate)
+}
+
 // SetActivePaddingOnMuteUpTrack will enable padding on the track when its uptrack is muted.
 // Pion will not fire OnTrack event until it receives packet for the track,
 // so we send padding packets to help pion client (go-sdk) to fire the event.
@@ -1567,6 +1786,11 @@ func (d *DownTrack) retransmitPackets(nacks []uint16) {
 			continue
 		}
 
+		if !d.retransmitLimiter.allow(n, time.Now()) {
+			// over the repair budget of the track
+			continue
+		}
+
 		if epm.nacked > 1 {
 			numRepeatedNACKs++
 		}
@@ -1665,6 +1889,23 @@ func (d *DownTrack) DebugInfo() map[string]interface{} {
 		"LastPli": d.rtpStats.LastPli(),
 	}
 	stats["RTPMunger"] = d.forwarder.RTPMungerDebugInfo()
+	if auditEntries := d.forwarder.AuditEntries(); auditEntries != nil {
+		stats["ForwarderAudit"] = auditEntries
+	}
+
+	if d.kind == webrtc.RTPCodecTypeVideo {
+		state := d.forwarder.AllocationState()
+		stats["Allocation"] = map[string]interface{}{
+			"Deviation":          string(state.Deviation),
+			"PauseReason":        state.PauseReason.String(),
+			"TargetLayer":        state.TargetLayer.String(),
+			"CurrentLayer":       state.CurrentLayer.String(),
+			"MaxLayer":           state.MaxLayer.String(),
+			"MaxPublishedLayer":  state.MaxPublishedLayer.String(),
+			"BandwidthRequested": state.BandwidthRequested,
+			"DistanceToDesired":  state.DistanceToDesired,
+		}
+	}
 
 	senderReport := d.CreateSenderReport()
 	if senderReport != nil {
@@ -1732,6 +1973,8 @@ func (d *DownTrack) GetAndResetBytesSent() (uint32, uint32) {
 
 func (d *DownTrack) onBindAndConnectedChange() {
 	if d.connected.Load() && d.bound.Load() && !d.bindAndConnectedOnce.Swap(true) {
+		d.stallDetector.onReset(time.Now())
+
 		if d.kind == webrtc.RTPCodecTypeVideo {
 			_, layer := d.forwarder.CheckSync()
 			if layer != buffer.InvalidLayerSpatial {
@@ -1864,6 +2107,10 @@ func (d *DownTrack) packetSent(md interface{}, marker bool, hdrSize int, payload
 		return
 	}
 
+	if !spmd.isPadding && !spmd.isRTX {
+		d.stallDetector.onSent(sendTime)
+	}
+
 	if !spmd.disableCounter {
 		// STREAM-ALLOCATOR-TODO: remove this stream allocator bytes counter once stream allocator changes fully to pull bytes counter
 		size := uint32(hdrSize + payloadSize)
@@ -105,6 +105,27 @@ func (v VideoAllocation) String() string {
 	)
 }
 
+// VideoAllocationDeviation is the reason a down track is not forwarding the layers the subscriber asked for
+type VideoAllocationDeviation string
+
+const (
+	VideoAllocationDeviationNone           VideoAllocationDeviation = "NONE"
+	VideoAllocationDeviationPaused         VideoAllocationDeviation = "PAUSED"
+	VideoAllocationDeviationBandwidth      VideoAllocationDeviation = "BANDWIDTH"
+	VideoAllocationDeviationPublisherLimit VideoAllocationDeviation = "PUBLISHER_LIMIT"
+)
+
+type VideoAllocationState struct {
+	Deviation          VideoAllocationDeviation
+	PauseReason        VideoPauseReason
+	TargetLayer        buffer.VideoLayer
+	CurrentLayer       buffer.VideoLayer
+	MaxLayer           buffer.VideoLayer
+	MaxPublishedLayer  buffer.VideoLayer
+	BandwidthRequested int64
+	DistanceToDesired  float64
+}
+
 var (
 	VideoAllocationDefault = VideoAllocation{
 		PauseReason:         VideoPauseReasonFeedDry, // start with no feed till feed is seen
@@ -210,6 +231,8 @@ type Forwarder struct {
 	vls videolayerselector.VideoLayerSelector
 
 	codecMunger codecmunger.CodecMunger
+
+	audit *ForwarderAudit
 }
 
 func NewForwarder(
@@ -236,6 +259,56 @@ func NewForwarder(
 	return f
 }
 
+// EnableAudit records the last `size` rewrite decisions (feed switches, offset updates, resyncs)
+// to help diagnose freezes after layer switches.
+func (f *Forwarder) EnableAudit(size int) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if size <= 0 {
+		f.audit = nil
+		return
+	}
+	f.audit = NewForwarderAudit(size)
+}
+
+func (f *Forwarder) AuditEntries() []ForwarderAuditEntry {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+
+	if f.audit == nil {
+		return nil
+	}
+	return f.audit.Entries()
+}
+
+// should be called with lock held
+func (f *Forwarder) recordAudit(event ForwarderAuditEvent, extPkt *buffer.ExtPacket, layer int32, toSSRC uint32, err error) {
+	if f.audit == nil {
+		return
+	}
+
+	snOffset, tsOffset, snRanges := f.rtpMunger.GetOffsets()
+	entry := ForwarderAuditEntry{
+		At:       time.Now(),
+		Event:    event,
+		Layer:    layer,
+		FromSSRC: f.lastSSRC,
+		ToSSRC:   toSSRC,
+		SNOffset: snOffset,
+		TSOffset: tsOffset,
+		SNRanges: snRanges,
+	}
+	if extPkt != nil {
+		entry.ExtSN = extPkt.ExtSequenceNumber
+		entry.ExtTS = extPkt.ExtTimestamp
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	f.audit.Add(entry)
+}
+
 func (f *Forwarder) SetMaxPublishedLayer(maxPublishedLayer int32) bool {
 	f.lock.Lock()
 	defer f.lock.Unlock()
@@ -291,7 +364,7 @@ func (f *Forwarder) DetermineCodec(codec webrtc.RTPCodecCapability, extensions [
 			f.vls = videolayerselector.NewSimulcast(f.logger)
 		}
 		f.vls.SetTemporalLayerSelector(temporallayerselector.NewVP8(f.logger))
-	case "video/h264":
+	case "video/h264", "video/h265":
 		if f.vls != nil {
 			f.vls = videolayerselector.NewSimulcastFromNull(f.vls)
 		} else {
@@ -326,9 +399,9 @@ func (f *Forwarder) DetermineCodec(codec webrtc.RTPCodecCapability, extensions [
 			}
 		} else {
 			if f.vls != nil {
-				f.vls = videolayerselector.NewSimulcastFromNull(f.vls)
+				f.vls = videolayerselector.NewAV1FromNull(f.vls)
 			} else {
-				f.vls = videolayerselector.NewSimulcast(f.logger)
+				f.vls = videolayerselector.NewAV1(f.logger)
 			}
 		}
 		// SVC-TODO: Support for AV1 Simulcast
@@ -370,6 +443,8 @@ func (f *Forwarder) SeedState(state ForwarderState) {
 	f.preStartTime = state.PreStartTime
 	f.extFirstTS = state.ExtFirstTS
 	f.refTSOffset = state.RefTSOffset
+
+	f.recordAudit(ForwarderAuditEventSeed, nil, state.ReferenceLayerSpatial, 0, nil)
 }
 
 func (f *Forwarder) Mute(muted bool) bool {
@@ -557,6 +632,24 @@ func (f *Forwarder) PauseReason() VideoPauseReason {
 	return f.lastAllocation.PauseReason
 }
 
+// AllocationState returns the latest allocation and why it deviates from the layers the subscriber asked for
+func (f *Forwarder) AllocationState() VideoAllocationState {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+
+	state := VideoAllocationState{
+		PauseReason:        f.lastAllocation.PauseReason,
+		TargetLayer:        f.vls.GetTarget(),
+		CurrentLayer:       f.vls.GetCurrent(),
+		MaxLayer:           f.vls.GetMax(),
+		MaxPublishedLayer:  f.vls.GetMaxSeen(),
+		BandwidthRequested: f.lastAllocation.BandwidthRequested,
+		DistanceToDesired:  f.lastAllocation.DistanceToDesired,
+	}
+	state.Deviation = getAllocationDeviation(f.lastAllocation, state.MaxLayer, state.MaxPublishedLayer)
+	return state
+}
+
 func (f *Forwarder) BandwidthRequested(brs Bitrates) int64 {
 	f.lock.RLock()
 	defer f.lock.RUnlock()
@@ -1356,8 +1449,10 @@ func (f *Forwarder) Pause(availableLayers []int32, brs Bitrates) VideoAllocation
 
 func (f *Forwarder) updateAllocation(alloc VideoAllocation, reason string) VideoAllocation {
 	// restrict target temporal to 0 if codec does not support temporal layers
-	if alloc.TargetLayer.IsValid() && strings.ToLower(f.codec.MimeType) == "video/h264" {
-		alloc.TargetLayer.Temporal = 0
+	if alloc.TargetLayer.IsValid() {
+		if mime := strings.ToLower(f.codec.MimeType); mime == "video/h264" || mime == "video/h265" {
+			alloc.TargetLayer.Temporal = 0
+		}
 	}
 
 	if alloc.IsDeficient != f.lastAllocation.IsDeficient ||
@@ -1389,6 +1484,8 @@ func (f *Forwarder) Resync() {
 }
 
 func (f *Forwarder) resyncLocked() {
+	f.recordAudit(ForwarderAuditEventResync, nil, f.vls.GetCurrent().Spatial, 0, nil)
+
 	f.vls.SetCurrent(buffer.InvalidLayer)
 	f.lastSSRC = 0
 	if f.pubMuted {
@@ -1631,10 +1728,16 @@ func (f *Forwarder) processSourceSwitch(extPkt *buffer.ExtPacket, layer int32) e
 // should be called with lock held
 func (f *Forwarder) getTranslationParamsCommon(extPkt *buffer.ExtPacket, layer int32, tp *TranslationParams) (*TranslationParams, error) {
 	if f.lastSSRC != extPkt.Packet.SSRC {
+		event := ForwarderAuditEventSwitch
+		if !f.started {
+			event = ForwarderAuditEventStart
+		}
 		if err := f.processSourceSwitch(extPkt, layer); err != nil {
+			f.recordAudit(ForwarderAuditEventSwitchFailed, extPkt, layer, extPkt.Packet.SSRC, err)
 			tp.shouldDrop = true
 			return tp, nil
 		}
+		f.recordAudit(event, extPkt, layer, extPkt.Packet.SSRC, nil)
 		f.logger.Debugw("switching feed", "from", f.lastSSRC, "to", extPkt.Packet.SSRC)
 		f.lastSSRC = extPkt.Packet.SSRC
 	}
@@ -1805,6 +1908,38 @@ func (f *Forwarder) GetSnTsForBlankFrames(frameRate uint32, numPackets int) ([]S
 		numPackets++
 	}
 
+	snts, err := f.rtpMunger.UpdateAndGetPaddingSnTs(numPackets, f.codec.ClockRate, frameRate, frameEndNeeded, f.getExpectedExtTimestamp())
+	return snts, frameEndNeeded, err
+}
+
+// GetSnTsForPlaceholderFrame is like GetSnTsForBlankFrames, but for a single frame spanning numPackets packets.
+// All packets of the frame share a timestamp, a packet closing out the previous frame is prepended when needed.
+func (f *Forwarder) GetSnTsForPlaceholderFrame(frameRate uint32, numPackets int) ([]SnTs, bool, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.maybeStart()
+
+	frameEndNeeded := !f.rtpMunger.IsOnFrameBoundary()
+	numFirst := 1
+	if frameEndNeeded {
+		numFirst++
+	}
+
+	snts, err := f.rtpMunger.UpdateAndGetPaddingSnTs(numFirst, f.codec.ClockRate, frameRate, frameEndNeeded, f.getExpectedExtTimestamp())
+	if err != nil || numPackets <= 1 {
+		return snts, frameEndNeeded, err
+	}
+
+	// a frame rate of 0 keeps the timestamp of the first packet of the frame
+	rest, err := f.rtpMunger.UpdateAndGetPaddingSnTs(numPackets-1, f.codec.ClockRate, 0, false, 0)
+	if err != nil {
+		return nil, frameEndNeeded, err
+	}
+	return append(snts, rest...), frameEndNeeded, nil
+}
+
+func (f *Forwarder) getExpectedExtTimestamp() uint64 {
 	extLastTS := f.rtpMunger.GetLast().ExtLastTS
 	extExpectedTS := extLastTS
 	if f.getExpectedRTPTimestamp != nil {
@@ -1816,8 +1951,7 @@ func (f *Forwarder) GetSnTsForBlankFrames(frameRate uint32, numPackets int) ([]S
 	if int64(extExpectedTS-extLastTS) <= 0 {
 		extExpectedTS = extLastTS + 1
 	}
-	snts, err := f.rtpMunger.UpdateAndGetPaddingSnTs(numPackets, f.codec.ClockRate, frameRate, frameEndNeeded, extExpectedTS)
-	return snts, frameEndNeeded, err
+	return extExpectedTS
 }
 
 func (f *Forwarder) GetPadding(frameEndNeeded bool) ([]byte, error) {
@@ -1859,6 +1993,38 @@ func getOptimalBandwidthNeeded(muted bool, pubMuted bool, maxPublishedLayer int3
 	return 0
 }
 
+// getAllocationDeviation explains an allocation, a paused track has the reason of the pause, a deficient track lacks bandwidth.
+// Otherwise the publisher limits the track if it does not publish, or does not have bitrate on, the layer the subscriber asked for
+func getAllocationDeviation(alloc VideoAllocation, maxLayer buffer.VideoLayer, maxPublishedLayer buffer.VideoLayer) VideoAllocationDeviation {
+	if alloc.PauseReason != VideoPauseReasonNone {
+		return VideoAllocationDeviationPaused
+	}
+	if alloc.IsDeficient {
+		return VideoAllocationDeviationBandwidth
+	}
+	if !maxLayer.IsValid() {
+		return VideoAllocationDeviationNone
+	}
+
+	maxAvailable := buffer.InvalidLayer
+	for s := int32(len(alloc.Bitrates)) - 1; s >= 0 && !maxAvailable.IsValid(); s-- {
+		for t := int32(len(alloc.Bitrates[0])) - 1; t >= 0; t-- {
+			if alloc.Bitrates[s][t] != 0 {
+				maxAvailable = buffer.VideoLayer{Spatial: s, Temporal: t}
+				break
+			}
+		}
+	}
+	if !maxAvailable.IsValid() {
+		// bitrates not measured yet
+		maxAvailable = maxPublishedLayer
+	}
+	if maxAvailable.IsValid() && maxLayer.GreaterThan(maxAvailable) {
+		return VideoAllocationDeviationPublisherLimit
+	}
+	return VideoAllocationDeviationNone
+}
+
 func getBandwidthNeeded(brs Bitrates, layer buffer.VideoLayer, fallback int64) int64 {
 	if layer.IsValid() && brs[layer.Spatial][layer.Temporal] > 0 {
 		return brs[layer.Spatial][layer.Temporal]
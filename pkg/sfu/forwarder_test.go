@@ -1884,6 +1884,93 @@ func TestForwarderGetSnTsForBlankFrames(t *testing.T) {
 	require.Equal(t, sntsExpected, snts)
 }
 
+func TestForwarderGetSnTsForPlaceholderFrame(t *testing.T) {
+	f := newForwarder(testutils.TestVP8Codec, webrtc.RTPCodecTypeVideo)
+
+	params := &testutils.TestExtPacketParams{
+		SequenceNumber: 23333,
+		Timestamp:      0xabcdef,
+		SSRC:           0x12345678,
+		PayloadSize:    20,
+	}
+	vp8 := &buffer.VP8{
+		FirstByte:  25,
+		I:          true,
+		M:          true,
+		PictureID:  13467,
+		HeaderSize: 4,
+		IsKeyFrame: true,
+	}
+	extPkt, _ := testutils.GetTestExtPacketVP8(params, vp8)
+
+	f.vls.SetTarget(buffer.VideoLayer{
+		Spatial:  0,
+		Temporal: 1,
+	})
+	f.vls.SetCurrent(buffer.InvalidLayer)
+
+	// send it through so that forwarder locks onto stream
+	_, _ = f.GetTranslationParams(extPkt, 0)
+
+	// last packet did not have RTP marker set, so the previous frame is closed out first
+	clockRate := testutils.TestVP8Codec.ClockRate
+	frameRate := uint32(30)
+	snts, frameEndNeeded, err := f.GetSnTsForPlaceholderFrame(frameRate, 3)
+	require.NoError(t, err)
+	require.True(t, frameEndNeeded)
+
+	// +1 here due to expected time stamp bumping by at least one so that time stamp is always moving ahead
+	ts := uint64(params.Timestamp) + 1 + uint64((clockRate+frameRate-1)/frameRate)
+	sntsExpected := []SnTs{
+		{extSequenceNumber: uint64(params.SequenceNumber) + 1, extTimestamp: uint64(params.Timestamp)},
+		{extSequenceNumber: uint64(params.SequenceNumber) + 2, extTimestamp: ts},
+		{extSequenceNumber: uint64(params.SequenceNumber) + 3, extTimestamp: ts},
+		{extSequenceNumber: uint64(params.SequenceNumber) + 4, extTimestamp: ts},
+	}
+	require.Equal(t, sntsExpected, snts)
+
+	// on a frame boundary now, all packets belong to the new frame
+	snts, frameEndNeeded, err = f.GetSnTsForPlaceholderFrame(frameRate, 2)
+	require.NoError(t, err)
+	require.False(t, frameEndNeeded)
+
+	ts += 1 + uint64((clockRate+frameRate-1)/frameRate)
+	sntsExpected = []SnTs{
+		{extSequenceNumber: uint64(params.SequenceNumber) + 5, extTimestamp: ts},
+		{extSequenceNumber: uint64(params.SequenceNumber) + 6, extTimestamp: ts},
+	}
+	require.Equal(t, sntsExpected, snts)
+}
+
+func TestForwarderAllocationDeviation(t *testing.T) {
+	maxLayer := buffer.VideoLayer{Spatial: 2, Temporal: 1}
+
+	// paused takes precedence over everything else
+	alloc := VideoAllocation{PauseReason: VideoPauseReasonBandwidth, IsDeficient: true}
+	require.Equal(t, VideoAllocationDeviationPaused, getAllocationDeviation(alloc, maxLayer, maxLayer))
+
+	alloc = VideoAllocation{IsDeficient: true}
+	require.Equal(t, VideoAllocationDeviationBandwidth, getAllocationDeviation(alloc, maxLayer, maxLayer))
+
+	// publisher sends only two spatial layers
+	alloc = VideoAllocation{}
+	alloc.Bitrates[0][1] = 100
+	alloc.Bitrates[1][1] = 300
+	require.Equal(t, VideoAllocationDeviationPublisherLimit, getAllocationDeviation(alloc, maxLayer, maxLayer))
+
+	// publisher sends everything asked for
+	alloc.Bitrates[2][1] = 1000
+	require.Equal(t, VideoAllocationDeviationNone, getAllocationDeviation(alloc, maxLayer, maxLayer))
+
+	// without bitrates, the highest layer seen is used
+	alloc = VideoAllocation{}
+	require.Equal(t, VideoAllocationDeviationPublisherLimit, getAllocationDeviation(alloc, maxLayer, buffer.VideoLayer{Spatial: 0, Temporal: 1}))
+	require.Equal(t, VideoAllocationDeviationNone, getAllocationDeviation(alloc, maxLayer, buffer.InvalidLayer))
+
+	// no max set
+	require.Equal(t, VideoAllocationDeviationNone, getAllocationDeviation(alloc, buffer.InvalidLayer, maxLayer))
+}
+
 func TestForwarderGetPaddingVP8(t *testing.T) {
 	f := newForwarder(testutils.TestVP8Codec, webrtc.RTPCodecTypeVideo)
 
@@ -0,0 +1,95 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sfu
+
+import (
+	"time"
+)
+
+type ForwarderAuditEvent string
+
+const (
+	ForwarderAuditEventStart        ForwarderAuditEvent = "START"
+	ForwarderAuditEventSwitch       ForwarderAuditEvent = "SWITCH"
+	ForwarderAuditEventSwitchFailed ForwarderAuditEvent = "SWITCH_FAILED"
+	ForwarderAuditEventResync       ForwarderAuditEvent = "RESYNC"
+	ForwarderAuditEventSeed         ForwarderAuditEvent = "SEED"
+)
+
+// ForwarderAuditEntry is a rewrite decision of the forwarder along with the
+// sequence number/timestamp offsets in effect after it was applied.
+type ForwarderAuditEntry struct {
+	At       time.Time
+	Event    ForwarderAuditEvent
+	Layer    int32
+	FromSSRC uint32
+	ToSSRC   uint32
+	ExtSN    uint64 // incoming extended sequence number which triggered the decision
+	ExtTS    uint64 // incoming extended timestamp which triggered the decision
+	SNOffset uint64
+	TSOffset uint64
+	SNRanges string
+	Error    string `json:",omitempty"`
+	Repeats  int    `json:",omitempty"` // number of identical failures folded into this entry
+}
+
+// ForwarderAudit keeps the most recent rewrite decisions of a forwarder in a ring buffer.
+// It is not safe for concurrent use, forwarder lock protects it.
+type ForwarderAudit struct {
+	entries []ForwarderAuditEntry
+	next    int
+	full    bool
+}
+
+func NewForwarderAudit(size int) *ForwarderAudit {
+	return &ForwarderAudit{
+		entries: make([]ForwarderAuditEntry, size),
+	}
+}
+
+func (a *ForwarderAudit) Add(entry ForwarderAuditEntry) {
+	if len(a.entries) == 0 {
+		return
+	}
+
+	// a failed switch is retried on every packet till it succeeds, fold those into one entry
+	if entry.Event == ForwarderAuditEventSwitchFailed && (a.next != 0 || a.full) {
+		last := &a.entries[(a.next+len(a.entries)-1)%len(a.entries)]
+		if last.Event == entry.Event && last.FromSSRC == entry.FromSSRC && last.ToSSRC == entry.ToSSRC && last.Error == entry.Error {
+			repeats := last.Repeats + 1
+			*last = entry
+			last.Repeats = repeats
+			return
+		}
+	}
+
+	a.entries[a.next] = entry
+	a.next++
+	if a.next == len(a.entries) {
+		a.next = 0
+		a.full = true
+	}
+}
+
+// Entries returns recorded entries, oldest first
+func (a *ForwarderAudit) Entries() []ForwarderAuditEntry {
+	if !a.full {
+		return append([]ForwarderAuditEntry(nil), a.entries[:a.next]...)
+	}
+
+	entries := make([]ForwarderAuditEntry, 0, len(a.entries))
+	entries = append(entries, a.entries[a.next:]...)
+	return append(entries, a.entries[:a.next]...)
+}
@@ -0,0 +1,101 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sfu
+
+import (
+	"testing"
+
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/livekit-server/pkg/sfu/testutils"
+)
+
+func TestForwarderAudit(t *testing.T) {
+	a := NewForwarderAudit(3)
+	require.Empty(t, a.Entries())
+
+	a.Add(ForwarderAuditEntry{ExtSN: 1})
+	a.Add(ForwarderAuditEntry{ExtSN: 2})
+	require.Equal(t, []ForwarderAuditEntry{{ExtSN: 1}, {ExtSN: 2}}, a.Entries())
+
+	// wraps around, oldest dropped
+	a.Add(ForwarderAuditEntry{ExtSN: 3})
+	a.Add(ForwarderAuditEntry{ExtSN: 4})
+	require.Equal(t, []ForwarderAuditEntry{{ExtSN: 2}, {ExtSN: 3}, {ExtSN: 4}}, a.Entries())
+
+	a.Add(ForwarderAuditEntry{ExtSN: 5})
+	a.Add(ForwarderAuditEntry{ExtSN: 6})
+	require.Equal(t, []ForwarderAuditEntry{{ExtSN: 4}, {ExtSN: 5}, {ExtSN: 6}}, a.Entries())
+
+	// repeated switch failures are folded
+	a.Add(ForwarderAuditEntry{Event: ForwarderAuditEventSwitchFailed, ToSSRC: 1, ExtSN: 7, Error: "too far behind"})
+	a.Add(ForwarderAuditEntry{Event: ForwarderAuditEventSwitchFailed, ToSSRC: 1, ExtSN: 8, Error: "too far behind"})
+	a.Add(ForwarderAuditEntry{Event: ForwarderAuditEventSwitchFailed, ToSSRC: 1, ExtSN: 9, Error: "too far behind"})
+	require.Equal(t, []ForwarderAuditEntry{
+		{ExtSN: 5},
+		{ExtSN: 6},
+		{Event: ForwarderAuditEventSwitchFailed, ToSSRC: 1, ExtSN: 9, Error: "too far behind", Repeats: 2},
+	}, a.Entries())
+}
+
+func TestForwarderAuditSwitch(t *testing.T) {
+	f := newForwarder(testutils.TestOpusCodec, webrtc.RTPCodecTypeAudio)
+	require.Nil(t, f.AuditEntries())
+
+	f.EnableAudit(10)
+
+	extPkt, _ := testutils.GetTestExtPacket(&testutils.TestExtPacketParams{
+		SequenceNumber: 23333,
+		Timestamp:      0xabcdef,
+		SSRC:           0x12345678,
+		PayloadSize:    20,
+	})
+	_, err := f.GetTranslationParams(extPkt, 0)
+	require.NoError(t, err)
+
+	// switch to a different feed, offsets should be recorded
+	extPkt, _ = testutils.GetTestExtPacket(&testutils.TestExtPacketParams{
+		SequenceNumber: 100,
+		Timestamp:      0x1000,
+		SSRC:           0x87654321,
+		PayloadSize:    20,
+	})
+	_, err = f.GetTranslationParams(extPkt, 0)
+	require.NoError(t, err)
+
+	f.Resync()
+
+	entries := f.AuditEntries()
+	require.Len(t, entries, 3)
+
+	require.Equal(t, ForwarderAuditEventStart, entries[0].Event)
+	require.Equal(t, uint32(0), entries[0].FromSSRC)
+	require.Equal(t, uint32(0x12345678), entries[0].ToSSRC)
+	require.Equal(t, uint64(0), entries[0].SNOffset)
+
+	require.Equal(t, ForwarderAuditEventSwitch, entries[1].Event)
+	require.Equal(t, uint32(0x12345678), entries[1].FromSSRC)
+	require.Equal(t, uint32(0x87654321), entries[1].ToSSRC)
+	require.Equal(t, uint64(100), entries[1].ExtSN)
+	// the new feed is behind the sent stream, offsets wrap around
+	newSN, nextSN := uint64(100), uint64(23334)
+	require.Equal(t, newSN-nextSN, entries[1].SNOffset)
+	newTS, lastTS := uint64(0x1000), uint64(0xabcdef)
+	require.Equal(t, newTS-lastTS-1, entries[1].TSOffset)
+
+	require.Equal(t, ForwarderAuditEventResync, entries[2].Event)
+	require.Equal(t, uint32(0x87654321), entries[2].FromSSRC)
+}
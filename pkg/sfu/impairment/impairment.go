@@ -0,0 +1,97 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package impairment
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+var ErrInvalidParams = errors.New("loss must be between 0 and 100 percent, latency and jitter cannot be negative")
+
+// Params describe artificial impairment applied to the media path of a participant.
+// Jitter is applied uniformly in [-Jitter, +Jitter] around Latency, delays never go below zero.
+type Params struct {
+	LossPercent float64
+	Latency     time.Duration
+	Jitter      time.Duration
+}
+
+func (p Params) Validate() error {
+	if p.LossPercent < 0 || p.LossPercent > 100 || p.Latency < 0 || p.Jitter < 0 {
+		return ErrInvalidParams
+	}
+	return nil
+}
+
+func (p Params) IsZero() bool {
+	return p.LossPercent == 0 && p.Latency == 0 && p.Jitter == 0
+}
+
+// Impairment decides the fate of each packet passing through an impaired path.
+// A zero Impairment lets every packet through untouched; params can be updated at any time.
+type Impairment struct {
+	lock   sync.Mutex
+	params Params
+	rng    *rand.Rand
+}
+
+func New() *Impairment {
+	return &Impairment{
+		rng: rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+func (i *Impairment) SetParams(params Params) error {
+	if err := params.Validate(); err != nil {
+		return err
+	}
+
+	i.lock.Lock()
+	i.params = params
+	i.lock.Unlock()
+	return nil
+}
+
+func (i *Impairment) Params() Params {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	return i.params
+}
+
+// Apply returns whether the next packet should be dropped, and if not, how long it should be held back.
+func (i *Impairment) Apply() (drop bool, delay time.Duration) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	if i.params.IsZero() {
+		return false, 0
+	}
+
+	if i.params.LossPercent > 0 && i.rng.Float64()*100 < i.params.LossPercent {
+		return true, 0
+	}
+
+	delay = i.params.Latency
+	if i.params.Jitter > 0 {
+		delay += time.Duration(i.rng.Int63n(int64(2*i.params.Jitter)+1)) - i.params.Jitter
+	}
+	if delay < 0 {
+		delay = 0
+	}
+	return false, delay
+}
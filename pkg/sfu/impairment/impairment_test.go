@@ -0,0 +1,72 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package impairment
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestImpairment(t *testing.T) {
+	t.Run("zero params pass everything", func(t *testing.T) {
+		i := New()
+		for n := 0; n < 100; n++ {
+			drop, delay := i.Apply()
+			require.False(t, drop)
+			require.Zero(t, delay)
+		}
+	})
+
+	t.Run("loss", func(t *testing.T) {
+		i := New()
+		require.NoError(t, i.SetParams(Params{LossPercent: 100}))
+		drop, _ := i.Apply()
+		require.True(t, drop)
+
+		require.NoError(t, i.SetParams(Params{LossPercent: 30}))
+		dropped := 0
+		for n := 0; n < 10000; n++ {
+			if drop, _ := i.Apply(); drop {
+				dropped++
+			}
+		}
+		require.InDelta(t, 3000, dropped, 300)
+	})
+
+	t.Run("latency and jitter", func(t *testing.T) {
+		i := New()
+		require.NoError(t, i.SetParams(Params{Latency: 100 * time.Millisecond, Jitter: 20 * time.Millisecond}))
+		for n := 0; n < 1000; n++ {
+			drop, delay := i.Apply()
+			require.False(t, drop)
+			require.GreaterOrEqual(t, delay, 80*time.Millisecond)
+			require.LessOrEqual(t, delay, 120*time.Millisecond)
+		}
+
+		require.NoError(t, i.SetParams(Params{Jitter: 20 * time.Millisecond}))
+		for n := 0; n < 1000; n++ {
+			_, delay := i.Apply()
+			require.GreaterOrEqual(t, delay, time.Duration(0))
+		}
+	})
+
+	t.Run("invalid params", func(t *testing.T) {
+		i := New()
+		require.ErrorIs(t, i.SetParams(Params{LossPercent: 101}), ErrInvalidParams)
+		require.ErrorIs(t, i.SetParams(Params{Latency: -time.Second}), ErrInvalidParams)
+	})
+}
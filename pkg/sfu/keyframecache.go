@@ -0,0 +1,82 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sfu
+
+import (
+	"sync"
+)
+
+// keyFrameCache holds the payloads of the last complete key frame forwarded on a down track, so that it can be
+// repeated as a placeholder while the track is paused. Frames with missing packets or spanning more than
+// maxPackets packets are not kept.
+type keyFrameCache struct {
+	lock       sync.Mutex
+	maxPackets int
+
+	frame [][]byte
+
+	building   [][]byte
+	buildingTS uint32
+	lastSN     uint64
+}
+
+func newKeyFrameCache(maxPackets int) *keyFrameCache {
+	return &keyFrameCache{
+		maxPackets: maxPackets,
+	}
+}
+
+// add takes a forwarded packet, payload is the codec payload without any codec specific descriptor and is copied
+func (k *keyFrameCache) add(extSequenceNumber uint64, timestamp uint32, keyFrame bool, marker bool, payload []byte) {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+
+	switch {
+	case k.building != nil && timestamp == k.buildingTS:
+		if extSequenceNumber != k.lastSN+1 || len(k.building) == k.maxPackets {
+			k.building = nil
+			return
+		}
+	case keyFrame:
+		k.building = make([][]byte, 0, 4)
+		k.buildingTS = timestamp
+	default:
+		k.building = nil
+		return
+	}
+
+	k.building = append(k.building, append([]byte(nil), payload...))
+	k.lastSN = extSequenceNumber
+	if marker {
+		k.frame = k.building
+		k.building = nil
+	}
+}
+
+// get returns the payloads of the last complete key frame, nil if there is none
+func (k *keyFrameCache) get() [][]byte {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+
+	return k.frame
+}
+
+func (k *keyFrameCache) reset() {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+
+	k.frame = nil
+	k.building = nil
+}
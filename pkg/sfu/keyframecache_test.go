@@ -0,0 +1,63 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sfu
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyFrameCache(t *testing.T) {
+	k := newKeyFrameCache(3)
+	require.Nil(t, k.get())
+
+	// delta frames are not kept
+	k.add(1, 1000, false, true, []byte{1})
+	require.Nil(t, k.get())
+
+	// key frame spanning packets is available once complete
+	k.add(2, 2000, true, false, []byte{2})
+	k.add(3, 2000, false, false, []byte{3})
+	require.Nil(t, k.get())
+	k.add(4, 2000, false, true, []byte{4})
+	require.Equal(t, [][]byte{{2}, {3}, {4}}, k.get())
+
+	// incomplete key frame does not replace the last one
+	k.add(5, 3000, true, false, []byte{5})
+	k.add(7, 3000, false, true, []byte{7})
+	require.Equal(t, [][]byte{{2}, {3}, {4}}, k.get())
+
+	// key frame interrupted by the next frame
+	k.add(8, 4000, true, false, []byte{8})
+	k.add(9, 5000, false, true, []byte{9})
+	require.Equal(t, [][]byte{{2}, {3}, {4}}, k.get())
+
+	// too large
+	k.add(10, 6000, true, false, []byte{10})
+	k.add(11, 6000, false, false, []byte{11})
+	k.add(12, 6000, false, false, []byte{12})
+	k.add(13, 6000, false, true, []byte{13})
+	require.Equal(t, [][]byte{{2}, {3}, {4}}, k.get())
+
+	// payload is copied
+	payload := []byte{14}
+	k.add(14, 7000, true, true, payload)
+	payload[0] = 0
+	require.Equal(t, [][]byte{{14}}, k.get())
+
+	k.reset()
+	require.Nil(t, k.get())
+}
@@ -26,7 +26,6 @@ import (
 	"go.uber.org/atomic"
 
 	"github.com/livekit/mediatransportutil/pkg/bucket"
-	"github.com/livekit/mediatransportutil/pkg/twcc"
 	"github.com/livekit/protocol/livekit"
 	"github.com/livekit/protocol/logger"
 
@@ -35,6 +34,7 @@ import (
 	"github.com/livekit/livekit-server/pkg/sfu/buffer"
 	"github.com/livekit/livekit-server/pkg/sfu/connectionquality"
 	dd "github.com/livekit/livekit-server/pkg/sfu/dependencydescriptor"
+	"github.com/livekit/livekit-server/pkg/sfu/twcc"
 )
 
 var (
@@ -78,6 +78,10 @@ type TrackReceiver interface {
 	// Get red receiver for primary codec, used by forward red encodings for opus only codec
 	GetRedReceiver() TrackReceiver
 
+	// Get receiver transcoding video to codec, used for subscribers that cannot decode the published codecs;
+	// returns itself when the track cannot be transcoded
+	GetTranscodedReceiver(codec webrtc.RTPCodecParameters) TrackReceiver
+
 	GetTemporalLayerFpsForSpatial(layer int32) []float32
 
 	GetCalculatedClockRate(layer int32) uint32
@@ -129,6 +133,22 @@ type WebRTCReceiver struct {
 	primaryReceiver atomic.Pointer[RedPrimaryReceiver]
 	redReceiver     atomic.Pointer[RedReceiver]
 	redPktWriter    func(pkt *buffer.ExtPacket, spatialLayer int32)
+
+	// bitrate of the transcoded rendition, 0 disables transcoding
+	transcodeBitrate   int
+	transcodedReceiver atomic.Pointer[TranscodedReceiver]
+	transcodePktWriter func(pkt *buffer.ExtPacket, layer int32)
+
+	bitrateLimiter *BitrateLimiter
+
+	// nil lets panics crash the process
+	onPanic func(r any)
+
+	simulcastValidator   *SimulcastValidator
+	checkSimulcastLayers sync.Once
+
+	// arrival of the most recent packet carrying media, in unix nanoseconds
+	lastPacketAt atomic.Int64
 }
 
 // SVC-TODO: Have to use more conditions to differentiate between
@@ -187,6 +207,32 @@ func WithLoadBalanceThreshold(downTracks int) ReceiverOpts {
 	}
 }
 
+// WithBitrateLimiter enforces a maximum bitrate on the track
+func WithBitrateLimiter(limiter *BitrateLimiter) ReceiverOpts {
+	return func(w *WebRTCReceiver) *WebRTCReceiver {
+		w.bitrateLimiter = limiter
+		return w
+	}
+}
+
+// WithPanicHandler recovers panics in the forwarding goroutines, passing them to onPanic instead of crashing the process.
+// The receiver is closed after a recovered panic
+func WithPanicHandler(onPanic func(r any)) ReceiverOpts {
+	return func(w *WebRTCReceiver) *WebRTCReceiver {
+		w.onPanic = onPanic
+		return w
+	}
+}
+
+// WithTranscoding allows the video track to be transcoded to a single rendition of the given bitrate (bps) for
+// subscribers that cannot decode its codec
+func WithTranscoding(bitrate int) ReceiverOpts {
+	return func(w *WebRTCReceiver) *WebRTCReceiver {
+		w.transcodeBitrate = bitrate
+		return w
+	}
+}
+
 // NewWebRTCReceiver creates a new webrtc track receiver
 func NewWebRTCReceiver(
 	receiver *webrtc.RTPReceiver,
@@ -213,6 +259,10 @@ func NewWebRTCReceiver(
 	w.streamTrackerManager = NewStreamTrackerManager(logger, trackInfo, w.isSVC, w.codec.ClockRate, trackersConfig)
 	w.streamTrackerManager.SetListener(w)
 
+	if w.kind == webrtc.RTPCodecTypeVideo && !w.isSVC {
+		w.simulcastValidator = NewSimulcastValidator(trackInfo, logger)
+	}
+
 	for _, opt := range opts {
 		w = opt(w)
 	}
@@ -270,6 +320,16 @@ func (w *WebRTCReceiver) IsClosed() bool {
 	return w.closed.Load()
 }
 
+// LastPacketTime returns when the most recent packet carrying media arrived on any layer,
+// zero if nothing has been received yet. Padding only packets are not counted.
+func (w *WebRTCReceiver) LastPacketTime() time.Time {
+	at := w.lastPacketAt.Load()
+	if at == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, at)
+}
+
 func (w *WebRTCReceiver) SetRTT(rtt uint32) {
 	w.bufferMu.Lock()
 	if w.rtt == rtt {
@@ -326,10 +386,15 @@ func (w *WebRTCReceiver) AddUpTrack(track *webrtc.TrackRemote, buff *buffer.Buff
 	}
 
 	layer := int32(0)
-	if w.Kind() == webrtc.RTPCodecTypeVideo && !w.isSVC {
-		layer = buffer.RidToSpatialLayer(track.RID(), w.trackInfo)
+	if w.simulcastValidator != nil {
+		layer = w.simulcastValidator.AssignLayer(track.RID())
+		if len(w.trackInfo.GetLayers()) > 1 {
+			w.checkSimulcastLayers.Do(func() {
+				time.AfterFunc(simulcastLayersSettleTime, w.limitToPublishedLayers)
+			})
+		}
 	}
-	buff.SetLogger(w.logger.WithValues("layer", layer))
+	w.setBufferLayer(buff, layer)
 	buff.SetTWCC(w.twcc)
 	buff.SetAudioLevelParams(audio.AudioLevelParams{
 		ActiveLevel:     w.audioConfig.ActiveLevel,
@@ -339,6 +404,12 @@ func (w *WebRTCReceiver) AddUpTrack(track *webrtc.TrackRemote, buff *buffer.Buff
 	})
 	buff.OnRtcpFeedback(w.sendRTCP)
 	buff.OnRtcpSenderReport(func() {
+		// layers can be remapped after the up track is added
+		layer := w.getLayerOfBuffer(buff)
+		if layer == buffer.InvalidLayerSpatial {
+			return
+		}
+
 		srFirst, srNewest := buff.GetSenderReportData()
 		w.streamTrackerManager.SetRTCPSenderReportData(layer, srFirst, srNewest)
 
@@ -347,6 +418,27 @@ func (w *WebRTCReceiver) AddUpTrack(track *webrtc.TrackRemote, buff *buffer.Buff
 		})
 	})
 
+	w.upTrackMu.Lock()
+	w.upTracks[layer] = track
+	w.upTrackMu.Unlock()
+
+	w.bufferMu.Lock()
+	w.buffers[layer] = buff
+	rtt := w.rtt
+	w.bufferMu.Unlock()
+	buff.SetRTT(rtt)
+	buff.SetPaused(w.streamTrackerManager.IsPaused())
+
+	if w.Kind() == webrtc.RTPCodecTypeVideo && w.useTrackers {
+		w.streamTrackerManager.AddTracker(layer)
+	}
+
+	go w.forwardRTP(layer)
+}
+
+func (w *WebRTCReceiver) setBufferLayer(buff *buffer.Buffer, layer int32) {
+	buff.SetLogger(w.logger.WithValues("layer", layer))
+
 	var duration time.Duration
 	switch layer {
 	case 2:
@@ -361,23 +453,66 @@ func (w *WebRTCReceiver) AddUpTrack(track *webrtc.TrackRemote, buff *buffer.Buff
 	if duration != 0 {
 		buff.SetPLIThrottle(duration.Nanoseconds())
 	}
+}
 
-	w.upTrackMu.Lock()
-	w.upTracks[layer] = track
-	w.upTrackMu.Unlock()
+// limitToPublishedLayers stops expecting declared layers that the publisher does not send
+func (w *WebRTCReceiver) limitToPublishedLayers() {
+	if w.closed.Load() {
+		return
+	}
+
+	if missing := w.simulcastValidator.MissingLayers(); len(missing) == 0 {
+		return
+	}
+
+	w.streamTrackerManager.LimitMaxExpectedSpatialLayer(w.simulcastValidator.MaxLayer())
+	w.connectionStats.AddLayerTransition(w.streamTrackerManager.DistanceToDesired())
+}
+
+// reorderLayers moves the streams of layers to the layers given by order, indexed by current layer,
+// so that higher layers carry higher resolutions. Down tracks are resynced on key frames of the new layers.
+func (w *WebRTCReceiver) reorderLayers(order []int32) {
+	var moved []int32
 
 	w.bufferMu.Lock()
-	w.buffers[layer] = buff
-	rtt := w.rtt
+	w.upTrackMu.Lock()
+	var buffers [buffer.DefaultMaxLayerSpatial + 1]*buffer.Buffer
+	var upTracks [buffer.DefaultMaxLayerSpatial + 1]*webrtc.TrackRemote
+	for from, to := range order {
+		buffers[to] = w.buffers[from]
+		upTracks[to] = w.upTracks[from]
+		if int32(from) != to {
+			moved = append(moved, to)
+			if buffers[to] != nil {
+				w.setBufferLayer(buffers[to], to)
+			}
+		}
+	}
+	w.buffers = buffers
+	w.upTracks = upTracks
+	w.upTrackMu.Unlock()
 	w.bufferMu.Unlock()
-	buff.SetRTT(rtt)
-	buff.SetPaused(w.streamTrackerManager.IsPaused())
 
-	if w.Kind() == webrtc.RTPCodecTypeVideo && w.useTrackers {
-		w.streamTrackerManager.AddTracker(layer)
+	w.streamTrackerManager.ResetLayers(moved)
+
+	w.downTrackSpreader.Broadcast(func(dt TrackSender) {
+		dt.Resync()
+	})
+	for _, layer := range moved {
+		w.SendPLI(layer, true)
 	}
+}
 
-	go w.forwardRTP(layer)
+func (w *WebRTCReceiver) getLayerOfBuffer(buff *buffer.Buffer) int32 {
+	w.bufferMu.RLock()
+	defer w.bufferMu.RUnlock()
+
+	for layer, b := range w.buffers {
+		if b == buff {
+			return int32(layer)
+		}
+	}
+	return buffer.InvalidLayerSpatial
 }
 
 // SetUpTrackPaused indicates upstream will not be sending any data.
@@ -636,6 +771,9 @@ func (w *WebRTCReceiver) forwardRTP(layer int32) {
 			if pr := w.redReceiver.Load(); pr != nil {
 				pr.Close()
 			}
+			if tr := w.transcodedReceiver.Load(); tr != nil {
+				tr.Close()
+			}
 		})
 
 		w.streamTrackerManager.RemoveTracker(layer)
@@ -643,17 +781,37 @@ func (w *WebRTCReceiver) forwardRTP(layer int32) {
 			w.streamTrackerManager.RemoveAllTrackers()
 		}
 	}()
+	if w.onPanic != nil {
+		defer func() {
+			if r := recover(); r != nil {
+				w.onPanic(r)
+			}
+		}()
+	}
 
 	for {
 		w.bufferMu.RLock()
 		buf := w.buffers[layer]
 		redPktWriter := w.redPktWriter
+		transcodePktWriter := w.transcodePktWriter
 		w.bufferMu.RUnlock()
 		pkt, err := buf.ReadExtended(pktBuf)
 		if err == io.EOF {
 			return
 		}
 
+		if len(pkt.Packet.Payload) != 0 {
+			w.lastPacketAt.Store(pkt.Arrival.UnixNano())
+		}
+
+		if pkt.KeyFrame && w.simulcastValidator != nil {
+			if vp8, ok := pkt.Payload.(buffer.VP8); ok && vp8.Width != 0 && vp8.Height != 0 {
+				if order := w.simulcastValidator.ObserveResolution(layer, vp8.Width, vp8.Height); order != nil {
+					w.reorderLayers(order)
+				}
+			}
+		}
+
 		spatialTracker := tracker
 		spatialLayer := layer
 		if pkt.Spatial >= 0 {
@@ -665,12 +823,17 @@ func (w *WebRTCReceiver) forwardRTP(layer int32) {
 			}
 		}
 
-		w.downTrackSpreader.Broadcast(func(dt TrackSender) {
-			_ = dt.WriteRTP(pkt, spatialLayer)
-		})
+		if w.bitrateLimiter == nil || w.bitrateLimiter.Admit(pkt, spatialLayer, time.Now()) {
+			w.downTrackSpreader.Broadcast(func(dt TrackSender) {
+				_ = dt.WriteRTP(pkt, spatialLayer)
+			})
 
-		if redPktWriter != nil {
-			redPktWriter(pkt, spatialLayer)
+			if redPktWriter != nil {
+				redPktWriter(pkt, spatialLayer)
+			}
+			if transcodePktWriter != nil {
+				transcodePktWriter(pkt, layer)
+			}
 		}
 
 		if spatialTracker != nil {
@@ -719,6 +882,14 @@ func (w *WebRTCReceiver) DebugInfo() map[string]interface{} {
 	w.upTrackMu.RUnlock()
 	info["UpTracks"] = upTrackInfo
 
+	if w.bitrateLimiter != nil {
+		info["BitrateLimit"] = map[string]interface{}{
+			"MaxBitrate": w.bitrateLimiter.MaxBitrate(),
+			"Bitrate":    w.bitrateLimiter.Bitrate(),
+			"Suspended":  w.bitrateLimiter.IsSuspended(),
+		}
+	}
+
 	return info
 }
 
@@ -760,6 +931,30 @@ func (w *WebRTCReceiver) GetRedReceiver() TrackReceiver {
 	return w.redReceiver.Load()
 }
 
+func (w *WebRTCReceiver) GetTranscodedReceiver(codec webrtc.RTPCodecParameters) TrackReceiver {
+	if w.transcodeBitrate <= 0 || w.kind != webrtc.RTPCodecTypeVideo || w.closed.Load() ||
+		!strings.EqualFold(codec.MimeType, webrtc.MimeTypeH264) || strings.EqualFold(w.codec.MimeType, codec.MimeType) {
+		return w
+	}
+
+	if w.transcodedReceiver.Load() == nil {
+		tr, err := NewTranscodedReceiver(w, codec, w.transcodeBitrate, DownTrackSpreaderParams{
+			Threshold: w.lbThreshold,
+			Logger:    w.logger,
+		})
+		if err != nil {
+			w.logger.Warnw("could not transcode track", err, "codec", codec.MimeType)
+			return w
+		}
+		if w.transcodedReceiver.CompareAndSwap(nil, tr) {
+			w.bufferMu.Lock()
+			w.transcodePktWriter = tr.ForwardRTP
+			w.bufferMu.Unlock()
+		}
+	}
+	return w.transcodedReceiver.Load()
+}
+
 func (w *WebRTCReceiver) GetTemporalLayerFpsForSpatial(layer int32) []float32 {
 	b := w.getBuffer(layer)
 	if b == nil {
@@ -30,6 +30,7 @@ type dummyDowntrack struct {
 	TrackSender
 	lastReceivedPkt *rtp.Packet
 	receivedPkts    []*rtp.Packet
+	closed          bool
 }
 
 func (dt *dummyDowntrack) WriteRTP(p *buffer.ExtPacket, _ int32) error {
@@ -38,6 +39,14 @@ func (dt *dummyDowntrack) WriteRTP(p *buffer.ExtPacket, _ int32) error {
 	return nil
 }
 
+func (dt *dummyDowntrack) Close() {
+	dt.closed = true
+}
+
+func (dt *dummyDowntrack) IsClosed() bool {
+	return dt.closed
+}
+
 func TestRedReceiver(t *testing.T) {
 	dt := &dummyDowntrack{TrackSender: &DownTrack{}}
 
@@ -0,0 +1,81 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sfu
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// retransmissions may burst up to this much of a second worth of the limit
+	retransmitBurst = 250 * time.Millisecond
+	// a burst always fits a few full size packets
+	retransmitMinBurstBytes = 3 * 1500
+)
+
+// retransmitLimiter is a token bucket capping the bitrate of retransmissions. Under sustained loss
+// retransmissions beyond the cap are skipped packet by packet instead of all of them being switched off
+type retransmitLimiter struct {
+	lock    sync.Mutex
+	bitrate int64
+	tokens  float64
+	last    time.Time
+}
+
+// setBitrate caps retransmissions at bitrate bps, 0 removes the cap
+func (r *retransmitLimiter) setBitrate(bitrate int64) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.bitrate == 0 && bitrate > 0 {
+		// start with a full bucket
+		r.tokens = r.burstLocked(bitrate)
+		r.last = time.Time{}
+	}
+	r.bitrate = bitrate
+}
+
+// allow takes size bytes from the bucket, returning false when the packet is over the cap
+func (r *retransmitLimiter) allow(size int, now time.Time) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.bitrate <= 0 {
+		return true
+	}
+
+	if !r.last.IsZero() {
+		r.tokens += now.Sub(r.last).Seconds() * float64(r.bitrate) / 8
+		if burst := r.burstLocked(r.bitrate); r.tokens > burst {
+			r.tokens = burst
+		}
+	}
+	r.last = now
+
+	if r.tokens < float64(size) {
+		return false
+	}
+	r.tokens -= float64(size)
+	return true
+}
+
+func (r *retransmitLimiter) burstLocked(bitrate int64) float64 {
+	burst := float64(bitrate) / 8 * retransmitBurst.Seconds()
+	if burst < retransmitMinBurstBytes {
+		burst = retransmitMinBurstBytes
+	}
+	return burst
+}
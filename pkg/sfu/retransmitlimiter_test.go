@@ -0,0 +1,109 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sfu
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetransmitLimiter(t *testing.T) {
+	t.Run("allows everything without a cap", func(t *testing.T) {
+		var l retransmitLimiter
+		now := time.Now()
+		for i := 0; i < 1000; i++ {
+			require.True(t, l.allow(1500, now))
+		}
+	})
+
+	t.Run("allows a burst then limits to the cap", func(t *testing.T) {
+		var l retransmitLimiter
+		// 800 kbps -> 100 KB/s, 25 KB burst
+		l.setBitrate(800_000)
+
+		now := time.Now()
+		admitted := 0
+		for i := 0; i < 100; i++ {
+			if l.allow(1000, now) {
+				admitted++
+			}
+		}
+		require.Equal(t, 25, admitted)
+
+		// a second of sustained over sending, 200 packets of 1000 bytes, only the cap gets through
+		admitted = 0
+		for i := 0; i < 200; i++ {
+			now = now.Add(5 * time.Millisecond)
+			if l.allow(1000, now) {
+				admitted++
+			}
+		}
+		require.InDelta(t, 100, admitted, 1)
+	})
+
+	t.Run("keeps repairing under steady loss", func(t *testing.T) {
+		var l retransmitLimiter
+		l.setBitrate(80_000)
+
+		// retransmissions well under the cap are never held back
+		now := time.Now()
+		for i := 0; i < 100; i++ {
+			now = now.Add(100 * time.Millisecond)
+			require.True(t, l.allow(500, now))
+		}
+	})
+
+	t.Run("small caps still fit full size packets", func(t *testing.T) {
+		var l retransmitLimiter
+		l.setBitrate(8_000)
+
+		now := time.Now()
+		for i := 0; i < 3; i++ {
+			require.True(t, l.allow(1500, now))
+		}
+		require.False(t, l.allow(1500, now))
+	})
+
+	t.Run("removing the cap lets everything through", func(t *testing.T) {
+		var l retransmitLimiter
+		l.setBitrate(8_000)
+
+		now := time.Now()
+		for l.allow(1500, now) {
+		}
+
+		l.setBitrate(0)
+		require.True(t, l.allow(1500, now))
+	})
+}
+
+func TestDownTrackMaxRetransmitBitrate(t *testing.T) {
+	d := &DownTrack{}
+	d.SetMaxRetransmitBitrate(8_000)
+
+	now := time.Now()
+	admitted := 0
+	for i := 0; i < 10; i++ {
+		if d.retransmitLimiter.allow(1500, now) {
+			admitted++
+		}
+	}
+	require.Equal(t, 3, admitted)
+
+	d.SetMaxRetransmitBitrate(0)
+	require.True(t, d.retransmitLimiter.allow(1500, now))
+}
@@ -110,6 +110,10 @@ func (r *RTPMunger) GetLast() RTPMungerState {
 	}
 }
 
+func (r *RTPMunger) GetOffsets() (snOffset uint64, tsOffset uint64, snRanges string) {
+	return r.snOffset, r.tsOffset, r.snRangeMap.String()
+}
+
 func (r *RTPMunger) SeedLast(state RTPMungerState) {
 	r.extLastSN = state.ExtLastSN
 	r.extSecondLastSN = state.ExtSecondLastSN
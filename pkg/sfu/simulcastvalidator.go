@@ -0,0 +1,193 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sfu
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/livekit/livekit-server/pkg/sfu/buffer"
+	"github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/logger"
+)
+
+// time given to publishers to start all declared layers before missing ones are no longer expected
+const simulcastLayersSettleTime = 10 * time.Second
+
+type simulcastLayer struct {
+	rid    string
+	width  uint16
+	height uint16
+}
+
+func (l *simulcastLayer) area() int {
+	return int(l.width) * int(l.height)
+}
+
+// SimulcastValidator checks simulcast layers sent by a publisher against what it declared.
+// Layers are assigned from rids, conflicting assignments are moved to free layers, and layers whose
+// resolutions are out of order are remapped so that higher layers carry higher resolutions.
+// The order is checked once all layers have sent a key frame, as a wrong mapping does not change mid-stream.
+// Resolutions are only known for codecs that expose them in key frames, currently VP8.
+type SimulcastValidator struct {
+	trackInfo *livekit.TrackInfo
+	logger    logger.Logger
+
+	lock      sync.Mutex
+	layers    [buffer.DefaultMaxLayerSpatial + 1]*simulcastLayer
+	validated bool
+}
+
+func NewSimulcastValidator(trackInfo *livekit.TrackInfo, logger logger.Logger) *SimulcastValidator {
+	return &SimulcastValidator{
+		trackInfo: trackInfo,
+		logger:    logger,
+	}
+}
+
+// AssignLayer returns the spatial layer to use for a rid
+func (s *SimulcastValidator) AssignLayer(rid string) int32 {
+	layer := buffer.RidToSpatialLayer(rid, s.trackInfo, s.logger)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if existing := s.layers[layer]; existing != nil && existing.rid != rid {
+		// rids do not match the declared layers, e.g. three rids for two declared qualities,
+		// take the closest free layer rather than replacing the stream already on this one
+		free := s.closestFreeLayerLocked(layer)
+		if free == buffer.InvalidLayerSpatial {
+			s.logger.Warnw("simulcast layer conflict, no free layer", nil, "rid", rid, "layer", layer, "existingRid", existing.rid)
+			return layer
+		}
+		s.logger.Warnw("simulcast layer conflict", nil, "rid", rid, "layer", layer, "existingRid", existing.rid, "assignedLayer", free)
+		layer = free
+	}
+	s.layers[layer] = &simulcastLayer{rid: rid}
+	s.validated = false
+	return layer
+}
+
+func (s *SimulcastValidator) closestFreeLayerLocked(layer int32) int32 {
+	for l := layer + 1; l < int32(len(s.layers)); l++ {
+		if s.layers[l] == nil {
+			return l
+		}
+	}
+	for l := layer - 1; l >= 0; l-- {
+		if s.layers[l] == nil {
+			return l
+		}
+	}
+	return buffer.InvalidLayerSpatial
+}
+
+// ObserveResolution records the resolution of a key frame received on a layer.
+// Once all layers are known, it returns the layer each current layer should move to if they are out of order.
+func (s *SimulcastValidator) ObserveResolution(layer int32, width uint16, height uint16) []int32 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.validated || layer < 0 || int(layer) >= len(s.layers) || s.layers[layer] == nil {
+		return nil
+	}
+	s.layers[layer].width = width
+	s.layers[layer].height = height
+
+	var known []int32
+	for idx, l := range s.layers {
+		if l == nil {
+			continue
+		}
+		if l.area() == 0 {
+			// wait for all layers
+			return nil
+		}
+		known = append(known, int32(idx))
+	}
+	s.validated = true
+	ordered := make([]int32, len(known))
+	copy(ordered, known)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return s.layers[ordered[i]].area() < s.layers[ordered[j]].area()
+	})
+
+	order := make([]int32, len(s.layers))
+	for idx := range order {
+		order[idx] = int32(idx)
+	}
+	reordered := false
+	for idx, from := range ordered {
+		if from != known[idx] {
+			order[from] = known[idx]
+			reordered = true
+		}
+	}
+	if !reordered {
+		return nil
+	}
+
+	s.logger.Warnw("simulcast layers out of order, remapping", nil, "layers", s.describeLocked(), "order", order)
+
+	var layers [buffer.DefaultMaxLayerSpatial + 1]*simulcastLayer
+	for from, to := range order {
+		layers[to] = s.layers[from]
+	}
+	s.layers = layers
+	return order
+}
+
+// MissingLayers returns the declared layers the publisher does not send
+func (s *SimulcastValidator) MissingLayers() []int32 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var missing []int32
+	for _, declared := range s.trackInfo.GetLayers() {
+		layer := buffer.VideoQualityToSpatialLayer(declared.Quality, s.trackInfo)
+		if layer >= 0 && int(layer) < len(s.layers) && s.layers[layer] == nil {
+			missing = append(missing, layer)
+		}
+	}
+	if len(missing) != 0 {
+		s.logger.Warnw("simulcast layers missing", nil, "missing", missing, "layers", s.describeLocked())
+	}
+	return missing
+}
+
+// MaxLayer returns the highest layer being sent
+func (s *SimulcastValidator) MaxLayer() int32 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for l := int32(len(s.layers)) - 1; l >= 0; l-- {
+		if s.layers[l] != nil {
+			return l
+		}
+	}
+	return buffer.InvalidLayerSpatial
+}
+
+func (s *SimulcastValidator) describeLocked() []string {
+	var layers []string
+	for idx, l := range s.layers {
+		if l != nil {
+			layers = append(layers, fmt.Sprintf("%d: rid %q, %dx%d", idx, l.rid, l.width, l.height))
+		}
+	}
+	return layers
+}
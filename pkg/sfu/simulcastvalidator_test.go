@@ -0,0 +1,81 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sfu
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/logger"
+
+	"github.com/livekit/livekit-server/pkg/sfu/buffer"
+)
+
+func simulcastTrackInfo(qualities ...livekit.VideoQuality) *livekit.TrackInfo {
+	ti := &livekit.TrackInfo{Type: livekit.TrackType_VIDEO}
+	for _, q := range qualities {
+		ti.Layers = append(ti.Layers, &livekit.VideoLayer{Quality: q})
+	}
+	return ti
+}
+
+func TestSimulcastValidator(t *testing.T) {
+	t.Run("reorders layers by resolution", func(t *testing.T) {
+		v := NewSimulcastValidator(simulcastTrackInfo(livekit.VideoQuality_LOW, livekit.VideoQuality_MEDIUM, livekit.VideoQuality_HIGH), logger.GetLogger())
+		require.Equal(t, int32(0), v.AssignLayer(buffer.QuarterResolution))
+		require.Equal(t, int32(1), v.AssignLayer(buffer.HalfResolution))
+		require.Equal(t, int32(2), v.AssignLayer(buffer.FullResolution))
+
+		// checked once all layers are known
+		require.Nil(t, v.ObserveResolution(0, 1280, 720))
+		require.Nil(t, v.ObserveResolution(1, 640, 360))
+		require.Equal(t, []int32{2, 1, 0}, v.ObserveResolution(2, 320, 180))
+		require.Equal(t, buffer.FullResolution, v.layers[0].rid)
+		require.Equal(t, buffer.HalfResolution, v.layers[1].rid)
+		require.Equal(t, buffer.QuarterResolution, v.layers[2].rid)
+
+		// not checked again once validated
+		require.Nil(t, v.ObserveResolution(0, 1280, 720))
+	})
+
+	t.Run("keeps layers in order", func(t *testing.T) {
+		v := NewSimulcastValidator(simulcastTrackInfo(livekit.VideoQuality_LOW, livekit.VideoQuality_MEDIUM, livekit.VideoQuality_HIGH), logger.GetLogger())
+		v.AssignLayer(buffer.QuarterResolution)
+		v.AssignLayer(buffer.HalfResolution)
+		v.AssignLayer(buffer.FullResolution)
+
+		require.Nil(t, v.ObserveResolution(0, 320, 180))
+		require.Nil(t, v.ObserveResolution(1, 640, 360))
+		require.Nil(t, v.ObserveResolution(2, 1280, 720))
+	})
+
+	t.Run("moves conflicting rids to free layers", func(t *testing.T) {
+		v := NewSimulcastValidator(simulcastTrackInfo(livekit.VideoQuality_LOW, livekit.VideoQuality_HIGH), logger.GetLogger())
+		require.Equal(t, int32(0), v.AssignLayer(buffer.QuarterResolution))
+		require.Equal(t, int32(1), v.AssignLayer(buffer.HalfResolution))
+		require.Equal(t, int32(2), v.AssignLayer(buffer.FullResolution))
+		require.Empty(t, v.MissingLayers())
+	})
+
+	t.Run("missing layers", func(t *testing.T) {
+		v := NewSimulcastValidator(simulcastTrackInfo(livekit.VideoQuality_LOW, livekit.VideoQuality_MEDIUM, livekit.VideoQuality_HIGH), logger.GetLogger())
+		v.AssignLayer(buffer.QuarterResolution)
+		v.AssignLayer(buffer.HalfResolution)
+		require.Equal(t, []int32{2}, v.MissingLayers())
+		require.Equal(t, int32(1), v.MaxLayer())
+	})
+}
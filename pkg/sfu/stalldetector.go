@@ -0,0 +1,66 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sfu
+
+import (
+	"time"
+
+	"go.uber.org/atomic"
+)
+
+type DownTrackStall string
+
+const (
+	DownTrackStallNone DownTrackStall = ""
+	// packets arrive from the publisher, but the forwarder drops all of them
+	DownTrackStallForwarding DownTrackStall = "forwarding"
+	// packets are handed to the pacer, but none of them are sent out
+	DownTrackStallSending DownTrackStall = "sending"
+)
+
+// stallDetector keeps the time packets last went through each stage of a down track, in unix nanoseconds
+type stallDetector struct {
+	receivedAt atomic.Int64
+	enqueuedAt atomic.Int64
+	sentAt     atomic.Int64
+	resetAt    atomic.Int64
+}
+
+func (s *stallDetector) onReceived(at time.Time) { s.receivedAt.Store(at.UnixNano()) }
+func (s *stallDetector) onEnqueued(at time.Time) { s.enqueuedAt.Store(at.UnixNano()) }
+func (s *stallDetector) onSent(at time.Time)     { s.sentAt.Store(at.UnixNano()) }
+func (s *stallDetector) onReset(at time.Time)    { s.resetAt.Store(at.UnixNano()) }
+
+// check reports a stall when nothing was sent for timeout since the later of the last sent packet and
+// the last reset, while packets kept arriving within timeout.
+func (s *stallDetector) check(now time.Time, timeout time.Duration) DownTrackStall {
+	since := s.sentAt.Load()
+	if resetAt := s.resetAt.Load(); resetAt > since {
+		since = resetAt
+	}
+	cutoff := now.Add(-timeout).UnixNano()
+	if since == 0 || since > cutoff {
+		return DownTrackStallNone
+	}
+
+	switch {
+	case s.enqueuedAt.Load() > cutoff:
+		return DownTrackStallSending
+	case s.receivedAt.Load() > cutoff:
+		return DownTrackStallForwarding
+	default:
+		return DownTrackStallNone
+	}
+}
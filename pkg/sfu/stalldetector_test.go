@@ -0,0 +1,68 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sfu
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStallDetector(t *testing.T) {
+	timeout := 10 * time.Second
+	start := time.Now()
+
+	t.Run("not started", func(t *testing.T) {
+		s := &stallDetector{}
+		s.onReceived(start)
+		require.Equal(t, DownTrackStallNone, s.check(start.Add(time.Minute), timeout))
+	})
+
+	t.Run("sending", func(t *testing.T) {
+		s := &stallDetector{}
+		s.onReset(start)
+		s.onSent(start)
+		require.Equal(t, DownTrackStallNone, s.check(start.Add(5*time.Second), timeout))
+
+		s.onReceived(start.Add(15 * time.Second))
+		require.Equal(t, DownTrackStallForwarding, s.check(start.Add(15*time.Second), timeout))
+
+		s.onEnqueued(start.Add(15 * time.Second))
+		require.Equal(t, DownTrackStallSending, s.check(start.Add(15*time.Second), timeout))
+
+		s.onSent(start.Add(16 * time.Second))
+		require.Equal(t, DownTrackStallNone, s.check(start.Add(16*time.Second), timeout))
+	})
+
+	t.Run("idle publisher", func(t *testing.T) {
+		s := &stallDetector{}
+		s.onReceived(start)
+		s.onSent(start)
+		require.Equal(t, DownTrackStallNone, s.check(start.Add(time.Minute), timeout))
+	})
+
+	t.Run("reset restarts the timeout", func(t *testing.T) {
+		s := &stallDetector{}
+		s.onSent(start)
+		s.onReceived(start.Add(20 * time.Second))
+		require.Equal(t, DownTrackStallForwarding, s.check(start.Add(20*time.Second), timeout))
+
+		s.onReset(start.Add(20 * time.Second))
+		require.Equal(t, DownTrackStallNone, s.check(start.Add(25*time.Second), timeout))
+		s.onReceived(start.Add(30 * time.Second))
+		require.Equal(t, DownTrackStallForwarding, s.check(start.Add(30*time.Second), timeout))
+	})
+}
@@ -0,0 +1,121 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package streamallocator
+
+import (
+	"time"
+)
+
+// ------------------------------------------------
+
+const (
+	repairBudgetWindow = time.Second
+	// weight of the latest window in the smoothed repair bitrate
+	repairBudgetSmoothing = 0.5
+	// retransmissions of a track are never capped below this, so a track with a small allocation can still repair
+	minTrackRepairBitrate = 64_000
+)
+
+// ------------------------------------------------
+
+// RepairBudget limits the share of sent bytes spent on repair traffic (retransmissions and probe padding)
+// relative to primary media. Repair is paid for out of the allocation: the measured repair bitrate, up to the
+// budget, is reserved from the channel capacity before media is allocated, and each track may retransmit up
+// to the budgeted share of its own allocation.
+type RepairBudget struct {
+	maxShare float64
+
+	windowStart  time.Time
+	primaryBytes uint64
+	repairBytes  uint64
+
+	repairBitrate float64
+	share         float64
+}
+
+func NewRepairBudget(maxShare float64) *RepairBudget {
+	return &RepairBudget{
+		maxShare: maxShare,
+	}
+}
+
+func (r *RepairBudget) IsEnabled() bool {
+	return r.maxShare > 0.0 && r.maxShare < 1.0
+}
+
+// IsExceeded returns true when repair took more than its share of the last window
+func (r *RepairBudget) IsExceeded() bool {
+	return r.IsEnabled() && r.share > r.maxShare
+}
+
+// Update accumulates sent bytes and measures repair at the end of each window.
+// Returns true when a window ended.
+func (r *RepairBudget) Update(primaryBytes uint32, repairBytes uint32, now time.Time) bool {
+	if !r.IsEnabled() {
+		return false
+	}
+
+	if r.windowStart.IsZero() {
+		r.windowStart = now
+	}
+	r.primaryBytes += uint64(primaryBytes)
+	r.repairBytes += uint64(repairBytes)
+
+	elapsed := now.Sub(r.windowStart)
+	if elapsed < repairBudgetWindow {
+		return false
+	}
+
+	r.share = 0.0
+	if total := r.primaryBytes + r.repairBytes; total != 0 {
+		r.share = float64(r.repairBytes) / float64(total)
+	}
+	bitrate := float64(r.repairBytes*8) / elapsed.Seconds()
+	r.repairBitrate = repairBudgetSmoothing*bitrate + (1-repairBudgetSmoothing)*r.repairBitrate
+
+	r.windowStart = now
+	r.primaryBytes = 0
+	r.repairBytes = 0
+	return true
+}
+
+// Reserved returns the bitrate to set aside for repair out of capacity, the measured repair bitrate
+// capped at the budget
+func (r *RepairBudget) Reserved(capacity int64) int64 {
+	if !r.IsEnabled() || capacity <= 0 {
+		return 0
+	}
+
+	reserved := int64(r.repairBitrate)
+	if maxReserved := int64(float64(capacity) * r.maxShare); reserved > maxReserved {
+		reserved = maxReserved
+	}
+	return reserved
+}
+
+// TrackRetransmitBitrate returns the cap on retransmissions of a track allocated the given bitrate,
+// 0 when there is no cap
+func (r *RepairBudget) TrackRetransmitBitrate(allocated int64) int64 {
+	if !r.IsEnabled() {
+		return 0
+	}
+
+	// repair / (allocated + repair) <= maxShare
+	bitrate := int64(float64(allocated) * r.maxShare / (1 - r.maxShare))
+	if bitrate < minTrackRepairBitrate {
+		bitrate = minTrackRepairBitrate
+	}
+	return bitrate
+}
@@ -0,0 +1,92 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package streamallocator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepairBudget(t *testing.T) {
+	t.Run("disabled", func(t *testing.T) {
+		for _, maxShare := range []float64{0.0, 1.0} {
+			r := NewRepairBudget(maxShare)
+			require.False(t, r.IsEnabled())
+
+			now := time.Now()
+			require.False(t, r.Update(1000, 1000, now))
+			require.False(t, r.Update(1000, 1000, now.Add(2*time.Second)))
+			require.False(t, r.IsExceeded())
+			require.Zero(t, r.Reserved(1_000_000))
+			require.Zero(t, r.TrackRetransmitBitrate(1_000_000))
+		}
+	})
+
+	t.Run("measures at the end of a window", func(t *testing.T) {
+		r := NewRepairBudget(0.2)
+		now := time.Now()
+
+		require.False(t, r.Update(50_000, 25_000, now))
+		require.False(t, r.Update(50_000, 25_000, now.Add(500*time.Millisecond)))
+		require.False(t, r.IsExceeded())
+		require.Zero(t, r.Reserved(1_000_000))
+
+		// 100 KB primary + 50 KB repair in a second -> 33% share, 400 kbps repair smoothed to 200 kbps
+		require.True(t, r.Update(0, 0, now.Add(time.Second)))
+		require.True(t, r.IsExceeded())
+		require.Equal(t, int64(200_000), r.Reserved(10_000_000))
+
+		// next window starts afresh
+		require.False(t, r.Update(100_000, 0, now.Add(1500*time.Millisecond)))
+		require.True(t, r.Update(0, 0, now.Add(2*time.Second)))
+		require.False(t, r.IsExceeded())
+		require.Equal(t, int64(100_000), r.Reserved(10_000_000))
+	})
+
+	t.Run("reservation is capped at the share of capacity", func(t *testing.T) {
+		r := NewRepairBudget(0.2)
+		now := time.Now()
+
+		r.Update(0, 0, now)
+		for i := 1; i <= 10; i++ {
+			// 1 Mbps of repair
+			r.Update(0, 125_000, now.Add(time.Duration(i)*time.Second))
+		}
+		require.InDelta(t, 1_000_000, r.Reserved(100_000_000), 1000)
+		require.Equal(t, int64(200_000), r.Reserved(1_000_000))
+		require.Zero(t, r.Reserved(0))
+	})
+
+	t.Run("track retransmit bitrate", func(t *testing.T) {
+		r := NewRepairBudget(0.2)
+
+		testCases := []struct {
+			name      string
+			allocated int64
+			expected  int64
+		}{
+			{"share of allocation", 1_000_000, 250_000},
+			{"floor for small allocations", 100_000, minTrackRepairBitrate},
+			{"floor for paused tracks", 0, minTrackRepairBitrate},
+		}
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				require.Equal(t, tc.expected, r.TrackRetransmitBitrate(tc.allocated))
+			})
+		}
+	})
+}
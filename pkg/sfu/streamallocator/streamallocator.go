@@ -36,10 +36,16 @@ import (
 const (
 	ChannelCapacityInfinity = 100 * 1000 * 1000 // 100 Mbps
 
+	// fraction of channel capacity the repair reservation has to move by to trigger a re-allocation
+	repairReservationHysteresis = 0.05
+
 	PriorityMin                = uint8(1)
 	PriorityMax                = uint8(255)
+	PriorityLow                = PriorityMin
+	PriorityNormal             = uint8(128)
+	PriorityHigh               = PriorityMax
 	PriorityDefaultScreenshare = PriorityMax
-	PriorityDefaultVideo       = PriorityMin
+	PriorityDefaultVideo       = PriorityNormal
 
 	FlagAllowOvershootWhileOptimal              = true
 	FlagAllowOvershootWhileDeficient            = false
@@ -49,6 +55,20 @@ const (
 	FlagAllowOvershootInBoost                   = true
 )
 
+// layers in the order they are handed out during allocation, lowest first
+var allocationLayers = func() []buffer.VideoLayer {
+	var layers []buffer.VideoLayer
+	for spatial := int32(0); spatial <= buffer.DefaultMaxLayerSpatial; spatial++ {
+		for temporal := int32(0); temporal <= buffer.DefaultMaxLayerTemporal; temporal++ {
+			layers = append(layers, buffer.VideoLayer{
+				Spatial:  spatial,
+				Temporal: temporal,
+			})
+		}
+	}
+	return layers
+}()
+
 // ---------------------------------------------------------------------------
 
 type streamAllocatorState int
@@ -84,6 +104,8 @@ const (
 	streamAllocatorSignalResume
 	streamAllocatorSignalSetAllowPause
 	streamAllocatorSignalSetChannelCapacity
+	streamAllocatorSignalSetMaxChannelCapacity
+	streamAllocatorSignalSetBandwidthOverride
 	streamAllocatorSignalNACK
 	streamAllocatorSignalRTCPReceiverReport
 )
@@ -110,6 +132,10 @@ func (s streamAllocatorSignal) String() string {
 		return "SET_ALLOW_PAUSE"
 	case streamAllocatorSignalSetChannelCapacity:
 		return "SET_CHANNEL_CAPACITY"
+	case streamAllocatorSignalSetMaxChannelCapacity:
+		return "SET_MAX_CHANNEL_CAPACITY"
+	case streamAllocatorSignalSetBandwidthOverride:
+		return "SET_BANDWIDTH_OVERRIDE"
 	case streamAllocatorSignalNACK:
 		return "NACK"
 	case streamAllocatorSignalRTCPReceiverReport:
@@ -133,6 +159,17 @@ func (e Event) String() string {
 
 // ---------------------------------------------------------------------------
 
+// BandwidthOverride caps or forces the channel capacity used for allocation, e.g. for deterministic QoS tests.
+// Bitrates are in bps, 0 leaves the estimate as is
+type BandwidthOverride struct {
+	MaxBitrate    int64
+	ForcedBitrate int64
+	// kept free of video, e.g. for the redundancy of audio tracks
+	Reserved int64
+}
+
+// ---------------------------------------------------------------------------
+
 type StreamAllocatorParams struct {
 	Config config.CongestionControlConfig
 	Logger logger.Logger
@@ -151,6 +188,13 @@ type StreamAllocator struct {
 	committedChannelCapacity  int64
 	overriddenChannelCapacity int64
 
+	// ceiling imposed from outside, e.g. a subscriber's share of a room wide egress limit
+	maxChannelCapacity int64
+	// set by operators, replacing congestion control decisions
+	bandwidthOverride BandwidthOverride
+	// mirror of committedChannelCapacity for readers outside the event loop
+	estimatedChannelCapacity atomic.Int64
+
 	probeController *ProbeController
 
 	prober *Prober
@@ -158,11 +202,21 @@ type StreamAllocator struct {
 	channelObserver *ChannelObserver
 	rateMonitor     *RateMonitor
 
+	repairBudget   *RepairBudget
+	probeBytesSent uint32
+	// bitrate set aside for repair traffic, not available to media
+	repairReserved int64
+
 	videoTracksMu        sync.RWMutex
 	videoTracks          map[livekit.TrackID]*Track
 	isAllocateAllPending bool
 	rembTrackingSSRC     uint32
 
+	// tracks of this publisher are given the highest layers that fit before others are upgraded
+	preferredPublisherID livekit.ParticipantID
+	// sent by video tracks while paused, applied to tracks as they are added
+	pausedVideoPlaceholder config.PausedVideoPlaceholder
+
 	state streamAllocatorState
 
 	eventChMu sync.RWMutex
@@ -178,9 +232,10 @@ func NewStreamAllocator(params StreamAllocatorParams) *StreamAllocator {
 		prober: NewProber(ProberParams{
 			Logger: params.Logger,
 		}),
-		rateMonitor: NewRateMonitor(),
-		videoTracks: make(map[livekit.TrackID]*Track),
-		eventCh:     make(chan Event, 1000),
+		rateMonitor:  NewRateMonitor(),
+		repairBudget: NewRepairBudget(params.Config.MaxRepairShare),
+		videoTracks:  make(map[livekit.TrackID]*Track),
+		eventCh:      make(chan Event, 1000),
 	}
 
 	s.probeController = NewProbeController(ProbeControllerParams{
@@ -240,8 +295,10 @@ func (s *StreamAllocator) AddTrack(downTrack *sfu.DownTrack, params AddTrackPara
 
 	s.videoTracksMu.Lock()
 	s.videoTracks[livekit.TrackID(downTrack.ID())] = track
+	pausedVideoPlaceholder := s.pausedVideoPlaceholder
 	s.videoTracksMu.Unlock()
 
+	downTrack.SetPausedVideoPlaceholder(pausedVideoPlaceholder)
 	downTrack.SetStreamAllocatorListener(s)
 	if s.prober.IsRunning() {
 		// STREAM-ALLOCATOR-TODO: this can be changed to adapt to probe rate
@@ -279,6 +336,33 @@ func (s *StreamAllocator) SetTrackPriority(downTrack *sfu.DownTrack, priority ui
 	s.videoTracksMu.Unlock()
 }
 
+// SetPreferredPublisher makes the video of a publisher, e.g. the active speaker, get the highest layers
+// that fit after all tracks are given the lowest layer, other tracks are degraded first. Empty clears it.
+func (s *StreamAllocator) SetPreferredPublisher(publisherID livekit.ParticipantID) {
+	s.videoTracksMu.Lock()
+	if s.preferredPublisherID != publisherID {
+		s.preferredPublisherID = publisherID
+		if !s.isAllocateAllPending {
+			s.isAllocateAllPending = true
+			s.postEvent(Event{
+				Signal: streamAllocatorSignalAllocateAllTracks,
+			})
+		}
+	}
+	s.videoTracksMu.Unlock()
+}
+
+// SetPausedVideoPlaceholder selects what video tracks send while paused, see config.PausedVideoPlaceholder
+func (s *StreamAllocator) SetPausedVideoPlaceholder(placeholder config.PausedVideoPlaceholder) {
+	s.videoTracksMu.Lock()
+	s.pausedVideoPlaceholder = placeholder
+	s.videoTracksMu.Unlock()
+
+	for _, track := range s.getTracks() {
+		track.DownTrack().SetPausedVideoPlaceholder(placeholder)
+	}
+}
+
 func (s *StreamAllocator) SetAllowPause(allowPause bool) {
 	s.postEvent(Event{
 		Signal: streamAllocatorSignalSetAllowPause,
@@ -293,6 +377,37 @@ func (s *StreamAllocator) SetChannelCapacity(channelCapacity int64) {
 	})
 }
 
+// SetMaxChannelCapacity caps the channel capacity used for allocation, 0 removes the cap
+func (s *StreamAllocator) SetMaxChannelCapacity(maxChannelCapacity int64) {
+	s.postEvent(Event{
+		Signal: streamAllocatorSignalSetMaxChannelCapacity,
+		Data:   maxChannelCapacity,
+	})
+}
+
+// SetBandwidthOverride caps or forces the channel capacity regardless of the estimate, a forced capacity also
+// stops probing
+func (s *StreamAllocator) SetBandwidthOverride(override BandwidthOverride) {
+	s.postEvent(Event{
+		Signal: streamAllocatorSignalSetBandwidthOverride,
+		Data:   override,
+	})
+}
+
+// GetBandwidthDemand returns the bandwidth needed to forward all managed tracks at their optimal layers,
+// limited to the estimated channel capacity when an estimate is available
+func (s *StreamAllocator) GetBandwidthDemand() int64 {
+	demand := int64(0)
+	for _, track := range s.getTracks() {
+		demand += track.BandwidthOptimal()
+	}
+
+	if estimate := s.estimatedChannelCapacity.Load(); estimate > 0 && estimate < demand {
+		demand = estimate
+	}
+	return demand
+}
+
 func (s *StreamAllocator) resetState() {
 	s.channelObserver = s.newChannelObserverNonProbe()
 	s.probeController.Reset()
@@ -439,6 +554,11 @@ func (s *StreamAllocator) OnSubscribedLayerChanged(downTrack *sfu.DownTrack, lay
 	}
 }
 
+// called when subscriber hints a different priority for a track
+func (s *StreamAllocator) OnSubscriberPriorityChanged(downTrack *sfu.DownTrack) {
+	s.SetTrackPriority(downTrack, downTrack.SubscriberPriority())
+}
+
 // called when forwarder resumes a track
 func (s *StreamAllocator) OnResume(downTrack *sfu.DownTrack) {
 	s.postEvent(Event{
@@ -598,6 +718,10 @@ func (s *StreamAllocator) handleEvent(event *Event) {
 		s.handleSignalSetAllowPause(event)
 	case streamAllocatorSignalSetChannelCapacity:
 		s.handleSignalSetChannelCapacity(event)
+	case streamAllocatorSignalSetMaxChannelCapacity:
+		s.handleSignalSetMaxChannelCapacity(event)
+	case streamAllocatorSignalSetBandwidthOverride:
+		s.handleSignalSetBandwidthOverride(event)
 	case streamAllocatorSignalNACK:
 		s.handleSignalNACK(event)
 	case streamAllocatorSignalRTCPReceiverReport:
@@ -683,6 +807,7 @@ func (s *StreamAllocator) handleSignalSendProbe(event *Event) {
 
 	if bytesSent != 0 {
 		s.prober.ProbeSent(bytesSent)
+		s.probeBytesSent += uint32(bytesSent)
 	}
 }
 
@@ -719,6 +844,28 @@ func (s *StreamAllocator) handleSignalSetChannelCapacity(event *Event) {
 	}
 }
 
+func (s *StreamAllocator) handleSignalSetMaxChannelCapacity(event *Event) {
+	maxChannelCapacity := event.Data.(int64)
+	if maxChannelCapacity == s.maxChannelCapacity {
+		return
+	}
+
+	s.params.Logger.Debugw("setting max channel capacity", "old", s.maxChannelCapacity, "new", maxChannelCapacity)
+	s.maxChannelCapacity = maxChannelCapacity
+	s.allocateAllTracks()
+}
+
+func (s *StreamAllocator) handleSignalSetBandwidthOverride(event *Event) {
+	override := event.Data.(BandwidthOverride)
+	if override == s.bandwidthOverride {
+		return
+	}
+
+	s.params.Logger.Infow("setting bandwidth override", "old", s.bandwidthOverride, "new", override)
+	s.bandwidthOverride = override
+	s.allocateAllTracks()
+}
+
 func (s *StreamAllocator) handleSignalNACK(event *Event) {
 	nackInfos := event.Data.([]sfu.NackInfo)
 
@@ -836,6 +983,7 @@ func (s *StreamAllocator) handleNewEstimateInNonProbe() {
 	}
 
 	s.committedChannelCapacity = estimateToCommit
+	s.estimatedChannelCapacity.Store(estimateToCommit)
 
 	// reset to get new set of samples for next trend
 	s.channelObserver = s.newChannelObserverNonProbe()
@@ -1031,6 +1179,7 @@ func (s *StreamAllocator) onProbeDone(isNotFailing bool, isGoalReached bool) {
 
 	if highestEstimateInProbe > s.committedChannelCapacity {
 		s.committedChannelCapacity = highestEstimateInProbe
+		s.estimatedChannelCapacity.Store(highestEstimateInProbe)
 	}
 
 	s.maybeBoostDeficientTracks()
@@ -1137,21 +1286,19 @@ func (s *StreamAllocator) allocateAllTracks() {
 			track.ProvisionalAllocatePrepare()
 		}
 
-		for spatial := int32(0); spatial <= buffer.DefaultMaxLayerSpatial; spatial++ {
-			for temporal := int32(0); temporal <= buffer.DefaultMaxLayerTemporal; temporal++ {
-				layer := buffer.VideoLayer{
-					Spatial:  spatial,
-					Temporal: temporal,
-				}
-
-				for _, track := range sorted {
-					_, usedChannelCapacity := track.ProvisionalAllocate(availableChannelCapacity, layer, s.allowPause, FlagAllowOvershootWhileDeficient)
-					availableChannelCapacity -= usedChannelCapacity
-					if availableChannelCapacity < 0 {
-						availableChannelCapacity = 0
-					}
-				}
+		preferred, others := s.partitionPreferred(sorted)
+		if len(preferred) == 0 {
+			s.provisionalAllocate(sorted, allocationLayers, &availableChannelCapacity)
+		} else {
+			//
+			// Every track gets a shot at the lowest layer to keep as many tracks streaming as possible.
+			// Preferred tracks then take the highest layers that fit before the rest are upgraded layer-by-layer.
+			//
+			s.provisionalAllocate(sorted, allocationLayers[:1], &availableChannelCapacity)
+			for _, track := range preferred {
+				s.provisionalAllocate([]*Track{track}, allocationLayers[1:], &availableChannelCapacity)
 			}
+			s.provisionalAllocate(others, allocationLayers[1:], &availableChannelCapacity)
 		}
 
 		for _, track := range sorted {
@@ -1165,6 +1312,38 @@ func (s *StreamAllocator) allocateAllTracks() {
 	s.adjustState()
 }
 
+func (s *StreamAllocator) provisionalAllocate(tracks []*Track, layers []buffer.VideoLayer, availableChannelCapacity *int64) {
+	for _, layer := range layers {
+		for _, track := range tracks {
+			_, usedChannelCapacity := track.ProvisionalAllocate(*availableChannelCapacity, layer, s.allowPause, FlagAllowOvershootWhileDeficient)
+			*availableChannelCapacity -= usedChannelCapacity
+			if *availableChannelCapacity < 0 {
+				*availableChannelCapacity = 0
+			}
+		}
+	}
+}
+
+func (s *StreamAllocator) partitionPreferred(tracks []*Track) ([]*Track, []*Track) {
+	s.videoTracksMu.RLock()
+	preferredPublisherID := s.preferredPublisherID
+	s.videoTracksMu.RUnlock()
+
+	if preferredPublisherID == "" {
+		return nil, tracks
+	}
+
+	var preferred, others []*Track
+	for _, track := range tracks {
+		if track.PublisherID() == preferredPublisherID {
+			preferred = append(preferred, track)
+		} else {
+			others = append(others, track)
+		}
+	}
+	return preferred, others
+}
+
 func (s *StreamAllocator) maybeSendUpdate(update *StreamStateUpdate) {
 	if update.Empty() {
 		return
@@ -1203,6 +1382,27 @@ func (s *StreamAllocator) getAvailableChannelCapacity(allowOverride bool) int64
 			"override", availableChannelCapacity,
 		)
 	}
+	if s.bandwidthOverride.ForcedBitrate > 0 {
+		availableChannelCapacity = s.bandwidthOverride.ForcedBitrate
+	}
+	if s.maxChannelCapacity > 0 && availableChannelCapacity > s.maxChannelCapacity {
+		availableChannelCapacity = s.maxChannelCapacity
+	}
+	if s.bandwidthOverride.MaxBitrate > 0 && availableChannelCapacity > s.bandwidthOverride.MaxBitrate {
+		availableChannelCapacity = s.bandwidthOverride.MaxBitrate
+	}
+	if s.bandwidthOverride.Reserved > 0 {
+		availableChannelCapacity -= s.bandwidthOverride.Reserved
+		if availableChannelCapacity < 0 {
+			availableChannelCapacity = 0
+		}
+	}
+	if s.repairReserved > 0 {
+		availableChannelCapacity -= s.repairReserved
+		if availableChannelCapacity < 0 {
+			availableChannelCapacity = 0
+		}
+	}
 
 	return availableChannelCapacity
 }
@@ -1298,10 +1498,22 @@ func (s *StreamAllocator) initProbe(probeGoalDeltaBps int64) {
 }
 
 func (s *StreamAllocator) maybeProbe() {
-	if s.overriddenChannelCapacity > 0 {
+	if s.overriddenChannelCapacity > 0 || s.bandwidthOverride.ForcedBitrate > 0 {
 		// do not probe if channel capacity is overridden
 		return
 	}
+	if s.bandwidthOverride.MaxBitrate > 0 && s.committedChannelCapacity >= s.bandwidthOverride.MaxBitrate {
+		// probing beyond the cap of the operator is pointless
+		return
+	}
+	if s.maxChannelCapacity > 0 && s.committedChannelCapacity >= s.maxChannelCapacity {
+		// already able to use all of the allowed capacity
+		return
+	}
+	if s.repairBudget.IsExceeded() {
+		// probing would add to repair traffic which is already over budget
+		return
+	}
 	if !s.probeController.CanProbe() {
 		return
 	}
@@ -1432,6 +1644,40 @@ func (s *StreamAllocator) monitorRate(estimate int64) {
 	}
 
 	s.rateMonitor.Update(estimate, managedBytesSent, managedBytesRetransmitted, unmanagedBytesSent, unmanagedBytesRetransmitted)
+
+	probeBytesSent := s.probeBytesSent
+	s.probeBytesSent = 0
+	if s.repairBudget.Update(
+		managedBytesSent+unmanagedBytesSent,
+		managedBytesRetransmitted+unmanagedBytesRetransmitted+probeBytesSent,
+		time.Now(),
+	) {
+		s.updateRepairReservation()
+	}
+}
+
+// updateRepairReservation sets aside the measured repair bitrate from the capacity media is allocated from,
+// re-allocating when it moved noticeably, and caps retransmissions of each track at its share
+func (s *StreamAllocator) updateRepairReservation() {
+	reserved := s.repairBudget.Reserved(s.committedChannelCapacity)
+	delta := reserved - s.repairReserved
+	if delta < 0 {
+		delta = -delta
+	}
+	if float64(delta) > float64(s.committedChannelCapacity)*repairReservationHysteresis {
+		s.params.Logger.Debugw(
+			"stream allocator: repair reservation change",
+			"old(bps)", s.repairReserved,
+			"new(bps)", reserved,
+			"exceeded", s.repairBudget.IsExceeded(),
+		)
+		s.repairReserved = reserved
+		s.allocateAllTracks()
+	}
+
+	for _, track := range s.getTracks() {
+		track.SetMaxRetransmitBitrate(s.repairBudget.TrackRetransmitBitrate(track.BandwidthRequested()))
+	}
 }
 
 func (s *StreamAllocator) updateTracksHistory() {
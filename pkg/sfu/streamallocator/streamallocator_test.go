@@ -0,0 +1,61 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package streamallocator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/protocol/livekit"
+
+	"github.com/livekit/livekit-server/pkg/sfu/buffer"
+)
+
+func TestPartitionPreferred(t *testing.T) {
+	a1 := &Track{publisherID: "a"}
+	a2 := &Track{publisherID: "a"}
+	b := &Track{publisherID: "b"}
+
+	testCases := []struct {
+		name      string
+		preferred livekit.ParticipantID
+		tracks    []*Track
+		expected  []*Track
+		others    []*Track
+	}{
+		{name: "none preferred", tracks: []*Track{a1, b}, others: []*Track{a1, b}},
+		{name: "all tracks of the publisher", preferred: "a", tracks: []*Track{a1, b, a2}, expected: []*Track{a1, a2}, others: []*Track{b}},
+		{name: "publisher not subscribed", preferred: "c", tracks: []*Track{a1, b}, others: []*Track{a1, b}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := &StreamAllocator{preferredPublisherID: tc.preferred}
+			preferred, others := s.partitionPreferred(tc.tracks)
+			require.Equal(t, tc.expected, preferred)
+			require.Equal(t, tc.others, others)
+		})
+	}
+}
+
+func TestAllocationLayers(t *testing.T) {
+	// lowest first, the first layer is handed to every track before preferred tracks are upgraded
+	require.Len(t, allocationLayers, int((buffer.DefaultMaxLayerSpatial+1)*(buffer.DefaultMaxLayerTemporal+1)))
+	require.Equal(t, int32(0), allocationLayers[0].Spatial)
+	require.Equal(t, int32(0), allocationLayers[0].Temporal)
+	for i := 1; i < len(allocationLayers); i++ {
+		require.True(t, allocationLayers[i].GreaterThan(allocationLayers[i-1]))
+	}
+}
@@ -200,6 +200,10 @@ func (t *Track) BandwidthRequested() int64 {
 	return t.downTrack.BandwidthRequested()
 }
 
+func (t *Track) BandwidthOptimal() int64 {
+	return t.downTrack.BandwidthOptimal()
+}
+
 func (t *Track) DistanceToDesired() float64 {
 	return t.downTrack.DistanceToDesired()
 }
@@ -300,6 +304,10 @@ func (t *Track) GetAndResetBytesSent() (uint32, uint32) {
 	return t.downTrack.GetAndResetBytesSent()
 }
 
+func (t *Track) SetMaxRetransmitBitrate(bitrate int64) {
+	t.downTrack.SetMaxRetransmitBitrate(bitrate)
+}
+
 func (t *Track) UpdateHistory() {
 	t.updateNackHistory()
 }
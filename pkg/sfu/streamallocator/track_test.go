@@ -0,0 +1,91 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package streamallocator
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/protocol/livekit"
+)
+
+func TestTrackPriority(t *testing.T) {
+	t.Run("defaults by source when unset", func(t *testing.T) {
+		video := &Track{source: livekit.TrackSource_CAMERA}
+		require.True(t, video.SetPriority(0))
+		require.Equal(t, PriorityDefaultVideo, video.Priority())
+		require.Equal(t, PriorityNormal, video.Priority())
+
+		screenshare := &Track{source: livekit.TrackSource_SCREEN_SHARE}
+		require.True(t, screenshare.SetPriority(0))
+		require.Equal(t, PriorityDefaultScreenshare, screenshare.Priority())
+	})
+
+	t.Run("explicit priority overrides default", func(t *testing.T) {
+		track := &Track{source: livekit.TrackSource_CAMERA}
+		track.SetPriority(0)
+
+		require.True(t, track.SetPriority(PriorityHigh))
+		require.Equal(t, PriorityHigh, track.Priority())
+		require.False(t, track.SetPriority(PriorityHigh))
+
+		require.True(t, track.SetPriority(PriorityLow))
+		require.Equal(t, PriorityLow, track.Priority())
+
+		// clearing goes back to the default
+		require.True(t, track.SetPriority(0))
+		require.Equal(t, PriorityDefaultVideo, track.Priority())
+
+		// normal is the default for video, hinting it does not boost the track
+		require.False(t, track.SetPriority(PriorityNormal))
+	})
+}
+
+func TestTrackPriorityOrder(t *testing.T) {
+	require.Less(t, PriorityLow, PriorityDefaultVideo)
+	require.Less(t, PriorityDefaultVideo, PriorityHigh)
+
+	newTracks := func() []*Track {
+		return []*Track{
+			{source: livekit.TrackSource_CAMERA, priority: PriorityDefaultVideo},
+			{source: livekit.TrackSource_CAMERA, priority: PriorityLow},
+			{source: livekit.TrackSource_CAMERA, priority: PriorityHigh},
+		}
+	}
+
+	t.Run("low priority track gives up bandwidth first", func(t *testing.T) {
+		tracks := MinDistanceSorter(newTracks())
+		sort.Sort(tracks)
+		require.Equal(t, PriorityLow, tracks[0].Priority())
+		require.Equal(t, PriorityDefaultVideo, tracks[1].Priority())
+		require.Equal(t, PriorityHigh, tracks[2].Priority())
+	})
+
+	t.Run("low priority track is allocated last", func(t *testing.T) {
+		tracks := TrackSorter(newTracks())
+		sort.Sort(tracks)
+		require.Equal(t, PriorityHigh, tracks[0].Priority())
+		require.Equal(t, PriorityDefaultVideo, tracks[1].Priority())
+		require.Equal(t, PriorityLow, tracks[2].Priority())
+	})
+
+	t.Run("low priority track recovers last", func(t *testing.T) {
+		tracks := MaxDistanceSorter(newTracks())
+		sort.Sort(tracks)
+		require.Equal(t, PriorityLow, tracks[2].Priority())
+	})
+}
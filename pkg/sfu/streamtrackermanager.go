@@ -74,6 +74,9 @@ type StreamTrackerManager struct {
 	maxExpectedLayer int32
 	paused           bool
 
+	// highest layer the publisher actually sends, can be lower than what track info declares
+	maxExpectedLayerLimit int32
+
 	senderReportMu sync.RWMutex
 	senderReports  [buffer.DefaultMaxLayerSpatial + 1]endsSenderReport
 	layerOffsets   [buffer.DefaultMaxLayerSpatial + 1][buffer.DefaultMaxLayerSpatial + 1]uint64
@@ -98,6 +101,8 @@ func NewStreamTrackerManager(
 		maxTemporalLayerSeen: buffer.InvalidLayerTemporal,
 		clockRate:            clockRate,
 		closed:               core.NewFuse(),
+
+		maxExpectedLayerLimit: buffer.DefaultMaxLayerSpatial,
 	}
 
 	switch s.trackInfo.Source {
@@ -314,6 +319,9 @@ func (s *StreamTrackerManager) IsPaused() bool {
 
 func (s *StreamTrackerManager) SetMaxExpectedSpatialLayer(layer int32) int32 {
 	s.lock.Lock()
+	if layer > s.maxExpectedLayerLimit {
+		layer = s.maxExpectedLayerLimit
+	}
 	prev := s.maxExpectedLayer
 	if layer <= s.maxExpectedLayer {
 		// some higher layer(s) expected to stop, nothing else to do
@@ -353,6 +361,43 @@ func (s *StreamTrackerManager) SetMaxExpectedSpatialLayer(layer int32) int32 {
 	return prev
 }
 
+// LimitMaxExpectedSpatialLayer stops expecting layers above the given one,
+// used when a publisher does not send all the layers declared in track info
+func (s *StreamTrackerManager) LimitMaxExpectedSpatialLayer(layer int32) {
+	s.lock.Lock()
+	s.maxExpectedLayerLimit = layer
+	if s.maxExpectedLayer > layer {
+		s.maxExpectedLayer = layer
+	}
+	s.lock.Unlock()
+}
+
+// ResetLayers restarts tracking of layers whose streams changed, e.g. after the layers are reordered
+func (s *StreamTrackerManager) ResetLayers(layers []int32) {
+	s.lock.RLock()
+	var trackersToReset []streamtracker.StreamTrackerWorker
+	for _, layer := range layers {
+		if s.trackers[layer] != nil {
+			trackersToReset = append(trackersToReset, s.trackers[layer])
+		}
+	}
+	s.lock.RUnlock()
+
+	for _, tracker := range trackersToReset {
+		tracker.Reset()
+	}
+
+	s.senderReportMu.Lock()
+	for _, layer := range layers {
+		s.senderReports[layer] = endsSenderReport{}
+		for other := range s.layerOffsets {
+			s.layerOffsets[layer][other] = 0
+			s.layerOffsets[other][layer] = 0
+		}
+	}
+	s.senderReportMu.Unlock()
+}
+
 func (s *StreamTrackerManager) DistanceToDesired() float64 {
 	s.lock.RLock()
 	defer s.lock.RUnlock()
@@ -542,6 +587,9 @@ func (s *StreamTrackerManager) maxExpectedLayerFromTrackInfo() {
 			s.maxExpectedLayer = spatialLayer
 		}
 	}
+	if s.maxExpectedLayer > s.maxExpectedLayerLimit {
+		s.maxExpectedLayer = s.maxExpectedLayerLimit
+	}
 }
 
 func (s *StreamTrackerManager) updateLayerOffsetLocked(ref, other int32) {
@@ -0,0 +1,300 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sfu
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pion/rtp"
+	"github.com/pion/rtp/codecs"
+	"github.com/pion/webrtc/v3"
+	"go.uber.org/atomic"
+
+	"github.com/livekit/mediatransportutil/pkg/bucket"
+	"github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/logger"
+
+	"github.com/livekit/livekit-server/pkg/sfu/buffer"
+	"github.com/livekit/livekit-server/pkg/transcode"
+)
+
+const (
+	// packets queued for transcoding, packets that don't fit are dropped as if they were lost
+	transcodeQueueSize = 512
+	// payload size of the packets of transcoded frames, leaving room for header extensions
+	transcodedPayloadSize = 1200
+)
+
+type transcodePacket struct {
+	payload           []byte
+	marker            bool
+	extSequenceNumber uint64
+	extTimestamp      uint64
+	keyFrame          bool
+	// padding and upper spatial layers of SVC streams are not decoded, they only keep the sequence
+	skip bool
+}
+
+// TranscodedReceiver forwards a single rendition of the track transcoded to another codec, to subscribers that
+// cannot decode the codecs it is published with. The lowest simulcast layer, or the base spatial layer of SVC
+// codecs, is decoded and encoded at a fixed bitrate by a worker so that the forwarding path is not held up
+type TranscodedReceiver struct {
+	TrackReceiver
+	codec             webrtc.RTPCodecParameters
+	bitrate           int
+	downTrackSpreader *DownTrackSpreader
+	logger            logger.Logger
+	closed            atomic.Bool
+	closeOnce         sync.Once
+	workerOnce        sync.Once
+	closeChan         chan struct{}
+	packets           chan transcodePacket
+	forceKeyFrame     atomic.Bool
+
+	newTranscoder func(params transcode.Params) (transcode.Transcoder, error)
+
+	// only accessed by the worker
+	frameBuilder       *transcode.FrameBuilder
+	transcoder         transcode.Transcoder
+	transcoderFailed   bool
+	payloader          codecs.H264Payloader
+	started            bool
+	lastSequenceNumber uint64
+	waitKeyFrame       bool
+	extSequenceNumber  uint64
+}
+
+func NewTranscodedReceiver(receiver TrackReceiver, codec webrtc.RTPCodecParameters, bitrate int, dsp DownTrackSpreaderParams) (*TranscodedReceiver, error) {
+	frameBuilder, err := transcode.NewFrameBuilder(receiver.Codec().MimeType)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TranscodedReceiver{
+		TrackReceiver:     receiver,
+		codec:             codec,
+		bitrate:           bitrate,
+		downTrackSpreader: NewDownTrackSpreader(dsp),
+		logger:            dsp.Logger,
+		closeChan:         make(chan struct{}),
+		packets:           make(chan transcodePacket, transcodeQueueSize),
+		newTranscoder:     transcode.NewTranscoder,
+		frameBuilder:      frameBuilder,
+		waitKeyFrame:      true,
+	}, nil
+}
+
+// ForwardRTP queues packets of the stream received on layer for transcoding
+func (r *TranscodedReceiver) ForwardRTP(pkt *buffer.ExtPacket, layer int32) {
+	if layer != 0 || r.closed.Load() || r.downTrackSpreader.DownTrackCount() == 0 {
+		return
+	}
+
+	tp := transcodePacket{
+		marker:            pkt.Packet.Marker,
+		extSequenceNumber: pkt.ExtSequenceNumber,
+		extTimestamp:      pkt.ExtTimestamp,
+		keyFrame:          pkt.KeyFrame,
+		skip:              len(pkt.Packet.Payload) == 0 || pkt.Spatial > 0,
+	}
+	if !tp.skip {
+		// the packet buffer is reused by the forwarding path
+		tp.payload = append([]byte(nil), pkt.Packet.Payload...)
+	}
+
+	select {
+	case r.packets <- tp:
+	default:
+		// transcoding falls behind, the worker recovers from the gap like from a loss
+	}
+}
+
+func (r *TranscodedReceiver) Codec() webrtc.RTPCodecParameters {
+	return r.codec
+}
+
+// GetLayeredBitrate reports the transcoded rendition as the only layer, available while the transcoded layer is
+func (r *TranscodedReceiver) GetLayeredBitrate() ([]int32, Bitrates) {
+	var brs Bitrates
+	available, _ := r.TrackReceiver.GetLayeredBitrate()
+	for _, layer := range available {
+		if layer == 0 {
+			brs[0][0] = int64(r.bitrate)
+			return []int32{0}, brs
+		}
+	}
+	return nil, brs
+}
+
+// SendPLI has the next frame encoded as a key frame, the publisher is only asked for one when decoding needs it
+func (r *TranscodedReceiver) SendPLI(_ int32, _ bool) {
+	r.forceKeyFrame.Store(true)
+}
+
+func (r *TranscodedReceiver) AddDownTrack(track TrackSender) error {
+	if r.closed.Load() {
+		return ErrReceiverClosed
+	}
+
+	if r.downTrackSpreader.HasDownTrack(track.SubscriberID()) {
+		r.logger.Infow("subscriberID already exists, replacing downtrack", "subscriberID", track.SubscriberID())
+	}
+
+	r.downTrackSpreader.Store(track)
+	r.workerOnce.Do(func() {
+		go r.worker()
+	})
+	return nil
+}
+
+func (r *TranscodedReceiver) DeleteDownTrack(subscriberID livekit.ParticipantID) {
+	if r.closed.Load() {
+		return
+	}
+
+	r.downTrackSpreader.Free(subscriberID)
+}
+
+func (r *TranscodedReceiver) CanClose() bool {
+	return r.closed.Load() || r.downTrackSpreader.DownTrackCount() == 0
+}
+
+func (r *TranscodedReceiver) IsClosed() bool {
+	return r.closed.Load()
+}
+
+func (r *TranscodedReceiver) Close() {
+	r.closeOnce.Do(func() {
+		r.closed.Store(true)
+		close(r.closeChan)
+		closeTrackSenders(r.downTrackSpreader.ResetAndGetDownTracks())
+	})
+}
+
+func (r *TranscodedReceiver) ReadRTP(buf []byte, layer uint8, sn uint16) (int, error) {
+	// transcoded packets are not kept for retransmission
+	return 0, bucket.ErrPacketMismatch
+}
+
+func (r *TranscodedReceiver) worker() {
+	defer func() {
+		if r.transcoder != nil {
+			r.transcoder.Close()
+		}
+	}()
+
+	for {
+		select {
+		case <-r.closeChan:
+			return
+		case tp := <-r.packets:
+			r.process(tp)
+		}
+	}
+}
+
+func (r *TranscodedReceiver) process(tp transcodePacket) {
+	if r.started && tp.extSequenceNumber != r.lastSequenceNumber+1 {
+		// packets were lost or dropped, decoding resumes at the next key frame
+		r.frameBuilder.Reset()
+		r.waitKeyFrame = true
+	}
+	r.started = true
+	r.lastSequenceNumber = tp.extSequenceNumber
+	if tp.skip {
+		return
+	}
+
+	frames, err := r.frameBuilder.Push(&rtp.Packet{Header: rtp.Header{Marker: tp.marker}, Payload: tp.payload}, tp.extTimestamp, tp.keyFrame)
+	for _, frame := range frames {
+		r.transcodeFrame(frame)
+	}
+	if err != nil {
+		r.logger.Debugw("could not depacketize transcoded layer", err)
+		r.waitKeyFrame = true
+	}
+}
+
+func (r *TranscodedReceiver) transcodeFrame(frame transcode.Frame) {
+	if r.transcoderFailed {
+		return
+	}
+	if r.waitKeyFrame {
+		if !frame.KeyFrame {
+			r.TrackReceiver.SendPLI(0, false)
+			return
+		}
+		r.waitKeyFrame = false
+	}
+
+	if r.transcoder == nil {
+		transcoder, err := r.newTranscoder(transcode.Params{
+			SourceMime: r.TrackReceiver.Codec().MimeType,
+			TargetMime: r.codec.MimeType,
+			Bitrate:    r.bitrate,
+		})
+		if err != nil {
+			r.transcoderFailed = true
+			r.logger.Errorw("could not create transcoder", err,
+				"source", r.TrackReceiver.Codec().MimeType,
+				"target", r.codec.MimeType,
+			)
+			return
+		}
+		r.transcoder = transcoder
+	}
+
+	encoded, err := r.transcoder.Transcode(frame, r.forceKeyFrame.Swap(false))
+	for _, f := range encoded {
+		r.send(f)
+	}
+	if err != nil {
+		r.logger.Warnw("could not transcode frame", err)
+		r.waitKeyFrame = true
+	}
+}
+
+func (r *TranscodedReceiver) send(frame transcode.Frame) {
+	payloads := r.payloader.Payload(transcodedPayloadSize, frame.Data)
+	for i, payload := range payloads {
+		r.extSequenceNumber++
+		pkt := &buffer.ExtPacket{
+			VideoLayer: buffer.VideoLayer{
+				Spatial:  buffer.InvalidLayerSpatial,
+				Temporal: 0,
+			},
+			Arrival:           time.Now(),
+			ExtSequenceNumber: r.extSequenceNumber,
+			ExtTimestamp:      frame.Timestamp,
+			Packet: &rtp.Packet{
+				Header: rtp.Header{
+					Version:        2,
+					Marker:         i == len(payloads)-1,
+					PayloadType:    uint8(r.codec.PayloadType),
+					SequenceNumber: uint16(r.extSequenceNumber),
+					Timestamp:      uint32(frame.Timestamp),
+				},
+				Payload: payload,
+			},
+			KeyFrame: buffer.IsH264KeyFrame(payload),
+		}
+
+		// not setting ExtPacket.RawPacket as it is not used by the DownTrack
+		r.downTrackSpreader.Broadcast(func(dt TrackSender) {
+			_ = dt.WriteRTP(pkt, 0)
+		})
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sfu
+
+import (
+	"testing"
+
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/livekit-server/pkg/transcode"
+)
+
+type fakeTranscoder struct {
+	frames         []transcode.Frame
+	forcedKeyFrame []bool
+}
+
+func (f *fakeTranscoder) Transcode(frame transcode.Frame, forceKeyFrame bool) ([]transcode.Frame, error) {
+	f.frames = append(f.frames, frame)
+	f.forcedKeyFrame = append(f.forcedKeyFrame, forceKeyFrame)
+	// IDR slice
+	return []transcode.Frame{{Data: []byte{0x00, 0x00, 0x00, 0x01, 0x65, 0xaa}, Timestamp: frame.Timestamp, KeyFrame: true}}, nil
+}
+
+func (f *fakeTranscoder) Close() {}
+
+func TestTranscodedReceiver(t *testing.T) {
+	h264 := webrtc.RTPCodecParameters{
+		RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeH264, ClockRate: 90000},
+		PayloadType:        125,
+	}
+
+	w := &WebRTCReceiver{
+		kind:  webrtc.RTPCodecTypeVideo,
+		codec: webrtc.RTPCodecParameters{RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeVP8, ClockRate: 90000}},
+	}
+	require.Equal(t, w, w.GetTranscodedReceiver(h264), "transcoding is not enabled")
+
+	w.transcodeBitrate = 500_000
+	require.Equal(t, w, w.GetTranscodedReceiver(webrtc.RTPCodecParameters{
+		RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeVP9},
+	}), "only h264 is encoded")
+
+	tr, ok := w.GetTranscodedReceiver(h264).(*TranscodedReceiver)
+	require.True(t, ok)
+	require.Equal(t, tr, w.GetTranscodedReceiver(h264))
+	require.Equal(t, h264, tr.Codec())
+
+	transcoder := &fakeTranscoder{}
+	tr.newTranscoder = func(params transcode.Params) (transcode.Transcoder, error) {
+		require.Equal(t, transcode.Params{SourceMime: webrtc.MimeTypeVP8, TargetMime: webrtc.MimeTypeH264, Bitrate: 500_000}, params)
+		return transcoder, nil
+	}
+	dt := &dummyDowntrack{TrackSender: &DownTrack{}}
+	tr.downTrackSpreader.Store(dt)
+
+	// decoding starts at a key frame
+	tr.process(transcodePacket{payload: []byte{0x10, 0x01}, marker: true, extSequenceNumber: 10, extTimestamp: 3000})
+	require.Empty(t, transcoder.frames)
+
+	tr.process(transcodePacket{payload: []byte{0x10, 0x02}, marker: true, extSequenceNumber: 11, extTimestamp: 6000, keyFrame: true})
+	require.Equal(t, []transcode.Frame{{Data: []byte{0x02}, Timestamp: 6000, KeyFrame: true}}, transcoder.frames)
+	require.Len(t, dt.receivedPkts, 1)
+	require.True(t, dt.lastReceivedPkt.Marker)
+	require.Equal(t, uint8(125), dt.lastReceivedPkt.PayloadType)
+	require.Equal(t, uint32(6000), dt.lastReceivedPkt.Timestamp)
+	require.Equal(t, []byte{0x65, 0xaa}, dt.lastReceivedPkt.Payload)
+
+	// key frames asked for by subscribers are encoded
+	tr.SendPLI(0, false)
+	tr.process(transcodePacket{payload: []byte{0x10, 0x03}, marker: true, extSequenceNumber: 12, extTimestamp: 9000})
+	require.Equal(t, []bool{false, true}, transcoder.forcedKeyFrame)
+	require.Equal(t, uint16(dt.receivedPkts[0].SequenceNumber+1), dt.lastReceivedPkt.SequenceNumber)
+
+	// after a loss, frames are dropped up to the next key frame
+	tr.process(transcodePacket{payload: []byte{0x10, 0x05}, marker: true, extSequenceNumber: 14, extTimestamp: 15000})
+	tr.process(transcodePacket{skip: true, extSequenceNumber: 15})
+	tr.process(transcodePacket{payload: []byte{0x10, 0x06}, marker: true, extSequenceNumber: 16, extTimestamp: 18000})
+	require.Len(t, transcoder.frames, 2)
+	tr.process(transcodePacket{payload: []byte{0x10, 0x07}, marker: true, extSequenceNumber: 17, extTimestamp: 21000, keyFrame: true})
+	require.Len(t, transcoder.frames, 3)
+
+	tr.Close()
+	require.True(t, tr.IsClosed())
+	require.True(t, dt.IsClosed())
+	require.ErrorIs(t, tr.AddDownTrack(dt), ErrReceiverClosed)
+}
@@ -0,0 +1,83 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package twcc
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	piontwcc "github.com/pion/interceptor/pkg/twcc"
+	"github.com/pion/rtcp"
+)
+
+const (
+	DefaultFeedbackInterval = 100 * time.Millisecond
+
+	minPacketsPerFeedback = 20
+	maxPacketsPerFeedback = 100
+)
+
+// Responder builds transport-wide congestion control feedback for all streams of a transport.
+// Feedback is sent every FeedbackInterval, or after half of it on frame boundaries so that
+// the sender gets timely information at the end of a frame.
+type Responder struct {
+	sync.Mutex
+
+	mSSRC            uint32
+	sSSRC            uint32
+	feedbackInterval int64
+	lastReport       int64
+	recorder         *piontwcc.Recorder
+
+	onFeedback func(packet []rtcp.Packet)
+}
+
+func NewTransportWideCCResponder(mSSRC uint32, feedbackInterval time.Duration) *Responder {
+	if feedbackInterval <= 0 {
+		feedbackInterval = DefaultFeedbackInterval
+	}
+	sSSRC := rand.Uint32()
+	return &Responder{
+		sSSRC:            sSSRC,
+		mSSRC:            mSSRC,
+		feedbackInterval: feedbackInterval.Nanoseconds(),
+		recorder:         piontwcc.NewRecorder(sSSRC),
+	}
+}
+
+// Push records a transport-wide sequence number read from the header extension of an incoming packet
+func (t *Responder) Push(sn uint16, timeNS int64, marker bool) {
+	t.Lock()
+	defer t.Unlock()
+
+	t.recorder.Record(t.mSSRC, sn, timeNS/1000)
+
+	delta := timeNS - t.lastReport
+	if t.recorder.PacketsHeld() > minPacketsPerFeedback && t.mSSRC != 0 &&
+		(delta >= t.feedbackInterval ||
+			t.recorder.PacketsHeld() > maxPacketsPerFeedback ||
+			(marker && delta >= t.feedbackInterval/2)) {
+		if pkts := t.recorder.BuildFeedbackPacket(); pkts != nil && t.onFeedback != nil {
+			t.onFeedback(pkts)
+		}
+		t.lastReport = timeNS
+	}
+}
+
+// OnFeedback sets the callback for the formed feedback packets
+func (t *Responder) OnFeedback(f func(pkts []rtcp.Packet)) {
+	t.onFeedback = f
+}
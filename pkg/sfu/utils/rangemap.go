@@ -16,7 +16,9 @@ package utils
 
 import (
 	"errors"
+	"fmt"
 	"math"
+	"strings"
 	"unsafe"
 )
 
@@ -151,6 +153,22 @@ func (r *RangeMap[RT, VT]) GetValue(key RT) (VT, error) {
 	return 0, errKeyNotFound
 }
 
+func (r *RangeMap[RT, VT]) String() string {
+	var sb strings.Builder
+	for idx, rv := range r.ranges {
+		if idx != 0 {
+			sb.WriteString(" ")
+		}
+		if idx == len(r.ranges)-1 {
+			// open range
+			sb.WriteString(fmt.Sprintf("[%d-]:%d", rv.start, rv.value))
+		} else {
+			sb.WriteString(fmt.Sprintf("[%d-%d]:%d", rv.start, rv.end, rv.value))
+		}
+	}
+	return sb.String()
+}
+
 func (r *RangeMap[RT, VT]) prune() {
 	if len(r.ranges) > r.size+1 { // +1 to accommodate the open range
 		r.ranges = r.ranges[len(r.ranges)-r.size-1:]
@@ -301,3 +301,15 @@ func TestRangeMapUint32(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, uint32(10), value)
 }
+
+func TestRangeMapString(t *testing.T) {
+	r := NewRangeMap[uint64, uint64](2)
+	require.Equal(t, "[0-]:0", r.String())
+
+	require.NoError(t, r.ExcludeRange(10, 11))
+	require.NoError(t, r.ExcludeRange(20, 22))
+	require.Equal(t, "[0-9]:0 [11-19]:1 [22-]:3", r.String())
+
+	r.ClearAndResetValue(5)
+	require.Equal(t, "[0-]:5", r.String())
+}
@@ -0,0 +1,101 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package videolayerselector
+
+import (
+	"github.com/livekit/livekit-server/pkg/sfu/buffer"
+	"github.com/livekit/protocol/logger"
+)
+
+// AV1 selects layers of AV1 streams sent without dependency descriptor, using the layers of OBU extension
+// headers. Without the switching points signalled by the descriptor, spatial layers are switched up on key
+// frames only and other switches happen at the start of a temporal unit
+type AV1 struct {
+	*Base
+}
+
+func NewAV1(logger logger.Logger) *AV1 {
+	return &AV1{
+		Base: NewBase(logger),
+	}
+}
+
+func NewAV1FromNull(vls VideoLayerSelector) *AV1 {
+	return &AV1{
+		Base: vls.(*Null).Base,
+	}
+}
+
+func (a *AV1) IsOvershootOkay() bool {
+	return false
+}
+
+func (a *AV1) Select(extPkt *buffer.ExtPacket, _layer int32) (result VideoLayerSelectorResult) {
+	av1, ok := extPkt.Payload.(buffer.AV1)
+	if !ok {
+		return
+	}
+
+	// a temporal unit starts with the base spatial layer, layers of the previous one have all been forwarded
+	isTemporalUnitStart := !av1.Z && extPkt.VideoLayer.Spatial == 0
+
+	if a.currentLayer != a.targetLayer {
+		updatedLayer := a.currentLayer
+
+		if !a.currentLayer.IsValid() || (extPkt.KeyFrame && a.currentLayer.Spatial < a.targetLayer.Spatial) {
+			// all layers of a key frame temporal unit are decodable
+			if extPkt.KeyFrame {
+				updatedLayer = a.targetLayer
+			}
+		} else if isTemporalUnitStart {
+			if a.currentLayer.Temporal < a.targetLayer.Temporal {
+				// temporal scale up, frames of the base layer and of the next layer only refer to forwarded frames
+				switch {
+				case extPkt.VideoLayer.Temporal == 0:
+					updatedLayer.Temporal = a.targetLayer.Temporal
+				case extPkt.VideoLayer.Temporal == a.currentLayer.Temporal+1:
+					updatedLayer.Temporal = extPkt.VideoLayer.Temporal
+				}
+			} else {
+				// temporal scale down
+				updatedLayer.Temporal = a.targetLayer.Temporal
+			}
+
+			if a.currentLayer.Spatial > a.targetLayer.Spatial {
+				// spatial scale down, spatial scale up waits for a key frame
+				updatedLayer.Spatial = a.targetLayer.Spatial
+			}
+		}
+
+		if updatedLayer != a.currentLayer {
+			result.IsSwitching = true
+			if !a.currentLayer.IsValid() && updatedLayer.IsValid() {
+				result.IsResuming = true
+			}
+
+			a.previousLayer = a.currentLayer
+			a.currentLayer = updatedLayer
+		}
+	}
+
+	result.RTPMarker = extPkt.Packet.Marker
+	if extPkt.VideoLayer.Spatial == a.currentLayer.Spatial && av1.EndsFrame() {
+		// higher spatial layers of the temporal unit are dropped
+		result.RTPMarker = true
+	}
+	result.IsSelected = extPkt.VideoLayer.Spatial <= a.currentLayer.Spatial && extPkt.VideoLayer.Temporal <= a.currentLayer.Temporal
+	result.IsRelevant = true
+	return
+}
@@ -22,6 +22,10 @@ import (
 
 type VP9 struct {
 	*Base
+
+	// k-SVC streams use inter-layer prediction in key pictures only, upper spatial layers of other pictures are
+	// decoded without the lower ones
+	isKSVC bool
 }
 
 func NewVP9(logger logger.Logger) *VP9 {
@@ -46,6 +50,13 @@ func (v *VP9) Select(extPkt *buffer.ExtPacket, _layer int32) (result VideoLayerS
 		return
 	}
 
+	if vp9.B && vp9.P && extPkt.VideoLayer.Spatial > 0 {
+		if isKSVC := !vp9.D; isKSVC != v.isKSVC {
+			v.logger.Debugw("vp9 inter-layer prediction changed", "kSVC", isKSVC)
+			v.isKSVC = isKSVC
+		}
+	}
+
 	currentLayer := v.currentLayer
 	if v.currentLayer != v.targetLayer {
 		updatedLayer := v.currentLayer
@@ -56,6 +67,7 @@ func (v *VP9) Select(extPkt *buffer.ExtPacket, _layer int32) (result VideoLayerS
 			}
 
 			updatedLayer = extPkt.VideoLayer
+			currentLayer = extPkt.VideoLayer
 		} else {
 			if v.currentLayer.Temporal != v.targetLayer.Temporal {
 				if v.currentLayer.Temporal < v.targetLayer.Temporal {
@@ -80,8 +92,14 @@ func (v *VP9) Select(extPkt *buffer.ExtPacket, _layer int32) (result VideoLayerS
 						updatedLayer.Spatial = extPkt.VideoLayer.Spatial
 					}
 				} else {
-					// spatial scale down
-					if vp9.E {
+					// spatial scale down, k-SVC streams have not forwarded the lower layers since the last key
+					// picture and switch on the next one
+					if v.isKSVC {
+						if extPkt.KeyFrame {
+							currentLayer.Spatial = v.targetLayer.Spatial
+							updatedLayer.Spatial = v.targetLayer.Spatial
+						}
+					} else if vp9.E {
 						updatedLayer.Spatial = v.targetLayer.Spatial
 					}
 				}
@@ -103,7 +121,11 @@ func (v *VP9) Select(extPkt *buffer.ExtPacket, _layer int32) (result VideoLayerS
 	if vp9.E && extPkt.VideoLayer.Spatial == currentLayer.Spatial && (vp9.P || v.targetLayer.Spatial <= v.currentLayer.Spatial) {
 		result.RTPMarker = true
 	}
-	result.IsSelected = !extPkt.VideoLayer.GreaterThan(currentLayer)
+	result.IsSelected = extPkt.VideoLayer.Spatial <= currentLayer.Spatial && extPkt.VideoLayer.Temporal <= currentLayer.Temporal
+	if v.isKSVC && vp9.P && extPkt.VideoLayer.Spatial < currentLayer.Spatial {
+		// not referenced by the forwarded layer outside of key pictures
+		result.IsSelected = false
+	}
 	result.IsRelevant = true
 	return
 }
@@ -0,0 +1,79 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package videolayerselector
+
+import (
+	"testing"
+
+	"github.com/pion/rtp"
+	"github.com/pion/rtp/codecs"
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/livekit-server/pkg/sfu/buffer"
+	"github.com/livekit/protocol/logger"
+)
+
+func TestVP9KSVC(t *testing.T) {
+	// one packet per layer frame, key pictures use inter-layer prediction only
+	frame := func(spatial int32, keyPicture bool) *buffer.ExtPacket {
+		return &buffer.ExtPacket{
+			Packet:     &rtp.Packet{Header: rtp.Header{Marker: spatial == 2}},
+			VideoLayer: buffer.VideoLayer{Spatial: spatial, Temporal: 0},
+			KeyFrame:   keyPicture && spatial == 0,
+			Payload: codecs.VP9Packet{
+				B:   true,
+				E:   true,
+				P:   !keyPicture,
+				D:   keyPicture && spatial > 0,
+				SID: uint8(spatial),
+			},
+		}
+	}
+
+	v := NewVP9(logger.GetLogger())
+	v.SetTarget(buffer.VideoLayer{Spatial: 2, Temporal: 0})
+	v.SetRequestSpatial(2)
+
+	result := v.Select(frame(0, true), 0)
+	require.True(t, result.IsResuming)
+	require.True(t, result.IsSelected)
+	require.True(t, v.Select(frame(1, true), 0).IsSelected)
+	result = v.Select(frame(2, true), 0)
+	require.True(t, result.IsSelected)
+	require.True(t, result.RTPMarker)
+	require.Equal(t, buffer.VideoLayer{Spatial: 2, Temporal: 0}, v.GetCurrent())
+
+	// lower layers of other pictures are not needed once the stream is known to be k-SVC
+	require.True(t, v.Select(frame(0, false), 0).IsSelected)
+	require.False(t, v.Select(frame(1, false), 0).IsSelected)
+	require.True(t, v.Select(frame(2, false), 0).IsSelected)
+	require.False(t, v.Select(frame(0, false), 0).IsSelected)
+	require.False(t, v.Select(frame(1, false), 0).IsSelected)
+
+	// scaling down waits for a key picture
+	v.SetTarget(buffer.VideoLayer{Spatial: 0, Temporal: 0})
+	v.SetRequestSpatial(0)
+	require.True(t, v.Select(frame(2, false), 0).IsSelected)
+	locked, _ := v.CheckSync()
+	require.False(t, locked)
+
+	result = v.Select(frame(0, true), 0)
+	require.True(t, result.IsSwitching)
+	require.True(t, result.IsSelected)
+	require.True(t, result.RTPMarker)
+	require.False(t, v.Select(frame(1, true), 0).IsSelected)
+	require.False(t, v.Select(frame(2, true), 0).IsSelected)
+	require.True(t, v.Select(frame(0, false), 0).IsSelected)
+}
@@ -0,0 +1,210 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/protocol/utils"
+)
+
+const azureAPIVersion = "2020-10-02"
+
+// azureBackend uploads block blobs, authorized with a SAS token. Uncommitted blocks are kept by
+// the service for a week, an upload can be resumed as long as the blob is not committed
+type azureBackend struct {
+	conf     config.StorageProfileConfig
+	client   *http.Client
+	endpoint string
+}
+
+func newAzureBackend(conf config.StorageProfileConfig, client *http.Client) *azureBackend {
+	endpoint := strings.TrimSuffix(conf.Endpoint, "/")
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://%s.blob.core.windows.net", conf.AccountName)
+	}
+	return &azureBackend{
+		conf:     conf,
+		client:   client,
+		endpoint: endpoint,
+	}
+}
+
+func (b *azureBackend) CreateUpload(_ context.Context, _ string, _ string) (string, error) {
+	// blocks are staged directly, the id only needs to keep block ids of different uploads apart
+	return utils.NewGuid("UP_"), nil
+}
+
+// block ids of a blob must all have the same length
+func blockID(uploadID string, partNumber int) string {
+	return base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%s-%06d", uploadID, partNumber)))
+}
+
+func (b *azureBackend) UploadPart(ctx context.Context, key string, uploadID string, partNumber int, data []byte) (string, error) {
+	id := blockID(uploadID, partNumber)
+	query := url.Values{
+		"comp":    {"block"},
+		"blockid": {id},
+	}
+	if _, err := b.do(ctx, http.MethodPut, key, query, data, nil); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+type blockList struct {
+	XMLName xml.Name `xml:"BlockList"`
+	Latest  []string `xml:"Latest"`
+}
+
+func (b *azureBackend) CompleteUpload(ctx context.Context, key string, _ string, contentType string, parts []Part) (string, error) {
+	list := blockList{}
+	for _, p := range parts {
+		list.Latest = append(list.Latest, p.ETag)
+	}
+	data, err := xml.Marshal(list)
+	if err != nil {
+		return "", err
+	}
+
+	headers := map[string]string{"Content-Type": "application/xml"}
+	if contentType != "" {
+		headers["x-ms-blob-content-type"] = contentType
+	}
+	if _, err = b.do(ctx, http.MethodPut, key, url.Values{"comp": {"blocklist"}}, append([]byte(xml.Header), data...), headers); err != nil {
+		return "", err
+	}
+	return b.blobURL(key), nil
+}
+
+func (b *azureBackend) AbortUpload(_ context.Context, _ string, _ string) error {
+	// uncommitted blocks are garbage collected by the service
+	return nil
+}
+
+func (b *azureBackend) PutObject(ctx context.Context, key string, contentType string, data []byte) error {
+	headers := map[string]string{"x-ms-blob-type": "BlockBlob"}
+	if contentType != "" {
+		headers["Content-Type"] = contentType
+	}
+	_, err := b.do(ctx, http.MethodPut, key, nil, data, headers)
+	return err
+}
+
+func (b *azureBackend) GetObject(ctx context.Context, key string) ([]byte, error) {
+	data, err := b.do(ctx, http.MethodGet, key, nil, nil, nil)
+	if isNotFound(err) {
+		return nil, ErrObjectNotFound
+	}
+	return data, err
+}
+
+type azureListResult struct {
+	Blobs []struct {
+		Name string `xml:"Name"`
+	} `xml:"Blobs>Blob"`
+	NextMarker string `xml:"NextMarker"`
+}
+
+func (b *azureBackend) ListObjects(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	var marker string
+	for {
+		query := url.Values{
+			"restype": {"container"},
+			"comp":    {"list"},
+			"prefix":  {prefix},
+		}
+		if marker != "" {
+			query.Set("marker", marker)
+		}
+		data, err := b.do(ctx, http.MethodGet, "", query, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var result azureListResult
+		if err = xml.Unmarshal(data, &result); err != nil {
+			return nil, err
+		}
+		for _, blob := range result.Blobs {
+			keys = append(keys, blob.Name)
+		}
+		if result.NextMarker == "" {
+			return keys, nil
+		}
+		marker = result.NextMarker
+	}
+}
+
+func (b *azureBackend) DeleteObject(ctx context.Context, key string) error {
+	if _, err := b.do(ctx, http.MethodDelete, key, nil, nil, nil); err != nil && !isNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+func (b *azureBackend) blobURL(key string) string {
+	return b.endpoint + "/" + b.conf.Bucket + "/" + escapePath(key)
+}
+
+// do sends a request for the blob key, or for the container when key is empty, and returns the response body
+func (b *azureBackend) do(ctx context.Context, method string, key string, query url.Values, body []byte, headers map[string]string) ([]byte, error) {
+	rawQuery := query.Encode()
+	if sas := strings.TrimPrefix(b.conf.SASToken, "?"); sas != "" {
+		rawQuery += "&" + sas
+	}
+
+	u := b.blobURL(key)
+	if key == "" {
+		u = b.endpoint + "/" + b.conf.Bucket
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u+"?"+rawQuery, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("x-ms-version", azureAPIVersion)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	res, err := b.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	data, err := io.ReadAll(res.Body)
+	if res.StatusCode >= 300 {
+		var e struct {
+			Message string `xml:"Message"`
+		}
+		_ = xml.Unmarshal(data, &e)
+		return nil, &requestError{
+			StatusCode: res.StatusCode,
+			Code:       res.Header.Get("x-ms-error-code"),
+			Message:    e.Message,
+		}
+	}
+	return data, err
+}
@@ -0,0 +1,85 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/livekit/livekit-server/pkg/telemetry/prometheus"
+)
+
+// Objects reads and writes small objects, such as documents, in the storage of a profile. Keys are relative
+// to the profile prefix
+type Objects struct {
+	p      *profile
+	prefix string
+}
+
+// Objects returns ErrUnknownProfile when the profile is not configured
+func (s *Storage) Objects(profileName string) (*Objects, error) {
+	if s == nil || s.profiles[profileName] == nil {
+		return nil, ErrUnknownProfile
+	}
+	return &Objects{
+		p:      s.profiles[profileName],
+		prefix: s.ObjectKey(profileName),
+	}, nil
+}
+
+func (o *Objects) key(key string) string {
+	if o.prefix == "" {
+		return key
+	}
+	return o.prefix + "/" + key
+}
+
+// Put stores data under key, replacing any existing object
+func (o *Objects) Put(ctx context.Context, key string, contentType string, data []byte) error {
+	start := time.Now()
+	err := o.p.retry(ctx, func() error {
+		return o.p.backend.PutObject(ctx, o.key(key), contentType, data)
+	})
+	prometheus.RecordStorageUpload(o.p.conf.Provider, o.p.name, err == nil, time.Since(start))
+	if err == nil {
+		prometheus.AddStorageUploadBytes(o.p.conf.Provider, o.p.name, len(data))
+	}
+	return err
+}
+
+// Get returns ErrObjectNotFound when there is no object with the key
+func (o *Objects) Get(ctx context.Context, key string) ([]byte, error) {
+	return o.p.backend.GetObject(ctx, o.key(key))
+}
+
+// List returns the keys starting with prefix
+func (o *Objects) List(ctx context.Context, prefix string) ([]string, error) {
+	keys, err := o.p.backend.ListObjects(ctx, o.key(prefix))
+	if err != nil {
+		return nil, err
+	}
+	if o.prefix != "" {
+		for i, key := range keys {
+			keys[i] = strings.TrimPrefix(key, o.prefix+"/")
+		}
+	}
+	return keys, nil
+}
+
+// Delete succeeds when there is no object with the key
+func (o *Objects) Delete(ctx context.Context, key string) error {
+	return o.p.backend.DeleteObject(ctx, o.key(key))
+}
@@ -0,0 +1,407 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/livekit/livekit-server/pkg/config"
+)
+
+const (
+	defaultS3Region    = "us-east-1"
+	defaultGCSEndpoint = "https://storage.googleapis.com"
+	defaultGCSRegion   = "auto"
+)
+
+// s3Backend talks to S3 compatible APIs with signature version 4, GCS is supported through its
+// XML API with HMAC keys
+type s3Backend struct {
+	conf     config.StorageProfileConfig
+	client   *http.Client
+	endpoint *url.URL
+	region   string
+}
+
+func newS3Backend(conf config.StorageProfileConfig, client *http.Client) *s3Backend {
+	region := conf.Region
+	endpoint := conf.Endpoint
+	if conf.Provider == "gcs" {
+		if region == "" {
+			region = defaultGCSRegion
+		}
+		if endpoint == "" {
+			endpoint = defaultGCSEndpoint
+		}
+	}
+	if region == "" {
+		region = defaultS3Region
+	}
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", region)
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		u = &url.URL{Scheme: "https", Host: strings.TrimPrefix(endpoint, "//")}
+	}
+
+	return &s3Backend{
+		conf:     conf,
+		client:   client,
+		endpoint: u,
+		region:   region,
+	}
+}
+
+func (b *s3Backend) CreateUpload(ctx context.Context, key string, contentType string) (string, error) {
+	headers := map[string]string{}
+	if contentType != "" {
+		headers["Content-Type"] = contentType
+	}
+	res, err := b.do(ctx, http.MethodPost, key, url.Values{"uploads": {""}}, nil, headers)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	var result struct {
+		UploadID string `xml:"UploadId"`
+	}
+	if err = xml.NewDecoder(res.Body).Decode(&result); err != nil {
+		return "", err
+	}
+	return result.UploadID, nil
+}
+
+func (b *s3Backend) UploadPart(ctx context.Context, key string, uploadID string, partNumber int, data []byte) (string, error) {
+	query := url.Values{
+		"partNumber": {fmt.Sprint(partNumber)},
+		"uploadId":   {uploadID},
+	}
+	res, err := b.do(ctx, http.MethodPut, key, query, data, nil)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(io.Discard, res.Body)
+	return res.Header.Get("ETag"), nil
+}
+
+type completedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+type completeMultipartUpload struct {
+	XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+	Parts   []completedPart `xml:"Part"`
+}
+
+func (b *s3Backend) CompleteUpload(ctx context.Context, key string, uploadID string, _ string, parts []Part) (string, error) {
+	body := completeMultipartUpload{}
+	for _, p := range parts {
+		body.Parts = append(body.Parts, completedPart{PartNumber: p.Number, ETag: p.ETag})
+	}
+	data, err := xml.Marshal(body)
+	if err != nil {
+		return "", err
+	}
+
+	res, err := b.do(ctx, http.MethodPost, key, url.Values{"uploadId": {uploadID}}, data, map[string]string{"Content-Type": "application/xml"})
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	// errors can be reported with a 200 status once the request has been accepted
+	resData, err := io.ReadAll(res.Body)
+	if err != nil {
+		return "", err
+	}
+	if reqErr := parseS3Error(res.StatusCode, resData); reqErr != nil {
+		return "", reqErr
+	}
+	var result struct {
+		XMLName  xml.Name
+		Location string `xml:"Location"`
+	}
+	if err = xml.Unmarshal(resData, &result); err == nil && result.Location != "" {
+		return result.Location, nil
+	}
+	return b.objectURL(key).String(), nil
+}
+
+func (b *s3Backend) AbortUpload(ctx context.Context, key string, uploadID string) error {
+	res, err := b.do(ctx, http.MethodDelete, key, url.Values{"uploadId": {uploadID}}, nil, nil)
+	if err != nil {
+		return err
+	}
+	return res.Body.Close()
+}
+
+func (b *s3Backend) PutObject(ctx context.Context, key string, contentType string, data []byte) error {
+	headers := map[string]string{}
+	if contentType != "" {
+		headers["Content-Type"] = contentType
+	}
+	res, err := b.do(ctx, http.MethodPut, key, nil, data, headers)
+	if err != nil {
+		return err
+	}
+	_, _ = io.Copy(io.Discard, res.Body)
+	return res.Body.Close()
+}
+
+func (b *s3Backend) GetObject(ctx context.Context, key string) ([]byte, error) {
+	res, err := b.do(ctx, http.MethodGet, key, nil, nil, nil)
+	if isNotFound(err) {
+		return nil, ErrObjectNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	return io.ReadAll(res.Body)
+}
+
+type listBucketResult struct {
+	Contents []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+func (b *s3Backend) ListObjects(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	var continuationToken string
+	for {
+		query := url.Values{
+			"list-type": {"2"},
+			"prefix":    {prefix},
+		}
+		if continuationToken != "" {
+			query.Set("continuation-token", continuationToken)
+		}
+		res, err := b.do(ctx, http.MethodGet, "", query, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var result listBucketResult
+		err = xml.NewDecoder(res.Body).Decode(&result)
+		res.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range result.Contents {
+			keys = append(keys, c.Key)
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return keys, nil
+		}
+		continuationToken = result.NextContinuationToken
+	}
+}
+
+func (b *s3Backend) DeleteObject(ctx context.Context, key string) error {
+	res, err := b.do(ctx, http.MethodDelete, key, nil, nil, nil)
+	if isNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return res.Body.Close()
+}
+
+// objectURL returns the bucket URL for an empty key
+func (b *s3Backend) objectURL(key string) *url.URL {
+	u := *b.endpoint
+	if b.conf.ForcePathStyle && key == "" {
+		u.Path = "/" + b.conf.Bucket
+	} else if b.conf.ForcePathStyle {
+		u.Path = "/" + b.conf.Bucket + "/" + key
+	} else {
+		u.Host = b.conf.Bucket + "." + u.Host
+		u.Path = "/" + key
+	}
+	return &u
+}
+
+func (b *s3Backend) do(ctx context.Context, method string, key string, query url.Values, body []byte, headers map[string]string) (*http.Response, error) {
+	u := b.objectURL(key)
+	u.RawQuery = canonicalQuery(query)
+	u.RawPath = escapePath(u.Path)
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	b.sign(req, body, time.Now().UTC())
+
+	res, err := b.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode >= 300 {
+		defer res.Body.Close()
+		data, _ := io.ReadAll(res.Body)
+		if reqErr := parseS3Error(res.StatusCode, data); reqErr != nil {
+			return nil, reqErr
+		}
+		return nil, &requestError{StatusCode: res.StatusCode}
+	}
+	return res, nil
+}
+
+func parseS3Error(statusCode int, data []byte) error {
+	var e struct {
+		XMLName xml.Name
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	if err := xml.Unmarshal(data, &e); err != nil || e.XMLName.Local != "Error" {
+		if statusCode >= 300 {
+			return &requestError{StatusCode: statusCode}
+		}
+		return nil
+	}
+	if e.Code == "NoSuchUpload" {
+		return ErrUploadNotFound
+	}
+	if statusCode < 300 {
+		// failure after the request was accepted, may be retried
+		statusCode = http.StatusInternalServerError
+	}
+	return &requestError{StatusCode: statusCode, Code: e.Code, Message: e.Message}
+}
+
+// sign adds an AWS signature version 4 authorization header
+func (b *s3Backend) sign(req *http.Request, body []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("Host", req.URL.Host)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	names := make([]string, 0, len(req.Header))
+	for name := range req.Header {
+		names = append(names, strings.ToLower(name))
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name)
+		canonicalHeaders.WriteByte(':')
+		canonicalHeaders.WriteString(strings.TrimSpace(req.Header.Get(name)))
+		canonicalHeaders.WriteByte('\n')
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + b.region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	signingKey := hmacSHA256([]byte("AWS4"+b.conf.Secret), date)
+	signingKey = hmacSHA256(signingKey, b.region)
+	signingKey = hmacSHA256(signingKey, "s3")
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		b.conf.AccessKey, scope, signedHeaders, signature,
+	))
+	// set by the client from the URL
+	req.Header.Del("Host")
+}
+
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		for _, v := range query[k] {
+			parts = append(parts, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+func escapePath(p string) string {
+	return uriEncode(p, false)
+}
+
+// uriEncode escapes everything but unreserved characters, as required by signature version 4
+func uriEncode(s string, encodeSlash bool) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z', c >= '0' && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			sb.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			sb.WriteByte(c)
+		default:
+			fmt.Fprintf(&sb, "%%%02X", c)
+		}
+	}
+	return sb.String()
+}
+
+func sha256Hex(data []byte) string {
+	h := sha256.Sum256(data)
+	return hex.EncodeToString(h[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
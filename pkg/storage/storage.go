@@ -0,0 +1,337 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/telemetry/prometheus"
+	"github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/logger"
+)
+
+const (
+	defaultPartSize   = 16 << 20
+	defaultMaxRetries = 5
+	defaultRetryDelay = time.Second
+	maxRetryDelay     = time.Minute
+
+	// stateSuffix is appended to the local path to persist the progress of an upload
+	stateSuffix = ".upload"
+)
+
+var (
+	ErrUnknownProfile = errors.New("unknown storage profile")
+	// ErrUploadNotFound is returned by backends when a multipart upload expired or was aborted
+	ErrUploadNotFound = errors.New("multipart upload not found")
+	ErrObjectNotFound = errors.New("object not found")
+)
+
+type Part struct {
+	Number int    `json:"number"`
+	ETag   string `json:"etag"`
+}
+
+// backend stores objects with multipart uploads
+type backend interface {
+	CreateUpload(ctx context.Context, key string, contentType string) (string, error)
+	UploadPart(ctx context.Context, key string, uploadID string, partNumber int, data []byte) (string, error)
+	// CompleteUpload returns the location of the object
+	CompleteUpload(ctx context.Context, key string, uploadID string, contentType string, parts []Part) (string, error)
+	AbortUpload(ctx context.Context, key string, uploadID string) error
+
+	// small objects, written in a single request
+	PutObject(ctx context.Context, key string, contentType string, data []byte) error
+	// GetObject returns ErrObjectNotFound when there is no object with the key
+	GetObject(ctx context.Context, key string) ([]byte, error)
+	// ListObjects returns the keys of the objects starting with prefix
+	ListObjects(ctx context.Context, prefix string) ([]string, error)
+	DeleteObject(ctx context.Context, key string) error
+}
+
+// requestError is returned by backends for unsuccessful responses
+type requestError struct {
+	StatusCode int
+	Code       string
+	Message    string
+}
+
+func (e *requestError) Error() string {
+	return fmt.Sprintf("storage request failed, status: %d, code: %s, message: %s", e.StatusCode, e.Code, e.Message)
+}
+
+func isNotFound(err error) bool {
+	var reqErr *requestError
+	return errors.As(err, &reqErr) && reqErr.StatusCode == http.StatusNotFound
+}
+
+func isRetryable(err error) bool {
+	var reqErr *requestError
+	if errors.As(err, &reqErr) {
+		return reqErr.StatusCode >= 500 || reqErr.StatusCode == http.StatusTooManyRequests || reqErr.StatusCode == http.StatusRequestTimeout
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// uploadState is persisted next to the uploaded file, an interrupted upload resumes from the
+// last completed part, including after a restart
+type uploadState struct {
+	Key      string `json:"key"`
+	PartSize int64  `json:"part_size"`
+	UploadID string `json:"upload_id"`
+	Parts    []Part `json:"parts"`
+}
+
+type profile struct {
+	name    string
+	conf    config.StorageProfileConfig
+	backend backend
+}
+
+// Storage uploads generated media to the object storage of the profile configured for a room
+type Storage struct {
+	conf     config.StorageConfig
+	profiles map[string]*profile
+}
+
+// NewStorage returns nil when no profile is configured
+func NewStorage(conf *config.Config) (*Storage, error) {
+	if len(conf.Storage.Profiles) == 0 {
+		return nil, nil
+	}
+
+	s := &Storage{
+		conf:     conf.Storage,
+		profiles: make(map[string]*profile),
+	}
+	for name, pc := range conf.Storage.Profiles {
+		if pc.PartSize <= 0 {
+			pc.PartSize = defaultPartSize
+		}
+		if pc.MaxRetries <= 0 {
+			pc.MaxRetries = defaultMaxRetries
+		}
+		if pc.RetryDelay <= 0 {
+			pc.RetryDelay = defaultRetryDelay
+		}
+
+		client := &http.Client{
+			Transport: newThrottledTransport(http.DefaultTransport, pc.MaxBandwidth),
+		}
+		var b backend
+		switch pc.Provider {
+		case "s3", "gcs":
+			b = newS3Backend(pc, client)
+		case "azure":
+			b = newAzureBackend(pc, client)
+		default:
+			return nil, fmt.Errorf("storage profile %s: unknown provider %q", name, pc.Provider)
+		}
+		s.profiles[name] = &profile{
+			name:    name,
+			conf:    pc,
+			backend: b,
+		}
+	}
+	return s, nil
+}
+
+// ProfileFor returns the profile used for a room, empty when its files are kept locally
+func (s *Storage) ProfileFor(roomName livekit.RoomName) string {
+	if s == nil {
+		return ""
+	}
+	for _, r := range s.conf.Rooms {
+		if strings.HasPrefix(string(roomName), r.RoomPrefix) {
+			return r.Profile
+		}
+	}
+	return s.conf.DefaultProfile
+}
+
+// ObjectKey prepends the profile prefix
+func (s *Storage) ObjectKey(profileName string, elems ...string) string {
+	var prefix string
+	if p := s.profiles[profileName]; p != nil {
+		prefix = strings.Trim(p.conf.Prefix, "/")
+	}
+	return strings.TrimPrefix(path.Join(append([]string{prefix}, elems...)...), "/")
+}
+
+// Upload stores a local file under key and returns its location. A failed upload can be retried
+// by calling Upload again, already uploaded parts are skipped
+func (s *Storage) Upload(ctx context.Context, profileName string, localPath string, key string, contentType string) (string, error) {
+	p := s.profiles[profileName]
+	if p == nil {
+		return "", ErrUnknownProfile
+	}
+
+	start := time.Now()
+	location, err := p.upload(ctx, localPath, key, contentType)
+	prometheus.RecordStorageUpload(p.conf.Provider, p.name, err == nil, time.Since(start))
+	if err != nil {
+		return "", err
+	}
+
+	if p.conf.DeleteLocal {
+		if err := os.Remove(localPath); err != nil {
+			logger.Warnw("could not remove uploaded file", err, "path", localPath)
+		}
+	}
+	return location, nil
+}
+
+func (p *profile) upload(ctx context.Context, localPath string, key string, contentType string) (string, error) {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	st, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	statePath := localPath + stateSuffix
+	state := loadState(statePath)
+	resumed := state != nil && state.Key == key && state.PartSize == p.conf.PartSize && state.UploadID != ""
+	if !resumed {
+		state = &uploadState{Key: key, PartSize: p.conf.PartSize}
+	}
+
+	location, err := p.uploadParts(ctx, f, st.Size(), state, statePath, contentType)
+	if errors.Is(err, ErrUploadNotFound) && resumed {
+		logger.Infow("stored upload expired, restarting", "key", key, "uploadID", state.UploadID)
+		state = &uploadState{Key: key, PartSize: p.conf.PartSize}
+		location, err = p.uploadParts(ctx, f, st.Size(), state, statePath, contentType)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	_ = os.Remove(statePath)
+	return location, nil
+}
+
+func (p *profile) uploadParts(ctx context.Context, f *os.File, size int64, state *uploadState, statePath string, contentType string) (string, error) {
+	if state.UploadID == "" {
+		err := p.retry(ctx, func() error {
+			id, err := p.backend.CreateUpload(ctx, state.Key, contentType)
+			state.UploadID = id
+			return err
+		})
+		if err != nil {
+			return "", err
+		}
+		saveState(statePath, state)
+	} else {
+		logger.Infow("resuming upload", "key", state.Key, "uploadID", state.UploadID, "parts", len(state.Parts))
+	}
+
+	buf := make([]byte, state.PartSize)
+	for {
+		offset := int64(len(state.Parts)) * state.PartSize
+		if offset >= size && len(state.Parts) > 0 {
+			break
+		}
+
+		n, err := f.ReadAt(buf, offset)
+		if err != nil && err != io.EOF {
+			return "", err
+		}
+		data := buf[:n]
+		partNumber := len(state.Parts) + 1
+
+		var etag string
+		err = p.retry(ctx, func() error {
+			var err error
+			etag, err = p.backend.UploadPart(ctx, state.Key, state.UploadID, partNumber, data)
+			return err
+		})
+		if err != nil {
+			return "", err
+		}
+		prometheus.AddStorageUploadBytes(p.conf.Provider, p.name, n)
+
+		state.Parts = append(state.Parts, Part{Number: partNumber, ETag: etag})
+		saveState(statePath, state)
+	}
+
+	var location string
+	err := p.retry(ctx, func() error {
+		var err error
+		location, err = p.backend.CompleteUpload(ctx, state.Key, state.UploadID, contentType, state.Parts)
+		return err
+	})
+	return location, err
+}
+
+// retry runs f until it succeeds, fails with a permanent error, or runs out of attempts
+func (p *profile) retry(ctx context.Context, f func() error) error {
+	delay := p.conf.RetryDelay
+	for attempt := 1; ; attempt++ {
+		err := f()
+		if err == nil || !isRetryable(err) || attempt >= p.conf.MaxRetries {
+			return err
+		}
+
+		logger.Debugw("storage request failed, retrying", "error", err, "profile", p.name, "attempt", attempt)
+		prometheus.RecordStorageUploadRetry(p.conf.Provider, p.name)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+		if delay > maxRetryDelay {
+			delay = maxRetryDelay
+		}
+	}
+}
+
+func loadState(statePath string) *uploadState {
+	data, err := os.ReadFile(statePath)
+	if err != nil {
+		return nil
+	}
+	state := &uploadState{}
+	if err = json.Unmarshal(data, state); err != nil {
+		return nil
+	}
+	return state
+}
+
+func saveState(statePath string, state *uploadState) {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return
+	}
+	if err = os.WriteFile(statePath, data, 0644); err != nil {
+		logger.Warnw("could not save upload state", err, "path", statePath)
+	}
+}
@@ -0,0 +1,190 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/storage"
+	"github.com/livekit/livekit-server/pkg/telemetry/prometheus"
+	"github.com/livekit/protocol/livekit"
+)
+
+func init() {
+	prometheus.Init("test", livekit.NodeType_SERVER, "test")
+}
+
+// fakeS3 implements the multipart part of the S3 API
+type fakeS3 struct {
+	lock      sync.Mutex
+	uploads   int
+	parts     map[string]string
+	objects   map[string]string
+	failParts map[string]int
+}
+
+func newFakeS3() *fakeS3 {
+	return &fakeS3{
+		parts:     make(map[string]string),
+		objects:   make(map[string]string),
+		failParts: make(map[string]int),
+	}
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=key/") {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	q := r.URL.Query()
+	switch {
+	case r.Method == http.MethodPost && q.Has("uploads"):
+		f.uploads++
+		_, _ = fmt.Fprintf(w, "<InitiateMultipartUploadResult><UploadId>upload%d</UploadId></InitiateMultipartUploadResult>", f.uploads)
+
+	case r.Method == http.MethodPut:
+		part := q.Get("partNumber")
+		if status := f.failParts[part]; status != 0 {
+			delete(f.failParts, part)
+			w.WriteHeader(status)
+			_, _ = io.WriteString(w, "<Error><Code>Injected</Code></Error>")
+			return
+		}
+		data, _ := io.ReadAll(r.Body)
+		f.parts[q.Get("uploadId")+"/"+part] = string(data)
+		w.Header().Set("ETag", "\"etag"+part+"\"")
+
+	case r.Method == http.MethodPost && q.Has("uploadId"):
+		var sb strings.Builder
+		for i := 1; ; i++ {
+			data, ok := f.parts[fmt.Sprintf("%s/%d", q.Get("uploadId"), i)]
+			if !ok {
+				break
+			}
+			sb.WriteString(data)
+		}
+		f.objects[r.URL.Path] = sb.String()
+		_, _ = io.WriteString(w, "<CompleteMultipartUploadResult><Location>http://fake"+r.URL.Path+"</Location></CompleteMultipartUploadResult>")
+
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func newTestStorage(t *testing.T, endpoint string) *storage.Storage {
+	conf := &config.Config{
+		Storage: config.StorageConfig{
+			DefaultProfile: "default",
+			Profiles: map[string]config.StorageProfileConfig{
+				"default": {
+					Provider:       "s3",
+					Bucket:         "bucket",
+					Prefix:         "/recordings/",
+					Endpoint:       endpoint,
+					AccessKey:      "key",
+					Secret:         "secret",
+					ForcePathStyle: true,
+					PartSize:       4,
+					MaxRetries:     3,
+					RetryDelay:     time.Millisecond,
+				},
+				"exam": {
+					Provider:    "azure",
+					Bucket:      "container",
+					AccountName: "account",
+				},
+			},
+			Rooms: []config.StorageRoomConfig{
+				{RoomPrefix: "exam-", Profile: "exam"},
+			},
+		},
+	}
+	s, err := storage.NewStorage(conf)
+	require.NoError(t, err)
+	return s
+}
+
+func writeTestFile(t *testing.T, content string) string {
+	p := filepath.Join(t.TempDir(), "file.ogg")
+	require.NoError(t, os.WriteFile(p, []byte(content), 0644))
+	return p
+}
+
+func TestProfileFor(t *testing.T) {
+	s := newTestStorage(t, "http://localhost")
+	require.Equal(t, "exam", s.ProfileFor("exam-math"))
+	require.Equal(t, "default", s.ProfileFor("lecture"))
+	require.Equal(t, "recordings/room/RC_id/file.ogg", s.ObjectKey("default", "room", "RC_id", "file.ogg"))
+
+	var nilStorage *storage.Storage
+	require.Equal(t, "", nilStorage.ProfileFor("lecture"))
+}
+
+func TestUpload(t *testing.T) {
+	fake := newFakeS3()
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+	s := newTestStorage(t, srv.URL)
+
+	t.Run("multipart with retry", func(t *testing.T) {
+		fake.failParts["2"] = http.StatusServiceUnavailable
+		p := writeTestFile(t, "0123456789")
+
+		location, err := s.Upload(context.Background(), "default", p, "recordings/a.ogg", "audio/ogg")
+		require.NoError(t, err)
+		require.Equal(t, "http://fake/bucket/recordings/a.ogg", location)
+		require.Equal(t, "0123456789", fake.objects["/bucket/recordings/a.ogg"])
+		require.NoFileExists(t, p+".upload")
+	})
+
+	t.Run("resumes after failure", func(t *testing.T) {
+		fake.failParts["2"] = http.StatusForbidden
+		p := writeTestFile(t, "abcdefghij")
+
+		_, err := s.Upload(context.Background(), "default", p, "recordings/b.ogg", "audio/ogg")
+		require.Error(t, err)
+		require.FileExists(t, p+".upload")
+		uploads := fake.uploads
+
+		// part 1 is not sent again
+		fake.parts[fmt.Sprintf("upload%d/1", uploads)] = "ABCD"
+		_, err = s.Upload(context.Background(), "default", p, "recordings/b.ogg", "audio/ogg")
+		require.NoError(t, err)
+		require.Equal(t, uploads, fake.uploads)
+		require.Equal(t, "ABCDefghij", fake.objects["/bucket/recordings/b.ogg"])
+	})
+
+	t.Run("unknown profile", func(t *testing.T) {
+		_, err := s.Upload(context.Background(), "missing", writeTestFile(t, "x"), "x", "")
+		require.ErrorIs(t, err, storage.ErrUnknownProfile)
+	})
+}
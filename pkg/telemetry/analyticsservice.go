@@ -33,15 +33,17 @@ type AnalyticsService interface {
 type analyticsService struct {
 	analyticsKey string
 	nodeID       string
+	redactor     *Redactor
 
 	events livekit.AnalyticsRecorderService_IngestEventsClient
 	stats  livekit.AnalyticsRecorderService_IngestStatsClient
 }
 
-func NewAnalyticsService(_ *config.Config, currentNode routing.LocalNode) AnalyticsService {
+func NewAnalyticsService(conf *config.Config, currentNode routing.LocalNode) AnalyticsService {
 	return &analyticsService{
 		analyticsKey: "", // TODO: conf.AnalyticsKey
 		nodeID:       currentNode.Id,
+		redactor:     NewRedactor(&conf.Redaction),
 	}
 }
 
@@ -64,6 +66,7 @@ func (a *analyticsService) SendEvent(_ context.Context, event *livekit.Analytics
 		return
 	}
 
+	event = a.redactor.RedactAnalyticsEvent(event)
 	event.AnalyticsKey = a.analyticsKey
 	if err := a.events.Send(&livekit.AnalyticsEvents{
 		Events: []*livekit.AnalyticsEvent{event},
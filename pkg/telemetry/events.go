@@ -16,10 +16,13 @@ package telemetry
 
 import (
 	"context"
+	"sync"
 	"time"
 
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/timestamppb"
 
+	"github.com/livekit/livekit-server/pkg/config"
 	"github.com/livekit/livekit-server/pkg/telemetry/prometheus"
 	"github.com/livekit/protocol/livekit"
 	"github.com/livekit/protocol/logger"
@@ -27,14 +30,103 @@ import (
 	"github.com/livekit/protocol/webhook"
 )
 
+// sent when a subscribed track stopped forwarding and was reset by the watchdog
+const EventTrackStalled = "track_stalled"
+
+// EventListener receives the webhook events of this node, whether or not webhooks are configured
+type EventListener func(event *livekit.WebhookEvent)
+
+const eventListenerQueueSize = 1000
+
+var (
+	eventListenerMu sync.RWMutex
+	eventListener   *eventDispatcher
+)
+
+// SetEventListener sets the in-process receiver of webhook events, nil removes it. Events are redacted like
+// webhooks and delivered in order from a separate goroutine, they are dropped while the listener falls behind.
+func SetEventListener(listener EventListener, redaction *config.RedactionConfig) {
+	var d *eventDispatcher
+	if listener != nil {
+		d = &eventDispatcher{
+			listener: listener,
+			redactor: NewRedactor(redaction),
+			queue:    make(chan *livekit.WebhookEvent, eventListenerQueueSize),
+		}
+		go d.run()
+	}
+
+	eventListenerMu.Lock()
+	prev := eventListener
+	eventListener = d
+	eventListenerMu.Unlock()
+
+	if prev != nil {
+		prev.close()
+	}
+}
+
+type eventDispatcher struct {
+	listener EventListener
+	redactor *Redactor
+
+	lock   sync.RWMutex
+	queue  chan *livekit.WebhookEvent
+	closed bool
+}
+
+func (d *eventDispatcher) notify(event *livekit.WebhookEvent) {
+	// events share protos with live room state, the listener gets its own copy
+	if d.redactor == nil {
+		event = proto.Clone(event).(*livekit.WebhookEvent)
+	} else {
+		event = d.redactor.RedactWebhookEvent(event)
+	}
+
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	if d.closed {
+		return
+	}
+	select {
+	case d.queue <- event:
+	default:
+		logger.Warnw("event listener queue full, dropping event", nil, "event", event.Event)
+	}
+}
+
+func (d *eventDispatcher) close() {
+	d.lock.Lock()
+	if !d.closed {
+		d.closed = true
+		close(d.queue)
+	}
+	d.lock.Unlock()
+}
+
+func (d *eventDispatcher) run() {
+	for event := range d.queue {
+		d.listener(event)
+	}
+}
+
 func (t *telemetryService) NotifyEvent(ctx context.Context, event *livekit.WebhookEvent) {
-	if t.notifier == nil {
+	eventListenerMu.RLock()
+	listener := eventListener
+	eventListenerMu.RUnlock()
+	if t.notifier == nil && listener == nil {
 		return
 	}
 
 	event.CreatedAt = time.Now().Unix()
 	event.Id = utils.NewGuid("EV_")
 
+	if listener != nil {
+		listener.notify(event)
+	}
+	if t.notifier == nil {
+		return
+	}
 	if err := t.notifier.QueueNotify(ctx, event); err != nil {
 		logger.Warnw("failed to notify webhook", err, "event", event.Event)
 	}
@@ -299,7 +391,7 @@ func (t *telemetryService) TrackSubscribeFailed(
 	isUserError bool,
 ) {
 	t.enqueue(func() {
-		prometheus.RecordTrackSubscribeFailure(err, isUserError)
+		prometheus.RecordTrackSubscribeFailure(ctx, err, isUserError)
 
 		room := t.getRoomDetails(participantID)
 		ev := newTrackEvent(livekit.AnalyticsEventType_TRACK_SUBSCRIBE_FAILED, room, participantID, &livekit.TrackInfo{
@@ -377,6 +469,21 @@ func (t *telemetryService) TrackUnmuted(
 	})
 }
 
+func (t *telemetryService) TrackStalled(
+	ctx context.Context,
+	participantID livekit.ParticipantID,
+	track *livekit.TrackInfo,
+) {
+	t.enqueue(func() {
+		t.NotifyEvent(ctx, &livekit.WebhookEvent{
+			Event:       EventTrackStalled,
+			Room:        t.getRoomDetails(participantID),
+			Participant: &livekit.ParticipantInfo{Sid: string(participantID)},
+			Track:       track,
+		})
+	})
+}
+
 func (t *telemetryService) TrackPublishRTPStats(
 	ctx context.Context,
 	participantID livekit.ParticipantID,
@@ -22,6 +22,10 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/webhook"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/telemetry"
 )
 
 func Test_OnParticipantJoin_EventIsSent(t *testing.T) {
@@ -236,3 +240,31 @@ func Test_OnTrackSubscribed_EventIsSent(t *testing.T) {
 	require.Equal(t, publisherInfo.Identity, eventTrackSubscribed.Publisher.Identity)
 
 }
+
+func Test_EventListener_ReceivesRedactedEvents(t *testing.T) {
+	fixture := createFixture()
+
+	events := make(chan *livekit.WebhookEvent, 1)
+	telemetry.SetEventListener(func(event *livekit.WebhookEvent) {
+		events <- event
+	}, &config.RedactionConfig{Fields: []string{config.RedactFieldIdentity}})
+	t.Cleanup(func() { telemetry.SetEventListener(nil, nil) })
+
+	participant := &livekit.ParticipantInfo{Sid: "PA_1", Identity: "student@campus.edu"}
+	fixture.sut.NotifyEvent(context.Background(), &livekit.WebhookEvent{
+		Event:       webhook.EventParticipantJoined,
+		Participant: participant,
+	})
+
+	select {
+	case event := <-events:
+		require.Equal(t, webhook.EventParticipantJoined, event.Event)
+		require.NotEmpty(t, event.Id)
+		require.Equal(t, "PA_1", event.Participant.Sid)
+		require.Empty(t, event.Participant.Identity)
+	case <-time.After(time.Second):
+		require.Fail(t, "event not delivered")
+	}
+	// the live participant is left untouched
+	require.Equal(t, "student@campus.edu", participant.Identity)
+}
@@ -0,0 +1,70 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/livekit/protocol/livekit"
+)
+
+var (
+	authFailureTotal  *prometheus.CounterVec
+	authLockoutTotal  prometheus.Counter
+	authRejectedTotal prometheus.Counter
+)
+
+func initAuthStats(nodeID string, nodeType livekit.NodeType, env string) {
+	authFailureTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "auth",
+		Name:        "failure_total",
+		ConstLabels: prometheus.Labels{"node_id": nodeID, "node_type": nodeType.String(), "env": env},
+	}, []string{"reason", "api_key"})
+	authLockoutTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "auth",
+		Name:        "lockout_total",
+		ConstLabels: prometheus.Labels{"node_id": nodeID, "node_type": nodeType.String(), "env": env},
+		Help:        "Number of times a client IP was locked out after repeated authentication failures.",
+	})
+	authRejectedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "auth",
+		Name:        "locked_out_requests_total",
+		ConstLabels: prometheus.Labels{"node_id": nodeID, "node_type": nodeType.String(), "env": env},
+		Help:        "Requests rejected because the client IP was locked out.",
+	})
+
+	prometheus.MustRegister(authFailureTotal)
+	prometheus.MustRegister(authLockoutTotal)
+	prometheus.MustRegister(authRejectedTotal)
+}
+
+// RecordAuthFailure counts a failed authentication. apiKey should only be set for configured keys to bound cardinality
+func RecordAuthFailure(reason string, apiKey string) {
+	if apiKey == "" {
+		apiKey = "unknown"
+	}
+	authFailureTotal.WithLabelValues(reason, apiKey).Inc()
+}
+
+func RecordAuthLockout() {
+	authLockoutTotal.Inc()
+}
+
+func RecordAuthLockedOutRequest() {
+	authRejectedTotal.Inc()
+}
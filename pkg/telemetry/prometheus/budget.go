@@ -0,0 +1,151 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"runtime"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/atomic"
+
+	"github.com/livekit/protocol/livekit"
+)
+
+// objects counted by subsystem
+const (
+	ObjectRooms            = "rooms"
+	ObjectParticipants     = "participants"
+	ObjectPublishedTracks  = "published_tracks"
+	ObjectSubscribedTracks = "subscribed_tracks"
+	ObjectPeerConnections  = "peer_connections"
+)
+
+var (
+	peerConnectionCurrent atomic.Int32
+
+	promGoroutines     prometheus.Gauge
+	promOpenFDs        prometheus.Gauge
+	promFDLimit        prometheus.Gauge
+	promObjects        *prometheus.GaugeVec
+	promBudgetExceeded *prometheus.GaugeVec
+	promBudgetTotal    *prometheus.CounterVec
+)
+
+// ResourceUsage is a sample of the resources held by the process
+type ResourceUsage struct {
+	Goroutines int
+	// open file descriptors and their limit, 0 when unavailable
+	OpenFDs uint64
+	FDLimit uint64
+	Objects map[string]int
+}
+
+func initBudgetStats(nodeID string, nodeType livekit.NodeType, env string) {
+	promGoroutines = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "node",
+		Name:        "goroutines",
+		ConstLabels: prometheus.Labels{"node_id": nodeID, "node_type": nodeType.String(), "env": env},
+		Help:        "Goroutines of the process.",
+	})
+	promOpenFDs = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "node",
+		Name:        "open_fds",
+		ConstLabels: prometheus.Labels{"node_id": nodeID, "node_type": nodeType.String(), "env": env},
+		Help:        "Open file descriptors of the process, linux only.",
+	})
+	promFDLimit = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "node",
+		Name:        "fd_limit",
+		ConstLabels: prometheus.Labels{"node_id": nodeID, "node_type": nodeType.String(), "env": env},
+		Help:        "Soft limit of open file descriptors of the process, linux only.",
+	})
+	promObjects = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "node",
+		Name:        "objects",
+		ConstLabels: prometheus.Labels{"node_id": nodeID, "node_type": nodeType.String(), "env": env},
+		Help:        "Live objects by subsystem.",
+	}, []string{"subsystem"})
+	promBudgetExceeded = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "node",
+		Name:        "budget_exceeded",
+		ConstLabels: prometheus.Labels{"node_id": nodeID, "node_type": nodeType.String(), "env": env},
+		Help:        "1 while usage of a resource is above its soft limit.",
+	}, []string{"resource"})
+	promBudgetTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "node",
+		Name:        "budget_exceeded_total",
+		ConstLabels: prometheus.Labels{"node_id": nodeID, "node_type": nodeType.String(), "env": env},
+		Help:        "Times usage of a resource went above its soft limit.",
+	}, []string{"resource"})
+
+	prometheus.MustRegister(promGoroutines)
+	prometheus.MustRegister(promOpenFDs)
+	prometheus.MustRegister(promFDLimit)
+	prometheus.MustRegister(promObjects)
+	prometheus.MustRegister(promBudgetExceeded)
+	prometheus.MustRegister(promBudgetTotal)
+}
+
+func AddPeerConnection() {
+	peerConnectionCurrent.Inc()
+}
+
+func SubPeerConnection() {
+	peerConnectionCurrent.Dec()
+}
+
+// GetResourceUsage samples the resources held by the process and exports them
+func GetResourceUsage() ResourceUsage {
+	usage := ResourceUsage{
+		Goroutines: runtime.NumGoroutine(),
+		Objects: map[string]int{
+			ObjectRooms:            int(roomCurrent.Load()),
+			ObjectParticipants:     int(participantCurrent.Load()),
+			ObjectPublishedTracks:  int(trackPublishedCurrent.Load()),
+			ObjectSubscribedTracks: int(trackSubscribedCurrent.Load()),
+			ObjectPeerConnections:  int(peerConnectionCurrent.Load()),
+		},
+	}
+	// do not error out, and use the information if it is available
+	usage.OpenFDs, usage.FDLimit, _ = getFDStats()
+
+	if promGoroutines != nil {
+		promGoroutines.Set(float64(usage.Goroutines))
+		promOpenFDs.Set(float64(usage.OpenFDs))
+		promFDLimit.Set(float64(usage.FDLimit))
+		for subsystem, count := range usage.Objects {
+			promObjects.WithLabelValues(subsystem).Set(float64(count))
+		}
+	}
+	return usage
+}
+
+func RecordBudgetExceeded(resource string, exceeded bool) {
+	if promBudgetExceeded == nil {
+		return
+	}
+	if exceeded {
+		promBudgetExceeded.WithLabelValues(resource).Set(1)
+		promBudgetTotal.WithLabelValues(resource).Inc()
+	} else {
+		promBudgetExceeded.WithLabelValues(resource).Set(0)
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.uber.org/atomic"
+)
+
+const exemplarTraceIDLabel = "trace_id"
+
+// TraceIDFunc returns the ID of the trace recorded for the context, or an empty string when there is none
+type TraceIDFunc func(ctx context.Context) string
+
+var traceIDFunc atomic.Value
+
+// SetTraceIDFunc is set by the tracing integration so that metrics can carry the trace they were recorded in as
+// an exemplar. Without it no exemplars are attached.
+func SetTraceIDFunc(f TraceIDFunc) {
+	traceIDFunc.Store(f)
+}
+
+// Handler serves the metrics of the default registry, exemplars are only exposed in the OpenMetrics format
+func Handler(enableOpenMetrics bool) http.Handler {
+	return promhttp.InstrumentMetricHandler(
+		prometheus.DefaultRegisterer,
+		promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{
+			EnableOpenMetrics: enableOpenMetrics,
+		}),
+	)
+}
+
+func exemplarLabels(ctx context.Context) prometheus.Labels {
+	if ctx == nil {
+		return nil
+	}
+	f, ok := traceIDFunc.Load().(TraceIDFunc)
+	if !ok || f == nil {
+		return nil
+	}
+	traceID := f(ctx)
+	if traceID == "" {
+		return nil
+	}
+	return prometheus.Labels{exemplarTraceIDLabel: traceID}
+}
+
+func addWithExemplar(ctx context.Context, c prometheus.Counter, v float64) {
+	if labels := exemplarLabels(ctx); labels != nil {
+		if ea, ok := c.(prometheus.ExemplarAdder); ok {
+			ea.AddWithExemplar(v, labels)
+			return
+		}
+	}
+	c.Add(v)
+}
+
+func observeWithExemplar(ctx context.Context, o prometheus.Observer, v float64) {
+	if labels := exemplarLabels(ctx); labels != nil {
+		if eo, ok := o.(prometheus.ExemplarObserver); ok {
+			eo.ObserveWithExemplar(v, labels)
+			return
+		}
+	}
+	o.Observe(v)
+}
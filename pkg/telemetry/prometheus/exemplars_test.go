@@ -0,0 +1,77 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExemplars(t *testing.T) {
+	testCases := []struct {
+		name    string
+		traceID TraceIDFunc
+		ctx     context.Context
+		// trace id of the exemplar, none when empty
+		expected string
+	}{
+		{name: "no tracing", ctx: context.Background()},
+		{name: "not traced", traceID: func(context.Context) string { return "" }, ctx: context.Background()},
+		{name: "no context", traceID: func(context.Context) string { return "4bf92f3577b34da6" }},
+		{name: "traced", traceID: func(context.Context) string { return "4bf92f3577b34da6" }, ctx: context.Background(), expected: "4bf92f3577b34da6"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			SetTraceIDFunc(tc.traceID)
+			defer SetTraceIDFunc(nil)
+
+			counter := prometheus.NewCounter(prometheus.CounterOpts{Name: "test_total"})
+			addWithExemplar(tc.ctx, counter, 2)
+			histogram := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "test_ms", Buckets: []float64{10, 100}})
+			observeWithExemplar(tc.ctx, histogram, 50)
+
+			m := &dto.Metric{}
+			require.NoError(t, counter.Write(m))
+			require.Equal(t, float64(2), m.GetCounter().GetValue())
+			requireExemplar(t, tc.expected, m.GetCounter().GetExemplar())
+
+			m = &dto.Metric{}
+			require.NoError(t, histogram.Write(m))
+			require.Equal(t, uint64(1), m.GetHistogram().GetSampleCount())
+			var exemplar *dto.Exemplar
+			for _, bucket := range m.GetHistogram().GetBucket() {
+				if bucket.GetExemplar() != nil {
+					exemplar = bucket.GetExemplar()
+				}
+			}
+			requireExemplar(t, tc.expected, exemplar)
+		})
+	}
+}
+
+func requireExemplar(t *testing.T, traceID string, exemplar *dto.Exemplar) {
+	if traceID == "" {
+		require.Nil(t, exemplar)
+		return
+	}
+	require.NotNil(t, exemplar)
+	require.Len(t, exemplar.GetLabel(), 1)
+	require.Equal(t, exemplarTraceIDLabel, exemplar.GetLabel()[0].GetName())
+	require.Equal(t, traceID, exemplar.GetLabel()[0].GetValue())
+}
@@ -0,0 +1,60 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/livekit/protocol/livekit"
+)
+
+var (
+	signalPongTimeoutTotal prometheus.Counter
+	deadPeerTotal          prometheus.Counter
+)
+
+func initHeartbeatStats(nodeID string, nodeType livekit.NodeType, env string) {
+	signalPongTimeoutTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "signal",
+		Name:        "pong_timeout_total",
+		ConstLabels: prometheus.Labels{"node_id": nodeID, "node_type": nodeType.String(), "env": env},
+		Help:        "WebSocket connections closed because the client stopped answering pings.",
+	})
+	deadPeerTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "participant",
+		Name:        "dead_peer_total",
+		ConstLabels: prometheus.Labels{"node_id": nodeID, "node_type": nodeType.String(), "env": env},
+		Help:        "Participants removed because neither signal nor media was heard from them.",
+	})
+
+	prometheus.MustRegister(signalPongTimeoutTotal)
+	prometheus.MustRegister(deadPeerTotal)
+}
+
+func RecordSignalPongTimeout() {
+	if signalPongTimeoutTotal == nil {
+		return
+	}
+	signalPongTimeoutTotal.Inc()
+}
+
+func RecordDeadPeer() {
+	if deadPeerTotal == nil {
+		return
+	}
+	deadPeerTotal.Inc()
+}
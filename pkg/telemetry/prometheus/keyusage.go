@@ -0,0 +1,307 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/livekit/protocol/livekit"
+)
+
+const (
+	// usage is kept in one minute buckets for this long
+	keyUsageRetention = time.Hour
+	// participant minutes of active connections are accrued at this interval
+	keyUsageAccrueInterval = 15 * time.Second
+)
+
+var (
+	promKeyTokensValidated    *prometheus.CounterVec
+	promKeyRoomsCreated       *prometheus.CounterVec
+	promKeyParticipantSeconds *prometheus.CounterVec
+	promKeyParticipants       *prometheus.GaugeVec
+	promKeyQuotaUsage         *prometheus.GaugeVec
+	promKeyEgressBitrate      *prometheus.GaugeVec
+	promKeyQuotaExceeded      *prometheus.CounterVec
+
+	keyUsage = &keyUsageTracker{
+		active:    make(map[string]int),
+		accruedAt: time.Now(),
+		total:     make(map[string]*KeyUsage),
+	}
+)
+
+// KeyUsage is the usage attributed to the API key tokens were signed with
+type KeyUsage struct {
+	APIKey             string  `json:"api_key"`
+	TokensValidated    int64   `json:"tokens_validated"`
+	RoomsCreated       int64   `json:"rooms_created"`
+	ParticipantMinutes float64 `json:"participant_minutes"`
+	// signal connections currently open on this node
+	ActiveParticipants int `json:"active_participants"`
+}
+
+type keyUsageBucket struct {
+	start time.Time
+	usage map[string]*KeyUsage
+}
+
+type keyUsageTracker struct {
+	lock      sync.Mutex
+	buckets   []*keyUsageBucket
+	active    map[string]int
+	accruedAt time.Time
+	total     map[string]*KeyUsage
+}
+
+func initKeyUsageStats(nodeID string, nodeType livekit.NodeType, env string) {
+	promKeyTokensValidated = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "api_key",
+		Name:        "tokens_validated_total",
+		ConstLabels: prometheus.Labels{"node_id": nodeID, "node_type": nodeType.String(), "env": env},
+	}, []string{"api_key"})
+	promKeyRoomsCreated = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "api_key",
+		Name:        "rooms_created_total",
+		ConstLabels: prometheus.Labels{"node_id": nodeID, "node_type": nodeType.String(), "env": env},
+	}, []string{"api_key"})
+	promKeyParticipantSeconds = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "api_key",
+		Name:        "participant_seconds_total",
+		ConstLabels: prometheus.Labels{"node_id": nodeID, "node_type": nodeType.String(), "env": env},
+		Help:        "Time participants joined with tokens of the key were connected to signaling on this node.",
+	}, []string{"api_key"})
+	promKeyParticipants = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "api_key",
+		Name:        "participants",
+		ConstLabels: prometheus.Labels{"node_id": nodeID, "node_type": nodeType.String(), "env": env},
+	}, []string{"api_key"})
+
+	promKeyQuotaUsage = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "api_key",
+		Name:        "quota_usage",
+		ConstLabels: prometheus.Labels{"node_id": nodeID, "node_type": nodeType.String(), "env": env},
+		Help:        "Usage of the key across the cluster counted against its quotas: rooms, participants and monthly_participant_minutes.",
+	}, []string{"api_key", "quota"})
+	promKeyEgressBitrate = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "api_key",
+		Name:        "egress_bitrate",
+		ConstLabels: prometheus.Labels{"node_id": nodeID, "node_type": nodeType.String(), "env": env},
+		Help:        "Bitrate subscribers of rooms created with the key demand on this node.",
+	}, []string{"api_key"})
+	promKeyQuotaExceeded = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "api_key",
+		Name:        "quota_exceeded_total",
+		ConstLabels: prometheus.Labels{"node_id": nodeID, "node_type": nodeType.String(), "env": env},
+	}, []string{"api_key", "quota"})
+
+	prometheus.MustRegister(promKeyTokensValidated)
+	prometheus.MustRegister(promKeyRoomsCreated)
+	prometheus.MustRegister(promKeyParticipantSeconds)
+	prometheus.MustRegister(promKeyParticipants)
+	prometheus.MustRegister(promKeyQuotaUsage)
+	prometheus.MustRegister(promKeyEgressBitrate)
+	prometheus.MustRegister(promKeyQuotaExceeded)
+
+	go keyUsage.accrueWorker()
+}
+
+// RecordAPIKeyTokenValidated counts a token of a configured key that passed verification
+func RecordAPIKeyTokenValidated(apiKey string) {
+	promKeyTokensValidated.WithLabelValues(apiKey).Inc()
+
+	keyUsage.update(apiKey, func(u *KeyUsage) {
+		u.TokensValidated++
+	})
+}
+
+// RecordAPIKeyRoomCreated counts a room created by a request signed with the key
+func RecordAPIKeyRoomCreated(apiKey string) {
+	promKeyRoomsCreated.WithLabelValues(apiKey).Inc()
+
+	keyUsage.update(apiKey, func(u *KeyUsage) {
+		u.RoomsCreated++
+	})
+}
+
+// AddAPIKeyParticipant tracks a participant connected with a token of the key, its connected time accrues to the
+// key until it is removed with the returned function
+func AddAPIKeyParticipant(apiKey string) func() {
+	promKeyParticipants.WithLabelValues(apiKey).Inc()
+	keyUsage.setActive(apiKey, 1)
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			promKeyParticipants.WithLabelValues(apiKey).Dec()
+			keyUsage.setActive(apiKey, -1)
+		})
+	}
+}
+
+// RecordAPIKeyQuotaUsage reports the cluster wide usage of the key for a quota
+func RecordAPIKeyQuotaUsage(apiKey string, quota string, usage float64) {
+	if promKeyQuotaUsage == nil {
+		return
+	}
+	promKeyQuotaUsage.WithLabelValues(apiKey, quota).Set(usage)
+}
+
+// RecordAPIKeyEgressBitrate reports the bitrate subscribers of rooms created with the key demand on this node
+func RecordAPIKeyEgressBitrate(apiKey string, bitrate int64) {
+	if promKeyEgressBitrate == nil {
+		return
+	}
+	promKeyEgressBitrate.WithLabelValues(apiKey).Set(float64(bitrate))
+}
+
+// RecordAPIKeyQuotaExceeded counts a room or participant rejected because the key exceeded a quota
+func RecordAPIKeyQuotaExceeded(apiKey string, quota string) {
+	if promKeyQuotaExceeded == nil {
+		return
+	}
+	promKeyQuotaExceeded.WithLabelValues(apiKey, quota).Inc()
+}
+
+// GetAPIKeyUsage returns the usage of keys on this node within window, or since the node started when window
+// is zero. Windows start at a full minute and cover at most an hour.
+func GetAPIKeyUsage(window time.Duration) []*KeyUsage {
+	return keyUsage.get(window)
+}
+
+func (t *keyUsageTracker) update(apiKey string, f func(u *KeyUsage)) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	f(usageFor(t.total, apiKey))
+	f(usageFor(t.bucketLocked(time.Now()).usage, apiKey))
+}
+
+func (t *keyUsageTracker) setActive(apiKey string, delta int) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	// connected time so far is attributed at the previous count
+	t.accrueLocked(time.Now())
+	t.active[apiKey] += delta
+	if t.active[apiKey] <= 0 {
+		delete(t.active, apiKey)
+	}
+}
+
+func (t *keyUsageTracker) get(window time.Duration) []*KeyUsage {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	now := time.Now()
+	t.accrueLocked(now)
+
+	usage := make(map[string]*KeyUsage)
+	add := func(from map[string]*KeyUsage) {
+		for apiKey, u := range from {
+			sum := usageFor(usage, apiKey)
+			sum.TokensValidated += u.TokensValidated
+			sum.RoomsCreated += u.RoomsCreated
+			sum.ParticipantMinutes += u.ParticipantMinutes
+		}
+	}
+	if window <= 0 {
+		add(t.total)
+	} else {
+		since := now.Add(-window).Truncate(time.Minute)
+		for _, b := range t.buckets {
+			if !b.start.Before(since) {
+				add(b.usage)
+			}
+		}
+	}
+	for apiKey, n := range t.active {
+		usageFor(usage, apiKey).ActiveParticipants = n
+	}
+
+	res := make([]*KeyUsage, 0, len(usage))
+	for _, u := range usage {
+		res = append(res, u)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].APIKey < res[j].APIKey
+	})
+	return res
+}
+
+func (t *keyUsageTracker) accrueWorker() {
+	ticker := time.NewTicker(keyUsageAccrueInterval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		t.lock.Lock()
+		t.accrueLocked(now)
+		t.lock.Unlock()
+	}
+}
+
+func (t *keyUsageTracker) accrueLocked(now time.Time) {
+	elapsed := now.Sub(t.accruedAt)
+	t.accruedAt = now
+	if elapsed <= 0 {
+		return
+	}
+
+	bucket := t.bucketLocked(now)
+	for apiKey, n := range t.active {
+		seconds := float64(n) * elapsed.Seconds()
+		if promKeyParticipantSeconds != nil {
+			promKeyParticipantSeconds.WithLabelValues(apiKey).Add(seconds)
+		}
+		usageFor(t.total, apiKey).ParticipantMinutes += seconds / 60
+		usageFor(bucket.usage, apiKey).ParticipantMinutes += seconds / 60
+	}
+}
+
+// bucketLocked returns the bucket of the current minute, dropping buckets past retention
+func (t *keyUsageTracker) bucketLocked(now time.Time) *keyUsageBucket {
+	start := now.Truncate(time.Minute)
+	if n := len(t.buckets); n > 0 && t.buckets[n-1].start.Equal(start) {
+		return t.buckets[n-1]
+	}
+
+	expiry := start.Add(-keyUsageRetention)
+	for len(t.buckets) > 0 && !t.buckets[0].start.After(expiry) {
+		t.buckets = t.buckets[1:]
+	}
+	b := &keyUsageBucket{start: start, usage: make(map[string]*KeyUsage)}
+	t.buckets = append(t.buckets, b)
+	return b
+}
+
+func usageFor(usage map[string]*KeyUsage, apiKey string) *KeyUsage {
+	u := usage[apiKey]
+	if u == nil {
+		u = &KeyUsage{APIKey: apiKey}
+		usage[apiKey] = u
+	}
+	return u
+}
@@ -0,0 +1,111 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestKeyUsageTracker() *keyUsageTracker {
+	return &keyUsageTracker{
+		active:    make(map[string]int),
+		accruedAt: time.Now(),
+		total:     make(map[string]*KeyUsage),
+	}
+}
+
+func TestKeyUsageWindows(t *testing.T) {
+	tracker := newTestKeyUsageTracker()
+	now := time.Now()
+	// usage 30 minutes ago and in the current minute
+	old := tracker.bucketLocked(now.Add(-30 * time.Minute))
+	usageFor(old.usage, "a").RoomsCreated = 2
+	usageFor(tracker.total, "a").RoomsCreated = 2
+	tracker.update("b", func(u *KeyUsage) { u.TokensValidated++ })
+	tracker.update("a", func(u *KeyUsage) { u.TokensValidated++ })
+
+	testCases := []struct {
+		name     string
+		window   time.Duration
+		expected []*KeyUsage
+	}{
+		{
+			name:   "since start",
+			window: 0,
+			expected: []*KeyUsage{
+				{APIKey: "a", TokensValidated: 1, RoomsCreated: 2},
+				{APIKey: "b", TokensValidated: 1},
+			},
+		},
+		{
+			name:   "last hour",
+			window: time.Hour,
+			expected: []*KeyUsage{
+				{APIKey: "a", TokensValidated: 1, RoomsCreated: 2},
+				{APIKey: "b", TokensValidated: 1},
+			},
+		},
+		{
+			name:   "last minutes",
+			window: 5 * time.Minute,
+			expected: []*KeyUsage{
+				{APIKey: "a", TokensValidated: 1},
+				{APIKey: "b", TokensValidated: 1},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, tracker.get(tc.window))
+		})
+	}
+}
+
+func TestKeyUsageParticipantMinutes(t *testing.T) {
+	tracker := newTestKeyUsageTracker()
+	now := time.Now()
+	tracker.active["a"] = 2
+	tracker.accruedAt = now.Add(-30 * time.Second)
+
+	tracker.accrueLocked(now)
+	require.InDelta(t, 1.0, tracker.total["a"].ParticipantMinutes, 0.001)
+	require.InDelta(t, 1.0, tracker.bucketLocked(now).usage["a"].ParticipantMinutes, 0.001)
+
+	// leaving stops accruing, earlier time stays attributed
+	tracker.setActive("a", -2)
+	require.Empty(t, tracker.active)
+	usage := tracker.get(0)
+	require.Len(t, usage, 1)
+	require.Zero(t, usage[0].ActiveParticipants)
+	require.InDelta(t, 1.0, usage[0].ParticipantMinutes, 0.01)
+}
+
+func TestKeyUsageRetention(t *testing.T) {
+	tracker := newTestKeyUsageTracker()
+	now := time.Now()
+	tracker.bucketLocked(now.Add(-keyUsageRetention - 30*time.Minute))
+	tracker.bucketLocked(now.Add(-keyUsageRetention - 20*time.Minute))
+	require.Len(t, tracker.buckets, 2)
+
+	// buckets past retention are dropped once a new minute starts
+	tracker.bucketLocked(now.Add(-keyUsageRetention / 2))
+	tracker.bucketLocked(now)
+	require.Len(t, tracker.buckets, 2)
+	require.Equal(t, now.Add(-keyUsageRetention/2).Truncate(time.Minute), tracker.buckets[0].start)
+	require.Equal(t, now.Truncate(time.Minute), tracker.buckets[1].start)
+}
@@ -0,0 +1,42 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/livekit/protocol/livekit"
+)
+
+var muteCorrectionTotal *prometheus.CounterVec
+
+func initMuteStats(nodeID string, nodeType livekit.NodeType, env string) {
+	muteCorrectionTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "track",
+		Name:        "mute_correction_total",
+		ConstLabels: prometheus.Labels{"node_id": nodeID, "node_type": nodeType.String(), "env": env},
+		Help:        "Published tracks whose signaled mute state diverged from the media received, by correction.",
+	}, []string{"kind", "action"})
+
+	prometheus.MustRegister(muteCorrectionTotal)
+}
+
+func RecordMuteCorrection(kind livekit.TrackType, action string) {
+	if muteCorrectionTotal == nil {
+		return
+	}
+	muteCorrectionTotal.WithLabelValues(kind.String(), action).Inc()
+}
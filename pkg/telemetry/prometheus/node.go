@@ -15,6 +15,7 @@
 package prometheus
 
 import (
+	"context"
 	"time"
 
 	"github.com/mackerelio/go-osstat/memory"
@@ -110,6 +111,21 @@ func Init(nodeID string, nodeType livekit.NodeType, env string) {
 	initRoomStats(nodeID, nodeType, env)
 	initPSRPCStats(nodeID, nodeType, env)
 	initQualityStats(nodeID, nodeType, env)
+	initAuthStats(nodeID, nodeType, env)
+	initStorageStats(nodeID, nodeType, env)
+	initTransportStats(nodeID, nodeType, env)
+	initRouterStats(nodeID, nodeType, env)
+	initTurnStats(nodeID, nodeType, env)
+	initKeyUsageStats(nodeID, nodeType, env)
+	initHeartbeatStats(nodeID, nodeType, env)
+	initWatchdogStats(nodeID, nodeType, env)
+	initMuteStats(nodeID, nodeType, env)
+	initWebhookStats(nodeID, nodeType, env)
+	initBudgetStats(nodeID, nodeType, env)
+}
+
+func IncrementTwirpRequestStatus(ctx context.Context, service string, method string, statusFamily string, code string) {
+	addWithExemplar(ctx, TwirpRequestStatusCounter.WithLabelValues(service, method, statusFamily, code), 1)
 }
 
 func GetUpdatedNodeStats(prev *livekit.NodeStats, prevAverage *livekit.NodeStats) (*livekit.NodeStats, bool, error) {
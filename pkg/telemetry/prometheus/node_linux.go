@@ -19,6 +19,8 @@ package prometheus
 
 import (
 	"fmt"
+	"os"
+	"syscall"
 
 	"github.com/florianl/go-tc"
 )
@@ -44,3 +46,21 @@ func getTCStats() (packets, drops uint32, err error) {
 
 	return
 }
+
+func getFDStats() (open, limit uint64, err error) {
+	entries, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		err = fmt.Errorf("could not list file descriptors: %v", err)
+		return
+	}
+	// includes the descriptor of the listing itself
+	open = uint64(len(entries)) - 1
+
+	var rlimit syscall.Rlimit
+	if err = syscall.Getrlimit(syscall.RLIMIT_NOFILE, &rlimit); err != nil {
+		err = fmt.Errorf("could not get file descriptor limit: %v", err)
+		return
+	}
+	limit = rlimit.Cur
+	return
+}
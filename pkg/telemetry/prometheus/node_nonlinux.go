@@ -20,3 +20,8 @@ func getTCStats() (packets, drops uint32, err error) {
 	// linux only
 	return
 }
+
+func getFDStats() (open, limit uint64, err error) {
+	// linux only
+	return
+}
@@ -15,6 +15,9 @@
 package prometheus
 
 import (
+	"context"
+	"time"
+
 	"github.com/prometheus/client_golang/prometheus"
 	"go.uber.org/atomic"
 
@@ -28,6 +31,7 @@ const (
 	Outgoing               Direction = "outgoing"
 	transmissionInitial              = "initial"
 	transmissionRetransmit           = "retransmit"
+	transmissionPadding              = "padding"
 )
 
 var (
@@ -55,6 +59,7 @@ var (
 	promJitter          *prometheus.HistogramVec
 	promRTT             *prometheus.HistogramVec
 	promParticipantJoin *prometheus.CounterVec
+	promJoinLatency     prometheus.Histogram
 	promConnections     *prometheus.GaugeVec
 
 	promPacketTotalIncomingInitial    prometheus.Counter
@@ -65,6 +70,12 @@ var (
 	promPacketBytesIncomingRetransmit prometheus.Counter
 	promPacketBytesOutgoingInitial    prometheus.Counter
 	promPacketBytesOutgoingRetransmit prometheus.Counter
+
+	// padding only packets, used for probing, are accounted separately from media
+	promPacketTotalIncomingPadding prometheus.Counter
+	promPacketTotalOutgoingPadding prometheus.Counter
+	promPacketBytesIncomingPadding prometheus.Counter
+	promPacketBytesOutgoingPadding prometheus.Counter
 )
 
 func initPacketStats(nodeID string, nodeType livekit.NodeType, env string) {
@@ -131,6 +142,13 @@ func initPacketStats(nodeID string, nodeType livekit.NodeType, env string) {
 		Name:        "total",
 		ConstLabels: prometheus.Labels{"node_id": nodeID, "node_type": nodeType.String(), "env": env},
 	}, []string{"state"})
+	promJoinLatency = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "participant_join",
+		Name:        "latency_ms",
+		ConstLabels: prometheus.Labels{"node_id": nodeID, "node_type": nodeType.String(), "env": env},
+		Buckets:     []float64{50, 100, 200, 300, 500, 750, 1000, 2000, 5000, 10000, 20000},
+	})
 	promConnections = prometheus.NewGaugeVec(prometheus.GaugeOpts{
 		Namespace:   livekitNamespace,
 		Subsystem:   "connection",
@@ -148,6 +166,7 @@ func initPacketStats(nodeID string, nodeType livekit.NodeType, env string) {
 	prometheus.MustRegister(promJitter)
 	prometheus.MustRegister(promRTT)
 	prometheus.MustRegister(promParticipantJoin)
+	prometheus.MustRegister(promJoinLatency)
 	prometheus.MustRegister(promConnections)
 
 	promPacketTotalIncomingInitial = promPacketTotal.WithLabelValues(string(Incoming), transmissionInitial)
@@ -158,8 +177,14 @@ func initPacketStats(nodeID string, nodeType livekit.NodeType, env string) {
 	promPacketBytesIncomingRetransmit = promPacketBytes.WithLabelValues(string(Incoming), transmissionRetransmit)
 	promPacketBytesOutgoingInitial = promPacketBytes.WithLabelValues(string(Outgoing), transmissionInitial)
 	promPacketBytesOutgoingRetransmit = promPacketBytes.WithLabelValues(string(Outgoing), transmissionRetransmit)
+	promPacketTotalIncomingPadding = promPacketTotal.WithLabelValues(string(Incoming), transmissionPadding)
+	promPacketTotalOutgoingPadding = promPacketTotal.WithLabelValues(string(Outgoing), transmissionPadding)
+	promPacketBytesIncomingPadding = promPacketBytes.WithLabelValues(string(Incoming), transmissionPadding)
+	promPacketBytesOutgoingPadding = promPacketBytes.WithLabelValues(string(Outgoing), transmissionPadding)
 }
 
+// IncrementPackets records media packets, or retransmitted (NACK'ed and RTX) packets when retransmit is set.
+// Both are labelled apart and both count towards node packet counters, which reflect the load of the node
 func IncrementPackets(direction Direction, count uint64, retransmit bool) {
 	if direction == Incoming {
 		if retransmit {
@@ -185,6 +210,7 @@ func IncrementPackets(direction Direction, count uint64, retransmit bool) {
 	}
 }
 
+// IncrementBytes records bytes the same way as IncrementPackets
 func IncrementBytes(direction Direction, count uint64, retransmit bool) {
 	if direction == Incoming {
 		if retransmit {
@@ -210,6 +236,18 @@ func IncrementBytes(direction Direction, count uint64, retransmit bool) {
 	}
 }
 
+// IncrementPadding records padding only packets. They are not included in node packet/byte counters
+// so that those reflect media throughput.
+func IncrementPadding(direction Direction, packets uint64, bytes uint64) {
+	if direction == Incoming {
+		promPacketTotalIncomingPadding.Add(float64(packets))
+		promPacketBytesIncomingPadding.Add(float64(bytes))
+	} else {
+		promPacketTotalOutgoingPadding.Add(float64(packets))
+		promPacketBytesOutgoingPadding.Add(float64(bytes))
+	}
+}
+
 func IncrementRTCP(direction Direction, nack, pli, fir uint32) {
 	if nack > 0 {
 		promNackTotal.WithLabelValues(string(direction)).Add(float64(nack))
@@ -251,12 +289,17 @@ func IncrementParticipantJoin(join uint32) {
 	}
 }
 
-func IncrementParticipantJoinFail(join uint32) {
+func IncrementParticipantJoinFail(ctx context.Context, join uint32) {
 	if join > 0 {
-		promParticipantJoin.WithLabelValues("signal_failed").Add(float64(join))
+		addWithExemplar(ctx, promParticipantJoin.WithLabelValues("signal_failed"), float64(join))
 	}
 }
 
+// RecordParticipantJoinLatency records the time taken from a join request until the signal connection is established
+func RecordParticipantJoinLatency(ctx context.Context, latency time.Duration) {
+	observeWithExemplar(ctx, promJoinLatency, float64(latency.Milliseconds()))
+}
+
 func IncrementParticipantRtcInit(join uint32) {
 	if join > 0 {
 		participantRTCInit.Add(uint64(join))
@@ -15,6 +15,7 @@
 package prometheus
 
 import (
+	"context"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -43,6 +44,7 @@ var (
 	promTrackSubscribedCurrent *prometheus.GaugeVec
 	promTrackPublishCounter    *prometheus.CounterVec
 	promTrackSubscribeCounter  *prometheus.CounterVec
+	promPanicCounter           *prometheus.CounterVec
 )
 
 func initRoomStats(nodeID string, nodeType livekit.NodeType, env string) {
@@ -91,6 +93,13 @@ func initRoomStats(nodeID string, nodeType livekit.NodeType, env string) {
 		Name:        "subscribe_counter",
 		ConstLabels: prometheus.Labels{"node_id": nodeID, "node_type": nodeType.String(), "env": env},
 	}, []string{"state", "error"})
+	promPanicCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "participant",
+		Name:        "panic_total",
+		ConstLabels: prometheus.Labels{"node_id": nodeID, "node_type": nodeType.String(), "env": env},
+		Help:        "panics recovered in participant goroutines",
+	}, []string{"component"})
 
 	prometheus.MustRegister(promRoomCurrent)
 	prometheus.MustRegister(promRoomDuration)
@@ -99,6 +108,7 @@ func initRoomStats(nodeID string, nodeType livekit.NodeType, env string) {
 	prometheus.MustRegister(promTrackSubscribedCurrent)
 	prometheus.MustRegister(promTrackPublishCounter)
 	prometheus.MustRegister(promTrackSubscribeCounter)
+	prometheus.MustRegister(promPanicCounter)
 }
 
 func RoomStarted() {
@@ -124,6 +134,13 @@ func SubParticipant() {
 	participantCurrent.Dec()
 }
 
+func RecordPanic(component string) {
+	if promPanicCounter == nil {
+		return
+	}
+	promPanicCounter.WithLabelValues(component).Inc()
+}
+
 func AddPublishedTrack(kind string) {
 	promTrackPublishedCurrent.WithLabelValues(kind).Add(1)
 	trackPublishedCurrent.Inc()
@@ -165,8 +182,8 @@ func RecordTrackSubscribeAttempt() {
 	promTrackSubscribeCounter.WithLabelValues("attempt", "").Inc()
 }
 
-func RecordTrackSubscribeFailure(err error, isUserError bool) {
-	promTrackSubscribeCounter.WithLabelValues("failure", err.Error()).Inc()
+func RecordTrackSubscribeFailure(ctx context.Context, err error, isUserError bool) {
+	addWithExemplar(ctx, promTrackSubscribeCounter.WithLabelValues("failure", err.Error()), 1)
 
 	if isUserError {
 		trackSubscribeUserError.Inc()
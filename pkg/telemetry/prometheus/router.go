@@ -0,0 +1,107 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/livekit/protocol/livekit"
+)
+
+var (
+	routerPublishes  prometheus.Counter
+	routerMessages   prometheus.Counter
+	routerBytes      *prometheus.CounterVec
+	routerBytesSaved prometheus.Counter
+	routerDegraded   prometheus.Gauge
+	routerDegrades   prometheus.Counter
+)
+
+func initRouterStats(nodeID string, nodeType livekit.NodeType, env string) {
+	routerPublishes = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "router",
+		Name:        "publishes_total",
+		ConstLabels: prometheus.Labels{"node_id": nodeID, "node_type": nodeType.String(), "env": env},
+		Help:        "number of Redis publishes carrying inter-node router messages",
+	})
+	routerMessages = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "router",
+		Name:        "messages_total",
+		ConstLabels: prometheus.Labels{"node_id": nodeID, "node_type": nodeType.String(), "env": env},
+		Help:        "number of inter-node router messages published, a publish may batch several messages",
+	})
+	routerBytes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "router",
+		Name:        "bytes_total",
+		ConstLabels: prometheus.Labels{"node_id": nodeID, "node_type": nodeType.String(), "env": env},
+		Help:        "router message bytes before (raw) and after (published) batching and compression",
+	}, []string{"stage"})
+	routerBytesSaved = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "router",
+		Name:        "bytes_saved_total",
+		ConstLabels: prometheus.Labels{"node_id": nodeID, "node_type": nodeType.String(), "env": env},
+		Help:        "router message bytes saved by compression",
+	})
+	routerDegraded = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "router",
+		Name:        "degraded",
+		ConstLabels: prometheus.Labels{"node_id": nodeID, "node_type": nodeType.String(), "env": env},
+		Help:        "1 while Redis is unreachable and the node only routes its own rooms",
+	})
+	routerDegrades = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "router",
+		Name:        "degraded_total",
+		ConstLabels: prometheus.Labels{"node_id": nodeID, "node_type": nodeType.String(), "env": env},
+		Help:        "number of times the node lost Redis and switched to local routing",
+	})
+
+	prometheus.MustRegister(routerPublishes)
+	prometheus.MustRegister(routerMessages)
+	prometheus.MustRegister(routerBytes)
+	prometheus.MustRegister(routerBytesSaved)
+	prometheus.MustRegister(routerDegraded)
+	prometheus.MustRegister(routerDegrades)
+}
+
+func RecordRouterPublish(messages int, rawBytes int, publishedBytes int) {
+	if routerPublishes == nil {
+		return
+	}
+	routerPublishes.Inc()
+	routerMessages.Add(float64(messages))
+	routerBytes.WithLabelValues("raw").Add(float64(rawBytes))
+	routerBytes.WithLabelValues("published").Add(float64(publishedBytes))
+	if rawBytes > publishedBytes {
+		routerBytesSaved.Add(float64(rawBytes - publishedBytes))
+	}
+}
+
+func RecordRouterDegraded(degraded bool) {
+	if routerDegraded == nil {
+		return
+	}
+	if degraded {
+		routerDegraded.Set(1)
+		routerDegrades.Inc()
+	} else {
+		routerDegraded.Set(0)
+	}
+}
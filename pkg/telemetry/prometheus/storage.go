@@ -0,0 +1,82 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/livekit/protocol/livekit"
+)
+
+var (
+	storageUploadTotal    *prometheus.CounterVec
+	storageUploadBytes    *prometheus.CounterVec
+	storageUploadRetries  *prometheus.CounterVec
+	storageUploadDuration *prometheus.HistogramVec
+)
+
+func initStorageStats(nodeID string, nodeType livekit.NodeType, env string) {
+	storageUploadTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "storage",
+		Name:        "upload_total",
+		ConstLabels: prometheus.Labels{"node_id": nodeID, "node_type": nodeType.String(), "env": env},
+	}, []string{"provider", "profile", "status"})
+	storageUploadBytes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "storage",
+		Name:        "upload_bytes_total",
+		ConstLabels: prometheus.Labels{"node_id": nodeID, "node_type": nodeType.String(), "env": env},
+	}, []string{"provider", "profile"})
+	storageUploadRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "storage",
+		Name:        "upload_retries_total",
+		ConstLabels: prometheus.Labels{"node_id": nodeID, "node_type": nodeType.String(), "env": env},
+	}, []string{"provider", "profile"})
+	storageUploadDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "storage",
+		Name:        "upload_duration_seconds",
+		ConstLabels: prometheus.Labels{"node_id": nodeID, "node_type": nodeType.String(), "env": env},
+		Buckets:     []float64{0.1, 0.5, 1, 5, 10, 30, 60, 300, 900, 3600},
+	}, []string{"provider", "profile"})
+
+	prometheus.MustRegister(storageUploadTotal)
+	prometheus.MustRegister(storageUploadBytes)
+	prometheus.MustRegister(storageUploadRetries)
+	prometheus.MustRegister(storageUploadDuration)
+}
+
+func RecordStorageUpload(provider string, profile string, success bool, duration time.Duration) {
+	status := "success"
+	if !success {
+		status = "failure"
+	}
+	storageUploadTotal.WithLabelValues(provider, profile, status).Inc()
+	if success {
+		storageUploadDuration.WithLabelValues(provider, profile).Observe(duration.Seconds())
+	}
+}
+
+func AddStorageUploadBytes(provider string, profile string, bytes int) {
+	storageUploadBytes.WithLabelValues(provider, profile).Add(float64(bytes))
+}
+
+func RecordStorageUploadRetry(provider string, profile string) {
+	storageUploadRetries.WithLabelValues(provider, profile).Inc()
+}
@@ -0,0 +1,44 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/livekit/protocol/livekit"
+)
+
+var (
+	srtpProtectionProfileTotal *prometheus.CounterVec
+)
+
+func initTransportStats(nodeID string, nodeType livekit.NodeType, env string) {
+	srtpProtectionProfileTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "transport",
+		Name:        "srtp_protection_profile_total",
+		ConstLabels: prometheus.Labels{"node_id": nodeID, "node_type": nodeType.String(), "env": env},
+		Help:        "Connections by negotiated SRTP protection profile.",
+	}, []string{"profile", "direction"})
+
+	prometheus.MustRegister(srtpProtectionProfileTotal)
+}
+
+func RecordSRTPProtectionProfile(profile string, direction string) {
+	if srtpProtectionProfileTotal == nil {
+		return
+	}
+	srtpProtectionProfileTotal.WithLabelValues(profile, direction).Inc()
+}
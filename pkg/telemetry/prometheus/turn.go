@@ -0,0 +1,99 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/livekit/protocol/livekit"
+)
+
+var (
+	turnAllocations        *prometheus.GaugeVec
+	turnRelayedBytes       *prometheus.CounterVec
+	turnAllocationFailures *prometheus.CounterVec
+	turnDroppedBytes       *prometheus.CounterVec
+)
+
+func initTurnStats(nodeID string, nodeType livekit.NodeType, env string) {
+	turnAllocations = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "turn",
+		Name:        "allocations",
+		ConstLabels: prometheus.Labels{"node_id": nodeID, "node_type": nodeType.String(), "env": env},
+		Help:        "active TURN allocations by client transport",
+	}, []string{"transport"})
+	turnRelayedBytes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "turn",
+		Name:        "relayed_bytes_total",
+		ConstLabels: prometheus.Labels{"node_id": nodeID, "node_type": nodeType.String(), "env": env},
+		Help:        "bytes relayed by TURN allocations, incoming from peers and outgoing to them",
+	}, []string{"transport", "direction"})
+	turnAllocationFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "turn",
+		Name:        "allocation_failures_total",
+		ConstLabels: prometheus.Labels{"node_id": nodeID, "node_type": nodeType.String(), "env": env},
+		Help:        "TURN allocations that failed, by reason",
+	}, []string{"reason"})
+	turnDroppedBytes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "turn",
+		Name:        "dropped_bytes_total",
+		ConstLabels: prometheus.Labels{"node_id": nodeID, "node_type": nodeType.String(), "env": env},
+		Help:        "bytes dropped by TURN allocations exceeding turn.max_allocation_bitrate",
+	}, []string{"transport", "direction"})
+
+	prometheus.MustRegister(turnAllocations)
+	prometheus.MustRegister(turnRelayedBytes)
+	prometheus.MustRegister(turnAllocationFailures)
+	prometheus.MustRegister(turnDroppedBytes)
+}
+
+func AddTurnAllocation(transport string) {
+	if turnAllocations == nil {
+		return
+	}
+	turnAllocations.WithLabelValues(transport).Inc()
+}
+
+func SubTurnAllocation(transport string) {
+	if turnAllocations == nil {
+		return
+	}
+	turnAllocations.WithLabelValues(transport).Dec()
+}
+
+func IncrementTurnRelayedBytes(transport string, direction Direction, bytes uint64) {
+	if turnRelayedBytes == nil {
+		return
+	}
+	turnRelayedBytes.WithLabelValues(transport, string(direction)).Add(float64(bytes))
+}
+
+func RecordTurnAllocationFailure(reason string) {
+	if turnAllocationFailures == nil {
+		return
+	}
+	turnAllocationFailures.WithLabelValues(reason).Inc()
+}
+
+func IncrementTurnDroppedBytes(transport string, direction Direction, bytes uint64) {
+	if turnDroppedBytes == nil {
+		return
+	}
+	turnDroppedBytes.WithLabelValues(transport, string(direction)).Add(float64(bytes))
+}
@@ -0,0 +1,79 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/livekit/protocol/livekit"
+)
+
+var (
+	webhookDeliveries      *prometheus.CounterVec
+	webhookRequestDuration *prometheus.HistogramVec
+	webhookArchived        *prometheus.CounterVec
+)
+
+func initWebhookStats(nodeID string, nodeType livekit.NodeType, env string) {
+	webhookDeliveries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "webhook",
+		Name:        "deliveries_total",
+		ConstLabels: prometheus.Labels{"node_id": nodeID, "node_type": nodeType.String(), "env": env},
+		Help:        "Webhook events by URL and outcome: success, retry, failure, dead_lettered or dropped.",
+	}, []string{"url", "result"})
+	webhookRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "webhook",
+		Name:        "request_duration_seconds",
+		ConstLabels: prometheus.Labels{"node_id": nodeID, "node_type": nodeType.String(), "env": env},
+		Buckets:     []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+	}, []string{"url"})
+
+	webhookArchived = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "webhook",
+		Name:        "archived_total",
+		ConstLabels: prometheus.Labels{"node_id": nodeID, "node_type": nodeType.String(), "env": env},
+		Help:        "Webhook payloads by archiving outcome: archived, failed or dropped.",
+	}, []string{"result"})
+
+	prometheus.MustRegister(webhookDeliveries)
+	prometheus.MustRegister(webhookRequestDuration)
+	prometheus.MustRegister(webhookArchived)
+}
+
+func RecordWebhookDelivery(url string, result string) {
+	if webhookDeliveries == nil {
+		return
+	}
+	webhookDeliveries.WithLabelValues(url, result).Inc()
+}
+
+func RecordWebhookRequest(url string, duration time.Duration) {
+	if webhookRequestDuration == nil {
+		return
+	}
+	webhookRequestDuration.WithLabelValues(url).Observe(duration.Seconds())
+}
+
+func RecordWebhookArchived(result string) {
+	if webhookArchived == nil {
+		return
+	}
+	webhookArchived.WithLabelValues(result).Inc()
+}
@@ -0,0 +1,162 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package telemetry
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/webhook"
+)
+
+// Redactor strips or hashes personal data from outgoing event payloads.
+// Events are cloned before redaction since they share protos with live room state.
+type Redactor struct {
+	identity bool
+	name     bool
+	metadata bool
+	hash     bool
+	salt     string
+}
+
+// NewRedactor returns nil when no fields are configured for redaction
+func NewRedactor(conf *config.RedactionConfig) *Redactor {
+	if len(conf.Fields) == 0 {
+		return nil
+	}
+
+	r := &Redactor{
+		hash: conf.Hash,
+		salt: conf.Salt,
+	}
+	for _, field := range conf.Fields {
+		switch field {
+		case config.RedactFieldIdentity:
+			r.identity = true
+		case config.RedactFieldName:
+			r.name = true
+		case config.RedactFieldMetadata:
+			r.metadata = true
+		}
+	}
+	return r
+}
+
+func (r *Redactor) RedactWebhookEvent(event *livekit.WebhookEvent) *livekit.WebhookEvent {
+	if r == nil {
+		return event
+	}
+
+	event = proto.Clone(event).(*livekit.WebhookEvent)
+	r.redactRoom(event.Room)
+	r.redactParticipant(event.Participant)
+	r.redactEgress(event.EgressInfo)
+	r.redactIngress(event.IngressInfo)
+	return event
+}
+
+func (r *Redactor) RedactAnalyticsEvent(event *livekit.AnalyticsEvent) *livekit.AnalyticsEvent {
+	if r == nil {
+		return event
+	}
+
+	event = proto.Clone(event).(*livekit.AnalyticsEvent)
+	r.redactRoom(event.Room)
+	r.redactParticipant(event.Participant)
+	r.redactParticipant(event.Publisher)
+	r.redactEgress(event.Egress)
+	r.redactIngress(event.Ingress)
+	return event
+}
+
+func (r *Redactor) redactRoom(room *livekit.Room) {
+	if room == nil {
+		return
+	}
+	if r.metadata {
+		room.Metadata = r.redact(room.Metadata)
+	}
+}
+
+func (r *Redactor) redactParticipant(pi *livekit.ParticipantInfo) {
+	if pi == nil {
+		return
+	}
+	if r.identity {
+		pi.Identity = r.redact(pi.Identity)
+	}
+	if r.name {
+		pi.Name = r.redact(pi.Name)
+	}
+	if r.metadata {
+		pi.Metadata = r.redact(pi.Metadata)
+	}
+}
+
+func (r *Redactor) redactEgress(info *livekit.EgressInfo) {
+	if info == nil || !r.identity {
+		return
+	}
+	if req, ok := info.Request.(*livekit.EgressInfo_Participant); ok && req.Participant != nil {
+		req.Participant.Identity = r.redact(req.Participant.Identity)
+	}
+}
+
+func (r *Redactor) redactIngress(info *livekit.IngressInfo) {
+	if info == nil {
+		return
+	}
+	if r.identity {
+		info.ParticipantIdentity = r.redact(info.ParticipantIdentity)
+	}
+	if r.name {
+		info.ParticipantName = r.redact(info.ParticipantName)
+	}
+}
+
+func (r *Redactor) redact(value string) string {
+	if value == "" || !r.hash {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(r.salt + value))
+	return hex.EncodeToString(sum[:])
+}
+
+// ------------------------------------------------
+
+type redactingNotifier struct {
+	webhook.QueuedNotifier
+	redactor *Redactor
+}
+
+// NewRedactingNotifier wraps a webhook notifier, redacting events before they are queued
+func NewRedactingNotifier(notifier webhook.QueuedNotifier, redactor *Redactor) webhook.QueuedNotifier {
+	if notifier == nil || redactor == nil {
+		return notifier
+	}
+	return &redactingNotifier{
+		QueuedNotifier: notifier,
+		redactor:       redactor,
+	}
+}
+
+func (n *redactingNotifier) QueueNotify(ctx context.Context, event *livekit.WebhookEvent) error {
+	return n.QueuedNotifier.QueueNotify(ctx, n.redactor.RedactWebhookEvent(event))
+}
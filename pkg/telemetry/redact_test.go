@@ -0,0 +1,73 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package telemetry_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/telemetry"
+	"github.com/livekit/protocol/livekit"
+)
+
+func TestRedactor(t *testing.T) {
+	event := &livekit.WebhookEvent{
+		Room: &livekit.Room{Sid: "RM_1", Name: "room", Metadata: "room meta"},
+		Participant: &livekit.ParticipantInfo{
+			Sid:      "PA_1",
+			Identity: "student@campus.edu",
+			Name:     "Student",
+			Metadata: "{}",
+		},
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		r := telemetry.NewRedactor(&config.RedactionConfig{})
+		require.Nil(t, r)
+		require.Same(t, event, r.RedactWebhookEvent(event))
+	})
+
+	t.Run("clear", func(t *testing.T) {
+		r := telemetry.NewRedactor(&config.RedactionConfig{
+			Fields: []string{config.RedactFieldIdentity, config.RedactFieldName},
+		})
+		redacted := r.RedactWebhookEvent(event)
+		require.Equal(t, "PA_1", redacted.Participant.Sid)
+		require.Empty(t, redacted.Participant.Identity)
+		require.Empty(t, redacted.Participant.Name)
+		require.Equal(t, "{}", redacted.Participant.Metadata)
+		require.Equal(t, "room meta", redacted.Room.Metadata)
+
+		// original event is untouched
+		require.Equal(t, "student@campus.edu", event.Participant.Identity)
+	})
+
+	t.Run("hash", func(t *testing.T) {
+		r := telemetry.NewRedactor(&config.RedactionConfig{
+			Fields: []string{config.RedactFieldIdentity, config.RedactFieldMetadata},
+			Hash:   true,
+			Salt:   "salt",
+		})
+		first := r.RedactWebhookEvent(event)
+		second := r.RedactWebhookEvent(event)
+		require.NotEqual(t, event.Participant.Identity, first.Participant.Identity)
+		require.Len(t, first.Participant.Identity, 64)
+		require.Equal(t, first.Participant.Identity, second.Participant.Identity)
+		require.NotEqual(t, event.Room.Metadata, first.Room.Metadata)
+		require.Equal(t, "Student", first.Participant.Name)
+	})
+}
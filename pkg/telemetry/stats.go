@@ -47,6 +47,19 @@ func StatsKeyForData(streamType livekit.StreamType, participantID livekit.Partic
 	}
 }
 
+func (k StatsKey) ParticipantID() livekit.ParticipantID {
+	return k.participantID
+}
+
+func (k StatsKey) StreamType() livekit.StreamType {
+	return k.streamType
+}
+
+// IsTrack is false for data channel stats
+func (k StatsKey) IsTrack() bool {
+	return k.track
+}
+
 func (t *telemetryService) TrackStats(key StatsKey, stat *livekit.AnalyticsStat) {
 	t.enqueue(func() {
 		direction := prometheus.Incoming
@@ -61,20 +74,18 @@ func (t *telemetryService) TrackStats(key StatsKey, stat *livekit.AnalyticsStat)
 		bytes := uint64(0)
 		retransmitBytes := uint64(0)
 		retransmitPackets := uint32(0)
+		paddingBytes := uint64(0)
+		paddingPackets := uint32(0)
 		for _, stream := range stat.Streams {
 			nacks += stream.Nacks
 			plis += stream.Plis
 			firs += stream.Firs
-			packets += stream.PrimaryPackets + stream.PaddingPackets
-			bytes += stream.PrimaryBytes + stream.PaddingBytes
-			if key.streamType == livekit.StreamType_DOWNSTREAM {
-				retransmitPackets += stream.RetransmitPackets
-				retransmitBytes += stream.RetransmitBytes
-			} else {
-				// for upstream, we don't account for these separately for now
-				packets += stream.RetransmitPackets
-				bytes += stream.RetransmitBytes
-			}
+			packets += stream.PrimaryPackets
+			bytes += stream.PrimaryBytes
+			retransmitPackets += stream.RetransmitPackets
+			retransmitBytes += stream.RetransmitBytes
+			paddingPackets += stream.PaddingPackets
+			paddingBytes += stream.PaddingBytes
 			if key.track {
 				prometheus.RecordPacketLoss(direction, key.trackSource, key.trackType, stream.PacketsLost, stream.PrimaryPackets+stream.PaddingPackets)
 				prometheus.RecordRTT(direction, key.trackSource, key.trackType, stream.Rtt)
@@ -90,6 +101,9 @@ func (t *telemetryService) TrackStats(key StatsKey, stat *livekit.AnalyticsStat)
 		if retransmitBytes != 0 {
 			prometheus.IncrementBytes(direction, retransmitBytes, true)
 		}
+		if paddingPackets != 0 || paddingBytes != 0 {
+			prometheus.IncrementPadding(direction, uint64(paddingPackets), paddingBytes)
+		}
 
 		if worker, ok := t.getWorker(key.participantID); ok {
 			worker.OnTrackStat(key.trackID, key.streamType, stat)
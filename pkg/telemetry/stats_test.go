@@ -19,6 +19,7 @@ import (
 	"testing"
 	"time"
 
+	promclient "github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/require"
 
 	"github.com/livekit/livekit-server/pkg/telemetry"
@@ -614,6 +615,78 @@ func Test_BothDownstreamAndUpstreamStatsAreSentTogether(t *testing.T) {
 	require.Equal(t, livekit.StreamType_DOWNSTREAM, stats[1].Kind)
 }
 
+func Test_RetransmitAndPaddingAccountedSeparately(t *testing.T) {
+	testCases := []struct {
+		name       string
+		streamType livekit.StreamType
+		direction  prometheus.Direction
+	}{
+		{name: "upstream", streamType: livekit.StreamType_UPSTREAM, direction: prometheus.Incoming},
+		{name: "downstream", streamType: livekit.StreamType_DOWNSTREAM, direction: prometheus.Outgoing},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fixture := createFixture()
+			partSID := livekit.ParticipantID("part1")
+			fixture.sut.ParticipantJoined(context.Background(), &livekit.Room{}, &livekit.ParticipantInfo{Sid: string(partSID)}, nil, nil, true)
+
+			before := map[string]float64{}
+			for _, transmission := range []string{"initial", "retransmit", "padding"} {
+				before[transmission] = packetCounter(t, "livekit_packet_total", tc.direction, transmission)
+				before[transmission+"_bytes"] = packetCounter(t, "livekit_packet_bytes", tc.direction, transmission)
+			}
+
+			fixture.sut.TrackStats(telemetry.StatsKeyForData(tc.streamType, partSID, "trackID"), &livekit.AnalyticsStat{
+				Streams: []*livekit.AnalyticsStream{
+					{
+						PrimaryPackets:    10,
+						PrimaryBytes:      10000,
+						RetransmitPackets: 2,
+						RetransmitBytes:   2000,
+						PaddingPackets:    3,
+						PaddingBytes:      750,
+					},
+				},
+			})
+			fixture.flush()
+
+			expected := map[string]float64{
+				"initial":          10,
+				"initial_bytes":    10000,
+				"retransmit":       2,
+				"retransmit_bytes": 2000,
+				"padding":          3,
+				"padding_bytes":    750,
+			}
+			for _, transmission := range []string{"initial", "retransmit", "padding"} {
+				require.Equal(t, expected[transmission], packetCounter(t, "livekit_packet_total", tc.direction, transmission)-before[transmission], transmission)
+				require.Equal(t, expected[transmission+"_bytes"], packetCounter(t, "livekit_packet_bytes", tc.direction, transmission)-before[transmission+"_bytes"], transmission)
+			}
+		})
+	}
+}
+
+// packetCounter returns the value of a packet counter of the default registry
+func packetCounter(t *testing.T, name string, direction prometheus.Direction, transmission string) float64 {
+	families, err := promclient.DefaultGatherer.Gather()
+	require.NoError(t, err)
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			labels := map[string]string{}
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			if labels["direction"] == string(direction) && labels["transmission"] == transmission {
+				return metric.GetCounter().GetValue()
+			}
+		}
+	}
+	return 0
+}
+
 func (f *telemetryServiceFixture) flush() {
 	time.Sleep(time.Millisecond * 500)
 	f.sut.FlushStats()
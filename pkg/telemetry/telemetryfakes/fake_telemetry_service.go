@@ -177,6 +177,13 @@ type FakeTelemetryService struct {
 		arg2 livekit.ParticipantID
 		arg3 *livekit.TrackInfo
 	}
+	TrackStalledStub        func(context.Context, livekit.ParticipantID, *livekit.TrackInfo)
+	trackStalledMutex       sync.RWMutex
+	trackStalledArgsForCall []struct {
+		arg1 context.Context
+		arg2 livekit.ParticipantID
+		arg3 *livekit.TrackInfo
+	}
 	TrackStatsStub        func(telemetry.StatsKey, *livekit.AnalyticsStat)
 	trackStatsMutex       sync.RWMutex
 	trackStatsArgsForCall []struct {
@@ -1058,6 +1065,40 @@ func (fake *FakeTelemetryService) TrackPublishedUpdateArgsForCall(i int) (contex
 	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
 }
 
+func (fake *FakeTelemetryService) TrackStalled(arg1 context.Context, arg2 livekit.ParticipantID, arg3 *livekit.TrackInfo) {
+	fake.trackStalledMutex.Lock()
+	fake.trackStalledArgsForCall = append(fake.trackStalledArgsForCall, struct {
+		arg1 context.Context
+		arg2 livekit.ParticipantID
+		arg3 *livekit.TrackInfo
+	}{arg1, arg2, arg3})
+	stub := fake.TrackStalledStub
+	fake.recordInvocation("TrackStalled", []interface{}{arg1, arg2, arg3})
+	fake.trackStalledMutex.Unlock()
+	if stub != nil {
+		fake.TrackStalledStub(arg1, arg2, arg3)
+	}
+}
+
+func (fake *FakeTelemetryService) TrackStalledCallCount() int {
+	fake.trackStalledMutex.RLock()
+	defer fake.trackStalledMutex.RUnlock()
+	return len(fake.trackStalledArgsForCall)
+}
+
+func (fake *FakeTelemetryService) TrackStalledCalls(stub func(context.Context, livekit.ParticipantID, *livekit.TrackInfo)) {
+	fake.trackStalledMutex.Lock()
+	defer fake.trackStalledMutex.Unlock()
+	fake.TrackStalledStub = stub
+}
+
+func (fake *FakeTelemetryService) TrackStalledArgsForCall(i int) (context.Context, livekit.ParticipantID, *livekit.TrackInfo) {
+	fake.trackStalledMutex.RLock()
+	defer fake.trackStalledMutex.RUnlock()
+	argsForCall := fake.trackStalledArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
 func (fake *FakeTelemetryService) TrackStats(arg1 telemetry.StatsKey, arg2 *livekit.AnalyticsStat) {
 	fake.trackStatsMutex.Lock()
 	fake.trackStatsArgsForCall = append(fake.trackStatsArgsForCall, struct {
@@ -1389,6 +1430,8 @@ func (fake *FakeTelemetryService) Invocations() map[string][][]interface{} {
 	defer fake.trackPublishedMutex.RUnlock()
 	fake.trackPublishedUpdateMutex.RLock()
 	defer fake.trackPublishedUpdateMutex.RUnlock()
+	fake.trackStalledMutex.RLock()
+	defer fake.trackStalledMutex.RUnlock()
 	fake.trackStatsMutex.RLock()
 	defer fake.trackStatsMutex.RUnlock()
 	fake.trackSubscribeFailedMutex.RLock()
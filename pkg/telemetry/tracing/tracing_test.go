@@ -0,0 +1,48 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/livekit/psrpc/pkg/metadata"
+)
+
+func TestMetadataPropagation(t *testing.T) {
+	otel.SetTracerProvider(sdktrace.NewTracerProvider())
+
+	ctx, span := Start(context.Background(), "signal.Join")
+	defer span.End()
+	require.NotEmpty(t, TraceID(ctx))
+
+	// psrpc sends the outgoing metadata of the client as the incoming header of the server
+	md := metadata.OutgoingContextMetadata(InjectMetadata(ctx))
+	require.Contains(t, md, "traceparent")
+	received := metadata.NewContextWithIncomingHeader(context.Background(), &metadata.Header{Metadata: md})
+
+	remote := trace.SpanContextFromContext(ExtractMetadata(received))
+	require.True(t, remote.IsRemote())
+	require.Equal(t, TraceID(ctx), remote.TraceID().String())
+
+	// nothing to continue without a trace
+	require.Equal(t, context.Background(), InjectMetadata(context.Background()))
+	require.Empty(t, TraceID(ExtractMetadata(context.Background())))
+}
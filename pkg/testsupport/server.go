@@ -0,0 +1,268 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package testsupport boots an in-process server for end-to-end tests of services integrating with it.
+// The server listens on ephemeral ports on the loopback interface and keeps its state in memory.
+package testsupport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/twitchtv/twirp"
+
+	"github.com/livekit/mediatransportutil/pkg/rtcconfig"
+	"github.com/livekit/protocol/auth"
+	"github.com/livekit/protocol/livekit"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/routing"
+	"github.com/livekit/livekit-server/pkg/service"
+	"github.com/livekit/livekit-server/pkg/telemetry/prometheus"
+	testclient "github.com/livekit/livekit-server/test/client"
+)
+
+const (
+	DefaultAPIKey    = "testkey"
+	DefaultAPISecret = "testsecret-testsecret-testsecret"
+
+	startTimeout = 10 * time.Second
+)
+
+var (
+	ErrStartTimeout = errors.New("server did not start in time")
+
+	initOnce sync.Once
+)
+
+type Options struct {
+	APIKey    string
+	APISecret string
+	// applied to the config before the server is created
+	ConfigUpdater func(conf *config.Config)
+}
+
+type Server struct {
+	*service.LivekitServer
+
+	Config *config.Config
+	// http://127.0.0.1:<port>
+	URL string
+	// ws://127.0.0.1:<port>
+	WSURL string
+
+	apiKey    string
+	apiSecret string
+}
+
+// Start boots a single node server and waits until it accepts connections.
+func Start(opts *Options) (*Server, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	apiKey, apiSecret := opts.APIKey, opts.APISecret
+	if apiKey == "" || apiSecret == "" {
+		apiKey, apiSecret = DefaultAPIKey, DefaultAPISecret
+	}
+
+	conf, err := config.NewConfig("", true, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	initOnce.Do(func() {
+		config.InitLoggerFromConfig(&conf.Logging)
+		prometheus.Init("testsupport", livekit.NodeType_SERVER, "test")
+	})
+
+	httpPort, err := freePort("tcp")
+	if err != nil {
+		return nil, err
+	}
+	rtcTCPPort, err := freePort("tcp")
+	if err != nil {
+		return nil, err
+	}
+	rtcUDPPort, err := freePort("udp")
+	if err != nil {
+		return nil, err
+	}
+	conf.Port = uint32(httpPort)
+	conf.BindAddresses = []string{"127.0.0.1"}
+	conf.RTC.TCPPort = uint32(rtcTCPPort)
+	conf.RTC.UDPPort = rtcconfig.PortRange{Start: rtcUDPPort}
+	conf.Keys = map[string]string{apiKey: apiSecret}
+	if opts.ConfigUpdater != nil {
+		opts.ConfigUpdater(conf)
+	}
+
+	currentNode, err := routing.NewLocalNode(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	ls, err := service.InitializeServer(conf, currentNode)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		LivekitServer: ls,
+		Config:        conf,
+		URL:           fmt.Sprintf("http://127.0.0.1:%d", conf.Port),
+		WSURL:         fmt.Sprintf("ws://127.0.0.1:%d", conf.Port),
+		apiKey:        apiKey,
+		apiSecret:     apiSecret,
+	}
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- ls.Start()
+	}()
+	if err = s.waitUntilReady(errChan); err != nil {
+		ls.Stop(true)
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Server) Stop() {
+	s.LivekitServer.Stop(true)
+}
+
+func (s *Server) APIKey() string {
+	return s.apiKey
+}
+
+func (s *Server) APISecret() string {
+	return s.apiSecret
+}
+
+// Token signs an access token for identity with the given grant, identity can be empty for API tokens
+func (s *Server) Token(identity string, grant *auth.VideoGrant) (string, error) {
+	at := auth.NewAccessToken(s.apiKey, s.apiSecret).
+		AddGrant(grant).
+		SetIdentity(identity).
+		SetName(identity)
+	return at.ToJWT()
+}
+
+// JoinToken returns a token allowing identity to join room
+func (s *Server) JoinToken(room, identity string) (string, error) {
+	return s.Token(identity, &auth.VideoGrant{RoomJoin: true, Room: room})
+}
+
+// AdminContext returns a context authorizing RoomService calls that create, list and record rooms
+func (s *Server) AdminContext(ctx context.Context) (context.Context, error) {
+	token, err := s.Token("", &auth.VideoGrant{RoomCreate: true, RoomList: true, RoomRecord: true})
+	if err != nil {
+		return nil, err
+	}
+	return ContextWithToken(ctx, token)
+}
+
+// RoomAdminContext returns a context authorizing RoomService calls on room
+func (s *Server) RoomAdminContext(ctx context.Context, room string) (context.Context, error) {
+	token, err := s.Token("", &auth.VideoGrant{RoomAdmin: true, Room: room})
+	if err != nil {
+		return nil, err
+	}
+	return ContextWithToken(ctx, token)
+}
+
+// RoomClient returns a RoomService client, authorize calls with AdminContext or RoomAdminContext
+func (s *Server) RoomClient() livekit.RoomService {
+	return livekit.NewRoomServiceJSONClient(s.URL, &http.Client{})
+}
+
+// Connect joins room as identity and waits until the client is connected
+func (s *Server) Connect(room, identity string, opts *testclient.Options) (*testclient.RTCClient, error) {
+	token, err := s.JoinToken(room, identity)
+	if err != nil {
+		return nil, err
+	}
+	ws, err := testclient.NewWebSocketConn(s.WSURL, token, opts)
+	if err != nil {
+		return nil, err
+	}
+	c, err := testclient.NewRTCClient(ws, opts)
+	if err != nil {
+		return nil, err
+	}
+	go c.Run()
+
+	if err = c.WaitUntilConnected(); err != nil {
+		c.Stop()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (s *Server) waitUntilReady(errChan chan error) error {
+	timeout := time.After(startTimeout)
+	for {
+		select {
+		case err := <-errChan:
+			if err == nil {
+				err = errors.New("server stopped while starting")
+			}
+			return err
+		case <-timeout:
+			return ErrStartTimeout
+		case <-time.After(10 * time.Millisecond):
+			if !s.IsRunning() {
+				continue
+			}
+			res, err := http.Get(s.URL)
+			if err != nil {
+				continue
+			}
+			_ = res.Body.Close()
+			if res.StatusCode == http.StatusOK {
+				return nil
+			}
+		}
+	}
+}
+
+// ContextWithToken returns a context that authorizes Twirp client calls with token
+func ContextWithToken(ctx context.Context, token string) (context.Context, error) {
+	header := make(http.Header)
+	testclient.SetAuthorizationToken(header, token)
+	return twirp.WithHTTPRequestHeaders(ctx, header)
+}
+
+// ports are released before the server binds them, another process could grab one in between
+func freePort(network string) (int, error) {
+	switch network {
+	case "udp":
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			return 0, err
+		}
+		defer conn.Close()
+		return conn.LocalAddr().(*net.UDPAddr).Port, nil
+	default:
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			return 0, err
+		}
+		defer ln.Close()
+		return ln.Addr().(*net.TCPAddr).Port, nil
+	}
+}
@@ -0,0 +1,52 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testsupport
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/protocol/livekit"
+
+	testclient "github.com/livekit/livekit-server/test/client"
+)
+
+func TestServer(t *testing.T) {
+	s, err := Start(nil)
+	require.NoError(t, err)
+	defer s.Stop()
+
+	opts := &testclient.Options{AutoSubscribe: true}
+	pub, err := s.Connect("room", "publisher", opts)
+	require.NoError(t, err)
+	defer pub.Stop()
+	sub, err := s.Connect("room", "subscriber", opts)
+	require.NoError(t, err)
+	defer sub.Stop()
+
+	writer, err := PublishSyntheticAudio(pub, "audio")
+	require.NoError(t, err)
+	defer writer.Stop()
+	require.NoError(t, WaitForSubscribedTracks(sub, 1, 10*time.Second))
+
+	ctx, err := s.RoomAdminContext(context.Background(), "room")
+	require.NoError(t, err)
+	res, err := s.RoomClient().ListParticipants(ctx, &livekit.ListParticipantsRequest{Room: "room"})
+	require.NoError(t, err)
+	require.Len(t, res.Participants, 2)
+}
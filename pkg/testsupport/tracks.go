@@ -0,0 +1,62 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testsupport
+
+import (
+	"errors"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+
+	"github.com/livekit/protocol/utils"
+
+	testclient "github.com/livekit/livekit-server/test/client"
+)
+
+var ErrTracksTimeout = errors.New("expected tracks were not subscribed in time")
+
+// PublishSyntheticTrack publishes a track of generated samples with the given codec mime type.
+// Stop the returned writer to stop sending media.
+func PublishSyntheticTrack(c *testclient.RTCClient, mime string, name string) (*testclient.TrackWriter, error) {
+	return c.AddStaticTrack(mime, utils.NewGuid("TR_"), name)
+}
+
+func PublishSyntheticAudio(c *testclient.RTCClient, name string) (*testclient.TrackWriter, error) {
+	return PublishSyntheticTrack(c, webrtc.MimeTypeOpus, name)
+}
+
+func PublishSyntheticVideo(c *testclient.RTCClient, name string) (*testclient.TrackWriter, error) {
+	return PublishSyntheticTrack(c, webrtc.MimeTypeVP8, name)
+}
+
+// WaitForSubscribedTracks waits until c is subscribed to at least count tracks
+func WaitForSubscribedTracks(c *testclient.RTCClient, count int, timeout time.Duration) error {
+	deadline := time.After(timeout)
+	for {
+		total := 0
+		for _, tracks := range c.SubscribedTracks() {
+			total += len(tracks)
+		}
+		if total >= count {
+			return nil
+		}
+
+		select {
+		case <-deadline:
+			return ErrTracksTimeout
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
@@ -0,0 +1,273 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transcode
+
+import (
+	"encoding/binary"
+	"errors"
+	"strings"
+
+	"github.com/pion/rtp"
+	"github.com/pion/rtp/codecs"
+	"github.com/pion/rtp/pkg/frame"
+)
+
+const (
+	h265AggregationPacket   = 48
+	h265FragmentationUnit   = 49
+	av1OBUExtensionFlag     = 0x04
+	av1OBUHasSizeField      = 0x02
+	h265PayloadHeaderSize   = 2
+	h265FragmentHeaderSize  = 1
+	h265AggregationSizeSize = 2
+)
+
+var (
+	errShortPayload = errors.New("payload is too short")
+
+	annexBStartCode = []byte{0x00, 0x00, 0x00, 0x01}
+)
+
+type depacketizer interface {
+	// appendPayload appends the media of an RTP payload to data
+	appendPayload(data []byte, payload []byte) ([]byte, error)
+	reset()
+}
+
+// FrameBuilder assembles the frames of a video stream from its RTP packets. A frame is complete at the packet with
+// the marker bit, or once a packet of the next frame arrives as the marker bit only ends the highest spatial layer
+// of SVC streams. Packets have to be pushed in order, the builder is reset after losses
+type FrameBuilder struct {
+	depacketizer depacketizer
+	frame        Frame
+	pending      bool
+}
+
+func NewFrameBuilder(mime string) (*FrameBuilder, error) {
+	var d depacketizer
+	switch strings.ToLower(mime) {
+	case "video/vp8":
+		d = &vp8Depacketizer{}
+	case "video/vp9":
+		d = &vp9Depacketizer{}
+	case "video/h264":
+		d = &h264Depacketizer{}
+	case "video/h265":
+		d = &h265Depacketizer{}
+	case "video/av1":
+		d = &av1Depacketizer{}
+	default:
+		return nil, ErrUnsupportedCodec
+	}
+	return &FrameBuilder{depacketizer: d}, nil
+}
+
+// Push adds a packet with its extended timestamp, keyFrame marks packets of key frames. It returns the frames
+// the packet completed
+func (b *FrameBuilder) Push(pkt *rtp.Packet, extTimestamp uint64, keyFrame bool) ([]Frame, error) {
+	var frames []Frame
+	if b.pending && extTimestamp != b.frame.Timestamp {
+		frames = b.flush(frames)
+	}
+
+	data, err := b.depacketizer.appendPayload(b.frame.Data, pkt.Payload)
+	if err != nil {
+		b.Reset()
+		return frames, err
+	}
+	b.frame.Data = data
+	b.frame.Timestamp = extTimestamp
+	b.frame.KeyFrame = b.frame.KeyFrame || keyFrame
+	b.pending = true
+
+	if pkt.Marker {
+		frames = b.flush(frames)
+	}
+	return frames, nil
+}
+
+// Reset drops the frame being assembled
+func (b *FrameBuilder) Reset() {
+	b.frame = Frame{}
+	b.pending = false
+	b.depacketizer.reset()
+}
+
+func (b *FrameBuilder) flush(frames []Frame) []Frame {
+	if len(b.frame.Data) != 0 {
+		frames = append(frames, b.frame)
+	}
+	b.frame = Frame{}
+	b.pending = false
+	return frames
+}
+
+// ------------------------------------------------
+
+type vp8Depacketizer struct{}
+
+func (d *vp8Depacketizer) appendPayload(data []byte, payload []byte) ([]byte, error) {
+	var pkt codecs.VP8Packet
+	media, err := pkt.Unmarshal(payload)
+	if err != nil {
+		return data, err
+	}
+	return append(data, media...), nil
+}
+
+func (d *vp8Depacketizer) reset() {}
+
+// ------------------------------------------------
+
+type vp9Depacketizer struct{}
+
+func (d *vp9Depacketizer) appendPayload(data []byte, payload []byte) ([]byte, error) {
+	var pkt codecs.VP9Packet
+	media, err := pkt.Unmarshal(payload)
+	if err != nil {
+		return data, err
+	}
+	return append(data, media...), nil
+}
+
+func (d *vp9Depacketizer) reset() {}
+
+// ------------------------------------------------
+
+type h264Depacketizer struct {
+	// keeps fragmentation units until the last one
+	pkt codecs.H264Packet
+}
+
+func (d *h264Depacketizer) appendPayload(data []byte, payload []byte) ([]byte, error) {
+	nalus, err := d.pkt.Unmarshal(payload)
+	if err != nil {
+		return data, err
+	}
+	return append(data, nalus...), nil
+}
+
+func (d *h264Depacketizer) reset() {
+	d.pkt = codecs.H264Packet{}
+}
+
+// ------------------------------------------------
+
+// h265Depacketizer depacketizes RFC 7798 payloads without decoding order numbers, as sent by WebRTC publishers
+type h265Depacketizer struct {
+	fragment   []byte
+	inFragment bool
+}
+
+func (d *h265Depacketizer) appendPayload(data []byte, payload []byte) ([]byte, error) {
+	if len(payload) <= h265PayloadHeaderSize {
+		return data, errShortPayload
+	}
+
+	switch (payload[0] >> 1) & 0x3f {
+	case h265AggregationPacket:
+		for i := h265PayloadHeaderSize; i < len(payload); {
+			if i+h265AggregationSizeSize > len(payload) {
+				return data, errShortPayload
+			}
+			size := int(binary.BigEndian.Uint16(payload[i:]))
+			i += h265AggregationSizeSize
+			if i+size > len(payload) {
+				return data, errShortPayload
+			}
+			data = append(data, annexBStartCode...)
+			data = append(data, payload[i:i+size]...)
+			i += size
+		}
+
+	case h265FragmentationUnit:
+		if len(payload) <= h265PayloadHeaderSize+h265FragmentHeaderSize {
+			return data, errShortPayload
+		}
+		fuHeader := payload[h265PayloadHeaderSize]
+		if fuHeader&0x80 != 0 {
+			// rebuild the header of the unit from the payload header and the type of the fragment
+			d.fragment = append(d.fragment[:0], payload[0]&0x81|(fuHeader&0x3f)<<1, payload[1])
+			d.inFragment = true
+		}
+		if !d.inFragment {
+			// the start of the unit was lost
+			return data, nil
+		}
+		d.fragment = append(d.fragment, payload[h265PayloadHeaderSize+h265FragmentHeaderSize:]...)
+		if fuHeader&0x40 != 0 {
+			data = append(data, annexBStartCode...)
+			data = append(data, d.fragment...)
+			d.inFragment = false
+		}
+
+	default:
+		data = append(data, annexBStartCode...)
+		data = append(data, payload...)
+	}
+	return data, nil
+}
+
+func (d *h265Depacketizer) reset() {
+	d.inFragment = false
+}
+
+// ------------------------------------------------
+
+type av1Depacketizer struct {
+	obus frame.AV1
+}
+
+func (d *av1Depacketizer) appendPayload(data []byte, payload []byte) ([]byte, error) {
+	var pkt codecs.AV1Packet
+	if _, err := pkt.Unmarshal(payload); err != nil {
+		return data, err
+	}
+	obus, err := d.obus.ReadFrames(&pkt)
+	if err != nil {
+		return data, err
+	}
+	for _, obu := range obus {
+		data = appendOBU(data, obu)
+	}
+	return data, nil
+}
+
+func (d *av1Depacketizer) reset() {
+	d.obus = frame.AV1{}
+}
+
+// appendOBU appends an OBU in low overhead bitstream format, RTP payloads usually leave out the size field
+func appendOBU(data []byte, obu []byte) []byte {
+	if len(obu) == 0 {
+		return data
+	}
+	if obu[0]&av1OBUHasSizeField != 0 {
+		return append(data, obu...)
+	}
+
+	headerSize := 1
+	if obu[0]&av1OBUExtensionFlag != 0 {
+		headerSize = 2
+	}
+	if len(obu) < headerSize {
+		return data
+	}
+	data = append(data, obu[0]|av1OBUHasSizeField)
+	data = append(data, obu[1:headerSize]...)
+	// leb128 is the unsigned varint encoding
+	data = binary.AppendUvarint(data, uint64(len(obu)-headerSize))
+	return append(data, obu[headerSize:]...)
+}
@@ -0,0 +1,92 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transcode
+
+import (
+	"testing"
+
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFrameBuilder(t *testing.T) {
+	t.Run("vp8 frames end at the marker", func(t *testing.T) {
+		b, err := NewFrameBuilder("video/VP8")
+		require.NoError(t, err)
+
+		// payload descriptors without extensions, S bit on the first packet of the frame
+		frames, err := b.Push(&rtp.Packet{Payload: []byte{0x10, 0x01, 0x02}}, 3000, true)
+		require.NoError(t, err)
+		require.Empty(t, frames)
+
+		frames, err = b.Push(&rtp.Packet{Header: rtp.Header{Marker: true}, Payload: []byte{0x00, 0x03}}, 3000, false)
+		require.NoError(t, err)
+		require.Equal(t, []Frame{{Data: []byte{0x01, 0x02, 0x03}, Timestamp: 3000, KeyFrame: true}}, frames)
+	})
+
+	t.Run("frames end at the next timestamp", func(t *testing.T) {
+		b, err := NewFrameBuilder("video/vp8")
+		require.NoError(t, err)
+
+		frames, err := b.Push(&rtp.Packet{Payload: []byte{0x10, 0x01}}, 3000, false)
+		require.NoError(t, err)
+		require.Empty(t, frames)
+
+		frames, err = b.Push(&rtp.Packet{Payload: []byte{0x10, 0x02}}, 6000, false)
+		require.NoError(t, err)
+		require.Equal(t, []Frame{{Data: []byte{0x01}, Timestamp: 3000}}, frames)
+
+		b.Reset()
+		frames, err = b.Push(&rtp.Packet{Header: rtp.Header{Marker: true}, Payload: []byte{0x10, 0x03}}, 9000, false)
+		require.NoError(t, err)
+		require.Equal(t, []Frame{{Data: []byte{0x03}, Timestamp: 9000}}, frames)
+	})
+
+	t.Run("h265 aggregation and fragmentation units", func(t *testing.T) {
+		b, err := NewFrameBuilder("video/h265")
+		require.NoError(t, err)
+
+		// VPS and SPS aggregated
+		_, err = b.Push(&rtp.Packet{Payload: []byte{0x60, 0x01, 0x00, 0x02, 0x40, 0x01, 0x00, 0x02, 0x42, 0x01}}, 0, true)
+		require.NoError(t, err)
+		// IDR_W_RADL (19) in two fragments
+		_, err = b.Push(&rtp.Packet{Payload: []byte{0x62, 0x01, 0x80 | 19, 0xaa}}, 0, true)
+		require.NoError(t, err)
+		frames, err := b.Push(&rtp.Packet{Header: rtp.Header{Marker: true}, Payload: []byte{0x62, 0x01, 0x40 | 19, 0xbb}}, 0, true)
+		require.NoError(t, err)
+		require.Len(t, frames, 1)
+		require.Equal(t, []byte{
+			0x00, 0x00, 0x00, 0x01, 0x40, 0x01,
+			0x00, 0x00, 0x00, 0x01, 0x42, 0x01,
+			0x00, 0x00, 0x00, 0x01, 0x26, 0x01, 0xaa, 0xbb,
+		}, frames[0].Data)
+	})
+
+	t.Run("av1 obus get their size", func(t *testing.T) {
+		b, err := NewFrameBuilder("video/av1")
+		require.NoError(t, err)
+
+		// two OBU elements, the sequence header with a length field and a frame OBU filling the rest
+		payload := []byte{0x20 | 0x08, 0x02, 0x08, 0xaa, 0x30, 0xbb, 0xcc}
+		frames, err := b.Push(&rtp.Packet{Header: rtp.Header{Marker: true}, Payload: payload}, 0, true)
+		require.NoError(t, err)
+		require.Equal(t, []Frame{{Data: []byte{0x0a, 0x01, 0xaa, 0x32, 0x02, 0xbb, 0xcc}, KeyFrame: true}}, frames)
+	})
+
+	t.Run("unsupported codec", func(t *testing.T) {
+		_, err := NewFrameBuilder("video/x-unknown")
+		require.ErrorIs(t, err, ErrUnsupportedCodec)
+	})
+}
@@ -0,0 +1,47 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transcode
+
+import "errors"
+
+var (
+	ErrUnavailable      = errors.New("transcoding is not available, the server needs to be built with cgo and the transcode tag")
+	ErrUnsupportedCodec = errors.New("codec cannot be transcoded")
+)
+
+// Frame is an encoded video frame. H.264 and H.265 frames are in Annex B byte stream format, AV1 frames in
+// low overhead bitstream format
+type Frame struct {
+	Data []byte
+	// extended RTP timestamp, encoded frames keep the timestamp of the frame they were decoded from
+	Timestamp uint64
+	KeyFrame  bool
+}
+
+type Params struct {
+	// mime types of the decoded and of the encoded frames
+	SourceMime string
+	TargetMime string
+	// bitrate (bps) the encoder aims for
+	Bitrate int
+}
+
+// Transcoder decodes frames of one codec and encodes them with another. Only H.264 can be encoded
+type Transcoder interface {
+	// Transcode decodes a frame and returns the frames the encoder produced, the next one encoded as a key frame
+	// when forceKeyFrame is set
+	Transcode(frame Frame, forceKeyFrame bool) ([]Frame, error)
+	Close()
+}
@@ -0,0 +1,247 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build transcode && cgo
+// +build transcode,cgo
+
+package transcode
+
+/*
+#cgo pkg-config: libavcodec libavutil
+#include <errno.h>
+#include <libavcodec/avcodec.h>
+#include <libavutil/opt.h>
+
+static AVCodecContext *lk_open_decoder(enum AVCodecID id, int *err) {
+	const AVCodec *codec = avcodec_find_decoder(id);
+	if (!codec) {
+		*err = AVERROR_DECODER_NOT_FOUND;
+		return NULL;
+	}
+	AVCodecContext *ctx = avcodec_alloc_context3(codec);
+	if (!ctx) {
+		*err = AVERROR(ENOMEM);
+		return NULL;
+	}
+	*err = avcodec_open2(ctx, codec, NULL);
+	if (*err < 0) {
+		avcodec_free_context(&ctx);
+		return NULL;
+	}
+	return ctx;
+}
+
+// frames are encoded as they come, without b-frames or lookahead, and parameter sets are repeated with every
+// key frame so that subscribers can start decoding at any of them
+static AVCodecContext *lk_open_h264_encoder(int width, int height, int64_t bitrate, int *err) {
+	const AVCodec *codec = avcodec_find_encoder_by_name("libx264");
+	if (!codec) {
+		*err = AVERROR_ENCODER_NOT_FOUND;
+		return NULL;
+	}
+	AVCodecContext *ctx = avcodec_alloc_context3(codec);
+	if (!ctx) {
+		*err = AVERROR(ENOMEM);
+		return NULL;
+	}
+	ctx->width = width;
+	ctx->height = height;
+	ctx->pix_fmt = AV_PIX_FMT_YUV420P;
+	ctx->time_base = (AVRational){1, 90000};
+	ctx->bit_rate = bitrate;
+	ctx->max_b_frames = 0;
+	// key frames are requested by subscribers, periodic ones only bound the recovery from unreported losses
+	ctx->gop_size = 600;
+	av_opt_set(ctx->priv_data, "preset", "veryfast", 0);
+	av_opt_set(ctx->priv_data, "tune", "zerolatency", 0);
+	av_opt_set(ctx->priv_data, "profile", "baseline", 0);
+	av_opt_set(ctx->priv_data, "forced-idr", "1", 0);
+	*err = avcodec_open2(ctx, codec, NULL);
+	if (*err < 0) {
+		avcodec_free_context(&ctx);
+		return NULL;
+	}
+	return ctx;
+}
+
+static void lk_free_context(AVCodecContext *ctx) {
+	avcodec_free_context(&ctx);
+}
+
+static int lk_is_again(int ret) {
+	return ret == AVERROR(EAGAIN) || ret == AVERROR_EOF;
+}
+
+static int lk_is_yuv420p(const AVFrame *frame) {
+	return frame->format == AV_PIX_FMT_YUV420P;
+}
+
+static void lk_set_key_frame(AVFrame *frame, int key) {
+	frame->pict_type = key ? AV_PICTURE_TYPE_I : AV_PICTURE_TYPE_NONE;
+}
+
+static int lk_is_key_packet(const AVPacket *pkt) {
+	return (pkt->flags & AV_PKT_FLAG_KEY) != 0;
+}
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unsafe"
+)
+
+const Supported = true
+
+var errPixelFormat = errors.New("decoded frames are not yuv420p")
+
+var decoderIDs = map[string]C.enum_AVCodecID{
+	"video/vp8":  C.AV_CODEC_ID_VP8,
+	"video/vp9":  C.AV_CODEC_ID_VP9,
+	"video/h264": C.AV_CODEC_ID_H264,
+	"video/h265": C.AV_CODEC_ID_HEVC,
+	"video/av1":  C.AV_CODEC_ID_AV1,
+}
+
+type libavTranscoder struct {
+	params  Params
+	decoder *C.AVCodecContext
+	encoder *C.AVCodecContext
+	packet  *C.AVPacket
+	frame   *C.AVFrame
+}
+
+func NewTranscoder(params Params) (Transcoder, error) {
+	id, ok := decoderIDs[strings.ToLower(params.SourceMime)]
+	if !ok || !strings.EqualFold(params.TargetMime, "video/h264") {
+		return nil, ErrUnsupportedCodec
+	}
+
+	var ret C.int
+	decoder := C.lk_open_decoder(id, &ret)
+	if decoder == nil {
+		return nil, avError("could not open decoder", ret)
+	}
+	return &libavTranscoder{
+		params:  params,
+		decoder: decoder,
+		packet:  C.av_packet_alloc(),
+		frame:   C.av_frame_alloc(),
+	}, nil
+}
+
+func (t *libavTranscoder) Transcode(frame Frame, forceKeyFrame bool) ([]Frame, error) {
+	if len(frame.Data) == 0 {
+		return nil, nil
+	}
+
+	// libavcodec reads past the end of packets, they are allocated with padding
+	if ret := C.av_new_packet(t.packet, C.int(len(frame.Data))); ret < 0 {
+		return nil, avError("could not allocate packet", ret)
+	}
+	copy(unsafe.Slice((*byte)(unsafe.Pointer(t.packet.data)), len(frame.Data)), frame.Data)
+	t.packet.pts = C.int64_t(frame.Timestamp)
+	ret := C.avcodec_send_packet(t.decoder, t.packet)
+	C.av_packet_unref(t.packet)
+	if ret < 0 {
+		return nil, avError("could not decode frame", ret)
+	}
+
+	var frames []Frame
+	for {
+		ret = C.avcodec_receive_frame(t.decoder, t.frame)
+		if C.lk_is_again(ret) != 0 {
+			return frames, nil
+		}
+		if ret < 0 {
+			return frames, avError("could not decode frame", ret)
+		}
+
+		encoded, err := t.encode(forceKeyFrame)
+		C.av_frame_unref(t.frame)
+		frames = append(frames, encoded...)
+		if err != nil {
+			return frames, err
+		}
+		forceKeyFrame = false
+	}
+}
+
+func (t *libavTranscoder) encode(forceKeyFrame bool) ([]Frame, error) {
+	if C.lk_is_yuv420p(t.frame) == 0 {
+		return nil, errPixelFormat
+	}
+	if t.encoder == nil || t.encoder.width != t.frame.width || t.encoder.height != t.frame.height {
+		// first frame or the publisher changed resolution, the new encoder starts with a key frame
+		if t.encoder != nil {
+			C.lk_free_context(t.encoder)
+		}
+		var ret C.int
+		t.encoder = C.lk_open_h264_encoder(t.frame.width, t.frame.height, C.int64_t(t.params.Bitrate), &ret)
+		if t.encoder == nil {
+			return nil, avError("could not open encoder", ret)
+		}
+	}
+
+	key := C.int(0)
+	if forceKeyFrame {
+		key = 1
+	}
+	C.lk_set_key_frame(t.frame, key)
+	if ret := C.avcodec_send_frame(t.encoder, t.frame); ret < 0 {
+		return nil, avError("could not encode frame", ret)
+	}
+
+	var frames []Frame
+	for {
+		ret := C.avcodec_receive_packet(t.encoder, t.packet)
+		if C.lk_is_again(ret) != 0 {
+			return frames, nil
+		}
+		if ret < 0 {
+			return frames, avError("could not encode frame", ret)
+		}
+		frames = append(frames, Frame{
+			Data:      C.GoBytes(unsafe.Pointer(t.packet.data), t.packet.size),
+			Timestamp: uint64(t.packet.pts),
+			KeyFrame:  C.lk_is_key_packet(t.packet) != 0,
+		})
+		C.av_packet_unref(t.packet)
+	}
+}
+
+func (t *libavTranscoder) Close() {
+	if t.encoder != nil {
+		C.lk_free_context(t.encoder)
+		t.encoder = nil
+	}
+	if t.decoder != nil {
+		C.lk_free_context(t.decoder)
+		t.decoder = nil
+	}
+	if t.packet != nil {
+		C.av_packet_free(&t.packet)
+	}
+	if t.frame != nil {
+		C.av_frame_free(&t.frame)
+	}
+}
+
+func avError(msg string, ret C.int) error {
+	buf := make([]C.char, 128)
+	C.av_strerror(ret, &buf[0], C.size_t(len(buf)))
+	return fmt.Errorf("%s: %s", msg, C.GoString(&buf[0]))
+}
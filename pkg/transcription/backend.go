@@ -0,0 +1,75 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transcription
+
+import (
+	"context"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/protocol/livekit"
+)
+
+const (
+	BackendWebSocket = "websocket"
+	BackendGRPC      = "grpc"
+
+	FormatOpus = "opus"
+	FormatPCM  = "pcm"
+)
+
+// StreamInfo describes the audio of a stream, it is the first message sent to the backend
+type StreamInfo struct {
+	Room                livekit.RoomName            `json:"room"`
+	ParticipantIdentity livekit.ParticipantIdentity `json:"participant_identity"`
+	TrackID             livekit.TrackID             `json:"track_id"`
+	// opus frames as published, or little endian 16-bit mono PCM
+	Format         string `json:"format"`
+	SampleRate     int    `json:"sample_rate"`
+	Channels       int    `json:"channels"`
+	Language       string `json:"language,omitempty"`
+	InterimResults bool   `json:"interim_results"`
+}
+
+// Result is the transcription of a part of a stream returned by a backend
+type Result struct {
+	Text string `json:"text"`
+	// final results are not revised anymore
+	Final    bool   `json:"final"`
+	Language string `json:"language,omitempty"`
+	// offsets from the start of the stream
+	StartMs int64 `json:"start_ms,omitempty"`
+	EndMs   int64 `json:"end_ms,omitempty"`
+}
+
+// Backend opens one transcription stream per audio track
+type Backend interface {
+	Open(ctx context.Context, info StreamInfo) (Stream, error)
+	Close()
+}
+
+type Stream interface {
+	// WriteAudio sends a frame of audio in the format of the stream
+	WriteAudio(frame []byte) error
+	// Recv blocks until the backend returns a result
+	Recv() (*Result, error)
+	Close() error
+}
+
+func newBackend(conf config.TranscriptionConfig) (Backend, error) {
+	if conf.Backend == BackendGRPC {
+		return newGRPCBackend(conf)
+	}
+	return newWebSocketBackend(conf), nil
+}
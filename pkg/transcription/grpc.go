@@ -0,0 +1,117 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transcription
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/livekit/livekit-server/pkg/config"
+)
+
+// there is no generated service, messages are exchanged with the json codec
+const grpcTranscribeMethod = "/livekit.transcription.Transcriber/Transcribe"
+
+var grpcTranscribeStream = &grpc.StreamDesc{
+	StreamName:    "Transcribe",
+	ServerStreams: true,
+	ClientStreams: true,
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+func (jsonCodec) Name() string {
+	return "json"
+}
+
+// grpcRequest is sent on the stream, the first one carries the stream info and the following ones audio
+type grpcRequest struct {
+	Start *StreamInfo `json:"start,omitempty"`
+	Audio []byte      `json:"audio,omitempty"`
+}
+
+type grpcBackend struct {
+	conn  *grpc.ClientConn
+	token string
+}
+
+func newGRPCBackend(conf config.TranscriptionConfig) (*grpcBackend, error) {
+	creds := credentials.NewTLS(&tls.Config{MinVersion: tls.VersionTLS12})
+	if conf.Insecure {
+		creds = insecure.NewCredentials()
+	}
+	// connects lazily
+	conn, err := grpc.Dial(conf.URL, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, err
+	}
+	return &grpcBackend{
+		conn:  conn,
+		token: conf.Token,
+	}, nil
+}
+
+func (b *grpcBackend) Open(ctx context.Context, info StreamInfo) (Stream, error) {
+	if b.token != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+b.token)
+	}
+	cs, err := b.conn.NewStream(ctx, grpcTranscribeStream, grpcTranscribeMethod, grpc.ForceCodec(jsonCodec{}))
+	if err != nil {
+		return nil, err
+	}
+	if err = cs.SendMsg(&grpcRequest{Start: &info}); err != nil {
+		return nil, err
+	}
+	return &grpcStream{cs: cs}, nil
+}
+
+func (b *grpcBackend) Close() {
+	_ = b.conn.Close()
+}
+
+// grpcStream ends with the context it was opened with
+type grpcStream struct {
+	cs grpc.ClientStream
+}
+
+func (s *grpcStream) WriteAudio(frame []byte) error {
+	return s.cs.SendMsg(&grpcRequest{Audio: frame})
+}
+
+func (s *grpcStream) Recv() (*Result, error) {
+	res := &Result{}
+	if err := s.cs.RecvMsg(res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (s *grpcStream) Close() error {
+	return s.cs.CloseSend()
+}
@@ -0,0 +1,134 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transcription
+
+import (
+	"errors"
+	"sort"
+	"sync"
+
+	"github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/logger"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/opus"
+)
+
+var (
+	ErrNotTranscribing     = errors.New("room is not transcribed")
+	ErrAlreadyTranscribing = errors.New("room is already transcribed")
+)
+
+// Manager transcribes rooms hosted on this node
+type Manager struct {
+	conf    config.TranscriptionConfig
+	backend Backend
+
+	lock     sync.RWMutex
+	sessions map[livekit.RoomName]*session
+}
+
+// NewManager returns nil when transcription is disabled
+func NewManager(conf *config.Config) *Manager {
+	if !conf.Transcription.Enabled {
+		return nil
+	}
+	if conf.Transcription.Format == FormatPCM && !opus.Supported {
+		logger.Errorw("could not transcribe pcm audio, transcription disabled", opus.ErrUnavailable)
+		return nil
+	}
+	backend, err := newBackend(conf.Transcription)
+	if err != nil {
+		logger.Errorw("could not create transcription backend, transcription disabled", err)
+		return nil
+	}
+	return &Manager{
+		conf:     conf.Transcription,
+		backend:  backend,
+		sessions: make(map[livekit.RoomName]*session),
+	}
+}
+
+// Start transcribes the microphone tracks of all participants of the room, including ones joining later
+func (m *Manager) Start(room Room) (*Info, error) {
+	m.lock.Lock()
+	if _, ok := m.sessions[room.Name()]; ok {
+		m.lock.Unlock()
+		return nil, ErrAlreadyTranscribing
+	}
+	s := newSession(m.conf, m.backend, room)
+	s.onStopped = m.onSessionStopped
+	m.sessions[room.Name()] = s
+	m.lock.Unlock()
+
+	s.start()
+	return s.info(), nil
+}
+
+func (m *Manager) Stop(roomName livekit.RoomName) (*Info, error) {
+	s := m.getSession(roomName)
+	if s == nil {
+		return nil, ErrNotTranscribing
+	}
+	s.stop()
+	return s.info(), nil
+}
+
+func (m *Manager) List() []*Info {
+	m.lock.RLock()
+	infos := make([]*Info, 0, len(m.sessions))
+	for _, s := range m.sessions {
+		infos = append(infos, s.info())
+	}
+	m.lock.RUnlock()
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].StartedAt > infos[j].StartedAt
+	})
+	return infos
+}
+
+// Close stops transcribing all rooms
+func (m *Manager) Close() {
+	if m == nil {
+		return
+	}
+
+	m.lock.RLock()
+	sessions := make([]*session, 0, len(m.sessions))
+	for _, s := range m.sessions {
+		sessions = append(sessions, s)
+	}
+	m.lock.RUnlock()
+
+	for _, s := range sessions {
+		s.stop()
+	}
+	m.backend.Close()
+}
+
+func (m *Manager) getSession(roomName livekit.RoomName) *session {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	return m.sessions[roomName]
+}
+
+func (m *Manager) onSessionStopped(s *session) {
+	m.lock.Lock()
+	if m.sessions[s.room.Name()] == s {
+		delete(m.sessions, s.room.Name())
+	}
+	m.lock.Unlock()
+}
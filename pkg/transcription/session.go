@@ -0,0 +1,448 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transcription
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+
+	"github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/logger"
+	"github.com/livekit/protocol/utils"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/opus"
+	"github.com/livekit/livekit-server/pkg/rtc/types"
+	"github.com/livekit/livekit-server/pkg/sfu"
+)
+
+const (
+	TranscriptionPrefix = "TR_"
+
+	syncInterval = time.Second
+	// a track whose stream failed is transcribed again after
+	retryDelay = 10 * time.Second
+	// frames buffered per track while the backend is slow, two seconds of 20ms frames
+	frameQueueSize = 100
+	// longest opus frame
+	maxFrameDurationMs = 120
+)
+
+type Room interface {
+	Name() livekit.RoomName
+	ID() livekit.RoomID
+	IsClosed() bool
+	GetParticipants() []types.LocalParticipant
+	SendDataPacket(up *livekit.UserPacket, kind livekit.DataPacket_Kind)
+}
+
+// Caption is the payload of the data packets sent to the room. Packets are attributed to the speaker, interim
+// captions are sent lossy as they are superseded anyway
+type Caption struct {
+	ParticipantIdentity livekit.ParticipantIdentity `json:"participant_identity"`
+	TrackID             livekit.TrackID             `json:"track_id"`
+	Text                string                      `json:"text"`
+	Final               bool                        `json:"final"`
+	Language            string                      `json:"language,omitempty"`
+	StartMs             int64                       `json:"start_ms,omitempty"`
+	EndMs               int64                       `json:"end_ms,omitempty"`
+}
+
+// Info describes the transcription of a room
+type Info struct {
+	ID       string           `json:"id"`
+	RoomName livekit.RoomName `json:"room_name"`
+	RoomID   livekit.RoomID   `json:"room_id"`
+	// unix milliseconds
+	StartedAt int64        `json:"started_at"`
+	Tracks    []*TrackInfo `json:"tracks"`
+}
+
+type TrackInfo struct {
+	TrackID             livekit.TrackID             `json:"track_id"`
+	ParticipantIdentity livekit.ParticipantIdentity `json:"participant_identity"`
+	// final captions sent
+	Captions int64 `json:"captions"`
+	// last error of the stream, it is retried while the track is published
+	Error string `json:"error,omitempty"`
+}
+
+type session struct {
+	id        string
+	conf      config.TranscriptionConfig
+	backend   Backend
+	room      Room
+	logger    logger.Logger
+	startedAt time.Time
+
+	lock    sync.Mutex
+	stopped bool
+	tracks  map[livekit.TrackID]*trackStream
+
+	doneChan  chan struct{}
+	stopOnce  sync.Once
+	onStopped func(s *session)
+}
+
+func newSession(conf config.TranscriptionConfig, backend Backend, room Room) *session {
+	id := utils.NewGuid(TranscriptionPrefix)
+	return &session{
+		id:        id,
+		conf:      conf,
+		backend:   backend,
+		room:      room,
+		logger:    logger.GetLogger().WithValues("room", room.Name(), "roomID", room.ID(), "transcriptionID", id),
+		startedAt: time.Now(),
+		tracks:    make(map[livekit.TrackID]*trackStream),
+		doneChan:  make(chan struct{}),
+	}
+}
+
+func (s *session) start() {
+	s.sync()
+	go s.worker()
+}
+
+func (s *session) worker() {
+	ticker := time.NewTicker(syncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.doneChan:
+			return
+		case <-ticker.C:
+			if s.room.IsClosed() {
+				s.stop()
+				return
+			}
+			s.sync()
+		}
+	}
+}
+
+// sync taps newly published microphone tracks and retries failed streams
+func (s *session) sync() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.stopped {
+		return
+	}
+
+	published := make(map[livekit.TrackID]bool)
+	for _, p := range s.room.GetParticipants() {
+		for _, track := range p.GetPublishedTracks() {
+			if track.Kind() != livekit.TrackType_AUDIO || track.Source() == livekit.TrackSource_SCREEN_SHARE_AUDIO ||
+				!track.IsOpen() || track.IsEncrypted() {
+				continue
+			}
+			published[track.ID()] = true
+			if t, ok := s.tracks[track.ID()]; ok && !t.canRetry() {
+				continue
+			}
+			s.attachLocked(p, track)
+		}
+	}
+
+	for trackID, t := range s.tracks {
+		if !published[trackID] && t.isDone() {
+			delete(s.tracks, trackID)
+		}
+	}
+}
+
+func (s *session) attachLocked(p types.LocalParticipant, track types.MediaTrack) {
+	receivers := track.Receivers()
+	if len(receivers) == 0 {
+		return
+	}
+	receiver := receivers[0]
+	if !strings.EqualFold(receiver.Codec().MimeType, webrtc.MimeTypeOpus) {
+		return
+	}
+
+	t := &trackStream{
+		session:       s,
+		participantID: p.ID(),
+		track:         track,
+		info: StreamInfo{
+			Room:                s.room.Name(),
+			ParticipantIdentity: p.Identity(),
+			TrackID:             track.ID(),
+			Format:              s.conf.Format,
+			SampleRate:          s.conf.SampleRate,
+			Channels:            1,
+			Language:            s.conf.Language,
+			InterimResults:      s.conf.InterimResults,
+		},
+		frames: make(chan []byte, frameQueueSize),
+		logger: s.logger.WithValues("participant", p.Identity(), "trackID", track.ID()),
+	}
+	if s.conf.Format == FormatOpus {
+		t.info.SampleRate = int(receiver.Codec().ClockRate)
+		t.info.Channels = int(receiver.Codec().Channels)
+	}
+	t.ctx, t.cancel = context.WithCancel(context.Background())
+	t.tap = sfu.NewAudioTap(track.ID(), livekit.ParticipantID(s.id), t, t.logger)
+	t.tap.OnClose(t.cancel)
+	if err := receiver.AddDownTrack(t.tap); err != nil {
+		t.logger.Warnw("could not tap track for transcription", err)
+		t.cancel()
+		return
+	}
+
+	s.tracks[track.ID()] = t
+	go t.run()
+	t.logger.Infow("transcribing track")
+}
+
+func (s *session) stop() {
+	stopped := false
+	s.stopOnce.Do(func() {
+		close(s.doneChan)
+		stopped = true
+	})
+	if !stopped {
+		return
+	}
+
+	s.lock.Lock()
+	s.stopped = true
+	tracks := s.tracks
+	s.lock.Unlock()
+
+	for _, t := range tracks {
+		t.cancel()
+	}
+	s.logger.Infow("transcription stopped")
+
+	if s.onStopped != nil {
+		s.onStopped(s)
+	}
+}
+
+func (s *session) info() *Info {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	info := &Info{
+		ID:        s.id,
+		RoomName:  s.room.Name(),
+		RoomID:    s.room.ID(),
+		StartedAt: s.startedAt.UnixMilli(),
+		Tracks:    make([]*TrackInfo, 0, len(s.tracks)),
+	}
+	for _, t := range s.tracks {
+		info.Tracks = append(info.Tracks, t.trackInfo())
+	}
+	return info
+}
+
+func (s *session) sendCaption(t *trackStream, res *Result) {
+	payload, err := json.Marshal(&Caption{
+		ParticipantIdentity: t.info.ParticipantIdentity,
+		TrackID:             t.info.TrackID,
+		Text:                res.Text,
+		Final:               res.Final,
+		Language:            res.Language,
+		StartMs:             res.StartMs,
+		EndMs:               res.EndMs,
+	})
+	if err != nil {
+		t.logger.Errorw("could not marshal caption", err)
+		return
+	}
+
+	kind := livekit.DataPacket_LOSSY
+	if res.Final {
+		kind = livekit.DataPacket_RELIABLE
+	}
+	topic := s.conf.Topic
+	s.room.SendDataPacket(&livekit.UserPacket{
+		ParticipantSid:      string(t.participantID),
+		ParticipantIdentity: string(t.info.ParticipantIdentity),
+		Payload:             payload,
+		Topic:               &topic,
+	}, kind)
+}
+
+// ----------------------------------------------------
+
+// trackStream feeds the audio of a track to a backend stream. Audio is queued as the tap is written to from the
+// forwarding path of the track, frames are dropped while the backend does not keep up
+type trackStream struct {
+	session       *session
+	participantID livekit.ParticipantID
+	track         types.MediaTrack
+	info          StreamInfo
+	tap           *sfu.AudioTap
+	frames        chan []byte
+	logger        logger.Logger
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	lock     sync.Mutex
+	err      error
+	endedAt  time.Time
+	captions int64
+	dropped  int
+}
+
+func (t *trackStream) WriteAudio(payload []byte, _ uint64) error {
+	if t.ctx.Err() != nil {
+		return nil
+	}
+	frame := make([]byte, len(payload))
+	copy(frame, payload)
+	select {
+	case t.frames <- frame:
+	default:
+		t.lock.Lock()
+		t.dropped++
+		t.lock.Unlock()
+	}
+	return nil
+}
+
+func (t *trackStream) run() {
+	err := t.stream()
+	if t.ctx.Err() != nil {
+		// stopped or unpublished
+		err = nil
+	}
+
+	t.cancel()
+	for _, r := range t.track.Receivers() {
+		r.DeleteDownTrack(t.tap.SubscriberID())
+	}
+	t.tap.Close()
+
+	t.lock.Lock()
+	t.err = err
+	t.endedAt = time.Now()
+	dropped := t.dropped
+	t.lock.Unlock()
+
+	if err != nil {
+		t.logger.Warnw("transcription stream failed", err, "droppedFrames", dropped)
+	} else {
+		t.logger.Debugw("transcription stream ended", "droppedFrames", dropped)
+	}
+}
+
+func (t *trackStream) stream() error {
+	var decoder opus.Decoder
+	var pcm []int16
+	var out []byte
+	if t.info.Format == FormatPCM {
+		var err error
+		if decoder, err = opus.NewDecoder(t.info.SampleRate); err != nil {
+			return err
+		}
+		defer decoder.Close()
+		pcm = make([]int16, t.info.SampleRate*maxFrameDurationMs/1000)
+		out = make([]byte, 2*len(pcm))
+	}
+
+	stream, err := t.session.backend.Open(t.ctx, t.info)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = stream.Close()
+	}()
+
+	recvErr := make(chan error, 1)
+	go func() {
+		recvErr <- t.receive(stream)
+	}()
+
+	for {
+		select {
+		case <-t.ctx.Done():
+			return nil
+		case err = <-recvErr:
+			return err
+		case frame := <-t.frames:
+			if decoder != nil {
+				n, err := decoder.Decode(frame, pcm)
+				if err != nil {
+					t.logger.Debugw("could not decode audio", "error", err)
+					continue
+				}
+				for i, sample := range pcm[:n] {
+					binary.LittleEndian.PutUint16(out[2*i:], uint16(sample))
+				}
+				frame = out[:2*n]
+			}
+			if err = stream.WriteAudio(frame); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (t *trackStream) receive(stream Stream) error {
+	for {
+		res, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+		if res.Text == "" || (!res.Final && !t.info.InterimResults) {
+			continue
+		}
+		if res.Final {
+			t.lock.Lock()
+			t.captions++
+			t.lock.Unlock()
+		}
+		t.session.sendCaption(t, res)
+	}
+}
+
+func (t *trackStream) isDone() bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return !t.endedAt.IsZero()
+}
+
+// canRetry is true once a failed stream waited long enough, streams ending with their track are not retried
+func (t *trackStream) canRetry() bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.err != nil && time.Since(t.endedAt) > retryDelay
+}
+
+func (t *trackStream) trackInfo() *TrackInfo {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	info := &TrackInfo{
+		TrackID:             t.info.TrackID,
+		ParticipantIdentity: t.info.ParticipantIdentity,
+		Captions:            t.captions,
+	}
+	if t.err != nil {
+		info.Error = t.err.Error()
+	}
+	return info
+}
@@ -0,0 +1,107 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transcription
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/livekit/livekit-server/pkg/config"
+)
+
+const websocketWriteTimeout = 5 * time.Second
+
+// websocketBackend sends a JSON start message carrying the stream info followed by audio in binary messages,
+// the backend replies with results in JSON text messages
+type websocketBackend struct {
+	url   string
+	token string
+}
+
+type websocketStartMessage struct {
+	Type string `json:"type"`
+	StreamInfo
+}
+
+func newWebSocketBackend(conf config.TranscriptionConfig) *websocketBackend {
+	return &websocketBackend{
+		url:   conf.URL,
+		token: conf.Token,
+	}
+}
+
+func (b *websocketBackend) Open(ctx context.Context, info StreamInfo) (Stream, error) {
+	header := http.Header{}
+	if b.token != "" {
+		header.Set("Authorization", "Bearer "+b.token)
+	}
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, b.url, header)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &websocketStream{conn: conn}
+	if err = s.writeJSON(&websocketStartMessage{Type: "start", StreamInfo: info}); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (b *websocketBackend) Close() {}
+
+type websocketStream struct {
+	conn *websocket.Conn
+
+	// gorilla connections support a single concurrent writer
+	writeLock sync.Mutex
+}
+
+func (s *websocketStream) writeJSON(v interface{}) error {
+	s.writeLock.Lock()
+	defer s.writeLock.Unlock()
+	_ = s.conn.SetWriteDeadline(time.Now().Add(websocketWriteTimeout))
+	return s.conn.WriteJSON(v)
+}
+
+func (s *websocketStream) WriteAudio(frame []byte) error {
+	s.writeLock.Lock()
+	defer s.writeLock.Unlock()
+	_ = s.conn.SetWriteDeadline(time.Now().Add(websocketWriteTimeout))
+	return s.conn.WriteMessage(websocket.BinaryMessage, frame)
+}
+
+func (s *websocketStream) Recv() (*Result, error) {
+	res := &Result{}
+	if err := s.conn.ReadJSON(res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (s *websocketStream) Close() error {
+	s.writeLock.Lock()
+	_ = s.conn.WriteControl(
+		websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""),
+		time.Now().Add(websocketWriteTimeout),
+	)
+	s.writeLock.Unlock()
+	return s.conn.Close()
+}
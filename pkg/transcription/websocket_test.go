@@ -0,0 +1,75 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transcription
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/livekit-server/pkg/config"
+)
+
+func TestWebSocketBackend(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	received := make(chan []byte, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		conn, err := upgrader.Upgrade(w, r, nil)
+		require.NoError(t, err)
+		defer conn.Close()
+
+		start := &websocketStartMessage{}
+		require.NoError(t, conn.ReadJSON(start))
+		require.Equal(t, "start", start.Type)
+		require.Equal(t, FormatOpus, start.Format)
+		require.Equal(t, 48000, start.SampleRate)
+
+		mt, frame, err := conn.ReadMessage()
+		require.NoError(t, err)
+		require.Equal(t, websocket.BinaryMessage, mt)
+		received <- frame
+
+		require.NoError(t, conn.WriteJSON(&Result{Text: "hello", Final: true, StartMs: 20}))
+		_, _, _ = conn.ReadMessage()
+	}))
+	defer srv.Close()
+
+	backend := newWebSocketBackend(config.TranscriptionConfig{
+		URL:   "ws" + strings.TrimPrefix(srv.URL, "http"),
+		Token: "secret",
+	})
+	stream, err := backend.Open(context.Background(), StreamInfo{
+		Room:       "room",
+		Format:     FormatOpus,
+		SampleRate: 48000,
+		Channels:   2,
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, stream.WriteAudio([]byte{1, 2, 3}))
+	require.Equal(t, []byte{1, 2, 3}, <-received)
+
+	res, err := stream.Recv()
+	require.NoError(t, err)
+	require.Equal(t, &Result{Text: "hello", Final: true, StartMs: 20}, res)
+
+	require.NoError(t, stream.Close())
+}
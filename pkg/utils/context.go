@@ -16,9 +16,19 @@
 
 package utils
 
-import "context"
+import (
+	"context"
 
-var attemptKey = struct{}{}
+	"github.com/livekit/protocol/logger"
+)
+
+type attemptKeyType struct{}
+type loggerKeyType struct{}
+
+var (
+	attemptKey = attemptKeyType{}
+	loggerKey  = loggerKeyType{}
+)
 
 func ContextWithAttempt(ctx context.Context, attempt int) context.Context {
 	return context.WithValue(ctx, attemptKey, attempt)
@@ -30,3 +40,14 @@ func GetAttempt(ctx context.Context) int {
 	}
 	return 0
 }
+
+// ContextWithLogger attaches a room/participant scoped logger to the context,
+// so code paths that only receive a context log with the same correlation fields
+func ContextWithLogger(ctx context.Context, l logger.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey, l)
+}
+
+func LoggerFromContext(ctx context.Context) (logger.Logger, bool) {
+	l, ok := ctx.Value(loggerKey).(logger.Logger)
+	return l, ok
+}
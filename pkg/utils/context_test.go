@@ -0,0 +1,68 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/protocol/logger"
+)
+
+func TestContextWithLogger(t *testing.T) {
+	roomLogger := logger.GetLogger().WithValues("room", "r1")
+	participantLogger := roomLogger.WithValues("participant", "p1")
+
+	testCases := []struct {
+		name     string
+		ctx      context.Context
+		expected logger.Logger
+	}{
+		{
+			name: "no logger",
+			ctx:  context.Background(),
+		},
+		{
+			name:     "logger attached",
+			ctx:      ContextWithLogger(context.Background(), roomLogger),
+			expected: roomLogger,
+		},
+		{
+			name:     "innermost logger wins",
+			ctx:      ContextWithLogger(ContextWithLogger(context.Background(), roomLogger), participantLogger),
+			expected: participantLogger,
+		},
+		{
+			name:     "kept through other values",
+			ctx:      ContextWithAttempt(ContextWithLogger(context.Background(), roomLogger), 2),
+			expected: roomLogger,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			l, ok := LoggerFromContext(tc.ctx)
+			require.Equal(t, tc.expected != nil, ok)
+			require.Equal(t, tc.expected, l)
+		})
+	}
+
+	t.Run("attempt kept alongside logger", func(t *testing.T) {
+		ctx := ContextWithLogger(ContextWithAttempt(context.Background(), 3), roomLogger)
+		require.Equal(t, 3, GetAttempt(ctx))
+	})
+}
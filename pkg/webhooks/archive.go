@@ -0,0 +1,261 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/livekit/protocol/logger"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/storage"
+	"github.com/livekit/livekit-server/pkg/telemetry/prometheus"
+)
+
+const (
+	ArchiveStatusDelivered = "delivered"
+	ArchiveStatusFailed    = "failed"
+
+	// archived payloads are stored as webhooks/<day>/<delivery id>.json, by the day the event was created
+	archivePrefix    = "webhooks/"
+	archiveSuffix    = ".json"
+	ArchiveDayLayout = "2006-01-02"
+
+	archiveTimeout         = 30 * time.Second
+	archiveCleanupInterval = time.Hour
+	archiveCleanupTimeout  = 10 * time.Minute
+
+	resultArchived       = "archived"
+	resultArchiveFailed  = "failed"
+	resultArchiveDropped = "dropped"
+)
+
+var ErrArchivedDeliveryNotFound = errors.New("archived webhook delivery not found")
+
+// ArchiveStorage keeps archived payloads, implemented by storage.Objects
+type ArchiveStorage interface {
+	Put(ctx context.Context, key string, contentType string, data []byte) error
+	// Get returns storage.ErrObjectNotFound when there is no object with the key
+	Get(ctx context.Context, key string) ([]byte, error)
+	List(ctx context.Context, prefix string) ([]string, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// ArchivedDelivery is the record of a delivery that succeeded or failed. A replayed dead letter replaces the
+// record of its failure
+type ArchivedDelivery struct {
+	*Delivery
+	Status     string `json:"status"`
+	ArchivedAt int64  `json:"archived_at"`
+}
+
+// Archive writes the payload of every delivered or failed event to object storage in the background, and
+// deletes payloads older than the retention
+type Archive struct {
+	conf    config.WebHookArchiveConfig
+	storage ArchiveStorage
+	queue   chan *ArchivedDelivery
+
+	// payloads of days before this one were deleted
+	cleanedUntil time.Time
+
+	ctx         context.Context
+	cancel      context.CancelFunc
+	stoppedChan chan struct{}
+}
+
+// NewArchive returns nil when archiving is disabled
+func NewArchive(conf *config.WebHookConfig, storage ArchiveStorage) *Archive {
+	if conf.Archive.StorageProfile == "" || storage == nil {
+		return nil
+	}
+
+	a := &Archive{
+		conf:        conf.Archive,
+		storage:     storage,
+		queue:       make(chan *ArchivedDelivery, conf.Archive.QueueSize),
+		stoppedChan: make(chan struct{}),
+	}
+	a.ctx, a.cancel = context.WithCancel(context.Background())
+	go a.worker()
+	return a
+}
+
+// Stop archives the queued payloads before returning
+func (a *Archive) Stop() {
+	if a == nil {
+		return
+	}
+	a.cancel()
+	<-a.stoppedChan
+}
+
+func (a *Archive) add(d *Delivery, status string) {
+	if a == nil {
+		return
+	}
+
+	// deliveries are updated by later attempts
+	dc := *d
+	select {
+	case a.queue <- &ArchivedDelivery{Delivery: &dc, Status: status}:
+	default:
+		prometheus.RecordWebhookArchived(resultArchiveDropped)
+		logger.Warnw("webhook archive queue full, payload not archived", nil, "deliveryID", d.ID, "event", d.Event)
+	}
+}
+
+// List returns the ids of the deliveries of events created on day, in UTC
+func (a *Archive) List(ctx context.Context, day time.Time) ([]string, error) {
+	keys, err := a.storage.List(ctx, archiveDayPrefix(day))
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(keys))
+	for _, key := range keys {
+		ids = append(ids, strings.TrimSuffix(key[strings.LastIndexByte(key, '/')+1:], archiveSuffix))
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// Load returns ErrArchivedDeliveryNotFound when no delivery with the id was archived on day
+func (a *Archive) Load(ctx context.Context, day time.Time, id string) (*ArchivedDelivery, error) {
+	if id == "" || strings.ContainsAny(id, "/.") {
+		return nil, ErrArchivedDeliveryNotFound
+	}
+
+	data, err := a.storage.Get(ctx, archiveKey(day, id))
+	if errors.Is(err, storage.ErrObjectNotFound) {
+		return nil, ErrArchivedDeliveryNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	ad := &ArchivedDelivery{}
+	if err = json.Unmarshal(data, ad); err != nil {
+		return nil, err
+	}
+	return ad, nil
+}
+
+func (a *Archive) worker() {
+	defer close(a.stoppedChan)
+
+	ticker := time.NewTicker(archiveCleanupInterval)
+	defer ticker.Stop()
+	if a.conf.Retention > 0 {
+		a.cleanup()
+	}
+
+	for {
+		select {
+		case <-a.ctx.Done():
+			for {
+				select {
+				case ad := <-a.queue:
+					a.store(ad)
+				default:
+					return
+				}
+			}
+		case ad := <-a.queue:
+			a.store(ad)
+		case <-ticker.C:
+			if a.conf.Retention > 0 {
+				a.cleanup()
+			}
+		}
+	}
+}
+
+func (a *Archive) store(ad *ArchivedDelivery) {
+	ad.ArchivedAt = time.Now().Unix()
+	data, err := json.Marshal(ad)
+	if err != nil {
+		prometheus.RecordWebhookArchived(resultArchiveFailed)
+		logger.Errorw("could not encode archived webhook", err, "deliveryID", ad.ID)
+		return
+	}
+
+	// queued payloads are still archived when stopping
+	ctx, cancel := context.WithTimeout(context.Background(), archiveTimeout)
+	defer cancel()
+	if err = a.storage.Put(ctx, archiveKey(time.Unix(ad.CreatedAt, 0), ad.ID), "application/json", data); err != nil {
+		prometheus.RecordWebhookArchived(resultArchiveFailed)
+		logger.Errorw("could not archive webhook", err, "deliveryID", ad.ID, "event", ad.Event)
+		return
+	}
+	prometheus.RecordWebhookArchived(resultArchived)
+}
+
+// cleanup deletes the payloads of days that are entirely older than the retention. The whole archive is listed
+// once, later runs only list the days that expired since
+func (a *Archive) cleanup() {
+	ctx, cancel := context.WithTimeout(a.ctx, archiveCleanupTimeout)
+	defer cancel()
+
+	before := archiveDay(time.Now().Add(-a.conf.Retention))
+	var keys []string
+	var err error
+	if a.cleanedUntil.IsZero() {
+		keys, err = a.storage.List(ctx, archivePrefix)
+	} else {
+		for day := a.cleanedUntil; day.Before(before) && err == nil; day = day.AddDate(0, 0, 1) {
+			var dayKeys []string
+			dayKeys, err = a.storage.List(ctx, archiveDayPrefix(day))
+			keys = append(keys, dayKeys...)
+		}
+	}
+	if err != nil {
+		logger.Warnw("could not list archived webhooks", err)
+		return
+	}
+
+	deleted := 0
+	for _, key := range keys {
+		day, err := time.Parse(ArchiveDayLayout, strings.SplitN(strings.TrimPrefix(key, archivePrefix), "/", 2)[0])
+		if err != nil || !day.Before(before) {
+			continue
+		}
+		if err = a.storage.Delete(ctx, key); err != nil {
+			logger.Warnw("could not delete archived webhook", err, "key", key)
+			return
+		}
+		deleted++
+	}
+	a.cleanedUntil = before
+	if deleted > 0 {
+		logger.Infow("deleted archived webhooks past retention", "count", deleted, "before", before.Format(ArchiveDayLayout))
+	}
+}
+
+func archiveDay(t time.Time) time.Time {
+	return t.UTC().Truncate(24 * time.Hour)
+}
+
+func archiveDayPrefix(day time.Time) string {
+	return archivePrefix + archiveDay(day).Format(ArchiveDayLayout) + "/"
+}
+
+func archiveKey(day time.Time, id string) string {
+	return archiveDayPrefix(day) + id + archiveSuffix
+}
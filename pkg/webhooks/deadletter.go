@@ -0,0 +1,105 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhooks
+
+import (
+	"context"
+	"sort"
+
+	"github.com/livekit/protocol/auth"
+	"github.com/livekit/protocol/logger"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/telemetry/prometheus"
+)
+
+// DeadLetterStore keeps deliveries that failed, shared by the nodes of the cluster
+type DeadLetterStore interface {
+	// StoreWebhookDeadLetter adds or replaces d, discarding the oldest dead letters beyond maxSize
+	StoreWebhookDeadLetter(ctx context.Context, d *Delivery, maxSize int) error
+	// LoadWebhookDeadLetter returns ErrDeadLetterNotFound when there is no dead letter with the id
+	LoadWebhookDeadLetter(ctx context.Context, id string) (*Delivery, error)
+	ListWebhookDeadLetters(ctx context.Context) ([]*Delivery, error)
+	DeleteWebhookDeadLetter(ctx context.Context, id string) error
+}
+
+// DeadLetters keeps deliveries that exhausted their attempts or did not fit the queue, so they can be replayed
+type DeadLetters struct {
+	conf    config.WebHookDeadLetterConfig
+	store   DeadLetterStore
+	sender  *sender
+	archive *Archive
+}
+
+// NewDeadLetters returns nil when dead letters are disabled, replays are archived when archive is set
+func NewDeadLetters(conf *config.WebHookConfig, store DeadLetterStore, keyProvider auth.KeyProvider, archive *Archive) *DeadLetters {
+	if !conf.DeadLetter.Enabled || store == nil {
+		return nil
+	}
+	return &DeadLetters{
+		conf:    conf.DeadLetter,
+		store:   store,
+		sender:  newSender(*conf, keyProvider),
+		archive: archive,
+	}
+}
+
+func (q *DeadLetters) add(ctx context.Context, d *Delivery) {
+	if err := q.store.StoreWebhookDeadLetter(ctx, d, q.conf.MaxSize); err != nil {
+		logger.Errorw("could not store webhook dead letter", err, "url", d.URL, "event", d.Event, "deliveryID", d.ID)
+		return
+	}
+	prometheus.RecordWebhookDelivery(d.URL, resultDeadLettered)
+}
+
+// List returns dead letters oldest first
+func (q *DeadLetters) List(ctx context.Context) ([]*Delivery, error) {
+	deliveries, err := q.store.ListWebhookDeadLetters(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(deliveries, func(i, j int) bool {
+		return deliveries[i].FailedAt < deliveries[j].FailedAt
+	})
+	return deliveries, nil
+}
+
+// Replay sends the dead letter once more with a fresh signature. It is removed once delivered, and kept with the
+// error of the attempt otherwise
+func (q *DeadLetters) Replay(ctx context.Context, id string) (*Delivery, error) {
+	d, err := q.store.LoadWebhookDeadLetter(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = q.sender.deliver(ctx, d, 1); err != nil {
+		q.archive.add(d, ArchiveStatusFailed)
+		if sErr := q.store.StoreWebhookDeadLetter(ctx, d, q.conf.MaxSize); sErr != nil {
+			logger.Errorw("could not store webhook dead letter", sErr, "url", d.URL, "deliveryID", d.ID)
+		}
+		return d, err
+	}
+
+	q.archive.add(d, ArchiveStatusDelivered)
+	logger.Infow("replayed webhook", "url", d.URL, "event", d.Event, "deliveryID", d.ID, "attempts", d.Attempts)
+	return d, q.store.DeleteWebhookDeadLetter(ctx, id)
+}
+
+func (q *DeadLetters) Discard(ctx context.Context, id string) error {
+	if _, err := q.store.LoadWebhookDeadLetter(ctx, id); err != nil {
+		return err
+	}
+	return q.store.DeleteWebhookDeadLetter(ctx, id)
+}
@@ -0,0 +1,197 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/livekit/protocol/auth"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/telemetry/prometheus"
+)
+
+const (
+	DeliveryPrefix = "WD_"
+
+	DeliveryIDHeader = "X-Livekit-Delivery-Id"
+	TimestampHeader  = "X-Livekit-Timestamp"
+	SignatureHeader  = "X-Livekit-Signature"
+
+	signatureVersion = "v1="
+
+	resultSuccess      = "success"
+	resultRetry        = "retry"
+	resultFailure      = "failure"
+	resultDeadLettered = "dead_lettered"
+	resultDropped      = "dropped"
+)
+
+var (
+	ErrSecretNotFound     = errors.New("webhook api_key has no secret")
+	ErrInvalidSignature   = errors.New("webhook signature does not match")
+	ErrSignatureExpired   = errors.New("webhook timestamp is outside of the tolerance")
+	ErrDeadLetterNotFound = errors.New("webhook dead letter not found")
+)
+
+// Delivery is a webhook event sent to one URL, kept as dead letter when it could not be delivered
+type Delivery struct {
+	ID    string `json:"id"`
+	URL   string `json:"url"`
+	Event string `json:"event"`
+	// WebhookEvent in its protobuf JSON form
+	Payload json.RawMessage `json:"payload"`
+	// additional HTTP headers
+	Headers   map[string]string `json:"headers,omitempty"`
+	APIKey    string            `json:"api_key"`
+	Attempts  int               `json:"attempts"`
+	LastError string            `json:"last_error,omitempty"`
+	CreatedAt int64             `json:"created_at"`
+	FailedAt  int64             `json:"failed_at,omitempty"`
+}
+
+// sender posts deliveries, signing them with the current secret of their API key
+type sender struct {
+	conf        config.WebHookConfig
+	keyProvider auth.KeyProvider
+	client      *http.Client
+}
+
+func newSender(conf config.WebHookConfig, keyProvider auth.KeyProvider) *sender {
+	return &sender{
+		conf:        conf,
+		keyProvider: keyProvider,
+		client:      &http.Client{Timeout: conf.RequestTimeout},
+	}
+}
+
+// deliver makes up to attempts attempts, backing off exponentially between them. Responses other than
+// 408, 429 and 5xx are not retried
+func (s *sender) deliver(ctx context.Context, d *Delivery, attempts int) error {
+	interval := s.conf.RetryInterval
+	for attempt := 1; ; attempt++ {
+		d.Attempts++
+		retryable, err := s.post(ctx, d)
+		if err == nil {
+			d.LastError = ""
+			prometheus.RecordWebhookDelivery(d.URL, resultSuccess)
+			return nil
+		}
+
+		d.LastError = err.Error()
+		d.FailedAt = time.Now().Unix()
+		if !retryable || attempt >= attempts {
+			prometheus.RecordWebhookDelivery(d.URL, resultFailure)
+			return err
+		}
+		prometheus.RecordWebhookDelivery(d.URL, resultRetry)
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(interval):
+		}
+		if interval *= 2; interval > s.conf.MaxRetryInterval {
+			interval = s.conf.MaxRetryInterval
+		}
+	}
+}
+
+func (s *sender) post(ctx context.Context, d *Delivery) (bool, error) {
+	secret := s.keyProvider.GetSecret(d.APIKey)
+	if secret == "" {
+		return false, ErrSecretNotFound
+	}
+
+	// the token keeps webhooks verifiable by the server SDKs
+	sum := sha256.Sum256(d.Payload)
+	token, err := auth.NewAccessToken(d.APIKey, secret).
+		SetValidFor(5 * time.Minute).
+		SetSha256(base64.StdEncoding.EncodeToString(sum[:])).
+		ToJWT()
+	if err != nil {
+		return false, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return false, err
+	}
+	for name, value := range d.Headers {
+		req.Header.Set(name, value)
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Authorization", token)
+	// custom mime type to ensure signature is checked prior to parsing
+	req.Header.Set("Content-Type", "application/webhook+json")
+	req.Header.Set(DeliveryIDHeader, d.ID)
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, signatureVersion+sign(secret, timestamp, d.Payload))
+
+	start := time.Now()
+	resp, err := s.client.Do(req)
+	prometheus.RecordWebhookRequest(d.URL, time.Since(start))
+	if err != nil {
+		return true, err
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusRequestTimeout, resp.StatusCode == http.StatusTooManyRequests, resp.StatusCode >= 500:
+		return true, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	default:
+		return false, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+}
+
+func sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignature checks the HMAC signature headers of a webhook request with the secret of its API key,
+// rejecting requests signed more than tolerance ago
+func VerifySignature(secret string, header http.Header, body []byte, tolerance time.Duration) error {
+	timestamp, err := strconv.ParseInt(header.Get(TimestampHeader), 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if age := time.Since(time.Unix(timestamp, 0)); age > tolerance || age < -tolerance {
+		return ErrSignatureExpired
+	}
+
+	signature := strings.TrimPrefix(header.Get(SignatureHeader), signatureVersion)
+	if !hmac.Equal([]byte(signature), []byte(sign(secret, timestamp, body))) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
@@ -0,0 +1,189 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhooks
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/atomic"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/livekit/protocol/auth"
+	"github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/logger"
+	"github.com/livekit/protocol/utils"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/telemetry/prometheus"
+)
+
+// Notifier delivers webhook events to URLs in the order they were queued, retrying failed requests with
+// exponential backoff. Events that still fail, or that find the queue of their URL full, are dead lettered
+// when dead letters are enabled and dropped otherwise. The number of dropped events is reported in the next
+// event delivered to the URL. Delivered and failed events are archived when an archive is set.
+type Notifier struct {
+	conf        config.WebHookConfig
+	apiKey      string
+	sender      *sender
+	deadLetters *DeadLetters
+	archive     *Archive
+	urls        []*urlNotifier
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+type urlNotifier struct {
+	url     string
+	queue   chan queuedEvent
+	dropped atomic.Int32
+}
+
+type queuedEvent struct {
+	event   *livekit.WebhookEvent
+	headers map[string]string
+}
+
+type headersKey struct{}
+
+// WithHeader has events queued with the returned context delivered with an additional HTTP header. Headers are
+// not covered by the signature of the payload
+func WithHeader(ctx context.Context, name string, value string) context.Context {
+	headers := map[string]string{name: value}
+	for k, v := range headersFromContext(ctx) {
+		if k != name {
+			headers[k] = v
+		}
+	}
+	return context.WithValue(ctx, headersKey{}, headers)
+}
+
+func headersFromContext(ctx context.Context) map[string]string {
+	headers, _ := ctx.Value(headersKey{}).(map[string]string)
+	return headers
+}
+
+// NewNotifier signs events with apiKey, deadLetters and archive may be nil
+func NewNotifier(
+	conf *config.WebHookConfig,
+	apiKey string,
+	urls []string,
+	keyProvider auth.KeyProvider,
+	deadLetters *DeadLetters,
+	archive *Archive,
+) *Notifier {
+	n := &Notifier{
+		conf:        *conf,
+		apiKey:      apiKey,
+		sender:      newSender(*conf, keyProvider),
+		deadLetters: deadLetters,
+		archive:     archive,
+	}
+	n.ctx, n.cancel = context.WithCancel(context.Background())
+	for _, url := range urls {
+		u := &urlNotifier{
+			url:   url,
+			queue: make(chan queuedEvent, conf.QueueSize),
+		}
+		n.urls = append(n.urls, u)
+		go n.worker(u)
+	}
+	return n
+}
+
+func (n *Notifier) QueueNotify(ctx context.Context, event *livekit.WebhookEvent) error {
+	qe := queuedEvent{event: event, headers: headersFromContext(ctx)}
+	for _, u := range n.urls {
+		select {
+		case u.queue <- qe:
+		default:
+			d, err := n.newDelivery(u, qe)
+			if err == nil {
+				d.LastError = "queue full"
+				d.FailedAt = d.CreatedAt
+				n.archive.add(d, ArchiveStatusFailed)
+			}
+			if err != nil || n.deadLetters == nil {
+				u.dropped.Inc()
+				prometheus.RecordWebhookDelivery(u.url, resultDropped)
+				logger.Warnw("webhook queue full, dropping event", err, "url", u.url, "event", event.Event)
+				continue
+			}
+			n.deadLetters.add(ctx, d)
+		}
+	}
+	return nil
+}
+
+// Stop abandons queued events and retries in progress
+func (n *Notifier) Stop() {
+	n.cancel()
+}
+
+func (n *Notifier) worker(u *urlNotifier) {
+	for {
+		select {
+		case <-n.ctx.Done():
+			return
+		case qe := <-u.queue:
+			n.deliver(u, qe)
+		}
+	}
+}
+
+func (n *Notifier) deliver(u *urlNotifier, qe queuedEvent) {
+	event := qe.event
+	d, err := n.newDelivery(u, qe)
+	if err != nil {
+		logger.Errorw("could not encode webhook event", err, "url", u.url, "event", event.Event)
+		return
+	}
+
+	if err = n.sender.deliver(n.ctx, d, n.conf.MaxAttempts); err != nil {
+		logger.Warnw("failed to send webhook", err, "url", u.url, "event", event.Event, "attempts", d.Attempts)
+		n.archive.add(d, ArchiveStatusFailed)
+		if n.deadLetters != nil && n.ctx.Err() == nil {
+			n.deadLetters.add(n.ctx, d)
+		} else {
+			u.dropped.Inc()
+		}
+		return
+	}
+	logger.Infow("sent webhook", "url", u.url, "event", event.Event, "attempts", d.Attempts)
+	n.archive.add(d, ArchiveStatusDelivered)
+}
+
+func (n *Notifier) newDelivery(u *urlNotifier, qe queuedEvent) (*Delivery, error) {
+	// events are shared by the URLs, the dropped count is per URL
+	event := proto.Clone(qe.event).(*livekit.WebhookEvent)
+	event.NumDropped = u.dropped.Swap(0)
+	payload, err := protojson.Marshal(event)
+	if err != nil {
+		u.dropped.Add(event.NumDropped)
+		return nil, err
+	}
+
+	return &Delivery{
+		ID:        utils.NewGuid(DeliveryPrefix),
+		URL:       u.url,
+		Event:     event.Event,
+		Payload:   payload,
+		Headers:   qe.headers,
+		APIKey:    n.apiKey,
+		CreatedAt: time.Now().Unix(),
+	}, nil
+}
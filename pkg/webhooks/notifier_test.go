@@ -0,0 +1,306 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhooks
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+
+	"github.com/livekit/protocol/auth"
+	"github.com/livekit/protocol/auth/authfakes"
+	"github.com/livekit/protocol/livekit"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/storage"
+)
+
+const (
+	testAPIKey    = "APIabcdefg"
+	testAPISecret = "secret"
+)
+
+type fakeDeadLetterStore struct {
+	lock        sync.Mutex
+	deadLetters map[string]*Delivery
+}
+
+func (f *fakeDeadLetterStore) StoreWebhookDeadLetter(_ context.Context, d *Delivery, _ int) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.deadLetters[d.ID] = d
+	return nil
+}
+
+func (f *fakeDeadLetterStore) LoadWebhookDeadLetter(_ context.Context, id string) (*Delivery, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	d := f.deadLetters[id]
+	if d == nil {
+		return nil, ErrDeadLetterNotFound
+	}
+	return d, nil
+}
+
+func (f *fakeDeadLetterStore) ListWebhookDeadLetters(_ context.Context) ([]*Delivery, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	var deliveries []*Delivery
+	for _, d := range f.deadLetters {
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, nil
+}
+
+func (f *fakeDeadLetterStore) DeleteWebhookDeadLetter(_ context.Context, id string) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	delete(f.deadLetters, id)
+	return nil
+}
+
+type fakeArchiveStorage struct {
+	lock    sync.Mutex
+	objects map[string][]byte
+}
+
+func (f *fakeArchiveStorage) Put(_ context.Context, key string, _ string, data []byte) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.objects[key] = data
+	return nil
+}
+
+func (f *fakeArchiveStorage) Get(_ context.Context, key string) ([]byte, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	data, ok := f.objects[key]
+	if !ok {
+		return nil, storage.ErrObjectNotFound
+	}
+	return data, nil
+}
+
+func (f *fakeArchiveStorage) List(_ context.Context, prefix string) ([]string, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	var keys []string
+	for key := range f.objects {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+func (f *fakeArchiveStorage) Delete(_ context.Context, key string) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	delete(f.objects, key)
+	return nil
+}
+
+func newTestConfig() *config.WebHookConfig {
+	return &config.WebHookConfig{
+		APIKey:           testAPIKey,
+		MaxAttempts:      3,
+		RetryInterval:    time.Millisecond,
+		MaxRetryInterval: 5 * time.Millisecond,
+		RequestTimeout:   time.Second,
+		QueueSize:        10,
+		DeadLetter: config.WebHookDeadLetterConfig{
+			Enabled: true,
+			MaxSize: 10,
+		},
+	}
+}
+
+func newTestKeyProvider() auth.KeyProvider {
+	kp := &authfakes.FakeKeyProvider{}
+	kp.GetSecretReturns(testAPISecret)
+	return kp
+}
+
+func TestNotifierRetries(t *testing.T) {
+	var requests atomic.Int32
+	received := make(chan struct{}, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Inc() == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		if VerifySignature(testAPISecret, r.Header, body, time.Minute) != nil || r.Header.Get(DeliveryIDHeader) == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		received <- struct{}{}
+	}))
+	defer server.Close()
+
+	conf := newTestConfig()
+	store := &fakeDeadLetterStore{deadLetters: make(map[string]*Delivery)}
+	n := NewNotifier(conf, testAPIKey, []string{server.URL}, newTestKeyProvider(), NewDeadLetters(conf, store, newTestKeyProvider(), nil), nil)
+	defer n.Stop()
+
+	require.NoError(t, n.QueueNotify(context.Background(), &livekit.WebhookEvent{Event: "room_started"}))
+	select {
+	case <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook not delivered")
+	}
+	require.Equal(t, int32(2), requests.Load())
+	require.Empty(t, store.deadLetters)
+}
+
+func TestNotifierHeaders(t *testing.T) {
+	received := make(chan http.Header, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header
+	}))
+	defer server.Close()
+
+	conf := newTestConfig()
+	n := NewNotifier(conf, testAPIKey, []string{server.URL}, newTestKeyProvider(), nil, nil)
+	defer n.Stop()
+
+	ctx := WithHeader(context.Background(), "X-Test", "first")
+	ctx = WithHeader(ctx, "X-Other", "value")
+	ctx = WithHeader(ctx, "X-Test", "second")
+	require.NoError(t, n.QueueNotify(ctx, &livekit.WebhookEvent{Event: "room_finished"}))
+	select {
+	case header := <-received:
+		require.Equal(t, "second", header.Get("X-Test"))
+		require.Equal(t, "value", header.Get("X-Other"))
+		require.NotEmpty(t, header.Get(SignatureHeader))
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook not delivered")
+	}
+}
+
+func TestNotifierDeadLetters(t *testing.T) {
+	var fail atomic.Bool
+	fail.Store(true)
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Inc()
+		if fail.Load() {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	conf := newTestConfig()
+	store := &fakeDeadLetterStore{deadLetters: make(map[string]*Delivery)}
+	deadLetters := NewDeadLetters(conf, store, newTestKeyProvider(), nil)
+	n := NewNotifier(conf, testAPIKey, []string{server.URL}, newTestKeyProvider(), deadLetters, nil)
+	defer n.Stop()
+
+	require.NoError(t, n.QueueNotify(context.Background(), &livekit.WebhookEvent{Event: "room_finished"}))
+	var failed []*Delivery
+	require.Eventually(t, func() bool {
+		failed, _ = deadLetters.List(context.Background())
+		return len(failed) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	// client errors are not retried
+	require.Equal(t, int32(1), requests.Load())
+	require.Equal(t, "room_finished", failed[0].Event)
+	require.Equal(t, 1, failed[0].Attempts)
+
+	d, err := deadLetters.Replay(context.Background(), failed[0].ID)
+	require.Error(t, err)
+	require.Equal(t, 2, d.Attempts)
+	require.Len(t, store.deadLetters, 1)
+
+	fail.Store(false)
+	_, err = deadLetters.Replay(context.Background(), failed[0].ID)
+	require.NoError(t, err)
+	require.Empty(t, store.deadLetters)
+
+	_, err = deadLetters.Replay(context.Background(), failed[0].ID)
+	require.ErrorIs(t, err, ErrDeadLetterNotFound)
+}
+
+func TestNotifierArchive(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	conf := newTestConfig()
+	conf.Archive = config.WebHookArchiveConfig{
+		StorageProfile: "archive",
+		Retention:      24 * time.Hour,
+		QueueSize:      10,
+	}
+	objects := &fakeArchiveStorage{objects: map[string][]byte{
+		// past retention
+		"webhooks/2000-01-01/WD_old.json": []byte(`{}`),
+	}}
+	archive := NewArchive(conf, objects)
+	n := NewNotifier(conf, testAPIKey, []string{server.URL, server.URL + "/fail"}, newTestKeyProvider(), nil, archive)
+
+	require.NoError(t, n.QueueNotify(context.Background(), &livekit.WebhookEvent{Event: "participant_joined"}))
+	var ids []string
+	require.Eventually(t, func() bool {
+		ids, _ = archive.List(context.Background(), time.Now())
+		return len(ids) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	n.Stop()
+	archive.Stop()
+
+	statuses := make(map[string]string)
+	for _, id := range ids {
+		ad, err := archive.Load(context.Background(), time.Now(), id)
+		require.NoError(t, err)
+		require.Equal(t, "participant_joined", ad.Event)
+		require.NotZero(t, ad.ArchivedAt)
+		statuses[ad.URL] = ad.Status
+	}
+	require.Equal(t, ArchiveStatusDelivered, statuses[server.URL])
+	require.Equal(t, ArchiveStatusFailed, statuses[server.URL+"/fail"])
+
+	_, err := archive.Load(context.Background(), time.Now(), "WD_unknown")
+	require.ErrorIs(t, err, ErrArchivedDeliveryNotFound)
+	_, err = objects.Get(context.Background(), "webhooks/2000-01-01/WD_old.json")
+	require.ErrorIs(t, err, storage.ErrObjectNotFound)
+}
+
+func TestVerifySignature(t *testing.T) {
+	body := []byte(`{"event":"room_started"}`)
+	timestamp := time.Now().Unix()
+	header := http.Header{}
+	header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	header.Set(SignatureHeader, signatureVersion+sign(testAPISecret, timestamp, body))
+
+	require.NoError(t, VerifySignature(testAPISecret, header, body, time.Minute))
+	require.ErrorIs(t, VerifySignature("other", header, body, time.Minute), ErrInvalidSignature)
+	require.ErrorIs(t, VerifySignature(testAPISecret, header, []byte(`{}`), time.Minute), ErrInvalidSignature)
+
+	header.Set(TimestampHeader, strconv.FormatInt(timestamp-120, 10))
+	header.Set(SignatureHeader, signatureVersion+sign(testAPISecret, timestamp-120, body))
+	require.ErrorIs(t, VerifySignature(testAPISecret, header, body, time.Minute), ErrSignatureExpired)
+}